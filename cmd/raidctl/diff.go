@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// change is one difference between two JSON documents
+type change struct {
+	Op   byte // '+' added, '-' removed, '~' changed
+	Path string
+	Old  interface{}
+	New  interface{}
+}
+
+func (c change) String() string {
+	switch c.Op {
+	case '+':
+		return fmt.Sprintf("+ %s: %s", c.Path, compact(c.New))
+	case '-':
+		return fmt.Sprintf("- %s: %s", c.Path, compact(c.Old))
+	default:
+		return fmt.Sprintf("~ %s: %s -> %s", c.Path, compact(c.Old), compact(c.New))
+	}
+}
+
+// diffJSON compares two decoded JSON documents and returns the changes in
+// path order. Objects are compared key by key and arrays element by element.
+func diffJSON(a, b interface{}) []change {
+	var changes []change
+	diffValue("", a, b, &changes)
+	return changes
+}
+
+func diffValue(path string, a, b interface{}, changes *[]change) {
+	switch o := a.(type) {
+	case map[string]interface{}:
+		if n, ok := b.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(o)+len(n))
+			for k := range o {
+				keys = append(keys, k)
+			}
+			for k := range n {
+				if _, ok := o[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				ov, inOld := o[k]
+				nv, inNew := n[k]
+				switch {
+				case !inOld:
+					*changes = append(*changes, change{Op: '+', Path: path + "." + k, New: nv})
+				case !inNew:
+					*changes = append(*changes, change{Op: '-', Path: path + "." + k, Old: ov})
+				default:
+					diffValue(path+"."+k, ov, nv, changes)
+				}
+			}
+			return
+		}
+	case []interface{}:
+		if n, ok := b.([]interface{}); ok {
+			for i := 0; i < len(o) || i < len(n); i++ {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(o):
+					*changes = append(*changes, change{Op: '+', Path: p, New: n[i]})
+				case i >= len(n):
+					*changes = append(*changes, change{Op: '-', Path: p, Old: o[i]})
+				default:
+					diffValue(p, o[i], n[i], changes)
+				}
+			}
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		if path == "" {
+			path = "."
+		}
+		*changes = append(*changes, change{Op: '~', Path: path, Old: a, New: b})
+	}
+}
+
+func compact(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+// Check outcomes
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// checkResult is one line of the doctor report
+type checkResult struct {
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Detail  string  `json:"detail"`
+	Latency float64 `json:"latencyMs,omitempty"`
+}
+
+// doctor runs the checks against one server
+type doctor struct {
+	env        *cliEnv
+	maxLatency time.Duration
+	results    []checkResult
+	// operator is set once the credentials are known to grant operator
+	// access
+	operator bool
+}
+
+func runDoctor(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("doctor")
+	maxLatency := fs.Duration("max-latency", 500*time.Millisecond, "warn when a request or the storage health check takes longer")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	d := &doctor{env: env, maxLatency: *maxLatency}
+	d.checkHealth(ctx)
+	d.checkAuth(ctx)
+	d.checkOperatorAccess(ctx)
+	d.checkStorage(ctx)
+	d.checkResolution(ctx)
+
+	failed := 0
+	for _, r := range d.results {
+		if r.Status == checkFail {
+			failed++
+		}
+	}
+	if *asJSON {
+		if err := env.printJSON(d.results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range d.results {
+			fmt.Fprintf(env.stdout, "%-4s  %-16s %s\n", r.Status, r.Name, r.Detail)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(d.results))
+	}
+	return nil
+}
+
+// add records a result
+func (d *doctor) add(name, status string, latency time.Duration, format string, args ...interface{}) {
+	d.results = append(d.results, checkResult{
+		Name:    name,
+		Status:  status,
+		Detail:  fmt.Sprintf(format, args...),
+		Latency: float64(latency.Microseconds()) / 1000,
+	})
+}
+
+// timed runs fn and adds a pass, or a warning when it was slow, or a
+// failure when it returned an error
+func (d *doctor) timed(name string, fn func() (string, error)) bool {
+	start := time.Now()
+	detail, err := fn()
+	latency := time.Since(start)
+	switch {
+	case err != nil:
+		d.add(name, checkFail, latency, "%v", err)
+		return false
+	case latency > d.maxLatency:
+		d.add(name, checkWarn, latency, "%s, but took %s", detail, latency.Round(time.Millisecond))
+	default:
+		d.add(name, checkPass, latency, "%s (%s)", detail, latency.Round(time.Millisecond))
+	}
+	return true
+}
+
+func (d *doctor) checkHealth(ctx context.Context) {
+	d.timed("health", func() (string, error) {
+		return "server is up", d.env.client.Health(ctx)
+	})
+}
+
+// checkAuth probes the admin API with an invalid token. With auth enabled
+// the token itself is rejected; with auth disabled it is ignored and the
+// request fails for lack of a role, locking operators out.
+func (d *doctor) checkAuth(ctx context.Context) {
+	anon := raid.NewClient(d.env.server, raid.WithRetry(raid.RetryPolicy{}), raid.WithUserAgent("raidctl"), raid.WithToken("invalid"))
+	_, err := anon.Maintenance(ctx)
+	var apiErr *raid.Error
+	switch {
+	case err == nil:
+		d.add("auth", checkFail, 0, "admin API accepts an invalid token")
+	case !errors.As(err, &apiErr):
+		d.add("auth", checkFail, 0, "%v", err)
+	case apiErr.StatusCode == http.StatusUnauthorized && apiErr.Message == "Invalid or expired token":
+		d.add("auth", checkPass, 0, "auth enabled; invalid tokens are rejected")
+	case apiErr.StatusCode == http.StatusUnauthorized:
+		d.add("auth", checkWarn, 0, "auth disabled; the admin and debug APIs are unreachable")
+	default:
+		d.add("auth", checkWarn, 0, "unexpected response from admin API: %v", err)
+	}
+}
+
+// checkOperatorAccess checks that the given credentials grant operator
+// access and reports read-only mode
+func (d *doctor) checkOperatorAccess(ctx context.Context) {
+	if !d.env.authenticated {
+		d.add("operator-access", checkSkip, 0, "no credentials given; pass -token to check operator access")
+		return
+	}
+	var status *raid.MaintenanceStatus
+	ok := d.timed("operator-access", func() (string, error) {
+		var err error
+		status, err = d.env.client.Maintenance(ctx)
+		return "credentials grant operator access", err
+	})
+	if !ok {
+		return
+	}
+	d.operator = true
+	if status.Enabled {
+		d.add("read-only", checkWarn, 0, "read-only mode since %s: %s", status.Since.Format(time.RFC3339), status.Reason)
+	} else {
+		d.add("read-only", checkPass, 0, "writes are accepted")
+	}
+}
+
+// checkStorage runs the server's storage health check, or times a listing
+// without operator access
+func (d *doctor) checkStorage(ctx context.Context) {
+	if !d.operator {
+		d.timed("storage", func() (string, error) {
+			_, err := d.env.client.ListPublicRAiDs(ctx, &raid.ListOptions{Limit: 1})
+			return "listing RAiDs succeeded", err
+		})
+		return
+	}
+
+	status, err := d.env.client.StorageStatus(ctx)
+	if err != nil {
+		d.add("storage", checkFail, 0, "%v", err)
+		return
+	}
+	latency, _ := time.ParseDuration(status.HealthCheckLatency)
+	switch {
+	case !status.Healthy:
+		d.add("storage", checkFail, latency, "%s backend unhealthy: %s", status.Type, status.Error)
+	case latency > d.maxLatency:
+		d.add("storage", checkWarn, latency, "%s backend healthy, but its health check took %s", status.Type, status.HealthCheckLatency)
+	default:
+		d.add("storage", checkPass, latency, "%s backend healthy (%s)", status.Type, status.HealthCheckLatency)
+	}
+}
+
+// checkResolution resolves the handle of a public RAiD
+func (d *doctor) checkResolution(ctx context.Context) {
+	page, err := d.env.client.ListPublicRAiDs(ctx, &raid.ListOptions{Limit: 1})
+	if err != nil {
+		d.add("resolution", checkFail, 0, "listing public RAiDs: %v", err)
+		return
+	}
+	if len(page) == 0 || page[0].Identifier == nil {
+		d.add("resolution", checkSkip, 0, "no public RAiDs to resolve")
+		return
+	}
+	id := page[0].Identifier.ID
+	prefix, suffix, err := raid.ParseHandle(id)
+	if err != nil {
+		d.add("resolution", checkFail, 0, "listed RAiD has an invalid identifier: %v", err)
+		return
+	}
+	d.timed("resolution", func() (string, error) {
+		r, err := d.env.client.GetRAiD(ctx, prefix, suffix)
+		if err != nil {
+			return "", err
+		}
+		if r.Identifier == nil || r.Identifier.ID != id {
+			return "", fmt.Errorf("%s/%s resolved to a different RAiD", prefix, suffix)
+		}
+		return fmt.Sprintf("%s/%s resolves", prefix, suffix), nil
+	})
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/leifj/go-raid/internal/workpool"
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+// Record types in an NDJSON dump
+const (
+	dumpServicePoint = "servicePoint"
+	dumpRAiD         = "raid"
+)
+
+// dumpRecord is one line of a dump written by export and read by import.
+// Service points come first so that import can map their IDs before the
+// RAiDs that reference them.
+type dumpRecord struct {
+	Type         string             `json:"type"`
+	ServicePoint *raid.ServicePoint `json:"servicePoint,omitempty"`
+	// Versions holds every version of a RAiD, oldest first, or only the
+	// current one when exported with -history=false
+	Versions []*raid.RAiD `json:"versions,omitempty"`
+}
+
+// importSummary is printed by import
+type importSummary struct {
+	ServicePoints int `json:"servicePoints"`
+	RAiDs         int `json:"raids"`
+	Versions      int `json:"versions"`
+	Skipped       int `json:"skipped"`
+}
+
+func runExport(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("export")
+	out := fs.String("o", "-", "file to write the dump to (- for stdout)")
+	history := fs.Bool("history", true, "include every version of each RAiD, not only the current one")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	w := env.stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	sps, err := env.client.ListServicePoints(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sp := range sps {
+		if err := enc.Encode(dumpRecord{Type: dumpServicePoint, ServicePoint: sp}); err != nil {
+			return err
+		}
+	}
+
+	raids := 0
+	for r, err := range env.client.RAiDs(ctx, raid.ListOptions{}) {
+		if err != nil {
+			return err
+		}
+		versions := []*raid.RAiD{r}
+		if *history && r.Identifier != nil && r.Identifier.Version > 1 {
+			if versions, err = env.versions(ctx, r); err != nil {
+				return err
+			}
+		}
+		if err := enc.Encode(dumpRecord{Type: dumpRAiD, Versions: versions}); err != nil {
+			return err
+		}
+		raids++
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "Exported %d service points and %d RAiDs\n", len(sps), raids)
+	return nil
+}
+
+// versions fetches all versions of a RAiD, oldest first, ending with current
+func (env *cliEnv) versions(ctx context.Context, current *raid.RAiD) ([]*raid.RAiD, error) {
+	prefix, suffix, err := raid.ParseHandle(current.Identifier.ID)
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*raid.RAiD, 0, current.Identifier.Version)
+	for v := 1; v < current.Identifier.Version; v++ {
+		r, err := env.client.GetRAiDVersion(ctx, prefix, suffix, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s version %d: %w", prefix, suffix, v, err)
+		}
+		versions = append(versions, r)
+	}
+	return append(versions, current), nil
+}
+
+func runImport(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("import")
+	file := fs.String("f", "", "NDJSON dump written by export (- for stdin)")
+	skipExisting := fs.Bool("skip-existing", false, "skip RAiDs that already exist instead of failing")
+	workers := fs.Int("workers", workpool.DefaultWorkers, "RAiDs imported at once")
+	rate := fs.Float64("rate", 0, "RAiDs started per second at most (0 for no limit)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return env.usageError("usage: raidctl import -f FILE [-skip-existing] [-workers N] [-rate N]")
+	}
+
+	var r io.Reader = env.stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	// Service points get new IDs on the target server; RAiD owners are
+	// rewritten to match. RAiDs are imported in parallel and share spIDs
+	// and summary with the main loop.
+	var mu sync.Mutex
+	spIDs := map[int64]int64{}
+	var summary importSummary
+	pool := workpool.New(ctx, workpool.Options{Workers: *workers, Rate: *rate})
+	dec := json.NewDecoder(bufio.NewReader(r))
+	err := func() error {
+		for line := 1; ; line++ {
+			var rec dumpRecord
+			if err := dec.Decode(&rec); err == io.EOF {
+				return nil
+			} else if err != nil {
+				return fmt.Errorf("%s: record %d: %w", *file, line, err)
+			}
+
+			switch rec.Type {
+			case dumpServicePoint:
+				if rec.ServicePoint == nil {
+					return fmt.Errorf("%s: record %d: missing service point", *file, line)
+				}
+				oldID := rec.ServicePoint.ID
+				rec.ServicePoint.ID = 0
+				sp, err := env.client.CreateServicePoint(ctx, rec.ServicePoint)
+				if err != nil {
+					return fmt.Errorf("create service point %q: %w", rec.ServicePoint.Name, err)
+				}
+				mu.Lock()
+				spIDs[oldID] = sp.ID
+				summary.ServicePoints++
+				mu.Unlock()
+			case dumpRAiD:
+				err := pool.Go(fmt.Sprintf("%s: record %d", *file, line), func(ctx context.Context) error {
+					imported, err := env.importRAiD(ctx, rec.Versions, func(id int64) (int64, bool) {
+						mu.Lock()
+						defer mu.Unlock()
+						newID, ok := spIDs[id]
+						return newID, ok
+					})
+					mu.Lock()
+					defer mu.Unlock()
+					switch {
+					case raid.IsConflict(err) && *skipExisting:
+						summary.Skipped++
+					case err != nil:
+						return err
+					default:
+						summary.RAiDs++
+						summary.Versions += imported
+					}
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("%s: record %d: unknown record type %q", *file, line, rec.Type)
+			}
+		}
+	}()
+	if poolErr := pool.Wait(); poolErr != nil && err == nil {
+		err = poolErr
+	}
+	if err != nil {
+		return err
+	}
+	return env.printJSON(summary)
+}
+
+// importRAiD mints the first version of a RAiD and replays the later ones as
+// updates, with owners renumbered by spID. It returns the number of versions
+// written.
+func (env *cliEnv) importRAiD(ctx context.Context, versions []*raid.RAiD, spID func(int64) (int64, bool)) (int, error) {
+	if len(versions) == 0 || versions[0].Identifier == nil {
+		return 0, fmt.Errorf("RAiD record without an identifier")
+	}
+	for _, v := range versions {
+		if v.Identifier != nil && v.Identifier.Owner != nil {
+			if id, ok := spID(v.Identifier.Owner.ServicePoint); ok {
+				v.Identifier.Owner.ServicePoint = id
+			}
+		}
+	}
+
+	minted, err := env.client.MintRAiD(ctx, versions[0])
+	if err != nil {
+		return 0, err
+	}
+	prefix, suffix, err := raid.ParseHandle(minted.Identifier.ID)
+	if err != nil {
+		return 1, err
+	}
+	for i, v := range versions[1:] {
+		// Each version replaces the one imported before it, whatever the
+		// versions were numbered in the dump
+		if v.Identifier != nil {
+			v.Identifier.Version = minted.Identifier.Version + i
+		}
+		if _, err := env.client.UpdateRAiD(ctx, prefix, suffix, v); err != nil {
+			return i + 1, fmt.Errorf("%s/%s: %w", prefix, suffix, err)
+		}
+	}
+	return len(versions), nil
+}
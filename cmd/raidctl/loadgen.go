@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/loadgen"
+)
+
+func runLoadgen(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("loadgen")
+	concurrency := fs.Int("c", 10, "number of concurrent workers")
+	duration := fs.Duration("d", 30*time.Second, "test duration (0 to run until -n requests are done)")
+	requests := fs.Int("n", 0, "stop after this many requests (default: run for -d)")
+	mix := fs.String("mix", "mint=10,read=80,update=10", "relative weights of the operations")
+	warmup := fs.Int("warmup", 20, "RAiDs to mint before measuring")
+	randSeed := fs.Int64("seed", time.Now().UnixNano(), "random seed for the generated documents")
+	servicePoint := fs.Int64("service-point", 0, "service point that owns the minted RAiDs")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	m, err := loadgen.ParseMix(*mix)
+	if err != nil {
+		return env.usageError("%v", err)
+	}
+	if *concurrency < 1 || *duration < 0 || *requests < 0 || *warmup < 0 {
+		return env.usageError("-c must be positive and -d, -n and -warmup must not be negative")
+	}
+
+	fmt.Fprintf(env.stderr, "Running %s with %d workers...\n", *mix, *concurrency)
+	report, err := loadgen.Run(ctx, env.client, loadgen.Options{
+		Concurrency:  *concurrency,
+		Duration:     *duration,
+		Requests:     *requests,
+		Warmup:       *warmup,
+		Mix:          m,
+		Seed:         *randSeed,
+		ServicePoint: *servicePoint,
+	})
+	if err != nil {
+		return err
+	}
+	return env.printJSON(report)
+}
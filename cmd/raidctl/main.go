@@ -0,0 +1,178 @@
+// Command raidctl is a command-line client for the go-RAiD HTTP API.
+//
+// Usage:
+//
+//	raidctl [global flags] <command> [flags] [arguments]
+//
+// Responses are printed to stdout as JSON so that output can be piped to
+// tools such as jq. The exit status is 0 on success, 1 when a request fails
+// and 2 for usage errors.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+// errUsage is returned by commands for invalid arguments; the usage message
+// has already been printed
+var errUsage = errors.New("usage error")
+
+// command is a raidctl subcommand
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, env *cliEnv, args []string) error
+}
+
+var commands = []command{
+	{"mint", "-f FILE", "mint a RAiD from a JSON file (- for stdin)", runMint},
+	{"get", "PREFIX/SUFFIX [-version N]", "fetch a RAiD or one of its versions", runGet},
+	{"update", "PREFIX/SUFFIX -f FILE", "replace a RAiD with the contents of a JSON file", runUpdate},
+	{"delete", "PREFIX/SUFFIX", "delete a RAiD", runDelete},
+	{"list", "[-public] [-contributor ID] [-organisation ID] [-limit N] [-offset N] [-ids]", "list RAiDs", runList},
+	{"history", "PREFIX/SUFFIX", "list the changes made by each version of a RAiD", runHistory},
+	{"diff", "PREFIX/SUFFIX [-from N] [-to N]", "show the changes between two versions (default: the latest change)", runDiff},
+	{"doctor", "[-max-latency D] [-json]", "check a deployment: health, auth, storage latency and identifier resolution", runDoctor},
+	{"export", "[-o FILE] [-history=false]", "write service points and RAiDs with their history as NDJSON", runExport},
+	{"import", "-f FILE [-skip-existing] [-workers N] [-rate N]", "load an NDJSON dump written by export", runImport},
+	{"loadgen", "[-c N] [-d D | -n N] [-mix mint=W,read=W,update=W] [-warmup N]", "drive a synthetic mint/read/update load and report latencies", runLoadgen},
+	{"sp", "list | get ID | create -f FILE | update ID -f FILE | enable ID | disable ID [-y] | set-prefix ID PREFIX [-y] | rotate-key ID -user ID", "manage service points; create reads JSON or YAML and accepts several at once", runServicePoint},
+	{"token", "-user ID [-roles R,...] [-service-point N] [-ttl D]", "sign an access token with the server's JWT secret", runToken},
+}
+
+// cliEnv carries the global options and output streams to commands
+type cliEnv struct {
+	client *raid.Client
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// server is the base URL of the server and authenticated whether
+	// credentials were given, for commands that probe the server itself
+	server        string
+	authenticated bool
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run parses the global flags, dispatches to a command and returns the exit
+// status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("raidctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	server := fs.String("server", envOr("RAIDCTL_SERVER", "http://localhost:8080"), "base URL of the go-RAiD server (RAIDCTL_SERVER)")
+	token := fs.String("token", os.Getenv("RAIDCTL_TOKEN"), "bearer token (RAIDCTL_TOKEN)")
+	tokenFile := fs.String("token-file", os.Getenv("RAIDCTL_TOKEN_FILE"), "read the bearer token from a file (RAIDCTL_TOKEN_FILE)")
+	apiKey := fs.String("api-key", os.Getenv("RAIDCTL_API_KEY"), "API key sent as X-API-Key, for gateways that authenticate API keys (RAIDCTL_API_KEY)")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	fs.Usage = func() { usage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		usage(fs)
+		return 2
+	}
+
+	if *tokenFile != "" {
+		data, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			fmt.Fprintf(stderr, "raidctl: %v\n", err)
+			return 2
+		}
+		*token = strings.TrimSpace(string(data))
+	}
+
+	name := fs.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name != name {
+			continue
+		}
+		env := &cliEnv{
+			client: raid.NewClient(*server,
+				raid.WithTimeout(*timeout),
+				raid.WithAuth(raid.MultiAuth(raid.BearerToken(*token), raid.APIKey(*apiKey))),
+				raid.WithUserAgent("raidctl")),
+			stdin:         stdin,
+			stdout:        stdout,
+			stderr:        stderr,
+			server:        *server,
+			authenticated: *token != "" || *apiKey != "",
+		}
+		err := cmd.run(context.Background(), env, fs.Args()[1:])
+		switch {
+		case err == nil:
+			return 0
+		case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+			return 2
+		default:
+			fmt.Fprintf(stderr, "raidctl %s: %v\n", name, err)
+			return 1
+		}
+	}
+
+	fmt.Fprintf(stderr, "raidctl: unknown command %q\n", name)
+	usage(fs)
+	return 2
+}
+
+func usage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintf(w, "Usage: raidctl [global flags] <command> [flags] [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n  %-8s     %s\n", cmd.name, cmd.args, "", cmd.summary)
+	}
+	fmt.Fprintf(w, "\nGlobal flags:\n")
+	fs.PrintDefaults()
+}
+
+// newFlagSet creates the flag set for a command; parse errors and -h are
+// reported on stderr
+func (env *cliEnv) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("raidctl "+name, flag.ContinueOnError)
+	fs.SetOutput(env.stderr)
+	return fs
+}
+
+// usageError prints a message and returns errUsage
+func (env *cliEnv) usageError(format string, args ...interface{}) error {
+	fmt.Fprintf(env.stderr, format+"\n", args...)
+	return errUsage
+}
+
+// parseArgs parses flags that may appear before or after positional
+// arguments and returns the positional arguments
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
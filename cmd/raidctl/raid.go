@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+// readInput decodes a JSON document from a file, or stdin for "-"
+func (env *cliEnv) readInput(path string, v interface{}) error {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(env.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s does not contain a valid document: %w", path, err)
+	}
+	return nil
+}
+
+// printJSON writes a value as indented JSON
+func (env *cliEnv) printJSON(v interface{}) error {
+	enc := json.NewEncoder(env.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// handleArg parses the single PREFIX/SUFFIX argument of a command
+func (env *cliEnv) handleArg(name string, args []string) (string, string, error) {
+	if len(args) != 1 {
+		return "", "", env.usageError("usage: raidctl %s PREFIX/SUFFIX", name)
+	}
+	prefix, suffix, err := raid.ParseHandle(args[0])
+	if err != nil {
+		return "", "", env.usageError("%v", err)
+	}
+	return prefix, suffix, nil
+}
+
+func runMint(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("mint")
+	file := fs.String("f", "", "JSON file with the RAiD to mint (- for stdin)")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *file == "" {
+		return env.usageError("usage: raidctl mint -f FILE")
+	}
+
+	var in raid.RAiD
+	if err := env.readInput(*file, &in); err != nil {
+		return err
+	}
+	r, err := env.client.MintRAiD(ctx, &in)
+	if err != nil {
+		return err
+	}
+	return env.printJSON(r)
+}
+
+func runGet(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("get")
+	version := fs.Int("version", 0, "fetch this version instead of the current one")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	prefix, suffix, err := env.handleArg("get", positional)
+	if err != nil {
+		return err
+	}
+
+	var r *raid.RAiD
+	if *version > 0 {
+		r, err = env.client.GetRAiDVersion(ctx, prefix, suffix, *version)
+	} else {
+		r, err = env.client.GetRAiD(ctx, prefix, suffix)
+	}
+	if err != nil {
+		return err
+	}
+	return env.printJSON(r)
+}
+
+func runUpdate(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("update")
+	file := fs.String("f", "", "JSON file with the new RAiD contents (- for stdin)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	prefix, suffix, err := env.handleArg("update", positional)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		return env.usageError("usage: raidctl update PREFIX/SUFFIX -f FILE")
+	}
+
+	var in raid.RAiD
+	if err := env.readInput(*file, &in); err != nil {
+		return err
+	}
+	r, err := env.client.UpdateRAiD(ctx, prefix, suffix, &in)
+	if err != nil {
+		return err
+	}
+	return env.printJSON(r)
+}
+
+func runDelete(ctx context.Context, env *cliEnv, args []string) error {
+	prefix, suffix, err := env.handleArg("delete", args)
+	if err != nil {
+		return err
+	}
+	if err := env.client.DeleteRAiD(ctx, prefix, suffix); err != nil {
+		return err
+	}
+	fmt.Fprintf(env.stderr, "Deleted %s/%s\n", prefix, suffix)
+	return nil
+}
+
+func runList(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("list")
+	public := fs.Bool("public", false, "list public RAiDs only")
+	contributor := fs.String("contributor", "", "only RAiDs with this contributor ID")
+	organisation := fs.String("organisation", "", "only RAiDs with this organisation ID")
+	limit := fs.Int("limit", 0, "maximum number of RAiDs (default: all)")
+	offset := fs.Int("offset", 0, "number of RAiDs to skip")
+	ids := fs.Bool("ids", false, "print one identifier per line instead of JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	if *public && (*contributor != "" || *organisation != "") {
+		return env.usageError("-contributor and -organisation cannot be combined with -public")
+	}
+
+	opts := raid.ListOptions{ContributorID: *contributor, OrganisationID: *organisation, Offset: *offset}
+	seq := env.client.RAiDs(ctx, opts)
+	if *public {
+		seq = env.client.PublicRAiDs(ctx, opts)
+	}
+
+	raids := []*raid.RAiD{}
+	for r, err := range seq {
+		if err != nil {
+			return err
+		}
+		if *ids && r.Identifier != nil {
+			fmt.Fprintln(env.stdout, r.Identifier.ID)
+		}
+		raids = append(raids, r)
+		if *limit > 0 && len(raids) >= *limit {
+			break
+		}
+	}
+	if *ids {
+		return nil
+	}
+	return env.printJSON(raids)
+}
+
+func runHistory(ctx context.Context, env *cliEnv, args []string) error {
+	prefix, suffix, err := env.handleArg("history", args)
+	if err != nil {
+		return err
+	}
+	history, err := env.client.RAiDHistory(ctx, prefix, suffix)
+	if err != nil {
+		return err
+	}
+	return env.printJSON(history)
+}
+
+func runDiff(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("diff")
+	from := fs.Int("from", 0, "older version (default: the version before -to)")
+	to := fs.Int("to", 0, "newer version (default: the current version)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	prefix, suffix, err := env.handleArg("diff", positional)
+	if err != nil {
+		return err
+	}
+
+	fetch := func(version int) (*raid.RAiD, error) {
+		if version > 0 {
+			return env.client.GetRAiDVersion(ctx, prefix, suffix, version)
+		}
+		return env.client.GetRAiD(ctx, prefix, suffix)
+	}
+
+	newer, err := fetch(*to)
+	if err != nil {
+		return err
+	}
+	newVersion := *to
+	if newer.Identifier != nil {
+		newVersion = newer.Identifier.Version
+	}
+	oldVersion := *from
+	if oldVersion == 0 {
+		oldVersion = newVersion - 1
+	}
+	if oldVersion < 1 {
+		fmt.Fprintf(env.stderr, "%s/%s has only one version\n", prefix, suffix)
+		return nil
+	}
+	older, err := fetch(oldVersion)
+	if err != nil {
+		return err
+	}
+
+	a, err := toGeneric(older)
+	if err != nil {
+		return err
+	}
+	b, err := toGeneric(newer)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(env.stdout, "--- %s/%s version %d\n+++ %s/%s version %d\n", prefix, suffix, oldVersion, prefix, suffix, newVersion)
+	for _, change := range diffJSON(a, b) {
+		fmt.Fprintln(env.stdout, change)
+	}
+	return nil
+}
+
+// toGeneric converts a value to its decoded JSON form for diffing
+func toGeneric(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/api"
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/handlers"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/storage/file"
+	"github.com/leifj/go-raid/pkg/raid"
+	"github.com/leifj/go-raid/pkg/server"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	raids := handlers.NewRAiDHandler(repo, handlers.DocumentLimits{})
+	sps := handlers.NewServicePointHandler(repo)
+	r := chi.NewRouter()
+	r.Route(raid.APIVersion, func(r chi.Router) {
+		api.HandlerFromMux(api.NewServer(raids, sps), r)
+		r.Delete("/raid/{prefix}/{suffix}", raids.DeleteRAiD)
+	})
+
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func runCLI(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRaidctl_Lifecycle(t *testing.T) {
+	srv := newTestServer(t)
+	dir := t.TempDir()
+	raidFile := filepath.Join(dir, "raid.json")
+	os.WriteFile(raidFile, []byte(`{"identifier":{"id":"https://raid.org/10.99999/abc"},"title":[{"text":"First"}]}`), 0644)
+
+	if code, _, stderr := runCLI(t, "", "-server", srv.URL, "mint", "-f", raidFile); code != 0 {
+		t.Fatalf("mint failed (%d): %s", code, stderr)
+	}
+
+	update := `{"identifier":{"id":"https://raid.org/10.99999/abc"},"title":[{"text":"Second"}]}`
+	if code, _, stderr := runCLI(t, update, "-server", srv.URL, "update", "10.99999/abc", "-f", "-"); code != 0 {
+		t.Fatalf("update failed (%d): %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI(t, "", "-server", srv.URL, "get", "https://raid.org/10.99999/abc")
+	if code != 0 {
+		t.Fatalf("get failed (%d): %s", code, stderr)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal([]byte(stdout), &got); err != nil {
+		t.Fatalf("get did not print JSON: %v", err)
+	}
+
+	code, stdout, _ = runCLI(t, "", "-server", srv.URL, "diff", "10.99999/abc")
+	if code != 0 || !strings.Contains(stdout, `~ .title[0].text: "First" -> "Second"`) {
+		t.Errorf("unexpected diff (%d):\n%s", code, stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "", "-server", srv.URL, "list", "-ids")
+	if code != 0 || strings.TrimSpace(stdout) != "https://raid.org/10.99999/abc" {
+		t.Errorf("unexpected list (%d): %q", code, stdout)
+	}
+
+	if code, _, stderr := runCLI(t, "", "-server", srv.URL, "delete", "10.99999/abc"); code != 0 {
+		t.Fatalf("delete failed (%d): %s", code, stderr)
+	}
+	code, _, stderr = runCLI(t, "", "-server", srv.URL, "get", "10.99999/abc")
+	if code != 1 || !strings.Contains(stderr, "404") {
+		t.Errorf("expected get after delete to fail with 404, got %d: %s", code, stderr)
+	}
+}
+
+func TestRaidctl_ServicePoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	code, stdout, stderr := runCLI(t, `{"name":"Test SP","prefix":"10.99999"}`, "-server", srv.URL, "sp", "create", "-f", "-")
+	if code != 0 {
+		t.Fatalf("sp create failed (%d): %s", code, stderr)
+	}
+	var sp struct{ ID int64 }
+	json.Unmarshal([]byte(stdout), &sp)
+
+	code, stdout, _ = runCLI(t, "", "-server", srv.URL, "sp", "get", strconv.FormatInt(sp.ID, 10))
+	if code != 0 || !strings.Contains(stdout, "Test SP") {
+		t.Errorf("unexpected sp get (%d): %s", code, stdout)
+	}
+
+	if code, _, _ := runCLI(t, "", "-server", srv.URL, "sp", "get", "abc"); code != 2 {
+		t.Errorf("expected usage error for invalid ID, got %d", code)
+	}
+}
+
+func TestRaidctl_Token(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "", "token", "-secret", "s3cret", "-user", "alice", "-roles", "operator")
+	if code != 0 {
+		t.Fatalf("token failed (%d): %s", code, stderr)
+	}
+
+	auth := middleware.JWTAuth(&config.AuthConfig{Enabled: true, JWTSecret: "s3cret"})
+	var user string
+	handler := auth(middleware.RequireRole(middleware.RoleOperator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = middleware.GetUserID(r.Context())
+	})))
+	req := httptest.NewRequest(http.MethodGet, "/admin/stats", nil)
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(stdout))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK || user != "alice" {
+		t.Errorf("expected token to authenticate alice as operator, got %d for %q", rr.Code, user)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	a := map[string]interface{}{"a": 1.0, "b": []interface{}{"x"}, "c": "gone"}
+	b := map[string]interface{}{"a": 2.0, "b": []interface{}{"x", "y"}, "d": true}
+
+	var got []string
+	for _, c := range diffJSON(a, b) {
+		got = append(got, c.String())
+	}
+	want := []string{"~ .a: 1 -> 2", `+ .b[1]: "y"`, `- .c: "gone"`, "+ .d: true"}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestRaidctl_ExportImport(t *testing.T) {
+	src := newTestServer(t)
+	_, stdout, _ := runCLI(t, `{"name":"Source SP","prefix":"10.99999"}`, "-server", src.URL, "sp", "create", "-f", "-")
+	var sp struct{ ID int64 }
+	json.Unmarshal([]byte(stdout), &sp)
+	owner := `"owner":{"servicePoint":` + strconv.FormatInt(sp.ID, 10) + `}`
+	runCLI(t, `{"identifier":{"id":"https://raid.org/10.99999/abc",`+owner+`},"title":[{"text":"First"}]}`, "-server", src.URL, "mint", "-f", "-")
+	runCLI(t, `{"identifier":{"id":"https://raid.org/10.99999/abc",`+owner+`},"title":[{"text":"Second"}]}`, "-server", src.URL, "update", "10.99999/abc", "-f", "-")
+
+	dump := filepath.Join(t.TempDir(), "dump.ndjson")
+	code, _, stderr := runCLI(t, "", "-server", src.URL, "export", "-o", dump)
+	if code != 0 {
+		t.Fatalf("export failed (%d): %s", code, stderr)
+	}
+
+	// Occupy the source's service point ID on the target so the imported one
+	// is renumbered
+	dst := newTestServer(t)
+	runCLI(t, `{"name":"Existing SP"}`, "-server", dst.URL, "sp", "create", "-f", "-")
+
+	code, stdout, stderr = runCLI(t, "", "-server", dst.URL, "import", "-f", dump)
+	if code != 0 {
+		t.Fatalf("import failed (%d): %s", code, stderr)
+	}
+	var summary importSummary
+	json.Unmarshal([]byte(stdout), &summary)
+	if summary != (importSummary{ServicePoints: 1, RAiDs: 1, Versions: 2}) {
+		t.Errorf("unexpected import summary %+v", summary)
+	}
+
+	code, stdout, _ = runCLI(t, "", "-server", dst.URL, "get", "10.99999/abc", "-version", "1")
+	if code != 0 || !strings.Contains(stdout, `"First"`) {
+		t.Errorf("expected version 1 to be imported (%d): %s", code, stdout)
+	}
+	code, stdout, _ = runCLI(t, "", "-server", dst.URL, "get", "10.99999/abc")
+	if code != 0 || !strings.Contains(stdout, `"Second"`) || !strings.Contains(stdout, `"servicePoint": `+strconv.FormatInt(sp.ID+1, 10)) {
+		t.Errorf("expected current version owned by the renumbered service point (%d): %s", code, stdout)
+	}
+
+	if code, _, _ := runCLI(t, "", "-server", dst.URL, "import", "-f", dump); code != 1 {
+		t.Errorf("expected importing existing RAiDs to fail, got %d", code)
+	}
+	code, stdout, _ = runCLI(t, "", "-server", dst.URL, "import", "-f", dump, "-skip-existing")
+	if code != 0 || !strings.Contains(stdout, `"skipped": 1`) {
+		t.Errorf("expected existing RAiDs to be skipped (%d): %s", code, stdout)
+	}
+}
+
+func TestRaidctl_ServicePointAdmin(t *testing.T) {
+	srv := newTestServer(t)
+	file := filepath.Join(t.TempDir(), "sps.yaml")
+	os.WriteFile(file, []byte("name: First SP\nprefix: 10.11111\nenabled: true\nadminEmail: admin@first.example\n---\nname: Second SP\nenabled: true\nprefixAllocation: round-robin\nprefixes:\n  - prefix: 10.33330\n    last: 10.33332\n"), 0644)
+
+	code, stdout, stderr := runCLI(t, "", "-server", srv.URL, "sp", "create", "-f", file)
+	if code != 0 {
+		t.Fatalf("sp create failed (%d): %s", code, stderr)
+	}
+	var created []raid.ServicePoint
+	if err := json.Unmarshal([]byte(stdout), &created); err != nil || len(created) != 2 || created[0].AdminEmail != "admin@first.example" {
+		t.Fatalf("unexpected sp create output: %s", stdout)
+	}
+	if pools := created[1].Prefixes; len(pools) != 1 || pools[0].Prefix != "10.33330" || pools[0].Last != "10.33332" {
+		t.Errorf("expected the prefix range to be kept verbatim, got %+v", pools)
+	}
+	id := strconv.FormatInt(created[0].ID, 10)
+
+	// Destructive operations need confirmation
+	code, _, stderr = runCLI(t, "n\n", "-server", srv.URL, "sp", "disable", id)
+	if code != 1 || !strings.Contains(stderr, "Disable service point "+id+" (First SP)?") {
+		t.Errorf("expected declined disable to abort, got %d: %s", code, stderr)
+	}
+	code, stdout, _ = runCLI(t, "y\n", "-server", srv.URL, "sp", "disable", id)
+	if code != 0 || !strings.Contains(stdout, `"enabled": false`) {
+		t.Errorf("expected confirmed disable to succeed (%d): %s", code, stdout)
+	}
+	code, stdout, _ = runCLI(t, "", "-server", srv.URL, "sp", "enable", id)
+	if code != 0 || !strings.Contains(stdout, `"enabled": true`) {
+		t.Errorf("expected enable to succeed without confirmation (%d): %s", code, stdout)
+	}
+	code, stdout, _ = runCLI(t, "", "-server", srv.URL, "sp", "set-prefix", id, "10.22222", "-y")
+	if code != 0 || !strings.Contains(stdout, `"prefix": "10.22222"`) || !strings.Contains(stdout, "First SP") {
+		t.Errorf("expected set-prefix to keep other fields (%d): %s", code, stdout)
+	}
+
+	code, stdout, stderr = runCLI(t, "", "-server", srv.URL, "sp", "rotate-key", id, "-user", "sp-bot", "-secret", "s3cret")
+	if code != 0 || strings.Count(strings.TrimSpace(stdout), ".") != 2 {
+		t.Errorf("expected rotate-key to print a token (%d): %s %s", code, stdout, stderr)
+	}
+}
+
+func TestRaidctl_Doctor(t *testing.T) {
+	newServer := func(authEnabled bool) *httptest.Server {
+		cfg := server.DefaultConfig()
+		cfg.Storage.File.DataDir = t.TempDir()
+		cfg.Auth = config.AuthConfig{Enabled: authEnabled, JWTSecret: "s3cret"}
+		repo, err := server.NewRepository(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { repo.Close() })
+		s, err := server.New(cfg, repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(s)
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	srv := newServer(true)
+	_, token, _ := runCLI(t, "", "token", "-secret", "s3cret", "-user", "ops", "-roles", "operator")
+	token = strings.TrimSpace(token)
+	runCLI(t, `{"identifier":{"id":"https://raid.org/10.99999/abc"},"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}}}`, "-server", srv.URL, "mint", "-f", "-")
+
+	code, stdout, stderr := runCLI(t, "", "-server", srv.URL, "-token", token, "doctor", "-json")
+	if code != 0 {
+		t.Fatalf("doctor failed (%d): %s%s", code, stdout, stderr)
+	}
+	var results []checkResult
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatal(err)
+	}
+	statuses := map[string]string{}
+	for _, r := range results {
+		statuses[r.Name] = r.Status
+	}
+	want := map[string]string{"health": checkPass, "auth": checkPass, "operator-access": checkPass, "read-only": checkPass, "storage": checkPass, "resolution": checkPass}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("unexpected checks %v", statuses)
+	}
+
+	code, stdout, _ = runCLI(t, "", "-server", srv.URL, "doctor")
+	if code != 0 || !strings.Contains(stdout, "skip  operator-access") {
+		t.Errorf("expected operator checks to be skipped without a token (%d):\n%s", code, stdout)
+	}
+
+	code, stdout, _ = runCLI(t, "", "-server", newServer(false).URL, "doctor")
+	if code != 0 || !strings.Contains(stdout, "warn  auth             auth disabled") {
+		t.Errorf("expected disabled auth to be reported (%d):\n%s", code, stdout)
+	}
+
+	// The API rejects the token too, not only the operator routes
+	code, _, stderr = runCLI(t, "", "-server", srv.URL, "-token", "bogus", "doctor")
+	if code != 1 || !strings.Contains(stderr, "3 of 5 checks failed") {
+		t.Errorf("expected a rejected token to fail (%d): %s", code, stderr)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/pkg/raid"
+	"gopkg.in/yaml.v3"
+)
+
+const spUsage = "usage: raidctl sp list | get ID | create -f FILE | update ID -f FILE | enable ID | disable ID [-y] | set-prefix ID PREFIX [-y] | rotate-key ID -user ID"
+
+// errAborted is returned when the operator declines a confirmation prompt
+var errAborted = errors.New("aborted")
+
+func runServicePoint(ctx context.Context, env *cliEnv, args []string) error {
+	if len(args) == 0 {
+		return env.usageError(spUsage)
+	}
+	if args[0] == "rotate-key" {
+		return runRotateKey(ctx, env, args[1:])
+	}
+
+	fs := env.newFlagSet("sp " + args[0])
+	file := fs.String("f", "", "JSON or YAML file with the service point(s) (- for stdin)")
+	yes := fs.Bool("y", false, "do not ask for confirmation")
+	positional, err := parseArgs(fs, args[1:])
+	if err != nil {
+		return err
+	}
+
+	var id int64
+	switch args[0] {
+	case "list", "create":
+		if len(positional) != 0 {
+			return env.usageError(spUsage)
+		}
+	case "get", "update", "enable", "disable":
+		if len(positional) != 1 {
+			return env.usageError(spUsage)
+		}
+	case "set-prefix":
+		if len(positional) != 2 || positional[1] == "" {
+			return env.usageError(spUsage)
+		}
+	default:
+		return env.usageError(spUsage)
+	}
+	if len(positional) > 0 {
+		if id, err = strconv.ParseInt(positional[0], 10, 64); err != nil {
+			return env.usageError("invalid service point ID %q", positional[0])
+		}
+	}
+
+	var in []*raid.ServicePoint
+	if args[0] == "create" || args[0] == "update" {
+		if *file == "" {
+			return env.usageError(spUsage)
+		}
+		if in, err = env.readServicePoints(*file); err != nil {
+			return err
+		}
+		if args[0] == "update" && len(in) != 1 {
+			return fmt.Errorf("%s must contain exactly one service point to update", *file)
+		}
+	}
+
+	var out interface{}
+	switch args[0] {
+	case "list":
+		out, err = env.client.ListServicePoints(ctx)
+	case "get":
+		out, err = env.client.GetServicePoint(ctx, id)
+	case "create":
+		var created []*raid.ServicePoint
+		for _, sp := range in {
+			c, err := env.client.CreateServicePoint(ctx, sp)
+			if err != nil {
+				return fmt.Errorf("create service point %q: %w", sp.Name, err)
+			}
+			created = append(created, c)
+		}
+		out = created
+		if len(created) == 1 {
+			out = created[0]
+		}
+	case "update":
+		out, err = env.client.UpdateServicePoint(ctx, id, in[0])
+	case "enable":
+		out, err = env.modifyServicePoint(ctx, id, "", *yes, func(sp *raid.ServicePoint) { sp.Enabled = true })
+	case "disable":
+		out, err = env.modifyServicePoint(ctx, id, "Disable service point %d (%s)? It will no longer be able to mint or update RAiDs.", *yes,
+			func(sp *raid.ServicePoint) { sp.Enabled = false })
+	case "set-prefix":
+		prefix := positional[1]
+		out, err = env.modifyServicePoint(ctx, id, "Change the prefix of service point %d (%s) to "+prefix+"? RAiDs minted from now on will use the new prefix.", *yes,
+			func(sp *raid.ServicePoint) { sp.Prefix = prefix })
+	}
+	if err != nil {
+		return err
+	}
+	return env.printJSON(out)
+}
+
+// modifyServicePoint fetches a service point, applies change and stores it.
+// If prompt is not empty the operator is asked to confirm first; prompt is
+// formatted with the service point's ID and name.
+func (env *cliEnv) modifyServicePoint(ctx context.Context, id int64, prompt string, yes bool, change func(*raid.ServicePoint)) (*raid.ServicePoint, error) {
+	sp, err := env.client.GetServicePoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if prompt != "" {
+		if err := env.confirm(fmt.Sprintf(prompt, sp.ID, sp.Name), yes); err != nil {
+			return nil, err
+		}
+	}
+	change(sp)
+	return env.client.UpdateServicePoint(ctx, id, sp)
+}
+
+// confirm asks the operator to confirm a destructive operation on stdin
+// unless yes is set
+func (env *cliEnv) confirm(prompt string, yes bool) error {
+	if yes {
+		return nil
+	}
+	fmt.Fprintf(env.stderr, "%s [y/N] ", prompt)
+	answer, err := bufio.NewReader(env.stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+	return errAborted
+}
+
+// readServicePoints reads one or more service points from a JSON file (an
+// object or an array) or a YAML file (one document per service point, or a
+// list). YAML keys are the JSON field names.
+func (env *cliEnv) readServicePoints(path string) ([]*raid.ServicePoint, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(env.stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); {
+	case ext == ".yaml" || ext == ".yml" || (path == "-" && !json.Valid(data)):
+		if docs, err = decodeYAMLServicePoints(data); err != nil {
+			return nil, fmt.Errorf("%s does not contain valid YAML: %w", path, err)
+		}
+	default:
+		var doc interface{}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("%s does not contain a valid document: %w", path, err)
+		}
+		docs = []interface{}{doc}
+		if list, ok := doc.([]interface{}); ok {
+			docs = list
+		}
+	}
+
+	var sps []*raid.ServicePoint
+	for i, doc := range docs {
+		// Round-trip through JSON so that the JSON field names apply
+		b, err := json.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: service point %d: %w", path, i+1, err)
+		}
+		var sp raid.ServicePoint
+		if err := json.Unmarshal(b, &sp); err != nil {
+			return nil, fmt.Errorf("%s: service point %d: %w", path, i+1, err)
+		}
+		sps = append(sps, &sp)
+	}
+	if len(sps) == 0 {
+		return nil, fmt.Errorf("%s contains no service points", path)
+	}
+	return sps, nil
+}
+
+// spStringFields holds the JSON names of the string fields of a service
+// point and its prefix pools. YAML values for these are kept verbatim, so
+// that an unquoted prefix such as 10.82841 is not read as a number.
+var spStringFields = func() map[string]bool {
+	fields := map[string]bool{}
+	for _, t := range []reflect.Type{reflect.TypeOf(raid.ServicePoint{}), reflect.TypeOf(raid.PrefixPool{})} {
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).Type.Kind() == reflect.String {
+				name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+				fields[name] = true
+			}
+		}
+	}
+	return fields
+}()
+
+// decodeYAMLServicePoints decodes YAML documents, each a service point or a
+// list of them, into generic values keyed by the JSON field names
+func decodeYAMLServicePoints(data []byte) ([]interface{}, error) {
+	var docs []interface{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		nodes := doc.Content
+		if len(nodes) == 1 && nodes[0].Kind == yaml.SequenceNode {
+			nodes = nodes[0].Content
+		}
+		for _, n := range nodes {
+			sp, err := decodeYAMLObject(n)
+			if err != nil {
+				return nil, err
+			}
+			docs = append(docs, sp)
+		}
+	}
+}
+
+// decodeYAMLObject decodes a YAML mapping into generic values, keeping
+// string fields verbatim in it and in any nested lists of mappings
+func decodeYAMLObject(n *yaml.Node) (map[string]interface{}, error) {
+	var fields map[string]yaml.Node
+	if err := n.Decode(&fields); err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	for name, value := range fields {
+		switch {
+		case spStringFields[name] && value.Kind == yaml.ScalarNode:
+			obj[name] = value.Value
+		case value.Kind == yaml.SequenceNode && len(value.Content) > 0 && value.Content[0].Kind == yaml.MappingNode:
+			var items []interface{}
+			for _, item := range value.Content {
+				decoded, err := decodeYAMLObject(item)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, decoded)
+			}
+			obj[name] = items
+		default:
+			var v interface{}
+			if err := value.Decode(&v); err != nil {
+				return nil, err
+			}
+			obj[name] = v
+		}
+	}
+	return obj, nil
+}
+
+// runRotateKey issues a new access token scoped to a service point. Tokens
+// are not stored by the server, so rotating means handing out a new token
+// and letting the old one expire; rotate the JWT secret to revoke all
+// tokens at once.
+func runRotateKey(ctx context.Context, env *cliEnv, args []string) error {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return env.usageError("usage: raidctl sp rotate-key ID -user ID [token flags]")
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return env.usageError("invalid service point ID %q", args[0])
+	}
+	sp, err := env.client.GetServicePoint(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !sp.Enabled {
+		fmt.Fprintf(env.stderr, "Warning: service point %d (%s) is disabled\n", sp.ID, sp.Name)
+	}
+	return runToken(ctx, env, append(args[1:], "-service-point", args[0]))
+}
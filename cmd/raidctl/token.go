@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+// runToken signs an HS256 access token accepted by a server configured with
+// the same JWT secret, issuer and audience. It is meant for operators and
+// scripts that have access to the secret; no request is made.
+func runToken(ctx context.Context, env *cliEnv, args []string) error {
+	fs := env.newFlagSet("token")
+	secret := fs.String("secret", os.Getenv("JWT_SECRET"), "HMAC signing secret (JWT_SECRET)")
+	secretFile := fs.String("secret-file", os.Getenv("JWT_SECRET_FILE"), "read the signing secret from a file (JWT_SECRET_FILE)")
+	user := fs.String("user", "", "user ID")
+	email := fs.String("email", "", "email address")
+	servicePoint := fs.Int64("service-point", 0, "service point the user acts for")
+	roles := fs.String("roles", "", "comma-separated roles, e.g. operator")
+	issuer := fs.String("issuer", os.Getenv("JWT_ISSUER"), "token issuer (JWT_ISSUER)")
+	audience := fs.String("audience", os.Getenv("JWT_AUDIENCE"), "token audience (JWT_AUDIENCE)")
+	ttl := fs.Duration("ttl", time.Hour, "token lifetime")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	if *secretFile != "" {
+		data, err := os.ReadFile(*secretFile)
+		if err != nil {
+			return err
+		}
+		*secret = strings.TrimSpace(string(data))
+	}
+	if *secret == "" || *user == "" {
+		return env.usageError("usage: raidctl token -user ID [-roles R,...] with -secret, -secret-file or JWT_SECRET")
+	}
+
+	opts := raid.TokenOptions{
+		UserID:   *user,
+		Email:    *email,
+		Issuer:   *issuer,
+		Audience: *audience,
+		TTL:      *ttl,
+	}
+	if *servicePoint != 0 {
+		opts.ServicePointID = servicePoint
+	}
+	if *roles != "" {
+		opts.Roles = strings.Split(*roles, ",")
+	}
+
+	token, err := raid.NewToken([]byte(*secret), opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(env.stdout, token)
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// runConvertEncoding implements "raid-server convert-encoding": it rewrites
+// the records of the configured backend that are not yet stored in its
+// configured encoding, such as JSON data after switching to msgpack, and
+// returns the exit code
+func runConvertEncoding(args []string) int {
+	fs := flag.NewFlagSet("convert-encoding", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	fs.Parse(args)
+
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 2
+	}
+
+	repo, err := storage.NewRepository(&cfg.Storage)
+	if err != nil {
+		log.Printf("Failed to initialize storage: %v", err)
+		return 2
+	}
+	defer repo.Close()
+
+	converter, ok := repo.(storage.EncodingConverter)
+	if !ok {
+		log.Printf("Storage backend %s does not support storage encodings", cfg.Storage.Type)
+		return 2
+	}
+
+	n, err := converter.ConvertEncoding(context.Background())
+	if err != nil {
+		log.Printf("Conversion failed after %d records: %v", n, err)
+		return 1
+	}
+	log.Printf("Converted %d records", n)
+	return 0
+}
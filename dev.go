@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/leifj/go-raid/internal/config"
+	raidmw "github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/seed"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/pkg/raid"
+	"github.com/leifj/go-raid/pkg/server"
+)
+
+// devTokenTTL is the lifetime of the operator token printed in dev mode
+const devTokenTTL = 24 * time.Hour
+
+// setupDev switches cfg to zero-config development mode: a throwaway file
+// store in a temporary directory, JWT auth with a random per-run secret and
+// verbose logging. It returns the operator token to print and a cleanup
+// function that removes the temporary store.
+func setupDev(cfg *config.Config) (string, func(), error) {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+
+	// There is no in-memory backend; a file store that is deleted on exit
+	// behaves the same for the edit-run loop
+	dir, err := os.MkdirTemp("", "raid-dev-")
+	if err != nil {
+		return "", nil, fmt.Errorf("create temporary data directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = dir
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("generate JWT secret: %w", err)
+	}
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = hex.EncodeToString(secret)
+	// Log responses straying from the OpenAPI document while developing
+	cfg.Server.ValidateResponses = true
+
+	token, err := raid.NewToken([]byte(cfg.Auth.JWTSecret), raid.TokenOptions{
+		UserID:   "dev",
+		Email:    "dev@localhost",
+		Roles:    []string{raidmw.RoleOperator},
+		Issuer:   cfg.Auth.JWTIssuer,
+		Audience: cfg.Auth.JWTAudience,
+		TTL:      devTokenTTL,
+	})
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("sign dev token: %w", err)
+	}
+	return token, cleanup, nil
+}
+
+// seedDev fills the dev store with the standard demo data
+func seedDev(repo storage.Repository) error {
+	summary, err := seed.Run(context.Background(), repo, seed.Options{Seed: 1})
+	if err != nil {
+		return err
+	}
+	log.Printf("Seeded %d service points and %d sample RAiDs", len(summary.ServicePoints), len(summary.RAiDs))
+	return nil
+}
+
+// devOptions returns the server options used in dev mode
+func devOptions() []server.Option {
+	return []server.Option{server.WithMiddleware(raidmw.CORS(raidmw.LocalhostOrigin))}
+}
@@ -0,0 +1,83 @@
+// Package access enforces the rules for changing the access type of a
+// RAiD. An embargo lapses once its embargoExpiry passes, after which the
+// RAiD counts as open whatever its access type says.
+package access
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/validation"
+)
+
+// Who may embargo a RAiD that is open
+const (
+	ReembargoNever    = "never"
+	ReembargoOperator = "operator"
+	ReembargoAnyone   = "anyone"
+)
+
+// Policy configures which access type changes are allowed on update
+type Policy struct {
+	// Reembargo says who may embargo an open RAiD: never, operator (the
+	// default) or anyone
+	Reembargo string `yaml:"reembargo" toml:"reembargo" json:"reembargo,omitempty"`
+	// KeepEmbargoes rejects opening an embargoed RAiD before its
+	// embargoExpiry passes, unless the caller is an operator
+	KeepEmbargoes bool `yaml:"keepEmbargoes" toml:"keepEmbargoes" json:"keepEmbargoes,omitempty"`
+}
+
+// Validate checks the policy for unknown settings
+func (p Policy) Validate() error {
+	switch p.Reembargo {
+	case "", ReembargoNever, ReembargoOperator, ReembargoAnyone:
+		return nil
+	}
+	return fmt.Errorf("unknown reembargo setting %q (want %s, %s or %s)", p.Reembargo, ReembargoNever, ReembargoOperator, ReembargoAnyone)
+}
+
+// embargoed reports whether raid is under an embargo that has not lapsed
+// by now. An embargo without an expiry date does not lapse.
+func embargoed(raid *models.RAiD, now time.Time) bool {
+	if raid.Access == nil || raid.Access.Type == nil || raid.Access.Type.ID != storage.AccessTypeEmbargoed {
+		return false
+	}
+	_, lapsed := lapsedBy(raid.Access.EmbargoExpiry, now)
+	return !lapsed
+}
+
+// Check reports a change of access from prev to next that the policy does
+// not allow as a *validation.Error. operator is whether the caller has the
+// operator role, which overrides the policy where it says so.
+func (p Policy) Check(prev, next *models.RAiD, operator bool, now time.Time) error {
+	wasEmbargoed, isEmbargoed := embargoed(prev, now), embargoed(next, now)
+	switch {
+	case !wasEmbargoed && isEmbargoed:
+		switch p.Reembargo {
+		case ReembargoAnyone:
+			return nil
+		case ReembargoNever:
+			return reject("an open RAiD cannot be embargoed again")
+		}
+		if !operator {
+			return reject("only an operator can embargo an open RAiD")
+		}
+	case wasEmbargoed && !isEmbargoed && p.KeepEmbargoes && !operator:
+		if prev.Access.EmbargoExpiry == "" {
+			return reject("the embargo has no expiry date and can only be lifted by an operator")
+		}
+		return reject(fmt.Sprintf("the RAiD is embargoed until %s and opens then", prev.Access.EmbargoExpiry))
+	}
+	return nil
+}
+
+// reject returns a validation failure of the access type
+func reject(message string) error {
+	return &validation.Error{Failures: []models.ValidationFailure{{
+		FieldID:   "access.type",
+		ErrorType: validation.InvalidValue,
+		Message:   message,
+	}}}
+}
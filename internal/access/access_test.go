@@ -0,0 +1,65 @@
+package access
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/validation"
+)
+
+func withAccess(typeID, expiry string) *models.RAiD {
+	return &models.RAiD{Access: &models.Access{Type: &models.IDSchema{ID: typeID}, EmbargoExpiry: expiry}}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	now := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	open := withAccess(storage.AccessTypeOpen, "")
+	embargoed := withAccess(storage.AccessTypeEmbargoed, "2025-12-31")
+	lapsed := withAccess(storage.AccessTypeEmbargoed, "2025-01-31")
+	tests := []struct {
+		name       string
+		policy     Policy
+		prev, next *models.RAiD
+		operator   bool
+		wantErr    bool
+	}{
+		{name: "open stays open", prev: open, next: open},
+		{name: "embargo lifted", prev: embargoed, next: open},
+		{name: "embargo kept", policy: Policy{KeepEmbargoes: true}, prev: embargoed, next: open, wantErr: true},
+		{name: "embargo lifted by operator", policy: Policy{KeepEmbargoes: true}, prev: embargoed, next: open, operator: true},
+		{name: "lapsed embargo opened", policy: Policy{KeepEmbargoes: true}, prev: lapsed, next: open},
+		{name: "lapsed embargo left as is", prev: lapsed, next: lapsed},
+		{name: "open embargoed", prev: open, next: embargoed, wantErr: true},
+		{name: "lapsed embargo renewed", prev: lapsed, next: embargoed, wantErr: true},
+		{name: "open embargoed by operator", prev: open, next: embargoed, operator: true},
+		{name: "open embargoed by anyone", policy: Policy{Reembargo: ReembargoAnyone}, prev: open, next: embargoed},
+		{name: "never embargoed again", policy: Policy{Reembargo: ReembargoNever}, prev: open, next: embargoed, operator: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.prev, tt.next, tt.operator, now)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var valErr *validation.Error
+			if !errors.As(err, &valErr) || valErr.Failures[0].FieldID != "access.type" {
+				t.Fatalf("expected an access.type failure, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	if err := (Policy{Reembargo: "sometimes"}).Validate(); err == nil {
+		t.Error("expected an unknown reembargo setting to be rejected")
+	}
+	if err := (Policy{Reembargo: ReembargoNever}).Validate(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+package access
+
+import (
+	"sort"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Timeline condenses the versions of a RAiD into the changes of its access
+// type and embargo expiry. Embargoes that lapsed before the next change,
+// or before now, add a change to open at their expiry date.
+func Timeline(history []*models.RAiD, now time.Time) []models.AccessChange {
+	versions := make([]*models.RAiD, 0, len(history))
+	seen := make(map[int]bool, len(history))
+	for _, raid := range history {
+		if v := version(raid); !seen[v] {
+			seen[v] = true
+			versions = append(versions, raid)
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool { return version(versions[i]) < version(versions[j]) })
+
+	timeline := make([]models.AccessChange, 0)
+	// lapse adds the expiry of the current embargo if it passed before t
+	lapse := func(t time.Time) {
+		if len(timeline) == 0 {
+			return
+		}
+		last := timeline[len(timeline)-1]
+		if effective(last).typ != storage.AccessTypeEmbargoed {
+			return
+		}
+		if expiry, ok := lapsedBy(last.EmbargoExpiry, t); ok {
+			timeline = append(timeline, models.AccessChange{Version: last.Version, Timestamp: expiry, Type: storage.AccessTypeOpen, Lapsed: true})
+		}
+	}
+
+	for _, raid := range versions {
+		change := models.AccessChange{Version: version(raid), Timestamp: timestamp(raid)}
+		if raid.Access != nil {
+			if raid.Access.Type != nil {
+				change.Type = raid.Access.Type.ID
+			}
+			if change.Type == storage.AccessTypeEmbargoed {
+				change.EmbargoExpiry = raid.Access.EmbargoExpiry
+			}
+		}
+		lapse(change.Timestamp)
+		// Versions that leave the access as it was, including ones still
+		// saying embargoed after the embargo lapsed, are not changes
+		if n := len(timeline); n > 0 && effective(timeline[n-1]) == effective(change) {
+			continue
+		}
+		timeline = append(timeline, change)
+	}
+	lapse(now)
+	return timeline
+}
+
+// state is the access a RAiD is under
+type state struct {
+	typ, expiry string
+}
+
+// effective returns the access in force after change; an embargo that had
+// already lapsed when it was saved counts as open
+func effective(change models.AccessChange) state {
+	if change.Type == storage.AccessTypeEmbargoed {
+		if _, ok := lapsedBy(change.EmbargoExpiry, change.Timestamp); ok {
+			return state{typ: storage.AccessTypeOpen}
+		}
+	}
+	return state{typ: change.Type, expiry: change.EmbargoExpiry}
+}
+
+// lapsedBy returns the expiry date and whether it passed before t
+func lapsedBy(expiry string, t time.Time) (time.Time, bool) {
+	day, err := time.Parse("2006-01-02", expiry)
+	return day, err == nil && day.Before(t)
+}
+
+func version(raid *models.RAiD) int {
+	if raid.Identifier == nil {
+		return 0
+	}
+	return raid.Identifier.Version
+}
+
+// timestamp returns when a version was saved
+func timestamp(raid *models.RAiD) time.Time {
+	if raid.Metadata == nil {
+		return time.Time{}
+	}
+	if !raid.Metadata.Updated.IsZero() {
+		return raid.Metadata.Updated
+	}
+	return raid.Metadata.Created
+}
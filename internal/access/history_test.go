@@ -0,0 +1,48 @@
+package access
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestTimeline(t *testing.T) {
+	at := func(version int, day string, raid *models.RAiD) *models.RAiD {
+		updated, _ := time.Parse("2006-01-02", day)
+		raid.Identifier = &models.Identifier{Version: version}
+		raid.Metadata = &models.Metadata{Updated: updated}
+		return raid
+	}
+	history := []*models.RAiD{
+		at(1, "2024-01-10", withAccess(storage.AccessTypeEmbargoed, "2024-06-30")),
+		at(2, "2024-03-01", withAccess(storage.AccessTypeEmbargoed, "2024-06-30")),
+		at(3, "2024-05-01", withAccess(storage.AccessTypeEmbargoed, "2024-09-30")),
+		at(4, "2024-11-01", withAccess(storage.AccessTypeEmbargoed, "2024-09-30")),
+		at(5, "2025-01-01", withAccess(storage.AccessTypeOpen, "")),
+		at(6, "2025-02-01", withAccess(storage.AccessTypeEmbargoed, "2025-04-30")),
+	}
+	got := Timeline(history, time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC))
+
+	want := []models.AccessChange{
+		{Version: 1, Type: storage.AccessTypeEmbargoed, EmbargoExpiry: "2024-06-30"},
+		{Version: 3, Type: storage.AccessTypeEmbargoed, EmbargoExpiry: "2024-09-30"},
+		{Version: 3, Type: storage.AccessTypeOpen, Lapsed: true},
+		{Version: 6, Type: storage.AccessTypeEmbargoed, EmbargoExpiry: "2025-04-30"},
+		{Version: 6, Type: storage.AccessTypeOpen, Lapsed: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d changes, got %+v", len(want), got)
+	}
+	for i := range want {
+		g := got[i]
+		g.Timestamp = time.Time{}
+		if g != want[i] {
+			t.Errorf("change %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+	if day := got[2].Timestamp.Format("2006-01-02"); day != "2024-09-30" {
+		t.Errorf("expected the embargo to lapse at its expiry, got %s", day)
+	}
+}
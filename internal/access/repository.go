@@ -0,0 +1,35 @@
+package access
+
+import (
+	"context"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that checks updates of RAiDs in repo against
+// the access type changes p allows. operator reports whether the caller
+// of a request is an operator; nil means no caller is.
+func Wrap(repo storage.Repository, p Policy, operator func(context.Context) bool) storage.Repository {
+	if operator == nil {
+		operator = func(context.Context) bool { return false }
+	}
+	return &repository{Repository: repo, policy: p, operator: operator}
+}
+
+type repository struct {
+	storage.Repository
+	policy   Policy
+	operator func(context.Context) bool
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	// A missing RAiD is reported by the update itself
+	if prev, err := r.Repository.GetRAiD(ctx, prefix, suffix); err == nil {
+		if err := r.policy.Check(prev, raid, r.operator(ctx), time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
@@ -0,0 +1,48 @@
+// Package accesslog persists structured access log entries.
+//
+// Entries use the storage.AccessLogEntry schema and are written either as
+// JSON lines to a size-rotated file or in batches to a storage backend that
+// implements storage.AccessLogStore. They can also be exported, as audit
+// events in CEF, OCSF or JSON, to syslog or an HTTP collector. Sinks never
+// fail a request; write failures and dropped entries are counted in the
+// "accessLog" expvar.
+package accesslog
+
+import (
+	"errors"
+	"expvar"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// metrics exposes sink outcomes under /debug/vars
+var metrics = expvar.NewMap("accessLog")
+
+// Sink receives access log entries
+type Sink interface {
+	// Log records an entry; failures are counted rather than returned
+	Log(entry storage.AccessLogEntry)
+	// Close flushes pending entries and releases resources
+	Close() error
+}
+
+// Tee returns a sink logging to each of sinks
+func Tee(sinks ...Sink) Sink {
+	return tee(sinks)
+}
+
+type tee []Sink
+
+func (t tee) Log(entry storage.AccessLogEntry) {
+	for _, s := range t {
+		s.Log(entry)
+	}
+}
+
+func (t tee) Close() error {
+	var errs []error
+	for _, s := range t {
+		errs = append(errs, s.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+package accesslog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestFileSink_Rotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "access.log")
+	sink, err := NewFileSink(path, 300, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := storage.AccessLogEntry{Schema: storage.AccessLogSchema, Method: "GET", Path: "/raid/10.99999/abc/", Status: 200, RAiD: "10.99999/abc"}
+	for i := 0; i < 10; i++ {
+		sink.Log(entry)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", filepath.Base(name), err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s exceeds max size: %d bytes", filepath.Base(name), info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept")
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var got storage.AccessLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		if got.Schema != storage.AccessLogSchema || got.RAiD != "10.99999/abc" {
+			t.Errorf("unexpected entry: %+v", got)
+		}
+	}
+}
+
+type memoryStore struct {
+	entries []storage.AccessLogEntry
+}
+
+func (m *memoryStore) WriteAccessLog(_ context.Context, entries []storage.AccessLogEntry) error {
+	m.entries = append(m.entries, entries...)
+	return nil
+}
+
+func TestStoreSink_FlushOnClose(t *testing.T) {
+	store := &memoryStore{}
+	sink := NewStoreSink(store, 0)
+	for i := 0; i < storeBatchSize+5; i++ {
+		sink.Log(storage.AccessLogEntry{Path: strings.Repeat("x", i)})
+	}
+	sink.Close()
+
+	if len(store.entries) != storeBatchSize+5 {
+		t.Errorf("expected %d entries written, got %d", storeBatchSize+5, len(store.entries))
+	}
+}
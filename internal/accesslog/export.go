@@ -0,0 +1,256 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// exportTimeout bounds sending one batch of events
+	exportTimeout = 10 * time.Second
+	// syslogPriority is facility log audit (13) and severity
+	// informational (6)
+	syslogPriority = 13*8 + 6
+)
+
+// Sender delivers formatted events to a collector
+type Sender interface {
+	// Send delivers a batch of events
+	Send(ctx context.Context, events [][]byte) error
+	// Close releases the connection, if any
+	Close() error
+}
+
+// ExportSink formats entries and sends them in batches to a Sender from a
+// background goroutine, for SIEM systems to ingest. Entries are dropped
+// when the buffer is full; send failures are logged and counted.
+type ExportSink struct {
+	format  Formatter
+	sender  Sender
+	entries chan storage.AccessLogEntry
+	done    chan struct{}
+}
+
+// NewExportSink starts a sink buffering up to bufferSize entries
+func NewExportSink(format Formatter, sender Sender, bufferSize int) *ExportSink {
+	if bufferSize <= 0 {
+		bufferSize = 10 * storeBatchSize
+	}
+	s := &ExportSink{
+		format:  format,
+		sender:  sender,
+		entries: make(chan storage.AccessLogEntry, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Log queues an entry
+func (s *ExportSink) Log(entry storage.AccessLogEntry) {
+	select {
+	case s.entries <- entry:
+	default:
+		metrics.Add("exportDropped", 1)
+	}
+}
+
+func (s *ExportSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(storeFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([][]byte, 0, storeBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+		err := s.sender.Send(ctx, batch)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to export %d access log entries: %v", len(batch), err)
+			metrics.Add("exportErrors", int64(len(batch)))
+		} else {
+			metrics.Add("exported", int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry, ok := <-s.entries:
+			if !ok {
+				flush()
+				return
+			}
+			event, err := s.format(entry)
+			if err != nil {
+				metrics.Add("exportErrors", 1)
+				continue
+			}
+			batch = append(batch, event)
+			if len(batch) >= storeBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Close sends any queued entries, stops the background goroutine and
+// closes the sender. Log must not be called after Close.
+func (s *ExportSink) Close() error {
+	close(s.entries)
+	<-s.done
+	return s.sender.Close()
+}
+
+// SyslogSender sends events as RFC 5424 syslog messages, over UDP, TCP
+// (octet-counted, RFC 6587) or a Unix socket
+type SyslogSender struct {
+	network, address string
+	hostname         string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogSender returns a sender to address, a udp://host:port,
+// tcp://host:port or unix:///path URL. Connections are made on the first
+// send, and again after a failed one.
+func NewSyslogSender(address string) (*SyslogSender, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog address: %w", err)
+	}
+	s := &SyslogSender{network: u.Scheme, address: u.Host}
+	switch u.Scheme {
+	case "udp", "tcp":
+		if u.Port() == "" {
+			return nil, fmt.Errorf("syslog address %s has no port", address)
+		}
+	case "unix":
+		// Local daemons listen on datagram sockets such as /dev/log
+		s.network, s.address = "unixgram", u.Path
+	default:
+		return nil, fmt.Errorf("syslog address must be a udp://, tcp:// or unix:// URL, got %s", address)
+	}
+	if s.hostname, err = os.Hostname(); err != nil || s.hostname == "" {
+		s.hostname = "-"
+	}
+	return s, nil
+}
+
+// Send writes each event as a message
+func (s *SyslogSender) Send(ctx context.Context, events [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, s.network, s.address)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline)
+	}
+	for _, event := range events {
+		msg := s.message(event)
+		if s.network == "tcp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// message frames event as an RFC 5424 message without structured data
+func (s *SyslogSender) message(event []byte) []byte {
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ", syslogPriority, time.Now().UTC().Format(time.RFC3339Nano), s.hostname, "go-raid", os.Getpid())
+	return append([]byte(header), event...)
+}
+
+// Close closes the connection
+func (s *SyslogSender) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// HTTPSender posts batches of events to a collector as newline-delimited
+// lines: JSON events as application/x-ndjson, CEF as text/plain
+type HTTPSender struct {
+	url           string
+	contentType   string
+	authorization string
+	client        *http.Client
+}
+
+// NewHTTPSender returns a sender posting events of format to url, with
+// authorization, if set, as the Authorization header
+func NewHTTPSender(url, format, authorization string) *HTTPSender {
+	contentType := "application/x-ndjson"
+	if format == FormatCEF {
+		contentType = "text/plain; charset=utf-8"
+	}
+	return &HTTPSender{url: url, contentType: contentType, authorization: authorization, client: &http.Client{}}
+}
+
+// Send posts the batch; any status but 2xx fails it
+func (s *HTTPSender) Send(ctx context.Context, events [][]byte) error {
+	var body bytes.Buffer
+	for _, event := range events {
+		body.Write(event)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", s.contentType)
+	if s.authorization != "" {
+		req.Header.Set("Authorization", s.authorization)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("collector answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Close does nothing
+func (s *HTTPSender) Close() error {
+	return nil
+}
@@ -0,0 +1,149 @@
+package accesslog
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+var auditEntry = storage.AccessLogEntry{
+	Schema:       storage.AccessLogSchema,
+	Time:         time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	RequestID:    "req-1",
+	Method:       "PUT",
+	Path:         "/raid/10.99999/abc",
+	Route:        "/raid/{prefix}/{suffix}",
+	Status:       200,
+	Actor:        "alice",
+	ServicePoint: 20000000,
+	RAiD:         "10.99999/abc",
+	RemoteAddr:   "192.0.2.7:51234",
+}
+
+func TestExport_CEFToSyslog(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	format, err := NewFormatter(FormatCEF, map[string]string{"raid": "cs5", "userAgent": ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender, err := NewSyslogSender("udp://" + conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := auditEntry
+	entry.Path = "/raid/10.99999/a=b|c"
+	entry.Route = ""
+	sink := NewExportSink(format, sender, 0)
+	sink.Log(entry)
+	sink.Close()
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<110>1 ") {
+		t.Errorf("message %q is not an RFC 5424 log audit message", msg)
+	}
+	_, event, _ := strings.Cut(msg, " - - ")
+	for _, want := range []string{
+		`CEF:0|go-RAiD|go-RAiD|`,
+		`|PUT /raid/10.99999/a=b\|c|PUT /raid/10.99999/a=b\|c|3|`,
+		"outcome=success",
+		"rt=1714564800000",
+		"suser=alice",
+		"src=192.0.2.7 ",
+		`request=/raid/10.99999/a\=b|c`,
+		"cs5=10.99999/abc cs5Label=raid",
+	} {
+		if !strings.Contains(event, want) {
+			t.Errorf("event %q lacks %q", event, want)
+		}
+	}
+	if strings.Contains(event, "cs1=") {
+		t.Errorf("event %q keeps the default key of a remapped field", event)
+	}
+}
+
+func TestExport_OCSFToHTTP(t *testing.T) {
+	var events []map[string]any
+	var contentType, authorization string
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType, authorization = r.Header.Get("Content-Type"), r.Header.Get("Authorization")
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var event map[string]any
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				t.Errorf("invalid event %q: %v", scanner.Text(), err)
+			}
+			events = append(events, event)
+		}
+	}))
+	defer collector.Close()
+
+	format, err := NewFormatter(FormatOCSF, map[string]string{"raid": "resource.uid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := NewExportSink(format, NewHTTPSender(collector.URL, FormatOCSF, "Splunk token"), 0)
+	sink.Log(auditEntry)
+	failed := auditEntry
+	failed.Method, failed.Status = "DELETE", 403
+	sink.Log(failed)
+	sink.Close()
+
+	if contentType != "application/x-ndjson" || authorization != "Splunk token" {
+		t.Errorf("posted as %q with authorization %q", contentType, authorization)
+	}
+	if len(events) != 2 {
+		t.Fatalf("collector got %d events, want 2", len(events))
+	}
+	update := events[0]
+	if update["class_uid"] != 6003.0 || update["activity_id"] != 3.0 || update["type_uid"] != 600303.0 || update["status_id"] != 1.0 {
+		t.Errorf("update classified as %v", update)
+	}
+	if user := update["actor"].(map[string]any)["user"].(map[string]any); user["uid"] != "alice" {
+		t.Errorf("actor.user = %v", user)
+	}
+	if ip := update["src_endpoint"].(map[string]any)["ip"]; ip != "192.0.2.7" {
+		t.Errorf("src_endpoint.ip = %v", ip)
+	}
+	if uid := update["resource"].(map[string]any)["uid"]; uid != "10.99999/abc" {
+		t.Errorf("resource.uid = %v", uid)
+	}
+	if _, ok := update["unmapped"]; ok {
+		t.Errorf("remapped RAiD still written to unmapped: %v", update["unmapped"])
+	}
+	if events[1]["activity_id"] != 4.0 || events[1]["status_id"] != 2.0 {
+		t.Errorf("refused delete classified as %v", events[1])
+	}
+}
+
+func TestNewFormatter_Invalid(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		fields map[string]string
+	}{
+		{"leef", nil},
+		{FormatCEF, map[string]string{"owner": "suid"}},
+		{FormatCEF, map[string]string{"raid": "raid id"}},
+	} {
+		if _, err := NewFormatter(tc.format, tc.fields); err == nil {
+			t.Errorf("NewFormatter(%q, %v) accepted", tc.format, tc.fields)
+		}
+	}
+}
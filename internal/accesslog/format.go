@@ -0,0 +1,325 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Export formats
+const (
+	// FormatCEF is ArcSight Common Event Format, one line per entry
+	FormatCEF = "cef"
+	// FormatOCSF is an Open Cybersecurity Schema Framework API Activity
+	// event as JSON
+	FormatOCSF = "ocsf"
+	// FormatJSON is the entry as the file sink writes it
+	FormatJSON = "json"
+)
+
+const (
+	// productName and productVendor identify the server in CEF headers
+	// and OCSF metadata
+	productName   = "go-RAiD"
+	productVendor = "go-RAiD"
+
+	// ocsfVersion is the OCSF schema version events are written in
+	ocsfVersion = "1.1.0"
+	// ocsfAPIActivity is the class of OCSF events, in the Application
+	// Activity category
+	ocsfAPIActivity    = 6003
+	ocsfApplicationCat = 6
+	ocsfSeverityInfo   = 1
+	ocsfStatusSuccess  = 1
+	ocsfStatusFailure  = 2
+)
+
+// entryFields lists the fields of storage.AccessLogEntry by JSON name
+var entryFields = []string{"schema", "time", "requestId", "method", "path", "route", "status", "bytes", "latencyMs", "actor", "servicePoint", "raid", "version", "remoteAddr", "userAgent"}
+
+// cefFields maps entry fields to CEF extension keys by default. Custom
+// fields (csN, cnN) are labelled with the entry field name.
+var cefFields = map[string]string{
+	"time":         "rt",
+	"requestId":    "externalId",
+	"method":       "requestMethod",
+	"path":         "request",
+	"route":        "cs2",
+	"status":       "cn1",
+	"bytes":        "out",
+	"latencyMs":    "cn2",
+	"actor":        "suser",
+	"servicePoint": "cs3",
+	"raid":         "cs1",
+	"version":      "cn3",
+	"remoteAddr":   "src",
+	"userAgent":    "requestClientApplication",
+}
+
+// ocsfFields maps entry fields to OCSF attributes, dot-separated, by
+// default
+var ocsfFields = map[string]string{
+	"time":         "time",
+	"requestId":    "api.request.uid",
+	"method":       "http_request.http_method",
+	"path":         "http_request.url.path",
+	"route":        "api.operation",
+	"status":       "http_response.code",
+	"bytes":        "http_response.length",
+	"latencyMs":    "duration",
+	"actor":        "actor.user.uid",
+	"servicePoint": "actor.user.org.uid",
+	"raid":         "unmapped.raid",
+	"version":      "unmapped.version",
+	"remoteAddr":   "src_endpoint.ip",
+	"userAgent":    "http_request.user_agent",
+}
+
+// Formatter renders an entry as one exported event
+type Formatter func(entry storage.AccessLogEntry) ([]byte, error)
+
+// NewFormatter returns the formatter of format. fields maps entry fields,
+// by their JSON names, to the keys they are written under, over the
+// format's defaults: CEF extension keys, dot-separated OCSF attributes or
+// JSON properties. A field mapped to "" is left out.
+func NewFormatter(format string, fields map[string]string) (Formatter, error) {
+	var defaults map[string]string
+	switch format {
+	case FormatCEF:
+		defaults = cefFields
+	case FormatOCSF:
+		defaults = ocsfFields
+	case FormatJSON:
+		defaults = make(map[string]string, len(entryFields))
+		for _, field := range entryFields {
+			defaults[field] = field
+		}
+	default:
+		return nil, fmt.Errorf("unknown export format %q; formats are %s, %s and %s", format, FormatCEF, FormatOCSF, FormatJSON)
+	}
+
+	mapping := make(map[string]string, len(defaults))
+	for field, key := range defaults {
+		mapping[field] = key
+	}
+	for field, key := range fields {
+		if !slices.Contains(entryFields, field) {
+			return nil, fmt.Errorf("unknown access log field %q", field)
+		}
+		if key == "" {
+			delete(mapping, field)
+			continue
+		}
+		if format == FormatCEF && !validCEFKey(key) {
+			return nil, fmt.Errorf("invalid CEF extension key %q for field %s", key, field)
+		}
+		mapping[field] = key
+	}
+
+	switch format {
+	case FormatCEF:
+		return func(entry storage.AccessLogEntry) ([]byte, error) { return formatCEF(entry, mapping), nil }, nil
+	case FormatOCSF:
+		return func(entry storage.AccessLogEntry) ([]byte, error) { return formatOCSF(entry, mapping) }, nil
+	}
+	return func(entry storage.AccessLogEntry) ([]byte, error) {
+		event := map[string]any{}
+		for field, value := range values(entry, false) {
+			if key, ok := mapping[field]; ok {
+				set(event, key, value)
+			}
+		}
+		return json.Marshal(event)
+	}, nil
+}
+
+// values returns the fields of entry that are set, by their JSON names.
+// For security formats, times are in epoch milliseconds and addresses
+// without their port.
+func values(entry storage.AccessLogEntry, security bool) map[string]any {
+	v := map[string]any{
+		"schema": entry.Schema,
+		"method": entry.Method,
+		"path":   entry.Path,
+		"status": entry.Status,
+		"bytes":  entry.Bytes,
+	}
+	if security {
+		v["time"] = entry.Time.UnixMilli()
+		v["latencyMs"] = int64(entry.LatencyMS + 0.5)
+	} else {
+		v["time"] = entry.Time
+		v["latencyMs"] = entry.LatencyMS
+	}
+	optional := map[string]string{
+		"requestId":  entry.RequestID,
+		"route":      entry.Route,
+		"actor":      entry.Actor,
+		"raid":       entry.RAiD,
+		"remoteAddr": entry.RemoteAddr,
+		"userAgent":  entry.UserAgent,
+	}
+	for field, s := range optional {
+		if s != "" {
+			v[field] = s
+		}
+	}
+	if security && entry.RemoteAddr != "" {
+		if host, _, err := net.SplitHostPort(entry.RemoteAddr); err == nil {
+			v["remoteAddr"] = host
+		}
+	}
+	if entry.ServicePoint != 0 {
+		v["servicePoint"] = entry.ServicePoint
+	}
+	if entry.Version != 0 {
+		v["version"] = entry.Version
+	}
+	return v
+}
+
+// formatCEF renders entry as a CEF line whose signature is the method and
+// route, with a severity by status: 3 for success, 5 for client errors and
+// 7 for server errors
+func formatCEF(entry storage.AccessLogEntry, mapping map[string]string) []byte {
+	operation := entry.Method + " " + entry.Route
+	if entry.Route == "" {
+		operation = entry.Method + " " + entry.Path
+	}
+	severity := 3
+	switch {
+	case entry.Status >= 500:
+		severity = 7
+	case entry.Status >= 400:
+		severity = 5
+	}
+
+	var b strings.Builder
+	b.WriteString("CEF:0")
+	for _, h := range []string{productVendor, productName, productVersion(), operation, operation, strconv.Itoa(severity)} {
+		b.WriteByte('|')
+		b.WriteString(cefHeaderEscaper.Replace(h))
+	}
+	b.WriteByte('|')
+
+	vals := values(entry, true)
+	outcome := "success"
+	if entry.Status >= 400 {
+		outcome = "failure"
+	}
+	ext := []string{"outcome=" + outcome}
+	fields := make([]string, 0, len(vals))
+	for field := range vals {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		key, ok := mapping[field]
+		if !ok {
+			continue
+		}
+		ext = append(ext, key+"="+cefValueEscaper.Replace(fmt.Sprint(vals[field])))
+		if isCEFCustom(key) {
+			ext = append(ext, key+"Label="+cefValueEscaper.Replace(field))
+		}
+	}
+	b.WriteString(strings.Join(ext, " "))
+	return []byte(b.String())
+}
+
+var (
+	cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper  = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// validCEFKey reports whether key can be an extension key: letters and
+// digits only
+func validCEFKey(key string) bool {
+	for _, r := range key {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return key != ""
+}
+
+// isCEFCustom reports whether key is a custom extension key such as cs1,
+// which takes a label
+func isCEFCustom(key string) bool {
+	for _, p := range []string{"cs", "cn", "cfp", "c6a", "deviceCustomDate"} {
+		if rest, ok := strings.CutPrefix(key, p); ok {
+			_, err := strconv.Atoi(rest)
+			return err == nil
+		}
+	}
+	return false
+}
+
+// formatOCSF renders entry as an OCSF API Activity event. The activity is
+// Create, Read, Update or Delete by method, and Other for the rest.
+func formatOCSF(entry storage.AccessLogEntry, mapping map[string]string) ([]byte, error) {
+	activity := 99
+	switch entry.Method {
+	case "POST":
+		activity = 1
+	case "GET", "HEAD":
+		activity = 2
+	case "PUT", "PATCH":
+		activity = 3
+	case "DELETE":
+		activity = 4
+	}
+	status := ocsfStatusSuccess
+	if entry.Status >= 400 {
+		status = ocsfStatusFailure
+	}
+	event := map[string]any{
+		"class_uid":    ocsfAPIActivity,
+		"category_uid": ocsfApplicationCat,
+		"activity_id":  activity,
+		"type_uid":     ocsfAPIActivity*100 + activity,
+		"severity_id":  ocsfSeverityInfo,
+		"status_id":    status,
+		"metadata": map[string]any{
+			"version":  ocsfVersion,
+			"log_name": entry.Schema,
+			"product":  map[string]any{"name": productName, "vendor_name": productVendor, "version": productVersion()},
+		},
+	}
+	for field, value := range values(entry, true) {
+		if key, ok := mapping[field]; ok {
+			set(event, key, value)
+		}
+	}
+	return json.Marshal(event)
+}
+
+// set sets the dot-separated attribute key of event to value, creating the
+// objects on its path
+func set(event map[string]any, key string, value any) {
+	path := strings.Split(key, ".")
+	for _, name := range path[:len(path)-1] {
+		next, ok := event[name].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			event[name] = next
+		}
+		event = next
+	}
+	event[path[len(path)-1]] = value
+}
+
+// productVersion returns the version of the server's module
+func productVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
@@ -0,0 +1,95 @@
+package accesslog
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// storeBatchSize is the largest number of entries written at once
+	storeBatchSize = 100
+	// storeFlushInterval bounds how long entries wait before being written
+	storeFlushInterval = time.Second
+)
+
+// StoreSink writes entries to a storage backend in batches from a background
+// goroutine. Entries are dropped when the buffer is full.
+type StoreSink struct {
+	store   storage.AccessLogStore
+	entries chan storage.AccessLogEntry
+	done    chan struct{}
+}
+
+// NewStoreSink starts a sink buffering up to bufferSize entries
+func NewStoreSink(store storage.AccessLogStore, bufferSize int) *StoreSink {
+	if bufferSize <= 0 {
+		bufferSize = 10 * storeBatchSize
+	}
+	s := &StoreSink{
+		store:   store,
+		entries: make(chan storage.AccessLogEntry, bufferSize),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Log queues an entry
+func (s *StoreSink) Log(entry storage.AccessLogEntry) {
+	select {
+	case s.entries <- entry:
+	default:
+		metrics.Add("dropped", 1)
+	}
+}
+
+func (s *StoreSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(storeFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.AccessLogEntry, 0, storeBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := s.store.WriteAccessLog(ctx, batch)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to write %d access log entries: %v", len(batch), err)
+			metrics.Add("errors", int64(len(batch)))
+		} else {
+			metrics.Add("written", int64(len(batch)))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case entry, ok := <-s.entries:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= storeBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// Close writes any queued entries and stops the background goroutine. Log
+// must not be called after Close.
+func (s *StoreSink) Close() error {
+	close(s.entries)
+	<-s.done
+	return nil
+}
@@ -0,0 +1,172 @@
+// Package activitypub publishes registry activity as ActivityStreams 2.0
+// (https://www.w3.org/TR/activitystreams-core/): mints and updates of public
+// RAiDs are Create and Update activities of the registry's actor, listed in
+// its outbox. With a signing key the actor also takes ActivityPub follows,
+// and activities are delivered to the inboxes of its followers as they
+// happen.
+package activitypub
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+const (
+	// ContentType is the media type of ActivityStreams documents
+	ContentType = "application/activity+json"
+	// Context is the JSON-LD context of ActivityStreams documents, and
+	// SecurityContext that of the actor's public key
+	Context         = "https://www.w3.org/ns/activitystreams"
+	SecurityContext = "https://w3id.org/security/v1"
+	// Public addresses activities to everyone
+	Public = "https://www.w3.org/ns/activitystreams#Public"
+
+	// Paths of the actor and its collections, relative to the base URL
+	ActorPath     = "/activitypub/actor"
+	InboxPath     = "/activitypub/inbox"
+	OutboxPath    = "/activitypub/outbox"
+	FollowersPath = "/activitypub/followers"
+)
+
+// Activity types
+const (
+	TypeCreate = "Create"
+	TypeUpdate = "Update"
+	TypeFollow = "Follow"
+	TypeAccept = "Accept"
+	TypeUndo   = "Undo"
+)
+
+// Object is the ActivityStreams object of a RAiD: a page with the RAiD's
+// primary title and first description, at the RAiD's landing page
+type Object struct {
+	ID           string   `json:"id"`
+	Type         string   `json:"type"`
+	Name         string   `json:"name,omitempty"`
+	Summary      string   `json:"summary,omitempty"`
+	URL          string   `json:"url,omitempty"`
+	AttributedTo string   `json:"attributedTo,omitempty"`
+	Published    string   `json:"published,omitempty"`
+	Updated      string   `json:"updated,omitempty"`
+	To           []string `json:"to,omitempty"`
+}
+
+// Activity is an activity of the registry's actor
+type Activity struct {
+	JSONLDContext any      `json:"@context,omitempty"`
+	ID            string   `json:"id"`
+	Type          string   `json:"type"`
+	Actor         string   `json:"actor"`
+	Object        any      `json:"object"`
+	Published     string   `json:"published,omitempty"`
+	To            []string `json:"to,omitempty"`
+	CC            []string `json:"cc,omitempty"`
+}
+
+// PublicKey is the key an actor signs its requests with
+type PublicKey struct {
+	ID           string `json:"id"`
+	Owner        string `json:"owner"`
+	PublicKeyPEM string `json:"publicKeyPem"`
+}
+
+// Endpoints are the endpoints an actor shares with others on its server
+type Endpoints struct {
+	SharedInbox string `json:"sharedInbox,omitempty"`
+}
+
+// Actor is an ActivityPub actor: the registry's own, or a follower as read
+// from its server
+type Actor struct {
+	JSONLDContext     any        `json:"@context,omitempty"`
+	ID                string     `json:"id"`
+	Type              string     `json:"type"`
+	PreferredUsername string     `json:"preferredUsername,omitempty"`
+	Name              string     `json:"name,omitempty"`
+	Summary           string     `json:"summary,omitempty"`
+	Inbox             string     `json:"inbox,omitempty"`
+	Outbox            string     `json:"outbox,omitempty"`
+	Followers         string     `json:"followers,omitempty"`
+	Endpoints         *Endpoints `json:"endpoints,omitempty"`
+	PublicKey         *PublicKey `json:"publicKey,omitempty"`
+}
+
+// OrderedCollection is a collection of the actor, newest first
+type OrderedCollection struct {
+	JSONLDContext any    `json:"@context,omitempty"`
+	ID            string `json:"id"`
+	Type          string `json:"type"`
+	TotalItems    int    `json:"totalItems"`
+	OrderedItems  []any  `json:"orderedItems,omitempty"`
+}
+
+// ForRAiD returns the activity of the last mint or update of raid, as an
+// activity of the actor at base: Create for a first version and Update
+// otherwise. Objects have IDs under base, as servers expect them on the
+// actor's host, and link to the RAiD's landing page.
+func ForRAiD(base string, raid *models.RAiD) *Activity {
+	base = strings.TrimSuffix(base, "/")
+	actor := base + ActorPath
+	id := raid.Identifier.ID
+	if prefix, suffix, err := identifier.Parse(raid.Identifier.ID); err == nil {
+		id = base + "/raid/" + prefix + "/" + suffix
+	}
+	obj := &Object{
+		ID:           id,
+		Type:         "Page",
+		Name:         title(raid),
+		URL:          raid.Identifier.ID,
+		AttributedTo: actor,
+		To:           []string{Public},
+	}
+	if len(raid.Description) > 0 {
+		obj.Summary = raid.Description[0].Text
+	}
+	typ := TypeUpdate
+	if raid.Identifier.Version <= 1 {
+		typ = TypeCreate
+	}
+	var changed time.Time
+	if raid.Metadata != nil {
+		obj.Published = formatTime(raid.Metadata.Created)
+		if raid.Metadata.Updated.After(raid.Metadata.Created) {
+			obj.Updated = formatTime(raid.Metadata.Updated)
+			changed = raid.Metadata.Updated
+		} else {
+			changed = raid.Metadata.Created
+		}
+	}
+	return &Activity{
+		ID:        id + "#v" + strconv.Itoa(raid.Identifier.Version),
+		Type:      typ,
+		Actor:     actor,
+		Object:    obj,
+		Published: formatTime(changed),
+		To:        []string{Public},
+		CC:        []string{base + FollowersPath},
+	}
+}
+
+// title returns the primary title of raid, or its first title
+func title(raid *models.RAiD) string {
+	for _, t := range raid.Title {
+		if t.Type != nil && t.Type.ID == models.TitleTypePrimary {
+			return t.Text
+		}
+	}
+	if len(raid.Title) > 0 {
+		return raid.Title[0].Text
+	}
+	return ""
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,197 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// followers is a FollowerStore in memory
+type followers struct {
+	mu   sync.Mutex
+	byID map[string]*models.Follower
+}
+
+func (f *followers) SaveFollower(ctx context.Context, follower *models.Follower) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.byID[follower.ID] = follower
+	return nil
+}
+
+func (f *followers) ListFollowers(ctx context.Context) ([]*models.Follower, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	list := make([]*models.Follower, 0, len(f.byID))
+	for _, follower := range f.byID {
+		list = append(list, follower)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (f *followers) DeleteFollower(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.byID[id] == nil {
+		return storage.ErrNotFound
+	}
+	delete(f.byID, id)
+	return nil
+}
+
+func newKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestSignature(t *testing.T) {
+	key, _ := newKey(t)
+	public, err := publicKeyPEM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := []byte(`{"type":"Follow"}`)
+	req := httptest.NewRequest(http.MethodPost, "https://raid.example.org/activitypub/inbox", bytes.NewReader(body))
+	if err := Sign(req, body, "https://social.example.org/users/a#main-key", key); err != nil {
+		t.Fatal(err)
+	}
+	if KeyID(req) != "https://social.example.org/users/a#main-key" {
+		t.Errorf("expected the key ID to be read back, got %q", KeyID(req))
+	}
+	if err := Verify(req, body, public); err != nil {
+		t.Errorf("expected the signature to verify, got %v", err)
+	}
+	if err := Verify(req, []byte(`{"type":"Undo"}`), public); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for another body, got %v", err)
+	}
+	_, other := newKey(t)
+	otherKey, _ := ParseKey(other)
+	otherPublic, _ := publicKeyPEM(otherKey)
+	if err := Verify(req, body, otherPublic); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for another key, got %v", err)
+	}
+	req.Header.Set("Date", time.Now().Add(-48*time.Hour).UTC().Format(http.TimeFormat))
+	if err := Verify(req, body, public); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for a stale date, got %v", err)
+	}
+}
+
+func TestForRAiD(t *testing.T) {
+	raid := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.1/a", Version: 2},
+		Title:      []models.Title{{Text: "Alternative"}, {Text: "Primary", Type: &models.IDSchema{ID: models.TitleTypePrimary}}},
+	}
+	activity := ForRAiD("https://raid.example.org/", raid)
+	obj := activity.Object.(*Object)
+	if activity.Type != TypeUpdate || activity.Actor != "https://raid.example.org"+ActorPath {
+		t.Errorf("expected an Update of the registry's actor, got %+v", activity)
+	}
+	if obj.ID != "https://raid.example.org/raid/10.1/a" || obj.URL != raid.Identifier.ID || obj.Name != "Primary" {
+		t.Errorf("expected the RAiD's page under the base URL, got %+v", obj)
+	}
+	raid.Identifier.Version = 1
+	if ForRAiD("https://raid.example.org", raid).Type != TypeCreate {
+		t.Error("expected a first version to be a Create")
+	}
+}
+
+func TestFollow(t *testing.T) {
+	// A remote server, with an actor following the registry
+	remoteKey, _ := newKey(t)
+	remotePublic, _ := publicKeyPEM(remoteKey)
+	var mu sync.Mutex
+	var received []map[string]any
+	var registry *Publisher
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			host := "http://" + r.Host
+			json.NewEncoder(w).Encode(Actor{
+				ID: host + "/users/a", Type: "Person", Inbox: host + "/users/a/inbox",
+				Endpoints: &Endpoints{SharedInbox: host + "/inbox"},
+				PublicKey: &PublicKey{ID: host + "/users/a#main-key", Owner: host + "/users/a", PublicKeyPEM: remotePublic},
+			})
+		case http.MethodPost:
+			body, _ := io.ReadAll(r.Body)
+			if err := Verify(r, body, registry.Actor().PublicKey.PublicKeyPEM); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			var activity map[string]any
+			json.Unmarshal(body, &activity)
+			mu.Lock()
+			received = append(received, activity)
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer remote.Close()
+
+	_, keyPEM := newKey(t)
+	store := &followers{byID: map[string]*models.Follower{}}
+	registry, err := NewPublisher("https://raid.example.org", "raid", keyPEM, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !registry.Follows() || registry.Actor().PublicKey == nil {
+		t.Fatal("expected the actor to take follows")
+	}
+
+	receive := func(activity map[string]any, key *rsa.PrivateKey) error {
+		body, _ := json.Marshal(activity)
+		req := httptest.NewRequest(http.MethodPost, "https://raid.example.org"+InboxPath, bytes.NewReader(body))
+		if err := Sign(req, body, remote.URL+"/users/a#main-key", key); err != nil {
+			t.Fatal(err)
+		}
+		return registry.Receive(context.Background(), req, body)
+	}
+	follow := map[string]any{"id": remote.URL + "/follows/1", "type": TypeFollow, "actor": remote.URL + "/users/a", "object": registry.ID()}
+
+	forged, _ := newKey(t)
+	if err := receive(follow, forged); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected a follow signed with another key to be refused, got %v", err)
+	}
+	if err := receive(follow, remoteKey); err != nil {
+		t.Fatal(err)
+	}
+	registry.Wait()
+	list, _ := store.ListFollowers(context.Background())
+	if len(list) != 1 || list[0].Inbox != remote.URL+"/inbox" {
+		t.Fatalf("expected the follower with its shared inbox, got %+v", list)
+	}
+
+	registry.Publish(context.Background(), &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.1/a", Version: 1}})
+	registry.Wait()
+	mu.Lock()
+	if len(received) != 2 || received[0]["type"] != TypeAccept || received[1]["type"] != TypeCreate {
+		t.Errorf("expected the follow to be accepted and the mint delivered, got %v", received)
+	}
+	mu.Unlock()
+
+	if err := receive(map[string]any{"id": remote.URL + "/undo/1", "type": TypeUndo, "actor": remote.URL + "/users/a", "object": follow}, remoteKey); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := registry.CountFollowers(context.Background()); n != 0 {
+		t.Errorf("expected the follow to be undone, got %d followers", n)
+	}
+}
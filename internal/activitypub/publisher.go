@@ -0,0 +1,300 @@
+package activitypub
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// metrics counts follows and deliveries, published under "activitypub" in
+// /debug/vars
+var metrics = expvar.NewMap("activitypub")
+
+// maxDocumentBytes bounds the actor documents read from other servers
+const maxDocumentBytes = 1 << 20
+
+// ErrUnsupported is returned for activities the inbox does not take
+var ErrUnsupported = errors.New("only Follow and Undo Follow activities are supported")
+
+// Publisher is the registry's actor. It describes the actor and, with a
+// key and a follower store, takes follows and delivers activities to the
+// followers in the background, so that slow or unreachable servers do not
+// hold up requests.
+type Publisher struct {
+	base      string
+	username  string
+	key       *rsa.PrivateKey
+	publicKey string
+	followers storage.FollowerStore
+	client    *http.Client
+	sending   sync.WaitGroup
+}
+
+// NewPublisher creates the actor of the registry at base, named username.
+// Follows are taken when keyPEM, the RSA key requests are signed with, and
+// followers are given.
+func NewPublisher(base, username, keyPEM string, followers storage.FollowerStore) (*Publisher, error) {
+	p := &Publisher{
+		base:     strings.TrimSuffix(base, "/"),
+		username: username,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if keyPEM == "" || followers == nil {
+		return p, nil
+	}
+	key, err := ParseKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	if p.publicKey, err = publicKeyPEM(key); err != nil {
+		return nil, err
+	}
+	p.key, p.followers = key, followers
+	return p, nil
+}
+
+// Base returns the base URL of the actor's paths
+func (p *Publisher) Base() string {
+	return p.base
+}
+
+// Username returns the preferred username of the actor
+func (p *Publisher) Username() string {
+	return p.username
+}
+
+// Follows reports whether the actor takes follows
+func (p *Publisher) Follows() bool {
+	return p.key != nil
+}
+
+// ID returns the URL of the actor
+func (p *Publisher) ID() string {
+	return p.base + ActorPath
+}
+
+func (p *Publisher) keyID() string {
+	return p.ID() + "#main-key"
+}
+
+// Actor returns the actor document
+func (p *Publisher) Actor() *Actor {
+	actor := &Actor{
+		JSONLDContext:     []string{Context, SecurityContext},
+		ID:                p.ID(),
+		Type:              "Service",
+		PreferredUsername: p.username,
+		Name:              "RAiD registry",
+		Summary:           "Mints and updates of public Research Activity Identifiers",
+		Inbox:             p.base + InboxPath,
+		Outbox:            p.base + OutboxPath,
+	}
+	if p.Follows() {
+		actor.Followers = p.base + FollowersPath
+		actor.PublicKey = &PublicKey{ID: p.keyID(), Owner: p.ID(), PublicKeyPEM: p.publicKey}
+	}
+	return actor
+}
+
+// Publish delivers the activity of the last mint or update of raid to the
+// followers. Failures are logged.
+func (p *Publisher) Publish(ctx context.Context, raid *models.RAiD) {
+	if !p.Follows() || raid.Identifier == nil {
+		return
+	}
+	activity := ForRAiD(p.base, raid)
+	activity.JSONLDContext = Context
+	ctx = context.WithoutCancel(ctx)
+	p.sending.Add(1)
+	go func() {
+		defer p.sending.Done()
+		followers, err := p.followers.ListFollowers(ctx)
+		if err != nil {
+			log.Printf("Failed to list ActivityPub followers: %v", err)
+			return
+		}
+		// Followers on one server share its inbox
+		delivered := make(map[string]bool, len(followers))
+		for _, f := range followers {
+			if delivered[f.Inbox] {
+				continue
+			}
+			delivered[f.Inbox] = true
+			if err := p.Deliver(ctx, f.Inbox, activity); err != nil {
+				metrics.Add("failed", 1)
+				log.Printf("Failed to deliver %s to %s: %v", activity.ID, f.Inbox, err)
+				continue
+			}
+			metrics.Add("delivered", 1)
+		}
+	}()
+}
+
+// Wait waits for the activities being delivered
+func (p *Publisher) Wait() {
+	p.sending.Wait()
+}
+
+// Deliver posts a signed activity to inbox
+func (p *Publisher) Deliver(ctx context.Context, inbox string, activity *Activity) error {
+	body, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	if err := Sign(req, body, p.keyID(), p.key); err != nil {
+		return err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("inbox responded %s", resp.Status)
+	}
+	return nil
+}
+
+// FetchActor reads the actor document at id, signing the request as
+// servers requiring authorized fetch expect
+func (p *Publisher) FetchActor(ctx context.Context, id string) (*Actor, error) {
+	u, err := url.Parse(id)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("actor %q is not an http(s) URL", id)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, id, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentType)
+	if err := Sign(req, nil, p.keyID(), p.key); err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch actor %s: %s", id, resp.Status)
+	}
+	var actor Actor
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentBytes)).Decode(&actor); err != nil {
+		return nil, fmt.Errorf("fetch actor %s: %w", id, err)
+	}
+	return &actor, nil
+}
+
+// inbound is an activity received in the inbox
+type inbound struct {
+	ID     string          `json:"id"`
+	Type   string          `json:"type"`
+	Actor  string          `json:"actor"`
+	Object json.RawMessage `json:"object"`
+}
+
+// Receive takes an activity posted to the inbox by req, whose body is
+// body. Follows of the actor add the follower and are accepted; undoing
+// them removes it. The request must be signed by the activity's actor.
+func (p *Publisher) Receive(ctx context.Context, req *http.Request, body []byte) error {
+	var activity inbound
+	if err := json.Unmarshal(body, &activity); err != nil {
+		return fmt.Errorf("invalid activity: %w", err)
+	}
+	if activity.Type != TypeFollow && activity.Type != TypeUndo {
+		return ErrUnsupported
+	}
+	if activity.Actor == "" {
+		return errors.New("activity has no actor")
+	}
+
+	// The key must be the actor's own
+	keyID := KeyID(req)
+	if keyID == "" {
+		return ErrInvalidSignature
+	}
+	actor, err := p.FetchActor(ctx, activity.Actor)
+	if err != nil {
+		return err
+	}
+	if actor.ID != activity.Actor || actor.PublicKey == nil || actor.PublicKey.ID != keyID {
+		return fmt.Errorf("%w: not signed with the key of %s", ErrInvalidSignature, activity.Actor)
+	}
+	if err := Verify(req, body, actor.PublicKey.PublicKeyPEM); err != nil {
+		return err
+	}
+
+	if activity.Type == TypeUndo {
+		var undone inbound
+		if err := json.Unmarshal(activity.Object, &undone); err != nil || undone.Type != TypeFollow {
+			return ErrUnsupported
+		}
+		if err := p.followers.DeleteFollower(ctx, actor.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+		metrics.Add("unfollowed", 1)
+		return nil
+	}
+
+	var object string
+	if err := json.Unmarshal(activity.Object, &object); err != nil || object != p.ID() {
+		return errors.New("only the registry's actor can be followed")
+	}
+	inbox := actor.Inbox
+	if actor.Endpoints != nil && actor.Endpoints.SharedInbox != "" {
+		inbox = actor.Endpoints.SharedInbox
+	}
+	if inbox == "" {
+		return fmt.Errorf("actor %s has no inbox", actor.ID)
+	}
+	if err := p.followers.SaveFollower(ctx, &models.Follower{ID: actor.ID, Inbox: inbox, Followed: time.Now().UTC()}); err != nil {
+		return err
+	}
+	metrics.Add("followed", 1)
+
+	accept := &Activity{
+		JSONLDContext: Context,
+		ID:            p.ID() + "#accept-" + url.QueryEscape(activity.ID),
+		Type:          TypeAccept,
+		Actor:         p.ID(),
+		Object:        json.RawMessage(body),
+	}
+	ctx = context.WithoutCancel(ctx)
+	p.sending.Add(1)
+	go func() {
+		defer p.sending.Done()
+		if err := p.Deliver(ctx, actor.Inbox, accept); err != nil {
+			log.Printf("Failed to accept the follow of %s: %v", actor.ID, err)
+		}
+	}()
+	return nil
+}
+
+// CountFollowers returns the number of followers
+func (p *Publisher) CountFollowers(ctx context.Context) (int, error) {
+	if !p.Follows() {
+		return 0, nil
+	}
+	followers, err := p.followers.ListFollowers(ctx)
+	return len(followers), err
+}
@@ -0,0 +1,44 @@
+package activitypub
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that delivers the mints and updates of public
+// RAiDs to the followers of publisher
+func Wrap(repo storage.Repository, publisher *Publisher) storage.Repository {
+	return &repository{Repository: repo, publisher: publisher}
+}
+
+type repository struct {
+	storage.Repository
+	publisher *Publisher
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.publish(ctx, created)
+	return created, nil
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	updated, err := r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.publish(ctx, updated)
+	return updated, nil
+}
+
+func (r *repository) publish(ctx context.Context, raid *models.RAiD) {
+	if raid.Access == nil || raid.Access.Type == nil || raid.Access.Type.ID != storage.AccessTypeOpen {
+		return
+	}
+	r.publisher.Publish(ctx, raid)
+}
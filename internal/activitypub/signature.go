@@ -0,0 +1,199 @@
+package activitypub
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Requests between ActivityPub servers are signed with HTTP Signatures as
+// deployed in the fediverse (draft-cavage-http-signatures-12): RSA keys,
+// rsa-sha256, over the request target, host, date and body digest.
+
+// ErrInvalidSignature is returned for requests whose signature is missing
+// or does not verify
+var ErrInvalidSignature = errors.New("invalid HTTP signature")
+
+// clockSkew bounds how far the Date of a signed request may be from now
+const clockSkew = 12 * time.Hour
+
+// ParseKey reads an RSA private key from PEM, in PKCS #8 or PKCS #1 form
+func ParseKey(pemKey string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("key is not PEM-encoded")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not an RSA key")
+	}
+	return key, nil
+}
+
+// publicKeyPEM returns the PEM encoding of the public half of key
+func publicKeyPEM(key *rsa.PrivateKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Sign signs req, whose body is body, with key, named keyID, setting its
+// Date, Digest and Signature headers
+func Sign(req *http.Request, body []byte, keyID string, key *rsa.PrivateKey) error {
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	headers := []string{"(request-target)", "host", "date"}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		req.Header.Set("Digest", "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]))
+		headers = append(headers, "digest")
+	}
+	digest := sha256.Sum256([]byte(signingString(req, headers)))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Signature", fmt.Sprintf(`keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(sig)))
+	return nil
+}
+
+// signature is a parsed Signature header
+type signature struct {
+	keyID     string
+	algorithm string
+	headers   []string
+	signature []byte
+}
+
+func parseSignature(header string) (*signature, error) {
+	sig := &signature{headers: []string{"date"}}
+	for _, param := range strings.Split(header, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		switch name {
+		case "keyId":
+			sig.keyID = value
+		case "algorithm":
+			sig.algorithm = value
+		case "headers":
+			sig.headers = strings.Fields(strings.ToLower(value))
+		case "signature":
+			b, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, ErrInvalidSignature
+			}
+			sig.signature = b
+		}
+	}
+	if sig.keyID == "" || sig.signature == nil {
+		return nil, ErrInvalidSignature
+	}
+	return sig, nil
+}
+
+// KeyID returns the ID of the key req is signed with, if it is signed
+func KeyID(req *http.Request) string {
+	sig, err := parseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return ""
+	}
+	return sig.keyID
+}
+
+// Verify checks the signature of req, whose body is body, against the
+// PEM-encoded public key of its signer. Signatures must cover the request
+// target, host and date, and the digest of the body, which is checked too.
+func Verify(req *http.Request, body []byte, pemKey string) error {
+	sig, err := parseSignature(req.Header.Get("Signature"))
+	if err != nil {
+		return err
+	}
+	for _, required := range []string{"(request-target)", "host", "date", "digest"} {
+		if required == "digest" && body == nil {
+			continue
+		}
+		found := false
+		for _, h := range sig.headers {
+			found = found || h == required
+		}
+		if !found {
+			return fmt.Errorf("%w: %s is not signed", ErrInvalidSignature, required)
+		}
+	}
+	date, err := http.ParseTime(req.Header.Get("Date"))
+	if err != nil || time.Since(date).Abs() > clockSkew {
+		return fmt.Errorf("%w: date is missing or too far from now", ErrInvalidSignature)
+	}
+	if body != nil {
+		sum := sha256.Sum256(body)
+		if req.Header.Get("Digest") != "SHA-256="+base64.StdEncoding.EncodeToString(sum[:]) {
+			return fmt.Errorf("%w: digest does not match the body", ErrInvalidSignature)
+		}
+	}
+
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return fmt.Errorf("%w: signer's key is not PEM-encoded", ErrInvalidSignature)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	signed := []byte(signingString(req, sig.headers))
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(signed)
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest[:], sig.signature) != nil {
+			return ErrInvalidSignature
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(pub, signed, sig.signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: unsupported key type %T", ErrInvalidSignature, pub)
+	}
+	return nil
+}
+
+// signingString returns the string signed for headers of req
+func signingString(req *http.Request, headers []string) string {
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = h + ": " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			host := req.Host
+			if host == "" {
+				host = req.URL.Host
+			}
+			lines[i] = h + ": " + host
+		default:
+			lines[i] = h + ": " + strings.Join(req.Header.Values(h), ", ")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,132 @@
+// Package agency lets one deployment host several registration agencies.
+// Each agency is served on its own host names, mints identifiers under its
+// own base URL and sees only the service points assigned to it and the
+// RAiDs they own. Requests on other hosts see everything.
+package agency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/prefix"
+)
+
+// Agency is a registration agency hosted by the deployment
+type Agency struct {
+	// ID identifies the agency and is recorded on its service points
+	ID   string `yaml:"id" toml:"id" json:"id"`
+	Name string `yaml:"name" toml:"name" json:"name,omitempty"`
+	// BaseURL is the URL identifiers are minted under, e.g.
+	// https://raid.example.org/
+	BaseURL string `yaml:"baseUrl" toml:"baseUrl" json:"baseUrl"`
+	// Hosts are the request host names served as this agency
+	Hosts []string `yaml:"hosts" toml:"hosts" json:"hosts,omitempty"`
+	// RegistrationAgency is the ROR ID recorded as the registration agency
+	// of RAiDs minted through the agency, if they do not name one
+	RegistrationAgency string `yaml:"registrationAgency" toml:"registrationAgency" json:"registrationAgency,omitempty"`
+	// Prefixes are the handle prefixes allocated to the agency; its service
+	// points may then only own these or prefixes derived from them
+	Prefixes []string `yaml:"prefixes" toml:"prefixes" json:"prefixes,omitempty"`
+}
+
+// Validate checks a set of agencies for missing or conflicting settings
+func Validate(agencies []Agency) error {
+	var errs []error
+	ids := map[string]bool{}
+	hosts := map[string]string{}
+	for i, a := range agencies {
+		if a.ID == "" {
+			errs = append(errs, fmt.Errorf("agencies[%d].id is required", i))
+		} else if ids[a.ID] {
+			errs = append(errs, fmt.Errorf("agencies[%d].id %q is used twice", i, a.ID))
+		}
+		ids[a.ID] = true
+
+		if err := identifier.ValidateBaseURL(a.BaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("agencies[%d].baseUrl %w", i, err))
+		}
+
+		if len(a.Hosts) == 0 {
+			errs = append(errs, fmt.Errorf("agencies[%d].hosts must list at least one host", i))
+		}
+		for _, h := range a.Hosts {
+			h = strings.ToLower(h)
+			if other, ok := hosts[h]; ok {
+				errs = append(errs, fmt.Errorf("agencies[%d]: host %s is already served by agency %q", i, h, other))
+			}
+			hosts[h] = a.ID
+		}
+
+		for _, p := range a.Prefixes {
+			if !prefix.Valid(p) {
+				errs = append(errs, fmt.Errorf("agencies[%d].prefixes: %q is not a handle prefix such as 10.82841", i, p))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FormatIdentifier returns the identifier URL for a handle minted by a
+func (a *Agency) FormatIdentifier(prefix, suffix string) string {
+	return identifier.Format(a.BaseURL, prefix, suffix)
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying the agency a request is served as
+func NewContext(ctx context.Context, a *Agency) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// FromContext returns the agency a request is served as, or nil
+func FromContext(ctx context.Context) *Agency {
+	a, _ := ctx.Value(contextKey{}).(*Agency)
+	return a
+}
+
+// Registry finds the agency serving a request
+type Registry struct {
+	byHost map[string]*Agency
+	byID   map[string]*Agency
+}
+
+// NewRegistry returns a registry of validated agencies
+func NewRegistry(agencies []Agency) *Registry {
+	reg := &Registry{byHost: map[string]*Agency{}, byID: map[string]*Agency{}}
+	for i := range agencies {
+		a := &agencies[i]
+		reg.byID[a.ID] = a
+		for _, h := range a.Hosts {
+			reg.byHost[strings.ToLower(h)] = a
+		}
+	}
+	return reg
+}
+
+// Lookup returns the agency serving host, which may include a port, or nil
+func (reg *Registry) Lookup(host string) *Agency {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return reg.byHost[strings.ToLower(host)]
+}
+
+// Get returns the agency with id, or nil
+func (reg *Registry) Get(id string) *Agency {
+	return reg.byID[id]
+}
+
+// Middleware adds the agency serving each request to its context
+func (reg *Registry) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a := reg.Lookup(r.Host); a != nil {
+			r = r.WithContext(NewContext(r.Context(), a))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package agency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	good := []Agency{
+		{ID: "a", BaseURL: "https://raid.a.example", Hosts: []string{"raid.a.example"}},
+		{ID: "b", BaseURL: "https://raid.b.example/", Hosts: []string{"raid.b.example", "api.b.example"}},
+	}
+	if err := Validate(good); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	bad := []Agency{
+		{ID: "a", BaseURL: "https://raid.a.example/ids?x", Hosts: []string{"raid.a.example"}},
+		{ID: "a", BaseURL: "raid.b.example", Hosts: []string{"RAID.A.EXAMPLE"}},
+		{BaseURL: "https://raid.c.example"},
+	}
+	err := Validate(bad)
+	for _, want := range []string{"agencies[0].baseUrl", "agencies[1].id \"a\" is used twice", "agencies[1].baseUrl", "host raid.a.example is already served", "agencies[2].id is required", "agencies[2].hosts"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestRegistry_Middleware(t *testing.T) {
+	reg := NewRegistry([]Agency{{ID: "a", BaseURL: "https://raid.a.example", Hosts: []string{"raid.a.example"}}})
+
+	var served string
+	h := reg.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = ""
+		if a := FromContext(r.Context()); a != nil {
+			served = a.ID
+		}
+	}))
+
+	for host, want := range map[string]string{"raid.a.example": "a", "Raid.A.Example:8080": "a", "localhost:8080": ""} {
+		req := httptest.NewRequest(http.MethodGet, "/raid/", nil)
+		req.Host = host
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if served != want {
+			t.Errorf("host %s: served as %q, want %q", host, served, want)
+		}
+	}
+
+	if got := reg.Lookup("raid.a.example").FormatIdentifier("10.1", "x"); got != "https://raid.a.example/10.1/x" {
+		t.Errorf("FormatIdentifier = %s", got)
+	}
+}
@@ -0,0 +1,295 @@
+package agency
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// rorSchema is the schema URI of registration agency ROR IDs
+const rorSchema = "https://ror.org/"
+
+// Wrap returns a repository that confines requests served as an agency to
+// that agency's service points and RAiDs. RAiDs and service points of other
+// agencies are reported as not found; minting for them is denied. Requests
+// without an agency pass through to repo unchanged.
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+// owns reports whether the service point with id belongs to a
+func (r *repository) owns(ctx context.Context, a *Agency, id int64) (bool, error) {
+	if id == 0 {
+		return false, nil
+	}
+	sp, err := r.Repository.GetServicePoint(ctx, id)
+	if err == storage.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return sp.AgencyID == a.ID, nil
+}
+
+// servicePoint returns the owning service point ID of raid, or 0
+func servicePoint(raid *models.RAiD) int64 {
+	if raid == nil || raid.Identifier == nil || raid.Identifier.Owner == nil {
+		return 0
+	}
+	return raid.Identifier.Owner.ServicePoint
+}
+
+// visible returns raid if it belongs to a, and ErrNotFound otherwise
+func (r *repository) visible(ctx context.Context, a *Agency, raid *models.RAiD) (*models.RAiD, error) {
+	ok, err := r.owns(ctx, a, servicePoint(raid))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return raid, nil
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	a := FromContext(ctx)
+	if a == nil {
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	ok, err := r.owns(ctx, a, servicePoint(raid))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, storage.ErrAccessDenied
+	}
+
+	if raid.Identifier.RegistrationAgency == nil && a.RegistrationAgency != "" {
+		raid.Identifier.RegistrationAgency = &models.RegistrationAgency{ID: a.RegistrationAgency, SchemaURI: rorSchema}
+	}
+	if raid.Identifier.ID == "" {
+		return storage.MintGenerated(ctx, r.Repository, raid, a.FormatIdentifier)
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+	raid, err := r.Repository.GetRAiD(ctx, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	if a := FromContext(ctx); a != nil {
+		return r.visible(ctx, a, raid)
+	}
+	return raid, nil
+}
+
+func (r *repository) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	raids, err := r.Repository.GetRAiDs(ctx, refs)
+	a := FromContext(ctx)
+	if err != nil || a == nil {
+		return raids, err
+	}
+	members, err := r.members(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+	for i, raid := range raids {
+		if raid != nil && !members[servicePoint(raid)] {
+			raids[i] = nil
+		}
+	}
+	return raids, nil
+}
+
+func (r *repository) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
+	raid, err := r.Repository.GetRAiDVersion(ctx, prefix, suffix, version)
+	if err != nil {
+		return nil, err
+	}
+	if a := FromContext(ctx); a != nil {
+		return r.visible(ctx, a, raid)
+	}
+	return raid, nil
+}
+
+func (r *repository) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
+	history, err := r.Repository.GetRAiDHistory(ctx, prefix, suffix)
+	if err != nil || len(history) == 0 {
+		return history, err
+	}
+	if a := FromContext(ctx); a != nil {
+		if _, err := r.visible(ctx, a, history[len(history)-1]); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+func (r *repository) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	changes, err := r.Repository.GetRAiDChanges(ctx, prefix, suffix, page)
+	if err != nil {
+		return nil, err
+	}
+	// Changes carry no owner, which the latest version read names; past the
+	// last page, that is the version the page starts after
+	version := 0
+	if len(changes) > 0 {
+		version = changes[len(changes)-1].Version
+	} else if page != nil {
+		version = page.After
+	}
+	if a := FromContext(ctx); a != nil && version > 0 {
+		latest, err := r.Repository.GetRAiDVersion(ctx, prefix, suffix, version)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := r.visible(ctx, a, latest); err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	if a := FromContext(ctx); a != nil {
+		if _, err := r.GetRAiD(ctx, prefix, suffix); err != nil {
+			return nil, err
+		}
+		// The RAiD may not be handed to another agency's service point
+		ok, err := r.owns(ctx, a, servicePoint(raid))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, storage.ErrAccessDenied
+		}
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	if FromContext(ctx) != nil {
+		if _, err := r.GetRAiD(ctx, prefix, suffix); err != nil {
+			return err
+		}
+	}
+	return r.Repository.DeleteRAiD(ctx, prefix, suffix)
+}
+
+func (r *repository) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return r.list(ctx, filter, r.Repository.ListRAiDs)
+}
+
+func (r *repository) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return r.list(ctx, filter, r.Repository.ListPublicRAiDs)
+}
+
+// list pages through the agency's RAiDs. Backends cannot filter by agency,
+// so every matching RAiD is read and the page is cut afterwards.
+func (r *repository) list(ctx context.Context, filter *storage.RAiDFilter, list func(context.Context, *storage.RAiDFilter) ([]*models.RAiD, error)) ([]*models.RAiD, error) {
+	a := FromContext(ctx)
+	if a == nil {
+		return list(ctx, filter)
+	}
+	members, err := r.members(ctx, a)
+	if err != nil {
+		return nil, err
+	}
+
+	all := *filter
+	all.Limit, all.Offset = 0, 0
+	raids, err := list(ctx, &all)
+	if err != nil {
+		return nil, err
+	}
+	page := make([]*models.RAiD, 0)
+	skipped := 0
+	for _, raid := range raids {
+		if !members[servicePoint(raid)] {
+			continue
+		}
+		if skipped < filter.Offset {
+			skipped++
+			continue
+		}
+		page = append(page, raid)
+		if filter.Limit > 0 && len(page) == filter.Limit {
+			break
+		}
+	}
+	return page, nil
+}
+
+// members returns the IDs of the service points belonging to a
+func (r *repository) members(ctx context.Context, a *Agency) (map[int64]bool, error) {
+	sps, err := r.Repository.ListServicePoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[int64]bool)
+	for _, sp := range sps {
+		if sp.AgencyID == a.ID {
+			members[sp.ID] = true
+		}
+	}
+	return members, nil
+}
+
+func (r *repository) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	if a := FromContext(ctx); a != nil {
+		sp.AgencyID = a.ID
+	}
+	return r.Repository.CreateServicePoint(ctx, sp)
+}
+
+func (r *repository) GetServicePoint(ctx context.Context, id int64) (*models.ServicePoint, error) {
+	sp, err := r.Repository.GetServicePoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a := FromContext(ctx); a != nil && sp.AgencyID != a.ID {
+		return nil, storage.ErrNotFound
+	}
+	return sp, nil
+}
+
+func (r *repository) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	if a := FromContext(ctx); a != nil {
+		if _, err := r.GetServicePoint(ctx, id); err != nil {
+			return nil, err
+		}
+		sp.AgencyID = a.ID
+	}
+	return r.Repository.UpdateServicePoint(ctx, id, sp)
+}
+
+func (r *repository) ListServicePoints(ctx context.Context) ([]*models.ServicePoint, error) {
+	sps, err := r.Repository.ListServicePoints(ctx)
+	a := FromContext(ctx)
+	if err != nil || a == nil {
+		return sps, err
+	}
+	mine := make([]*models.ServicePoint, 0, len(sps))
+	for _, sp := range sps {
+		if sp.AgencyID == a.ID {
+			mine = append(mine, sp)
+		}
+	}
+	return mine, nil
+}
+
+func (r *repository) DeleteServicePoint(ctx context.Context, id int64) error {
+	if FromContext(ctx) != nil {
+		if _, err := r.GetServicePoint(ctx, id); err != nil {
+			return err
+		}
+	}
+	return r.Repository.DeleteServicePoint(ctx, id)
+}
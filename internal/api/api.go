@@ -0,0 +1,7 @@
+// Package api implements the RAiD service API as described by the reference
+// OpenAPI document (raido-openapi-3.0.yaml). The request and response types,
+// parameter binding and routes in generated.go are produced from the spec, so
+// that the server stays wire-compatible with the reference raid.org API.
+package api
+
+//go:generate go run ./apigen -config ../../oapi-codegen.yaml ../../raido-openapi-3.0.yaml
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/handlers"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+// fullRAiD returns a RAiD with every field set
+func fullRAiD() *models.RAiD {
+	lang := &models.Language{ID: "eng", SchemaURI: "https://www.iso.org/standard/74575.html"}
+	schema := &models.IDSchema{ID: "https://vocabulary.raid.org/x", SchemaURI: "https://vocabulary.raid.org/"}
+	return &models.RAiD{
+		Metadata: &models.Metadata{
+			Created: time.Date(2024, 5, 1, 12, 0, 0, 123000000, time.UTC),
+			Updated: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+		},
+		Identifier: &models.Identifier{
+			ID:                 "https://raid.org/10.99999/abc",
+			SchemaURI:          "https://raid.org/",
+			RegistrationAgency: &models.RegistrationAgency{ID: "https://ror.org/038sjwq14", SchemaURI: "https://ror.org/"},
+			Owner:              &models.Owner{ID: "https://ror.org/02stey378", SchemaURI: "https://ror.org/", ServicePoint: 1},
+			RAIDAgencyURL:      "https://static.prod.raid.org.au/raids/10.99999/abc",
+			License:            "Creative Commons CC-0",
+			Version:            2,
+		},
+		Title:       []models.Title{{Text: "Title", Type: schema, StartDate: "2024-01-01", EndDate: "2024-12-31", Language: lang}},
+		Date:        &models.Date{StartDate: "2024-01-01", EndDate: "2025-01-01"},
+		Description: []models.Description{{Text: "Description", Type: schema, Language: lang}},
+		Access: &models.Access{
+			Type:          schema,
+			Statement:     &models.AccessStatement{Text: "Embargoed", Language: lang},
+			EmbargoExpiry: "2025-01-01",
+		},
+		AlternateURL: []models.AlternateURL{{URL: "https://example.org"}},
+		Contributor: []models.Contributor{{
+			ID: "https://orcid.org/0000-0000-0000-0001", SchemaURI: "https://orcid.org/",
+			Status: "AUTHENTICATED", StatusMessage: "ok", Email: "a@example.org", UUID: "u",
+			Position: []models.ContributorPosition{{SchemaURI: schema.SchemaURI, ID: schema.ID, StartDate: "2024-01-01", EndDate: "2024-06-01"}},
+			Role:     []models.IDSchema{*schema},
+			Leader:   true,
+			Contact:  true,
+		}},
+		Organisation: []models.Organisation{{
+			ID: "https://ror.org/02stey378", SchemaURI: "https://ror.org/",
+			Role: []models.OrganisationRole{{SchemaURI: schema.SchemaURI, ID: schema.ID, StartDate: "2024-01-01", EndDate: "2024-06-01"}},
+		}},
+		Subject:              []models.Subject{{ID: "https://linked.data.gov.au/def/anzsrc-for/2020/3702", SchemaURI: "https://linked.data.gov.au/def/anzsrc-for/2020/", Keyword: []models.SubjectKeyword{{Text: "climate", Language: lang}}}},
+		RelatedRAiD:          []models.RelatedRAiD{{ID: "https://raid.org/10.99999/def", Type: schema}},
+		RelatedObject:        []models.RelatedObject{{ID: "https://doi.org/10.1000/1", SchemaURI: "https://doi.org/", Type: schema, Category: []models.IDSchema{*schema}}},
+		AlternateIdentifier:  []models.AlternateIdentifier{{ID: "ABC-1", Type: "local"}},
+		SpatialCoverage:      []models.SpatialCoverage{{ID: "https://www.geonames.org/2661886", SchemaURI: "https://www.geonames.org/", Place: []models.SpatialCoveragePlace{{Text: "Sweden", Language: lang}}}},
+		TraditionalKnowledge: []models.TraditionalKnowledge{{ID: "https://localcontexts.org/label/tk-a/", SchemaURI: "https://localcontexts.org/labels/"}},
+	}
+}
+
+// decodeStrict decodes data into v, failing on properties the spec does not
+// define
+func decodeStrict(t *testing.T, data []byte, v any) {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		t.Fatalf("response does not match the spec: %v\n%s", err, data)
+	}
+}
+
+// TestWireFormat tests that the models encode to the spec's schemas
+func TestWireFormat(t *testing.T) {
+	data, _ := json.Marshal(fullRAiD())
+	var dto RaidDto
+	decodeStrict(t, data, &dto)
+	if dto.Metadata == nil || dto.Metadata.Created == nil || *dto.Metadata.Created != 1714564800.123 {
+		t.Errorf("expected metadata.created as epoch seconds, got %s", data)
+	}
+
+	data, _ = json.Marshal(&models.ServicePoint{ID: 1, Name: "SP", IdentifierOwner: "https://ror.org/1", RepositoryID: "r", Prefix: "10.99999", GroupID: "g", SearchContent: "s", TechEmail: "t@example.org", AdminEmail: "a@example.org", Enabled: true, AppWritesEnabled: true})
+	var sp ServicePoint
+	decodeStrict(t, data, &sp)
+
+	data, _ = json.Marshal(&models.RAiDChange{Handle: "10.99999/abc", Version: 1, Diff: "W10=", Timestamp: time.Now()})
+	var change RaidChange
+	decodeStrict(t, data, &change)
+}
+
+// TestMetadata_RoundTrip tests that metadata survives encoding and that
+// documents stored with RFC 3339 timestamps can still be read
+func TestMetadata_RoundTrip(t *testing.T) {
+	in := models.Metadata{Created: time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)}
+	data, _ := json.Marshal(in)
+	if string(data) != `{"created":1714564800.123456789}` {
+		t.Errorf("unexpected encoding %s", data)
+	}
+	var out models.Metadata
+	if err := json.Unmarshal(data, &out); err != nil || !out.Created.Equal(in.Created) || !out.Updated.IsZero() {
+		t.Errorf("round trip gave %+v, %v", out, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"created":"2024-05-01T12:00:00Z","updated":1.7145648e9}`), &out); err != nil {
+		t.Fatal(err)
+	}
+	if !out.Created.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) || !out.Updated.Equal(out.Created) {
+		t.Errorf("unexpected legacy decoding %+v", out)
+	}
+}
+
+func newTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return Handler(NewServer(handlers.NewRAiDHandler(repo, handlers.DocumentLimits{}), handlers.NewServicePointHandler(repo)))
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// TestHandler tests the generated routes against the file backend
+func TestHandler(t *testing.T) {
+	h := newTestHandler(t)
+
+	in := fullRAiD()
+	in.Identifier.Version = 0
+	raid, _ := json.Marshal(in)
+	if w := serve(h, http.MethodPost, "/raid/", string(raid)); w.Code != http.StatusCreated {
+		t.Fatalf("mint: expected 201, got %d: %s", w.Code, w.Body)
+	} else {
+		decodeStrict(t, w.Body.Bytes(), &RaidDto{})
+	}
+	in.Title[0].Text = "Updated title"
+	updated, _ := json.Marshal(in)
+	if w := serve(h, http.MethodPut, "/raid/10.99999/abc", string(updated)); w.Code != http.StatusOK {
+		t.Fatalf("update: expected 200, got %d: %s", w.Code, w.Body)
+	}
+
+	w := serve(h, http.MethodGet, "/raid/10.99999/abc", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("read: expected 200, got %d", w.Code)
+	}
+	decodeStrict(t, w.Body.Bytes(), &RaidDto{})
+
+	w = serve(h, http.MethodGet, "/raid/10.99999/abc/history", "")
+	var changes []RaidChange
+	decodeStrict(t, w.Body.Bytes(), &changes)
+	if len(changes) != 2 || *changes[1].Version != *changes[0].Version+1 || *changes[1].Handle != "10.99999/abc" {
+		t.Errorf("unexpected history %s", w.Body)
+	}
+
+	if w := serve(h, http.MethodGet, "/raid/10.99999/abc/"+strconv.Itoa(*changes[0].Version), ""); w.Code != http.StatusOK {
+		t.Errorf("read version: expected 200, got %d", w.Code)
+	}
+	if w := serve(h, http.MethodGet, "/raid/10.99999/abc/latest", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-integer version, got %d", w.Code)
+	}
+
+	w = serve(h, http.MethodPost, "/service-point/", `{"name":"SP","identifierOwner":"https://ror.org/1","groupId":"g"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create service point: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	var sp ServicePoint
+	decodeStrict(t, w.Body.Bytes(), &sp)
+	w = serve(h, http.MethodGet, "/service-point/"+strconv.FormatInt(sp.Id, 10), "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("read service point: expected 200, got %d", w.Code)
+	}
+	decodeStrict(t, w.Body.Bytes(), &ServicePoint{})
+	if w := serve(h, http.MethodGet, "/service-point/one", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a non-integer service point ID, got %d", w.Code)
+	}
+}
+
+// TestGetSpec tests that the embedded spec is the one in the repository
+func TestGetSpec(t *testing.T) {
+	want, err := os.ReadFile("../../raido-openapi-3.0.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("embedded spec is out of date; run go generate ./internal/api")
+	}
+}
@@ -0,0 +1,801 @@
+// Command apigen generates the RAiD API types, chi server and embedded spec
+// from the OpenAPI document. It reads the same configuration file as
+// oapi-codegen and produces equivalent output for the subset of OpenAPI used
+// by the RAiD specification, without adding a runtime dependency.
+//
+// Usage:
+//
+//	go run ./internal/api/apigen -config oapi-codegen.yaml raido-openapi-3.0.yaml
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the subset of the oapi-codegen configuration that apigen honours
+type Config struct {
+	Package  string `yaml:"package"`
+	Generate struct {
+		Models       bool `yaml:"models"`
+		ChiServer    bool `yaml:"chi-server"`
+		EmbeddedSpec bool `yaml:"embedded-spec"`
+	} `yaml:"generate"`
+	Output string `yaml:"output"`
+}
+
+// Spec is the subset of an OpenAPI 3.0 document that apigen understands
+type Spec struct {
+	Paths      map[string]*PathItem `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+// PathItem holds the operations for one path
+type PathItem struct {
+	Parameters []*Parameter `yaml:"parameters"`
+	Get        *Operation   `yaml:"get"`
+	Put        *Operation   `yaml:"put"`
+	Post       *Operation   `yaml:"post"`
+	Delete     *Operation   `yaml:"delete"`
+	Patch      *Operation   `yaml:"patch"`
+}
+
+// Operation is a single API operation
+type Operation struct {
+	OperationID string       `yaml:"operationId"`
+	Summary     string       `yaml:"summary"`
+	Parameters  []*Parameter `yaml:"parameters"`
+	RequestBody *struct {
+		Content map[string]struct {
+			Schema *Schema `yaml:"schema"`
+		} `yaml:"content"`
+	} `yaml:"requestBody"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name        string  `yaml:"name"`
+	In          string  `yaml:"in"`
+	Description string  `yaml:"description"`
+	Required    bool    `yaml:"required"`
+	Schema      *Schema `yaml:"schema"`
+}
+
+// Schema is a JSON schema
+type Schema struct {
+	Ref         string             `yaml:"$ref"`
+	Type        string             `yaml:"type"`
+	Format      string             `yaml:"format"`
+	Description string             `yaml:"description"`
+	Required    []string           `yaml:"required"`
+	Properties  map[string]*Schema `yaml:"properties"`
+	Items       *Schema            `yaml:"items"`
+	AllOf       []*Schema          `yaml:"allOf"`
+}
+
+func main() {
+	configFile := flag.String("config", "oapi-codegen.yaml", "oapi-codegen configuration file")
+	flag.Parse()
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: apigen -config FILE SPEC")
+		os.Exit(2)
+	}
+
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := Generate(cfg, data)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// The output path is relative to the configuration file, so that
+	// go:generate can run from the package directory
+	output := filepath.Join(filepath.Dir(*configFile), cfg.Output)
+	if err := os.WriteFile(output, src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if cfg.Package == "" || cfg.Output == "" {
+		return nil, fmt.Errorf("%s: package and output are required", path)
+	}
+	return &cfg, nil
+}
+
+// Generate returns the formatted Go source for the spec
+func Generate(cfg *Config, specData []byte) ([]byte, error) {
+	var spec Spec
+	if err := yaml.Unmarshal(specData, &spec); err != nil {
+		return nil, fmt.Errorf("parsing spec: %w", err)
+	}
+
+	g := &generator{spec: &spec}
+	ops, err := g.operations()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Generate.Models {
+		if err := g.models(ops); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Generate.ChiServer {
+		if err := g.chiServer(ops); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Generate.EmbeddedSpec {
+		if err := g.embeddedSpec(specData); err != nil {
+			return nil, err
+		}
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by apigen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", cfg.Package)
+	fmt.Fprintf(&out, "import (\n")
+	for _, imp := range importPaths {
+		name := imp[strings.LastIndex(imp, "/")+1:]
+		if name == "v5" {
+			name = "chi"
+		}
+		if regexp.MustCompile(`\b` + name + `\.`).Match(g.buf.Bytes()) {
+			if strings.Contains(imp, ".") {
+				// Separate third-party imports from the standard library
+				out.WriteString("\n")
+			}
+			fmt.Fprintf(&out, "%q\n", imp)
+		}
+	}
+	fmt.Fprintf(&out, ")\n\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w\n%s", err, out.Bytes())
+	}
+	return src, nil
+}
+
+// importPaths are the packages generated code may use; each is imported
+// when its name is referenced
+var importPaths = []string{
+	"bytes",
+	"compress/gzip",
+	"encoding/base64",
+	"fmt",
+	"io",
+	"net/http",
+	"strconv",
+	"strings",
+	"sync",
+	"time",
+	"github.com/go-chi/chi/v5",
+}
+
+type generator struct {
+	spec *Spec
+	buf  bytes.Buffer
+}
+
+func (g *generator) printf(format string, args ...any) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// operation is an API operation with its resolved path and parameters
+type operation struct {
+	*Operation
+	Method string
+	Path   string
+	Name   string
+	// PathParams are in the order they appear in the path
+	PathParams  []*Parameter
+	QueryParams []*Parameter
+	BodySchema  *Schema
+}
+
+var methods = []string{"GET", "PUT", "POST", "DELETE", "PATCH"}
+
+func (p *PathItem) operation(method string) *Operation {
+	switch method {
+	case "GET":
+		return p.Get
+	case "PUT":
+		return p.Put
+	case "POST":
+		return p.Post
+	case "DELETE":
+		return p.Delete
+	case "PATCH":
+		return p.Patch
+	}
+	return nil
+}
+
+// operations lists the operations sorted by path and method
+func (g *generator) operations() ([]*operation, error) {
+	paths := make([]string, 0, len(g.spec.Paths))
+	for path := range g.spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ops []*operation
+	for _, path := range paths {
+		item := g.spec.Paths[path]
+		for _, method := range methods {
+			o := item.operation(method)
+			if o == nil {
+				continue
+			}
+			if o.OperationID == "" {
+				return nil, fmt.Errorf("%s %s: operationId is required", method, path)
+			}
+			op := &operation{Operation: o, Method: method, Path: path, Name: goName(o.OperationID)}
+
+			params := append(append([]*Parameter{}, item.Parameters...), o.Parameters...)
+			for _, p := range params {
+				switch p.In {
+				case "path":
+					op.PathParams = append(op.PathParams, p)
+				case "query":
+					op.QueryParams = append(op.QueryParams, p)
+				default:
+					return nil, fmt.Errorf("%s %s: unsupported parameter location %q", method, path, p.In)
+				}
+			}
+			sort.SliceStable(op.PathParams, func(i, j int) bool {
+				return strings.Index(path, "{"+op.PathParams[i].Name+"}") < strings.Index(path, "{"+op.PathParams[j].Name+"}")
+			})
+
+			if o.RequestBody != nil {
+				if c, ok := o.RequestBody.Content["application/json"]; ok {
+					op.BodySchema = c.Schema
+				}
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// models writes a type for every component schema, plus the parameter
+// structs and request body aliases of the operations
+func (g *generator) models(ops []*operation) error {
+	names := make([]string, 0, len(g.spec.Components.Schemas))
+	for name := range g.spec.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s := g.spec.Components.Schemas[name]
+		typeName := goName(name)
+		g.comment(typeName, s.Description, "defines model for "+name+".")
+
+		if s.Type != "object" && len(s.AllOf) == 0 && len(s.Properties) == 0 {
+			t, err := g.goType(s)
+			if err != nil {
+				return fmt.Errorf("schema %s: %w", name, err)
+			}
+			g.printf("type %s = %s\n\n", typeName, t)
+			continue
+		}
+
+		props, required, err := g.flatten(s)
+		if err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+		if err := g.structType(typeName, props, required); err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	for _, op := range ops {
+		if len(op.QueryParams) > 0 {
+			g.printf("// %sParams defines parameters for %s.\n", op.Name, op.Name)
+			g.printf("type %sParams struct {\n", op.Name)
+			for _, p := range op.QueryParams {
+				t, err := g.goType(p.Schema)
+				if err != nil {
+					return fmt.Errorf("%s parameter %s: %w", op.Name, p.Name, err)
+				}
+				g.fieldComment(goName(p.Name), p.Description)
+				if p.Required {
+					g.printf("%s %s `form:\"%s\" json:\"%s\"`\n", goName(p.Name), t, p.Name, p.Name)
+				} else {
+					g.printf("%s *%s `form:\"%s,omitempty\" json:\"%s,omitempty\"`\n", goName(p.Name), t, p.Name, p.Name)
+				}
+			}
+			g.printf("}\n\n")
+		}
+	}
+
+	for _, op := range ops {
+		if op.BodySchema != nil {
+			t, err := g.goType(op.BodySchema)
+			if err != nil {
+				return fmt.Errorf("%s request body: %w", op.Name, err)
+			}
+			g.printf("// %sJSONRequestBody defines body for %s for application/json ContentType.\n", op.Name, op.Name)
+			g.printf("type %sJSONRequestBody = %s\n\n", op.Name, t)
+		}
+	}
+	return nil
+}
+
+// flatten merges the properties of allOf members into the schema's own
+func (g *generator) flatten(s *Schema) (map[string]*Schema, map[string]bool, error) {
+	props := make(map[string]*Schema)
+	required := make(map[string]bool)
+	for _, member := range s.AllOf {
+		if member.Ref != "" {
+			ref, err := g.resolve(member.Ref)
+			if err != nil {
+				return nil, nil, err
+			}
+			member = ref
+		}
+		p, r, err := g.flatten(member)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, prop := range p {
+			props[name] = prop
+		}
+		for name := range r {
+			required[name] = true
+		}
+	}
+	for name, prop := range s.Properties {
+		props[name] = prop
+	}
+	for _, name := range s.Required {
+		required[name] = true
+	}
+	return props, required, nil
+}
+
+func (g *generator) structType(typeName string, props map[string]*Schema, required map[string]bool) error {
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g.printf("type %s struct {\n", typeName)
+	for _, name := range names {
+		prop := props[name]
+		t, err := g.goType(prop)
+		if err != nil {
+			return fmt.Errorf("property %s: %w", name, err)
+		}
+		g.fieldComment(goName(name), prop.Description)
+		if required[name] {
+			g.printf("%s %s `json:\"%s\"`\n", goName(name), t, name)
+		} else {
+			g.printf("%s *%s `json:\"%s,omitempty\"`\n", goName(name), t, name)
+		}
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+// goType returns the Go type for a schema. Component schemas are referenced
+// by name.
+func (g *generator) goType(s *Schema) (string, error) {
+	if s == nil {
+		return "interface{}", nil
+	}
+	if s.Ref != "" {
+		if _, err := g.resolve(s.Ref); err != nil {
+			return "", err
+		}
+		return goName(s.Ref[strings.LastIndex(s.Ref, "/")+1:]), nil
+	}
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		switch s.Format {
+		case "int64":
+			return "int64", nil
+		case "int32":
+			return "int32", nil
+		}
+		return "int", nil
+	case "number":
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		t, err := g.goType(s.Items)
+		if err != nil {
+			return "", err
+		}
+		return "[]" + t, nil
+	case "object", "":
+		if len(s.Properties) == 0 && len(s.AllOf) == 0 {
+			return "map[string]interface{}", nil
+		}
+		return "", fmt.Errorf("inline object schemas are not supported")
+	}
+	return "", fmt.Errorf("unsupported schema type %q", s.Type)
+}
+
+func (g *generator) resolve(ref string) (*Schema, error) {
+	const prefix = "#/components/schemas/"
+	if !strings.HasPrefix(ref, prefix) {
+		return nil, fmt.Errorf("unsupported reference %q", ref)
+	}
+	s, ok := g.spec.Components.Schemas[strings.TrimPrefix(ref, prefix)]
+	if !ok {
+		return nil, fmt.Errorf("unresolved reference %q", ref)
+	}
+	return s, nil
+}
+
+// comment writes a doc comment for a type, falling back to fallback when the
+// schema has no description
+func (g *generator) comment(name, description, fallback string) {
+	description = strings.TrimSpace(description)
+	if description == "" {
+		g.printf("// %s %s\n", name, fallback)
+		return
+	}
+	lines := strings.Split(description, "\n")
+	g.printf("// %s %s\n", name, strings.TrimSpace(lines[0]))
+	for _, line := range lines[1:] {
+		g.printf("// %s\n", strings.TrimSpace(line))
+	}
+}
+
+func (g *generator) fieldComment(name, description string) {
+	if strings.TrimSpace(description) != "" {
+		g.comment(name, description, "")
+	}
+}
+
+// chiServer writes the ServerInterface, its chi wrapper and route
+// registration
+func (g *generator) chiServer(ops []*operation) error {
+	g.printf("// ServerInterface represents all server handlers.\n")
+	g.printf("type ServerInterface interface {\n")
+	for _, op := range ops {
+		if op.Summary != "" {
+			g.printf("// %s\n", op.Summary)
+		}
+		g.printf("// (%s %s)\n", op.Method, op.Path)
+		sig, err := g.signature(op)
+		if err != nil {
+			return err
+		}
+		g.printf("%s(w http.ResponseWriter, r *http.Request%s)\n", op.Name, sig)
+	}
+	g.printf("}\n\n")
+
+	g.printf(`// ServerInterfaceWrapper converts contexts to parameters.
+type ServerInterfaceWrapper struct {
+	Handler            ServerInterface
+	HandlerMiddlewares []MiddlewareFunc
+	ErrorHandlerFunc   func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// MiddlewareFunc wraps the handler of every operation.
+type MiddlewareFunc func(http.Handler) http.Handler
+
+`)
+
+	for _, op := range ops {
+		g.printf("// %s operation middleware\n", op.Name)
+		g.printf("func (siw *ServerInterfaceWrapper) %s(w http.ResponseWriter, r *http.Request) {\n", op.Name)
+		var args []string
+		for _, p := range op.PathParams {
+			v := lowerFirst(goName(p.Name))
+			args = append(args, v)
+			t, err := g.goType(p.Schema)
+			if err != nil {
+				return fmt.Errorf("%s parameter %s: %w", op.Name, p.Name, err)
+			}
+			g.printf("\n// ------------- Path parameter %q -------------\n", p.Name)
+			g.printf("var %s %s\n", v, t)
+			if err := g.bindString(v, t, fmt.Sprintf("chi.URLParam(r, %q)", p.Name), p.Name); err != nil {
+				return fmt.Errorf("%s parameter %s: %w", op.Name, p.Name, err)
+			}
+		}
+		if len(op.QueryParams) > 0 {
+			args = append(args, "params")
+			g.printf("\n// Parameter object where we will unmarshal all parameters from the context\n")
+			g.printf("var params %sParams\n", op.Name)
+			g.printf("query := r.URL.Query()\n")
+			for _, p := range op.QueryParams {
+				field := goName(p.Name)
+				t, err := g.goType(p.Schema)
+				if err != nil {
+					return fmt.Errorf("%s parameter %s: %w", op.Name, p.Name, err)
+				}
+				g.printf("\n// ------------- Query parameter %q -------------\n", p.Name)
+				if strings.HasPrefix(t, "[]") {
+					if t != "[]string" {
+						return fmt.Errorf("%s parameter %s: only string arrays are supported", op.Name, p.Name)
+					}
+					g.printf("if values, ok := query[%q]; ok {\n", p.Name)
+					g.printf("params.%s = &values\n", field)
+				} else {
+					g.printf("if value, ok := query[%q]; ok {\n", p.Name)
+					g.printf("var v %s\n", t)
+					if err := g.bindString("v", t, "value[0]", p.Name); err != nil {
+						return fmt.Errorf("%s parameter %s: %w", op.Name, p.Name, err)
+					}
+					g.printf("params.%s = &v\n", field)
+				}
+				if p.Required {
+					g.printf("} else {\n")
+					g.printf("siw.ErrorHandlerFunc(w, r, &RequiredParamError{ParamName: %q})\n", p.Name)
+					g.printf("return\n")
+				}
+				g.printf("}\n")
+			}
+		}
+
+		call := "r"
+		if len(args) > 0 {
+			call += ", " + strings.Join(args, ", ")
+		}
+		g.printf(`
+handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	siw.Handler.%s(w, %s)
+}))
+
+for _, middleware := range siw.HandlerMiddlewares {
+	handler = middleware(handler)
+}
+
+handler.ServeHTTP(w, r)
+}
+
+`, op.Name, call)
+	}
+
+	g.printf(`// InvalidParamFormatError is reported when a parameter cannot be parsed.
+type InvalidParamFormatError struct {
+	ParamName string
+	Err       error
+}
+
+func (e *InvalidParamFormatError) Error() string {
+	return fmt.Sprintf("Invalid format for parameter %%s: %%s", e.ParamName, e.Err.Error())
+}
+
+func (e *InvalidParamFormatError) Unwrap() error {
+	return e.Err
+}
+
+// RequiredParamError is reported when a required query parameter is missing.
+type RequiredParamError struct {
+	ParamName string
+}
+
+func (e *RequiredParamError) Error() string {
+	return fmt.Sprintf("Query argument %%s is required, but not found", e.ParamName)
+}
+
+// Handler creates http.Handler with routing matching OpenAPI spec.
+func Handler(si ServerInterface) http.Handler {
+	return HandlerWithOptions(si, ChiServerOptions{})
+}
+
+// ChiServerOptions configures the generated router.
+type ChiServerOptions struct {
+	BaseURL          string
+	BaseRouter       chi.Router
+	Middlewares      []MiddlewareFunc
+	ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// HandlerFromMux creates http.Handler with routing matching OpenAPI spec based on the provided mux.
+func HandlerFromMux(si ServerInterface, r chi.Router) http.Handler {
+	return HandlerWithOptions(si, ChiServerOptions{
+		BaseRouter: r,
+	})
+}
+
+// HandlerWithOptions creates http.Handler with additional options
+func HandlerWithOptions(si ServerInterface, options ChiServerOptions) http.Handler {
+	r := options.BaseRouter
+
+	if r == nil {
+		r = chi.NewRouter()
+	}
+	if options.ErrorHandlerFunc == nil {
+		options.ErrorHandlerFunc = func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}
+	wrapper := ServerInterfaceWrapper{
+		Handler:            si,
+		HandlerMiddlewares: options.Middlewares,
+		ErrorHandlerFunc:   options.ErrorHandlerFunc,
+	}
+
+`)
+	for _, op := range ops {
+		g.printf("r.Group(func(r chi.Router) {\n")
+		g.printf("r.%s(options.BaseURL+%q, wrapper.%s)\n", methodFunc(op.Method), op.Path, op.Name)
+		g.printf("})\n")
+	}
+	g.printf("\nreturn r\n}\n\n")
+	return nil
+}
+
+// signature returns the parameters of a ServerInterface method after the
+// request
+func (g *generator) signature(op *operation) (string, error) {
+	var sig string
+	for _, p := range op.PathParams {
+		t, err := g.goType(p.Schema)
+		if err != nil {
+			return "", fmt.Errorf("%s parameter %s: %w", op.Name, p.Name, err)
+		}
+		sig += fmt.Sprintf(", %s %s", lowerFirst(goName(p.Name)), t)
+	}
+	if len(op.QueryParams) > 0 {
+		sig += fmt.Sprintf(", params %sParams", op.Name)
+	}
+	return sig, nil
+}
+
+// bindString writes code parsing the string expression src into v
+func (g *generator) bindString(v, t, src, name string) error {
+	var parse string
+	switch t {
+	case "string":
+		g.printf("%s = %s\n", v, src)
+		return nil
+	case "int64":
+		parse = "strconv.ParseInt(%s, 10, 64)"
+	case "int32":
+		parse = "strconv.ParseInt(%s, 10, 32)"
+	case "int":
+		parse = "strconv.ParseInt(%s, 10, 0)"
+	case "float64":
+		parse = "strconv.ParseFloat(%s, 64)"
+	case "float32":
+		parse = "strconv.ParseFloat(%s, 32)"
+	case "bool":
+		parse = "strconv.ParseBool(%s)"
+	case "time.Time":
+		parse = "time.Parse(time.RFC3339, %s)"
+	default:
+		return fmt.Errorf("unsupported parameter type %s", t)
+	}
+	g.printf("{\nparsed, err := "+parse+"\n", src)
+	g.printf("if err != nil {\n")
+	g.printf("siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: %q, Err: err})\n", name)
+	g.printf("return\n}\n")
+	switch t {
+	case "int64", "float64", "bool", "time.Time":
+		g.printf("%s = parsed\n}\n", v)
+	default:
+		g.printf("%s = %s(parsed)\n}\n", v, t)
+	}
+	return nil
+}
+
+// embeddedSpec writes the gzipped, base64 encoded spec and GetSpec
+func (g *generator) embeddedSpec(data []byte) error {
+	var zipped bytes.Buffer
+	zw, err := gzip.NewWriterLevel(&zipped, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(zipped.Bytes())
+
+	g.printf("// Base64 encoded, gzipped, OpenAPI document\n")
+	g.printf("var swaggerSpec = []string{\n")
+	for len(encoded) > 0 {
+		n := min(80, len(encoded))
+		g.printf("%q,\n", encoded[:n])
+		encoded = encoded[n:]
+	}
+	g.printf("}\n\n")
+
+	g.printf(`var (
+	specOnce sync.Once
+	specData []byte
+	specErr  error
+)
+
+// GetSpec returns the OpenAPI document the API was generated from.
+func GetSpec() ([]byte, error) {
+	specOnce.Do(func() {
+		zr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(strings.Join(swaggerSpec, ""))))
+		if err != nil {
+			specErr = fmt.Errorf("error decompressing spec: %%w", err)
+			return
+		}
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, zr); err != nil {
+			specErr = fmt.Errorf("error decompressing spec: %%w", err)
+			return
+		}
+		specData = buf.Bytes()
+	})
+	return specData, specErr
+}
+
+`)
+	return nil
+}
+
+func methodFunc(method string) string {
+	return method[:1] + strings.ToLower(method[1:])
+}
+
+// goName converts an OpenAPI name such as "raid-history" or "contributor.id"
+// to an exported Go identifier
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
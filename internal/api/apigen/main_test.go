@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// TestGeneratedUpToDate tests that internal/api/generated.go matches the spec
+func TestGeneratedUpToDate(t *testing.T) {
+	cfg, err := loadConfig("../../../oapi-codegen.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := os.ReadFile("../../../raido-openapi-3.0.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := Generate(cfg, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../generated.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("generated.go is out of date; run go generate ./internal/api")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for in, want := range map[string]string{
+		"raid-history":   "RaidHistory",
+		"contributor.id": "ContributorId",
+		"findRaidByName": "FindRaidByName",
+		"schemaUri":      "SchemaUri",
+	} {
+		if got := goName(in); got != want {
+			t.Errorf("goName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
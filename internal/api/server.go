@@ -0,0 +1,94 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/leifj/go-raid/internal/handlers"
+)
+
+// Server implements the generated ServerInterface on top of the RAiD and
+// service point handlers. Parameters have already been bound and validated
+// by the generated wrapper; the handlers read them from the request.
+type Server struct {
+	raids         *handlers.RAiDHandler
+	servicePoints *handlers.ServicePointHandler
+}
+
+var _ ServerInterface = (*Server)(nil)
+
+// NewServer creates a server backed by the given handlers
+func NewServer(raids *handlers.RAiDHandler, servicePoints *handlers.ServicePointHandler) *Server {
+	return &Server{raids: raids, servicePoints: servicePoints}
+}
+
+// FindAllRaids handles GET /raid/
+func (s *Server) FindAllRaids(w http.ResponseWriter, r *http.Request, params FindAllRaidsParams) {
+	s.raids.FindAllRAiDs(w, r)
+}
+
+// MintRaid handles POST /raid/
+func (s *Server) MintRaid(w http.ResponseWriter, r *http.Request) {
+	s.raids.MintRAiD(w, r)
+}
+
+// FindAllPublicRaids handles GET /raid/all-public
+func (s *Server) FindAllPublicRaids(w http.ResponseWriter, r *http.Request) {
+	s.raids.FindAllPublicRAiDs(w, r)
+}
+
+// FindRaidByName handles GET /raid/{prefix}/{suffix}
+func (s *Server) FindRaidByName(w http.ResponseWriter, r *http.Request, prefix string, suffix string) {
+	s.raids.FindRAiDByName(w, r)
+}
+
+// UpdateRaid handles PUT /raid/{prefix}/{suffix}
+func (s *Server) UpdateRaid(w http.ResponseWriter, r *http.Request, prefix string, suffix string) {
+	s.raids.UpdateRAiD(w, r)
+}
+
+// PatchRaid handles PATCH /raid/{prefix}/{suffix}
+func (s *Server) PatchRaid(w http.ResponseWriter, r *http.Request, prefix string, suffix string) {
+	s.raids.PatchRAiD(w, r)
+}
+
+// RaidHistory handles GET /raid/{prefix}/{suffix}/history
+func (s *Server) RaidHistory(w http.ResponseWriter, r *http.Request, prefix string, suffix string) {
+	s.raids.RAiDHistory(w, r)
+}
+
+// FindRaidByNameAndVersion handles GET /raid/{prefix}/{suffix}/{version}
+func (s *Server) FindRaidByNameAndVersion(w http.ResponseWriter, r *http.Request, prefix string, suffix string, version int) {
+	s.raids.FindRAiDByNameAndVersion(w, r)
+}
+
+// FindAllServicePoints handles GET /service-point/
+func (s *Server) FindAllServicePoints(w http.ResponseWriter, r *http.Request) {
+	s.servicePoints.FindAllServicePoints(w, r)
+}
+
+// CreateServicePoint handles POST /service-point/
+func (s *Server) CreateServicePoint(w http.ResponseWriter, r *http.Request) {
+	s.servicePoints.CreateServicePoint(w, r)
+}
+
+// FindServicePointById handles GET /service-point/{id}
+func (s *Server) FindServicePointById(w http.ResponseWriter, r *http.Request, id int64) {
+	s.servicePoints.FindServicePointByID(w, r)
+}
+
+// UpdateServicePoint handles PUT /service-point/{id}
+func (s *Server) UpdateServicePoint(w http.ResponseWriter, r *http.Request, id int64) {
+	s.servicePoints.UpdateServicePoint(w, r)
+}
+
+// ServeSpec handles GET /openapi.yaml - the OpenAPI document the API
+// implements
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	spec, err := GetSpec()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(spec)
+}
@@ -0,0 +1,477 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/i18n"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/validation"
+	"gopkg.in/yaml.v3"
+)
+
+// schema is the subset of JSON schema the RAiD spec uses
+type schema struct {
+	Ref        string             `yaml:"$ref"`
+	Type       string             `yaml:"type"`
+	Format     string             `yaml:"format"`
+	Required   []string           `yaml:"required"`
+	Properties map[string]*schema `yaml:"properties"`
+	Items      *schema            `yaml:"items"`
+	AllOf      []*schema          `yaml:"allOf"`
+}
+
+type parameter struct {
+	Name     string  `yaml:"name"`
+	In       string  `yaml:"in"`
+	Required bool    `yaml:"required"`
+	Schema   *schema `yaml:"schema"`
+}
+
+type content map[string]struct {
+	Schema *schema `yaml:"schema"`
+}
+
+type operation struct {
+	Parameters  []*parameter `yaml:"parameters"`
+	RequestBody *struct {
+		Required bool    `yaml:"required"`
+		Content  content `yaml:"content"`
+	} `yaml:"requestBody"`
+	Responses map[string]struct {
+		Content content `yaml:"content"`
+	} `yaml:"responses"`
+}
+
+type pathItem struct {
+	Parameters []*parameter `yaml:"parameters"`
+	Get        *operation   `yaml:"get"`
+	Put        *operation   `yaml:"put"`
+	Post       *operation   `yaml:"post"`
+	Delete     *operation   `yaml:"delete"`
+	Patch      *operation   `yaml:"patch"`
+}
+
+// Validator checks requests to the operations of the OpenAPI document, and
+// optionally their responses, against the document's parameters and
+// schemas. Properties the schemas do not define are allowed, as the API
+// keeps extensions. Required properties the schemas do not define are not
+// enforced: the reference document lists some under names its schemas do
+// not use (titles for title, and so on).
+type Validator struct {
+	// Requests rejects requests not matching the document
+	Requests bool
+	// Responses logs responses not matching the document. They are
+	// buffered to be checked, so this is meant for development.
+	Responses bool
+
+	operations map[string]*operation
+	schemas    map[string]*schema
+}
+
+// NewValidator returns a validator for the OpenAPI document spec
+func NewValidator(spec []byte) (*Validator, error) {
+	var doc struct {
+		Paths      map[string]*pathItem `yaml:"paths"`
+		Components struct {
+			Schemas map[string]*schema `yaml:"schemas"`
+		} `yaml:"components"`
+	}
+	if err := yaml.Unmarshal(spec, &doc); err != nil {
+		return nil, fmt.Errorf("parsing OpenAPI document: %w", err)
+	}
+
+	v := &Validator{operations: make(map[string]*operation), schemas: doc.Components.Schemas}
+	for path, item := range doc.Paths {
+		for method, op := range map[string]*operation{
+			http.MethodGet: item.Get, http.MethodPut: item.Put, http.MethodPost: item.Post,
+			http.MethodDelete: item.Delete, http.MethodPatch: item.Patch,
+		} {
+			if op == nil {
+				continue
+			}
+			// Path-level parameters apply to every operation
+			op.Parameters = append(append([]*parameter(nil), item.Parameters...), op.Parameters...)
+			v.operations[operationKey(method, path)] = op
+		}
+	}
+	return v, nil
+}
+
+// operationKey identifies the operation of a method and path. chi drops the
+// trailing slash of route patterns, so it is dropped here too.
+func operationKey(method, path string) string {
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return method + " " + path
+}
+
+// routePattern returns the route pattern of r within the router serving it,
+// so that the API matches its operations when mounted under a prefix
+func routePattern(r *http.Request) string {
+	patterns := chi.RouteContext(r.Context()).RoutePatterns
+	if len(patterns) == 0 {
+		return ""
+	}
+	return patterns[len(patterns)-1]
+}
+
+// Middleware checks requests and responses as configured. Requests whose
+// parameters or body do not match the operation they are routed to are
+// rejected with 400 and an ErrorResponse listing each failure. It must wrap
+// handlers routed by chi, whose route pattern names the operation; requests
+// to paths the document does not define pass through.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := v.operations[operationKey(r.Method, routePattern(r))]
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var failures []models.ValidationFailure
+		if v.Requests {
+			failures = v.checkParameters(op, r)
+		}
+		if v.Requests && op.RequestBody != nil {
+			data, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				// The handler reports bodies that could not be read, such
+				// as oversized ones
+				r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), errReader{err}))
+				next.ServeHTTP(w, r)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+			failures = append(failures, v.checkBody(op, data)...)
+		}
+		if len(failures) > 0 {
+			writeFailures(w, r, failures)
+			return
+		}
+
+		if !v.Responses {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if failures := v.checkResponse(op, rec); len(failures) > 0 {
+			msgs := make([]string, len(failures))
+			for i, f := range failures {
+				msgs[i] = f.FieldID + ": " + f.Message
+			}
+			log.Printf("%s %s: %d response does not match the OpenAPI document: %s", r.Method, r.URL.Path, rec.status, strings.Join(msgs, "; "))
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// ParamError reports a parameter the generated routes could not bind as
+// the validation failures do, for ChiServerOptions.ErrorHandlerFunc
+func (v *Validator) ParamError(w http.ResponseWriter, r *http.Request, err error) {
+	failure := models.ValidationFailure{ErrorType: validation.InvalidValue, Message: err.Error()}
+	switch e := err.(type) {
+	case *InvalidParamFormatError:
+		failure.FieldID = e.ParamName
+		failure.Message = e.Err.Error()
+	case *RequiredParamError:
+		failure.FieldID, failure.ErrorType, failure.Message = e.ParamName, validation.NotSet, "is required"
+	}
+	writeFailures(w, r, []models.ValidationFailure{failure})
+}
+
+func writeFailures(w http.ResponseWriter, r *http.Request, failures []models.ValidationFailure) {
+	p := i18n.For(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Type:     "https://raid.org/errors#ValidationException",
+		Title:    p.Sprintf("There were validation failures."),
+		Status:   http.StatusBadRequest,
+		Detail:   p.Sprintf("Request does not match the OpenAPI document."),
+		Instance: r.URL.Path,
+		Failures: p.Failures(failures),
+	})
+}
+
+func (v *Validator) checkParameters(op *operation, r *http.Request) []models.ValidationFailure {
+	var failures []models.ValidationFailure
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case "path":
+			values = []string{chi.URLParam(r, p.Name)}
+		case "query":
+			values = query[p.Name]
+		case "header":
+			values = r.Header.Values(p.Name)
+		}
+		if len(values) == 0 || values[0] == "" && p.In == "path" {
+			if p.Required {
+				failures = append(failures, models.ValidationFailure{FieldID: p.Name, ErrorType: validation.NotSet, Message: "is required"})
+			}
+			continue
+		}
+
+		s := v.resolve(p.Schema)
+		if s == nil {
+			continue
+		}
+		if s.Type == "array" {
+			for i, value := range values {
+				v.checkParameter(s.Items, value, fmt.Sprintf("%s[%d]", p.Name, i), &failures)
+			}
+			continue
+		}
+		v.checkParameter(s, values[0], p.Name, &failures)
+	}
+	return failures
+}
+
+// checkParameter checks a parameter value given as a string
+func (v *Validator) checkParameter(s *schema, value, field string, failures *[]models.ValidationFailure) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+	var parsed any = value
+	switch s.Type {
+	case "integer", "number":
+		parsed = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			*failures = append(*failures, typeFailure(field, s.Type))
+			return
+		}
+		parsed = b
+	}
+	v.check(s, parsed, field, failures)
+}
+
+func (v *Validator) checkBody(op *operation, data []byte) []models.ValidationFailure {
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.RequestBody.Required {
+			return []models.ValidationFailure{{FieldID: "body", ErrorType: validation.NotSet, Message: "is required"}}
+		}
+		return nil
+	}
+	s := jsonSchema(op.RequestBody.Content)
+	if s == nil {
+		return nil
+	}
+	var failures []models.ValidationFailure
+	body, err := decodeJSON(data)
+	if err != nil {
+		return []models.ValidationFailure{{FieldID: "body", ErrorType: validation.InvalidValue, Message: "is not valid JSON"}}
+	}
+	v.check(s, body, "", &failures)
+	return failures
+}
+
+func (v *Validator) checkResponse(op *operation, rec *responseRecorder) []models.ValidationFailure {
+	resp, ok := op.Responses[strconv.Itoa(rec.status)]
+	if !ok {
+		resp, ok = op.Responses["default"]
+	}
+	if !ok {
+		// Statuses the document does not list, such as 404, have no schema
+		return nil
+	}
+	// Responses in another profile, such as enveloped lists, are not the
+	// document's
+	s := jsonSchema(resp.Content)
+	mediaType, params, _ := mime.ParseMediaType(rec.Header().Get("Content-Type"))
+	if s == nil || mediaType != "application/json" || params["profile"] != "" {
+		return nil
+	}
+	body, err := decodeJSON(rec.body.Bytes())
+	if err != nil {
+		return []models.ValidationFailure{{FieldID: "body", ErrorType: validation.InvalidValue, Message: "is not valid JSON"}}
+	}
+	var failures []models.ValidationFailure
+	v.check(s, body, "", &failures)
+	return failures
+}
+
+// jsonSchema returns the schema of the JSON content, if any
+func jsonSchema(c content) *schema {
+	if media, ok := c["application/json"]; ok {
+		return media.Schema
+	}
+	return nil
+}
+
+// decodeJSON decodes data keeping numbers as json.Number, so that integers
+// can be told from other numbers
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("trailing data after JSON value")
+	}
+	return v, nil
+}
+
+// resolve follows a reference to a component schema
+func (v *Validator) resolve(s *schema) *schema {
+	for s != nil && s.Ref != "" {
+		s = v.schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+	}
+	return s
+}
+
+// check appends the ways value breaks s to failures. Nulls are taken as
+// absent values.
+func (v *Validator) check(s *schema, value any, field string, failures *[]models.ValidationFailure) {
+	s = v.resolve(s)
+	if s == nil || value == nil {
+		return
+	}
+	for _, sub := range s.AllOf {
+		v.check(sub, value, field, failures)
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			*failures = append(*failures, typeFailure(field, s.Type))
+			return
+		}
+		for _, name := range s.Required {
+			if _, defined := s.Properties[name]; defined && obj[name] == nil {
+				*failures = append(*failures, models.ValidationFailure{FieldID: join(field, name), ErrorType: validation.NotSet, Message: "is required"})
+			}
+		}
+		// Sorted so that failures are listed in a stable order
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := obj[name]; ok {
+				v.check(s.Properties[name], value, join(field, name), failures)
+			}
+		}
+	case "array":
+		arr, ok := value.([]any)
+		if !ok {
+			*failures = append(*failures, typeFailure(field, s.Type))
+			return
+		}
+		for i, item := range arr {
+			v.check(s.Items, item, fmt.Sprintf("%s[%d]", field, i), failures)
+		}
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			*failures = append(*failures, typeFailure(field, s.Type))
+			return
+		}
+		if msg := checkFormat(s.Format, str); msg != "" {
+			*failures = append(*failures, models.ValidationFailure{FieldID: fieldName(field), ErrorType: validation.InvalidValue, Message: msg})
+		}
+	case "integer":
+		n, ok := value.(json.Number)
+		if _, err := n.Int64(); !ok || err != nil {
+			*failures = append(*failures, typeFailure(field, s.Type))
+		}
+	case "number":
+		n, ok := value.(json.Number)
+		if _, err := n.Float64(); !ok || err != nil {
+			*failures = append(*failures, typeFailure(field, s.Type))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			*failures = append(*failures, typeFailure(field, s.Type))
+		}
+	}
+}
+
+// checkFormat returns why str breaks the string format, or "" if it does
+// not. Formats other than dates are not checked.
+func checkFormat(format, str string) string {
+	switch format {
+	case "date":
+		if _, err := time.Parse(time.DateOnly, str); err != nil {
+			return "must be a date (YYYY-MM-DD)"
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			return "must be an RFC 3339 date and time"
+		}
+	}
+	return ""
+}
+
+func typeFailure(field, typ string) models.ValidationFailure {
+	article := "a"
+	if typ == "array" || typ == "object" || typ == "integer" {
+		article = "an"
+	}
+	return models.ValidationFailure{FieldID: fieldName(field), ErrorType: validation.InvalidValue, Message: "must be " + article + " " + typ}
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func fieldName(field string) string {
+	if field == "" {
+		return "body"
+	}
+	return field
+}
+
+// errReader returns err once the body read before it is consumed
+type errReader struct{ err error }
+
+func (r errReader) Read([]byte) (int, error) { return 0, r.err }
+
+// responseRecorder buffers a response so that it can be checked before it
+// is sent
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *responseRecorder) Write(p []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(p)
+}
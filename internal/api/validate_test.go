@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/handlers"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func newValidatingHandler(t *testing.T, v *Validator) http.Handler {
+	t.Helper()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return HandlerWithOptions(NewServer(handlers.NewRAiDHandler(repo, handlers.DocumentLimits{}), handlers.NewServicePointHandler(repo)), ChiServerOptions{
+		Middlewares:      []MiddlewareFunc{v.Middleware},
+		ErrorHandlerFunc: v.ParamError,
+	})
+}
+
+func newValidator(t *testing.T) *Validator {
+	t.Helper()
+	spec, err := GetSpec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidator(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// failureFields returns the fields of the failures in a 400 response
+func failureFields(t *testing.T, w interface {
+	Result() *http.Response
+}) []string {
+	t.Helper()
+	resp := w.Result()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.StatusCode)
+	}
+	var body models.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, f := range body.Failures {
+		fields = append(fields, f.FieldID)
+	}
+	return fields
+}
+
+func TestValidator_Requests(t *testing.T) {
+	v := newValidator(t)
+	v.Requests = true
+	h := newValidatingHandler(t, v)
+
+	in := fullRAiD()
+	in.Identifier.Version = 0
+	raid, _ := json.Marshal(in)
+	if w := serve(h, http.MethodPost, "/raid/", string(raid)); w.Code != http.StatusCreated {
+		t.Fatalf("expected a RAiD matching the spec to be minted, got %d: %s", w.Code, w.Body)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		want   string
+	}{
+		{"missing body", http.MethodPost, "/raid/", "", "body"},
+		{"malformed body", http.MethodPost, "/raid/", "{", "body"},
+		{"wrong type", http.MethodPost, "/raid/", `{"title":{"text":"x"}}`, "title"},
+		{"missing required property", http.MethodPost, "/raid/", `{"title":[{"text":"x","startDate":"2024-01-01"}]}`, "title[0].type"},
+		{"bad date", http.MethodPut, "/raid/10.99999/abc", `{"access":{"type":{"id":"x","schemaUri":"y"},"embargoExpiry":"soon"}}`, "access.embargoExpiry"},
+		{"bad path parameter", http.MethodGet, "/service-point/one", "", "id"},
+		{"bad service point", http.MethodPost, "/service-point/", `{"prefix":10}`, "prefix"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := failureFields(t, serve(h, tt.method, tt.target, tt.body))
+			if !strings.Contains(strings.Join(fields, " "), tt.want) {
+				t.Errorf("expected a failure of %s, got %v", tt.want, fields)
+			}
+		})
+	}
+}
+
+func TestValidator_Mounted(t *testing.T) {
+	v := newValidator(t)
+	v.Requests = true
+	r := chi.NewRouter()
+	r.Mount("/v2", newValidatingHandler(t, v))
+
+	fields := failureFields(t, serve(r, http.MethodPost, "/v2/raid/", `{"title":{"text":"x"}}`))
+	if !slices.Contains(fields, "title") {
+		t.Errorf("expected requests under a prefix to be checked, got %v", fields)
+	}
+}
+
+func TestValidator_Responses(t *testing.T) {
+	v := newValidator(t)
+	v.Responses = true
+	h := newValidatingHandler(t, v)
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	in := fullRAiD()
+	in.Identifier.Version = 0
+	raid, _ := json.Marshal(in)
+	// Requests are not checked
+	if w := serve(h, http.MethodPost, "/raid/", `{"title":{"text":"x"}}`); w.Code == http.StatusBadRequest && strings.Contains(w.Body.String(), "OpenAPI") {
+		t.Error("expected requests to pass unchecked")
+	}
+	if w := serve(h, http.MethodPost, "/raid/", string(raid)); w.Code != http.StatusCreated {
+		t.Fatalf("mint: expected 201, got %d: %s", w.Code, w.Body)
+	}
+	if w := serve(h, http.MethodGet, "/raid/10.99999/abc", ""); w.Code != http.StatusOK {
+		t.Fatalf("read: expected 200, got %d", w.Code)
+	}
+	if logs.Len() > 0 {
+		t.Errorf("expected responses matching the spec, got %s", logs.String())
+	}
+}
+
+func TestValidator_ResponseMismatch(t *testing.T) {
+	v := newValidator(t)
+	v.Responses = true
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	h := HandlerWithOptions(stubServer{}, ChiServerOptions{Middlewares: []MiddlewareFunc{v.Middleware}})
+	w := serve(h, http.MethodGet, "/service-point/1", "")
+	if w.Code != http.StatusOK || w.Body.String() != `{"id":"one"}` {
+		t.Errorf("expected the response to be sent unchanged, got %d: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(logs.String(), "id: must be an integer") {
+		t.Errorf("expected the mismatch to be logged, got %q", logs.String())
+	}
+}
+
+// stubServer answers service point reads with an ID of the wrong type
+type stubServer struct{ ServerInterface }
+
+func (stubServer) FindServicePointById(w http.ResponseWriter, r *http.Request, id int64) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"id":"one"}`))
+}
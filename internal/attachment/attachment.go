@@ -0,0 +1,197 @@
+// Package attachment stores documents attached to RAiDs, such as data
+// management plans. Content is addressed by its SHA-256 hash, held in the
+// storage backend or in S3 and checked against its hash when read; the
+// attachments of a RAiD are kept by the storage backend. Documents are
+// held to a policy of media types and sizes and may be scanned before they
+// are accepted. Attachments are referenced from the RAiD as related
+// objects by their URL on this server.
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/vocabulary"
+)
+
+// metrics counts documents added and refused, published under
+// "attachments" in /debug/vars
+var metrics = expvar.NewMap("attachments")
+
+// ErrTooLarge is returned for content over the size limit
+var ErrTooLarge = errors.New("attachment is too large")
+
+// ErrMediaType is returned for documents of a media type not accepted
+var ErrMediaType = errors.New("media type of the attachment is not accepted")
+
+// ErrChecksum is returned for content that does not match the checksum
+// given with it
+var ErrChecksum = errors.New("attachment content does not match its checksum")
+
+// ErrCorrupt is returned for stored content that no longer matches its hash
+var ErrCorrupt = errors.New("attachment content does not match its hash")
+
+// hashForm is the form of content hashes: hex-encoded SHA-256
+var hashForm = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// Hash returns the hash content is addressed by
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidHash reports whether hash is of the form content is addressed by
+func ValidHash(hash string) bool {
+	return hashForm.MatchString(hash)
+}
+
+// Store holds attachments and their content
+type Store struct {
+	blobs       storage.BlobStore
+	attachments storage.AttachmentStore
+	maxBytes    int64
+	mediaTypes  []string
+	scanner     Scanner
+}
+
+// New creates a store keeping content in blobs and attachments in
+// attachments. Content over maxBytes is refused.
+func New(blobs storage.BlobStore, attachments storage.AttachmentStore, maxBytes int64) *Store {
+	return &Store{blobs: blobs, attachments: attachments, maxBytes: maxBytes}
+}
+
+// WithMediaTypes accepts only documents of mediaTypes, unless a service
+// point's policy lists its own
+func (s *Store) WithMediaTypes(mediaTypes []string) *Store {
+	s.mediaTypes = mediaTypes
+	return s
+}
+
+// WithScanner has scanner check content before it is accepted
+func (s *Store) WithScanner(scanner Scanner) *Store {
+	s.scanner = scanner
+	return s
+}
+
+// MaxBytes returns the size limit of content
+func (s *Store) MaxBytes() int64 {
+	return s.maxBytes
+}
+
+// Policy returns the policy documents attached by servicePoint are held
+// to: its own media types if it lists any, and the smaller of its size cap
+// and the store's
+func (s *Store) Policy(servicePoint *models.ServicePoint) models.AttachmentPolicy {
+	policy := models.AttachmentPolicy{MediaTypes: s.mediaTypes, MaxBytes: s.maxBytes}
+	if servicePoint == nil || servicePoint.Attachments == nil {
+		return policy
+	}
+	if own := servicePoint.Attachments; len(own.MediaTypes) > 0 {
+		policy.MediaTypes = own.MediaTypes
+	}
+	if own := servicePoint.Attachments.MaxBytes; own > 0 && own < policy.MaxBytes {
+		policy.MaxBytes = own
+	}
+	return policy
+}
+
+// Add stores the content read from r and attaches it to the RAiD named by
+// attachment, setting its hash, size and creation time. The document must
+// meet policy and, when attachment has a hash, match it, and pass the
+// scanner. Attaching content the RAiD already has replaces that
+// attachment.
+func (s *Store) Add(ctx context.Context, attachment *models.Attachment, r io.Reader, policy models.AttachmentPolicy) (*models.Attachment, error) {
+	if !Accepts(policy, attachment.MediaType) {
+		metrics.Add("refused", 1)
+		return nil, fmt.Errorf("%w: %s is not one of %s", ErrMediaType, attachment.MediaType, strings.Join(policy.MediaTypes, ", "))
+	}
+	limit := s.maxBytes
+	if policy.MaxBytes > 0 && policy.MaxBytes < limit {
+		limit = policy.MaxBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		metrics.Add("refused", 1)
+		return nil, fmt.Errorf("%w: the limit is %d bytes", ErrTooLarge, limit)
+	}
+	added := *attachment
+	added.Hash = Hash(data)
+	added.Size = int64(len(data))
+	added.Created = time.Now().UTC()
+	if attachment.Hash != "" && !strings.EqualFold(attachment.Hash, added.Hash) {
+		metrics.Add("refused", 1)
+		return nil, fmt.Errorf("%w: SHA-256 is %s", ErrChecksum, added.Hash)
+	}
+	if s.scanner != nil {
+		if err := s.scanner.Scan(ctx, &added, data); err != nil {
+			if errors.Is(err, ErrInfected) {
+				metrics.Add("infected", 1)
+			}
+			return nil, err
+		}
+	}
+	if err := s.blobs.PutBlob(ctx, added.Hash, data); err != nil {
+		return nil, fmt.Errorf("store attachment content: %w", err)
+	}
+	if err := s.attachments.SaveAttachment(ctx, &added); err != nil {
+		return nil, err
+	}
+	metrics.Add("added", 1)
+	return &added, nil
+}
+
+// Get returns the attachment of the content hash to a RAiD
+func (s *Store) Get(ctx context.Context, prefix, suffix, hash string) (*models.Attachment, error) {
+	if !ValidHash(hash) {
+		return nil, storage.ErrNotFound
+	}
+	return s.attachments.GetAttachment(ctx, prefix, suffix, hash)
+}
+
+// List returns the attachments of a RAiD, ordered by hash
+func (s *Store) List(ctx context.Context, prefix, suffix string) ([]*models.Attachment, error) {
+	return s.attachments.ListAttachments(ctx, prefix, suffix)
+}
+
+// Open returns the content of attachment, checked against its hash
+func (s *Store) Open(ctx context.Context, attachment *models.Attachment) (io.ReadSeeker, error) {
+	data, err := s.blobs.GetBlob(ctx, attachment.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if Hash(data) != attachment.Hash {
+		return nil, ErrCorrupt
+	}
+	return bytes.NewReader(data), nil
+}
+
+// RelatedObject returns the related object referencing the attachment at
+// url, a document of the project's internal process, of objectType, a
+// related object type term, if not empty
+func RelatedObject(url, objectType string) models.RelatedObject {
+	obj := models.RelatedObject{
+		ID: url,
+		Category: []models.IDSchema{{
+			ID:        vocabulary.Base + "related-object.category.id/192",
+			SchemaURI: vocabulary.RelatedObjectCategory.SchemaURI,
+		}},
+	}
+	if objectType != "" {
+		obj.Type = &models.IDSchema{ID: objectType, SchemaURI: vocabulary.RelatedObjectType.SchemaURI}
+	}
+	return obj
+}
@@ -0,0 +1,222 @@
+package attachment
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+// blobs is a BlobStore in memory
+type blobs struct {
+	mu     sync.Mutex
+	byHash map[string][]byte
+}
+
+func (b *blobs) PutBlob(ctx context.Context, hash string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.byHash[hash] = append([]byte(nil), data...)
+	return nil
+}
+
+func (b *blobs) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, ok := b.byHash[hash]
+	if !ok {
+		return nil, storage.ErrNotFound
+	}
+	return data, nil
+}
+
+func TestStore(t *testing.T) {
+	fs, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	content := &blobs{byHash: map[string][]byte{}}
+	store := New(content, fs, 16)
+	ctx := context.Background()
+
+	plan := &models.Attachment{Prefix: "10.1", Suffix: "a", Name: "dmp.txt", MediaType: "text/plain", ServicePoint: 1}
+	added, err := store.Add(ctx, plan, strings.NewReader("data plan"), store.Policy(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added.Hash != Hash([]byte("data plan")) || added.Size != 9 || added.Created.IsZero() {
+		t.Errorf("expected the attachment addressed by its hash, got %+v", added)
+	}
+	if _, err := store.Add(ctx, plan, strings.NewReader("a much longer data plan"), store.Policy(nil)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge over the limit, got %v", err)
+	}
+	// The same document attached elsewhere is stored once
+	if _, err := store.Add(ctx, &models.Attachment{Prefix: "10.1", Suffix: "b", Name: "plan.txt"}, strings.NewReader("data plan"), store.Policy(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(content.byHash) != 1 {
+		t.Errorf("expected one blob, got %d", len(content.byHash))
+	}
+
+	list, err := store.List(ctx, "10.1", "a")
+	if err != nil || len(list) != 1 || list[0].Name != "dmp.txt" {
+		t.Fatalf("expected the attachment of the RAiD, got %+v (%v)", list, err)
+	}
+	if _, err := store.Get(ctx, "10.1", "a", "../../etc/passwd"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected a malformed hash not to be found, got %v", err)
+	}
+	got, err := store.Get(ctx, "10.1", "a", added.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := store.Open(ctx, got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(r); string(data) != "data plan" {
+		t.Errorf("expected the content, got %q", data)
+	}
+
+	content.byHash[added.Hash] = []byte("tampered")
+	if _, err := store.Open(ctx, got); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("expected ErrCorrupt for changed content, got %v", err)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	store := New(&blobs{byHash: map[string][]byte{}}, nil, 1024).WithMediaTypes([]string{"application/pdf", "text/*"})
+	policy := store.Policy(nil)
+	for mediaType, accepted := range map[string]bool{
+		"application/pdf":           true,
+		"text/plain; charset=utf-8": true,
+		"TEXT/CSV":                  true,
+		"application/zip":           false,
+		"textual/plain":             false,
+		"":                          false,
+	} {
+		if Accepts(policy, mediaType) != accepted {
+			t.Errorf("Accepts(%q): expected %t", mediaType, accepted)
+		}
+	}
+
+	own := store.Policy(&models.ServicePoint{Attachments: &models.AttachmentPolicy{MediaTypes: []string{"image/*"}, MaxBytes: 4096}})
+	if !Accepts(own, "image/png") || Accepts(own, "application/pdf") || own.MaxBytes != 1024 {
+		t.Errorf("expected the service point's media types within the store's size limit, got %+v", own)
+	}
+	if smaller := store.Policy(&models.ServicePoint{Attachments: &models.AttachmentPolicy{MaxBytes: 10}}); smaller.MaxBytes != 10 || !Accepts(smaller, "text/plain") {
+		t.Errorf("expected the service point's smaller cap and the store's media types, got %+v", smaller)
+	}
+
+	ctx := context.Background()
+	if _, err := store.Add(ctx, &models.Attachment{MediaType: "application/zip"}, strings.NewReader("PK"), policy); !errors.Is(err, ErrMediaType) {
+		t.Errorf("expected ErrMediaType, got %v", err)
+	}
+	if _, err := store.Add(ctx, &models.Attachment{MediaType: "text/plain", Hash: Hash([]byte("other"))}, strings.NewReader("data plan"), policy); !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected ErrChecksum for content not matching its checksum, got %v", err)
+	}
+
+	for _, p := range []*models.AttachmentPolicy{{MaxBytes: -1}, {MediaTypes: []string{"pdf"}}, {MediaTypes: []string{"*/*"}}} {
+		if ValidatePolicy(p) == nil {
+			t.Errorf("expected %+v to be invalid", p)
+		}
+	}
+}
+
+func TestHTTPScanner(t *testing.T) {
+	scanner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		switch {
+		case !strings.HasPrefix(r.Header.Get("Content-Digest"), "sha-256=:"):
+			w.WriteHeader(http.StatusBadRequest)
+		case strings.Contains(string(data), "EICAR"):
+			http.Error(w, "Eicar-Test-Signature FOUND", http.StatusUnprocessableEntity)
+		case strings.Contains(string(data), "crash"):
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer scanner.Close()
+
+	fs, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	content := &blobs{byHash: map[string][]byte{}}
+	store := New(content, fs, 1024).WithScanner(NewHTTPScanner(scanner.URL, time.Second))
+	add := func(data string) error {
+		_, err := store.Add(context.Background(), &models.Attachment{Prefix: "10.1", Suffix: "a", MediaType: "text/plain"}, strings.NewReader(data), store.Policy(nil))
+		return err
+	}
+
+	if err := add("data plan"); err != nil {
+		t.Errorf("expected clean content to be accepted, got %v", err)
+	}
+	if err := add("EICAR test file"); !errors.Is(err, ErrInfected) || !strings.Contains(err.Error(), "Eicar-Test-Signature") {
+		t.Errorf("expected ErrInfected with the scanner's reason, got %v", err)
+	}
+	if err := add("crash the scanner"); !errors.Is(err, ErrScanFailed) {
+		t.Errorf("expected ErrScanFailed when the scanner fails, got %v", err)
+	}
+	if len(content.byHash) != 1 {
+		t.Errorf("expected refused content not to be stored, got %d blobs", len(content.byHash))
+	}
+}
+
+func TestS3Blobs(t *testing.T) {
+	stored := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			if r.Header.Get("X-Amz-Content-Sha256") != Hash(data) {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			stored[r.URL.Path] = data
+		case http.MethodGet:
+			data, ok := stored[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	s3, err := NewS3Blobs(S3Config{Bucket: "bucket", Prefix: "raid/", Region: "us-east-1", Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	hash := Hash([]byte("data plan"))
+	if err := s3.PutBlob(ctx, hash, []byte("data plan")); err != nil {
+		t.Fatal(err)
+	}
+	if string(stored["/bucket/raid/"+hash]) != "data plan" {
+		t.Errorf("expected the content under its hash, got %v", stored)
+	}
+	if data, err := s3.GetBlob(ctx, hash); err != nil || string(data) != "data plan" {
+		t.Errorf("expected the content back, got %q (%v)", data, err)
+	}
+	if _, err := s3.GetBlob(ctx, Hash(nil)); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for missing content, got %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package attachment
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Accepts reports whether policy accepts documents of mediaType. Listed
+// types ending in /* accept all subtypes; parameters are ignored.
+func Accepts(policy models.AttachmentPolicy, mediaType string) bool {
+	if len(policy.MediaTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(mediaType)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range policy.MediaTypes {
+		accepted = strings.ToLower(accepted)
+		if accepted == mediaType || (strings.HasSuffix(accepted, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(accepted, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidatePolicy checks the attachment policy of a service point
+func ValidatePolicy(policy *models.AttachmentPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxBytes < 0 {
+		return fmt.Errorf("attachments.maxBytes must not be negative")
+	}
+	return ValidateMediaTypes("attachments.mediaTypes", policy.MediaTypes)
+}
+
+// ValidateMediaTypes checks that mediaTypes, named field in errors, are
+// media types or type/* patterns
+func ValidateMediaTypes(field string, mediaTypes []string) error {
+	for _, t := range mediaTypes {
+		major, minor, ok := strings.Cut(t, "/")
+		if !ok || major == "" || major == "*" || minor == "" || strings.ContainsAny(t, " ;,") {
+			return fmt.Errorf("%s: %q is not a media type, e.g. application/pdf or text/*", field, t)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/awsv4"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// S3Config configures an S3 (or S3-compatible) bucket holding content
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to object keys, e.g. "raid/attachments/"
+	Prefix string
+	// Region overrides AWS_REGION
+	Region string
+	// Endpoint selects an S3-compatible service (MinIO, Ceph, ...) using
+	// path-style addressing; empty means AWS S3
+	Endpoint string
+}
+
+// S3Blobs keeps content in an S3 bucket, one object per hash. Credentials
+// are read from the standard AWS_* environment variables.
+type S3Blobs struct {
+	cfg    S3Config
+	creds  awsv4.Credentials
+	client *http.Client
+}
+
+// NewS3Blobs creates a blob store in an S3 bucket
+func NewS3Blobs(cfg S3Config) (*S3Blobs, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	creds, err := awsv4.CredentialsFromEnv(cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Blobs{cfg: cfg, creds: creds, client: &http.Client{Timeout: 5 * time.Minute}}, nil
+}
+
+func (b *S3Blobs) String() string {
+	return fmt.Sprintf("s3://%s/%s", b.cfg.Bucket, b.cfg.Prefix)
+}
+
+// objectURL returns the URL of the object holding hash
+func (b *S3Blobs) objectURL(hash string) string {
+	u := &url.URL{Scheme: "https"}
+	if b.cfg.Endpoint != "" {
+		if parsed, err := url.Parse(b.cfg.Endpoint); err == nil {
+			u.Scheme, u.Host = parsed.Scheme, parsed.Host
+		}
+		u.Path = "/" + b.cfg.Bucket + "/" + b.cfg.Prefix + hash
+	} else {
+		u.Host = fmt.Sprintf("%s.s3.%s.amazonaws.com", b.cfg.Bucket, b.creds.Region)
+		u.Path = "/" + b.cfg.Prefix + hash
+	}
+	return u.String()
+}
+
+func (b *S3Blobs) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	awsv4.Sign(req, payloadHash, "s3", b.creds, time.Now())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, storage.ErrNotFound
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 %s %s returned %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// PutBlob uploads content. The payload hash signed is the content's own
+// hash, so S3 refuses content that does not match it.
+func (b *S3Blobs) PutBlob(ctx context.Context, hash string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, b.objectURL(hash), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := b.do(req, awsv4.PayloadHash(data))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// GetBlob downloads content
+func (b *S3Blobs) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.objectURL(hash), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(req, awsv4.PayloadHash(nil))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Verify S3Blobs can hold content
+var _ storage.BlobStore = (*S3Blobs)(nil)
@@ -0,0 +1,73 @@
+package attachment
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ErrInfected is returned for content a scanner refuses
+var ErrInfected = errors.New("attachment was refused by the scanner")
+
+// ErrScanFailed is returned when content could not be scanned; it is not
+// accepted unscanned
+var ErrScanFailed = errors.New("attachment could not be scanned")
+
+// Scanner checks content before it is accepted, e.g. for malware
+type Scanner interface {
+	// Scan returns an error wrapping ErrInfected for content that must
+	// be refused, and ErrScanFailed when it could not check it
+	Scan(ctx context.Context, attachment *models.Attachment, data []byte) error
+}
+
+// HTTPScanner has an external service scan content. The content is posted
+// to it with its media type and a Content-Digest; a 2xx response accepts
+// it, and a 4xx response refuses it, its body giving the reason.
+type HTTPScanner struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPScanner creates a scanner posting content to url, giving up
+// after timeout
+func NewHTTPScanner(url string, timeout time.Duration) *HTTPScanner {
+	return &HTTPScanner{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Scan posts data to the scanning service
+func (s *HTTPScanner) Scan(ctx context.Context, attachment *models.Attachment, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	req.Header.Set("Content-Type", attachment.MediaType)
+	if sum, err := hex.DecodeString(attachment.Hash); err == nil {
+		req.Header.Set("Content-Digest", "sha-256=:"+base64.StdEncoding.EncodeToString(sum)+":")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrScanFailed, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	switch {
+	case resp.StatusCode/100 == 2:
+		return nil
+	case resp.StatusCode/100 == 4:
+		if reason := strings.TrimSpace(string(body)); reason != "" {
+			return fmt.Errorf("%w: %s", ErrInfected, reason)
+		}
+		return ErrInfected
+	default:
+		return fmt.Errorf("%w: scanner responded %s", ErrScanFailed, resp.Status)
+	}
+}
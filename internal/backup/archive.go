@@ -0,0 +1,76 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// errStop ends reading an archive early
+var errStop = errors.New("stop")
+
+// Archive reads the contents of an archive file the way a backend exports
+// them, so that it can be compared with one. Every call reads the file
+// again rather than holding the archive in memory.
+type Archive struct {
+	path string
+}
+
+// OpenArchive returns the archive at path after checking its header
+func OpenArchive(path string) (*Archive, error) {
+	a := &Archive{path: path}
+	if err := a.read(func(*Entry) error { return errStop }); err != nil && err != errStop {
+		return nil, err
+	}
+	return a, nil
+}
+
+// read calls fn for each entry of the archive
+func (a *Archive) read(fn func(*Entry) error) error {
+	f, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return readArchive(f, fn)
+}
+
+// ListServicePoints returns the archived service points
+func (a *Archive) ListServicePoints(ctx context.Context) ([]*models.ServicePoint, error) {
+	var sps []*models.ServicePoint
+	err := a.read(func(entry *Entry) error {
+		if entry.Kind == KindServicePoint {
+			sps = append(sps, entry.ServicePoint)
+		}
+		return ctx.Err()
+	})
+	return sps, err
+}
+
+// ExportRAiDs calls fn for every archived RAiD
+func (a *Archive) ExportRAiDs(ctx context.Context, fn func(*storage.RAiDRecord) error) error {
+	return a.read(func(entry *Entry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.Kind != KindRAiD {
+			return nil
+		}
+		return fn(entry.RAiD)
+	})
+}
+
+// Counters returns the archived identifier counters
+func (a *Archive) Counters(ctx context.Context) (map[string]int64, error) {
+	counters := make(map[string]int64)
+	err := a.read(func(entry *Entry) error {
+		if entry.Kind == KindCounter {
+			counters[entry.Counter.Name] = entry.Counter.Value
+		}
+		return ctx.Err()
+	})
+	return counters, err
+}
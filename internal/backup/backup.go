@@ -0,0 +1,395 @@
+// Package backup writes and reads backend-independent snapshot archives.
+//
+// An archive is a gzip-compressed stream of newline-delimited JSON. The first
+// line is a Header identifying the format and version; every following line
+// is an Entry holding one RAiD (with all versions), service point or counter,
+// or one record of the audit log: a purge, a redaction or, where the backend
+// stores the access log, an access log entry.
+// RAiD entries of archives written with a Signer carry a detached JWS of
+// their raid member, exactly as it appears in the line.
+// Entries are written as they are read from storage so archives of any size
+// can be streamed.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/workpool"
+)
+
+const (
+	// FormatName identifies go-RAiD backup archives
+	FormatName = "go-raid-backup"
+	// FormatVersion is the archive version written by this package
+	FormatVersion = 1
+)
+
+// Entry kinds
+const (
+	KindRAiD         = "raid"
+	KindServicePoint = "servicePoint"
+	KindCounter      = "counter"
+	KindPurge        = "purge"
+	KindRedaction    = "redaction"
+	KindAccessLog    = "accessLog"
+)
+
+// accessLogBatch is the number of access log entries restored at a time
+const accessLogBatch = 500
+
+var (
+	// ErrUnsupported is returned when the storage backend cannot be snapshotted
+	ErrUnsupported = errors.New("storage backend does not support backup and restore")
+	// ErrNotEmpty is returned when restoring into a backend that already holds data
+	ErrNotEmpty = errors.New("storage backend is not empty")
+	// ErrInvalidArchive is returned for archives that cannot be read
+	ErrInvalidArchive = errors.New("invalid backup archive")
+)
+
+// Header is the first line of an archive
+type Header struct {
+	Format      string              `json:"format"`
+	Version     int                 `json:"version"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	StorageType storage.StorageType `json:"storageType,omitempty"`
+}
+
+// Entry is a single archived item
+type Entry struct {
+	Kind         string                  `json:"kind"`
+	RAiD         *storage.RAiDRecord     `json:"raid,omitempty"`
+	ServicePoint *models.ServicePoint    `json:"servicePoint,omitempty"`
+	Counter      *Counter                `json:"counter,omitempty"`
+	Purge        *models.Purge           `json:"purge,omitempty"`
+	Redaction    *models.Redaction       `json:"redaction,omitempty"`
+	AccessLog    *storage.AccessLogEntry `json:"accessLog,omitempty"`
+	// Signature is the detached JWS of the RAiD, if the archive is signed
+	Signature string `json:"signature,omitempty"`
+}
+
+// Signer signs the RAiDs of archives, returning a detached JWS of payload
+type Signer interface {
+	Sign(payload []byte) (string, error)
+}
+
+// Counter is an identifier counter value
+type Counter struct {
+	Name  string `json:"name"`
+	Value int64  `json:"value"`
+}
+
+// Summary counts the items written or restored
+type Summary struct {
+	RAiDs         int `json:"raids"`
+	Versions      int `json:"versions"`
+	ServicePoints int `json:"servicePoints"`
+	Counters      int `json:"counters"`
+	Purges        int `json:"purges"`
+	Redactions    int `json:"redactions"`
+	AccessLog     int `json:"accessLog"`
+	// Skipped counts the audit records restoring skipped because the
+	// backend cannot store them
+	Skipped int `json:"skipped,omitempty"`
+}
+
+// Write streams a complete snapshot of repo to w, signing its RAiDs with
+// signer unless it is nil
+func Write(ctx context.Context, repo storage.Repository, storageType storage.StorageType, signer Signer, w io.Writer) (*Summary, error) {
+	snap, ok := repo.(storage.Snapshotter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+	summary := &Summary{}
+
+	header := Header{
+		Format:      FormatName,
+		Version:     FormatVersion,
+		CreatedAt:   time.Now().UTC(),
+		StorageType: storageType,
+	}
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+
+	// Service points first so restored RAiDs can resolve their owners
+	sps, err := repo.ListServicePoints(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list service points: %w", err)
+	}
+	for _, sp := range sps {
+		if err := enc.Encode(Entry{Kind: KindServicePoint, ServicePoint: sp}); err != nil {
+			return nil, err
+		}
+		summary.ServicePoints++
+	}
+
+	err = snap.ExportRAiDs(ctx, func(record *storage.RAiDRecord) error {
+		summary.RAiDs++
+		summary.Versions += len(record.Versions)
+		entry := Entry{Kind: KindRAiD, RAiD: record}
+		if signer != nil {
+			// The record is encoded as a member of the entry just as
+			// json.Marshal encodes it on its own
+			payload, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			if entry.Signature, err = signer.Sign(payload); err != nil {
+				return fmt.Errorf("failed to sign RAiD: %w", err)
+			}
+		}
+		return enc.Encode(entry)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to export RAiDs: %w", err)
+	}
+
+	counters, err := snap.Counters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read counters: %w", err)
+	}
+	for name, value := range counters {
+		if err := enc.Encode(Entry{Kind: KindCounter, Counter: &Counter{Name: name, Value: value}}); err != nil {
+			return nil, err
+		}
+		summary.Counters++
+	}
+
+	if err := writeAudit(ctx, repo, enc, summary); err != nil {
+		return nil, err
+	}
+
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// writeAudit writes the records of purges and redactions and the stored
+// access log, as far as repo keeps them
+func writeAudit(ctx context.Context, repo storage.Repository, enc *json.Encoder, summary *Summary) error {
+	if purger, ok := repo.(storage.Purger); ok {
+		purges, err := purger.ListPurges(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list purges: %w", err)
+		}
+		for _, purge := range purges {
+			if err := enc.Encode(Entry{Kind: KindPurge, Purge: purge}); err != nil {
+				return err
+			}
+			summary.Purges++
+		}
+	}
+	if redactor, ok := repo.(storage.Redactor); ok {
+		redactions, err := redactor.ListRedactions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list redactions: %w", err)
+		}
+		for _, redaction := range redactions {
+			if err := enc.Encode(Entry{Kind: KindRedaction, Redaction: redaction}); err != nil {
+				return err
+			}
+			summary.Redactions++
+		}
+	}
+	if exporter, ok := repo.(storage.AccessLogExporter); ok {
+		err := exporter.ExportAccessLog(ctx, func(entry *storage.AccessLogEntry) error {
+			summary.AccessLog++
+			return enc.Encode(Entry{Kind: KindAccessLog, AccessLog: entry})
+		})
+		if err != nil {
+			return fmt.Errorf("failed to export access log: %w", err)
+		}
+	}
+	return nil
+}
+
+// Restore loads an archive into repo, which must be empty. Audit records
+// repo cannot store, such as access log entries restored into a backend
+// without an access log, are skipped and counted.
+func Restore(ctx context.Context, repo storage.Repository, r io.Reader) (*Summary, error) {
+	snap, ok := repo.(storage.Snapshotter)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+
+	if err := EnsureEmpty(ctx, repo); err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{}
+	counters := make(map[string]int64)
+	importer, _ := repo.(storage.AuditImporter)
+	accessLog, _ := repo.(storage.AccessLogStore)
+	var entries []storage.AccessLogEntry
+
+	// RAiDs are imported in parallel; summary is shared with the workers
+	var mu sync.Mutex
+	count := func(n *int) {
+		mu.Lock()
+		*n++
+		mu.Unlock()
+	}
+	pool := workpool.New(ctx, workpool.Options{})
+	err := readArchive(r, func(entry *Entry) error {
+		switch entry.Kind {
+		case KindServicePoint:
+			if err := snap.ImportServicePoint(ctx, entry.ServicePoint); err != nil {
+				return fmt.Errorf("failed to restore service point %d: %w", entry.ServicePoint.ID, err)
+			}
+			count(&summary.ServicePoints)
+
+		case KindRAiD:
+			record := entry.RAiD
+			err := pool.Go(record.Current().Identifier.ID, func(ctx context.Context) error {
+				if err := snap.ImportRAiD(ctx, record); err != nil {
+					return err
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				summary.RAiDs++
+				summary.Versions += len(record.Versions)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+		case KindCounter:
+			counters[entry.Counter.Name] = entry.Counter.Value
+			count(&summary.Counters)
+
+		case KindPurge:
+			if importer == nil {
+				count(&summary.Skipped)
+				return nil
+			}
+			if err := importer.ImportPurge(ctx, entry.Purge); err != nil {
+				return fmt.Errorf("failed to restore purge of %s/%s: %w", entry.Purge.Prefix, entry.Purge.Suffix, err)
+			}
+			count(&summary.Purges)
+
+		case KindRedaction:
+			if importer == nil {
+				count(&summary.Skipped)
+				return nil
+			}
+			if err := importer.ImportRedaction(ctx, entry.Redaction); err != nil {
+				return fmt.Errorf("failed to restore redaction of %s/%s: %w", entry.Redaction.Prefix, entry.Redaction.Suffix, err)
+			}
+			count(&summary.Redactions)
+
+		case KindAccessLog:
+			if accessLog == nil {
+				count(&summary.Skipped)
+				return nil
+			}
+			entries = append(entries, *entry.AccessLog)
+			count(&summary.AccessLog)
+			if len(entries) == accessLogBatch {
+				if err := accessLog.WriteAccessLog(ctx, entries); err != nil {
+					return fmt.Errorf("failed to restore access log: %w", err)
+				}
+				entries = entries[:0]
+			}
+
+		default:
+			// Entry kinds added by later format revisions are skipped
+		}
+		return nil
+	})
+	if poolErr := pool.Wait(); poolErr != nil && err == nil {
+		err = fmt.Errorf("failed to restore RAiDs: %w", poolErr)
+	}
+	if err != nil {
+		return summary, err
+	}
+
+	if err := snap.SetCounters(ctx, counters); err != nil {
+		return summary, fmt.Errorf("failed to restore counters: %w", err)
+	}
+	if len(entries) > 0 {
+		if err := accessLog.WriteAccessLog(ctx, entries); err != nil {
+			return summary, fmt.Errorf("failed to restore access log: %w", err)
+		}
+	}
+
+	return summary, nil
+}
+
+// readArchive checks the header of the archive r and calls fn for each of
+// its entries, stopping at the first error from fn
+func readArchive(r io.Reader, fn func(*Entry) error) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+
+	var header Header
+	if err := dec.Decode(&header); err != nil {
+		return fmt.Errorf("%w: missing header: %v", ErrInvalidArchive, err)
+	}
+	if header.Format != FormatName {
+		return fmt.Errorf("%w: unknown format %q", ErrInvalidArchive, header.Format)
+	}
+	if header.Version < 1 || header.Version > FormatVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, header.Version)
+	}
+
+	for {
+		var entry Entry
+		if err := dec.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		switch {
+		case entry.Kind == KindServicePoint && entry.ServicePoint == nil:
+			return fmt.Errorf("%w: empty service point entry", ErrInvalidArchive)
+		case entry.Kind == KindRAiD && (entry.RAiD == nil || entry.RAiD.Current() == nil || entry.RAiD.Current().Identifier == nil):
+			return fmt.Errorf("%w: empty RAiD entry", ErrInvalidArchive)
+		case entry.Kind == KindCounter && entry.Counter == nil:
+			return fmt.Errorf("%w: empty counter entry", ErrInvalidArchive)
+		case entry.Kind == KindPurge && entry.Purge == nil,
+			entry.Kind == KindRedaction && entry.Redaction == nil,
+			entry.Kind == KindAccessLog && entry.AccessLog == nil:
+			return fmt.Errorf("%w: empty %s entry", ErrInvalidArchive, entry.Kind)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+}
+
+// EnsureEmpty returns ErrNotEmpty if repo holds any RAiDs or service points
+func EnsureEmpty(ctx context.Context, repo storage.Repository) error {
+	raids, err := repo.ListRAiDs(ctx, &storage.RAiDFilter{Limit: 1})
+	if err != nil {
+		return err
+	}
+	sps, err := repo.ListServicePoints(ctx)
+	if err != nil {
+		return err
+	}
+	if len(raids) > 0 || len(sps) > 0 {
+		return ErrNotEmpty
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/signing"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func newFileStorage(t *testing.T) *file.FileStorage {
+	t.Helper()
+	fs, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	return fs
+}
+
+func TestWriteRestore_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src := newFileStorage(t)
+
+	sp, err := src.CreateServicePoint(ctx, &models.ServicePoint{Name: "Test SP", Prefix: "10.99999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raid, err := src.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/abc"},
+		Title:      []models.Title{{Text: "Original"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.UpdateRAiD(storage.WithActor(ctx, "alice"), "10.99999", "abc", &models.RAiD{
+		Identifier: &models.Identifier{ID: raid.Identifier.ID},
+		Title:      []models.Title{{Text: "Updated"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := src.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/gone"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.DeleteRAiD(ctx, "10.99999", "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	written, err := Write(ctx, src, storage.StorageTypeFile, nil, &archive)
+	if err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if written.RAiDs != 2 || written.Versions != 3 || written.ServicePoints != 1 {
+		t.Errorf("unexpected write summary: %+v", written)
+	}
+
+	dst := newFileStorage(t)
+	restored, err := Restore(ctx, dst, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if *restored != *written {
+		t.Errorf("restore summary %+v does not match write summary %+v", restored, written)
+	}
+
+	got, err := dst.GetRAiDVersion(ctx, "10.99999", "abc", 1)
+	if err != nil {
+		t.Fatalf("expected version 1 to be restored: %v", err)
+	}
+	if got.Title[0].Text != "Original" {
+		t.Errorf("expected original title in version 1, got %q", got.Title[0].Text)
+	}
+
+	current, err := dst.GetRAiD(ctx, "10.99999", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Identifier.Version != 2 || !current.Metadata.Created.Equal(raid.Metadata.Created) {
+		t.Errorf("expected version 2 with original creation time, got %+v", current.Identifier)
+	}
+
+	changes, err := dst.GetRAiDChanges(ctx, "10.99999", "abc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Actor != "" || changes[1].Actor != "alice" {
+		t.Errorf("expected the actor of version 2 to be restored, got %+v", changes)
+	}
+
+	if _, err := dst.GetRAiD(ctx, "10.99999", "gone"); err != storage.ErrNotFound {
+		t.Errorf("expected deleted RAiD to stay deleted, got %v", err)
+	}
+
+	if _, err := dst.GetServicePoint(ctx, sp.ID); err != nil {
+		t.Errorf("expected service point %d to be restored: %v", sp.ID, err)
+	}
+
+	// New service points continue after the restored IDs
+	next, err := dst.CreateServicePoint(ctx, &models.ServicePoint{Name: "Next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.ID <= sp.ID {
+		t.Errorf("expected new service point ID above %d, got %d", sp.ID, next.ID)
+	}
+}
+
+func TestWriteRestore_Audit(t *testing.T) {
+	ctx := context.Background()
+	src := newFileStorage(t)
+
+	for _, suffix := range []string{"purged", "private"} {
+		if _, err := src.CreateRAiD(ctx, &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix},
+			Title:      []models.Title{{Text: "Original"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := src.DeleteRAiD(ctx, "10.99999", "purged"); err != nil {
+		t.Fatal(err)
+	}
+	purge := &models.Purge{Prefix: "10.99999", Suffix: "purged", Actor: "admin", Reason: "spam", Purged: time.Now().UTC()}
+	if err := src.PurgeRAiD(ctx, purge); err != nil {
+		t.Fatal(err)
+	}
+	redaction := &models.Redaction{ID: "r1", Prefix: "10.99999", Suffix: "private", Actor: "dpo", Redacted: time.Now().UTC()}
+	redact := func(raid *models.RAiD) bool {
+		raid.Title[0].Text = "Redacted"
+		return true
+	}
+	if _, err := src.RedactRAiD(ctx, "10.99999", "private", redact, redaction); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	written, err := Write(ctx, src, storage.StorageTypeFile, nil, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.Purges != 1 || written.Redactions != 1 {
+		t.Errorf("unexpected write summary: %+v", written)
+	}
+
+	dst := newFileStorage(t)
+	restored, err := Restore(ctx, dst, bytes.NewReader(archive.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *restored != *written {
+		t.Errorf("restore summary %+v does not match write summary %+v", restored, written)
+	}
+	purges, err := dst.ListPurges(ctx)
+	if err != nil || len(purges) != 1 || purges[0].Actor != "admin" || purges[0].Reason != "spam" || !purges[0].Purged.Equal(purge.Purged) {
+		t.Errorf("expected the purge to be restored, got %+v, %v", purges, err)
+	}
+	redactions, err := dst.ListRedactions(ctx)
+	if err != nil || len(redactions) != 1 || redactions[0].ID != "r1" || redactions[0].Versions != 1 {
+		t.Errorf("expected the redaction to be restored, got %+v, %v", redactions, err)
+	}
+}
+
+func TestRestore_RequiresEmptyBackend(t *testing.T) {
+	ctx := context.Background()
+	src := newFileStorage(t)
+
+	var archive bytes.Buffer
+	if _, err := Write(ctx, src, storage.StorageTypeFile, nil, &archive); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newFileStorage(t)
+	dst.CreateServicePoint(ctx, &models.ServicePoint{Name: "Existing"})
+
+	if _, err := Restore(ctx, dst, &archive); !errors.Is(err, ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty, got %v", err)
+	}
+}
+
+func TestRestore_InvalidArchive(t *testing.T) {
+	dst := newFileStorage(t)
+
+	if _, err := Restore(context.Background(), dst, bytes.NewReader([]byte("not gzip"))); !errors.Is(err, ErrInvalidArchive) {
+		t.Errorf("expected ErrInvalidArchive, got %v", err)
+	}
+}
+
+func TestWrite_Signed(t *testing.T) {
+	ctx := context.Background()
+	src := newFileStorage(t)
+	if _, err := src.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/abc"},
+		Title:      []models.Title{{Text: "Signed <title> & more"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	signer, err := signing.New(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), "backup", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	if _, err := Write(ctx, src, storage.StorageTypeFile, signer, &archive); err != nil {
+		t.Fatal(err)
+	}
+
+	// Consumers verify the raid member exactly as it appears in the line
+	gz, err := gzip.NewReader(&archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]ed25519.PublicKey{"backup": signer.Public()}
+	signed := 0
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var line struct {
+			Kind      string          `json:"kind"`
+			RAiD      json.RawMessage `json:"raid"`
+			Signature string          `json:"signature"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatal(err)
+		}
+		if line.Kind != KindRAiD {
+			continue
+		}
+		if err := signing.VerifyJWS(keys, line.RAiD, line.Signature); err != nil {
+			t.Errorf("expected the RAiD signature to verify, got %v", err)
+		}
+		signed++
+	}
+	if signed != 1 {
+		t.Errorf("expected 1 signed RAiD, got %d", signed)
+	}
+}
@@ -0,0 +1,287 @@
+package backup
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+// archivePrefix and archiveSuffix identify archives managed by the scheduler;
+// other files at the target are never rotated
+const (
+	archivePrefix = "raid-backup-"
+	archiveSuffix = ".ndjson.gz"
+)
+
+// metrics exposes backup outcomes under /debug/vars
+var metrics = expvar.NewMap("backup")
+
+// ArchiveName returns the file name for an archive taken at t
+func ArchiveName(t time.Time) string {
+	return archivePrefix + t.UTC().Format("20060102T150405Z") + archiveSuffix
+}
+
+// Retention controls which scheduled backups are kept. A backup is removed
+// when it falls outside the newest Count backups or is older than MaxAge.
+// Zero values disable the respective rule; the newest backup is always kept.
+type Retention struct {
+	Count  int
+	MaxAge time.Duration
+}
+
+// Status reports the scheduler's state
+type Status struct {
+	Schedule     string    `json:"schedule"`
+	Target       string    `json:"target"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"nextRun,omitempty"`
+	LastRun      time.Time `json:"lastRun,omitempty"`
+	LastSuccess  time.Time `json:"lastSuccess,omitempty"`
+	LastError    string    `json:"lastError,omitempty"`
+	LastDuration string    `json:"lastDuration,omitempty"`
+	LastArchive  string    `json:"lastArchive,omitempty"`
+	LastSize     int64     `json:"lastSize,omitempty"`
+	LastSummary  *Summary  `json:"lastSummary,omitempty"`
+	Successes    int64     `json:"successes"`
+	Failures     int64     `json:"failures"`
+	Backups      []Object  `json:"backups,omitempty"`
+}
+
+// Scheduler takes backups on a cron schedule and rotates old ones
+type Scheduler struct {
+	repo        storage.Repository
+	storageType storage.StorageType
+	target      Target
+	retention   Retention
+	schedule    cron.Schedule
+	signer      Signer
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewScheduler creates a scheduler for a standard five-field cron
+// expression or descriptor such as "@daily". Archives are signed with
+// signer unless it is nil.
+func NewScheduler(repo storage.Repository, storageType storage.StorageType, spec string, target Target, retention Retention, signer Signer) (*Scheduler, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup schedule %q: %w", spec, err)
+	}
+
+	s := &Scheduler{
+		repo:        repo,
+		storageType: storageType,
+		target:      target,
+		retention:   retention,
+		schedule:    schedule,
+		signer:      signer,
+	}
+	s.status.Schedule = spec
+	s.status.Target = target.String()
+
+	return s, nil
+}
+
+// Run takes backups on schedule until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	for {
+		next := s.schedule.Next(time.Now())
+		s.mu.Lock()
+		s.status.NextRun = next
+		s.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := s.RunNow(ctx); err != nil {
+			log.Printf("Scheduled backup failed: %v", err)
+		}
+	}
+}
+
+// RunNow takes a backup immediately and applies the retention policy. It
+// returns an error if a backup is already in progress.
+func (s *Scheduler) RunNow(ctx context.Context) (*Status, error) {
+	s.mu.Lock()
+	if s.status.Running {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("backup already in progress")
+	}
+	s.status.Running = true
+	s.mu.Unlock()
+
+	start := time.Now()
+	name, size, summary, err := s.backup(ctx, start)
+
+	s.mu.Lock()
+	s.status.Running = false
+	s.status.LastRun = start
+	s.status.LastDuration = time.Since(start).String()
+	metrics.Set("lastRunUnix", intVar(start.Unix()))
+	metrics.Set("lastDurationSeconds", floatVar(time.Since(start).Seconds()))
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.Failures++
+		metrics.Add("failures", 1)
+	} else {
+		s.status.LastError = ""
+		s.status.LastSuccess = start
+		s.status.LastArchive = name
+		s.status.LastSize = size
+		s.status.LastSummary = summary
+		s.status.Successes++
+		metrics.Add("successes", 1)
+		metrics.Set("lastSuccessUnix", intVar(start.Unix()))
+		metrics.Set("lastSizeBytes", intVar(size))
+	}
+	s.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Backup %s written to %s (%d bytes, %d RAiDs)", name, s.target, size, summary.RAiDs)
+
+	if err := s.rotate(ctx); err != nil {
+		log.Printf("Backup rotation failed: %v", err)
+	}
+
+	status := s.Status()
+	return &status, nil
+}
+
+// backup writes an archive to a temporary file and uploads it to the target
+func (s *Scheduler) backup(ctx context.Context, now time.Time) (string, int64, *Summary, error) {
+	tmp, err := os.CreateTemp("", "raid-backup-*")
+	if err != nil {
+		return "", 0, nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	summary, err := Write(ctx, s.repo, s.storageType, s.signer, tmp)
+	if err != nil {
+		return "", 0, nil, err
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", 0, nil, err
+	}
+
+	name := ArchiveName(now)
+	if err := s.target.Put(ctx, name, tmp, size); err != nil {
+		return "", 0, nil, fmt.Errorf("failed to store backup at %s: %w", s.target, err)
+	}
+
+	return name, size, summary, nil
+}
+
+// rotate deletes scheduled backups outside the retention policy
+func (s *Scheduler) rotate(ctx context.Context) error {
+	if s.retention.Count <= 0 && s.retention.MaxAge <= 0 {
+		return nil
+	}
+
+	archives, err := s.archives(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range expired(archives, s.retention, time.Now()) {
+		if err := s.target.Delete(ctx, obj.Name); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", obj.Name, err)
+		}
+		log.Printf("Deleted expired backup %s", obj.Name)
+	}
+	return nil
+}
+
+// archives lists scheduler-managed archives at the target, newest first
+func (s *Scheduler) archives(ctx context.Context) ([]Object, error) {
+	objects, err := s.target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	archives := make([]Object, 0, len(objects))
+	for _, obj := range objects {
+		if strings.HasPrefix(obj.Name, archivePrefix) && strings.HasSuffix(obj.Name, archiveSuffix) {
+			archives = append(archives, obj)
+		}
+	}
+
+	// Names embed the timestamp, so they sort chronologically
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].Name > archives[j].Name
+	})
+	return archives, nil
+}
+
+// expired returns the archives (sorted newest first) to delete under policy
+func expired(archives []Object, policy Retention, now time.Time) []Object {
+	var out []Object
+	for i, obj := range archives {
+		if i == 0 {
+			continue
+		}
+		if policy.Count > 0 && i >= policy.Count {
+			out = append(out, obj)
+			continue
+		}
+		if policy.MaxAge > 0 && now.Sub(obj.Modified) > policy.MaxAge {
+			out = append(out, obj)
+		}
+	}
+	return out
+}
+
+// Status returns the scheduler state without listing the target
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// StatusWithBackups returns the scheduler state including the archives
+// currently stored at the target
+func (s *Scheduler) StatusWithBackups(ctx context.Context) (Status, error) {
+	status := s.Status()
+	archives, err := s.archives(ctx)
+	if err != nil {
+		return status, err
+	}
+	status.Backups = archives
+	return status, nil
+}
+
+func intVar(v int64) *expvar.Int {
+	i := new(expvar.Int)
+	i.Set(v)
+	return i
+}
+
+func floatVar(v float64) *expvar.Float {
+	f := new(expvar.Float)
+	f.Set(v)
+	return f
+}
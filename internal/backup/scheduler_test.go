@@ -0,0 +1,138 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestScheduler_RunNowAndRotate(t *testing.T) {
+	ctx := context.Background()
+	repo := newFileStorage(t)
+	repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Test SP"})
+
+	target, err := NewLocalTarget(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pre-existing archives from earlier runs plus an unrelated file
+	for _, name := range []string{
+		ArchiveName(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+		ArchiveName(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)),
+		"notes.txt",
+	} {
+		target.Put(ctx, name, strings.NewReader("x"), 1)
+	}
+
+	s, err := NewScheduler(repo, storage.StorageTypeFile, "@daily", target, Retention{Count: 2}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := s.RunNow(ctx)
+	if err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if status.Successes != 1 || status.LastSummary.ServicePoints != 1 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	objects, _ := target.List(ctx)
+	names := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		names = append(names, obj.Name)
+	}
+	joined := strings.Join(names, ",")
+
+	if strings.Contains(joined, "20240101") {
+		t.Errorf("expected oldest archive to be rotated, got %s", joined)
+	}
+	if !strings.Contains(joined, "20240102") || !strings.Contains(joined, status.LastArchive) {
+		t.Errorf("expected two newest archives to be kept, got %s", joined)
+	}
+	if !strings.Contains(joined, "notes.txt") {
+		t.Errorf("expected unrelated files to be left alone, got %s", joined)
+	}
+}
+
+func TestNewScheduler_InvalidSchedule(t *testing.T) {
+	target := &LocalTarget{Dir: t.TempDir()}
+	if _, err := NewScheduler(newFileStorage(t), storage.StorageTypeFile, "every tuesday", target, Retention{}, nil); err == nil {
+		t.Error("expected error for invalid cron expression")
+	}
+}
+
+func TestExpired_MaxAge(t *testing.T) {
+	now := time.Now()
+	archives := []Object{
+		{Name: "c", Modified: now.Add(-48 * time.Hour)},
+		{Name: "b", Modified: now.Add(-72 * time.Hour)},
+		{Name: "a", Modified: now.Add(-time.Hour)},
+	}
+
+	got := expired(archives, Retention{MaxAge: 24 * time.Hour}, now)
+	if len(got) != 1 || got[0].Name != "b" {
+		t.Errorf("expected only b to expire (newest is always kept), got %+v", got)
+	}
+}
+
+func TestS3Target(t *testing.T) {
+	var uploaded []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/bucket/raid/archive.ndjson.gz":
+			uploaded, _ = io.ReadAll(r.Body)
+		case r.Method == http.MethodGet && r.URL.Path == "/bucket/" && r.URL.Query().Get("list-type") == "2":
+			w.Write([]byte(`<ListBucketResult>
+  <Contents><Key>raid/archive.ndjson.gz</Key><Size>7</Size><LastModified>2024-01-02T03:04:05Z</LastModified></Contents>
+  <IsTruncated>false</IsTruncated>
+</ListBucketResult>`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/bucket/raid/archive.ndjson.gz":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	target, err := NewS3Target(S3Config{Bucket: "bucket", Prefix: "raid/", Region: "us-east-1", Endpoint: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := target.Put(ctx, "archive.ndjson.gz", bytes.NewReader([]byte("archive")), 7); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if string(uploaded) != "archive" {
+		t.Errorf("unexpected upload body %q", uploaded)
+	}
+
+	objects, err := target.List(ctx)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Name != "archive.ndjson.gz" || objects[0].Size != 7 {
+		t.Errorf("unexpected listing: %+v", objects)
+	}
+
+	if err := target.Delete(ctx, "archive.ndjson.gz"); err != nil {
+		t.Errorf("Delete failed: %v", err)
+	}
+}
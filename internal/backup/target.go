@@ -0,0 +1,245 @@
+package backup
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/awsv4"
+)
+
+// Object describes a stored backup archive
+type Object struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Target is a destination for scheduled backups
+type Target interface {
+	// String describes the target for logs and status output
+	String() string
+	// Put stores an archive of the given size under name
+	Put(ctx context.Context, name string, r io.Reader, size int64) error
+	// List returns the archives stored at the target
+	List(ctx context.Context) ([]Object, error)
+	// Delete removes an archive
+	Delete(ctx context.Context, name string) error
+}
+
+// LocalTarget stores backups in a directory on the local filesystem
+type LocalTarget struct {
+	Dir string
+}
+
+// NewLocalTarget creates a local directory target, creating the directory
+func NewLocalTarget(dir string) (*LocalTarget, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	return &LocalTarget{Dir: dir}, nil
+}
+
+func (t *LocalTarget) String() string {
+	return "local:" + t.Dir
+}
+
+// Put writes the archive to a temporary file and renames it into place so
+// that partial backups are never listed
+func (t *LocalTarget) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	tmp, err := os.CreateTemp(t.Dir, ".partial-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(t.Dir, name))
+}
+
+// List returns the archives in the directory
+func (t *LocalTarget) List(ctx context.Context) ([]Object, error) {
+	entries, err := os.ReadDir(t.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]Object, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		objects = append(objects, Object{Name: entry.Name(), Size: info.Size(), Modified: info.ModTime()})
+	}
+	return objects, nil
+}
+
+// Delete removes an archive from the directory
+func (t *LocalTarget) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(t.Dir, filepath.Base(name)))
+}
+
+// S3Config configures an S3 (or S3-compatible) backup target
+type S3Config struct {
+	Bucket string
+	// Prefix is prepended to object keys, e.g. "raid/backups/"
+	Prefix string
+	// Region overrides AWS_REGION
+	Region string
+	// Endpoint selects an S3-compatible service (MinIO, Ceph, ...) using
+	// path-style addressing; empty means AWS S3
+	Endpoint string
+}
+
+// S3Target stores backups in an S3 bucket. Credentials are read from the
+// standard AWS_* environment variables.
+type S3Target struct {
+	cfg    S3Config
+	creds  awsv4.Credentials
+	client *http.Client
+}
+
+// NewS3Target creates an S3 target
+func NewS3Target(cfg S3Config) (*S3Target, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+	creds, err := awsv4.CredentialsFromEnv(cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Target{cfg: cfg, creds: creds, client: &http.Client{}}, nil
+}
+
+func (t *S3Target) String() string {
+	return fmt.Sprintf("s3://%s/%s", t.cfg.Bucket, t.cfg.Prefix)
+}
+
+// objectURL returns the URL of a key, or of the bucket when key is empty
+func (t *S3Target) objectURL(key string) *url.URL {
+	u := &url.URL{Scheme: "https"}
+	if t.cfg.Endpoint != "" {
+		if parsed, err := url.Parse(t.cfg.Endpoint); err == nil {
+			u.Scheme, u.Host = parsed.Scheme, parsed.Host
+		}
+		u.Path = "/" + t.cfg.Bucket + "/" + key
+	} else {
+		u.Host = fmt.Sprintf("%s.s3.%s.amazonaws.com", t.cfg.Bucket, t.creds.Region)
+		u.Path = "/" + key
+	}
+	return u
+}
+
+func (t *S3Target) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	awsv4.Sign(req, payloadHash, "s3", t.creds, time.Now())
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("S3 %s %s returned %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// Put uploads an archive
+func (t *S3Target) Put(ctx context.Context, name string, r io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.objectURL(t.cfg.Prefix+name).String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+
+	resp, err := t.do(req, awsv4.UnsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the archives under the configured prefix
+func (t *S3Target) List(ctx context.Context) ([]Object, error) {
+	objects := make([]Object, 0)
+	token := ""
+
+	for {
+		u := t.objectURL("")
+		query := url.Values{"list-type": {"2"}, "prefix": {t.cfg.Prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u.RawQuery = query.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.do(req, awsv4.PayloadHash(nil))
+		if err != nil {
+			return nil, err
+		}
+
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("invalid S3 list response: %w", err)
+		}
+
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, t.cfg.Prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			objects = append(objects, Object{Name: name, Size: c.Size, Modified: c.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Delete removes an archive
+func (t *S3Target) Delete(ctx context.Context, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.objectURL(t.cfg.Prefix+name).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, awsv4.PayloadHash(nil))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+	repo := testutil.NewMockRepository()
+	loads := 0
+	repo.GetRAiDFunc = func(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+		loads++
+		if suffix == "missing" {
+			return nil, storage.ErrNotFound
+		}
+		return testutil.NewTestRAiD(prefix, suffix), nil
+	}
+	cached := Wrap(repo, NewMemoryStore(0), Policy{TTL: time.Minute, NegativeTTL: time.Minute})
+
+	for range 2 {
+		raid, err := cached.GetRAiD(ctx, "10.12345", "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Callers get their own copy to modify
+		raid.Title = nil
+	}
+	if loads != 1 {
+		t.Errorf("expected one backend read for two reads, got %d", loads)
+	}
+	if raid, _ := cached.GetRAiD(ctx, "10.12345", "a"); len(raid.Title) == 0 {
+		t.Error("expected the cached RAiD to be unaffected by changes to a copy")
+	}
+
+	// Misses are cached too
+	for range 2 {
+		if _, err := cached.GetRAiD(ctx, "10.12345", "missing"); !errors.Is(err, storage.ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	}
+	if loads != 2 {
+		t.Errorf("expected one backend read for two reads of a missing RAiD, got %d", loads-1)
+	}
+
+	// Writes drop the cached value
+	if _, err := cached.UpdateRAiD(ctx, "10.12345", "a", testutil.NewTestRAiD("10.12345", "a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.GetRAiD(ctx, "10.12345", "a"); err != nil || loads != 3 {
+		t.Errorf("expected an update to drop the cached RAiD, got %d reads, %v", loads, err)
+	}
+	repo.CreateRAiDFunc = func(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+		return testutil.NewTestRAiD("10.12345", "missing"), nil
+	}
+	if _, err := cached.CreateRAiD(ctx, &models.RAiD{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cached.GetRAiD(ctx, "10.12345", "missing"); loads != 4 {
+		t.Errorf("expected a mint to drop the cached miss, got %d reads, %v", loads, err)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	s := NewMemoryStore(2)
+	s.now = func() time.Time { return now }
+
+	s.Set(ctx, "a", []byte("1"), time.Second)
+	if v, ok, _ := s.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Errorf("expected a to be cached, got %q, %t", v, ok)
+	}
+	now = now.Add(time.Second)
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("expected a to expire")
+	}
+
+	s.Set(ctx, "b", nil, time.Minute)
+	s.Set(ctx, "c", nil, time.Minute)
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("expected the full store to be emptied")
+	}
+	if _, ok, _ := s.Get(ctx, "c"); !ok {
+		t.Error("expected c to be cached")
+	}
+}
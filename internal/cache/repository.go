@@ -0,0 +1,176 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// metrics exposes cache hits and misses under /debug/vars
+var metrics = expvar.NewMap("cache")
+
+// Policy configures how long values are cached
+type Policy struct {
+	// TTL bounds how long a RAiD or service point is served from the
+	// cache. Changes made through the same instance, and purges and
+	// redactions reported with InvalidateRAiD, drop it at once; the TTL
+	// bounds how long other instances and other admin writes go unseen.
+	TTL time.Duration
+	// NegativeTTL bounds how long a RAiD or service point found missing is
+	// reported missing without asking the backend; 0 does not cache
+	// misses
+	NegativeTTL time.Duration
+}
+
+// Wrap returns a repository serving GetRAiD and GetServicePoint from store,
+// loading values from repo on a miss, and dropping the cached values of
+// RAiDs and service points it creates, updates or deletes
+func Wrap(repo storage.Repository, store Store, policy Policy) storage.Repository {
+	return &repository{Repository: repo, store: store, policy: policy}
+}
+
+// RAiDInvalidator is implemented by the repositories Wrap returns. It
+// drops the cached value of a RAiD changed around the cache, as purges and
+// redactions by the admin API and retention are.
+type RAiDInvalidator interface {
+	InvalidateRAiD(ctx context.Context, prefix, suffix string)
+}
+
+type repository struct {
+	storage.Repository
+	store  Store
+	policy Policy
+	// gen counts invalidations, so that a value loaded while it was being
+	// changed is not cached
+	gen atomic.Uint64
+}
+
+func raidKey(prefix, suffix string) string {
+	return "raid:" + prefix + "/" + suffix
+}
+
+func servicePointKey(id int64) string {
+	return "sp:" + strconv.FormatInt(id, 10)
+}
+
+// readThrough returns the value cached under key, or calls load and caches
+// its result. Misses are cached as empty values. The cache failing only
+// costs the backend call it would have saved.
+func readThrough[T any](ctx context.Context, r *repository, key string, load func() (*T, error)) (*T, error) {
+	data, ok, err := r.store.Get(ctx, key)
+	if err != nil {
+		metrics.Add("errors", 1)
+		log.Printf("Failed to read %s from cache: %v", key, err)
+	}
+	if ok {
+		if len(data) == 0 {
+			metrics.Add("negativeHits", 1)
+			return nil, storage.ErrNotFound
+		}
+		var v T
+		if json.Unmarshal(data, &v) == nil {
+			metrics.Add("hits", 1)
+			return &v, nil
+		}
+	}
+	metrics.Add("misses", 1)
+
+	gen := r.gen.Load()
+	v, err := load()
+	ttl := r.policy.TTL
+	switch {
+	case err == nil:
+		if data, err = json.Marshal(v); err != nil {
+			return v, nil
+		}
+	case errors.Is(err, storage.ErrNotFound) && r.policy.NegativeTTL > 0:
+		data, ttl = []byte{}, r.policy.NegativeTTL
+	default:
+		return v, err
+	}
+	if r.gen.Load() == gen {
+		if setErr := r.store.Set(ctx, key, data, ttl); setErr != nil {
+			metrics.Add("errors", 1)
+			log.Printf("Failed to cache %s: %v", key, setErr)
+		}
+	}
+	return v, err
+}
+
+// invalidate drops the values cached under keys after they were changed
+func (r *repository) invalidate(ctx context.Context, keys ...string) {
+	r.gen.Add(1)
+	if err := r.store.Delete(ctx, keys...); err != nil {
+		metrics.Add("errors", 1)
+		log.Printf("Failed to drop %v from cache: %v", keys, err)
+	}
+}
+
+// InvalidateRAiD drops the cached value of a RAiD
+func (r *repository) InvalidateRAiD(ctx context.Context, prefix, suffix string) {
+	r.invalidate(ctx, raidKey(prefix, suffix))
+}
+
+func (r *repository) GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+	return readThrough(ctx, r, raidKey(prefix, suffix), func() (*models.RAiD, error) {
+		return r.Repository.GetRAiD(ctx, prefix, suffix)
+	})
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	// The identifier may have been cached as missing
+	if err == nil && created.Identifier != nil {
+		if prefix, suffix, parseErr := identifier.Parse(created.Identifier.ID); parseErr == nil {
+			r.invalidate(ctx, raidKey(prefix, suffix))
+		}
+	}
+	return created, err
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	updated, err := r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+	r.invalidate(ctx, raidKey(prefix, suffix))
+	return updated, err
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	err := r.Repository.DeleteRAiD(ctx, prefix, suffix)
+	r.invalidate(ctx, raidKey(prefix, suffix))
+	return err
+}
+
+func (r *repository) GetServicePoint(ctx context.Context, id int64) (*models.ServicePoint, error) {
+	return readThrough(ctx, r, servicePointKey(id), func() (*models.ServicePoint, error) {
+		return r.Repository.GetServicePoint(ctx, id)
+	})
+}
+
+func (r *repository) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	created, err := r.Repository.CreateServicePoint(ctx, sp)
+	if err == nil {
+		r.invalidate(ctx, servicePointKey(created.ID))
+	}
+	return created, err
+}
+
+func (r *repository) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	updated, err := r.Repository.UpdateServicePoint(ctx, id, sp)
+	r.invalidate(ctx, servicePointKey(id))
+	return updated, err
+}
+
+func (r *repository) DeleteServicePoint(ctx context.Context, id int64) error {
+	err := r.Repository.DeleteServicePoint(ctx, id)
+	r.invalidate(ctx, servicePointKey(id))
+	return err
+}
@@ -0,0 +1,126 @@
+// Package cache layers a read-through cache over a storage backend. Wrap
+// serves GetRAiD and GetServicePoint from a Store, in process memory or in
+// Redis shared by all server instances, and drops the entries of RAiDs and
+// service points changed through it.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Store holds cached values by key
+type Store interface {
+	// Get returns the value stored under key and whether there is one
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete drops the values stored under keys
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// MemoryStore keeps values in process memory, per server instance. It
+// holds at most maxEntries values and is emptied when full.
+type MemoryStore struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+	now        func() time.Time
+}
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates an in-memory store of up to maxEntries values;
+// 0 or less means 10000
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+	return &MemoryStore{entries: make(map[string]memoryEntry), maxEntries: maxEntries, now: time.Now}
+}
+
+// Get returns the value stored under key, if it has not expired
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !s.now().Before(e.expires) {
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+// Set stores value under key for ttl
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[key]; !ok && len(s.entries) >= s.maxEntries {
+		s.entries = make(map[string]memoryEntry)
+	}
+	s.entries[key] = memoryEntry{value: value, expires: s.now().Add(ttl)}
+	return nil
+}
+
+// Delete drops the values stored under keys
+func (s *MemoryStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// RedisStore keeps values in Redis, shared by all server instances
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a store from a redis:// or rediss:// URL. Keys are
+// namespaced with prefix.
+func NewRedisStore(url, prefix string) (*RedisStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisStore{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+// Get returns the value stored under key
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores value under key for ttl
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+// Delete drops the values stored under keys
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = s.prefix + key
+	}
+	return s.client.Del(ctx, prefixed...).Err()
+}
+
+// Close closes the Redis connection
+func (s *RedisStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,396 @@
+// Package chain verifies the hash chains linking the stored versions of
+// each RAiD, and anchors them: on a schedule it appends the chain hash of
+// the newest version of every RAiD, with a digest of them all, to an anchor
+// file kept or copied outside the storage backend. Rewriting stored history
+// breaks the chain at the version rewritten; rewriting the chain hashes
+// along with it is detected against the anchors written before.
+package chain
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+// IssueAnchorMismatch is a RAiD whose chain no longer leads to the chain
+// hash anchored for it
+const IssueAnchorMismatch = "anchor-mismatch"
+
+// IssueAnchorRedacted notes a RAiD redacted since it was anchored, whose
+// chain was recorded again by the redaction
+const IssueAnchorRedacted = "anchor-redacted"
+
+// IssueAnchorPurged notes an anchored RAiD purged since
+const IssueAnchorPurged = "anchor-purged"
+
+// metrics exposes anchoring outcomes under /debug/vars
+var metrics = expvar.NewMap("chain")
+
+// Result is the verification of the chain of one RAiD
+type Result struct {
+	RAiD string `json:"raid"`
+	// Versions is the number of stored versions, Chained those with a
+	// chain hash
+	Versions int `json:"versions"`
+	Chained  int `json:"chained"`
+	// Head is the chain hash of the newest version
+	Head   string                `json:"head,omitempty"`
+	Valid  bool                  `json:"valid"`
+	Issues []storage.VerifyIssue `json:"issues"`
+}
+
+// Verify checks the chain of the RAiD prefix/suffix in repo, which must
+// return versions as stored
+func Verify(ctx context.Context, repo storage.Repository, prefix, suffix string) (*Result, error) {
+	history, err := repo.GetRAiDHistory(ctx, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := repo.GetRAiDChanges(ctx, prefix, suffix, nil)
+	if err != nil {
+		return nil, err
+	}
+	handle := prefix + "/" + suffix
+	issues := storage.CheckChain(handle, handle, history, changes)
+	result := &Result{RAiD: handle, Versions: len(history), Valid: len(issues) == 0, Issues: issues}
+	if result.Issues == nil {
+		result.Issues = []storage.VerifyIssue{}
+	}
+	for _, change := range changes {
+		if change.Chain != "" {
+			result.Chained++
+		}
+	}
+	if n := len(changes); n > 0 {
+		result.Head = changes[n-1].Chain
+	}
+	return result, nil
+}
+
+// VerifyAll checks the chain of every RAiD in repo, adding the issues to
+// report, and against anchor, if not nil. The versions of deleted RAiDs
+// are not served and not checked. A redaction records the chain of a RAiD
+// again, so one redacted since the anchor is noted rather than reported;
+// so is an anchored RAiD no longer stored because it was purged since.
+// Purges whose records retention has removed are not known, and the RAiDs
+// are reported as mismatched: verification cannot tell them from RAiDs
+// removed behind its back.
+func VerifyAll(ctx context.Context, repo storage.Repository, report *storage.VerifyReport, anchor *Anchor) error {
+	heads := make(map[string]Head)
+	if anchor != nil {
+		for _, head := range anchor.Heads {
+			heads[head.RAiD] = head
+		}
+	}
+	redacted, err := redactions(ctx, repo)
+	if err != nil {
+		return err
+	}
+	purged, err := purges(ctx, repo)
+	if err != nil {
+		return err
+	}
+	raids, err := repo.ListRAiDs(ctx, &storage.RAiDFilter{IncludeDeleted: true})
+	if err != nil {
+		return fmt.Errorf("failed to list RAiDs: %w", err)
+	}
+	for _, raid := range raids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if raid.Identifier == nil {
+			continue
+		}
+		prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil {
+			continue
+		}
+		handle := prefix + "/" + suffix
+		anchored, hasAnchor := heads[handle]
+		delete(heads, handle)
+		if raid.Metadata != nil && raid.Metadata.Deleted {
+			continue
+		}
+
+		result, err := Verify(ctx, repo, prefix, suffix)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %w", handle, err)
+		}
+		for _, issue := range result.Issues {
+			report.Add(issue)
+		}
+		if hasAnchor {
+			issue := checkAnchor(ctx, repo, prefix, suffix, anchored, anchor.Time)
+			if issue == nil {
+				continue
+			}
+			if at := redacted[handle]; at.After(anchor.Time) {
+				report.Note(storage.VerifyIssue{
+					Kind:     IssueAnchorRedacted,
+					Location: handle,
+					RAiD:     handle,
+					Message:  fmt.Sprintf("version %d was anchored on %s and the RAiD redacted on %s", anchored.Version, anchor.Time.Format(time.RFC3339), at.Format(time.RFC3339)),
+				})
+				continue
+			}
+			report.Add(*issue)
+		}
+	}
+	for handle, head := range heads {
+		if at := purged[handle]; at.After(anchor.Time) {
+			report.Note(storage.VerifyIssue{
+				Kind:     IssueAnchorPurged,
+				Location: handle,
+				RAiD:     handle,
+				Message:  fmt.Sprintf("RAiD anchored at version %d on %s was purged on %s", head.Version, anchor.Time.Format(time.RFC3339), at.Format(time.RFC3339)),
+			})
+			continue
+		}
+		report.Add(storage.VerifyIssue{
+			Kind:     IssueAnchorMismatch,
+			Location: handle,
+			RAiD:     handle,
+			Message:  fmt.Sprintf("RAiD anchored at version %d on %s is no longer stored", head.Version, anchor.Time.Format(time.RFC3339)),
+		})
+	}
+	return nil
+}
+
+// redactions returns when each RAiD was last redacted, by handle, if repo
+// records redactions
+func redactions(ctx context.Context, repo storage.Repository) (map[string]time.Time, error) {
+	redacted := make(map[string]time.Time)
+	redactor, ok := storage.Source(repo).(storage.Redactor)
+	if !ok {
+		return redacted, nil
+	}
+	list, err := redactor.ListRedactions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list redactions: %w", err)
+	}
+	for _, redaction := range list {
+		handle := redaction.Prefix + "/" + redaction.Suffix
+		if redaction.Redacted.After(redacted[handle]) {
+			redacted[handle] = redaction.Redacted
+		}
+	}
+	return redacted, nil
+}
+
+// purges returns when each RAiD was last purged, by handle, if repo records
+// purges
+func purges(ctx context.Context, repo storage.Repository) (map[string]time.Time, error) {
+	purged := make(map[string]time.Time)
+	purger, ok := storage.Source(repo).(storage.Purger)
+	if !ok {
+		return purged, nil
+	}
+	list, err := purger.ListPurges(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list purges: %w", err)
+	}
+	for _, purge := range list {
+		handle := purge.Prefix + "/" + purge.Suffix
+		if purge.Purged.After(purged[handle]) {
+			purged[handle] = purge.Purged
+		}
+	}
+	return purged, nil
+}
+
+// checkAnchor reports the RAiD prefix/suffix if the stored chain hash of
+// its anchored version is not the one anchored
+func checkAnchor(ctx context.Context, repo storage.Repository, prefix, suffix string, head Head, at time.Time) *storage.VerifyIssue {
+	changes, err := repo.GetRAiDChanges(ctx, prefix, suffix, &storage.HistoryPage{After: head.Version - 1, Limit: 1, Summary: true})
+	if err == nil && len(changes) == 1 && changes[0].Version == head.Version && changes[0].Chain == head.Chain {
+		return nil
+	}
+	return &storage.VerifyIssue{
+		Kind:     IssueAnchorMismatch,
+		Location: head.RAiD,
+		RAiD:     head.RAiD,
+		Message:  fmt.Sprintf("version %d no longer has the chain hash anchored on %s: history up to it was rewritten", head.Version, at.Format(time.RFC3339)),
+	}
+}
+
+// Head is the newest chained version of a RAiD
+type Head struct {
+	RAiD    string `json:"raid"`
+	Version int    `json:"version"`
+	Chain   string `json:"chain"`
+}
+
+// Anchor records the heads of the chains of all RAiDs at a point in time.
+// Digest covers the heads, so publishing it, for instance to a timestamping
+// service, commits to all of them.
+type Anchor struct {
+	Time   time.Time `json:"time"`
+	Digest string    `json:"digest"`
+	Heads  []Head    `json:"heads"`
+}
+
+// Heads returns the chain heads of every RAiD in repo that has one, by
+// handle
+func Heads(ctx context.Context, repo storage.Repository) (*Anchor, error) {
+	raids, err := repo.ListRAiDs(ctx, &storage.RAiDFilter{IncludeDeleted: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list RAiDs: %w", err)
+	}
+	anchor := &Anchor{Time: time.Now().UTC(), Heads: []Head{}}
+	for _, raid := range raids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if raid.Identifier == nil || raid.Metadata != nil && raid.Metadata.Deleted {
+			continue
+		}
+		prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil {
+			continue
+		}
+		changes, err := repo.GetRAiDChanges(ctx, prefix, suffix, &storage.HistoryPage{After: raid.Identifier.Version - 1, Limit: 1, Summary: true})
+		if err != nil || len(changes) == 0 || changes[0].Chain == "" {
+			continue
+		}
+		anchor.Heads = append(anchor.Heads, Head{RAiD: prefix + "/" + suffix, Version: changes[0].Version, Chain: changes[0].Chain})
+	}
+	sort.Slice(anchor.Heads, func(i, j int) bool { return anchor.Heads[i].RAiD < anchor.Heads[j].RAiD })
+	anchor.Digest = anchor.digest()
+	return anchor, nil
+}
+
+// digest hashes the heads, one line each, in handle order
+func (a *Anchor) digest() string {
+	h := sha256.New()
+	for _, head := range a.Heads {
+		fmt.Fprintf(h, "%s %d %s\n", head.RAiD, head.Version, head.Chain)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// LastAnchor reads the newest anchor of the anchor file at path, nil if
+// it has none
+func LastAnchor(path string) (*Anchor, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var last *Anchor
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<30)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var anchor Anchor
+		if err := json.Unmarshal(scanner.Bytes(), &anchor); err != nil {
+			return nil, fmt.Errorf("invalid anchor in %s: %w", path, err)
+		}
+		if anchor.Digest != anchor.digest() {
+			return nil, fmt.Errorf("anchor of %s in %s does not match its digest", anchor.Time.Format(time.RFC3339), path)
+		}
+		last = &anchor
+	}
+	return last, scanner.Err()
+}
+
+// Anchorer appends anchors to a file on a cron schedule
+type Anchorer struct {
+	repo     storage.Repository
+	path     string
+	schedule cron.Schedule
+
+	mu sync.Mutex
+}
+
+// NewAnchorer creates an anchorer for a standard five-field cron
+// expression or descriptor such as "@daily", appending to the file at path
+func NewAnchorer(repo storage.Repository, spec, path string) (*Anchorer, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid anchor schedule %q: %w", spec, err)
+	}
+	if path == "" {
+		return nil, fmt.Errorf("an anchor file is required")
+	}
+	return &Anchorer{repo: repo, path: path, schedule: schedule}, nil
+}
+
+// Run anchors on schedule until ctx is cancelled
+func (a *Anchorer) Run(ctx context.Context) {
+	for {
+		timer := time.NewTimer(time.Until(a.schedule.Next(time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := a.AnchorNow(ctx); err != nil {
+			log.Printf("Anchoring version history failed: %v", err)
+		}
+	}
+}
+
+// AnchorNow appends an anchor of the current chain heads to the file
+func (a *Anchorer) AnchorNow(ctx context.Context) (*Anchor, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	anchor, err := Heads(ctx, a.repo)
+	if err != nil {
+		metrics.Add("failures", 1)
+		return nil, err
+	}
+	data, err := json.Marshal(anchor)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(a.path), 0750); err != nil {
+		metrics.Add("failures", 1)
+		return nil, err
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+	if err != nil {
+		metrics.Add("failures", 1)
+		return nil, err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		metrics.Add("failures", 1)
+		return nil, err
+	}
+	metrics.Add("anchors", 1)
+	log.Printf("Anchored the version history of %d RAiDs: digest %s", len(anchor.Heads), anchor.Digest)
+	return anchor, nil
+}
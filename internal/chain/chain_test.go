@@ -0,0 +1,266 @@
+package chain
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+// findFile returns the path of the file under dir whose path ends in name
+func findFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	var found string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && strings.HasSuffix(path, string(filepath.Separator)+name) {
+			found = path
+		}
+		return nil
+	})
+	if found == "" {
+		t.Fatalf("%s not found", name)
+	}
+	return found
+}
+
+// rewrite replaces old with new in the file at path
+func rewrite(t *testing.T, path, old, new string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(old)) {
+		t.Fatalf("%s does not contain %q", path, old)
+	}
+	if err := os.WriteFile(path, bytes.ReplaceAll(data, []byte(old), []byte(new)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := file.New(&file.Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	id := &models.Identifier{ID: "https://raid.org/10.99999/busy"}
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: id, Title: []models.Title{{Text: "Version 1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	for v := 2; v <= 3; v++ {
+		if _, err := repo.UpdateRAiD(ctx, "10.99999", "busy", &models.RAiD{Identifier: id, Title: []models.Title{{Text: fmt.Sprintf("Version %d", v)}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Verify(ctx, repo, "10.99999", "busy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Valid || result.Versions != 3 || result.Chained != 3 || result.Head == "" {
+		t.Fatalf("untouched history verified as %+v", result)
+	}
+
+	anchorFile := filepath.Join(t.TempDir(), "anchors.jsonl")
+	anchorer, err := NewAnchorer(repo, "@daily", anchorFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := anchorer.AnchorNow(ctx); err != nil {
+		t.Fatal(err)
+	}
+	anchor, err := LastAnchor(anchorFile)
+	if err != nil || anchor == nil || len(anchor.Heads) != 1 || anchor.Heads[0].Chain != result.Head {
+		t.Fatalf("LastAnchor() = %+v, %v", anchor, err)
+	}
+
+	// Rewriting a version breaks the chain there
+	rewrite(t, findFile(t, dir, "v2.json"), "Version 2", "Version X")
+	result, err = Verify(ctx, repo, "10.99999", "busy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Valid || len(result.Issues) != 1 || result.Issues[0].Kind != storage.IssueChainBroken {
+		t.Fatalf("rewritten version verified as %+v", result)
+	}
+
+	// Rewriting the chain along with it is caught by the anchor
+	history, err := repo.GetRAiDHistory(ctx, "10.99999", "busy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := storage.RecordHistory(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(changes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(findFile(t, dir, filepath.Join(".changes", "busy.json")), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	report := storage.NewVerifyReport()
+	if err := VerifyAll(ctx, repo, report, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Fatalf("consistently rewritten chain reported without an anchor: %+v", report.Issues)
+	}
+	report = storage.NewVerifyReport()
+	if err := VerifyAll(ctx, repo, report, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != IssueAnchorMismatch {
+		t.Errorf("rewritten chain verified against its anchor as %+v", report.Issues)
+	}
+}
+
+func TestVerifyAll_Redacted(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	id := &models.Identifier{ID: "https://raid.org/10.99999/private"}
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: id, Title: []models.Title{{Text: "Version 1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	anchor, err := Heads(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redact := func(raid *models.RAiD) bool {
+		raid.Title[0].Text = "Redacted"
+		return true
+	}
+	redaction := &models.Redaction{ID: "r1", Prefix: "10.99999", Suffix: "private", Actor: "dpo", Redacted: time.Now().UTC()}
+	if _, err := repo.RedactRAiD(ctx, "10.99999", "private", redact, redaction); err != nil {
+		t.Fatal(err)
+	}
+
+	report := storage.NewVerifyReport()
+	if err := VerifyAll(ctx, repo, report, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Notes) != 1 || report.Notes[0].Kind != IssueAnchorRedacted {
+		t.Fatalf("RAiD redacted since its anchor verified as %+v, notes %+v", report.Issues, report.Notes)
+	}
+
+	// Redacted before the anchor, a rewritten chain is still a mismatch
+	anchor.Time = redaction.Redacted.Add(time.Second)
+	report = storage.NewVerifyReport()
+	if err := VerifyAll(ctx, repo, report, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 1 || report.Issues[0].Kind != IssueAnchorMismatch {
+		t.Errorf("RAiD redacted before its anchor verified as %+v", report.Issues)
+	}
+}
+
+func TestVerifyAll_Purged(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for _, suffix := range []string{"purged", "removed"} {
+		id := &models.Identifier{ID: "https://raid.org/10.99999/" + suffix}
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: id, Title: []models.Title{{Text: "Version 1"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	anchor, err := Heads(ctx, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	purge := func(suffix string) {
+		t.Helper()
+		if err := repo.DeleteRAiD(ctx, "10.99999", suffix); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: suffix, Actor: "admin", Purged: time.Now().UTC()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	purge("purged")
+
+	report := storage.NewVerifyReport()
+	if err := VerifyAll(ctx, repo, report, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || len(report.Notes) != 1 || report.Notes[0].Kind != IssueAnchorPurged || report.Notes[0].RAiD != "10.99999/purged" {
+		t.Fatalf("RAiD purged since its anchor verified as %+v, notes %+v", report.Issues, report.Notes)
+	}
+
+	// Once retention removes the record of the purge, the RAiD is reported
+	// as if removed outside the API
+	purge("removed")
+	if _, err := repo.PruneAudit(ctx, time.Now().Add(time.Second), false); err != nil {
+		t.Fatal(err)
+	}
+	report = storage.NewVerifyReport()
+	if err := VerifyAll(ctx, repo, report, anchor); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Issues) != 2 || report.Issues[0].Kind != IssueAnchorMismatch || len(report.Notes) != 0 {
+		t.Errorf("RAiDs purged without a record verified as %+v, notes %+v", report.Issues, report.Notes)
+	}
+}
+
+func TestCheckChain_Deleted(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	id := &models.Identifier{ID: "https://raid.org/10.99999/gone"}
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: id, Title: []models.Title{{Text: "Version 1"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.UpdateRAiD(ctx, "10.99999", "gone", &models.RAiD{Identifier: id, Title: []models.Title{{Text: "Version 2"}}}); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := repo.GetRAiDChanges(ctx, "10.99999", "gone", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := repo.GetRAiDVersion(ctx, "10.99999", "gone", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteRAiD(ctx, "10.99999", "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The current version as listed carries its deletion time, which is
+	// not part of the version
+	listed, err := repo.ListRAiDs(ctx, &storage.RAiDFilter{IncludeDeleted: true})
+	if err != nil || len(listed) != 1 || listed[0].Metadata.DeletedAt.IsZero() {
+		t.Fatalf("expected the RAiD listed with its deletion time, got %+v, %v", listed, err)
+	}
+	if issues := storage.CheckChain("gone", "10.99999/gone", []*models.RAiD{first, listed[0]}, changes); len(issues) != 0 {
+		t.Errorf("deleted RAiD reported as %+v", issues)
+	}
+}
@@ -0,0 +1,78 @@
+// Package checkdigit implements the ISO/IEC 7064 MOD 37-2 check character
+// system for alphanumeric strings. The check character is one of 0-9, A-Z
+// or '*' and detects all single substitution errors and all transpositions
+// of adjacent characters, the most common typing mistakes.
+package checkdigit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ*"
+	modulus  = 37
+	radix    = 2
+)
+
+// ErrMismatch is returned by Validate when the check character is wrong
+var ErrMismatch = errors.New("check character does not match")
+
+// value returns the numeric value of an input character, case-insensitively
+func value(c byte) (int, bool) {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	i := strings.IndexByte(alphabet[:modulus-1], c)
+	return i, i >= 0
+}
+
+// Compute returns the check character for s, which may only contain
+// letters and digits
+func Compute(s string) (byte, error) {
+	if s == "" {
+		return 0, errors.New("empty input")
+	}
+	p := 0
+	for i := 0; i < len(s); i++ {
+		v, ok := value(s[i])
+		if !ok {
+			return 0, fmt.Errorf("invalid character %q at position %d", s[i], i+1)
+		}
+		p = (p + v) * radix % modulus
+	}
+	return alphabet[(modulus+1-p)%modulus], nil
+}
+
+// Append returns s followed by its check character
+func Append(s string) (string, error) {
+	c, err := Compute(s)
+	if err != nil {
+		return "", err
+	}
+	return s + string(c), nil
+}
+
+// Validate checks the last character of s against the rest. It returns
+// ErrMismatch for a wrong check character and a descriptive error for
+// input that cannot carry one. A string missing its check character is
+// not reliably caught: its last character is the check character of the
+// rest 1 time in 37.
+func Validate(s string) error {
+	if len(s) < 2 {
+		return errors.New("too short to carry a check character")
+	}
+	want, err := Compute(s[:len(s)-1])
+	if err != nil {
+		return err
+	}
+	got := s[len(s)-1]
+	if got >= 'a' && got <= 'z' {
+		got -= 'a' - 'A'
+	}
+	if got != want {
+		return ErrMismatch
+	}
+	return nil
+}
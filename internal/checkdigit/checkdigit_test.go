@@ -0,0 +1,59 @@
+package checkdigit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		in   string
+		want byte
+	}{
+		{"G123498654321", 'H'},
+		{"g123498654321", 'H'},
+		{"0", '1'},
+	}
+	for _, tt := range tests {
+		got, err := Compute(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("Compute(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := Compute("ab-c"); err == nil {
+		t.Error("expected an error for a non-alphanumeric character")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Append("1760600000123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(s); err != nil {
+		t.Errorf("Validate(%q) = %v", s, err)
+	}
+
+	// Every single substitution and adjacent transposition is detected
+	for i := 0; i < len(s); i++ {
+		for _, c := range []byte(alphabet) {
+			if c == s[i] {
+				continue
+			}
+			typo := s[:i] + string(c) + s[i+1:]
+			if err := Validate(typo); err == nil {
+				t.Fatalf("substitution %q not detected", typo)
+			}
+		}
+		if i+1 < len(s) && s[i] != s[i+1] {
+			swapped := s[:i] + string(s[i+1]) + string(s[i]) + s[i+2:]
+			if err := Validate(swapped); !errors.Is(err, ErrMismatch) {
+				t.Fatalf("transposition %q not detected: %v", swapped, err)
+			}
+		}
+	}
+
+	if err := Validate("1"); err == nil {
+		t.Error("expected an error for a single character")
+	}
+}
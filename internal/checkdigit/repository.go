@@ -0,0 +1,140 @@
+package checkdigit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// IdentifierError is returned by a wrapped repository for a RAiD
+// identifier whose suffix does not carry a valid check character
+type IdentifierError struct {
+	Prefix string
+	Suffix string
+	Err    error
+}
+
+func (e *IdentifierError) Error() string {
+	if errors.Is(e.Err, ErrMismatch) {
+		return fmt.Sprintf("invalid RAiD identifier %s/%s: check character does not match, the identifier may be mistyped", e.Prefix, e.Suffix)
+	}
+	return fmt.Sprintf("invalid RAiD identifier %s/%s: %v", e.Prefix, e.Suffix, e.Err)
+}
+
+func (e *IdentifierError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap returns a repository that appends a check character to generated
+// suffixes and rejects identifiers with an invalid one before they reach
+// repo
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+// check validates the check character of suffix
+func check(prefix, suffix string) error {
+	if err := Validate(suffix); err != nil {
+		return &IdentifierError{Prefix: prefix, Suffix: suffix, Err: err}
+	}
+	return nil
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier != nil && raid.Identifier.ID != "" {
+		// Malformed identifiers are left for the backend to reject
+		if prefix, suffix, err := identifier.Parse(raid.Identifier.ID); err == nil {
+			if err := check(prefix, suffix); err != nil {
+				return nil, err
+			}
+		}
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	// Identifiers are generated here, with their check character, and
+	// minted through this repository again
+	return storage.MintGenerated(ctx, r, raid, identifier.FormatDefault)
+}
+
+func (r *repository) GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+	if err := check(prefix, suffix); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetRAiD(ctx, prefix, suffix)
+}
+
+// GetRAiDs reads the RAiDs whose suffixes carry a valid check character;
+// the others cannot be held
+func (r *repository) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	valid := make([]storage.IdentifierRef, 0, len(refs))
+	index := make([]int, 0, len(refs))
+	for i, ref := range refs {
+		if check(ref.Prefix, ref.Suffix) == nil {
+			valid = append(valid, ref)
+			index = append(index, i)
+		}
+	}
+	found, err := r.Repository.GetRAiDs(ctx, valid)
+	if err != nil {
+		return nil, err
+	}
+	raids := make([]*models.RAiD, len(refs))
+	for i, raid := range found {
+		raids[index[i]] = raid
+	}
+	return raids, nil
+}
+
+func (r *repository) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
+	if err := check(prefix, suffix); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetRAiDVersion(ctx, prefix, suffix, version)
+}
+
+func (r *repository) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
+	if err := check(prefix, suffix); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetRAiDHistory(ctx, prefix, suffix)
+}
+
+func (r *repository) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	if err := check(prefix, suffix); err != nil {
+		return nil, err
+	}
+	return r.Repository.GetRAiDChanges(ctx, prefix, suffix, page)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	if err := check(prefix, suffix); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	if err := check(prefix, suffix); err != nil {
+		return err
+	}
+	return r.Repository.DeleteRAiD(ctx, prefix, suffix)
+}
+
+func (r *repository) GenerateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
+	prefix, suffix, err := r.Repository.GenerateIdentifier(ctx, servicePointID)
+	if err != nil {
+		return "", "", err
+	}
+	checked, err := Append(suffix)
+	if err != nil {
+		return "", "", fmt.Errorf("generated suffix %q: %w", suffix, err)
+	}
+	return prefix, checked, nil
+}
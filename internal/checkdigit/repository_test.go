@@ -0,0 +1,72 @@
+package checkdigit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestWrap_Mint(t *testing.T) {
+	mock := testutil.NewMockRepository()
+	mock.GenerateIdentifierFunc = func(context.Context, int64) (string, string, error) {
+		return "10.12345", "1760600000123456789", nil
+	}
+	repo := Wrap(mock)
+	ctx := context.Background()
+
+	created, err := repo.CreateRAiD(ctx, &models.RAiD{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	suffix := created.Identifier.ID[strings.LastIndex(created.Identifier.ID, "/")+1:]
+	if !strings.HasPrefix(suffix, "1760600000123456789") || len(suffix) != 20 || Validate(suffix) != nil {
+		t.Errorf("expected a generated suffix with a check character, got %s", created.Identifier.ID)
+	}
+
+	// A supplied identifier must carry a valid check character
+	_, err = repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.12345/" + suffix[:19] + "0"}})
+	var idErr *IdentifierError
+	if !errors.As(err, &idErr) || !strings.Contains(err.Error(), "mistyped") {
+		t.Errorf("expected an IdentifierError for a supplied identifier, got %v", err)
+	}
+	if mock.CreateRAiDCalls != 1 {
+		t.Errorf("expected the invalid mint not to reach storage, got %d calls", mock.CreateRAiDCalls)
+	}
+}
+
+func TestWrap_Validate(t *testing.T) {
+	mock := testutil.NewMockRepository()
+	repo := Wrap(mock)
+	ctx := context.Background()
+
+	valid, _ := Append("1760600000123456789")
+	typo := valid[:3] + valid[4:5] + valid[3:4] + valid[5:]
+
+	if _, err := repo.GetRAiD(ctx, "10.12345", valid); err != nil {
+		t.Errorf("GetRAiD with a valid suffix: %v", err)
+	}
+
+	calls := []struct {
+		name string
+		call func(suffix string) error
+	}{
+		{"GetRAiD", func(s string) error { _, err := repo.GetRAiD(ctx, "10.12345", s); return err }},
+		{"GetRAiDVersion", func(s string) error { _, err := repo.GetRAiDVersion(ctx, "10.12345", s, 1); return err }},
+		{"GetRAiDHistory", func(s string) error { _, err := repo.GetRAiDHistory(ctx, "10.12345", s); return err }},
+		{"UpdateRAiD", func(s string) error { _, err := repo.UpdateRAiD(ctx, "10.12345", s, &models.RAiD{}); return err }},
+		{"DeleteRAiD", func(s string) error { return repo.DeleteRAiD(ctx, "10.12345", s) }},
+	}
+	for _, c := range calls {
+		var idErr *IdentifierError
+		if err := c.call(typo); !errors.As(err, &idErr) || !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s(%s): expected a check character mismatch, got %v", c.name, typo, err)
+		}
+	}
+	if mock.GetRAiDCalls != 1 || mock.UpdateRAiDCalls != 0 || mock.DeleteRAiDCalls != 0 {
+		t.Errorf("expected invalid identifiers not to reach storage")
+	}
+}
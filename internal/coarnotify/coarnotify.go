@@ -0,0 +1,238 @@
+// Package coarnotify exchanges COAR Notify notifications
+// (https://coar-notify.net) with repositories and other services over
+// Linked Data Notifications (LDN). Repositories announce outputs related to
+// a RAiD to the server's inbox, where they are held as proposals for the
+// RAiD's service point to accept; related objects added to RAiDs here are
+// announced to the inboxes of other services from the outbox.
+//
+// Notifications follow the Announce Relationship pattern: an Announce of a
+// Relationship between an as:subject and an as:object, one of which is a
+// RAiD.
+package coarnotify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/vocabulary"
+)
+
+const (
+	// ContentType is the media type of notifications
+	ContentType = "application/ld+json"
+	// InboxRel is the link relation of an LDN inbox
+	InboxRel = "http://www.w3.org/ns/ldp#inbox"
+
+	// ActivityStreamsContext and NotifyContext are the JSON-LD contexts of
+	// notifications
+	ActivityStreamsContext = "https://www.w3.org/ns/activitystreams"
+	NotifyContext          = "https://coar-notify.net"
+
+	// TypeAnnounce is the activity of announcements
+	TypeAnnounce = "Announce"
+	// TypeRelationshipAction marks an announcement of a relationship
+	TypeRelationshipAction = "coar-notify:RelationshipAction"
+	// TypeRelationship is the type of an announced relationship
+	TypeRelationship = "Relationship"
+)
+
+// ErrUnsupported is returned for notifications of patterns other than
+// Announce Relationship
+var ErrUnsupported = errors.New("only Announce Relationship notifications are supported")
+
+// Types is an ActivityStreams type, written as a string or an array
+type Types []string
+
+// UnmarshalJSON accepts a single type or an array of them
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or an array of strings")
+	}
+	*t = many
+	return nil
+}
+
+// Has reports whether t includes typ
+func (t Types) Has(typ string) bool {
+	return slices.Contains(t, typ)
+}
+
+// Service is the origin or target of a notification
+type Service struct {
+	ID    string `json:"id"`
+	Inbox string `json:"inbox,omitempty"`
+	Type  Types  `json:"type,omitempty"`
+}
+
+// Item describes the content of a resource, e.g. a PDF of an article
+type Item struct {
+	ID        string `json:"id"`
+	MediaType string `json:"mediaType,omitempty"`
+	Type      Types  `json:"type,omitempty"`
+}
+
+// Resource is the object or context of a notification. A Relationship
+// has Subject, Relationship and Object; other resources may be described
+// by their persistent identifier and content.
+type Resource struct {
+	ID           string `json:"id"`
+	Type         Types  `json:"type,omitempty"`
+	Subject      string `json:"as:subject,omitempty"`
+	Relationship string `json:"as:relationship,omitempty"`
+	Object       string `json:"as:object,omitempty"`
+	CiteAs       string `json:"ietf:cite-as,omitempty"`
+	Item         *Item  `json:"ietf:item,omitempty"`
+}
+
+// Notification is a COAR Notify notification, an ActivityStreams activity
+type Notification struct {
+	JSONLDContext []string  `json:"@context"`
+	ID            string    `json:"id"`
+	Type          Types     `json:"type"`
+	Origin        *Service  `json:"origin"`
+	Target        *Service  `json:"target"`
+	Actor         *Service  `json:"actor,omitempty"`
+	Object        *Resource `json:"object"`
+	Context       *Resource `json:"context,omitempty"`
+	InReplyTo     string    `json:"inReplyTo,omitempty"`
+}
+
+// Parse reads a notification, checking the properties every notification
+// has
+func Parse(data []byte) (*Notification, error) {
+	var raw struct {
+		Notification
+		// @context may be a single context too
+		JSONLDContext json.RawMessage `json:"@context"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid notification: %w", err)
+	}
+	n := &raw.Notification
+	switch {
+	case n.ID == "":
+		return nil, errors.New("notification has no id")
+	case len(n.Type) == 0:
+		return nil, errors.New("notification has no type")
+	case n.Origin == nil || n.Origin.ID == "" || n.Origin.Inbox == "":
+		return nil, errors.New("notification has no origin with an id and inbox")
+	case n.Object == nil:
+		return nil, errors.New("notification has no object")
+	}
+	return n, nil
+}
+
+// Relationship returns the relationship an Announce Relationship
+// notification announces
+func (n *Notification) Relationship() (*Resource, error) {
+	if !n.Type.Has(TypeAnnounce) || !n.Type.Has(TypeRelationshipAction) {
+		return nil, ErrUnsupported
+	}
+	rel := n.Object
+	if !rel.Type.Has(TypeRelationship) || rel.Subject == "" || rel.Relationship == "" || rel.Object == "" {
+		return nil, errors.New("object is not a Relationship with an as:subject, as:relationship and as:object")
+	}
+	return rel, nil
+}
+
+// categoryTerms are the related object categories by name
+var categoryTerms = map[string]int{"output": 190, "input": 191, "internal-process-document-or-artefact": 192}
+
+// itemTypes maps schema.org types of announced resources to related object
+// types
+var itemTypes = map[string]int{
+	"sorg:ScholarlyArticle":    250,
+	"sorg:Article":             250,
+	"sorg:Dataset":             269,
+	"sorg:SoftwareSourceCode":  259,
+	"sorg:SoftwareApplication": 259,
+	"sorg:Book":                258,
+	"sorg:Chapter":             271,
+	"sorg:Thesis":              253,
+	"sorg:Report":              252,
+	"sorg:ImageObject":         257,
+	"sorg:AudioObject":         261,
+	"sorg:VideoObject":         273,
+	"sorg:Event":               260,
+}
+
+// RelatedObject returns the related object announced for a RAiD by n: id
+// is the other end of its relationship. Its category is output, unless the
+// relationship is from the RAiD and is a related object category, as
+// announced by Announcement. Its type is taken from the schema.org types
+// of the object, its content or the notification's context.
+func (n *Notification) RelatedObject(id string) models.RelatedObject {
+	category := "output"
+	if rel := n.Object; rel != nil {
+		for name, number := range categoryTerms {
+			if rel.Relationship == categoryID(number) && rel.Object == id {
+				category = name
+			}
+		}
+	}
+	obj := models.RelatedObject{
+		ID:       id,
+		Category: []models.IDSchema{{ID: categoryID(categoryTerms[category]), SchemaURI: vocabulary.Base + "related-object.category.schema/385"}},
+	}
+
+	var types Types
+	for _, r := range []*Resource{n.Object, n.Context} {
+		if r == nil {
+			continue
+		}
+		types = append(types, r.Type...)
+		if r.Item != nil {
+			types = append(types, r.Item.Type...)
+		}
+	}
+	for _, t := range types {
+		if number, ok := itemTypes[t]; ok {
+			obj.Type = &models.IDSchema{
+				ID:        fmt.Sprintf("%srelated-object.type.schema/%d", vocabulary.Base, number),
+				SchemaURI: vocabulary.Base + "related-object.type.schema/329",
+			}
+			break
+		}
+	}
+	return obj
+}
+
+func categoryID(number int) string {
+	return fmt.Sprintf("%srelated-object.category.id/%d", vocabulary.Base, number)
+}
+
+// Announcement returns an Announce Relationship notification from origin
+// to target, of the relationship of a RAiD to one of its related objects.
+// The relationship is the object's category, output if it has none.
+func Announcement(id string, origin, target Service, raidID string, obj models.RelatedObject) *Notification {
+	relationship := categoryID(categoryTerms["output"])
+	if len(obj.Category) > 0 && strings.HasPrefix(obj.Category[0].ID, vocabulary.Base) {
+		relationship = obj.Category[0].ID
+	}
+	origin.Type, target.Type = Types{"Service"}, Types{"Service"}
+	return &Notification{
+		JSONLDContext: []string{ActivityStreamsContext, NotifyContext},
+		ID:            "urn:uuid:" + id,
+		Type:          Types{TypeAnnounce, TypeRelationshipAction},
+		Origin:        &origin,
+		Target:        &target,
+		Object: &Resource{
+			ID:           "urn:uuid:" + id + ":relationship",
+			Type:         Types{TypeRelationship},
+			Subject:      raidID,
+			Relationship: relationship,
+			Object:       obj.ID,
+		},
+		Context: &Resource{ID: obj.ID},
+	}
+}
@@ -0,0 +1,99 @@
+package coarnotify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestParse(t *testing.T) {
+	for name, data := range map[string]string{
+		"no id":     `{"type":"Announce","origin":{"id":"https://a","inbox":"https://a/inbox"},"object":{"id":"x"}}`,
+		"no origin": `{"id":"urn:uuid:1","type":"Announce","object":{"id":"x"}}`,
+		"no inbox":  `{"id":"urn:uuid:1","type":"Announce","origin":{"id":"https://a"},"object":{"id":"x"}}`,
+		"no object": `{"id":"urn:uuid:1","type":"Announce","origin":{"id":"https://a","inbox":"https://a/inbox"}}`,
+		"bad type":  `{"id":"urn:uuid:1","type":1,"origin":{"id":"https://a","inbox":"https://a/inbox"},"object":{"id":"x"}}`,
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	n, err := Parse([]byte(`{"@context":"https://www.w3.org/ns/activitystreams","id":"urn:uuid:1","type":"Offer",
+		"origin":{"id":"https://a","inbox":"https://a/inbox"},"object":{"id":"x"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := n.Relationship(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for an Offer, got %v", err)
+	}
+}
+
+func TestAnnouncement(t *testing.T) {
+	obj := models.RelatedObject{
+		ID:       "https://doi.org/10.5555/data",
+		Category: []models.IDSchema{{ID: categoryID(categoryTerms["input"])}},
+	}
+	sent := Announcement("1", Service{ID: "https://raid.example.org", Inbox: "https://raid.example.org/inbox"},
+		Service{ID: "https://repository.example.org"}, "https://raid.org/10.1/a", obj)
+	data, err := json.Marshal(sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back, the relationship gives the category
+	n, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := n.Relationship()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rel.Subject != "https://raid.org/10.1/a" || rel.Object != obj.ID {
+		t.Errorf("expected the RAiD to be the subject, got %+v", rel)
+	}
+	got := n.RelatedObject(rel.Object)
+	if got.ID != obj.ID || got.Category[0].ID != obj.Category[0].ID {
+		t.Errorf("expected %+v, got %+v", obj, got)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	var received *Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Add("Link", `<https://doi.org/10.5555/data>; rel="cite-as", </inbox>; rel="`+InboxRel+`"`)
+		case http.MethodPost:
+			if r.Header.Get("Content-Type") != ContentType {
+				http.Error(w, "not ld+json", http.StatusUnsupportedMediaType)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&received)
+			w.WriteHeader(http.StatusCreated)
+		}
+	}))
+	defer srv.Close()
+
+	o := NewOutbox("https://raid.example.org/", nil, true)
+	inbox, err := o.Discover(context.Background(), srv.URL+"/record/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inbox != srv.URL+"/inbox" {
+		t.Fatalf("expected the inbox to be discovered, got %q", inbox)
+	}
+
+	raid := &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.1/a"}}
+	o.Announce(context.Background(), raid, []models.RelatedObject{{ID: srv.URL + "/record/1"}})
+	o.Wait()
+	if received == nil || received.Object.Subject != raid.Identifier.ID || received.Origin.Inbox != "https://raid.example.org/inbox" {
+		t.Errorf("expected the discovered inbox to be notified, got %+v", received)
+	}
+}
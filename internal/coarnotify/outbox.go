@@ -0,0 +1,180 @@
+package coarnotify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// metrics counts notifications received and sent, published under
+// "coarnotify" in /debug/vars
+var metrics = expvar.NewMap("coarnotify")
+
+// Received counts a notification accepted into the inbox
+func Received() {
+	metrics.Add("received", 1)
+}
+
+// Outbox sends notifications to the inboxes of other services. Sending
+// happens in the background, so that slow or unreachable inboxes do not
+// hold up requests.
+type Outbox struct {
+	origin   Service
+	targets  []string
+	discover bool
+	client   *http.Client
+	sending  sync.WaitGroup
+}
+
+// NewOutbox creates an outbox sending as the service at baseURL, whose
+// inbox is baseURL/inbox, to the inboxes at targets. With discover, the
+// inbox of each related object is also discovered from the Link headers of
+// its URL and notified.
+func NewOutbox(baseURL string, targets []string, discover bool) *Outbox {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return &Outbox{
+		origin:   Service{ID: baseURL, Inbox: baseURL + "/inbox"},
+		targets:  targets,
+		discover: discover,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Announce announces the relationships of raid to related objects added to
+// it, to the configured inboxes and those discovered for each object.
+// Failures are logged.
+func (o *Outbox) Announce(ctx context.Context, raid *models.RAiD, added []models.RelatedObject) {
+	if raid.Identifier == nil || len(added) == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	o.sending.Add(1)
+	go func() {
+		defer o.sending.Done()
+		for _, obj := range added {
+			inboxes := o.targets
+			if o.discover {
+				inbox, err := o.Discover(ctx, obj.ID)
+				if err != nil {
+					log.Printf("Failed to discover the inbox of %s: %v", obj.ID, err)
+				} else if inbox != "" {
+					inboxes = append(inboxes[:len(inboxes):len(inboxes)], inbox)
+				}
+			}
+			for _, inbox := range inboxes {
+				n := Announcement(newUUID(), o.origin, Service{ID: targetID(inbox), Inbox: inbox}, raid.Identifier.ID, obj)
+				if err := o.Send(ctx, inbox, n); err != nil {
+					metrics.Add("failed", 1)
+					log.Printf("Failed to announce %s of %s to %s: %v", obj.ID, raid.Identifier.ID, inbox, err)
+					continue
+				}
+				metrics.Add("sent", 1)
+			}
+		}
+	}()
+}
+
+// Wait waits for the notifications being sent
+func (o *Outbox) Wait() {
+	o.sending.Wait()
+}
+
+// Send posts n to inbox
+func (o *Outbox) Send(ctx context.Context, inbox string, n *Notification) error {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, inbox, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("inbox responded %s", resp.Status)
+	}
+	return nil
+}
+
+// Discover returns the LDN inbox of the resource at target, from the Link
+// headers of a HEAD request following redirects, or "" if it has none
+func (o *Outbox) Discover(ctx context.Context, target string) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	for _, link := range resp.Header.Values("Link") {
+		if inbox := parseInboxLink(link); inbox != "" {
+			ref, err := resp.Request.URL.Parse(inbox)
+			if err != nil {
+				return "", err
+			}
+			return ref.String(), nil
+		}
+	}
+	return "", nil
+}
+
+// parseInboxLink returns the target of the inbox link in a Link header
+// value, if it has one
+func parseInboxLink(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(link), ";")
+		if !ok || !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "rel") && strings.Contains(" "+strings.Trim(value, `"`)+" ", " "+InboxRel+" ") {
+				return strings.Trim(target, "<>")
+			}
+		}
+	}
+	return ""
+}
+
+// targetID returns the ID of the service taking notifications at inbox,
+// its origin
+func targetID(inbox string) string {
+	u, err := url.Parse(inbox)
+	if err != nil || u.Host == "" {
+		return inbox
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
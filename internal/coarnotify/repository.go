@@ -0,0 +1,59 @@
+package coarnotify
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that announces the related objects mints and
+// updates add to RAiDs through outbox. Only public RAiDs are announced.
+func Wrap(repo storage.Repository, outbox *Outbox) storage.Repository {
+	return &repository{Repository: repo, outbox: outbox}
+}
+
+type repository struct {
+	storage.Repository
+	outbox *Outbox
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.announce(ctx, created, nil)
+	return created, nil
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	var before []models.RelatedObject
+	if current, err := r.Repository.GetRAiD(ctx, prefix, suffix); err == nil {
+		before = current.RelatedObject
+	}
+	updated, err := r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.announce(ctx, updated, before)
+	return updated, nil
+}
+
+// announce announces the related objects of raid not in before
+func (r *repository) announce(ctx context.Context, raid *models.RAiD, before []models.RelatedObject) {
+	if raid.Access == nil || raid.Access.Type == nil || raid.Access.Type.ID != storage.AccessTypeOpen {
+		return
+	}
+	known := make(map[string]bool, len(before))
+	for _, obj := range before {
+		known[obj.ID] = true
+	}
+	var added []models.RelatedObject
+	for _, obj := range raid.RelatedObject {
+		if !known[obj.ID] {
+			added = append(added, obj)
+		}
+	}
+	r.outbox.Announce(ctx, raid, added)
+}
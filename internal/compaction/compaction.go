@@ -0,0 +1,203 @@
+// Package compaction archives old RAiD versions on a schedule. Versions
+// beyond a configurable depth and age are stored as diffs against the next
+// newer version by backends implementing storage.VersionCompactor, which
+// rehydrate them transparently when read.
+package compaction
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+// ErrUnsupported is returned for storage backends that cannot archive
+// versions
+var ErrUnsupported = errors.New("storage backend does not support version compaction")
+
+// metrics exposes compaction outcomes under /debug/vars
+var metrics = expvar.NewMap("compaction")
+
+// Summary counts the work of one compaction run
+type Summary struct {
+	// RAiDs is the number of RAiDs with versions beyond the kept depth
+	RAiDs int `json:"raids"`
+	// Archived is the number of versions archived by the run
+	Archived int `json:"archived"`
+	// Failed is the number of RAiDs that could not be compacted
+	Failed int `json:"failed"`
+}
+
+// Status reports the compactor's state
+type Status struct {
+	Schedule     string    `json:"schedule"`
+	KeepVersions int       `json:"keepVersions"`
+	MinAge       string    `json:"minAge,omitempty"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"nextRun,omitempty"`
+	LastRun      time.Time `json:"lastRun,omitempty"`
+	LastError    string    `json:"lastError,omitempty"`
+	LastDuration string    `json:"lastDuration,omitempty"`
+	LastSummary  *Summary  `json:"lastSummary,omitempty"`
+	Successes    int64     `json:"successes"`
+	Failures     int64     `json:"failures"`
+}
+
+// Compactor archives old versions on a cron schedule
+type Compactor struct {
+	repo      storage.Repository
+	compactor storage.VersionCompactor
+	policy    storage.CompactionPolicy
+	schedule  cron.Schedule
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates a compactor for a standard five-field cron expression or
+// descriptor such as "@weekly". It returns ErrUnsupported if repo cannot
+// archive versions.
+func New(repo storage.Repository, spec string, policy storage.CompactionPolicy) (*Compactor, error) {
+	compactor, ok := repo.(storage.VersionCompactor)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid compaction schedule %q: %w", spec, err)
+	}
+	if policy.KeepVersions < 1 {
+		return nil, fmt.Errorf("compaction must keep at least the current version")
+	}
+
+	c := &Compactor{
+		repo:      repo,
+		compactor: compactor,
+		policy:    policy,
+		schedule:  schedule,
+	}
+	c.status.Schedule = spec
+	c.status.KeepVersions = policy.KeepVersions
+	if policy.MinAge > 0 {
+		c.status.MinAge = policy.MinAge.String()
+	}
+	return c, nil
+}
+
+// Run compacts on schedule until ctx is cancelled
+func (c *Compactor) Run(ctx context.Context) {
+	for {
+		next := c.schedule.Next(time.Now())
+		c.mu.Lock()
+		c.status.NextRun = next
+		c.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := c.RunNow(ctx); err != nil {
+			log.Printf("Scheduled compaction failed: %v", err)
+		}
+	}
+}
+
+// RunNow compacts every RAiD immediately. It returns an error if a run is
+// already in progress.
+func (c *Compactor) RunNow(ctx context.Context) (*Status, error) {
+	c.mu.Lock()
+	if c.status.Running {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("compaction already in progress")
+	}
+	c.status.Running = true
+	c.mu.Unlock()
+
+	start := time.Now()
+	summary, err := c.compact(ctx)
+
+	c.mu.Lock()
+	c.status.Running = false
+	c.status.LastRun = start
+	c.status.LastDuration = time.Since(start).String()
+	metrics.Set("lastDurationSeconds", floatVar(time.Since(start).Seconds()))
+	if err != nil {
+		c.status.LastError = err.Error()
+		c.status.Failures++
+		metrics.Add("failures", 1)
+	} else {
+		c.status.LastError = ""
+		c.status.LastSummary = summary
+		c.status.Successes++
+		metrics.Add("successes", 1)
+		metrics.Add("archivedVersions", int64(summary.Archived))
+	}
+	c.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Compaction archived %d versions of %d RAiDs (%d failed)", summary.Archived, summary.RAiDs, summary.Failed)
+
+	status := c.Status()
+	return &status, nil
+}
+
+// compact archives the versions of every RAiD, including deleted ones,
+// that has more versions than are kept. Failures of single RAiDs are logged
+// and counted so that one damaged history does not stop the run.
+func (c *Compactor) compact(ctx context.Context) (*Summary, error) {
+	raids, err := c.repo.ListRAiDs(ctx, &storage.RAiDFilter{IncludeDeleted: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list RAiDs: %w", err)
+	}
+
+	summary := &Summary{}
+	for _, raid := range raids {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if raid.Identifier == nil || raid.Identifier.Version <= c.policy.KeepVersions {
+			continue
+		}
+		prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil {
+			continue
+		}
+
+		summary.RAiDs++
+		n, err := c.compactor.CompactRAiD(ctx, prefix, suffix, c.policy)
+		if err != nil {
+			log.Printf("Failed to compact RAiD %s/%s: %v", prefix, suffix, err)
+			summary.Failed++
+			continue
+		}
+		summary.Archived += n
+	}
+	return summary, nil
+}
+
+// Status returns the compactor state
+func (c *Compactor) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+func floatVar(v float64) *expvar.Float {
+	f := new(expvar.Float)
+	f.Set(v)
+	return f
+}
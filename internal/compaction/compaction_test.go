@@ -0,0 +1,163 @@
+package compaction
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestCompactor_RunNowAndRehydrate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	repo, err := file.New(&file.Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	description := []models.Description{{Text: strings.Repeat("A long description that stays the same. ", 20)}}
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{
+		Identifier:  &models.Identifier{ID: "https://raid.org/10.99999/busy"},
+		Title:       []models.Title{{Text: "Version 1"}},
+		Description: description,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for v := 2; v <= 5; v++ {
+		if _, err := repo.UpdateRAiD(ctx, "10.99999", "busy", &models.RAiD{
+			Identifier:  &models.Identifier{ID: "https://raid.org/10.99999/busy"},
+			Title:       []models.Title{{Text: fmt.Sprintf("Version %d", v)}},
+			Description: description,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/quiet"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(repo, "@weekly", storage.CompactionPolicy{KeepVersions: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := c.RunNow(ctx)
+	if err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if want := (Summary{RAiDs: 1, Archived: 3}); *status.LastSummary != want {
+		t.Errorf("got summary %+v, want %+v", *status.LastSummary, want)
+	}
+
+	historyDir := filepath.Join(dir, "raids", "10.99999", ".history", "busy")
+	for v := 1; v <= 4; v++ {
+		data, err := os.ReadFile(filepath.Join(historyDir, fmt.Sprintf("v%d.json", v)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := storage.IsArchived(data), v <= 3; got != want {
+			t.Errorf("version %d archived = %t, want %t", v, got, want)
+		}
+	}
+
+	// Archived versions read as before
+	for v := 1; v <= 5; v++ {
+		raid, err := repo.GetRAiDVersion(ctx, "10.99999", "busy", v)
+		if err != nil {
+			t.Fatalf("version %d: %v", v, err)
+		}
+		if raid.Identifier.Version != v || raid.Title[0].Text != fmt.Sprintf("Version %d", v) {
+			t.Errorf("version %d read back as version %d %q", v, raid.Identifier.Version, raid.Title[0].Text)
+		}
+	}
+	history, err := repo.GetRAiDHistory(ctx, "10.99999", "busy")
+	if err != nil || len(history) != 5 || history[4].Title[0].Text != "Version 1" {
+		t.Errorf("unexpected history: %d versions, %v", len(history), err)
+	}
+	if report, err := repo.Verify(ctx, storage.VerifyOptions{}); err != nil || len(report.Issues) != 0 {
+		t.Errorf("expected archived versions to verify, got %+v, %v", report, err)
+	}
+
+	// A second run finds nothing left to archive
+	if status, err := c.RunNow(ctx); err != nil || status.LastSummary.Archived != 0 {
+		t.Errorf("expected nothing archived on the second run, got %+v, %v", status, err)
+	}
+
+	n, err := repo.RehydrateRAiD(ctx, "10.99999", "busy")
+	if err != nil || n != 3 {
+		t.Fatalf("RehydrateRAiD = %d, %v; want 3", n, err)
+	}
+	data, _ := os.ReadFile(filepath.Join(historyDir, "v1.json"))
+	if storage.IsArchived(data) {
+		t.Error("expected version 1 to be a full document again")
+	}
+	raid, err := repo.GetRAiDVersion(ctx, "10.99999", "busy", 1)
+	if err != nil || raid.Title[0].Text != "Version 1" {
+		t.Errorf("unexpected rehydrated version 1: %+v, %v", raid, err)
+	}
+}
+
+func TestCompactor_MinAge(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for v := 1; v <= 3; v++ {
+		raid := &models.RAiD{
+			Identifier:  &models.Identifier{ID: "https://raid.org/10.99999/fresh"},
+			Title:       []models.Title{{Text: fmt.Sprintf("Version %d", v)}},
+			Description: []models.Description{{Text: strings.Repeat("x", 500)}},
+		}
+		if v == 1 {
+			_, err = repo.CreateRAiD(ctx, raid)
+		} else {
+			_, err = repo.UpdateRAiD(ctx, "10.99999", "fresh", raid)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c, err := New(repo, "@weekly", storage.CompactionPolicy{KeepVersions: 1, MinAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, err := c.RunNow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.LastSummary.Archived != 0 {
+		t.Errorf("expected versions written just now to be kept, got %+v", status.LastSummary)
+	}
+}
+
+func TestNew(t *testing.T) {
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if _, err := New(testutil.NewMockRepository(), "@daily", storage.CompactionPolicy{KeepVersions: 1}); err != ErrUnsupported {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	if _, err := New(repo, "every tuesday", storage.CompactionPolicy{KeepVersions: 1}); err == nil {
+		t.Error("expected error for invalid cron expression")
+	}
+	if _, err := New(repo, "@daily", storage.CompactionPolicy{}); err == nil {
+		t.Error("expected error for a policy keeping no versions")
+	}
+}
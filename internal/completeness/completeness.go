@@ -0,0 +1,64 @@
+// Package completeness scores RAiDs against a rubric of metadata a
+// well-curated RAiD has, so that service points can find the records most
+// in need of curation.
+package completeness
+
+import (
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Criterion is one item of the rubric
+type Criterion struct {
+	// Name identifies the criterion in scores, e.g. "description"
+	Name string
+	// Weight is the criterion's share of the score relative to the others
+	Weight int
+	// Met reports whether a RAiD meets the criterion
+	Met func(*models.RAiD) bool
+}
+
+// Rubric is the rubric RAiDs are scored against
+var Rubric = []Criterion{
+	{"primaryTitle", 1, func(r *models.RAiD) bool {
+		for _, t := range r.Title {
+			if t.Type != nil && t.Type.ID == models.TitleTypePrimary {
+				return true
+			}
+		}
+		return false
+	}},
+	{"description", 2, func(r *models.RAiD) bool { return len(r.Description) > 0 }},
+	{"endDate", 1, func(r *models.RAiD) bool { return r.Date != nil && r.Date.EndDate != "" }},
+	{"contributor", 2, func(r *models.RAiD) bool { return len(r.Contributor) > 0 }},
+	{"organisation", 2, func(r *models.RAiD) bool { return len(r.Organisation) > 0 }},
+	{"subject", 2, func(r *models.RAiD) bool { return len(r.Subject) > 0 }},
+	{"relatedObject", 2, func(r *models.RAiD) bool { return len(r.RelatedObject) > 0 }},
+	{"relatedRaid", 1, func(r *models.RAiD) bool { return len(r.RelatedRAiD) > 0 }},
+	{"alternateUrl", 1, func(r *models.RAiD) bool { return len(r.AlternateURL) > 0 }},
+	{"spatialCoverage", 1, func(r *models.RAiD) bool { return len(r.SpatialCoverage) > 0 }},
+	{"openAccess", 2, func(r *models.RAiD) bool {
+		return r.Access != nil && r.Access.Type != nil && r.Access.Type.ID == storage.AccessTypeOpen
+	}},
+}
+
+// Score scores raid against Rubric. The score is the weighted share of
+// criteria met, from 0 to 100.
+func Score(raid *models.RAiD) *models.Completeness {
+	c := &models.Completeness{Met: []string{}, Missing: []string{}}
+	var met, total int
+	for _, criterion := range Rubric {
+		total += criterion.Weight
+		if criterion.Met(raid) {
+			met += criterion.Weight
+			c.Met = append(c.Met, criterion.Name)
+		} else {
+			c.Missing = append(c.Missing, criterion.Name)
+		}
+	}
+	if raid.Identifier != nil {
+		c.ID = raid.Identifier.ID
+	}
+	c.Score = met * 100 / total
+	return c
+}
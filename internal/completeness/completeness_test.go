@@ -0,0 +1,27 @@
+package completeness
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestScore(t *testing.T) {
+	bare := Score(&models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"}})
+	if bare.Score != 0 || len(bare.Met) != 0 || len(bare.Missing) != len(Rubric) || bare.ID != "https://raid.org/10.99999/a" {
+		t.Errorf("expected a bare RAiD to miss everything, got %+v", bare)
+	}
+
+	partial := Score(&models.RAiD{
+		Description: []models.Description{{Text: "About"}},
+		Subject:     []models.Subject{{ID: "https://linked.data.gov.au/def/anzsrc-for/2020/3107"}},
+		Access:      &models.Access{Type: &models.IDSchema{ID: storage.AccessTypeOpen}},
+		Date:        &models.Date{StartDate: "2024-01-01"},
+	})
+	// 6 of 17 weight points
+	if partial.Score != 35 || !slices.Equal(partial.Met, []string{"description", "subject", "openAccess"}) || slices.Contains(partial.Missing, "subject") {
+		t.Errorf("unexpected partial score: %+v", partial)
+	}
+}
@@ -1,106 +1,1538 @@
 package config
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
+	"github.com/leifj/go-raid/internal/access"
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/attachment"
+	"github.com/leifj/go-raid/internal/faults"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/notify"
+	"github.com/leifj/go-raid/internal/policy"
+	"github.com/leifj/go-raid/internal/prefix"
+	"github.com/leifj/go-raid/internal/secrets"
 	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+	"github.com/leifj/go-raid/internal/vocabulary"
+	"gopkg.in/yaml.v3"
 )
 
 // Config holds application configuration
 type Config struct {
-	Server  ServerConfig
-	Storage storage.StorageConfig
-	Auth    AuthConfig
+	Server      ServerConfig          `yaml:"server" toml:"server"`
+	Storage     storage.StorageConfig `yaml:"storage" toml:"storage"`
+	Auth        AuthConfig            `yaml:"auth" toml:"auth"`
+	Backup      BackupConfig          `yaml:"backup" toml:"backup"`
+	Compaction  CompactionConfig      `yaml:"compaction" toml:"compaction"`
+	Retention   RetentionConfig       `yaml:"retention" toml:"retention"`
+	History     HistoryConfig         `yaml:"history" toml:"history"`
+	Resilience  ResilienceConfig      `yaml:"resilience" toml:"resilience"`
+	Faults      FaultsConfig          `yaml:"faults" toml:"faults"`
+	Cache       CacheConfig           `yaml:"cache" toml:"cache"`
+	RateLimit   RateLimitConfig       `yaml:"rateLimit" toml:"rateLimit"`
+	AccessLog   AccessLogConfig       `yaml:"accessLog" toml:"accessLog"`
+	Identifiers IdentifierConfig      `yaml:"identifiers" toml:"identifiers"`
+	Relations   RelationConfig        `yaml:"relations" toml:"relations"`
+	COARNotify  COARNotifyConfig      `yaml:"coarNotify" toml:"coarNotify"`
+	ActivityPub ActivityPubConfig     `yaml:"activityPub" toml:"activityPub"`
+	Attachments AttachmentConfig      `yaml:"attachments" toml:"attachments"`
+	Languages   LanguageConfig        `yaml:"languages" toml:"languages"`
+	Invitations InvitationConfig      `yaml:"invitations" toml:"invitations"`
+	Publication PublicationConfig     `yaml:"publication" toml:"publication"`
+	Signing     SigningConfig         `yaml:"signing" toml:"signing"`
+	// Notifications configures email about RAiD events; templates can
+	// only be set in the configuration file
+	Notifications NotificationConfig `yaml:"notifications" toml:"notifications"`
+	// Access configures which access type changes updates may make
+	Access access.Policy `yaml:"access" toml:"access"`
+	// Vocabularies configures the vocabularies RAiD metadata is checked
+	// against; it can only be set in the configuration file
+	Vocabularies VocabularyConfig `yaml:"vocabularies" toml:"vocabularies"`
+	// Agencies lists the registration agencies hosted by the deployment;
+	// they can only be set in the configuration file
+	Agencies []agency.Agency `yaml:"agencies" toml:"agencies"`
+	// Policies are rules RAiD mints and updates must meet; they can only
+	// be set in the configuration file
+	Policies []policy.Policy `yaml:"policies" toml:"policies"`
 }
 
 // ServerConfig holds HTTP server configuration
 type ServerConfig struct {
-	Host string
-	Port int
+	Host string `yaml:"host" toml:"host"`
+	Port int    `yaml:"port" toml:"port"`
+	// GRPCHealthPort serves the gRPC health checking protocol over
+	// cleartext HTTP/2 on its own port; 0 disables it
+	GRPCHealthPort int `yaml:"grpcHealthPort" toml:"grpcHealthPort"`
+	// ReusePort binds the listening sockets with SO_REUSEPORT, so that
+	// another instance can bind the same port while this one runs
+	ReusePort bool `yaml:"reusePort" toml:"reusePort"`
+	// MaxBodyBytes caps request body size; 0 disables the limit
+	MaxBodyBytes int64 `yaml:"maxBodyBytes" toml:"maxBodyBytes"`
+	// MaxRAiDBytes caps the size of a RAiD document minted or updated, below
+	// MaxBodyBytes; 0 applies only MaxBodyBytes
+	MaxRAiDBytes int64 `yaml:"maxRaidBytes" toml:"maxRaidBytes"`
+	// MaxRelatedObjects caps the related objects of a RAiD document minted
+	// or updated; 0 disables the limit
+	MaxRelatedObjects int `yaml:"maxRelatedObjects" toml:"maxRelatedObjects"`
+	// StrictDecoding rejects minted and updated RAiD documents with
+	// properties the schema does not define, other than x-... extensions,
+	// with 400 instead of keeping them
+	StrictDecoding bool `yaml:"strictDecoding" toml:"strictDecoding"`
+	// ReadHeaderTimeout, ReadTimeout, WriteTimeout and IdleTimeout are
+	// passed to http.Server; 0 disables the timeout
+	ReadHeaderTimeout time.Duration `yaml:"readHeaderTimeout" toml:"readHeaderTimeout"`
+	ReadTimeout       time.Duration `yaml:"readTimeout" toml:"readTimeout"`
+	WriteTimeout      time.Duration `yaml:"writeTimeout" toml:"writeTimeout"`
+	IdleTimeout       time.Duration `yaml:"idleTimeout" toml:"idleTimeout"`
+	// ReadRouteTimeout and WriteRouteTimeout bound handler execution for
+	// API reads (GET) and writes (POST/PUT/PATCH); 0 disables the timeout
+	ReadRouteTimeout  time.Duration `yaml:"readRouteTimeout" toml:"readRouteTimeout"`
+	WriteRouteTimeout time.Duration `yaml:"writeRouteTimeout" toml:"writeRouteTimeout"`
+	// ReadOnly starts the server in maintenance mode, rejecting writes with
+	// 503; it can be toggled at runtime through the admin API
+	ReadOnly           bool          `yaml:"readOnly" toml:"readOnly"`
+	ReadOnlyReason     string        `yaml:"readOnlyReason" toml:"readOnlyReason"`
+	ReadOnlyRetryAfter time.Duration `yaml:"readOnlyRetryAfter" toml:"readOnlyRetryAfter"`
+	// ValidateRequests rejects requests to the RAiD API that do not match
+	// the OpenAPI document with 400, before they reach the handlers
+	ValidateRequests bool `yaml:"validateRequests" toml:"validateRequests"`
+	// ValidateResponses logs RAiD API responses that do not match the
+	// OpenAPI document; it buffers responses and is meant for development
+	ValidateResponses bool `yaml:"validateResponses" toml:"validateResponses"`
+	// ListEnvelope wraps list responses in an envelope with page metadata
+	// and links by default; clients can ask for either format with an
+	// Accept profile
+	ListEnvelope bool `yaml:"listEnvelope" toml:"listEnvelope"`
+	// UnversionedDeprecation and UnversionedSunset are the dates (YYYY-MM-DD
+	// or RFC 3339) announced in the Deprecation and Sunset headers of the
+	// unversioned API paths, which serve the same API as /v2; empty omits
+	// the header
+	UnversionedDeprecation string `yaml:"unversionedDeprecation" toml:"unversionedDeprecation"`
+	UnversionedSunset      string `yaml:"unversionedSunset" toml:"unversionedSunset"`
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
-	JWTSecret string
+	// JWTSecret is the HMAC signing secret, or a secret reference
+	// (file:, env:, vault:, awssm:, gcpsm:) resolved at load time
+	JWTSecret   string `yaml:"jwtSecret" toml:"jwtSecret"`
+	JWTIssuer   string `yaml:"jwtIssuer" toml:"jwtIssuer"`
+	JWTAudience string `yaml:"jwtAudience" toml:"jwtAudience"`
 	// For future OAuth2/OIDC integration
-	Enabled bool
+	Enabled bool `yaml:"enabled" toml:"enabled"`
 }
 
-// Load loads configuration from environment variables
+// BackupConfig holds scheduled backup configuration
+type BackupConfig struct {
+	// Schedule is a cron expression ("0 2 * * *") or descriptor ("@daily");
+	// empty disables scheduled backups
+	Schedule string `yaml:"schedule" toml:"schedule"`
+	// Target is "local" or "s3"
+	Target string         `yaml:"target" toml:"target"`
+	Dir    string         `yaml:"dir" toml:"dir"`
+	S3     BackupS3Config `yaml:"s3" toml:"s3"`
+	// RetentionCount keeps the newest N backups; 0 keeps all
+	RetentionCount int `yaml:"retentionCount" toml:"retentionCount"`
+	// RetentionMaxAge removes backups older than this; 0 disables
+	RetentionMaxAge time.Duration `yaml:"retentionMaxAge" toml:"retentionMaxAge"`
+}
+
+// CompactionConfig holds scheduled compaction of old RAiD versions
+type CompactionConfig struct {
+	// Schedule is a cron expression ("0 3 * * 0") or descriptor ("@weekly");
+	// empty disables compaction
+	Schedule string `yaml:"schedule" toml:"schedule"`
+	// KeepVersions is the number of newest versions, including the current
+	// one, kept as full documents; older ones are stored as diffs
+	KeepVersions int `yaml:"keepVersions" toml:"keepVersions"`
+	// MinAge keeps versions younger than this as full documents; 0 disables
+	MinAge time.Duration `yaml:"minAge" toml:"minAge"`
+}
+
+// RetentionConfig holds scheduled removal of data older than its retention
+// window. The windows apply to all service points; Rules can set windows
+// for single service points as well.
+type RetentionConfig struct {
+	// Schedule is a cron expression ("0 4 * * *") or descriptor ("@daily");
+	// empty disables retention
+	Schedule string `yaml:"schedule" toml:"schedule"`
+	// PurgeDeletedAfter is how long a RAiD stays soft deleted before it is
+	// purged; 0 keeps deleted RAiDs
+	PurgeDeletedAfter time.Duration `yaml:"purgeDeletedAfter" toml:"purgeDeletedAfter"`
+	// AccessLogAfter is how long stored access log entries are kept; 0
+	// keeps them
+	AccessLogAfter time.Duration `yaml:"accessLogAfter" toml:"accessLogAfter"`
+	// ReservationsAfter is how long a reservation is kept from when it
+	// was made, even if it has not expired; 0 leaves them to expire
+	ReservationsAfter time.Duration `yaml:"reservationsAfter" toml:"reservationsAfter"`
+	// AuditAfter is how long the records of purges and redactions are
+	// kept; 0 keeps them
+	AuditAfter time.Duration `yaml:"auditAfter" toml:"auditAfter"`
+	// DryRun reports what runs would remove without removing it
+	DryRun bool `yaml:"dryRun" toml:"dryRun"`
+	// Rules sets windows per service point, over those above. They can
+	// only be set in the configuration file.
+	Rules []RetentionRule `yaml:"rules" toml:"rules"`
+}
+
+// RetentionRule keeps one kind of data for a window
+type RetentionRule struct {
+	// Data is "deleted", "access-log", "reservations" or "audit"
+	Data string `yaml:"data" toml:"data"`
+	// ServicePoint limits the rule to one service point; 0 applies it to
+	// all, in place of the window set for the data above
+	ServicePoint int64 `yaml:"servicePoint" toml:"servicePoint"`
+	// After is the window data is kept for
+	After time.Duration `yaml:"after" toml:"after"`
+}
+
+// HistoryConfig holds anchoring of the hash chains of RAiD versions
+type HistoryConfig struct {
+	// AnchorSchedule is a cron expression or descriptor ("@daily") to
+	// anchor the chain heads of all RAiDs on; empty disables anchoring
+	AnchorSchedule string `yaml:"anchorSchedule" toml:"anchorSchedule"`
+	// AnchorFile is the file anchors are appended to and verified
+	// against, ideally outside the storage backend and its backups
+	AnchorFile string `yaml:"anchorFile" toml:"anchorFile"`
+}
+
+// SigningConfig holds signing of RAiD responses and backup archives
+type SigningConfig struct {
+	// Key is the PEM-encoded PKCS #8 Ed25519 private key responses and
+	// archives are signed with, or a secret reference resolved at load
+	// time; empty disables signing
+	Key string `yaml:"key" toml:"key"`
+	// KeyID identifies the key in signatures and the published key set;
+	// empty means the key's JWK thumbprint
+	KeyID string `yaml:"keyId" toml:"keyId"`
+	// Format is how responses are signed: "jws" for a detached JWS in the
+	// X-JWS-Signature header (default), or "http" for HTTP message
+	// signatures (RFC 9421)
+	Format string `yaml:"format" toml:"format"`
+}
+
+// ResilienceConfig holds retries and the circuit breaker around storage
+// calls made by the API
+type ResilienceConfig struct {
+	// MaxRetries is how often a call failing with a transient error, such
+	// as a CockroachDB serialization failure, is retried; 0 disables retries
+	MaxRetries int `yaml:"maxRetries" toml:"maxRetries"`
+	// InitialBackoff is the wait before the first retry, doubled for each
+	// further retry up to MaxBackoff
+	InitialBackoff time.Duration `yaml:"initialBackoff" toml:"initialBackoff"`
+	MaxBackoff     time.Duration `yaml:"maxBackoff" toml:"maxBackoff"`
+	// BreakerThreshold is the number of consecutive backend failures that
+	// open the circuit breaker; 0 disables the breaker
+	BreakerThreshold int `yaml:"breakerThreshold" toml:"breakerThreshold"`
+	// BreakerOpenTimeout is how long an open breaker fails requests fast
+	// before letting a trial request through
+	BreakerOpenTimeout time.Duration `yaml:"breakerOpenTimeout" toml:"breakerOpenTimeout"`
+}
+
+// FaultsConfig injects storage failures into the calls made by the API,
+// beneath retries and the circuit breaker, for resilience testing. It must
+// not be enabled on instances serving production traffic.
+type FaultsConfig struct {
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Latency is added to every call, plus a random share of Jitter
+	Latency time.Duration `yaml:"latency" toml:"latency"`
+	Jitter  time.Duration `yaml:"jitter" toml:"jitter"`
+	// ErrorPercent is the share of calls, 0 to 100, failing with a
+	// transient error before they reach the backend
+	ErrorPercent int `yaml:"errorPercent" toml:"errorPercent"`
+	// PartialPercent is the share of successful writes, 0 to 100, that
+	// are reported as failed
+	PartialPercent int `yaml:"partialPercent" toml:"partialPercent"`
+	// Operations limits faults to these repository methods, such as
+	// CreateRAiD; empty for all
+	Operations []string `yaml:"operations" toml:"operations"`
+}
+
+// CacheConfig holds the read-through cache of RAiDs and service points
+// served by the API
+type CacheConfig struct {
+	// Store is "memory", "redis" or empty to disable the cache
+	Store string `yaml:"store" toml:"store"`
+	// RedisURL is the Redis server of the redis store (redis:// or
+	// rediss://, or a secret reference)
+	RedisURL    string `yaml:"redisUrl" toml:"redisUrl"`
+	RedisPrefix string `yaml:"redisPrefix" toml:"redisPrefix"`
+	// MaxEntries bounds the memory store
+	MaxEntries int `yaml:"maxEntries" toml:"maxEntries"`
+	// TTL bounds how long other instances and admin writes other than
+	// purges and redactions go unseen
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+	// NegativeTTL is how long missing RAiDs and service points are
+	// remembered; 0 does not cache misses
+	NegativeTTL time.Duration `yaml:"negativeTtl" toml:"negativeTtl"`
+}
+
+// BackupS3Config holds the S3 backup target; credentials come from AWS_*
+type BackupS3Config struct {
+	Bucket   string `yaml:"bucket" toml:"bucket"`
+	Prefix   string `yaml:"prefix" toml:"prefix"`
+	Region   string `yaml:"region" toml:"region"`
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+}
+
+// RateLimitConfig holds request rate limiting configuration. Per-caller
+// limits are requests per minute for each route class with a burst
+// allowance; a limit of 0 disables that class.
+type RateLimitConfig struct {
+	Enabled        bool `yaml:"enabled" toml:"enabled"`
+	ReadPerMinute  int  `yaml:"readPerMinute" toml:"readPerMinute"`
+	ReadBurst      int  `yaml:"readBurst" toml:"readBurst"`
+	WritePerMinute int  `yaml:"writePerMinute" toml:"writePerMinute"`
+	WriteBurst     int  `yaml:"writeBurst" toml:"writeBurst"`
+	AdminPerMinute int  `yaml:"adminPerMinute" toml:"adminPerMinute"`
+	AdminBurst     int  `yaml:"adminBurst" toml:"adminBurst"`
+	// GlobalPerSecond limits all callers together; 0 disables
+	GlobalPerSecond int `yaml:"globalPerSecond" toml:"globalPerSecond"`
+	GlobalBurst     int `yaml:"globalBurst" toml:"globalBurst"`
+	// RedisURL shares limits between instances (redis:// or rediss://, or a
+	// secret reference); empty keeps them in memory per instance
+	RedisURL    string `yaml:"redisUrl" toml:"redisUrl"`
+	RedisPrefix string `yaml:"redisPrefix" toml:"redisPrefix"`
+	// TrustProxy identifies anonymous callers by X-Forwarded-For
+	TrustProxy bool `yaml:"trustProxy" toml:"trustProxy"`
+}
+
+// AccessLogConfig holds structured access log configuration
+type AccessLogConfig struct {
+	// Sink is "file", "storage" or empty to disable the access log
+	Sink string `yaml:"sink" toml:"sink"`
+	// File is the log path for the file sink
+	File string `yaml:"file" toml:"file"`
+	// MaxSizeMB rotates the file when it reaches this size; 0 disables
+	// rotation
+	MaxSizeMB int `yaml:"maxSizeMB" toml:"maxSizeMB"`
+	// MaxBackups is the number of rotated files kept
+	MaxBackups int `yaml:"maxBackups" toml:"maxBackups"`
+	// Export streams entries as audit events to a SIEM collector, in
+	// addition to the sink
+	Export AccessLogExportConfig `yaml:"export" toml:"export"`
+}
+
+// AccessLogExportConfig holds audit event export configuration
+type AccessLogExportConfig struct {
+	// Target is "syslog", "http" or empty to disable the export
+	Target string `yaml:"target" toml:"target"`
+	// Address is a udp://, tcp:// or unix:// URL for syslog, or the
+	// collector URL events are posted to for http
+	Address string `yaml:"address" toml:"address"`
+	// Format is "cef", "ocsf" or "json"
+	Format string `yaml:"format" toml:"format"`
+	// Authorization is sent as the Authorization header to http
+	// collectors, e.g. "Splunk <token>"
+	Authorization string `yaml:"authorization" toml:"authorization"`
+	// Fields maps access log fields to the keys of the format, over its
+	// defaults; an empty key leaves a field out. It can only be set in
+	// the configuration file.
+	Fields map[string]string `yaml:"fields" toml:"fields"`
+}
+
+// IdentifierConfig holds RAiD identifier configuration
+type IdentifierConfig struct {
+	// CheckDigit appends an ISO 7064 MOD 37-2 check character to generated
+	// suffixes and rejects identifiers whose check character does not match
+	CheckDigit bool `yaml:"checkDigit" toml:"checkDigit"`
+	// BaseURL is the URL minted identifiers start with, e.g.
+	// https://doi.org/ or https://raid.example.org/id/; empty means
+	// https://raid.org/
+	BaseURL string `yaml:"baseUrl" toml:"baseUrl"`
+	// Suffix picks how suffixes are minted: "counter" (the default) or
+	// "ulid", whose suffixes sort by mint time
+	Suffix string `yaml:"suffix" toml:"suffix"`
+	// AllocatedPrefixes are the handle prefixes allocated to the
+	// registration agency; service points may then only own these or
+	// prefixes derived from them. Hosted agencies list their own.
+	AllocatedPrefixes []string `yaml:"allocatedPrefixes" toml:"allocatedPrefixes"`
+	// ReservationTTL is how long POST /raid/reserve holds an identifier
+	// for the mint that supplies it (default 24 hours)
+	ReservationTTL time.Duration `yaml:"reservationTtl" toml:"reservationTtl"`
+}
+
+// RelationConfig holds configuration of relations between RAiDs
+type RelationConfig struct {
+	// Reciprocal adds the inverse of each relatedRaid link to the related
+	// RAiD when both are held by this instance
+	Reciprocal bool `yaml:"reciprocal" toml:"reciprocal"`
+}
+
+// COARNotifyConfig holds configuration of the COAR Notify inbox and outbox
+type COARNotifyConfig struct {
+	// Inbox takes announcements of outputs related to RAiDs at POST /inbox,
+	// holding them as proposals for the owning service point to accept
+	Inbox bool `yaml:"inbox" toml:"inbox"`
+	// BaseURL is the public URL of the server, the origin of the
+	// notifications it sends; sending is disabled without it
+	BaseURL string `yaml:"baseUrl" toml:"baseUrl"`
+	// Targets are the inboxes announcements of related objects added to
+	// public RAiDs are sent to
+	Targets []string `yaml:"targets" toml:"targets"`
+	// Discover also sends announcements to the inbox each related object
+	// advertises in its Link headers
+	Discover bool `yaml:"discover" toml:"discover"`
+}
+
+// ActivityPubConfig holds publication of registry activity as
+// ActivityStreams
+type ActivityPubConfig struct {
+	// BaseURL is the public URL of the server, under which the actor and
+	// its outbox are published; empty disables publication
+	BaseURL string `yaml:"baseUrl" toml:"baseUrl"`
+	// Username is the actor's name in WebFinger lookups (default "raid")
+	Username string `yaml:"username" toml:"username"`
+	// Key is the PEM-encoded RSA private key the actor signs deliveries
+	// with, or a secret reference resolved at load time; with it the actor
+	// takes follows and delivers activities to its followers
+	Key string `yaml:"key" toml:"key"`
+}
+
+// AttachmentConfig holds documents attached to RAiDs
+type AttachmentConfig struct {
+	// Store is where attachment content is kept: "backend" for the storage
+	// backend or "s3"; empty disables attachments
+	Store string `yaml:"store" toml:"store"`
+	// MaxBytes caps the size of an attachment; service points may set a
+	// smaller cap
+	MaxBytes int64 `yaml:"maxBytes" toml:"maxBytes"`
+	// MediaTypes lists the media types accepted (application/pdf, text/*)
+	// unless a service point lists its own; empty accepts any
+	MediaTypes []string `yaml:"mediaTypes" toml:"mediaTypes"`
+	// ScanURL is an HTTP service uploads are posted to before they are
+	// accepted, e.g. a virus scanner: 2xx accepts, 4xx refuses; empty
+	// disables scanning
+	ScanURL     string             `yaml:"scanUrl" toml:"scanUrl"`
+	ScanTimeout time.Duration      `yaml:"scanTimeout" toml:"scanTimeout"`
+	S3          AttachmentS3Config `yaml:"s3" toml:"s3"`
+}
+
+// AttachmentS3Config holds the S3 bucket of attachment content;
+// credentials come from AWS_*
+type AttachmentS3Config struct {
+	Bucket   string `yaml:"bucket" toml:"bucket"`
+	Prefix   string `yaml:"prefix" toml:"prefix"`
+	Region   string `yaml:"region" toml:"region"`
+	Endpoint string `yaml:"endpoint" toml:"endpoint"`
+}
+
+// LanguageConfig holds configuration of title and description languages
+type LanguageConfig struct {
+	// Detect guesses the language of titles and descriptions given
+	// without one, marking it autoDetected
+	Detect bool `yaml:"detect" toml:"detect"`
+	// Messages is a directory of message catalogs translating API error
+	// messages, one <ISO 639-3 code>.json per language, adding to and
+	// overriding the built-in ones
+	Messages string `yaml:"messages" toml:"messages"`
+}
+
+// InvitationConfig holds configuration of contributor invitations
+type InvitationConfig struct {
+	// Secret signs invitation links, or is a secret reference resolved at
+	// load time; empty means a key derived from the JWT secret. With
+	// neither set, invitations are disabled.
+	Secret string `yaml:"secret" toml:"secret"`
+	// TTL is how long invitation links are valid (default 14 days)
+	TTL time.Duration `yaml:"ttl" toml:"ttl"`
+}
+
+// PublicationConfig holds the scheduler applying changes scheduled to take
+// effect later
+type PublicationConfig struct {
+	// Interval is how often due changes are applied (default 1 minute); 0
+	// leaves applying them to other instances
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+}
+
+// NotificationConfig holds email notifications of RAiD events
+type NotificationConfig struct {
+	// SMTPHost is the mail server notifications are submitted to; empty
+	// disables notifications
+	SMTPHost string `yaml:"smtpHost" toml:"smtpHost"`
+	SMTPPort int    `yaml:"smtpPort" toml:"smtpPort"`
+	// Username and Password authenticate to the mail server if Username
+	// is set; Password may be a secret reference resolved at load time
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+	// From is the sender address
+	From string `yaml:"from" toml:"from"`
+	// EmbargoNotice is how long before an embargo expires its service
+	// point is warned (default 14 days)
+	EmbargoNotice time.Duration `yaml:"embargoNotice" toml:"embargoNotice"`
+	// QuotaThreshold is the percentage of its quota a service point is
+	// warned at (default 90); 0 disables the warning
+	QuotaThreshold int `yaml:"quotaThreshold" toml:"quotaThreshold"`
+	// InvitationURL is the link sent to invited contributors, with
+	// {token} replaced by the invitation token; empty means the API's
+	// invitation path on the host the invitation was made through
+	InvitationURL string `yaml:"invitationUrl" toml:"invitationUrl"`
+	// Templates maps events (invitation, transfer, embargo, quota) to
+	// template files replacing the built-in ones
+	Templates map[string]string `yaml:"templates" toml:"templates"`
+}
+
+// VocabularyConfig holds configuration of controlled vocabularies
+type VocabularyConfig struct {
+	// SubjectSchemes lists the subject classification schemes subject IDs
+	// must come from; empty means ANZSRC 2020 FoR and SEO
+	SubjectSchemes []vocabulary.SubjectScheme `yaml:"subjectSchemes" toml:"subjectSchemes"`
+}
+
+// Load loads configuration from the file named by CONFIG_FILE (if set),
+// then applies environment variable overrides and validates the result
 func Load() (*Config, error) {
-	port, err := strconv.Atoi(getEnv("SERVER_PORT", "8080"))
-	if err != nil {
-		return nil, fmt.Errorf("invalid SERVER_PORT: %w", err)
+	return LoadFile(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFile loads configuration from a YAML or TOML file, applies environment
+// variable overrides and validates the result. An empty path skips the file
+// and uses defaults plus environment variables only.
+func LoadFile(path string) (*Config, error) {
+	return load(path, true)
+}
+
+// LoadFileOnly loads and validates a configuration file without applying
+// environment variable overrides, for tools that need a second, independent
+// configuration such as a migration target
+func LoadFileOnly(path string) (*Config, error) {
+	return load(path, false)
+}
+
+func load(path string, useEnv bool) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		if err := cfg.readFile(path); err != nil {
+			return nil, err
+		}
 	}
 
-	// Load storage configuration
-	storageType := storage.StorageType(getEnv("STORAGE_TYPE", "file"))
-	storageCfg, err := loadStorageConfig(storageType)
-	if err != nil {
-		return nil, fmt.Errorf("failed to load storage config: %w", err)
+	if useEnv {
+		if err := cfg.applyEnv(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
+	if err := cfg.resolveSecrets(context.Background()); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Default returns the built-in configuration defaults
+func Default() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-			Port: port,
+			Host:               "0.0.0.0",
+			Port:               8080,
+			MaxBodyBytes:       4 << 20,
+			MaxRelatedObjects:  10000,
+			ReadHeaderTimeout:  10 * time.Second,
+			ReadTimeout:        60 * time.Second,
+			IdleTimeout:        120 * time.Second,
+			ReadRouteTimeout:   15 * time.Second,
+			WriteRouteTimeout:  30 * time.Second,
+			ReadOnlyRetryAfter: 5 * time.Minute,
 		},
-		Storage: *storageCfg,
-		Auth: AuthConfig{
-			JWTSecret: getEnv("JWT_SECRET", ""),
-			Enabled:   getEnv("AUTH_ENABLED", "false") == "true",
+		Storage: storage.StorageConfig{
+			Type: storage.StorageTypeFile,
+			File: &storage.FileConfig{
+				DataDir:        "./data",
+				GitAutoCommit:  true,
+				GitAuthorName:  "RAiD System",
+				GitAuthorEmail: "raid@example.org",
+				Lock:           "exclusive",
+				LeaseTTL:       30 * time.Second,
+			},
+			FDB: &storage.FDBConfig{
+				APIVersion: 710,
+			},
+			Cockroach: &storage.CockroachConfig{
+				Host:     "localhost",
+				Port:     26257,
+				Database: "raid",
+				User:     "root",
+				SSLMode:  "disable",
+				// Re-read rotated passwords from secret stores
+				PasswordRefresh: 5 * time.Minute,
+			},
 		},
-	}, nil
+		Backup: BackupConfig{
+			Target:         "local",
+			Dir:            "./backups",
+			RetentionCount: 7,
+		},
+		Compaction: CompactionConfig{
+			KeepVersions: 10,
+		},
+		Resilience: ResilienceConfig{
+			MaxRetries:         3,
+			InitialBackoff:     50 * time.Millisecond,
+			MaxBackoff:         time.Second,
+			BreakerThreshold:   5,
+			BreakerOpenTimeout: 30 * time.Second,
+		},
+		Cache: CacheConfig{
+			RedisPrefix: "raid:cache:",
+			MaxEntries:  10000,
+			TTL:         5 * time.Minute,
+			NegativeTTL: 30 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			ReadPerMinute:  600,
+			ReadBurst:      100,
+			WritePerMinute: 60,
+			WriteBurst:     20,
+			AdminPerMinute: 60,
+			AdminBurst:     10,
+			RedisPrefix:    "raid:ratelimit:",
+		},
+		AccessLog: AccessLogConfig{
+			File:       "./logs/access.log",
+			MaxSizeMB:  100,
+			MaxBackups: 10,
+			Export:     AccessLogExportConfig{Format: "cef"},
+		},
+		Publication: PublicationConfig{
+			Interval: time.Minute,
+		},
+		ActivityPub: ActivityPubConfig{
+			Username: "raid",
+		},
+		Attachments: AttachmentConfig{
+			MaxBytes:    8 << 20,
+			ScanTimeout: 30 * time.Second,
+		},
+		Notifications: NotificationConfig{
+			SMTPPort:       587,
+			EmbargoNotice:  14 * 24 * time.Hour,
+			QuotaThreshold: 90,
+		},
+	}
 }
 
-func loadStorageConfig(storageType storage.StorageType) (*storage.StorageConfig, error) {
-	cfg := &storage.StorageConfig{
-		Type: storageType,
+// readFile decodes a configuration file on top of the current values
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
 	}
 
-	switch storageType {
-	case storage.StorageTypeFile, storage.StorageTypeFileGit:
-		cfg.File = &storage.FileConfig{
-			DataDir:        getEnv("STORAGE_FILE_DATADIR", "./data"),
-			GitEnabled:     storageType == storage.StorageTypeFileGit,
-			GitAutoCommit:  getEnv("STORAGE_GIT_AUTOCOMMIT", "true") == "true",
-			GitAuthorName:  getEnv("STORAGE_GIT_AUTHOR_NAME", "RAiD System"),
-			GitAuthorEmail: getEnv("STORAGE_GIT_AUTHOR_EMAIL", "raid@example.org"),
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, c)
+	case ".toml":
+		err = toml.Unmarshal(data, c)
+	case ".json":
+		err = json.Unmarshal(data, c)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// applyEnv overrides configuration values with any environment variables set
+func (c *Config) applyEnv() error {
+	var errs []error
+
+	envString("SERVER_HOST", &c.Server.Host)
+	errs = append(errs, envInt("SERVER_PORT", &c.Server.Port))
+	errs = append(errs, envInt("SERVER_GRPC_HEALTH_PORT", &c.Server.GRPCHealthPort))
+	errs = append(errs, envBool("SERVER_REUSE_PORT", &c.Server.ReusePort))
+	errs = append(errs, envInt64("SERVER_MAX_BODY_BYTES", &c.Server.MaxBodyBytes))
+	errs = append(errs, envInt64("SERVER_MAX_RAID_BYTES", &c.Server.MaxRAiDBytes))
+	errs = append(errs, envInt("SERVER_MAX_RELATED_OBJECTS", &c.Server.MaxRelatedObjects))
+	errs = append(errs, envBool("SERVER_STRICT_DECODING", &c.Server.StrictDecoding))
+	errs = append(errs, envDuration("SERVER_READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout))
+	errs = append(errs, envDuration("SERVER_READ_TIMEOUT", &c.Server.ReadTimeout))
+	errs = append(errs, envDuration("SERVER_WRITE_TIMEOUT", &c.Server.WriteTimeout))
+	errs = append(errs, envDuration("SERVER_IDLE_TIMEOUT", &c.Server.IdleTimeout))
+	errs = append(errs, envDuration("SERVER_READ_ROUTE_TIMEOUT", &c.Server.ReadRouteTimeout))
+	errs = append(errs, envDuration("SERVER_WRITE_ROUTE_TIMEOUT", &c.Server.WriteRouteTimeout))
+	errs = append(errs, envBool("SERVER_READ_ONLY", &c.Server.ReadOnly))
+	envString("SERVER_READ_ONLY_REASON", &c.Server.ReadOnlyReason)
+	errs = append(errs, envDuration("SERVER_READ_ONLY_RETRY_AFTER", &c.Server.ReadOnlyRetryAfter))
+	errs = append(errs, envBool("SERVER_VALIDATE_REQUESTS", &c.Server.ValidateRequests))
+	errs = append(errs, envBool("SERVER_VALIDATE_RESPONSES", &c.Server.ValidateResponses))
+	errs = append(errs, envBool("SERVER_LIST_ENVELOPE", &c.Server.ListEnvelope))
+	envString("SERVER_UNVERSIONED_DEPRECATION", &c.Server.UnversionedDeprecation)
+	envString("SERVER_UNVERSIONED_SUNSET", &c.Server.UnversionedSunset)
+
+	if v := os.Getenv("STORAGE_TYPE"); v != "" {
+		c.Storage.Type = storage.StorageType(v)
+	}
+
+	if c.Storage.File == nil {
+		c.Storage.File = &storage.FileConfig{}
+	}
+	envString("STORAGE_FILE_DATADIR", &c.Storage.File.DataDir)
+	errs = append(errs, envBool("STORAGE_GIT_AUTOCOMMIT", &c.Storage.File.GitAutoCommit))
+	envString("STORAGE_GIT_AUTHOR_NAME", &c.Storage.File.GitAuthorName)
+	envString("STORAGE_GIT_AUTHOR_EMAIL", &c.Storage.File.GitAuthorEmail)
+	envString("STORAGE_FILE_LOCK", &c.Storage.File.Lock)
+	errs = append(errs, envDuration("STORAGE_FILE_LEASE_TTL", &c.Storage.File.LeaseTTL))
+	c.Storage.File.GitEnabled = c.Storage.Type == storage.StorageTypeFileGit
+
+	if c.Storage.FDB == nil {
+		c.Storage.FDB = &storage.FDBConfig{}
+	}
+	envString("STORAGE_FDB_CLUSTER_FILE", &c.Storage.FDB.ClusterFile)
+	errs = append(errs, envInt("STORAGE_FDB_API_VERSION", &c.Storage.FDB.APIVersion))
+	envString("STORAGE_FDB_ENCODING", &c.Storage.FDB.Encoding)
+
+	if c.Storage.Cockroach == nil {
+		c.Storage.Cockroach = &storage.CockroachConfig{}
+	}
+	envString("STORAGE_COCKROACH_HOST", &c.Storage.Cockroach.Host)
+	errs = append(errs, envInt("STORAGE_COCKROACH_PORT", &c.Storage.Cockroach.Port))
+	envString("STORAGE_COCKROACH_DATABASE", &c.Storage.Cockroach.Database)
+	envString("STORAGE_COCKROACH_USER", &c.Storage.Cockroach.User)
+	envString("STORAGE_COCKROACH_PASSWORD", &c.Storage.Cockroach.Password)
+	envFile("STORAGE_COCKROACH_PASSWORD_FILE", &c.Storage.Cockroach.Password)
+	errs = append(errs, envDuration("STORAGE_COCKROACH_PASSWORD_REFRESH", &c.Storage.Cockroach.PasswordRefresh))
+	errs = append(errs, envBool("STORAGE_COCKROACH_FOLLOWER_READS", &c.Storage.Cockroach.FollowerReads))
+	envList("STORAGE_COCKROACH_REGIONS", &c.Storage.Cockroach.Regions)
+	envString("STORAGE_COCKROACH_REGION", &c.Storage.Cockroach.Region)
+	// The migration target is configured in the file; the read share is
+	// what changes as a migration proceeds
+	if os.Getenv("STORAGE_MIGRATION_READ_PERCENT") != "" {
+		if c.Storage.Migration == nil {
+			c.Storage.Migration = &storage.MigrationConfig{}
 		}
+		errs = append(errs, envInt("STORAGE_MIGRATION_READ_PERCENT", &c.Storage.Migration.ReadPercent))
+	}
+	envString("STORAGE_COCKROACH_SSLMODE", &c.Storage.Cockroach.SSLMode)
+	envString("STORAGE_COCKROACH_SSLCERT", &c.Storage.Cockroach.SSLCert)
+	envString("STORAGE_COCKROACH_SSLKEY", &c.Storage.Cockroach.SSLKey)
+	envString("STORAGE_COCKROACH_SSLROOT", &c.Storage.Cockroach.SSLRoot)
 
-	case storage.StorageTypeFDB:
-		apiVersion, _ := strconv.Atoi(getEnv("STORAGE_FDB_API_VERSION", "710"))
-		cfg.FDB = &storage.FDBConfig{
-			ClusterFile: getEnv("STORAGE_FDB_CLUSTER_FILE", ""),
-			APIVersion:  apiVersion,
+	envString("JWT_SECRET", &c.Auth.JWTSecret)
+	envFile("JWT_SECRET_FILE", &c.Auth.JWTSecret)
+	envString("JWT_ISSUER", &c.Auth.JWTIssuer)
+	envString("JWT_AUDIENCE", &c.Auth.JWTAudience)
+	errs = append(errs, envBool("AUTH_ENABLED", &c.Auth.Enabled))
+
+	envString("BACKUP_SCHEDULE", &c.Backup.Schedule)
+	envString("BACKUP_TARGET", &c.Backup.Target)
+	envString("BACKUP_DIR", &c.Backup.Dir)
+	envString("BACKUP_S3_BUCKET", &c.Backup.S3.Bucket)
+	envString("BACKUP_S3_PREFIX", &c.Backup.S3.Prefix)
+	envString("BACKUP_S3_REGION", &c.Backup.S3.Region)
+	envString("BACKUP_S3_ENDPOINT", &c.Backup.S3.Endpoint)
+	errs = append(errs, envInt("BACKUP_RETENTION_COUNT", &c.Backup.RetentionCount))
+	errs = append(errs, envDuration("BACKUP_RETENTION_MAX_AGE", &c.Backup.RetentionMaxAge))
+	envString("COMPACTION_SCHEDULE", &c.Compaction.Schedule)
+	errs = append(errs, envInt("COMPACTION_KEEP_VERSIONS", &c.Compaction.KeepVersions))
+	errs = append(errs, envDuration("COMPACTION_MIN_AGE", &c.Compaction.MinAge))
+	envString("RETENTION_SCHEDULE", &c.Retention.Schedule)
+	errs = append(errs, envDuration("RETENTION_PURGE_DELETED_AFTER", &c.Retention.PurgeDeletedAfter))
+	errs = append(errs, envDuration("RETENTION_ACCESS_LOG_AFTER", &c.Retention.AccessLogAfter))
+	errs = append(errs, envDuration("RETENTION_RESERVATIONS_AFTER", &c.Retention.ReservationsAfter))
+	errs = append(errs, envDuration("RETENTION_AUDIT_AFTER", &c.Retention.AuditAfter))
+	errs = append(errs, envBool("RETENTION_DRY_RUN", &c.Retention.DryRun))
+	envString("HISTORY_ANCHOR_SCHEDULE", &c.History.AnchorSchedule)
+	envString("HISTORY_ANCHOR_FILE", &c.History.AnchorFile)
+	envString("SIGNING_KEY", &c.Signing.Key)
+	envFile("SIGNING_KEY_FILE", &c.Signing.Key)
+	envString("SIGNING_KEY_ID", &c.Signing.KeyID)
+	envString("SIGNING_FORMAT", &c.Signing.Format)
+	errs = append(errs, envInt("RESILIENCE_MAX_RETRIES", &c.Resilience.MaxRetries))
+	errs = append(errs, envDuration("RESILIENCE_INITIAL_BACKOFF", &c.Resilience.InitialBackoff))
+	errs = append(errs, envDuration("RESILIENCE_MAX_BACKOFF", &c.Resilience.MaxBackoff))
+	errs = append(errs, envInt("RESILIENCE_BREAKER_THRESHOLD", &c.Resilience.BreakerThreshold))
+	errs = append(errs, envDuration("RESILIENCE_BREAKER_OPEN_TIMEOUT", &c.Resilience.BreakerOpenTimeout))
+	errs = append(errs, envBool("FAULTS_ENABLED", &c.Faults.Enabled))
+	errs = append(errs, envDuration("FAULTS_LATENCY", &c.Faults.Latency))
+	errs = append(errs, envDuration("FAULTS_JITTER", &c.Faults.Jitter))
+	errs = append(errs, envInt("FAULTS_ERROR_PERCENT", &c.Faults.ErrorPercent))
+	errs = append(errs, envInt("FAULTS_PARTIAL_PERCENT", &c.Faults.PartialPercent))
+	envList("FAULTS_OPERATIONS", &c.Faults.Operations)
+
+	envString("CACHE_STORE", &c.Cache.Store)
+	envString("CACHE_REDIS_URL", &c.Cache.RedisURL)
+	envFile("CACHE_REDIS_URL_FILE", &c.Cache.RedisURL)
+	envString("CACHE_REDIS_PREFIX", &c.Cache.RedisPrefix)
+	errs = append(errs, envInt("CACHE_MAX_ENTRIES", &c.Cache.MaxEntries))
+	errs = append(errs, envDuration("CACHE_TTL", &c.Cache.TTL))
+	errs = append(errs, envDuration("CACHE_NEGATIVE_TTL", &c.Cache.NegativeTTL))
+
+	errs = append(errs, envBool("RATE_LIMIT_ENABLED", &c.RateLimit.Enabled))
+	errs = append(errs, envInt("RATE_LIMIT_READ_PER_MINUTE", &c.RateLimit.ReadPerMinute))
+	errs = append(errs, envInt("RATE_LIMIT_READ_BURST", &c.RateLimit.ReadBurst))
+	errs = append(errs, envInt("RATE_LIMIT_WRITE_PER_MINUTE", &c.RateLimit.WritePerMinute))
+	errs = append(errs, envInt("RATE_LIMIT_WRITE_BURST", &c.RateLimit.WriteBurst))
+	errs = append(errs, envInt("RATE_LIMIT_ADMIN_PER_MINUTE", &c.RateLimit.AdminPerMinute))
+	errs = append(errs, envInt("RATE_LIMIT_ADMIN_BURST", &c.RateLimit.AdminBurst))
+	errs = append(errs, envInt("RATE_LIMIT_GLOBAL_PER_SECOND", &c.RateLimit.GlobalPerSecond))
+	errs = append(errs, envInt("RATE_LIMIT_GLOBAL_BURST", &c.RateLimit.GlobalBurst))
+	envString("RATE_LIMIT_REDIS_URL", &c.RateLimit.RedisURL)
+	envFile("RATE_LIMIT_REDIS_URL_FILE", &c.RateLimit.RedisURL)
+	envString("RATE_LIMIT_REDIS_PREFIX", &c.RateLimit.RedisPrefix)
+	errs = append(errs, envBool("RATE_LIMIT_TRUST_PROXY", &c.RateLimit.TrustProxy))
+
+	envString("ACCESS_LOG_SINK", &c.AccessLog.Sink)
+	envString("ACCESS_LOG_FILE", &c.AccessLog.File)
+	errs = append(errs, envInt("ACCESS_LOG_MAX_SIZE_MB", &c.AccessLog.MaxSizeMB))
+	errs = append(errs, envInt("ACCESS_LOG_MAX_BACKUPS", &c.AccessLog.MaxBackups))
+	envString("ACCESS_LOG_EXPORT_TARGET", &c.AccessLog.Export.Target)
+	envString("ACCESS_LOG_EXPORT_ADDRESS", &c.AccessLog.Export.Address)
+	envString("ACCESS_LOG_EXPORT_FORMAT", &c.AccessLog.Export.Format)
+	envString("ACCESS_LOG_EXPORT_AUTHORIZATION", &c.AccessLog.Export.Authorization)
+	envFile("ACCESS_LOG_EXPORT_AUTHORIZATION_FILE", &c.AccessLog.Export.Authorization)
+
+	errs = append(errs, envBool("IDENTIFIERS_CHECK_DIGIT", &c.Identifiers.CheckDigit))
+	envString("IDENTIFIERS_BASE_URL", &c.Identifiers.BaseURL)
+	envString("IDENTIFIERS_SUFFIX", &c.Identifiers.Suffix)
+	envList("IDENTIFIERS_ALLOCATED_PREFIXES", &c.Identifiers.AllocatedPrefixes)
+	errs = append(errs, envDuration("IDENTIFIERS_RESERVATION_TTL", &c.Identifiers.ReservationTTL))
+	errs = append(errs, envBool("RELATIONS_RECIPROCAL", &c.Relations.Reciprocal))
+	errs = append(errs, envBool("COAR_NOTIFY_INBOX", &c.COARNotify.Inbox))
+	envString("COAR_NOTIFY_BASE_URL", &c.COARNotify.BaseURL)
+	envList("COAR_NOTIFY_TARGETS", &c.COARNotify.Targets)
+	errs = append(errs, envBool("COAR_NOTIFY_DISCOVER", &c.COARNotify.Discover))
+	envString("ACTIVITYPUB_BASE_URL", &c.ActivityPub.BaseURL)
+	envString("ACTIVITYPUB_USERNAME", &c.ActivityPub.Username)
+	envString("ACTIVITYPUB_KEY", &c.ActivityPub.Key)
+	envFile("ACTIVITYPUB_KEY_FILE", &c.ActivityPub.Key)
+	envString("ATTACHMENTS_STORE", &c.Attachments.Store)
+	errs = append(errs, envInt64("ATTACHMENTS_MAX_BYTES", &c.Attachments.MaxBytes))
+	envList("ATTACHMENTS_MEDIA_TYPES", &c.Attachments.MediaTypes)
+	envString("ATTACHMENTS_SCAN_URL", &c.Attachments.ScanURL)
+	errs = append(errs, envDuration("ATTACHMENTS_SCAN_TIMEOUT", &c.Attachments.ScanTimeout))
+	envString("ATTACHMENTS_S3_BUCKET", &c.Attachments.S3.Bucket)
+	envString("ATTACHMENTS_S3_PREFIX", &c.Attachments.S3.Prefix)
+	envString("ATTACHMENTS_S3_REGION", &c.Attachments.S3.Region)
+	envString("ATTACHMENTS_S3_ENDPOINT", &c.Attachments.S3.Endpoint)
+	errs = append(errs, envBool("LANGUAGES_DETECT", &c.Languages.Detect))
+	envString("LANGUAGES_MESSAGES", &c.Languages.Messages)
+	envString("INVITATIONS_SECRET", &c.Invitations.Secret)
+	envFile("INVITATIONS_SECRET_FILE", &c.Invitations.Secret)
+	errs = append(errs, envDuration("INVITATIONS_TTL", &c.Invitations.TTL))
+	errs = append(errs, envDuration("PUBLICATION_INTERVAL", &c.Publication.Interval))
+
+	envString("NOTIFICATIONS_SMTP_HOST", &c.Notifications.SMTPHost)
+	errs = append(errs, envInt("NOTIFICATIONS_SMTP_PORT", &c.Notifications.SMTPPort))
+	envString("NOTIFICATIONS_USERNAME", &c.Notifications.Username)
+	envString("NOTIFICATIONS_PASSWORD", &c.Notifications.Password)
+	envFile("NOTIFICATIONS_PASSWORD_FILE", &c.Notifications.Password)
+	envString("NOTIFICATIONS_FROM", &c.Notifications.From)
+	errs = append(errs, envDuration("NOTIFICATIONS_EMBARGO_NOTICE", &c.Notifications.EmbargoNotice))
+	errs = append(errs, envInt("NOTIFICATIONS_QUOTA_THRESHOLD", &c.Notifications.QuotaThreshold))
+	envString("NOTIFICATIONS_INVITATION_URL", &c.Notifications.InvitationURL)
+	envString("ACCESS_REEMBARGO", &c.Access.Reembargo)
+	errs = append(errs, envBool("ACCESS_KEEP_EMBARGOES", &c.Access.KeepEmbargoes))
+
+	return errors.Join(errs...)
+}
+
+// resolveSecrets replaces secret references that are needed at startup with
+// their values. The database password is left as a reference so the storage
+// backend can re-read it when credentials are rotated.
+func (c *Config) resolveSecrets(ctx context.Context) error {
+	secret, err := secrets.Resolve(ctx, c.Auth.JWTSecret)
+	if err != nil {
+		return fmt.Errorf("failed to load JWT secret: %w", err)
+	}
+	if c.Auth.Enabled && secret == "" {
+		return fmt.Errorf("invalid configuration: JWT secret resolved to an empty value")
+	}
+	c.Auth.JWTSecret = secret
+
+	secret, err = secrets.Resolve(ctx, c.Invitations.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to load invitation secret: %w", err)
+	}
+	c.Invitations.Secret = secret
+
+	secret, err = secrets.Resolve(ctx, c.Notifications.Password)
+	if err != nil {
+		return fmt.Errorf("failed to load notification SMTP password: %w", err)
+	}
+	c.Notifications.Password = secret
+
+	secret, err = secrets.Resolve(ctx, c.Signing.Key)
+	if err != nil {
+		return fmt.Errorf("failed to load signing key: %w", err)
+	}
+	c.Signing.Key = secret
+
+	secret, err = secrets.Resolve(ctx, c.ActivityPub.Key)
+	if err != nil {
+		return fmt.Errorf("failed to load ActivityPub key: %w", err)
+	}
+	c.ActivityPub.Key = secret
+
+	redisURL, err := secrets.Resolve(ctx, c.RateLimit.RedisURL)
+	if err != nil {
+		return fmt.Errorf("failed to load rate limit Redis URL: %w", err)
+	}
+	c.RateLimit.RedisURL = redisURL
+
+	if redisURL, err = secrets.Resolve(ctx, c.Cache.RedisURL); err != nil {
+		return fmt.Errorf("failed to load cache Redis URL: %w", err)
+	}
+	c.Cache.RedisURL = redisURL
+	return nil
+}
+
+// Validate checks the configuration for missing or inconsistent settings
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("server.port must be between 1 and 65535, got %d", c.Server.Port))
+	}
+	if p := c.Server.GRPCHealthPort; p < 0 || p > 65535 || p == c.Server.Port {
+		errs = append(errs, fmt.Errorf("server.grpcHealthPort must be between 1 and 65535 and not server.port, or 0, got %d", p))
+	}
+	if c.Server.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("server.maxBodyBytes must not be negative"))
+	}
+	if c.Server.MaxRAiDBytes < 0 {
+		errs = append(errs, fmt.Errorf("server.maxRaidBytes must not be negative"))
+	}
+	if c.Server.MaxRelatedObjects < 0 {
+		errs = append(errs, fmt.Errorf("server.maxRelatedObjects must not be negative"))
+	}
+	timeouts := []struct {
+		name string
+		d    time.Duration
+	}{
+		{"readHeaderTimeout", c.Server.ReadHeaderTimeout},
+		{"readTimeout", c.Server.ReadTimeout},
+		{"writeTimeout", c.Server.WriteTimeout},
+		{"idleTimeout", c.Server.IdleTimeout},
+		{"readRouteTimeout", c.Server.ReadRouteTimeout},
+		{"writeRouteTimeout", c.Server.WriteRouteTimeout},
+		{"readOnlyRetryAfter", c.Server.ReadOnlyRetryAfter},
+	}
+	for _, t := range timeouts {
+		if t.d < 0 {
+			errs = append(errs, fmt.Errorf("server.%s must not be negative", t.name))
 		}
+	}
+	deprecated, errDeprecated := ParseDate(c.Server.UnversionedDeprecation)
+	if errDeprecated != nil {
+		errs = append(errs, fmt.Errorf("server.unversionedDeprecation: %w", errDeprecated))
+	}
+	sunset, errSunset := ParseDate(c.Server.UnversionedSunset)
+	if errSunset != nil {
+		errs = append(errs, fmt.Errorf("server.unversionedSunset: %w", errSunset))
+	}
+	if !deprecated.IsZero() && !sunset.IsZero() && sunset.Before(deprecated) {
+		errs = append(errs, fmt.Errorf("server.unversionedSunset must not be before server.unversionedDeprecation"))
+	}
+	// A route timeout longer than the connection write timeout would never
+	// get to send its 503
+	if wt := c.Server.WriteTimeout; wt > 0 {
+		if c.Server.ReadRouteTimeout == 0 || c.Server.ReadRouteTimeout >= wt ||
+			c.Server.WriteRouteTimeout == 0 || c.Server.WriteRouteTimeout >= wt {
+			errs = append(errs, fmt.Errorf("server route timeouts must be set and shorter than server.writeTimeout"))
+		}
+	}
 
-	case storage.StorageTypeCockroach:
-		port, _ := strconv.Atoi(getEnv("STORAGE_COCKROACH_PORT", "26257"))
-		cfg.Cockroach = &storage.CockroachConfig{
-			Host:     getEnv("STORAGE_COCKROACH_HOST", "localhost"),
-			Port:     port,
-			Database: getEnv("STORAGE_COCKROACH_DATABASE", "raid"),
-			User:     getEnv("STORAGE_COCKROACH_USER", "root"),
-			Password: getEnv("STORAGE_COCKROACH_PASSWORD", ""),
-			SSLMode:  getEnv("STORAGE_COCKROACH_SSLMODE", "disable"),
-			SSLCert:  getEnv("STORAGE_COCKROACH_SSLCERT", ""),
-			SSLKey:   getEnv("STORAGE_COCKROACH_SSLKEY", ""),
-			SSLRoot:  getEnv("STORAGE_COCKROACH_SSLROOT", ""),
+	if c.Invitations.TTL < 0 {
+		errs = append(errs, fmt.Errorf("invitations.ttl must not be negative"))
+	}
+	if c.Publication.Interval < 0 {
+		errs = append(errs, fmt.Errorf("publication.interval must not be negative"))
+	}
+	if n := c.Notifications; n.SMTPHost != "" {
+		if n.SMTPPort <= 0 || n.SMTPPort > 65535 {
+			errs = append(errs, fmt.Errorf("notifications.smtpPort must be between 1 and 65535, got %d", n.SMTPPort))
+		}
+		if !strings.Contains(n.From, "@") {
+			errs = append(errs, fmt.Errorf("notifications.from must be an email address"))
+		}
+		if n.EmbargoNotice < 0 {
+			errs = append(errs, fmt.Errorf("notifications.embargoNotice must not be negative"))
+		}
+		if n.QuotaThreshold < 0 || n.QuotaThreshold > 100 {
+			errs = append(errs, fmt.Errorf("notifications.quotaThreshold must be a percentage"))
 		}
+		for event := range n.Templates {
+			if !slices.Contains(notify.Events, event) {
+				errs = append(errs, fmt.Errorf("notifications.templates: no event %q, only %s", event, strings.Join(notify.Events, ", ")))
+			}
+		}
+	}
 
+	if err := c.Access.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("access: %w", err))
+	}
+
+	if c.Identifiers.BaseURL != "" {
+		if err := identifier.ValidateBaseURL(c.Identifiers.BaseURL); err != nil {
+			errs = append(errs, fmt.Errorf("identifiers.baseUrl %w", err))
+		}
+	}
+	if n := c.COARNotify; n.BaseURL == "" && (len(n.Targets) > 0 || n.Discover) {
+		errs = append(errs, fmt.Errorf("coarNotify.baseUrl is required to send notifications"))
+	}
+	for _, u := range append([]string{c.COARNotify.BaseURL}, c.COARNotify.Targets...) {
+		if parsed, err := url.Parse(u); u != "" && (err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "") {
+			errs = append(errs, fmt.Errorf("coarNotify: %q is not an http(s) URL", u))
+		}
+	}
+	if ap := c.ActivityPub; ap.BaseURL != "" {
+		if u, err := url.Parse(ap.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("activityPub.baseUrl: %q is not an http(s) URL", ap.BaseURL))
+		}
+		if ap.Username == "" {
+			errs = append(errs, fmt.Errorf("activityPub.username must not be empty"))
+		}
+	} else if ap.Key != "" {
+		errs = append(errs, fmt.Errorf("activityPub.baseUrl is required to take follows"))
+	}
+	switch c.Attachments.Store {
+	case "", "backend":
+	case "s3":
+		if c.Attachments.S3.Bucket == "" {
+			errs = append(errs, fmt.Errorf("attachments.s3.bucket is required for the s3 store"))
+		}
 	default:
-		return nil, fmt.Errorf("unknown storage type: %s", storageType)
+		errs = append(errs, fmt.Errorf("unknown attachment store: %s", c.Attachments.Store))
+	}
+	if c.Attachments.Store != "" && c.Attachments.MaxBytes <= 0 {
+		errs = append(errs, fmt.Errorf("attachments.maxBytes must be positive"))
+	}
+	if err := attachment.ValidateMediaTypes("attachments.mediaTypes", c.Attachments.MediaTypes); err != nil {
+		errs = append(errs, err)
+	}
+	if u := c.Attachments.ScanURL; u != "" {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			errs = append(errs, fmt.Errorf("attachments.scanUrl: %q is not an http(s) URL", u))
+		}
+		if c.Attachments.ScanTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("attachments.scanTimeout must be positive"))
+		}
+	}
+	if c.Identifiers.ReservationTTL < 0 {
+		errs = append(errs, fmt.Errorf("identifiers.reservationTtl must not be negative"))
+	}
+	switch c.Identifiers.Suffix {
+	case "", identifier.SuffixCounter, identifier.SuffixULID:
+	default:
+		errs = append(errs, fmt.Errorf("identifiers.suffix must be %q or %q, got %q",
+			identifier.SuffixCounter, identifier.SuffixULID, c.Identifiers.Suffix))
+	}
+	for _, p := range c.Identifiers.AllocatedPrefixes {
+		if !prefix.Valid(p) {
+			errs = append(errs, fmt.Errorf("identifiers.allocatedPrefixes: %q is not a handle prefix such as 10.82841", p))
+		}
+	}
+	if err := agency.Validate(c.Agencies); err != nil {
+		errs = append(errs, err)
+	}
+	if err := vocabulary.ValidateSubjectSchemes(c.Vocabularies.SubjectSchemes); err != nil {
+		errs = append(errs, fmt.Errorf("vocabularies: %w", err))
+	}
+	if err := policy.Validate(c.Policies); err != nil {
+		errs = append(errs, err)
 	}
 
-	return cfg, nil
+	errs = append(errs, validateStorage("storage", &c.Storage)...)
+
+	if c.Auth.Enabled && c.Auth.JWTSecret == "" {
+		errs = append(errs, fmt.Errorf("auth.jwtSecret (JWT_SECRET) is required when auth is enabled"))
+	}
+
+	if c.Backup.Schedule != "" {
+		switch c.Backup.Target {
+		case "local":
+			if c.Backup.Dir == "" {
+				errs = append(errs, fmt.Errorf("backup.dir is required for local backups"))
+			}
+		case "s3":
+			if c.Backup.S3.Bucket == "" {
+				errs = append(errs, fmt.Errorf("backup.s3.bucket is required for S3 backups"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown backup target: %s", c.Backup.Target))
+		}
+	}
+	if c.Backup.RetentionCount < 0 || c.Backup.RetentionMaxAge < 0 {
+		errs = append(errs, fmt.Errorf("backup retention must not be negative"))
+	}
+
+	if c.Compaction.Schedule != "" && c.Compaction.KeepVersions < 1 {
+		errs = append(errs, fmt.Errorf("compaction.keepVersions must be at least 1"))
+	}
+	if c.History.AnchorSchedule != "" && c.History.AnchorFile == "" {
+		errs = append(errs, fmt.Errorf("history.anchorFile is required to anchor version history"))
+	}
+	switch c.Signing.Format {
+	case "", "jws", "http":
+	default:
+		errs = append(errs, fmt.Errorf("unknown signing format: %s (expected jws or http)", c.Signing.Format))
+	}
+	if c.Compaction.MinAge < 0 {
+		errs = append(errs, fmt.Errorf("compaction.minAge must not be negative"))
+	}
+	if r := c.Retention; r.PurgeDeletedAfter < 0 || r.AccessLogAfter < 0 || r.ReservationsAfter < 0 || r.AuditAfter < 0 {
+		errs = append(errs, fmt.Errorf("retention windows must not be negative"))
+	}
+	for _, rule := range c.Retention.Rules {
+		switch {
+		case rule.Data != "deleted" && rule.Data != "access-log" && rule.Data != "reservations" && rule.Data != "audit":
+			errs = append(errs, fmt.Errorf("retention.rules: unknown data %q (expected deleted, access-log, reservations or audit)", rule.Data))
+		case rule.After <= 0:
+			errs = append(errs, fmt.Errorf("retention.rules: the window for %s must be positive", rule.Data))
+		case rule.Data == "audit" && rule.ServicePoint != 0:
+			errs = append(errs, fmt.Errorf("retention.rules: audit records have no service point"))
+		}
+	}
+	if r := c.Retention; r.Schedule != "" && r.PurgeDeletedAfter == 0 && r.AccessLogAfter == 0 && r.ReservationsAfter == 0 && r.AuditAfter == 0 && len(r.Rules) == 0 {
+		errs = append(errs, fmt.Errorf("retention.purgeDeletedAfter or another window must be set to apply retention"))
+	}
+
+	if res := c.Resilience; res.MaxRetries < 0 || res.InitialBackoff < 0 || res.MaxBackoff < 0 || res.BreakerThreshold < 0 || res.BreakerOpenTimeout < 0 {
+		errs = append(errs, fmt.Errorf("resilience settings must not be negative"))
+	}
+	if c.Resilience.BreakerThreshold > 0 && c.Resilience.BreakerOpenTimeout == 0 {
+		errs = append(errs, fmt.Errorf("resilience.breakerOpenTimeout is required with a breaker threshold"))
+	}
+
+	if f := c.Faults; f.Enabled {
+		if f.Latency < 0 || f.Jitter < 0 {
+			errs = append(errs, fmt.Errorf("faults.latency and faults.jitter must not be negative"))
+		}
+		if f.ErrorPercent < 0 || f.ErrorPercent > 100 {
+			errs = append(errs, fmt.Errorf("faults.errorPercent must be between 0 and 100, got %d", f.ErrorPercent))
+		}
+		if f.PartialPercent < 0 || f.PartialPercent > 100 {
+			errs = append(errs, fmt.Errorf("faults.partialPercent must be between 0 and 100, got %d", f.PartialPercent))
+		}
+		for _, op := range f.Operations {
+			if !slices.Contains(faults.Operations, op) {
+				errs = append(errs, fmt.Errorf("unknown faults.operations entry: %s", op))
+			}
+		}
+	}
+
+	switch c.Cache.Store {
+	case "", "memory":
+	case "redis":
+		if c.Cache.RedisURL == "" {
+			errs = append(errs, fmt.Errorf("cache.redisUrl is required for the redis store"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown cache store: %s", c.Cache.Store))
+	}
+	if c.Cache.Store != "" && c.Cache.TTL <= 0 {
+		errs = append(errs, fmt.Errorf("cache.ttl must be positive"))
+	}
+	if c.Cache.MaxEntries < 0 || c.Cache.NegativeTTL < 0 {
+		errs = append(errs, fmt.Errorf("cache settings must not be negative"))
+	}
+
+	rl := c.RateLimit
+	for _, v := range []int{rl.ReadPerMinute, rl.ReadBurst, rl.WritePerMinute, rl.WriteBurst, rl.AdminPerMinute, rl.AdminBurst, rl.GlobalPerSecond, rl.GlobalBurst} {
+		if v < 0 {
+			errs = append(errs, fmt.Errorf("rate limits must not be negative"))
+			break
+		}
+	}
+
+	switch c.AccessLog.Sink {
+	case "", "storage":
+	case "file":
+		if c.AccessLog.File == "" {
+			errs = append(errs, fmt.Errorf("accessLog.file is required for the file sink"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("unknown access log sink: %s", c.AccessLog.Sink))
+	}
+	if c.AccessLog.MaxSizeMB < 0 || c.AccessLog.MaxBackups < 0 {
+		errs = append(errs, fmt.Errorf("access log rotation settings must not be negative"))
+	}
+	if e := c.AccessLog.Export; e.Target != "" {
+		switch e.Target {
+		case "syslog", "http":
+		default:
+			errs = append(errs, fmt.Errorf("unknown access log export target: %s", e.Target))
+		}
+		if e.Address == "" {
+			errs = append(errs, fmt.Errorf("accessLog.export.address is required for the %s target", e.Target))
+		}
+		switch e.Format {
+		case "cef", "ocsf", "json":
+		default:
+			errs = append(errs, fmt.Errorf("unknown access log export format: %s", e.Format))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("invalid configuration: %w", err)
+	}
+	return nil
+}
+
+// Summary returns a human-readable description of the effective
+// configuration with secrets redacted, suitable for logging at startup
+func (c *Config) Summary() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "server: %s:%d maxBodyBytes=%d maxRaidBytes=%d maxRelatedObjects=%d readTimeout=%s writeTimeout=%s readRouteTimeout=%s writeRouteTimeout=%s\n",
+		c.Server.Host, c.Server.Port, c.Server.MaxBodyBytes, c.Server.MaxRAiDBytes, c.Server.MaxRelatedObjects,
+		c.Server.ReadTimeout, c.Server.WriteTimeout, c.Server.ReadRouteTimeout, c.Server.WriteRouteTimeout)
+	if c.Server.ReadOnly {
+		fmt.Fprintf(&b, "server: read-only mode enabled reason=%q\n", c.Server.ReadOnlyReason)
+	}
+	if c.Server.ValidateRequests || c.Server.ValidateResponses {
+		fmt.Fprintf(&b, "server: OpenAPI validation requests=%t responses=%t\n", c.Server.ValidateRequests, c.Server.ValidateResponses)
+	}
+	if c.Server.ListEnvelope {
+		fmt.Fprintf(&b, "server: lists enveloped by default\n")
+	}
+	if c.Server.StrictDecoding {
+		fmt.Fprintf(&b, "server: strict decoding rejects unknown RAiD properties\n")
+	}
+	if c.Server.UnversionedDeprecation != "" || c.Server.UnversionedSunset != "" {
+		fmt.Fprintf(&b, "server: unversioned API deprecated=%q sunset=%q\n", c.Server.UnversionedDeprecation, c.Server.UnversionedSunset)
+	}
+	fmt.Fprintf(&b, "storage: type=%s", c.Storage.Type)
+
+	switch c.Storage.Type {
+	case storage.StorageTypeFile, storage.StorageTypeFileGit:
+		if f := c.Storage.File; f != nil {
+			fmt.Fprintf(&b, " dataDir=%s lock=%s leaseTTL=%s", f.DataDir, f.Lock, f.LeaseTTL)
+			if c.Storage.Type == storage.StorageTypeFileGit {
+				fmt.Fprintf(&b, " gitAutoCommit=%t gitAuthor=%q <%s>", f.GitAutoCommit, f.GitAuthorName, f.GitAuthorEmail)
+			}
+		}
+	case storage.StorageTypeFDB:
+		if f := c.Storage.FDB; f != nil {
+			fmt.Fprintf(&b, " clusterFile=%q apiVersion=%d encoding=%s", f.ClusterFile, f.APIVersion, cmp.Or(f.Encoding, string(codec.JSON)))
+		}
+	case storage.StorageTypeCockroach:
+		if crdb := c.Storage.Cockroach; crdb != nil {
+			fmt.Fprintf(&b, " host=%s port=%d database=%s user=%s password=%s sslMode=%s followerReads=%t",
+				crdb.Host, crdb.Port, crdb.Database, crdb.User, secrets.Describe(crdb.Password), crdb.SSLMode, crdb.FollowerReads)
+			if len(crdb.Regions) > 0 {
+				fmt.Fprintf(&b, " regions=%s region=%s", strings.Join(crdb.Regions, ","), cmp.Or(crdb.Region, "(gateway)"))
+			}
+		}
+	default:
+		// Option values may be credentials, so only their names are shown
+		names := make([]string, 0, len(c.Storage.Options))
+		for name := range c.Storage.Options {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, " options=%s", strings.Join(names, ","))
+	}
+	if m := c.Storage.Migration; m != nil && m.Target != nil {
+		fmt.Fprintf(&b, " migratingTo=%s readPercent=%d", m.Target.Type, m.ReadPercent)
+	}
+	b.WriteString("\n")
+
+	fmt.Fprintf(&b, "auth: enabled=%t jwtSecret=%s issuer=%q audience=%q",
+		c.Auth.Enabled, secrets.Describe(c.Auth.JWTSecret), c.Auth.JWTIssuer, c.Auth.JWTAudience)
+
+	if c.Backup.Schedule != "" {
+		fmt.Fprintf(&b, "\nbackup: schedule=%q target=%s retentionCount=%d retentionMaxAge=%s",
+			c.Backup.Schedule, c.Backup.Target, c.Backup.RetentionCount, c.Backup.RetentionMaxAge)
+	}
+
+	if c.Compaction.Schedule != "" {
+		fmt.Fprintf(&b, "\ncompaction: schedule=%q keepVersions=%d minAge=%s",
+			c.Compaction.Schedule, c.Compaction.KeepVersions, c.Compaction.MinAge)
+	}
+	if c.Retention.Schedule != "" {
+		fmt.Fprintf(&b, "\nretention: schedule=%q purgeDeletedAfter=%s accessLogAfter=%s reservationsAfter=%s auditAfter=%s rules=%d dryRun=%t",
+			c.Retention.Schedule, c.Retention.PurgeDeletedAfter, c.Retention.AccessLogAfter, c.Retention.ReservationsAfter, c.Retention.AuditAfter, len(c.Retention.Rules), c.Retention.DryRun)
+	}
+	if c.History.AnchorSchedule != "" {
+		fmt.Fprintf(&b, "\nhistory: anchorSchedule=%q anchorFile=%s", c.History.AnchorSchedule, c.History.AnchorFile)
+	}
+
+	if sg := c.Signing; sg.Key != "" {
+		fmt.Fprintf(&b, "\nsigning: key=%s keyId=%s format=%s", secrets.Describe(sg.Key), sg.KeyID, cmp.Or(sg.Format, "jws"))
+	}
+
+	fmt.Fprintf(&b, "\nresilience: maxRetries=%d initialBackoff=%s maxBackoff=%s breakerThreshold=%d breakerOpenTimeout=%s",
+		c.Resilience.MaxRetries, c.Resilience.InitialBackoff, c.Resilience.MaxBackoff,
+		c.Resilience.BreakerThreshold, c.Resilience.BreakerOpenTimeout)
+
+	if f := c.Faults; f.Enabled {
+		fmt.Fprintf(&b, "\nfaults: latency=%s jitter=%s errorPercent=%d partialPercent=%d operations=%s",
+			f.Latency, f.Jitter, f.ErrorPercent, f.PartialPercent, strings.Join(f.Operations, ","))
+	}
+
+	if ca := c.Cache; ca.Store != "" {
+		fmt.Fprintf(&b, "\ncache: store=%s ttl=%s negativeTtl=%s", ca.Store, ca.TTL, ca.NegativeTTL)
+	}
+
+	if rl := c.RateLimit; rl.Enabled {
+		store := "memory"
+		if rl.RedisURL != "" {
+			store = "redis"
+		}
+		fmt.Fprintf(&b, "\nrateLimit: read=%d/min write=%d/min admin=%d/min global=%d/s store=%s trustProxy=%t",
+			rl.ReadPerMinute, rl.WritePerMinute, rl.AdminPerMinute, rl.GlobalPerSecond, store, rl.TrustProxy)
+	}
+
+	switch al := c.AccessLog; al.Sink {
+	case "file":
+		fmt.Fprintf(&b, "\naccessLog: sink=file file=%s maxSizeMB=%d maxBackups=%d", al.File, al.MaxSizeMB, al.MaxBackups)
+	case "storage":
+		b.WriteString("\naccessLog: sink=storage")
+	}
+
+	if id := c.Identifiers; id.CheckDigit || id.BaseURL != "" || id.Suffix != "" || len(id.AllocatedPrefixes) > 0 {
+		fmt.Fprintf(&b, "\nidentifiers: checkDigit=%t baseUrl=%s suffix=%s", id.CheckDigit, id.BaseURL,
+			cmp.Or(id.Suffix, identifier.SuffixCounter))
+		if len(id.AllocatedPrefixes) > 0 {
+			fmt.Fprintf(&b, " allocatedPrefixes=%s", strings.Join(id.AllocatedPrefixes, ","))
+		}
+	}
+
+	if inv := c.Invitations; inv.Secret != "" || c.Auth.JWTSecret != "" {
+		fmt.Fprintf(&b, "\ninvitations: secret=%s ttl=%s", secrets.Describe(inv.Secret), inv.TTL)
+	}
+
+	if c.Publication.Interval > 0 {
+		fmt.Fprintf(&b, "\npublication: interval=%s", c.Publication.Interval)
+	}
+
+	if n := c.Notifications; n.SMTPHost != "" {
+		fmt.Fprintf(&b, "\nnotifications: smtp=%s:%d username=%s password=%s from=%s embargoNotice=%s quotaThreshold=%d",
+			n.SMTPHost, n.SMTPPort, n.Username, secrets.Describe(n.Password), n.From, n.EmbargoNotice, n.QuotaThreshold)
+	}
+
+	if c.Relations.Reciprocal {
+		b.WriteString("\nrelations: reciprocal=true")
+	}
+
+	if n := c.COARNotify; n.Inbox || n.BaseURL != "" {
+		fmt.Fprintf(&b, "\ncoarNotify: inbox=%t baseUrl=%s targets=%d discover=%t", n.Inbox, n.BaseURL, len(n.Targets), n.Discover)
+	}
+
+	if ap := c.ActivityPub; ap.BaseURL != "" {
+		fmt.Fprintf(&b, "\nactivityPub: baseUrl=%s username=%s key=%s", ap.BaseURL, ap.Username, secrets.Describe(ap.Key))
+	}
+
+	if a := c.Attachments; a.Store != "" {
+		fmt.Fprintf(&b, "\nattachments: store=%s maxBytes=%d mediaTypes=%d scanUrl=%s", a.Store, a.MaxBytes, len(a.MediaTypes), a.ScanURL)
+	}
+
+	if c.Languages.Detect || c.Languages.Messages != "" {
+		fmt.Fprintf(&b, "\nlanguages: detect=%t messages=%q", c.Languages.Detect, c.Languages.Messages)
+	}
+
+	if a := c.Access; a != (access.Policy{}) {
+		fmt.Fprintf(&b, "\naccess: reembargo=%s keepEmbargoes=%t", cmp.Or(a.Reembargo, access.ReembargoOperator), a.KeepEmbargoes)
+	}
+
+	for _, s := range c.Vocabularies.SubjectSchemes {
+		fmt.Fprintf(&b, "\nsubject scheme: %s", s.SchemaURI)
+	}
+
+	for _, a := range c.Agencies {
+		fmt.Fprintf(&b, "\nagency: id=%s baseUrl=%s hosts=%s", a.ID, a.BaseURL, strings.Join(a.Hosts, ","))
+	}
+
+	for _, p := range c.Policies {
+		fmt.Fprintf(&b, "\npolicy: %s on=%s", p.Name, cmp.Or(strings.Join(p.On, ","), "mint,update"))
+	}
+
+	return b.String()
 }
 
-func getEnv(key, defaultValue string) string {
+func envString(key string, target *string) {
 	if value := os.Getenv(key); value != "" {
-		return value
+		*target = value
+	}
+}
+
+// envFile implements the Docker/Kubernetes *_FILE convention by turning the
+// path into a file: secret reference
+func envFile(key string, target *string) {
+	if path := os.Getenv(key); path != "" {
+		*target = "file:" + path
+	}
+}
+
+// ParseDate parses a date given as YYYY-MM-DD, taken as midnight UTC, or as
+// an RFC 3339 timestamp; an empty string is the zero time
+func ParseDate(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected YYYY-MM-DD or RFC 3339", value)
+	}
+	return t, nil
+}
+
+// envList sets target to the comma-separated values of key, if set
+func envList(key string, target *[]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	*target = nil
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*target = append(*target, v)
+		}
+	}
+}
+
+func envDuration(key string, target *time.Duration) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = d
+	return nil
+}
+
+func envInt(key string, target *int) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = n
+	return nil
+}
+
+func envInt64(key string, target *int64) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = n
+	return nil
+}
+
+func envBool(key string, target *bool) error {
+	value := os.Getenv(key)
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %w", key, err)
+	}
+	*target = b
+	return nil
+}
+
+// validateStorage checks the storage configuration s, named field in errors
+func validateStorage(field string, s *storage.StorageConfig) []error {
+	var errs []error
+	switch s.Type {
+	case storage.StorageTypeFile, storage.StorageTypeFileGit:
+		if s.File == nil || s.File.DataDir == "" {
+			errs = append(errs, fmt.Errorf("%s.file.dataDir is required for storage type %s", field, s.Type))
+		}
+		if f := s.File; f != nil {
+			switch f.Lock {
+			case "", "exclusive", "wait", "none":
+			default:
+				errs = append(errs, fmt.Errorf("unknown %s.file.lock mode: %s", field, f.Lock))
+			}
+			if f.LeaseTTL < 0 {
+				errs = append(errs, fmt.Errorf("%s.file.leaseTTL must not be negative", field))
+			}
+		}
+
+	case storage.StorageTypeFDB:
+		if s.FDB == nil || s.FDB.APIVersion < 0 {
+			errs = append(errs, fmt.Errorf("%s.fdb.apiVersion must not be negative", field))
+		}
+		if s.FDB != nil {
+			if _, err := codec.ParseFormat(s.FDB.Encoding); err != nil {
+				errs = append(errs, fmt.Errorf("%s.fdb.encoding: %w", field, err))
+			}
+		}
+
+	case storage.StorageTypeCockroach:
+		crdb := s.Cockroach
+		if crdb == nil {
+			crdb = &storage.CockroachConfig{}
+		}
+		if crdb.Host == "" {
+			errs = append(errs, fmt.Errorf("%s.cockroach.host is required", field))
+		}
+		if crdb.Port <= 0 || crdb.Port > 65535 {
+			errs = append(errs, fmt.Errorf("%s.cockroach.port must be between 1 and 65535, got %d", field, crdb.Port))
+		}
+		if crdb.Database == "" {
+			errs = append(errs, fmt.Errorf("%s.cockroach.database is required", field))
+		}
+		if crdb.User == "" {
+			errs = append(errs, fmt.Errorf("%s.cockroach.user is required", field))
+		}
+		if crdb.SSLMode == "verify-full" && crdb.SSLRoot == "" {
+			errs = append(errs, fmt.Errorf("%s.cockroach.sslRoot is required when sslMode is verify-full", field))
+		}
+		if crdb.Region != "" && !slices.Contains(crdb.Regions, crdb.Region) {
+			errs = append(errs, fmt.Errorf("%s.cockroach.region %q must be one of %s.cockroach.regions", field, crdb.Region, field))
+		}
+
+	default:
+		// Backends registered out of tree validate their own options
+		if !storage.Registered(s.Type) {
+			errs = append(errs, fmt.Errorf("unknown %s type: %s", field, s.Type))
+		}
+	}
+
+	if m := s.Migration; m != nil {
+		if m.Target == nil {
+			errs = append(errs, fmt.Errorf("%s.migration.target is required for a migration", field))
+		} else {
+			if m.Target.Migration != nil {
+				errs = append(errs, fmt.Errorf("%s.migration.target cannot itself be migrating", field))
+			}
+			errs = append(errs, validateStorage(field+".migration.target", m.Target)...)
+		}
+		if m.ReadPercent < 0 || m.ReadPercent > 100 {
+			errs = append(errs, fmt.Errorf("%s.migration.readPercent must be between 0 and 100, got %d", field, m.ReadPercent))
+		}
 	}
-	return defaultValue
+	return errs
 }
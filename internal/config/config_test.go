@@ -0,0 +1,279 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile_Defaults(t *testing.T) {
+	cfg, err := LoadFile("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server.Port != 8080 {
+		t.Errorf("expected default port 8080, got %d", cfg.Server.Port)
+	}
+	if cfg.Storage.Type != storage.StorageTypeFile {
+		t.Errorf("expected default storage type file, got %s", cfg.Storage.Type)
+	}
+	if cfg.Storage.File.DataDir != "./data" {
+		t.Errorf("expected default data dir ./data, got %s", cfg.Storage.File.DataDir)
+	}
+}
+
+func TestLoadFile_YAML(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 9090
+storage:
+  type: cockroach
+  cockroach:
+    host: db.example.org
+    database: raid_prod
+auth:
+  enabled: true
+  jwtSecret: from-file
+  jwtIssuer: https://raid.example.org
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server.Port != 9090 {
+		t.Errorf("expected port 9090, got %d", cfg.Server.Port)
+	}
+	if cfg.Server.Host != "0.0.0.0" {
+		t.Errorf("expected default host to be kept, got %s", cfg.Server.Host)
+	}
+	if cfg.Storage.Cockroach.Host != "db.example.org" {
+		t.Errorf("expected cockroach host from file, got %s", cfg.Storage.Cockroach.Host)
+	}
+	if cfg.Storage.Cockroach.Port != 26257 {
+		t.Errorf("expected default cockroach port to be kept, got %d", cfg.Storage.Cockroach.Port)
+	}
+	if cfg.Auth.JWTIssuer != "https://raid.example.org" {
+		t.Errorf("expected issuer from file, got %s", cfg.Auth.JWTIssuer)
+	}
+}
+
+func TestLoadFile_TOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+[server]
+port = 9191
+
+[storage]
+type = "file-git"
+
+[storage.file]
+dataDir = "/var/lib/raid"
+`)
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server.Port != 9191 {
+		t.Errorf("expected port 9191, got %d", cfg.Server.Port)
+	}
+	if cfg.Storage.File.DataDir != "/var/lib/raid" {
+		t.Errorf("expected data dir from file, got %s", cfg.Storage.File.DataDir)
+	}
+	if !cfg.Storage.File.GitEnabled {
+		t.Error("expected git to be enabled for file-git storage")
+	}
+}
+
+func TestLoadFile_EnvOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 9090
+`)
+	t.Setenv("SERVER_PORT", "7070")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Server.Port != 7070 {
+		t.Errorf("expected environment to override port, got %d", cfg.Server.Port)
+	}
+}
+
+func TestLoadFile_Validation(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr string
+	}{
+		{
+			name:    "auth enabled without secret",
+			env:     map[string]string{"AUTH_ENABLED": "true"},
+			wantErr: "jwtSecret",
+		},
+		{
+			name:    "unknown storage type",
+			env:     map[string]string{"STORAGE_TYPE": "tape"},
+			wantErr: "unknown storage type",
+		},
+		{
+			name:    "invalid port",
+			env:     map[string]string{"SERVER_PORT": "not-a-number"},
+			wantErr: "SERVER_PORT",
+		},
+		{
+			name:    "cockroach with invalid port",
+			env:     map[string]string{"STORAGE_TYPE": "cockroach", "STORAGE_COCKROACH_PORT": "0"},
+			wantErr: "storage.cockroach.port",
+		},
+		{
+			name:    "cockroach region outside the regions",
+			env:     map[string]string{"STORAGE_TYPE": "cockroach", "STORAGE_COCKROACH_REGIONS": "europe-west1,us-east1", "STORAGE_COCKROACH_REGION": "asia-east1"},
+			wantErr: "storage.cockroach.region",
+		},
+		{
+			name:    "migration read share without target",
+			env:     map[string]string{"STORAGE_MIGRATION_READ_PERCENT": "50"},
+			wantErr: "storage.migration.target",
+		},
+		{
+			name:    "fdb with unknown encoding",
+			env:     map[string]string{"STORAGE_TYPE": "fdb", "STORAGE_FDB_ENCODING": "protobuf"},
+			wantErr: "storage.fdb.encoding",
+		},
+		{
+			name:    "negative body limit",
+			env:     map[string]string{"SERVER_MAX_BODY_BYTES": "-1"},
+			wantErr: "server.maxBodyBytes",
+		},
+		{
+			name:    "negative related object limit",
+			env:     map[string]string{"SERVER_MAX_RELATED_OBJECTS": "-1"},
+			wantErr: "server.maxRelatedObjects",
+		},
+		{
+			name:    "malformed deprecation date",
+			env:     map[string]string{"SERVER_UNVERSIONED_DEPRECATION": "next year"},
+			wantErr: "server.unversionedDeprecation",
+		},
+		{
+			name:    "sunset before deprecation",
+			env:     map[string]string{"SERVER_UNVERSIONED_DEPRECATION": "2027-01-01", "SERVER_UNVERSIONED_SUNSET": "2026-06-30T12:00:00Z"},
+			wantErr: "server.unversionedSunset",
+		},
+		{
+			name:    "negative retries",
+			env:     map[string]string{"RESILIENCE_MAX_RETRIES": "-1"},
+			wantErr: "resilience settings must not be negative",
+		},
+		{
+			name:    "breaker without open timeout",
+			env:     map[string]string{"RESILIENCE_BREAKER_OPEN_TIMEOUT": "0s"},
+			wantErr: "resilience.breakerOpenTimeout",
+		},
+		{
+			name:    "route timeout exceeds write timeout",
+			env:     map[string]string{"SERVER_WRITE_TIMEOUT": "10s", "SERVER_WRITE_ROUTE_TIMEOUT": "30s"},
+			wantErr: "shorter than server.writeTimeout",
+		},
+		{
+			name:    "identifier base URL without scheme",
+			env:     map[string]string{"IDENTIFIERS_BASE_URL": "raid.example.org"},
+			wantErr: "identifiers.baseUrl",
+		},
+		{
+			name:    "redis cache without URL",
+			env:     map[string]string{"CACHE_STORE": "redis"},
+			wantErr: "cache.redisUrl",
+		},
+		{
+			name:    "unknown identifier suffix",
+			env:     map[string]string{"IDENTIFIERS_SUFFIX": "uuid"},
+			wantErr: "identifiers.suffix",
+		},
+		{
+			name:    "malformed allocated prefix",
+			env:     map[string]string{"IDENTIFIERS_ALLOCATED_PREFIXES": "10.82841, doi:10.1"},
+			wantErr: "identifiers.allocatedPrefixes",
+		},
+		{
+			name:    "unknown reembargo setting",
+			env:     map[string]string{"ACCESS_REEMBARGO": "sometimes"},
+			wantErr: "access: unknown reembargo setting",
+		},
+		{
+			name:    "compaction keeping no versions",
+			env:     map[string]string{"COMPACTION_SCHEDULE": "@weekly", "COMPACTION_KEEP_VERSIONS": "0"},
+			wantErr: "compaction.keepVersions",
+		},
+		{
+			name:    "retention without a window",
+			env:     map[string]string{"RETENTION_SCHEDULE": "@daily"},
+			wantErr: "retention.purgeDeletedAfter",
+		},
+		{
+			name:    "negative retention window",
+			env:     map[string]string{"RETENTION_SCHEDULE": "@daily", "RETENTION_AUDIT_AFTER": "-1h"},
+			wantErr: "retention windows must not be negative",
+		},
+		{
+			name:    "fault injection for an unknown operation",
+			env:     map[string]string{"FAULTS_ENABLED": "true", "FAULTS_OPERATIONS": "Teleport"},
+			wantErr: "faults.operations",
+		},
+		{
+			name:    "notifications without sender",
+			env:     map[string]string{"NOTIFICATIONS_SMTP_HOST": "smtp.example.org"},
+			wantErr: "notifications.from",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := LoadFile("")
+			if err == nil {
+				t.Fatal("expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestSummary_RedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.Storage.Type = storage.StorageTypeCockroach
+	cfg.Storage.Cockroach.Password = "hunter2"
+	cfg.Auth.JWTSecret = "super-secret"
+
+	summary := cfg.Summary()
+
+	if strings.Contains(summary, "hunter2") || strings.Contains(summary, "super-secret") {
+		t.Errorf("summary leaks secrets: %s", summary)
+	}
+	if !strings.Contains(summary, "type=cockroach") {
+		t.Errorf("summary missing storage type: %s", summary)
+	}
+}
@@ -0,0 +1,140 @@
+// Package contributor merges duplicate contributor entries of a RAiD and
+// gives every contributor a UUID that stays the same across versions, so
+// that other systems can follow a contributor from version to version.
+package contributor
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Key returns the ORCID iD of a contributor ID written in any form
+// (https://orcid.org/..., orcid.org/... or the bare iD), or "" if id is
+// empty
+func Key(id string) string {
+	id = strings.TrimSpace(id)
+	if i := strings.LastIndexByte(strings.TrimSuffix(id, "/"), '/'); i >= 0 {
+		id = strings.TrimSuffix(id, "/")[i+1:]
+	}
+	return strings.ToUpper(id)
+}
+
+// Merge returns contributors with entries for the same ORCID iD merged into
+// the first of them. Positions and roles are combined without repeats,
+// leader and contact flags are kept if set on any entry, and other fields
+// keep the first value set.
+func Merge(contributors []models.Contributor) []models.Contributor {
+	merged := make([]models.Contributor, 0, len(contributors))
+	index := make(map[string]int, len(contributors))
+	for _, c := range contributors {
+		key := Key(c.ID)
+		i, ok := index[key]
+		if key == "" || !ok {
+			if key != "" {
+				index[key] = len(merged)
+			}
+			// Slicing to zero capacity keeps empty lists from becoming null
+			c.Position = appendPositions(c.Position[:0:0], c.Position)
+			c.Role = appendRoles(c.Role[:0:0], c.Role)
+			merged = append(merged, c)
+			continue
+		}
+
+		m := &merged[i]
+		m.Position = appendPositions(m.Position, c.Position)
+		m.Role = appendRoles(m.Role, c.Role)
+		m.Leader = m.Leader || c.Leader
+		m.Contact = m.Contact || c.Contact
+		for _, f := range []struct{ dst, src *string }{
+			{&m.SchemaURI, &c.SchemaURI},
+			{&m.Status, &c.Status},
+			{&m.StatusMessage, &c.StatusMessage},
+			{&m.Email, &c.Email},
+			{&m.UUID, &c.UUID},
+		} {
+			if *f.dst == "" {
+				*f.dst = *f.src
+			}
+		}
+	}
+	return merged
+}
+
+func appendPositions(dst, src []models.ContributorPosition) []models.ContributorPosition {
+	for _, p := range src {
+		dup := false
+		for _, q := range dst {
+			if p == q {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			dst = append(dst, p)
+		}
+	}
+	return dst
+}
+
+func appendRoles(dst, src []models.IDSchema) []models.IDSchema {
+	for _, r := range src {
+		dup := false
+		for _, q := range dst {
+			if r.ID == q.ID {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			dst = append(dst, r)
+		}
+	}
+	return dst
+}
+
+// identity returns the ORCID iD of c or, for a contributor without one,
+// its email address
+func identity(c *models.Contributor) string {
+	if key := Key(c.ID); key != "" {
+		return key
+	}
+	if c.Email != "" {
+		return "mailto:" + strings.ToLower(strings.TrimSpace(c.Email))
+	}
+	return ""
+}
+
+// AssignUUIDs sets the UUID of each contributor without one: the UUID the
+// same ORCID iD, or for contributors without one the same email address,
+// had in previous, if any, or a new random UUID
+func AssignUUIDs(contributors, previous []models.Contributor) {
+	known := make(map[string]string, len(previous))
+	for i := range previous {
+		if key := identity(&previous[i]); key != "" && previous[i].UUID != "" {
+			known[key] = previous[i].UUID
+		}
+	}
+	for i := range contributors {
+		c := &contributors[i]
+		if c.UUID != "" {
+			continue
+		}
+		if uuid, ok := known[identity(c)]; ok {
+			c.UUID = uuid
+		} else {
+			c.UUID = newUUID()
+		}
+	}
+}
+
+// newUUID returns a random (version 4) UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,82 @@
+package contributor
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestMerge(t *testing.T) {
+	leader := models.ContributorPosition{ID: "leader", StartDate: "2024-01-01"}
+	member := models.ContributorPosition{ID: "member", StartDate: "2023-01-01"}
+	merged := Merge([]models.Contributor{
+		{ID: "https://orcid.org/0000-0002-1825-0097", Position: []models.ContributorPosition{member}, Role: []models.IDSchema{{ID: "a"}}},
+		{ID: "https://orcid.org/0000-0001-5109-3700", Position: []models.ContributorPosition{}},
+		{ID: "0000-0002-1825-0097", Email: "j@example.org", Leader: true, Position: []models.ContributorPosition{member, leader}, Role: []models.IDSchema{{ID: "a"}, {ID: "b"}}},
+		{Position: []models.ContributorPosition{member}},
+		{Position: []models.ContributorPosition{member}},
+	})
+
+	if len(merged) != 4 {
+		t.Fatalf("expected 4 contributors, got %d: %+v", len(merged), merged)
+	}
+	m := merged[0]
+	if m.ID != "https://orcid.org/0000-0002-1825-0097" || m.Email != "j@example.org" || !m.Leader {
+		t.Errorf("unexpected merged fields: %+v", m)
+	}
+	if len(m.Position) != 2 || m.Position[1] != leader || len(m.Role) != 2 || m.Role[1].ID != "b" {
+		t.Errorf("expected positions and roles combined without repeats, got %+v %+v", m.Position, m.Role)
+	}
+	if merged[1].Position == nil {
+		t.Error("expected an empty position list to stay empty rather than null")
+	}
+}
+
+func TestWrap_UUIDs(t *testing.T) {
+	mock := testutil.NewMockRepository()
+	var stored *models.RAiD
+	mock.CreateRAiDFunc = func(_ context.Context, raid *models.RAiD) (*models.RAiD, error) {
+		stored = raid
+		return raid, nil
+	}
+	mock.GetRAiDFunc = func(context.Context, string, string) (*models.RAiD, error) {
+		return stored, nil
+	}
+	mock.UpdateRAiDFunc = func(_ context.Context, _, _ string, raid *models.RAiD) (*models.RAiD, error) {
+		return raid, nil
+	}
+	repo := Wrap(mock)
+	ctx := context.Background()
+
+	created, err := repo.CreateRAiD(ctx, &models.RAiD{Contributor: []models.Contributor{
+		{ID: "https://orcid.org/0000-0002-1825-0097"},
+		{ID: "https://orcid.org/0000-0001-5109-3700"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := created.Contributor[0].UUID
+	if !uuidPattern.MatchString(first) || first == created.Contributor[1].UUID {
+		t.Fatalf("expected distinct random UUIDs, got %+v", created.Contributor)
+	}
+
+	// The client drops the UUIDs and reorders the contributors
+	updated, err := repo.UpdateRAiD(ctx, "10.1", "a", &models.RAiD{Contributor: []models.Contributor{
+		{ID: "https://orcid.org/0000-0003-1415-9269"},
+		{ID: "0000-0002-1825-0097"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Contributor[1].UUID != first {
+		t.Errorf("expected the contributor to keep UUID %s, got %s", first, updated.Contributor[1].UUID)
+	}
+	if u := updated.Contributor[0].UUID; !uuidPattern.MatchString(u) || u == first {
+		t.Errorf("expected a new UUID for a new contributor, got %s", u)
+	}
+}
@@ -0,0 +1,110 @@
+package contributor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// RedactedExtension is the contributor property left in place of redacted
+// personal data, naming the redaction that removed it
+const RedactedExtension = "x-redacted"
+
+// Subject identifies the contributor whose personal data is to be redacted.
+// Entries match on any identifier set: the ORCID iD in any form, the email
+// address regardless of case, or the contributor UUID.
+type Subject struct {
+	ID    string `json:"id,omitempty"`
+	Email string `json:"email,omitempty"`
+	UUID  string `json:"uuid,omitempty"`
+}
+
+// Empty reports whether s sets no identifier
+func (s Subject) Empty() bool {
+	return Key(s.ID) == "" && strings.TrimSpace(s.Email) == "" && strings.TrimSpace(s.UUID) == ""
+}
+
+// Hash returns the SHA-256 hash of the normalised identifiers of s, for
+// recording whose data was redacted without keeping the identifiers
+func (s Subject) Hash() string {
+	sum := sha256.Sum256([]byte(Key(s.ID) + "\n" + strings.ToLower(strings.TrimSpace(s.Email)) + "\n" + strings.TrimSpace(s.UUID)))
+	return hex.EncodeToString(sum[:])
+}
+
+// matches reports whether contributor c is the subject
+func (s Subject) matches(c *models.Contributor) bool {
+	if key := Key(s.ID); key != "" && Key(c.ID) == key {
+		return true
+	}
+	if email := strings.TrimSpace(s.Email); email != "" && strings.EqualFold(strings.TrimSpace(c.Email), email) {
+		return true
+	}
+	uuid := strings.TrimSpace(s.UUID)
+	return uuid != "" && c.UUID == uuid
+}
+
+// Redact returns a function for storage.Redactor that removes the personal
+// data of the subject's entries in a version of a RAiD: email address,
+// UUID, status message and properties the contributor type does not define.
+// The ORCID iD, roles and positions are kept, so that who contributed what
+// remains on record, and a RedactedExtension tombstone names redaction. The
+// fields removed are added to redaction.Fields, in order. Versions already
+// redacted are left unchanged.
+func Redact(subject Subject, redaction *models.Redaction) func(*models.RAiD) bool {
+	tombstone, _ := json.Marshal(map[string]string{
+		"redaction": redaction.ID,
+		"redacted":  redaction.Redacted.UTC().Format(time.RFC3339),
+	})
+
+	return func(raid *models.RAiD) bool {
+		changed := false
+		for i := range raid.Contributor {
+			c := &raid.Contributor[i]
+			if !subject.matches(c) {
+				continue
+			}
+
+			var fields []string
+			for _, f := range []struct {
+				name  string
+				value *string
+			}{
+				{"email", &c.Email},
+				{"uuid", &c.UUID},
+				{"statusMessage", &c.StatusMessage},
+			} {
+				if *f.value != "" {
+					*f.value = ""
+					fields = append(fields, f.name)
+				}
+			}
+			for name := range c.Extensions {
+				if name != RedactedExtension {
+					delete(c.Extensions, name)
+					fields = append(fields, name)
+				}
+			}
+			if len(fields) == 0 {
+				continue
+			}
+
+			if c.Extensions == nil {
+				c.Extensions = make(models.Extensions)
+			}
+			c.Extensions[RedactedExtension] = tombstone
+			for _, f := range fields {
+				if !slices.Contains(redaction.Fields, f) {
+					redaction.Fields = append(redaction.Fields, f)
+				}
+			}
+			slices.Sort(redaction.Fields)
+			changed = true
+		}
+		return changed
+	}
+}
@@ -0,0 +1,36 @@
+package contributor
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that merges duplicate contributors with Merge
+// and assigns contributor UUIDs with AssignUUIDs before RAiDs are minted or
+// updated in repo. On update, contributors keep the UUIDs they had in the
+// current version.
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	raid.Contributor = Merge(raid.Contributor)
+	AssignUUIDs(raid.Contributor, nil)
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	current, err := r.Repository.GetRAiD(ctx, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	raid.Contributor = Merge(raid.Contributor)
+	AssignUUIDs(raid.Contributor, current.Contributor)
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
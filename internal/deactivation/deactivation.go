@@ -0,0 +1,202 @@
+// Package deactivation soft deletes service points. A deactivated service
+// point is kept, disabled and with a record of its deactivation, and mints
+// no more RAiDs. RAiDs it still owns must be transferred to another service point
+// or kept read-only.
+package deactivation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// What becomes of the RAiDs of a deactivated service point
+const (
+	// RAiDsNone is recorded when the service point owned no RAiDs
+	RAiDsNone = "none"
+	// RAiDsReadOnly keeps the RAiDs with the service point; they can no
+	// longer be updated or deleted
+	RAiDsReadOnly = "read-only"
+	// RAiDsTransferred moves the RAiDs to another service point
+	RAiDsTransferred = "transferred"
+)
+
+// listPage is the number of RAiDs listed at a time
+const listPage = 500
+
+var (
+	// ErrDeactivated is returned for a service point deactivated already
+	ErrDeactivated = errors.New("service point is deactivated")
+	// ErrTransferTarget is returned for a transfer to an unknown or
+	// deactivated service point, or to the service point itself
+	ErrTransferTarget = errors.New("RAiDs cannot be transferred to that service point")
+)
+
+// ActiveRAiDsError reports a deactivation without a plan for the RAiDs the
+// service point owns
+type ActiveRAiDsError struct {
+	Count int
+}
+
+func (e *ActiveRAiDsError) Error() string {
+	return fmt.Sprintf("service point owns %d RAiDs; they must be transferred or kept read-only", e.Count)
+}
+
+// Plan says what becomes of the RAiDs of a service point being deactivated
+type Plan struct {
+	Reason string
+	// ReadOnly keeps the RAiDs with the service point, read-only
+	ReadOnly bool
+	// TransferTo moves the RAiDs to this service point, as a new version
+	// of each
+	TransferTo int64
+}
+
+// Deactivate soft deletes the service point with id in repo according to
+// plan. Without a plan it fails with an *ActiveRAiDsError if the service
+// point owns any RAiDs, soft deleted ones aside. A transfer that fails
+// part way leaves the service point active, owning the RAiDs not yet
+// transferred.
+func Deactivate(ctx context.Context, repo storage.Repository, id int64, plan Plan) (*models.ServicePoint, error) {
+	sp, err := repo.GetServicePoint(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if sp.Deactivation != nil {
+		return nil, ErrDeactivated
+	}
+
+	target, err := transferTarget(ctx, repo, id, plan.TransferTo)
+	if err != nil {
+		return nil, err
+	}
+	raids, err := ownedRAiDs(ctx, repo, id)
+	if err != nil {
+		return nil, err
+	}
+	deactivation := &models.ServicePointDeactivation{
+		Date:   time.Now().UTC().Format("2006-01-02"),
+		Reason: plan.Reason,
+		Actor:  storage.Actor(ctx),
+		RAiDs:  RAiDsNone,
+		Count:  len(raids),
+	}
+	switch {
+	case len(raids) == 0:
+	case target != nil:
+		if err := transferAll(ctx, repo, raids, target); err != nil {
+			return nil, err
+		}
+		deactivation.RAiDs = RAiDsTransferred
+		deactivation.TransferredTo = target.ID
+	case plan.ReadOnly:
+		deactivation.RAiDs = RAiDsReadOnly
+	default:
+		return nil, &ActiveRAiDsError{Count: len(raids)}
+	}
+
+	sp.Enabled = false
+	sp.Deactivation = deactivation
+	return repo.UpdateServicePoint(ctx, id, sp)
+}
+
+// Delete removes the service point with id from repo outright, first
+// transferring its RAiDs to the service point transferTo if it is not 0.
+// Without a transfer it fails with an *ActiveRAiDsError if the service
+// point owns any RAiDs, unless force is set, which leaves them owned by a
+// service point that no longer exists.
+func Delete(ctx context.Context, repo storage.Repository, id, transferTo int64, force bool) error {
+	if _, err := repo.GetServicePoint(ctx, id); err != nil {
+		return err
+	}
+	target, err := transferTarget(ctx, repo, id, transferTo)
+	if err != nil {
+		return err
+	}
+	raids, err := ownedRAiDs(ctx, repo, id)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(raids) == 0, target == nil && force:
+	case target != nil:
+		if err := transferAll(ctx, repo, raids, target); err != nil {
+			return err
+		}
+	default:
+		return &ActiveRAiDsError{Count: len(raids)}
+	}
+	return repo.DeleteServicePoint(ctx, id)
+}
+
+// transferTarget returns the active service point transferTo that the
+// RAiDs of service point id may be transferred to, or nil if it is 0
+func transferTarget(ctx context.Context, repo storage.Repository, id, transferTo int64) (*models.ServicePoint, error) {
+	if transferTo == 0 {
+		return nil, nil
+	}
+	if transferTo == id {
+		return nil, ErrTransferTarget
+	}
+	target, err := repo.GetServicePoint(ctx, transferTo)
+	if err == storage.ErrNotFound {
+		return nil, ErrTransferTarget
+	}
+	if err != nil {
+		return nil, err
+	}
+	if target.Deactivation != nil {
+		return nil, ErrTransferTarget
+	}
+	return target, nil
+}
+
+// transferAll makes target the owner of raids
+func transferAll(ctx context.Context, repo storage.Repository, raids []*models.RAiD, target *models.ServicePoint) error {
+	for _, raid := range raids {
+		if err := transfer(ctx, repo, raid, target); err != nil {
+			return fmt.Errorf("transfer %s: %w", raid.Identifier.ID, err)
+		}
+	}
+	return nil
+}
+
+// ownedRAiDs returns the RAiDs owned by the service point with id
+func ownedRAiDs(ctx context.Context, repo storage.Repository, id int64) ([]*models.RAiD, error) {
+	var owned []*models.RAiD
+	filter := &storage.RAiDFilter{ServicePointID: id, Limit: listPage}
+	for {
+		raids, err := repo.ListRAiDs(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		owned = append(owned, raids...)
+		if len(raids) < listPage {
+			return owned, nil
+		}
+		prefix, suffix, err := identifier.Parse(raids[len(raids)-1].Identifier.ID)
+		if err != nil {
+			return nil, err
+		}
+		filter.Cursor = identifier.EncodeCursor(prefix, suffix)
+	}
+}
+
+// transfer makes target the owner of raid
+func transfer(ctx context.Context, repo storage.Repository, raid *models.RAiD, target *models.ServicePoint) error {
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+	if err != nil {
+		return err
+	}
+	raid.Identifier.Owner.ServicePoint = target.ID
+	if target.IdentifierOwner != "" {
+		raid.Identifier.Owner.ID = target.IdentifierOwner
+	}
+	_, err = repo.UpdateRAiD(ctx, prefix, suffix, raid)
+	return err
+}
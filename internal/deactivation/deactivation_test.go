@@ -0,0 +1,142 @@
+package deactivation
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func newRepo(t *testing.T) storage.Repository {
+	t.Helper()
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { inner.Close() })
+	return Wrap(inner)
+}
+
+func mint(t *testing.T, repo storage.Repository, suffix string, sp int64) {
+	t.Helper()
+	if _, err := repo.CreateRAiD(context.Background(), &models.RAiD{Identifier: &models.Identifier{
+		ID:    "https://raid.org/10.99999/" + suffix,
+		Owner: &models.Owner{ID: "https://ror.org/04fa4r544", ServicePoint: sp},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeactivate(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	var sps []*models.ServicePoint
+	for _, name := range []string{"Empty", "Busy", "Target"} {
+		sp, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: name, IdentifierOwner: "https://ror.org/038sjwq14"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sps = append(sps, sp)
+	}
+	empty, busy, target := sps[0], sps[1], sps[2]
+	mint(t, repo, "a", busy.ID)
+	mint(t, repo, "b", busy.ID)
+
+	sp, err := Deactivate(ctx, repo, empty.ID, Plan{Reason: "closed"})
+	if err != nil {
+		t.Fatalf("expected a service point without RAiDs to be deactivated, got %v", err)
+	}
+	if d := sp.Deactivation; d == nil || d.RAiDs != RAiDsNone || d.Reason != "closed" {
+		t.Errorf("unexpected deactivation %+v", d)
+	}
+	if _, err := Deactivate(ctx, repo, empty.ID, Plan{}); err != ErrDeactivated {
+		t.Errorf("expected ErrDeactivated, got %v", err)
+	}
+
+	var active *ActiveRAiDsError
+	if _, err := Deactivate(ctx, repo, busy.ID, Plan{}); !errors.As(err, &active) || active.Count != 2 {
+		t.Fatalf("expected an ActiveRAiDsError for 2 RAiDs, got %v", err)
+	}
+	for _, to := range []int64{busy.ID, empty.ID, 9999} {
+		if _, err := Deactivate(ctx, repo, busy.ID, Plan{TransferTo: to}); err != ErrTransferTarget {
+			t.Errorf("expected ErrTransferTarget for service point %d, got %v", to, err)
+		}
+	}
+
+	sp, err = Deactivate(ctx, repo, busy.ID, Plan{TransferTo: target.ID})
+	if err != nil {
+		t.Fatalf("expected the RAiDs to be transferred, got %v", err)
+	}
+	if d := sp.Deactivation; d.RAiDs != RAiDsTransferred || d.Count != 2 || d.TransferredTo != target.ID {
+		t.Errorf("unexpected deactivation %+v", d)
+	}
+	raid, err := repo.GetRAiD(ctx, "10.99999", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner := raid.Identifier.Owner; owner.ServicePoint != target.ID || owner.ID != target.IdentifierOwner {
+		t.Errorf("expected the RAiD to be owned by the target, got %+v", owner)
+	}
+
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{
+		Owner: &models.Owner{ServicePoint: busy.ID},
+	}}); err != storage.ErrAccessDenied {
+		t.Errorf("expected minting for a deactivated service point to be denied, got %v", err)
+	}
+}
+
+func TestDeactivate_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	sp, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Busy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mint(t, repo, "a", sp.ID)
+
+	sp, err = Deactivate(ctx, repo, sp.ID, Plan{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sp.Deactivation.RAiDs != RAiDsReadOnly {
+		t.Errorf("expected the RAiDs to be read-only, got %+v", sp.Deactivation)
+	}
+
+	raid, err := repo.GetRAiD(ctx, "10.99999", "a")
+	if err != nil {
+		t.Fatalf("expected the RAiD to stay readable, got %v", err)
+	}
+	if _, err := repo.UpdateRAiD(ctx, "10.99999", "a", raid); err != storage.ErrAccessDenied {
+		t.Errorf("expected the update to be denied, got %v", err)
+	}
+	if err := repo.DeleteRAiD(ctx, "10.99999", "a"); err != storage.ErrAccessDenied {
+		t.Errorf("expected the delete to be denied, got %v", err)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepo(t)
+	sp, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Busy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mint(t, repo, "a", sp.ID)
+
+	var active *ActiveRAiDsError
+	if err := Delete(ctx, repo, sp.ID, 0, false); !errors.As(err, &active) {
+		t.Fatalf("expected an ActiveRAiDsError, got %v", err)
+	}
+	if err := Delete(ctx, repo, sp.ID, 0, true); err != nil {
+		t.Fatalf("expected a forced delete to succeed, got %v", err)
+	}
+	if _, err := repo.GetServicePoint(ctx, sp.ID); err != storage.ErrNotFound {
+		t.Errorf("expected the service point to be gone, got %v", err)
+	}
+	if err := Delete(ctx, repo, sp.ID, 0, true); err != storage.ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
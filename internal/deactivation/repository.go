@@ -0,0 +1,83 @@
+package deactivation
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that refuses, with storage.ErrAccessDenied, to
+// mint RAiDs for deactivated service points and to update or delete the
+// RAiDs they keep read-only
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+// deactivated returns the deactivation of the service point with id, or
+// nil if it is active or there is none
+func (r *repository) deactivated(ctx context.Context, id int64) (*models.ServicePointDeactivation, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	sp, err := r.Repository.GetServicePoint(ctx, id)
+	if err == storage.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sp.Deactivation, nil
+}
+
+// checkReadOnly fails if the RAiD prefix/suffix is kept read-only by its
+// deactivated service point
+func (r *repository) checkReadOnly(ctx context.Context, prefix, suffix string) error {
+	current, err := r.Repository.GetRAiD(ctx, prefix, suffix)
+	if err != nil {
+		// Left to the write to report
+		return nil
+	}
+	if current.Identifier == nil || current.Identifier.Owner == nil {
+		return nil
+	}
+	d, err := r.deactivated(ctx, current.Identifier.Owner.ServicePoint)
+	if err != nil {
+		return err
+	}
+	if d != nil && d.RAiDs == RAiDsReadOnly {
+		return storage.ErrAccessDenied
+	}
+	return nil
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier != nil && raid.Identifier.Owner != nil {
+		d, err := r.deactivated(ctx, raid.Identifier.Owner.ServicePoint)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			return nil, storage.ErrAccessDenied
+		}
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	if err := r.checkReadOnly(ctx, prefix, suffix); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	if err := r.checkReadOnly(ctx, prefix, suffix); err != nil {
+		return err
+	}
+	return r.Repository.DeleteRAiD(ctx, prefix, suffix)
+}
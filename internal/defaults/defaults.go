@@ -0,0 +1,92 @@
+// Package defaults merges the mint defaults of a service point into the
+// RAiDs minted for it, so that integrators need not repeat the registration
+// agency, owner, license, access type and standard organisations in every
+// request.
+package defaults
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that merges the defaults of the owning service
+// point (identifier.owner.servicePoint) into RAiDs minted in repo
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier != nil && raid.Identifier.Owner != nil && raid.Identifier.Owner.ServicePoint != 0 {
+		// A missing service point is left to the mint to report
+		if sp, err := r.Repository.GetServicePoint(ctx, raid.Identifier.Owner.ServicePoint); err == nil && sp.Defaults != nil {
+			if err := Merge(raid, sp.Defaults); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+// Merge fills in the values of d that raid leaves out. raid gets copies,
+// so later changes to it leave d as it was.
+func Merge(raid *models.RAiD, d *models.MintDefaults) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	var c models.MintDefaults
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	if raid.Identifier == nil {
+		raid.Identifier = &models.Identifier{}
+	}
+	id := raid.Identifier
+	if c.RegistrationAgency != nil && (id.RegistrationAgency == nil || id.RegistrationAgency.ID == "") {
+		id.RegistrationAgency = c.RegistrationAgency
+	}
+	if c.Owner != nil {
+		if id.Owner == nil {
+			id.Owner = &models.Owner{}
+		}
+		if id.Owner.ID == "" {
+			id.Owner.ID, id.Owner.SchemaURI = c.Owner.ID, c.Owner.SchemaURI
+		}
+	}
+	if id.License == "" {
+		id.License = c.License
+	}
+
+	if c.Access != nil {
+		switch {
+		case raid.Access == nil:
+			raid.Access = c.Access
+		case raid.Access.Type == nil:
+			raid.Access.Type = c.Access.Type
+		}
+	}
+
+	for _, org := range c.Organisation {
+		if !hasOrganisation(raid.Organisation, org.ID) {
+			raid.Organisation = append(raid.Organisation, org)
+		}
+	}
+	return nil
+}
+
+func hasOrganisation(orgs []models.Organisation, id string) bool {
+	for _, org := range orgs {
+		if org.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+package defaults
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func TestWrap(t *testing.T) {
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+	repo := Wrap(inner)
+	ctx := context.Background()
+
+	open := &models.IDSchema{ID: "https://vocabulary.raid.org/access.type.schema/82", SchemaURI: "https://vocabulary.raid.org/access.type.schema/376"}
+	lead := models.Organisation{ID: "https://ror.org/038sjwq14", SchemaURI: "https://ror.org/",
+		Role: []models.OrganisationRole{{ID: "https://vocabulary.raid.org/organisation.role.schema/182", StartDate: "2024-01-01"}}}
+	sp, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Lab", Defaults: &models.MintDefaults{
+		RegistrationAgency: &models.RegistrationAgency{ID: "https://ror.org/04fa4r544", SchemaURI: "https://ror.org/"},
+		Owner:              &models.Owner{ID: "https://ror.org/038sjwq14", SchemaURI: "https://ror.org/"},
+		License:            "Creative Commons CC-0",
+		Access:             &models.Access{Type: open},
+		Organisation:       []models.Organisation{lead},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raid, err := repo.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{
+			ID:      "https://raid.org/10.99999/a",
+			Owner:   &models.Owner{ServicePoint: sp.ID},
+			License: "Own license",
+		},
+		Organisation: []models.Organisation{{ID: "https://ror.org/other"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := raid.Identifier
+	if id.RegistrationAgency == nil || id.RegistrationAgency.ID != "https://ror.org/04fa4r544" {
+		t.Errorf("expected the default registration agency, got %+v", id.RegistrationAgency)
+	}
+	if id.Owner.ID != "https://ror.org/038sjwq14" || id.Owner.ServicePoint != sp.ID {
+		t.Errorf("expected the default owner for the service point, got %+v", id.Owner)
+	}
+	if id.License != "Own license" {
+		t.Errorf("expected the request's license to be kept, got %q", id.License)
+	}
+	if raid.Access == nil || raid.Access.Type.ID != open.ID {
+		t.Errorf("expected the default access type, got %+v", raid.Access)
+	}
+	if len(raid.Organisation) != 2 || raid.Organisation[1].ID != lead.ID {
+		t.Errorf("expected the default organisation to be added, got %+v", raid.Organisation)
+	}
+
+	// Organisations already listed are not repeated
+	raid, err = repo.CreateRAiD(ctx, &models.RAiD{
+		Identifier:   &models.Identifier{ID: "https://raid.org/10.99999/b", Owner: &models.Owner{ServicePoint: sp.ID}},
+		Organisation: []models.Organisation{{ID: lead.ID}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raid.Organisation) != 1 || raid.Organisation[0].Role != nil {
+		t.Errorf("expected the request's organisation entry alone, got %+v", raid.Organisation)
+	}
+}
@@ -0,0 +1,152 @@
+// Package expr compiles and evaluates CEL (https://cel.dev) expressions
+// over RAiD documents: the RAiD as JSON, with its top-level fields as
+// variables. Besides the standard CEL macros and functions, such as has(x.y),
+// size(x), 'in', s.startsWith(p) and s.contains(sub), expressions can use
+// the string extensions (s.lowerAscii() and so on) and a few functions on
+// YYYY-MM-DD dates:
+//
+//   - today(): the current UTC date
+//   - addDays(date, n) and addYears(date, n): the date n days or years
+//     from date
+//
+// Dates are strings, so ISO dates compare in order. Top-level fields
+// missing from a RAiD are empty lists or objects, as their type has it;
+// reading a field missing further down is an error.
+package expr
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"github.com/leifj/go-raid/internal/models"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// defaults maps the top-level fields of a RAiD document to the value they
+// have when missing or null
+var defaults = documentFields(reflect.TypeOf(models.RAiD{}))
+
+// documentFields returns the JSON fields of the struct type t, with empty
+// lists for slices and empty objects for the rest
+func documentFields(t reflect.Type) map[string]any {
+	fields := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		if f.Type.Kind() == reflect.Slice {
+			fields[name] = []any{}
+		} else {
+			fields[name] = map[string]any{}
+		}
+	}
+	return fields
+}
+
+// env declares the fields of RAiD documents and the date functions
+var env = func() *cel.Env {
+	opts := []cel.EnvOption{
+		cel.CrossTypeNumericComparisons(true),
+		ext.Strings(),
+		cel.Function("today", cel.Overload("today", nil, cel.StringType,
+			cel.FunctionBinding(func(...ref.Val) ref.Val {
+				return types.String(time.Now().UTC().Format(time.DateOnly))
+			}))),
+		cel.Function("addDays", cel.Overload("addDays_string_int", []*cel.Type{cel.StringType, cel.IntType}, cel.StringType,
+			cel.BinaryBinding(addDate(func(t time.Time, n int) time.Time { return t.AddDate(0, 0, n) })))),
+		cel.Function("addYears", cel.Overload("addYears_string_int", []*cel.Type{cel.StringType, cel.IntType}, cel.StringType,
+			cel.BinaryBinding(addDate(func(t time.Time, n int) time.Time { return t.AddDate(n, 0, 0) })))),
+	}
+	for name := range defaults {
+		opts = append(opts, cel.Variable(name, cel.DynType))
+	}
+	e, err := cel.NewEnv(opts...)
+	if err != nil {
+		panic(err)
+	}
+	return e
+}()
+
+func addDate(add func(time.Time, int) time.Time) func(ref.Val, ref.Val) ref.Val {
+	return func(date, n ref.Val) ref.Val {
+		s := string(date.(types.String))
+		t, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			return types.NewErr("%q is not a YYYY-MM-DD date", s)
+		}
+		return types.String(add(t, int(n.(types.Int))).Format(time.DateOnly))
+	}
+}
+
+// Expr is a compiled expression
+type Expr struct {
+	src     string
+	ast     *cel.Ast
+	program cel.Program
+}
+
+// Compile parses and checks src
+func Compile(src string) (*Expr, error) {
+	ast, issues := env.Compile(src)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+	return &Expr{src: src, ast: ast, program: program}, nil
+}
+
+// String returns the source of e
+func (e *Expr) String() string {
+	return e.src
+}
+
+// Eval evaluates e against the RAiD document doc, JSON decoded into a
+// map[string]any, and returns the result as a JSON value
+func (e *Expr) Eval(doc map[string]any) (any, error) {
+	out, err := e.eval(doc)
+	if err != nil {
+		return nil, err
+	}
+	v, err := out.ConvertToNative(reflect.TypeOf(&structpb.Value{}))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", e.src, err)
+	}
+	return v.(*structpb.Value).AsInterface(), nil
+}
+
+// EvalBool evaluates e against doc and fails if the result is not a boolean
+func (e *Expr) EvalBool(doc map[string]any) (bool, error) {
+	out, err := e.eval(doc)
+	if err != nil {
+		return false, err
+	}
+	b, ok := out.(types.Bool)
+	if !ok {
+		return false, fmt.Errorf("%s: want a boolean, got %s", e.src, out.Type().TypeName())
+	}
+	return bool(b), nil
+}
+
+func (e *Expr) eval(doc map[string]any) (ref.Val, error) {
+	vars := make(map[string]any, len(defaults))
+	for name, def := range defaults {
+		if v := doc[name]; v != nil {
+			vars[name] = v
+		} else {
+			vars[name] = def
+		}
+	}
+	out, _, err := e.program.Eval(vars)
+	return out, err
+}
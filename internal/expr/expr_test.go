@@ -0,0 +1,128 @@
+package expr
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const doc = `{
+	"title": [{"text": "Ocean Survey"}],
+	"access": {"type": {"id": "open"}, "embargoExpiry": null},
+	"date": {"startDate": "2024-03-01"},
+	"description": [],
+	"identifier": {"owner": {"servicePoint": 3}}
+}`
+
+func TestEval(t *testing.T) {
+	var d map[string]any
+	if err := json.Unmarshal([]byte(doc), &d); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := time.Now().UTC().AddDate(0, 0, 1).Format(time.DateOnly)
+	for src, want := range map[string]any{
+		`access.type.id == 'open'`:                                                true,
+		`access.type.id != "open"`:                                                false,
+		`title[0].text`:                                                           "Ocean Survey",
+		`size(title) == 1 && !has(identifier.license)`:                            true,
+		`has(access.embargoExpiry) && access.embargoExpiry == null`:               true,
+		`size(description) > 0 || size(subject) > 0`:                              false,
+		`date.startDate >= '2023-01-01' && date.startDate < '2025-01-01'`:         true,
+		`identifier.owner.servicePoint in [1, 2, 3]`:                              true,
+		`identifier.owner.servicePoint < 4 && -identifier.owner.servicePoint < 0`: true,
+		`access.type.id in ['closed']`:                                            false,
+		`title[0].text.lowerAscii().startsWith('ocean')`:                          true,
+		`title[0].text.contains('Survey') && 2 in [1, 2]`:                         true,
+		`addYears(date.startDate, 2)`:                                             "2026-03-01",
+		`addDays(today(), 1) == '` + tomorrow + `'`:                               true,
+		`title.map(t, t.text)`:                                                    []any{"Ocean Survey"},
+		`!has(deprecation.reason)`:                                                true,
+	} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		got, err := e.Eval(d)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestCompile_Errors(t *testing.T) {
+	for src, want := range map[string]string{
+		`title ==`:         "Syntax error",
+		`title == 'b`:      "Syntax error",
+		`nosuch(title)`:    "undeclared reference to 'nosuch'",
+		`versions == 1`:    "undeclared reference to 'versions'",
+		`addDays(today())`: "found no matching overload",
+		`access.type.id.`:  "Syntax error",
+	} {
+		if _, err := Compile(src); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", src, want, err)
+		}
+	}
+}
+
+func TestEvalBool_Errors(t *testing.T) {
+	d := map[string]any{"date": map[string]any{"n": 1.0, "s": "x"}}
+	for _, src := range []string{`date.n`, `date.n < date.s`, `date.missing == 'x'`, `addDays(date.s, 1) == ''`, `title[0].text == ''`} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		if _, err := e.EvalBool(d); err == nil {
+			t.Errorf("%s: expected an error", src)
+		}
+	}
+}
+
+func TestSQL(t *testing.T) {
+	for src, want := range map[string]struct {
+		cond string
+		args []any
+	}{
+		`access.type.id == 'open' && !has(access.embargoExpiry)`: {
+			`(((data #> '{access,type,id}') = $3::JSONB) AND (NOT (CASE jsonb_typeof(COALESCE(NULLIF((data #> '{access}'), 'null'::JSONB), '{}'::JSONB)) WHEN 'object' THEN (COALESCE(NULLIF((data #> '{access}'), 'null'::JSONB), '{}'::JSONB) -> 'embargoExpiry') IS NOT NULL END)))`,
+			[]any{`"open"`},
+		},
+		`'2023-01-01' <= date.startDate`: {
+			`(CASE WHEN jsonb_typeof((data #> '{date,startDate}')) = 'string' THEN ((data #> '{date,startDate}') #>> '{}') >= $3 END)`,
+			[]any{"2023-01-01"},
+		},
+		`size(title) > 1 || identifier.owner.servicePoint in [1, null]`: {
+			`(((CASE jsonb_typeof(COALESCE(NULLIF((data #> '{title}'), 'null'::JSONB), '[]'::JSONB)) WHEN 'array' THEN jsonb_array_length(COALESCE(NULLIF((data #> '{title}'), 'null'::JSONB), '[]'::JSONB)) WHEN 'string' THEN char_length(COALESCE(NULLIF((data #> '{title}'), 'null'::JSONB), '[]'::JSONB) #>> '{}') WHEN 'object' THEN (SELECT count(*) FROM jsonb_object_keys(COALESCE(NULLIF((data #> '{title}'), 'null'::JSONB), '[]'::JSONB))) END) > $3) OR ((data #> '{identifier,owner,servicePoint}') IN ($4::JSONB, $5::JSONB)))`,
+			[]any{int64(1), "1", "null"},
+		},
+		`title[0].text < 3`: {
+			`(CASE WHEN jsonb_typeof((data #> '{title,0,text}')) = 'number' THEN ((data #> '{title,0,text}') #>> '{}')::DECIMAL < $3 END)`,
+			[]any{int64(3)},
+		},
+	} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cond, args, ok := e.SQL("data", 3)
+		if !ok || cond != want.cond || !reflect.DeepEqual(args, want.args) {
+			t.Errorf("%s:\ngot  %t %s %v\nwant %s %v", src, ok, cond, args, want.cond, want.args)
+		}
+	}
+
+	for _, src := range []string{`title[0].text.startsWith('a')`, `title == description`, `today() > '2020'`, `title.exists(t, t.text == 'a')`, `title`, `date.startDate in []`} {
+		e, err := Compile(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, ok := e.SQL("data", 1); ok {
+			t.Errorf("%s: expected no translation", src)
+		}
+	}
+}
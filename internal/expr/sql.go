@@ -0,0 +1,250 @@
+package expr
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+)
+
+// SQL translates e into a condition on a JSONB column holding the
+// documents, for PostgreSQL and CockroachDB, with placeholders numbered from
+// $next. The translation covers comparisons of fields and their sizes with
+// literals, in, has and the boolean operators; ok is false for anything
+// else, which must then be evaluated with Eval. Where Eval fails, the
+// condition is NULL, which SQL combines with AND, OR and NOT as CEL
+// combines errors, so the rows it keeps are those Eval matches.
+func (e *Expr) SQL(column string, next int) (cond string, args []any, ok bool) {
+	t := &translator{column: column, next: next}
+	cond, ok = t.cond(e.ast.NativeRep().Expr())
+	if !ok {
+		return "", nil, false
+	}
+	return cond, t.args, true
+}
+
+type translator struct {
+	column string
+	next   int
+	args   []any
+}
+
+// arg adds a placeholder for v
+func (t *translator) arg(v any) string {
+	t.args = append(t.args, v)
+	p := "$" + strconv.Itoa(t.next)
+	t.next++
+	return p
+}
+
+// fieldPath returns the path of the field n reads from the document, with
+// list indexes as numbers. Field names are CEL identifiers, so they need
+// no quoting in SQL.
+func fieldPath(n ast.Expr) ([]string, bool) {
+	switch n.Kind() {
+	case ast.IdentKind:
+		return []string{n.AsIdent()}, true
+	case ast.SelectKind:
+		s := n.AsSelect()
+		if s.IsTestOnly() {
+			return nil, false
+		}
+		p, ok := fieldPath(s.Operand())
+		return append(p, s.FieldName()), ok
+	case ast.CallKind:
+		c := n.AsCall()
+		if c.FunctionName() != operators.Index || len(c.Args()) != 2 {
+			return nil, false
+		}
+		p, ok := fieldPath(c.Args()[0])
+		i, isInt := literal(c.Args()[1]).(int64)
+		return append(p, strconv.FormatInt(i, 10)), ok && isInt && i >= 0
+	}
+	return nil, false
+}
+
+// value returns the JSONB value of the field at p, SQL NULL if it is
+// missing; top-level fields have the defaults Eval gives them
+func (t *translator) value(p []string) string {
+	v := fmt.Sprintf("(%s #> '{%s}')", t.column, strings.Join(p, ","))
+	if len(p) == 1 {
+		def, _ := json.Marshal(defaults[p[0]])
+		v = fmt.Sprintf("COALESCE(NULLIF(%s, 'null'::JSONB), '%s'::JSONB)", v, def)
+	}
+	return v
+}
+
+// literal returns the Go value of a literal, types.NullValue for null, or
+// nil if n is not a literal
+func literal(n ast.Expr) any {
+	if n.Kind() != ast.LiteralKind {
+		return nil
+	}
+	switch v := n.AsLiteral().(type) {
+	case types.String:
+		return string(v)
+	case types.Int:
+		return int64(v)
+	case types.Uint:
+		return uint64(v)
+	case types.Double:
+		return float64(v)
+	case types.Bool:
+		return bool(v)
+	case types.Null:
+		return v
+	}
+	return nil
+}
+
+// jsonLiteral returns a literal as a JSONB placeholder
+func (t *translator) jsonLiteral(n ast.Expr) (string, bool) {
+	v := literal(n)
+	if v == nil {
+		return "", false
+	}
+	if _, isNull := v.(types.Null); isNull {
+		v = nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return t.arg(string(data)) + "::JSONB", true
+}
+
+var sqlOps = map[string]string{
+	operators.Equals: "=", operators.NotEquals: "<>",
+	operators.Less: "<", operators.LessEquals: "<=", operators.Greater: ">", operators.GreaterEquals: ">=",
+}
+
+// flipped gives the operator with its operands swapped
+var flipped = map[string]string{
+	operators.Equals: operators.Equals, operators.NotEquals: operators.NotEquals,
+	operators.Less: operators.Greater, operators.LessEquals: operators.GreaterEquals,
+	operators.Greater: operators.Less, operators.GreaterEquals: operators.LessEquals,
+}
+
+func (t *translator) cond(n ast.Expr) (string, bool) {
+	switch n.Kind() {
+	case ast.LiteralKind:
+		if b, ok := literal(n).(bool); ok {
+			return strings.ToUpper(strconv.FormatBool(b)), true
+		}
+	case ast.SelectKind:
+		if n.AsSelect().IsTestOnly() {
+			return t.has(n.AsSelect())
+		}
+	case ast.CallKind:
+		c := n.AsCall()
+		switch op := c.FunctionName(); op {
+		case operators.LogicalAnd, operators.LogicalOr:
+			conds := make([]string, len(c.Args()))
+			for i, arg := range c.Args() {
+				var ok bool
+				if conds[i], ok = t.cond(arg); !ok {
+					return "", false
+				}
+			}
+			join := map[string]string{operators.LogicalAnd: " AND ", operators.LogicalOr: " OR "}[op]
+			return "(" + strings.Join(conds, join) + ")", true
+		case operators.LogicalNot:
+			cond, ok := t.cond(c.Args()[0])
+			return "(NOT " + cond + ")", ok
+		case operators.In:
+			return t.in(c.Args()[0], c.Args()[1])
+		}
+		if _, isCompare := sqlOps[c.FunctionName()]; isCompare {
+			return t.compare(c.FunctionName(), c.Args()[0], c.Args()[1])
+		}
+	}
+	return "", false
+}
+
+// has tests for a field of an object, as has() does; it fails for
+// anything else
+func (t *translator) has(s ast.SelectExpr) (string, bool) {
+	p, ok := fieldPath(s.Operand())
+	if !ok {
+		return "", false
+	}
+	parent := t.value(p)
+	return fmt.Sprintf("(CASE jsonb_typeof(%s) WHEN 'object' THEN (%s -> '%s') IS NOT NULL END)", parent, parent, s.FieldName()), true
+}
+
+func (t *translator) in(left, right ast.Expr) (string, bool) {
+	p, ok := fieldPath(left)
+	if !ok || right.Kind() != ast.ListKind || len(right.AsList().Elements()) == 0 {
+		return "", false
+	}
+	items := right.AsList().Elements()
+	values := make([]string, len(items))
+	for i, item := range items {
+		if values[i], ok = t.jsonLiteral(item); !ok {
+			return "", false
+		}
+	}
+	return "(" + t.value(p) + " IN (" + strings.Join(values, ", ") + "))", true
+}
+
+// sizeOf returns the path of the field n takes the size of
+func sizeOf(n ast.Expr) ([]string, bool) {
+	if n.Kind() != ast.CallKind || n.AsCall().FunctionName() != overloads.Size {
+		return nil, false
+	}
+	c := n.AsCall()
+	if c.IsMemberFunction() {
+		return fieldPath(c.Target())
+	}
+	if len(c.Args()) != 1 {
+		return nil, false
+	}
+	return fieldPath(c.Args()[0])
+}
+
+func (t *translator) compare(op string, left, right ast.Expr) (string, bool) {
+	if left.Kind() == ast.LiteralKind {
+		left, right, op = right, left, flipped[op]
+	}
+	lit := literal(right)
+	if lit == nil {
+		return "", false
+	}
+
+	// Sizes compare as numbers; size() fails for anything but lists,
+	// strings and objects
+	if p, ok := sizeOf(left); ok {
+		switch lit.(type) {
+		case int64, uint64, float64:
+		default:
+			return "", false
+		}
+		v := t.value(p)
+		size := fmt.Sprintf("(CASE jsonb_typeof(%[1]s) WHEN 'array' THEN jsonb_array_length(%[1]s) WHEN 'string' THEN char_length(%[1]s #>> '{}') WHEN 'object' THEN (SELECT count(*) FROM jsonb_object_keys(%[1]s)) END)", v)
+		return fmt.Sprintf("(%s %s %s)", size, sqlOps[op], t.arg(lit)), true
+	}
+	p, ok := fieldPath(left)
+	if !ok {
+		return "", false
+	}
+	v := t.value(p)
+	if op == operators.Equals || op == operators.NotEquals {
+		r, ok := t.jsonLiteral(right)
+		return fmt.Sprintf("(%s %s %s)", v, sqlOps[op], r), ok
+	}
+
+	// Ordering holds only between two strings or two numbers, and fails
+	// otherwise; CASE keeps other values from being cast
+	switch lit := lit.(type) {
+	case string:
+		return fmt.Sprintf("(CASE WHEN jsonb_typeof(%[1]s) = 'string' THEN (%[1]s #>> '{}') %s %s END)", v, sqlOps[op], t.arg(lit)), true
+	case int64, uint64, float64:
+		return fmt.Sprintf("(CASE WHEN jsonb_typeof(%[1]s) = 'number' THEN (%[1]s #>> '{}')::DECIMAL %s %s END)", v, sqlOps[op], t.arg(lit)), true
+	}
+	return "", false
+}
@@ -0,0 +1,190 @@
+// Package faults injects storage failures for resilience testing.
+//
+// Wrap decorates a repository with added latency, transient errors before
+// calls reach the backend and partial failures: writes that the backend
+// applies but that are reported as failed, as when a connection drops
+// before a commit is acknowledged. Injected errors are transient (see
+// storage.IsTransient), so they exercise retries, the circuit breaker and
+// the error paths of handlers like real backend failures do. It is meant
+// for test and staging instances only.
+package faults
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// ErrInjected is returned for calls failed by fault injection
+var ErrInjected = errors.New("injected storage fault")
+
+func init() {
+	storage.RegisterTransient(func(err error) bool { return errors.Is(err, ErrInjected) })
+}
+
+// metrics counts injected faults under /debug/vars
+var metrics = expvar.NewMap("faults")
+
+// Operations lists the repository methods faults can be limited to
+var Operations = []string{
+	"CreateRAiD", "GetRAiD", "GetRAiDs", "GetRAiDVersion", "UpdateRAiD",
+	"ListRAiDs", "ListPublicRAiDs", "GetRAiDHistory", "GetRAiDChanges",
+	"DeleteRAiD", "GenerateIdentifier",
+	"CreateServicePoint", "GetServicePoint", "UpdateServicePoint",
+	"ListServicePoints", "DeleteServicePoint",
+	"HealthCheck",
+}
+
+// Options configures the injected faults
+type Options struct {
+	// Latency is added to every call, plus a random share of Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorPercent is the share of calls, 0 to 100, failing before they
+	// reach the backend
+	ErrorPercent int
+	// PartialPercent is the share of successful writes, 0 to 100,
+	// reported as failed
+	PartialPercent int
+	// Operations limits faults to these methods; empty for all
+	Operations []string
+}
+
+// Wrap returns a repository injecting faults into the calls to repo
+func Wrap(repo storage.Repository, opts Options) storage.Repository {
+	r := &repository{Repository: repo, opts: opts}
+	if len(opts.Operations) > 0 {
+		r.ops = make(map[string]bool, len(opts.Operations))
+		for _, op := range opts.Operations {
+			r.ops[op] = true
+		}
+	}
+	return r
+}
+
+type repository struct {
+	storage.Repository
+	opts Options
+	// ops holds the methods faults are limited to, nil for all
+	ops map[string]bool
+}
+
+// call runs fn, the method op, with faults injected; write marks methods
+// that change the backend
+func call[T any](ctx context.Context, r *repository, op string, write bool, fn func() (T, error)) (T, error) {
+	var zero T
+	if r.ops != nil && !r.ops[op] {
+		return fn()
+	}
+
+	if delay := r.opts.Latency + rand.N(r.opts.Jitter+1); delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if rand.IntN(100) < r.opts.ErrorPercent {
+		metrics.Add("errors", 1)
+		return zero, fmt.Errorf("%s: %w", op, ErrInjected)
+	}
+
+	v, err := fn()
+	if err == nil && write && rand.IntN(100) < r.opts.PartialPercent {
+		metrics.Add("partial", 1)
+		return zero, fmt.Errorf("%s applied but reported failed: %w", op, ErrInjected)
+	}
+	return v, err
+}
+
+// exec adapts calls without a result to call
+func exec(ctx context.Context, r *repository, op string, write bool, fn func() error) error {
+	_, err := call(ctx, r, op, write, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	return call(ctx, r, "CreateRAiD", true, func() (*models.RAiD, error) { return r.Repository.CreateRAiD(ctx, raid) })
+}
+
+func (r *repository) GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+	return call(ctx, r, "GetRAiD", false, func() (*models.RAiD, error) { return r.Repository.GetRAiD(ctx, prefix, suffix) })
+}
+
+func (r *repository) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	return call(ctx, r, "GetRAiDs", false, func() ([]*models.RAiD, error) { return r.Repository.GetRAiDs(ctx, refs) })
+}
+
+func (r *repository) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
+	return call(ctx, r, "GetRAiDVersion", false, func() (*models.RAiD, error) {
+		return r.Repository.GetRAiDVersion(ctx, prefix, suffix, version)
+	})
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	return call(ctx, r, "UpdateRAiD", true, func() (*models.RAiD, error) { return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid) })
+}
+
+func (r *repository) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return call(ctx, r, "ListRAiDs", false, func() ([]*models.RAiD, error) { return r.Repository.ListRAiDs(ctx, filter) })
+}
+
+func (r *repository) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return call(ctx, r, "ListPublicRAiDs", false, func() ([]*models.RAiD, error) { return r.Repository.ListPublicRAiDs(ctx, filter) })
+}
+
+func (r *repository) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
+	return call(ctx, r, "GetRAiDHistory", false, func() ([]*models.RAiD, error) { return r.Repository.GetRAiDHistory(ctx, prefix, suffix) })
+}
+
+func (r *repository) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	return call(ctx, r, "GetRAiDChanges", false, func() ([]storage.VersionChange, error) {
+		return r.Repository.GetRAiDChanges(ctx, prefix, suffix, page)
+	})
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	return exec(ctx, r, "DeleteRAiD", true, func() error { return r.Repository.DeleteRAiD(ctx, prefix, suffix) })
+}
+
+func (r *repository) GenerateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
+	id, err := call(ctx, r, "GenerateIdentifier", true, func() ([2]string, error) {
+		prefix, suffix, err := r.Repository.GenerateIdentifier(ctx, servicePointID)
+		return [2]string{prefix, suffix}, err
+	})
+	return id[0], id[1], err
+}
+
+func (r *repository) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	return call(ctx, r, "CreateServicePoint", true, func() (*models.ServicePoint, error) { return r.Repository.CreateServicePoint(ctx, sp) })
+}
+
+func (r *repository) GetServicePoint(ctx context.Context, id int64) (*models.ServicePoint, error) {
+	return call(ctx, r, "GetServicePoint", false, func() (*models.ServicePoint, error) { return r.Repository.GetServicePoint(ctx, id) })
+}
+
+func (r *repository) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	return call(ctx, r, "UpdateServicePoint", true, func() (*models.ServicePoint, error) {
+		return r.Repository.UpdateServicePoint(ctx, id, sp)
+	})
+}
+
+func (r *repository) ListServicePoints(ctx context.Context) ([]*models.ServicePoint, error) {
+	return call(ctx, r, "ListServicePoints", false, func() ([]*models.ServicePoint, error) { return r.Repository.ListServicePoints(ctx) })
+}
+
+func (r *repository) DeleteServicePoint(ctx context.Context, id int64) error {
+	return exec(ctx, r, "DeleteServicePoint", true, func() error { return r.Repository.DeleteServicePoint(ctx, id) })
+}
+
+func (r *repository) HealthCheck(ctx context.Context) error {
+	return exec(ctx, r, "HealthCheck", false, func() error { return r.Repository.HealthCheck(ctx) })
+}
@@ -0,0 +1,69 @@
+package faults
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/resilience"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestWrap(t *testing.T) {
+	ctx := context.Background()
+	repo := testutil.NewMockRepository()
+	raid := testutil.NewTestRAiD("10.12345", "a")
+
+	// Failing calls never reach the backend
+	wrapped := Wrap(repo, Options{ErrorPercent: 100})
+	if _, err := wrapped.CreateRAiD(ctx, raid); !errors.Is(err, ErrInjected) || !storage.IsTransient(err) {
+		t.Errorf("expected a transient injected error, got %v", err)
+	}
+	if repo.CreateRAiDCalls != 0 {
+		t.Errorf("expected no backend call, got %d", repo.CreateRAiDCalls)
+	}
+
+	// Partial failures reach the backend; reads are not affected
+	wrapped = Wrap(repo, Options{PartialPercent: 100})
+	if _, err := wrapped.CreateRAiD(ctx, raid); !errors.Is(err, ErrInjected) {
+		t.Errorf("expected an injected error, got %v", err)
+	}
+	if repo.CreateRAiDCalls != 1 {
+		t.Errorf("expected the write to reach the backend, got %d calls", repo.CreateRAiDCalls)
+	}
+	if _, err := wrapped.GetRAiD(ctx, "10.12345", "a"); err != nil {
+		t.Errorf("expected reads to succeed, got %v", err)
+	}
+
+	// Only the listed operations fail
+	wrapped = Wrap(repo, Options{ErrorPercent: 100, Operations: []string{"UpdateRAiD"}})
+	if _, err := wrapped.CreateRAiD(ctx, raid); err != nil {
+		t.Errorf("expected CreateRAiD to pass, got %v", err)
+	}
+	if _, err := wrapped.UpdateRAiD(ctx, "10.12345", "a", raid); !errors.Is(err, ErrInjected) {
+		t.Errorf("expected UpdateRAiD to fail, got %v", err)
+	}
+
+	// Latency gives way to the caller's deadline
+	wrapped = Wrap(repo, Options{Latency: time.Minute})
+	deadline, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if _, err := wrapped.GetRAiD(deadline, "10.12345", "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to end the delay, got %v", err)
+	}
+}
+
+func TestWrap_OpensBreaker(t *testing.T) {
+	breaker := resilience.NewBreaker(2, time.Minute)
+	wrapped := resilience.Wrap(Wrap(testutil.NewMockRepository(), Options{ErrorPercent: 100}),
+		resilience.Policy{MaxRetries: 1, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, breaker)
+
+	if _, err := wrapped.GetRAiD(context.Background(), "10.12345", "a"); !errors.Is(err, storage.ErrBackendUnavailable) {
+		t.Errorf("expected the backend to be reported unavailable, got %v", err)
+	}
+	if _, err := wrapped.GetRAiD(context.Background(), "10.12345", "a"); !errors.Is(err, resilience.ErrCircuitOpen) {
+		t.Errorf("expected the breaker to be open after the retried failures, got %v", err)
+	}
+}
@@ -0,0 +1,583 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Request is a GraphQL request as sent over HTTP
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of executing a request. Data is nil when the
+// request could not be executed at all, in which case Errors says why.
+type Response struct {
+	Data   any      `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Error is a GraphQL error. Path is set for errors raised while resolving a
+// field and names the field in the response.
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Execute runs the query in req against the schema
+func (s *Schema) Execute(ctx context.Context, req Request) *Response {
+	doc, err := parse(req.Query)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+	if op.kind != "query" {
+		return &Response{Errors: []*Error{{Message: fmt.Sprintf("Only query operations are supported, not %s.", op.kind), Locations: []Location{op.loc}}}}
+	}
+
+	vars, err := coerceVariables(op, req.Variables)
+	if err != nil {
+		return &Response{Errors: []*Error{toError(err)}}
+	}
+
+	maxDepth := s.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	v := &validator{doc: doc, op: op, vars: vars, maxDepth: maxDepth, spreading: make(map[string]bool)}
+	v.selections(s.Query, op.selections, 1)
+	if len(v.errors) > 0 {
+		return &Response{Errors: v.errors}
+	}
+
+	e := &executor{doc: doc, vars: vars}
+	fields := e.collectFields(s.Query, op.selections)
+	data, ok := e.executeFields(ctx, s.Query, nil, fields, nil)
+	resp := &Response{Data: data, Errors: e.errors}
+	if !ok {
+		resp.Data = json.RawMessage("null")
+	}
+	return resp
+}
+
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
+
+// operation selects the operation to run
+func (d *document) operation(name string) (*operation, error) {
+	if name == "" {
+		if len(d.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+// coerceVariables applies defaults and checks that required variables are
+// given. Values are coerced to argument types where they are used.
+func coerceVariables(op *operation, given map[string]any) (map[string]any, error) {
+	vars := make(map[string]any, len(op.vars))
+	for _, def := range op.vars {
+		if v, ok := given[def.name]; ok {
+			if v == nil && def.typ.nonNull {
+				return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" of non-null type %q must not be null.", def.name, def.typ)}
+			}
+			vars[def.name] = v
+			continue
+		}
+		if def.def != nil {
+			v, err := def.def.eval(nil)
+			if err != nil {
+				return nil, err
+			}
+			vars[def.name] = v
+			continue
+		}
+		if def.typ.nonNull {
+			return nil, &Error{Message: fmt.Sprintf("Variable \"$%s\" of required type %q was not provided.", def.name, def.typ)}
+		}
+	}
+	return vars, nil
+}
+
+// validator checks a query against the schema before it is executed
+type validator struct {
+	doc       *document
+	op        *operation
+	vars      map[string]any
+	maxDepth  int
+	spreading map[string]bool
+	errors    []*Error
+}
+
+func (v *validator) fail(loc Location, format string, args ...any) {
+	v.errors = append(v.errors, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+}
+
+func (v *validator) selections(obj *Object, sels []selection, depth int) {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			v.directives(sel.directives)
+			v.field(obj, sel, depth)
+		case *fragmentSpread:
+			v.directives(sel.directives)
+			frag, ok := v.doc.fragments[sel.name]
+			if !ok {
+				v.fail(sel.loc, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			if frag.typeCond != obj.Name {
+				v.fail(sel.loc, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", sel.name, obj.Name, frag.typeCond)
+				continue
+			}
+			if v.spreading[sel.name] {
+				v.fail(sel.loc, "Cannot spread fragment %q within itself.", sel.name)
+				continue
+			}
+			v.spreading[sel.name] = true
+			v.selections(obj, frag.selections, depth)
+			delete(v.spreading, sel.name)
+		case *inlineFragment:
+			v.directives(sel.directives)
+			if sel.typeCond != "" && sel.typeCond != obj.Name {
+				v.fail(sel.loc, "Fragment cannot be spread here as objects of type %q can never be of type %q.", obj.Name, sel.typeCond)
+				continue
+			}
+			v.selections(obj, sel.selections, depth)
+		}
+	}
+}
+
+func (v *validator) field(obj *Object, f *field, depth int) {
+	if depth > v.maxDepth {
+		v.fail(f.loc, "Query exceeds the maximum depth of %d.", v.maxDepth)
+		return
+	}
+
+	var (
+		typ  Type = String
+		args []*Argument
+	)
+	if f.name != "__typename" {
+		def := obj.field(f.name)
+		if def == nil {
+			v.fail(f.loc, "Cannot query field %q on type %q.", f.name, obj.Name)
+			return
+		}
+		typ, args = def.Type, def.Args
+	}
+
+	given := make(map[string]bool, len(f.args))
+	for _, a := range f.args {
+		given[a.name] = true
+		v.variables(a.value)
+		if !hasArg(args, a.name) {
+			v.fail(f.loc, "Unknown argument %q on field \"%s.%s\".", a.name, obj.Name, f.name)
+		}
+	}
+	for _, a := range args {
+		if _, required := a.Type.(NonNull); required && a.Default == nil && !given[a.Name] {
+			v.fail(f.loc, "Field \"%s.%s\" argument %q of type %q is required, but it was not provided.", obj.Name, f.name, a.Name, a.Type)
+		}
+	}
+
+	switch t := namedType(typ).(type) {
+	case *Object:
+		if len(f.selections) == 0 {
+			v.fail(f.loc, "Field %q of type %q must have a selection of subfields.", f.name, typ)
+			return
+		}
+		v.selections(t, f.selections, depth+1)
+	default:
+		if len(f.selections) > 0 {
+			v.fail(f.loc, "Field %q must not have a selection since type %q has no subfields.", f.name, typ)
+		}
+	}
+}
+
+func (v *validator) directives(dirs []*directive) {
+	for _, d := range dirs {
+		if d.name != "include" && d.name != "skip" {
+			v.fail(d.loc, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		if len(d.args) != 1 || d.args[0].name != "if" {
+			v.fail(d.loc, "Directive \"@%s\" argument \"if\" of type \"Boolean!\" is required.", d.name)
+			continue
+		}
+		v.variables(d.args[0].value)
+	}
+}
+
+// variables checks that every variable in val is defined by the operation
+func (v *validator) variables(val *value) {
+	switch val.kind {
+	case valueVariable:
+		if _, ok := v.vars[val.raw]; !ok {
+			// Optional variables without a default are absent from vars,
+			// so only report the ones the operation does not declare
+			if !v.declared(val.raw) {
+				v.fail(val.loc, "Variable \"$%s\" is not defined.", val.raw)
+			}
+		}
+	case valueList:
+		for _, item := range val.list {
+			v.variables(item)
+		}
+	case valueObject:
+		for _, f := range val.fields {
+			v.variables(f.value)
+		}
+	}
+}
+
+func (v *validator) declared(name string) bool {
+	for _, def := range v.op.vars {
+		if def.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func hasArg(args []*Argument, name string) bool {
+	for _, a := range args {
+		if a.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// executor resolves a validated query
+type executor struct {
+	doc    *document
+	vars   map[string]any
+	errors []*Error
+}
+
+func (e *executor) fail(f *field, path []any, format string, args ...any) {
+	e.errors = append(e.errors, &Error{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: []Location{f.loc},
+		Path:      append([]any(nil), path...),
+	})
+}
+
+// fieldSet is the fields selected on an object, grouped by response key in
+// the order they first appear
+type fieldSet struct {
+	keys   []string
+	fields map[string][]*field
+}
+
+func (e *executor) collectFields(obj *Object, sels []selection) *fieldSet {
+	set := &fieldSet{fields: make(map[string][]*field)}
+	e.collect(obj, sels, set, make(map[string]bool))
+	return set
+}
+
+func (e *executor) collect(obj *Object, sels []selection, set *fieldSet, visited map[string]bool) {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *field:
+			if !e.included(sel.directives) {
+				continue
+			}
+			key := sel.key()
+			if _, ok := set.fields[key]; !ok {
+				set.keys = append(set.keys, key)
+			}
+			set.fields[key] = append(set.fields[key], sel)
+		case *fragmentSpread:
+			if !e.included(sel.directives) || visited[sel.name] {
+				continue
+			}
+			visited[sel.name] = true
+			e.collect(obj, e.doc.fragments[sel.name].selections, set, visited)
+		case *inlineFragment:
+			if !e.included(sel.directives) {
+				continue
+			}
+			e.collect(obj, sel.selections, set, visited)
+		}
+	}
+}
+
+// included evaluates @include and @skip
+func (e *executor) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		v, _ := d.args[0].value.eval(e.vars)
+		b, _ := v.(bool)
+		if d.name == "include" && !b || d.name == "skip" && b {
+			return false
+		}
+	}
+	return true
+}
+
+// executeFields resolves the fields of an object. It returns false when a
+// non-null field resolved to null, which makes the object itself null.
+func (e *executor) executeFields(ctx context.Context, obj *Object, source any, fields *fieldSet, path []any) (*orderedMap, bool) {
+	out := &orderedMap{values: make(map[string]any, len(fields.keys))}
+	for _, key := range fields.keys {
+		v, ok := e.executeField(ctx, obj, source, fields.fields[key], append(path, key))
+		if !ok {
+			return nil, false
+		}
+		out.set(key, v)
+	}
+	return out, true
+}
+
+func (e *executor) executeField(ctx context.Context, obj *Object, source any, fields []*field, path []any) (any, bool) {
+	f := fields[0]
+	if f.name == "__typename" {
+		return obj.Name, true
+	}
+	def := obj.field(f.name)
+	_, nonNull := def.Type.(NonNull)
+
+	args, err := e.arguments(def, f)
+	if err != nil {
+		e.fail(f, path, "%v", err)
+		return nil, !nonNull
+	}
+	resolve := def.Resolve
+	if resolve == nil {
+		resolve = defaultResolve(def.Name)
+	}
+	v, err := resolve(ctx, source, args)
+	if err != nil {
+		e.fail(f, path, "%v", err)
+		return nil, !nonNull
+	}
+	return e.complete(ctx, def.Type, fields, v, path)
+}
+
+// arguments coerces the arguments of f to the types declared by def
+func (e *executor) arguments(def *Field, f *field) (map[string]any, error) {
+	args := make(map[string]any, len(def.Args))
+	for _, a := range def.Args {
+		if a.Default != nil {
+			args[a.Name] = a.Default
+		}
+	}
+	for _, a := range f.args {
+		if a.value.kind == valueVariable {
+			if _, ok := e.vars[a.value.raw]; !ok {
+				continue
+			}
+		}
+		raw, err := a.value.eval(e.vars)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q has invalid value: %v", a.name, err)
+		}
+		var typ Type
+		for _, da := range def.Args {
+			if da.Name == a.name {
+				typ = da.Type
+			}
+		}
+		v, err := coerceInput(typ, raw)
+		if err != nil {
+			return nil, fmt.Errorf("Argument %q has invalid value: %v", a.name, err)
+		}
+		if v == nil {
+			delete(args, a.name)
+			continue
+		}
+		args[a.name] = v
+	}
+	return args, nil
+}
+
+func coerceInput(t Type, v any) (any, error) {
+	switch t := t.(type) {
+	case NonNull:
+		if v == nil {
+			return nil, fmt.Errorf("expected a non-null %s", t.Of)
+		}
+		return coerceInput(t.Of, v)
+	case List:
+		if v == nil {
+			return nil, nil
+		}
+		items, ok := v.([]any)
+		if !ok {
+			items = []any{v}
+		}
+		out := make([]any, 0, len(items))
+		for _, item := range items {
+			x, err := coerceInput(t.Of, item)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, x)
+		}
+		return out, nil
+	case *Scalar:
+		if v == nil {
+			return nil, nil
+		}
+		return t.Coerce(v)
+	}
+	return nil, fmt.Errorf("%s is not an input type", t)
+}
+
+// complete converts a resolved value to its response form. It returns false
+// when a non-null value is null, so the parent must be null too.
+func (e *executor) complete(ctx context.Context, t Type, fields []*field, v any, path []any) (any, bool) {
+	if nn, ok := t.(NonNull); ok {
+		before := len(e.errors)
+		out, ok := e.complete(ctx, nn.Of, fields, v, path)
+		if !ok || out != nil {
+			return out, ok
+		}
+		if len(e.errors) == before {
+			e.fail(fields[0], path, "Cannot return null for non-nullable field %q.", fields[0].name)
+		}
+		return nil, false
+	}
+	if isNil(v) {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case List:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fail(fields[0], path, "Expected a list for field %q, got %T.", fields[0].name, v)
+			return nil, true
+		}
+		out := make([]any, rv.Len())
+		for i := range out {
+			item, ok := e.complete(ctx, t.Of, fields, rv.Index(i).Interface(), append(path, i))
+			if !ok {
+				return nil, true
+			}
+			out[i] = item
+		}
+		return out, true
+	case *Scalar:
+		rv := reflect.ValueOf(v)
+		for rv.Kind() == reflect.Pointer {
+			rv = rv.Elem()
+		}
+		out, err := t.Serialize(rv.Interface())
+		if err != nil {
+			e.fail(fields[0], path, "%v", err)
+			return nil, true
+		}
+		return out, true
+	case *Object:
+		var sels []selection
+		for _, f := range fields {
+			sels = append(sels, f.selections...)
+		}
+		out, ok := e.executeFields(ctx, t, v, e.collectFields(t, sels), path)
+		if !ok {
+			return nil, true
+		}
+		return out, true
+	}
+	return nil, true
+}
+
+func isNil(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// defaultResolve returns the map entry or struct field of the source named
+// name. Struct fields are matched on their json tag, then their Go name.
+func defaultResolve(name string) ResolveFunc {
+	return func(_ context.Context, source any, _ map[string]any) (any, error) {
+		if m, ok := source.(map[string]any); ok {
+			return m[name], nil
+		}
+		rv := reflect.ValueOf(source)
+		for rv.Kind() == reflect.Pointer {
+			if rv.IsNil() {
+				return nil, nil
+			}
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("cannot resolve %q on %T", name, source)
+		}
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			sf := rt.Field(i)
+			tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if tag == name || tag == "" && strings.EqualFold(sf.Name, name) {
+				return rv.Field(i).Interface(), nil
+			}
+		}
+		return nil, fmt.Errorf("cannot resolve %q on %T", name, source)
+	}
+}
+
+// orderedMap is a JSON object that keeps the order fields were selected in
+type orderedMap struct {
+	keys   []string
+	values map[string]any
+}
+
+func (m *orderedMap) set(key string, v any) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+// MarshalJSON writes the fields in selection order
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		v, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
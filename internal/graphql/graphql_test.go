@@ -0,0 +1,189 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type book struct {
+	Title  string `json:"title"`
+	Pages  int    `json:"pages"`
+	Author *person
+}
+
+type person struct {
+	Name string `json:"name"`
+}
+
+func testSchema() *Schema {
+	authorType := &Object{Name: "Person", Fields: []*Field{{Name: "name", Type: NonNull{String}}}}
+	bookType := &Object{Name: "Book", Description: "A book", Fields: []*Field{
+		{Name: "title", Type: NonNull{String}},
+		{Name: "pages", Type: Int},
+		{Name: "author", Type: authorType},
+		{Name: "broken", Type: NonNull{String}, Resolve: func(context.Context, any, map[string]any) (any, error) {
+			return nil, errors.New("broken")
+		}},
+	}}
+	books := []*book{
+		{Title: "A", Pages: 10, Author: &person{Name: "X"}},
+		{Title: "B", Pages: 20},
+		{Title: "C", Pages: 30},
+	}
+	return &Schema{MaxDepth: 2, Query: &Object{Name: "Query", Fields: []*Field{
+		{
+			Name: "books",
+			Type: NonNull{List{NonNull{bookType}}},
+			Args: []*Argument{{Name: "first", Type: Int, Default: 2}, {Name: "title", Type: String}},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				var out []*book
+				for _, b := range books {
+					if t, ok := args["title"].(string); ok && b.Title != t {
+						continue
+					}
+					out = append(out, b)
+				}
+				if n := args["first"].(int); n < len(out) {
+					out = out[:n]
+				}
+				return out, nil
+			},
+		},
+		{
+			Name: "book",
+			Type: bookType,
+			Args: []*Argument{{Name: "title", Type: NonNull{String}}},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				for _, b := range books {
+					if b.Title == args["title"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+		},
+	}}}
+}
+
+func execute(t *testing.T, req Request) string {
+	t.Helper()
+	data, err := json.Marshal(testSchema().Execute(context.Background(), req))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want string
+	}{
+		{
+			"default argument",
+			Request{Query: `{ books { title } }`},
+			`{"data":{"books":[{"title":"A"},{"title":"B"}]}}`,
+		},
+		{
+			"aliases",
+			Request{Query: `query { first: book(title: "A") { pages } none: book(title: "Z") { pages } }`},
+			`{"data":{"first":{"pages":10},"none":null}}`,
+		},
+		{
+			"variables",
+			Request{Query: `query Q($n: Int = 1, $t: String) { books(first: $n, title: $t) { title } }`, Variables: map[string]any{"n": float64(3)}},
+			`{"data":{"books":[{"title":"A"},{"title":"B"},{"title":"C"}]}}`,
+		},
+		{
+			"fragments and directives",
+			Request{Query: `query($skip: Boolean!) { books(first: 1) { ...F ... on Book { pages @skip(if: $skip) } __typename } } fragment F on Book { title }`, Variables: map[string]any{"skip": true}},
+			`{"data":{"books":[{"title":"A","__typename":"Book"}]}}`,
+		},
+		{
+			"operation name",
+			Request{Query: `query A { books { title } } query B { book(title: "C") { title } }`, OperationName: "B"},
+			`{"data":{"book":{"title":"C"}}}`,
+		},
+		{
+			"null propagation",
+			Request{Query: `{ book(title: "A") { title broken } }`},
+			`{"data":{"book":null},"errors":[{"message":"broken","locations":[{"line":1,"column":28}],"path":["book","broken"]}]}`,
+		},
+		{
+			"null propagation to root",
+			Request{Query: `{ books { broken } }`},
+			`{"data":null,"errors":[{"message":"broken","locations":[{"line":1,"column":11}],"path":["books",0,"broken"]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := execute(t, tt.req); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecute_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"syntax", `{ books { title }`, "Syntax Error"},
+		{"mutation", `mutation { books { title } }`, "Only query operations"},
+		{"unknown field", `{ books { isbn } }`, `Cannot query field "isbn" on type "Book".`},
+		{"unknown argument", `{ books(last: 1) { title } }`, `Unknown argument "last"`},
+		{"missing argument", `{ book { title } }`, `argument "title" of type "String!" is required`},
+		{"missing subselection", `{ books }`, "must have a selection of subfields"},
+		{"leaf subselection", `{ books { title { x } } }`, "must not have a selection"},
+		{"unknown fragment", `{ books { ...F } }`, `Unknown fragment "F".`},
+		{"fragment cycle", `{ books { ...F } } fragment F on Book { ...F }`, "within itself"},
+		{"fragment type", `{ books { ...F } } fragment F on Person { name }`, "can never be of type"},
+		{"undefined variable", `{ books(first: $n) { title } }`, `Variable "$n" is not defined.`},
+		{"required variable", `query($n: Int!) { books(first: $n) { title } }`, "was not provided"},
+		{"depth", `{ book(title: "A") { author { name } } }`, "maximum depth of 2"},
+		{"argument type", `{ books(first: "two") { title } }`, `Argument "first" has invalid value`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := testSchema().Execute(context.Background(), Request{Query: tt.query})
+			if len(resp.Errors) == 0 || !strings.Contains(resp.Errors[0].Message, tt.want) {
+				t.Errorf("expected an error containing %q, got %+v", tt.want, resp.Errors)
+			}
+		})
+	}
+}
+
+func TestSDL(t *testing.T) {
+	want := `schema {
+  query: Query
+}
+
+type Query {
+  books(first: Int = 2, title: String): [Book!]!
+  book(title: String!): Book
+}
+
+"""A book"""
+type Book {
+  title: String!
+  pages: Int
+  author: Person
+  broken: String!
+}
+
+type Person {
+  name: String!
+}
+`
+	if got := testSchema().SDL(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
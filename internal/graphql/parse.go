@@ -0,0 +1,752 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Location is a position in the query document
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string
+	name       string
+	vars       []*varDef
+	selections []selection
+	loc        Location
+}
+
+type varDef struct {
+	name string
+	typ  *typeRef
+	def  *value
+}
+
+// typeRef is a type in a variable definition, e.g. [String!]!
+type typeRef struct {
+	name    string
+	elem    *typeRef
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+// key is the name of the field in the response
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	loc        Location
+}
+
+type inlineFragment struct {
+	typeCond   string
+	directives []*directive
+	selections []selection
+	loc        Location
+}
+
+type fragment struct {
+	name       string
+	typeCond   string
+	selections []selection
+	loc        Location
+}
+
+type argument struct {
+	name  string
+	value *value
+}
+
+type directive struct {
+	name string
+	args []*argument
+	loc  Location
+}
+
+type valueKind int
+
+const (
+	valueVariable valueKind = iota
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueNull
+	valueEnum
+	valueList
+	valueObject
+)
+
+type value struct {
+	kind   valueKind
+	raw    string
+	list   []*value
+	fields []*argument
+	loc    Location
+}
+
+// eval returns the Go value of a literal, substituting variables
+func (v *value) eval(vars map[string]any) (any, error) {
+	switch v.kind {
+	case valueVariable:
+		return vars[v.raw], nil
+	case valueInt:
+		n, err := strconv.ParseInt(v.raw, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %s", v.raw)
+		}
+		return n, nil
+	case valueFloat:
+		return strconv.ParseFloat(v.raw, 64)
+	case valueString, valueEnum:
+		return v.raw, nil
+	case valueBoolean:
+		return v.raw == "true", nil
+	case valueNull:
+		return nil, nil
+	case valueList:
+		out := make([]any, 0, len(v.list))
+		for _, item := range v.list {
+			x, err := item.eval(vars)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, x)
+		}
+		return out, nil
+	case valueObject:
+		out := make(map[string]any, len(v.fields))
+		for _, f := range v.fields {
+			x, err := f.value.eval(vars)
+			if err != nil {
+				return nil, err
+			}
+			out[f.name] = x
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown value kind %d", v.kind)
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	loc  Location
+}
+
+// lexer splits a query into tokens, skipping whitespace, commas and comments
+type lexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func (l *lexer) loc() Location {
+	return Location{Line: l.line, Column: l.pos - l.lineStart + 1}
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.lineStart = l.pos
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return l.token()
+		}
+	}
+	return token{kind: tokEOF, loc: l.loc()}, nil
+}
+
+func (l *lexer) token() (token, error) {
+	loc := l.loc()
+	c := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, val: "...", loc: loc}, nil
+	case strings.IndexByte("!$()=:@[]{}|&", c) >= 0:
+		l.pos++
+		return token{kind: tokPunct, val: string(c), loc: loc}, nil
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, val: l.src[start:l.pos], loc: loc}, nil
+	case c == '-' || isDigit(c):
+		return l.number(loc)
+	case c == '"':
+		return l.string(loc)
+	}
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	return token{}, &Error{Message: fmt.Sprintf("Syntax Error: unexpected character %q", r), Locations: []Location{loc}}
+}
+
+func (l *lexer) number(loc Location) (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, &Error{Message: "Syntax Error: invalid number", Locations: []Location{loc}}
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		kind = tokFloat
+		if digits() == 0 {
+			return token{}, &Error{Message: "Syntax Error: invalid number", Locations: []Location{loc}}
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		kind = tokFloat
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, &Error{Message: "Syntax Error: invalid number", Locations: []Location{loc}}
+		}
+	}
+	return token{kind: kind, val: l.src[start:l.pos], loc: loc}, nil
+}
+
+func (l *lexer) string(loc Location) (token, error) {
+	unterminated := &Error{Message: "Syntax Error: unterminated string", Locations: []Location{loc}}
+
+	if strings.HasPrefix(l.src[l.pos:], `"""`) {
+		end := strings.Index(l.src[l.pos+3:], `"""`)
+		if end < 0 {
+			return token{}, unterminated
+		}
+		raw := l.src[l.pos+3 : l.pos+3+end]
+		for _, c := range raw {
+			if c == '\n' {
+				l.line++
+			}
+		}
+		l.pos += 3 + end + 3
+		if i := strings.LastIndexByte(l.src[:l.pos], '\n'); i >= 0 {
+			l.lineStart = i + 1
+		}
+		return token{kind: tokString, val: strings.TrimSpace(raw), loc: loc}, nil
+	}
+
+	var b strings.Builder
+	l.pos++
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return token{kind: tokString, val: b.String(), loc: loc}, nil
+		case c == '\n':
+			return token{}, unterminated
+		case c == '\\':
+			if l.pos+1 >= len(l.src) {
+				return token{}, unterminated
+			}
+			esc := l.src[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, unterminated
+				}
+				r, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, &Error{Message: "Syntax Error: invalid unicode escape", Locations: []Location{l.loc()}}
+				}
+				b.WriteRune(rune(r))
+				l.pos += 4
+			default:
+				return token{}, &Error{Message: fmt.Sprintf("Syntax Error: invalid escape \\%c", esc), Locations: []Location{l.loc()}}
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return token{}, unterminated
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+// parser is a recursive descent parser for executable GraphQL documents
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+// parse parses a query document
+func parse(query string) (*document, error) {
+	p := &parser{lex: &lexer{src: strings.TrimPrefix(query, "\uFEFF"), line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	doc := &document{fragments: make(map[string]*fragment)}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peek("{"), p.tok.kind == tokName && (p.tok.val == "query" || p.tok.val == "mutation" || p.tok.val == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case p.tok.kind == tokName && p.tok.val == "fragment":
+			f, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.fragments[f.name]; dup {
+				return nil, &Error{Message: fmt.Sprintf("There can be only one fragment named %q.", f.name), Locations: []Location{f.loc}}
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "Document does not contain an operation"}
+	}
+	return doc, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokPunct && p.tok.val == punct
+}
+
+func (p *parser) unexpected() error {
+	desc := fmt.Sprintf("%q", p.tok.val)
+	if p.tok.kind == tokEOF {
+		desc = "<EOF>"
+	}
+	return &Error{Message: "Syntax Error: unexpected " + desc, Locations: []Location{p.tok.loc}}
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.val
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*operation, error) {
+	op := &operation{kind: "query", loc: p.tok.loc}
+	if p.peek("{") {
+		sels, err := p.selectionSet()
+		op.selections = sels
+		return op, err
+	}
+
+	op.kind = p.tok.val
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.name = p.tok.val
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek(")") {
+			v, err := p.varDef()
+			if err != nil {
+				return nil, err
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.selectionSet()
+	op.selections = sels
+	return op, err
+}
+
+func (p *parser) varDef() (*varDef, error) {
+	if err := p.expect("$"); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	typ, err := p.typeRef()
+	if err != nil {
+		return nil, err
+	}
+	v := &varDef{name: name, typ: typ}
+	if p.peek("=") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if v.def, err = p.value(true); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (p *parser) typeRef() (*typeRef, error) {
+	t := &typeRef{}
+	if p.peek("[") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		elem, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		t.elem = elem
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		t.name = name
+	}
+	if p.peek("!") {
+		t.nonNull = true
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+func (p *parser) fragment() (*fragment, error) {
+	f := &fragment{loc: p.tok.loc}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if name == "on" {
+		return nil, &Error{Message: `Syntax Error: unexpected "on"`, Locations: []Location{f.loc}}
+	}
+	f.name = name
+	if p.tok.kind != tokName || p.tok.val != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if f.typeCond, err = p.name(); err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	f.selections, err = p.selectionSet()
+	return f, err
+}
+
+func (p *parser) selectionSet() ([]selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var sels []selection
+	for !p.peek("}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+	}
+	if len(sels) == 0 {
+		return nil, p.unexpected()
+	}
+	return sels, p.advance()
+}
+
+func (p *parser) selection() (selection, error) {
+	loc := p.tok.loc
+	if p.peek("...") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if p.tok.kind == tokName && p.tok.val != "on" {
+			spread := &fragmentSpread{name: p.tok.val, loc: loc}
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			var err error
+			spread.directives, err = p.directives()
+			return spread, err
+		}
+		inline := &inlineFragment{loc: loc}
+		if p.tok.kind == tokName {
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			var err error
+			if inline.typeCond, err = p.name(); err != nil {
+				return nil, err
+			}
+		}
+		var err error
+		if inline.directives, err = p.directives(); err != nil {
+			return nil, err
+		}
+		inline.selections, err = p.selectionSet()
+		return inline, err
+	}
+
+	f := &field{loc: loc}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek(":") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		f.alias = name
+		if name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	f.name = name
+	if f.args, err = p.arguments(false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = p.directives(); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if f.selections, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (p *parser) arguments(constant bool) ([]*argument, error) {
+	if !p.peek("(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var args []*argument
+	for !p.peek(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value(constant)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, &argument{name: name, value: v})
+	}
+	if len(args) == 0 {
+		return nil, p.unexpected()
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]*directive, error) {
+	var dirs []*directive
+	for p.peek("@") {
+		loc := p.tok.loc
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(false)
+		if err != nil {
+			return nil, err
+		}
+		dirs = append(dirs, &directive{name: name, args: args, loc: loc})
+	}
+	return dirs, nil
+}
+
+func (p *parser) value(constant bool) (*value, error) {
+	tok := p.tok
+	v := &value{raw: tok.val, loc: tok.loc}
+	switch {
+	case p.peek("$") && !constant:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		v.kind, v.raw = valueVariable, name
+		return v, nil
+	case p.peek("["):
+		v.kind = valueList
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek("]") {
+			item, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.list = append(v.list, item)
+		}
+		return v, p.advance()
+	case p.peek("{"):
+		v.kind = valueObject
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			fv, err := p.value(constant)
+			if err != nil {
+				return nil, err
+			}
+			v.fields = append(v.fields, &argument{name: name, value: fv})
+		}
+		return v, p.advance()
+	case tok.kind == tokInt:
+		v.kind = valueInt
+	case tok.kind == tokFloat:
+		v.kind = valueFloat
+	case tok.kind == tokString:
+		v.kind = valueString
+	case tok.kind == tokName:
+		switch tok.val {
+		case "true", "false":
+			v.kind = valueBoolean
+		case "null":
+			v.kind = valueNull
+		default:
+			v.kind = valueEnum
+		}
+	default:
+		return nil, p.unexpected()
+	}
+	return v, p.advance()
+}
@@ -0,0 +1,278 @@
+// Package graphql is a small GraphQL query engine. It executes query
+// operations - fields, aliases, arguments, variables, fragments and the
+// @include and @skip directives - against a schema of object types whose
+// fields are resolved by Go functions. Mutations, subscriptions, interfaces,
+// unions, input objects and introspection are not supported; Schema.SDL
+// describes the schema instead.
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Type is a GraphQL output type: a *Scalar, *Object, List or NonNull
+type Type interface {
+	String() string
+}
+
+// Scalar is a leaf type
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize converts a resolved value to its JSON representation
+	Serialize func(any) (any, error)
+	// Coerce converts an argument value to the Go value passed to resolvers
+	Coerce func(any) (any, error)
+}
+
+func (s *Scalar) String() string { return s.Name }
+
+// The built-in scalars. Int arguments are passed to resolvers as int,
+// Float as float64, String and ID as string and Boolean as bool.
+var (
+	Int     = &Scalar{Name: "Int", Serialize: serializeInt, Coerce: coerceInt}
+	Float   = &Scalar{Name: "Float", Serialize: serializeFloat, Coerce: coerceFloat}
+	String  = &Scalar{Name: "String", Serialize: serializeString, Coerce: coerceString}
+	Boolean = &Scalar{Name: "Boolean", Serialize: serializeBool, Coerce: coerceBool}
+	ID      = &Scalar{Name: "ID", Serialize: serializeString, Coerce: coerceID}
+)
+
+// List is a list of Of
+type List struct {
+	Of Type
+}
+
+func (l List) String() string { return "[" + l.Of.String() + "]" }
+
+// NonNull marks Of as never null
+type NonNull struct {
+	Of Type
+}
+
+func (n NonNull) String() string { return n.Of.String() + "!" }
+
+// Object is an object type. Fields may be assigned after construction so
+// that types can refer to each other.
+type Object struct {
+	Name        string
+	Description string
+	Fields      []*Field
+}
+
+func (o *Object) String() string { return o.Name }
+
+func (o *Object) field(name string) *Field {
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// Field is a field of an object type
+type Field struct {
+	Name        string
+	Description string
+	Type        Type
+	Args        []*Argument
+	// Resolve returns the field value for the parent value source. When nil
+	// the value is taken from the struct field with a matching json tag, or
+	// the map entry with a matching key.
+	Resolve ResolveFunc
+}
+
+// Argument is a field argument
+type Argument struct {
+	Name        string
+	Description string
+	Type        Type
+	// Default is used when the argument is not given
+	Default any
+}
+
+// ResolveFunc resolves a field. args holds every declared argument that was
+// given or has a default, coerced to its Go type.
+type ResolveFunc func(ctx context.Context, source any, args map[string]any) (any, error)
+
+// Schema is a set of types rooted at the query type
+type Schema struct {
+	Query *Object
+	// MaxDepth limits the nesting of selections; zero means DefaultMaxDepth
+	MaxDepth int
+}
+
+// DefaultMaxDepth is the selection depth allowed when Schema.MaxDepth is
+// not set
+const DefaultMaxDepth = 10
+
+// SDL returns the schema in the GraphQL schema definition language
+func (s *Schema) SDL() string {
+	var b strings.Builder
+	seen := map[*Object]bool{s.Query: true}
+	queue := []*Object{s.Query}
+	b.WriteString("schema {\n  query: " + s.Query.Name + "\n}\n")
+
+	for len(queue) > 0 {
+		obj := queue[0]
+		queue = queue[1:]
+
+		b.WriteString("\n")
+		writeDescription(&b, "", obj.Description)
+		b.WriteString("type " + obj.Name + " {\n")
+		for _, f := range obj.Fields {
+			writeDescription(&b, "  ", f.Description)
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, 0, len(f.Args))
+				for _, a := range f.Args {
+					arg := a.Name + ": " + a.Type.String()
+					if a.Default != nil {
+						arg += " = " + literal(a.Default)
+					}
+					args = append(args, arg)
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type.String() + "\n")
+
+			if o, ok := namedType(f.Type).(*Object); ok && !seen[o] {
+				seen[o] = true
+				queue = append(queue, o)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	return b.String()
+}
+
+func writeDescription(b *strings.Builder, indent, desc string) {
+	if desc != "" {
+		b.WriteString(indent + `"""` + desc + `"""` + "\n")
+	}
+}
+
+func literal(v any) string {
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(v)
+}
+
+// namedType strips List and NonNull wrappers
+func namedType(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case List:
+			t = w.Of
+		case NonNull:
+			t = w.Of
+		default:
+			return t
+		}
+	}
+}
+
+func serializeInt(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	}
+	return nil, fmt.Errorf("Int cannot represent %T", v)
+}
+
+func serializeFloat(v any) (any, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return serializeInt(v)
+}
+
+func serializeString(v any) (any, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano), nil
+	case fmt.Stringer:
+		return x.String(), nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return fmt.Sprint(rv.Int()), nil
+	}
+	return nil, fmt.Errorf("String cannot represent %T", v)
+}
+
+func serializeBool(v any) (any, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("Boolean cannot represent %T", v)
+}
+
+func coerceInt(v any) (any, error) {
+	switch x := v.(type) {
+	case int64:
+		if int64(int(x)) == x {
+			return int(x), nil
+		}
+	case float64:
+		if x == float64(int(x)) {
+			return int(x), nil
+		}
+	case int:
+		return x, nil
+	}
+	return nil, fmt.Errorf("Int cannot represent %v", v)
+}
+
+func coerceFloat(v any) (any, error) {
+	switch x := v.(type) {
+	case float64:
+		return x, nil
+	case int64:
+		return float64(x), nil
+	case int:
+		return float64(x), nil
+	}
+	return nil, fmt.Errorf("Float cannot represent %v", v)
+}
+
+func coerceString(v any) (any, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return nil, fmt.Errorf("String cannot represent %v", v)
+}
+
+func coerceID(v any) (any, error) {
+	switch x := v.(type) {
+	case string:
+		return x, nil
+	case int64, int:
+		return fmt.Sprint(x), nil
+	case float64:
+		if x == float64(int64(x)) {
+			return fmt.Sprint(int64(x)), nil
+		}
+	}
+	return nil, fmt.Errorf("ID cannot represent %v", v)
+}
+
+func coerceBool(v any) (any, error) {
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("Boolean cannot represent %v", v)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/leifj/go-raid/internal/activitypub"
+)
+
+// ActivityHandler publishes registry activity as ActivityStreams: the
+// registry's actor, its outbox of the mints and updates of public RAiDs
+// and, when follows are taken, its inbox and followers
+type ActivityHandler struct {
+	publisher *activitypub.Publisher
+	raids     *RAiDHandler
+}
+
+// NewActivityHandler creates a new activity handler, listing the public
+// RAiDs of raids in the outbox of publisher's actor
+func NewActivityHandler(publisher *activitypub.Publisher, raids *RAiDHandler) *ActivityHandler {
+	return &ActivityHandler{publisher: publisher, raids: raids}
+}
+
+// Actor handles GET /activitypub/actor - the registry's actor
+func (h *ActivityHandler) Actor(w http.ResponseWriter, r *http.Request) {
+	writeActivity(w, h.publisher.Actor())
+}
+
+// Outbox handles GET /activitypub/outbox - the Create and Update
+// activities of the most recently minted or updated public RAiDs, newest
+// first. It takes the parameters of GET /raid/feed.atom; no
+// authentication is needed.
+func (h *ActivityHandler) Outbox(w http.ResponseWriter, r *http.Request) {
+	raids, ok := h.raids.recentPublic(w, r)
+	if !ok {
+		return
+	}
+	outbox := activitypub.OrderedCollection{
+		JSONLDContext: activitypub.Context,
+		ID:            h.publisher.Base() + activitypub.OutboxPath,
+		Type:          "OrderedCollection",
+		TotalItems:    len(raids),
+		OrderedItems:  make([]any, 0, len(raids)),
+	}
+	if r.URL.RawQuery != "" {
+		outbox.ID += "?" + r.URL.RawQuery
+	}
+	for _, raid := range raids {
+		outbox.OrderedItems = append(outbox.OrderedItems, activitypub.ForRAiD(h.publisher.Base(), raid))
+	}
+	writeActivity(w, outbox)
+}
+
+// Followers handles GET /activitypub/followers - the number of followers
+// of the actor; who they are is not disclosed
+func (h *ActivityHandler) Followers(w http.ResponseWriter, r *http.Request) {
+	count, err := h.publisher.CountFollowers(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeActivity(w, activitypub.OrderedCollection{
+		JSONLDContext: activitypub.Context,
+		ID:            h.publisher.Base() + activitypub.FollowersPath,
+		Type:          "OrderedCollection",
+		TotalItems:    count,
+	})
+}
+
+// Inbox handles POST /activitypub/inbox - takes Follow and Undo Follow
+// activities, signed with the HTTP signature of their actor. Other
+// activities are acknowledged and ignored.
+func (h *ActivityHandler) Inbox(w http.ResponseWriter, r *http.Request) {
+	if !h.publisher.Follows() {
+		http.Error(w, "The registry takes no follows", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	switch err := h.publisher.Receive(r.Context(), r, body); {
+	case err == nil, errors.Is(err, activitypub.ErrUnsupported):
+		w.WriteHeader(http.StatusAccepted)
+	case errors.Is(err, activitypub.ErrInvalidSignature):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// WebFinger handles GET /.well-known/webfinger - resolves
+// acct:<username>@<host> to the registry's actor, as fediverse servers
+// look accounts up (RFC 7033)
+func (h *ActivityHandler) WebFinger(w http.ResponseWriter, r *http.Request) {
+	resource := r.URL.Query().Get("resource")
+	host := h.publisher.Base()
+	if u, err := url.Parse(host); err == nil {
+		host = u.Host
+	}
+	if resource != "acct:"+h.publisher.Username()+"@"+host && resource != h.publisher.ID() {
+		http.Error(w, "Unknown resource", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/jrd+json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"subject": "acct:" + h.publisher.Username() + "@" + host,
+		"aliases": []string{h.publisher.ID()},
+		"links": []map[string]string{
+			{"rel": "self", "type": activitypub.ContentType, "href": h.publisher.ID()},
+		},
+	})
+}
+
+func writeActivity(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", activitypub.ContentType)
+	json.NewEncoder(w).Encode(v)
+}
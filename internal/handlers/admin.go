@@ -0,0 +1,313 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/cache"
+	"github.com/leifj/go-raid/internal/chain"
+	"github.com/leifj/go-raid/internal/compaction"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/retention"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// AdminHandler handles operator-only administrative requests
+type AdminHandler struct {
+	storage     storage.Repository
+	storageType storage.StorageType
+	maintenance *middleware.Maintenance
+	scheduler   *backup.Scheduler
+	compactor   *compaction.Compactor
+	anchorer    *chain.Anchorer
+	anchors     string
+	signer      backup.Signer
+	retention   *retention.Enforcer
+	cache       cache.RAiDInvalidator
+}
+
+// NewAdminHandler creates a new admin handler. scheduler, compactor and
+// anchorer may be nil when scheduled backups, compaction or anchoring are
+// disabled. anchors is the file version history is verified against, if
+// any. Backups are signed with signer unless it is nil.
+func NewAdminHandler(repo storage.Repository, storageType storage.StorageType, maintenance *middleware.Maintenance, scheduler *backup.Scheduler, compactor *compaction.Compactor, anchorer *chain.Anchorer, anchors string, signer backup.Signer) *AdminHandler {
+	return &AdminHandler{
+		storage:     repo,
+		storageType: storageType,
+		maintenance: maintenance,
+		scheduler:   scheduler,
+		compactor:   compactor,
+		anchorer:    anchorer,
+		anchors:     anchors,
+		signer:      signer,
+	}
+}
+
+// WithCache drops the RAiDs the handler purges or redacts from c, the
+// cache serving the API
+func (h *AdminHandler) WithCache(c cache.RAiDInvalidator) *AdminHandler {
+	h.cache = c
+	return h
+}
+
+// invalidate drops a RAiD changed around the API from its cache, if any
+func (h *AdminHandler) invalidate(r *http.Request, prefix, suffix string) {
+	if h.cache != nil {
+		h.cache.InvalidateRAiD(r.Context(), prefix, suffix)
+	}
+}
+
+// maintenanceRequest is the body of PUT /admin/maintenance
+type maintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Reason  string `json:"reason,omitempty"`
+	// RetryAfter is the number of seconds advertised to rejected clients
+	RetryAfter *int `json:"retryAfter,omitempty"`
+}
+
+// GetMaintenance handles GET /admin/maintenance - reports read-only mode
+func (h *AdminHandler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.maintenance.Status())
+}
+
+// SetMaintenance handles PUT /admin/maintenance - toggles read-only mode
+func (h *AdminHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req maintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	if req.RetryAfter != nil {
+		if *req.RetryAfter < 0 {
+			http.Error(w, "retryAfter must not be negative", http.StatusBadRequest)
+			return
+		}
+		h.maintenance.SetRetryAfter(time.Duration(*req.RetryAfter) * time.Second)
+	}
+	h.maintenance.Set(req.Enabled, req.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.maintenance.Status())
+}
+
+// Backup handles GET /admin/backup - streams a snapshot archive
+func (h *AdminHandler) Backup(w http.ResponseWriter, r *http.Request) {
+	if _, ok := h.storage.(storage.Snapshotter); !ok {
+		http.Error(w, backup.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	filename := fmt.Sprintf("raid-backup-%s.ndjson.gz", time.Now().UTC().Format("20060102T150405Z"))
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	// Headers are already sent once streaming starts, so failures can only
+	// be logged; the truncated archive fails to decompress on restore
+	summary, err := backup.Write(r.Context(), h.storage, h.storageType, h.signer, w)
+	if err != nil {
+		log.Printf("Backup failed: %v", err)
+		return
+	}
+	log.Printf("Backup completed: %d RAiDs (%d versions), %d service points",
+		summary.RAiDs, summary.Versions, summary.ServicePoints)
+}
+
+// Restore handles POST /admin/restore - loads a snapshot archive into an
+// empty backend
+func (h *AdminHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	summary, err := backup.Restore(r.Context(), h.storage, r.Body)
+	if err != nil {
+		switch {
+		case errors.Is(err, backup.ErrUnsupported):
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		case errors.Is(err, backup.ErrNotEmpty):
+			http.Error(w, "Restore requires an empty storage backend", http.StatusConflict)
+		case errors.Is(err, backup.ErrInvalidArchive):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			writeStorageError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// BackupStatus handles GET /admin/backup/status - reports scheduled backups
+func (h *AdminHandler) BackupStatus(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		http.Error(w, "Scheduled backups are not configured", http.StatusNotFound)
+		return
+	}
+
+	status, err := h.scheduler.StatusWithBackups(r.Context())
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to list backups: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// RunBackup handles POST /admin/backup/run - takes a scheduled-style backup now
+func (h *AdminHandler) RunBackup(w http.ResponseWriter, r *http.Request) {
+	if h.scheduler == nil {
+		http.Error(w, "Scheduled backups are not configured", http.StatusNotFound)
+		return
+	}
+
+	status, err := h.scheduler.RunNow(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// CompactionStatus handles GET /admin/compaction/status - reports scheduled
+// compaction of old versions
+func (h *AdminHandler) CompactionStatus(w http.ResponseWriter, r *http.Request) {
+	if h.compactor == nil {
+		http.Error(w, "Compaction is not configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.compactor.Status())
+}
+
+// RunCompaction handles POST /admin/compaction/run - archives old versions now
+func (h *AdminHandler) RunCompaction(w http.ResponseWriter, r *http.Request) {
+	if h.compactor == nil {
+		http.Error(w, "Compaction is not configured", http.StatusNotFound)
+		return
+	}
+
+	status, err := h.compactor.RunNow(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// rehydrateResponse is the body returned by POST /admin/raids/{prefix}/{suffix}/rehydrate
+type rehydrateResponse struct {
+	Rehydrated int `json:"rehydrated"`
+}
+
+// RehydrateRAiD handles POST /admin/raids/{prefix}/{suffix}/rehydrate -
+// stores the archived versions of a RAiD as full documents again
+func (h *AdminHandler) RehydrateRAiD(w http.ResponseWriter, r *http.Request) {
+	compactor, ok := h.storage.(storage.VersionCompactor)
+	if !ok {
+		http.Error(w, compaction.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	n, err := compactor.RehydrateRAiD(r.Context(), chi.URLParam(r, "prefix"), chi.URLParam(r, "suffix"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, fmt.Errorf("failed to rehydrate RAiD: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rehydrateResponse{Rehydrated: n})
+}
+
+// VerifyChain handles GET /admin/raids/{prefix}/{suffix}/verify - checks
+// that the stored versions of a RAiD match their hash chain
+func (h *AdminHandler) VerifyChain(w http.ResponseWriter, r *http.Request) {
+	result, err := chain.Verify(r.Context(), h.storage, chi.URLParam(r, "prefix"), chi.URLParam(r, "suffix"))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, fmt.Errorf("failed to verify RAiD: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// RunAnchor handles POST /admin/anchors/run - anchors the version history
+// of every RAiD immediately
+func (h *AdminHandler) RunAnchor(w http.ResponseWriter, r *http.Request) {
+	if h.anchorer == nil {
+		http.Error(w, "Version history anchoring is not configured", http.StatusNotFound)
+		return
+	}
+
+	anchor, err := h.anchorer.AnchorNow(r.Context())
+	if err != nil {
+		writeStorageError(w, r, fmt.Errorf("anchoring failed: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anchorResponse{Time: anchor.Time, Digest: anchor.Digest, RAiDs: len(anchor.Heads)})
+}
+
+// anchorResponse summarises an anchor appended by RunAnchor
+type anchorResponse struct {
+	Time   time.Time `json:"time"`
+	Digest string    `json:"digest"`
+	RAiDs  int       `json:"raids"`
+}
+
+// Verify handles GET /admin/verify - checks stored data for integrity
+// problems, including version history against its hash chains and the
+// newest anchor. POST /admin/verify?repair=true also repairs what the
+// backend can.
+func (h *AdminHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	verifier, ok := h.storage.(storage.Verifier)
+	if !ok {
+		http.Error(w, "Storage backend does not support verification", http.StatusNotImplemented)
+		return
+	}
+
+	opts := storage.VerifyOptions{
+		Repair: r.Method == http.MethodPost && r.URL.Query().Get("repair") == "true",
+	}
+
+	report, err := verifier.Verify(r.Context(), opts)
+	if err != nil {
+		writeStorageError(w, r, fmt.Errorf("verification failed: %w", err))
+		return
+	}
+	var anchor *chain.Anchor
+	if h.anchors != "" {
+		if anchor, err = chain.LastAnchor(h.anchors); err != nil {
+			writeStorageError(w, r, fmt.Errorf("verification failed: %w", err))
+			return
+		}
+	}
+	if err := chain.VerifyAll(r.Context(), h.storage, report, anchor); err != nil {
+		writeStorageError(w, r, fmt.Errorf("verification failed: %w", err))
+		return
+	}
+	report.Finish()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
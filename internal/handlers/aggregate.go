@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// aggregatePageSize is the number of RAiDs read at a time when aggregating
+// as the RAiDs are listed
+const aggregatePageSize = 500
+
+// groupings maps the groupings of GET /raid/aggregate to the facets whose
+// values they group by
+var groupings = map[string]string{
+	models.GroupBySubject:      models.FacetSubject,
+	models.GroupByOrganisation: models.FacetOrganisation,
+	models.GroupByYear:         models.FacetStartYear,
+}
+
+// WithAggregator lets aggregates be computed by a backend natively and
+// returns h. Without one, or for filters it cannot aggregate, RAiDs are
+// counted as they are listed.
+func (h *RAiDHandler) WithAggregator(a storage.Aggregator) *RAiDHandler {
+	h.aggregator = a
+	return h
+}
+
+// AggregateRAiDs handles GET /raid/aggregate?groupBy=subject|organisation|year
+// - counts the RAiDs matching the filters of GET /raid/ and servicePoint by
+// subject, organisation or start year, for reports that would otherwise
+// need a bulk export. Years are listed in order, other groups most common
+// first.
+func (h *RAiDHandler) AggregateRAiDs(w http.ResponseWriter, r *http.Request) {
+	groupBy := r.URL.Query().Get("groupBy")
+	if _, ok := groupings[groupBy]; !ok {
+		http.Error(w, "groupBy must be subject, organisation or year", http.StatusBadRequest)
+		return
+	}
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = models.MetricCount
+	}
+	if metric != models.MetricCount {
+		http.Error(w, "metric must be count", http.StatusBadRequest)
+		return
+	}
+	filter, err := parseRAiDFilter(r)
+	if err == nil {
+		err = parseServicePoint(r, filter)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Limit, filter.Offset = 0, 0
+
+	counts, err := h.aggregate(r.Context(), filter, groupBy)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	resp := models.Aggregate{GroupBy: groupBy, Metric: metric, Groups: make([]models.AggregateGroup, 0, len(counts))}
+	for key, n := range counts {
+		resp.Groups = append(resp.Groups, models.AggregateGroup{Key: key, Value: n})
+	}
+	sort.Slice(resp.Groups, func(i, j int) bool {
+		a, b := resp.Groups[i], resp.Groups[j]
+		if groupBy != models.GroupByYear && a.Value != b.Value {
+			return a.Value > b.Value
+		}
+		return a.Key < b.Key
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// aggregate counts the RAiDs matching filter by groupBy, natively where the
+// backend can. On an agency's host the RAiDs are listed, as the backend
+// cannot tell which belong to the agency.
+func (h *RAiDHandler) aggregate(ctx context.Context, filter *storage.RAiDFilter, groupBy string) (map[string]int64, error) {
+	if h.aggregator != nil && agency.FromContext(ctx) == nil {
+		counts, err := h.aggregator.AggregateRAiDs(ctx, filter, groupBy)
+		if !errors.Is(err, storage.ErrAggregateUnsupported) {
+			return counts, err
+		}
+	}
+
+	// Read a page at a time, continuing from the last RAiD read, so that
+	// memory use does not grow with the repository
+	page := *filter
+	page.Limit, page.Cursor = aggregatePageSize, ""
+	counts := make(map[string]int64)
+	for {
+		raids, err := h.storage.ListRAiDs(ctx, &page)
+		if err != nil {
+			return nil, err
+		}
+		for _, raid := range raids {
+			for _, key := range facetValues(raid, groupings[groupBy]) {
+				counts[key]++
+			}
+		}
+		if len(raids) < aggregatePageSize {
+			return counts, nil
+		}
+		if page.Cursor = identifier.NextCursor(raids); page.Cursor == "" {
+			return nil, errors.New("cannot continue the listing after a RAiD without an identifier")
+		}
+	}
+}
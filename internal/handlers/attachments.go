@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/attachment"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/vocabulary"
+)
+
+// AttachmentHandler handles the documents attached to RAiDs. Members of
+// the service point owning a RAiD attach documents to it. Attachments of
+// open RAiDs can be read by anyone, as the RAiD can; those of other RAiDs
+// only by the members of the owning service point.
+type AttachmentHandler struct {
+	storage     storage.Repository
+	attachments *attachment.Store
+}
+
+// NewAttachmentHandler creates a new attachment handler
+func NewAttachmentHandler(repo storage.Repository, attachments *attachment.Store) *AttachmentHandler {
+	return &AttachmentHandler{storage: repo, attachments: attachments}
+}
+
+// UploadAttachment handles POST /raid/{prefix}/{suffix}/attachments -
+// attaches the document in the file part of a multipart/form-data body to
+// a RAiD and references it from the RAiD as a related object. The
+// document must meet the attachment policy of the owning service point.
+// Optional fields: sha256, the hex checksum the content must match; type,
+// the related object type; relate=false, to leave the RAiD as it is.
+func (h *AttachmentHandler) UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	raid, prefix, suffix, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	owner := raid.Identifier.Owner
+	if owner == nil || !isMember(r, owner.ServicePoint) {
+		http.Error(w, "Only members of the service point owning the RAiD can attach documents to it", http.StatusForbidden)
+		return
+	}
+	servicePoint, err := h.storage.GetServicePoint(r.Context(), owner.ServicePoint)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+	policy := h.attachments.Policy(servicePoint)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Documents are uploaded as multipart/form-data", http.StatusUnsupportedMediaType)
+		return
+	}
+	// Fields may follow the file, so the file is read, up to the size cap,
+	// before it is added
+	upload := &models.Attachment{Prefix: prefix, Suffix: suffix, ServicePoint: owner.ServicePoint}
+	var content []byte
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 1024))
+			if err != nil {
+				writeDecodeError(w, err)
+				return
+			}
+			fields[part.FormName()] = strings.TrimSpace(string(value))
+			continue
+		}
+		if content, err = io.ReadAll(io.LimitReader(part, policy.MaxBytes+1)); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		upload.Name = cmp.Or(part.FileName(), "attachment")
+		upload.MediaType = part.Header.Get("Content-Type")
+	}
+	if content == nil {
+		http.Error(w, "The document is uploaded in the file part", http.StatusBadRequest)
+		return
+	}
+	// Declared types are kept; generic ones are detected from the content
+	if mediaType, _, err := mime.ParseMediaType(upload.MediaType); err != nil || mediaType == "application/octet-stream" {
+		upload.MediaType = http.DetectContentType(content)
+	}
+	if sum := fields["sha256"]; sum != "" {
+		if !attachment.ValidHash(strings.ToLower(sum)) {
+			http.Error(w, "sha256 must be the hex-encoded SHA-256 of the document", http.StatusBadRequest)
+			return
+		}
+		upload.Hash = sum
+	}
+	var objectType string
+	if t := fields["type"]; t != "" {
+		if objectType, ok = vocabulary.RelatedObjectType.Normalize(t); !ok {
+			http.Error(w, "type must be a term of the related object type vocabulary", http.StatusBadRequest)
+			return
+		}
+	}
+
+	added, err := h.attachments.Add(r.Context(), upload, bytes.NewReader(content), policy)
+	switch {
+	case errors.Is(err, attachment.ErrTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	case errors.Is(err, attachment.ErrMediaType):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+		return
+	case errors.Is(err, attachment.ErrChecksum), errors.Is(err, attachment.ErrInfected):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	case errors.Is(err, attachment.ErrScanFailed):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		writeStorageError(w, r, err)
+		return
+	}
+
+	if fields["relate"] != "false" {
+		url := raidURL(r, prefix, suffix, "attachments", added.Hash)
+		related := false
+		for _, obj := range raid.RelatedObject {
+			related = related || obj.ID == url
+		}
+		if !related {
+			raid.RelatedObject = append(raid.RelatedObject, attachment.RelatedObject(url, objectType))
+			if _, err := h.storage.UpdateRAiD(r.Context(), prefix, suffix, raid); err != nil {
+				writeStorageError(w, r, err)
+				return
+			}
+		}
+	}
+	w.Header().Set("Location", rootRef(r, "raid", prefix, suffix, "attachments", added.Hash))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(added)
+}
+
+// ListAttachments handles GET /raid/{prefix}/{suffix}/attachments - lists
+// the attachments of a RAiD
+func (h *AttachmentHandler) ListAttachments(w http.ResponseWriter, r *http.Request) {
+	_, prefix, suffix, ok := h.readable(w, r)
+	if !ok {
+		return
+	}
+	attachments, err := h.attachments.List(r.Context(), prefix, suffix)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeList(w, r, attachments, listPage{}, func(a *models.Attachment) map[string]string {
+		return map[string]string{
+			"self": rootRef(r, "raid", a.Prefix, a.Suffix, "attachments", a.Hash),
+			"raid": rootRef(r, "raid", a.Prefix, a.Suffix),
+		}
+	})
+}
+
+// GetAttachment handles GET /raid/{prefix}/{suffix}/attachments/{hash} -
+// the content of an attachment. Its URL is what the RAiD references it by
+// as a related object.
+func (h *AttachmentHandler) GetAttachment(w http.ResponseWriter, r *http.Request) {
+	_, prefix, suffix, ok := h.readable(w, r)
+	if !ok {
+		return
+	}
+	a, err := h.attachments.Get(r.Context(), prefix, suffix, chi.URLParam(r, "hash"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	content, err := h.attachments.Open(r.Context(), a)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	// Content never changes under its hash
+	w.Header().Set("ETag", `"`+a.Hash+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("Content-Type", a.MediaType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", a.Created, content)
+}
+
+// readable returns the RAiD a request addresses and its handle, if the
+// caller can read its attachments
+func (h *AttachmentHandler) readable(w http.ResponseWriter, r *http.Request) (*models.RAiD, string, string, bool) {
+	raid, prefix, suffix, ok := h.open(w, r)
+	if !ok {
+		return nil, "", "", false
+	}
+	if raid.Access == nil || raid.Access.Type == nil || raid.Access.Type.ID != storage.AccessTypeOpen {
+		if raid.Identifier.Owner == nil || !isMember(r, raid.Identifier.Owner.ServicePoint) {
+			http.Error(w, "Only members of the service point owning the RAiD can see the attachments of a RAiD that is not open", http.StatusForbidden)
+			return nil, "", "", false
+		}
+	}
+	return raid, prefix, suffix, true
+}
+
+// open returns the RAiD a request addresses and its handle
+func (h *AttachmentHandler) open(w http.ResponseWriter, r *http.Request) (*models.RAiD, string, string, bool) {
+	raid, err := h.storage.GetRAiD(r.Context(), chi.URLParam(r, "prefix"), chi.URLParam(r, "suffix"))
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return nil, "", "", false
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return nil, "", "", false
+		}
+		writeStorageError(w, r, err)
+		return nil, "", "", false
+	}
+	if raid.Identifier == nil {
+		http.Error(w, "RAiD not found", http.StatusNotFound)
+		return nil, "", "", false
+	}
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, "", "", false
+	}
+	return raid, prefix, suffix, true
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Citation handles GET /raid/{prefix}/{suffix}/{version}/citation - a
+// citation of the RAiD as it was in that version, with the title and
+// contributors of the version. Versions never change, so neither does the
+// citation. Clients accepting text/plain get the formatted text only.
+func (h *RAiDHandler) Citation(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil {
+		http.Error(w, "Invalid version number", http.StatusBadRequest)
+		return
+	}
+
+	raid, err := h.storage.GetRAiDVersion(r.Context(), prefix, suffix, version)
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD version not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	citation := cite(raid)
+	if strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprintln(w, citation.Text)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(citation)
+}
+
+// cite builds the citation of a RAiD version. The title is the primary
+// title in force when the version was written.
+func cite(raid *models.RAiD) *models.Citation {
+	c := &models.Citation{Version: raidVersion(raid), Contributors: make([]string, 0, len(raid.Contributor))}
+	if raid.Identifier != nil {
+		c.ID = raid.Identifier.ID
+		if raid.Identifier.RegistrationAgency != nil {
+			c.Publisher = raid.Identifier.RegistrationAgency.ID
+		}
+	}
+	var written time.Time
+	if raid.Metadata != nil {
+		written = raid.Metadata.Updated
+		if written.IsZero() {
+			written = raid.Metadata.Created
+		}
+	}
+	if !written.IsZero() {
+		c.Date = written.UTC().Format("2006-01-02")
+		c.Title = primaryTitleOn(raid, c.Date)
+	} else {
+		c.Title = primaryTitle(raid)
+	}
+
+	contributors := slices.Clone(raid.Contributor)
+	slices.SortStableFunc(contributors, func(a, b models.Contributor) int {
+		switch {
+		case a.Leader && !b.Leader:
+			return -1
+		case b.Leader && !a.Leader:
+			return 1
+		}
+		return 0
+	})
+	for _, contributor := range contributors {
+		c.Contributors = append(c.Contributors, contributor.ID)
+	}
+
+	var text strings.Builder
+	if len(c.Contributors) > 0 {
+		text.WriteString(strings.Join(c.Contributors, "; "))
+		if c.Date != "" {
+			fmt.Fprintf(&text, " (%s)", c.Date[:4])
+		}
+		text.WriteString(". ")
+	}
+	fmt.Fprintf(&text, "%s (Version %d) [Research activity]. RAiD.", c.Title, c.Version)
+	if c.ID != "" {
+		text.WriteString(" " + c.ID)
+	}
+	c.Text = text.String()
+	return c
+}
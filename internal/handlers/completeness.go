@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/completeness"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Completeness handles GET /raid/{prefix}/{suffix}/completeness - the
+// completeness score of a RAiD, with the criteria it meets and misses
+func (h *RAiDHandler) Completeness(w http.ResponseWriter, r *http.Request) {
+	raid, err := h.storage.GetRAiD(r.Context(), chi.URLParam(r, "prefix"), chi.URLParam(r, "suffix"))
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(completeness.Score(raid))
+}
+
+// parseCompletenessFilter reads ?completeness.min and ?completeness.max,
+// returning a filter keeping the RAiDs scoring within them, or nil if
+// neither is set
+func parseCompletenessFilter(r *http.Request) (func(*models.RAiD) bool, error) {
+	bounds := [2]int{0, 100}
+	set := false
+	for i, name := range []string{"completeness.min", "completeness.max"} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("%s must be a score between 0 and 100", name)
+		}
+		bounds[i], set = n, true
+	}
+	if !set {
+		return nil, nil
+	}
+	return func(raid *models.RAiD) bool {
+		score := completeness.Score(raid).Score
+		return score >= bounds[0] && score <= bounds[1]
+	}, nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/invitation"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// defaultPageSize is the page size of views when ?limit is not set
+	defaultPageSize = 20
+	// maxPageSize bounds the page size of views
+	maxPageSize = 100
+)
+
+// ContributorRAiDs handles GET /contributor/{orcid}/raids - the RAiDs a
+// person appears in, with their positions and roles in each. The ORCID iD
+// may be given bare or as a URL.
+func (h *RAiDHandler) ContributorRAiDs(w http.ResponseWriter, r *http.Request) {
+	// chi leaves escaped slashes of an ORCID URL escaped
+	param, err := url.PathUnescape(chi.URLParam(r, "orcid"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	orcid, err := invitation.ParseORCID(param)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	raids, err := h.listPage(r, &storage.RAiDFilter{ContributorID: orcid}, &page)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	resp := models.ContributorRAiDs{Contributor: orcid, RAiDs: make([]models.ContributorRAiD, 0, len(raids)), Page: page}
+	for _, raid := range raids {
+		for _, c := range raid.Contributor {
+			if c.ID != orcid {
+				continue
+			}
+			item := models.ContributorRAiD{ID: raid.Identifier.ID, Title: primaryTitle(raid), Leader: c.Leader, Contact: c.Contact, Position: c.Position, Role: c.Role}
+			item.StartDate, item.EndDate = positionSpan(c.Position)
+			resp.RAiDs = append(resp.RAiDs, item)
+			break
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parsePage reads ?limit and ?offset, defaulting to the first page of
+// defaultPageSize
+func parsePage(r *http.Request) (models.Page, error) {
+	page := models.Page{Limit: defaultPageSize}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxPageSize {
+			return page, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+		page.Limit = n
+	}
+	if offset := r.URL.Query().Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return page, fmt.Errorf("offset must not be negative")
+		}
+		page.Offset = n
+	}
+	return page, nil
+}
+
+// listPage lists one page of the RAiDs matching filter, reading one more
+// to set page.HasMore
+func (h *RAiDHandler) listPage(r *http.Request, filter *storage.RAiDFilter, page *models.Page) ([]*models.RAiD, error) {
+	filter.Offset, filter.Limit = page.Offset, page.Limit+1
+	raids, err := h.storage.ListRAiDs(r.Context(), filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(raids) > page.Limit {
+		raids, page.HasMore = raids[:page.Limit], true
+	}
+	return raids, nil
+}
+
+// primaryTitle returns the text of the primary title of raid in force
+// today, or of its first title
+func primaryTitle(raid *models.RAiD) string {
+	return primaryTitleOn(raid, time.Now().UTC().Format("2006-01-02"))
+}
+
+// primaryTitleOn returns the text of the primary title of raid in force on
+// day, a YYYY-MM-DD date, or of its first title
+func primaryTitleOn(raid *models.RAiD, day string) string {
+	for _, t := range raid.Title {
+		if t.Type != nil && t.Type.ID == models.TitleTypePrimary && (t.StartDate == "" || t.StartDate <= day) && (t.EndDate == "" || t.EndDate >= day) {
+			return t.Text
+		}
+	}
+	if len(raid.Title) > 0 {
+		return raid.Title[0].Text
+	}
+	return ""
+}
+
+// positionSpan returns the earliest start date and the latest end date of
+// positions, with an empty end date if any position is open
+func positionSpan(positions []models.ContributorPosition) (start, end string) {
+	open := false
+	for _, p := range positions {
+		if p.StartDate != "" && (start == "" || p.StartDate < start) {
+			start = p.StartDate
+		}
+		if p.EndDate == "" {
+			open = true
+		} else if p.EndDate > end {
+			end = p.EndDate
+		}
+	}
+	if open {
+		end = ""
+	}
+	return start, end
+}
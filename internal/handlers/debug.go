@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+var startTime = time.Now()
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} {
+		return runtime.NumGoroutine()
+	}))
+	expvar.Publish("uptimeSeconds", expvar.Func(func() interface{} {
+		return int64(time.Since(startTime).Seconds())
+	}))
+}
+
+// DebugHandler serves runtime and storage diagnostics for operators
+type DebugHandler struct {
+	storage     storage.Repository
+	storageType storage.StorageType
+}
+
+// NewDebugHandler creates a new diagnostics handler
+func NewDebugHandler(repo storage.Repository, storageType storage.StorageType) *DebugHandler {
+	return &DebugHandler{
+		storage:     repo,
+		storageType: storageType,
+	}
+}
+
+// StorageStats handles GET /debug/storage - reports backend-specific statistics
+func (h *DebugHandler) StorageStats(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"type": h.storageType,
+	}
+
+	start := time.Now()
+	if err := h.storage.HealthCheck(r.Context()); err != nil {
+		response["healthy"] = false
+		response["error"] = err.Error()
+	} else {
+		response["healthy"] = true
+	}
+	response["healthCheckLatency"] = time.Since(start).String()
+
+	if sp, ok := h.storage.(storage.StatsProvider); ok {
+		stats, err := sp.Stats(r.Context())
+		if err != nil {
+			response["statsError"] = err.Error()
+		} else {
+			response["stats"] = stats
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
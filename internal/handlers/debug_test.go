@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestStorageStats(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	NewDebugHandler(repo, storage.StorageTypeFile).StorageStats(rr, httptest.NewRequest(http.MethodGet, "/debug/storage", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp struct {
+		Type    string         `json:"type"`
+		Healthy bool           `json:"healthy"`
+		Latency string         `json:"healthCheckLatency"`
+		Stats   map[string]any `json:"stats"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Type != string(storage.StorageTypeFile) || !resp.Healthy || resp.Latency == "" {
+		t.Errorf("unexpected diagnostics %+v", resp)
+	}
+	if resp.Stats["raidFiles"] != float64(1) {
+		t.Errorf("expected the backend statistics, got %v", resp.Stats)
+	}
+}
+
+func TestStorageStats_Unhealthy(t *testing.T) {
+	repo := testutil.NewMockRepository()
+	repo.HealthCheckFunc = func(context.Context) error { return errors.New("connection refused") }
+
+	rr := httptest.NewRecorder()
+	NewDebugHandler(repo, storage.StorageTypeCockroach).StorageStats(rr, httptest.NewRequest(http.MethodGet, "/debug/storage", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp map[string]any
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp["healthy"] != false || resp["error"] != "connection refused" {
+		t.Errorf("expected the failed health check reported, got %v", resp)
+	}
+	// The mock provides no statistics
+	if _, ok := resp["stats"]; ok {
+		t.Errorf("expected no statistics, got %v", resp["stats"])
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/validation"
+)
+
+// DocumentLimits bounds the RAiD documents accepted by mint and update
+type DocumentLimits struct {
+	// MaxBytes caps the size of a RAiD document; 0 leaves only the request
+	// body limit of the route
+	MaxBytes int64
+	// MaxRelatedObjects caps the related objects of a RAiD; 0 disables the
+	// limit
+	MaxRelatedObjects int
+	// Strict rejects documents with properties the RAiD schema does not
+	// define, other than x-... extensions, instead of keeping them
+	Strict bool
+}
+
+// LimitError reports a RAiD document exceeding one of its DocumentLimits
+type LimitError struct {
+	Message string
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+// decodeRAiD decodes the RAiD document in the body of r. Related objects,
+// the part of a document that grows without bound, are decoded one at a
+// time so that a document over the limit is rejected as soon as the first
+// object too many arrives rather than after the whole body is buffered. In
+// strict mode unknown properties fail with a *validation.Error.
+func decodeRAiD(w http.ResponseWriter, r *http.Request, limits DocumentLimits) (*models.RAiD, error) {
+	body := io.Reader(r.Body)
+	if limits.MaxBytes > 0 {
+		body = http.MaxBytesReader(w, r.Body, limits.MaxBytes)
+	}
+	dec := json.NewDecoder(body)
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	var related []models.RelatedObject
+	var unknown []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := tok.(string)
+		if !strings.EqualFold(name, "relatedObject") {
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			fields[name] = raw
+			continue
+		}
+
+		if related, err = decodeRelatedObjects(dec, limits.MaxRelatedObjects, limits.Strict, &unknown); err != nil {
+			return nil, err
+		}
+	}
+	if err := expectDelim(dec, '}'); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	var raid models.RAiD
+	if err := json.Unmarshal(data, &raid); err != nil {
+		return nil, err
+	}
+	raid.RelatedObject = related
+
+	if limits.Strict {
+		unknown = append(models.UnknownProperties(data, &raid), unknown...)
+		if len(unknown) > 0 {
+			failures := make([]models.ValidationFailure, 0, len(unknown))
+			for _, path := range unknown {
+				failures = append(failures, models.ValidationFailure{FieldID: path, ErrorType: validation.UnknownProperty, Message: "is not defined by the RAiD schema"})
+			}
+			return nil, &validation.Error{Failures: failures}
+		}
+	}
+	return &raid, nil
+}
+
+// decodeRelatedObjects decodes a relatedObject array element by element,
+// failing with a LimitError once it holds more than max objects. If strict,
+// the paths of unknown properties are added to unknown.
+func decodeRelatedObjects(dec *json.Decoder, max int, strict bool, unknown *[]string) ([]models.RelatedObject, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == nil {
+		return nil, nil
+	}
+	if d, ok := tok.(json.Delim); !ok || d != '[' {
+		return nil, fmt.Errorf("relatedObject must be an array")
+	}
+
+	var related []models.RelatedObject
+	for dec.More() {
+		if max > 0 && len(related) == max {
+			return nil, &LimitError{Message: fmt.Sprintf("RAiD has more than %d related objects", max)}
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, err
+		}
+		var obj models.RelatedObject
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+		if strict {
+			for _, path := range models.UnknownProperties(raw, &obj) {
+				*unknown = append(*unknown, fmt.Sprintf("relatedObject[%d].%s", len(related), path))
+			}
+		}
+		related = append(related, obj)
+	}
+	return related, expectDelim(dec, ']')
+}
+
+// expectDelim reads the next token of dec and fails unless it is d
+func expectDelim(dec *json.Decoder, d json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return fmt.Errorf("expected %v in RAiD document", d)
+	}
+	return nil
+}
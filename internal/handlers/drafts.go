@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// DraftHandler handles draft RAiDs, which service points stage before
+// minting them. Drafts are not validated until they are promoted, and take
+// no identifier until then.
+type DraftHandler struct {
+	store storage.DraftStore
+	raids *RAiDHandler
+}
+
+// NewDraftHandler creates a new draft handler, which promotes drafts by
+// minting them with raids
+func NewDraftHandler(store storage.DraftStore, raids *RAiDHandler) *DraftHandler {
+	return &DraftHandler{
+		store: store,
+		raids: raids,
+	}
+}
+
+// ListDrafts handles GET /drafts - lists the drafts of the caller's
+// service point
+func (h *DraftHandler) ListDrafts(w http.ResponseWriter, r *http.Request) {
+	servicePoint, ok := middleware.GetServicePointID(r.Context())
+	if !ok || servicePoint == 0 {
+		http.Error(w, "The token names no service point", http.StatusForbidden)
+		return
+	}
+	drafts, err := h.store.ListDrafts(r.Context(), servicePoint)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeList(w, r, drafts, listPage{}, draftLinks(r))
+}
+
+// draftLinks returns the links of the drafts listed in response to r
+func draftLinks(r *http.Request) func(*models.Draft) map[string]string {
+	return func(draft *models.Draft) map[string]string {
+		return map[string]string{
+			"self":    rootRef(r, "drafts", draft.ID),
+			"promote": rootRef(r, "drafts", draft.ID, "promote"),
+		}
+	}
+}
+
+// CreateDraft handles POST /drafts - stages a RAiD for the service point
+// named by identifier.owner.servicePoint, or else the caller's
+func (h *DraftHandler) CreateDraft(w http.ResponseWriter, r *http.Request) {
+	req, err := decodeRAiD(w, r, h.raids.limits)
+	if err != nil {
+		if !writeValidationError(w, r, err) {
+			writeDecodeError(w, err)
+		}
+		return
+	}
+	servicePoint, _ := middleware.GetServicePointID(r.Context())
+	if req.Identifier != nil && req.Identifier.Owner != nil && req.Identifier.Owner.ServicePoint != 0 {
+		servicePoint = req.Identifier.Owner.ServicePoint
+	}
+	if servicePoint == 0 || !isMember(r, servicePoint) {
+		http.Error(w, "Only members of a service point can stage its drafts", http.StatusForbidden)
+		return
+	}
+
+	now := time.Now().UTC()
+	draft := &models.Draft{ID: identifier.NewULID(now), ServicePoint: servicePoint, RAiD: req, Created: now, Updated: now}
+	if err := h.store.SaveDraft(r.Context(), draft); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(draft)
+}
+
+// GetDraft handles GET /drafts/{id} - retrieves a draft
+func (h *DraftHandler) GetDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+// UpdateDraft handles PUT /drafts/{id} - replaces the RAiD of a draft
+func (h *DraftHandler) UpdateDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	req, err := decodeRAiD(w, r, h.raids.limits)
+	if err != nil {
+		if !writeValidationError(w, r, err) {
+			writeDecodeError(w, err)
+		}
+		return
+	}
+	if req.Identifier != nil && req.Identifier.Owner != nil && req.Identifier.Owner.ServicePoint != 0 && req.Identifier.Owner.ServicePoint != draft.ServicePoint {
+		http.Error(w, "A draft cannot move to another service point", http.StatusBadRequest)
+		return
+	}
+	draft.RAiD = req
+	draft.Updated = time.Now().UTC()
+	if err := h.store.SaveDraft(r.Context(), draft); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(draft)
+}
+
+// DeleteDraft handles DELETE /drafts/{id} - discards a draft
+func (h *DraftHandler) DeleteDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteDraft(r.Context(), draft.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PromoteDraft handles POST /drafts/{id}/promote - mints a draft as
+// POST /raid/ does, with full validation, and discards the draft once it
+// is minted. A draft that fails to mint is kept for correcting.
+func (h *DraftHandler) PromoteDraft(w http.ResponseWriter, r *http.Request) {
+	draft, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	req := draft.RAiD
+	if req == nil {
+		req = &models.RAiD{}
+	}
+	if req.Identifier == nil {
+		req.Identifier = &models.Identifier{}
+	}
+	if req.Identifier.Owner == nil {
+		req.Identifier.Owner = &models.Owner{}
+	}
+	req.Identifier.Owner.ServicePoint = draft.ServicePoint
+
+	raid, ok := h.raids.mint(w, r, req)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteDraft(r.Context(), draft.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(raid)
+}
+
+// open returns the draft a request names, if the caller belongs to its
+// service point or is an operator
+func (h *DraftHandler) open(w http.ResponseWriter, r *http.Request) (*models.Draft, bool) {
+	draft, err := h.store.GetDraft(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	if !isMember(r, draft.ServicePoint) {
+		// Drafts of other service points are not disclosed
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return nil, false
+	}
+	return draft, true
+}
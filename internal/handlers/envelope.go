@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+type envelopeDefaultKey struct{}
+
+// DefaultEnvelope makes enveloped lists the default for requests not
+// asking for a profile
+func DefaultEnvelope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), envelopeDefaultKey{}, true)))
+	})
+}
+
+// wantsEnvelope reports whether the list response to r is enveloped
+func wantsEnvelope(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, media := range strings.Split(accept, ",") {
+			_, params, err := mime.ParseMediaType(media)
+			if err != nil {
+				continue
+			}
+			switch params["profile"] {
+			case models.EnvelopeProfile:
+				return true
+			case models.BareProfile:
+				return false
+			}
+		}
+	}
+	def, _ := r.Context().Value(envelopeDefaultKey{}).(bool)
+	return def
+}
+
+// listPage describes the page of a list response
+type listPage struct {
+	Limit  int
+	Offset int
+	Cursor string
+	// Next is the reference to the next page, if any
+	Next string
+}
+
+// writeList writes items as a bare array, or in an envelope if the client
+// asks for one, with the links of each item returned by links. Either way a
+// next page is linked from the Link header.
+func writeList[T any](w http.ResponseWriter, r *http.Request, items []T, page listPage, links func(T) map[string]string) {
+	if page.Next != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, page.Next))
+	}
+	w.Header().Add("Vary", "Accept")
+	if !wantsEnvelope(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(items)
+		return
+	}
+
+	data := make([]json.RawMessage, 0, len(items))
+	for _, item := range items {
+		raw, err := withLinks(item, links(item))
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		data = append(data, raw)
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	env := models.Envelope{
+		Data: encoded,
+		Meta: models.EnvelopeMeta{
+			Count:     len(items),
+			Limit:     page.Limit,
+			Offset:    page.Offset,
+			Cursor:    page.Cursor,
+			RequestID: chimw.GetReqID(r.Context()),
+		},
+		Links: map[string]string{"self": selfRef(r)},
+	}
+	if page.Next != "" {
+		env.Links["next"] = page.Next
+	}
+	w.Header().Set("Content-Type", fmt.Sprintf(`application/json; profile="%s"`, models.EnvelopeProfile))
+	json.NewEncoder(w).Encode(env)
+}
+
+// withLinks encodes item with links added under "_links"
+func withLinks(item any, links map[string]string) (json.RawMessage, error) {
+	data, err := json.Marshal(item)
+	if err != nil || len(links) == 0 || len(data) < 2 || data[0] != '{' {
+		return data, err
+	}
+	encoded, err := json.Marshal(links)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), data[:len(data)-1]...)
+	if len(data) > 2 {
+		out = append(out, ',')
+	}
+	out = append(out, `"_links":`...)
+	out = append(append(out, encoded...), '}')
+	return out, nil
+}
+
+// selfRef returns a reference to the requested page, relative to it
+func selfRef(r *http.Request) string {
+	ref := path.Base(r.URL.Path)
+	if strings.HasSuffix(r.URL.Path, "/") {
+		ref = "./"
+	}
+	if r.URL.RawQuery != "" {
+		ref += "?" + r.URL.RawQuery
+	}
+	return ref
+}
+
+// requestURL returns the absolute URL of path on the host r was sent to.
+// Behind a proxy terminating TLS the scheme comes from X-Forwarded-Proto.
+func requestURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
+
+// rootRef returns a reference to the API path made of segments, relative to
+// the requested one, so that it resolves wherever the server is mounted
+func rootRef(r *http.Request, segments ...string) string {
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	depth := strings.Count(middleware.RoutePath(r), "/") - 1
+	ref := strings.Repeat("../", max(depth, 0)) + strings.Join(segments, "/")
+	if !strings.HasPrefix(ref, "../") {
+		ref = "./" + ref
+	}
+	return ref
+}
+
+// raidLinks returns the links of the RAiDs listed in response to r
+func raidLinks(r *http.Request) func(*models.RAiD) map[string]string {
+	return func(raid *models.RAiD) map[string]string {
+		if raid.Identifier == nil {
+			return nil
+		}
+		prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil {
+			return nil
+		}
+		links := map[string]string{
+			"self":    rootRef(r, "raid", prefix, suffix),
+			"history": rootRef(r, "raid", prefix, suffix, "history"),
+		}
+		if raid.Identifier.Version > 0 {
+			links["version"] = rootRef(r, "raid", prefix, suffix, strconv.Itoa(raid.Identifier.Version))
+		}
+		return links
+	}
+}
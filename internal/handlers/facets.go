@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// facets lists the facets of GET /raid/search, counted unless ?facets
+// names fewer
+var facets = []string{models.FacetSubject, models.FacetOrganisation, models.FacetAccessType, models.FacetStartYear, models.FacetServicePoint}
+
+const (
+	// defaultFacetSize is the number of values listed per facet when
+	// ?facet.limit is not set
+	defaultFacetSize = 10
+	// maxFacetSize bounds ?facet.limit
+	maxFacetSize = 100
+)
+
+// SearchRAiDs handles GET /raid/search - a page of the public RAiDs matching
+// the filters of GET /raid/, with the number found over all pages and their
+// counts by subject, organisation, access type, start year and service
+// point, for discovery portals to render filters from. servicePoint,
+// accessType and startYear narrow the search to a facet value too. No
+// authentication is needed.
+func (h *RAiDHandler) SearchRAiDs(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseRAiDFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Limit, filter.Offset = 0, 0
+	if err := parseServicePoint(r, filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	counted, err := parseFacets(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	size := defaultFacetSize
+	if l := r.URL.Query().Get("facet.limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 1 || n > maxFacetSize {
+			http.Error(w, "facet.limit must be between 1 and "+strconv.Itoa(maxFacetSize), http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	raids, err := h.storage.ListPublicRAiDs(r.Context(), filter)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	accessType, startYear := r.URL.Query().Get("accessType"), r.URL.Query().Get("startYear")
+	found := make([]*models.RAiD, 0, len(raids))
+	for _, raid := range raids {
+		if accessType != "" && !hasFacetValue(raid, models.FacetAccessType, accessType) {
+			continue
+		}
+		if startYear != "" && !hasFacetValue(raid, models.FacetStartYear, startYear) {
+			continue
+		}
+		found = append(found, raid)
+	}
+
+	resp := models.SearchResult{Total: len(found), Facets: make(map[string][]models.FacetCount, len(counted)), Page: page}
+	for _, facet := range counted {
+		resp.Facets[facet] = countFacet(found, facet, size)
+	}
+	hits := found[min(page.Offset, len(found)):]
+	if len(hits) > page.Limit {
+		hits, resp.Page.HasMore = hits[:page.Limit], true
+	}
+	hits, ok := localize(w, r, hits...)
+	if !ok {
+		return
+	}
+	resp.Hits = hits
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// parseFacets reads ?facets, a comma-separated list of the facets to count,
+// defaulting to all of them. An empty list counts none.
+func parseFacets(r *http.Request) ([]string, error) {
+	if !r.URL.Query().Has("facets") {
+		return facets, nil
+	}
+	var counted []string
+	for _, facet := range strings.Split(r.URL.Query().Get("facets"), ",") {
+		facet = strings.TrimSpace(facet)
+		if facet == "" {
+			continue
+		}
+		if !slices.Contains(facets, facet) {
+			return nil, fmt.Errorf("unknown facet %q; facets are %s", facet, strings.Join(facets, ", "))
+		}
+		counted = append(counted, facet)
+	}
+	return counted, nil
+}
+
+// facetValues returns the values of facet raid is counted under, each once
+func facetValues(raid *models.RAiD, facet string) []string {
+	var values []string
+	switch facet {
+	case models.FacetSubject:
+		for _, s := range raid.Subject {
+			values = append(values, s.ID)
+		}
+	case models.FacetOrganisation:
+		for _, o := range raid.Organisation {
+			values = append(values, o.ID)
+		}
+	case models.FacetAccessType:
+		if raid.Access != nil && raid.Access.Type != nil {
+			values = append(values, raid.Access.Type.ID)
+		}
+	case models.FacetStartYear:
+		if raid.Date != nil && len(raid.Date.StartDate) >= 4 {
+			values = append(values, raid.Date.StartDate[:4])
+		}
+	case models.FacetServicePoint:
+		if raid.Identifier != nil && raid.Identifier.Owner != nil && raid.Identifier.Owner.ServicePoint != 0 {
+			values = append(values, strconv.FormatInt(raid.Identifier.Owner.ServicePoint, 10))
+		}
+	}
+	seen := make(map[string]bool, len(values))
+	unique := values[:0]
+	for _, v := range values {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
+
+func hasFacetValue(raid *models.RAiD, facet, value string) bool {
+	return slices.Contains(facetValues(raid, facet), value)
+}
+
+// countFacet returns the size most common values of facet among raids,
+// most common first and then by value
+func countFacet(raids []*models.RAiD, facet string, size int) []models.FacetCount {
+	counts := make(map[string]int)
+	for _, raid := range raids {
+		for _, v := range facetValues(raid, facet) {
+			counts[v]++
+		}
+	}
+	buckets := make([]models.FacetCount, 0, len(counts))
+	for v, n := range counts {
+		buckets = append(buckets, models.FacetCount{Value: v, Count: n})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Value < buckets[j].Value
+	})
+	if len(buckets) > size {
+		buckets = buckets[:size]
+	}
+	return buckets
+}
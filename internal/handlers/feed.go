@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Number of entries in RAiD feeds, unless the limit query parameter asks for
+// fewer or, up to the maximum, more
+const (
+	defaultFeedEntries = 50
+	maxFeedEntries     = 500
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published,omitempty"`
+	Category  atomCategory `xml:"category"`
+	Links     []atomLink   `xml:"link"`
+	Summary   string       `xml:"summary,omitempty"`
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link"`
+	Description string  `xml:"description,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+	PubDate     string  `xml:"pubDate"`
+	Category    string  `xml:"category"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// AtomFeed handles GET /raid/feed.atom - the most recently minted or updated
+// public RAiDs as an Atom feed, newest first. servicePoint and subject
+// narrow the feed to the RAiDs of a service point or on a subject. No
+// authentication is needed.
+func (h *RAiDHandler) AtomFeed(w http.ResponseWriter, r *http.Request) {
+	raids, ok := h.recentPublic(w, r)
+	if !ok {
+		return
+	}
+	self := requestURL(r, r.URL.RequestURI())
+	feed := atomFeed{
+		ID:      self,
+		Title:   feedTitle(r),
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Author:  atomPerson{Name: r.Host},
+		Links:   []atomLink{{Rel: "self", Type: "application/atom+xml", Href: self}},
+		Entries: make([]atomEntry, 0, len(raids)),
+	}
+	if len(raids) > 0 {
+		feed.Updated = changedAt(raids[0]).Format(time.RFC3339)
+	}
+	for _, raid := range raids {
+		entry := atomEntry{
+			ID:       raid.Identifier.ID,
+			Title:    primaryTitle(raid),
+			Updated:  changedAt(raid).Format(time.RFC3339),
+			Category: atomCategory{Term: feedChange(raid)},
+			Links:    []atomLink{{Rel: "alternate", Href: raid.Identifier.ID}},
+			Summary:  feedSummary(raid),
+		}
+		if raid.Metadata != nil && !raid.Metadata.Created.IsZero() {
+			entry.Published = raid.Metadata.Created.UTC().Format(time.RFC3339)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	writeFeed(w, "application/atom+xml; charset=utf-8", feed)
+}
+
+// RSSFeed handles GET /raid/feed.rss - the feed of GET /raid/feed.atom as
+// RSS 2.0, for readers without Atom support
+func (h *RAiDHandler) RSSFeed(w http.ResponseWriter, r *http.Request) {
+	raids, ok := h.recentPublic(w, r)
+	if !ok {
+		return
+	}
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       feedTitle(r),
+			Link:        requestURL(r, r.URL.RequestURI()),
+			Description: "Recently minted and updated public RAiDs",
+			Items:       make([]rssItem, 0, len(raids)),
+		},
+	}
+	if len(raids) > 0 {
+		feed.Channel.LastBuildDate = changedAt(raids[0]).Format(time.RFC1123Z)
+	}
+	for _, raid := range raids {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       primaryTitle(raid),
+			Link:        raid.Identifier.ID,
+			Description: feedSummary(raid),
+			GUID:        rssGUID{IsPermaLink: false, Value: raid.Identifier.ID + "#" + strconv.Itoa(raid.Identifier.Version)},
+			PubDate:     changedAt(raid).Format(time.RFC1123Z),
+			Category:    feedChange(raid),
+		})
+	}
+	writeFeed(w, "application/rss+xml; charset=utf-8", feed)
+}
+
+// recentPublic returns the public RAiDs a feed lists, most recently changed
+// first
+func (h *RAiDHandler) recentPublic(w http.ResponseWriter, r *http.Request) ([]*models.RAiD, bool) {
+	filter := &storage.RAiDFilter{SubjectID: r.URL.Query().Get("subject")}
+	if err := parseServicePoint(r, filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	limit := defaultFeedEntries
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxFeedEntries {
+			http.Error(w, "limit must be between 1 and "+strconv.Itoa(maxFeedEntries), http.StatusBadRequest)
+			return nil, false
+		}
+		limit = n
+	}
+
+	raids, err := h.storage.ListPublicRAiDs(r.Context(), filter)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	recent := make([]*models.RAiD, 0, len(raids))
+	for _, raid := range raids {
+		if raid.Identifier != nil {
+			recent = append(recent, raid)
+		}
+	}
+	sort.SliceStable(recent, func(i, j int) bool { return changedAt(recent[i]).After(changedAt(recent[j])) })
+	if len(recent) > limit {
+		recent = recent[:limit]
+	}
+	return recent, true
+}
+
+// changedAt returns when raid was last minted or updated
+func changedAt(raid *models.RAiD) time.Time {
+	if raid.Metadata == nil {
+		return time.Time{}
+	}
+	if raid.Metadata.Updated.After(raid.Metadata.Created) {
+		return raid.Metadata.Updated.UTC()
+	}
+	return raid.Metadata.Created.UTC()
+}
+
+// feedChange returns the category of the entry of raid: minted for a first
+// version, updated otherwise
+func feedChange(raid *models.RAiD) string {
+	if raid.Identifier.Version <= 1 {
+		return "minted"
+	}
+	return "updated"
+}
+
+// feedTitle returns the title of the feed requested by r
+func feedTitle(r *http.Request) string {
+	title := "Public RAiDs"
+	if sp := r.URL.Query().Get("servicePoint"); sp != "" {
+		title += " of service point " + sp
+	}
+	if subject := r.URL.Query().Get("subject"); subject != "" {
+		title += " on subject " + subject
+	}
+	return title
+}
+
+// feedSummary returns the first description of raid
+func feedSummary(raid *models.RAiD) string {
+	if len(raid.Description) > 0 {
+		return raid.Description[0].Text
+	}
+	return ""
+}
+
+func writeFeed(w http.ResponseWriter, contentType string, feed any) {
+	w.Header().Set("Content-Type", contentType)
+	w.Write([]byte(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	enc.Encode(feed)
+}
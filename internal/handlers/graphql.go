@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/graphql"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// defaultGraphQLPageSize is the number of RAiDs returned when first is
+	// not given
+	defaultGraphQLPageSize = 20
+	// maxGraphQLPageSize bounds first
+	maxGraphQLPageSize = 100
+)
+
+// GraphQLHandler serves a read-only GraphQL schema over RAiDs, their
+// versions, service points, contributors and organisations
+type GraphQLHandler struct {
+	schema *graphql.Schema
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(repo storage.Repository) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: newRAiDSchema(repo),
+	}
+}
+
+// Query handles GET and POST /graphql. POST takes a JSON request body with
+// query, operationName and variables, or a bare query with Content-Type
+// application/graphql; GET takes the same as URL parameters, with variables
+// JSON encoded.
+func (h *GraphQLHandler) Query(w http.ResponseWriter, r *http.Request) {
+	var req graphql.Request
+	switch {
+	case r.Method == http.MethodGet:
+		req.Query = r.URL.Query().Get("query")
+		req.OperationName = r.URL.Query().Get("operationName")
+		if vars := r.URL.Query().Get("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+				http.Error(w, "Invalid variables", http.StatusBadRequest)
+				return
+			}
+		}
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql"):
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		req.Query = string(body)
+	default:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+	}
+	if req.Query == "" {
+		http.Error(w, "Missing query", http.StatusBadRequest)
+		return
+	}
+
+	resp := h.schema.Execute(r.Context(), req)
+
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Data == nil {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Schema handles GET /graphql/schema - returns the schema in the GraphQL
+// schema definition language
+func (h *GraphQLHandler) Schema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, h.schema.SDL())
+}
+
+// raidConnection is a page of RAiDs
+type raidConnection struct {
+	Nodes    []*models.RAiD `json:"nodes"`
+	PageInfo pageInfo       `json:"pageInfo"`
+}
+
+type pageInfo struct {
+	Offset      int  `json:"offset"`
+	Limit       int  `json:"limit"`
+	HasNextPage bool `json:"hasNextPage"`
+}
+
+// newRAiDSchema builds the GraphQL schema. Fields resolve from the model's
+// json tags unless they need storage lookups or reshaping.
+func newRAiDSchema(repo storage.Repository) *graphql.Schema {
+	str := graphql.String
+	idSchema := &graphql.Object{Name: "IdSchema", Description: "An identifier and the URI of its scheme", Fields: []*graphql.Field{
+		{Name: "id", Type: graphql.NonNull{Of: str}},
+		{Name: "schemaUri", Type: str},
+	}}
+	text := &graphql.Object{Name: "LanguageText", Description: "Text with an optional language", Fields: []*graphql.Field{
+		{Name: "text", Type: graphql.NonNull{Of: str}},
+		{Name: "language", Type: idSchema},
+	}}
+	role := &graphql.Object{Name: "Role", Description: "A position or role held for a period", Fields: []*graphql.Field{
+		{Name: "id", Type: graphql.NonNull{Of: str}},
+		{Name: "schemaUri", Type: str},
+		{Name: "startDate", Type: str},
+		{Name: "endDate", Type: str},
+	}}
+
+	servicePoint := &graphql.Object{Name: "ServicePoint", Fields: []*graphql.Field{
+		{Name: "id", Type: graphql.NonNull{Of: graphql.ID}},
+		{Name: "name", Type: graphql.NonNull{Of: str}},
+		{Name: "identifierOwner", Type: str},
+		{Name: "repositoryId", Type: str},
+		{Name: "prefix", Type: str},
+		{Name: "groupId", Type: str},
+		{Name: "searchContent", Type: str},
+		{Name: "techEmail", Type: str},
+		{Name: "adminEmail", Type: str},
+		{Name: "enabled", Type: graphql.NonNull{Of: graphql.Boolean}},
+		{Name: "appWritesEnabled", Type: graphql.NonNull{Of: graphql.Boolean}},
+	}}
+
+	raid := &graphql.Object{Name: "RAiD", Description: "A Research Activity Identifier"}
+	connection := &graphql.Object{Name: "RAiDConnection", Description: "A page of RAiDs", Fields: []*graphql.Field{
+		{Name: "nodes", Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: raid}}}},
+		{Name: "pageInfo", Type: graphql.NonNull{Of: &graphql.Object{Name: "PageInfo", Fields: []*graphql.Field{
+			{Name: "offset", Type: graphql.NonNull{Of: graphql.Int}},
+			{Name: "limit", Type: graphql.NonNull{Of: graphql.Int}},
+			{Name: "hasNextPage", Type: graphql.NonNull{Of: graphql.Boolean}},
+		}}}},
+	}}
+	pageArgs := func(args ...*graphql.Argument) []*graphql.Argument {
+		return append(args,
+			&graphql.Argument{Name: "first", Type: graphql.Int, Default: defaultGraphQLPageSize, Description: fmt.Sprintf("Page size, at most %d", maxGraphQLPageSize)},
+			&graphql.Argument{Name: "offset", Type: graphql.Int, Default: 0},
+		)
+	}
+	// raids lists a page of RAiDs matching the filter built from the source
+	// and arguments
+	raids := func(filter func(source any, args map[string]any) *storage.RAiDFilter) graphql.ResolveFunc {
+		return func(ctx context.Context, source any, args map[string]any) (any, error) {
+			first, offset := args["first"].(int), args["offset"].(int)
+			if first < 1 || first > maxGraphQLPageSize {
+				return nil, fmt.Errorf("first must be between 1 and %d", maxGraphQLPageSize)
+			}
+			if offset < 0 {
+				return nil, fmt.Errorf("offset must not be negative")
+			}
+			f := filter(source, args)
+			f.Limit, f.Offset = first+1, offset
+
+			list := repo.ListRAiDs
+			if public, _ := args["publicOnly"].(bool); public {
+				list = repo.ListPublicRAiDs
+			}
+			nodes, err := list(ctx, f)
+			if err != nil {
+				return nil, err
+			}
+			conn := &raidConnection{Nodes: nodes, PageInfo: pageInfo{Offset: offset, Limit: first}}
+			if len(nodes) > first {
+				conn.Nodes, conn.PageInfo.HasNextPage = nodes[:first], true
+			}
+			return conn, nil
+		}
+	}
+	contributor := &graphql.Object{Name: "Contributor", Description: "A person contributing to RAiDs", Fields: []*graphql.Field{
+		{Name: "id", Type: graphql.NonNull{Of: str}},
+		{Name: "raids", Type: graphql.NonNull{Of: connection}, Args: pageArgs(), Resolve: raids(func(source any, _ map[string]any) *storage.RAiDFilter {
+			return &storage.RAiDFilter{ContributorID: source.(map[string]any)["id"].(string)}
+		})},
+	}}
+	organisation := &graphql.Object{Name: "Organisation", Description: "An organisation involved in RAiDs", Fields: []*graphql.Field{
+		{Name: "id", Type: graphql.NonNull{Of: str}},
+		{Name: "raids", Type: graphql.NonNull{Of: connection}, Args: pageArgs(), Resolve: raids(func(source any, _ map[string]any) *storage.RAiDFilter {
+			return &storage.RAiDFilter{OrganisationID: source.(map[string]any)["id"].(string)}
+		})},
+	}}
+
+	getServicePoint := func(ctx context.Context, id int64) (any, error) {
+		sp, err := repo.GetServicePoint(ctx, id)
+		if err == storage.ErrNotFound {
+			return nil, nil
+		}
+		return sp, err
+	}
+	getRAiD := func(ctx context.Context, prefix, suffix string, version int) (any, error) {
+		var (
+			r   *models.RAiD
+			err error
+		)
+		if version > 0 {
+			r, err = repo.GetRAiDVersion(ctx, prefix, suffix, version)
+		} else {
+			r, err = repo.GetRAiD(ctx, prefix, suffix)
+		}
+		if err == storage.ErrNotFound {
+			return nil, nil
+		}
+		return r, err
+	}
+
+	raid.Fields = []*graphql.Field{
+		{Name: "handle", Type: graphql.NonNull{Of: str}, Description: "PREFIX/SUFFIX", Resolve: func(_ context.Context, source any, _ map[string]any) (any, error) {
+			prefix, suffix := raidHandle(source.(*models.RAiD))
+			return prefix + "/" + suffix, nil
+		}},
+		{Name: "metadata", Type: &graphql.Object{Name: "Metadata", Fields: []*graphql.Field{
+			{Name: "created", Type: str, Description: "RFC 3339 timestamp", Resolve: func(_ context.Context, source any, _ map[string]any) (any, error) {
+				return timestamp(source.(*models.Metadata).Created), nil
+			}},
+			{Name: "updated", Type: str, Description: "RFC 3339 timestamp", Resolve: func(_ context.Context, source any, _ map[string]any) (any, error) {
+				return timestamp(source.(*models.Metadata).Updated), nil
+			}},
+		}}},
+		{Name: "identifier", Type: &graphql.Object{Name: "Identifier", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "schemaUri", Type: str},
+			{Name: "registrationAgency", Type: idSchema},
+			{Name: "owner", Type: &graphql.Object{Name: "Owner", Fields: []*graphql.Field{
+				{Name: "id", Type: graphql.NonNull{Of: str}},
+				{Name: "schemaUri", Type: str},
+				{Name: "servicePoint", Type: servicePoint, Resolve: func(ctx context.Context, source any, _ map[string]any) (any, error) {
+					if id := source.(*models.Owner).ServicePoint; id != 0 {
+						return getServicePoint(ctx, id)
+					}
+					return nil, nil
+				}},
+			}}},
+			{Name: "raidAgencyUrl", Type: str},
+			{Name: "license", Type: str},
+			{Name: "version", Type: graphql.NonNull{Of: graphql.Int}},
+		}}},
+		{Name: "title", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "Title", Fields: []*graphql.Field{
+			{Name: "text", Type: graphql.NonNull{Of: str}},
+			{Name: "type", Type: idSchema},
+			{Name: "startDate", Type: str},
+			{Name: "endDate", Type: str},
+			{Name: "language", Type: idSchema},
+		}}}}},
+		{Name: "date", Type: &graphql.Object{Name: "Date", Fields: []*graphql.Field{
+			{Name: "startDate", Type: str},
+			{Name: "endDate", Type: str},
+		}}},
+		{Name: "description", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "Description", Fields: []*graphql.Field{
+			{Name: "text", Type: graphql.NonNull{Of: str}},
+			{Name: "type", Type: idSchema},
+			{Name: "language", Type: idSchema},
+		}}}}},
+		{Name: "access", Type: &graphql.Object{Name: "Access", Fields: []*graphql.Field{
+			{Name: "type", Type: idSchema},
+			{Name: "statement", Type: text},
+			{Name: "embargoExpiry", Type: str},
+		}}},
+		{Name: "alternateUrl", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "AlternateUrl", Fields: []*graphql.Field{
+			{Name: "url", Type: graphql.NonNull{Of: str}},
+		}}}}},
+		{Name: "contributor", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "RaidContributor", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "schemaUri", Type: str},
+			{Name: "status", Type: str},
+			{Name: "statusMessage", Type: str},
+			{Name: "email", Type: str},
+			{Name: "uuid", Type: str},
+			{Name: "position", Type: graphql.List{Of: graphql.NonNull{Of: role}}},
+			{Name: "role", Type: graphql.List{Of: graphql.NonNull{Of: idSchema}}},
+			{Name: "leader", Type: graphql.NonNull{Of: graphql.Boolean}},
+			{Name: "contact", Type: graphql.NonNull{Of: graphql.Boolean}},
+		}}}}},
+		{Name: "organisation", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "RaidOrganisation", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "schemaUri", Type: str},
+			{Name: "role", Type: graphql.List{Of: graphql.NonNull{Of: role}}},
+		}}}}},
+		{Name: "subject", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "Subject", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "schemaUri", Type: str},
+			{Name: "keyword", Type: graphql.List{Of: graphql.NonNull{Of: text}}},
+		}}}}},
+		{Name: "relatedRaid", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "RelatedRaid", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "type", Type: idSchema},
+			{Name: "raid", Type: raid, Description: "The related RAiD, if it is registered here", Resolve: func(ctx context.Context, source any, _ map[string]any) (any, error) {
+				rel := source.(relatedRaid)
+				return rel.batch.get(ctx, repo, rel.index)
+			}},
+		}}}}, Resolve: func(_ context.Context, source any, _ map[string]any) (any, error) {
+			return relatedRaids(source.(*models.RAiD).RelatedRAiD), nil
+		}},
+		{Name: "relatedObject", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "RelatedObject", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "schemaUri", Type: str},
+			{Name: "type", Type: idSchema},
+			{Name: "category", Type: graphql.List{Of: graphql.NonNull{Of: idSchema}}},
+		}}}}},
+		{Name: "alternateIdentifier", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "AlternateIdentifier", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "type", Type: str},
+		}}}}},
+		{Name: "spatialCoverage", Type: graphql.List{Of: graphql.NonNull{Of: &graphql.Object{Name: "SpatialCoverage", Fields: []*graphql.Field{
+			{Name: "id", Type: graphql.NonNull{Of: str}},
+			{Name: "schemaUri", Type: str},
+			{Name: "place", Type: graphql.List{Of: graphql.NonNull{Of: text}}},
+		}}}}},
+		{Name: "traditionalKnowledgeLabel", Type: graphql.List{Of: graphql.NonNull{Of: idSchema}}},
+		{Name: "versions", Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: raid}}}, Description: "Every stored version, oldest first", Resolve: func(ctx context.Context, source any, _ map[string]any) (any, error) {
+			prefix, suffix := raidHandle(source.(*models.RAiD))
+			history, err := repo.GetRAiDHistory(ctx, prefix, suffix)
+			if err != nil {
+				return nil, err
+			}
+			sort.SliceStable(history, func(i, j int) bool { return raidVersion(history[i]) < raidVersion(history[j]) })
+			return history, nil
+		}},
+		{Name: "version", Type: raid, Args: []*graphql.Argument{{Name: "number", Type: graphql.NonNull{Of: graphql.Int}}}, Resolve: func(ctx context.Context, source any, args map[string]any) (any, error) {
+			prefix, suffix := raidHandle(source.(*models.RAiD))
+			return getRAiD(ctx, prefix, suffix, args["number"].(int))
+		}},
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: []*graphql.Field{
+		{
+			Name: "raid",
+			Type: raid,
+			Args: []*graphql.Argument{
+				{Name: "prefix", Type: graphql.NonNull{Of: str}},
+				{Name: "suffix", Type: graphql.NonNull{Of: str}},
+				{Name: "version", Type: graphql.Int, Description: "Defaults to the current version"},
+			},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				version, _ := args["version"].(int)
+				return getRAiD(ctx, args["prefix"].(string), args["suffix"].(string), version)
+			},
+		},
+		{
+			Name: "raids",
+			Type: graphql.NonNull{Of: connection},
+			Args: pageArgs(
+				&graphql.Argument{Name: "contributorId", Type: str},
+				&graphql.Argument{Name: "organisationId", Type: str},
+				&graphql.Argument{Name: "subjectId", Type: str, Description: "Also matches narrower subjects"},
+				&graphql.Argument{Name: "subjectKeyword", Type: str},
+				&graphql.Argument{Name: "text", Type: str, Description: "Matches titles and subject keywords containing it, ignoring case and diacritics"},
+				&graphql.Argument{Name: "publicOnly", Type: graphql.Boolean, Default: false},
+			),
+			Resolve: raids(func(_ any, args map[string]any) *storage.RAiDFilter {
+				f := &storage.RAiDFilter{}
+				f.ContributorID, _ = args["contributorId"].(string)
+				f.OrganisationID, _ = args["organisationId"].(string)
+				f.SubjectID, _ = args["subjectId"].(string)
+				f.SubjectKeyword, _ = args["subjectKeyword"].(string)
+				f.Text, _ = args["text"].(string)
+				return f
+			}),
+		},
+		{
+			Name: "servicePoint",
+			Type: servicePoint,
+			Args: []*graphql.Argument{{Name: "id", Type: graphql.NonNull{Of: graphql.ID}}},
+			Resolve: func(ctx context.Context, _ any, args map[string]any) (any, error) {
+				id, err := strconv.ParseInt(args["id"].(string), 10, 64)
+				if err != nil {
+					return nil, fmt.Errorf("invalid service point ID %q", args["id"])
+				}
+				return getServicePoint(ctx, id)
+			},
+		},
+		{
+			Name: "servicePoints",
+			Type: graphql.NonNull{Of: graphql.List{Of: graphql.NonNull{Of: servicePoint}}},
+			Resolve: func(ctx context.Context, _ any, _ map[string]any) (any, error) {
+				return repo.ListServicePoints(ctx)
+			},
+		},
+		{
+			Name: "contributor",
+			Type: graphql.NonNull{Of: contributor},
+			Args: []*graphql.Argument{{Name: "id", Type: graphql.NonNull{Of: str}, Description: "ORCID URL"}},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				return map[string]any{"id": args["id"]}, nil
+			},
+		},
+		{
+			Name: "organisation",
+			Type: graphql.NonNull{Of: organisation},
+			Args: []*graphql.Argument{{Name: "id", Type: graphql.NonNull{Of: str}, Description: "ROR URL"}},
+			Resolve: func(_ context.Context, _ any, args map[string]any) (any, error) {
+				return map[string]any{"id": args["id"]}, nil
+			},
+		},
+	}}
+
+	return &graphql.Schema{Query: query}
+}
+
+// relatedRaid is a relatedRaid link of a RAiD, resolving its RAiD through
+// the batch shared by the RAiD's other links
+type relatedRaid struct {
+	ID    string           `json:"id"`
+	Type  *models.IDSchema `json:"type"`
+	batch *relatedBatch
+	index int
+}
+
+// relatedBatch reads the RAiDs a RAiD links to with one GetRAiDs call, the
+// first time one of them is resolved
+type relatedBatch struct {
+	once  sync.Once
+	refs  []storage.IdentifierRef
+	slots []int // index into refs of each link, -1 if not a RAiD handle
+	raids []*models.RAiD
+	err   error
+}
+
+func relatedRaids(links []models.RelatedRAiD) []relatedRaid {
+	batch := &relatedBatch{slots: make([]int, len(links))}
+	related := make([]relatedRaid, len(links))
+	for i, link := range links {
+		related[i] = relatedRaid{ID: link.ID, Type: link.Type, batch: batch, index: i}
+		batch.slots[i] = -1
+		if prefix, suffix, err := identifier.Parse(link.ID); err == nil {
+			batch.slots[i] = len(batch.refs)
+			batch.refs = append(batch.refs, storage.IdentifierRef{Prefix: prefix, Suffix: suffix})
+		}
+	}
+	return related
+}
+
+// get returns the RAiD of link i, or nil if it is not registered here
+func (b *relatedBatch) get(ctx context.Context, repo storage.Repository, i int) (any, error) {
+	b.once.Do(func() { b.raids, b.err = repo.GetRAiDs(ctx, b.refs) })
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.slots[i] < 0 || b.raids[b.slots[i]] == nil {
+		return nil, nil
+	}
+	return b.raids[b.slots[i]], nil
+}
+
+// raidHandle returns the prefix and suffix of a stored RAiD
+func raidHandle(raid *models.RAiD) (prefix, suffix string) {
+	if raid.Identifier == nil {
+		return "", ""
+	}
+	prefix, suffix, _ = identifier.Parse(raid.Identifier.ID)
+	return prefix, suffix
+}
+
+func timestamp(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func newGraphQLTestRepository() *testutil.MockRepository {
+	repo := testutil.NewMockRepository()
+	a := testutil.NewTestRAiD("10.12345", "a")
+	a.RelatedRAiD = []models.RelatedRAiD{{ID: "https://raid.org/10.12345/b"}, {ID: "https://raid.org/10.12345/x"}}
+	b := testutil.NewTestRAiD("10.12345", "b")
+	raids := map[string]*models.RAiD{"a": a, "b": b}
+
+	repo.GetRAiDFunc = func(_ context.Context, prefix, suffix string) (*models.RAiD, error) {
+		if r, ok := raids[suffix]; ok && prefix == "10.12345" {
+			return r, nil
+		}
+		return nil, storage.ErrNotFound
+	}
+	repo.ListRAiDsFunc = func(_ context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+		all := []*models.RAiD{a, b}
+		if filter.Offset >= len(all) {
+			return nil, nil
+		}
+		all = all[filter.Offset:]
+		if filter.Limit < len(all) {
+			all = all[:filter.Limit]
+		}
+		return all, nil
+	}
+	repo.GetServicePointFunc = func(_ context.Context, id int64) (*models.ServicePoint, error) {
+		if id != 1 {
+			return nil, storage.ErrNotFound
+		}
+		return &models.ServicePoint{ID: 1, Name: "Test SP"}, nil
+	}
+	return repo
+}
+
+func TestGraphQL_Query(t *testing.T) {
+	repo := newGraphQLTestRepository()
+	batches := 0
+	repo.GetRAiDsFunc = func(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+		batches++
+		return storage.GetEach(ctx, repo.GetRAiD, refs)
+	}
+	handler := NewGraphQLHandler(repo)
+
+	body := `{"query":"query($first: Int) { raids(first: $first) { nodes { handle identifier { owner { servicePoint { name } } } relatedRaid { raid { handle } } } pageInfo { hasNextPage } } missing: raid(prefix: \"10.12345\", suffix: \"x\") { handle } }","variables":{"first":1}}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.Query(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rr.Code, rr.Body)
+	}
+	want := `{"data":{"raids":{"nodes":[{"handle":"10.12345/a","identifier":{"owner":{"servicePoint":{"name":"Test SP"}}},"relatedRaid":[{"raid":{"handle":"10.12345/b"}},{"raid":null}]}],"pageInfo":{"hasNextPage":true}},"missing":null}}`
+	if got := strings.TrimSpace(rr.Body.String()); got != want {
+		t.Errorf("Unexpected response\ngot  %s\nwant %s", got, want)
+	}
+	if repo.ListRAiDsCalls != 1 {
+		t.Errorf("Expected 1 ListRAiDs call, got %d", repo.ListRAiDsCalls)
+	}
+	if batches != 1 {
+		t.Errorf("Expected the related RAiDs to be read in 1 batch, got %d", batches)
+	}
+}
+
+func TestGraphQL_Get(t *testing.T) {
+	handler := NewGraphQLHandler(newGraphQLTestRepository())
+
+	q := url.Values{"query": {`{ contributor(id: "https://orcid.org/0000-0000-0000-0001") { raids(offset: 1) { nodes { handle } pageInfo { offset limit hasNextPage } } } }`}}
+	req := httptest.NewRequest(http.MethodGet, "/graphql?"+q.Encode(), nil)
+	rr := httptest.NewRecorder()
+	handler.Query(rr, req)
+
+	var resp struct {
+		Data struct {
+			Contributor struct {
+				Raids raidConnection `json:"raids"`
+			} `json:"contributor"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	page := resp.Data.Contributor.Raids
+	if len(page.Nodes) != 1 || page.PageInfo != (pageInfo{Offset: 1, Limit: defaultGraphQLPageSize}) {
+		t.Errorf("Unexpected page %+v", page)
+	}
+}
+
+func TestGraphQL_InvalidQuery(t *testing.T) {
+	handler := NewGraphQLHandler(newGraphQLTestRepository())
+
+	for _, body := range []string{`{"query":"{ raids { nodes { nope } } }"}`, `{"query":""}`, `{`} {
+		req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+		rr := httptest.NewRecorder()
+		handler.Query(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", body, rr.Code)
+		}
+	}
+}
+
+func TestGraphQL_PageSize(t *testing.T) {
+	handler := NewGraphQLHandler(newGraphQLTestRepository())
+
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{ raids(first: 1000) { nodes { handle } } }`))
+	req.Header.Set("Content-Type", "application/graphql")
+	rr := httptest.NewRecorder()
+	handler.Query(rr, req)
+
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "first must be between 1 and 100") {
+		t.Errorf("Expected a page size error, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestGraphQL_Schema(t *testing.T) {
+	handler := NewGraphQLHandler(testutil.NewMockRepository())
+
+	rr := httptest.NewRecorder()
+	handler.Schema(rr, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+
+	for _, want := range []string{"type Query {", "raids(contributorId: String, organisationId: String, subjectId: String, subjectKeyword: String, text: String, publicOnly: Boolean = false, first: Int = 20, offset: Int = 0): RAiDConnection!", "type ServicePoint {"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("Expected schema to contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/hooks"
+	"github.com/leifj/go-raid/internal/invitation"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/notify"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// InvitationHandler handles invitations to contributors known only by
+// email to attach their ORCID iD
+type InvitationHandler struct {
+	storage  storage.Repository
+	signer   *invitation.Signer
+	notifier *notify.Notifier
+	link     string
+}
+
+// NewInvitationHandler creates a new invitation handler
+func NewInvitationHandler(repo storage.Repository, signer *invitation.Signer) *InvitationHandler {
+	return &InvitationHandler{
+		storage: repo,
+		signer:  signer,
+	}
+}
+
+// WithNotifier emails invitation links to invitees through n, and returns
+// h. link is the URL sent, with {token} replaced by the invitation token;
+// empty means the invitation path on the host the invitation was made
+// through.
+func (h *InvitationHandler) WithNotifier(n *notify.Notifier, link string) *InvitationHandler {
+	h.notifier = n
+	h.link = link
+	return h
+}
+
+// InviteRequest is the body of POST /raid/{prefix}/{suffix}/invitations
+type InviteRequest struct {
+	Email string `json:"email"`
+	// Position and Role are used when the invitee is added as a new
+	// contributor
+	Position []models.ContributorPosition `json:"position,omitempty"`
+	Role     []models.IDSchema            `json:"role,omitempty"`
+}
+
+// InviteResponse is returned for a new invitation. Path is the invitation
+// link relative to the server root, to be passed on to the invitee.
+type InviteResponse struct {
+	Contributor models.Contributor `json:"contributor"`
+	Token       string             `json:"token"`
+	Path        string             `json:"path"`
+	Expires     time.Time          `json:"expires"`
+}
+
+// InvitationDetails is returned by GET /invitations/{token}
+type InvitationDetails struct {
+	RAiD    string    `json:"raid"`
+	Title   string    `json:"title,omitempty"`
+	Email   string    `json:"email"`
+	Status  string    `json:"status"`
+	Expires time.Time `json:"expires"`
+}
+
+// InvitationResponse is the body of POST /invitations/{token}
+type InvitationResponse struct {
+	Accept bool   `json:"accept"`
+	ORCID  string `json:"orcid,omitempty"`
+}
+
+// Invite handles POST /raid/{prefix}/{suffix}/invitations - invites a
+// contributor by email. A contributor of the RAiD with that email and no
+// ORCID iD is invited; otherwise one is added. The contributor is marked
+// pending until the invitee responds.
+func (h *InvitationHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
+	var req InviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	req.Email = strings.TrimSpace(req.Email)
+	if !strings.Contains(req.Email, "@") {
+		http.Error(w, "A valid email address is required", http.StatusBadRequest)
+		return
+	}
+
+	raid, ok := h.getRAiD(w, r, prefix, suffix)
+	if !ok {
+		return
+	}
+	i := invitee(raid, req.Email)
+	if i < 0 {
+		raid.Contributor = append(raid.Contributor, models.Contributor{Email: req.Email, Position: req.Position, Role: req.Role})
+		i = len(raid.Contributor) - 1
+	} else if raid.Contributor[i].ID != "" {
+		http.Error(w, "Contributor already has an ORCID iD", http.StatusConflict)
+		return
+	}
+	raid.Contributor[i].Status = models.ContributorStatusPending
+	raid.Contributor[i].StatusMessage = "Invited " + today()
+
+	raid, ok = h.updateRAiD(w, r, prefix, suffix, raid)
+	if !ok {
+		return
+	}
+	c := raid.Contributor[invitee(raid, req.Email)]
+	inv := &invitation.Invitation{Prefix: prefix, Suffix: suffix, Contributor: c.UUID, Email: c.Email}
+	token, err := h.signer.Sign(inv)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Under the API version prefix the invitation was created with
+	prefixPath := strings.TrimSuffix(r.URL.EscapedPath(), middleware.RoutePath(r))
+	path := prefixPath + "/invitations/" + token
+	if h.notifier != nil {
+		h.notifier.Invitation(r.Context(), raid, c.Email, h.invitationLink(r, path, token), inv.Expires)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(InviteResponse{Contributor: c, Token: token, Path: path, Expires: inv.Expires})
+}
+
+// GetInvitation handles GET /invitations/{token} - describes an invitation
+// to the invitee
+func (h *InvitationHandler) GetInvitation(w http.ResponseWriter, r *http.Request) {
+	inv, raid, i, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	details := InvitationDetails{RAiD: raid.Identifier.ID, Email: inv.Email, Status: raid.Contributor[i].Status, Expires: inv.Expires}
+	if len(raid.Title) > 0 {
+		details.Title = raid.Title[0].Text
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(details)
+}
+
+// RespondToInvitation handles POST /invitations/{token} - the invitee
+// accepts, attaching their ORCID iD, or declines. Either way the RAiD gets
+// a new version recording the contributor's new status.
+func (h *InvitationHandler) RespondToInvitation(w http.ResponseWriter, r *http.Request) {
+	var req InvitationResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	inv, raid, i, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	c := &raid.Contributor[i]
+	if c.Status != models.ContributorStatusPending {
+		http.Error(w, "Invitation has already been answered", http.StatusConflict)
+		return
+	}
+
+	if req.Accept {
+		orcid, err := invitation.ParseORCID(req.ORCID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.ID, c.SchemaURI = orcid, invitation.ORCIDBase
+		c.Status, c.StatusMessage = models.ContributorStatusAuthenticated, "Accepted "+today()
+	} else {
+		c.Status, c.StatusMessage = models.ContributorStatusUnauthenticated, "Declined "+today()
+	}
+
+	raid, ok = h.updateRAiD(w, r, inv.Prefix, inv.Suffix, raid)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(raid)
+}
+
+// open verifies the invitation token of a request and returns the
+// invitation, the RAiD and the index of the invited contributor
+func (h *InvitationHandler) open(w http.ResponseWriter, r *http.Request) (*invitation.Invitation, *models.RAiD, int, bool) {
+	inv, err := h.signer.Verify(chi.URLParam(r, "token"))
+	if err == invitation.ErrExpired {
+		http.Error(w, "Invitation has expired", http.StatusGone)
+		return nil, nil, 0, false
+	}
+	if err != nil {
+		http.Error(w, "Invitation not found", http.StatusNotFound)
+		return nil, nil, 0, false
+	}
+	raid, ok := h.getRAiD(w, r, inv.Prefix, inv.Suffix)
+	if !ok {
+		return nil, nil, 0, false
+	}
+	for i, c := range raid.Contributor {
+		if c.UUID == inv.Contributor {
+			return inv, raid, i, true
+		}
+	}
+	// The contributor has been removed since the invitation was sent
+	http.Error(w, "Invitation is no longer valid", http.StatusGone)
+	return nil, nil, 0, false
+}
+
+func (h *InvitationHandler) getRAiD(w http.ResponseWriter, r *http.Request, prefix, suffix string) (*models.RAiD, bool) {
+	raid, err := h.storage.GetRAiD(r.Context(), prefix, suffix)
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return nil, false
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return nil, false
+		}
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	return raid, true
+}
+
+func (h *InvitationHandler) updateRAiD(w http.ResponseWriter, r *http.Request, prefix, suffix string, raid *models.RAiD) (*models.RAiD, bool) {
+	raid, err := h.storage.UpdateRAiD(r.Context(), prefix, suffix, raid)
+	if err != nil {
+		if writeIdentifierError(w, err) || writeVocabularyError(w, err) || writeValidationError(w, r, err) {
+			return nil, false
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return nil, false
+		}
+		var veto *hooks.VetoError
+		if errors.As(err, &veto) {
+			http.Error(w, veto.Reason, http.StatusUnprocessableEntity)
+			return nil, false
+		}
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	return raid, true
+}
+
+// invitationLink returns the link emailed to an invitee, given the path of
+// the invitation
+func (h *InvitationHandler) invitationLink(r *http.Request, path, token string) string {
+	if h.link != "" {
+		return strings.ReplaceAll(h.link, "{token}", url.PathEscape(token))
+	}
+	return requestURL(r, path)
+}
+
+// invitee returns the index of the contributor of raid with email, or -1
+func invitee(raid *models.RAiD, email string) int {
+	for i, c := range raid.Contributor {
+		if strings.EqualFold(strings.TrimSpace(c.Email), email) {
+			return i
+		}
+	}
+	return -1
+}
+
+func today() string {
+	return time.Now().UTC().Format("2006-01-02")
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/language"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// languagePreferences returns the languages the response to r selects the
+// titles, descriptions and keywords of RAiDs in, most preferred first, or
+// nil for the full multilingual record. ?lang lists them, or is * for the
+// full record; without it the Accept-Language header does.
+func languagePreferences(w http.ResponseWriter, r *http.Request) ([]string, error) {
+	if !r.URL.Query().Has("lang") {
+		w.Header().Add("Vary", "Accept-Language")
+		return language.Preferences(r.Header.Get("Accept-Language")), nil
+	}
+	list := strings.TrimSpace(r.URL.Query().Get("lang"))
+	if list == "*" {
+		return nil, nil
+	}
+	prefs := language.Preferences(list)
+	if len(prefs) == 0 {
+		return nil, errors.New("lang must list ISO 639 language codes, or be * for all languages")
+	}
+	return prefs, nil
+}
+
+// localize returns raids with their metadata in the languages r asks for,
+// see languagePreferences. It writes an error and returns false for a
+// malformed ?lang.
+func localize(w http.ResponseWriter, r *http.Request, raids ...*models.RAiD) ([]*models.RAiD, bool) {
+	prefs, err := languagePreferences(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+	if len(prefs) == 0 {
+		return raids, true
+	}
+	selected := make([]*models.RAiD, len(raids))
+	for i, raid := range raids {
+		selected[i] = language.Select(raid, prefs)
+	}
+	return selected, true
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Memento (RFC 7089) over the version history: GET /raid/{prefix}/{suffix}
+// is the original resource, each GET /raid/{prefix}/{suffix}/{version} a
+// memento of it, dated when the version was written, and the timegate and
+// timemap below negotiate and list them. Datetimes are HTTP dates, so
+// versions are dated to the second.
+
+// linkFormat is the media type of TimeMaps
+const linkFormat = "application/link-format"
+
+// TimeGate handles GET /raid/{prefix}/{suffix}/timegate - redirects to the
+// version of a RAiD current at its Accept-Datetime, the latest without
+// one, or the first if the RAiD was minted after it
+func (h *RAiDHandler) TimeGate(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
+	at := time.Now()
+	if accept := r.Header.Get("Accept-Datetime"); accept != "" {
+		var err error
+		if at, err = http.ParseTime(accept); err != nil {
+			http.Error(w, "Accept-Datetime must be an HTTP date, e.g. Thu, 01 Feb 2024 12:00:00 GMT", http.StatusBadRequest)
+			return
+		}
+	}
+	changes, ok := h.mementos(w, r, prefix, suffix)
+	if !ok {
+		return
+	}
+	version := changes[0].Version
+	for _, change := range changes {
+		if !change.Timestamp.Truncate(time.Second).After(at) {
+			version = change.Version
+		}
+	}
+
+	w.Header().Set("Vary", "accept-datetime")
+	w.Header().Set("Link", strings.Join([]string{
+		fmt.Sprintf(`<%s>; rel="original"`, raidURL(r, prefix, suffix)),
+		fmt.Sprintf(`<%s>; rel="timemap"; type="%s"`, raidURL(r, prefix, suffix, "timemap"), linkFormat),
+	}, ", "))
+	w.Header().Set("Location", raidURL(r, prefix, suffix, strconv.Itoa(version)))
+	w.WriteHeader(http.StatusFound)
+}
+
+// TimeMap handles GET /raid/{prefix}/{suffix}/timemap - lists the versions
+// of a RAiD as mementos in link format
+func (h *RAiDHandler) TimeMap(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
+	changes, ok := h.mementos(w, r, prefix, suffix)
+	if !ok {
+		return
+	}
+	first, last := changes[0].Timestamp, changes[len(changes)-1].Timestamp
+	links := []string{
+		fmt.Sprintf(`<%s>; rel="original"`, raidURL(r, prefix, suffix)),
+		fmt.Sprintf(`<%s>; rel="timegate"`, raidURL(r, prefix, suffix, "timegate")),
+		fmt.Sprintf(`<%s>; rel="self"; type="%s"; from="%s"; until="%s"`,
+			raidURL(r, prefix, suffix, "timemap"), linkFormat, httpDate(first), httpDate(last)),
+	}
+	for i, change := range changes {
+		rel := "memento"
+		switch {
+		case len(changes) == 1:
+			rel = "first last memento"
+		case i == 0:
+			rel = "first memento"
+		case i == len(changes)-1:
+			rel = "last memento"
+		}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"; datetime="%s"`,
+			raidURL(r, prefix, suffix, strconv.Itoa(change.Version)), rel, httpDate(change.Timestamp)))
+	}
+	w.Header().Set("Content-Type", linkFormat)
+	w.Write([]byte(strings.Join(links, ",\n") + "\n"))
+}
+
+// mementos returns the changes of a RAiD, oldest first, without patches
+func (h *RAiDHandler) mementos(w http.ResponseWriter, r *http.Request, prefix, suffix string) ([]storage.VersionChange, bool) {
+	changes, err := h.storage.GetRAiDChanges(r.Context(), prefix, suffix, &storage.HistoryPage{Summary: true})
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return nil, false
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return nil, false
+		}
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	if len(changes) == 0 {
+		http.Error(w, "RAiD not found", http.StatusNotFound)
+		return nil, false
+	}
+	return changes, true
+}
+
+// setOriginalLinks links the current RAiD, the original resource, to its
+// timegate and timemap
+func setOriginalLinks(w http.ResponseWriter, r *http.Request, prefix, suffix string) {
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="timegate", <%s>; rel="timemap"; type="%s"`,
+		raidURL(r, prefix, suffix, "timegate"), raidURL(r, prefix, suffix, "timemap"), linkFormat))
+}
+
+// setMementoHeaders dates a version of a RAiD as a memento, linking it to
+// the original resource, its timegate and timemap
+func setMementoHeaders(w http.ResponseWriter, r *http.Request, prefix, suffix string, raid *models.RAiD) {
+	if raid.Metadata == nil {
+		return
+	}
+	written := raid.Metadata.Updated
+	if written.IsZero() {
+		written = raid.Metadata.Created
+	}
+	w.Header().Set("Memento-Datetime", httpDate(written))
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="original", <%s>; rel="timegate", <%s>; rel="timemap"; type="%s"`,
+		raidURL(r, prefix, suffix), raidURL(r, prefix, suffix, "timegate"), raidURL(r, prefix, suffix, "timemap"), linkFormat))
+}
+
+// raidURL returns the absolute URL of the RAiD path made of segments
+// after /raid/{prefix}/{suffix}, for references that leave the server:
+// Memento clients follow links between hosts, and related objects are
+// read from RAiDs anywhere.
+func raidURL(r *http.Request, prefix, suffix string, segments ...string) string {
+	ref, err := url.Parse(rootRef(r, append([]string{"raid", prefix, suffix}, segments...)...))
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(requestURL(r, r.URL.EscapedPath()))
+	if err != nil {
+		return ref.String()
+	}
+	return base.ResolveReference(ref).String()
+}
+
+func httpDate(t time.Time) string {
+	return t.UTC().Format(http.TimeFormat)
+}
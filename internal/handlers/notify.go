@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/coarnotify"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// NotifyHandler handles the COAR Notify inbox, which other services
+// announce related objects of RAiDs to, and the proposals the
+// announcements are held as until the RAiD's service point accepts or
+// rejects them
+type NotifyHandler struct {
+	storage   storage.Repository
+	proposals storage.ProposalStore
+}
+
+// NewNotifyHandler creates a new notification handler, holding proposals
+// in proposals and adding accepted ones to RAiDs in repo
+func NewNotifyHandler(repo storage.Repository, proposals storage.ProposalStore) *NotifyHandler {
+	return &NotifyHandler{storage: repo, proposals: proposals}
+}
+
+// Inbox handles POST /inbox - receives a COAR Notify Announce
+// Relationship notification, an LDN, holding the related object it
+// announces for a RAiD as a proposal. No authentication is needed; nothing
+// is added to the RAiD until its service point accepts the proposal.
+func (h *NotifyHandler) Inbox(w http.ResponseWriter, r *http.Request) {
+	if media, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); media != coarnotify.ContentType && media != "application/json" {
+		http.Error(w, "Notifications must be "+coarnotify.ContentType, http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	n, err := coarnotify.Parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rel, err := n.Relationship()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Either end of the relationship may be the RAiD
+	var raid *models.RAiD
+	related := ""
+	for _, ends := range [][2]string{{rel.Subject, rel.Object}, {rel.Object, rel.Subject}} {
+		prefix, suffix, err := identifier.Parse(ends[0])
+		if err != nil {
+			continue
+		}
+		found, err := h.storage.GetRAiD(r.Context(), prefix, suffix)
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		raid, related = found, ends[1]
+		break
+	}
+	if raid == nil {
+		http.Error(w, "Neither end of the relationship is a RAiD held here", http.StatusUnprocessableEntity)
+		return
+	}
+	prefix, suffix, _ := identifier.Parse(raid.Identifier.ID)
+	obj := n.RelatedObject(related)
+
+	var servicePoint int64
+	if raid.Identifier.Owner != nil {
+		servicePoint = raid.Identifier.Owner.ServicePoint
+	}
+	// Repeated announcements, and those of objects already related, are
+	// taken without holding them again
+	for _, existing := range raid.RelatedObject {
+		if existing.ID == obj.ID {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+	held, err := h.proposals.ListProposals(r.Context(), servicePoint)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	for _, p := range held {
+		if p.Notification == n.ID || (p.Prefix == prefix && p.Suffix == suffix && p.RelatedObject.ID == obj.ID) {
+			w.Header().Set("Location", rootRef(r, "proposals", p.ID))
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+	}
+
+	now := time.Now().UTC()
+	proposal := &models.Proposal{
+		ID:            identifier.NewULID(now),
+		ServicePoint:  servicePoint,
+		Prefix:        prefix,
+		Suffix:        suffix,
+		RelatedObject: obj,
+		Origin:        n.Origin.ID,
+		Inbox:         n.Origin.Inbox,
+		Notification:  n.ID,
+		Created:       now,
+	}
+	if err := h.proposals.SaveProposal(r.Context(), proposal); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	coarnotify.Received()
+	w.Header().Set("Location", rootRef(r, "proposals", proposal.ID))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// ListInbox handles GET /inbox - lists, as LDP containment, the proposals
+// held for the RAiDs of the caller's service point. Anonymous callers see
+// an empty inbox.
+func (h *NotifyHandler) ListInbox(w http.ResponseWriter, r *http.Request) {
+	contains := make([]string, 0)
+	if servicePoint, ok := middleware.GetServicePointID(r.Context()); ok && servicePoint != 0 {
+		proposals, err := h.proposals.ListProposals(r.Context(), servicePoint)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		for _, p := range proposals {
+			contains = append(contains, rootRef(r, "proposals", p.ID))
+		}
+	}
+	w.Header().Set("Content-Type", coarnotify.ContentType)
+	json.NewEncoder(w).Encode(map[string]any{
+		"@context": "http://www.w3.org/ns/ldp",
+		"@id":      "",
+		"contains": contains,
+	})
+}
+
+// ListProposals handles GET /proposals - lists the proposals held for the
+// RAiDs of the caller's service point
+func (h *NotifyHandler) ListProposals(w http.ResponseWriter, r *http.Request) {
+	servicePoint, ok := middleware.GetServicePointID(r.Context())
+	if !ok || servicePoint == 0 {
+		http.Error(w, "The token names no service point", http.StatusForbidden)
+		return
+	}
+	proposals, err := h.proposals.ListProposals(r.Context(), servicePoint)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeList(w, r, proposals, listPage{}, func(p *models.Proposal) map[string]string {
+		return map[string]string{"self": rootRef(r, "proposals", p.ID), "raid": rootRef(r, "raid", p.Prefix, p.Suffix)}
+	})
+}
+
+// GetProposal handles GET /proposals/{id} - retrieves a proposal
+func (h *NotifyHandler) GetProposal(w http.ResponseWriter, r *http.Request) {
+	proposal, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proposal)
+}
+
+// AcceptProposal handles POST /proposals/{id}/accept - adds the proposed
+// related object to the RAiD as a new version, and drops the proposal
+func (h *NotifyHandler) AcceptProposal(w http.ResponseWriter, r *http.Request) {
+	proposal, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	raid, err := h.storage.GetRAiD(r.Context(), proposal.Prefix, proposal.Suffix)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+	related := false
+	for _, obj := range raid.RelatedObject {
+		related = related || obj.ID == proposal.RelatedObject.ID
+	}
+	if !related {
+		raid.RelatedObject = append(raid.RelatedObject, proposal.RelatedObject)
+		if raid, err = h.storage.UpdateRAiD(r.Context(), proposal.Prefix, proposal.Suffix, raid); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+	}
+	if err := h.proposals.DeleteProposal(r.Context(), proposal.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(raid)
+}
+
+// RejectProposal handles DELETE /proposals/{id} - drops a proposal
+func (h *NotifyHandler) RejectProposal(w http.ResponseWriter, r *http.Request) {
+	proposal, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	if err := h.proposals.DeleteProposal(r.Context(), proposal.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// open returns the proposal a request names, if the caller belongs to the
+// service point of its RAiD or is an operator
+func (h *NotifyHandler) open(w http.ResponseWriter, r *http.Request) (*models.Proposal, bool) {
+	proposal, err := h.proposals.GetProposal(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Proposal not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	if !isMember(r, proposal.ServicePoint) {
+		// Proposals of other service points are not disclosed
+		http.Error(w, "Proposal not found", http.StatusNotFound)
+		return nil, false
+	}
+	return proposal, true
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// organisationRoleGroups names the groups of GET /organisation/{ror}/raids
+// by role ID. Roles outside the vocabulary are grouped as other.
+var organisationRoleGroups = map[string]string{
+	models.OrganisationRoleLead:          "lead",
+	models.OrganisationRoleOtherResearch: "other-research",
+	models.OrganisationRolePartner:       "partner",
+	models.OrganisationRoleContractor:    "contractor",
+	models.OrganisationRoleFunder:        "funder",
+	models.OrganisationRoleFacility:      "facility",
+	models.OrganisationRoleOther:         "other",
+}
+
+// roleGroup returns the group of an organisation role ID
+func roleGroup(id string) string {
+	if group, ok := organisationRoleGroups[id]; ok {
+		return group
+	}
+	return "other"
+}
+
+// OrganisationRAiDs handles GET /organisation/{ror}/raids - the RAiDs an
+// organisation appears in, grouped by its role in them, with counts per
+// role. ?role limits the listing to one group. Counts are taken over every
+// matching RAiD, so all of them are read and the page is cut afterwards.
+func (h *RAiDHandler) OrganisationRAiDs(w http.ResponseWriter, r *http.Request) {
+	// chi leaves escaped slashes of a ROR URL escaped
+	param, err := url.PathUnescape(chi.URLParam(r, "ror"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ror, ok := identifier.NormalizeROR(param)
+	if !ok {
+		http.Error(w, fmt.Sprintf("%q is not a ROR ID", param), http.StatusBadRequest)
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	role := r.URL.Query().Get("role")
+	if role != "" && !knownGroup(role) {
+		http.Error(w, fmt.Sprintf("unknown role %q", role), http.StatusBadRequest)
+		return
+	}
+
+	raids, err := h.storage.ListRAiDs(r.Context(), &storage.RAiDFilter{OrganisationID: ror})
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	resp := models.OrganisationRAiDs{Organisation: ror, Counts: map[string]int{}, Roles: map[string][]models.OrganisationRAiD{}}
+	var matching []models.OrganisationRAiD
+	for _, raid := range raids {
+		var item *models.OrganisationRAiD
+		for _, o := range raid.Organisation {
+			if o.ID == ror {
+				item = &models.OrganisationRAiD{ID: raid.Identifier.ID, Title: primaryTitle(raid), Role: o.Role}
+				break
+			}
+		}
+		if item == nil {
+			continue
+		}
+		groups := itemGroups(item)
+		for group := range groups {
+			resp.Counts[group]++
+		}
+		if role == "" || groups[role] {
+			matching = append(matching, *item)
+		}
+	}
+	resp.Total = len(matching)
+
+	start := min(page.Offset, len(matching))
+	end := min(start+page.Limit, len(matching))
+	page.HasMore = end < len(matching)
+	resp.Page = page
+	for _, item := range matching[start:end] {
+		for group := range itemGroups(&item) {
+			if role == "" || group == role {
+				resp.Roles[group] = append(resp.Roles[group], item)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// knownGroup reports whether group names a group of organisation roles
+func knownGroup(group string) bool {
+	for _, g := range organisationRoleGroups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// itemGroups returns the set of groups of an organisation's roles in a
+// RAiD; an organisation listed without roles is grouped as other
+func itemGroups(item *models.OrganisationRAiD) map[string]bool {
+	groups := map[string]bool{}
+	for _, role := range item.Role {
+		groups[roleGroup(role.ID)] = true
+	}
+	if len(groups) == 0 {
+		groups["other"] = true
+	}
+	return groups
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/retention"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// WithRetention reports on and runs the retention window through e
+func (h *AdminHandler) WithRetention(e *retention.Enforcer) *AdminHandler {
+	h.retention = e
+	return h
+}
+
+// ListDeletedRAiDs handles GET /admin/raids/deleted - lists soft deleted
+// RAiDs with the filters and paging of GET /raid/ and ?servicePoint, each
+// with the time it was deleted where the backend recorded it
+func (h *AdminHandler) ListDeletedRAiDs(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseRAiDFilter(r)
+	if err == nil {
+		err = parseServicePoint(r, filter)
+	}
+	if err == nil {
+		err = parseCursor(r, filter)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.DeletedOnly = true
+
+	raids, err := h.storage.ListRAiDs(r.Context(), filter)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeList(w, r, raids, raidPage(r, raids, filter), raidLinks(r))
+}
+
+// purgeRequest is the body of POST /admin/raids/{prefix}/{suffix}/purge
+type purgeRequest struct {
+	// Reason is recorded with the purge and is required
+	Reason string `json:"reason"`
+}
+
+// PurgeRAiD handles POST /admin/raids/{prefix}/{suffix}/purge - removes a
+// soft deleted RAiD with all its versions for good, recording who purged
+// it and why
+func (h *AdminHandler) PurgeRAiD(w http.ResponseWriter, r *http.Request) {
+	purger, ok := h.storage.(storage.Purger)
+	if !ok {
+		http.Error(w, retention.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	var req purgeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "A reason for the purge is required", http.StatusBadRequest)
+		return
+	}
+
+	actor, _ := middleware.GetUserID(r.Context())
+	if actor == "" {
+		actor, _ = middleware.GetUserEmail(r.Context())
+	}
+	purge := &models.Purge{
+		Prefix: chi.URLParam(r, "prefix"),
+		Suffix: chi.URLParam(r, "suffix"),
+		Actor:  actor,
+		Reason: strings.TrimSpace(req.Reason),
+		Purged: time.Now().UTC(),
+	}
+	if err := purger.PurgeRAiD(r.Context(), purge); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound):
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+		case errors.Is(err, storage.ErrNotDeleted):
+			http.Error(w, "Only deleted RAiDs can be purged", http.StatusConflict)
+		default:
+			writeStorageError(w, r, err)
+		}
+		return
+	}
+	h.invalidate(r, purge.Prefix, purge.Suffix)
+	log.Printf("RAiD %s/%s purged by %q: %s", purge.Prefix, purge.Suffix, purge.Actor, purge.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(purge)
+}
+
+// ListPurges handles GET /admin/purges - lists the recorded purges, oldest
+// first
+func (h *AdminHandler) ListPurges(w http.ResponseWriter, r *http.Request) {
+	purger, ok := h.storage.(storage.Purger)
+	if !ok {
+		http.Error(w, retention.ErrUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+
+	purges, err := purger.ListPurges(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(purges)
+}
+
+// RetentionStatus handles GET /admin/retention/status - reports the
+// scheduled removal of data older than its retention window
+func (h *AdminHandler) RetentionStatus(w http.ResponseWriter, r *http.Request) {
+	if h.retention == nil {
+		http.Error(w, "Retention is not configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.retention.Status())
+}
+
+// RunRetention handles POST /admin/retention/run - removes the data past
+// its retention window now, or with ?dryRun=true reports what would be
+// removed
+func (h *AdminHandler) RunRetention(w http.ResponseWriter, r *http.Request) {
+	if h.retention == nil {
+		http.Error(w, "Retention is not configured", http.StatusNotFound)
+		return
+	}
+
+	if r.URL.Query().Get("dryRun") == "true" {
+		summary, err := h.retention.DryRun(r.Context())
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+		return
+	}
+
+	status, err := h.retention.RunNow(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
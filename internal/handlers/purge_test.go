@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+// serveAs serves a request to h as the authenticated user userID and
+// returns the response
+func serveAs(h http.Handler, userID, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), middleware.UserIDKey, userID))
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestAdminPurge(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"a", "b"} {
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.DeleteRAiD(ctx, "10.99999", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewAdminHandler(repo, storage.StorageTypeFile, nil, nil, nil, nil, "", nil)
+	router := chi.NewRouter()
+	router.Get("/admin/raids/deleted", handler.ListDeletedRAiDs)
+	router.Post("/admin/raids/{prefix}/{suffix}/purge", handler.PurgeRAiD)
+	router.Get("/admin/purges", handler.ListPurges)
+
+	rr := serveAs(router, "operator-1", http.MethodGet, "/admin/raids/deleted", "")
+	var deleted []*models.RAiD
+	if err := json.NewDecoder(rr.Body).Decode(&deleted); err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].Identifier.ID != "https://raid.org/10.99999/a" || deleted[0].Metadata.DeletedAt.IsZero() {
+		t.Fatalf("expected the deleted RAiD with its deletion time, got %+v", deleted)
+	}
+
+	for _, tc := range []struct {
+		path, body string
+		want       int
+	}{
+		{"/admin/raids/10.99999/a/purge", `{}`, http.StatusBadRequest},
+		{"/admin/raids/10.99999/b/purge", `{"reason":"Court order"}`, http.StatusConflict},
+		{"/admin/raids/10.99999/c/purge", `{"reason":"Court order"}`, http.StatusNotFound},
+		{"/admin/raids/10.99999/a/purge", `{"reason":"Court order"}`, http.StatusOK},
+		{"/admin/raids/10.99999/a/purge", `{"reason":"Court order"}`, http.StatusNotFound},
+	} {
+		if rr := serveAs(router, "operator-1", http.MethodPost, tc.path, tc.body); rr.Code != tc.want {
+			t.Errorf("POST %s %s: expected %d, got %d: %s", tc.path, tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr = serveAs(router, "operator-1", http.MethodGet, "/admin/purges", "")
+	var purges []models.Purge
+	if err := json.NewDecoder(rr.Body).Decode(&purges); err != nil {
+		t.Fatal(err)
+	}
+	if len(purges) != 1 || purges[0].Actor != "operator-1" || purges[0].Reason != "Court order" {
+		t.Errorf("expected the purge on record, got %+v", purges)
+	}
+	if rr := serveAs(router, "operator-1", http.MethodGet, "/admin/raids/deleted", ""); strings.TrimSpace(rr.Body.String()) != "[]" {
+		t.Errorf("expected no deleted RAiDs after the purge, got %s", rr.Body.String())
+	}
+}
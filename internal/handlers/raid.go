@@ -1,43 +1,64 @@
 package handlers
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/access"
+	"github.com/leifj/go-raid/internal/expr"
+	"github.com/leifj/go-raid/internal/hooks"
+	"github.com/leifj/go-raid/internal/identifier"
 	"github.com/leifj/go-raid/internal/models"
 	"github.com/leifj/go-raid/internal/storage"
 )
 
 // RAiDHandler handles RAiD-related HTTP requests
 type RAiDHandler struct {
-	storage storage.Repository
+	storage    storage.Repository
+	limits     DocumentLimits
+	schedules  storage.ScheduleStore
+	aggregator storage.Aggregator
 }
 
-// NewRAiDHandler creates a new RAiD handler
-func NewRAiDHandler(repo storage.Repository) *RAiDHandler {
+// NewRAiDHandler creates a new RAiD handler accepting documents within
+// limits
+func NewRAiDHandler(repo storage.Repository, limits DocumentLimits) *RAiDHandler {
 	return &RAiDHandler{
 		storage: repo,
+		limits:  limits,
 	}
 }
 
-// MintRAiD handles POST /raid/ - creates a new RAiD
+// MintRAiD handles POST /raid/ - creates a new RAiD. The optional
+// projectType query parameter is used to allocate the prefix. With a
+// future effectiveAt the mint is scheduled instead.
 func (h *RAiDHandler) MintRAiD(w http.ResponseWriter, r *http.Request) {
-	var req models.RAiD
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, err := decodeRAiD(w, r, h.limits)
+	if err != nil {
+		if !writeValidationError(w, r, err) {
+			writeDecodeError(w, err)
+		}
+		return
+	}
+	if at, ok := h.effectiveAt(w, r); !ok {
+		return
+	} else if !at.IsZero() {
+		h.schedule(w, r, "", "", req, at)
 		return
 	}
 
-	// Create RAiD using storage
-	raid, err := h.storage.CreateRAiD(r.Context(), &req)
-	if err != nil {
-		if err == storage.ErrAlreadyExists {
-			http.Error(w, "RAiD already exists", http.StatusConflict)
-			return
-		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	raid, ok := h.mint(w, r, req)
+	if !ok {
 		return
 	}
 
@@ -46,13 +67,193 @@ func (h *RAiDHandler) MintRAiD(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(raid)
 }
 
+// mint creates req, writing the error response if that fails
+func (h *RAiDHandler) mint(w http.ResponseWriter, r *http.Request, req *models.RAiD) (*models.RAiD, bool) {
+	// The project type selects the prefix for service points that
+	// allocate prefixes by project type
+	ctx := r.Context()
+	if projectType := r.URL.Query().Get("projectType"); projectType != "" {
+		ctx = storage.WithProjectType(ctx, projectType)
+	}
+
+	// Create RAiD using storage
+	raid, err := h.storage.CreateRAiD(ctx, req)
+	if err != nil {
+		if writeIdentifierError(w, err) || writeVocabularyError(w, err) || writeValidationError(w, r, err) {
+			return nil, false
+		}
+		if err == storage.ErrAlreadyExists {
+			http.Error(w, "RAiD already exists", http.StatusConflict)
+			return nil, false
+		}
+		if err == storage.ErrAccessDenied {
+			http.Error(w, "Service point not available", http.StatusForbidden)
+			return nil, false
+		}
+		var veto *hooks.VetoError
+		if errors.As(err, &veto) {
+			http.Error(w, veto.Reason, http.StatusUnprocessableEntity)
+			return nil, false
+		}
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	return raid, true
+}
+
 // FindAllRAiDs handles GET /raid/ - lists all RAiDs
 func (h *RAiDHandler) FindAllRAiDs(w http.ResponseWriter, r *http.Request) {
-	// Parse query parameters
+	h.findRAiDs(w, r, h.storage.ListRAiDs)
+}
+
+// findRAiDs writes the RAiDs list finds for the filter and page in the
+// query parameters
+func (h *RAiDHandler) findRAiDs(w http.ResponseWriter, r *http.Request, list func(context.Context, *storage.RAiDFilter) ([]*models.RAiD, error)) {
+	filter, err := parseRAiDFilter(r)
+	if err == nil {
+		err = parseCursor(r, filter)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	complete, err := parseCompletenessFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// List RAiDs. Backends cannot score completeness, so with a
+	// completeness filter every matching RAiD is read and the page is cut
+	// afterwards.
+	selected := filter
+	if complete != nil {
+		all := *filter
+		all.Limit, all.Offset = 0, 0
+		selected = &all
+	}
+	raids, err := list(r.Context(), selected)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	if complete != nil {
+		raids = page(slices.DeleteFunc(raids, func(raid *models.RAiD) bool { return !complete(raid) }), filter)
+	}
+
+	raids, ok := localize(w, r, raids...)
+	if !ok {
+		return
+	}
+	writeList(w, r, raids, raidPage(r, raids, filter), raidLinks(r))
+}
+
+// parseRAiDFilter reads the filter and page of a RAiD listing from the
+// query parameters
+func parseRAiDFilter(r *http.Request) (*storage.RAiDFilter, error) {
 	filter := &storage.RAiDFilter{
-		ContributorID:  r.URL.Query().Get("contributor.id"),
-		OrganisationID: r.URL.Query().Get("organisation.id"),
+		ContributorID:               r.URL.Query().Get("contributor.id"),
+		OrganisationID:              r.URL.Query().Get("organisation.id"),
+		TraditionalKnowledgeLabelID: r.URL.Query().Get("traditionalKnowledgeLabel.id"),
+		SubjectID:                   r.URL.Query().Get("subject.id"),
+		SubjectKeyword:              r.URL.Query().Get("subject.keyword"),
+		Text:                        r.URL.Query().Get("q"),
+	}
+	if has := r.URL.Query().Get("traditionalKnowledgeLabel"); has != "" {
+		b, err := strconv.ParseBool(has)
+		if err != nil {
+			return nil, errors.New("traditionalKnowledgeLabel must be true or false")
+		}
+		filter.HasTraditionalKnowledge = &b
+	}
+	if since := r.URL.Query().Get("minted.since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			if t, err = time.Parse(time.DateOnly, since); err != nil {
+				return nil, errors.New("minted.since must be a date (YYYY-MM-DD) or an RFC 3339 time")
+			}
+		}
+		filter.MintedSince = t
+	}
+	if src := r.URL.Query().Get("filter"); src != "" {
+		e, err := expr.Compile(src)
+		if err != nil {
+			return nil, fmt.Errorf("filter: %w", err)
+		}
+		filter.Expression = e
+	}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		filter.Limit, _ = strconv.Atoi(limit)
+	}
+
+	if offset := r.URL.Query().Get("offset"); offset != "" {
+		filter.Offset, _ = strconv.Atoi(offset)
+	}
+	return filter, nil
+}
+
+// parseServicePoint reads ?servicePoint, the owner of the RAiDs a public
+// listing keeps, into filter
+func parseServicePoint(r *http.Request, filter *storage.RAiDFilter) error {
+	sp := r.URL.Query().Get("servicePoint")
+	if sp == "" {
+		return nil
+	}
+	id, err := strconv.ParseInt(sp, 10, 64)
+	if err != nil || id <= 0 {
+		return errors.New("servicePoint must be a service point ID")
+	}
+	filter.ServicePointID = id
+	return nil
+}
+
+// parseCursor reads the cursor a listing continues from into filter
+func parseCursor(r *http.Request, filter *storage.RAiDFilter) error {
+	cursor := r.URL.Query().Get("cursor")
+	if cursor == "" {
+		return nil
+	}
+	if _, _, err := identifier.ParseCursor(cursor); err != nil {
+		return errors.New("cursor must be taken from the next link of a previous page")
 	}
+	filter.Cursor = cursor
+	return nil
+}
+
+// raidPage describes the page of raids selected by filter, linking a full
+// page to the next, which continues from its last RAiD. The reference is
+// relative, so it resolves wherever the server is mounted.
+func raidPage(r *http.Request, raids []*models.RAiD, filter *storage.RAiDFilter) listPage {
+	p := listPage{Limit: filter.Limit, Offset: filter.Offset, Cursor: filter.Cursor}
+	if filter.Limit <= 0 || len(raids) < filter.Limit {
+		return p
+	}
+	q := r.URL.Query()
+	q.Del("offset")
+	q.Set("cursor", identifier.NextCursor(raids))
+	p.Next = "?" + q.Encode()
+	return p
+}
+
+// page cuts the page selected by filter out of raids
+func page(raids []*models.RAiD, filter *storage.RAiDFilter) []*models.RAiD {
+	raids = raids[min(max(filter.Offset, 0), len(raids)):]
+	if filter.Limit > 0 && filter.Limit < len(raids) {
+		raids = raids[:filter.Limit]
+	}
+	return raids
+}
+
+// FindRAiDsByRelatedObject handles GET /raid/find?relatedObject=DOI - lists
+// the RAiDs linking an output, given as a DOI in any common form
+func (h *RAiDHandler) FindRAiDsByRelatedObject(w http.ResponseWriter, r *http.Request) {
+	doi, ok := identifier.NormalizeDOI(r.URL.Query().Get("relatedObject"))
+	if !ok {
+		http.Error(w, "relatedObject must be a DOI, e.g. 10.5061/dryad.abc", http.StatusBadRequest)
+		return
+	}
+	filter := &storage.RAiDFilter{RelatedObjectDOI: doi}
 
 	if limit := r.URL.Query().Get("limit"); limit != "" {
 		filter.Limit, _ = strconv.Atoi(limit)
@@ -62,15 +263,57 @@ func (h *RAiDHandler) FindAllRAiDs(w http.ResponseWriter, r *http.Request) {
 		filter.Offset, _ = strconv.Atoi(offset)
 	}
 
-	// List RAiDs
 	raids, err := h.storage.ListRAiDs(r.Context(), filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(raids)
+	if raids, ok = localize(w, r, raids...); !ok {
+		return
+	}
+	writeList(w, r, raids, listPage{Limit: filter.Limit, Offset: filter.Offset}, raidLinks(r))
+}
+
+// maxBatchSize bounds the RAiDs requested from GET /raid/batch at once
+const maxBatchSize = 100
+
+// GetRAiDBatch handles GET /raid/batch?id=PREFIX/SUFFIX&id=... - retrieves
+// several RAiDs at once, in the order requested. RAiDs not held here are
+// left out.
+func (h *RAiDHandler) GetRAiDBatch(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query()["id"]
+	if len(ids) == 0 || len(ids) > maxBatchSize {
+		http.Error(w, fmt.Sprintf("between 1 and %d id parameters are required", maxBatchSize), http.StatusBadRequest)
+		return
+	}
+	refs := make([]storage.IdentifierRef, len(ids))
+	for i, id := range ids {
+		prefix, suffix, err := identifier.Parse(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("id %q must be a RAiD handle or URL", id), http.StatusBadRequest)
+			return
+		}
+		refs[i] = storage.IdentifierRef{Prefix: prefix, Suffix: suffix}
+	}
+
+	found, err := h.storage.GetRAiDs(r.Context(), refs)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	raids := make([]*models.RAiD, 0, len(found))
+	for _, raid := range found {
+		if raid != nil {
+			raids = append(raids, raid)
+		}
+	}
+
+	raids, ok := localize(w, r, raids...)
+	if !ok {
+		return
+	}
+	writeList(w, r, raids, listPage{}, raidLinks(r))
 }
 
 // FindAllPublicRAiDs handles GET /raid/all-public - lists public RAiDs
@@ -85,28 +328,206 @@ func (h *RAiDHandler) FindAllPublicRAiDs(w http.ResponseWriter, r *http.Request)
 		filter.Offset, _ = strconv.Atoi(offset)
 	}
 
+	if err := parseCursor(r, filter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	raids, err := h.storage.ListPublicRAiDs(r.Context(), filter)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(raids)
+	raids, ok := localize(w, r, raids...)
+	if !ok {
+		return
+	}
+	writeList(w, r, raids, raidPage(r, raids, filter), raidLinks(r))
 }
 
-// FindRAiDByName handles GET /raid/{prefix}/{suffix} - retrieves a specific RAiD
+// FindRAiDByName handles GET /raid/{prefix}/{suffix} - retrieves a specific
+// RAiD. With asOf it retrieves the version current at that time instead.
 func (h *RAiDHandler) FindRAiDByName(w http.ResponseWriter, r *http.Request) {
 	prefix := chi.URLParam(r, "prefix")
 	suffix := chi.URLParam(r, "suffix")
+	if asOf := r.URL.Query().Get("asOf"); asOf != "" {
+		h.findRAiDAsOf(w, r, prefix, suffix, asOf)
+		return
+	}
 
 	raid, err := h.storage.GetRAiD(r.Context(), prefix, suffix)
 	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+	if raid.Deprecation != nil {
+		writeDeprecated(w, raid)
+		return
+	}
+
+	localized, ok := localize(w, r, raid)
+	if !ok {
+		return
+	}
+	setOriginalLinks(w, r, prefix, suffix)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(localized[0])
+}
+
+// findRAiDAsOf writes the version of a RAiD current at asOf, an RFC 3339
+// time or a date meaning its start in UTC: the last version written by
+// then. Content-Location names the version, which is returned as is, even
+// if it was later deprecated.
+func (h *RAiDHandler) findRAiDAsOf(w http.ResponseWriter, r *http.Request, prefix, suffix, asOf string) {
+	at, err := time.Parse(time.RFC3339, asOf)
+	if err != nil {
+		if at, err = time.Parse(time.DateOnly, asOf); err != nil {
+			http.Error(w, "asOf must be a date (YYYY-MM-DD) or an RFC 3339 time", http.StatusBadRequest)
+			return
+		}
+	}
+
+	changes, err := h.storage.GetRAiDChanges(r.Context(), prefix, suffix, &storage.HistoryPage{Summary: true})
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+	version := 0
+	for _, change := range changes {
+		if !change.Timestamp.After(at) {
+			version = change.Version
+		}
+	}
+	if version == 0 {
+		http.Error(w, "RAiD was not minted until after "+asOf, http.StatusNotFound)
+		return
+	}
+
+	raid, err := h.storage.GetRAiDVersion(r.Context(), prefix, suffix, version)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD version not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	localized, ok := localize(w, r, raid)
+	if !ok {
+		return
+	}
+	setMementoHeaders(w, r, prefix, suffix, raid)
+	w.Header().Set("Content-Location", rootRef(r, "raid", prefix, suffix, strconv.Itoa(version)))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(localized[0])
+}
+
+// DeprecateRequest is the body of POST /raid/{prefix}/{suffix}/deprecate
+type DeprecateRequest struct {
+	// SupersededBy identifies the RAiD replacing the deprecated one
+	SupersededBy string `json:"supersededBy,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+}
+
+// DeprecatedResponse is returned when a deprecated RAiD is retrieved
+type DeprecatedResponse struct {
+	Message      string       `json:"message"`
+	SupersededBy string       `json:"supersededBy,omitempty"`
+	Reason       string       `json:"reason,omitempty"`
+	RAiD         *models.RAiD `json:"raid"`
+}
+
+// DeprecateRAiD handles POST /raid/{prefix}/{suffix}/deprecate - marks a
+// RAiD as deprecated, optionally superseded by another RAiD, as a new
+// version
+func (h *RAiDHandler) DeprecateRAiD(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
+	var req DeprecateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	current, err := h.storage.GetRAiD(r.Context(), prefix, suffix)
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
 		if err == storage.ErrNotFound {
 			http.Error(w, "RAiD not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
+		return
+	}
+	if current.Deprecation != nil {
+		http.Error(w, "RAiD is already deprecated", http.StatusConflict)
+		return
+	}
+
+	if req.SupersededBy != "" {
+		successorPrefix, successorSuffix, err := identifier.Parse(req.SupersededBy)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if successorPrefix == prefix && successorSuffix == suffix {
+			http.Error(w, "A RAiD cannot supersede itself", http.StatusBadRequest)
+			return
+		}
+		successor, err := h.storage.GetRAiD(r.Context(), successorPrefix, successorSuffix)
+		if err != nil {
+			if writeIdentifierError(w, err) {
+				return
+			}
+			if err == storage.ErrNotFound {
+				http.Error(w, "Superseding RAiD not found", http.StatusUnprocessableEntity)
+				return
+			}
+			writeStorageError(w, r, err)
+			return
+		}
+		if successor.Deprecation != nil {
+			http.Error(w, "Superseding RAiD is itself deprecated", http.StatusUnprocessableEntity)
+			return
+		}
+		req.SupersededBy = successor.Identifier.ID
+	}
+
+	current.Deprecation = &models.Deprecation{
+		SupersededBy: req.SupersededBy,
+		Reason:       req.Reason,
+		Date:         time.Now().UTC().Format("2006-01-02"),
+	}
+	raid, err := h.storage.UpdateRAiD(r.Context(), prefix, suffix, current)
+	if err != nil {
+		if writeVocabularyError(w, err) || writeValidationError(w, r, err) {
+			return
+		}
+		var veto *hooks.VetoError
+		if errors.As(err, &veto) {
+			http.Error(w, veto.Reason, http.StatusUnprocessableEntity)
+			return
+		}
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -114,24 +535,218 @@ func (h *RAiDHandler) FindRAiDByName(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(raid)
 }
 
-// UpdateRAiD handles PUT /raid/{prefix}/{suffix} - updates a RAiD
-func (h *RAiDHandler) UpdateRAiD(w http.ResponseWriter, r *http.Request) {
+// writeDeprecated answers a retrieval of a deprecated RAiD: 301 to the
+// superseding RAiD, with a successor-version link, or 410 if there is none.
+// The body carries the deprecated record.
+func writeDeprecated(w http.ResponseWriter, raid *models.RAiD) {
+	dep := raid.Deprecation
+	resp := DeprecatedResponse{Message: "RAiD has been deprecated", SupersededBy: dep.SupersededBy, Reason: dep.Reason, RAiD: raid}
+	status := http.StatusGone
+	if prefix, suffix, err := identifier.Parse(dep.SupersededBy); err == nil {
+		// Relative to .../{prefix}/{suffix}, so it works at any mount point
+		w.Header().Set("Location", "../"+url.PathEscape(prefix)+"/"+url.PathEscape(suffix))
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, dep.SupersededBy))
+		resp.Message = "RAiD has been superseded"
+		status = http.StatusMovedPermanently
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// SplitRequest is the body of POST /raid/{prefix}/{suffix}/split. The
+// indexes select the blocks of the original RAiD copied into the new one.
+type SplitRequest struct {
+	Title        []int `json:"title"`
+	Contributor  []int `json:"contributor,omitempty"`
+	Organisation []int `json:"organisation,omitempty"`
+	// ID optionally names the new RAiD, as when minting
+	ID string `json:"id,omitempty"`
+}
+
+// SplitResponse is returned by a split
+type SplitResponse struct {
+	Original *models.RAiD `json:"original"`
+	Derived  *models.RAiD `json:"derived"`
+}
+
+// SplitRAiD handles POST /raid/{prefix}/{suffix}/split - mints a new RAiD
+// from selected titles, contributors and organisations of an existing one.
+// The new RAiD keeps the original's dates, access and owner and is linked
+// to it with IsDerivedFrom; the original gets a new version linking back
+// with HasDerivation.
+func (h *RAiDHandler) SplitRAiD(w http.ResponseWriter, r *http.Request) {
 	prefix := chi.URLParam(r, "prefix")
 	suffix := chi.URLParam(r, "suffix")
 
-	var req models.RAiD
+	var req SplitRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.Title) == 0 {
+		http.Error(w, "At least one title must be selected", http.StatusBadRequest)
 		return
 	}
 
-	raid, err := h.storage.UpdateRAiD(r.Context(), prefix, suffix, &req)
+	original, err := h.storage.GetRAiD(r.Context(), prefix, suffix)
 	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
 		if err == storage.ErrNotFound {
 			http.Error(w, "RAiD not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
+		return
+	}
+	if original.Deprecation != nil {
+		http.Error(w, "RAiD is deprecated", http.StatusConflict)
+		return
+	}
+
+	derived := &models.RAiD{
+		Identifier: &models.Identifier{
+			ID:                 req.ID,
+			SchemaURI:          original.Identifier.SchemaURI,
+			RegistrationAgency: original.Identifier.RegistrationAgency,
+			Owner:              original.Identifier.Owner,
+			License:            original.Identifier.License,
+		},
+		Date:   original.Date,
+		Access: original.Access,
+		RelatedRAiD: []models.RelatedRAiD{{
+			ID:   original.Identifier.ID,
+			Type: &models.IDSchema{ID: models.RelatedRAiDTypeIsDerivedFrom, SchemaURI: models.RelatedRAiDTypeSchema},
+		}},
+	}
+	if derived.Title, err = pick(original.Title, req.Title, "title"); err == nil {
+		if derived.Contributor, err = pick(original.Contributor, req.Contributor, "contributor"); err == nil {
+			derived.Organisation, err = pick(original.Organisation, req.Organisation, "organisation")
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	derived, err = h.storage.CreateRAiD(r.Context(), derived)
+	if err != nil {
+		if writeIdentifierError(w, err) || writeVocabularyError(w, err) || writeValidationError(w, r, err) {
+			return
+		}
+		if err == storage.ErrAlreadyExists {
+			http.Error(w, "RAiD already exists", http.StatusConflict)
+			return
+		}
+		if err == storage.ErrAccessDenied {
+			http.Error(w, "Service point not available", http.StatusForbidden)
+			return
+		}
+		var veto *hooks.VetoError
+		if errors.As(err, &veto) {
+			http.Error(w, veto.Reason, http.StatusUnprocessableEntity)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Reciprocal linking may already have added the link back
+	if original, err = h.storage.GetRAiD(r.Context(), prefix, suffix); err == nil && !linksTo(original, derived) {
+		original.RelatedRAiD = append(original.RelatedRAiD, models.RelatedRAiD{
+			ID:   derived.Identifier.ID,
+			Type: &models.IDSchema{ID: models.RelatedRAiDTypeHasDerivation, SchemaURI: models.RelatedRAiDTypeSchema},
+		})
+		original, err = h.storage.UpdateRAiD(r.Context(), prefix, suffix, original)
+	}
+	if err != nil {
+		// The derived RAiD exists, so report it along with the failure
+		var veto *hooks.VetoError
+		if errors.As(err, &veto) {
+			http.Error(w, fmt.Sprintf("Minted %s but could not link the original: %s", derived.Identifier.ID, veto.Reason), http.StatusUnprocessableEntity)
+			return
+		}
+		log.Printf("Minted %s but could not link the original: %v", derived.Identifier.ID, err)
+		http.Error(w, fmt.Sprintf("Minted %s but could not link the original", derived.Identifier.ID), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SplitResponse{Original: original, Derived: derived})
+}
+
+// linksTo reports whether raid has a relatedRaid link to other
+func linksTo(raid, other *models.RAiD) bool {
+	for _, rel := range raid.RelatedRAiD {
+		if rel.ID == other.Identifier.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// pick returns the blocks at indexes, in the order given, ignoring repeats
+func pick[T any](blocks []T, indexes []int, name string) ([]T, error) {
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+	picked := make([]T, 0, len(indexes))
+	seen := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		if i < 0 || i >= len(blocks) {
+			return nil, fmt.Errorf("%s index %d is out of range", name, i)
+		}
+		if !seen[i] {
+			seen[i] = true
+			picked = append(picked, blocks[i])
+		}
+	}
+	return picked, nil
+}
+
+// UpdateRAiD handles PUT /raid/{prefix}/{suffix} - updates a RAiD. With a
+// future effectiveAt the update is scheduled instead.
+func (h *RAiDHandler) UpdateRAiD(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
+	req, err := decodeRAiD(w, r, h.limits)
+	if err != nil {
+		if !writeValidationError(w, r, err) {
+			writeDecodeError(w, err)
+		}
+		return
+	}
+	if at, ok := h.effectiveAt(w, r); !ok {
+		return
+	} else if !at.IsZero() {
+		h.schedule(w, r, prefix, suffix, req, at)
+		return
+	}
+
+	raid, err := h.storage.UpdateRAiD(r.Context(), prefix, suffix, req)
+	if err != nil {
+		if writeIdentifierError(w, err) || writeVocabularyError(w, err) || writeValidationError(w, r, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		if err == storage.ErrAccessDenied {
+			http.Error(w, "Service point not available", http.StatusForbidden)
+			return
+		}
+		var veto *hooks.VetoError
+		if errors.As(err, &veto) {
+			http.Error(w, veto.Reason, http.StatusUnprocessableEntity)
+			return
+		}
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -139,6 +754,27 @@ func (h *RAiDHandler) UpdateRAiD(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(raid)
 }
 
+// DeleteRAiD handles DELETE /raid/{prefix}/{suffix} - deletes a RAiD. The
+// version history is kept by the storage backend.
+func (h *RAiDHandler) DeleteRAiD(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
+	if err := h.storage.DeleteRAiD(r.Context(), prefix, suffix); err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // PatchRAiD handles PATCH /raid/{prefix}/{suffix} - partially updates a RAiD
 func (h *RAiDHandler) PatchRAiD(w http.ResponseWriter, r *http.Request) {
 	// TODO: Implement JSON Patch (RFC 6902) support
@@ -163,33 +799,158 @@ func (h *RAiDHandler) FindRAiDByNameAndVersion(w http.ResponseWriter, r *http.Re
 
 	raid, err := h.storage.GetRAiDVersion(r.Context(), prefix, suffix, version)
 	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
 		if err == storage.ErrNotFound {
 			http.Error(w, "RAiD version not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
+	localized, ok := localize(w, r, raid)
+	if !ok {
+		return
+	}
+	setMementoHeaders(w, r, prefix, suffix, raid)
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(raid)
+	json.NewEncoder(w).Encode(localized[0])
+}
+
+// maxHistoryPageSize bounds the limit of a page of history
+const maxHistoryPageSize = 1000
+
+// historyPage parses the limit, cursor and summary parameters of a history
+// request, returning nil if none is given
+func historyPage(r *http.Request) (*storage.HistoryPage, error) {
+	q := r.URL.Query()
+	if !q.Has("limit") && !q.Has("cursor") && !q.Has("summary") {
+		return nil, nil
+	}
+	page := &storage.HistoryPage{}
+	if limit := q.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > maxHistoryPageSize {
+			return nil, fmt.Errorf("limit must be between 1 and %d", maxHistoryPageSize)
+		}
+		page.Limit = n
+	}
+	if cursor := q.Get("cursor"); cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 {
+			return nil, errors.New("cursor must be taken from the next link of a previous page")
+		}
+		page.After = n
+	}
+	if summary := q.Get("summary"); summary != "" {
+		b, err := strconv.ParseBool(summary)
+		if err != nil {
+			return nil, errors.New("summary must be true or false")
+		}
+		page.Summary = b
+	}
+	return page, nil
 }
 
-// RAiDHistory handles GET /raid/{prefix}/{suffix}/history - retrieves version history
+// RAiDHistory handles GET /raid/{prefix}/{suffix}/history - lists the changes
+// made by each version as base64 encoded JSON Patch (RFC 6902) documents.
+// With limit, a full page links to the next, continuing after its last
+// version; with summary=true, the patches are left out.
 func (h *RAiDHandler) RAiDHistory(w http.ResponseWriter, r *http.Request) {
 	prefix := chi.URLParam(r, "prefix")
 	suffix := chi.URLParam(r, "suffix")
 
+	page, err := historyPage(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stored, err := h.storage.GetRAiDChanges(r.Context(), prefix, suffix, page)
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	changes, err := raidChanges(prefix+"/"+suffix, stored, page != nil && page.Summary)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	var p listPage
+	if page != nil {
+		p.Limit = page.Limit
+		p.Cursor = r.URL.Query().Get("cursor")
+		if page.Limit > 0 && len(stored) == page.Limit {
+			q := r.URL.Query()
+			q.Set("cursor", strconv.Itoa(stored[len(stored)-1].Version))
+			p.Next = "?" + q.Encode()
+		}
+	}
+	writeList(w, r, changes, p, func(change models.RAiDChange) map[string]string {
+		return map[string]string{"version": rootRef(r, "raid", prefix, suffix, strconv.Itoa(change.Version))}
+	})
+}
+
+// AccessHistory handles GET /raid/{prefix}/{suffix}/access-history - the
+// access type changes of a RAiD, derived from its versions, including
+// embargoes lapsing
+func (h *RAiDHandler) AccessHistory(w http.ResponseWriter, r *http.Request) {
+	prefix := chi.URLParam(r, "prefix")
+	suffix := chi.URLParam(r, "suffix")
+
 	history, err := h.storage.GetRAiDHistory(r.Context(), prefix, suffix)
 	if err != nil {
+		if writeIdentifierError(w, err) {
+			return
+		}
 		if err == storage.ErrNotFound {
 			http.Error(w, "RAiD not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(history)
+	json.NewEncoder(w).Encode(access.Timeline(history, time.Now()))
+}
+
+// raidChanges encodes the stored changes of a RAiD for the API
+func raidChanges(handle string, stored []storage.VersionChange, summary bool) ([]models.RAiDChange, error) {
+	changes := make([]models.RAiDChange, 0, len(stored))
+	for _, c := range stored {
+		change := models.RAiDChange{
+			Handle:    handle,
+			Version:   c.Version,
+			Timestamp: c.Timestamp,
+			Actor:     c.Actor,
+		}
+		if !summary {
+			patch, err := json.Marshal(c.Patch)
+			if err != nil {
+				return nil, err
+			}
+			change.Diff = base64.StdEncoding.EncodeToString(patch)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+func raidVersion(raid *models.RAiD) int {
+	if raid.Identifier == nil {
+		return 0
+	}
+	return raid.Identifier.Version
 }
@@ -3,10 +3,14 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
@@ -17,7 +21,7 @@ import (
 
 func TestNewRAiDHandler(t *testing.T) {
 	repo := testutil.NewMockRepository()
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 
 	if handler == nil {
 		t.Fatal("Expected non-nil handler")
@@ -51,7 +55,7 @@ func TestMintRAiD_Success(t *testing.T) {
 	rr := httptest.NewRecorder()
 
 	// Execute
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.MintRAiD(rr, req)
 
 	// Assert
@@ -82,7 +86,7 @@ func TestMintRAiD_InvalidJSON(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.MintRAiD(rr, req)
 
 	if rr.Code != http.StatusBadRequest {
@@ -108,12 +112,105 @@ func TestMintRAiD_RepositoryError(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	rr := httptest.NewRecorder()
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.MintRAiD(rr, req)
 
 	if rr.Code != http.StatusInternalServerError {
 		t.Errorf("Expected status 500, got %d", rr.Code)
 	}
+	if strings.Contains(rr.Body.String(), "database error") {
+		t.Errorf("Expected the backend error to be kept from the client, got %q", rr.Body.String())
+	}
+}
+
+func TestMintRAiD_DocumentLimits(t *testing.T) {
+	testRAiD := testutil.NewTestRAiD("10.12345", "67890")
+	testRAiD.Extensions = models.Extensions{"x-local": json.RawMessage(`"kept"`)}
+	for i := 0; i < 3; i++ {
+		testRAiD.RelatedObject = append(testRAiD.RelatedObject, models.RelatedObject{ID: fmt.Sprintf("https://doi.org/10.1000/%d", i)})
+	}
+	bodyBytes, _ := json.Marshal(testRAiD)
+
+	tests := []struct {
+		name   string
+		limits DocumentLimits
+		status int
+	}{
+		{"within limits", DocumentLimits{MaxBytes: int64(len(bodyBytes)), MaxRelatedObjects: 3}, http.StatusCreated},
+		{"too many related objects", DocumentLimits{MaxRelatedObjects: 2}, http.StatusRequestEntityTooLarge},
+		{"document too large", DocumentLimits{MaxBytes: int64(len(bodyBytes)) - 1}, http.StatusRequestEntityTooLarge},
+		{"strict", DocumentLimits{Strict: true}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := testutil.NewMockRepository()
+			var created *models.RAiD
+			repo.CreateRAiDFunc = func(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+				created = raid
+				return raid, nil
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/raid", bytes.NewBuffer(bodyBytes))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			NewRAiDHandler(repo, tt.limits).MintRAiD(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.status != http.StatusCreated {
+				if repo.CreateRAiDCalls != 0 {
+					t.Errorf("Expected 0 CreateRAiD calls, got %d", repo.CreateRAiDCalls)
+				}
+				return
+			}
+			if len(created.RelatedObject) != 3 || created.RelatedObject[2].ID != "https://doi.org/10.1000/2" {
+				t.Errorf("Related objects not decoded: %+v", created.RelatedObject)
+			}
+			if len(created.Title) == 0 || string(created.Extensions["x-local"]) != `"kept"` {
+				t.Errorf("Document fields not decoded: %+v", created)
+			}
+		})
+	}
+}
+
+func TestMintRAiD_Strict(t *testing.T) {
+	body := `{"title":[{"text":"Typos","lang":"en"}],"contributers":[],"x-local":1,
+		"relatedObject":[{"id":"https://doi.org/10.1000/1"},{"id":"https://doi.org/10.1000/2","typ":{}}]}`
+
+	repo := testutil.NewMockRepository()
+	req := httptest.NewRequest(http.MethodPost, "/raid", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	NewRAiDHandler(repo, DocumentLimits{Strict: true}).MintRAiD(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	var fields []string
+	for _, f := range resp.Failures {
+		fields = append(fields, f.FieldID)
+		if f.ErrorType != "unknownProperty" {
+			t.Errorf("Expected failures of type unknownProperty, got %s", f.ErrorType)
+		}
+	}
+	if want := []string{"contributers", "title[0].lang", "relatedObject[1].typ"}; !slices.Equal(fields, want) {
+		t.Errorf("Expected failures of %v, got %v", want, fields)
+	}
+	if repo.CreateRAiDCalls != 0 {
+		t.Errorf("Expected 0 CreateRAiD calls, got %d", repo.CreateRAiDCalls)
+	}
+
+	// Without strict mode they are kept as extensions
+	rr = httptest.NewRecorder()
+	NewRAiDHandler(repo, DocumentLimits{}).MintRAiD(rr, httptest.NewRequest(http.MethodPost, "/raid", strings.NewReader(body)))
+	if rr.Code != http.StatusCreated {
+		t.Errorf("Expected status 201 without strict mode, got %d", rr.Code)
+	}
 }
 
 func TestFindAllRAiDs_Success(t *testing.T) {
@@ -133,7 +230,7 @@ func TestFindAllRAiDs_Success(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/raid?limit=10&offset=0", nil)
 	rr := httptest.NewRecorder()
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.FindAllRAiDs(rr, req)
 
 	if rr.Code != http.StatusOK {
@@ -154,6 +251,72 @@ func TestFindAllRAiDs_Success(t *testing.T) {
 	}
 }
 
+func TestFindAllRAiDs_Envelope(t *testing.T) {
+	repo := testutil.NewMockRepository()
+	repo.ListRAiDsFunc = func(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+		return []*models.RAiD{
+			testutil.NewTestRAiD("10.12345", "00001"),
+			testutil.NewTestRAiD("10.12345", "00002"),
+		}, nil
+	}
+	handler := NewRAiDHandler(repo, DocumentLimits{})
+
+	tests := []struct {
+		name      string
+		accept    string
+		byDefault bool
+		envelope  bool
+	}{
+		{"bare by default", "", false, false},
+		{"asked for", `application/json; profile="` + models.EnvelopeProfile + `"`, false, true},
+		{"configured default", "application/json", true, true},
+		{"bare asked for", `application/json; profile="` + models.BareProfile + `"`, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/raid/?limit=2", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			rr := httptest.NewRecorder()
+			h := http.Handler(http.HandlerFunc(handler.FindAllRAiDs))
+			if tt.byDefault {
+				h = DefaultEnvelope(h)
+			}
+			h.ServeHTTP(rr, req)
+
+			if !tt.envelope {
+				var raids []*models.RAiD
+				if err := json.NewDecoder(rr.Body).Decode(&raids); err != nil || len(raids) != 2 {
+					t.Fatalf("Expected a bare array of 2 RAiDs, got %v", err)
+				}
+				return
+			}
+
+			var env models.Envelope
+			if err := json.NewDecoder(rr.Body).Decode(&env); err != nil {
+				t.Fatal(err)
+			}
+			if env.Meta.Count != 2 || env.Meta.Limit != 2 {
+				t.Errorf("Unexpected meta %+v", env.Meta)
+			}
+			if env.Links["self"] != "./?limit=2" || !strings.HasPrefix(env.Links["next"], "?cursor=") {
+				t.Errorf("Unexpected links %v", env.Links)
+			}
+			var items []struct {
+				Identifier *models.Identifier `json:"identifier"`
+				Links      map[string]string  `json:"_links"`
+			}
+			if err := json.Unmarshal(env.Data, &items); err != nil || len(items) != 2 || items[0].Identifier == nil {
+				t.Fatalf("Expected 2 RAiDs, got %v", err)
+			}
+			if got := items[0].Links["history"]; got != "../raid/10.12345/00001/history" {
+				t.Errorf("Unexpected history link %q", got)
+			}
+		})
+	}
+}
+
 func TestFindAllRAiDs_WithFilters(t *testing.T) {
 	repo := testutil.NewMockRepository()
 
@@ -171,7 +334,7 @@ func TestFindAllRAiDs_WithFilters(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/raid?limit=20&offset=10", nil)
 	rr := httptest.NewRecorder()
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.FindAllRAiDs(rr, req)
 
 	if rr.Code != http.StatusOK {
@@ -189,7 +352,7 @@ func TestFindAllRAiDs_RepositoryError(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/raid", nil)
 	rr := httptest.NewRecorder()
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.FindAllRAiDs(rr, req)
 
 	if rr.Code != http.StatusInternalServerError {
@@ -219,7 +382,7 @@ func TestFindRAiDByName_Success(t *testing.T) {
 	rctx.URLParams.Add("suffix", suffix)
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.FindRAiDByName(rr, req)
 
 	if rr.Code != http.StatusOK {
@@ -251,7 +414,7 @@ func TestFindRAiDByName_NotFound(t *testing.T) {
 	rctx.URLParams.Add("suffix", "99999")
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.FindRAiDByName(rr, req)
 
 	if rr.Code != http.StatusNotFound {
@@ -286,7 +449,7 @@ func TestUpdateRAiD_Success(t *testing.T) {
 	rctx.URLParams.Add("suffix", suffix)
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.UpdateRAiD(rr, req)
 
 	if rr.Code != http.StatusOK {
@@ -322,7 +485,7 @@ func TestUpdateRAiD_NotFound(t *testing.T) {
 	rctx.URLParams.Add("suffix", "99999")
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.UpdateRAiD(rr, req)
 
 	if rr.Code != http.StatusNotFound {
@@ -335,6 +498,91 @@ func TestUpdateRAiD_NotFound(t *testing.T) {
 	}
 }
 
+func TestUpdateRAiD_StorageErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"stale version", &storage.ConflictError{Current: 3, Given: 2}, http.StatusConflict},
+		{"backend unavailable", &storage.UnavailableError{Err: errors.New("connection refused")}, http.StatusServiceUnavailable},
+		{"validation", &storage.ValidationError{Failures: []models.ValidationFailure{{FieldID: "title", Message: "required"}}}, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := testutil.NewMockRepository()
+			repo.UpdateRAiDFunc = func(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+				return nil, fmt.Errorf("update: %w", tt.err)
+			}
+
+			bodyBytes, _ := json.Marshal(testutil.NewTestRAiD("10.12345", "67890"))
+			req := httptest.NewRequest(http.MethodPut, "/raid/10.12345/67890", bytes.NewBuffer(bodyBytes))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("prefix", "10.12345")
+			rctx.URLParams.Add("suffix", "67890")
+			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			rr := httptest.NewRecorder()
+
+			NewRAiDHandler(repo, DocumentLimits{}).UpdateRAiD(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			switch rr.Code {
+			case http.StatusConflict:
+				var resp models.ErrorResponse
+				if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(resp.Detail, "current version is 3") {
+					t.Errorf("Expected the detail to name the current version, got %q", resp.Detail)
+				}
+			case http.StatusServiceUnavailable:
+				if rr.Header().Get("Retry-After") == "" {
+					t.Error("Expected a Retry-After header")
+				}
+				if strings.Contains(rr.Body.String(), "connection refused") {
+					t.Errorf("Expected the backend error to be kept from the client, got %q", rr.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateRAiD_LocalizedErrors(t *testing.T) {
+	repo := testutil.NewMockRepository()
+	repo.UpdateRAiDFunc = func(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+		return nil, &storage.ValidationError{Failures: []models.ValidationFailure{{FieldID: "title", ErrorType: "notSet", Message: "a primary title is required"}}}
+	}
+
+	bodyBytes, _ := json.Marshal(testutil.NewTestRAiD("10.12345", "67890"))
+	req := httptest.NewRequest(http.MethodPut, "/raid/10.12345/67890", bytes.NewBuffer(bodyBytes))
+	req.Header.Set("Accept-Language", "de-DE, en;q=0.5")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("prefix", "10.12345")
+	rctx.URLParams.Add("suffix", "67890")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+
+	NewRAiDHandler(repo, DocumentLimits{}).UpdateRAiD(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if got := rr.Header().Get("Content-Language"); got != "de" {
+		t.Errorf("Expected Content-Language de, got %q", got)
+	}
+	var resp models.ErrorResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Title != "Die Validierung ist fehlgeschlagen." || len(resp.Failures) != 1 ||
+		resp.Failures[0].Message != "ein Haupttitel ist erforderlich" || resp.Failures[0].FieldID != "title" {
+		t.Errorf("Expected a German error with untranslated field names, got %+v", resp)
+	}
+}
+
 func TestRAiDHistory_Success(t *testing.T) {
 	repo := testutil.NewMockRepository()
 	prefix, suffix := "10.12345", "67890"
@@ -365,28 +613,43 @@ func TestRAiDHistory_Success(t *testing.T) {
 	rctx.URLParams.Add("suffix", suffix)
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.RAiDHistory(rr, req)
 
 	if rr.Code != http.StatusOK {
 		t.Errorf("Expected status 200, got %d", rr.Code)
 	}
 
-	var response []*models.RAiD
+	var response []models.RAiDChange
 	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
 	if len(response) != 3 {
-		t.Errorf("Expected 3 versions, got %d", len(response))
+		t.Fatalf("Expected 3 changes, got %d", len(response))
 	}
 
 	// Verify versions are in sequence
-	for i, raid := range response {
+	for i, change := range response {
 		expectedVersion := i + 1
-		if raid.Identifier.Version != expectedVersion {
-			t.Errorf("Expected version %d, got %d", expectedVersion, raid.Identifier.Version)
+		if change.Version != expectedVersion {
+			t.Errorf("Expected version %d, got %d", expectedVersion, change.Version)
 		}
+		if change.Handle != prefix+"/"+suffix {
+			t.Errorf("Expected handle %s/%s, got %s", prefix, suffix, change.Handle)
+		}
+	}
+
+	// The first change creates the document, later ones only bump the version
+	patch, err := base64.StdEncoding.DecodeString(response[1].Diff)
+	if err != nil {
+		t.Fatalf("Failed to decode diff: %v", err)
+	}
+	if string(patch) != `[{"op":"replace","path":"/identifier/version","value":2}]` {
+		t.Errorf("Unexpected diff for version 2: %s", patch)
+	}
+	if patch, _ := base64.StdEncoding.DecodeString(response[0].Diff); !bytes.Contains(patch, []byte(`"op":"add","path":"/identifier"`)) {
+		t.Errorf("Expected first diff to add the identifier, got %s", patch)
 	}
 
 	if repo.GetRAiDHistoryCalls != 1 {
@@ -394,6 +657,61 @@ func TestRAiDHistory_Success(t *testing.T) {
 	}
 }
 
+func TestRAiDHistory_Page(t *testing.T) {
+	repo := testutil.NewMockRepository()
+	prefix, suffix := "10.12345", "67890"
+	history := make([]*models.RAiD, 3)
+	for i := range history {
+		history[i] = testutil.NewTestRAiD(prefix, suffix)
+		history[i].Identifier.Version = i + 1
+	}
+	repo.GetRAiDHistoryFunc = func(ctx context.Context, p, s string) ([]*models.RAiD, error) {
+		return history, nil
+	}
+	handler := NewRAiDHandler(repo, DocumentLimits{})
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/raid/%s/%s/history?%s", prefix, suffix, query), nil)
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("prefix", prefix)
+		rctx.URLParams.Add("suffix", suffix)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		handler.RAiDHistory(rr, req)
+		return rr
+	}
+
+	rr := get("limit=2&summary=true")
+	var response []models.RAiDChange
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 2 || response[0].Version != 1 || response[1].Version != 2 || response[0].Diff != "" {
+		t.Errorf("Expected versions 1 and 2 without diffs, got %+v", response)
+	}
+	if link := rr.Header().Get("Link"); link != `<?cursor=2&limit=2&summary=true>; rel="next"` {
+		t.Errorf("Unexpected Link header %q", link)
+	}
+
+	rr = get("limit=2&cursor=2")
+	response = nil
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response) != 1 || response[0].Version != 3 || response[0].Diff == "" {
+		t.Errorf("Expected version 3 with its diff, got %+v", response)
+	}
+	if link := rr.Header().Get("Link"); link != "" {
+		t.Errorf("Expected no Link header on the last page, got %q", link)
+	}
+
+	for _, query := range []string{"limit=0", "limit=x", "cursor=-1", "summary=maybe"} {
+		if rr := get(query); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", query, rr.Code)
+		}
+	}
+}
+
 func TestRAiDHistory_NotFound(t *testing.T) {
 	repo := testutil.NewMockRepository()
 
@@ -409,7 +727,7 @@ func TestRAiDHistory_NotFound(t *testing.T) {
 	rctx.URLParams.Add("suffix", "99999")
 	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
 
-	handler := NewRAiDHandler(repo)
+	handler := NewRAiDHandler(repo, DocumentLimits{})
 	handler.RAiDHistory(rr, req)
 
 	if rr.Code != http.StatusNotFound {
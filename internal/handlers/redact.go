@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/contributor"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// redactionRequest is the body of POST /admin/redactions
+type redactionRequest struct {
+	// Contributor identifies the contributor whose data is removed
+	Contributor contributor.Subject `json:"contributor"`
+	// RAiDs lists the RAiDs to redact, as URLs or PREFIX/SUFFIX
+	RAiDs []string `json:"raids"`
+	// Reason is recorded with the redactions and is required
+	Reason string `json:"reason"`
+}
+
+// redactionResponse reports the outcome of POST /admin/redactions
+type redactionResponse struct {
+	ID         string              `json:"id"`
+	Redactions []*models.Redaction `json:"redactions"`
+	// Unchanged lists the RAiDs holding no data of the contributor
+	Unchanged []string `json:"unchanged"`
+}
+
+// Redact handles POST /admin/redactions - removes a contributor's email
+// address, UUID and other personal data from every version of the given
+// RAiDs, leaving a tombstone and keeping their ORCID iD, roles and
+// positions. Each RAiD changed is recorded under one redaction ID, with a
+// hash of the contributor's identifiers rather than the identifiers.
+func (h *AdminHandler) Redact(w http.ResponseWriter, r *http.Request) {
+	redactor, ok := h.storage.(storage.Redactor)
+	if !ok {
+		http.Error(w, "Storage backend does not support redacting RAiDs", http.StatusNotImplemented)
+		return
+	}
+
+	var req redactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		http.Error(w, "A reason for the redaction is required", http.StatusBadRequest)
+		return
+	}
+	if req.Contributor.Empty() {
+		http.Error(w, "An id, email or uuid identifying the contributor is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.RAiDs) == 0 {
+		http.Error(w, "At least one RAiD is required", http.StatusBadRequest)
+		return
+	}
+	type ref struct{ prefix, suffix string }
+	refs := make([]ref, len(req.RAiDs))
+	for i, id := range req.RAiDs {
+		prefix, suffix, err := identifier.Parse(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid RAiD %q: %v", id, err), http.StatusBadRequest)
+			return
+		}
+		refs[i] = ref{prefix, suffix}
+	}
+
+	actor, _ := middleware.GetUserID(r.Context())
+	if actor == "" {
+		actor, _ = middleware.GetUserEmail(r.Context())
+	}
+	now := time.Now().UTC()
+	resp := redactionResponse{
+		ID:         identifier.NewULID(now),
+		Redactions: make([]*models.Redaction, 0, len(refs)),
+		Unchanged:  make([]string, 0),
+	}
+	for _, ref := range refs {
+		redaction := &models.Redaction{
+			ID:       resp.ID,
+			Prefix:   ref.prefix,
+			Suffix:   ref.suffix,
+			Subject:  req.Contributor.Hash(),
+			Fields:   make([]string, 0),
+			Actor:    actor,
+			Reason:   strings.TrimSpace(req.Reason),
+			Redacted: now,
+		}
+		n, err := redactor.RedactRAiD(r.Context(), ref.prefix, ref.suffix, contributor.Redact(req.Contributor, redaction), redaction)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				http.Error(w, fmt.Sprintf("RAiD %s/%s not found", ref.prefix, ref.suffix), http.StatusNotFound)
+				return
+			}
+			writeStorageError(w, r, err)
+			return
+		}
+		if n == 0 {
+			resp.Unchanged = append(resp.Unchanged, ref.prefix+"/"+ref.suffix)
+			continue
+		}
+		h.invalidate(r, ref.prefix, ref.suffix)
+		log.Printf("RAiD %s/%s: %d versions redacted by %q (%s): %s", ref.prefix, ref.suffix, n, actor, resp.ID, redaction.Reason)
+		resp.Redactions = append(resp.Redactions, redaction)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListRedactions handles GET /admin/redactions - lists the recorded
+// redactions, oldest first
+func (h *AdminHandler) ListRedactions(w http.ResponseWriter, r *http.Request) {
+	redactor, ok := h.storage.(storage.Redactor)
+	if !ok {
+		http.Error(w, "Storage backend does not support redacting RAiDs", http.StatusNotImplemented)
+		return
+	}
+
+	redactions, err := redactor.ListRedactions(r.Context())
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(redactions)
+}
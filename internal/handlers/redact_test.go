@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/contributor"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func TestAdminRedact(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	repo, err := file.New(&file.Config{DataDir: dataDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	contributors := []models.Contributor{
+		{ID: "https://orcid.org/0000-0002-1825-0097", Email: "josiah@example.org", UUID: "3f2b8c1e-2d4a-4c5b-9e6f-7a8b9c0d1e2f", Role: []models.IDSchema{{ID: "supervision"}}},
+		{ID: "https://orcid.org/0000-0001-5109-3700", Email: "other@example.org"},
+	}
+	for _, suffix := range []string{"a", "b"} {
+		raid := &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix}}
+		if suffix == "a" {
+			raid.Contributor = contributors
+		}
+		if _, err := repo.CreateRAiD(ctx, raid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, title := range []string{"Second", "Third"} {
+		raid, err := repo.GetRAiD(ctx, "10.99999", "a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raid.Title = []models.Title{{Text: title}}
+		if _, err := repo.UpdateRAiD(ctx, "10.99999", "a", raid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The diff of an archived version holds contributor data too
+	if n, err := repo.CompactRAiD(ctx, "10.99999", "a", storage.CompactionPolicy{KeepVersions: 1}); err != nil || n != 2 {
+		t.Fatalf("expected 2 versions archived, got %d: %v", n, err)
+	}
+
+	handler := NewAdminHandler(repo, storage.StorageTypeFile, nil, nil, nil, nil, "", nil)
+	router := chi.NewRouter()
+	router.Post("/admin/redactions", handler.Redact)
+	router.Get("/admin/redactions", handler.ListRedactions)
+
+	for _, tc := range []struct {
+		body string
+		want int
+	}{
+		{`{"contributor":{"id":"0000-0002-1825-0097"},"raids":["10.99999/a"]}`, http.StatusBadRequest},
+		{`{"contributor":{},"raids":["10.99999/a"],"reason":"Erasure request"}`, http.StatusBadRequest},
+		{`{"contributor":{"id":"0000-0002-1825-0097"},"raids":["not a raid"],"reason":"Erasure request"}`, http.StatusBadRequest},
+		{`{"contributor":{"id":"0000-0002-1825-0097"},"raids":["10.99999/c"],"reason":"Erasure request"}`, http.StatusNotFound},
+	} {
+		if rr := serveAs(router, "operator-1", http.MethodPost, "/admin/redactions", tc.body); rr.Code != tc.want {
+			t.Errorf("POST %s: expected %d, got %d: %s", tc.body, tc.want, rr.Code, rr.Body.String())
+		}
+	}
+
+	body := `{"contributor":{"id":"0000-0002-1825-0097"},"raids":["10.99999/a","https://raid.org/10.99999/b"],"reason":"Erasure request"}`
+	rr := serveAs(router, "operator-1", http.MethodPost, "/admin/redactions", body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp redactionResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Redactions) != 1 || len(resp.Unchanged) != 1 || resp.Unchanged[0] != "10.99999/b" {
+		t.Fatalf("expected a and not b to be redacted, got %+v", resp)
+	}
+	redaction := resp.Redactions[0]
+	if redaction.Versions != 3 || strings.Join(redaction.Fields, ",") != "email,uuid" || redaction.Actor != "operator-1" {
+		t.Errorf("unexpected redaction %+v", redaction)
+	}
+	if redaction.Subject != (contributor.Subject{ID: "https://orcid.org/0000-0002-1825-0097"}).Hash() {
+		t.Errorf("expected the subject hash of the ORCID iD in any form, got %s", redaction.Subject)
+	}
+
+	history, err := repo.GetRAiDHistory(ctx, "10.99999", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(history))
+	}
+	for _, v := range history {
+		c := v.Contributor[0]
+		if c.Email != "" || c.UUID != "" || c.Extensions[contributor.RedactedExtension] == nil || len(c.Role) != 1 {
+			t.Errorf("version %d: expected the contributor redacted with roles kept, got %+v", v.Identifier.Version, c)
+		}
+		if v.Contributor[1].Email != "other@example.org" {
+			t.Errorf("version %d: expected other contributors kept, got %+v", v.Identifier.Version, v.Contributor[1])
+		}
+	}
+	changes, err := repo.GetRAiDChanges(ctx, "10.99999", "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := json.Marshal(changes); len(changes) != 3 || strings.Contains(string(data), "josiah@example.org") {
+		t.Errorf("expected the changes recorded again without the email address, got %s", data)
+	}
+
+	filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if data, _ := os.ReadFile(path); err == nil && !d.IsDir() && strings.Contains(string(data), "josiah@example.org") {
+			t.Errorf("expected no stored file to hold the email address, found it in %s", path)
+		}
+		return nil
+	})
+
+	// Redacting again changes nothing
+	rr = serveAs(router, "operator-1", http.MethodPost, "/admin/redactions", body)
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Redactions) != 0 || len(resp.Unchanged) != 2 {
+		t.Errorf("expected nothing left to redact, got %+v", resp)
+	}
+
+	rr = serveAs(router, "operator-1", http.MethodGet, "/admin/redactions", "")
+	var redactions []models.Redaction
+	if err := json.NewDecoder(rr.Body).Decode(&redactions); err != nil {
+		t.Fatal(err)
+	}
+	if len(redactions) != 1 || redactions[0].Prefix != "10.99999" || redactions[0].Suffix != "a" || redactions[0].Reason != "Erasure request" {
+		t.Errorf("expected the redaction on record, got %+v", redactions)
+	}
+}
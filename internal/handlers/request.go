@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/leifj/go-raid/internal/checkdigit"
+	"github.com/leifj/go-raid/internal/hooks"
+	"github.com/leifj/go-raid/internal/i18n"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/validation"
+	"github.com/leifj/go-raid/internal/vocabulary"
+)
+
+// writeDecodeError reports a request body that could not be decoded. Bodies
+// rejected by a size or document limit get 413 and bodies the client was
+// too slow to send get 408; anything else is a malformed request.
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, fmt.Sprintf("Request body exceeds the limit of %d bytes", maxBytesErr.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		http.Error(w, limitErr.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		http.Error(w, "Timed out reading request body", http.StatusRequestTimeout)
+		return
+	}
+
+	http.Error(w, "Invalid request body", http.StatusBadRequest)
+}
+
+// writeIdentifierError reports a RAiD identifier rejected for a bad check
+// character and returns true, or returns false for any other error
+func writeIdentifierError(w http.ResponseWriter, err error) bool {
+	var idErr *checkdigit.IdentifierError
+	if !errors.As(err, &idErr) {
+		return false
+	}
+	http.Error(w, idErr.Error(), http.StatusBadRequest)
+	return true
+}
+
+// writeVocabularyError reports RAiD metadata using terms outside their
+// controlled vocabularies and returns true, or returns false for any other
+// error
+func writeVocabularyError(w http.ResponseWriter, err error) bool {
+	var vocabErr *vocabulary.Error
+	if !errors.As(err, &vocabErr) {
+		return false
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+	return true
+}
+
+// writeValidationError reports a RAiD breaking the metadata schema rules as
+// an ErrorResponse listing each failure, translated to the language the
+// request prefers, and returns true, or returns false for any other error
+func writeValidationError(w http.ResponseWriter, r *http.Request, err error) bool {
+	var valErr *validation.Error
+	if !errors.As(err, &valErr) {
+		return false
+	}
+	p := i18n.For(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(models.ErrorResponse{
+		Type:     "https://raid.org/errors#ValidationException",
+		Title:    p.Sprintf("There were validation failures."),
+		Status:   http.StatusBadRequest,
+		Detail:   p.Sprintf("Request had validation failures."),
+		Instance: r.URL.Path,
+		Failures: p.Failures(valErr.Failures),
+	})
+	return true
+}
+
+// unavailableRetryAfter is the Retry-After, in seconds, of responses to
+// requests failed by an unavailable backend
+const unavailableRetryAfter = 5
+
+// writeStorageError reports err from the repository with the status of its
+// class, in the language the request prefers. Errors of no known class get
+// a bare 500; their message, which may come from the backend, is logged
+// rather than sent to the client.
+func writeStorageError(w http.ResponseWriter, r *http.Request, err error) {
+	if writeIdentifierError(w, err) || writeVocabularyError(w, err) || writeValidationError(w, r, err) {
+		return
+	}
+	var veto *hooks.VetoError
+	var conflict *storage.ConflictError
+	var quota *storage.QuotaError
+	p := i18n.For(w, r)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		p.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, storage.ErrAlreadyExists):
+		p.Error(w, "Already exists", http.StatusConflict)
+	case errors.As(err, &conflict):
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.ErrorResponse{
+			Type:     "https://raid.org/errors#ConflictException",
+			Title:    p.Sprintf("The RAiD has changed."),
+			Status:   http.StatusConflict,
+			Detail:   p.Sprintf("Update was made to version %d but the current version is %d.", conflict.Given, conflict.Current),
+			Instance: r.URL.Path,
+		})
+	case errors.Is(err, storage.ErrAccessDenied):
+		p.Error(w, "Service point not available", http.StatusForbidden)
+	case errors.As(err, &quota):
+		http.Error(w, p.Sprintf("Service point has reached its quota of %d RAiDs", quota.Max), http.StatusForbidden)
+	case errors.As(err, &veto):
+		http.Error(w, veto.Reason, http.StatusUnprocessableEntity)
+	case errors.Is(err, storage.ErrIdentifiersExhausted):
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		p.Error(w, "No free identifier could be generated, try again", http.StatusServiceUnavailable)
+	case errors.Is(err, storage.ErrBackendUnavailable):
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		w.Header().Set("Retry-After", strconv.Itoa(unavailableRetryAfter))
+		p.Error(w, "Storage temporarily unavailable", http.StatusServiceUnavailable)
+	default:
+		log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+		p.Error(w, "Internal server error", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/reservation"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// ReservationHandler handles identifier reservations, which hold an
+// identifier for a service point until a mint supplies it
+type ReservationHandler struct {
+	store   storage.ReservationStore
+	raids   *RAiDHandler
+	ttl     time.Duration
+	baseURL string
+}
+
+// NewReservationHandler creates a new reservation handler, which
+// generates identifiers with raids, writes them under baseURL and holds
+// them for ttl
+func NewReservationHandler(store storage.ReservationStore, raids *RAiDHandler, ttl time.Duration, baseURL string) *ReservationHandler {
+	return &ReservationHandler{
+		store:   store,
+		raids:   raids,
+		ttl:     ttl,
+		baseURL: baseURL,
+	}
+}
+
+// ReserveRequest is the optional body of POST /raid/reserve
+type ReserveRequest struct {
+	// ServicePoint is the service point the identifier is reserved for;
+	// the caller's if zero
+	ServicePoint int64 `json:"servicePoint,omitempty"`
+}
+
+// ReserveIdentifier handles POST /raid/reserve - generates an identifier
+// for the service point in the body, or else the caller's, and holds it
+// without minting a RAiD. projectType selects the prefix as it does for
+// a mint, which must supply the identifier as identifier.id before the
+// reservation expires.
+func (h *ReservationHandler) ReserveIdentifier(w http.ResponseWriter, r *http.Request) {
+	var req ReserveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeDecodeError(w, err)
+		return
+	}
+	servicePoint, _ := middleware.GetServicePointID(r.Context())
+	if req.ServicePoint != 0 {
+		servicePoint = req.ServicePoint
+	}
+	if servicePoint == 0 || !isMember(r, servicePoint) {
+		http.Error(w, "Only members of a service point can reserve its identifiers", http.StatusForbidden)
+		return
+	}
+
+	if _, err := h.raids.storage.GetServicePoint(r.Context(), servicePoint); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "Service point not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+	if projectType := r.URL.Query().Get("projectType"); projectType != "" {
+		ctx = storage.WithProjectType(ctx, projectType)
+	}
+	format := func(prefix, suffix string) string {
+		return identifier.Format(h.baseURL, prefix, suffix)
+	}
+	if a := agency.FromContext(ctx); a != nil {
+		format = a.FormatIdentifier
+	}
+	reserved, err := reservation.Reserve(ctx, h.raids.storage, h.store, servicePoint, h.ttl, format)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reserved)
+}
+
+// GetReservation handles GET /raid/reserve/{prefix}/{suffix} - retrieves
+// a live reservation
+func (h *ReservationHandler) GetReservation(w http.ResponseWriter, r *http.Request) {
+	reserved, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reserved)
+}
+
+// ReleaseReservation handles DELETE /raid/reserve/{prefix}/{suffix} -
+// gives a reserved identifier up before it expires
+func (h *ReservationHandler) ReleaseReservation(w http.ResponseWriter, r *http.Request) {
+	reserved, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteReservation(r.Context(), reserved.Prefix, reserved.Suffix); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MintReservation handles POST /raid/reserve/{prefix}/{suffix}/mint -
+// mints the RAiD in the body under the reserved identifier, for the
+// service point holding it, as POST /raid/ does. The reservation is
+// released once the RAiD is minted.
+func (h *ReservationHandler) MintReservation(w http.ResponseWriter, r *http.Request) {
+	reserved, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	req, err := decodeRAiD(w, r, h.raids.limits)
+	if err != nil {
+		if !writeValidationError(w, r, err) {
+			writeDecodeError(w, err)
+		}
+		return
+	}
+	if req.Identifier == nil {
+		req.Identifier = &models.Identifier{}
+	}
+	if req.Identifier.ID != "" && req.Identifier.ID != reserved.ID {
+		http.Error(w, "identifier.id must be the reserved identifier or left out", http.StatusBadRequest)
+		return
+	}
+	if req.Identifier.Owner == nil {
+		req.Identifier.Owner = &models.Owner{}
+	}
+	if req.Identifier.Owner.ServicePoint != 0 && req.Identifier.Owner.ServicePoint != reserved.ServicePoint {
+		http.Error(w, "The identifier is reserved for another service point", http.StatusConflict)
+		return
+	}
+	req.Identifier.ID = reserved.ID
+	req.Identifier.Owner.ServicePoint = reserved.ServicePoint
+
+	raid, ok := h.raids.mint(w, r, req)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(raid)
+}
+
+// open returns the live reservation a request names, if the caller
+// belongs to its service point or is an operator
+func (h *ReservationHandler) open(w http.ResponseWriter, r *http.Request) (*models.Reservation, bool) {
+	reserved, err := h.store.GetReservation(r.Context(), chi.URLParam(r, "prefix"), chi.URLParam(r, "suffix"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Reservation not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	if !isMember(r, reserved.ServicePoint) {
+		// Reservations of other service points are not disclosed
+		http.Error(w, "Reservation not found", http.StatusNotFound)
+		return nil, false
+	}
+	return reserved, true
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/validation"
+)
+
+// WithSchedules lets mints and updates be scheduled to take effect later,
+// holding them in store until the publication scheduler applies them, and
+// returns h
+func (h *RAiDHandler) WithSchedules(store storage.ScheduleStore) *RAiDHandler {
+	h.schedules = store
+	return h
+}
+
+// effectiveAt reads the effectiveAt query parameter, an RFC 3339 time,
+// returning the zero time if it is absent or not in the future
+func (h *RAiDHandler) effectiveAt(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	value := r.URL.Query().Get("effectiveAt")
+	if value == "" {
+		return time.Time{}, true
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		http.Error(w, "effectiveAt must be an RFC 3339 time", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	if !at.After(time.Now()) {
+		return time.Time{}, true
+	}
+	if h.schedules == nil {
+		http.Error(w, "This server cannot schedule changes", http.StatusBadRequest)
+		return time.Time{}, false
+	}
+	return at.UTC(), true
+}
+
+// schedule holds req until at, as a mint if prefix is empty and otherwise
+// as an update of the RAiD prefix/suffix. The schema is checked now; the
+// other checks of a write are made when the change is applied.
+func (h *RAiDHandler) schedule(w http.ResponseWriter, r *http.Request, prefix, suffix string, req *models.RAiD, at time.Time) {
+	if err := validation.Validate(req); err != nil {
+		writeValidationError(w, r, err)
+		return
+	}
+
+	servicePoint, _ := middleware.GetServicePointID(r.Context())
+	owned := req
+	if prefix != "" {
+		current, err := h.storage.GetRAiD(r.Context(), prefix, suffix)
+		if err != nil {
+			if writeIdentifierError(w, err) {
+				return
+			}
+			if err == storage.ErrNotFound {
+				http.Error(w, "RAiD not found", http.StatusNotFound)
+				return
+			}
+			writeStorageError(w, r, err)
+			return
+		}
+		owned = current
+	}
+	if owned.Identifier != nil && owned.Identifier.Owner != nil && owned.Identifier.Owner.ServicePoint != 0 {
+		servicePoint = owned.Identifier.Owner.ServicePoint
+	}
+
+	now := time.Now().UTC()
+	change := &models.ScheduledChange{
+		ID:           identifier.NewULID(now),
+		ServicePoint: servicePoint,
+		Prefix:       prefix,
+		Suffix:       suffix,
+		ProjectType:  r.URL.Query().Get("projectType"),
+		Actor:        storage.Actor(r.Context()),
+		RAiD:         req,
+		EffectiveAt:  at,
+		Status:       models.SchedulePending,
+		Created:      now,
+	}
+	if a := agency.FromContext(r.Context()); a != nil {
+		change.Agency = a.ID
+	}
+	if err := h.schedules.SaveScheduledChange(r.Context(), change); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", rootRef(r, "scheduled", change.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(change)
+}
+
+// ScheduleHandler handles the changes held until they take effect
+type ScheduleHandler struct {
+	store storage.ScheduleStore
+}
+
+// NewScheduleHandler creates a new scheduled change handler
+func NewScheduleHandler(store storage.ScheduleStore) *ScheduleHandler {
+	return &ScheduleHandler{store: store}
+}
+
+// ListScheduledChanges handles GET /scheduled - lists the scheduled
+// changes of the caller's service point
+func (h *ScheduleHandler) ListScheduledChanges(w http.ResponseWriter, r *http.Request) {
+	servicePoint, ok := middleware.GetServicePointID(r.Context())
+	if !ok || servicePoint == 0 {
+		http.Error(w, "The token names no service point", http.StatusForbidden)
+		return
+	}
+	changes, err := h.store.ListScheduledChanges(r.Context(), servicePoint)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeList(w, r, changes, listPage{}, func(change *models.ScheduledChange) map[string]string {
+		return map[string]string{"self": rootRef(r, "scheduled", change.ID)}
+	})
+}
+
+// GetScheduledChange handles GET /scheduled/{id} - retrieves a scheduled
+// change
+func (h *ScheduleHandler) GetScheduledChange(w http.ResponseWriter, r *http.Request) {
+	change, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(change)
+}
+
+// CancelScheduledChange handles DELETE /scheduled/{id} - withdraws a change
+// that has not been applied, or removes one that failed
+func (h *ScheduleHandler) CancelScheduledChange(w http.ResponseWriter, r *http.Request) {
+	change, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteScheduledChange(r.Context(), change.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// open returns the change a request names, if the caller belongs to its
+// service point or is an operator
+func (h *ScheduleHandler) open(w http.ResponseWriter, r *http.Request) (*models.ScheduledChange, bool) {
+	change, err := h.store.GetScheduledChange(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Scheduled change not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return nil, false
+	}
+	if !isMember(r, change.ServicePoint) {
+		// Changes of other service points are not disclosed
+		http.Error(w, "Scheduled change not found", http.StatusNotFound)
+		return nil, false
+	}
+	return change, true
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Scopes of a saved search, given by the scope query parameter
+const (
+	ScopeUser         = "user"
+	ScopeServicePoint = "service-point"
+)
+
+// searchName is the form of saved search names, which appear in paths
+var searchName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// SearchHandler handles saved RAiD searches, kept for the authenticated
+// user or their service point and run as RAiD listings
+type SearchHandler struct {
+	store storage.SearchStore
+	raids *RAiDHandler
+}
+
+// NewSearchHandler creates a new saved search handler, which runs
+// searches with raids
+func NewSearchHandler(store storage.SearchStore, raids *RAiDHandler) *SearchHandler {
+	return &SearchHandler{
+		store: store,
+		raids: raids,
+	}
+}
+
+// SearchRequest is the body of PUT /searches/{name}
+type SearchRequest struct {
+	// Query holds the query parameters of the listing, as taken by
+	// GET /raid/
+	Query map[string]string `json:"query"`
+	// Public publishes the public RAiDs the search finds as a feed
+	Public bool `json:"public,omitempty"`
+}
+
+// ListSearches handles GET /searches - lists the searches of the caller
+// and of their service point
+func (h *SearchHandler) ListSearches(w http.ResponseWriter, r *http.Request) {
+	searches := make([]*models.SavedSearch, 0)
+	for _, scope := range []string{ScopeUser, ScopeServicePoint} {
+		owner, ok := searchOwner(r, scope)
+		if !ok {
+			continue
+		}
+		owned, err := h.store.ListSearches(r.Context(), owner)
+		if err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+		searches = append(searches, owned...)
+	}
+	writeList(w, r, searches, listPage{}, searchLinks(r))
+}
+
+// searchLinks returns the links of the searches listed in response to r
+func searchLinks(r *http.Request) func(*models.SavedSearch) map[string]string {
+	return func(search *models.SavedSearch) map[string]string {
+		scope := ""
+		if strings.HasPrefix(search.Owner, storage.SearchOwnerServicePoint) {
+			scope = "?scope=" + ScopeServicePoint
+		}
+		links := map[string]string{
+			"self":    rootRef(r, "searches", search.Name) + scope,
+			"results": rootRef(r, "searches", search.Name, "results") + scope,
+		}
+		if search.Feed != "" {
+			links["feed"] = rootRef(r, "feeds", search.Feed)
+		}
+		return links
+	}
+}
+
+// SaveSearch handles PUT /searches/{name} - saves a search for the caller,
+// or with scope=service-point for their service point, which takes an
+// admin of it. Making the search public gives it a feed; making it private
+// again withdraws the feed.
+func (h *SearchHandler) SaveSearch(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if !searchName.MatchString(name) {
+		http.Error(w, "Search names are up to 64 letters, digits, '.', '_' and '-', starting with a letter or digit", http.StatusBadRequest)
+		return
+	}
+	scope := r.URL.Query().Get("scope")
+	owner, ok := h.writableOwner(w, r, scope)
+	if !ok {
+		return
+	}
+
+	var req SearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if err := checkQuery(r, req.Query); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	search := &models.SavedSearch{Owner: owner, Name: name, Query: req.Query, Created: now, Updated: now}
+	status := http.StatusCreated
+	current, err := h.store.GetSearch(r.Context(), owner, name)
+	switch {
+	case err == nil:
+		search.Created, search.Feed = current.Created, current.Feed
+		status = http.StatusOK
+	case !errors.Is(err, storage.ErrNotFound):
+		writeStorageError(w, r, err)
+		return
+	}
+	switch {
+	case !req.Public:
+		search.Feed = ""
+	case search.Feed == "":
+		search.Feed = newFeed()
+	}
+	if err := h.store.SaveSearch(r.Context(), search); err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(search)
+}
+
+// GetSearch handles GET /searches/{name} - retrieves a saved search
+func (h *SearchHandler) GetSearch(w http.ResponseWriter, r *http.Request) {
+	search, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(search)
+}
+
+// DeleteSearch handles DELETE /searches/{name} - removes a saved search of
+// the caller, or with scope=service-point of their service point
+func (h *SearchHandler) DeleteSearch(w http.ResponseWriter, r *http.Request) {
+	owner, ok := h.writableOwner(w, r, r.URL.Query().Get("scope"))
+	if !ok {
+		return
+	}
+	if err := h.store.DeleteSearch(r.Context(), owner, chi.URLParam(r, "name")); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			http.Error(w, "Search not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SearchResults handles GET /searches/{name}/results - runs a saved search
+// as GET /raid/ does
+func (h *SearchHandler) SearchResults(w http.ResponseWriter, r *http.Request) {
+	search, ok := h.find(w, r)
+	if !ok {
+		return
+	}
+	h.raids.findRAiDs(w, withQuery(r, search.Query), h.raids.storage.ListRAiDs)
+}
+
+// Feed handles GET /feeds/{feed} - runs the public search published under
+// feed, finding only public RAiDs. No authentication is needed.
+func (h *SearchHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	search, err := h.store.GetSearchByFeed(r.Context(), chi.URLParam(r, "feed"))
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, "Feed not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	h.raids.findRAiDs(w, withQuery(r, search.Query), h.raids.storage.ListPublicRAiDs)
+}
+
+// find returns the search a request names. Without a scope the caller's
+// own search is preferred to their service point's.
+func (h *SearchHandler) find(w http.ResponseWriter, r *http.Request) (*models.SavedSearch, bool) {
+	scopes := []string{ScopeUser, ScopeServicePoint}
+	if scope := r.URL.Query().Get("scope"); scope != "" {
+		if scope != ScopeUser && scope != ScopeServicePoint {
+			http.Error(w, "scope must be user or service-point", http.StatusBadRequest)
+			return nil, false
+		}
+		scopes = []string{scope}
+	}
+	for _, scope := range scopes {
+		owner, ok := searchOwner(r, scope)
+		if !ok {
+			continue
+		}
+		search, err := h.store.GetSearch(r.Context(), owner, chi.URLParam(r, "name"))
+		if errors.Is(err, storage.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			writeStorageError(w, r, err)
+			return nil, false
+		}
+		return search, true
+	}
+	http.Error(w, "Search not found", http.StatusNotFound)
+	return nil, false
+}
+
+// writableOwner returns the owner of the searches in scope, which the
+// caller must be allowed to change
+func (h *SearchHandler) writableOwner(w http.ResponseWriter, r *http.Request, scope string) (string, bool) {
+	switch scope {
+	case "", ScopeUser:
+		owner, ok := searchOwner(r, ScopeUser)
+		if !ok {
+			http.Error(w, "The token names no user", http.StatusForbidden)
+		}
+		return owner, ok
+	case ScopeServicePoint:
+		owner, ok := searchOwner(r, ScopeServicePoint)
+		if !ok {
+			http.Error(w, "The token names no service point", http.StatusForbidden)
+			return "", false
+		}
+		id, _ := middleware.GetServicePointID(r.Context())
+		if !middleware.IsServicePointAdmin(r.Context(), id) {
+			http.Error(w, "Only an admin of the service point can change its searches", http.StatusForbidden)
+			return "", false
+		}
+		return owner, true
+	}
+	http.Error(w, "scope must be user or service-point", http.StatusBadRequest)
+	return "", false
+}
+
+// searchOwner returns the owner of the caller's searches in scope, if the
+// token names one
+func searchOwner(r *http.Request, scope string) (string, bool) {
+	if scope == ScopeServicePoint {
+		id, ok := middleware.GetServicePointID(r.Context())
+		if !ok || id == 0 {
+			return "", false
+		}
+		return storage.SearchOwnerServicePoint + strconv.FormatInt(id, 10), true
+	}
+	id, ok := middleware.GetUserID(r.Context())
+	if !ok || id == "" {
+		return "", false
+	}
+	return storage.SearchOwnerUser + id, true
+}
+
+// checkQuery rejects a saved query GET /raid/ would reject
+func checkQuery(r *http.Request, query map[string]string) error {
+	q := r.Clone(r.Context())
+	q.URL.RawQuery = ""
+	q = withQuery(q, query)
+	filter, err := parseRAiDFilter(q)
+	if err == nil {
+		err = parseCursor(q, filter)
+	}
+	if err == nil {
+		_, err = parseCompletenessFilter(q)
+	}
+	return err
+}
+
+// withQuery returns r running query, overridden by the query parameters of
+// r itself, so that pages and refinements can be asked for
+func withQuery(r *http.Request, query map[string]string) *http.Request {
+	q := url.Values{}
+	for k, v := range query {
+		q.Set(k, v)
+	}
+	for k, v := range r.URL.Query() {
+		if k != "scope" {
+			q[k] = v
+		}
+	}
+	r = r.Clone(r.Context())
+	r.URL.RawQuery = q.Encode()
+	return r
+}
+
+// newFeed returns a new feed name, which cannot be guessed
+func newFeed() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -2,17 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/attachment"
+	"github.com/leifj/go-raid/internal/completeness"
+	"github.com/leifj/go-raid/internal/deactivation"
+	"github.com/leifj/go-raid/internal/middleware"
 	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/notify"
 	"github.com/leifj/go-raid/internal/storage"
 )
 
 // ServicePointHandler handles service point-related HTTP requests
 type ServicePointHandler struct {
 	storage storage.Repository
+	tags    storage.TagStore
 }
 
 // NewServicePointHandler creates a new service point handler
@@ -22,21 +33,47 @@ func NewServicePointHandler(repo storage.Repository) *ServicePointHandler {
 	}
 }
 
+// WithTags lets the RAiD listings of service points be filtered by the
+// tags kept in store, and returns h
+func (h *ServicePointHandler) WithTags(store storage.TagStore) *ServicePointHandler {
+	h.tags = store
+	return h
+}
+
 // CreateServicePoint handles POST /service-point/
 func (h *ServicePointHandler) CreateServicePoint(w http.ResponseWriter, r *http.Request) {
 	var req models.ServicePoint
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, err)
 		return
 	}
 
+	if err := storage.ValidatePrefixes(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MaxRAiDs < 0 {
+		http.Error(w, "maxRaids must not be negative", http.StatusBadRequest)
+		return
+	}
+	if err := notify.ValidateSettings(req.Notifications); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := attachment.ValidatePolicy(req.Attachments); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Service points are deactivated through DELETE only
+	req.Deactivation = nil
+
 	sp, err := h.storage.CreateServicePoint(r.Context(), &req)
 	if err != nil {
 		if err == storage.ErrAlreadyExists {
 			http.Error(w, "Service point already exists", http.StatusConflict)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -49,12 +86,17 @@ func (h *ServicePointHandler) CreateServicePoint(w http.ResponseWriter, r *http.
 func (h *ServicePointHandler) FindAllServicePoints(w http.ResponseWriter, r *http.Request) {
 	servicePoints, err := h.storage.ListServicePoints(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(servicePoints)
+	writeList(w, r, servicePoints, listPage{}, func(sp *models.ServicePoint) map[string]string {
+		id := strconv.FormatInt(sp.ID, 10)
+		return map[string]string{
+			"self":  rootRef(r, "service-point", id),
+			"raids": rootRef(r, "service-point", id, "raids"),
+		}
+	})
 }
 
 // FindServicePointByID handles GET /service-point/{id}
@@ -72,7 +114,7 @@ func (h *ServicePointHandler) FindServicePointByID(w http.ResponseWriter, r *htt
 			http.Error(w, "Service point not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
@@ -91,9 +133,39 @@ func (h *ServicePointHandler) UpdateServicePoint(w http.ResponseWriter, r *http.
 
 	var req models.ServicePoint
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		writeDecodeError(w, err)
+		return
+	}
+
+	if err := storage.ValidatePrefixes(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.MaxRAiDs < 0 {
+		http.Error(w, "maxRaids must not be negative", http.StatusBadRequest)
+		return
+	}
+	if err := notify.ValidateSettings(req.Notifications); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := attachment.ValidatePolicy(req.Attachments); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The deactivation record is kept as is; updates can neither make nor
+	// undo it
+	current, err := h.storage.GetServicePoint(r.Context(), id)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "Service point not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
 		return
 	}
+	req.Deactivation = current.Deactivation
 
 	sp, err := h.storage.UpdateServicePoint(r.Context(), id, &req)
 	if err != nil {
@@ -101,10 +173,196 @@ func (h *ServicePointHandler) UpdateServicePoint(w http.ResponseWriter, r *http.
 			http.Error(w, "Service point not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeStorageError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(sp)
 }
+
+// DeleteServicePoint handles DELETE /service-point/{id}, which soft deletes
+// the service point. If it owns RAiDs, the raids=read-only or
+// transferTo={id} query parameter must say what becomes of them; reason
+// is recorded with the deactivation. With force=true the service point is
+// removed outright instead, after transferring its RAiDs if transferTo is
+// given.
+func (h *ServicePointHandler) DeleteServicePoint(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid service point ID", http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	plan := deactivation.Plan{Reason: q.Get("reason")}
+	switch q.Get("raids") {
+	case "":
+	case deactivation.RAiDsReadOnly:
+		plan.ReadOnly = true
+	default:
+		http.Error(w, "raids must be read-only", http.StatusBadRequest)
+		return
+	}
+	if v := q.Get("transferTo"); v != "" {
+		if plan.TransferTo, err = strconv.ParseInt(v, 10, 64); err != nil {
+			http.Error(w, "Invalid transferTo service point ID", http.StatusBadRequest)
+			return
+		}
+		if plan.ReadOnly {
+			http.Error(w, "raids=read-only and transferTo are exclusive", http.StatusBadRequest)
+			return
+		}
+	}
+	force := false
+	if v := q.Get("force"); v != "" {
+		if force, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "force must be true or false", http.StatusBadRequest)
+			return
+		}
+		if force && plan.ReadOnly {
+			http.Error(w, "raids=read-only does not apply to a forced delete", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var sp *models.ServicePoint
+	if force {
+		err = deactivation.Delete(r.Context(), h.storage, id, plan.TransferTo, true)
+	} else {
+		sp, err = deactivation.Deactivate(r.Context(), h.storage, id, plan)
+	}
+	if err != nil {
+		var active *deactivation.ActiveRAiDsError
+		switch {
+		case err == storage.ErrNotFound:
+			http.Error(w, "Service point not found", http.StatusNotFound)
+		case err == deactivation.ErrDeactivated:
+			http.Error(w, "Service point is already deactivated", http.StatusConflict)
+		case err == deactivation.ErrTransferTarget:
+			http.Error(w, "RAiDs cannot be transferred to that service point", http.StatusUnprocessableEntity)
+		case errors.As(err, &active):
+			http.Error(w, fmt.Sprintf("Service point owns %d RAiDs; pass raids=read-only to keep them read-only, transferTo to move them or force=true to delete it regardless", active.Count), http.StatusConflict)
+		default:
+			writeStorageError(w, r, err)
+		}
+		return
+	}
+	if force {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sp)
+}
+
+// raidSortKeys compare RAiDs by the keys accepted by ?sort
+var raidSortKeys = map[string]func(a, b *models.RAiD) int{
+	"created": func(a, b *models.RAiD) int { return metadataTime(a, false).Compare(metadataTime(b, false)) },
+	"updated": func(a, b *models.RAiD) int { return metadataTime(a, true).Compare(metadataTime(b, true)) },
+	"title": func(a, b *models.RAiD) int {
+		return strings.Compare(strings.ToLower(primaryTitle(a)), strings.ToLower(primaryTitle(b)))
+	},
+	"completeness": func(a, b *models.RAiD) int {
+		return completeness.Score(a).Score - completeness.Score(b).Score
+	},
+}
+
+// metadataTime returns when raid was created or last updated
+func metadataTime(raid *models.RAiD, updated bool) time.Time {
+	if raid.Metadata == nil {
+		return time.Time{}
+	}
+	if updated {
+		return raid.Metadata.Updated
+	}
+	return raid.Metadata.Created
+}
+
+// FindServicePointRAiDs handles GET /service-point/{id}/raids - the RAiDs
+// owned by a service point, with the filters, limit and offset of GET /raid/
+// and ?sort=created, updated, title or completeness, descending with a
+// leading "-". Members of the service point can keep the RAiDs it gave
+// every ?tag.
+// Anonymous callers see open RAiDs only; admins of the service point and
+// operators also see embargoed and deleted ones, the latter marked by
+// metadata.deleted.
+func (h *ServicePointHandler) FindServicePointRAiDs(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid service point ID", http.StatusBadRequest)
+		return
+	}
+	filter, err := parseRAiDFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	complete, err := parseCompletenessFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sortKey := r.URL.Query().Get("sort")
+	compare, ok := raidSortKeys[strings.TrimPrefix(sortKey, "-")]
+	if sortKey != "" && !ok {
+		http.Error(w, "sort must be created, updated, title or completeness, optionally prefixed with -", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.storage.GetServicePoint(r.Context(), id); err != nil {
+		if err == storage.ErrNotFound {
+			http.Error(w, "Service point not found", http.StatusNotFound)
+			return
+		}
+		writeStorageError(w, r, err)
+		return
+	}
+
+	// Tags are only shown to members of the service point
+	var tagged func(*models.RAiD) bool
+	if r.URL.Query().Has("tag") {
+		if h.tags == nil {
+			http.Error(w, "The storage backend does not keep tags", http.StatusBadRequest)
+			return
+		}
+		if !isMember(r, id) {
+			http.Error(w, "Only members of the service point can filter by its tags", http.StatusForbidden)
+			return
+		}
+		if tagged, err = taggedFilter(r, h.tags, id); err != nil {
+			writeStorageError(w, r, err)
+			return
+		}
+	}
+
+	// Access, tags and sort order are applied here, so the page is cut
+	// afterwards
+	admin := middleware.IsServicePointAdmin(r.Context(), id)
+	all := *filter
+	all.ServicePointID, all.IncludeDeleted = id, admin
+	all.Limit, all.Offset = 0, 0
+	raids, err := h.storage.ListRAiDs(r.Context(), &all)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	raids = slices.DeleteFunc(raids, func(raid *models.RAiD) bool {
+		if !admin && (raid.Access == nil || raid.Access.Type == nil || raid.Access.Type.ID != storage.AccessTypeOpen) {
+			return true
+		}
+		return (complete != nil && !complete(raid)) || (tagged != nil && !tagged(raid))
+	})
+	if compare != nil {
+		slices.SortStableFunc(raids, func(a, b *models.RAiD) int {
+			if strings.HasPrefix(sortKey, "-") {
+				return compare(b, a)
+			}
+			return compare(a, b)
+		})
+	}
+	raids = page(raids, filter)
+
+	writeList(w, r, raids, listPage{Limit: filter.Limit, Offset: filter.Offset}, raidLinks(r))
+}
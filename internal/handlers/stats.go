@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// defaultStatsDays is the minting window reported when ?days is not set
+	defaultStatsDays = 30
+	// maxStatsDays bounds the minting window
+	maxStatsDays = 366
+)
+
+// StatsResponse is the body of GET /admin/stats
+type StatsResponse struct {
+	StorageType   storage.StorageType `json:"storageType"`
+	GeneratedAt   time.Time           `json:"generatedAt"`
+	RAiDs         RAiDStats           `json:"raids"`
+	Versions      int64               `json:"versions"`
+	ServicePoints []ServicePointStats `json:"servicePoints"`
+	Minting       MintingStats        `json:"minting"`
+}
+
+// RAiDStats counts RAiDs by state
+type RAiDStats struct {
+	Total     int64 `json:"total"`
+	Public    int64 `json:"public"`
+	Embargoed int64 `json:"embargoed"`
+	Deleted   int64 `json:"deleted"`
+}
+
+// ServicePointStats counts the RAiDs owned by a service point. ID 0 collects
+// RAiDs without an owning service point.
+type ServicePointStats struct {
+	ID    int64  `json:"id"`
+	Name  string `json:"name,omitempty"`
+	RAiDs int64  `json:"raids"`
+}
+
+// MintingStats reports mint rates over the trailing window of Days days
+type MintingStats struct {
+	Days          int        `json:"days"`
+	Total         int64      `json:"total"`
+	PerDayAverage float64    `json:"perDayAverage"`
+	Series        []DayCount `json:"series"`
+}
+
+// DayCount is the number of RAiDs minted on a UTC date
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// Stats handles GET /admin/stats - repository counts for dashboards. On an
+// agency's host only that agency's service points and RAiDs are counted.
+func (h *AdminHandler) Stats(w http.ResponseWriter, r *http.Request) {
+	days := defaultStatsDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsDays {
+			http.Error(w, "days must be between 1 and 366", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	sps, err := h.storage.ListServicePoints(r.Context())
+	if err != nil {
+		http.Error(w, "Failed to list service points", http.StatusInternalServerError)
+		return
+	}
+
+	var counts *storage.RAiDCounts
+	if a := agency.FromContext(r.Context()); a != nil {
+		// Served as an agency: count only its service points' RAiDs
+		var keep func(*storage.RAiDRecord) bool
+		sps, keep = agencyScope(sps, a)
+		counts, err = storage.CountRAiDsWhere(r.Context(), h.storage, keep)
+	} else {
+		counts, err = storage.CountRAiDs(r.Context(), h.storage)
+	}
+	if err == storage.ErrCountUnsupported {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to count RAiDs", http.StatusInternalServerError)
+		return
+	}
+
+	response := StatsResponse{
+		StorageType: h.storageType,
+		GeneratedAt: time.Now().UTC(),
+		RAiDs: RAiDStats{
+			Total:     counts.Total,
+			Public:    counts.Public,
+			Embargoed: counts.Embargoed,
+			Deleted:   counts.Deleted,
+		},
+		Versions:      counts.Versions,
+		ServicePoints: servicePointStats(sps, counts.ByServicePoint),
+		Minting:       mintingStats(counts.MintedByDay, days, time.Now().UTC()),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// agencyScope returns the service points of a and a filter for the RAiD
+// records they own
+func agencyScope(sps []*models.ServicePoint, a *agency.Agency) ([]*models.ServicePoint, func(*storage.RAiDRecord) bool) {
+	members := make(map[int64]bool)
+	mine := sps[:0:0]
+	for _, sp := range sps {
+		if sp.AgencyID == a.ID {
+			members[sp.ID] = true
+			mine = append(mine, sp)
+		}
+	}
+	return mine, func(record *storage.RAiDRecord) bool {
+		current := record.Current()
+		return current != nil && current.Identifier != nil && current.Identifier.Owner != nil &&
+			members[current.Identifier.Owner.ServicePoint]
+	}
+}
+
+// MintingActivity is the body of GET /admin/stats/minting
+type MintingActivity struct {
+	Interval string           `json:"interval"`
+	Series   []ActivityPeriod `json:"series"`
+}
+
+// ActivityPeriod counts the RAiDs minted and updated in one interval, by
+// one service point when grouped by service point
+type ActivityPeriod struct {
+	Period       string `json:"period"`
+	ServicePoint *int64 `json:"servicePoint,omitempty"`
+	Minted       int64  `json:"minted"`
+	Updated      int64  `json:"updated"`
+}
+
+// MintingActivity handles GET /admin/stats/minting - RAiDs minted and
+// updated per ?interval (day, week, month or year; month by default) from
+// ?from to ?to, both optional dates, per service point with
+// ?groupBy=servicePoint. Intervals without activity are left out. On an
+// agency's host only that agency's RAiDs are counted.
+func (h *AdminHandler) MintingActivity(w http.ResponseWriter, r *http.Request) {
+	interval := cmp.Or(r.URL.Query().Get("interval"), storage.IntervalMonth)
+	if err := storage.ValidateInterval(interval); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	byServicePoint := false
+	switch groupBy := r.URL.Query().Get("groupBy"); groupBy {
+	case "":
+	case "servicePoint":
+		byServicePoint = true
+	default:
+		http.Error(w, "groupBy must be servicePoint", http.StatusBadRequest)
+		return
+	}
+	var from, to string
+	for name, bound := range map[string]*string{"from": &from, "to": &to} {
+		v := r.URL.Query().Get(name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			http.Error(w, name+" must be a date (YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		*bound = storage.PeriodStart(t, interval).Format("2006-01-02")
+	}
+
+	var counts []storage.ActivityCount
+	var err error
+	if a := agency.FromContext(r.Context()); a != nil {
+		sps, spErr := h.storage.ListServicePoints(r.Context())
+		if spErr != nil {
+			http.Error(w, "Failed to list service points", http.StatusInternalServerError)
+			return
+		}
+		_, keep := agencyScope(sps, a)
+		counts, err = storage.CountActivityWhere(r.Context(), h.storage, interval, keep)
+	} else {
+		counts, err = storage.CountActivity(r.Context(), h.storage, interval)
+	}
+	if err == storage.ErrCountUnsupported {
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to count RAiDs", http.StatusInternalServerError)
+		return
+	}
+
+	response := MintingActivity{Interval: interval, Series: make([]ActivityPeriod, 0, len(counts))}
+	for _, c := range counts {
+		if from != "" && c.Period < from || to != "" && c.Period > to {
+			continue
+		}
+		if byServicePoint {
+			sp := c.ServicePoint
+			response.Series = append(response.Series, ActivityPeriod{Period: c.Period, ServicePoint: &sp, Minted: c.Minted, Updated: c.Updated})
+			continue
+		}
+		// Counts come ordered by period, so service points of one period
+		// are merged into the last entry
+		if n := len(response.Series); n > 0 && response.Series[n-1].Period == c.Period {
+			response.Series[n-1].Minted += c.Minted
+			response.Series[n-1].Updated += c.Updated
+			continue
+		}
+		response.Series = append(response.Series, ActivityPeriod{Period: c.Period, Minted: c.Minted, Updated: c.Updated})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// servicePointStats lists every service point with its RAiD count, plus any
+// owners referenced by RAiDs that are not registered service points
+func servicePointStats(sps []*models.ServicePoint, byServicePoint map[int64]int64) []ServicePointStats {
+	out := make([]ServicePointStats, 0, len(sps)+1)
+	seen := make(map[int64]bool, len(sps))
+	for _, sp := range sps {
+		out = append(out, ServicePointStats{ID: sp.ID, Name: sp.Name, RAiDs: byServicePoint[sp.ID]})
+		seen[sp.ID] = true
+	}
+	for id, n := range byServicePoint {
+		if !seen[id] {
+			out = append(out, ServicePointStats{ID: id, RAiDs: n})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// mintingStats builds a daily series for the days up to and including today
+func mintingStats(byDay map[string]int64, days int, now time.Time) MintingStats {
+	stats := MintingStats{Days: days, Series: make([]DayCount, 0, days)}
+	for i := days - 1; i >= 0; i-- {
+		date := now.AddDate(0, 0, -i).Format("2006-01-02")
+		n := byDay[date]
+		stats.Series = append(stats.Series, DayCount{Date: date, Count: n})
+		stats.Total += n
+	}
+	stats.PerDayAverage = float64(stats.Total) / float64(days)
+	return stats
+}
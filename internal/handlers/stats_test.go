@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestAdminStats(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sp, _ := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Test SP"})
+	for suffix, access := range map[string]string{"a": storage.AccessTypeOpen, "b": storage.AccessTypeEmbargoed, "c": storage.AccessTypeOpen} {
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{
+			Identifier: &models.Identifier{
+				ID:    "https://raid.org/10.99999/" + suffix,
+				Owner: &models.Owner{ServicePoint: sp.ID},
+			},
+			Access: &models.Access{Type: &models.IDSchema{ID: access}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.UpdateRAiD(ctx, "10.99999", "a", &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Owner: &models.Owner{ServicePoint: sp.ID}},
+		Access:     &models.Access{Type: &models.IDSchema{ID: storage.AccessTypeOpen}},
+		Title:      []models.Title{{Text: "Updated"}},
+	})
+	repo.DeleteRAiD(ctx, "10.99999", "c")
+
+	handler := NewAdminHandler(repo, storage.StorageTypeFile, nil, nil, nil, nil, "", nil)
+	rr := httptest.NewRecorder()
+	handler.Stats(rr, httptest.NewRequest(http.MethodGet, "/admin/stats?days=7", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var stats StatsResponse
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.RAiDs != (RAiDStats{Total: 2, Public: 1, Embargoed: 1, Deleted: 1}) {
+		t.Errorf("unexpected RAiD counts: %+v", stats.RAiDs)
+	}
+	if stats.Versions != 4 {
+		t.Errorf("expected 4 versions, got %d", stats.Versions)
+	}
+	if len(stats.ServicePoints) != 1 || stats.ServicePoints[0].RAiDs != 2 {
+		t.Errorf("unexpected service point counts: %+v", stats.ServicePoints)
+	}
+	if len(stats.Minting.Series) != 7 || stats.Minting.Total != 3 {
+		t.Errorf("expected 3 RAiDs minted in a 7 day series, got %+v", stats.Minting)
+	}
+	if today := time.Now().UTC().Format("2006-01-02"); stats.Minting.Series[6].Date != today {
+		t.Errorf("expected series to end today, got %s", stats.Minting.Series[6].Date)
+	}
+}
+
+func TestAdminStats_InvalidDays(t *testing.T) {
+	handler := NewAdminHandler(testutil.NewMockRepository(), storage.StorageTypeFile, nil, nil, nil, nil, "", nil)
+	rr := httptest.NewRecorder()
+	handler.Stats(rr, httptest.NewRequest(http.MethodGet, "/admin/stats?days=0", nil))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestAdminMintingActivity(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sp, _ := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Test SP"})
+	for suffix, owner := range map[string]int64{"a": sp.ID, "b": sp.ID, "c": 0} {
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix, Owner: &models.Owner{ServicePoint: owner}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.UpdateRAiD(ctx, "10.99999", "a", &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Owner: &models.Owner{ServicePoint: sp.ID}},
+		Title:      []models.Title{{Text: "Updated"}},
+	})
+	repo.DeleteRAiD(ctx, "10.99999", "b")
+
+	handler := NewAdminHandler(repo, storage.StorageTypeFile, nil, nil, nil, nil, "", nil)
+	get := func(query string) MintingActivity {
+		rr := httptest.NewRecorder()
+		handler.MintingActivity(rr, httptest.NewRequest(http.MethodGet, "/admin/stats/minting"+query, nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", query, rr.Code, rr.Body.String())
+		}
+		var activity MintingActivity
+		if err := json.NewDecoder(rr.Body).Decode(&activity); err != nil {
+			t.Fatal(err)
+		}
+		return activity
+	}
+
+	month := time.Now().UTC().Format("2006-01") + "-01"
+	activity := get("")
+	if activity.Interval != storage.IntervalMonth || len(activity.Series) != 1 ||
+		activity.Series[0] != (ActivityPeriod{Period: month, Minted: 3, Updated: 1}) {
+		t.Errorf("unexpected monthly activity: %+v", activity)
+	}
+
+	activity = get("?interval=year&groupBy=servicePoint")
+	if len(activity.Series) != 2 || *activity.Series[0].ServicePoint != 0 || activity.Series[0].Minted != 1 ||
+		*activity.Series[1].ServicePoint != sp.ID || activity.Series[1].Minted != 2 || activity.Series[1].Updated != 1 {
+		t.Errorf("unexpected activity per service point: %+v", activity)
+	}
+
+	if activity := get("?interval=day&to=2000-01-01"); len(activity.Series) != 0 {
+		t.Errorf("expected no activity before 2000, got %+v", activity)
+	}
+
+	for _, query := range []string{"?interval=hour", "?groupBy=agency", "?from=yesterday"} {
+		rr := httptest.NewRecorder()
+		handler.MintingActivity(rr, httptest.NewRequest(http.MethodGet, "/admin/stats/minting"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
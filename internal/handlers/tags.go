@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// tagForm is the form of tags once normalized by normalizeTag
+var tagForm = regexp.MustCompile(`^[a-z0-9][a-z0-9._:-]{0,63}$`)
+
+// TagHandler handles the tags service points give the RAiDs they own, for
+// their own curation. Tags are not part of the RAiD metadata.
+type TagHandler struct {
+	storage storage.Repository
+	tags    storage.TagStore
+}
+
+// NewTagHandler creates a new tag handler, keeping tags in tags
+func NewTagHandler(repo storage.Repository, tags storage.TagStore) *TagHandler {
+	return &TagHandler{
+		storage: repo,
+		tags:    tags,
+	}
+}
+
+// Tags is the body of tag requests and responses
+type Tags struct {
+	Tags []string `json:"tags"`
+}
+
+// GetTags handles GET /raid/{prefix}/{suffix}/tags - lists the tags the
+// owning service point gave a RAiD
+func (h *TagHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	servicePoint, prefix, suffix, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	tags, err := h.tags.GetTags(r.Context(), servicePoint, prefix, suffix)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeTags(w, tags)
+}
+
+// AddTags handles POST /raid/{prefix}/{suffix}/tags - adds the tags in the
+// body to a RAiD
+func (h *TagHandler) AddTags(w http.ResponseWriter, r *http.Request) {
+	var req Tags
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req.Tags) == 0 {
+		http.Error(w, "tags must list at least one tag", http.StatusBadRequest)
+		return
+	}
+	for i, tag := range req.Tags {
+		normalized, ok := normalizeTag(tag)
+		if !ok {
+			http.Error(w, "Tags are up to 64 letters, digits, '.', '_', ':' and '-', starting with a letter or digit", http.StatusBadRequest)
+			return
+		}
+		req.Tags[i] = normalized
+	}
+	servicePoint, prefix, suffix, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	tags, err := h.tags.AddTags(r.Context(), servicePoint, prefix, suffix, req.Tags)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeTags(w, tags)
+}
+
+// RemoveTag handles DELETE /raid/{prefix}/{suffix}/tags/{tag} - removes a
+// tag from a RAiD
+func (h *TagHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	tag, _ := normalizeTag(chi.URLParam(r, "tag"))
+	servicePoint, prefix, suffix, ok := h.open(w, r)
+	if !ok {
+		return
+	}
+	tags, err := h.tags.RemoveTags(r.Context(), servicePoint, prefix, suffix, []string{tag})
+	if err != nil {
+		writeStorageError(w, r, err)
+		return
+	}
+	writeTags(w, tags)
+}
+
+// open returns the owning service point and handle of the RAiD a request
+// addresses, if the caller belongs to that service point or is an
+// operator
+func (h *TagHandler) open(w http.ResponseWriter, r *http.Request) (int64, string, string, bool) {
+	raid, err := h.storage.GetRAiD(r.Context(), chi.URLParam(r, "prefix"), chi.URLParam(r, "suffix"))
+	if err != nil {
+		if writeIdentifierError(w, err) {
+			return 0, "", "", false
+		}
+		if err == storage.ErrNotFound {
+			http.Error(w, "RAiD not found", http.StatusNotFound)
+			return 0, "", "", false
+		}
+		writeStorageError(w, r, err)
+		return 0, "", "", false
+	}
+	var owner int64
+	if raid.Identifier != nil && raid.Identifier.Owner != nil {
+		owner = raid.Identifier.Owner.ServicePoint
+	}
+	if owner == 0 || !isMember(r, owner) {
+		http.Error(w, "Only members of the service point owning the RAiD can see its tags", http.StatusForbidden)
+		return 0, "", "", false
+	}
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+	if err != nil {
+		writeStorageError(w, r, err)
+		return 0, "", "", false
+	}
+	return owner, prefix, suffix, true
+}
+
+// isMember reports whether the caller acts for servicePoint: its members
+// and operators do
+func isMember(r *http.Request, servicePoint int64) bool {
+	if middleware.HasRole(r.Context(), middleware.RoleOperator) {
+		return true
+	}
+	id, ok := middleware.GetServicePointID(r.Context())
+	return ok && id == servicePoint
+}
+
+// normalizeTag returns tag trimmed and in lower case, and whether it is a
+// valid tag
+func normalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	return tag, tagForm.MatchString(tag)
+}
+
+// taggedFilter reads ?tag, which may be repeated, returning a filter
+// keeping the RAiDs servicePoint gave every tag, or nil without tags
+func taggedFilter(r *http.Request, store storage.TagStore, servicePoint int64) (func(*models.RAiD) bool, error) {
+	tags := make(map[string]bool)
+	for _, tag := range r.URL.Query()["tag"] {
+		tag, _ = normalizeTag(tag)
+		tags[tag] = true
+	}
+	if len(tags) == 0 {
+		return nil, nil
+	}
+	counts := make(map[string]int)
+	for tag := range tags {
+		refs, err := store.TaggedRAiDs(r.Context(), servicePoint, tag)
+		if err != nil {
+			return nil, err
+		}
+		for _, ref := range refs {
+			counts[ref.Prefix+"/"+ref.Suffix]++
+		}
+	}
+	return func(raid *models.RAiD) bool {
+		if raid.Identifier == nil {
+			return false
+		}
+		prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+		return err == nil && counts[prefix+"/"+suffix] == len(tags)
+	}, nil
+}
+
+func writeTags(w http.ResponseWriter, tags []string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Tags{Tags: tags})
+}
@@ -0,0 +1,201 @@
+package health
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The gRPC health checking protocol, grpc.health.v1, spoken over
+// cleartext HTTP/2 without a gRPC library: its messages are small enough
+// to encode by hand.
+
+const (
+	grpcCheckPath = "/grpc.health.v1.Health/Check"
+	grpcWatchPath = "/grpc.health.v1.Health/Watch"
+
+	// maxGRPCRequest bounds a HealthCheckRequest, which names a service
+	maxGRPCRequest = 4096
+)
+
+// WatchInterval is how often a Watch call checks for a change
+var WatchInterval = 5 * time.Second
+
+// ServingStatus values of grpc.health.v1.HealthCheckResponse
+const (
+	servingUnknown        = 0
+	serving               = 1
+	notServing            = 2
+	servingServiceUnknown = 3
+)
+
+// gRPC status codes
+const (
+	codeOK            = 0
+	codeInvalidArg    = 3
+	codeNotFound      = 5
+	codeUnimplemented = 12
+)
+
+// GRPCHandler returns a handler of the grpc.health.v1.Health service, to
+// be served over HTTP/2. The service "" is the server; the names of the
+// dependencies added to c are services too. Degraded counts as SERVING,
+// as the server still serves.
+func GRPCHandler(c *Checker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		service, err := readHealthCheckRequest(r.Body)
+		if err != nil {
+			writeGRPCStatus(w, codeInvalidArg, err.Error())
+			return
+		}
+
+		switch r.URL.Path {
+		case grpcCheckPath:
+			level, ok := c.Run(r.Context()).Level(service)
+			if !ok {
+				writeGRPCStatus(w, codeNotFound, "unknown service "+strconv.Quote(service))
+				return
+			}
+			writeHealthCheckResponse(w, servingStatus(level, true))
+			setGRPCTrailer(w, codeOK, "")
+		case grpcWatchPath:
+			watch(r.Context(), w, c, service)
+		default:
+			writeGRPCStatus(w, codeUnimplemented, "unknown method "+r.URL.Path)
+		}
+	})
+}
+
+// watch streams the serving status of service whenever it changes, until
+// the client goes away
+func watch(ctx context.Context, w http.ResponseWriter, c *Checker, service string) {
+	rc := http.NewResponseController(w)
+	last := -1
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		level, ok := c.Run(ctx).Level(service)
+		if status := servingStatus(level, ok); status != last {
+			writeHealthCheckResponse(w, status)
+			if err := rc.Flush(); err != nil {
+				return
+			}
+			last = status
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func servingStatus(level Level, known bool) int {
+	switch {
+	case !known:
+		return servingServiceUnknown
+	case level == Down:
+		return notServing
+	case level == OK || level == Degraded:
+		return serving
+	}
+	return servingUnknown
+}
+
+// readHealthCheckRequest returns the service of the length-prefixed
+// HealthCheckRequest message in body
+func readHealthCheckRequest(body io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(body, maxGRPCRequest+1))
+	if err != nil {
+		return "", err
+	}
+	if len(data) == 0 {
+		return "", nil
+	}
+	if len(data) < 5 || len(data) > maxGRPCRequest {
+		return "", errors.New("malformed request")
+	}
+	if data[0] != 0 {
+		return "", errors.New("compressed requests are not supported")
+	}
+	msg := data[5:]
+	if int(binary.BigEndian.Uint32(data[1:5])) != len(msg) {
+		return "", errors.New("malformed request")
+	}
+
+	// Field 1, a string, is the service; any other field is skipped
+	var service string
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return "", errors.New("malformed request")
+		}
+		msg = msg[n:]
+		var value []byte
+		switch key & 7 {
+		case 0:
+			if _, n = binary.Uvarint(msg); n <= 0 {
+				return "", errors.New("malformed request")
+			}
+		case 1:
+			n = 8
+		case 2:
+			size, m := binary.Uvarint(msg)
+			if m <= 0 || size > uint64(len(msg)-m) {
+				return "", errors.New("malformed request")
+			}
+			value, n = msg[m:m+int(size)], m+int(size)
+		case 5:
+			n = 4
+		default:
+			return "", fmt.Errorf("unsupported wire type %d", key&7)
+		}
+		if n > len(msg) {
+			return "", errors.New("malformed request")
+		}
+		msg = msg[n:]
+		if key == 1<<3|2 {
+			service = string(value)
+		}
+	}
+	return service, nil
+}
+
+// writeHealthCheckResponse writes a length-prefixed HealthCheckResponse
+// with status in field 1
+func writeHealthCheckResponse(w http.ResponseWriter, status int) {
+	msg := []byte{1 << 3, byte(status)}
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	w.Write(append(frame, msg...))
+}
+
+// writeGRPCStatus ends a call that sent no message, with the status in
+// the headers
+func writeGRPCStatus(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set("Grpc-Message", message)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// setGRPCTrailer ends a call that sent messages, with the status in the
+// trailers
+func setGRPCTrailer(w http.ResponseWriter, code int, message string) {
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(code))
+	if message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", message)
+	}
+}
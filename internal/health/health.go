@@ -0,0 +1,144 @@
+// Package health reports the state of the server's dependencies. Each
+// dependency is checked by a Check, and a Checker combines their results
+// into one of three levels: ok, degraded (serving, with reduced
+// function) or down. Handler serves the report for /readyz; GRPCHandler
+// serves it by the gRPC health checking protocol.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Level is how well a dependency, or the server, is working
+type Level string
+
+const (
+	// OK is full function
+	OK Level = "ok"
+	// Degraded is serving with reduced function, e.g. read-only
+	Degraded Level = "degraded"
+	// Down is not serving
+	Down Level = "down"
+)
+
+// severity orders levels from best to worst
+func (l Level) severity() int {
+	switch l {
+	case OK:
+		return 0
+	case Degraded:
+		return 1
+	}
+	return 2
+}
+
+// Timeout bounds each check of a report
+const Timeout = 5 * time.Second
+
+// Result is the state of a dependency
+type Result struct {
+	Status Level `json:"status"`
+	// Message says why the dependency is not OK
+	Message string `json:"message,omitempty"`
+	// Detail is the dependency's own status, e.g. its circuit breaker
+	Detail any `json:"detail,omitempty"`
+	// RetryAfter is when a dependency that is down may be back, if known
+	RetryAfter time.Duration `json:"-"`
+}
+
+// Check checks a dependency
+type Check func(ctx context.Context) Result
+
+type dependency struct {
+	name     string
+	critical bool
+	check    Check
+}
+
+// Checker checks the dependencies added to it
+type Checker struct {
+	deps []dependency
+}
+
+// Add adds a dependency. The server is down when a critical dependency is
+// down; other dependencies only degrade it.
+func (c *Checker) Add(name string, critical bool, check Check) {
+	c.deps = append(c.deps, dependency{name: name, critical: critical, check: check})
+}
+
+// Report is the state of the server and of each of its dependencies
+type Report struct {
+	// Ready is whether the server is serving, i.e. not down
+	Ready  bool              `json:"ready"`
+	Status Level             `json:"status"`
+	Checks map[string]Result `json:"checks"`
+	// RetryAfter is the longest RetryAfter of the dependencies down
+	RetryAfter time.Duration `json:"-"`
+}
+
+// Run checks every dependency, concurrently and each within Timeout
+func (c *Checker) Run(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	results := make([]Result, len(c.deps))
+	var wg sync.WaitGroup
+	for i, dep := range c.deps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = dep.check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: OK, Checks: make(map[string]Result, len(c.deps))}
+	for i, dep := range c.deps {
+		result := results[i]
+		report.Checks[dep.name] = result
+		level := result.Status
+		if level == Down && !dep.critical {
+			level = Degraded
+		}
+		if level.severity() > report.Status.severity() {
+			report.Status = level
+		}
+		if result.Status == Down {
+			report.RetryAfter = max(report.RetryAfter, result.RetryAfter)
+		}
+	}
+	report.Ready = report.Status != Down
+	return report
+}
+
+// Level returns the state of the named dependency, or of the server for
+// an empty name, and whether there is such a dependency
+func (r *Report) Level(name string) (Level, bool) {
+	if name == "" {
+		return r.Status, true
+	}
+	result, ok := r.Checks[name]
+	return result.Status, ok
+}
+
+// Handler returns the handler of GET /readyz. It answers 503 while the
+// server is down, so that load balancers stop routing to it, with
+// Retry-After when a dependency says when it may be back.
+func Handler(c *Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Ready {
+			if report.RetryAfter > 0 {
+				w.Header().Set("Retry-After", strconv.Itoa(int(report.RetryAfter.Seconds())+1))
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
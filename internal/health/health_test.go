@@ -0,0 +1,120 @@
+package health
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func fixed(level Level) Check {
+	return func(context.Context) Result { return Result{Status: level} }
+}
+
+func TestChecker_Run(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		critical Level
+		optional Level
+		want     Level
+	}{
+		{"all ok", OK, OK, OK},
+		{"critical degraded", Degraded, OK, Degraded},
+		{"optional down", OK, Down, Degraded},
+		{"critical down", Down, Degraded, Down},
+	} {
+		var c Checker
+		c.Add("storage", true, fixed(tc.critical))
+		c.Add("notifications", false, fixed(tc.optional))
+		report := c.Run(context.Background())
+		if report.Status != tc.want || report.Ready != (tc.want != Down) {
+			t.Errorf("%s: expected %s, got %s (ready %t)", tc.name, tc.want, report.Status, report.Ready)
+		}
+		if report.Checks["notifications"].Status != tc.optional {
+			t.Errorf("%s: expected the dependency's own level reported, got %+v", tc.name, report.Checks)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var c Checker
+	c.Add("storage", true, func(context.Context) Result {
+		return Result{Status: Down, Message: "connection refused", RetryAfter: 30 * time.Second}
+	})
+	w := httptest.NewRecorder()
+	Handler(&c).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	if w.Code != http.StatusServiceUnavailable || json.NewDecoder(w.Body).Decode(&report) != nil {
+		t.Fatalf("expected 503, got %d", w.Code)
+	}
+	if w.Header().Get("Retry-After") != "31" || report.Checks["storage"].Message != "connection refused" {
+		t.Errorf("unexpected response %q %+v", w.Header().Get("Retry-After"), report)
+	}
+}
+
+// grpcRequest returns a length-prefixed HealthCheckRequest for service
+func grpcRequest(service string) []byte {
+	msg := append([]byte{1<<3 | 2, byte(len(service))}, service...)
+	frame := make([]byte, 5)
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	return append(frame, msg...)
+}
+
+func TestGRPCHandler(t *testing.T) {
+	level := OK
+	var c Checker
+	c.Add("storage", true, func(context.Context) Result { return Result{Status: level} })
+
+	srv := httptest.NewUnstartedServer(GRPCHandler(&c))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+	client := &http.Client{Transport: &http.Transport{Protocols: new(http.Protocols)}}
+	client.Transport.(*http.Transport).Protocols.SetUnencryptedHTTP2(true)
+
+	call := func(method, service string) (*http.Response, []byte) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+"/grpc.health.v1.Health/"+method, bytes.NewReader(grpcRequest(service)))
+		req.Header.Set("Content-Type", "application/grpc")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, body
+	}
+	status := func(resp *http.Response) string {
+		if s := resp.Trailer.Get("Grpc-Status"); s != "" {
+			return s
+		}
+		return resp.Header.Get("Grpc-Status")
+	}
+
+	for _, tc := range []struct {
+		level   Level
+		service string
+		want    byte
+	}{{OK, "", serving}, {Degraded, "storage", serving}, {Down, "", notServing}} {
+		level = tc.level
+		resp, body := call("Check", tc.service)
+		if resp.ProtoMajor != 2 || status(resp) != "0" {
+			t.Fatalf("expected an HTTP/2 call with status 0, got %s %q", resp.Proto, status(resp))
+		}
+		if !bytes.Equal(body, []byte{0, 0, 0, 0, 2, 1 << 3, tc.want}) {
+			t.Errorf("%s %q: unexpected response % x", tc.level, tc.service, body)
+		}
+	}
+	if resp, _ := call("Check", "search"); status(resp) != "5" {
+		t.Errorf("expected NOT_FOUND for an unknown service, got %q", status(resp))
+	}
+	if resp, _ := call("List", ""); status(resp) != "12" {
+		t.Errorf("expected UNIMPLEMENTED for an unknown method, got %q", status(resp))
+	}
+}
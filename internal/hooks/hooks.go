@@ -0,0 +1,119 @@
+// Package hooks runs deployment-specific code around RAiD writes. Before
+// hooks may modify the RAiD or veto the operation; after hooks observe the
+// result and cannot undo it.
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Hook is called with the request context and a RAiD
+type Hook func(ctx context.Context, raid *models.RAiD) error
+
+// UpdateHook is called with the request context, the stored RAiD and its
+// replacement
+type UpdateHook func(ctx context.Context, current, updated *models.RAiD) error
+
+// Hooks holds the registered hooks. Hooks of the same kind run in the order
+// they were added.
+type Hooks struct {
+	// BeforeMint runs before a RAiD is created and may modify it; the
+	// first error aborts the mint
+	BeforeMint []Hook
+	// AfterMint runs after a RAiD has been created; errors are logged
+	AfterMint []Hook
+	// BeforeUpdate runs before a RAiD is replaced and may modify the
+	// replacement; the first error aborts the update
+	BeforeUpdate []UpdateHook
+	// AfterDelete runs with the last version of a deleted RAiD; errors are
+	// logged
+	AfterDelete []Hook
+}
+
+// VetoError is returned by a before hook to reject an operation. The
+// reason is returned to the client.
+type VetoError struct {
+	Reason string
+}
+
+func (e *VetoError) Error() string {
+	return "rejected by policy: " + e.Reason
+}
+
+// Veto returns a VetoError with a formatted reason
+func Veto(format string, args ...interface{}) error {
+	return &VetoError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns a repository that runs h around the RAiD writes of repo, or
+// repo itself if no hooks are registered. The wrapper only has the
+// Repository methods, so capability checks by type assertion must use the
+// unwrapped repository.
+func Wrap(repo storage.Repository, h *Hooks) storage.Repository {
+	if h == nil || (len(h.BeforeMint) == 0 && len(h.AfterMint) == 0 && len(h.BeforeUpdate) == 0 && len(h.AfterDelete) == 0) {
+		return repo
+	}
+	return &repository{Repository: repo, hooks: h}
+}
+
+type repository struct {
+	storage.Repository
+	hooks *Hooks
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	for _, h := range r.hooks.BeforeMint {
+		if err := h(ctx, raid); err != nil {
+			return nil, err
+		}
+	}
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range r.hooks.AfterMint {
+		if err := h(ctx, created); err != nil {
+			log.Printf("AfterMint hook failed for %s: %v", created.Identifier.ID, err)
+		}
+	}
+	return created, nil
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	if len(r.hooks.BeforeUpdate) > 0 {
+		current, err := r.Repository.GetRAiD(ctx, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range r.hooks.BeforeUpdate {
+			if err := h(ctx, current, raid); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	if len(r.hooks.AfterDelete) == 0 {
+		return r.Repository.DeleteRAiD(ctx, prefix, suffix)
+	}
+	last, err := r.Repository.GetRAiD(ctx, prefix, suffix)
+	if err != nil {
+		return err
+	}
+	if err := r.Repository.DeleteRAiD(ctx, prefix, suffix); err != nil {
+		return err
+	}
+	for _, h := range r.hooks.AfterDelete {
+		if err := h(ctx, last); err != nil {
+			log.Printf("AfterDelete hook failed for %s/%s: %v", prefix, suffix, err)
+		}
+	}
+	return nil
+}
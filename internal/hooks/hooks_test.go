@@ -0,0 +1,78 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestWrap(t *testing.T) {
+	repo := testutil.NewMockRepository()
+	if Wrap(repo, &Hooks{}) != storage.Repository(repo) {
+		t.Error("expected Wrap without hooks to return the repository")
+	}
+
+	var calls []string
+	h := &Hooks{
+		BeforeMint: []Hook{
+			func(_ context.Context, raid *models.RAiD) error {
+				calls = append(calls, "before1")
+				raid.Title = []models.Title{{Text: "Set by hook"}}
+				return nil
+			},
+			func(_ context.Context, raid *models.RAiD) error {
+				calls = append(calls, "before2:"+raid.Title[0].Text)
+				return nil
+			},
+		},
+		AfterMint: []Hook{func(context.Context, *models.RAiD) error {
+			calls = append(calls, "after")
+			return errors.New("logged, not returned")
+		}},
+		BeforeUpdate: []UpdateHook{func(_ context.Context, current, updated *models.RAiD) error {
+			if updated.Identifier == nil || updated.Identifier.Owner == nil || updated.Identifier.Owner.ServicePoint != current.Identifier.Owner.ServicePoint {
+				return Veto("the owner of %s cannot be changed", current.Identifier.ID)
+			}
+			return nil
+		}},
+		AfterDelete: []Hook{func(_ context.Context, raid *models.RAiD) error {
+			calls = append(calls, "deleted:"+raid.Identifier.ID)
+			return nil
+		}},
+	}
+	wrapped := Wrap(repo, h)
+	ctx := context.Background()
+
+	if _, err := wrapped.CreateRAiD(ctx, testutil.NewTestRAiD("10.12345", "a")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := wrapped.UpdateRAiD(ctx, "10.12345", "a", &models.RAiD{})
+	var veto *VetoError
+	if !errors.As(err, &veto) || veto.Reason != "the owner of https://raid.org/10.12345/a cannot be changed" {
+		t.Errorf("expected the update to be vetoed, got %v", err)
+	}
+	if repo.UpdateRAiDCalls != 0 {
+		t.Error("vetoed update reached the repository")
+	}
+	if _, err := wrapped.UpdateRAiD(ctx, "10.12345", "a", testutil.NewTestRAiD("10.12345", "a")); err != nil {
+		t.Errorf("expected an allowed update to succeed, got %v", err)
+	}
+	if err := wrapped.DeleteRAiD(ctx, "10.12345", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"before1", "before2:Set by hook", "after", "deleted:https://raid.org/10.12345/a"}
+	if len(calls) != len(want) {
+		t.Fatalf("hooks ran as %v, want %v", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("hooks ran as %v, want %v", calls, want)
+			break
+		}
+	}
+}
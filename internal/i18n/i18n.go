@@ -0,0 +1,257 @@
+// Package i18n translates the error messages of API responses: the titles
+// and details of error documents, their validation failure messages and
+// the plain text of other errors.
+//
+// Messages are written in English throughout the code and translated when
+// a response is written, in the language negotiated from its request's
+// Accept-Language header. A catalog maps the English messages of one
+// language, named by its ISO 639-3 code, to their translations; messages a
+// catalog lacks stay in English. Messages with arguments are listed with
+// the fmt verbs they are formatted with, "%s overlaps %s in %s", and
+// translated ones are recognised by matching the formatted message. The
+// verbs of translations may be indexed, %[2]s, to reorder the arguments.
+// Field names and error types are not translated, so clients can keep
+// matching them.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/leifj/go-raid/internal/language"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// English is the language messages are written in
+const English = "eng"
+
+//go:embed catalogs/*.json
+var builtin embed.FS
+
+var (
+	mu       sync.RWMutex
+	catalogs = make(map[string]*catalog)
+)
+
+func init() {
+	entries, err := builtin.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := builtin.ReadFile("catalogs/" + entry.Name())
+		if err != nil {
+			panic(err)
+		}
+		if err := add(strings.TrimSuffix(entry.Name(), ".json"), data); err != nil {
+			panic(fmt.Sprintf("built-in catalog %s: %v", entry.Name(), err))
+		}
+	}
+}
+
+// LoadDir adds the catalogs in dir, one <code>.json file per language,
+// to the built-in ones. Their translations take precedence, so that a
+// deployment can add languages and reword messages.
+func LoadDir(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := add(strings.TrimSuffix(filepath.Base(path), ".json"), data); err != nil {
+			return fmt.Errorf("message catalog %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Languages returns the codes of the languages messages can be translated
+// to, English first
+func Languages() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	codes := make([]string, 0, len(catalogs))
+	for code := range catalogs {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return append([]string{English}, codes...)
+}
+
+// catalog holds the translations of one language
+type catalog struct {
+	messages map[string]string
+	// patterns recognise formatted messages, longest first
+	patterns []pattern
+}
+
+// pattern recognises a message formatted from format and translates it
+type pattern struct {
+	format string
+	re     *regexp.Regexp
+	// translation takes the arguments as the strings matched by re
+	translation string
+}
+
+// verb matches the fmt verbs of messages and translations
+var verb = regexp.MustCompile(`%(\[\d+\])?[sdvq]`)
+
+// add merges the catalog in data into that of code
+func add(code string, data []byte) error {
+	if code == English || len(code) != 3 {
+		return fmt.Errorf("catalogs must be named by an ISO 639-3 code other than %s, got %q", English, code)
+	}
+	var messages map[string]string
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return err
+	}
+
+	for message, translation := range messages {
+		if n := len(verb.FindAllString(message, -1)); n > 0 {
+			args := make([]any, n)
+			for i := range args {
+				args[i] = "x"
+			}
+			if got := fmt.Sprintf(verb.ReplaceAllString(translation, "%${1}s"), args...); strings.Contains(got, "%!") {
+				return fmt.Errorf("translation %q does not take the %d arguments of %q", translation, n, message)
+			}
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	c := catalogs[code]
+	if c == nil {
+		c = &catalog{messages: make(map[string]string)}
+		catalogs[code] = c
+	}
+	for message, translation := range messages {
+		c.messages[message] = translation
+	}
+
+	c.patterns = c.patterns[:0]
+	for message, translation := range c.messages {
+		if !verb.MatchString(message) {
+			continue
+		}
+		parts := verb.Split(message, -1)
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		c.patterns = append(c.patterns, pattern{
+			format:      message,
+			re:          regexp.MustCompile("^" + strings.Join(parts, "(.+?)") + "$"),
+			translation: verb.ReplaceAllString(translation, "%${1}s"),
+		})
+	}
+	sort.Slice(c.patterns, func(i, j int) bool {
+		if len(c.patterns[i].format) != len(c.patterns[j].format) {
+			return len(c.patterns[i].format) > len(c.patterns[j].format)
+		}
+		return c.patterns[i].format < c.patterns[j].format
+	})
+	return nil
+}
+
+// Negotiate returns the code of the language an Accept-Language header
+// prefers among those with a catalog, or English
+func Negotiate(acceptLanguage string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, code := range language.Preferences(acceptLanguage) {
+		if code == English {
+			break
+		}
+		if _, ok := catalogs[code]; ok {
+			return code
+		}
+	}
+	return English
+}
+
+// Printer translates the messages of one response
+type Printer struct {
+	lang string
+}
+
+// For returns the printer of the response to r, in the language negotiated
+// from its Accept-Language header, and sets the headers saying so on w. It
+// must be called before the response header is written.
+func For(w http.ResponseWriter, r *http.Request) Printer {
+	p := Printer{lang: Negotiate(r.Header.Get("Accept-Language"))}
+	w.Header().Add("Vary", "Accept-Language")
+	w.Header().Set("Content-Language", language.Tag(p.lang))
+	return p
+}
+
+// Sprintf formats the translation of format with args
+func (p Printer) Sprintf(format string, args ...any) string {
+	mu.RLock()
+	if c := catalogs[p.lang]; c != nil {
+		if translation, ok := c.messages[format]; ok {
+			format = translation
+		}
+	}
+	mu.RUnlock()
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Message returns the translation of an already formatted message
+func (p Printer) Message(message string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	c := catalogs[p.lang]
+	if c == nil {
+		return message
+	}
+	if translation, ok := c.messages[message]; ok {
+		return translation
+	}
+	for _, pat := range c.patterns {
+		if m := pat.re.FindStringSubmatch(message); m != nil {
+			args := make([]any, len(m)-1)
+			for i, arg := range m[1:] {
+				args[i] = arg
+			}
+			return fmt.Sprintf(pat.translation, args...)
+		}
+	}
+	return message
+}
+
+// Failures returns a copy of failures with their messages translated
+func (p Printer) Failures(failures []models.ValidationFailure) []models.ValidationFailure {
+	if p.lang == English || failures == nil {
+		return failures
+	}
+	translated := make([]models.ValidationFailure, len(failures))
+	for i, f := range failures {
+		f.Message = p.Message(f.Message)
+		translated[i] = f
+	}
+	return translated
+}
+
+// Error replies to the request with the translation of message as a
+// plain text error, as http.Error does
+func (p Printer) Error(w http.ResponseWriter, message string, code int) {
+	http.Error(w, p.Message(message), code)
+}
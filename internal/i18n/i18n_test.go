@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", English},
+		{"sv-SE", "swe"},
+		{"fi, de;q=0.8", "deu"},
+		{"en, sv;q=0.5", English},
+		{"*", English},
+		{"fr-CA;q=0.4, sv;q=0.9", "swe"},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.header); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept-Language", "sv")
+	w := httptest.NewRecorder()
+	p := For(w, r)
+
+	if got := w.Header().Get("Content-Language"); got != "sv" {
+		t.Errorf("expected Content-Language sv, got %q", got)
+	}
+	if got := p.Sprintf("Update was made to version %d but the current version is %d.", 2, 3); got != "Ändringen gjordes mot version 2 men aktuell version är 3." {
+		t.Errorf("unexpected translation: %q", got)
+	}
+	// Formatted messages are recognised, the most specific format first
+	if got := p.Message("10.1 overlaps prefix 10.2 of service point 4"); got != "10.1 överlappar prefixet 10.2 hos tjänstepunkt 4" {
+		t.Errorf("unexpected translation: %q", got)
+	}
+	if got := p.Message("something new"); got != "something new" {
+		t.Errorf("expected an unknown message in English, got %q", got)
+	}
+
+	failures := []models.ValidationFailure{{FieldID: "title", ErrorType: "notSet", Message: "a primary title is required"}}
+	translated := p.Failures(failures)
+	if translated[0].Message != "en huvudtitel krävs" || translated[0].FieldID != "title" || translated[0].ErrorType != "notSet" {
+		t.Errorf("unexpected translated failure: %+v", translated[0])
+	}
+	if failures[0].Message != "a primary title is required" {
+		t.Error("expected the failures to be left unchanged")
+	}
+
+	w = httptest.NewRecorder()
+	if got := For(w, httptest.NewRequest(http.MethodGet, "/", nil)).Message("Not found"); got != "Not found" || w.Header().Get("Content-Language") != "en" {
+		t.Errorf("expected English without Accept-Language, got %q in %q", got, w.Header().Get("Content-Language"))
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "nob.json"), []byte(`{"Not found": "Ikke funnet", "%s overlaps %s in %s": "%[3]s: %[1]s overlapper %[2]s"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(dir); err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	p := Printer{lang: Negotiate("nb-NO")}
+	if got := p.Message("Not found"); got != "Ikke funnet" {
+		t.Errorf("expected the added catalog to be used, got %q", got)
+	}
+	if got := p.Message("10.1 overlaps 10.1.2 in servicePoint.prefix"); got != "servicePoint.prefix: 10.1 overlapper 10.1.2" {
+		t.Errorf("expected reordered arguments, got %q", got)
+	}
+
+	bad := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bad, "dan.json"), []byte(`{"%s is not allocated to the registration agency": "ikke tildelt"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDir(bad); err == nil {
+		t.Error("expected a translation dropping an argument to be rejected")
+	}
+	if Negotiate("da") != English {
+		t.Error("expected a rejected catalog not to be used")
+	}
+	if err := LoadDir(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected a missing directory to be reported")
+	}
+}
@@ -0,0 +1,76 @@
+package identifier
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ErrInvalidCursor is returned for a listing cursor that was not made by
+// EncodeCursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor returns an opaque cursor continuing a listing after the RAiD
+// with the given handle
+func EncodeCursor(prefix, suffix string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(prefix + "/" + suffix))
+}
+
+// ParseCursor returns the handle of the RAiD a cursor continues after
+func ParseCursor(cursor string) (prefix, suffix string, err error) {
+	handle, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", "", ErrInvalidCursor
+	}
+	prefix, suffix, ok := strings.Cut(string(handle), "/")
+	if !ok || prefix == "" || suffix == "" {
+		return "", "", ErrInvalidCursor
+	}
+	return prefix, suffix, nil
+}
+
+// NextCursor returns the cursor continuing after the last RAiD of a page,
+// or "" for an empty page
+func NextCursor(page []*models.RAiD) string {
+	if len(page) == 0 {
+		return ""
+	}
+	last := page[len(page)-1]
+	if last.Identifier == nil {
+		return ""
+	}
+	prefix, suffix, err := Parse(last.Identifier.ID)
+	if err != nil {
+		return ""
+	}
+	return EncodeCursor(prefix, suffix)
+}
+
+// AfterCursor returns the RAiDs of a listing in handle order that come
+// after the RAiD cursor points at, which need not be listed itself. It is
+// for backends that filter in memory; an invalid cursor yields nothing.
+func AfterCursor(raids []*models.RAiD, cursor string) []*models.RAiD {
+	if cursor == "" {
+		return raids
+	}
+	after := make([]*models.RAiD, 0, len(raids))
+	prefix, suffix, err := ParseCursor(cursor)
+	if err != nil {
+		return after
+	}
+	for _, raid := range raids {
+		if raid.Identifier == nil {
+			continue
+		}
+		p, s, err := Parse(raid.Identifier.ID)
+		if err != nil {
+			continue
+		}
+		if p > prefix || p == prefix && s > suffix {
+			after = append(after, raid)
+		}
+	}
+	return after
+}
@@ -0,0 +1,108 @@
+// Package identifier formats and parses RAiD identifiers. A RAiD is named by
+// a handle, PREFIX/SUFFIX, and identified by a URL made of a base URL and the
+// handle, e.g. https://raid.org/10.82841/abc or https://doi.org/10.82841/abc.
+package identifier
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// DefaultBaseURL is the base URL of identifiers minted by raid.org
+const DefaultBaseURL = "https://raid.org/"
+
+// schemes are the URI forms of a handle accepted by Parse
+var schemes = []string{"doi:", "hdl:", "info:doi/", "info:hdl/"}
+
+// Format returns the identifier URL of a handle under baseURL; an empty
+// baseURL means DefaultBaseURL
+func Format(baseURL, prefix, suffix string) string {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + prefix + "/" + suffix
+}
+
+// FormatDefault returns the identifier URL of a handle under
+// DefaultBaseURL
+func FormatDefault(prefix, suffix string) string {
+	return Format("", prefix, suffix)
+}
+
+// Parse returns the handle of a RAiD given as an identifier URL under any
+// base URL, as doi:PREFIX/SUFFIX or hdl:PREFIX/SUFFIX, or as a bare
+// PREFIX/SUFFIX
+func Parse(id string) (prefix, suffix string, err error) {
+	p := id
+	for _, scheme := range schemes {
+		if len(p) > len(scheme) && strings.EqualFold(p[:len(scheme)], scheme) {
+			p = p[len(scheme):]
+			break
+		}
+	}
+	if u, err := url.Parse(p); err == nil && u.Scheme != "" && u.Host != "" {
+		p = u.Path
+	}
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) < 2 || parts[len(parts)-2] == "" || parts[len(parts)-1] == "" {
+		return "", "", fmt.Errorf("invalid RAiD identifier %q: expected PREFIX/SUFFIX", id)
+	}
+	return parts[len(parts)-2], parts[len(parts)-1], nil
+}
+
+// DOIBaseURL is the resolver DOIs are written under by NormalizeDOI
+const DOIBaseURL = "https://doi.org/"
+
+// doiForms are the prefixes DOIs are written with, resolver URLs first
+var doiForms = []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:", "info:doi/"}
+
+// NormalizeDOI returns a DOI given as a resolver URL, as doi:10.x/y or as a
+// bare 10.x/y in the form https://doi.org/10.x/y, lower-cased since DOIs
+// are case-insensitive. It returns false for anything that is not a DOI.
+func NormalizeDOI(id string) (string, bool) {
+	doi := strings.TrimSpace(id)
+	for _, form := range doiForms {
+		if len(doi) > len(form) && strings.EqualFold(doi[:len(form)], form) {
+			doi = doi[len(form):]
+			break
+		}
+	}
+	prefix, suffix, ok := strings.Cut(doi, "/")
+	if !ok || !strings.HasPrefix(prefix, "10.") || len(prefix) < 4 || suffix == "" {
+		return "", false
+	}
+	return DOIBaseURL + strings.ToLower(doi), true
+}
+
+// RORBaseURL is the URI ROR IDs are written under by NormalizeROR
+const RORBaseURL = "https://ror.org/"
+
+// rorPattern matches a ROR ID: a zero, six Crockford base32 characters and
+// two check digits
+var rorPattern = regexp.MustCompile(`^0[0-9a-hjkmnp-tv-z]{6}[0-9]{2}$`)
+
+// NormalizeROR returns a ROR ID given as a URL or bare, e.g. 038sjwq14, in
+// the form https://ror.org/038sjwq14. It returns false for anything that
+// is not a ROR ID.
+func NormalizeROR(id string) (string, bool) {
+	ror := strings.ToLower(strings.TrimSpace(id))
+	for _, form := range []string{RORBaseURL, "http://ror.org/", "ror.org/"} {
+		ror = strings.TrimPrefix(ror, form)
+	}
+	if !rorPattern.MatchString(ror) {
+		return "", false
+	}
+	return RORBaseURL + ror, true
+}
+
+// ValidateBaseURL checks that baseURL is an absolute http(s) URL that
+// handles can be appended to
+func ValidateBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("must be an http(s) URL without query or fragment, got %q", baseURL)
+	}
+	return nil
+}
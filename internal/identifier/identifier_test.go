@@ -0,0 +1,170 @@
+package identifier_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		base, want string
+	}{
+		{"", "https://raid.org/10.82841/abc"},
+		{"https://doi.org", "https://doi.org/10.82841/abc"},
+		{"https://registry.example.org/raid/", "https://registry.example.org/raid/10.82841/abc"},
+	}
+	for _, tt := range tests {
+		if got := identifier.Format(tt.base, "10.82841", "abc"); got != tt.want {
+			t.Errorf("Format(%q) = %s, want %s", tt.base, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, id := range []string{
+		"https://raid.org/10.82841/abc",
+		"https://doi.org/10.82841/abc",
+		"http://registry.example.org:8080/raid/10.82841/abc",
+		"doi:10.82841/abc",
+		"DOI:10.82841/abc",
+		"hdl:10.82841/abc",
+		"info:doi/10.82841/abc",
+		"10.82841/abc",
+		"/10.82841/abc/",
+	} {
+		prefix, suffix, err := identifier.Parse(id)
+		if err != nil || prefix != "10.82841" || suffix != "abc" {
+			t.Errorf("Parse(%q) = %q, %q, %v", id, prefix, suffix, err)
+		}
+	}
+	for _, id := range []string{"", "abc", "https://raid.org/abc", "doi:"} {
+		if _, _, err := identifier.Parse(id); err == nil {
+			t.Errorf("Parse(%q): expected an error", id)
+		}
+	}
+}
+
+func TestNormalizeDOI(t *testing.T) {
+	for _, id := range []string{
+		"10.5061/Dryad.ABC",
+		"doi:10.5061/dryad.abc",
+		"https://doi.org/10.5061/dryad.abc",
+		"http://dx.doi.org/10.5061/DRYAD.abc",
+		" info:doi/10.5061/dryad.abc ",
+	} {
+		if got, ok := identifier.NormalizeDOI(id); !ok || got != "https://doi.org/10.5061/dryad.abc" {
+			t.Errorf("NormalizeDOI(%q) = %s, %t", id, got, ok)
+		}
+	}
+	for _, id := range []string{"", "10.5061", "11.5061/abc", "https://example.org/10.5061/abc", "10./abc"} {
+		if got, ok := identifier.NormalizeDOI(id); ok {
+			t.Errorf("NormalizeDOI(%q) = %s, want rejection", id, got)
+		}
+	}
+}
+
+func TestNormalizeROR(t *testing.T) {
+	for _, id := range []string{"038sjwq14", "https://ror.org/038sjwq14", " ROR.org/038SJWQ14"} {
+		if got, ok := identifier.NormalizeROR(id); !ok || got != "https://ror.org/038sjwq14" {
+			t.Errorf("NormalizeROR(%q) = %s, %t", id, got, ok)
+		}
+	}
+	for _, id := range []string{"", "38sjwq14", "038sjwq1", "038sjwql4", "https://example.org/038sjwq14"} {
+		if got, ok := identifier.NormalizeROR(id); ok {
+			t.Errorf("NormalizeROR(%q) = %s, want rejection", id, got)
+		}
+	}
+}
+
+func TestValidateBaseURL(t *testing.T) {
+	for _, base := range []string{"https://raid.org/", "http://localhost:8080/ids"} {
+		if err := identifier.ValidateBaseURL(base); err != nil {
+			t.Errorf("ValidateBaseURL(%q): %v", base, err)
+		}
+	}
+	for _, base := range []string{"raid.org", "ftp://raid.org/", "https://raid.org/?x=1", "https:///path"} {
+		if err := identifier.ValidateBaseURL(base); err == nil {
+			t.Errorf("ValidateBaseURL(%q): expected an error", base)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	mock := testutil.NewMockRepository()
+	repo := identifier.Wrap(mock, "https://registry.example.org/raid/")
+
+	created, err := repo.CreateRAiD(context.Background(), &models.RAiD{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Identifier.ID, "https://registry.example.org/raid/10.12345/") || created.Identifier.SchemaURI != "https://registry.example.org/raid/" {
+		t.Errorf("unexpected identifier %+v", created.Identifier)
+	}
+
+	supplied := &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.1/x"}}
+	if created, _ := repo.CreateRAiD(context.Background(), supplied); created.Identifier.ID != "https://raid.org/10.1/x" {
+		t.Errorf("expected a supplied identifier to be kept, got %s", created.Identifier.ID)
+	}
+}
+
+func TestWrap_Collisions(t *testing.T) {
+	mock := testutil.NewMockRepository()
+	n := 0
+	mock.GenerateIdentifierFunc = func(context.Context, int64) (string, string, error) {
+		n++
+		return "10.12345", strconv.Itoa(n), nil
+	}
+	taken := map[string]bool{"https://registry.example.org/10.12345/1": true, "https://registry.example.org/10.12345/2": true}
+	mock.CreateRAiDFunc = func(_ context.Context, raid *models.RAiD) (*models.RAiD, error) {
+		if taken[raid.Identifier.ID] {
+			return nil, storage.ErrAlreadyExists
+		}
+		return raid, nil
+	}
+	repo := identifier.Wrap(mock, "https://registry.example.org/")
+
+	created, err := repo.CreateRAiD(context.Background(), &models.RAiD{})
+	if err != nil || created.Identifier.ID != "https://registry.example.org/10.12345/3" {
+		t.Fatalf("expected the first free identifier, got %v, %v", created, err)
+	}
+
+	mock.CreateRAiDFunc = func(context.Context, *models.RAiD) (*models.RAiD, error) { return nil, storage.ErrAlreadyExists }
+	n = 0
+	raid := &models.RAiD{}
+	if _, err := repo.CreateRAiD(context.Background(), raid); !errors.Is(err, storage.ErrIdentifiersExhausted) {
+		t.Errorf("expected ErrIdentifiersExhausted, got %v", err)
+	}
+	if n != storage.MintAttempts || raid.Identifier.ID != "" {
+		t.Errorf("expected %d attempts and no identifier left on the RAiD, got %d and %q", storage.MintAttempts, n, raid.Identifier.ID)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cursor := identifier.EncodeCursor("10.25.1.1", "42")
+	if prefix, suffix, err := identifier.ParseCursor(cursor); err != nil || prefix != "10.25.1.1" || suffix != "42" {
+		t.Errorf("ParseCursor(%q) = %s, %s, %v", cursor, prefix, suffix, err)
+	}
+	for _, bad := range []string{"not base64!", identifier.EncodeCursor("", "42")} {
+		if _, _, err := identifier.ParseCursor(bad); err == nil {
+			t.Errorf("ParseCursor(%q): expected an error", bad)
+		}
+	}
+
+	raid := func(id string) *models.RAiD { return &models.RAiD{Identifier: &models.Identifier{ID: id}} }
+	raids := []*models.RAiD{raid("https://raid.org/10.1/a"), raid("https://raid.org/10.1/b"), raid("https://raid.org/10.2/a")}
+	if next := identifier.NextCursor(raids[:1]); next != identifier.EncodeCursor("10.1", "a") {
+		t.Errorf("unexpected next cursor %q", next)
+	}
+	after := identifier.AfterCursor(raids, identifier.EncodeCursor("10.1", "a"))
+	if len(after) != 2 || after[0] != raids[1] || after[1] != raids[2] {
+		t.Errorf("expected the RAiDs after 10.1/a, got %d", len(after))
+	}
+}
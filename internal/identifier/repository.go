@@ -0,0 +1,34 @@
+package identifier
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that mints identifiers under baseURL instead of
+// the backend's default
+func Wrap(repo storage.Repository, baseURL string) storage.Repository {
+	return &repository{Repository: repo, baseURL: baseURL}
+}
+
+type repository struct {
+	storage.Repository
+	baseURL string
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier != nil && raid.Identifier.ID != "" {
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	if raid.Identifier == nil {
+		raid.Identifier = &models.Identifier{}
+	}
+	if raid.Identifier.SchemaURI == "" {
+		raid.Identifier.SchemaURI = r.baseURL
+	}
+	return storage.MintGenerated(ctx, r.Repository, raid, func(prefix, suffix string) string {
+		return Format(r.baseURL, prefix, suffix)
+	})
+}
@@ -0,0 +1,87 @@
+package identifier
+
+import (
+	"context"
+	"crypto/rand"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// SuffixCounter numbers suffixes per prefix, or uses timestamps with
+	// file storage; the default
+	SuffixCounter = "counter"
+	// SuffixULID mints ULID suffixes, which sort by mint time
+	SuffixULID = "ulid"
+)
+
+// crockford is the Crockford base32 alphabet ULIDs are written in
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID for time t: 10 characters of milliseconds since
+// the epoch followed by 16 random characters, so ULIDs sort by time
+func NewULID(t time.Time) string {
+	var entropy [10]byte
+	rand.Read(entropy[:])
+	return encodeULID(t, entropy)
+}
+
+// ULIDLowerBound returns the smallest ULID for time t. Every ULID minted at
+// or after t sorts at or after it, including with a check character
+// appended, so a scan of ULID suffixes from it finds the RAiDs minted since.
+func ULIDLowerBound(t time.Time) string {
+	return encodeULID(t, [10]byte{})
+}
+
+func encodeULID(t time.Time, entropy [10]byte) string {
+	var b [26]byte
+	ms := uint64(max(t.UnixMilli(), 0))
+	for i := 9; i >= 0; i-- {
+		b[i] = crockford[ms&31]
+		ms >>= 5
+	}
+	// 80 bits of entropy, in two halves of 40
+	for half := 0; half < 2; half++ {
+		var bits uint64
+		for _, c := range entropy[half*5 : half*5+5] {
+			bits = bits<<8 | uint64(c)
+		}
+		for i := 7; i >= 0; i-- {
+			b[10+half*8+i] = crockford[bits&31]
+			bits >>= 5
+		}
+	}
+	return string(b[:])
+}
+
+// WrapULID returns a repository that mints ULID suffixes instead of the
+// backend's. Prefixes are picked from the minting service point as the
+// backends do.
+func WrapULID(repo storage.Repository) storage.Repository {
+	return &ulidRepository{Repository: repo}
+}
+
+type ulidRepository struct {
+	storage.Repository
+	prefixes storage.PrefixAllocator
+}
+
+func (r *ulidRepository) GenerateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
+	var sp *models.ServicePoint
+	if servicePointID > 0 {
+		if loaded, err := r.Repository.GetServicePoint(ctx, servicePointID); err == nil {
+			sp = loaded
+		}
+	}
+	return r.prefixes.Allocate(ctx, sp), NewULID(time.Now()), nil
+}
+
+func (r *ulidRepository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier != nil && raid.Identifier.ID != "" {
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	// Minted here, as the backend would generate a suffix of its own
+	return storage.MintGenerated(ctx, r, raid, FormatDefault)
+}
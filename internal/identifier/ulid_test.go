@@ -0,0 +1,51 @@
+package identifier_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestULID(t *testing.T) {
+	// The example of the ULID specification
+	mint := time.UnixMilli(1469918176385)
+	id := identifier.NewULID(mint)
+	if len(id) != 26 {
+		t.Fatalf("expected 26 characters, got %q", id)
+	}
+	if id[:10] != "01ARYZ6S41" {
+		t.Errorf("unexpected time part %q", id[:10])
+	}
+	if bound := identifier.ULIDLowerBound(mint); bound > id || bound != "01ARYZ6S410000000000000000" {
+		t.Errorf("expected lower bound %q to sort before %q", bound, id)
+	}
+	if later := identifier.NewULID(mint.Add(time.Millisecond)); later <= id {
+		t.Errorf("expected %q minted later to sort after %q", later, id)
+	}
+	if identifier.NewULID(mint) == id {
+		t.Error("expected ULIDs minted at the same time to differ")
+	}
+}
+
+func TestWrapULID(t *testing.T) {
+	mock := testutil.NewMockRepository()
+	mock.GetServicePointFunc = func(_ context.Context, id int64) (*models.ServicePoint, error) {
+		return &models.ServicePoint{ID: id, Prefix: "10.55555"}, nil
+	}
+	repo := identifier.WrapULID(mock)
+
+	prefix, suffix, err := repo.GenerateIdentifier(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "10.55555" || len(suffix) != 26 {
+		t.Errorf("expected a ULID under the service point's prefix, got %s/%s", prefix, suffix)
+	}
+	if mock.GenerateIdentifierCalls != 0 {
+		t.Errorf("expected the backend's suffixes to be left alone, got %d calls", mock.GenerateIdentifierCalls)
+	}
+}
@@ -0,0 +1,57 @@
+package invitation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSigner(t *testing.T) {
+	s := NewSigner("secret", time.Hour)
+	inv := &Invitation{Prefix: "10.1", Suffix: "a", Contributor: "c-uuid", Email: "j@example.org"}
+	token, err := s.Sign(inv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Verify(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != *inv {
+		t.Errorf("expected %+v, got %+v", inv, got)
+	}
+
+	if _, err := NewSigner("other", time.Hour).Verify(token); err != ErrInvalid {
+		t.Errorf("expected ErrInvalid for another secret, got %v", err)
+	}
+	if _, err := s.Verify(token[:len(token)-2]); err != ErrInvalid {
+		t.Errorf("expected ErrInvalid for a damaged token, got %v", err)
+	}
+
+	expired := NewSigner("secret", time.Hour)
+	expired.ttl = -time.Hour
+	token, err = expired.Sign(&Invitation{Contributor: "c-uuid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token); err != ErrExpired {
+		t.Errorf("expected ErrExpired, got %v", err)
+	}
+}
+
+func TestParseORCID(t *testing.T) {
+	for in, want := range map[string]string{
+		"0000-0002-1825-0097":                    "https://orcid.org/0000-0002-1825-0097",
+		" https://orcid.org/0000-0001-5109-3700": "https://orcid.org/0000-0001-5109-3700",
+		"orcid.org/0000-0002-1694-233x":          "https://orcid.org/0000-0002-1694-233X",
+	} {
+		got, err := ParseORCID(in)
+		if err != nil || got != want {
+			t.Errorf("ParseORCID(%q) = %q, %v, want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "0000-0002-1825-0098", "0000-0002-1825", "https://example.org/0000-0002-1825-0097"} {
+		if _, err := ParseORCID(in); err == nil {
+			t.Errorf("ParseORCID(%q): expected an error", in)
+		}
+	}
+}
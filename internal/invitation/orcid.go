@@ -0,0 +1,40 @@
+package invitation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ORCIDBase is the URI ORCID iDs are written under
+const ORCIDBase = "https://orcid.org/"
+
+var orcidPattern = regexp.MustCompile(`^[0-9]{4}-[0-9]{4}-[0-9]{4}-[0-9]{3}[0-9X]$`)
+
+// ParseORCID returns the ORCID iD in s, written bare or as a URI, as a URI.
+// The check character is verified (ISO 7064 MOD 11-2).
+func ParseORCID(s string) (string, error) {
+	id := strings.TrimSpace(s)
+	for _, base := range []string{ORCIDBase, "http://orcid.org/", "orcid.org/"} {
+		id = strings.TrimPrefix(id, base)
+	}
+	id = strings.ToUpper(id)
+	if !orcidPattern.MatchString(id) {
+		return "", fmt.Errorf("%q is not an ORCID iD", s)
+	}
+
+	total := 0
+	digits := strings.ReplaceAll(id, "-", "")
+	for _, c := range digits[:15] {
+		total = (total + int(c-'0')) * 2
+	}
+	check := (12 - total%11) % 11
+	want := byte('0' + check)
+	if check == 10 {
+		want = 'X'
+	}
+	if digits[15] != want {
+		return "", fmt.Errorf("%q is not an ORCID iD: check character does not match", s)
+	}
+	return ORCIDBase + id, nil
+}
@@ -0,0 +1,244 @@
+// Package jsonpatch computes JSON Patch (RFC 6902) documents describing the
+// difference between two JSON documents and applies them.
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operation is a single JSON Patch operation
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Diff returns the operations that turn the JSON document from into to.
+// Objects are compared member by member and arrays element by element, with
+// elements appended or removed at the end; any other change replaces the
+// value. Members are visited in sorted order so the result is stable.
+func Diff(from, to []byte) ([]Operation, error) {
+	a, err := decode(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decode(to)
+	if err != nil {
+		return nil, err
+	}
+	ops := []Operation{}
+	if err := diff(&ops, "", a, b); err != nil {
+		return nil, err
+	}
+	return ops, nil
+}
+
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func diff(ops *[]Operation, path string, a, b any) error {
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			return diffObjects(ops, path, av, bv)
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			return diffArrays(ops, path, av, bv)
+		}
+	}
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+	return appendOp(ops, "replace", path, b)
+}
+
+func diffObjects(ops *[]Operation, path string, a, b map[string]any) error {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escape(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inB:
+			*ops = append(*ops, Operation{Op: "remove", Path: p})
+		case !inA:
+			if err := appendOp(ops, "add", p, bv); err != nil {
+				return err
+			}
+		default:
+			if err := diff(ops, p, av, bv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func diffArrays(ops *[]Operation, path string, a, b []any) error {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if err := diff(ops, path+"/"+strconv.Itoa(i), a[i], b[i]); err != nil {
+			return err
+		}
+	}
+	for i := n; i < len(b); i++ {
+		if err := appendOp(ops, "add", path+"/"+strconv.Itoa(i), b[i]); err != nil {
+			return err
+		}
+	}
+	// Remove from the end so that earlier indices stay valid
+	for i := len(a) - 1; i >= n; i-- {
+		*ops = append(*ops, Operation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+	}
+	return nil
+}
+
+func appendOp(ops *[]Operation, op, path string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	*ops = append(*ops, Operation{Op: op, Path: path, Value: data})
+	return nil
+}
+
+// escape encodes a member name as a JSON Pointer reference token
+func escape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// unescape decodes a JSON Pointer reference token
+func unescape(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+}
+
+// Apply returns doc with the add, remove and replace operations of ops
+// applied in order, as produced by Diff
+func Apply(doc []byte, ops []Operation) ([]byte, error) {
+	v, err := decode(doc)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		var value any
+		if op.Op != "remove" {
+			if value, err = decode(op.Value); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+			}
+		}
+		if v, err = apply(v, op.Op, pointer(op.Path), value); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+	return json.Marshal(v)
+}
+
+// pointer splits a JSON Pointer into its unescaped reference tokens
+func pointer(path string) []string {
+	if path == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = unescape(t)
+	}
+	return tokens
+}
+
+// apply performs op at the location tokens within v and returns the result
+func apply(v any, op string, tokens []string, value any) (any, error) {
+	if len(tokens) == 0 {
+		if op == "remove" {
+			return nil, fmt.Errorf("cannot remove the document")
+		}
+		return value, nil
+	}
+	token, rest := tokens[0], tokens[1:]
+
+	switch container := v.(type) {
+	case map[string]any:
+		if len(rest) > 0 {
+			child, ok := container[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", token)
+			}
+			child, err := apply(child, op, rest, value)
+			if err != nil {
+				return nil, err
+			}
+			container[token] = child
+			return container, nil
+		}
+		_, exists := container[token]
+		switch {
+		case op == "add":
+			container[token] = value
+		case !exists:
+			return nil, fmt.Errorf("member %q not found", token)
+		case op == "remove":
+			delete(container, token)
+		case op == "replace":
+			container[token] = value
+		default:
+			return nil, fmt.Errorf("unsupported operation %q", op)
+		}
+		return container, nil
+	case []any:
+		i := len(container)
+		if token != "-" {
+			n, err := strconv.Atoi(token)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid array index %q", token)
+			}
+			i = n
+		}
+		if len(rest) > 0 {
+			if i >= len(container) {
+				return nil, fmt.Errorf("array index %d out of range", i)
+			}
+			child, err := apply(container[i], op, rest, value)
+			if err != nil {
+				return nil, err
+			}
+			container[i] = child
+			return container, nil
+		}
+		switch {
+		case op == "add" && i <= len(container):
+			return append(container[:i], append([]any{value}, container[i:]...)...), nil
+		case i >= len(container):
+			return nil, fmt.Errorf("array index %d out of range", i)
+		case op == "remove":
+			return append(container[:i], container[i+1:]...), nil
+		case op == "replace":
+			container[i] = value
+			return container, nil
+		}
+		return nil, fmt.Errorf("unsupported operation %q", op)
+	}
+	return nil, fmt.Errorf("cannot address %q in a scalar", token)
+}
@@ -0,0 +1,95 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{"equal", `{"a":1}`, `{"a":1}`, `[]`},
+		{"add member", `{}`, `{"a":{"b":false}}`, `[{"op":"add","path":"/a","value":{"b":false}}]`},
+		{"remove member", `{"a":1,"b":2}`, `{"a":1}`, `[{"op":"remove","path":"/b"}]`},
+		{"replace value", `{"a":{"b":"x"}}`, `{"a":{"b":"y"}}`, `[{"op":"replace","path":"/a/b","value":"y"}]`},
+		{"replace type", `{"a":[1]}`, `{"a":{"b":1}}`, `[{"op":"replace","path":"/a","value":{"b":1}}]`},
+		{"append elements", `{"a":[1]}`, `{"a":[1,2,3]}`, `[{"op":"add","path":"/a/1","value":2},{"op":"add","path":"/a/2","value":3}]`},
+		{"truncate array", `{"a":[1,2,3]}`, `{"a":[1]}`, `[{"op":"remove","path":"/a/2"},{"op":"remove","path":"/a/1"}]`},
+		{"escaped names", `{}`, `{"a/b~c":null}`, `[{"op":"add","path":"/a~1b~0c","value":null}]`},
+		{"large numbers", `{"n":12345678901234567890}`, `{"n":12345678901234567891}`, `[{"op":"replace","path":"/n","value":12345678901234567891}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := Diff([]byte(tt.from), []byte(tt.to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := json.Marshal(ops)
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiff_InvalidJSON(t *testing.T) {
+	if _, err := Diff([]byte(`{`), []byte(`{}`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestApply(t *testing.T) {
+	docs := []string{
+		`{}`,
+		`{"a":1}`,
+		`{"a":{"b":false},"c":[1,2,3]}`,
+		`{"a":{"b":"y"},"c":[1],"d":"x"}`,
+		`{"a/b~c":null,"c":[{"x":1},{"y":2}],"n":12345678901234567890}`,
+		`{"c":{"b":1},"n":12345678901234567891}`,
+	}
+	for _, from := range docs {
+		for _, to := range docs {
+			ops, err := Diff([]byte(from), []byte(to))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Apply([]byte(from), ops)
+			if err != nil {
+				t.Fatalf("apply %s to %s: %v", to, from, err)
+			}
+			want, _ := Apply([]byte(to), nil)
+			if string(got) != string(want) {
+				t.Errorf("apply diff of %s to %s: got %s", to, from, got)
+			}
+		}
+	}
+}
+
+func TestApply_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  string
+	}{
+		{"missing member", `[{"op":"replace","path":"/x","value":1}]`},
+		{"missing parent", `[{"op":"add","path":"/x/y","value":1}]`},
+		{"index out of range", `[{"op":"remove","path":"/a/5"}]`},
+		{"scalar", `[{"op":"add","path":"/b/c","value":1}]`},
+		{"unsupported", `[{"op":"move","path":"/b"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []Operation
+			if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Apply([]byte(`{"a":[1],"b":true}`), ops); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
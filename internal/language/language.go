@@ -0,0 +1,83 @@
+// Package language guesses the language of title and description text by
+// counting common function words, so that RAiDs minted without languages
+// still carry one for indexing and multilingual display.
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SchemaURI is the schema URI of ISO 639-3 language codes
+const SchemaURI = "https://www.iso.org/standard/39534.html"
+
+// minHits is the number of function words a text must contain before its
+// language is guessed
+const minHits = 2
+
+// stopwords lists common function words by ISO 639-3 code. A word listed
+// for several languages counts towards each of them in part.
+var stopwords = map[string][]string{
+	"eng": {"the", "of", "and", "to", "in", "is", "for", "on", "with", "by", "from", "as", "at", "this", "that", "are", "an", "be", "its", "their", "which", "into", "through", "between"},
+	"deu": {"der", "die", "das", "und", "von", "zu", "mit", "den", "im", "für", "ist", "des", "dem", "auf", "eine", "ein", "einer", "sich", "nicht", "auch", "über", "zur", "zum", "bei"},
+	"fra": {"le", "la", "les", "et", "des", "du", "un", "une", "pour", "dans", "sur", "au", "aux", "est", "par", "avec", "qui", "que", "ce", "ces", "leur", "entre"},
+	"spa": {"el", "los", "las", "y", "del", "por", "para", "una", "con", "es", "al", "se", "su", "sus", "como", "entre", "sobre", "más"},
+	"ita": {"il", "lo", "gli", "di", "e", "della", "delle", "dei", "per", "una", "con", "nel", "nella", "sono", "alla", "tra", "sul"},
+	"por": {"o", "os", "as", "e", "do", "da", "dos", "das", "em", "um", "uma", "para", "com", "não", "na", "no", "ao", "pelo", "pela", "entre"},
+	"nld": {"de", "het", "een", "en", "van", "voor", "met", "op", "zijn", "niet", "aan", "bij", "naar", "wordt", "ook", "tussen"},
+	"swe": {"och", "att", "det", "som", "är", "för", "på", "med", "av", "till", "den", "inte", "om", "ett", "hos", "mellan"},
+	"fin": {"ja", "on", "ei", "että", "se", "kuin", "myös", "sekä", "tai", "ovat", "mutta", "kanssa", "välillä"},
+	"pol": {"i", "w", "z", "na", "się", "do", "nie", "że", "jest", "oraz", "dla", "od", "przez", "po", "jak", "między"},
+}
+
+// index maps each function word to the languages it belongs to
+var index = func() map[string][]string {
+	idx := make(map[string][]string)
+	for code, words := range stopwords {
+		for _, w := range words {
+			idx[w] = append(idx[w], code)
+		}
+	}
+	return idx
+}()
+
+// Detect returns the ISO 639-3 code of the language of text. It returns
+// false when the text has too few function words or they do not favour one
+// language, which is common for short titles.
+func Detect(text string) (string, bool) {
+	hits := make(map[string]float64)
+	total := 0
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	}) {
+		langs := index[word]
+		if len(langs) == 0 {
+			continue
+		}
+		total++
+		// A word shared by several languages counts as a fraction for each
+		for _, code := range langs {
+			hits[code] += 1 / float64(len(langs))
+		}
+	}
+	if total < minHits {
+		return "", false
+	}
+
+	best, second := "", 0.0
+	for code, n := range hits {
+		switch {
+		case best == "" || n > hits[best] || n == hits[best] && code < best:
+			if best != "" {
+				second = max(second, hits[best])
+			}
+			best = code
+		default:
+			second = max(second, n)
+		}
+	}
+	if hits[best] < minHits || hits[best] <= second {
+		return "", false
+	}
+	return best, true
+}
@@ -0,0 +1,113 @@
+package language
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestDetect(t *testing.T) {
+	for text, want := range map[string]string{
+		"The impact of climate change on the coastal ecosystems of the Pacific":              "eng",
+		"Die Auswirkungen des Klimawandels auf die Küstenökosysteme und ihre Bewohner":       "deu",
+		"L'impact du changement climatique sur les écosystèmes côtiers et leur biodiversité": "fra",
+		"El impacto del cambio climático en los ecosistemas costeros y sus habitantes":       "spa",
+		"Effekterna av klimatförändringar på kustnära ekosystem och deras invånare":          "swe",
+	} {
+		if got, ok := Detect(text); !ok || got != want {
+			t.Errorf("Detect(%q) = %s, %t; want %s", text, got, ok, want)
+		}
+	}
+	for _, text := range []string{"", "Coastal ecosystems", "COVID-19 genomics"} {
+		if got, ok := Detect(text); ok {
+			t.Errorf("Detect(%q) = %s; want no guess", text, got)
+		}
+	}
+}
+
+func TestFill(t *testing.T) {
+	given := &models.Language{ID: "deu", SchemaURI: SchemaURI}
+	raid := &models.RAiD{
+		Title: []models.Title{
+			{Text: "The history of the harbour and its people"},
+			{Text: "The history of the harbour and its people", Language: given},
+			{Text: "Harbour"},
+		},
+		Description: []models.Description{{Text: "Une étude de la ville et des habitants du port"}},
+	}
+	Fill(raid)
+
+	if l := raid.Title[0].Language; l == nil || l.ID != "eng" || !l.AutoDetected {
+		t.Errorf("expected English to be detected, got %+v", l)
+	}
+	if raid.Title[1].Language != given {
+		t.Error("expected a given language to be kept")
+	}
+	if raid.Title[2].Language != nil {
+		t.Errorf("expected no guess for a one-word title, got %+v", raid.Title[2].Language)
+	}
+	if l := raid.Description[0].Language; l == nil || l.ID != "fra" {
+		t.Errorf("expected French to be detected, got %+v", l)
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	for list, want := range map[string][]string{
+		"fr-CA, en;q=0.5, de;q=0.8": {"fra", "deu", "eng"},
+		"swe,eng":                   {"swe", "eng"},
+		"*, xx, en;q=0, mi":         {"mri"},
+		"":                          {},
+	} {
+		if got := Preferences(list); !slices.Equal(got, want) {
+			t.Errorf("Preferences(%q) = %v; want %v", list, got, want)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	primary := &models.IDSchema{ID: models.TitleTypePrimary}
+	lang := func(code string) *models.Language { return &models.Language{ID: code, SchemaURI: SchemaURI} }
+	raid := &models.RAiD{
+		Title: []models.Title{
+			{Text: "Harbour history", Type: primary, Language: lang("eng")},
+			{Text: "Hamnens historia", Type: primary, Language: lang("swe")},
+			{Text: "Old harbour", Type: primary, StartDate: "2020-01-01", EndDate: "2020-12-31"},
+		},
+		Description: []models.Description{
+			{Text: "A study of the harbour", Language: lang("eng")},
+			{Text: "Une étude du port", Language: lang("fra")},
+		},
+		Subject: []models.Subject{{ID: "4611", Keyword: []models.SubjectKeyword{
+			{Text: "harbour", Language: lang("eng")},
+			{Text: "hamn", Language: lang("swe")},
+			{Text: "port", Language: lang("swe")},
+		}}},
+	}
+	texts := func(r *models.RAiD) []string {
+		var out []string
+		for _, t := range r.Title {
+			out = append(out, t.Text)
+		}
+		for _, d := range r.Description {
+			out = append(out, d.Text)
+		}
+		for _, k := range r.Subject[0].Keyword {
+			out = append(out, k.Text)
+		}
+		return out
+	}
+
+	for prefs, want := range map[string][]string{
+		"swe,fra": {"Hamnens historia", "Old harbour", "Une étude du port", "hamn", "port"},
+		"fra":     {"Harbour history", "Old harbour", "Une étude du port", "harbour"},
+		"deu":     {"Harbour history", "Old harbour", "A study of the harbour", "harbour"},
+	} {
+		if got := texts(Select(raid, Preferences(prefs))); !slices.Equal(got, want) {
+			t.Errorf("Select(%s) = %v; want %v", prefs, got, want)
+		}
+	}
+	if len(raid.Title) != 3 || len(raid.Subject[0].Keyword) != 3 {
+		t.Error("expected the record not to be changed")
+	}
+}
@@ -0,0 +1,52 @@
+package language
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that fills in the language of titles and
+// descriptions minted or updated without one, where Detect can tell it.
+// Detected languages are marked AutoDetected.
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	Fill(raid)
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	Fill(raid)
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
+
+// Fill sets the language of the titles and descriptions of raid that have
+// none, where it can be detected
+func Fill(raid *models.RAiD) {
+	for i := range raid.Title {
+		if raid.Title[i].Language == nil {
+			raid.Title[i].Language = detect(raid.Title[i].Text)
+		}
+	}
+	for i := range raid.Description {
+		if raid.Description[i].Language == nil {
+			raid.Description[i].Language = detect(raid.Description[i].Text)
+		}
+	}
+}
+
+func detect(text string) *models.Language {
+	code, ok := Detect(text)
+	if !ok {
+		return nil
+	}
+	return &models.Language{ID: code, SchemaURI: SchemaURI, AutoDetected: true}
+}
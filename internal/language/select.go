@@ -0,0 +1,172 @@
+package language
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// iso6391 maps ISO 639-1 codes, as used by Accept-Language, to the ISO
+// 639-3 codes of RAiD metadata
+var iso6391 = map[string]string{
+	"ar": "ara", "bg": "bul", "bn": "ben", "ca": "cat", "cs": "ces", "cy": "cym",
+	"da": "dan", "de": "deu", "el": "ell", "en": "eng", "es": "spa", "et": "est",
+	"eu": "eus", "fa": "fas", "fi": "fin", "fr": "fra", "ga": "gle", "he": "heb",
+	"hi": "hin", "hr": "hrv", "hu": "hun", "id": "ind", "is": "isl", "it": "ita",
+	"ja": "jpn", "ko": "kor", "lt": "lit", "lv": "lav", "mi": "mri", "ms": "msa",
+	"mt": "mlt", "nb": "nob", "nl": "nld", "nn": "nno", "no": "nor", "pl": "pol",
+	"pt": "por", "ro": "ron", "ru": "rus", "se": "sme", "sk": "slk", "sl": "slv",
+	"sr": "srp", "sv": "swe", "sw": "swa", "th": "tha", "tr": "tur", "uk": "ukr",
+	"vi": "vie", "zh": "zho",
+}
+
+// Tag returns the language tag of an ISO 639-3 code for headers such as
+// Content-Language: its ISO 639-1 code where there is one, else the code
+func Tag(code string) string {
+	for tag, c := range iso6391 {
+		if c == code {
+			return tag
+		}
+	}
+	return code
+}
+
+// Preferences returns the ISO 639-3 codes of the languages list asks for,
+// most preferred first. list is an Accept-Language header or a
+// comma-separated list of codes; ISO 639-1 codes are mapped and region
+// subtags dropped, so "fr-CA, en;q=0.5" gives fra, eng. Wildcards and
+// unknown codes are left out.
+func Preferences(list string) []string {
+	type pref struct {
+		code string
+		q    float64
+	}
+	var prefs []pref
+	for _, part := range strings.Split(list, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		code, ok := iso6391[primary]
+		if !ok && len(primary) == 3 {
+			code, ok = primary, true
+		}
+		if ok && q > 0 {
+			prefs = append(prefs, pref{code, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	codes := make([]string, 0, len(prefs))
+	for _, p := range prefs {
+		if !slices.Contains(codes, p.code) {
+			codes = append(codes, p.code)
+		}
+	}
+	return codes
+}
+
+// Select returns a copy of raid keeping, of the titles, descriptions and
+// subject keywords that translate each other, those in the most preferred
+// language available. Titles of the same type and dates translate each
+// other, as do descriptions of the same type and the keywords of a
+// subject. The fallback chain is prefs in order, then text without a
+// language, then the language of the first of the alternatives. raid is
+// not changed.
+func Select(raid *models.RAiD, prefs []string) *models.RAiD {
+	selected := *raid
+
+	titles := raid.Title
+	keep := choose(len(titles), prefs, func(i int) (string, *models.Language) {
+		t := titles[i]
+		return typeOf(t.Type) + "|" + t.StartDate + "|" + t.EndDate, t.Language
+	})
+	selected.Title = kept(titles, keep)
+
+	descriptions := raid.Description
+	keep = choose(len(descriptions), prefs, func(i int) (string, *models.Language) {
+		return typeOf(descriptions[i].Type), descriptions[i].Language
+	})
+	selected.Description = kept(descriptions, keep)
+
+	if raid.Subject != nil {
+		selected.Subject = make([]models.Subject, len(raid.Subject))
+		for i, s := range raid.Subject {
+			keywords := s.Keyword
+			s.Keyword = kept(keywords, choose(len(keywords), prefs, func(j int) (string, *models.Language) {
+				return "", keywords[j].Language
+			}))
+			selected.Subject[i] = s
+		}
+	}
+	return &selected
+}
+
+// kept returns a new slice of the items marked in keep, or nil for nil
+// items
+func kept[T any](items []T, keep []bool) []T {
+	if items == nil {
+		return nil
+	}
+	out := make([]T, 0, len(items))
+	for i, item := range items {
+		if keep[i] {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// choose reports which of n items to keep: in each group of alternatives,
+// as returned by item, those in the language first in the fallback chain
+func choose(n int, prefs []string, item func(int) (string, *models.Language)) []bool {
+	rank := func(lang string) int {
+		for i, p := range prefs {
+			if p == lang {
+				return i
+			}
+		}
+		if lang == "" {
+			return len(prefs)
+		}
+		return len(prefs) + 1
+	}
+
+	groups := make([]string, n)
+	langs := make([]string, n)
+	best := make(map[string]int)
+	first := make(map[string]string)
+	for i := range n {
+		group, lang := item(i)
+		groups[i] = group
+		if lang != nil {
+			langs[i] = strings.ToLower(lang.ID)
+		}
+		if _, ok := first[group]; !ok {
+			first[group], best[group] = langs[i], rank(langs[i])
+		}
+		best[group] = min(best[group], rank(langs[i]))
+	}
+
+	keep := make([]bool, n)
+	for i := range n {
+		r := rank(langs[i])
+		keep[i] = r == best[groups[i]] && (r <= len(prefs) || langs[i] == first[groups[i]])
+	}
+	return keep
+}
+
+func typeOf(t *models.IDSchema) string {
+	if t == nil {
+		return ""
+	}
+	return t.ID
+}
@@ -0,0 +1,305 @@
+// Package loadgen drives a mix of mint, read and update requests against a
+// go-RAiD server and reports throughput and latency per operation, to check
+// how a deployment and its storage backend scale under load.
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/seed"
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+// Operations in a mix
+const (
+	OpMint   = "mint"
+	OpRead   = "read"
+	OpUpdate = "update"
+)
+
+// Mix is the relative weight of each operation
+type Mix struct {
+	Mint   int
+	Read   int
+	Update int
+}
+
+// DefaultMix is a read-heavy mix typical of a production registry
+var DefaultMix = Mix{Mint: 10, Read: 80, Update: 10}
+
+// ParseMix parses a mix such as "mint=10,read=80,update=10"; operations
+// that are not listed get weight zero
+func ParseMix(s string) (Mix, error) {
+	var m Mix
+	for _, part := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		weight, err := strconv.Atoi(value)
+		if !ok || err != nil || weight < 0 {
+			return Mix{}, fmt.Errorf("invalid mix entry %q: expected OP=WEIGHT", part)
+		}
+		switch name {
+		case OpMint:
+			m.Mint = weight
+		case OpRead:
+			m.Read = weight
+		case OpUpdate:
+			m.Update = weight
+		default:
+			return Mix{}, fmt.Errorf("unknown operation %q in mix", name)
+		}
+	}
+	if m.Mint+m.Read+m.Update == 0 {
+		return Mix{}, fmt.Errorf("mix has no operations")
+	}
+	return m, nil
+}
+
+// pick chooses an operation for a random number in [0, total)
+func (m Mix) pick(n int) string {
+	switch {
+	case n < m.Mint:
+		return OpMint
+	case n < m.Mint+m.Read:
+		return OpRead
+	default:
+		return OpUpdate
+	}
+}
+
+// Options controls a load test
+type Options struct {
+	// Concurrency is the number of workers issuing requests
+	Concurrency int
+	// Duration bounds the test; zero runs until Requests are done
+	Duration time.Duration
+	// Requests bounds the number of operations; zero runs for Duration
+	Requests int
+	// Warmup is the number of RAiDs minted before measuring, so that reads
+	// and updates have targets
+	Warmup int
+	Mix    Mix
+	// Seed initialises the document generator
+	Seed int64
+	// ServicePoint owns the minted RAiDs
+	ServicePoint int64
+}
+
+// OpStats summarises one operation. Latencies are in milliseconds.
+type OpStats struct {
+	Count  int     `json:"count"`
+	Errors int     `json:"errors"`
+	P50    float64 `json:"p50Ms"`
+	P95    float64 `json:"p95Ms"`
+	P99    float64 `json:"p99Ms"`
+	Max    float64 `json:"maxMs"`
+}
+
+// Report is the outcome of a load test
+type Report struct {
+	Duration   time.Duration       `json:"-"`
+	Seconds    float64             `json:"seconds"`
+	Requests   int                 `json:"requests"`
+	Errors     int                 `json:"errors"`
+	Throughput float64             `json:"requestsPerSecond"`
+	Operations map[string]*OpStats `json:"operations"`
+	// FirstError is a sample error message, if any request failed
+	FirstError string `json:"firstError,omitempty"`
+}
+
+// sample is the result of one request
+type sample struct {
+	op      string
+	latency time.Duration
+	err     error
+}
+
+// maxUpdateAttempts bounds the retries of an update conflicting with
+// another worker's
+const maxUpdateAttempts = 5
+
+// runner holds the state shared by the workers
+type runner struct {
+	client *raid.Client
+	gen    *seed.Generator
+	sp     *models.ServicePoint
+
+	mu      sync.Mutex
+	handles []string
+}
+
+// Run executes a load test against client
+func Run(ctx context.Context, client *raid.Client, opts Options) (*Report, error) {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return nil, fmt.Errorf("either a duration or a number of requests is required")
+	}
+	if opts.Mix == (Mix{}) {
+		opts.Mix = DefaultMix
+	}
+	if opts.Mix.Mint == 0 && opts.Warmup == 0 {
+		return nil, fmt.Errorf("a mix without mints needs warmup RAiDs to read and update")
+	}
+
+	r := &runner{
+		client: client,
+		gen:    seed.NewGenerator(opts.Seed),
+		sp:     &models.ServicePoint{ID: opts.ServicePoint},
+	}
+	for i := 0; i < opts.Warmup; i++ {
+		if err := r.mint(ctx); err != nil {
+			return nil, fmt.Errorf("warmup: %w", err)
+		}
+	}
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	// A shared budget of requests; unlimited when only a duration is set
+	var budget chan struct{}
+	if opts.Requests > 0 {
+		budget = make(chan struct{}, opts.Requests)
+		for i := 0; i < opts.Requests; i++ {
+			budget <- struct{}{}
+		}
+		close(budget)
+	}
+
+	samples := make(chan sample, opts.Concurrency*16)
+	var wg sync.WaitGroup
+	start := time.Now()
+	total := opts.Mix.Mint + opts.Mix.Read + opts.Mix.Update
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		rnd := rand.New(rand.NewSource(opts.Seed + int64(w) + 1))
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				if budget != nil {
+					if _, ok := <-budget; !ok {
+						return
+					}
+				}
+				op := opts.Mix.pick(rnd.Intn(total))
+				began := time.Now()
+				err := r.do(ctx, op, rnd)
+				if ctx.Err() != nil {
+					// Requests cut off by the deadline are not counted
+					return
+				}
+				samples <- sample{op: op, latency: time.Since(began), err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(samples)
+	}()
+
+	report := &Report{Operations: map[string]*OpStats{}}
+	latencies := map[string][]time.Duration{}
+	for s := range samples {
+		stats := report.Operations[s.op]
+		if stats == nil {
+			stats = &OpStats{}
+			report.Operations[s.op] = stats
+		}
+		stats.Count++
+		report.Requests++
+		if s.err != nil {
+			stats.Errors++
+			report.Errors++
+			if report.FirstError == "" {
+				report.FirstError = fmt.Sprintf("%s: %v", s.op, s.err)
+			}
+			continue
+		}
+		latencies[s.op] = append(latencies[s.op], s.latency)
+	}
+
+	report.Duration = time.Since(start)
+	report.Seconds = report.Duration.Seconds()
+	if report.Seconds > 0 {
+		report.Throughput = float64(report.Requests) / report.Seconds
+	}
+	for op, l := range latencies {
+		slices.Sort(l)
+		stats := report.Operations[op]
+		stats.P50 = percentile(l, 0.50)
+		stats.P95 = percentile(l, 0.95)
+		stats.P99 = percentile(l, 0.99)
+		stats.Max = milliseconds(l[len(l)-1])
+	}
+	return report, nil
+}
+
+// do performs one operation
+func (r *runner) do(ctx context.Context, op string, rnd *rand.Rand) error {
+	if op == OpMint {
+		return r.mint(ctx)
+	}
+
+	r.mu.Lock()
+	if len(r.handles) == 0 {
+		r.mu.Unlock()
+		return r.mint(ctx)
+	}
+	handle := r.handles[rnd.Intn(len(r.handles))]
+	r.mu.Unlock()
+
+	prefix, suffix, err := raid.ParseHandle(handle)
+	if err != nil {
+		return err
+	}
+	// An update racing another worker's to the same RAiD is refused as
+	// made to a stale version, and is retried on the new one
+	for attempt := 0; ; attempt++ {
+		current, err := r.client.GetRAiD(ctx, prefix, suffix)
+		if err != nil || op == OpRead {
+			return err
+		}
+		r.gen.Mutate(current)
+		_, err = r.client.UpdateRAiD(ctx, prefix, suffix, current)
+		if !raid.IsConflict(err) || attempt == maxUpdateAttempts-1 {
+			return err
+		}
+	}
+}
+
+// mint creates a RAiD and remembers its identifier for reads and updates
+func (r *runner) mint(ctx context.Context) error {
+	created, err := r.client.MintRAiD(ctx, r.gen.RAiD(r.sp))
+	if err != nil {
+		return err
+	}
+	if created.Identifier == nil {
+		return fmt.Errorf("minted RAiD has no identifier")
+	}
+	r.gen.Remember(created.Identifier.ID)
+	r.mu.Lock()
+	r.handles = append(r.handles, created.Identifier.ID)
+	r.mu.Unlock()
+	return nil
+}
+
+// percentile returns the q-quantile of sorted latencies in milliseconds
+func percentile(sorted []time.Duration, q float64) float64 {
+	i := int(float64(len(sorted)-1) * q)
+	return milliseconds(sorted[i])
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,63 @@
+package loadgen
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/api"
+	"github.com/leifj/go-raid/internal/handlers"
+	"github.com/leifj/go-raid/internal/storage/file"
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+func TestParseMix(t *testing.T) {
+	m, err := ParseMix("mint=1, read=3")
+	if err != nil || m != (Mix{Mint: 1, Read: 3}) {
+		t.Errorf("unexpected mix %+v, %v", m, err)
+	}
+	for _, bad := range []string{"", "mint", "mint=-1", "delete=1", "read=0"} {
+		if _, err := ParseMix(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	r := chi.NewRouter()
+	r.Route(raid.APIVersion, func(r chi.Router) {
+		api.HandlerFromMux(api.NewServer(handlers.NewRAiDHandler(repo, handlers.DocumentLimits{}), handlers.NewServicePointHandler(repo)), r)
+	})
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	report, err := Run(context.Background(), raid.NewClient(srv.URL), Options{
+		Concurrency: 4,
+		Requests:    60,
+		Warmup:      5,
+		Mix:         Mix{Mint: 1, Read: 1, Update: 1},
+		Seed:        1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Requests != 60 || report.Errors != 0 {
+		t.Fatalf("expected 60 successful requests, got %d (%d errors: %s)", report.Requests, report.Errors, report.FirstError)
+	}
+	for _, op := range []string{OpMint, OpRead, OpUpdate} {
+		stats := report.Operations[op]
+		if stats == nil || stats.Count == 0 || stats.P50 > stats.P99 || stats.P99 > stats.Max {
+			t.Errorf("unexpected %s stats %+v", op, stats)
+		}
+	}
+
+	if _, err := Run(context.Background(), raid.NewClient(srv.URL), Options{Requests: 1, Mix: Mix{Read: 1}}); err == nil {
+		t.Error("expected an error for a read-only mix without warmup")
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
+	"github.com/leifj/go-raid/internal/accesslog"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// accessLogKey holds the *accessActor for the current request
+const accessLogKey contextKey = "accessLog"
+
+// accessActor collects the caller identity established further down the
+// middleware chain, which is not visible in the access log's own context
+type accessActor struct {
+	userID         string
+	servicePointID int64
+}
+
+// recordActor notes the authenticated caller for the access log, if enabled
+func recordActor(ctx context.Context, userID string, servicePointID *int64) {
+	a, ok := ctx.Value(accessLogKey).(*accessActor)
+	if !ok {
+		return
+	}
+	a.userID = userID
+	if servicePointID != nil {
+		a.servicePointID = *servicePointID
+	}
+}
+
+// AccessLog writes a structured entry for every request to sink. It should
+// be installed after RequestID so that entries carry the request ID. A nil
+// sink disables logging.
+func AccessLog(sink accesslog.Sink) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if sink == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			actor := &accessActor{}
+			ww := chimw.NewWrapResponseWriter(w, r.ProtoMajor)
+			r = r.WithContext(context.WithValue(r.Context(), accessLogKey, actor))
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			entry := storage.AccessLogEntry{
+				Schema:       storage.AccessLogSchema,
+				Time:         start.UTC(),
+				RequestID:    chimw.GetReqID(r.Context()),
+				Method:       r.Method,
+				Path:         r.URL.Path,
+				Status:       status,
+				Bytes:        int64(ww.BytesWritten()),
+				LatencyMS:    float64(time.Since(start).Microseconds()) / 1000,
+				Actor:        actor.userID,
+				ServicePoint: actor.servicePointID,
+				RemoteAddr:   r.RemoteAddr,
+				UserAgent:    r.UserAgent(),
+			}
+
+			// Routing has completed, so the route context holds the
+			// matched pattern and URL parameters
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				entry.Route = rctx.RoutePattern()
+				prefix, suffix := rctx.URLParam("prefix"), rctx.URLParam("suffix")
+				if prefix != "" && suffix != "" {
+					entry.RAiD = prefix + "/" + suffix
+					if v, err := strconv.Atoi(rctx.URLParam("version")); err == nil {
+						entry.Version = v
+					}
+				}
+			}
+
+			sink.Log(entry)
+		})
+	}
+}
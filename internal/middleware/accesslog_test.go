@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+type recordingSink struct {
+	entries []storage.AccessLogEntry
+}
+
+func (s *recordingSink) Log(entry storage.AccessLogEntry) { s.entries = append(s.entries, entry) }
+func (s *recordingSink) Close() error                     { return nil }
+
+func TestAccessLog(t *testing.T) {
+	sink := &recordingSink{}
+	r := chi.NewRouter()
+	r.Use(AccessLog(sink))
+	r.Route("/raid/{prefix}/{suffix}", func(r chi.Router) {
+		r.Get("/{version}", func(w http.ResponseWriter, r *http.Request) {
+			recordActor(r.Context(), "alice", nil)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("not found"))
+		})
+	})
+
+	req := httptest.NewRequest("GET", "/raid/10.99999/abc/3", nil)
+	req.Header.Set("User-Agent", "test-agent")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(sink.entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(sink.entries))
+	}
+	e := sink.entries[0]
+	if e.Schema != storage.AccessLogSchema || e.Method != "GET" || e.Path != "/raid/10.99999/abc/3" {
+		t.Errorf("unexpected request fields: %+v", e)
+	}
+	if e.Status != http.StatusNotFound || e.Bytes != 9 {
+		t.Errorf("expected status 404 and 9 bytes, got %d and %d", e.Status, e.Bytes)
+	}
+	if e.Route != "/raid/{prefix}/{suffix}/{version}" {
+		t.Errorf("unexpected route %q", e.Route)
+	}
+	if e.RAiD != "10.99999/abc" || e.Version != 3 {
+		t.Errorf("expected RAiD 10.99999/abc version 3, got %q version %d", e.RAiD, e.Version)
+	}
+	if e.Actor != "alice" || e.UserAgent != "test-agent" {
+		t.Errorf("unexpected actor %q or user agent %q", e.Actor, e.UserAgent)
+	}
+}
@@ -0,0 +1,215 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// contextKey is the type for request context keys set by this package
+type contextKey string
+
+const (
+	// UserIDKey holds the authenticated user ID
+	UserIDKey contextKey = "userID"
+	// UserEmailKey holds the authenticated user's email address
+	UserEmailKey contextKey = "userEmail"
+	// ServicePointIDKey holds the service point the user acts for
+	ServicePointIDKey contextKey = "servicePointID"
+	// RolesKey holds the user's roles
+	RolesKey contextKey = "roles"
+)
+
+const (
+	// RoleOperator is required for operational endpoints (diagnostics, admin API)
+	RoleOperator = "operator"
+	// RoleAdmin administers the service point named in the caller's token
+	RoleAdmin = "admin"
+)
+
+// Claims are the JWT claims understood by the RAiD API
+type Claims struct {
+	UserID         string   `json:"user_id"`
+	Email          string   `json:"email,omitempty"`
+	ServicePointID *int64   `json:"service_point_id,omitempty"`
+	Roles          []string `json:"roles,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// JWTAuth validates bearer tokens and stores the caller's identity in the
+// request context. When authentication is disabled requests pass through.
+func JWTAuth(cfg *config.AuthConfig) func(http.Handler) http.Handler {
+	return jwtAuth(cfg, false)
+}
+
+// OptionalJWTAuth is JWTAuth for routes that anonymous callers may use too:
+// requests without an Authorization header pass through anonymously, while
+// invalid tokens are still rejected
+func OptionalJWTAuth(cfg *config.AuthConfig) func(http.Handler) http.Handler {
+	return jwtAuth(cfg, true)
+}
+
+func jwtAuth(cfg *config.AuthConfig, optional bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cfg.Enabled || optional && r.Header.Get("Authorization") == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			tokenString, err := extractToken(r)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			claims, err := validateJWT(tokenString, cfg)
+			if err != nil {
+				writeError(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+
+			ctx := r.Context()
+			ctx = context.WithValue(ctx, UserIDKey, claims.UserID)
+			ctx = context.WithValue(ctx, UserEmailKey, claims.Email)
+			if claims.ServicePointID != nil {
+				ctx = context.WithValue(ctx, ServicePointIDKey, *claims.ServicePointID)
+			}
+			ctx = context.WithValue(ctx, RolesKey, claims.Roles)
+			// Versions written by the request record who wrote them
+			ctx = storage.WithActor(ctx, claims.UserID)
+			recordActor(ctx, claims.UserID, claims.ServicePointID)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireRole rejects requests from callers that do not have the given role
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			roles, ok := GetRoles(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "Authentication required")
+				return
+			}
+
+			for _, have := range roles {
+				if have == role {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			writeError(w, http.StatusForbidden, fmt.Sprintf("Role %q required", role))
+		})
+	}
+}
+
+// GetUserID returns the authenticated user ID from the context
+func GetUserID(ctx context.Context) (string, bool) {
+	userID, ok := ctx.Value(UserIDKey).(string)
+	return userID, ok
+}
+
+// GetUserEmail returns the authenticated user's email from the context
+func GetUserEmail(ctx context.Context) (string, bool) {
+	email, ok := ctx.Value(UserEmailKey).(string)
+	return email, ok
+}
+
+// GetServicePointID returns the caller's service point ID from the context
+func GetServicePointID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(ServicePointIDKey).(int64)
+	return id, ok
+}
+
+// GetRoles returns the caller's roles from the context
+func GetRoles(ctx context.Context) ([]string, bool) {
+	roles, ok := ctx.Value(RolesKey).([]string)
+	return roles, ok
+}
+
+// IsServicePointAdmin reports whether the caller is an operator or an admin
+// of the service point with id
+func IsServicePointAdmin(ctx context.Context, id int64) bool {
+	if HasRole(ctx, RoleOperator) {
+		return true
+	}
+	sp, ok := GetServicePointID(ctx)
+	return ok && sp == id && HasRole(ctx, RoleAdmin)
+}
+
+// HasRole reports whether the caller has the given role
+func HasRole(ctx context.Context, role string) bool {
+	roles, _ := GetRoles(ctx)
+	for _, have := range roles {
+		if have == role {
+			return true
+		}
+	}
+	return false
+}
+
+// extractToken returns the bearer token from the Authorization header
+func extractToken(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", errors.New("missing Authorization header")
+	}
+
+	parts := strings.SplitN(header, " ", 2)
+	if len(parts) != 2 || !strings.EqualFold(parts[0], "bearer") {
+		return "", errors.New("invalid Authorization header format")
+	}
+
+	return parts[1], nil
+}
+
+// validateJWT parses and validates a token against the configured secret,
+// issuer and audience
+func validateJWT(tokenString string, cfg *config.AuthConfig) (*Claims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodHS384.Alg(), jwt.SigningMethodHS512.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if cfg.JWTIssuer != "" {
+		opts = append(opts, jwt.WithIssuer(cfg.JWTIssuer))
+	}
+	if cfg.JWTAudience != "" {
+		opts = append(opts, jwt.WithAudience(cfg.JWTAudience))
+	}
+
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecret), nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+
+	if claims.UserID == "" {
+		claims.UserID = claims.Subject
+	}
+
+	return claims, nil
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"error": message,
+	})
+}
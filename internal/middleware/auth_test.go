@@ -223,6 +223,60 @@ func TestJWTAuth_WithServicePointID(t *testing.T) {
 	}
 }
 
+// TestOptionalJWTAuth tests that anonymous requests pass through while
+// invalid tokens are rejected
+func TestOptionalJWTAuth(t *testing.T) {
+	secret := "test-secret"
+	cfg := &config.AuthConfig{
+		Enabled:   true,
+		JWTSecret: secret,
+	}
+
+	var authenticated bool
+	handler := OptionalJWTAuth(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, authenticated = GetUserID(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, tc := range []struct {
+		header string
+		status int
+		authed bool
+	}{
+		{"", http.StatusOK, false},
+		{"Bearer " + createTestToken(t, secret, "user123", "", nil, nil, "", ""), http.StatusOK, true},
+		{"Bearer invalid", http.StatusUnauthorized, false},
+	} {
+		authenticated = false
+		req := httptest.NewRequest("GET", "/test", nil)
+		if tc.header != "" {
+			req.Header.Set("Authorization", tc.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tc.status || authenticated != tc.authed {
+			t.Errorf("%q: expected %d (authenticated %v), got %d (%v)", tc.header, tc.status, tc.authed, w.Code, authenticated)
+		}
+	}
+}
+
+// TestIsServicePointAdmin tests that admins administer only their own
+// service point, and operators all of them
+func TestIsServicePointAdmin(t *testing.T) {
+	admin := context.WithValue(context.WithValue(context.Background(), ServicePointIDKey, int64(42)), RolesKey, []string{RoleAdmin})
+	operator := context.WithValue(context.Background(), RolesKey, []string{RoleOperator})
+
+	if !IsServicePointAdmin(admin, 42) || IsServicePointAdmin(admin, 7) {
+		t.Error("expected an admin to administer only their own service point")
+	}
+	if !IsServicePointAdmin(operator, 7) {
+		t.Error("expected an operator to administer any service point")
+	}
+	if IsServicePointAdmin(context.Background(), 42) {
+		t.Error("expected anonymous callers not to administer service points")
+	}
+}
+
 // TestExtractToken tests token extraction from Authorization header
 func TestExtractToken(t *testing.T) {
 	tests := []struct {
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	called := 0
+	handler := CORS(LocalhostOrigin)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called++
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		wantOrigin  string
+		wantStatus  int
+		wantForward bool
+	}{
+		{"localhost request", http.MethodGet, "http://localhost:3000", "http://localhost:3000", http.StatusOK, true},
+		{"loopback IPv6 request", http.MethodGet, "http://[::1]:5173", "http://[::1]:5173", http.StatusOK, true},
+		{"localhost preflight", http.MethodOptions, "http://127.0.0.1:8081", "http://127.0.0.1:8081", http.StatusNoContent, false},
+		{"other origin", http.MethodGet, "https://evil.example", "", http.StatusOK, true},
+		{"lookalike origin", http.MethodGet, "http://localhost.evil.example", "", http.StatusOK, true},
+		{"same origin", http.MethodGet, "", "", http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = 0
+			req := httptest.NewRequest(tt.method, "/raid/", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.method == http.MethodOptions {
+				req.Header.Set("Access-Control-Request-Method", "POST")
+				req.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d", tt.wantStatus, w.Code)
+			}
+			if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("expected Access-Control-Allow-Origin %q, got %q", tt.wantOrigin, got)
+			}
+			if (called == 1) != tt.wantForward {
+				t.Errorf("expected handler called=%t, got %d calls", tt.wantForward, called)
+			}
+			if tt.method == http.MethodOptions && w.Header().Get("Access-Control-Allow-Headers") != "Content-Type, Authorization" {
+				t.Errorf("expected requested headers to be allowed, got %q", w.Header().Get("Access-Control-Allow-Headers"))
+			}
+		})
+	}
+}
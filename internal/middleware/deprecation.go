@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Deprecation marks the responses of routes superseded by the same routes
+// under successor, such as "v2", with a link to the successor version and,
+// when set, the Deprecation (RFC 9745) and Sunset (RFC 8594) headers
+func Deprecation(successor string, deprecated, sunset time.Time) func(http.Handler) http.Handler {
+	successor = strings.Trim(successor, "/")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Relative, so that it resolves wherever the server is mounted
+			path := RoutePath(r)
+			ref := strings.Repeat("../", max(strings.Count(path, "/")-1, 0)) + successor + path
+			if !strings.HasPrefix(ref, "../") {
+				ref = "./" + ref
+			}
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, ref))
+			if !deprecated.IsZero() {
+				w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecated.Unix()))
+			}
+			if !sunset.IsZero() {
+				w.Header().Set("Sunset", sunset.UTC().Format(http.TimeFormat))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RoutePath returns the escaped path of r within the router serving it,
+// without the prefix of any router it is mounted under
+func RoutePath(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePath != "" {
+		return rctx.RoutePath
+	}
+	return r.URL.EscapedPath()
+}
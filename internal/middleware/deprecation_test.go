@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestDeprecation(t *testing.T) {
+	deprecated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	r := chi.NewRouter()
+	r.With(Deprecation("/v2", deprecated, sunset)).Get("/raid/{prefix}/{suffix}", ok)
+	r.With(Deprecation("/v2", time.Time{}, time.Time{})).Get("/health", ok)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/raid/10.1/abc", nil))
+	if got, want := w.Header().Get("Link"), `<../../v2/raid/10.1/abc>; rel="successor-version"`; got != want {
+		t.Errorf("expected Link %s, got %s", want, got)
+	}
+	if got := w.Header().Get("Deprecation"); got != "@1767225600" {
+		t.Errorf("expected Deprecation @1767225600, got %q", got)
+	}
+	if got := w.Header().Get("Sunset"); got != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Errorf("expected the sunset as an HTTP date, got %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if got, want := w.Header().Get("Link"), `<./v2/health>; rel="successor-version"`; got != want {
+		t.Errorf("expected Link %s, got %s", want, got)
+	}
+	if w.Header().Get("Deprecation") != "" || w.Header().Get("Sunset") != "" {
+		t.Error("expected no Deprecation or Sunset without dates")
+	}
+}
+
+func TestRoutePath(t *testing.T) {
+	var got string
+	r := chi.NewRouter()
+	r.Route("/v2", func(r chi.Router) {
+		r.Get("/raid/{prefix}/{suffix}", func(w http.ResponseWriter, r *http.Request) { got = RoutePath(r) })
+	})
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/v2/raid/10.1/abc", nil))
+	if got != "/raid/10.1/abc" {
+		t.Errorf("expected the path within the mounted router, got %q", got)
+	}
+}
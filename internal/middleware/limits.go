@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaxBodySize limits request bodies to limit bytes. Requests that declare a
+// larger Content-Length are rejected with 413 up front; bodies without a
+// declared length fail while being read, which handlers report as 413.
+// A limit of zero or less disables the check.
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body exceeds the limit of %d bytes", limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Timeout bounds the time a handler may take to d. The request context is
+// cancelled at the deadline so storage calls are abandoned, and the client
+// receives 503 Service Unavailable. A zero duration disables the timeout.
+//
+// The response is buffered until the handler returns, so Timeout must not be
+// used on streaming endpoints.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		// TimeoutHandler gives the handler headers of its own, which replace
+		// those of the response when it returns, so the headers outer
+		// middleware set are carried in for the handler to add to
+		inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if outer, ok := r.Context().Value(outerHeaderKey{}).(http.Header); ok {
+				for k, v := range outer {
+					w.Header()[k] = v
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+		timeout := http.TimeoutHandler(inner, d, `{"error":"request timed out"}`)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), outerHeaderKey{}, w.Header().Clone())))
+		})
+	}
+}
+
+type outerHeaderKey struct{}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMaxBodySize_DeclaredLength tests that oversized Content-Length is rejected up front
+func TestMaxBodySize_DeclaredLength(t *testing.T) {
+	called := false
+	handler := MaxBodySize(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	req := httptest.NewRequest("POST", "/raid/", strings.NewReader(strings.Repeat("x", 11)))
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413, got %d", w.Code)
+	}
+	if called {
+		t.Error("expected handler not to be called")
+	}
+}
+
+// TestMaxBodySize_StreamedBody tests that bodies without a declared length are cut off while reading
+func TestMaxBodySize_StreamedBody(t *testing.T) {
+	var readErr error
+	handler := MaxBodySize(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest("POST", "/raid/", strings.NewReader(strings.Repeat("x", 11)))
+	req.ContentLength = -1
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("expected MaxBytesError, got %v", readErr)
+	}
+}
+
+// TestTimeout tests that slow handlers are cut off with 503 and see a cancelled context
+func TestTimeout(t *testing.T) {
+	cancelled := make(chan struct{})
+	handler := Timeout(10 * time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(cancelled)
+	}))
+
+	req := httptest.NewRequest("GET", "/raid/", nil)
+	w := httptest.NewRecorder()
+
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("expected handler context to be cancelled")
+	}
+}
+
+func TestTimeout_KeepsOuterHeaders(t *testing.T) {
+	handler := Timeout(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `<next>; rel="next"`)
+	}))
+
+	w := httptest.NewRecorder()
+	w.Header().Add("Link", `<v2>; rel="successor-version"`)
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/raid/", nil))
+
+	if got := w.Header().Values("Link"); len(got) != 2 {
+		t.Errorf("expected the handler's Link to be added to the outer one, got %q", got)
+	}
+}
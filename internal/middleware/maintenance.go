@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Maintenance holds the server's read-only switch. While enabled, mutating
+// requests are rejected with 503 and a Retry-After header; reads still work.
+// It is safe for concurrent use and can be toggled at runtime.
+type Maintenance struct {
+	mu         sync.RWMutex
+	enabled    bool
+	reason     string
+	retryAfter time.Duration
+	since      time.Time
+}
+
+// MaintenanceStatus is a snapshot of the maintenance state
+type MaintenanceStatus struct {
+	Enabled    bool      `json:"enabled"`
+	Reason     string    `json:"reason,omitempty"`
+	RetryAfter int       `json:"retryAfter"`
+	Since      time.Time `json:"since,omitempty"`
+}
+
+// NewMaintenance creates a maintenance switch in the given initial state
+func NewMaintenance(enabled bool, reason string, retryAfter time.Duration) *Maintenance {
+	m := &Maintenance{retryAfter: retryAfter}
+	m.Set(enabled, reason)
+	return m
+}
+
+// Set enables or disables read-only mode
+func (m *Maintenance) Set(enabled bool, reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if enabled && !m.enabled {
+		m.since = time.Now().UTC()
+	}
+	if !enabled {
+		m.since = time.Time{}
+		reason = ""
+	}
+	m.enabled = enabled
+	m.reason = reason
+}
+
+// SetRetryAfter changes the delay advertised to rejected clients
+func (m *Maintenance) SetRetryAfter(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.retryAfter = d
+}
+
+// Enabled reports whether read-only mode is on
+func (m *Maintenance) Enabled() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.enabled
+}
+
+// Status returns the current maintenance state
+func (m *Maintenance) Status() MaintenanceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return MaintenanceStatus{
+		Enabled:    m.enabled,
+		Reason:     m.reason,
+		RetryAfter: int(m.retryAfter.Seconds()),
+		Since:      m.since,
+	}
+}
+
+// ReadOnly rejects mutating requests while maintenance mode is enabled
+func (m *Maintenance) ReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status := m.Status()
+		if !status.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		message := "Service is in read-only mode"
+		if status.Reason != "" {
+			message += ": " + status.Reason
+		}
+		if status.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(status.RetryAfter))
+		}
+		writeError(w, http.StatusServiceUnavailable, message)
+	})
+}
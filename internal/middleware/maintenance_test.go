@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestMaintenance_ReadOnly tests that writes are rejected and reads allowed in read-only mode
+func TestMaintenance_ReadOnly(t *testing.T) {
+	m := NewMaintenance(true, "backend failover", 2*time.Minute)
+
+	handler := m.ReadOnly(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/raid/", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("expected reads to pass, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/raid/", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "120" {
+		t.Errorf("expected Retry-After 120, got %q", got)
+	}
+
+	m.Set(false, "")
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("PUT", "/raid/10.1/abc", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected writes to pass after disabling, got %d", w.Code)
+	}
+}
@@ -0,0 +1,291 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate is a token bucket: Limit requests per Period are refilled continuously
+// and up to Burst requests may be made at once. A Limit of zero or less
+// disables limiting.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+	Burst  int
+}
+
+// capacity returns the bucket size
+func (r Rate) capacity() float64 {
+	if r.Burst > 0 {
+		return float64(r.Burst)
+	}
+	return float64(r.Limit)
+}
+
+// perSecond returns the refill rate
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// RateLimitResult is the outcome of taking a token
+type RateLimitResult struct {
+	Allowed bool
+	// Remaining is the number of whole tokens left
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until a token is available, when not allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore holds token buckets
+type RateLimitStore interface {
+	// Take removes one token from the bucket for key
+	Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error)
+}
+
+// result derives the response values from the tokens left in a bucket
+func result(allowed bool, tokens float64, rate Rate) RateLimitResult {
+	res := RateLimitResult{
+		Allowed:   allowed,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((rate.capacity() - tokens) / rate.perSecond() * float64(time.Second)),
+	}
+	if !allowed {
+		res.RetryAfter = time.Duration((1 - tokens) / rate.perSecond() * float64(time.Second))
+	}
+	return res
+}
+
+// MemoryRateLimitStore keeps buckets in process memory. Limits apply per
+// server instance.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// NewMemoryRateLimitStore creates an in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Take removes one token from the bucket for key
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: rate.capacity(), last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(rate.capacity(), b.tokens+now.Sub(b.last).Seconds()*rate.perSecond())
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	res := result(allowed, b.tokens, rate)
+	b.full = now.Add(res.Reset)
+	return res, nil
+}
+
+// sweep drops buckets that have refilled completely, at most once a minute
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if now.After(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// takeScript implements the token bucket atomically in Redis, using the
+// server clock so that all instances agree on time
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil then
+  tokens = capacity
+  ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('EXPIRE', KEYS[1], math.ceil(capacity / rate) + 1)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisRateLimitStore keeps buckets in Redis so that limits are shared by
+// all server instances
+type RedisRateLimitStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisRateLimitStore creates a store from a redis:// or rediss:// URL.
+// Keys are namespaced with prefix.
+func NewRedisRateLimitStore(url, prefix string) (*RedisRateLimitStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	return &RedisRateLimitStore{client: redis.NewClient(opts), prefix: prefix}, nil
+}
+
+// Take removes one token from the bucket for key
+func (s *RedisRateLimitStore) Take(ctx context.Context, key string, rate Rate) (RateLimitResult, error) {
+	values, err := takeScript.Run(ctx, s.client, []string{s.prefix + key}, rate.capacity(), rate.perSecond()).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(values) != 2 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+
+	allowed, _ := values[0].(int64)
+	tokensStr, _ := values[1].(string)
+	tokens, err := strconv.ParseFloat(tokensStr, 64)
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	return result(allowed == 1, tokens, rate), nil
+}
+
+// Close closes the Redis connection
+func (s *RedisRateLimitStore) Close() error {
+	return s.client.Close()
+}
+
+// RateLimiter applies a global limit shared by all callers and per-caller
+// limits for each route class. Callers are identified by their
+// authenticated user ID when authentication has run, and by client IP
+// otherwise. A nil RateLimiter does not limit.
+type RateLimiter struct {
+	store      RateLimitStore
+	global     Rate
+	trustProxy bool
+}
+
+// NewRateLimiter creates a limiter. With trustProxy set the client IP is
+// taken from X-Forwarded-For, which must then be set by a trusted proxy.
+func NewRateLimiter(store RateLimitStore, global Rate, trustProxy bool) *RateLimiter {
+	return &RateLimiter{store: store, global: global, trustProxy: trustProxy}
+}
+
+// Limit enforces rate for the route class. Responses carry RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers for the
+// caller's bucket; rejected requests receive 429 with Retry-After. If the
+// store fails, requests are let through.
+func (l *RateLimiter) Limit(class string, rate Rate) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l == nil || rate.Limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if l.global.Limit > 0 {
+				res, err := l.store.Take(r.Context(), "global", l.global)
+				if err != nil {
+					log.Printf("Rate limit store failed: %v", err)
+				} else if !res.Allowed {
+					l.reject(w, res)
+					return
+				}
+			}
+
+			res, err := l.store.Take(r.Context(), class+":"+l.callerKey(r), rate)
+			if err != nil {
+				log.Printf("Rate limit store failed: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Limit", strconv.Itoa(int(rate.capacity())))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+			h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d;burst=%d", rate.Limit, int(rate.Period.Seconds()), int(rate.capacity())))
+
+			if !res.Allowed {
+				l.reject(w, res)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func (l *RateLimiter) reject(w http.ResponseWriter, res RateLimitResult) {
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+	writeError(w, http.StatusTooManyRequests, "Rate limit exceeded")
+}
+
+// callerKey identifies the caller for per-caller buckets
+func (l *RateLimiter) callerKey(r *http.Request) string {
+	if userID, ok := GetUserID(r.Context()); ok && userID != "" {
+		return "user:" + userID
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP returns the client address, honouring X-Forwarded-For only for
+// trusted proxies
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
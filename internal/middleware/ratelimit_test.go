@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(global Rate) (*RateLimiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	return NewRateLimiter(store, global, false), &now
+}
+
+func doRequest(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/raid/", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+// TestRateLimit_Burst tests that requests beyond the burst are rejected with 429
+func TestRateLimit_Burst(t *testing.T) {
+	limiter, now := newTestLimiter(Rate{})
+	handler := limiter.Limit("read", Rate{Limit: 60, Period: time.Minute, Burst: 2})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 2; i++ {
+		if w := doRequest(handler, "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i, w.Code)
+		}
+	}
+
+	w := doRequest(handler, "192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Remaining"); got != "0" {
+		t.Errorf("expected RateLimit-Remaining 0, got %q", got)
+	}
+	if got := w.Header().Get("RateLimit-Policy"); got != "60;w=60;burst=2" {
+		t.Errorf("unexpected RateLimit-Policy %q", got)
+	}
+
+	// Another client has its own bucket
+	if w := doRequest(handler, "192.0.2.2:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected other client to be allowed, got %d", w.Code)
+	}
+
+	// One token is refilled per second
+	*now = now.Add(time.Second)
+	if w := doRequest(handler, "192.0.2.1:1234"); w.Code != http.StatusOK {
+		t.Errorf("expected request after refill to be allowed, got %d", w.Code)
+	}
+}
+
+// TestRateLimit_KeysByUser tests that authenticated callers are limited per user, not per IP
+func TestRateLimit_KeysByUser(t *testing.T) {
+	limiter, _ := newTestLimiter(Rate{})
+	handler := limiter.Limit("write", Rate{Limit: 1, Period: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, user := range []string{"alice", "bob"} {
+		req := httptest.NewRequest("POST", "/raid/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), UserIDKey, user))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Errorf("expected first request from %s to be allowed, got %d", user, w.Code)
+		}
+	}
+}
+
+// TestRateLimit_Global tests that the global limit applies across clients
+func TestRateLimit_Global(t *testing.T) {
+	limiter, _ := newTestLimiter(Rate{Limit: 1, Period: time.Second})
+	handler := limiter.Limit("read", Rate{Limit: 100, Period: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	doRequest(handler, "192.0.2.1:1234")
+	if w := doRequest(handler, "192.0.2.2:1234"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("expected global limit to reject second client, got %d", w.Code)
+	}
+}
+
+// TestRateLimit_Disabled tests that a nil limiter passes requests through
+func TestRateLimit_Disabled(t *testing.T) {
+	var limiter *RateLimiter
+	handler := limiter.Limit("read", Rate{Limit: 1, Period: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i := 0; i < 3; i++ {
+		if w := doRequest(handler, "192.0.2.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", w.Code)
+		}
+	}
+}
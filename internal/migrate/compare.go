@@ -0,0 +1,320 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// RepairActor is recorded as the actor of the purges Repair makes to
+// replace diverged replica RAiDs
+const RepairActor = "verify-replica"
+
+// Snapshot is the read side of a storage.Snapshotter, implemented by every
+// backend that supports backups and by backup.Archive
+type Snapshot interface {
+	ListServicePoints(ctx context.Context) ([]*models.ServicePoint, error)
+	ExportRAiDs(ctx context.Context, fn func(*storage.RAiDRecord) error) error
+	Counters(ctx context.Context) (map[string]int64, error)
+}
+
+// Counts sizes one side of a comparison
+type Counts struct {
+	ServicePoints int `json:"servicePoints"`
+	RAiDs         int `json:"raids"`
+	Versions      int `json:"versions"`
+	Counters      int `json:"counters"`
+}
+
+// Report lists the differences Compare found between a source and a
+// replica. RAiDs are compared by the checksums of their records, service
+// points by their JSON encoding.
+type Report struct {
+	Source  Counts `json:"source"`
+	Replica Counts `json:"replica"`
+	// Matched counts the RAiDs and service points equal on both sides
+	Matched int `json:"matched"`
+	// Missing holds the RAiDs only in the source, Extra those only in the
+	// replica and Differing those whose records differ
+	Missing   []string `json:"missing,omitempty"`
+	Extra     []string `json:"extra,omitempty"`
+	Differing []string `json:"differing,omitempty"`
+	// The same for service points, by ID
+	MissingServicePoints   []int64 `json:"missingServicePoints,omitempty"`
+	ExtraServicePoints     []int64 `json:"extraServicePoints,omitempty"`
+	DifferingServicePoints []int64 `json:"differingServicePoints,omitempty"`
+	// Counters holds the names of the identifier counters behind in the
+	// replica; ones ahead are fine, as counters only ever move forward
+	Counters []string `json:"counters,omitempty"`
+}
+
+// Consistent reports whether the replica equals the source
+func (r *Report) Consistent() bool {
+	return len(r.Missing)+len(r.Extra)+len(r.Differing)+
+		len(r.MissingServicePoints)+len(r.ExtraServicePoints)+len(r.DifferingServicePoints)+
+		len(r.Counters) == 0
+}
+
+// Compare reads src and dst in full and reports how dst differs from src.
+// Only the checksums of the source RAiDs are held in memory.
+func Compare(ctx context.Context, src, dst Snapshot) (*Report, error) {
+	report := &Report{}
+
+	checksums := make(map[string][32]byte)
+	err := src.ExportRAiDs(ctx, func(record *storage.RAiDRecord) error {
+		current := record.Current()
+		if current == nil || current.Identifier == nil {
+			return fmt.Errorf("source RAiD record has no versions")
+		}
+		sum, err := Checksum(record)
+		if err != nil {
+			return err
+		}
+		checksums[current.Identifier.ID] = sum
+		report.Source.RAiDs++
+		report.Source.Versions += len(record.Versions)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source RAiDs: %w", err)
+	}
+
+	err = dst.ExportRAiDs(ctx, func(record *storage.RAiDRecord) error {
+		current := record.Current()
+		if current == nil || current.Identifier == nil {
+			return fmt.Errorf("replica RAiD record has no versions")
+		}
+		id := current.Identifier.ID
+		report.Replica.RAiDs++
+		report.Replica.Versions += len(record.Versions)
+
+		want, ok := checksums[id]
+		if !ok {
+			report.Extra = append(report.Extra, id)
+			return nil
+		}
+		delete(checksums, id)
+
+		got, err := Checksum(record)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			report.Differing = append(report.Differing, id)
+			return nil
+		}
+		report.Matched++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read replica RAiDs: %w", err)
+	}
+	for id := range checksums {
+		report.Missing = append(report.Missing, id)
+	}
+
+	if err := compareServicePoints(ctx, src, dst, report); err != nil {
+		return nil, err
+	}
+	if err := compareCounters(ctx, src, dst, report); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(report.Missing)
+	sort.Strings(report.Extra)
+	sort.Strings(report.Differing)
+	return report, nil
+}
+
+func compareServicePoints(ctx context.Context, src, dst Snapshot, report *Report) error {
+	srcSPs, err := src.ListServicePoints(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list source service points: %w", err)
+	}
+	dstSPs, err := dst.ListServicePoints(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list replica service points: %w", err)
+	}
+	report.Source.ServicePoints = len(srcSPs)
+	report.Replica.ServicePoints = len(dstSPs)
+
+	replica := make(map[int64]*models.ServicePoint, len(dstSPs))
+	for _, sp := range dstSPs {
+		replica[sp.ID] = sp
+	}
+	for _, sp := range srcSPs {
+		got, ok := replica[sp.ID]
+		delete(replica, sp.ID)
+		switch {
+		case !ok:
+			report.MissingServicePoints = append(report.MissingServicePoints, sp.ID)
+		case !equalJSON(sp, got):
+			report.DifferingServicePoints = append(report.DifferingServicePoints, sp.ID)
+		default:
+			report.Matched++
+		}
+	}
+	for id := range replica {
+		report.ExtraServicePoints = append(report.ExtraServicePoints, id)
+	}
+
+	for _, ids := range [][]int64{report.MissingServicePoints, report.ExtraServicePoints, report.DifferingServicePoints} {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+	return nil
+}
+
+func compareCounters(ctx context.Context, src, dst Snapshot, report *Report) error {
+	srcCounters, err := src.Counters(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read source counters: %w", err)
+	}
+	dstCounters, err := dst.Counters(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read replica counters: %w", err)
+	}
+	report.Source.Counters = len(srcCounters)
+	report.Replica.Counters = len(dstCounters)
+
+	for name, value := range srcCounters {
+		if dstCounters[name] < value {
+			report.Counters = append(report.Counters, name)
+		}
+	}
+	sort.Strings(report.Counters)
+	return nil
+}
+
+// Repair copies the service points and RAiDs report found missing or
+// different from src into dst, the replica report was made against, and
+// raises the counters of dst to those of src. A differing RAiD is replaced
+// by deleting and purging the replica copy, which dst must support, before
+// importing the source copy. Items only in the replica are kept, as they
+// may have been written there deliberately. It returns the number of items
+// repaired.
+func Repair(ctx context.Context, src Snapshot, dst storage.Repository, report *Report) (int, error) {
+	dstSnap, ok := dst.(storage.Snapshotter)
+	if !ok {
+		return 0, fmt.Errorf("replica: %w", backup.ErrUnsupported)
+	}
+	purger, _ := dst.(storage.Purger)
+	if len(report.Differing) > 0 && purger == nil {
+		return 0, fmt.Errorf("replica cannot replace differing RAiDs: %w", backup.ErrUnsupported)
+	}
+
+	repaired := 0
+
+	// Service points first so repaired RAiDs can resolve their owners
+	missingSPs := make(map[int64]bool)
+	for _, id := range report.MissingServicePoints {
+		missingSPs[id] = true
+	}
+	differingSPs := make(map[int64]bool)
+	for _, id := range report.DifferingServicePoints {
+		differingSPs[id] = true
+	}
+	if len(missingSPs)+len(differingSPs) > 0 {
+		sps, err := src.ListServicePoints(ctx)
+		if err != nil {
+			return repaired, fmt.Errorf("failed to list source service points: %w", err)
+		}
+		for _, sp := range sps {
+			switch {
+			case missingSPs[sp.ID]:
+				err = dstSnap.ImportServicePoint(ctx, sp)
+			case differingSPs[sp.ID]:
+				_, err = dst.UpdateServicePoint(ctx, sp.ID, sp)
+			default:
+				continue
+			}
+			if err != nil {
+				return repaired, fmt.Errorf("failed to repair service point %d: %w", sp.ID, err)
+			}
+			repaired++
+		}
+	}
+
+	missing := make(map[string]bool)
+	for _, id := range report.Missing {
+		missing[id] = true
+	}
+	differing := make(map[string]bool)
+	for _, id := range report.Differing {
+		differing[id] = true
+	}
+	if len(missing)+len(differing) > 0 {
+		err := src.ExportRAiDs(ctx, func(record *storage.RAiDRecord) error {
+			current := record.Current()
+			if current == nil || current.Identifier == nil {
+				return nil
+			}
+			id := current.Identifier.ID
+			if differing[id] {
+				if err := replaceRAiD(ctx, dst, purger, id); err != nil {
+					return fmt.Errorf("failed to remove replica copy of %s: %w", id, err)
+				}
+			} else if !missing[id] {
+				return nil
+			}
+			if err := dstSnap.ImportRAiD(ctx, record); err != nil {
+				return fmt.Errorf("failed to repair %s: %w", id, err)
+			}
+			repaired++
+			return nil
+		})
+		if err != nil {
+			return repaired, err
+		}
+	}
+
+	if len(report.Counters) > 0 {
+		srcCounters, err := src.Counters(ctx)
+		if err != nil {
+			return repaired, fmt.Errorf("failed to read source counters: %w", err)
+		}
+		dstCounters, err := dstSnap.Counters(ctx)
+		if err != nil {
+			return repaired, fmt.Errorf("failed to read replica counters: %w", err)
+		}
+		raise := make(map[string]int64)
+		for name, value := range srcCounters {
+			if value > dstCounters[name] {
+				raise[name] = value
+			}
+		}
+		if len(raise) > 0 {
+			if err := dstSnap.SetCounters(ctx, raise); err != nil {
+				return repaired, fmt.Errorf("failed to set replica counters: %w", err)
+			}
+			repaired += len(raise)
+		}
+	}
+
+	return repaired, nil
+}
+
+// replaceRAiD removes the replica copy of the RAiD id entirely
+func replaceRAiD(ctx context.Context, dst storage.Repository, purger storage.Purger, id string) error {
+	prefix, suffix, err := identifier.Parse(id)
+	if err != nil {
+		return err
+	}
+	// An already deleted copy is not found
+	if err := dst.DeleteRAiD(ctx, prefix, suffix); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+	return purger.PurgeRAiD(ctx, &models.Purge{
+		Prefix: prefix,
+		Suffix: suffix,
+		Actor:  RepairActor,
+		Reason: "replaced by the source copy during replica repair",
+		Purged: time.Now().UTC(),
+	})
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestCompareAndRepair(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newFileStorage(t), newFileStorage(t)
+	seed(t, src)
+	if _, err := Run(ctx, src, dst, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Compare(ctx, src, dst)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !report.Consistent() || report.Matched != 4 || report.Source.RAiDs != 3 || report.Replica.Versions != 6 {
+		t.Errorf("expected a consistent replica, got %+v", report)
+	}
+
+	// "d" only in the source, "a" changed in the replica, "e" only there
+	if _, err := src.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/d"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.UpdateRAiD(ctx, "10.99999", "a", &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"},
+		Title:      []models.Title{{Text: "Diverged"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/e"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err = Compare(ctx, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Missing, []string{"https://raid.org/10.99999/d"}) ||
+		!reflect.DeepEqual(report.Differing, []string{"https://raid.org/10.99999/a"}) ||
+		!reflect.DeepEqual(report.Extra, []string{"https://raid.org/10.99999/e"}) {
+		t.Errorf("unexpected differences: %+v", report)
+	}
+
+	repaired, err := Repair(ctx, src, dst, report)
+	if err != nil {
+		t.Fatalf("Repair failed: %v", err)
+	}
+	if repaired != 2 {
+		t.Errorf("expected 2 repairs, got %d", repaired)
+	}
+	report, err = Compare(ctx, src, dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Missing)+len(report.Differing) != 0 || len(report.Extra) != 1 {
+		t.Errorf("expected only the extra RAiD to remain, got %+v", report)
+	}
+	raid, err := dst.GetRAiD(ctx, "10.99999", "a")
+	if err != nil || len(raid.Title) != 1 || raid.Title[0].Text != "Updated" {
+		t.Errorf("expected the source copy of a in the replica, got %v (%v)", raid, err)
+	}
+}
+
+func TestCompare_Archive(t *testing.T) {
+	ctx := context.Background()
+	src := newFileStorage(t)
+	seed(t, src)
+
+	var buf bytes.Buffer
+	if _, err := backup.Write(ctx, src, "file", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "backup.ndjson.gz")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := backup.OpenArchive(path)
+	if err != nil {
+		t.Fatalf("OpenArchive failed: %v", err)
+	}
+
+	report, err := Compare(ctx, src, archive)
+	if err != nil {
+		t.Fatalf("Compare failed: %v", err)
+	}
+	if !report.Consistent() || report.Matched != 4 {
+		t.Errorf("expected the archive to match its source, got %+v", report)
+	}
+
+	if _, err := backup.OpenArchive(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected an error for a missing archive")
+	}
+}
@@ -0,0 +1,275 @@
+// Package migrate copies all data from one storage backend to another.
+//
+// Service points are copied first, then RAiDs with their full version
+// history and deletion state, several at a time, then identifier counters.
+// Imports are atomic per RAiD, so an interrupted migration can be resumed:
+// items that already exist in the target are skipped and then checked by
+// the verification pass, which compares SHA-256 checksums of every source
+// and target record.
+//
+// The side stores, such as tags, drafts and the records of purges and
+// redactions, are copied last, as far as they can be listed; see
+// copySideStores.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/workpool"
+)
+
+// ErrChecksumMismatch is returned when verification finds target records
+// that differ from the source
+var ErrChecksumMismatch = errors.New("target does not match source")
+
+// maxReportedMismatches bounds the identifiers listed in a mismatch error
+const maxReportedMismatches = 10
+
+// Options controls a migration
+type Options struct {
+	// Resume allows a non-empty target and skips items that already exist
+	Resume bool
+	// Verify compares checksums of all source and target records afterwards
+	Verify bool
+	// Progress, if set, is called after every ProgressEvery RAiDs and once
+	// at the end of each phase
+	Progress      func(phase string, summary Summary)
+	ProgressEvery int
+	// Workers, Rate and ItemTimeout shape the RAiD imports into the
+	// target, see workpool.Options
+	Workers     int
+	Rate        float64
+	ItemTimeout time.Duration
+}
+
+// Summary counts migrated items
+type Summary struct {
+	ServicePoints int `json:"servicePoints"`
+	RAiDs         int `json:"raids"`
+	Versions      int `json:"versions"`
+	Counters      int `json:"counters"`
+	// Skipped counts items already present in the target
+	Skipped int `json:"skipped"`
+	// Verified counts records whose checksums matched
+	Verified int `json:"verified"`
+	// Side counts the records copied from the side stores, by store
+	Side map[string]int `json:"side,omitempty"`
+}
+
+// Run copies everything from src to dst
+func Run(ctx context.Context, src, dst storage.Repository, opts Options) (*Summary, error) {
+	srcSnap, ok := src.(storage.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("source: %w", backup.ErrUnsupported)
+	}
+	dstSnap, ok := dst.(storage.Snapshotter)
+	if !ok {
+		return nil, fmt.Errorf("target: %w", backup.ErrUnsupported)
+	}
+
+	if !opts.Resume {
+		if err := backup.EnsureEmpty(ctx, dst); err != nil {
+			return nil, err
+		}
+	}
+	if opts.ProgressEvery <= 0 {
+		opts.ProgressEvery = 100
+	}
+	progress := func(phase string, summary *Summary) {
+		if opts.Progress != nil {
+			opts.Progress(phase, *summary)
+		}
+	}
+
+	summary := &Summary{}
+
+	// Service points first so migrated RAiDs can resolve their owners
+	sps, err := src.ListServicePoints(ctx)
+	if err != nil {
+		return summary, fmt.Errorf("failed to list source service points: %w", err)
+	}
+	for _, sp := range sps {
+		if err := dstSnap.ImportServicePoint(ctx, sp); err == storage.ErrAlreadyExists {
+			summary.Skipped++
+		} else if err != nil {
+			return summary, fmt.Errorf("failed to copy service point %d: %w", sp.ID, err)
+		} else {
+			summary.ServicePoints++
+		}
+	}
+	progress("servicePoints", summary)
+
+	// RAiDs are imported in parallel; summary is shared with the workers
+	var mu sync.Mutex
+	pool := workpool.New(ctx, workpool.Options{Workers: opts.Workers, Rate: opts.Rate, ItemTimeout: opts.ItemTimeout})
+	checksums := make(map[string][32]byte)
+	var raids []handle
+	seen := 0
+	err = srcSnap.ExportRAiDs(ctx, func(record *storage.RAiDRecord) error {
+		current := record.Current()
+		if current == nil || current.Identifier == nil {
+			return fmt.Errorf("source RAiD record has no versions")
+		}
+		id := current.Identifier.ID
+		if prefix, suffix, err := identifier.Parse(id); err == nil {
+			raids = append(raids, handle{prefix, suffix})
+		}
+
+		if opts.Verify {
+			sum, err := Checksum(record)
+			if err != nil {
+				return err
+			}
+			checksums[id] = sum
+		}
+
+		return pool.Go(id, func(ctx context.Context) error {
+			err := dstSnap.ImportRAiD(ctx, record)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err == storage.ErrAlreadyExists {
+				summary.Skipped++
+			} else if err != nil {
+				return err
+			} else {
+				summary.RAiDs++
+				summary.Versions += len(record.Versions)
+			}
+
+			seen++
+			if seen%opts.ProgressEvery == 0 {
+				progress("raids", summary)
+			}
+			return nil
+		})
+	})
+	if poolErr := pool.Wait(); poolErr != nil && err == nil {
+		err = fmt.Errorf("failed to copy RAiDs: %w", poolErr)
+	}
+	if err != nil {
+		return summary, err
+	}
+	progress("raids", summary)
+
+	counters, err := srcSnap.Counters(ctx)
+	if err != nil {
+		return summary, fmt.Errorf("failed to read source counters: %w", err)
+	}
+	if err := dstSnap.SetCounters(ctx, counters); err != nil {
+		return summary, fmt.Errorf("failed to set target counters: %w", err)
+	}
+	summary.Counters = len(counters)
+	progress("counters", summary)
+
+	// During a dual-write window the side stores are only kept in the
+	// source
+	if err := copySideStores(ctx, storage.Source(src), dst, sps, raids, summary); err != nil {
+		return summary, err
+	}
+	progress("side", summary)
+
+	if opts.Verify {
+		if err := verify(ctx, dst, dstSnap, sps, checksums, summary); err != nil {
+			return summary, err
+		}
+		progress("verify", summary)
+	}
+
+	return summary, nil
+}
+
+// verify compares target records against the source checksums
+func verify(ctx context.Context, dst storage.Repository, dstSnap storage.Snapshotter, sps []*models.ServicePoint, checksums map[string][32]byte, summary *Summary) error {
+	var mismatches []string
+
+	err := dstSnap.ExportRAiDs(ctx, func(record *storage.RAiDRecord) error {
+		current := record.Current()
+		if current == nil || current.Identifier == nil {
+			return nil
+		}
+		want, ok := checksums[current.Identifier.ID]
+		if !ok {
+			// Present only in the target, e.g. minted there before a resume
+			return nil
+		}
+		delete(checksums, current.Identifier.ID)
+
+		got, err := Checksum(record)
+		if err != nil {
+			return err
+		}
+		if got != want {
+			mismatches = append(mismatches, current.Identifier.ID)
+			return nil
+		}
+		summary.Verified++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to read target RAiDs: %w", err)
+	}
+
+	// Anything left was not found in the target
+	for id := range checksums {
+		mismatches = append(mismatches, id+" (missing)")
+	}
+
+	for _, sp := range sps {
+		got, err := dst.GetServicePoint(ctx, sp.ID)
+		if err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("service point %d (%v)", sp.ID, err))
+			continue
+		}
+		if !equalJSON(sp, got) {
+			mismatches = append(mismatches, fmt.Sprintf("service point %d", sp.ID))
+			continue
+		}
+		summary.Verified++
+	}
+
+	if len(mismatches) == 0 {
+		return nil
+	}
+
+	sort.Strings(mismatches)
+	total := len(mismatches)
+	if total > maxReportedMismatches {
+		mismatches = append(mismatches[:maxReportedMismatches], "...")
+	}
+	return fmt.Errorf("%w: %d records differ: %s", ErrChecksumMismatch, total, strings.Join(mismatches, ", "))
+}
+
+// Checksum returns the SHA-256 of a record's canonical JSON encoding,
+// covering every version, the deletion state and the version actors
+func Checksum(record *storage.RAiDRecord) ([32]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(data), nil
+}
+
+func equalJSON(a, b interface{}) bool {
+	x, err := json.Marshal(a)
+	if err != nil {
+		return false
+	}
+	y, err := json.Marshal(b)
+	if err != nil {
+		return false
+	}
+	return string(x) == string(y)
+}
@@ -0,0 +1,178 @@
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func newFileStorage(t *testing.T) *file.FileStorage {
+	t.Helper()
+	fs, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("failed to create file storage: %v", err)
+	}
+	return fs
+}
+
+func seed(t *testing.T, repo storage.Repository) {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Test SP", Prefix: "10.99999"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"a", "b", "c"} {
+		id := "https://raid.org/10.99999/" + suffix
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+		updated := &models.RAiD{Identifier: &models.Identifier{ID: id}, Title: []models.Title{{Text: "Updated"}}}
+		if _, err := repo.UpdateRAiD(storage.WithActor(ctx, "alice"), "10.99999", suffix, updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.DeleteRAiD(ctx, "10.99999", "c"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newFileStorage(t), newFileStorage(t)
+	seed(t, src)
+
+	var phases []string
+	summary, err := Run(ctx, src, dst, Options{
+		Verify:   true,
+		Progress: func(phase string, s Summary) { phases = append(phases, phase) },
+	})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if summary.RAiDs != 3 || summary.Versions != 6 || summary.ServicePoints != 1 || summary.Verified != 4 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+	if len(phases) != 5 || phases[4] != "verify" {
+		t.Errorf("unexpected progress phases: %v", phases)
+	}
+
+	if _, err := dst.GetRAiDVersion(ctx, "10.99999", "a", 1); err != nil {
+		t.Errorf("expected history to be migrated: %v", err)
+	}
+	if changes, err := dst.GetRAiDChanges(ctx, "10.99999", "a", nil); err != nil || len(changes) != 2 || changes[1].Actor != "alice" {
+		t.Errorf("expected the actor of version 2 to be migrated, got %+v, %v", changes, err)
+	}
+	if _, err := dst.GetRAiD(ctx, "10.99999", "c"); err != storage.ErrNotFound {
+		t.Errorf("expected deleted RAiD to stay deleted, got %v", err)
+	}
+
+	// A second run needs -resume and then skips everything
+	if _, err := Run(ctx, src, dst, Options{}); !errors.Is(err, backup.ErrNotEmpty) {
+		t.Errorf("expected ErrNotEmpty without resume, got %v", err)
+	}
+	summary, err = Run(ctx, src, dst, Options{Resume: true, Verify: true})
+	if err != nil {
+		t.Fatalf("resumed Run failed: %v", err)
+	}
+	if summary.RAiDs != 0 || summary.Skipped != 4 || summary.Verified != 4 {
+		t.Errorf("unexpected resumed summary: %+v", summary)
+	}
+}
+
+func TestRun_DetectsMismatch(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newFileStorage(t), newFileStorage(t)
+	seed(t, src)
+
+	// A partially migrated target whose copy of "a" has diverged
+	if _, err := dst.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"},
+		Title:      []models.Title{{Text: "Diverged"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Run(ctx, src, dst, Options{Resume: true, Verify: true})
+	if !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+func TestRun_SideStores(t *testing.T) {
+	ctx := context.Background()
+	src, dst := newFileStorage(t), newFileStorage(t)
+	seed(t, src)
+	sps, err := src.ListServicePoints(ctx)
+	if err != nil || len(sps) != 1 {
+		t.Fatalf("expected the seeded service point, got %v, %v", sps, err)
+	}
+	sp := sps[0].ID
+	owner := storage.SearchOwnerServicePoint + strconv.FormatInt(sp, 10)
+
+	if _, err := src.AddTags(ctx, sp, "10.99999", "a", []string{"funded"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveDraft(ctx, &models.Draft{ID: "d1", ServicePoint: sp, RAiD: &models.RAiD{}, Created: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveSearch(ctx, &models.SavedSearch{Owner: owner, Name: "mine", Query: map[string]string{"q": "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveFollower(ctx, &models.Follower{ID: "https://social.example/users/a", Inbox: "https://social.example/inbox"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: "c", Actor: "admin", Purged: time.Now().UTC()}); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("data management plan")
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	if err := src.PutBlob(ctx, hash, content); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.SaveAttachment(ctx, &models.Attachment{Hash: hash, Prefix: "10.99999", Suffix: "a", Name: "dmp.txt", Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := Run(ctx, src, dst, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	for store, want := range map[string]int{"tags": 1, "drafts": 1, "searches": 1, "followers": 1, "purges": 1, "attachments": 1} {
+		if summary.Side[store] != want {
+			t.Errorf("expected %d %s copied, got %+v", want, store, summary.Side)
+		}
+	}
+
+	if tags, err := dst.GetTags(ctx, sp, "10.99999", "a"); err != nil || len(tags) != 1 || tags[0] != "funded" {
+		t.Errorf("expected the tag to be copied, got %v, %v", tags, err)
+	}
+	if _, err := dst.GetDraft(ctx, "d1"); err != nil {
+		t.Errorf("expected the draft to be copied: %v", err)
+	}
+	if _, err := dst.GetSearch(ctx, owner, "mine"); err != nil {
+		t.Errorf("expected the saved search to be copied: %v", err)
+	}
+	if followers, err := dst.ListFollowers(ctx); err != nil || len(followers) != 1 {
+		t.Errorf("expected the follower to be copied, got %v, %v", followers, err)
+	}
+	if purges, err := dst.ListPurges(ctx); err != nil || len(purges) != 1 || purges[0].Suffix != "c" {
+		t.Errorf("expected the purge to be copied, got %v, %v", purges, err)
+	}
+	if data, err := dst.GetBlob(ctx, hash); err != nil || string(data) != string(content) {
+		t.Errorf("expected the attachment content to be copied, got %q, %v", data, err)
+	}
+	if _, err := dst.GetAttachment(ctx, "10.99999", "a", hash); err != nil {
+		t.Errorf("expected the attachment to be copied: %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// handle names a copied RAiD
+type handle struct {
+	prefix, suffix string
+}
+
+// copySideStores copies what the stores beyond Repository hold in src to
+// dst, where both have them, counting records in summary.Side: the records
+// of purges and redactions, the drafts, scheduled changes, proposals and
+// shared saved searches of every service point, the followers, and the
+// tags and attachments of raids. Every copy replaces what dst holds, so a
+// resumed migration copies them again. Reservations and the saved searches
+// of single users cannot be listed and are not copied.
+func copySideStores(ctx context.Context, src, dst storage.Repository, sps []*models.ServicePoint, raids []handle, summary *Summary) error {
+	count := func(store string, n int) {
+		if summary.Side == nil {
+			summary.Side = make(map[string]int)
+		}
+		summary.Side[store] += n
+	}
+	// Side records made without a service point belong to none
+	ids := []int64{0}
+	for _, sp := range sps {
+		ids = append(ids, sp.ID)
+	}
+
+	if from, ok := src.(storage.Purger); ok {
+		if to, ok := dst.(storage.AuditImporter); ok {
+			purges, err := from.ListPurges(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list purges: %w", err)
+			}
+			for _, purge := range purges {
+				if err := to.ImportPurge(ctx, purge); err != nil {
+					return fmt.Errorf("failed to copy purge of %s/%s: %w", purge.Prefix, purge.Suffix, err)
+				}
+			}
+			count("purges", len(purges))
+		}
+	}
+	if from, ok := src.(storage.Redactor); ok {
+		if to, ok := dst.(storage.AuditImporter); ok {
+			redactions, err := from.ListRedactions(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list redactions: %w", err)
+			}
+			for _, redaction := range redactions {
+				if err := to.ImportRedaction(ctx, redaction); err != nil {
+					return fmt.Errorf("failed to copy redaction of %s/%s: %w", redaction.Prefix, redaction.Suffix, err)
+				}
+			}
+			count("redactions", len(redactions))
+		}
+	}
+
+	if from, ok := src.(storage.DraftStore); ok {
+		if to, ok := dst.(storage.DraftStore); ok {
+			for _, id := range ids {
+				drafts, err := from.ListDrafts(ctx, id)
+				if err != nil {
+					return fmt.Errorf("failed to list drafts of service point %d: %w", id, err)
+				}
+				for _, draft := range drafts {
+					if err := to.SaveDraft(ctx, draft); err != nil {
+						return fmt.Errorf("failed to copy draft %s: %w", draft.ID, err)
+					}
+				}
+				count("drafts", len(drafts))
+			}
+		}
+	}
+	if from, ok := src.(storage.ScheduleStore); ok {
+		if to, ok := dst.(storage.ScheduleStore); ok {
+			for _, id := range ids {
+				changes, err := from.ListScheduledChanges(ctx, id)
+				if err != nil {
+					return fmt.Errorf("failed to list scheduled changes of service point %d: %w", id, err)
+				}
+				for _, change := range changes {
+					if err := to.SaveScheduledChange(ctx, change); err != nil {
+						return fmt.Errorf("failed to copy scheduled change %s: %w", change.ID, err)
+					}
+				}
+				count("schedules", len(changes))
+			}
+		}
+	}
+	if from, ok := src.(storage.ProposalStore); ok {
+		if to, ok := dst.(storage.ProposalStore); ok {
+			for _, id := range ids {
+				proposals, err := from.ListProposals(ctx, id)
+				if err != nil {
+					return fmt.Errorf("failed to list proposals of service point %d: %w", id, err)
+				}
+				for _, proposal := range proposals {
+					if err := to.SaveProposal(ctx, proposal); err != nil {
+						return fmt.Errorf("failed to copy proposal %s: %w", proposal.ID, err)
+					}
+				}
+				count("proposals", len(proposals))
+			}
+		}
+	}
+	if from, ok := src.(storage.SearchStore); ok {
+		if to, ok := dst.(storage.SearchStore); ok {
+			for _, sp := range sps {
+				searches, err := from.ListSearches(ctx, storage.SearchOwnerServicePoint+strconv.FormatInt(sp.ID, 10))
+				if err != nil {
+					return fmt.Errorf("failed to list saved searches of service point %d: %w", sp.ID, err)
+				}
+				for _, search := range searches {
+					if err := to.SaveSearch(ctx, search); err != nil {
+						return fmt.Errorf("failed to copy saved search %s of %s: %w", search.Name, search.Owner, err)
+					}
+				}
+				count("searches", len(searches))
+			}
+		}
+	}
+	if from, ok := src.(storage.FollowerStore); ok {
+		if to, ok := dst.(storage.FollowerStore); ok {
+			followers, err := from.ListFollowers(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to list followers: %w", err)
+			}
+			for _, follower := range followers {
+				if err := to.SaveFollower(ctx, follower); err != nil {
+					return fmt.Errorf("failed to copy follower %s: %w", follower.ID, err)
+				}
+			}
+			count("followers", len(followers))
+		}
+	}
+
+	// Tags are looked up by service point and RAiD, as they cannot be
+	// listed otherwise
+	if from, ok := src.(storage.TagStore); ok {
+		if to, ok := dst.(storage.TagStore); ok {
+			for _, id := range ids {
+				for _, raid := range raids {
+					tags, err := from.GetTags(ctx, id, raid.prefix, raid.suffix)
+					if err != nil {
+						return fmt.Errorf("failed to read tags of %s/%s: %w", raid.prefix, raid.suffix, err)
+					}
+					if len(tags) == 0 {
+						continue
+					}
+					if _, err := to.AddTags(ctx, id, raid.prefix, raid.suffix, tags); err != nil {
+						return fmt.Errorf("failed to copy tags of %s/%s: %w", raid.prefix, raid.suffix, err)
+					}
+					count("tags", len(tags))
+				}
+			}
+		}
+	}
+
+	// Attachment content kept outside the backend, such as in S3, is not
+	// in the source and stays where it is
+	if from, ok := src.(storage.AttachmentStore); ok {
+		if to, ok := dst.(storage.AttachmentStore); ok {
+			fromBlobs, _ := src.(storage.BlobStore)
+			toBlobs, _ := dst.(storage.BlobStore)
+			for _, raid := range raids {
+				attachments, err := from.ListAttachments(ctx, raid.prefix, raid.suffix)
+				if err != nil {
+					return fmt.Errorf("failed to list attachments of %s/%s: %w", raid.prefix, raid.suffix, err)
+				}
+				for _, attachment := range attachments {
+					if fromBlobs != nil && toBlobs != nil {
+						data, err := fromBlobs.GetBlob(ctx, attachment.Hash)
+						if err == nil {
+							err = toBlobs.PutBlob(ctx, attachment.Hash, data)
+						} else if errors.Is(err, storage.ErrNotFound) {
+							err = nil
+						}
+						if err != nil {
+							return fmt.Errorf("failed to copy attachment %s of %s/%s: %w", attachment.Hash, raid.prefix, raid.suffix, err)
+						}
+					}
+					if err := to.SaveAttachment(ctx, attachment); err != nil {
+						return fmt.Errorf("failed to copy attachment %s of %s/%s: %w", attachment.Hash, raid.prefix, raid.suffix, err)
+					}
+				}
+				count("attachments", len(attachments))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Extensions holds the properties of a JSON object that its Go type does
+// not define, such as x-... extensions and local fields, so that they
+// survive being stored, read back and updated
+type Extensions map[string]json.RawMessage
+
+// knownFields caches the lower-cased JSON property names of struct types
+var knownFields sync.Map
+
+// fieldNames returns the lower-cased JSON property names of struct type t.
+// encoding/json matches names ignoring case, and so does this.
+func fieldNames(t reflect.Type) map[string]bool {
+	if names, ok := knownFields.Load(t); ok {
+		return names.(map[string]bool)
+	}
+	names := make(map[string]bool, t.NumField())
+	for name := range fieldTypes(t) {
+		names[name] = true
+	}
+	knownFields.Store(t, names)
+	return names
+}
+
+// fieldTypes returns the types of the fields of struct type t by their
+// lower-cased JSON property names
+func fieldTypes(t reflect.Type) map[string]reflect.Type {
+	types := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		types[strings.ToLower(name)] = f.Type
+	}
+	return types
+}
+
+var unmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// UnknownProperties returns the paths, such as "title[0].lang", of the
+// properties of the JSON document data that the type of v does not define,
+// other than x-... extensions. Values of types decoding themselves, other
+// than by keeping extensions, are not looked into.
+func UnknownProperties(data []byte, v any) []string {
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil
+	}
+	var paths []string
+	unknownProperties(doc, reflect.TypeOf(v), "", &paths)
+	slices.Sort(paths)
+	return paths
+}
+
+func unknownProperties(doc any, t reflect.Type, path string, paths *[]string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch doc := doc.(type) {
+	case map[string]any:
+		if t.Kind() == reflect.Map {
+			for name, value := range doc {
+				unknownProperties(value, t.Elem(), joinPath(path, name), paths)
+			}
+			return
+		}
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		if _, extended := t.FieldByName("Extensions"); !extended && reflect.PointerTo(t).Implements(unmarshalerType) {
+			return
+		}
+		fields := fieldTypes(t)
+		for name, value := range doc {
+			field, ok := fields[strings.ToLower(name)]
+			switch {
+			case ok:
+				unknownProperties(value, field, joinPath(path, name), paths)
+			case !strings.HasPrefix(name, "x-"):
+				*paths = append(*paths, joinPath(path, name))
+			}
+		}
+	case []any:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, value := range doc {
+			unknownProperties(value, t.Elem(), fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// decodeExtended decodes data into v, a pointer to a struct type without
+// the JSON methods of the type being decoded, and returns the properties of
+// data that v does not define
+func decodeExtended(data []byte, v any) (Extensions, error) {
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, nil
+	}
+	known := fieldNames(reflect.TypeOf(v).Elem())
+	var ext Extensions
+	for name, raw := range all {
+		if known[strings.ToLower(name)] {
+			continue
+		}
+		if ext == nil {
+			ext = make(Extensions)
+		}
+		ext[name] = raw
+	}
+	return ext, nil
+}
+
+// encodeExtended encodes v, a struct without the JSON methods of the type
+// being encoded, adding the properties of ext that v does not define
+func encodeExtended(v any, ext Extensions) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(ext) == 0 {
+		return data, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+	known := fieldNames(reflect.TypeOf(v))
+	for name, raw := range ext {
+		if !known[strings.ToLower(name)] {
+			all[name] = raw
+		}
+	}
+	return json.Marshal(all)
+}
+
+// MarshalJSON encodes the RAiD with its extensions
+func (r RAiD) MarshalJSON() ([]byte, error) {
+	type plain RAiD
+	return encodeExtended(plain(r), r.Extensions)
+}
+
+// UnmarshalJSON decodes a RAiD, keeping unknown properties as extensions
+func (r *RAiD) UnmarshalJSON(data []byte) error {
+	type plain RAiD
+	ext, err := decodeExtended(data, (*plain)(r))
+	r.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a title with its extensions
+func (t Title) MarshalJSON() ([]byte, error) {
+	type plain Title
+	return encodeExtended(plain(t), t.Extensions)
+}
+
+// UnmarshalJSON decodes a title, keeping unknown properties as
+// extensions
+func (t *Title) UnmarshalJSON(data []byte) error {
+	type plain Title
+	ext, err := decodeExtended(data, (*plain)(t))
+	t.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a description with its extensions
+func (d Description) MarshalJSON() ([]byte, error) {
+	type plain Description
+	return encodeExtended(plain(d), d.Extensions)
+}
+
+// UnmarshalJSON decodes a description, keeping unknown properties as
+// extensions
+func (d *Description) UnmarshalJSON(data []byte) error {
+	type plain Description
+	ext, err := decodeExtended(data, (*plain)(d))
+	d.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a contributor with its extensions
+func (c Contributor) MarshalJSON() ([]byte, error) {
+	type plain Contributor
+	return encodeExtended(plain(c), c.Extensions)
+}
+
+// UnmarshalJSON decodes a contributor, keeping unknown properties as
+// extensions
+func (c *Contributor) UnmarshalJSON(data []byte) error {
+	type plain Contributor
+	ext, err := decodeExtended(data, (*plain)(c))
+	c.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes an organisation with its extensions
+func (o Organisation) MarshalJSON() ([]byte, error) {
+	type plain Organisation
+	return encodeExtended(plain(o), o.Extensions)
+}
+
+// UnmarshalJSON decodes an organisation, keeping unknown properties as
+// extensions
+func (o *Organisation) UnmarshalJSON(data []byte) error {
+	type plain Organisation
+	ext, err := decodeExtended(data, (*plain)(o))
+	o.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a subject with its extensions
+func (s Subject) MarshalJSON() ([]byte, error) {
+	type plain Subject
+	return encodeExtended(plain(s), s.Extensions)
+}
+
+// UnmarshalJSON decodes a subject, keeping unknown properties as
+// extensions
+func (s *Subject) UnmarshalJSON(data []byte) error {
+	type plain Subject
+	ext, err := decodeExtended(data, (*plain)(s))
+	s.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a related RAiD with its extensions
+func (r RelatedRAiD) MarshalJSON() ([]byte, error) {
+	type plain RelatedRAiD
+	return encodeExtended(plain(r), r.Extensions)
+}
+
+// UnmarshalJSON decodes a related RAiD, keeping unknown properties as
+// extensions
+func (r *RelatedRAiD) UnmarshalJSON(data []byte) error {
+	type plain RelatedRAiD
+	ext, err := decodeExtended(data, (*plain)(r))
+	r.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a related object with its extensions
+func (r RelatedObject) MarshalJSON() ([]byte, error) {
+	type plain RelatedObject
+	return encodeExtended(plain(r), r.Extensions)
+}
+
+// UnmarshalJSON decodes a related object, keeping unknown properties as
+// extensions
+func (r *RelatedObject) UnmarshalJSON(data []byte) error {
+	type plain RelatedObject
+	ext, err := decodeExtended(data, (*plain)(r))
+	r.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes an alternate identifier with its extensions
+func (a AlternateIdentifier) MarshalJSON() ([]byte, error) {
+	type plain AlternateIdentifier
+	return encodeExtended(plain(a), a.Extensions)
+}
+
+// UnmarshalJSON decodes an alternate identifier, keeping unknown properties as
+// extensions
+func (a *AlternateIdentifier) UnmarshalJSON(data []byte) error {
+	type plain AlternateIdentifier
+	ext, err := decodeExtended(data, (*plain)(a))
+	a.Extensions = ext
+	return err
+}
+
+// MarshalJSON encodes a spatial coverage with its extensions
+func (s SpatialCoverage) MarshalJSON() ([]byte, error) {
+	type plain SpatialCoverage
+	return encodeExtended(plain(s), s.Extensions)
+}
+
+// UnmarshalJSON decodes a spatial coverage, keeping unknown properties as
+// extensions
+func (s *SpatialCoverage) UnmarshalJSON(data []byte) error {
+	type plain SpatialCoverage
+	ext, err := decodeExtended(data, (*plain)(s))
+	s.Extensions = ext
+	return err
+}
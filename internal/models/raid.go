@@ -1,6 +1,13 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // RAiD represents a Research Activity Identifier
 type RAiD struct {
@@ -19,12 +26,106 @@ type RAiD struct {
 	AlternateIdentifier  []AlternateIdentifier  `json:"alternateIdentifier,omitempty"`
 	SpatialCoverage      []SpatialCoverage      `json:"spatialCoverage,omitempty"`
 	TraditionalKnowledge []TraditionalKnowledge `json:"traditionalKnowledgeLabel,omitempty"`
+	Deprecation          *Deprecation           `json:"deprecation,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
-// Metadata contains timestamps for RAiD creation and updates
+// Deprecation marks a RAiD that is no longer in use, optionally pointing to
+// the RAiD that supersedes it. Deprecated RAiDs keep their history.
+type Deprecation struct {
+	SupersededBy string `json:"supersededBy,omitempty"`
+	Reason       string `json:"reason,omitempty"`
+	Date         string `json:"date"`
+}
+
+// Metadata contains timestamps for RAiD creation and updates. On the wire
+// they are Unix timestamps in seconds, as in the RAiD API.
 type Metadata struct {
 	Created time.Time `json:"created,omitempty"`
 	Updated time.Time `json:"updated,omitempty"`
+	// Deleted marks a soft deleted RAiD in listings that include them, and
+	// DeletedAt when it was deleted, if known; they are never stored
+	Deleted   bool      `json:"deleted,omitempty"`
+	DeletedAt time.Time `json:"deletedAt,omitempty"`
+}
+
+type metadataJSON struct {
+	Created   json.RawMessage `json:"created,omitempty"`
+	Updated   json.RawMessage `json:"updated,omitempty"`
+	Deleted   bool            `json:"deleted,omitempty"`
+	DeletedAt json.RawMessage `json:"deletedAt,omitempty"`
+}
+
+// MarshalJSON encodes the timestamps as seconds since the epoch. The
+// fraction keeps full precision so that stored documents round-trip exactly.
+// Zero timestamps are omitted.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	return json.Marshal(metadataJSON{
+		Created:   epochSeconds(m.Created),
+		Updated:   epochSeconds(m.Updated),
+		Deleted:   m.Deleted,
+		DeletedAt: epochSeconds(m.DeletedAt),
+	})
+}
+
+// UnmarshalJSON accepts epoch seconds and, for documents stored by earlier
+// versions, RFC 3339 strings
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	var raw metadataJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	var err error
+	if m.Created, err = parseTimestamp(raw.Created); err != nil {
+		return fmt.Errorf("metadata.created: %w", err)
+	}
+	if m.Updated, err = parseTimestamp(raw.Updated); err != nil {
+		return fmt.Errorf("metadata.updated: %w", err)
+	}
+	if m.DeletedAt, err = parseTimestamp(raw.DeletedAt); err != nil {
+		return fmt.Errorf("metadata.deletedAt: %w", err)
+	}
+	m.Deleted = raw.Deleted
+	return nil
+}
+
+func epochSeconds(t time.Time) json.RawMessage {
+	if t.IsZero() {
+		return nil
+	}
+	secs := strconv.FormatInt(t.Unix(), 10)
+	if ns := t.Nanosecond(); ns != 0 {
+		secs += strings.TrimRight(fmt.Sprintf(".%09d", ns), "0")
+	}
+	return json.RawMessage(secs)
+}
+
+func parseTimestamp(data json.RawMessage) (time.Time, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return time.Time{}, nil
+	}
+	if data[0] == '"' {
+		var t time.Time
+		err := json.Unmarshal(data, &t)
+		return t, err
+	}
+
+	// Parse plain decimals exactly; anything else goes through float64
+	whole, frac, _ := strings.Cut(string(data), ".")
+	if strings.Trim(whole+frac, "0123456789") == "" && whole != "" && len(frac) <= 9 {
+		secs, err := strconv.ParseInt(whole, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		ns, _ := strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64)
+		return time.Unix(secs, ns).UTC(), nil
+	}
+	f, err := strconv.ParseFloat(string(data), 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(math.Round(f * 1000))).UTC(), nil
 }
 
 // Identifier represents the RAiD identifier with all its components
@@ -58,8 +159,19 @@ type Title struct {
 	StartDate string    `json:"startDate"`
 	EndDate   string    `json:"endDate,omitempty"`
 	Language  *Language `json:"language,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
+// Title type vocabulary identifiers
+const (
+	TitleTypeSchema      = "https://vocabulary.raid.org/title.type.schema/376"
+	TitleTypeAlternative = "https://vocabulary.raid.org/title.type.schema/4"
+	TitleTypePrimary     = "https://vocabulary.raid.org/title.type.schema/5"
+	TitleTypeAcronym     = "https://vocabulary.raid.org/title.type.schema/156"
+	TitleTypeShort       = "https://vocabulary.raid.org/title.type.schema/157"
+)
+
 // Date contains start and end dates for the research activity
 type Date struct {
 	StartDate string `json:"startDate"`
@@ -71,6 +183,8 @@ type Description struct {
 	Text     string    `json:"text"`
 	Type     *IDSchema `json:"type"`
 	Language *Language `json:"language,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
 // Access defines the access type and optional embargo information
@@ -98,8 +212,19 @@ type Contributor struct {
 	Role          []IDSchema            `json:"role"`
 	Leader        bool                  `json:"leader,omitempty"`
 	Contact       bool                  `json:"contact,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
+// Contributor statuses
+const (
+	// ContributorStatusPending marks a contributor invited by email who has
+	// not yet attached an ORCID iD
+	ContributorStatusPending         = "PENDING_AUTHENTICATION"
+	ContributorStatusAuthenticated   = "AUTHENTICATED"
+	ContributorStatusUnauthenticated = "UNAUTHENTICATED"
+)
+
 // ContributorPosition represents a contributor's position with dates
 type ContributorPosition struct {
 	SchemaURI string `json:"schemaUri"`
@@ -113,6 +238,8 @@ type Organisation struct {
 	ID        string             `json:"id"`
 	SchemaURI string             `json:"schemaUri"`
 	Role      []OrganisationRole `json:"role"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
 // OrganisationRole represents an organisation's role with dates
@@ -123,6 +250,18 @@ type OrganisationRole struct {
 	EndDate   string `json:"endDate,omitempty"`
 }
 
+// Organisation role vocabulary identifiers
+const (
+	OrganisationRoleSchema        = "https://vocabulary.raid.org/organisation.role.schema/359"
+	OrganisationRoleLead          = "https://vocabulary.raid.org/organisation.role.schema/182"
+	OrganisationRoleOtherResearch = "https://vocabulary.raid.org/organisation.role.schema/183"
+	OrganisationRolePartner       = "https://vocabulary.raid.org/organisation.role.schema/184"
+	OrganisationRoleContractor    = "https://vocabulary.raid.org/organisation.role.schema/185"
+	OrganisationRoleFunder        = "https://vocabulary.raid.org/organisation.role.schema/186"
+	OrganisationRoleFacility      = "https://vocabulary.raid.org/organisation.role.schema/187"
+	OrganisationRoleOther         = "https://vocabulary.raid.org/organisation.role.schema/188"
+)
+
 // AlternateURL represents an alternate URL for the RAiD
 type AlternateURL struct {
 	URL string `json:"url"`
@@ -133,6 +272,8 @@ type Subject struct {
 	ID        string           `json:"id"`
 	SchemaURI string           `json:"schemaUri"`
 	Keyword   []SubjectKeyword `json:"keyword,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
 // SubjectKeyword represents a keyword with optional language
@@ -145,6 +286,39 @@ type SubjectKeyword struct {
 type RelatedRAiD struct {
 	ID   string    `json:"id"`
 	Type *IDSchema `json:"type,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
+}
+
+// Related RAiD type vocabulary identifiers
+const (
+	RelatedRAiDTypeSchema        = "https://vocabulary.raid.org/related-raid.type.schema/367"
+	RelatedRAiDTypeContinues     = "https://vocabulary.raid.org/related-raid.type.schema/198"
+	RelatedRAiDTypeHasPart       = "https://vocabulary.raid.org/related-raid.type.schema/199"
+	RelatedRAiDTypeIsContinuedBy = "https://vocabulary.raid.org/related-raid.type.schema/200"
+	RelatedRAiDTypeIsDerivedFrom = "https://vocabulary.raid.org/related-raid.type.schema/201"
+	RelatedRAiDTypeHasDerivation = "https://vocabulary.raid.org/related-raid.type.schema/202"
+	RelatedRAiDTypeIsPartOf      = "https://vocabulary.raid.org/related-raid.type.schema/203"
+	RelatedRAiDTypeIsObsoletedBy = "https://vocabulary.raid.org/related-raid.type.schema/204"
+	RelatedRAiDTypeObsoletes     = "https://vocabulary.raid.org/related-raid.type.schema/205"
+)
+
+var inverseRelatedRAiDTypes = map[string]string{
+	RelatedRAiDTypeContinues:     RelatedRAiDTypeIsContinuedBy,
+	RelatedRAiDTypeIsContinuedBy: RelatedRAiDTypeContinues,
+	RelatedRAiDTypeHasPart:       RelatedRAiDTypeIsPartOf,
+	RelatedRAiDTypeIsPartOf:      RelatedRAiDTypeHasPart,
+	RelatedRAiDTypeIsDerivedFrom: RelatedRAiDTypeHasDerivation,
+	RelatedRAiDTypeHasDerivation: RelatedRAiDTypeIsDerivedFrom,
+	RelatedRAiDTypeObsoletes:     RelatedRAiDTypeIsObsoletedBy,
+	RelatedRAiDTypeIsObsoletedBy: RelatedRAiDTypeObsoletes,
+}
+
+// InverseRelatedRAiDType returns the type of the relation from B to A
+// matching a relation of type id from A to B
+func InverseRelatedRAiDType(id string) (string, bool) {
+	inverse, ok := inverseRelatedRAiDTypes[id]
+	return inverse, ok
 }
 
 // RelatedObject represents a related object with type and categories
@@ -153,12 +327,16 @@ type RelatedObject struct {
 	SchemaURI string     `json:"schemaUri,omitempty"`
 	Type      *IDSchema  `json:"type,omitempty"`
 	Category  []IDSchema `json:"category,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
 // AlternateIdentifier represents an alternate identifier
 type AlternateIdentifier struct {
 	ID   string `json:"id"`
 	Type string `json:"type,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
 // SpatialCoverage represents spatial coverage with places
@@ -166,6 +344,8 @@ type SpatialCoverage struct {
 	ID        string                 `json:"id"`
 	SchemaURI string                 `json:"schemaUri,omitempty"`
 	Place     []SpatialCoveragePlace `json:"place,omitempty"`
+	// Extensions keeps properties the type does not define
+	Extensions Extensions `json:"-"`
 }
 
 // SpatialCoveragePlace represents a place with optional language
@@ -184,6 +364,9 @@ type TraditionalKnowledge struct {
 type Language struct {
 	ID        string `json:"id"`
 	SchemaURI string `json:"schemaUri"`
+	// AutoDetected marks a language guessed from the text rather than
+	// given by the client
+	AutoDetected bool `json:"autoDetected,omitempty"`
 }
 
 // IDSchema is a generic type for identifier/schema pairs
@@ -205,14 +388,411 @@ type ServicePoint struct {
 	AdminEmail       string `json:"adminEmail"`
 	Enabled          bool   `json:"enabled"`
 	AppWritesEnabled bool   `json:"appWritesEnabled,omitempty"`
+	// Prefixes, when set, replaces Prefix with a pool of prefixes that new
+	// RAiDs are allocated from according to PrefixAllocation
+	Prefixes         []PrefixPool `json:"prefixes,omitempty"`
+	PrefixAllocation string       `json:"prefixAllocation,omitempty"`
+	// AgencyID is the registration agency the service point belongs to in
+	// a deployment hosting several
+	AgencyID string `json:"agencyId,omitempty"`
+	// MaxRAiDs caps the RAiDs the service point may own, deleted ones
+	// aside; 0 means no limit
+	MaxRAiDs int `json:"maxRaids,omitempty"`
+	// Defaults fills in what requests to mint RAiDs for the service point
+	// leave out
+	Defaults *MintDefaults `json:"defaults,omitempty"`
+	// Deactivation, when set, records that the service point was soft
+	// deleted; it no longer mints RAiDs
+	Deactivation *ServicePointDeactivation `json:"deactivation,omitempty"`
+	// Notifications chooses who is emailed about the service point's RAiDs
+	Notifications *NotificationSettings `json:"notifications,omitempty"`
+	// Attachments limits the documents the service point attaches to its
+	// RAiDs, within the deployment's limits
+	Attachments *AttachmentPolicy `json:"attachments,omitempty"`
+	// Region homes the service point's RAiDs in a region of a multi-region
+	// CockroachDB deployment; unknown regions fall back to the minting
+	// instance's
+	Region string `json:"region,omitempty"`
+}
+
+// AttachmentPolicy limits the documents attached to RAiDs
+type AttachmentPolicy struct {
+	// MediaTypes lists the media types accepted, e.g. application/pdf, or
+	// text/* for all text types; empty accepts any
+	MediaTypes []string `json:"mediaTypes,omitempty"`
+	// MaxBytes caps the size of a document; 0 means no cap of its own
+	MaxBytes int64 `json:"maxBytes,omitempty"`
+}
+
+// NotificationSettings chooses who a service point's notifications go to
+type NotificationSettings struct {
+	// Recipients are the addresses notified; empty means AdminEmail
+	Recipients []string `json:"recipients,omitempty"`
+	// Events, when set, lists the events notified; others are not
+	Events []string `json:"events,omitempty"`
+}
+
+// MintDefaults are the values a service point merges into the RAiDs minted
+// for it. Each applies only where the mint request has none; Organisation
+// entries are added unless the request lists the same organisation.
+type MintDefaults struct {
+	RegistrationAgency *RegistrationAgency `json:"registrationAgency,omitempty"`
+	Owner              *Owner              `json:"owner,omitempty"`
+	License            string              `json:"license,omitempty"`
+	Access             *Access             `json:"access,omitempty"`
+	Organisation       []Organisation      `json:"organisation,omitempty"`
+}
+
+// ServicePointDeactivation records the soft deletion of a service point and
+// what became of its RAiDs
+type ServicePointDeactivation struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason,omitempty"`
+	// Actor is the authenticated user who deactivated the service point,
+	// if recorded
+	Actor string `json:"actor,omitempty"`
+	// RAiDs is what became of the RAiDs owned at the time: "none" if there
+	// were none, "read-only" if they are kept but can no longer be
+	// changed, or "transferred" to the service point TransferredTo
+	RAiDs         string `json:"raids"`
+	Count         int    `json:"count,omitempty"`
+	TransferredTo int64  `json:"transferredTo,omitempty"`
+}
+
+// PrefixPool is a handle prefix, or a range of prefixes, owned by a service
+// point
+type PrefixPool struct {
+	Prefix string `json:"prefix"`
+	// Last ends a range of prefixes that differ only in their final
+	// numeric component, e.g. 10.82841.1 to 10.82841.4
+	Last string `json:"last,omitempty"`
+	// ProjectTypes reserves the pool for RAiDs of these project types when
+	// prefixes are allocated by project type
+	ProjectTypes []string `json:"projectTypes,omitempty"`
 }
 
 // RAiDChange represents a change to a RAiD
 type RAiDChange struct {
 	Handle    string    `json:"handle"`
 	Version   int       `json:"version"`
-	Diff      string    `json:"diff"` // Base64 encoded JSON Patch (RFC 6902)
+	Diff      string    `json:"diff,omitempty"` // Base64 encoded JSON Patch (RFC 6902), left out of summaries
+	Timestamp time.Time `json:"timestamp"`
+	// Actor is the authenticated user who wrote the version, if recorded
+	Actor string `json:"actor,omitempty"`
+}
+
+// AccessChange is a point in a RAiD's access timeline
+type AccessChange struct {
+	// Version is the version that made the change, or for a lapsed
+	// embargo the version that set it
+	Version   int       `json:"version"`
 	Timestamp time.Time `json:"timestamp"`
+	// Type is the access type ID from then on
+	Type          string `json:"type"`
+	EmbargoExpiry string `json:"embargoExpiry,omitempty"`
+	// Lapsed marks the RAiD becoming open because its embargo expired
+	Lapsed bool `json:"lapsed,omitempty"`
+}
+
+// Citation is a frozen citation of one version of a RAiD
+type Citation struct {
+	ID      string `json:"id"`
+	Version int    `json:"version"`
+	Title   string `json:"title"`
+	// Contributors are the contributor IDs of the version, leaders first
+	Contributors []string `json:"contributors"`
+	// Date is the UTC date the version was written
+	Date string `json:"date,omitempty"`
+	// Publisher is the registration agency ID
+	Publisher string `json:"publisher,omitempty"`
+	// Text is the citation formatted for reference lists
+	Text string `json:"text"`
+}
+
+// Completeness scores a RAiD against the completeness rubric
+type Completeness struct {
+	ID string `json:"id"`
+	// Score is the weighted share of criteria met, from 0 to 100
+	Score   int      `json:"score"`
+	Met     []string `json:"met"`
+	Missing []string `json:"missing"`
+}
+
+// ContributorRAiDs lists the RAiDs a contributor appears in
+type ContributorRAiDs struct {
+	// Contributor is the ORCID iD as a URL
+	Contributor string            `json:"contributor"`
+	RAiDs       []ContributorRAiD `json:"raids"`
+	Page        Page              `json:"page"`
+}
+
+// ContributorRAiD is a RAiD a contributor appears in, with their part in it
+type ContributorRAiD struct {
+	ID       string                `json:"id"`
+	Title    string                `json:"title,omitempty"`
+	Leader   bool                  `json:"leader,omitempty"`
+	Contact  bool                  `json:"contact,omitempty"`
+	Position []ContributorPosition `json:"position"`
+	Role     []IDSchema            `json:"role"`
+	// StartDate and EndDate span the contributor's positions; EndDate is
+	// empty while any position is open
+	StartDate string `json:"startDate,omitempty"`
+	EndDate   string `json:"endDate,omitempty"`
+}
+
+// OrganisationRAiDs lists the RAiDs an organisation appears in, grouped by
+// its role in them
+type OrganisationRAiDs struct {
+	// Organisation is the ROR ID as a URL
+	Organisation string `json:"organisation"`
+	// Total is the number of RAiDs listed over all pages, and Counts the
+	// number of RAiDs per role regardless of any role filter
+	Total  int            `json:"total"`
+	Counts map[string]int `json:"counts"`
+	// Roles groups the RAiDs of this page by role; a RAiD the organisation
+	// has several roles in appears in each group
+	Roles map[string][]OrganisationRAiD `json:"roles"`
+	Page  Page                          `json:"page"`
+}
+
+// OrganisationRAiD is a RAiD an organisation appears in, with its roles
+type OrganisationRAiD struct {
+	ID    string             `json:"id"`
+	Title string             `json:"title,omitempty"`
+	Role  []OrganisationRole `json:"role"`
+}
+
+// Facets of a search, which counts the RAiDs found by each value of them
+const (
+	FacetSubject      = "subject"
+	FacetOrganisation = "organisation"
+	FacetAccessType   = "accessType"
+	FacetStartYear    = "startYear"
+	FacetServicePoint = "servicePoint"
+)
+
+// SearchResult is a page of the public RAiDs a search finds, with the RAiDs
+// found over all pages counted by facet
+type SearchResult struct {
+	Total int     `json:"total"`
+	Hits  []*RAiD `json:"hits"`
+	// Facets maps each facet asked for to its most common values, most
+	// common first
+	Facets map[string][]FacetCount `json:"facets"`
+	Page   Page                    `json:"page"`
+}
+
+// FacetCount is the number of RAiDs found with a value of a facet
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Groupings of an aggregate
+const (
+	GroupBySubject      = "subject"
+	GroupByOrganisation = "organisation"
+	// GroupByYear groups RAiDs by the year of their start date
+	GroupByYear = "year"
+)
+
+// MetricCount counts the RAiDs of each group of an aggregate
+const MetricCount = "count"
+
+// Aggregate reports a metric of the RAiDs grouped by the values of a field.
+// A RAiD with several values, such as subjects, is counted in each group,
+// and a RAiD without one in none.
+type Aggregate struct {
+	GroupBy string           `json:"groupBy"`
+	Metric  string           `json:"metric"`
+	Groups  []AggregateGroup `json:"groups"`
+}
+
+// AggregateGroup is the metric of the RAiDs with a value of the grouped
+// field
+type AggregateGroup struct {
+	Key   string `json:"key"`
+	Value int64  `json:"value"`
+}
+
+// Draft is a RAiD being prepared by a service point. It has no identifier
+// and is not listed until it is promoted, which mints it.
+type Draft struct {
+	ID           string    `json:"id"`
+	ServicePoint int64     `json:"servicePoint"`
+	RAiD         *RAiD     `json:"raid"`
+	Created      time.Time `json:"created"`
+	Updated      time.Time `json:"updated"`
+}
+
+// Reservation holds an identifier for a service point until its mint
+// supplies it, or until it expires
+type Reservation struct {
+	ID           string    `json:"id"`
+	Prefix       string    `json:"prefix"`
+	Suffix       string    `json:"suffix"`
+	ServicePoint int64     `json:"servicePoint"`
+	Created      time.Time `json:"created"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// Statuses of a ScheduledChange
+const (
+	SchedulePending = "pending"
+	ScheduleFailed  = "failed"
+)
+
+// ScheduledChange is a mint or update held until it takes effect, when the
+// publication scheduler applies it
+type ScheduledChange struct {
+	ID           string `json:"id"`
+	ServicePoint int64  `json:"servicePoint"`
+	// Prefix and Suffix name the RAiD updated; both are empty for a mint
+	Prefix string `json:"prefix,omitempty"`
+	Suffix string `json:"suffix,omitempty"`
+	// Agency and ProjectType are those the change was requested with,
+	// which select the prefix of a mint
+	Agency      string `json:"agency,omitempty"`
+	ProjectType string `json:"projectType,omitempty"`
+	// Actor is the user who scheduled the change, recorded as the actor of
+	// the version it writes
+	Actor       string    `json:"actor,omitempty"`
+	RAiD        *RAiD     `json:"raid"`
+	EffectiveAt time.Time `json:"effectiveAt"`
+	Status      string    `json:"status"`
+	// Error is why the change failed to apply
+	Error   string    `json:"error,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Proposal is a related object announced for a RAiD by another system,
+// such as a repository holding an output of the project, held until the
+// RAiD's service point accepts it into the RAiD or rejects it
+type Proposal struct {
+	ID           string `json:"id"`
+	ServicePoint int64  `json:"servicePoint"`
+	Prefix       string `json:"prefix"`
+	Suffix       string `json:"suffix"`
+	// RelatedObject is added to the RAiD when the proposal is accepted
+	RelatedObject RelatedObject `json:"relatedObject"`
+	// Origin is the service that made the announcement, Inbox where it
+	// takes notifications and Notification the ID of the announcement
+	Origin       string    `json:"origin,omitempty"`
+	Inbox        string    `json:"inbox,omitempty"`
+	Notification string    `json:"notification"`
+	Created      time.Time `json:"created"`
+}
+
+// Follower is an ActivityPub actor following the registry's activity
+type Follower struct {
+	// ID is the URL of the actor
+	ID string `json:"id"`
+	// Inbox is where activities are delivered to the actor, its shared
+	// inbox when it has one
+	Inbox    string    `json:"inbox"`
+	Followed time.Time `json:"followed"`
+}
+
+// Attachment is a document attached to a RAiD, such as a data management
+// plan. Its content is stored apart from it, addressed by its SHA-256
+// hash, so a document attached to several RAiDs is stored once.
+type Attachment struct {
+	// Hash is the hex-encoded SHA-256 hash of the content
+	Hash      string `json:"hash"`
+	Prefix    string `json:"prefix"`
+	Suffix    string `json:"suffix"`
+	Name      string `json:"name"`
+	MediaType string `json:"mediaType"`
+	Size      int64  `json:"size"`
+	// ServicePoint is the service point that attached the document
+	ServicePoint int64     `json:"servicePoint"`
+	Created      time.Time `json:"created"`
+}
+
+// Purge records the hard removal of a soft deleted RAiD. Purges are kept
+// after the RAiD is gone, so that what was removed, when and why can be
+// accounted for.
+type Purge struct {
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+	// Actor is the operator who purged the RAiD, "retention" for the
+	// retention window or "verify-replica" for a replica repair
+	Actor  string `json:"actor"`
+	Reason string `json:"reason"`
+	// Deleted is when the RAiD was soft deleted, if known
+	Deleted time.Time `json:"deleted,omitempty"`
+	Purged  time.Time `json:"purged"`
+}
+
+// Redaction records the removal of a contributor's personal data from every
+// version of a RAiD. It identifies the contributor only by a hash, so that
+// the record itself holds none of what was removed.
+type Redaction struct {
+	// ID is shared by the redactions made for one request
+	ID     string `json:"id"`
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+	// Subject is the SHA-256 hash of the identifiers the contributor was
+	// matched by
+	Subject string `json:"subject"`
+	// Fields names the contributor fields removed
+	Fields []string `json:"fields"`
+	// Versions is the number of versions redacted
+	Versions int       `json:"versions"`
+	Actor    string    `json:"actor"`
+	Reason   string    `json:"reason"`
+	Redacted time.Time `json:"redacted"`
+}
+
+// SavedSearch is a named RAiD listing kept for running again
+type SavedSearch struct {
+	// Owner scopes the name: user:ID for a user's searches and
+	// service-point:ID for those shared within a service point
+	Owner string `json:"owner"`
+	Name  string `json:"name"`
+	// Query holds the query parameters of the listing, as taken by
+	// GET /raid/
+	Query map[string]string `json:"query"`
+	// Feed, when set, publishes the public RAiDs the search finds at
+	// /feeds/{feed} without authentication
+	Feed    string    `json:"feed,omitempty"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Page describes one page of a view
+type Page struct {
+	Offset  int  `json:"offset"`
+	Limit   int  `json:"limit"`
+	HasMore bool `json:"hasMore"`
+}
+
+// Profiles of list responses, asked for with
+// Accept: application/json; profile="..."
+const (
+	// EnvelopeProfile wraps lists in an Envelope
+	EnvelopeProfile = "https://raid.org/profiles/envelope"
+	// BareProfile returns lists as bare arrays, also where envelopes are
+	// the default
+	BareProfile = "https://raid.org/profiles/bare"
+)
+
+// Envelope wraps a list response for clients asking for it, with the page
+// the items are and links to related resources. Each item carries the
+// links to its own resources under "_links".
+type Envelope struct {
+	Data  json.RawMessage   `json:"data"`
+	Meta  EnvelopeMeta      `json:"meta"`
+	Links map[string]string `json:"links"`
+}
+
+// EnvelopeMeta describes the page of items in an Envelope
+type EnvelopeMeta struct {
+	Count     int    `json:"count"`
+	Limit     int    `json:"limit,omitempty"`
+	Offset    int    `json:"offset,omitempty"`
+	Cursor    string `json:"cursor,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
 }
 
 // ValidationFailure represents a validation error
@@ -0,0 +1,336 @@
+// Package notify emails people about RAiD events: invitees of contributor
+// invitations, service points a RAiD is transferred from and to, service
+// points with embargoes about to expire and service points nearing their
+// quota. Service point notifications go to the recipients in its
+// NotificationSettings, or else its admin email, and may be limited to
+// some events.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"github.com/leifj/go-raid/internal/expr"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Events notified
+const (
+	// EventInvitation sends an invitation link to an invited contributor
+	EventInvitation = "invitation"
+	// EventTransfer tells service points of a RAiD transferred between
+	// them
+	EventTransfer = "transfer"
+	// EventEmbargo warns the owning service point of an embargo about to
+	// expire
+	EventEmbargo = "embargo"
+	// EventQuota warns a service point nearing its quota
+	EventQuota = "quota"
+)
+
+// Events lists every event
+var Events = []string{EventInvitation, EventTransfer, EventEmbargo, EventQuota}
+
+// Message is an email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Sender delivers messages
+type Sender interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Config configures a Notifier
+type Config struct {
+	// EmbargoNotice is how long before an embargo expires its service
+	// point is warned
+	EmbargoNotice time.Duration
+	// QuotaThreshold is the percentage of its quota a service point is
+	// warned at
+	QuotaThreshold int
+	// Templates maps events to template files replacing the built-in
+	// ones. A template renders a "Subject:" line, a blank line and the
+	// body.
+	Templates map[string]string
+}
+
+// Notifier renders and sends notifications. Messages are sent in the
+// background, so that a slow mail server does not hold up requests.
+type Notifier struct {
+	repo      storage.Repository
+	sender    Sender
+	cfg       Config
+	templates map[string]*template.Template
+	sending   sync.WaitGroup
+	pending   atomic.Int64
+
+	// mu guards failure, the error of the last send if it failed
+	mu      sync.Mutex
+	failure error
+}
+
+// New creates a notifier sending through sender, reading service points
+// and RAiDs from repo
+func New(repo storage.Repository, sender Sender, cfg Config) (*Notifier, error) {
+	n := &Notifier{repo: repo, sender: sender, cfg: cfg, templates: map[string]*template.Template{}}
+	for _, event := range Events {
+		text := defaultTemplates[event]
+		if path := cfg.Templates[event]; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s template: %w", event, err)
+			}
+			text = string(data)
+		}
+		t, err := template.New(event).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", event, err)
+		}
+		n.templates[event] = t
+	}
+	for event := range cfg.Templates {
+		if !slices.Contains(Events, event) {
+			return nil, fmt.Errorf("no event %q to template", event)
+		}
+	}
+	return n, nil
+}
+
+// ValidateSettings checks the notification settings of a service point
+func ValidateSettings(s *models.NotificationSettings) error {
+	if s == nil {
+		return nil
+	}
+	for _, to := range s.Recipients {
+		if !strings.Contains(to, "@") {
+			return fmt.Errorf("notifications.recipients: %q is not an email address", to)
+		}
+	}
+	for _, event := range s.Events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("notifications.events: %q is not one of %s", event, strings.Join(Events, ", "))
+		}
+	}
+	return nil
+}
+
+// Invitation sends the invitation link to the contributor invited to raid
+// by email
+func (n *Notifier) Invitation(ctx context.Context, raid *models.RAiD, email, link string, expires time.Time) {
+	if sp := n.servicePoint(ctx, owner(raid)); sp != nil && !wants(sp, EventInvitation) {
+		return
+	}
+	n.send(ctx, EventInvitation, []string{email}, map[string]any{
+		"RAiD":    raid,
+		"Title":   title(raid),
+		"Email":   email,
+		"Link":    link,
+		"Expires": expires,
+	})
+}
+
+// Transferred tells the service points raid moved from and to
+func (n *Notifier) Transferred(ctx context.Context, raid *models.RAiD, from int64) {
+	data := map[string]any{
+		"RAiD":  raid,
+		"Title": title(raid),
+		"From":  n.servicePoint(ctx, from),
+		"To":    n.servicePoint(ctx, owner(raid)),
+	}
+	for _, key := range []string{"From", "To"} {
+		if sp, _ := data[key].(*models.ServicePoint); sp != nil {
+			n.sendTo(ctx, sp, EventTransfer, data)
+		}
+	}
+}
+
+// Minted warns the service point owning a newly minted RAiD once it
+// reaches the quota threshold
+func (n *Notifier) Minted(ctx context.Context, raid *models.RAiD) {
+	sp := n.servicePoint(ctx, owner(raid))
+	if sp == nil || sp.MaxRAiDs == 0 || n.cfg.QuotaThreshold <= 0 {
+		return
+	}
+	threshold := (sp.MaxRAiDs*n.cfg.QuotaThreshold + 99) / 100
+	owned, err := n.repo.ListRAiDs(ctx, &storage.RAiDFilter{ServicePointID: sp.ID, Limit: threshold + 1})
+	if err != nil {
+		log.Printf("Failed to count RAiDs of service point %d: %v", sp.ID, err)
+		return
+	}
+	// Warned once, by the mint that reaches the threshold
+	if len(owned) != threshold {
+		return
+	}
+	n.sendTo(ctx, sp, EventQuota, map[string]any{
+		"ServicePoint": sp,
+		"Owned":        len(owned),
+		"Max":          sp.MaxRAiDs,
+	})
+}
+
+// Run warns of expiring embargoes every interval until ctx is cancelled.
+// Each run covers the expiry dates that came within notice since the
+// last, so that each embargo is warned of once.
+func (n *Notifier) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if err := n.CheckEmbargoes(ctx, last, now); err != nil {
+				log.Printf("Embargo notifications failed: %v", err)
+				continue
+			}
+			last = now
+		}
+	}
+}
+
+// CheckEmbargoes warns of the embargoes expiring after since plus the
+// notice period, up to now plus the notice period
+func (n *Notifier) CheckEmbargoes(ctx context.Context, since, now time.Time) error {
+	from := since.Add(n.cfg.EmbargoNotice).UTC().Format(time.DateOnly)
+	to := now.Add(n.cfg.EmbargoNotice).UTC().Format(time.DateOnly)
+	if from == to {
+		return nil
+	}
+	e, err := expr.Compile(fmt.Sprintf("access.type.id == '%s' && access.embargoExpiry > '%s' && access.embargoExpiry <= '%s'",
+		storage.AccessTypeEmbargoed, from, to))
+	if err != nil {
+		return err
+	}
+	raids, err := n.repo.ListRAiDs(ctx, &storage.RAiDFilter{Expression: e})
+	if err != nil {
+		return fmt.Errorf("failed to list expiring embargoes: %w", err)
+	}
+	for _, raid := range raids {
+		sp := n.servicePoint(ctx, owner(raid))
+		if sp == nil {
+			continue
+		}
+		n.sendTo(ctx, sp, EventEmbargo, map[string]any{
+			"RAiD":         raid,
+			"Title":        title(raid),
+			"Expiry":       raid.Access.EmbargoExpiry,
+			"ServicePoint": sp,
+		})
+	}
+	return nil
+}
+
+// Status returns the number of messages being sent, and the error of the
+// last send if it failed
+func (n *Notifier) Status() (pending int64, err error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.pending.Load(), n.failure
+}
+
+// Wait waits for the messages being sent
+func (n *Notifier) Wait() {
+	n.sending.Wait()
+}
+
+// sendTo sends event to the recipients of sp, if it wants to be notified
+func (n *Notifier) sendTo(ctx context.Context, sp *models.ServicePoint, event string, data map[string]any) {
+	if !wants(sp, event) {
+		return
+	}
+	to := []string{sp.AdminEmail}
+	if sp.Notifications != nil && len(sp.Notifications.Recipients) > 0 {
+		to = sp.Notifications.Recipients
+	}
+	if to[0] == "" {
+		return
+	}
+	n.send(ctx, event, to, data)
+}
+
+// send renders event with data and sends it to to in the background.
+// Failures are logged.
+func (n *Notifier) send(ctx context.Context, event string, to []string, data map[string]any) {
+	var b bytes.Buffer
+	if err := n.templates[event].Execute(&b, data); err != nil {
+		log.Printf("Failed to render %s notification: %v", event, err)
+		return
+	}
+	msg := parseMessage(b.String())
+	msg.To = to
+
+	ctx = context.WithoutCancel(ctx)
+	n.sending.Add(1)
+	n.pending.Add(1)
+	go func() {
+		defer n.sending.Done()
+		defer n.pending.Add(-1)
+		err := n.sender.Send(ctx, msg)
+		if err != nil {
+			log.Printf("Failed to send %s notification to %s: %v", event, strings.Join(to, ", "), err)
+		}
+		n.mu.Lock()
+		n.failure = err
+		n.mu.Unlock()
+	}()
+}
+
+// parseMessage splits a rendered template into its subject and body
+func parseMessage(text string) *Message {
+	head, body, found := strings.Cut(text, "\n\n")
+	if !found || !strings.HasPrefix(head, "Subject:") {
+		return &Message{Body: text}
+	}
+	return &Message{Subject: strings.TrimSpace(strings.TrimPrefix(head, "Subject:")), Body: body}
+}
+
+func (n *Notifier) servicePoint(ctx context.Context, id int64) *models.ServicePoint {
+	if id == 0 {
+		return nil
+	}
+	sp, err := n.repo.GetServicePoint(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return sp
+}
+
+// wants reports whether sp is notified of event
+func wants(sp *models.ServicePoint, event string) bool {
+	return sp.Notifications == nil || len(sp.Notifications.Events) == 0 || slices.Contains(sp.Notifications.Events, event)
+}
+
+func owner(raid *models.RAiD) int64 {
+	if raid == nil || raid.Identifier == nil || raid.Identifier.Owner == nil {
+		return 0
+	}
+	return raid.Identifier.Owner.ServicePoint
+}
+
+// title returns the title of raid, or its handle without one
+func title(raid *models.RAiD) string {
+	if len(raid.Title) > 0 {
+		return raid.Title[0].Text
+	}
+	if raid.Identifier != nil {
+		if prefix, suffix, err := identifier.Parse(raid.Identifier.ID); err == nil {
+			return prefix + "/" + suffix
+		}
+	}
+	return ""
+}
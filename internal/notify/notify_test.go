@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+type recorder struct {
+	mu   sync.Mutex
+	sent []*Message
+}
+
+func (r *recorder) Send(ctx context.Context, msg *Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, msg)
+	return nil
+}
+
+// take returns the messages sent since the last call
+func (r *recorder) take(n *Notifier) []*Message {
+	n.Wait()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sent := r.sent
+	r.sent = nil
+	return sent
+}
+
+func TestNotifier(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	north, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "North", AdminEmail: "admin@north.example", MaxRAiDs: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	south, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "South", AdminEmail: "admin@south.example",
+		Notifications: &models.NotificationSettings{Recipients: []string{"desk@south.example"}, Events: []string{EventTransfer}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := &recorder{}
+	n, err := New(repo, sent, Config{EmbargoNotice: 14 * 24 * time.Hour, QuotaThreshold: 75})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raids := Wrap(repo, n)
+
+	// The third of four RAiDs reaches 75% of the quota
+	expiry := time.Now().AddDate(0, 0, 14).UTC().Format(time.DateOnly)
+	for i, suffix := range []string{"a", "b", "c"} {
+		raid := &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix, Owner: &models.Owner{ServicePoint: north.ID}},
+			Title:      []models.Title{{Text: "Project " + suffix}},
+		}
+		if suffix == "a" {
+			raid.Access = &models.Access{Type: &models.IDSchema{ID: storage.AccessTypeEmbargoed}, EmbargoExpiry: expiry}
+		}
+		if _, err := raids.CreateRAiD(ctx, raid); err != nil {
+			t.Fatal(err)
+		}
+		if got := sent.take(n); i < 2 && len(got) != 0 {
+			t.Errorf("expected no warning below the threshold, got %+v", got)
+		} else if i == 2 && (len(got) != 1 || got[0].Subject != "North has minted 3 of 4 RAiDs" || got[0].To[0] != "admin@north.example") {
+			t.Errorf("expected a quota warning, got %+v", got)
+		}
+	}
+
+	// Transfers tell both service points, through their own recipients
+	if _, err := raids.UpdateRAiD(ctx, "10.99999", "b", &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/b", Owner: &models.Owner{ServicePoint: south.ID}},
+		Title:      []models.Title{{Text: "Project b"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	got := sent.take(n)
+	var to []string
+	for _, msg := range got {
+		to = append(to, msg.To...)
+		if !strings.Contains(msg.Body, "from North to South") {
+			t.Errorf("unexpected transfer notice: %s", msg.Body)
+		}
+	}
+	slices.Sort(to)
+	if !slices.Equal(to, []string{"admin@north.example", "desk@south.example"}) {
+		t.Errorf("expected both service points told, got %v", to)
+	}
+
+	// Embargoes are warned of once, when their expiry comes within notice
+	now := time.Now()
+	if err := n.CheckEmbargoes(ctx, now.AddDate(0, 0, -1), now); err != nil {
+		t.Fatal(err)
+	}
+	if got := sent.take(n); len(got) != 1 || !strings.Contains(got[0].Subject, expiry) {
+		t.Errorf("expected an embargo warning, got %+v", got)
+	}
+	if err := n.CheckEmbargoes(ctx, now, now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sent.take(n); len(got) != 0 {
+		t.Errorf("expected no second warning, got %+v", got)
+	}
+
+	// South is only told of transfers
+	raid, err := repo.GetRAiD(ctx, "10.99999", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Invitation(ctx, raid, "someone@example.org", "https://portal.example/invite/x", now)
+	if got := sent.take(n); len(got) != 0 {
+		t.Errorf("expected no invitation for a service point not notified of them, got %+v", got)
+	}
+	raid, _ = repo.GetRAiD(ctx, "10.99999", "c")
+	n.Invitation(ctx, raid, "someone@example.org", "https://portal.example/invite/x", now)
+	if got := sent.take(n); len(got) != 1 || got[0].To[0] != "someone@example.org" || !strings.Contains(got[0].Body, "https://portal.example/invite/x") {
+		t.Errorf("expected an invitation, got %+v", got)
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	for _, s := range []*models.NotificationSettings{
+		{Recipients: []string{"nobody"}},
+		{Events: []string{"minted"}},
+	} {
+		if ValidateSettings(s) == nil {
+			t.Errorf("expected %+v to be rejected", s)
+		}
+	}
+	if err := ValidateSettings(&models.NotificationSettings{Recipients: []string{"a@example.org"}, Events: Events}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that notifies n of the quotas reached by mints
+// and the transfers made by updates to repo
+func Wrap(repo storage.Repository, n *Notifier) storage.Repository {
+	return &repository{Repository: repo, notifier: n}
+}
+
+type repository struct {
+	storage.Repository
+	notifier *Notifier
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.notifier.Minted(ctx, created)
+	return created, nil
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	var from int64
+	if current, err := r.Repository.GetRAiD(ctx, prefix, suffix); err == nil {
+		from = owner(current)
+	}
+	updated, err := r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+	if err != nil {
+		return nil, err
+	}
+	if to := owner(updated); from != 0 && to != 0 && to != from {
+		r.notifier.Transferred(ctx, updated, from)
+	}
+	return updated, nil
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPSender sends messages through an SMTP server, using STARTTLS when
+// the server offers it
+type SMTPSender struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTPSender creates a sender submitting to host:port as from,
+// authenticating with username and password if username is set
+func NewSMTPSender(host string, port int, username, password, from string) *SMTPSender {
+	s := &SMTPSender{addr: net.JoinHostPort(host, strconv.Itoa(port)), from: from}
+	if username != "" {
+		s.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return s
+}
+
+// Send sends msg. The context is not consulted; net/smtp has no way to
+// cancel a submission.
+func (s *SMTPSender) Send(ctx context.Context, msg *Message) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", s.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return smtp.SendMail(s.addr, s.auth, s.from, msg.To, []byte(b.String()))
+}
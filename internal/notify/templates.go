@@ -0,0 +1,32 @@
+package notify
+
+// defaultTemplates are the built-in templates of each event
+var defaultTemplates = map[string]string{
+	EventInvitation: `Subject: Invitation to the RAiD "{{.Title}}"
+
+You have been added as a contributor to the research activity
+"{{.Title}}" ({{.RAiD.Identifier.ID}}).
+
+Attach your ORCID iD, or decline, at:
+
+{{.Link}}
+
+The link is valid until {{.Expires.Format "2 January 2006"}}.
+`,
+	EventTransfer: `Subject: RAiD "{{.Title}}" transferred
+
+The RAiD {{.RAiD.Identifier.ID}} ("{{.Title}}") has been transferred
+{{- with .From}} from {{.Name}}{{end}}{{with .To}} to {{.Name}}{{end}}.
+`,
+	EventEmbargo: `Subject: Embargo of "{{.Title}}" expires on {{.Expiry}}
+
+The embargo of the RAiD {{.RAiD.Identifier.ID}} ("{{.Title}}"), owned by
+{{.ServicePoint.Name}}, expires on {{.Expiry}}. The RAiD becomes public
+then unless the embargo is extended.
+`,
+	EventQuota: `Subject: {{.ServicePoint.Name}} has minted {{.Owned}} of {{.Max}} RAiDs
+
+The service point {{.ServicePoint.Name}} owns {{.Owned}} RAiDs of the
+{{.Max}} it may. Further mints will be refused once it reaches the quota.
+`,
+}
@@ -0,0 +1,270 @@
+// Package policy applies deployment rules to RAiD mints and updates. Each
+// rule is written in the expression language of package expr, evaluated
+// against the RAiD document: it can set fields and veto operations whose
+// RAiD does not meet a requirement, globally or for some service points.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/expr"
+	"github.com/leifj/go-raid/internal/hooks"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Operations a policy applies to
+const (
+	OnMint   = "mint"
+	OnUpdate = "update"
+)
+
+// Policy is a rule for RAiD writes. When When holds for the RAiD written,
+// the fields in Set are assigned and the operation is vetoed unless
+// Require then holds.
+type Policy struct {
+	Name string `yaml:"name" toml:"name" json:"name"`
+	// On lists the operations the policy applies to, mint and update;
+	// empty means both
+	On []string `yaml:"on" toml:"on" json:"on,omitempty"`
+	// ServicePoints limits the policy to RAiDs owned by these service
+	// points; empty means all
+	ServicePoints []int64 `yaml:"servicePoints" toml:"servicePoints" json:"servicePoints,omitempty"`
+	// When is the condition for the policy to apply; empty means always
+	When string `yaml:"when" toml:"when" json:"when,omitempty"`
+	// Set maps field paths such as identifier.license to expressions
+	// whose values they are given
+	Set map[string]string `yaml:"set" toml:"set" json:"set,omitempty"`
+	// Require must hold for the operation to go ahead
+	Require string `yaml:"require" toml:"require" json:"require,omitempty"`
+	// Message is the reason given to the client for a veto
+	Message string `yaml:"message" toml:"message" json:"message,omitempty"`
+}
+
+// compiled is a Policy with its expressions compiled
+type compiled struct {
+	Policy
+	when, require *expr.Expr
+	set           []assignment
+}
+
+type assignment struct {
+	path  []string
+	value *expr.Expr
+}
+
+// Validate compiles policies, reporting every mistake
+func Validate(policies []Policy) error {
+	_, err := compile(policies)
+	return err
+}
+
+func compile(policies []Policy) ([]compiled, error) {
+	var errs []error
+	out := make([]compiled, 0, len(policies))
+	for i, p := range policies {
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("policies[%d] (%s): %s", i, p.Name, fmt.Sprintf(format, args...)))
+		}
+		c := compiled{Policy: p}
+		if p.Name == "" {
+			fail("name is required")
+		}
+		for _, on := range p.On {
+			if on != OnMint && on != OnUpdate {
+				fail("on must list %s or %s, got %q", OnMint, OnUpdate, on)
+			}
+		}
+		if p.Require == "" && len(p.Set) == 0 {
+			fail("require or set is needed")
+		}
+		var err error
+		if p.When != "" {
+			if c.when, err = expr.Compile(p.When); err != nil {
+				fail("when: %v", err)
+			}
+		}
+		if p.Require != "" {
+			if c.require, err = expr.Compile(p.Require); err != nil {
+				fail("require: %v", err)
+			}
+		}
+		fields := make([]string, 0, len(p.Set))
+		for field := range p.Set {
+			fields = append(fields, field)
+		}
+		sort.Strings(fields)
+		for _, field := range fields {
+			path := strings.Split(field, ".")
+			if slices.Contains(path, "") {
+				fail("set: %q is not a field path", field)
+				continue
+			}
+			value, err := expr.Compile(p.Set[field])
+			if err != nil {
+				fail("set %s: %v", field, err)
+				continue
+			}
+			c.set = append(c.set, assignment{path: path, value: value})
+		}
+		out = append(out, c)
+	}
+	return out, errors.Join(errs...)
+}
+
+// applies reports whether c applies to the operation op on a RAiD owned by
+// the service point servicePoint
+func (c *compiled) applies(op string, servicePoint int64) bool {
+	return (len(c.On) == 0 || slices.Contains(c.On, op)) &&
+		(len(c.ServicePoints) == 0 || slices.Contains(c.ServicePoints, servicePoint))
+}
+
+// Wrap returns a repository that applies policies to the RAiDs minted and
+// updated in repo. Vetoes are returned as a *hooks.VetoError, as are
+// policies that cannot be evaluated against the RAiD. repo is returned
+// as is without policies; they must have passed Validate.
+func Wrap(repo storage.Repository, policies []Policy) (storage.Repository, error) {
+	if len(policies) == 0 {
+		return repo, nil
+	}
+	c, err := compile(policies)
+	if err != nil {
+		return nil, err
+	}
+	return &repository{Repository: repo, policies: c}, nil
+}
+
+type repository struct {
+	storage.Repository
+	policies []compiled
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if err := r.apply(OnMint, owner(raid), raid); err != nil {
+		return nil, err
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	// The stored owner counts, so that an update cannot shed policies by
+	// naming another service point; a missing RAiD is left to the update
+	servicePoint := owner(raid)
+	if current, err := r.Repository.GetRAiD(ctx, prefix, suffix); err == nil {
+		servicePoint = owner(current)
+	}
+	if err := r.apply(OnUpdate, servicePoint, raid); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
+
+func owner(raid *models.RAiD) int64 {
+	if raid.Identifier == nil || raid.Identifier.Owner == nil {
+		return 0
+	}
+	return raid.Identifier.Owner.ServicePoint
+}
+
+// apply runs the policies for op in order, each seeing the fields set by
+// those before it
+func (r *repository) apply(op string, servicePoint int64, raid *models.RAiD) error {
+	var doc map[string]any
+	changed := false
+	for i := range r.policies {
+		p := &r.policies[i]
+		if !p.applies(op, servicePoint) {
+			continue
+		}
+		if doc == nil {
+			var err error
+			if doc, err = toDocument(raid); err != nil {
+				return err
+			}
+		}
+		if err := p.run(doc, &changed); err != nil {
+			return err
+		}
+	}
+	if !changed {
+		return nil
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var updated models.RAiD
+	if err := json.Unmarshal(data, &updated); err != nil {
+		return hooks.Veto("policy sets fields the RAiD cannot hold: %v", err)
+	}
+	*raid = updated
+	return nil
+}
+
+// run applies the policy to doc, setting changed if it assigns fields
+func (c *compiled) run(doc map[string]any, changed *bool) error {
+	if c.when != nil {
+		ok, err := c.when.EvalBool(doc)
+		if err != nil {
+			return c.evalError(err)
+		}
+		if !ok {
+			return nil
+		}
+	}
+	for _, a := range c.set {
+		v, err := a.value.Eval(doc)
+		if err != nil {
+			return c.evalError(err)
+		}
+		setPath(doc, a.path, v)
+		*changed = true
+	}
+	if c.require != nil {
+		ok, err := c.require.EvalBool(doc)
+		if err != nil {
+			return c.evalError(err)
+		}
+		if !ok {
+			if c.Message != "" {
+				return hooks.Veto("%s", c.Message)
+			}
+			return hooks.Veto("policy %s requires %s", c.Name, c.Require)
+		}
+	}
+	return nil
+}
+
+func (c *compiled) evalError(err error) error {
+	return hooks.Veto("policy %s cannot be evaluated: %v", c.Name, err)
+}
+
+// toDocument returns raid as a JSON document
+func toDocument(raid *models.RAiD) (map[string]any, error) {
+	data, err := json.Marshal(raid)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	return doc, json.Unmarshal(data, &doc)
+}
+
+// setPath sets the field at path in doc to v, creating the objects on the
+// way, and replacing values in the way that are not objects
+func setPath(doc map[string]any, path []string, v any) {
+	for _, field := range path[:len(path)-1] {
+		next, ok := doc[field].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			doc[field] = next
+		}
+		doc = next
+	}
+	doc[path[len(path)-1]] = v
+}
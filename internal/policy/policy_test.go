@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/hooks"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+const openAccess = "https://vocabulary.raid.org/access.type.schema/82"
+
+func TestValidate(t *testing.T) {
+	err := Validate([]Policy{
+		{Name: "ok", When: "true", Require: "size(title) > 0"},
+		{Require: "true"},
+		{Name: "op", On: []string{"delete"}, Require: "true"},
+		{Name: "empty"},
+		{Name: "syntax", Require: "title =="},
+		{Name: "path", Set: map[string]string{"identifier..license": "'x'"}},
+	})
+	if err == nil {
+		t.Fatal("expected errors")
+	}
+	for _, want := range []string{"policies[1] (): name is required", "policies[2] (op): on must list", "policies[3] (empty): require or set", "policies[4] (syntax): require:", "policies[5] (path): set"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+	repo, err := Wrap(inner, []Policy{
+		{
+			Name:    "described",
+			When:    "access.type.id == '" + openAccess + "'",
+			Require: "size(description) > 0",
+			Message: "open RAiDs need a description",
+		},
+		{
+			Name:    "license",
+			On:      []string{OnMint},
+			Set:     map[string]string{"identifier.license": "'Creative Commons CC-0'"},
+			Require: "true",
+		},
+		{
+			Name:          "pilot",
+			ServicePoints: []int64{7},
+			Require:       "!has(access.embargoExpiry) || access.embargoExpiry <= addYears(today(), 2)",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	open := &models.Access{Type: &models.IDSchema{ID: openAccess}}
+
+	var veto *hooks.VetoError
+	_, err = repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"}, Access: open})
+	if !errors.As(err, &veto) || veto.Reason != "open RAiDs need a description" {
+		t.Fatalf("expected a veto, got %v", err)
+	}
+
+	created, err := repo.CreateRAiD(ctx, &models.RAiD{
+		Identifier:  &models.Identifier{ID: "https://raid.org/10.99999/a", Owner: &models.Owner{ServicePoint: 7}},
+		Access:      open,
+		Description: []models.Description{{Text: "Surveys"}},
+		Title:       []models.Title{{Text: "Kept", Extensions: models.Extensions{"x-note": []byte(`"kept"`)}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Identifier.License != "Creative Commons CC-0" {
+		t.Errorf("expected the license to be set, got %q", created.Identifier.License)
+	}
+	if string(created.Title[0].Extensions["x-note"]) != `"kept"` {
+		t.Errorf("expected extensions to survive, got %+v", created.Title[0])
+	}
+
+	// The stored owner decides which policies apply to an update
+	created.Identifier.Owner.ServicePoint = 8
+	created.Access = &models.Access{Type: &models.IDSchema{ID: "embargoed"}, EmbargoExpiry: "2999-01-01"}
+	if _, err := repo.UpdateRAiD(ctx, "10.99999", "a", created); !errors.As(err, &veto) {
+		t.Errorf("expected the service point policy to veto, got %v", err)
+	}
+	created.Access.EmbargoExpiry = ""
+	created.Identifier.License = "Own"
+	updated, err := repo.UpdateRAiD(ctx, "10.99999", "a", created)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Identifier.License != "Own" {
+		t.Errorf("expected the mint-only policy to leave updates alone, got %q", updated.Identifier.License)
+	}
+}
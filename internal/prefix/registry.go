@@ -0,0 +1,179 @@
+// Package prefix keeps the registry of handle prefixes owned by service
+// points. The registry is the set of prefixes of the stored service points:
+// a service point may only be saved with well-formed prefixes that no other
+// service point owns, that do not overlap each other and, where the
+// registration agency's allocation is configured, that it was allocated.
+package prefix
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/validation"
+)
+
+// Valid reports whether p is a handle prefix of at least two numeric
+// components separated by dots, such as 10.82841 or 10.25.1.1
+func Valid(p string) bool {
+	parts := strings.Split(p, ".")
+	if len(parts) < 2 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.Trim(part, "0123456789") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Within reports whether p is parent or a prefix derived from it, such as
+// 10.82841.1 from 10.82841. The owner of a prefix controls those derived
+// from it, so prefixes overlap when either is within the other.
+func Within(p, parent string) bool {
+	return p == parent || strings.HasPrefix(p, parent+".")
+}
+
+func overlap(a, b string) bool {
+	return Within(a, b) || Within(b, a)
+}
+
+// Allocation lists the prefixes allocated to the registration agency, under
+// which service points may own prefixes
+type Allocation struct {
+	// Prefixes applies to service points of no agency listed in Agencies;
+	// empty allows any prefix
+	Prefixes []string
+	// Agencies holds the prefixes allocated to each hosted agency by ID
+	Agencies map[string][]string
+}
+
+// allowed returns the prefixes sp may own under, or nil for any
+func (a Allocation) allowed(sp *models.ServicePoint) []string {
+	if prefixes, ok := a.Agencies[sp.AgencyID]; ok && sp.AgencyID != "" {
+		return prefixes
+	}
+	return a.Prefixes
+}
+
+// owned is a prefix held by a service point and the field holding it
+type owned struct {
+	field  string
+	prefix string
+}
+
+// ownedPrefixes returns the prefixes held by sp, expanding ranges
+func ownedPrefixes(sp *models.ServicePoint) []owned {
+	var prefixes []owned
+	if sp.Prefix != "" {
+		prefixes = append(prefixes, owned{"prefix", sp.Prefix})
+	}
+	for i, pool := range sp.Prefixes {
+		// Ranges are checked by storage.ValidatePrefixes
+		expanded, err := storage.PoolPrefixes(pool)
+		if err != nil {
+			expanded = []string{pool.Prefix}
+		}
+		for _, p := range expanded {
+			prefixes = append(prefixes, owned{fmt.Sprintf("prefixes[%d]", i), p})
+		}
+	}
+	return prefixes
+}
+
+// Wrap returns a repository that checks the prefixes of service points
+// created or updated in repo against the registry and alloc, failing with
+// a *validation.Error. Service point writes are serialised within the
+// process so that two cannot claim the same prefix at once; instances
+// sharing a backend are not coordinated.
+func Wrap(repo storage.Repository, alloc Allocation) storage.Repository {
+	return &repository{Repository: repo, alloc: alloc}
+}
+
+type repository struct {
+	storage.Repository
+	alloc Allocation
+	mu    sync.Mutex
+}
+
+// check returns the failures of the prefixes of sp, saved as service point
+// id, or 0 for a new one
+func (r *repository) check(ctx context.Context, id int64, sp *models.ServicePoint) error {
+	var failures []models.ValidationFailure
+	fail := func(field, format string, args ...any) {
+		failures = append(failures, models.ValidationFailure{FieldID: field, ErrorType: validation.InvalidValue, Message: fmt.Sprintf(format, args...)})
+	}
+
+	prefixes := ownedPrefixes(sp)
+	allowed := r.alloc.allowed(sp)
+	for i, p := range prefixes {
+		if !Valid(p.prefix) {
+			fail(p.field, "%s is not a handle prefix such as 10.82841", p.prefix)
+			continue
+		}
+		if len(allowed) > 0 && !withinAny(p.prefix, allowed) {
+			fail(p.field, "%s is not allocated to the registration agency", p.prefix)
+		}
+		// The single prefix may also be listed in a pool
+		for _, q := range prefixes[:i] {
+			if q.field != p.field && q.field != "prefix" && overlap(p.prefix, q.prefix) {
+				fail(p.field, "%s overlaps %s in %s", p.prefix, q.prefix, q.field)
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return &validation.Error{Failures: failures}
+	}
+
+	others, err := r.Repository.ListServicePoints(ctx)
+	if err != nil {
+		return err
+	}
+	for _, other := range others {
+		if other.ID == id {
+			continue
+		}
+		for _, q := range ownedPrefixes(other) {
+			for _, p := range prefixes {
+				if overlap(p.prefix, q.prefix) {
+					fail(p.field, "%s overlaps prefix %s of service point %d", p.prefix, q.prefix, other.ID)
+				}
+			}
+		}
+	}
+	if len(failures) > 0 {
+		return &validation.Error{Failures: failures}
+	}
+	return nil
+}
+
+func withinAny(p string, parents []string) bool {
+	for _, parent := range parents {
+		if Within(p, parent) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *repository) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.check(ctx, 0, sp); err != nil {
+		return nil, err
+	}
+	return r.Repository.CreateServicePoint(ctx, sp)
+}
+
+func (r *repository) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.check(ctx, id, sp); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateServicePoint(ctx, id, sp)
+}
@@ -0,0 +1,89 @@
+package prefix
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func TestValid(t *testing.T) {
+	for p, want := range map[string]bool{
+		"10.82841":    true,
+		"10.25.1.1":   true,
+		"10":          false,
+		"10.":         false,
+		"10.8284a":    false,
+		"10..1":       false,
+		"doi:10.1234": false,
+	} {
+		if got := Valid(p); got != want {
+			t.Errorf("Valid(%q) = %t, want %t", p, got, want)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+	repo := Wrap(inner, Allocation{
+		Prefixes: []string{"10.82841", "10.99999"},
+		Agencies: map[string][]string{"north": {"10.55555"}},
+	})
+	ctx := context.Background()
+
+	first, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "First", Prefix: "10.82841.1",
+		Prefixes: []models.PrefixPool{{Prefix: "10.82841.1"}, {Prefix: "10.82841.2", Last: "10.82841.4"}}})
+	if err != nil {
+		t.Fatalf("expected the first service point to be created, got %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		sp    models.ServicePoint
+		field string
+		msg   string
+	}{
+		{"malformed", models.ServicePoint{Prefix: "10.x"}, "prefix", "not a handle prefix"},
+		{"not allocated", models.ServicePoint{Prefix: "10.12345"}, "prefix", "not allocated"},
+		{"not allocated to the agency", models.ServicePoint{Prefix: "10.99999", AgencyID: "north"}, "prefix", "not allocated"},
+		{"same prefix", models.ServicePoint{Prefix: "10.82841.1"}, "prefix", "of service point"},
+		{"in a range", models.ServicePoint{Prefixes: []models.PrefixPool{{Prefix: "10.82841.3"}}}, "prefixes[0]", "10.82841.3 of service point"},
+		{"parent prefix", models.ServicePoint{Prefix: "10.82841"}, "prefix", "of service point"},
+		{"derived prefix", models.ServicePoint{Prefix: "10.82841.4.1"}, "prefix", "of service point"},
+		{"overlapping pools", models.ServicePoint{Prefixes: []models.PrefixPool{{Prefix: "10.99999"}, {Prefix: "10.99999.1"}}}, "prefixes[1]", "overlaps 10.99999 in prefixes[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := repo.CreateServicePoint(ctx, &tt.sp)
+			var valErr *storage.ValidationError
+			if !errors.As(err, &valErr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			f := valErr.Failures[0]
+			if f.FieldID != tt.field || !strings.Contains(f.Message, tt.msg) {
+				t.Errorf("expected a failure of %s containing %q, got %+v", tt.field, tt.msg, f)
+			}
+		})
+	}
+
+	if _, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "North", Prefix: "10.55555.1", AgencyID: "north"}); err != nil {
+		t.Errorf("expected a prefix allocated to the agency to be accepted, got %v", err)
+	}
+	if _, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "None"}); err != nil {
+		t.Errorf("expected a service point without prefixes to be accepted, got %v", err)
+	}
+
+	// A service point keeps its own prefixes when updated
+	first.Prefixes = append(first.Prefixes, models.PrefixPool{Prefix: "10.82841.5"})
+	if _, err := repo.UpdateServicePoint(ctx, first.ID, first); err != nil {
+		t.Errorf("expected the update to be accepted, got %v", err)
+	}
+}
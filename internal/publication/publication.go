@@ -0,0 +1,142 @@
+// Package publication applies changes scheduled to take effect later.
+// Mints and updates given an effective time are held by backends
+// implementing storage.ScheduleStore, and nothing of them is public until
+// the scheduler applies them through the same checks as any other write.
+package publication
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// metrics exposes publication outcomes under /debug/vars
+var metrics = expvar.NewMap("publication")
+
+// Summary counts the work of one run
+type Summary struct {
+	// Applied is the number of changes applied
+	Applied int `json:"applied"`
+	// Failed is the number of changes that could not be applied, which
+	// are kept marked failed
+	Failed int `json:"failed"`
+}
+
+// Scheduler applies due changes at an interval
+type Scheduler struct {
+	repo        storage.Repository
+	store       storage.ScheduleStore
+	interval    time.Duration
+	agencies    *agency.Registry
+	maintenance *middleware.Maintenance
+
+	// mu serialises runs
+	mu sync.Mutex
+}
+
+// New creates a scheduler applying the changes held by store to repo every
+// interval. Mints requested through an agency are applied as that agency,
+// looked up in agencies, which may be nil. No changes are applied while
+// maintenance, if not nil, keeps the API read-only; they are applied when
+// it ends.
+func New(repo storage.Repository, store storage.ScheduleStore, interval time.Duration, agencies *agency.Registry, maintenance *middleware.Maintenance) *Scheduler {
+	return &Scheduler{
+		repo:        repo,
+		store:       store,
+		interval:    interval,
+		agencies:    agencies,
+		maintenance: maintenance,
+	}
+}
+
+// Run applies due changes every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := s.RunNow(ctx); err != nil {
+			log.Printf("Scheduled publication failed: %v", err)
+		}
+	}
+}
+
+// RunNow applies the changes due now, earliest first, unless the API is
+// read-only. A change that fails is marked failed and kept, so that it is
+// not retried until it is scheduled again.
+func (s *Scheduler) RunNow(ctx context.Context) (*Summary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maintenance != nil && s.maintenance.Enabled() {
+		metrics.Add("skipped", 1)
+		return &Summary{}, nil
+	}
+
+	changes, err := s.store.DueChanges(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due changes: %w", err)
+	}
+
+	summary := &Summary{}
+	for _, change := range changes {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := s.apply(ctx, change); err != nil {
+			log.Printf("Failed to apply scheduled change %s: %v", change.ID, err)
+			summary.Failed++
+			metrics.Add("failures", 1)
+			change.Status = models.ScheduleFailed
+			change.Error = err.Error()
+			if err := s.store.SaveScheduledChange(ctx, change); err != nil {
+				return nil, fmt.Errorf("failed to mark change %s failed: %w", change.ID, err)
+			}
+			continue
+		}
+		summary.Applied++
+		metrics.Add("applied", 1)
+		if err := s.store.DeleteScheduledChange(ctx, change.ID); err != nil {
+			return nil, fmt.Errorf("failed to remove applied change %s: %w", change.ID, err)
+		}
+	}
+	if len(changes) > 0 {
+		log.Printf("Scheduled publication applied %d changes (%d failed)", summary.Applied, summary.Failed)
+	}
+	return summary, nil
+}
+
+// apply makes change, as the user and agency and with the project type it
+// was requested with
+func (s *Scheduler) apply(ctx context.Context, change *models.ScheduledChange) error {
+	if change.Actor != "" {
+		ctx = storage.WithActor(ctx, change.Actor)
+	}
+	if change.Agency != "" && s.agencies != nil {
+		if a := s.agencies.Get(change.Agency); a != nil {
+			ctx = agency.NewContext(ctx, a)
+		}
+	}
+	if change.ProjectType != "" {
+		ctx = storage.WithProjectType(ctx, change.ProjectType)
+	}
+	if change.Prefix == "" {
+		_, err := s.repo.CreateRAiD(ctx, change.RAiD)
+		return err
+	}
+	_, err := s.repo.UpdateRAiD(ctx, change.Prefix, change.Suffix, change.RAiD)
+	return err
+}
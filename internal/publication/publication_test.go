@@ -0,0 +1,88 @@
+package publication
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func TestScheduler_RunNow(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	if _, err := repo.CreateRAiD(ctx, &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/current"},
+		Title:      []models.Title{{Text: "Before"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	for _, change := range []*models.ScheduledChange{
+		{ID: "mint", RAiD: &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/announced"}}, EffectiveAt: past},
+		{ID: "update", Prefix: "10.99999", Suffix: "current", Actor: "editor", RAiD: &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/current"},
+			Title:      []models.Title{{Text: "After"}},
+		}, EffectiveAt: past},
+		{ID: "missing", Prefix: "10.99999", Suffix: "missing", RAiD: &models.RAiD{}, EffectiveAt: past},
+		{ID: "later", RAiD: &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/later"}}, EffectiveAt: future},
+	} {
+		change.Status = models.SchedulePending
+		if err := repo.SaveScheduledChange(ctx, change); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing is applied while the API is read-only
+	maintenance := middleware.NewMaintenance(true, "upgrade", 0)
+	s := New(repo, repo, time.Minute, nil, maintenance)
+	if summary, err := s.RunNow(ctx); err != nil || *summary != (Summary{}) {
+		t.Fatalf("expected no changes applied in maintenance, got %+v %v", summary, err)
+	}
+	if _, err := repo.GetRAiD(ctx, "10.99999", "announced"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the due mint held in maintenance, got %v", err)
+	}
+
+	maintenance.Set(false, "")
+	summary, err := s.RunNow(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Summary{Applied: 2, Failed: 1}); *summary != want {
+		t.Errorf("got summary %+v, want %+v", *summary, want)
+	}
+
+	if _, err := repo.GetRAiD(ctx, "10.99999", "announced"); err != nil {
+		t.Errorf("expected the due mint applied: %v", err)
+	}
+	if raid, err := repo.GetRAiD(ctx, "10.99999", "current"); err != nil || raid.Title[0].Text != "After" {
+		t.Errorf("expected the due update applied, got %v %v", raid, err)
+	}
+	changes, err := repo.GetRAiDChanges(ctx, "10.99999", "current", nil)
+	if err != nil || len(changes) != 2 || changes[1].Actor != "editor" {
+		t.Errorf("expected the update recorded as made by who scheduled it, got %+v %v", changes, err)
+	}
+	if _, err := repo.GetRAiD(ctx, "10.99999", "later"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the later mint held, got %v", err)
+	}
+	if _, err := repo.GetScheduledChange(ctx, "mint"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected applied changes removed, got %v", err)
+	}
+	failed, err := repo.GetScheduledChange(ctx, "missing")
+	if err != nil || failed.Status != models.ScheduleFailed || failed.Error == "" {
+		t.Errorf("expected the failed change kept and marked, got %+v %v", failed, err)
+	}
+
+	if summary, err := s.RunNow(ctx); err != nil || *summary != (Summary{}) {
+		t.Errorf("expected failed changes not retried, got %+v %v", summary, err)
+	}
+}
@@ -0,0 +1,54 @@
+// Package quota caps the RAiDs a service point may mint. A service point
+// with maxRaids set owns no more than that many RAiDs, soft deleted ones
+// aside; operators mint past the quota, for instance to finish a pilot.
+package quota
+
+import (
+	"context"
+	"sync"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that refuses, with a *storage.QuotaError, to
+// mint RAiDs for service points in repo that have reached their quota.
+// exempt reports whether the caller of a request may mint past quotas; nil
+// means no caller may. Mints are serialised within the process so that two
+// cannot take the last RAiD of a quota at once; instances sharing a backend
+// are not coordinated.
+func Wrap(repo storage.Repository, exempt func(context.Context) bool) storage.Repository {
+	if exempt == nil {
+		exempt = func(context.Context) bool { return false }
+	}
+	return &repository{Repository: repo, exempt: exempt}
+}
+
+type repository struct {
+	storage.Repository
+	exempt func(context.Context) bool
+	mu     sync.Mutex
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier == nil || raid.Identifier.Owner == nil || raid.Identifier.Owner.ServicePoint == 0 || r.exempt(ctx) {
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	id := raid.Identifier.Owner.ServicePoint
+	sp, err := r.Repository.GetServicePoint(ctx, id)
+	if err != nil || sp.MaxRAiDs == 0 {
+		// A missing service point is left to the mint to report
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	owned, err := r.Repository.ListRAiDs(ctx, &storage.RAiDFilter{ServicePointID: id, Limit: sp.MaxRAiDs})
+	if err != nil {
+		return nil, err
+	}
+	if len(owned) >= sp.MaxRAiDs {
+		return nil, &storage.QuotaError{ServicePoint: id, Max: sp.MaxRAiDs}
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
@@ -0,0 +1,59 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+type exemptKey struct{}
+
+func TestWrap(t *testing.T) {
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+	repo := Wrap(inner, func(ctx context.Context) bool { return ctx.Value(exemptKey{}) != nil })
+	ctx := context.Background()
+
+	sp, err := repo.CreateServicePoint(ctx, &models.ServicePoint{Name: "Pilot", MaxRAiDs: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mint := func(ctx context.Context, suffix string) error {
+		_, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{
+			ID:    "https://raid.org/10.99999/" + suffix,
+			Owner: &models.Owner{ServicePoint: sp.ID},
+		}})
+		return err
+	}
+	for i := range 2 {
+		if err := mint(ctx, fmt.Sprint(i)); err != nil {
+			t.Fatalf("expected mint %d within the quota, got %v", i, err)
+		}
+	}
+
+	var quotaErr *storage.QuotaError
+	if err := mint(ctx, "over"); !errors.As(err, &quotaErr) || quotaErr.Max != 2 {
+		t.Fatalf("expected a QuotaError, got %v", err)
+	}
+	if err := mint(context.WithValue(ctx, exemptKey{}, true), "exempt"); err != nil {
+		t.Errorf("expected an exempt caller to mint past the quota, got %v", err)
+	}
+
+	// Deleted RAiDs free their place in the quota
+	for _, suffix := range []string{"0", "1"} {
+		if err := repo.DeleteRAiD(ctx, "10.99999", suffix); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := mint(ctx, "freed"); err != nil {
+		t.Errorf("expected the mint to be accepted once RAiDs are deleted, got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Package relation keeps relatedRaid links between RAiDs held by the same
+// instance consistent, by maintaining the inverse link on the related RAiD.
+package relation
+
+import (
+	"context"
+	"log"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that, after a RAiD is minted or updated, adds
+// the inverse of each of its relatedRaid links to the related RAiD, or
+// corrects the type of an existing link back, as a new version of the
+// related RAiD. Links to RAiDs repo does not hold, and links of types
+// without an inverse, are left alone. Failing to update a related RAiD is
+// logged; the mint or update itself has already succeeded.
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.reciprocate(ctx, created)
+	return created, nil
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	updated, err := r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+	if err != nil {
+		return nil, err
+	}
+	r.reciprocate(ctx, updated)
+	return updated, nil
+}
+
+// reciprocate links the RAiDs related to raid back to it
+func (r *repository) reciprocate(ctx context.Context, raid *models.RAiD) {
+	if raid.Identifier == nil {
+		return
+	}
+	for _, rel := range raid.RelatedRAiD {
+		if rel.Type == nil {
+			continue
+		}
+		inverse, ok := models.InverseRelatedRAiDType(rel.Type.ID)
+		if !ok {
+			continue
+		}
+		if err := r.link(ctx, rel.ID, raid.Identifier.ID, inverse); err != nil {
+			log.Printf("Failed to link %s back to %s: %v", rel.ID, raid.Identifier.ID, err)
+		}
+	}
+}
+
+// link makes the RAiD identified by from relate to the RAiD identified by
+// to with relation type relType
+func (r *repository) link(ctx context.Context, from, to, relType string) error {
+	prefix, suffix, err := identifier.Parse(from)
+	if err != nil {
+		return nil
+	}
+	toPrefix, toSuffix, err := identifier.Parse(to)
+	if err != nil || (toPrefix == prefix && toSuffix == suffix) {
+		return nil
+	}
+	related, err := r.Repository.GetRAiD(ctx, prefix, suffix)
+	if err == storage.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if related.Deprecation != nil {
+		return nil
+	}
+
+	want := &models.IDSchema{ID: relType, SchemaURI: models.RelatedRAiDTypeSchema}
+	found := false
+	for i, rel := range related.RelatedRAiD {
+		if !same(rel.ID, toPrefix, toSuffix) {
+			continue
+		}
+		if rel.Type != nil && rel.Type.ID == relType {
+			return nil
+		}
+		related.RelatedRAiD[i].Type = want
+		found = true
+		break
+	}
+	if !found {
+		related.RelatedRAiD = append(related.RelatedRAiD, models.RelatedRAiD{ID: to, Type: want})
+	}
+	_, err = r.Repository.UpdateRAiD(ctx, prefix, suffix, related)
+	return err
+}
+
+// same reports whether id identifies the RAiD prefix/suffix, whatever form
+// it is written in
+func same(id, prefix, suffix string) bool {
+	p, s, err := identifier.Parse(id)
+	return err == nil && p == prefix && s == suffix
+}
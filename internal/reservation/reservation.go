@@ -0,0 +1,132 @@
+// Package reservation holds identifiers for service points ahead of their
+// mint, so that external systems can print or propagate a RAiD's
+// identifier before its metadata is ready. A reserved identifier is minted
+// by supplying it as identifier.id; until then, or until the reservation
+// expires, no other service point can mint it.
+package reservation
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// DefaultTTL is how long a reservation holds its identifier when no
+	// TTL is configured
+	DefaultTTL = 24 * time.Hour
+	// CleanupInterval is how often Run releases expired reservations
+	CleanupInterval = time.Hour
+)
+
+// Reserve generates an identifier for servicePoint with repo and holds it
+// in store for ttl, DefaultTTL if zero. format writes the identifier out
+// as MintGenerated's does. Like a mint, an identifier that turns out to
+// be minted or reserved is replaced by another, up to
+// storage.MintAttempts times before storage.ErrIdentifiersExhausted.
+func Reserve(ctx context.Context, repo storage.Repository, store storage.ReservationStore, servicePoint int64, ttl time.Duration, format func(prefix, suffix string) string) (*models.Reservation, error) {
+	if ttl == 0 {
+		ttl = DefaultTTL
+	}
+	for range storage.MintAttempts {
+		prefix, suffix, err := repo.GenerateIdentifier(ctx, servicePoint)
+		if err != nil {
+			return nil, err
+		}
+		now := time.Now().UTC()
+		reservation := &models.Reservation{
+			ID:           format(prefix, suffix),
+			Prefix:       prefix,
+			Suffix:       suffix,
+			ServicePoint: servicePoint,
+			Created:      now,
+			ExpiresAt:    now.Add(ttl),
+		}
+		err = store.SaveReservation(ctx, reservation)
+		if errors.Is(err, storage.ErrAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return reservation, nil
+	}
+	return nil, storage.ErrIdentifiersExhausted
+}
+
+// Wrap returns a repository that mints a reserved identifier only for the
+// service point holding it, and releases the reservation once it is
+// minted. Minting an identifier another service point holds fails with
+// storage.ErrAlreadyExists, so generated identifiers skip reserved ones.
+func Wrap(repo storage.Repository, store storage.ReservationStore) storage.Repository {
+	return &repository{Repository: repo, store: store}
+}
+
+type repository struct {
+	storage.Repository
+	store storage.ReservationStore
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if raid.Identifier == nil || raid.Identifier.ID == "" {
+		// Generated here, so that every identifier tried is checked
+		return storage.MintGenerated(ctx, r, raid, identifier.FormatDefault)
+	}
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+	if err != nil {
+		// Left for the backend to reject
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	held, err := r.store.GetReservation(ctx, prefix, suffix)
+	if errors.Is(err, storage.ErrNotFound) {
+		return r.Repository.CreateRAiD(ctx, raid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var owner int64
+	if raid.Identifier.Owner != nil {
+		owner = raid.Identifier.Owner.ServicePoint
+	}
+	if owner != held.ServicePoint {
+		return nil, storage.ErrAlreadyExists
+	}
+
+	created, err := r.Repository.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	// A reservation left behind expires, and holds a minted identifier
+	// in the meantime
+	r.store.DeleteReservation(ctx, prefix, suffix)
+	return created, nil
+}
+
+// Run releases the reservations that expired, every interval until ctx is
+// cancelled. Expired reservations hold nothing even before they are
+// released; releasing them only reclaims their space.
+func Run(ctx context.Context, store storage.ReservationStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n, err := store.ReleaseExpiredReservations(ctx, time.Now())
+		if err != nil {
+			log.Printf("Releasing expired reservations failed: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("Released %d expired reservations", n)
+		}
+	}
+}
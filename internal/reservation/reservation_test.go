@@ -0,0 +1,122 @@
+package reservation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func raidFor(id string, sp int64) *models.RAiD {
+	return &models.RAiD{Identifier: &models.Identifier{ID: id, Owner: &models.Owner{ID: "https://ror.org/04fa4r544", ServicePoint: sp}}}
+}
+
+func TestReserve(t *testing.T) {
+	ctx := context.Background()
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+	repo := Wrap(inner, inner)
+
+	reserved, err := Reserve(ctx, repo, inner, 1, time.Hour, identifier.FormatDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reserved.ID != identifier.FormatDefault(reserved.Prefix, reserved.Suffix) || reserved.ServicePoint != 1 {
+		t.Errorf("unexpected reservation %+v", reserved)
+	}
+	if err := inner.SaveReservation(ctx, reserved); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("expected a held identifier not to be reserved again, got %v", err)
+	}
+
+	// Only the holder mints the identifier, which releases it
+	if _, err := repo.CreateRAiD(ctx, raidFor(reserved.ID, 2)); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("expected another service point refused, got %v", err)
+	}
+	if _, err := repo.CreateRAiD(ctx, raidFor(reserved.ID, 1)); err != nil {
+		t.Fatalf("expected the holder to mint, got %v", err)
+	}
+	if _, err := inner.GetReservation(ctx, reserved.Prefix, reserved.Suffix); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the reservation released, got %v", err)
+	}
+	if err := inner.SaveReservation(ctx, reserved); !errors.Is(err, storage.ErrAlreadyExists) {
+		t.Errorf("expected a minted identifier not to be reserved, got %v", err)
+	}
+
+	// Expired reservations hold nothing
+	expired, err := Reserve(ctx, repo, inner, 1, -time.Second, identifier.FormatDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRAiD(ctx, raidFor(expired.ID, 2)); err != nil {
+		t.Errorf("expected an expired reservation ignored, got %v", err)
+	}
+}
+
+func TestReleaseExpired(t *testing.T) {
+	ctx := context.Background()
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+
+	live, err := Reserve(ctx, inner, inner, 1, time.Hour, identifier.FormatDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := Reserve(ctx, inner, inner, 1, time.Minute, identifier.FormatDefault); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n, err := inner.ReleaseExpiredReservations(ctx, time.Now().Add(10*time.Minute))
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 reservations released, got %d, %v", n, err)
+	}
+	if _, err := inner.GetReservation(ctx, live.Prefix, live.Suffix); err != nil {
+		t.Errorf("expected the live reservation kept, got %v", err)
+	}
+}
+
+func TestWrap_GeneratedSkipReserved(t *testing.T) {
+	ctx := context.Background()
+	inner, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inner.Close()
+	if err := inner.SaveReservation(ctx, &models.Reservation{Prefix: "10.1", Suffix: "held", ServicePoint: 1, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+
+	suffixes := []string{"held", "free"}
+	repo := Wrap(&generator{Repository: inner, next: func() string {
+		s := suffixes[0]
+		suffixes = suffixes[1:]
+		return s
+	}}, inner)
+	raid, err := repo.CreateRAiD(ctx, raidFor("", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raid.Identifier.ID != identifier.FormatDefault("10.1", "free") {
+		t.Errorf("expected the reserved identifier skipped, got %s", raid.Identifier.ID)
+	}
+}
+
+type generator struct {
+	storage.Repository
+	next func() string
+}
+
+func (g *generator) GenerateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
+	return "10.1", g.next(), nil
+}
@@ -0,0 +1,195 @@
+// Package resilience protects the server from a failing storage backend.
+// Wrap retries transient errors with exponential backoff, and a Breaker
+// fails requests fast once the backend keeps failing, instead of letting
+// them queue up behind timeouts, which Check reports for /readyz.
+package resilience
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/health"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// ErrCircuitOpen is returned for storage calls rejected while the circuit
+// breaker is open; it matches storage.ErrBackendUnavailable
+var ErrCircuitOpen error = &storage.UnavailableError{Err: errors.New("circuit breaker open")}
+
+// metrics exposes retries and breaker transitions under /debug/vars
+var metrics = expvar.NewMap("resilience")
+
+// State is the state of a circuit breaker
+type State string
+
+const (
+	// StateClosed lets all calls through
+	StateClosed State = "closed"
+	// StateOpen rejects calls until the open timeout has passed
+	StateOpen State = "open"
+	// StateHalfOpen lets one trial call through to probe the backend
+	StateHalfOpen State = "half-open"
+)
+
+// Status reports the state of a circuit breaker
+type Status struct {
+	State               State     `json:"state"`
+	ConsecutiveFailures int       `json:"consecutiveFailures"`
+	LastError           string    `json:"lastError,omitempty"`
+	OpenedAt            time.Time `json:"openedAt,omitempty"`
+	RetryAt             time.Time `json:"retryAt,omitempty"`
+}
+
+// Breaker is a circuit breaker. It opens after threshold consecutive
+// failed calls, rejects calls for openTimeout and then lets a single trial
+// call through, closing again if it succeeds. A nil Breaker lets every
+// call through and is never open.
+type Breaker struct {
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	lastErr  string
+	openedAt time.Time
+	trial    bool
+}
+
+// NewBreaker creates a closed circuit breaker
+func NewBreaker(threshold int, openTimeout time.Duration) *Breaker {
+	return &Breaker{
+		threshold:   max(threshold, 1),
+		openTimeout: openTimeout,
+		now:         time.Now,
+		state:       StateClosed,
+	}
+}
+
+// allow reports whether a call may go ahead, returning ErrCircuitOpen if
+// not. A call allowed in the half-open state is the trial call and must
+// be followed by record.
+func (b *Breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			metrics.Add("rejected", 1)
+			return ErrCircuitOpen
+		}
+		b.state = StateHalfOpen
+		b.trial = true
+		return nil
+	case StateHalfOpen:
+		if b.trial {
+			metrics.Add("rejected", 1)
+			return ErrCircuitOpen
+		}
+		b.trial = true
+	}
+	return nil
+}
+
+// record counts the outcome of an allowed call; err is a backend failure
+// or nil
+func (b *Breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if err == nil {
+		if b.state != StateClosed {
+			metrics.Add("closed", 1)
+		}
+		b.state = StateClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	b.lastErr = err.Error()
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		if b.state != StateOpen {
+			metrics.Add("opened", 1)
+		}
+		b.state = StateOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Status returns the breaker state
+func (b *Breaker) Status() Status {
+	if b == nil {
+		return Status{State: StateClosed}
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s := Status{State: b.state, ConsecutiveFailures: b.failures, LastError: b.lastErr}
+	if b.state != StateClosed {
+		s.OpenedAt = b.openedAt
+		s.RetryAt = b.openedAt.Add(b.openTimeout)
+	}
+	return s
+}
+
+// FailFast rejects requests with 503 while the breaker is open, before
+// they reach a handler
+func (b *Breaker) FailFast(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := b.Status()
+		if status.State == StateOpen && b.now().Before(status.RetryAt) {
+			b.setRetryAfter(w, status)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(map[string]string{"error": ErrCircuitOpen.Error()})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (b *Breaker) setRetryAfter(w http.ResponseWriter, status Status) {
+	seconds := int(status.RetryAt.Sub(b.now()).Seconds()) + 1
+	w.Header().Set("Retry-After", strconv.Itoa(max(seconds, 1)))
+}
+
+// Check returns the health check of the storage backend. It checks repo,
+// which should pass calls through breaker, and reports it down while the
+// check fails or the breaker is open, so that load balancers stop routing
+// to an instance whose backend is down, and degraded while the breaker
+// is half-open. Checks made while the breaker is half-open are its trial
+// calls.
+func Check(repo storage.Repository, breaker *Breaker) health.Check {
+	return func(ctx context.Context) health.Result {
+		err := repo.HealthCheck(ctx)
+		status := breaker.Status()
+
+		result := health.Result{Status: health.OK, Detail: status}
+		switch {
+		case err != nil:
+			result.Status, result.Message = health.Down, err.Error()
+			if status.State == StateOpen {
+				result.RetryAfter = max(status.RetryAt.Sub(breaker.now()), time.Second)
+			}
+		case status.State != StateClosed:
+			result.Status = health.Degraded
+		}
+		return result
+	}
+}
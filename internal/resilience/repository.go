@@ -0,0 +1,143 @@
+package resilience
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Policy configures retries of transient storage errors
+type Policy struct {
+	// MaxRetries is the number of times a call failing with a transient
+	// error is retried; 0 disables retries
+	MaxRetries int
+	// InitialBackoff is the wait before the first retry, doubled for each
+	// further retry up to MaxBackoff. Waits are jittered by up to half.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Wrap returns a repository that retries calls to repo failing with
+// transient errors (see storage.IsTransient) according to policy, and
+// passes calls through breaker, which may be nil
+func Wrap(repo storage.Repository, policy Policy, breaker *Breaker) storage.Repository {
+	return &repository{Repository: repo, policy: policy, breaker: breaker}
+}
+
+type repository struct {
+	storage.Repository
+	policy  Policy
+	breaker *Breaker
+}
+
+// call runs fn through the breaker, retrying transient errors
+func call[T any](ctx context.Context, r *repository, fn func() (T, error)) (T, error) {
+	var zero T
+	backoff := r.policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := r.breaker.allow(); err != nil {
+			return zero, err
+		}
+		v, err := fn()
+		failure := err
+		if !storage.IsUnavailable(err) {
+			// The backend answered, refusing the request, or the caller
+			// gave up
+			failure = nil
+		}
+		r.breaker.record(failure)
+		if err == nil || attempt >= r.policy.MaxRetries || !storage.IsTransient(err) {
+			return v, storage.Unavailable(err)
+		}
+
+		metrics.Add("retries", 1)
+		wait := backoff/2 + rand.N(backoff/2+1)
+		select {
+		case <-ctx.Done():
+			return zero, storage.Unavailable(err)
+		case <-time.After(wait):
+		}
+		backoff = min(backoff*2, max(r.policy.MaxBackoff, r.policy.InitialBackoff))
+	}
+}
+
+// exec adapts calls without a result to call
+func exec(ctx context.Context, r *repository, fn func() error) error {
+	_, err := call(ctx, r, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	return call(ctx, r, func() (*models.RAiD, error) { return r.Repository.CreateRAiD(ctx, raid) })
+}
+
+func (r *repository) GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+	return call(ctx, r, func() (*models.RAiD, error) { return r.Repository.GetRAiD(ctx, prefix, suffix) })
+}
+
+func (r *repository) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	return call(ctx, r, func() ([]*models.RAiD, error) { return r.Repository.GetRAiDs(ctx, refs) })
+}
+
+func (r *repository) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
+	return call(ctx, r, func() (*models.RAiD, error) { return r.Repository.GetRAiDVersion(ctx, prefix, suffix, version) })
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	return call(ctx, r, func() (*models.RAiD, error) { return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid) })
+}
+
+func (r *repository) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return call(ctx, r, func() ([]*models.RAiD, error) { return r.Repository.ListRAiDs(ctx, filter) })
+}
+
+func (r *repository) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return call(ctx, r, func() ([]*models.RAiD, error) { return r.Repository.ListPublicRAiDs(ctx, filter) })
+}
+
+func (r *repository) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
+	return call(ctx, r, func() ([]*models.RAiD, error) { return r.Repository.GetRAiDHistory(ctx, prefix, suffix) })
+}
+
+func (r *repository) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	return call(ctx, r, func() ([]storage.VersionChange, error) { return r.Repository.GetRAiDChanges(ctx, prefix, suffix, page) })
+}
+
+func (r *repository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	return exec(ctx, r, func() error { return r.Repository.DeleteRAiD(ctx, prefix, suffix) })
+}
+
+func (r *repository) GenerateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
+	id, err := call(ctx, r, func() ([2]string, error) {
+		prefix, suffix, err := r.Repository.GenerateIdentifier(ctx, servicePointID)
+		return [2]string{prefix, suffix}, err
+	})
+	return id[0], id[1], err
+}
+
+func (r *repository) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	return call(ctx, r, func() (*models.ServicePoint, error) { return r.Repository.CreateServicePoint(ctx, sp) })
+}
+
+func (r *repository) GetServicePoint(ctx context.Context, id int64) (*models.ServicePoint, error) {
+	return call(ctx, r, func() (*models.ServicePoint, error) { return r.Repository.GetServicePoint(ctx, id) })
+}
+
+func (r *repository) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	return call(ctx, r, func() (*models.ServicePoint, error) { return r.Repository.UpdateServicePoint(ctx, id, sp) })
+}
+
+func (r *repository) ListServicePoints(ctx context.Context) ([]*models.ServicePoint, error) {
+	return call(ctx, r, func() ([]*models.ServicePoint, error) { return r.Repository.ListServicePoints(ctx) })
+}
+
+func (r *repository) DeleteServicePoint(ctx context.Context, id int64) error {
+	return exec(ctx, r, func() error { return r.Repository.DeleteServicePoint(ctx, id) })
+}
+
+func (r *repository) HealthCheck(ctx context.Context) error {
+	return exec(ctx, r, func() error { return r.Repository.HealthCheck(ctx) })
+}
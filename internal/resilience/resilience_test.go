@@ -0,0 +1,114 @@
+package resilience
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/health"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+var errConflict = errors.New("serialization conflict")
+
+func init() {
+	storage.RegisterTransient(func(err error) bool { return errors.Is(err, errConflict) })
+}
+
+func TestWrapRetriesTransientErrors(t *testing.T) {
+	repo := testutil.NewMockRepository()
+	attempts := 0
+	repo.GetRAiDFunc = func(context.Context, string, string) (*models.RAiD, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errConflict
+		}
+		return testutil.NewTestRAiD("10.12345", "a"), nil
+	}
+	wrapped := Wrap(repo, Policy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond}, nil)
+
+	if _, err := wrapped.GetRAiD(context.Background(), "10.12345", "a"); err != nil {
+		t.Fatalf("expected the retried call to succeed, got %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+
+	attempts = -10
+	if _, err := wrapped.GetRAiD(context.Background(), "10.12345", "a"); !errors.Is(err, errConflict) {
+		t.Errorf("expected the last error after exhausting retries, got %v", err)
+	}
+	if attempts != -6 {
+		t.Errorf("expected 4 attempts, got %d", attempts+10)
+	}
+
+	attempts = 0
+	repo.GetRAiDFunc = func(context.Context, string, string) (*models.RAiD, error) {
+		attempts++
+		return nil, storage.ErrNotFound
+	}
+	if _, err := wrapped.GetRAiD(context.Background(), "10.12345", "a"); !errors.Is(err, storage.ErrNotFound) || attempts != 1 {
+		t.Errorf("expected a permanent error without retries, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Unix(0, 0)
+	breaker := NewBreaker(2, time.Minute)
+	breaker.now = func() time.Time { return now }
+
+	repo := testutil.NewMockRepository()
+	down := true
+	repo.HealthCheckFunc = func(context.Context) error {
+		if down {
+			return context.DeadlineExceeded
+		}
+		return nil
+	}
+	wrapped := Wrap(repo, Policy{}, breaker)
+	ctx := context.Background()
+
+	// Permanent errors are answers from the backend and do not count
+	repo.GetRAiDFunc = func(context.Context, string, string) (*models.RAiD, error) {
+		return nil, storage.ErrNotFound
+	}
+	for range 3 {
+		wrapped.GetRAiD(ctx, "10.12345", "a")
+	}
+	if s := breaker.Status(); s.State != StateClosed {
+		t.Fatalf("expected not found errors to leave the breaker closed, got %s", s.State)
+	}
+
+	wrapped.HealthCheck(ctx)
+	wrapped.HealthCheck(ctx)
+	if s := breaker.Status(); s.State != StateOpen || s.ConsecutiveFailures != 2 {
+		t.Fatalf("expected the breaker to open after 2 failures, got %+v", s)
+	}
+
+	down = false
+	if err := wrapped.HealthCheck(ctx); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected calls to fail fast while open, got %v", err)
+	}
+	handler := breaker.FailFast(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/raid/", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "61" {
+		t.Errorf("expected 503 with Retry-After, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if result := Check(wrapped, breaker)(ctx); result.Status != health.Down || result.RetryAfter != time.Minute {
+		t.Errorf("expected storage reported down while open, for a minute, got %+v", result)
+	}
+
+	now = now.Add(time.Minute)
+	if result := Check(wrapped, breaker)(ctx); result.Status != health.OK {
+		t.Errorf("expected the trial check to succeed, got %+v", result)
+	}
+	if s := breaker.Status(); s.State != StateClosed || s.ConsecutiveFailures != 0 {
+		t.Errorf("expected a successful trial call to close the breaker, got %+v", s)
+	}
+}
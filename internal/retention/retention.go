@@ -0,0 +1,393 @@
+// Package retention removes data once it is older than the windows set by
+// retention rules, on a schedule. Rules apply to soft deleted RAiDs,
+// through backends implementing storage.Purger, to stored access logs,
+// reservations and the records of purges and redactions, through the
+// backend's pruner of each. A rule holds for all service points or for
+// one, over the rule for all. Each RAiD purged is recorded like a purge
+// made by an operator, with the actor "retention".
+package retention
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/cache"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/robfig/cron/v3"
+)
+
+// Actor is recorded as the actor of purges made by the retention window
+const Actor = "retention"
+
+// The data retention rules apply to
+const (
+	// DataDeleted is soft deleted RAiDs, by when they were deleted and
+	// the service point owning them
+	DataDeleted = "deleted"
+	// DataAccessLog is stored access log entries, by when they were
+	// logged and the service point of the caller
+	DataAccessLog = "access-log"
+	// DataReservations is reservations, by when they were made and the
+	// service point holding them, whether or not they have expired
+	DataReservations = "reservations"
+	// DataAudit is the records of purges and redactions, by when they
+	// were made; rules for it apply to all service points
+	DataAudit = "audit"
+)
+
+// ErrUnsupported is returned for storage backends that cannot purge RAiDs
+var ErrUnsupported = errors.New("storage backend does not support purging RAiDs")
+
+// metrics exposes retention outcomes under /debug/vars
+var metrics = expvar.NewMap("retention")
+
+// Rule keeps one kind of data for a window
+type Rule struct {
+	// Data is DataDeleted, DataAccessLog, DataReservations or DataAudit
+	Data string
+	// ServicePoint limits the rule to the data of one service point; 0
+	// applies it to all without a rule of their own
+	ServicePoint int64
+	// After is the window data is kept for
+	After time.Duration
+}
+
+// String returns the rule as data[@servicePoint]=after
+func (r Rule) String() string {
+	if r.ServicePoint != 0 {
+		return fmt.Sprintf("%s@%d=%s", r.Data, r.ServicePoint, r.After)
+	}
+	return fmt.Sprintf("%s=%s", r.Data, r.After)
+}
+
+// Policy is the rules an enforcer applies
+type Policy struct {
+	Rules []Rule
+	// DryRun makes runs report what they would remove without removing
+	// it, to try out rules
+	DryRun bool
+}
+
+// Summary counts the work of one retention run
+type Summary struct {
+	// DryRun marks a run that removed nothing; the counts are of what it
+	// would have removed
+	DryRun bool `json:"dryRun,omitempty"`
+	// Deleted is the number of soft deleted RAiDs found
+	Deleted int `json:"deleted"`
+	// Purged is the number of RAiDs purged by the run
+	Purged int `json:"purged"`
+	// Failed is the number of RAiDs that could not be purged
+	Failed int `json:"failed"`
+	// AccessLog is the number of access log entries removed
+	AccessLog int `json:"accessLog"`
+	// Reservations is the number of reservations removed
+	Reservations int `json:"reservations"`
+	// Audit is the number of purge and redaction records removed
+	Audit int `json:"audit"`
+}
+
+// Status reports the enforcer's state
+type Status struct {
+	Schedule     string    `json:"schedule"`
+	Rules        []string  `json:"rules"`
+	DryRun       bool      `json:"dryRun,omitempty"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"nextRun,omitempty"`
+	LastRun      time.Time `json:"lastRun,omitempty"`
+	LastError    string    `json:"lastError,omitempty"`
+	LastDuration string    `json:"lastDuration,omitempty"`
+	LastSummary  *Summary  `json:"lastSummary,omitempty"`
+	Successes    int64     `json:"successes"`
+	Failures     int64     `json:"failures"`
+}
+
+// Enforcer removes data older than the windows of its rules on a cron
+// schedule
+type Enforcer struct {
+	repo storage.Repository
+	// backend holds the stores beyond Repository, see storage.Source
+	backend  storage.Repository
+	policy   Policy
+	schedule cron.Schedule
+	cache    cache.RAiDInvalidator
+
+	mu     sync.Mutex
+	status Status
+}
+
+// New creates an enforcer for a standard five-field cron expression or
+// descriptor such as "@daily". It returns ErrUnsupported for rules for
+// soft deleted RAiDs if repo cannot purge RAiDs, and an error for other
+// rules repo cannot apply.
+func New(repo storage.Repository, spec string, policy Policy) (*Enforcer, error) {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention schedule %q: %w", spec, err)
+	}
+	if len(policy.Rules) == 0 {
+		return nil, fmt.Errorf("no retention rules")
+	}
+
+	backend := storage.Source(repo)
+	seen := make(map[Rule]bool, len(policy.Rules))
+	rules := make([]string, 0, len(policy.Rules))
+	for _, rule := range policy.Rules {
+		if rule.After <= 0 {
+			return nil, fmt.Errorf("retention rule %s: window must be positive", rule)
+		}
+		if rule.ServicePoint < 0 {
+			return nil, fmt.Errorf("retention rule %s: invalid service point", rule)
+		}
+		var supported bool
+		switch rule.Data {
+		case DataDeleted:
+			if _, ok := repo.(storage.Purger); !ok {
+				return nil, ErrUnsupported
+			}
+			supported = true
+		case DataAccessLog:
+			_, supported = backend.(storage.AccessLogPruner)
+		case DataReservations:
+			_, supported = backend.(storage.ReservationPruner)
+		case DataAudit:
+			if rule.ServicePoint != 0 {
+				return nil, fmt.Errorf("retention rule %s: audit records have no service point", rule)
+			}
+			_, supported = backend.(storage.AuditPruner)
+		default:
+			return nil, fmt.Errorf("retention rule %s: unknown data %q (want %s, %s, %s or %s)", rule, rule.Data, DataDeleted, DataAccessLog, DataReservations, DataAudit)
+		}
+		if !supported {
+			return nil, fmt.Errorf("retention rule %s: storage backend does not support removing %s", rule, rule.Data)
+		}
+		key := Rule{Data: rule.Data, ServicePoint: rule.ServicePoint}
+		if seen[key] {
+			return nil, fmt.Errorf("retention rule %s: more than one rule for the same data", rule)
+		}
+		seen[key] = true
+		rules = append(rules, rule.String())
+	}
+
+	e := &Enforcer{
+		repo:     repo,
+		backend:  backend,
+		policy:   policy,
+		schedule: schedule,
+	}
+	e.status.Schedule = spec
+	e.status.Rules = rules
+	e.status.DryRun = policy.DryRun
+	return e, nil
+}
+
+// WithCache drops the RAiDs the enforcer purges from c, the cache serving
+// the API. It must be called before Run.
+func (e *Enforcer) WithCache(c cache.RAiDInvalidator) *Enforcer {
+	e.cache = c
+	return e
+}
+
+// cutoffs returns the cutoffs of the rules for data at now, and whether
+// there are any
+func (e *Enforcer) cutoffs(data string, now time.Time) (storage.Cutoffs, bool) {
+	var cutoffs storage.Cutoffs
+	found := false
+	for _, rule := range e.policy.Rules {
+		if rule.Data != data {
+			continue
+		}
+		found = true
+		cutoff := now.Add(-rule.After)
+		if rule.ServicePoint == 0 {
+			cutoffs.Default = cutoff
+			continue
+		}
+		if cutoffs.ServicePoints == nil {
+			cutoffs.ServicePoints = make(map[int64]time.Time)
+		}
+		cutoffs.ServicePoints[rule.ServicePoint] = cutoff
+	}
+	return cutoffs, found
+}
+
+// Run applies the rules on schedule until ctx is cancelled
+func (e *Enforcer) Run(ctx context.Context) {
+	for {
+		next := e.schedule.Next(time.Now())
+		e.mu.Lock()
+		e.status.NextRun = next
+		e.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if _, err := e.RunNow(ctx); err != nil {
+			log.Printf("Scheduled retention run failed: %v", err)
+		}
+	}
+}
+
+// RunNow applies the rules immediately, or with a dry run policy reports
+// what they would remove. It returns an error if a run is already in
+// progress.
+func (e *Enforcer) RunNow(ctx context.Context) (*Status, error) {
+	e.mu.Lock()
+	if e.status.Running {
+		e.mu.Unlock()
+		return nil, fmt.Errorf("retention run already in progress")
+	}
+	e.status.Running = true
+	e.mu.Unlock()
+
+	start := time.Now()
+	summary, err := e.apply(ctx, start, e.policy.DryRun)
+
+	e.mu.Lock()
+	e.status.Running = false
+	e.status.LastRun = start
+	e.status.LastDuration = time.Since(start).String()
+	if err != nil {
+		e.status.LastError = err.Error()
+		e.status.Failures++
+		metrics.Add("failures", 1)
+	} else {
+		e.status.LastError = ""
+		e.status.LastSummary = summary
+		e.status.Successes++
+		metrics.Add("successes", 1)
+		if !summary.DryRun {
+			metrics.Add("purged", int64(summary.Purged))
+			metrics.Add("accessLog", int64(summary.AccessLog))
+			metrics.Add("reservations", int64(summary.Reservations))
+			metrics.Add("audit", int64(summary.Audit))
+		}
+	}
+	e.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	verb := "removed"
+	if summary.DryRun {
+		verb = "would remove"
+	}
+	log.Printf("Retention %s %d of %d deleted RAiDs (%d failed), %d access log entries, %d reservations and %d audit records",
+		verb, summary.Purged, summary.Deleted, summary.Failed, summary.AccessLog, summary.Reservations, summary.Audit)
+
+	status := e.Status()
+	return &status, nil
+}
+
+// DryRun reports what a run would remove now, without removing anything
+// or recording a run
+func (e *Enforcer) DryRun(ctx context.Context) (*Summary, error) {
+	return e.apply(ctx, time.Now(), true)
+}
+
+// apply removes the data older than the windows of the rules at now, or
+// with dryRun counts it
+func (e *Enforcer) apply(ctx context.Context, now time.Time, dryRun bool) (*Summary, error) {
+	summary := &Summary{DryRun: dryRun}
+	if cutoffs, ok := e.cutoffs(DataDeleted, now); ok {
+		if err := e.purge(ctx, now, cutoffs, summary); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if cutoffs, ok := e.cutoffs(DataAccessLog, now); ok {
+		if summary.AccessLog, err = e.backend.(storage.AccessLogPruner).PruneAccessLog(ctx, cutoffs, dryRun); err != nil {
+			return nil, fmt.Errorf("failed to prune access log: %w", err)
+		}
+	}
+	if cutoffs, ok := e.cutoffs(DataReservations, now); ok {
+		if summary.Reservations, err = e.backend.(storage.ReservationPruner).PruneReservations(ctx, cutoffs, dryRun); err != nil {
+			return nil, fmt.Errorf("failed to prune reservations: %w", err)
+		}
+	}
+	if cutoffs, ok := e.cutoffs(DataAudit, now); ok {
+		if summary.Audit, err = e.backend.(storage.AuditPruner).PruneAudit(ctx, cutoffs.Default, dryRun); err != nil {
+			return nil, fmt.Errorf("failed to prune audit records: %w", err)
+		}
+	}
+	return summary, nil
+}
+
+// purge purges the RAiDs deleted before the cutoff of the service point
+// owning them, counting them in summary. RAiDs whose deletion time was not
+// recorded are kept, as their age is unknown. Failures of single RAiDs
+// are logged and counted so that one does not stop the run.
+func (e *Enforcer) purge(ctx context.Context, now time.Time, cutoffs storage.Cutoffs, summary *Summary) error {
+	raids, err := e.repo.ListRAiDs(ctx, &storage.RAiDFilter{DeletedOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to list deleted RAiDs: %w", err)
+	}
+
+	purger := e.repo.(storage.Purger)
+	summary.Deleted = len(raids)
+	for _, raid := range raids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if raid.Identifier == nil || raid.Metadata == nil || raid.Metadata.DeletedAt.IsZero() {
+			continue
+		}
+		var servicePoint int64
+		if raid.Identifier.Owner != nil {
+			servicePoint = raid.Identifier.Owner.ServicePoint
+		}
+		cutoff := cutoffs.For(servicePoint)
+		if !raid.Metadata.DeletedAt.Before(cutoff) {
+			continue
+		}
+		prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil {
+			continue
+		}
+		if summary.DryRun {
+			log.Printf("Retention would purge RAiD %s/%s", prefix, suffix)
+			summary.Purged++
+			continue
+		}
+
+		err = purger.PurgeRAiD(ctx, &models.Purge{
+			Prefix: prefix,
+			Suffix: suffix,
+			Actor:  Actor,
+			Reason: fmt.Sprintf("deleted for longer than the retention window of %s", now.Sub(cutoff)),
+			Purged: time.Now().UTC(),
+		})
+		if err != nil {
+			log.Printf("Failed to purge RAiD %s/%s: %v", prefix, suffix, err)
+			summary.Failed++
+			continue
+		}
+		if e.cache != nil {
+			e.cache.InvalidateRAiD(ctx, prefix, suffix)
+		}
+		log.Printf("Retention purged RAiD %s/%s", prefix, suffix)
+		summary.Purged++
+	}
+	return nil
+}
+
+// Status returns the enforcer state
+func (e *Enforcer) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.status
+}
@@ -0,0 +1,167 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+// invalidations records the RAiDs dropped from a cache
+type invalidations []string
+
+func (i *invalidations) InvalidateRAiD(_ context.Context, prefix, suffix string) {
+	*i = append(*i, prefix+"/"+suffix)
+}
+
+func TestEnforcer_RunNow(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	for _, suffix := range []string{"old", "recent", "kept"} {
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := repo.DeleteRAiD(ctx, "10.99999", "old"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if err := repo.DeleteRAiD(ctx, "10.99999", "recent"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := repo.ListRAiDs(ctx, &storage.RAiDFilter{DeletedOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 2 || deleted[0].Metadata.DeletedAt.IsZero() {
+		t.Fatalf("expected 2 deleted RAiDs with deletion times, got %+v", deleted)
+	}
+
+	e, err := New(repo, "@daily", Policy{Rules: []Rule{{Data: DataDeleted, After: 100 * time.Millisecond}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cached invalidations
+	e.WithCache(&cached)
+	status, err := e.RunNow(ctx)
+	if err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if want := (Summary{Deleted: 2, Purged: 1}); *status.LastSummary != want {
+		t.Errorf("got summary %+v, want %+v", *status.LastSummary, want)
+	}
+	if len(cached) != 1 || cached[0] != "10.99999/old" {
+		t.Errorf("expected the purged RAiD dropped from the cache, got %v", cached)
+	}
+
+	if _, err := repo.GetRAiDVersion(ctx, "10.99999", "old", 1); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the history of a purged RAiD to be gone, got %v", err)
+	}
+	if deleted, _ := repo.ListRAiDs(ctx, &storage.RAiDFilter{DeletedOnly: true}); len(deleted) != 1 {
+		t.Errorf("expected the RAiD deleted within the window to be kept, got %d", len(deleted))
+	}
+	purges, err := repo.ListPurges(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(purges) != 1 || purges[0].Suffix != "old" || purges[0].Actor != Actor || purges[0].Deleted.IsZero() {
+		t.Errorf("expected the purge on record, got %+v", purges)
+	}
+
+	// Only soft deleted RAiDs are purged
+	err = repo.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: "kept", Purged: time.Now()})
+	if !errors.Is(err, storage.ErrNotDeleted) {
+		t.Errorf("expected ErrNotDeleted purging a current RAiD, got %v", err)
+	}
+	err = repo.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: "old", Purged: time.Now()})
+	if !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected ErrNotFound purging a purged RAiD, got %v", err)
+	}
+}
+
+func TestEnforcer_Rules(t *testing.T) {
+	ctx := context.Background()
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	now := time.Now().UTC()
+	for i, sp := range []int64{1, 2} {
+		err := repo.SaveReservation(ctx, &models.Reservation{
+			Prefix:       "10.99999",
+			Suffix:       []string{"r1", "r2"}[i],
+			ServicePoint: sp,
+			Created:      now.Add(-2 * time.Hour),
+			ExpiresAt:    now.Add(time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, suffix := range []string{"old", "recent"} {
+		if _, err := repo.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.DeleteRAiD(ctx, "10.99999", suffix); err != nil {
+			t.Fatal(err)
+		}
+		purged := now.Add(-time.Duration(48-47*i) * time.Hour)
+		if err := repo.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: suffix, Purged: purged}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err = New(repo, "@daily", Policy{Rules: []Rule{{Data: DataAccessLog, After: time.Hour}}})
+	if err == nil {
+		t.Error("expected an error for access log rules with a backend keeping no access log")
+	}
+	_, err = New(repo, "@daily", Policy{Rules: []Rule{{Data: DataAudit, ServicePoint: 2, After: time.Hour}}})
+	if err == nil {
+		t.Error("expected an error for audit rules of a service point")
+	}
+
+	e, err := New(repo, "@daily", Policy{Rules: []Rule{
+		{Data: DataReservations, After: time.Hour},
+		{Data: DataReservations, ServicePoint: 2, After: 3 * time.Hour},
+		{Data: DataAudit, After: 24 * time.Hour},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Summary{DryRun: true, Reservations: 1, Audit: 1}
+	if summary, err := e.DryRun(ctx); err != nil || *summary != want {
+		t.Fatalf("got dry run summary %+v (%v), want %+v", summary, err, want)
+	}
+	if purges, _ := repo.ListPurges(ctx); len(purges) != 2 {
+		t.Errorf("expected a dry run to remove nothing, got %d purges", len(purges))
+	}
+
+	status, err := e.RunNow(ctx)
+	if err != nil {
+		t.Fatalf("RunNow failed: %v", err)
+	}
+	if want := (Summary{Reservations: 1, Audit: 1}); *status.LastSummary != want {
+		t.Errorf("got summary %+v, want %+v", *status.LastSummary, want)
+	}
+	if _, err := repo.GetReservation(ctx, "10.99999", "r1"); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("expected the reservation past the window to be removed, got %v", err)
+	}
+	if _, err := repo.GetReservation(ctx, "10.99999", "r2"); err != nil {
+		t.Errorf("expected the reservation within its service point's window to be kept, got %v", err)
+	}
+	if purges, _ := repo.ListPurges(ctx); len(purges) != 1 || purges[0].Suffix != "recent" {
+		t.Errorf("expected only the recent purge on record, got %+v", purges)
+	}
+}
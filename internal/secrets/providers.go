@@ -0,0 +1,214 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/awsv4"
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// fileProvider reads secrets from files, as mounted by Docker and Kubernetes
+type fileProvider struct{}
+
+func (fileProvider) Fetch(ctx context.Context, locator string) (string, error) {
+	path, key := splitKey(locator)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return selectKey(strings.TrimRight(string(data), "\r\n"), key)
+}
+
+// envProvider reads secrets from another environment variable
+type envProvider struct{}
+
+func (envProvider) Fetch(ctx context.Context, locator string) (string, error) {
+	value, ok := os.LookupEnv(locator)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", locator)
+	}
+	return value, nil
+}
+
+// vaultProvider reads secrets from HashiCorp Vault using VAULT_ADDR and
+// VAULT_TOKEN (or VAULT_TOKEN_FILE). Both KV v1 and KV v2 responses are understood.
+type vaultProvider struct{}
+
+func (vaultProvider) Fetch(ctx context.Context, locator string) (string, error) {
+	path, key := splitKey(locator)
+	if key == "" {
+		return "", fmt.Errorf("vault references require a #key selector")
+	}
+
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if tokenFile := os.Getenv("VAULT_TOKEN_FILE"); token == "" && tokenFile != "" {
+		data, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read VAULT_TOKEN_FILE: %w", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+
+	body, err := doRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	// KV v2 nests the secret under data.data
+	fields := resp.Data
+	if nested, ok := fields["data"].(map[string]interface{}); ok {
+		fields = nested
+	}
+	return stringField(fields, key)
+}
+
+// awsProvider reads secrets from AWS Secrets Manager using static credentials
+// from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION
+type awsProvider struct{}
+
+func (awsProvider) Fetch(ctx context.Context, locator string) (string, error) {
+	secretID, key := splitKey(locator)
+
+	creds, err := awsv4.CredentialsFromEnv("")
+	if err != nil {
+		return "", err
+	}
+
+	payload, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	host := fmt.Sprintf("secretsmanager.%s.amazonaws.com", creds.Region)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/", strings.NewReader(string(payload)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	awsv4.Sign(req, awsv4.PayloadHash(payload), "secretsmanager", creds, time.Now())
+
+	body, err := doRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid secrets manager response: %w", err)
+	}
+	return selectKey(resp.SecretString, key)
+}
+
+// gcpProvider reads secrets from GCP Secret Manager. The access token is taken
+// from GOOGLE_OAUTH_ACCESS_TOKEN or, when unset, from the GCE metadata server.
+type gcpProvider struct{}
+
+func (gcpProvider) Fetch(ctx context.Context, locator string) (string, error) {
+	name, key := splitKey(locator)
+	if !strings.Contains(name, "/versions/") {
+		name += "/versions/latest"
+	}
+
+	token, err := gcpAccessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://secretmanager.googleapis.com/v1/"+name+":access", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	body, err := doRequest(req)
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid secret manager response: %w", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("invalid secret payload encoding: %w", err)
+	}
+	return selectKey(string(data), key)
+}
+
+func gcpAccessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	body, err := doRequest(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain GCP access token: %w", err)
+	}
+
+	var resp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("invalid metadata token response: %w", err)
+	}
+	return resp.AccessToken, nil
+}
+
+func doRequest(req *http.Request) ([]byte, error) {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("secret store returned %s", resp.Status)
+	}
+	return body, nil
+}
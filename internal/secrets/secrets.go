@@ -0,0 +1,166 @@
+// Package secrets resolves credentials from external secret stores.
+//
+// Configuration values that hold credentials (JWT secret, database password)
+// may contain a secret reference instead of the literal value. A reference is
+// a scheme followed by a provider-specific locator:
+//
+//	file:/run/secrets/jwt_secret            Docker/Kubernetes secret file
+//	env:RAID_DB_PASSWORD                     another environment variable
+//	vault:secret/data/raid/db#password       HashiCorp Vault (KV v1 or v2)
+//	awssm:raid/db#password                   AWS Secrets Manager
+//	gcpsm:projects/p/secrets/s/versions/latest  GCP Secret Manager
+//
+// The optional #key suffix selects a field when the secret is a JSON object.
+// Values without a registered scheme are returned unchanged.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Provider fetches secret values from a backing store
+type Provider interface {
+	// Fetch returns the secret identified by the locator part of a reference
+	Fetch(ctx context.Context, locator string) (string, error)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = make(map[string]Provider)
+)
+
+func init() {
+	Register("file", fileProvider{})
+	Register("env", envProvider{})
+	Register("vault", vaultProvider{})
+	Register("awssm", awsProvider{})
+	Register("gcpsm", gcpProvider{})
+}
+
+// Register registers a provider for a reference scheme
+func Register(scheme string, p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[scheme] = p
+}
+
+// IsReference reports whether value is a secret reference with a registered scheme
+func IsReference(value string) bool {
+	_, _, ok := lookup(value)
+	return ok
+}
+
+// Resolve returns the secret a reference points to, or value itself when it
+// is not a reference
+func Resolve(ctx context.Context, value string) (string, error) {
+	p, locator, ok := lookup(value)
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := p.Fetch(ctx, locator)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve secret %s: %w", Describe(value), err)
+	}
+	return secret, nil
+}
+
+// Describe returns a log-safe description of a configured secret value
+func Describe(value string) string {
+	if value == "" {
+		return "(unset)"
+	}
+	if scheme, _, ok := strings.Cut(value, ":"); ok && IsReference(value) {
+		return scheme + ":(reference)"
+	}
+	return "(redacted)"
+}
+
+func lookup(value string) (Provider, string, bool) {
+	scheme, locator, ok := strings.Cut(value, ":")
+	if !ok || locator == "" {
+		return nil, "", false
+	}
+
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[scheme]
+	return p, locator, ok
+}
+
+// splitKey separates an optional #key field selector from a locator
+func splitKey(locator string) (string, string) {
+	path, key, _ := strings.Cut(locator, "#")
+	return path, key
+}
+
+// selectKey extracts key from a JSON object secret, or returns the raw value
+// when no key is requested
+func selectKey(raw, key string) (string, error) {
+	if key == "" {
+		return raw, nil
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &fields); err != nil {
+		return "", fmt.Errorf("secret is not a JSON object, cannot select key %q", key)
+	}
+	return stringField(fields, key)
+}
+
+func stringField(fields map[string]interface{}, key string) (string, error) {
+	v, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("key %q is not a string", key)
+	}
+	return s, nil
+}
+
+// Cached resolves a secret reference and caches the value for a TTL, so
+// rotated credentials are picked up without hitting the secret store on
+// every use
+type Cached struct {
+	value string
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	secret  string
+	fetched time.Time
+}
+
+// NewCached creates a cached secret. A zero TTL caches the value forever.
+func NewCached(value string, ttl time.Duration) *Cached {
+	return &Cached{value: value, ttl: ttl}
+}
+
+// Get returns the current secret value, refreshing it when the TTL has expired.
+// If a refresh fails the previously fetched value is kept.
+func (c *Cached) Get(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetched.IsZero() && (c.ttl == 0 || time.Since(c.fetched) < c.ttl) {
+		return c.secret, nil
+	}
+
+	secret, err := Resolve(ctx, c.value)
+	if err != nil {
+		if !c.fetched.IsZero() {
+			return c.secret, nil
+		}
+		return "", err
+	}
+
+	c.secret = secret
+	c.fetched = time.Now()
+	return secret, nil
+}
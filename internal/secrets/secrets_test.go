@@ -0,0 +1,149 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolve_Literal(t *testing.T) {
+	got, err := Resolve(context.Background(), "plain-secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "plain-secret" {
+		t.Errorf("expected literal value, got %q", got)
+	}
+
+	// Values that merely contain a colon are not references
+	got, _ = Resolve(context.Background(), "pa:ss")
+	if got != "pa:ss" {
+		t.Errorf("expected literal value, got %q", got)
+	}
+}
+
+func TestResolve_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwt_secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Resolve(context.Background(), "file:"+path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "from-file" {
+		t.Errorf("expected trailing newline to be trimmed, got %q", got)
+	}
+}
+
+func TestResolve_FileJSONKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "db.json")
+	if err := os.WriteFile(path, []byte(`{"username":"raid","password":"s3cret"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Resolve(context.Background(), "file:"+path+"#password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "s3cret" {
+		t.Errorf("expected selected key, got %q", got)
+	}
+}
+
+func TestResolve_Env(t *testing.T) {
+	t.Setenv("RAID_TEST_SECRET", "from-env")
+
+	got, err := Resolve(context.Background(), "env:RAID_TEST_SECRET")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "from-env" {
+		t.Errorf("expected env value, got %q", got)
+	}
+
+	if _, err := Resolve(context.Background(), "env:RAID_TEST_SECRET_MISSING"); err == nil {
+		t.Error("expected error for missing variable")
+	}
+}
+
+func TestResolve_VaultKV2(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/secret/data/raid/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"data":{"data":{"password":"vault-pass"},"metadata":{"version":3}}}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "root-token")
+
+	got, err := Resolve(context.Background(), "vault:secret/data/raid/db#password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "vault-pass" {
+		t.Errorf("expected vault value, got %q", got)
+	}
+}
+
+func TestCached_RefreshesAfterTTL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(path, []byte("first"), 0600)
+
+	cached := NewCached("file:"+path, 10*time.Millisecond)
+
+	got, err := cached.Get(context.Background())
+	if err != nil || got != "first" {
+		t.Fatalf("expected first, got %q (%v)", got, err)
+	}
+
+	os.WriteFile(path, []byte("rotated"), 0600)
+
+	got, _ = cached.Get(context.Background())
+	if got != "first" {
+		t.Errorf("expected cached value before TTL, got %q", got)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	got, _ = cached.Get(context.Background())
+	if got != "rotated" {
+		t.Errorf("expected rotated value after TTL, got %q", got)
+	}
+
+	// A failing refresh keeps the last known value
+	os.Remove(path)
+	time.Sleep(20 * time.Millisecond)
+
+	got, err = cached.Get(context.Background())
+	if err != nil || got != "rotated" {
+		t.Errorf("expected last known value on refresh failure, got %q (%v)", got, err)
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := map[string]string{
+		"":                 "(unset)",
+		"literal":          "(redacted)",
+		"file:/run/secret": "file:(reference)",
+		"vault:a/b#c":      "vault:(reference)",
+	}
+
+	for value, want := range tests {
+		if got := Describe(value); got != want {
+			t.Errorf("Describe(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
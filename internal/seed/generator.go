@@ -0,0 +1,142 @@
+package seed
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Generator produces synthetic RAiDs for load testing. Unlike the fixed
+// shape of the demo data, the number of contributors, subjects, related
+// objects and related RAiDs follows skewed distributions like those of
+// real registries: most projects are small, a long tail is very large.
+// A Generator is safe for concurrent use.
+type Generator struct {
+	mu    sync.Mutex
+	rnd   *rand.Rand
+	n     int
+	known []string
+}
+
+// maxKnown bounds the identifiers a Generator remembers for related RAiDs
+const maxKnown = 1000
+
+// NewGenerator creates a generator; the same seed produces the same
+// sequence of documents
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// RAiD generates a new document owned by sp. Its identifier is left empty
+// for the backend to mint.
+func (g *Generator) RAiD(sp *models.ServicePoint) *models.RAiD {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	raid := newRAiD(g.rnd, sp, g.n)
+	g.n++
+	start := raid.Date.StartDate
+
+	// Contributors: median of three, occasionally several dozen
+	for c, n := len(raid.Contributor), logNormalCount(g.rnd, 1.0, 0.9, 60); c < n; c++ {
+		raid.Contributor = append(raid.Contributor, models.Contributor{
+			ID:        fmt.Sprintf("%s0000-0003-%04d-%04d", orcidSchema, g.rnd.Intn(10000), g.rnd.Intn(10000)),
+			SchemaURI: orcidSchema,
+			Position: []models.ContributorPosition{{
+				SchemaURI: vocabulary + "contributor.position.schema/305",
+				ID:        vocabulary + "contributor.position.schema/309",
+				StartDate: start,
+			}},
+			Role: []models.IDSchema{{ID: "https://credit.niso.org/contributor-roles/investigation/", SchemaURI: "https://credit.niso.org/"}},
+		})
+	}
+
+	for s, n := len(raid.Subject), 1+geometricCount(g.rnd, 0.5, 8); s < n; s++ {
+		topic := topics[g.rnd.Intn(len(topics))]
+		raid.Subject = append(raid.Subject, models.Subject{
+			ID:        anzsrcSchema + topic.subject,
+			SchemaURI: anzsrcSchema,
+			Keyword:   []models.SubjectKeyword{{Text: topic.keyword, Language: raid.Title[0].Language}},
+		})
+	}
+
+	for o, n := 0, geometricCount(g.rnd, 0.35, 40); o < n; o++ {
+		raid.RelatedObject = append(raid.RelatedObject, models.RelatedObject{
+			ID:        fmt.Sprintf("https://doi.org/10.%d/synthetic.%d", 5000+g.rnd.Intn(5000), g.rnd.Int63()),
+			SchemaURI: "https://doi.org/",
+			Type:      &models.IDSchema{ID: vocabulary + "related-object.type.schema/" + []string{"250", "252", "258"}[g.rnd.Intn(3)], SchemaURI: vocabulary + "related-object.type.schema/329"},
+			Category:  []models.IDSchema{{ID: vocabulary + "related-object.category.id/" + []string{"190", "191", "192"}[g.rnd.Intn(3)], SchemaURI: vocabulary + "related-object.category.schema/385"}},
+		})
+	}
+
+	if len(g.known) > 0 {
+		for r, n := 0, geometricCount(g.rnd, 0.7, 5); r < n; r++ {
+			raid.RelatedRAiD = append(raid.RelatedRAiD, models.RelatedRAiD{
+				ID:   g.known[g.rnd.Intn(len(g.known))],
+				Type: &models.IDSchema{ID: vocabulary + "related-raid.type.schema/" + []string{"198", "201", "204"}[g.rnd.Intn(3)], SchemaURI: vocabulary + "related-raid.type.schema/367"},
+			})
+		}
+	}
+	return raid
+}
+
+// Remember records a minted identifier so that later documents can link to
+// it as a related RAiD
+func (g *Generator) Remember(id string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.known) < maxKnown {
+		g.known = append(g.known, id)
+	} else {
+		g.known[g.rnd.Intn(maxKnown)] = id
+	}
+}
+
+// Mutate applies a typical edit to raid in place: a retitle, a new
+// description, an added contributor or an added related object
+func (g *Generator) Mutate(raid *models.RAiD) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	switch g.rnd.Intn(4) {
+	case 0:
+		if len(raid.Title) > 0 {
+			raid.Title[0].Text = fmt.Sprintf("%s %s", topics[g.rnd.Intn(len(topics))].title, qualifiers[g.rnd.Intn(len(qualifiers))])
+		}
+	case 1:
+		raid.Description = append(raid.Description, models.Description{
+			Text: fmt.Sprintf("Progress update %d.", g.rnd.Intn(1000)),
+			Type: &models.IDSchema{ID: vocabulary + "description.type.schema/319", SchemaURI: vocabulary + "description.type.schema/320"},
+		})
+	case 2:
+		raid.Contributor = append(raid.Contributor, models.Contributor{
+			ID:        fmt.Sprintf("%s0000-0003-%04d-%04d", orcidSchema, g.rnd.Intn(10000), g.rnd.Intn(10000)),
+			SchemaURI: orcidSchema,
+			Role:      []models.IDSchema{{ID: "https://credit.niso.org/contributor-roles/software/", SchemaURI: "https://credit.niso.org/"}},
+		})
+	default:
+		raid.RelatedObject = append(raid.RelatedObject, models.RelatedObject{
+			ID:        fmt.Sprintf("https://doi.org/10.%d/synthetic.%d", 5000+g.rnd.Intn(5000), g.rnd.Int63()),
+			SchemaURI: "https://doi.org/",
+		})
+	}
+}
+
+// logNormalCount draws a count of at least 1 from a log-normal distribution
+func logNormalCount(rnd *rand.Rand, mu, sigma float64, limit int) int {
+	n := int(math.Round(math.Exp(mu + sigma*rnd.NormFloat64())))
+	return min(max(n, 1), limit)
+}
+
+// geometricCount draws the number of failures before the first success
+// with success probability p
+func geometricCount(rnd *rand.Rand, p float64, limit int) int {
+	n := 0
+	for n < limit && rnd.Float64() > p {
+		n++
+	}
+	return n
+}
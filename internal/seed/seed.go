@@ -0,0 +1,191 @@
+// Package seed fills a storage backend with demo data: a couple of service
+// points and a number of realistic sample RAiDs with varied titles, access
+// types, contributors, organisations and subjects. Generation is driven by a
+// seeded random source, so the same seed produces the same content.
+package seed
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// DefaultRAiDs is the number of RAiDs created when Options.RAiDs is zero
+const DefaultRAiDs = 25
+
+// maxMintAttempts bounds retries when a generated identifier already exists
+const maxMintAttempts = 3
+
+// Options controls seeding
+type Options struct {
+	// RAiDs is the number of RAiDs to create
+	RAiDs int
+	// Seed initialises the random source
+	Seed int64
+}
+
+// Summary reports what was created
+type Summary struct {
+	ServicePoints []int64  `json:"servicePoints"`
+	RAiDs         []string `json:"raids"`
+}
+
+const (
+	vocabulary   = "https://vocabulary.raid.org/"
+	rorSchema    = "https://ror.org/"
+	orcidSchema  = "https://orcid.org/"
+	languageURI  = "https://www.iso.org/standard/39534.html"
+	anzsrcSchema = "https://linked.data.gov.au/def/anzsrc-for/2020/"
+)
+
+var (
+	demoServicePoints = []models.ServicePoint{
+		{Name: "Demo University Research Office", IdentifierOwner: rorSchema + "038sjwq14", Prefix: "10.82841", GroupID: "demo-university", TechEmail: "tech@demo-university.example", AdminEmail: "research@demo-university.example", Enabled: true, AppWritesEnabled: true},
+		{Name: "Demo Institute Data Services", IdentifierOwner: rorSchema + "02stey378", Prefix: "10.82842", GroupID: "demo-institute", TechEmail: "tech@demo-institute.example", AdminEmail: "data@demo-institute.example", Enabled: true, AppWritesEnabled: true},
+	}
+
+	topics = []struct {
+		title, keyword, subject string
+	}{
+		{"Coastal erosion monitoring", "coastal erosion", "3709"},
+		{"Urban heat island mitigation", "urban climate", "3702"},
+		{"Soil microbiome diversity", "microbiome", "3107"},
+		{"Indigenous language revitalisation", "language revitalisation", "4704"},
+		{"Machine learning for crop yield", "agriculture", "4611"},
+		{"Groundwater contamination pathways", "hydrogeology", "3707"},
+		{"Antimicrobial resistance surveillance", "antimicrobial resistance", "3207"},
+		{"Historic shipwreck archaeology", "maritime archaeology", "4301"},
+		{"Wetland bird migration", "migration ecology", "3103"},
+		{"Quantum sensor calibration", "quantum sensing", "5108"},
+	}
+	qualifiers = []string{"in the southern hemisphere", "across regional communities", "using open data", "over two decades", "at national scale"}
+
+	organisations = []string{"038sjwq14", "02stey378", "0384j8v12", "01ej9dk98", "00rqy9422"}
+
+	accessTypes = []struct {
+		id, statement string
+	}{
+		{storage.AccessTypeOpen, ""},
+		{storage.AccessTypeOpen, ""},
+		{storage.AccessTypeEmbargoed, "Embargoed until the project's findings are published"},
+	}
+)
+
+// Run creates the demo service points and opts.RAiDs RAiDs in repo
+func Run(ctx context.Context, repo storage.Repository, opts Options) (*Summary, error) {
+	n := opts.RAiDs
+	if n == 0 {
+		n = DefaultRAiDs
+	}
+	rnd := rand.New(rand.NewSource(opts.Seed))
+	summary := &Summary{}
+
+	var sps []*models.ServicePoint
+	for _, tmpl := range demoServicePoints {
+		sp := tmpl
+		created, err := repo.CreateServicePoint(ctx, &sp)
+		if err != nil {
+			return summary, fmt.Errorf("create service point %q: %w", tmpl.Name, err)
+		}
+		sps = append(sps, created)
+		summary.ServicePoints = append(summary.ServicePoints, created.ID)
+	}
+
+	for i := 0; i < n; i++ {
+		raid, err := mint(ctx, repo, newRAiD(rnd, sps[i%len(sps)], i))
+		if err != nil {
+			return summary, fmt.Errorf("create RAiD %d: %w", i+1, err)
+		}
+		summary.RAiDs = append(summary.RAiDs, raid.Identifier.ID)
+	}
+	return summary, nil
+}
+
+// mint creates a RAiD, retrying with a fresh identifier if the generated one
+// is already taken
+func mint(ctx context.Context, repo storage.Repository, raid *models.RAiD) (*models.RAiD, error) {
+	for attempt := 1; ; attempt++ {
+		created, err := repo.CreateRAiD(ctx, raid)
+		if err != storage.ErrAlreadyExists || attempt == maxMintAttempts {
+			return created, err
+		}
+		raid.Identifier.ID = ""
+	}
+}
+
+// newRAiD generates the i-th sample RAiD, owned by sp
+func newRAiD(rnd *rand.Rand, sp *models.ServicePoint, i int) *models.RAiD {
+	topic := topics[rnd.Intn(len(topics))]
+	eng := &models.Language{ID: "eng", SchemaURI: languageURI}
+	start := time.Date(2018+rnd.Intn(7), time.Month(1+rnd.Intn(12)), 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(1+rnd.Intn(4), 0, 0)
+
+	raid := &models.RAiD{
+		Identifier: &models.Identifier{
+			SchemaURI:          "https://raid.org/",
+			RegistrationAgency: &models.RegistrationAgency{ID: sp.IdentifierOwner, SchemaURI: rorSchema},
+			Owner:              &models.Owner{ID: sp.IdentifierOwner, SchemaURI: rorSchema, ServicePoint: sp.ID},
+			License:            "Creative Commons CC-0",
+		},
+		Title: []models.Title{{
+			Text:      fmt.Sprintf("%s %s", topic.title, qualifiers[rnd.Intn(len(qualifiers))]),
+			Type:      &models.IDSchema{ID: vocabulary + "title.type.schema/5", SchemaURI: vocabulary + "title.type.schema/376"},
+			StartDate: start.Format("2006-01-02"),
+			Language:  eng,
+		}},
+		Date: &models.Date{StartDate: start.Format("2006-01-02"), EndDate: end.Format("2006-01-02")},
+		Description: []models.Description{{
+			Text:     fmt.Sprintf("Demo project %d studying %s.", i+1, topic.keyword),
+			Type:     &models.IDSchema{ID: vocabulary + "description.type.schema/318", SchemaURI: vocabulary + "description.type.schema/320"},
+			Language: eng,
+		}},
+		Subject: []models.Subject{{
+			ID:        anzsrcSchema + topic.subject,
+			SchemaURI: anzsrcSchema,
+			Keyword:   []models.SubjectKeyword{{Text: topic.keyword, Language: eng}},
+		}},
+	}
+
+	access := accessTypes[rnd.Intn(len(accessTypes))]
+	raid.Access = &models.Access{Type: &models.IDSchema{ID: access.id, SchemaURI: vocabulary + "access.type.schema/"}}
+	if access.statement != "" {
+		raid.Access.Statement = &models.AccessStatement{Text: access.statement, Language: eng}
+		raid.Access.EmbargoExpiry = end.Format("2006-01-02")
+	}
+
+	for c, n := 0, 1+rnd.Intn(3); c < n; c++ {
+		contributor := models.Contributor{
+			ID:        fmt.Sprintf("%s0000-0002-%04d-%04d", orcidSchema, rnd.Intn(10000), rnd.Intn(10000)),
+			SchemaURI: orcidSchema,
+			Position: []models.ContributorPosition{{
+				SchemaURI: vocabulary + "contributor.position.schema/305",
+				ID:        vocabulary + "contributor.position.schema/" + []string{"307", "308", "309"}[min(c, 2)],
+				StartDate: start.Format("2006-01-02"),
+			}},
+			Role: []models.IDSchema{{ID: "https://credit.niso.org/contributor-roles/investigation/", SchemaURI: "https://credit.niso.org/"}},
+		}
+		if c == 0 {
+			contributor.Leader, contributor.Contact = true, true
+		}
+		raid.Contributor = append(raid.Contributor, contributor)
+	}
+
+	lead := sp.IdentifierOwner
+	raid.Organisation = append(raid.Organisation, models.Organisation{
+		ID:        lead,
+		SchemaURI: rorSchema,
+		Role:      []models.OrganisationRole{{SchemaURI: vocabulary + "organisation.role.schema/359", ID: vocabulary + "organisation.role.schema/182", StartDate: start.Format("2006-01-02")}},
+	})
+	if partner := rorSchema + organisations[rnd.Intn(len(organisations))]; partner != lead {
+		raid.Organisation = append(raid.Organisation, models.Organisation{
+			ID:        partner,
+			SchemaURI: rorSchema,
+			Role:      []models.OrganisationRole{{SchemaURI: vocabulary + "organisation.role.schema/359", ID: vocabulary + "organisation.role.schema/183", StartDate: start.Format("2006-01-02")}},
+		})
+	}
+	return raid
+}
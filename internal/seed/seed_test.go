@@ -0,0 +1,101 @@
+package seed
+
+import (
+	"context"
+	"math/rand"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func TestRun(t *testing.T) {
+	repo, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+
+	summary, err := Run(ctx, repo, Options{RAiDs: 12, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.ServicePoints) != 2 || len(summary.RAiDs) != 12 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	raids, err := repo.ListRAiDs(ctx, &storage.RAiDFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(raids) != 12 {
+		t.Fatalf("expected 12 stored RAiDs, got %d", len(raids))
+	}
+	access := map[string]int{}
+	for _, raid := range raids {
+		access[raid.Access.Type.ID]++
+		if len(raid.Contributor) == 0 || !raid.Contributor[0].Leader || len(raid.Subject) == 0 {
+			t.Errorf("incomplete sample RAiD %s", raid.Identifier.ID)
+		}
+		sp := raid.Identifier.Owner.ServicePoint
+		if sp != summary.ServicePoints[0] && sp != summary.ServicePoints[1] {
+			t.Errorf("RAiD %s owned by unknown service point %d", raid.Identifier.ID, sp)
+		}
+	}
+	if access[storage.AccessTypeOpen] == 0 || access[storage.AccessTypeEmbargoed] == 0 {
+		t.Errorf("expected both open and embargoed RAiDs, got %v", access)
+	}
+}
+
+func TestNewRAiD_Deterministic(t *testing.T) {
+	sp := demoServicePoints[0]
+	sp.ID = 1
+	a := newRAiD(rand.New(rand.NewSource(7)), &sp, 0)
+	b := newRAiD(rand.New(rand.NewSource(7)), &sp, 0)
+	if a.Title[0].Text != b.Title[0].Text || a.Contributor[0].ID != b.Contributor[0].ID {
+		t.Error("expected the same seed to generate the same RAiD")
+	}
+}
+
+func TestGenerator(t *testing.T) {
+	sp := &models.ServicePoint{ID: 1}
+	g := NewGenerator(3)
+	g.Remember("https://raid.org/10.82841/known")
+
+	var contributors, related []int
+	for i := 0; i < 500; i++ {
+		raid := g.RAiD(sp)
+		if raid.Identifier.ID != "" || len(raid.Contributor) == 0 || len(raid.Subject) == 0 {
+			t.Fatalf("incomplete synthetic RAiD %d", i)
+		}
+		for _, r := range raid.RelatedRAiD {
+			if r.ID != "https://raid.org/10.82841/known" {
+				t.Errorf("unexpected related RAiD %q", r.ID)
+			}
+		}
+		contributors = append(contributors, len(raid.Contributor))
+		related = append(related, len(raid.RelatedObject))
+	}
+
+	// The distributions are skewed: a small median with a long tail
+	slices.Sort(contributors)
+	slices.Sort(related)
+	if median := contributors[250]; median < 2 || median > 4 {
+		t.Errorf("expected a median of 2-4 contributors, got %d", median)
+	}
+	if contributors[len(contributors)-1] < 10 {
+		t.Errorf("expected some RAiDs with many contributors, max %d", contributors[len(contributors)-1])
+	}
+	if related[0] != 0 || related[len(related)-1] < 5 {
+		t.Errorf("expected 0 to several related objects, got %d-%d", related[0], related[len(related)-1])
+	}
+
+	a, b := NewGenerator(9).RAiD(sp), NewGenerator(9).RAiD(sp)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected the same seed to generate the same RAiD")
+	}
+}
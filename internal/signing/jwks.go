@@ -0,0 +1,74 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// JWK is an Ed25519 public key as a JSON Web Key (RFC 8037)
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Thumbprint returns the JWK thumbprint (RFC 7638) of key
+func Thumbprint(key ed25519.PublicKey) string {
+	// The required members in lexicographic order, without white space
+	canonical := fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(key))
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// JWKS returns the key set publishing the signer's public key
+func (s *Signer) JWKS() JWKS {
+	return JWKS{Keys: []JWK{{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(s.Public()),
+		Kid: s.keyID,
+		Use: "sig",
+		Alg: "EdDSA",
+	}}}
+}
+
+// ServeJWKS handles GET /.well-known/jwks.json - the public key responses
+// and archives are signed with. No authentication is needed.
+func (s *Signer) ServeJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/jwk-set+json")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	json.NewEncoder(w).Encode(s.JWKS())
+}
+
+// PublicKeys returns the Ed25519 keys of set by key ID, for VerifyJWS and
+// VerifyResponse. Keys of other types are skipped.
+func (set JWKS) PublicKeys() (map[string]ed25519.PublicKey, error) {
+	keys := make(map[string]ed25519.PublicKey)
+	for _, k := range set.Keys {
+		if k.Kty != "OKP" || k.Crv != "Ed25519" {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key %q", k.Kid)
+		}
+		keys[k.Kid] = ed25519.PublicKey(x)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("key set holds no Ed25519 keys")
+	}
+	return keys, nil
+}
@@ -0,0 +1,318 @@
+// Package signing signs RAiD responses and backup archives with the
+// server's Ed25519 key, so that consumers holding the published public key
+// can verify their authenticity offline.
+//
+// Responses are signed in one of two formats: a detached JWS (RFC 7515
+// appendix F) of the body in the X-JWS-Signature header, or HTTP message
+// signatures (RFC 9421) over the status, content type and the
+// Content-Digest (RFC 9530) of the body. The public key is published as a
+// JSON Web Key Set at /.well-known/jwks.json.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response signature formats
+const (
+	// FormatJWS signs response bodies with a detached JWS
+	FormatJWS = "jws"
+	// FormatHTTP signs responses with HTTP message signatures
+	FormatHTTP = "http"
+)
+
+const (
+	// JWSHeader carries the detached JWS of a response body
+	JWSHeader = "X-JWS-Signature"
+	// JWKSPath is where the public key set is published
+	JWKSPath = "/.well-known/jwks.json"
+	// Label names the HTTP message signature of a response
+	Label = "raid"
+)
+
+// components are the parts of a response covered by its HTTP message
+// signature
+var components = []string{"@status", "content-type", "content-digest"}
+
+// ErrInvalidSignature is returned when a signature does not verify
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Signer signs with an Ed25519 private key
+type Signer struct {
+	key    ed25519.PrivateKey
+	keyID  string
+	format string
+}
+
+// New creates a signer for a PEM-encoded PKCS #8 Ed25519 private key, as
+// written by "openssl genpkey -algorithm ed25519". An empty keyID is set to
+// the key's JWK thumbprint (RFC 7638); an empty format means FormatJWS.
+func New(pemKey, keyID, format string) (*Signer, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("signing key is not PEM-encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is a %T, not an Ed25519 key", parsed)
+	}
+
+	switch format {
+	case "":
+		format = FormatJWS
+	case FormatJWS, FormatHTTP:
+	default:
+		return nil, fmt.Errorf("unknown signature format %q", format)
+	}
+
+	s := &Signer{key: key, keyID: keyID, format: format}
+	if s.keyID == "" {
+		s.keyID = Thumbprint(key.Public().(ed25519.PublicKey))
+	}
+	return s, nil
+}
+
+// KeyID returns the identifier of the signing key
+func (s *Signer) KeyID() string {
+	return s.keyID
+}
+
+// Public returns the public key signatures are verified with
+func (s *Signer) Public() ed25519.PublicKey {
+	return s.key.Public().(ed25519.PublicKey)
+}
+
+// jwsHeader is the protected header of signatures
+type jwsHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+}
+
+// Sign returns the detached JWS of payload: the compact serialization
+// with the payload left out, header..signature
+func (s *Signer) Sign(payload []byte) (string, error) {
+	header, err := json.Marshal(jwsHeader{Alg: "EdDSA", Kid: s.keyID})
+	if err != nil {
+		return "", err
+	}
+	protected := base64.RawURLEncoding.EncodeToString(header)
+	sig := ed25519.Sign(s.key, signingInput(protected, payload))
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyJWS checks the detached JWS jws of payload against keys, looked
+// up by the kid of the JWS, or the only key is used if it has none.
+func VerifyJWS(keys map[string]ed25519.PublicKey, payload []byte, jws string) error {
+	protected, sig, ok := strings.Cut(jws, "..")
+	if !ok {
+		return fmt.Errorf("%w: not a detached JWS", ErrInvalidSignature)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(protected)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	var header jwsHeader
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if header.Alg != "EdDSA" {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, header.Alg)
+	}
+	key, err := lookup(keys, header.Kid)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	if !ed25519.Verify(key, signingInput(protected, payload), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func signingInput(protected string, payload []byte) []byte {
+	return []byte(protected + "." + base64.RawURLEncoding.EncodeToString(payload))
+}
+
+func lookup(keys map[string]ed25519.PublicKey, kid string) (ed25519.PublicKey, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidSignature, kid)
+}
+
+// SignResponse sets the signature headers of a response with status,
+// whose headers are in header, for body
+func (s *Signer) SignResponse(header http.Header, status int, body []byte) error {
+	if s.format == FormatJWS {
+		jws, err := s.Sign(body)
+		if err != nil {
+			return err
+		}
+		header.Set(JWSHeader, jws)
+		return nil
+	}
+
+	header.Set("Content-Digest", ContentDigest(body))
+	params := signatureParams(time.Now().Unix(), s.keyID)
+	sig := ed25519.Sign(s.key, signatureBase(status, header, params))
+	header.Set("Signature-Input", Label+"="+params)
+	header.Set("Signature", Label+"=:"+base64.StdEncoding.EncodeToString(sig)+":")
+	return nil
+}
+
+// ContentDigest returns the SHA-256 Content-Digest field value of body
+func ContentDigest(body []byte) string {
+	sum := sha256.Sum256(body)
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum[:]) + ":"
+}
+
+// signatureParams returns the serialized signature parameters, which
+// are also the value of Signature-Input
+func signatureParams(created int64, keyID string) string {
+	quoted := make([]string, len(components))
+	for i, c := range components {
+		quoted[i] = strconv.Quote(c)
+	}
+	return fmt.Sprintf("(%s);created=%d;keyid=%s;alg=\"ed25519\"", strings.Join(quoted, " "), created, strconv.Quote(keyID))
+}
+
+// signatureBase returns the signature base (RFC 9421 section 2.5) of the
+// components for a response
+func signatureBase(status int, header http.Header, params string) []byte {
+	var b bytes.Buffer
+	for _, c := range components {
+		value := strconv.Itoa(status)
+		if c != "@status" {
+			value = strings.Join(header.Values(c), ", ")
+		}
+		fmt.Fprintf(&b, "%q: %s\n", c, value)
+	}
+	fmt.Fprintf(&b, "%q: %s", "@signature-params", params)
+	return b.Bytes()
+}
+
+// VerifyResponse checks the HTTP message signature of a response with
+// status, headers header and body against keys, looked up by keyid. Only
+// signatures covering the components this package signs are understood.
+func VerifyResponse(keys map[string]ed25519.PublicKey, status int, header http.Header, body []byte) error {
+	if header.Get("Content-Digest") != ContentDigest(body) {
+		return fmt.Errorf("%w: Content-Digest does not match the body", ErrInvalidSignature)
+	}
+	params, ok := strings.CutPrefix(header.Get("Signature-Input"), Label+"=")
+	if !ok {
+		return fmt.Errorf("%w: no %s signature", ErrInvalidSignature, Label)
+	}
+	encoded, ok := strings.CutPrefix(header.Get("Signature"), Label+"=:")
+	if !ok || !strings.HasSuffix(encoded, ":") {
+		return fmt.Errorf("%w: no %s signature", ErrInvalidSignature, Label)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSuffix(encoded, ":"))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	var created int64
+	var keyID string
+	list, rest, _ := strings.Cut(params, ";")
+	for _, param := range strings.Split(rest, ";") {
+		name, value, _ := strings.Cut(param, "=")
+		switch name {
+		case "created":
+			created, _ = strconv.ParseInt(value, 10, 64)
+		case "keyid":
+			keyID, _ = strconv.Unquote(value)
+		}
+	}
+	if params != signatureParams(created, keyID) {
+		return fmt.Errorf("%w: unsupported signature parameters %s", ErrInvalidSignature, list)
+	}
+	key, err := lookup(keys, keyID)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, signatureBase(status, header, params), signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Middleware signs the successful JSON responses of the handlers it
+// wraps, which are buffered to be signed. A nil signer signs nothing.
+func (s *Signer) Middleware(next http.Handler) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &bufferedResponse{header: w.Header()}
+		next.ServeHTTP(rec, r)
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		body := rec.body.Bytes()
+		if status >= 200 && status < 300 && len(body) > 0 && isJSON(w.Header().Get("Content-Type")) {
+			if err := s.SignResponse(w.Header(), status, body); err != nil {
+				http.Error(w, "Failed to sign response", http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(status)
+		w.Write(body)
+	})
+}
+
+// isJSON reports whether contentType is JSON, including the +json types
+func isJSON(contentType string) bool {
+	media, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (media == "application/json" || strings.HasSuffix(media, "+json"))
+}
+
+// bufferedResponse holds a response until it is signed
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
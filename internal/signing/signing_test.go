@@ -0,0 +1,124 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestSigner(t *testing.T, format string) *Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), "", format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// published returns the keys of s as a consumer reads them from the JWKS
+func published(t *testing.T, s *Signer) map[string]ed25519.PublicKey {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.ServeJWKS(rec, httptest.NewRequest(http.MethodGet, JWKSPath, nil))
+	var set JWKS
+	if err := json.NewDecoder(rec.Body).Decode(&set); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := set.PublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := keys[s.KeyID()]; !ok {
+		t.Fatalf("expected key %q to be published, got %v", s.KeyID(), set)
+	}
+	return keys
+}
+
+func TestJWS(t *testing.T) {
+	s := newTestSigner(t, "")
+	keys := published(t, s)
+	payload := []byte(`{"identifier":{"id":"https://raid.org/10.1/a"}}`)
+
+	jws, err := s.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyJWS(keys, payload, jws); err != nil {
+		t.Errorf("expected the signature to verify, got %v", err)
+	}
+	if err := VerifyJWS(keys, []byte(`{"identifier":{"id":"https://raid.org/10.1/b"}}`), jws); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for another payload, got %v", err)
+	}
+	if err := VerifyJWS(published(t, newTestSigner(t, "")), payload, jws); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected ErrInvalidSignature for another key, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	body := `{"identifier":{"id":"https://raid.org/10.1/a"}}`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(body))
+	}
+
+	for _, format := range []string{FormatJWS, FormatHTTP} {
+		t.Run(format, func(t *testing.T) {
+			s := newTestSigner(t, format)
+			keys := published(t, s)
+			h := s.Middleware(http.HandlerFunc(handler))
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/raid/", nil))
+			if rec.Code != http.StatusCreated || rec.Body.String() != body {
+				t.Fatalf("expected the response to pass through, got %d %q", rec.Code, rec.Body)
+			}
+			verify := func(body []byte) error {
+				if format == FormatJWS {
+					return VerifyJWS(keys, body, rec.Header().Get(JWSHeader))
+				}
+				return VerifyResponse(keys, rec.Code, rec.Header(), body)
+			}
+			if err := verify(rec.Body.Bytes()); err != nil {
+				t.Errorf("expected the response to verify, got %v", err)
+			}
+			if err := verify([]byte(`{}`)); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("expected ErrInvalidSignature for another body, got %v", err)
+			}
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+			if rec.Header().Get(JWSHeader) != "" || rec.Header().Get("Signature") != "" {
+				t.Errorf("expected errors to be left unsigned, got %v", rec.Header())
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New("not a key", "", ""); err == nil {
+		t.Error("expected an error for a key that is not PEM-encoded")
+	}
+	s := newTestSigner(t, "")
+	if s.KeyID() != Thumbprint(s.Public()) {
+		t.Errorf("expected the key ID to default to the thumbprint, got %q", s.KeyID())
+	}
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// AccessLogSchema identifies the access log entry format. It is written with
+// every entry so that consumers can detect format changes.
+const AccessLogSchema = "go-raid-access/1"
+
+// AccessLogEntry is a structured record of one API request
+type AccessLogEntry struct {
+	Schema    string    `json:"schema"`
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"requestId,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	// Route is the matched route pattern, e.g. /raid/{prefix}/{suffix}/
+	Route  string `json:"route,omitempty"`
+	Status int    `json:"status"`
+	Bytes  int64  `json:"bytes"`
+	// LatencyMS is the handler time in milliseconds
+	LatencyMS float64 `json:"latencyMs"`
+	// Actor is the authenticated user ID, if any
+	Actor        string `json:"actor,omitempty"`
+	ServicePoint int64  `json:"servicePoint,omitempty"`
+	// RAiD is the prefix/suffix of the RAiD addressed by the request
+	RAiD       string `json:"raid,omitempty"`
+	Version    int    `json:"version,omitempty"`
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	UserAgent  string `json:"userAgent,omitempty"`
+}
+
+// AccessLogStore is implemented by backends that can persist access logs
+type AccessLogStore interface {
+	// WriteAccessLog appends entries to the access log
+	WriteAccessLog(ctx context.Context, entries []AccessLogEntry) error
+}
+
+// AccessLogExporter is implemented by access log stores that can read their
+// entries back, to back them up
+type AccessLogExporter interface {
+	// ExportAccessLog calls fn for every stored entry, oldest first.
+	// Iteration stops at the first error from fn.
+	ExportAccessLog(ctx context.Context, fn func(*AccessLogEntry) error) error
+}
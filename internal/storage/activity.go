@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Activity intervals
+const (
+	IntervalDay   = "day"
+	IntervalWeek  = "week"
+	IntervalMonth = "month"
+	IntervalYear  = "year"
+)
+
+// ActivityCount counts the RAiDs a service point minted, and the updates it
+// made, in one interval. ServicePoint 0 collects RAiDs without one.
+type ActivityCount struct {
+	// Period is the UTC date the interval starts on, e.g. 2024-03-01 for
+	// March 2024; weeks start on Monday
+	Period       string `json:"period"`
+	ServicePoint int64  `json:"servicePoint"`
+	Minted       int64  `json:"minted"`
+	Updated      int64  `json:"updated"`
+}
+
+// ActivityProvider is implemented by backends that can aggregate minting
+// activity without reading every document, e.g. with SQL aggregates
+type ActivityProvider interface {
+	// CountActivity returns the activity per interval and service point,
+	// ordered by period and service point, leaving out empty intervals
+	CountActivity(ctx context.Context, interval string) ([]ActivityCount, error)
+}
+
+// ValidateInterval checks an activity interval
+func ValidateInterval(interval string) error {
+	switch interval {
+	case IntervalDay, IntervalWeek, IntervalMonth, IntervalYear:
+		return nil
+	}
+	return fmt.Errorf("unknown interval %q (want %s, %s, %s or %s)", interval, IntervalDay, IntervalWeek, IntervalMonth, IntervalYear)
+}
+
+// PeriodStart returns the start of the interval containing t, in UTC
+func PeriodStart(t time.Time, interval string) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch interval {
+	case IntervalWeek:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case IntervalMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case IntervalYear:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return day
+}
+
+// CountActivity aggregates the minting activity in repo per interval,
+// natively where supported and otherwise by scanning an export
+func CountActivity(ctx context.Context, repo Repository, interval string) ([]ActivityCount, error) {
+	if ap, ok := repo.(ActivityProvider); ok {
+		return ap.CountActivity(ctx, interval)
+	}
+	return CountActivityWhere(ctx, repo, interval, func(*RAiDRecord) bool { return true })
+}
+
+// CountActivityWhere aggregates the minting activity of the RAiDs in repo
+// for which keep returns true by scanning an export, deleted RAiDs
+// included. Each version counts towards the service point owning it then.
+func CountActivityWhere(ctx context.Context, repo Repository, interval string, keep func(*RAiDRecord) bool) ([]ActivityCount, error) {
+	snap, ok := repo.(Snapshotter)
+	if !ok {
+		return nil, ErrCountUnsupported
+	}
+
+	type key struct {
+		period       string
+		servicePoint int64
+	}
+	counts := make(map[key]*ActivityCount)
+	err := snap.ExportRAiDs(ctx, func(record *RAiDRecord) error {
+		if !keep(record) {
+			return nil
+		}
+		for i, raid := range record.Versions {
+			if raid.Metadata == nil {
+				continue
+			}
+			at := raid.Metadata.Updated
+			if i == 0 {
+				at = raid.Metadata.Created
+			}
+			if at.IsZero() {
+				continue
+			}
+			k := key{PeriodStart(at, interval).Format("2006-01-02"), 0}
+			if raid.Identifier != nil && raid.Identifier.Owner != nil {
+				k.servicePoint = raid.Identifier.Owner.ServicePoint
+			}
+			c, ok := counts[k]
+			if !ok {
+				c = &ActivityCount{Period: k.period, ServicePoint: k.servicePoint}
+				counts[k] = c
+			}
+			if i == 0 {
+				c.Minted++
+			} else {
+				c.Updated++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]ActivityCount, 0, len(counts))
+	for _, c := range counts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Period != out[j].Period {
+			return out[i].Period < out[j].Period
+		}
+		return out[i].ServicePoint < out[j].ServicePoint
+	})
+	return out, nil
+}
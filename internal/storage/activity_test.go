@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeriodStart(t *testing.T) {
+	// A Wednesday, late in the day east of UTC
+	at := time.Date(2024, 3, 13, 23, 30, 0, 0, time.FixedZone("AEDT", 11*60*60))
+	for interval, want := range map[string]string{
+		IntervalDay:   "2024-03-13",
+		IntervalWeek:  "2024-03-11",
+		IntervalMonth: "2024-03-01",
+		IntervalYear:  "2024-01-01",
+	} {
+		if got := PeriodStart(at, interval).Format("2006-01-02"); got != want {
+			t.Errorf("%s: expected %s, got %s", interval, want, got)
+		}
+	}
+	if got := PeriodStart(time.Date(2024, 3, 17, 12, 0, 0, 0, time.UTC), IntervalWeek).Format("2006-01-02"); got != "2024-03-11" {
+		t.Errorf("expected Sunday to belong to the week starting Monday, got %s", got)
+	}
+	if err := ValidateInterval("hour"); err == nil {
+		t.Error("expected an unknown interval to be rejected")
+	}
+}
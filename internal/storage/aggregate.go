@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrAggregateUnsupported is returned by Aggregator.AggregateRAiDs for
+// filters the backend cannot aggregate natively; the RAiDs are then counted
+// as they are listed
+var ErrAggregateUnsupported = errors.New("storage backend cannot aggregate this filter")
+
+// Aggregator is implemented by backends that can count RAiDs by group
+// natively, e.g. with SQL GROUP BY
+type Aggregator interface {
+	// AggregateRAiDs counts the current RAiDs matching filter, ignoring
+	// its page, by the values of groupBy, one of the models.GroupBy
+	// groupings. A RAiD counts once in each group it has a value of.
+	AggregateRAiDs(ctx context.Context, filter *RAiDFilter, groupBy string) (map[string]int64, error)
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// BlobStore is implemented by stores that can hold the content of
+// attachments, addressed by its hex-encoded SHA-256 hash
+type BlobStore interface {
+	// PutBlob stores data under hash; storing content already held does
+	// nothing
+	PutBlob(ctx context.Context, hash string, data []byte) error
+
+	// GetBlob retrieves content by hash
+	GetBlob(ctx context.Context, hash string) ([]byte, error)
+}
+
+// AttachmentStore is implemented by backends that can hold the documents
+// attached to RAiDs. Only the attachments are kept; their content is held
+// by a BlobStore.
+type AttachmentStore interface {
+	// SaveAttachment stores attachment, replacing the attachment of the
+	// same content to the same RAiD
+	SaveAttachment(ctx context.Context, attachment *models.Attachment) error
+
+	// GetAttachment retrieves the attachment of the content hash to a RAiD
+	GetAttachment(ctx context.Context, prefix, suffix, hash string) (*models.Attachment, error)
+
+	// ListAttachments retrieves the attachments of a RAiD, ordered by hash
+	ListAttachments(ctx context.Context, prefix, suffix string) ([]*models.Attachment, error)
+}
@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// AuditImporter is implemented by backends that can store the records of
+// purges and redactions made elsewhere, read with ListPurges and
+// ListRedactions, to restore and migrate them. Importing a record already
+// stored replaces it.
+type AuditImporter interface {
+	// ImportPurge records purge as given, removing nothing
+	ImportPurge(ctx context.Context, purge *models.Purge) error
+
+	// ImportRedaction records redaction as given, rewriting no version
+	ImportRedaction(ctx context.Context, redaction *models.Redaction) error
+}
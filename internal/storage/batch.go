@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// IdentifierRef names a RAiD by its prefix and suffix
+type IdentifierRef struct {
+	Prefix string
+	Suffix string
+}
+
+// GetEach implements GetRAiDs with one GetRAiD per ref, for repositories
+// without a cheaper way to read several RAiDs
+func GetEach(ctx context.Context, get func(ctx context.Context, prefix, suffix string) (*models.RAiD, error), refs []IdentifierRef) ([]*models.RAiD, error) {
+	raids := make([]*models.RAiD, len(refs))
+	for i, ref := range refs {
+		raid, err := get(ctx, ref.Prefix, ref.Suffix)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		raids[i] = raid
+	}
+	return raids, nil
+}
@@ -0,0 +1,192 @@
+package storage_test
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/seed"
+	"github.com/leifj/go-raid/internal/storage"
+
+	_ "github.com/leifj/go-raid/internal/storage/cockroach"
+	_ "github.com/leifj/go-raid/internal/storage/fdb"
+	_ "github.com/leifj/go-raid/internal/storage/file"
+)
+
+// Storage benchmarks run against every backend in -storage.backends with a
+// dataset of each size in -storage.sizes:
+//
+//	go test ./internal/storage -run '^$' -bench . -storage.backends file,cockroach -storage.sizes 1000,10000
+//
+// Database backends are configured with the usual STORAGE_* environment
+// variables and skipped when they are not compiled in or not reachable.
+// Each dataset uses a fresh prefix, so benchmarks can share a database with
+// other data.
+var (
+	benchBackends = flag.String("storage.backends", "file", "comma-separated storage backends to benchmark")
+	benchSizes    = flag.String("storage.sizes", "100,1000", "comma-separated dataset sizes to benchmark")
+)
+
+// benchPageSize is the page size of the ListRAiDs benchmark
+const benchPageSize = 100
+
+// dataset is a backend pre-filled with RAiDs
+type dataset struct {
+	repo     storage.Repository
+	prefix   string
+	suffixes []string
+	cleanup  func()
+}
+
+var datasets = map[string]*dataset{}
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	code := m.Run()
+	for _, ds := range datasets {
+		ds.repo.Close()
+		ds.cleanup()
+	}
+	os.Exit(code)
+}
+
+// forEachDataset runs fn as a sub-benchmark for every configured backend
+// and dataset size. Datasets are built once and shared between benchmarks.
+func forEachDataset(b *testing.B, fn func(b *testing.B, ds *dataset)) {
+	for _, backend := range strings.Split(*benchBackends, ",") {
+		for _, s := range strings.Split(*benchSizes, ",") {
+			size, err := strconv.Atoi(s)
+			if err != nil || size < 1 {
+				b.Fatalf("invalid dataset size %q", s)
+			}
+			b.Run(fmt.Sprintf("%s/n=%d", backend, size), func(b *testing.B) {
+				fn(b, openDataset(b, storage.StorageType(backend), size))
+			})
+		}
+	}
+}
+
+func openDataset(b *testing.B, backend storage.StorageType, size int) *dataset {
+	b.Helper()
+	key := fmt.Sprintf("%s/%d", backend, size)
+	if ds, ok := datasets[key]; ok {
+		return ds
+	}
+
+	cfg, err := config.LoadFile("")
+	if err != nil {
+		b.Fatal(err)
+	}
+	cfg.Storage.Type = backend
+	cleanup := func() {}
+	if backend == storage.StorageTypeFile || backend == storage.StorageTypeFileGit {
+		dir, err := os.MkdirTemp("", "raid-bench-")
+		if err != nil {
+			b.Fatal(err)
+		}
+		cfg.Storage.File.DataDir = dir
+		cfg.Storage.File.Lock = "none"
+		cleanup = func() { os.RemoveAll(dir) }
+	}
+	repo, err := storage.NewRepository(&cfg.Storage)
+	if err != nil {
+		cleanup()
+		b.Skipf("%s backend unavailable: %v", backend, err)
+	}
+	if err := repo.HealthCheck(context.Background()); err != nil {
+		repo.Close()
+		cleanup()
+		b.Skipf("%s backend unavailable: %v", backend, err)
+	}
+
+	ds := &dataset{repo: repo, prefix: fmt.Sprintf("10.%d", time.Now().UnixNano()), cleanup: cleanup}
+	gen := seed.NewGenerator(int64(size))
+	for i := 0; i < size; i++ {
+		if _, err := ds.create(gen); err != nil {
+			b.Fatalf("populate %s: %v", key, err)
+		}
+	}
+	datasets[key] = ds
+	return ds
+}
+
+// create stores a new synthetic RAiD in the dataset's prefix
+func (ds *dataset) create(gen *seed.Generator) (*models.RAiD, error) {
+	suffix := strconv.Itoa(len(ds.suffixes))
+	raid := gen.RAiD(&models.ServicePoint{})
+	raid.Identifier.ID = fmt.Sprintf("https://raid.org/%s/%s", ds.prefix, suffix)
+	created, err := ds.repo.CreateRAiD(context.Background(), raid)
+	if err == nil {
+		ds.suffixes = append(ds.suffixes, suffix)
+	}
+	return created, err
+}
+
+func BenchmarkCreateRAiD(b *testing.B) {
+	forEachDataset(b, func(b *testing.B, ds *dataset) {
+		gen := seed.NewGenerator(1)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := ds.create(gen); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkGetRAiD(b *testing.B) {
+	forEachDataset(b, func(b *testing.B, ds *dataset) {
+		ctx := context.Background()
+		rnd := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := ds.repo.GetRAiD(ctx, ds.prefix, ds.suffixes[rnd.Intn(len(ds.suffixes))]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkListRAiDs(b *testing.B) {
+	forEachDataset(b, func(b *testing.B, ds *dataset) {
+		ctx := context.Background()
+		rnd := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			filter := &storage.RAiDFilter{Limit: benchPageSize, Offset: rnd.Intn(len(ds.suffixes))}
+			if _, err := ds.repo.ListRAiDs(ctx, filter); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkUpdateRAiD(b *testing.B) {
+	forEachDataset(b, func(b *testing.B, ds *dataset) {
+		ctx := context.Background()
+		gen := seed.NewGenerator(1)
+		rnd := rand.New(rand.NewSource(1))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			suffix := ds.suffixes[rnd.Intn(len(ds.suffixes))]
+			b.StopTimer()
+			raid, err := ds.repo.GetRAiD(ctx, ds.prefix, suffix)
+			if err != nil {
+				b.Fatal(err)
+			}
+			gen.Mutate(raid)
+			b.StartTimer()
+			if _, err := ds.repo.UpdateRAiD(ctx, ds.prefix, suffix, raid); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ChainHash returns the hash chaining raid, a version as stored, to prev,
+// the chain hash of the version before it, or empty for the first
+// version and versions following ones written before versions were
+// chained. Unlike ContentHash it covers the whole version, number and
+// timestamps included, so rewriting any stored version, or the chain hash
+// of any version before it, changes the chain hash of every later one.
+func ChainHash(prev string, raid *models.RAiD) (string, error) {
+	data, err := json.Marshal(raid)
+	if err != nil {
+		return "", err
+	}
+	// Numbers are kept as written: timestamps carry more digits than a
+	// float64
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return "", err
+	}
+	// Listings mark deleted RAiDs, and when, which is never stored
+	if metadata, ok := doc["metadata"].(map[string]any); ok {
+		delete(metadata, "deleted")
+		delete(metadata, "deletedAt")
+	}
+	// Objects are marshalled with sorted keys, so equal versions give
+	// equal bytes
+	if data, err = json.Marshal(doc); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write([]byte{0})
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// RecordHistory returns the changes made by each version in history, which
+// may be in any order, oldest first and chained from the first version,
+// for storing an imported RAiD
+func RecordHistory(history []*models.RAiD) ([]VersionChange, error) {
+	changes, err := DiffHistory(history)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*models.RAiD, len(history))
+	for _, raid := range history {
+		if _, ok := byVersion[versionOf(raid)]; !ok {
+			byVersion[versionOf(raid)] = raid
+		}
+	}
+	var chain string
+	for i := range changes {
+		if chain, err = ChainHash(chain, byVersion[changes[i].Version]); err != nil {
+			return nil, err
+		}
+		changes[i].Chain = chain
+	}
+	return changes, nil
+}
+
+// Issue kinds reported by CheckChain
+const (
+	// IssueChainBroken is a stored version whose chain hash does not match
+	// its content and the chain hash of the version before it
+	IssueChainBroken = "chain-broken"
+	// IssueChainMissing is a version without a chain hash following one
+	// with a chain hash
+	IssueChainMissing = "chain-missing"
+)
+
+// CheckChain recomputes the chain hash of each of versions, the stored
+// versions of a RAiD, and reports those not matching the chain hash stored
+// in changes, its stored changes. Each version is checked against the
+// stored chain hash of the one before it, so only rewritten versions are
+// reported, and chained versions missing from versions are reported too.
+// Versions written before versions were chained have no chain hash and
+// are skipped; one without a chain hash following a chained version is
+// reported.
+func CheckChain(location, raid string, versions []*models.RAiD, changes []VersionChange) []VerifyIssue {
+	latest := 0
+	chains := make(map[int]string, len(changes))
+	for _, change := range changes {
+		chains[change.Version] = change.Chain
+		latest = max(latest, change.Version)
+	}
+	stored := make(map[int]*models.RAiD, len(versions))
+	for _, version := range versions {
+		stored[versionOf(version)] = version
+		latest = max(latest, versionOf(version))
+	}
+
+	var issues []VerifyIssue
+	add := func(kind string, format string, args ...any) {
+		issues = append(issues, VerifyIssue{Kind: kind, Location: location, RAiD: raid, Message: fmt.Sprintf(format, args...)})
+	}
+	chained := false
+	for v := 1; v <= latest; v++ {
+		chain := chains[v]
+		if chain == "" {
+			if chained {
+				add(IssueChainMissing, "version %d has no chain hash, though an earlier version has", v)
+			}
+			continue
+		}
+		chained = true
+		version, ok := stored[v]
+		if !ok {
+			add(IssueChainBroken, "chained version %d is missing", v)
+			continue
+		}
+		want, err := ChainHash(chains[v-1], version)
+		if err != nil {
+			add(IssueUnparseable, "version %d: %v", v, err)
+		} else if want != chain {
+			add(IssueChainBroken, "version %d does not match its chain hash: it or the chain hash before it was changed after it was written", v)
+		}
+	}
+	return issues
+}
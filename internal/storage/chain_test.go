@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestCheckChain(t *testing.T) {
+	versions := make([]*models.RAiD, 4)
+	for i := range versions {
+		versions[i] = &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Version: i + 1},
+			Title:      []models.Title{{Text: "Title"}},
+		}
+	}
+	// Versions 1 and 2 were written before versions were chained
+	changes := []VersionChange{{Version: 1}, {Version: 2}}
+	var chain string
+	for _, v := range versions[2:] {
+		var err error
+		if chain, err = ChainHash(chain, v); err != nil {
+			t.Fatal(err)
+		}
+		changes = append(changes, VersionChange{Version: v.Identifier.Version, Chain: chain})
+	}
+	if issues := CheckChain("a", "10.99999/a", versions, changes); len(issues) != 0 {
+		t.Fatalf("chain from version 3 on reported: %+v", issues)
+	}
+
+	versions[2].Title[0].Text = "Rewritten"
+	issues := CheckChain("a", "10.99999/a", versions, changes)
+	if len(issues) != 1 || issues[0].Kind != IssueChainBroken {
+		t.Errorf("rewritten version 3 reported as %+v", issues)
+	}
+
+	changes[3].Chain = ""
+	issues = CheckChain("a", "10.99999/a", versions[:2], changes)
+	if len(issues) != 2 || issues[0].Kind != IssueChainBroken || issues[1].Kind != IssueChainMissing {
+		t.Errorf("missing version 3 and chain hash of version 4 reported as %+v", issues)
+	}
+}
+
+func TestChainHash_Deletion(t *testing.T) {
+	raid := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Version: 2},
+		Metadata:   &models.Metadata{Created: time.Unix(1700000000, 0), Updated: time.Unix(1700000100, 0)},
+	}
+	want, err := ChainHash("prev", raid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Deletion marks and times are added when versions are read
+	raid.Metadata.Deleted = true
+	raid.Metadata.DeletedAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if got, err := ChainHash("prev", raid); err != nil || got != want {
+		t.Errorf("expected a deleted version to hash as stored, got %s, want %s (%v)", got, want, err)
+	}
+}
@@ -0,0 +1,242 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/leifj/go-raid/internal/jsonpatch"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// VersionChange is the change a version made to the one before it, as a
+// JSON Patch (RFC 6902). Backends compute it when a version is written, so
+// the change feed is read without diffing documents.
+type VersionChange struct {
+	Version   int                   `json:"version"`
+	Timestamp time.Time             `json:"timestamp"`
+	Patch     []jsonpatch.Operation `json:"patch"`
+	// Hash is the ContentHash of the version, compared by updates to skip
+	// versions repeating it
+	Hash string `json:"hash,omitempty"`
+	// Chain is the ChainHash of the version, linking it to the versions
+	// before it so that changes to stored history are detected
+	Chain string `json:"chain,omitempty"`
+	// Actor is the authenticated user who wrote the version, if known
+	Actor string `json:"actor,omitempty"`
+}
+
+// HistoryPage selects a page of the changes of a RAiD, oldest first
+type HistoryPage struct {
+	// After skips the changes of versions up to and including After
+	After int
+	// Limit bounds the number of changes returned; 0 returns all
+	Limit int
+	// Summary leaves out the patches
+	Summary bool
+}
+
+// PageChanges cuts the page selected by page, which may be nil, out of
+// changes, oldest first
+func PageChanges(changes []VersionChange, page *HistoryPage) []VersionChange {
+	if page == nil {
+		return changes
+	}
+	start := sort.Search(len(changes), func(i int) bool { return changes[i].Version > page.After })
+	changes = changes[start:]
+	if page.Limit > 0 && page.Limit < len(changes) {
+		changes = changes[:page.Limit]
+	}
+	if page.Summary {
+		summary := make([]VersionChange, len(changes))
+		for i, change := range changes {
+			change.Patch = nil
+			summary[i] = change
+		}
+		changes = summary
+	}
+	return changes
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the authenticated user writing
+// RAiDs, recorded with the versions written
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the user carried by ctx, if any
+func Actor(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// DiffVersion returns the change cur makes to prev, the version before it,
+// or to an empty document when prev is nil
+func DiffVersion(prev, cur *models.RAiD) (*VersionChange, error) {
+	from := []byte("{}")
+	if prev != nil {
+		var err error
+		if from, err = json.Marshal(prev); err != nil {
+			return nil, err
+		}
+	}
+	to, err := json.Marshal(cur)
+	if err != nil {
+		return nil, err
+	}
+	patch, err := jsonpatch.Diff(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := ContentHash(cur)
+	if err != nil {
+		return nil, err
+	}
+
+	change := &VersionChange{Patch: patch, Hash: hash}
+	if cur.Identifier != nil {
+		change.Version = cur.Identifier.Version
+	}
+	if cur.Metadata != nil {
+		change.Timestamp = cur.Metadata.Updated
+		if change.Timestamp.IsZero() {
+			change.Timestamp = cur.Metadata.Created
+		}
+	}
+	return change, nil
+}
+
+// RecordVersion returns the change cur, a version being written by the
+// actor carried by ctx, makes to prev, chained to chain, the stored chain
+// hash of prev
+func RecordVersion(ctx context.Context, prev, cur *models.RAiD, chain string) (*VersionChange, error) {
+	change, err := DiffVersion(prev, cur)
+	if err != nil {
+		return nil, err
+	}
+	change.Actor = Actor(ctx)
+	if change.Chain, err = ChainHash(chain, cur); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// DiffHistory returns the changes made by each version in history, which
+// may be in any order, oldest first. It serves backends without stored
+// changes and versions written before changes were stored.
+func DiffHistory(history []*models.RAiD) ([]VersionChange, error) {
+	versions := make([]*models.RAiD, 0, len(history))
+	seen := make(map[int]bool, len(history))
+	for _, raid := range history {
+		if v := versionOf(raid); !seen[v] {
+			seen[v] = true
+			versions = append(versions, raid)
+		}
+	}
+	sort.SliceStable(versions, func(i, j int) bool { return versionOf(versions[i]) < versionOf(versions[j]) })
+
+	changes := make([]VersionChange, 0, len(versions))
+	var prev *models.RAiD
+	for _, raid := range versions {
+		change, err := DiffVersion(prev, raid)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, *change)
+		prev = raid
+	}
+	return changes, nil
+}
+
+// RecordChanges returns the changes made by each version of record, oldest
+// first, chained and with the actors the record carries, for storing an
+// imported RAiD
+func RecordChanges(record *RAiDRecord) ([]VersionChange, error) {
+	changes, err := RecordHistory(record.Versions)
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		changes[i].Actor = record.Actors[changes[i].Version]
+	}
+	return changes, nil
+}
+
+// ChangeActors returns the actors of changes keyed by version, or nil if
+// none is known, for exporting a RAiD
+func ChangeActors(changes []VersionChange) map[int]string {
+	var actors map[int]string
+	for _, change := range changes {
+		if change.Actor == "" {
+			continue
+		}
+		if actors == nil {
+			actors = make(map[int]string)
+		}
+		actors[change.Version] = change.Actor
+	}
+	return actors
+}
+
+// DiffRecord returns the change each version of record made, keyed by
+// version, chained and with its actor, for storing an imported RAiD
+func DiffRecord(record *RAiDRecord) (map[int]*VersionChange, error) {
+	changes, err := RecordChanges(record)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*VersionChange, len(changes))
+	for i := range changes {
+		byVersion[changes[i].Version] = &changes[i]
+	}
+	return byVersion, nil
+}
+
+// ChangesComplete reports whether changes, oldest first, cover every
+// version from the first on. RAiDs written before changes were stored
+// lack the changes of their early versions and are diffed from history.
+func ChangesComplete(changes []VersionChange) bool {
+	if len(changes) == 0 {
+		return false
+	}
+	for i, change := range changes {
+		if change.Version != i+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// PageComplete reports whether changes, read for page, which may be nil,
+// hold the change of every version after page.After up to latest, the
+// newest version, or the page limit. Pages of RAiDs written before changes
+// were stored may miss some and are cut from the diffed history instead.
+func PageComplete(changes []VersionChange, page *HistoryPage, latest int) bool {
+	after, want := 0, latest
+	if page != nil {
+		after, want = page.After, latest-page.After
+		if page.Limit > 0 {
+			want = min(want, page.Limit)
+		}
+	}
+	if len(changes) != max(want, 0) {
+		return false
+	}
+	for i, change := range changes {
+		if change.Version != after+1+i {
+			return false
+		}
+	}
+	return true
+}
+
+func versionOf(raid *models.RAiD) int {
+	if raid.Identifier == nil {
+		return 0
+	}
+	return raid.Identifier.Version
+}
@@ -0,0 +1,33 @@
+package storage
+
+import "testing"
+
+func TestPageComplete(t *testing.T) {
+	changes := func(versions ...int) []VersionChange {
+		out := make([]VersionChange, len(versions))
+		for i, v := range versions {
+			out[i] = VersionChange{Version: v}
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		changes []VersionChange
+		page    *HistoryPage
+		latest  int
+		want    bool
+	}{
+		{"all", changes(1, 2, 3), nil, 3, true},
+		{"missing early versions", changes(3), nil, 3, false},
+		{"full page", changes(2, 3), &HistoryPage{After: 1, Limit: 2}, 5, true},
+		{"last page", changes(5), &HistoryPage{After: 4, Limit: 2}, 5, true},
+		{"gap in page", changes(2, 4), &HistoryPage{After: 1, Limit: 2}, 5, false},
+		{"none stored", nil, &HistoryPage{Limit: 2}, 5, false},
+		{"past the end", nil, &HistoryPage{After: 5}, 5, true},
+	}
+	for _, tt := range tests {
+		if got := PageComplete(tt.changes, tt.page, tt.latest); got != tt.want {
+			t.Errorf("%s: PageComplete = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// WriteAccessLog inserts access log entries in a single statement
+func (cs *CockroachStorage) WriteAccessLog(ctx context.Context, entries []storage.AccessLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO access_log (ts, method, path, status, actor, raid, data) VALUES `)
+	args := make([]interface{}, 0, len(entries)*7)
+
+	for i, entry := range entries {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return fmt.Errorf("failed to marshal access log entry: %w", err)
+		}
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6, n+7)
+		args = append(args, entry.Time, entry.Method, entry.Path, entry.Status,
+			nullString(entry.Actor), nullString(entry.RAiD), data)
+	}
+
+	_, err := cs.db.ExecContext(ctx, query.String(), args...)
+	return err
+}
+
+// ExportAccessLog calls fn for every stored entry, oldest first
+func (cs *CockroachStorage) ExportAccessLog(ctx context.Context, fn func(*storage.AccessLogEntry) error) error {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM access_log ORDER BY ts`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		var entry storage.AccessLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("failed to unmarshal access log entry: %w", err)
+		}
+		if err := fn(&entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// Verify CockroachStorage can persist and export access logs
+var (
+	_ storage.AccessLogStore    = (*CockroachStorage)(nil)
+	_ storage.AccessLogExporter = (*CockroachStorage)(nil)
+)
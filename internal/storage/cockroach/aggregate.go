@@ -0,0 +1,67 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// AggregateRAiDs counts the RAiDs matching filter by groupBy with GROUP BY.
+// Organisations are grouped from raid_parties, subjects and start years
+// from the documents. Filters with an expression that does not translate to
+// SQL are left to the caller.
+func (cs *CockroachStorage) AggregateRAiDs(ctx context.Context, filter *storage.RAiDFilter, groupBy string) (map[string]int64, error) {
+	conds, args, inMemory := filterConditions(filter, nil)
+	if inMemory {
+		return nil, storage.ErrAggregateUnsupported
+	}
+	matching := `FROM raids WHERE is_current = true AND is_deleted = false` + conds
+
+	var query string
+	switch groupBy {
+	case models.GroupByOrganisation:
+		query = fmt.Sprintf(`SELECT party_id, count(*) FROM raid_parties%s
+		          WHERE kind = '%s' AND party_id <> '' AND (prefix, suffix) IN (SELECT prefix, suffix %s)
+		          GROUP BY party_id`, cs.listingTime(), partyOrganisation, matching)
+	case models.GroupBySubject:
+		// A RAiD listing a subject twice counts once
+		query = `SELECT key, count(*) FROM (
+		           SELECT DISTINCT prefix, suffix, g->>'id' AS key
+		           FROM raids, jsonb_array_elements(COALESCE(data->'subject', '[]'::JSONB)) AS g
+		           WHERE is_current = true AND is_deleted = false` + conds + `
+		         ) AS grouped` + cs.listingTime() + `
+		         WHERE key <> '' GROUP BY key`
+	case models.GroupByYear:
+		query = `SELECT key, count(*) FROM (
+		           SELECT substr(data->'date'->>'startDate', 1, 4) AS key ` + matching + `
+		         ) AS grouped` + cs.listingTime() + `
+		         WHERE length(key) = 4 GROUP BY key`
+	default:
+		return nil, fmt.Errorf("unknown grouping %q", groupBy)
+	}
+
+	rows, err := cs.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var n int64
+		if err := rows.Scan(&key, &n); err != nil {
+			return nil, err
+		}
+		counts[key] = n
+	}
+	return counts, rows.Err()
+}
+
+// Verify CockroachStorage can aggregate RAiDs
+var _ storage.Aggregator = (*CockroachStorage)(nil)
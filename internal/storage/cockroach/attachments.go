@@ -0,0 +1,92 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// PutBlob stores attachment content
+func (cs *CockroachStorage) PutBlob(ctx context.Context, hash string, data []byte) error {
+	_, err := cs.db.ExecContext(ctx, `INSERT INTO blobs (hash, data) VALUES ($1, $2) ON CONFLICT (hash) DO NOTHING`, hash, data)
+	return err
+}
+
+// GetBlob retrieves attachment content
+func (cs *CockroachStorage) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx, `SELECT data FROM blobs WHERE hash = $1`, hash).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// SaveAttachment stores an attachment
+func (cs *CockroachStorage) SaveAttachment(ctx context.Context, attachment *models.Attachment) error {
+	data, err := json.Marshal(attachment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx, `UPSERT INTO attachments (prefix, suffix, hash, data) VALUES ($1, $2, $3, $4)`,
+		attachment.Prefix, attachment.Suffix, attachment.Hash, data)
+	return err
+}
+
+// GetAttachment retrieves an attachment
+func (cs *CockroachStorage) GetAttachment(ctx context.Context, prefix, suffix, hash string) (*models.Attachment, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx, `SELECT data FROM attachments WHERE prefix = $1 AND suffix = $2 AND hash = $3`,
+		prefix, suffix, hash).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var attachment models.Attachment
+	if err := json.Unmarshal(data, &attachment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// ListAttachments retrieves the attachments of a RAiD
+func (cs *CockroachStorage) ListAttachments(ctx context.Context, prefix, suffix string) ([]*models.Attachment, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM attachments WHERE prefix = $1 AND suffix = $2 ORDER BY hash`, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := make([]*models.Attachment, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var attachment models.Attachment
+		if err := json.Unmarshal(data, &attachment); err != nil {
+			continue
+		}
+		attachments = append(attachments, &attachment)
+	}
+	return attachments, rows.Err()
+}
+
+// Verify CockroachStorage can hold attachments and their content
+var (
+	_ storage.BlobStore       = (*CockroachStorage)(nil)
+	_ storage.AttachmentStore = (*CockroachStorage)(nil)
+)
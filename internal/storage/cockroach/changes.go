@@ -0,0 +1,82 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// changeSchema adds the column holding the change each version row made,
+// computed when the row is written. Rows written before it was added have
+// none and their RAiDs are diffed from history when read.
+const changeSchema = `ALTER TABLE raids ADD COLUMN IF NOT EXISTS diff JSONB`
+
+// marshalChange encodes the change of a version row
+func marshalChange(change *storage.VersionChange) ([]byte, error) {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal RAiD change: %w", err)
+	}
+	return data, nil
+}
+
+// GetRAiDChanges reads a page of the stored changes of a RAiD, diffing its
+// history if versions in the page have none
+func (cs *CockroachStorage) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	// Summaries leave the patches in the database
+	column := `diff`
+	query := `SELECT %s FROM raids WHERE prefix = $1 AND suffix = $2 AND version > $3 ORDER BY version`
+	args := []interface{}{prefix, suffix, 0}
+	if page != nil {
+		args[2] = page.After
+		if page.Summary {
+			column = `diff - 'patch'`
+		}
+		if page.Limit > 0 {
+			query += ` LIMIT $4`
+			args = append(args, page.Limit)
+		}
+	}
+	rows, err := cs.db.QueryContext(ctx, fmt.Sprintf(query, column), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]storage.VersionChange, 0)
+	complete := true
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var change storage.VersionChange
+		if data == nil || json.Unmarshal(data, &change) != nil {
+			complete = false
+			continue
+		}
+		changes = append(changes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Every version has a row, so the page lacks changes only where a row
+	// has no diff
+	if complete {
+		return changes, nil
+	}
+
+	history, err := cs.GetRAiDHistory(ctx, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	if changes, err = storage.DiffHistory(history); err != nil {
+		return nil, err
+	}
+	return storage.PageChanges(changes, page), nil
+}
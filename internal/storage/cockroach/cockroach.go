@@ -6,14 +6,19 @@ package cockroach
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/leifj/go-raid/internal/identifier"
 	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/secrets"
 	"github.com/leifj/go-raid/internal/storage"
-	_ "github.com/lib/pq" // PostgreSQL/CockroachDB driver
+	"github.com/leifj/go-raid/internal/textnorm"
+	"github.com/lib/pq" // PostgreSQL/CockroachDB driver
 )
 
 func init() {
@@ -33,13 +38,30 @@ func init() {
 			SSLCert:  crdbCfg.SSLCert,
 			SSLKey:   crdbCfg.SSLKey,
 			SSLRoot:  crdbCfg.SSLRoot,
+
+			PasswordRefresh: crdbCfg.PasswordRefresh,
+			FollowerReads:   crdbCfg.FollowerReads,
+			Region:          crdbCfg.Region,
+			Regions:         crdbCfg.Regions,
 		})
 	})
+
+	// Serialization failures and deadlocks abort the transaction, which
+	// CockroachDB expects the client to retry
+	storage.RegisterTransient(func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && (pqErr.Code == "40001" || pqErr.Code == "40P01")
+	})
 }
 
 // CockroachStorage implements storage.Repository using CockroachDB
 type CockroachStorage struct {
-	db *sql.DB
+	db            *sql.DB
+	prefixes      storage.PrefixAllocator
+	servicePoints storage.ServicePointCache
+	followerReads bool
+	region        string
+	regions       []string
 }
 
 // Config holds CockroachDB configuration
@@ -53,17 +75,36 @@ type Config struct {
 	SSLCert  string
 	SSLKey   string
 	SSLRoot  string
+
+	// Password may be a secret reference (see internal/secrets); it is then
+	// re-read every PasswordRefresh so rotated credentials are picked up
+	PasswordRefresh time.Duration
+
+	// FollowerReads reads listings AS OF SYSTEM TIME
+	// follower_read_timestamp(), so any replica can serve them without
+	// contending with writes, at the cost of missing the last few seconds
+	FollowerReads bool
+
+	// Regions, when set, makes the database multi-region with these
+	// regions, the first being primary. Region is the region the instance
+	// runs in and connects to; its mints draw on suffixes leased to it.
+	Region  string
+	Regions []string
 }
 
 // New creates a new CockroachDB storage instance
 func New(cfg *Config) (*CockroachStorage, error) {
-	// Build connection string
-	connStr := buildConnString(cfg)
+	// Open database connection. The connector resolves the password for
+	// every new connection so that rotated secrets take effect.
+	conn := &connector{
+		cfg:      cfg,
+		password: secrets.NewCached(cfg.Password, cfg.PasswordRefresh),
+	}
+	db := sql.OpenDB(conn)
 
-	// Open database connection
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+	// Recycle pooled connections so they re-authenticate with rotated passwords
+	if secrets.IsReference(cfg.Password) && cfg.PasswordRefresh > 0 {
+		db.SetConnMaxLifetime(cfg.PasswordRefresh)
 	}
 
 	// Test connection
@@ -72,13 +113,19 @@ func New(cfg *Config) (*CockroachStorage, error) {
 	}
 
 	cs := &CockroachStorage{
-		db: db,
+		db:            db,
+		followerReads: cfg.FollowerReads,
+		region:        cfg.Region,
+		regions:       cfg.Regions,
 	}
 
 	// Initialize schema
 	if err := cs.initSchema(); err != nil {
 		return nil, fmt.Errorf("failed to initialize schema: %w", err)
 	}
+	if err := cs.initRegions(cfg.Database); err != nil {
+		return nil, fmt.Errorf("failed to configure regions: %w", err)
+	}
 
 	return cs, nil
 }
@@ -102,6 +149,9 @@ func (cs *CockroachStorage) initSchema() error {
 		INVERTED INDEX raids_data_idx (data)
 	);
 
+	-- Keyset pagination of listings, in mint order
+	CREATE INDEX IF NOT EXISTS raids_listing_idx ON raids (created_at, prefix, suffix) WHERE is_current = true;
+
 	-- Service Point table
 	CREATE TABLE IF NOT EXISTS service_points (
 		id SERIAL PRIMARY KEY,
@@ -116,38 +166,145 @@ func (cs *CockroachStorage) initSchema() error {
 		name TEXT PRIMARY KEY,
 		value INT NOT NULL DEFAULT 1000
 	);
+
+	-- Access log, written when the storage access log sink is enabled
+	CREATE TABLE IF NOT EXISTS access_log (
+		id UUID NOT NULL DEFAULT gen_random_uuid() PRIMARY KEY,
+		ts TIMESTAMPTZ NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		status INT NOT NULL,
+		actor TEXT,
+		raid TEXT,
+		data JSONB NOT NULL,
+		INDEX access_log_ts_idx (ts),
+		INDEX access_log_raid_idx (raid, ts) WHERE raid IS NOT NULL
+	);
+
+	-- Saved searches, by owner and name
+	CREATE TABLE IF NOT EXISTS saved_searches (
+		owner TEXT NOT NULL,
+		name TEXT NOT NULL,
+		feed TEXT UNIQUE,
+		data JSONB NOT NULL,
+		PRIMARY KEY (owner, name)
+	);
+
+	-- Tags service points give RAiDs, kept apart from the documents
+	CREATE TABLE IF NOT EXISTS raid_tags (
+		service_point INT8 NOT NULL,
+		tag TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		suffix TEXT NOT NULL,
+		PRIMARY KEY (service_point, tag, prefix, suffix),
+		INDEX raid_tags_raid_idx (service_point, prefix, suffix)
+	);
+
+	-- Draft RAiDs, which have no identifier until they are promoted
+	CREATE TABLE IF NOT EXISTS drafts (
+		id TEXT PRIMARY KEY,
+		service_point INT8 NOT NULL,
+		data JSONB NOT NULL,
+		INDEX drafts_service_point_idx (service_point, id)
+	);
+
+	-- Identifiers reserved ahead of their mint
+	CREATE TABLE IF NOT EXISTS reservations (
+		prefix TEXT NOT NULL,
+		suffix TEXT NOT NULL,
+		service_point INT8 NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (prefix, suffix)
+	);
+
+	-- Changes held until they take effect
+	CREATE TABLE IF NOT EXISTS scheduled_changes (
+		id TEXT PRIMARY KEY,
+		service_point INT8 NOT NULL,
+		effective_at TIMESTAMPTZ NOT NULL,
+		status TEXT NOT NULL,
+		data JSONB NOT NULL,
+		INDEX scheduled_changes_service_point_idx (service_point, id),
+		INDEX scheduled_changes_due_idx (status, effective_at)
+	);
+
+	-- Related objects announced by other systems, until accepted
+	CREATE TABLE IF NOT EXISTS proposals (
+		id TEXT PRIMARY KEY,
+		service_point INT8 NOT NULL,
+		data JSONB NOT NULL,
+		INDEX proposals_service_point_idx (service_point, id)
+	);
+
+	-- ActivityPub actors following registry activity
+	CREATE TABLE IF NOT EXISTS followers (
+		id TEXT PRIMARY KEY,
+		data JSONB NOT NULL
+	);
+
+	-- Documents attached to RAiDs, and their content by SHA-256 hash
+	CREATE TABLE IF NOT EXISTS attachments (
+		prefix TEXT NOT NULL,
+		suffix TEXT NOT NULL,
+		hash TEXT NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (prefix, suffix, hash)
+	);
+	CREATE TABLE IF NOT EXISTS blobs (
+		hash TEXT PRIMARY KEY,
+		data BYTES NOT NULL
+	);
 	`
 
-	_, err := cs.db.Exec(schema)
-	return err
+	if _, err := cs.db.Exec(schema); err != nil {
+		return err
+	}
+
+	// Hot list filters read columns computed from the document and the
+	// raid_parties side table instead of JSONB containment over the whole
+	// table. Schema changes run one statement at a time, as CockroachDB
+	// cannot index a column added in the same transaction.
+	for _, stmt := range filterSchema {
+		if _, err := cs.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := cs.db.Exec(changeSchema); err != nil {
+		return err
+	}
+	if _, err := cs.db.Exec(termSchema); err != nil {
+		return err
+	}
+	for _, stmt := range purgeSchema {
+		if _, err := cs.db.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	if _, err := cs.db.Exec(redactionSchema); err != nil {
+		return err
+	}
+	if err := cs.backfillParties(context.Background()); err != nil {
+		return err
+	}
+	return cs.backfillTerms(context.Background())
 }
 
 // CreateRAiD creates a new RAiD
 func (cs *CockroachStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
 	// Generate identifier if not present
 	if raid.Identifier == nil || raid.Identifier.ID == "" {
-		servicePointID := int64(0)
-		if raid.Identifier != nil && raid.Identifier.Owner != nil {
-			servicePointID = raid.Identifier.Owner.ServicePoint
-		}
-		prefix, suffix, err := cs.GenerateIdentifier(ctx, servicePointID)
-		if err != nil {
-			return nil, err
-		}
-		if raid.Identifier == nil {
-			raid.Identifier = &models.Identifier{}
-		}
-		raid.Identifier.ID = fmt.Sprintf("https://raid.org/%s/%s", prefix, suffix)
+		return storage.MintGenerated(ctx, cs, raid, identifier.FormatDefault)
 	}
 
 	// Extract prefix and suffix
-	prefix, suffix, err := parseRAiDIdentifier(raid.Identifier.ID)
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set metadata
-	now := time.Now()
+	now := storage.WriteTime(ctx)
 	if raid.Metadata == nil {
 		raid.Metadata = &models.Metadata{}
 	}
@@ -163,6 +320,14 @@ func (cs *CockroachStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal RAiD: %w", err)
 	}
+	diff, err := storage.RecordVersion(ctx, nil, raid, "")
+	if err != nil {
+		return nil, err
+	}
+	change, err := marshalChange(diff)
+	if err != nil {
+		return nil, err
+	}
 
 	// Insert into database
 	tx, err := cs.db.BeginTx(ctx, nil)
@@ -185,14 +350,21 @@ func (cs *CockroachStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (
 	}
 
 	// Insert
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO raids (prefix, suffix, version, is_current, data, created_at, updated_at) 
-		 VALUES ($1, $2, $3, true, $4, $5, $6)`,
-		prefix, suffix, raid.Identifier.Version, data, now, now,
-	)
+	err = insertRAiD(ctx, tx, cs.homeRegion(ctx, raid), prefix, suffix, raid.Identifier.Version, data, change, now, now)
 	if err != nil {
+		// A concurrent mint inserted the same handle since the check
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return nil, storage.ErrAlreadyExists
+		}
 		return nil, fmt.Errorf("failed to insert RAiD: %w", err)
 	}
+	if err := setParties(ctx, tx, prefix, suffix, raid); err != nil {
+		return nil, err
+	}
+	if err := setTerms(ctx, tx, prefix, suffix, raid); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, err
@@ -225,6 +397,52 @@ func (cs *CockroachStorage) GetRAiD(ctx context.Context, prefix, suffix string)
 	return &raid, nil
 }
 
+// GetRAiDs retrieves several RAiDs in one query
+func (cs *CockroachStorage) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	raids := make([]*models.RAiD, len(refs))
+	if len(refs) == 0 {
+		return raids, nil
+	}
+	prefixes := make([]string, len(refs))
+	suffixes := make([]string, len(refs))
+	for i, ref := range refs {
+		prefixes[i], suffixes[i] = ref.Prefix, ref.Suffix
+	}
+
+	rows, err := cs.db.QueryContext(ctx,
+		`SELECT prefix, suffix, data FROM raids
+		 WHERE is_current = true AND is_deleted = false
+		 AND (prefix, suffix) IN (SELECT * FROM unnest($1::STRING[], $2::STRING[]))`,
+		pq.Array(prefixes), pq.Array(suffixes),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[storage.IdentifierRef]*models.RAiD, len(refs))
+	for rows.Next() {
+		var ref storage.IdentifierRef
+		var data []byte
+		if err := rows.Scan(&ref.Prefix, &ref.Suffix, &data); err != nil {
+			return nil, err
+		}
+		var raid models.RAiD
+		if err := json.Unmarshal(data, &raid); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal RAiD: %w", err)
+		}
+		found[ref] = &raid
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, ref := range refs {
+		raids[i] = found[ref]
+	}
+	return raids, nil
+}
+
 // GetRAiDVersion retrieves a specific version
 func (cs *CockroachStorage) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
 	var data []byte
@@ -240,6 +458,13 @@ func (cs *CockroachStorage) GetRAiDVersion(ctx context.Context, prefix, suffix s
 	if err != nil {
 		return nil, err
 	}
+	if storage.IsArchived(data) {
+		stored, err := storedVersions(ctx, cs.db, prefix, suffix, false)
+		if err != nil {
+			return nil, err
+		}
+		return storage.RehydrateVersion(stored, version)
+	}
 
 	var raid models.RAiD
 	if err := json.Unmarshal(data, &raid); err != nil {
@@ -260,10 +485,12 @@ func (cs *CockroachStorage) UpdateRAiD(ctx context.Context, prefix, suffix strin
 	// Get current version
 	var currentVersion int
 	var createdAt time.Time
+	var currentData []byte
+	var currentHash, currentChain string
 	err = tx.QueryRowContext(ctx,
-		`SELECT version, created_at FROM raids WHERE prefix = $1 AND suffix = $2 AND is_current = true`,
+		`SELECT version, created_at, data, COALESCE(diff->>'hash', ''), COALESCE(diff->>'chain', '') FROM raids WHERE prefix = $1 AND suffix = $2 AND is_current = true`,
 		prefix, suffix,
-	).Scan(&currentVersion, &createdAt)
+	).Scan(&currentVersion, &createdAt, &currentData, &currentHash, &currentChain)
 
 	if err == sql.ErrNoRows {
 		return nil, storage.ErrNotFound
@@ -271,9 +498,25 @@ func (cs *CockroachStorage) UpdateRAiD(ctx context.Context, prefix, suffix strin
 	if err != nil {
 		return nil, err
 	}
+	var current models.RAiD
+	if err := json.Unmarshal(currentData, &current); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RAiD: %w", err)
+	}
+	if err := storage.CheckVersion(raid, currentVersion); err != nil {
+		return nil, err
+	}
+
+	// An update repeating the current content adds no version
+	unchanged, err := storage.Unchanged(currentHash, &current, raid)
+	if err != nil {
+		return nil, err
+	}
+	if unchanged {
+		return &current, nil
+	}
 
 	// Update metadata
-	now := time.Now()
+	now := storage.WriteTime(ctx)
 	if raid.Metadata == nil {
 		raid.Metadata = &models.Metadata{}
 	}
@@ -286,6 +529,14 @@ func (cs *CockroachStorage) UpdateRAiD(ctx context.Context, prefix, suffix strin
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal RAiD: %w", err)
 	}
+	diff, err := storage.RecordVersion(ctx, &current, raid, currentChain)
+	if err != nil {
+		return nil, err
+	}
+	change, err := marshalChange(diff)
+	if err != nil {
+		return nil, err
+	}
 
 	// Mark old version as not current
 	_, err = tx.ExecContext(ctx,
@@ -297,14 +548,16 @@ func (cs *CockroachStorage) UpdateRAiD(ctx context.Context, prefix, suffix strin
 	}
 
 	// Insert new version
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO raids (prefix, suffix, version, is_current, data, created_at, updated_at) 
-		 VALUES ($1, $2, $3, true, $4, $5, $6)`,
-		prefix, suffix, raid.Identifier.Version, data, createdAt, now,
-	)
+	err = insertRAiD(ctx, tx, cs.homeRegion(ctx, raid), prefix, suffix, raid.Identifier.Version, data, change, createdAt, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to insert new version: %w", err)
 	}
+	if err := setParties(ctx, tx, prefix, suffix, raid); err != nil {
+		return nil, err
+	}
+	if err := setTerms(ctx, tx, prefix, suffix, raid); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
 		return nil, err
@@ -313,29 +566,33 @@ func (cs *CockroachStorage) UpdateRAiD(ctx context.Context, prefix, suffix strin
 	return raid, nil
 }
 
+// likePrefix returns a LIKE pattern matching strings starting with prefix
+func likePrefix(prefix string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(prefix) + "%"
+}
+
 // ListRAiDs lists RAiDs with filters
 func (cs *CockroachStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
-	query := `SELECT data FROM raids WHERE is_current = true AND is_deleted = false`
-	args := make([]interface{}, 0)
-	argCount := 1
-
-	// Build dynamic query based on filters
-	if filter != nil {
-		if filter.ContributorID != "" {
-			query += fmt.Sprintf(` AND data->'contributor' @> '[{"id": "%s"}]'`, filter.ContributorID)
-		}
-		if filter.OrganisationID != "" {
-			query += fmt.Sprintf(` AND data->'organisation' @> '[{"id": "%s"}]'`, filter.OrganisationID)
-		}
-		if filter.Limit > 0 {
-			query += fmt.Sprintf(` LIMIT $%d`, argCount)
-			args = append(args, filter.Limit)
-			argCount++
-		}
-		if filter.Offset > 0 {
-			query += fmt.Sprintf(` OFFSET $%d`, argCount)
-			args = append(args, filter.Offset)
-		}
+	query := `SELECT data, is_deleted, deleted_at FROM raids` + cs.listingTime() + ` WHERE is_current = true`
+	switch {
+	case filter != nil && filter.DeletedOnly:
+		query += ` AND is_deleted = true`
+	case filter == nil || !filter.IncludeDeleted:
+		query += ` AND is_deleted = false`
+	}
+	conds, args, inMemory := filterConditions(filter, nil)
+	query += conds
+	page := filter
+	if inMemory {
+		// The expression is evaluated on the rows, so the page is cut
+		// afterwards
+		all := *filter
+		all.Limit, all.Offset = 0, 0
+		page = &all
+	}
+	query, args, err := keysetPage(query, args, page)
+	if err != nil {
+		return nil, err
 	}
 
 	rows, err := cs.db.QueryContext(ctx, query, args...)
@@ -347,7 +604,9 @@ func (cs *CockroachStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDF
 	raids := make([]*models.RAiD, 0)
 	for rows.Next() {
 		var data []byte
-		if err := rows.Scan(&data); err != nil {
+		var deleted bool
+		var deletedAt sql.NullTime
+		if err := rows.Scan(&data, &deleted, &deletedAt); err != nil {
 			continue
 		}
 
@@ -355,32 +614,157 @@ func (cs *CockroachStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDF
 		if err := json.Unmarshal(data, &raid); err != nil {
 			continue
 		}
+		if deleted {
+			storage.MarkDeleted(&raid, deletedAt.Time)
+		}
+		if inMemory && !storage.MatchesExpression(&raid, filter.Expression) {
+			continue
+		}
 
 		raids = append(raids, &raid)
 	}
+	if inMemory {
+		raids = raids[min(max(filter.Offset, 0), len(raids)):]
+		if filter.Limit > 0 && filter.Limit < len(raids) {
+			raids = raids[:filter.Limit]
+		}
+	}
 
 	return raids, rows.Err()
 }
 
-// ListPublicRAiDs lists only public RAiDs
+// filterConditions returns the conditions filter puts on the current
+// versions in raids, each starting with AND, with args extended by their
+// arguments. inMemory reports an expression that does not translate to SQL
+// and is left for the caller to evaluate on the rows.
+func filterConditions(filter *storage.RAiDFilter, args []interface{}) (query string, _ []interface{}, inMemory bool) {
+	if filter == nil {
+		return "", args, false
+	}
+	argCount := len(args) + 1
+	if filter.ContributorID != "" {
+		query += fmt.Sprintf(` AND (prefix, suffix) IN (SELECT prefix, suffix FROM raid_parties WHERE kind = '%s' AND party_id = $%d)`, partyContributor, argCount)
+		args = append(args, filter.ContributorID)
+		argCount++
+	}
+	if filter.OrganisationID != "" {
+		query += fmt.Sprintf(` AND (prefix, suffix) IN (SELECT prefix, suffix FROM raid_parties WHERE kind = '%s' AND party_id = $%d)`, partyOrganisation, argCount)
+		args = append(args, filter.OrganisationID)
+		argCount++
+	}
+	if filter.HasTraditionalKnowledge != nil {
+		op := "="
+		if *filter.HasTraditionalKnowledge {
+			op = ">"
+		}
+		query += ` AND jsonb_array_length(COALESCE(data->'traditionalKnowledgeLabel', '[]'::JSONB)) ` + op + ` 0`
+	}
+	if filter.TraditionalKnowledgeLabelID != "" {
+		label, _ := json.Marshal([]map[string]string{{"id": filter.TraditionalKnowledgeLabelID}})
+		query += fmt.Sprintf(` AND data->'traditionalKnowledgeLabel' @> $%d::JSONB`, argCount)
+		args = append(args, string(label))
+		argCount++
+	}
+	if filter.SubjectKeyword != "" {
+		// Keywords are matched folded, through raid_terms, scoped by the
+		// subject they are given for
+		query += fmt.Sprintf(` AND (prefix, suffix) IN (SELECT prefix, suffix FROM raid_terms WHERE kind = '%s' AND term = $%d AND scope LIKE $%d)`, textnorm.TermKeyword, argCount, argCount+1)
+		args = append(args, textnorm.Fold(filter.SubjectKeyword), likePrefix(filter.SubjectID))
+		argCount += 2
+	} else if filter.SubjectID != "" {
+		query += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM jsonb_array_elements(data->'subject') AS s WHERE s->>'id' LIKE $%d)`, argCount)
+		args = append(args, likePrefix(filter.SubjectID))
+		argCount++
+	}
+	if filter.Text != "" {
+		query += fmt.Sprintf(` AND (prefix, suffix) IN (SELECT prefix, suffix FROM raid_terms WHERE term LIKE $%d)`, argCount)
+		args = append(args, "%"+likePrefix(textnorm.Fold(filter.Text)))
+		argCount++
+	}
+	if filter.RelatedObjectDOI != "" {
+		// Containment on the whole document uses the inverted index;
+		// DOIs are stored normalized
+		doc, _ := json.Marshal(map[string][]map[string]string{"relatedObject": {{"id": filter.RelatedObjectDOI}}})
+		query += fmt.Sprintf(` AND data @> $%d::JSONB`, argCount)
+		args = append(args, string(doc))
+		argCount++
+	}
+	if filter.ServicePointID != 0 {
+		query += fmt.Sprintf(` AND owner_service_point = $%d`, argCount)
+		args = append(args, filter.ServicePointID)
+		argCount++
+	}
+	if !filter.MintedSince.IsZero() {
+		// created_at keeps the mint time across versions and leads the
+		// listing index
+		query += fmt.Sprintf(` AND created_at >= $%d`, argCount)
+		args = append(args, filter.MintedSince)
+		argCount++
+	}
+	if filter.Expression != nil {
+		if cond, exprArgs, ok := filter.Expression.SQL("data", argCount); ok {
+			query += ` AND ` + cond
+			args = append(args, exprArgs...)
+		} else {
+			inMemory = true
+		}
+	}
+	return query, args, inMemory
+}
+
+// listingTime returns the AS OF SYSTEM TIME clause of listing queries,
+// empty unless follower reads are enabled. It covers the subqueries of the
+// filters and cursor too.
+func (cs *CockroachStorage) listingTime() string {
+	if !cs.followerReads {
+		return ""
+	}
+	return ` AS OF SYSTEM TIME follower_read_timestamp()`
+}
+
+// keysetPage orders a listing query by mint date and handle and appends
+// the page filter selects. A cursor seeks past the RAiD it names through
+// raids_listing_idx, so deep pages cost no more than the first; an offset
+// is still honoured after it.
+func keysetPage(query string, args []interface{}, filter *storage.RAiDFilter) (string, []interface{}, error) {
+	if filter != nil && filter.Cursor != "" {
+		prefix, suffix, err := identifier.ParseCursor(filter.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		n := len(args) + 1
+		query += fmt.Sprintf(` AND (created_at, prefix, suffix) > ((SELECT created_at FROM raids WHERE prefix = $%d AND suffix = $%d AND is_current = true), $%d, $%d)`, n, n+1, n, n+1)
+		args = append(args, prefix, suffix)
+	}
+	query += ` ORDER BY created_at, prefix, suffix`
+	if filter != nil && filter.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT $%d`, len(args)+1)
+		args = append(args, filter.Limit)
+	}
+	if filter != nil && filter.Offset > 0 {
+		query += fmt.Sprintf(` OFFSET $%d`, len(args)+1)
+		args = append(args, filter.Offset)
+	}
+	return query, args, nil
+}
+
+// ListPublicRAiDs lists only public RAiDs, with the filters of ListRAiDs
 func (cs *CockroachStorage) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
-	query := `SELECT data FROM raids 
+	query := `SELECT data FROM raids` + cs.listingTime() + `
 	          WHERE is_current = true 
 	          AND is_deleted = false 
-	          AND data->'access'->'type'->>'id' = 'https://vocabulary.raid.org/access.type.schema/82'`
-	args := make([]interface{}, 0)
-	argCount := 1
-
-	if filter != nil {
-		if filter.Limit > 0 {
-			query += fmt.Sprintf(` LIMIT $%d`, argCount)
-			args = append(args, filter.Limit)
-			argCount++
-		}
-		if filter.Offset > 0 {
-			query += fmt.Sprintf(` OFFSET $%d`, argCount)
-			args = append(args, filter.Offset)
-		}
+	          AND access_type = $1`
+	conds, args, inMemory := filterConditions(filter, []interface{}{storage.AccessTypeOpen})
+	query += conds
+	page := filter
+	if inMemory {
+		all := *filter
+		all.Limit, all.Offset = 0, 0
+		page = &all
+	}
+	query, args, err := keysetPage(query, args, page)
+	if err != nil {
+		return nil, err
 	}
 
 	rows, err := cs.db.QueryContext(ctx, query, args...)
@@ -400,46 +784,53 @@ func (cs *CockroachStorage) ListPublicRAiDs(ctx context.Context, filter *storage
 		if err := json.Unmarshal(data, &raid); err != nil {
 			continue
 		}
+		if inMemory && !storage.MatchesExpression(&raid, filter.Expression) {
+			continue
+		}
 
 		raids = append(raids, &raid)
 	}
+	if inMemory {
+		raids = raids[min(max(filter.Offset, 0), len(raids)):]
+		if filter.Limit > 0 && filter.Limit < len(raids) {
+			raids = raids[:filter.Limit]
+		}
+	}
 
 	return raids, rows.Err()
 }
 
 // GetRAiDHistory retrieves version history
 func (cs *CockroachStorage) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
-	rows, err := cs.db.QueryContext(ctx,
-		`SELECT data FROM raids WHERE prefix = $1 AND suffix = $2 ORDER BY version DESC`,
-		prefix, suffix,
-	)
+	stored, err := storedVersions(ctx, cs.db, prefix, suffix, false)
+	if err == storage.ErrNotFound {
+		return []*models.RAiD{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	full, err := storage.RehydrateVersions(stored)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	history := make([]*models.RAiD, 0)
-	for rows.Next() {
-		var data []byte
-		if err := rows.Scan(&data); err != nil {
-			continue
-		}
 
+	history := make([]*models.RAiD, 0, len(full))
+	for i := len(full) - 1; i >= 0; i-- {
 		var raid models.RAiD
-		if err := json.Unmarshal(data, &raid); err != nil {
+		if err := json.Unmarshal(full[i].Data, &raid); err != nil {
 			continue
 		}
 
 		history = append(history, &raid)
 	}
 
-	return history, rows.Err()
+	return history, nil
 }
 
 // DeleteRAiD soft deletes a RAiD
 func (cs *CockroachStorage) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
 	result, err := cs.db.ExecContext(ctx,
-		`UPDATE raids SET is_deleted = true WHERE prefix = $1 AND suffix = $2 AND is_current = true`,
+		`UPDATE raids SET is_deleted = true, deleted_at = now() WHERE prefix = $1 AND suffix = $2 AND is_current = true`,
 		prefix, suffix,
 	)
 	if err != nil {
@@ -460,13 +851,13 @@ func (cs *CockroachStorage) DeleteRAiD(ctx context.Context, prefix, suffix strin
 // GenerateIdentifier generates a unique identifier
 func (cs *CockroachStorage) GenerateIdentifier(ctx context.Context, servicePointID int64) (prefix, suffix string, err error) {
 	// Get prefix from service point
-	prefix = "10.25.1.1" // Default
+	var sp *models.ServicePoint
 	if servicePointID > 0 {
-		sp, err := cs.GetServicePoint(ctx, servicePointID)
-		if err == nil && sp.Prefix != "" {
-			prefix = sp.Prefix
+		if loaded, err := cs.servicePoints.Get(ctx, servicePointID, cs.GetServicePoint); err == nil {
+			sp = loaded
 		}
 	}
+	prefix = cs.prefixes.Allocate(ctx, sp)
 
 	// Generate suffix using database sequence
 	tx, err := cs.db.BeginTx(ctx, nil)
@@ -475,7 +866,19 @@ func (cs *CockroachStorage) GenerateIdentifier(ctx context.Context, servicePoint
 	}
 	defer tx.Rollback()
 
-	counterName := fmt.Sprintf("raid_%s", strings.ReplaceAll(prefix, ".", "_"))
+	counterName := raidCounterName(prefix)
+
+	// Instances in a region mint from the block leased to it
+	if cs.region != "" && len(cs.regions) > 0 {
+		counter, err := cs.nextRegional(ctx, tx, counterName)
+		if err != nil {
+			return "", "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return "", "", err
+		}
+		return prefix, fmt.Sprintf("%d", counter), nil
+	}
 
 	// Ensure counter exists
 	_, err = tx.ExecContext(ctx,
@@ -566,6 +969,7 @@ func (cs *CockroachStorage) UpdateServicePoint(ctx context.Context, id int64, sp
 		`UPDATE service_points SET data = $1, updated_at = NOW() WHERE id = $2`,
 		data, id,
 	)
+	cs.servicePoints.Invalidate(id)
 	if err != nil {
 		return nil, err
 	}
@@ -613,6 +1017,7 @@ func (cs *CockroachStorage) DeleteServicePoint(ctx context.Context, id int64) er
 		`DELETE FROM service_points WHERE id = $1`,
 		id,
 	)
+	cs.servicePoints.Invalidate(id)
 	if err != nil {
 		return err
 	}
@@ -638,6 +1043,78 @@ func (cs *CockroachStorage) HealthCheck(ctx context.Context) error {
 	return cs.db.PingContext(ctx)
 }
 
+// connector opens connections using the current password from the secret store
+type connector struct {
+	cfg      *Config
+	password *secrets.Cached
+}
+
+// Connect implements driver.Connector
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	password, err := c.password.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *c.cfg
+	cfg.Password = password
+
+	pqConnector, err := pq.NewConnector(buildConnString(&cfg))
+	if err != nil {
+		return nil, err
+	}
+	return pqConnector.Connect(ctx)
+}
+
+// Driver implements driver.Connector
+func (c *connector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
+
+// Stats reports connection pool statistics and table sizes
+func (cs *CockroachStorage) Stats(ctx context.Context) (map[string]interface{}, error) {
+	dbStats := cs.db.Stats()
+
+	stats := map[string]interface{}{
+		"openConnections":    dbStats.OpenConnections,
+		"inUse":              dbStats.InUse,
+		"idle":               dbStats.Idle,
+		"maxOpenConnections": dbStats.MaxOpenConnections,
+		"waitCount":          dbStats.WaitCount,
+		"waitDuration":       dbStats.WaitDuration.String(),
+		"maxIdleClosed":      dbStats.MaxIdleClosed,
+		"maxLifetimeClosed":  dbStats.MaxLifetimeClosed,
+	}
+
+	start := time.Now()
+	var current, versions, servicePoints int64
+	err := cs.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT count(*) FROM raids WHERE is_current = true AND is_deleted = false),
+			(SELECT count(*) FROM raids),
+			(SELECT count(*) FROM service_points)`,
+	).Scan(&current, &versions, &servicePoints)
+	if err != nil {
+		return nil, err
+	}
+
+	stats["raids"] = current
+	stats["raidVersions"] = versions
+	stats["servicePoints"] = servicePoints
+	stats["countQueryLatency"] = time.Since(start).String()
+
+	if len(cs.regions) > 0 {
+		stats["region"] = cs.region
+		stats["regions"] = cs.regions
+		// Connecting through another region's nodes forfeits local mints
+		if gateway, err := cs.gatewayRegion(ctx); err == nil {
+			stats["gatewayRegion"] = gateway
+		}
+	}
+
+	return stats, nil
+}
+
 // Helper functions
 
 func buildConnString(cfg *Config) string {
@@ -668,16 +1145,16 @@ func buildConnString(cfg *Config) string {
 		parts = append(parts, fmt.Sprintf("sslrootcert=%s", cfg.SSLRoot))
 	}
 
-	return strings.Join(parts, " ")
-}
-
-func parseRAiDIdentifier(id string) (prefix, suffix string, err error) {
-	parts := strings.Split(id, "/")
-	if len(parts) < 5 {
-		return "", "", fmt.Errorf("invalid RAiD identifier format: %s", id)
+	// Tell the cluster which region's instance each statement comes from
+	if cfg.Region != "" {
+		parts = append(parts, fmt.Sprintf("application_name=go-raid@%s", cfg.Region))
 	}
-	return parts[3], parts[4], nil
+
+	return strings.Join(parts, " ")
 }
 
 // Verify CockroachStorage implements storage.Repository
 var _ storage.Repository = (*CockroachStorage)(nil)
+
+// Verify CockroachStorage exposes diagnostic statistics
+var _ storage.StatsProvider = (*CockroachStorage)(nil)
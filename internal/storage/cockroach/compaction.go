@@ -0,0 +1,114 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// storedVersions reads the data of every version row of a RAiD as stored,
+// archived or not, in ascending order. With forUpdate the rows are locked
+// for the rest of the transaction.
+func storedVersions(ctx context.Context, q querier, prefix, suffix string, forUpdate bool) ([]storage.StoredVersion, error) {
+	query := `SELECT version, data FROM raids WHERE prefix = $1 AND suffix = $2 ORDER BY version`
+	if forUpdate {
+		query += ` FOR UPDATE`
+	}
+	rows, err := q.QueryContext(ctx, query, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []storage.StoredVersion
+	for rows.Next() {
+		var v storage.StoredVersion
+		if err := rows.Scan(&v.Version, &v.Data); err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, storage.ErrNotFound
+	}
+	return versions, nil
+}
+
+// CompactRAiD replaces the data of the version rows policy selects with
+// archived diffs
+func (cs *CockroachStorage) CompactRAiD(ctx context.Context, prefix, suffix string, policy storage.CompactionPolicy) (int, error) {
+	return cs.rewriteVersions(ctx, prefix, suffix, func(stored []storage.StoredVersion) ([]storage.StoredVersion, error) {
+		return storage.CompactVersions(stored, policy, time.Now())
+	})
+}
+
+// RehydrateRAiD stores the archived version rows of a RAiD as full
+// documents again
+func (cs *CockroachStorage) RehydrateRAiD(ctx context.Context, prefix, suffix string) (int, error) {
+	return cs.rewriteVersions(ctx, prefix, suffix, func(stored []storage.StoredVersion) ([]storage.StoredVersion, error) {
+		full, err := storage.RehydrateVersions(stored)
+		if err != nil {
+			return nil, err
+		}
+		var rehydrated []storage.StoredVersion
+		for i, v := range stored {
+			if storage.IsArchived(v.Data) {
+				rehydrated = append(rehydrated, full[i])
+			}
+		}
+		return rehydrated, nil
+	})
+}
+
+// rewriteVersions replaces the data of the version rows that rewrite
+// returns for the stored versions of a RAiD, in one transaction
+func (cs *CockroachStorage) rewriteVersions(ctx context.Context, prefix, suffix string, rewrite func([]storage.StoredVersion) ([]storage.StoredVersion, error)) (int, error) {
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stored, err := storedVersions(ctx, tx, prefix, suffix, true)
+	if err != nil {
+		return 0, err
+	}
+	changed, err := rewrite(stored)
+	if err != nil {
+		return 0, err
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+
+	for _, v := range changed {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE raids SET data = $4 WHERE prefix = $1 AND suffix = $2 AND version = $3 AND is_current = false`,
+			prefix, suffix, v.Version, v.Data,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(changed), nil
+}
+
+// Verify CockroachStorage supports compaction
+var _ storage.VersionCompactor = (*CockroachStorage)(nil)
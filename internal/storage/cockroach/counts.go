@@ -0,0 +1,112 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// CountRAiDs computes repository counts with SQL aggregates
+func (cs *CockroachStorage) CountRAiDs(ctx context.Context) (*storage.RAiDCounts, error) {
+	counts := storage.NewRAiDCounts()
+
+	err := cs.db.QueryRowContext(ctx,
+		`SELECT
+		   count(*) FILTER (WHERE is_current AND NOT is_deleted),
+		   count(*) FILTER (WHERE is_current AND NOT is_deleted AND access_type = $1),
+		   count(*) FILTER (WHERE is_current AND NOT is_deleted AND access_type = $2),
+		   count(*) FILTER (WHERE is_current AND is_deleted),
+		   count(*)
+		 FROM raids`,
+		storage.AccessTypeOpen, storage.AccessTypeEmbargoed,
+	).Scan(&counts.Total, &counts.Public, &counts.Embargoed, &counts.Deleted, &counts.Versions)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := cs.db.QueryContext(ctx,
+		`SELECT COALESCE(owner_service_point, 0) AS sp, count(*)
+		 FROM raids WHERE is_current AND NOT is_deleted
+		 GROUP BY sp`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var sp, n int64
+		if err := rows.Scan(&sp, &n); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		counts.ByServicePoint[sp] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = cs.db.QueryContext(ctx,
+		`SELECT created_at::DATE AS day, count(*) FROM raids WHERE version = 1 GROUP BY day`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day time.Time
+		var n int64
+		if err := rows.Scan(&day, &n); err != nil {
+			return nil, err
+		}
+		counts.MintedByDay[day.Format("2006-01-02")] = n
+	}
+
+	return counts, rows.Err()
+}
+
+// CountActivity aggregates minting activity with SQL aggregates. Each row
+// is a version, stamped with the time it was written.
+func (cs *CockroachStorage) CountActivity(ctx context.Context, interval string) ([]storage.ActivityCount, error) {
+	if err := storage.ValidateInterval(interval); err != nil {
+		return nil, err
+	}
+	// Archived versions are diffs without an owner; they count for the
+	// owner of the current version
+	rows, err := cs.db.QueryContext(ctx,
+		`SELECT date_trunc($1, r.created_at) AS period,
+		   COALESCE(r.owner_service_point, c.owner_service_point, 0) AS sp,
+		   count(*) FILTER (WHERE r.version = 1),
+		   count(*) FILTER (WHERE r.version > 1)
+		 FROM raids r
+		 LEFT JOIN raids c ON c.prefix = r.prefix AND c.suffix = r.suffix AND c.is_current
+		 GROUP BY period, sp
+		 ORDER BY period, sp`,
+		interval,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]storage.ActivityCount, 0)
+	for rows.Next() {
+		var period time.Time
+		var c storage.ActivityCount
+		if err := rows.Scan(&period, &c.ServicePoint, &c.Minted, &c.Updated); err != nil {
+			return nil, err
+		}
+		c.Period = period.Format("2006-01-02")
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Verify CockroachStorage counts natively
+var (
+	_ storage.RAiDCountProvider = (*CockroachStorage)(nil)
+	_ storage.ActivityProvider  = (*CockroachStorage)(nil)
+)
@@ -0,0 +1,83 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// SaveDraft stores a draft RAiD
+func (cs *CockroachStorage) SaveDraft(ctx context.Context, draft *models.Draft) error {
+	data, err := json.Marshal(draft)
+	if err != nil {
+		return fmt.Errorf("failed to marshal draft: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx,
+		`UPSERT INTO drafts (id, service_point, data) VALUES ($1, $2, $3)`,
+		draft.ID, draft.ServicePoint, data,
+	)
+	return err
+}
+
+// GetDraft retrieves a draft RAiD
+func (cs *CockroachStorage) GetDraft(ctx context.Context, id string) (*models.Draft, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx, `SELECT data FROM drafts WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var draft models.Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal draft: %w", err)
+	}
+	return &draft, nil
+}
+
+// ListDrafts retrieves the drafts of a service point
+func (cs *CockroachStorage) ListDrafts(ctx context.Context, servicePoint int64) ([]*models.Draft, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM drafts WHERE service_point = $1 ORDER BY id`, servicePoint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := make([]*models.Draft, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var draft models.Draft
+		if err := json.Unmarshal(data, &draft); err != nil {
+			continue
+		}
+		drafts = append(drafts, &draft)
+	}
+	return drafts, rows.Err()
+}
+
+// DeleteDraft removes a draft RAiD
+func (cs *CockroachStorage) DeleteDraft(ctx context.Context, id string) error {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM drafts WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// Verify CockroachStorage can keep drafts
+var _ storage.DraftStore = (*CockroachStorage)(nil)
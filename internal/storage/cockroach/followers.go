@@ -0,0 +1,61 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// SaveFollower stores a follower
+func (cs *CockroachStorage) SaveFollower(ctx context.Context, follower *models.Follower) error {
+	data, err := json.Marshal(follower)
+	if err != nil {
+		return fmt.Errorf("failed to marshal follower: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx, `UPSERT INTO followers (id, data) VALUES ($1, $2)`, follower.ID, data)
+	return err
+}
+
+// ListFollowers retrieves all followers
+func (cs *CockroachStorage) ListFollowers(ctx context.Context) ([]*models.Follower, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM followers ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	followers := make([]*models.Follower, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var follower models.Follower
+		if err := json.Unmarshal(data, &follower); err != nil {
+			continue
+		}
+		followers = append(followers, &follower)
+	}
+	return followers, rows.Err()
+}
+
+// DeleteFollower removes a follower
+func (cs *CockroachStorage) DeleteFollower(ctx context.Context, id string) error {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM followers WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// Verify CockroachStorage can hold followers
+var _ storage.FollowerStore = (*CockroachStorage)(nil)
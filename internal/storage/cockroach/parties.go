@@ -0,0 +1,100 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Kinds of party recorded in raid_parties
+const (
+	partyContributor  = "contributor"
+	partyOrganisation = "organisation"
+)
+
+// filterSchema adds the columns and side table the list filters read.
+// access_type and owner_service_point are computed from the document by
+// the database, so every writer keeps them current; raid_parties holds the
+// contributor and organisation IDs of current versions, which cannot be
+// computed columns as they are arrays of objects.
+var filterSchema = []string{
+	`ALTER TABLE raids ADD COLUMN IF NOT EXISTS access_type STRING AS (data->'access'->'type'->>'id') STORED`,
+	`ALTER TABLE raids ADD COLUMN IF NOT EXISTS owner_service_point INT8 AS ((data->'identifier'->'owner'->>'servicePoint')::INT8) STORED`,
+	`CREATE INDEX IF NOT EXISTS raids_access_idx ON raids (access_type, created_at, prefix, suffix) WHERE is_current = true AND is_deleted = false`,
+	`CREATE INDEX IF NOT EXISTS raids_owner_idx ON raids (owner_service_point, created_at, prefix, suffix) WHERE is_current = true`,
+	`CREATE TABLE IF NOT EXISTS raid_parties (
+		kind STRING NOT NULL,
+		party_id STRING NOT NULL,
+		prefix STRING NOT NULL,
+		suffix STRING NOT NULL,
+		PRIMARY KEY (kind, party_id, prefix, suffix),
+		INDEX raid_parties_raid_idx (prefix, suffix)
+	)`,
+}
+
+// setParties replaces the raid_parties rows of a RAiD with the contributors
+// and organisations of raid, its new current version
+func setParties(ctx context.Context, tx *sql.Tx, prefix, suffix string, raid *models.RAiD) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM raid_parties WHERE prefix = $1 AND suffix = $2`, prefix, suffix); err != nil {
+		return fmt.Errorf("failed to clear RAiD parties: %w", err)
+	}
+
+	insert := func(kind, id string) error {
+		if id == "" {
+			return nil
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO raid_parties (kind, party_id, prefix, suffix) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+			kind, id, prefix, suffix,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record RAiD parties: %w", err)
+		}
+		return nil
+	}
+	for _, c := range raid.Contributor {
+		if err := insert(partyContributor, c.ID); err != nil {
+			return err
+		}
+	}
+	for _, o := range raid.Organisation {
+		if err := insert(partyOrganisation, o.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillParties fills raid_parties from the current versions when it is
+// empty, such as when it has just been added to an existing database
+func (cs *CockroachStorage) backfillParties(ctx context.Context) error {
+	var filled bool
+	if err := cs.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM raid_parties)`).Scan(&filled); err != nil {
+		return err
+	}
+	if filled {
+		return nil
+	}
+
+	for _, kind := range []string{partyContributor, partyOrganisation} {
+		_, err := cs.db.ExecContext(ctx,
+			`INSERT INTO raid_parties (kind, party_id, prefix, suffix)
+			 SELECT DISTINCT $1, p->>'id', prefix, suffix
+			 FROM raids, jsonb_array_elements(
+			   CASE WHEN jsonb_typeof(data->$1) = 'array' THEN data->$1 ELSE '[]'::JSONB END
+			 ) AS p
+			 WHERE is_current = true AND p->>'id' IS NOT NULL AND p->>'id' != ''
+			 ON CONFLICT DO NOTHING`,
+			kind,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to backfill RAiD %s index: %w", kind, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// SaveProposal stores a proposal
+func (cs *CockroachStorage) SaveProposal(ctx context.Context, proposal *models.Proposal) error {
+	data, err := json.Marshal(proposal)
+	if err != nil {
+		return fmt.Errorf("failed to marshal proposal: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx,
+		`UPSERT INTO proposals (id, service_point, data) VALUES ($1, $2, $3)`,
+		proposal.ID, proposal.ServicePoint, data,
+	)
+	return err
+}
+
+// GetProposal retrieves a proposal
+func (cs *CockroachStorage) GetProposal(ctx context.Context, id string) (*models.Proposal, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx, `SELECT data FROM proposals WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var proposal models.Proposal
+	if err := json.Unmarshal(data, &proposal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal: %w", err)
+	}
+	return &proposal, nil
+}
+
+// ListProposals retrieves the proposals for the RAiDs of a service point
+func (cs *CockroachStorage) ListProposals(ctx context.Context, servicePoint int64) ([]*models.Proposal, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM proposals WHERE service_point = $1 ORDER BY id`, servicePoint)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	proposals := make([]*models.Proposal, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var proposal models.Proposal
+		if err := json.Unmarshal(data, &proposal); err != nil {
+			continue
+		}
+		proposals = append(proposals, &proposal)
+	}
+	return proposals, rows.Err()
+}
+
+// DeleteProposal removes a proposal
+func (cs *CockroachStorage) DeleteProposal(ctx context.Context, id string) error {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM proposals WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// Verify CockroachStorage can hold proposals
+var _ storage.ProposalStore = (*CockroachStorage)(nil)
@@ -0,0 +1,118 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// purgeSchema adds when each RAiD was soft deleted, which the retention
+// window is measured from, and the record of purges. RAiDs deleted before
+// deleted_at was added have none.
+var purgeSchema = []string{
+	`ALTER TABLE raids ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+	`CREATE TABLE IF NOT EXISTS purges (
+		prefix TEXT NOT NULL,
+		suffix TEXT NOT NULL,
+		purged_at TIMESTAMPTZ NOT NULL,
+		data JSONB NOT NULL,
+		PRIMARY KEY (prefix, suffix, purged_at),
+		INDEX purges_purged_idx (purged_at)
+	)`,
+}
+
+// PurgeRAiD removes every version of a soft deleted RAiD and its side rows,
+// recording the purge in the same transaction
+func (cs *CockroachStorage) PurgeRAiD(ctx context.Context, purge *models.Purge) error {
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var deleted bool
+	var deletedAt sql.NullTime
+	err = tx.QueryRowContext(ctx,
+		`SELECT is_deleted, deleted_at FROM raids WHERE prefix = $1 AND suffix = $2 AND is_current = true FOR UPDATE`,
+		purge.Prefix, purge.Suffix,
+	).Scan(&deleted, &deletedAt)
+	if err == sql.ErrNoRows {
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return storage.ErrNotDeleted
+	}
+	purge.Deleted = deletedAt.Time
+
+	for _, table := range []string{"raids", "raid_parties", "raid_terms", "raid_tags", "attachments"} {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE prefix = $1 AND suffix = $2`, purge.Prefix, purge.Suffix); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", table, err)
+		}
+	}
+
+	data, err := json.Marshal(purge)
+	if err != nil {
+		return fmt.Errorf("failed to marshal purge: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO purges (prefix, suffix, purged_at, data) VALUES ($1, $2, $3, $4)`,
+		purge.Prefix, purge.Suffix, purge.Purged, data,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record purge: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// ListPurges returns the recorded purges, oldest first
+func (cs *CockroachStorage) ListPurges(ctx context.Context) ([]*models.Purge, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM purges ORDER BY purged_at, prefix, suffix`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	purges := make([]*models.Purge, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var purge models.Purge
+		if err := json.Unmarshal(data, &purge); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal purge: %w", err)
+		}
+		purges = append(purges, &purge)
+	}
+	return purges, rows.Err()
+}
+
+// ImportPurge records a purge made elsewhere, removing nothing
+func (cs *CockroachStorage) ImportPurge(ctx context.Context, purge *models.Purge) error {
+	data, err := json.Marshal(purge)
+	if err != nil {
+		return fmt.Errorf("failed to marshal purge: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx,
+		`UPSERT INTO purges (prefix, suffix, purged_at, data) VALUES ($1, $2, $3, $4)`,
+		purge.Prefix, purge.Suffix, purge.Purged, data,
+	)
+	return err
+}
+
+// Verify CockroachStorage can purge RAiDs and import audit records
+var (
+	_ storage.Purger        = (*CockroachStorage)(nil)
+	_ storage.AuditImporter = (*CockroachStorage)(nil)
+)
@@ -0,0 +1,147 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// redactionSchema adds the record of redactions
+const redactionSchema = `CREATE TABLE IF NOT EXISTS redactions (
+	prefix TEXT NOT NULL,
+	suffix TEXT NOT NULL,
+	redacted_at TIMESTAMPTZ NOT NULL,
+	data JSONB NOT NULL,
+	PRIMARY KEY (prefix, suffix, redacted_at),
+	INDEX redactions_redacted_idx (redacted_at)
+)`
+
+// RedactRAiD rewrites the version rows of a RAiD redact changes, with the
+// archived rows, and the diffs of every row, recording the redaction in
+// the same transaction
+func (cs *CockroachStorage) RedactRAiD(ctx context.Context, prefix, suffix string, redact func(*models.RAiD) bool, redaction *models.Redaction) (int, error) {
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stored, err := storedVersions(ctx, tx, prefix, suffix, true)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.QueryContext(ctx,
+		`SELECT diff FROM raids WHERE prefix = $1 AND suffix = $2 AND diff IS NOT NULL ORDER BY version`,
+		prefix, suffix,
+	)
+	if err != nil {
+		return 0, err
+	}
+	var changes []storage.VersionChange
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		var change storage.VersionChange
+		if json.Unmarshal(data, &change) == nil {
+			changes = append(changes, change)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	rewritten, recorded, n, err := storage.RedactVersions(stored, changes, redact)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	for _, v := range rewritten {
+		_, err := tx.ExecContext(ctx,
+			`UPDATE raids SET data = $4 WHERE prefix = $1 AND suffix = $2 AND version = $3`,
+			prefix, suffix, v.Version, v.Data,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+	for i := range recorded {
+		diff, err := marshalChange(&recorded[i])
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx,
+			`UPDATE raids SET diff = $4 WHERE prefix = $1 AND suffix = $2 AND version = $3`,
+			prefix, suffix, recorded[i].Version, diff,
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	redaction.Versions = n
+	data, err := json.Marshal(redaction)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal redaction: %w", err)
+	}
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO redactions (prefix, suffix, redacted_at, data) VALUES ($1, $2, $3, $4)`,
+		prefix, suffix, redaction.Redacted, data,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to record redaction: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ListRedactions returns the recorded redactions, oldest first
+func (cs *CockroachStorage) ListRedactions(ctx context.Context) ([]*models.Redaction, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM redactions ORDER BY redacted_at, prefix, suffix`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	redactions := make([]*models.Redaction, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var redaction models.Redaction
+		if err := json.Unmarshal(data, &redaction); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal redaction: %w", err)
+		}
+		redactions = append(redactions, &redaction)
+	}
+	return redactions, rows.Err()
+}
+
+// ImportRedaction records a redaction made elsewhere, rewriting no version
+func (cs *CockroachStorage) ImportRedaction(ctx context.Context, redaction *models.Redaction) error {
+	data, err := json.Marshal(redaction)
+	if err != nil {
+		return fmt.Errorf("failed to marshal redaction: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx,
+		`UPSERT INTO redactions (prefix, suffix, redacted_at, data) VALUES ($1, $2, $3, $4)`,
+		redaction.Prefix, redaction.Suffix, redaction.Redacted, data,
+	)
+	return err
+}
+
+// Verify CockroachStorage can redact RAiDs
+var _ storage.Redactor = (*CockroachStorage)(nil)
@@ -0,0 +1,170 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/lib/pq"
+)
+
+// A multi-region deployment is one logical registry whose rows live near
+// the service points that own them. The database gets the configured
+// regions, the first being primary; raids are regional by row, homed in
+// the region of the owning service point; service points, read on every
+// mint, are global. Instances minting in a region draw suffixes from a
+// block leased to that region, so most mints touch only local rows.
+
+// counterBlock is the number of suffixes leased to a region at a time.
+// Suffixes stay unique across regions but are not issued in mint order.
+const counterBlock = 100
+
+// initRegions makes the database multi-region and sets table localities.
+// Every statement is idempotent, so all instances may run them.
+func (cs *CockroachStorage) initRegions(database string) error {
+	if len(cs.regions) == 0 {
+		return nil
+	}
+	db := pq.QuoteIdentifier(database)
+	stmts := []string{
+		fmt.Sprintf(`ALTER DATABASE %s SET PRIMARY REGION %s`, db, pq.QuoteIdentifier(cs.regions[0])),
+	}
+	for _, region := range cs.regions[1:] {
+		stmts = append(stmts, fmt.Sprintf(`ALTER DATABASE %s ADD REGION IF NOT EXISTS %s`, db, pq.QuoteIdentifier(region)))
+	}
+	stmts = append(stmts,
+		`ALTER TABLE raids SET LOCALITY REGIONAL BY ROW`,
+		`ALTER TABLE service_points SET LOCALITY GLOBAL`,
+		// Suffix blocks leased to regions, named by counter and region
+		`CREATE TABLE IF NOT EXISTS id_blocks (
+			name TEXT PRIMARY KEY,
+			next INT8 NOT NULL,
+			last INT8 NOT NULL
+		) LOCALITY REGIONAL BY ROW`,
+	)
+	for _, stmt := range stmts {
+		if _, err := cs.db.Exec(stmt); err != nil {
+			return fmt.Errorf("%s: %w", stmt, err)
+		}
+	}
+	return nil
+}
+
+// hasRegion reports whether region is one of the database's regions
+func (cs *CockroachStorage) hasRegion(region string) bool {
+	for _, r := range cs.regions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// homeRegion returns the region the rows of raid are homed in: that of
+// its owning service point if it is one of the database's, otherwise the
+// instance's. It is empty when the database is not multi-region, or when
+// rows are left to the region of the node they are written through.
+func (cs *CockroachStorage) homeRegion(ctx context.Context, raid *models.RAiD) string {
+	if len(cs.regions) == 0 {
+		return ""
+	}
+	if id := raid.Identifier; id != nil && id.Owner != nil && id.Owner.ServicePoint > 0 {
+		sp, err := cs.servicePoints.Get(ctx, id.Owner.ServicePoint, cs.GetServicePoint)
+		if err == nil && cs.hasRegion(sp.Region) {
+			return sp.Region
+		}
+	}
+	return cs.region
+}
+
+// insertRAiD inserts a version of a RAiD, homed in region unless empty
+func insertRAiD(ctx context.Context, tx *sql.Tx, region, prefix, suffix string, version int, data, change []byte, created, updated time.Time) error {
+	if region == "" {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO raids (prefix, suffix, version, is_current, data, diff, created_at, updated_at)
+			 VALUES ($1, $2, $3, true, $4, $5, $6, $7)`,
+			prefix, suffix, version, data, change, created, updated,
+		)
+		return err
+	}
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO raids (prefix, suffix, version, is_current, data, diff, created_at, updated_at, crdb_region)
+		 VALUES ($1, $2, $3, true, $4, $5, $6, $7, $8::crdb_internal_region)`,
+		prefix, suffix, version, data, change, created, updated, region,
+	)
+	return err
+}
+
+// regionalCounterName returns the id_blocks row of counter in region
+func regionalCounterName(counter, region string) string {
+	return counter + "@" + region
+}
+
+// nextRegional issues the next suffix of counter from the block leased to
+// the instance's region, leasing the next block from the global counter
+// when it is used up
+func (cs *CockroachStorage) nextRegional(ctx context.Context, tx *sql.Tx, counter string) (int64, error) {
+	name := regionalCounterName(counter, cs.region)
+
+	var next, last int64
+	err := tx.QueryRowContext(ctx,
+		`SELECT next, last FROM id_blocks WHERE name = $1 FOR UPDATE`,
+		name,
+	).Scan(&next, &last)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	if err == sql.ErrNoRows || next > last {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO id_counters (name, value) VALUES ($1, 1) ON CONFLICT (name) DO NOTHING`,
+			counter,
+		)
+		if err != nil {
+			return 0, err
+		}
+		err = tx.QueryRowContext(ctx,
+			`UPDATE id_counters SET value = value + $2 WHERE name = $1 RETURNING value`,
+			counter, counterBlock,
+		).Scan(&last)
+		if err != nil {
+			return 0, err
+		}
+		next = last - counterBlock + 1
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPSERT INTO id_blocks (name, next, last, crdb_region) VALUES ($1, $2, $3, $4::crdb_internal_region)`,
+		name, next+1, last, cs.region,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// dropLeases discards the blocks leased to regions from counter, so that
+// suffixes are leased afresh above a raised counter
+func (cs *CockroachStorage) dropLeases(ctx context.Context, counter string) error {
+	if len(cs.regions) == 0 {
+		return nil
+	}
+	_, err := cs.db.ExecContext(ctx,
+		`DELETE FROM id_blocks WHERE name LIKE $1`,
+		likePrefix(regionalCounterName(counter, "")),
+	)
+	return err
+}
+
+// gatewayRegion returns the region of the node the instance is connected
+// through, which writes without a home region default to
+func (cs *CockroachStorage) gatewayRegion(ctx context.Context) (string, error) {
+	var region string
+	err := cs.db.QueryRowContext(ctx, `SELECT gateway_region()`).Scan(&region)
+	return region, err
+}
@@ -0,0 +1,87 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// SaveReservation stores a reservation unless its handle is minted or
+// held by a live reservation. An expired reservation is replaced in the
+// same statement.
+func (cs *CockroachStorage) SaveReservation(ctx context.Context, reservation *models.Reservation) error {
+	data, err := json.Marshal(reservation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal reservation: %w", err)
+	}
+	result, err := cs.db.ExecContext(ctx, `
+		INSERT INTO reservations (prefix, suffix, service_point, expires_at, data)
+		SELECT $1, $2, $3, $4, $5
+		WHERE NOT EXISTS (SELECT 1 FROM raids WHERE prefix = $1 AND suffix = $2)
+		ON CONFLICT (prefix, suffix) DO UPDATE
+		SET service_point = excluded.service_point, expires_at = excluded.expires_at, data = excluded.data
+		WHERE reservations.expires_at <= now()`,
+		reservation.Prefix, reservation.Suffix, reservation.ServicePoint, reservation.ExpiresAt, data,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrAlreadyExists
+	}
+	return nil
+}
+
+// GetReservation retrieves the live reservation of a handle
+func (cs *CockroachStorage) GetReservation(ctx context.Context, prefix, suffix string) (*models.Reservation, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx,
+		`SELECT data FROM reservations WHERE prefix = $1 AND suffix = $2 AND expires_at > now()`,
+		prefix, suffix,
+	).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var reservation models.Reservation
+	if err := json.Unmarshal(data, &reservation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reservation: %w", err)
+	}
+	return &reservation, nil
+}
+
+// DeleteReservation releases the reservation of a handle
+func (cs *CockroachStorage) DeleteReservation(ctx context.Context, prefix, suffix string) error {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM reservations WHERE prefix = $1 AND suffix = $2`, prefix, suffix)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations removes the reservations expired by at
+func (cs *CockroachStorage) ReleaseExpiredReservations(ctx context.Context, at time.Time) (int, error) {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM reservations WHERE expires_at <= $1`, at)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+// Verify CockroachStorage can hold reservations
+var _ storage.ReservationStore = (*CockroachStorage)(nil)
@@ -0,0 +1,106 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// pruneBatchSize bounds the rows one retention statement removes, so that
+// pruning a large table does not run one long transaction
+const pruneBatchSize = 10000
+
+// prune removes the rows of table matching where in batches, or with
+// dryRun counts them
+func (cs *CockroachStorage) prune(ctx context.Context, table, where string, dryRun bool, args ...interface{}) (int, error) {
+	if dryRun {
+		var n int
+		err := cs.db.QueryRowContext(ctx, `SELECT count(*) FROM `+table+` WHERE `+where, args...).Scan(&n)
+		return n, err
+	}
+
+	pruned := 0
+	for {
+		result, err := cs.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE `+where+fmt.Sprintf(` LIMIT %d`, pruneBatchSize), args...)
+		if err != nil {
+			return pruned, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return pruned, err
+		}
+		pruned += int(n)
+		if n < pruneBatchSize {
+			return pruned, nil
+		}
+	}
+}
+
+// pruneByServicePoint removes the rows of table older than the cutoff of
+// their service point, one statement per service point with a cutoff and
+// one for the default. column is the timestamp column and servicePoint
+// the expression giving the service point of a row.
+func (cs *CockroachStorage) pruneByServicePoint(ctx context.Context, table, column, servicePoint string, cutoffs storage.Cutoffs, dryRun bool) (int, error) {
+	pruned := 0
+	exclude := make([]string, 0, len(cutoffs.ServicePoints))
+	args := make([]interface{}, 1, len(cutoffs.ServicePoints)+1)
+	for sp, cutoff := range cutoffs.ServicePoints {
+		exclude = append(exclude, fmt.Sprintf("$%d", len(args)+1))
+		args = append(args, sp)
+		if cutoff.IsZero() {
+			continue
+		}
+		n, err := cs.prune(ctx, table, column+` < $1 AND `+servicePoint+` = $2`, dryRun, cutoff, sp)
+		pruned += n
+		if err != nil {
+			return pruned, err
+		}
+	}
+	if cutoffs.Default.IsZero() {
+		return pruned, nil
+	}
+
+	where := column + ` < $1`
+	if len(exclude) > 0 {
+		where += ` AND ` + servicePoint + ` NOT IN (` + strings.Join(exclude, ", ") + `)`
+	}
+	args[0] = cutoffs.Default
+	n, err := cs.prune(ctx, table, where, dryRun, args...)
+	return pruned + n, err
+}
+
+// PruneAccessLog removes the access log rows logged before the cutoff of
+// their service point
+func (cs *CockroachStorage) PruneAccessLog(ctx context.Context, cutoffs storage.Cutoffs, dryRun bool) (int, error) {
+	return cs.pruneByServicePoint(ctx, "access_log", "ts", `COALESCE((data->>'servicePoint')::INT8, 0)`, cutoffs, dryRun)
+}
+
+// PruneReservations removes the reservations made before the cutoff of
+// their service point
+func (cs *CockroachStorage) PruneReservations(ctx context.Context, cutoffs storage.Cutoffs, dryRun bool) (int, error) {
+	return cs.pruneByServicePoint(ctx, "reservations", `(data->>'created')::TIMESTAMPTZ`, "service_point", cutoffs, dryRun)
+}
+
+// PruneAudit removes the purges and redactions recorded before before
+func (cs *CockroachStorage) PruneAudit(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	purges, err := cs.prune(ctx, "purges", `purged_at < $1`, dryRun, before)
+	if err != nil {
+		return purges, err
+	}
+	redactions, err := cs.prune(ctx, "redactions", `redacted_at < $1`, dryRun, before)
+	return purges + redactions, err
+}
+
+// Verify CockroachStorage can prune access logs, reservations and audit
+// records
+var (
+	_ storage.AccessLogPruner   = (*CockroachStorage)(nil)
+	_ storage.ReservationPruner = (*CockroachStorage)(nil)
+	_ storage.AuditPruner       = (*CockroachStorage)(nil)
+)
@@ -0,0 +1,96 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// SaveScheduledChange stores a scheduled change
+func (cs *CockroachStorage) SaveScheduledChange(ctx context.Context, change *models.ScheduledChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled change: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx,
+		`UPSERT INTO scheduled_changes (id, service_point, effective_at, status, data) VALUES ($1, $2, $3, $4, $5)`,
+		change.ID, change.ServicePoint, change.EffectiveAt, change.Status, data,
+	)
+	return err
+}
+
+// GetScheduledChange retrieves a scheduled change
+func (cs *CockroachStorage) GetScheduledChange(ctx context.Context, id string) (*models.ScheduledChange, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx, `SELECT data FROM scheduled_changes WHERE id = $1`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var change models.ScheduledChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scheduled change: %w", err)
+	}
+	return &change, nil
+}
+
+// ListScheduledChanges retrieves the scheduled changes of a service point
+func (cs *CockroachStorage) ListScheduledChanges(ctx context.Context, servicePoint int64) ([]*models.ScheduledChange, error) {
+	return cs.queryScheduledChanges(ctx, `SELECT data FROM scheduled_changes WHERE service_point = $1 ORDER BY id`, servicePoint)
+}
+
+// DueChanges retrieves the pending changes effective by at
+func (cs *CockroachStorage) DueChanges(ctx context.Context, at time.Time) ([]*models.ScheduledChange, error) {
+	return cs.queryScheduledChanges(ctx,
+		`SELECT data FROM scheduled_changes WHERE status = $1 AND effective_at <= $2 ORDER BY effective_at, id`,
+		models.SchedulePending, at,
+	)
+}
+
+func (cs *CockroachStorage) queryScheduledChanges(ctx context.Context, query string, args ...any) ([]*models.ScheduledChange, error) {
+	rows, err := cs.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make([]*models.ScheduledChange, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var change models.ScheduledChange
+		if err := json.Unmarshal(data, &change); err != nil {
+			continue
+		}
+		changes = append(changes, &change)
+	}
+	return changes, rows.Err()
+}
+
+// DeleteScheduledChange removes a scheduled change
+func (cs *CockroachStorage) DeleteScheduledChange(ctx context.Context, id string) error {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM scheduled_changes WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// Verify CockroachStorage can hold scheduled changes
+var _ storage.ScheduleStore = (*CockroachStorage)(nil)
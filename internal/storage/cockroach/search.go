@@ -0,0 +1,92 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// SaveSearch stores a saved search
+func (cs *CockroachStorage) SaveSearch(ctx context.Context, search *models.SavedSearch) error {
+	data, err := json.Marshal(search)
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved search: %w", err)
+	}
+	_, err = cs.db.ExecContext(ctx,
+		`UPSERT INTO saved_searches (owner, name, feed, data) VALUES ($1, $2, $3, $4)`,
+		search.Owner, search.Name, nullString(search.Feed), data,
+	)
+	return err
+}
+
+// GetSearch retrieves a saved search
+func (cs *CockroachStorage) GetSearch(ctx context.Context, owner, name string) (*models.SavedSearch, error) {
+	return cs.getSearch(ctx, `SELECT data FROM saved_searches WHERE owner = $1 AND name = $2`, owner, name)
+}
+
+// GetSearchByFeed retrieves the saved search published under feed
+func (cs *CockroachStorage) GetSearchByFeed(ctx context.Context, feed string) (*models.SavedSearch, error) {
+	return cs.getSearch(ctx, `SELECT data FROM saved_searches WHERE feed = $1`, feed)
+}
+
+func (cs *CockroachStorage) getSearch(ctx context.Context, query string, args ...interface{}) (*models.SavedSearch, error) {
+	var data []byte
+	err := cs.db.QueryRowContext(ctx, query, args...).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, storage.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var search models.SavedSearch
+	if err := json.Unmarshal(data, &search); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal saved search: %w", err)
+	}
+	return &search, nil
+}
+
+// ListSearches retrieves the saved searches of owner
+func (cs *CockroachStorage) ListSearches(ctx context.Context, owner string) ([]*models.SavedSearch, error) {
+	rows, err := cs.db.QueryContext(ctx, `SELECT data FROM saved_searches WHERE owner = $1 ORDER BY name`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	searches := make([]*models.SavedSearch, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var search models.SavedSearch
+		if err := json.Unmarshal(data, &search); err != nil {
+			continue
+		}
+		searches = append(searches, &search)
+	}
+	return searches, rows.Err()
+}
+
+// DeleteSearch removes a saved search
+func (cs *CockroachStorage) DeleteSearch(ctx context.Context, owner, name string) error {
+	result, err := cs.db.ExecContext(ctx, `DELETE FROM saved_searches WHERE owner = $1 AND name = $2`, owner, name)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return storage.ErrNotFound
+	}
+	return nil
+}
+
+// Verify CockroachStorage can keep saved searches
+var _ storage.SearchStore = (*CockroachStorage)(nil)
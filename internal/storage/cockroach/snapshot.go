@@ -0,0 +1,264 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// ExportRAiDs streams every RAiD, including deleted ones, with all versions
+func (cs *CockroachStorage) ExportRAiDs(ctx context.Context, fn func(*storage.RAiDRecord) error) error {
+	rows, err := cs.db.QueryContext(ctx,
+		`SELECT prefix, suffix, version, is_current, is_deleted, data, COALESCE(diff->>'actor', '') FROM raids ORDER BY prefix, suffix, version`,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Versions are collected as stored and decoded once the RAiD is
+	// complete, so that archived versions can be rehydrated
+	var stored []storage.StoredVersion
+	var deleted bool
+	var actors map[int]string
+	var lastPrefix, lastSuffix string
+	flush := func() error {
+		if stored == nil {
+			return nil
+		}
+		full, err := storage.RehydrateVersions(stored)
+		if err != nil {
+			return fmt.Errorf("failed to rehydrate RAiD %s/%s: %w", lastPrefix, lastSuffix, err)
+		}
+		record := &storage.RAiDRecord{Deleted: deleted, Actors: actors}
+		for _, v := range full {
+			var raid models.RAiD
+			if err := json.Unmarshal(v.Data, &raid); err != nil {
+				return fmt.Errorf("failed to unmarshal RAiD %s/%s: %w", lastPrefix, lastSuffix, err)
+			}
+			record.Versions = append(record.Versions, &raid)
+		}
+		stored, deleted, actors = nil, false, nil
+		return fn(record)
+	}
+
+	for rows.Next() {
+		var prefix, suffix string
+		var version int
+		var isCurrent, isDeleted bool
+		var data []byte
+		var actor string
+		if err := rows.Scan(&prefix, &suffix, &version, &isCurrent, &isDeleted, &data, &actor); err != nil {
+			return err
+		}
+
+		if prefix != lastPrefix || suffix != lastSuffix {
+			if err := flush(); err != nil {
+				return err
+			}
+			lastPrefix, lastSuffix = prefix, suffix
+		}
+
+		stored = append(stored, storage.StoredVersion{Version: version, Data: data})
+		if actor != "" {
+			if actors == nil {
+				actors = make(map[int]string)
+			}
+			actors[version] = actor
+		}
+		if isCurrent && isDeleted {
+			deleted = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
+
+// ImportRAiD inserts all versions of a RAiD in one transaction
+func (cs *CockroachStorage) ImportRAiD(ctx context.Context, record *storage.RAiDRecord) error {
+	current := record.Current()
+	if current == nil || current.Identifier == nil {
+		return fmt.Errorf("RAiD record has no versions")
+	}
+
+	prefix, suffix, err := identifier.Parse(current.Identifier.ID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM raids WHERE prefix = $1 AND suffix = $2)`,
+		prefix, suffix,
+	).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return storage.ErrAlreadyExists
+	}
+
+	diffs, err := storage.DiffRecord(record)
+	if err != nil {
+		return err
+	}
+	for i, version := range record.Versions {
+		data, err := json.Marshal(version)
+		if err != nil {
+			return fmt.Errorf("failed to marshal RAiD: %w", err)
+		}
+		change, err := marshalChange(diffs[version.Identifier.Version])
+		if err != nil {
+			return err
+		}
+
+		createdAt, updatedAt := time.Now(), time.Now()
+		if version.Metadata != nil {
+			if !version.Metadata.Created.IsZero() {
+				createdAt = version.Metadata.Created
+			}
+			if !version.Metadata.Updated.IsZero() {
+				updatedAt = version.Metadata.Updated
+			}
+		}
+
+		isCurrent := i == len(record.Versions)-1
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO raids (prefix, suffix, version, is_current, is_deleted, data, diff, created_at, updated_at)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+			prefix, suffix, version.Identifier.Version, isCurrent, isCurrent && record.Deleted, data, change, createdAt, updatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert RAiD version %d: %w", version.Identifier.Version, err)
+		}
+		if isCurrent {
+			if err := setParties(ctx, tx, prefix, suffix, version); err != nil {
+				return err
+			}
+			if err := setTerms(ctx, tx, prefix, suffix, version); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ImportServicePoint inserts a service point keeping its ID
+func (cs *CockroachStorage) ImportServicePoint(ctx context.Context, sp *models.ServicePoint) error {
+	data, err := json.Marshal(sp)
+	if err != nil {
+		return fmt.Errorf("failed to marshal service point: %w", err)
+	}
+
+	result, err := cs.db.ExecContext(ctx,
+		`INSERT INTO service_points (id, data, created_at, updated_at)
+		 VALUES ($1, $2, NOW(), NOW())
+		 ON CONFLICT (id) DO NOTHING`,
+		sp.ID, data,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert service point: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return storage.ErrAlreadyExists
+	}
+	return nil
+}
+
+// Counters returns the per-prefix RAiD suffix counters. Service point IDs
+// come from unique_rowid() and have no counter to carry over.
+func (cs *CockroachStorage) Counters(ctx context.Context) (map[string]int64, error) {
+	// Counter rows are keyed by a mangled prefix, so map them back through
+	// the prefixes actually in use
+	rows, err := cs.db.QueryContext(ctx, `SELECT DISTINCT prefix FROM raids`)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string)
+	for rows.Next() {
+		var prefix string
+		if err := rows.Scan(&prefix); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names[raidCounterName(prefix)] = prefix
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = cs.db.QueryContext(ctx, `SELECT name, value FROM id_counters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counters := make(map[string]int64)
+	for rows.Next() {
+		var name string
+		var value int64
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		if prefix, ok := names[name]; ok {
+			counters[storage.CounterRAiDPrefix+prefix] = value
+		}
+	}
+
+	return counters, rows.Err()
+}
+
+// SetCounters raises the per-prefix RAiD suffix counters
+func (cs *CockroachStorage) SetCounters(ctx context.Context, counters map[string]int64) error {
+	for name, value := range counters {
+		prefix, ok := strings.CutPrefix(name, storage.CounterRAiDPrefix)
+		if !ok {
+			continue
+		}
+		_, err := cs.db.ExecContext(ctx,
+			`INSERT INTO id_counters (name, value) VALUES ($1, $2)
+			 ON CONFLICT (name) DO UPDATE SET value = greatest(id_counters.value, excluded.value)`,
+			raidCounterName(prefix), value,
+		)
+		if err != nil {
+			return err
+		}
+		if err := cs.dropLeases(ctx, raidCounterName(prefix)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// raidCounterName returns the id_counters row used for a prefix
+func raidCounterName(prefix string) string {
+	return fmt.Sprintf("raid_%s", strings.ReplaceAll(prefix, ".", "_"))
+}
+
+// Verify CockroachStorage supports snapshots
+var _ storage.Snapshotter = (*CockroachStorage)(nil)
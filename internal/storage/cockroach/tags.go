@@ -0,0 +1,99 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// GetTags returns the tags a service point gave a RAiD
+func (cs *CockroachStorage) GetTags(ctx context.Context, servicePoint int64, prefix, suffix string) ([]string, error) {
+	return getTags(ctx, cs.db, servicePoint, prefix, suffix)
+}
+
+func getTags(ctx context.Context, db queryer, servicePoint int64, prefix, suffix string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		`SELECT tag FROM raid_tags WHERE service_point = $1 AND prefix = $2 AND suffix = $3 ORDER BY tag`,
+		servicePoint, prefix, suffix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]string, 0)
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// AddTags adds tags a service point gives a RAiD
+func (cs *CockroachStorage) AddTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error) {
+	return cs.updateTags(ctx, servicePoint, prefix, suffix, tags,
+		`INSERT INTO raid_tags (service_point, tag, prefix, suffix) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`)
+}
+
+// RemoveTags removes tags a service point gave a RAiD
+func (cs *CockroachStorage) RemoveTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error) {
+	return cs.updateTags(ctx, servicePoint, prefix, suffix, tags,
+		`DELETE FROM raid_tags WHERE service_point = $1 AND tag = $2 AND prefix = $3 AND suffix = $4`)
+}
+
+// updateTags runs stmt for each of tags and returns the tags of the RAiD
+// afterwards, in one transaction
+func (cs *CockroachStorage) updateTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string, stmt string) ([]string, error) {
+	tx, err := cs.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, stmt, servicePoint, tag, prefix, suffix); err != nil {
+			return nil, err
+		}
+	}
+	current, err := getTags(ctx, tx, servicePoint, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	return current, tx.Commit()
+}
+
+// TaggedRAiDs returns the RAiDs a service point gave tag
+func (cs *CockroachStorage) TaggedRAiDs(ctx context.Context, servicePoint int64, tag string) ([]storage.IdentifierRef, error) {
+	rows, err := cs.db.QueryContext(ctx,
+		`SELECT prefix, suffix FROM raid_tags WHERE service_point = $1 AND tag = $2`,
+		servicePoint, tag,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refs := make([]storage.IdentifierRef, 0)
+	for rows.Next() {
+		var ref storage.IdentifierRef
+		if err := rows.Scan(&ref.Prefix, &ref.Suffix); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// Verify CockroachStorage can keep tags
+var _ storage.TagStore = (*CockroachStorage)(nil)
@@ -0,0 +1,84 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/textnorm"
+)
+
+// termSchema adds raid_terms, the titles and subject keywords of current
+// versions folded by textnorm.Fold, which the text and keyword filters
+// match. Folding has no SQL equivalent, so the terms are written next to
+// each version rather than computed by the database.
+const termSchema = `CREATE TABLE IF NOT EXISTS raid_terms (
+	prefix STRING NOT NULL,
+	suffix STRING NOT NULL,
+	kind STRING NOT NULL,
+	scope STRING NOT NULL,
+	term STRING NOT NULL,
+	PRIMARY KEY (prefix, suffix, kind, scope, term),
+	INDEX raid_terms_term_idx (kind, term)
+)`
+
+// execer is satisfied by *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// setTerms replaces the raid_terms rows of a RAiD with the folded titles
+// and keywords of raid, its new current version
+func setTerms(ctx context.Context, tx execer, prefix, suffix string, raid *models.RAiD) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM raid_terms WHERE prefix = $1 AND suffix = $2`, prefix, suffix); err != nil {
+		return fmt.Errorf("failed to clear RAiD terms: %w", err)
+	}
+	for _, t := range textnorm.Terms(raid) {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO raid_terms (prefix, suffix, kind, scope, term) VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`,
+			prefix, suffix, t.Kind, t.Scope, t.Text,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record RAiD terms: %w", err)
+		}
+	}
+	return nil
+}
+
+// backfillTerms fills raid_terms from the current versions when it is
+// empty, such as when it has just been added to an existing database
+func (cs *CockroachStorage) backfillTerms(ctx context.Context) error {
+	var filled bool
+	if err := cs.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM raid_terms)`).Scan(&filled); err != nil {
+		return err
+	}
+	if filled {
+		return nil
+	}
+
+	rows, err := cs.db.QueryContext(ctx, `SELECT prefix, suffix, data FROM raids WHERE is_current = true`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var prefix, suffix string
+		var data []byte
+		if err := rows.Scan(&prefix, &suffix, &data); err != nil {
+			return err
+		}
+		var raid models.RAiD
+		if err := json.Unmarshal(data, &raid); err != nil {
+			return fmt.Errorf("failed to backfill terms of RAiD %s/%s: %w", prefix, suffix, err)
+		}
+		if err := setTerms(ctx, cs.db, prefix, suffix, &raid); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,171 @@
+//go:build !noexternal
+// +build !noexternal
+
+package cockroach
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// verifyGroup tracks the rows of one RAiD while scanning
+type verifyGroup struct {
+	name       string
+	versions   []int
+	hasCurrent bool
+	// stored is kept for RAiDs with archived versions, to check that they
+	// rehydrate
+	stored   []storage.StoredVersion
+	archived bool
+}
+
+// Verify scans every RAiD row and service point. Repair is not supported;
+// problems are reported only.
+func (cs *CockroachStorage) Verify(ctx context.Context, opts storage.VerifyOptions) (*storage.VerifyReport, error) {
+	report := storage.NewVerifyReport()
+
+	rows, err := cs.db.QueryContext(ctx,
+		`SELECT prefix, suffix, version, is_current, data FROM raids ORDER BY prefix, suffix, version`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var group *verifyGroup
+	flush := func() {
+		if group == nil {
+			return
+		}
+		if !group.hasCurrent {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueOrphanedHistory,
+				Location: "raids/" + group.name,
+				RAiD:     group.name,
+				Message:  fmt.Sprintf("%d versions without a current row", len(group.versions)),
+			})
+			return
+		}
+		report.RAiDs++
+		if issue := storage.CheckVersionSequence("raids/"+group.name, group.name, group.versions); issue != nil {
+			report.Add(*issue)
+		}
+		if group.archived {
+			if _, err := storage.RehydrateVersions(group.stored); err != nil {
+				report.Add(storage.VerifyIssue{
+					Kind:     storage.IssueUnparseable,
+					Location: "raids/" + group.name,
+					RAiD:     group.name,
+					Message:  fmt.Sprintf("archived versions cannot be rehydrated: %v", err),
+				})
+			}
+		}
+	}
+
+	for rows.Next() {
+		var prefix, suffix string
+		var version int
+		var isCurrent bool
+		var data []byte
+		if err := rows.Scan(&prefix, &suffix, &version, &isCurrent, &data); err != nil {
+			return nil, err
+		}
+
+		name := prefix + "/" + suffix
+		if group == nil || group.name != name {
+			flush()
+			group = &verifyGroup{name: name}
+		}
+		group.versions = append(group.versions, version)
+		group.hasCurrent = group.hasCurrent || isCurrent
+		report.Versions++
+
+		location := fmt.Sprintf("raids/%s@%d", name, version)
+
+		group.stored = append(group.stored, storage.StoredVersion{Version: version, Data: data})
+		if storage.IsArchived(data) {
+			group.archived = true
+			if n, _ := storage.ArchivedVersionNumber(data); n != version {
+				report.Add(storage.VerifyIssue{
+					Kind:     storage.IssueIdentifierMismatch,
+					Location: location,
+					RAiD:     name,
+					Message:  fmt.Sprintf("archived version %d does not match row", n),
+				})
+			}
+			continue
+		}
+
+		var raid models.RAiD
+		if err := json.Unmarshal(data, &raid); err != nil {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: location, RAiD: name, Message: err.Error()})
+			continue
+		}
+		if raid.Identifier == nil {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueIdentifierMismatch, Location: location, RAiD: name, Message: "document has no identifier"})
+			continue
+		}
+		p, s, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil || p != prefix || s != suffix || raid.Identifier.Version != version {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: location,
+				RAiD:     name,
+				Message:  fmt.Sprintf("payload identifier %q version %d does not match row", raid.Identifier.ID, raid.Identifier.Version),
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	if err := cs.verifyServicePoints(ctx, report); err != nil {
+		return nil, err
+	}
+
+	return report.Finish(), nil
+}
+
+// verifyServicePoints checks service point rows
+func (cs *CockroachStorage) verifyServicePoints(ctx context.Context, report *storage.VerifyReport) error {
+	rows, err := cs.db.QueryContext(ctx, `SELECT id, data FROM service_points ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+
+		location := fmt.Sprintf("service_points/%d", id)
+
+		var sp models.ServicePoint
+		if err := json.Unmarshal(data, &sp); err != nil {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: location, Message: err.Error()})
+			continue
+		}
+		report.ServicePoints++
+
+		if sp.ID != id {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: location,
+				Message:  fmt.Sprintf("service point ID %d does not match row", sp.ID),
+			})
+		}
+	}
+	return rows.Err()
+}
+
+// Verify CockroachStorage can check its data
+var _ storage.Verifier = (*CockroachStorage)(nil)
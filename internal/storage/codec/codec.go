@@ -0,0 +1,63 @@
+// Package codec encodes the documents key-value backends store. Records
+// are JSON everywhere above the storage layer; a backend may keep them in a
+// more compact binary encoding at rest and converts at its boundary. Stored
+// values identify their own encoding, so a backend can read data written in
+// any encoding while it is being converted.
+package codec
+
+import (
+	"fmt"
+)
+
+// Format is a storage encoding
+type Format string
+
+const (
+	// JSON stores documents as they are
+	JSON Format = "json"
+	// MsgPack stores documents as MessagePack, around a tenth smaller than
+	// JSON for RAiD metadata
+	MsgPack Format = "msgpack"
+)
+
+// msgpackMarker starts MessagePack values. 0xc1 is never used by
+// MessagePack and cannot start a JSON document.
+const msgpackMarker = 0xc1
+
+// ParseFormat parses an encoding name; the empty name selects JSON
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case "", JSON:
+		return JSON, nil
+	case MsgPack:
+		return MsgPack, nil
+	}
+	return "", fmt.Errorf("unknown storage encoding %q (want json or msgpack)", name)
+}
+
+// Encode converts a JSON document into its stored form in format f
+func Encode(f Format, doc []byte) ([]byte, error) {
+	switch f {
+	case "", JSON:
+		return doc, nil
+	case MsgPack:
+		return encodeMsgPack(doc)
+	}
+	return nil, fmt.Errorf("unknown storage encoding %q", f)
+}
+
+// Decode converts a stored value in any format back into JSON
+func Decode(data []byte) ([]byte, error) {
+	if FormatOf(data) == MsgPack {
+		return decodeMsgPack(data[1:])
+	}
+	return data, nil
+}
+
+// FormatOf returns the format a stored value is encoded in
+func FormatOf(data []byte) Format {
+	if len(data) > 0 && data[0] == msgpackMarker {
+		return MsgPack
+	}
+	return JSON
+}
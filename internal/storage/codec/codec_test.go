@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage/testutil"
+)
+
+func TestMsgPack_RoundTrip(t *testing.T) {
+	docs := []string{
+		`{}`,
+		`[]`,
+		`null`,
+		`{"a":1,"b":-1,"c":-33,"d":200,"e":-200,"f":70000,"g":-70000,"h":5000000000,"i":18446744073709551615,"j":1.5,"k":-2.25e-10}`,
+		`{"z":"first","a":"second","m":[true,false,null,{"nested":[1,[2,[3]]]}]}`,
+		`{"s":"quote \" backslash \\ newline \n tab \t control \u0001 unicode åäö 🚀"}`,
+		`{"long":"` + strings.Repeat("x", 40) + `","longer":"` + strings.Repeat("y", 300) + `","longest":"` + strings.Repeat("z", 70000) + `"}`,
+		`[` + strings.TrimSuffix(strings.Repeat(`1,`, 20), ",") + `]`,
+	}
+	for _, doc := range docs {
+		stored, err := Encode(MsgPack, []byte(doc))
+		if err != nil {
+			t.Fatalf("Encode(%.40s): %v", doc, err)
+		}
+		if FormatOf(stored) != MsgPack {
+			t.Fatalf("Encode(%.40s) is not marked as MessagePack", doc)
+		}
+		decoded, err := Decode(stored)
+		if err != nil {
+			t.Fatalf("Decode(%.40s): %v", doc, err)
+		}
+		if string(decoded) != doc {
+			t.Errorf("Round trip changed document:\n got %.200s\nwant %.200s", decoded, doc)
+		}
+	}
+}
+
+func TestMsgPack_RAiD(t *testing.T) {
+	raid := testutil.NewTestRAiD("10.12345", "67890")
+	raid.Extensions = models.Extensions{"x-local": json.RawMessage(`{"kept":true}`)}
+	doc, err := json.Marshal(raid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := Encode(MsgPack, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored) >= len(doc) {
+		t.Errorf("MessagePack (%d bytes) not smaller than JSON (%d bytes)", len(stored), len(doc))
+	}
+
+	decoded, err := Decode(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got models.RAiD
+	if err := json.Unmarshal(decoded, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, raid) {
+		t.Errorf("RAiD changed by round trip:\n got %s\nwant %s", decoded, doc)
+	}
+}
+
+func TestDecode_JSON(t *testing.T) {
+	doc := []byte(`{"a":1}`)
+	for _, f := range []Format{"", JSON} {
+		stored, err := Encode(f, doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if FormatOf(stored) != JSON {
+			t.Errorf("Encode(%q) is not stored as JSON", f)
+		}
+		decoded, err := Decode(stored)
+		if err != nil || string(decoded) != string(doc) {
+			t.Errorf("Decode(%s) = %s, %v", stored, decoded, err)
+		}
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, data := range [][]byte{
+		{msgpackMarker},
+		{msgpackMarker, 0xa5, 'a'},
+		{msgpackMarker, 0x81, 0xa1, 'a'},
+		{msgpackMarker, 0xc1},
+		{msgpackMarker, 0xc0, 0xc0},
+	} {
+		if _, err := Decode(data); err == nil {
+			t.Errorf("Decode(% x) succeeded", data)
+		}
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]Format{"": JSON, "json": JSON, "msgpack": MsgPack} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("ParseFormat(%q) = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ParseFormat("protobuf"); err == nil {
+		t.Error("ParseFormat(protobuf) succeeded")
+	}
+}
@@ -0,0 +1,319 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// encodeMsgPack transcodes a JSON document into a marked MessagePack value.
+// Object properties keep their order, so that decoding gives back the same
+// document apart from insignificant whitespace and number formatting.
+func encodeMsgPack(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+
+	out := []byte{msgpackMarker}
+	out, err := appendValue(out, dec)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after JSON document")
+	}
+	return out, nil
+}
+
+// appendValue appends the next JSON value of dec to out
+func appendValue(out []byte, dec *json.Decoder) ([]byte, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch v := tok.(type) {
+	case nil:
+		return append(out, 0xc0), nil
+	case bool:
+		if v {
+			return append(out, 0xc3), nil
+		}
+		return append(out, 0xc2), nil
+	case string:
+		return appendString(out, v), nil
+	case json.Number:
+		return appendNumber(out, v)
+	case json.Delim:
+		// Container lengths precede their elements, so elements are
+		// encoded separately and counted first
+		var body []byte
+		n := 0
+		for dec.More() {
+			if v == '{' {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				body = appendString(body, key.(string))
+			}
+			if body, err = appendValue(body, dec); err != nil {
+				return nil, err
+			}
+			n++
+		}
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		if v == '{' {
+			out = appendHeader(out, n, 0x80, 0xde, 0xdf)
+		} else {
+			out = appendHeader(out, n, 0x90, 0xdc, 0xdd)
+		}
+		return append(out, body...), nil
+	}
+	return nil, fmt.Errorf("unexpected JSON token %v", tok)
+}
+
+// appendHeader appends a map or array header for n elements, using the fix
+// form for up to 15 elements
+func appendHeader(out []byte, n int, fix, code16, code32 byte) []byte {
+	switch {
+	case n < 16:
+		return append(out, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(out, code16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(out, code32), uint32(n))
+}
+
+func appendString(out []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		out = append(out, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		out = append(out, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		out = binary.BigEndian.AppendUint16(append(out, 0xda), uint16(n))
+	default:
+		out = binary.BigEndian.AppendUint32(append(out, 0xdb), uint32(n))
+	}
+	return append(out, s...)
+}
+
+// appendNumber stores integers in the smallest integer form and other
+// numbers as 64-bit floats
+func appendNumber(out []byte, num json.Number) ([]byte, error) {
+	s := string(num)
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return appendInt(out, i), nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return binary.BigEndian.AppendUint64(append(out, 0xcf), u), nil
+		}
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xcb), math.Float64bits(f)), nil
+}
+
+func appendInt(out []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		return append(out, byte(i))
+	case i < 0 && i >= -32:
+		return append(out, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(out, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(out, 0xd1), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(out, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(out, 0xd3), uint64(i))
+}
+
+var errTruncated = errors.New("truncated MessagePack value")
+
+// msgpackReader transcodes MessagePack back into JSON
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+// decodeMsgPack transcodes an unmarked MessagePack value into JSON
+func decodeMsgPack(data []byte) ([]byte, error) {
+	r := &msgpackReader{data: data}
+	out, err := r.appendValue(make([]byte, 0, len(data)+len(data)/4))
+	if err != nil {
+		return nil, err
+	}
+	if r.pos != len(data) {
+		return nil, fmt.Errorf("unexpected data after MessagePack value")
+	}
+	return out, nil
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || len(r.data)-r.pos < n {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// uint reads an n byte big-endian unsigned integer
+func (r *msgpackReader) uint(n int) (uint64, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (r *msgpackReader) appendValue(out []byte) ([]byte, error) {
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return strconv.AppendInt(out, int64(c), 10), nil
+	case c >= 0xe0:
+		return strconv.AppendInt(out, int64(int8(c)), 10), nil
+	case c&0xf0 == 0x80:
+		return r.appendMap(out, int(c&0x0f))
+	case c&0xf0 == 0x90:
+		return r.appendArray(out, int(c&0x0f))
+	case c&0xe0 == 0xa0:
+		return r.appendString(out, int(c&0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return append(out, "null"...), nil
+	case 0xc2:
+		return append(out, "false"...), nil
+	case 0xc3:
+		return append(out, "true"...), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		size := 1 << (c - 0xd0)
+		v, err := r.uint(size)
+		if err != nil {
+			return nil, err
+		}
+		// Sign extend from the encoded width
+		shift := 64 - 8*size
+		return strconv.AppendInt(out, int64(v<<shift)>>shift, 10), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		v, err := r.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendUint(out, v, 10), nil
+	case 0xcb:
+		v, err := r.uint(8)
+		if err != nil {
+			return nil, err
+		}
+		return strconv.AppendFloat(out, math.Float64frombits(v), 'g', -1, 64), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.appendString(out, int(n))
+	case 0xdc, 0xdd:
+		n, err := r.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.appendArray(out, int(n))
+	case 0xde, 0xdf:
+		n, err := r.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.appendMap(out, int(n))
+	}
+	return nil, fmt.Errorf("unsupported MessagePack type 0x%02x", c)
+}
+
+func (r *msgpackReader) appendMap(out []byte, n int) ([]byte, error) {
+	out = append(out, '{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		// Keys are always strings
+		var err error
+		if out, err = r.appendValue(out); err != nil {
+			return nil, err
+		}
+		out = append(out, ':')
+		if out, err = r.appendValue(out); err != nil {
+			return nil, err
+		}
+	}
+	return append(out, '}'), nil
+}
+
+func (r *msgpackReader) appendArray(out []byte, n int) ([]byte, error) {
+	out = append(out, '[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		var err error
+		if out, err = r.appendValue(out); err != nil {
+			return nil, err
+		}
+	}
+	return append(out, ']'), nil
+}
+
+// appendString appends an n byte string as a quoted JSON string
+func (r *msgpackReader) appendString(out []byte, n int) ([]byte, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return nil, err
+	}
+	if !utf8.Valid(b) {
+		return nil, fmt.Errorf("invalid UTF-8 in MessagePack string")
+	}
+
+	const hex = "0123456789abcdef"
+	out = append(out, '"')
+	for _, c := range b {
+		switch {
+		case c == '"' || c == '\\':
+			out = append(out, '\\', c)
+		case c == '\n':
+			out = append(out, '\\', 'n')
+		case c == '\r':
+			out = append(out, '\\', 'r')
+		case c == '\t':
+			out = append(out, '\\', 't')
+		case c < 0x20:
+			out = append(out, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			out = append(out, c)
+		}
+	}
+	return append(out, '"'), nil
+}
@@ -0,0 +1,184 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/leifj/go-raid/internal/jsonpatch"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// VersionCompactor is implemented by backends that can archive old RAiD
+// versions as diffs to control the storage growth of frequently updated
+// RAiDs. Archived versions are rehydrated transparently when read.
+type VersionCompactor interface {
+	// CompactRAiD archives the versions of a RAiD that policy selects and
+	// returns how many it archived
+	CompactRAiD(ctx context.Context, prefix, suffix string, policy CompactionPolicy) (int, error)
+
+	// RehydrateRAiD stores the archived versions of a RAiD as full
+	// documents again and returns how many it restored
+	RehydrateRAiD(ctx context.Context, prefix, suffix string) (int, error)
+}
+
+// CompactionPolicy selects the versions compaction archives. A version is
+// archived when both rules allow it; the current version never is.
+type CompactionPolicy struct {
+	// KeepVersions is the number of newest versions, including the current
+	// one, kept as full documents
+	KeepVersions int
+	// MinAge keeps versions written less than MinAge ago as full documents;
+	// 0 disables the rule
+	MinAge time.Duration
+}
+
+// StoredVersion is a version document as a backend stores it, either a full
+// RAiD or an archived diff
+type StoredVersion struct {
+	Version int
+	Data    []byte
+}
+
+// archivedVersion is stored in place of an archived version. Patch turns
+// the document of version Base, the next newer one, into this version.
+type archivedVersion struct {
+	Archived *archive `json:"archived"`
+}
+
+type archive struct {
+	Version    int                   `json:"version"`
+	Base       int                   `json:"base"`
+	ArchivedAt time.Time             `json:"archivedAt"`
+	Patch      []jsonpatch.Operation `json:"patch"`
+}
+
+// IsArchived reports whether a stored version document is an archived diff
+func IsArchived(data []byte) bool {
+	if !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(`{"archived"`)) {
+		return false
+	}
+	var doc archivedVersion
+	return json.Unmarshal(data, &doc) == nil && doc.Archived != nil
+}
+
+// ArchivedVersionNumber returns the version an archived document stands for
+func ArchivedVersionNumber(data []byte) (int, error) {
+	var doc archivedVersion
+	if err := json.Unmarshal(data, &doc); err != nil || doc.Archived == nil {
+		return 0, fmt.Errorf("not an archived version")
+	}
+	return doc.Archived.Version, nil
+}
+
+// CompactVersions returns the archived documents replacing the versions
+// that policy selects. versions holds every stored version of a RAiD in
+// any order, the newest being current. Versions already archived and
+// those whose diff would not be smaller are left alone.
+func CompactVersions(versions []StoredVersion, policy CompactionPolicy, now time.Time) ([]StoredVersion, error) {
+	full, err := RehydrateVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+	versions = sortVersions(versions)
+
+	keep := max(policy.KeepVersions, 1)
+	var compacted []StoredVersion
+	for i := 0; i < len(versions)-keep; i++ {
+		if IsArchived(versions[i].Data) {
+			continue
+		}
+		if policy.MinAge > 0 && now.Sub(writtenAt(full[i].Data)) < policy.MinAge {
+			continue
+		}
+
+		patch, err := jsonpatch.Diff(full[i+1].Data, full[i].Data)
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", versions[i].Version, err)
+		}
+		data, err := json.Marshal(archivedVersion{Archived: &archive{
+			Version:    versions[i].Version,
+			Base:       versions[i+1].Version,
+			ArchivedAt: now.UTC(),
+			Patch:      patch,
+		}})
+		if err != nil {
+			return nil, err
+		}
+		if len(data) >= len(versions[i].Data) {
+			continue
+		}
+		compacted = append(compacted, StoredVersion{Version: versions[i].Version, Data: data})
+	}
+	return compacted, nil
+}
+
+// RehydrateVersions returns versions, in ascending order, with archived
+// documents replaced by the full RAiDs they stand for
+func RehydrateVersions(versions []StoredVersion) ([]StoredVersion, error) {
+	sorted := sortVersions(versions)
+	full := make(map[int][]byte, len(sorted))
+	for i := len(sorted) - 1; i >= 0; i-- {
+		v := sorted[i]
+		if !IsArchived(v.Data) {
+			full[v.Version] = v.Data
+			continue
+		}
+		var doc archivedVersion
+		if err := json.Unmarshal(v.Data, &doc); err != nil {
+			return nil, fmt.Errorf("version %d: %w", v.Version, err)
+		}
+		base, ok := full[doc.Archived.Base]
+		if !ok {
+			return nil, fmt.Errorf("version %d: base version %d is missing", v.Version, doc.Archived.Base)
+		}
+		data, err := jsonpatch.Apply(base, doc.Archived.Patch)
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", v.Version, err)
+		}
+		full[v.Version] = data
+		sorted[i].Data = data
+	}
+	return sorted, nil
+}
+
+// RehydrateVersion returns the full RAiD of one version from the stored
+// versions of its RAiD
+func RehydrateVersion(versions []StoredVersion, version int) (*models.RAiD, error) {
+	full, err := RehydrateVersions(versions)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range full {
+		if v.Version == version {
+			var raid models.RAiD
+			if err := json.Unmarshal(v.Data, &raid); err != nil {
+				return nil, err
+			}
+			return &raid, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// sortVersions returns a copy of versions in ascending order
+func sortVersions(versions []StoredVersion) []StoredVersion {
+	sorted := append([]StoredVersion(nil), versions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	return sorted
+}
+
+// writtenAt returns when a full version document was written
+func writtenAt(data []byte) time.Time {
+	var raid models.RAiD
+	if json.Unmarshal(data, &raid) != nil || raid.Metadata == nil {
+		return time.Time{}
+	}
+	if !raid.Metadata.Updated.IsZero() {
+		return raid.Metadata.Updated
+	}
+	return raid.Metadata.Created
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/jsonpatch"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func storedRAiD(t *testing.T, version int, title string, updated time.Time) StoredVersion {
+	t.Helper()
+	data, err := json.Marshal(&models.RAiD{
+		Identifier:  &models.Identifier{ID: "https://raid.org/10.99999/abc", Version: version},
+		Title:       []models.Title{{Text: title}},
+		Description: []models.Description{{Text: strings.Repeat("unchanged ", 50)}},
+		Metadata:    &models.Metadata{Updated: updated},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return StoredVersion{Version: version, Data: data}
+}
+
+func TestCompactVersions(t *testing.T) {
+	now := time.Now()
+	var versions []StoredVersion
+	for v := 1; v <= 4; v++ {
+		versions = append(versions, storedRAiD(t, v, fmt.Sprintf("Title %d", v), now.Add(time.Duration(v-5)*24*time.Hour)))
+	}
+
+	// Versions 1 and 2 are three and four days old; version 3 is beyond the depth
+	// but too recent
+	compacted, err := CompactVersions(versions, CompactionPolicy{KeepVersions: 1, MinAge: 60 * time.Hour}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compacted) != 2 || compacted[0].Version != 1 || compacted[1].Version != 2 {
+		t.Fatalf("unexpected compacted versions: %+v", compacted)
+	}
+
+	stored := append([]StoredVersion{}, versions...)
+	for _, v := range compacted {
+		if !IsArchived(v.Data) || len(v.Data) >= len(versions[v.Version-1].Data) {
+			t.Errorf("version %d is not a smaller archived document", v.Version)
+		}
+		stored[v.Version-1] = v
+	}
+
+	full, err := RehydrateVersions(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range full {
+		if ops, err := jsonpatch.Diff(versions[i].Data, v.Data); err != nil || len(ops) != 0 {
+			t.Errorf("version %d rehydrated with differences %+v, %v", v.Version, ops, err)
+		}
+	}
+
+	// Archived versions are left alone by later runs
+	again, err := CompactVersions(stored, CompactionPolicy{KeepVersions: 1, MinAge: 60 * time.Hour}, now)
+	if err != nil || len(again) != 0 {
+		t.Errorf("expected nothing more to archive, got %+v, %v", again, err)
+	}
+
+	raid, err := RehydrateVersion(stored, 1)
+	if err != nil || raid.Title[0].Text != "Title 1" {
+		t.Errorf("unexpected version 1: %+v, %v", raid, err)
+	}
+	if _, err := RehydrateVersion(stored, 9); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound for a missing version, got %v", err)
+	}
+
+	// An archived version whose base is gone cannot be rehydrated
+	if _, err := RehydrateVersions(stored[:2]); err == nil {
+		t.Error("expected error for a missing base version")
+	}
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ContentHash returns a hash of the content of a RAiD version. The version
+// number and metadata timestamps, which every write sets, are left out, so
+// an update repeating the current content hashes the same.
+func ContentHash(raid *models.RAiD) (string, error) {
+	data, err := json.Marshal(raid)
+	if err != nil {
+		return "", err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", err
+	}
+	delete(doc, "metadata")
+	if id, ok := doc["identifier"].(map[string]any); ok {
+		delete(id, "version")
+	}
+	// Objects are marshalled with sorted keys, so equal content gives equal
+	// bytes
+	if data, err = json.Marshal(doc); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Unchanged reports whether updated repeats the content of current, whose
+// stored content hash is hash or empty if none was stored. Backends then
+// return current instead of writing a new version.
+func Unchanged(hash string, current, updated *models.RAiD) (bool, error) {
+	if hash == "" {
+		var err error
+		if hash, err = ContentHash(current); err != nil {
+			return false, err
+		}
+	}
+	updatedHash, err := ContentHash(updated)
+	if err != nil {
+		return false, err
+	}
+	return updatedHash == hash, nil
+}
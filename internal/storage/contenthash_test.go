@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestContentHash(t *testing.T) {
+	a := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Version: 1},
+		Title:      []models.Title{{Text: "First"}},
+		Metadata:   &models.Metadata{Created: time.Unix(1, 0)},
+	}
+	b := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Version: 2},
+		Title:      []models.Title{{Text: "First"}},
+		Metadata:   &models.Metadata{Created: time.Unix(1, 0), Updated: time.Unix(2, 0)},
+	}
+
+	unchanged, err := Unchanged("", a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !unchanged {
+		t.Error("expected versions differing in version and metadata only to hash equal")
+	}
+
+	b.Title[0].Text = "Second"
+	hash, err := ContentHash(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if unchanged, err = Unchanged(hash, a, b); err != nil || unchanged {
+		t.Errorf("expected a title change to hash differently, got %v, %v", unchanged, err)
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+)
+
+// Access type vocabulary identifiers used when counting RAiDs
+const (
+	AccessTypeOpen      = "https://vocabulary.raid.org/access.type.schema/82"
+	AccessTypeEmbargoed = "https://vocabulary.raid.org/access.type.schema/53"
+)
+
+// ErrCountUnsupported is returned by CountRAiDs for backends that neither
+// count natively nor support export
+var ErrCountUnsupported = errors.New("storage backend does not support counting")
+
+// RAiDCounts summarises the contents of a backend
+type RAiDCounts struct {
+	// Total counts RAiDs that are not deleted
+	Total     int64 `json:"total"`
+	Public    int64 `json:"public"`
+	Embargoed int64 `json:"embargoed"`
+	Deleted   int64 `json:"deleted"`
+	// Versions counts stored versions of all RAiDs, including deleted ones
+	Versions int64 `json:"versions"`
+	// ByServicePoint counts non-deleted RAiDs per owning service point;
+	// 0 collects RAiDs without one
+	ByServicePoint map[int64]int64 `json:"byServicePoint"`
+	// MintedByDay counts RAiDs (including deleted ones) by UTC mint date,
+	// keyed "2006-01-02"
+	MintedByDay map[string]int64 `json:"mintedByDay"`
+}
+
+// NewRAiDCounts returns empty counts
+func NewRAiDCounts() *RAiDCounts {
+	return &RAiDCounts{
+		ByServicePoint: make(map[int64]int64),
+		MintedByDay:    make(map[string]int64),
+	}
+}
+
+// Add counts a record
+func (c *RAiDCounts) Add(record *RAiDRecord) {
+	current := record.Current()
+	if current == nil {
+		return
+	}
+
+	c.Versions += int64(len(record.Versions))
+	if first := record.Versions[0]; first.Metadata != nil && !first.Metadata.Created.IsZero() {
+		c.MintedByDay[first.Metadata.Created.UTC().Format("2006-01-02")]++
+	}
+
+	if record.Deleted {
+		c.Deleted++
+		return
+	}
+	c.Total++
+
+	if current.Access != nil && current.Access.Type != nil {
+		switch current.Access.Type.ID {
+		case AccessTypeOpen:
+			c.Public++
+		case AccessTypeEmbargoed:
+			c.Embargoed++
+		}
+	}
+
+	var servicePoint int64
+	if current.Identifier != nil && current.Identifier.Owner != nil {
+		servicePoint = current.Identifier.Owner.ServicePoint
+	}
+	c.ByServicePoint[servicePoint]++
+}
+
+// RAiDCountProvider is implemented by backends that can count RAiDs without
+// reading every document, e.g. with SQL aggregates
+type RAiDCountProvider interface {
+	CountRAiDs(ctx context.Context) (*RAiDCounts, error)
+}
+
+// CountRAiDs counts the RAiDs in repo, natively where supported and
+// otherwise by scanning an export
+func CountRAiDs(ctx context.Context, repo Repository) (*RAiDCounts, error) {
+	if cp, ok := repo.(RAiDCountProvider); ok {
+		return cp.CountRAiDs(ctx)
+	}
+
+	return CountRAiDsWhere(ctx, repo, func(*RAiDRecord) bool { return true })
+}
+
+// CountRAiDsWhere counts the RAiDs in repo for which keep returns true by
+// scanning an export; native counts cannot be filtered
+func CountRAiDsWhere(ctx context.Context, repo Repository, keep func(*RAiDRecord) bool) (*RAiDCounts, error) {
+	snap, ok := repo.(Snapshotter)
+	if !ok {
+		return nil, ErrCountUnsupported
+	}
+
+	counts := NewRAiDCounts()
+	err := snap.ExportRAiDs(ctx, func(record *RAiDRecord) error {
+		if keep(record) {
+			counts.Add(record)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
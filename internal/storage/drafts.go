@@ -0,0 +1,24 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// DraftStore is implemented by backends that can keep draft RAiDs. Drafts
+// are kept apart from RAiDs, so they take no identifier and appear in no
+// listing.
+type DraftStore interface {
+	// SaveDraft stores draft, replacing the draft with the same ID
+	SaveDraft(ctx context.Context, draft *models.Draft) error
+
+	// GetDraft retrieves a draft by ID
+	GetDraft(ctx context.Context, id string) (*models.Draft, error)
+
+	// ListDrafts retrieves the drafts of a service point, ordered by ID
+	ListDrafts(ctx context.Context, servicePoint int64) ([]*models.Draft, error)
+
+	// DeleteDraft removes a draft
+	DeleteDraft(ctx context.Context, id string) error
+}
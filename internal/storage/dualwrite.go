@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// A dual-write window moves a registry to another backend without
+// downtime. The backend migrated from stays the source of truth: writes
+// go to it first and are then mirrored to the target, keeping identifiers,
+// versions, timestamps and service point IDs. A mirrored write that fails
+// or leaves the target with other content is logged as a divergence and
+// does not fail the request. A share of reads is served by the target,
+// falling back to the source when it fails, so the share can be raised
+// step by step until the target serves all reads and the configuration
+// can switch over to it.
+
+// writeTimeKey is the context key of WithWriteTime
+type writeTimeKey struct{}
+
+// WithWriteTime returns a context under which RAiD versions are dated t
+// rather than when they are written, so that a mirrored write keeps the
+// time of the original
+func WithWriteTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, writeTimeKey{}, t)
+}
+
+// WriteTime returns the time RAiD versions written under ctx are dated:
+// the time given to WithWriteTime, otherwise now
+func WriteTime(ctx context.Context) time.Time {
+	if t, ok := ctx.Value(writeTimeKey{}).(time.Time); ok {
+		return t
+	}
+	return time.Now()
+}
+
+// migrationMetrics counts mirrored writes and shifted reads, published
+// under "migration" in /debug/vars
+var migrationMetrics = expvar.NewMap("migration")
+
+// MigrationConfig configures a dual-write window moving to another backend
+type MigrationConfig struct {
+	// Target is the backend migrated to
+	Target *StorageConfig `yaml:"target" toml:"target"`
+	// ReadPercent is the share of reads, 0 to 100, served by the target
+	ReadPercent int `yaml:"readPercent" toml:"readPercent"`
+}
+
+// DualWrite is a Repository writing to two backends during a migration.
+// Both must be Snapshotters, which mirroring relies on to keep what the
+// source assigned, and support purging, redacting and compacting RAiDs,
+// so that the admin API and background jobs keep working through the
+// window.
+type DualWrite struct {
+	source, target           Repository
+	sourceSnap, targetSnap   Snapshotter
+	sourceMaint, targetMaint maintainer
+	readPercent              int
+}
+
+// maintainer is what DualWrite needs of each backend beyond Repository
+// and Snapshotter to pass on purges, redactions and compaction
+type maintainer interface {
+	Purger
+	Redactor
+	VersionCompactor
+}
+
+// NewDualWrite returns a repository writing to source and mirroring its
+// writes to target, which serves readPercent percent of reads
+func NewDualWrite(source, target Repository, readPercent int) (*DualWrite, error) {
+	sourceSnap, ok := source.(Snapshotter)
+	if !ok {
+		return nil, errors.New("the backend migrated from does not support snapshots")
+	}
+	targetSnap, ok := target.(Snapshotter)
+	if !ok {
+		return nil, errors.New("the backend migrated to does not support snapshots")
+	}
+	sourceMaint, ok := source.(maintainer)
+	if !ok {
+		return nil, errors.New("the backend migrated from does not support purging, redacting and compacting RAiDs")
+	}
+	targetMaint, ok := target.(maintainer)
+	if !ok {
+		return nil, errors.New("the backend migrated to does not support purging, redacting and compacting RAiDs")
+	}
+	return &DualWrite{
+		source:      source,
+		target:      target,
+		sourceSnap:  sourceSnap,
+		targetSnap:  targetSnap,
+		sourceMaint: sourceMaint,
+		targetMaint: targetMaint,
+		readPercent: min(max(readPercent, 0), 100),
+	}, nil
+}
+
+// Source returns the backend holding the data of repo: the backend
+// migrated from during a dual-write window, otherwise repo itself. Stores
+// beyond Repository, such as tags and attachments, are only kept there
+// until migrate-storage copies them.
+func Source(repo Repository) Repository {
+	if d, ok := repo.(*DualWrite); ok {
+		return d.source
+	}
+	return repo
+}
+
+// diverged logs a write the target did not take as the source did
+func diverged(op, ref string, err error) {
+	migrationMetrics.Add("diverged", 1)
+	log.Printf("Migration: %s of %s diverged in the target: %v", op, ref, err)
+}
+
+// mirrored counts a write the target took as the source did
+func mirrored() {
+	migrationMetrics.Add("mirrored", 1)
+}
+
+// read runs fn against the target for the configured share of reads and
+// against the source otherwise, or when the target fails
+func read[T any](ctx context.Context, d *DualWrite, fn func(Repository) (T, error)) (T, error) {
+	if d.readPercent > 0 && rand.IntN(100) < d.readPercent {
+		v, err := fn(d.target)
+		if err == nil {
+			migrationMetrics.Add("targetReads", 1)
+			return v, nil
+		}
+		if ctx.Err() != nil {
+			return v, err
+		}
+		migrationMetrics.Add("fallbacks", 1)
+	}
+	return fn(d.source)
+}
+
+// copyRAiD returns a deep copy of raid, as backends modify what they store
+func copyRAiD(raid *models.RAiD) (*models.RAiD, error) {
+	data, err := json.Marshal(raid)
+	if err != nil {
+		return nil, err
+	}
+	var c models.RAiD
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// compare reports a divergence if the target's version of a RAiD differs
+// from the source's in version or content
+func compare(op, ref string, source, target *models.RAiD) {
+	if source.Identifier != nil && target.Identifier != nil && source.Identifier.Version != target.Identifier.Version {
+		diverged(op, ref, fmt.Errorf("version %d, source has %d", target.Identifier.Version, source.Identifier.Version))
+		return
+	}
+	sourceHash, err := ContentHash(source)
+	if err != nil {
+		diverged(op, ref, err)
+		return
+	}
+	targetHash, err := ContentHash(target)
+	if err != nil {
+		diverged(op, ref, err)
+		return
+	}
+	if sourceHash != targetHash {
+		diverged(op, ref, errors.New("content differs from the source"))
+		return
+	}
+	mirrored()
+}
+
+// mirrorMint imports a RAiD minted in the source into the target, as
+// minted by the actor of ctx, raising the target's counter past a numbered
+// suffix
+func (d *DualWrite) mirrorMint(ctx context.Context, raid *models.RAiD) {
+	ctx = context.WithoutCancel(ctx)
+	ref := raid.Identifier.ID
+	record := &RAiDRecord{Versions: []*models.RAiD{raid}}
+	if actor := Actor(ctx); actor != "" {
+		record.Actors = map[int]string{raid.Identifier.Version: actor}
+	}
+	if err := d.targetSnap.ImportRAiD(ctx, record); err != nil {
+		diverged("mint", ref, err)
+		return
+	}
+	// Identifiers end in PREFIX/SUFFIX
+	parts := strings.Split(strings.TrimRight(ref, "/"), "/")
+	if len(parts) >= 2 {
+		prefix, suffix := parts[len(parts)-2], parts[len(parts)-1]
+		if n, err := strconv.ParseInt(suffix, 10, 64); err == nil {
+			if err := d.targetSnap.SetCounters(ctx, map[string]int64{CounterRAiDPrefix + prefix: n}); err != nil {
+				diverged("mint", ref, err)
+				return
+			}
+		}
+	}
+	mirrored()
+}
+
+// CreateRAiD mints in the source and imports the result into the target
+func (d *DualWrite) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	created, err := d.source.CreateRAiD(ctx, raid)
+	if err != nil {
+		return nil, err
+	}
+	if c, err := copyRAiD(created); err != nil {
+		diverged("mint", created.Identifier.ID, err)
+	} else {
+		d.mirrorMint(ctx, c)
+	}
+	return created, nil
+}
+
+// UpdateRAiD updates the source, then the target. The target's version is
+// not checked against the request, the source has done so; comparing the
+// results catches a target that has fallen behind.
+func (d *DualWrite) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	replica, copyErr := copyRAiD(raid)
+	updated, err := d.source.UpdateRAiD(ctx, prefix, suffix, raid)
+	if err != nil {
+		return nil, err
+	}
+	ref := prefix + "/" + suffix
+	if copyErr != nil {
+		diverged("update", ref, copyErr)
+		return updated, nil
+	}
+	if replica.Identifier != nil {
+		replica.Identifier.Version = 0
+	}
+	mirrorCtx := context.WithoutCancel(ctx)
+	if updated.Metadata != nil {
+		mirrorCtx = WithWriteTime(mirrorCtx, updated.Metadata.Updated)
+	}
+	got, err := d.target.UpdateRAiD(mirrorCtx, prefix, suffix, replica)
+	if err != nil {
+		diverged("update", ref, err)
+		return updated, nil
+	}
+	compare("update", ref, updated, got)
+	return updated, nil
+}
+
+// DeleteRAiD deletes from the source, then the target
+func (d *DualWrite) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	if err := d.source.DeleteRAiD(ctx, prefix, suffix); err != nil {
+		return err
+	}
+	if err := d.target.DeleteRAiD(context.WithoutCancel(ctx), prefix, suffix); err != nil {
+		diverged("delete", prefix+"/"+suffix, err)
+	} else {
+		mirrored()
+	}
+	return nil
+}
+
+// GenerateIdentifier generates identifiers from the source's counters
+func (d *DualWrite) GenerateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
+	return d.source.GenerateIdentifier(ctx, servicePointID)
+}
+
+func (d *DualWrite) GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error) {
+	return read(ctx, d, func(r Repository) (*models.RAiD, error) { return r.GetRAiD(ctx, prefix, suffix) })
+}
+
+func (d *DualWrite) GetRAiDs(ctx context.Context, refs []IdentifierRef) ([]*models.RAiD, error) {
+	return read(ctx, d, func(r Repository) ([]*models.RAiD, error) { return r.GetRAiDs(ctx, refs) })
+}
+
+func (d *DualWrite) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
+	return read(ctx, d, func(r Repository) (*models.RAiD, error) { return r.GetRAiDVersion(ctx, prefix, suffix, version) })
+}
+
+func (d *DualWrite) ListRAiDs(ctx context.Context, filter *RAiDFilter) ([]*models.RAiD, error) {
+	return read(ctx, d, func(r Repository) ([]*models.RAiD, error) { return r.ListRAiDs(ctx, filter) })
+}
+
+func (d *DualWrite) ListPublicRAiDs(ctx context.Context, filter *RAiDFilter) ([]*models.RAiD, error) {
+	return read(ctx, d, func(r Repository) ([]*models.RAiD, error) { return r.ListPublicRAiDs(ctx, filter) })
+}
+
+func (d *DualWrite) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
+	return read(ctx, d, func(r Repository) ([]*models.RAiD, error) { return r.GetRAiDHistory(ctx, prefix, suffix) })
+}
+
+func (d *DualWrite) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *HistoryPage) ([]VersionChange, error) {
+	return read(ctx, d, func(r Repository) ([]VersionChange, error) { return r.GetRAiDChanges(ctx, prefix, suffix, page) })
+}
+
+// CreateServicePoint creates the service point in the source and imports
+// it into the target with the ID the source gave it
+func (d *DualWrite) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	created, err := d.source.CreateServicePoint(ctx, sp)
+	if err != nil {
+		return nil, err
+	}
+	replica := *created
+	if err := d.targetSnap.ImportServicePoint(context.WithoutCancel(ctx), &replica); err != nil {
+		diverged("service point creation", fmt.Sprintf("service point %d", created.ID), err)
+	} else {
+		mirrored()
+	}
+	return created, nil
+}
+
+// UpdateServicePoint updates the source, then the target
+func (d *DualWrite) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
+	updated, err := d.source.UpdateServicePoint(ctx, id, sp)
+	if err != nil {
+		return nil, err
+	}
+	replica := *updated
+	if _, err := d.target.UpdateServicePoint(context.WithoutCancel(ctx), id, &replica); err != nil {
+		diverged("service point update", fmt.Sprintf("service point %d", id), err)
+	} else {
+		mirrored()
+	}
+	return updated, nil
+}
+
+// DeleteServicePoint deletes from the source, then the target
+func (d *DualWrite) DeleteServicePoint(ctx context.Context, id int64) error {
+	if err := d.source.DeleteServicePoint(ctx, id); err != nil {
+		return err
+	}
+	if err := d.target.DeleteServicePoint(context.WithoutCancel(ctx), id); err != nil {
+		diverged("service point deletion", fmt.Sprintf("service point %d", id), err)
+	} else {
+		mirrored()
+	}
+	return nil
+}
+
+func (d *DualWrite) GetServicePoint(ctx context.Context, id int64) (*models.ServicePoint, error) {
+	return read(ctx, d, func(r Repository) (*models.ServicePoint, error) { return r.GetServicePoint(ctx, id) })
+}
+
+func (d *DualWrite) ListServicePoints(ctx context.Context) ([]*models.ServicePoint, error) {
+	return read(ctx, d, func(r Repository) ([]*models.ServicePoint, error) { return r.ListServicePoints(ctx) })
+}
+
+// Close closes both backends
+func (d *DualWrite) Close() error {
+	return errors.Join(d.source.Close(), d.target.Close())
+}
+
+// HealthCheck checks the source; the target failing degrades nothing
+// but the mirroring, which is logged
+func (d *DualWrite) HealthCheck(ctx context.Context) error {
+	return d.source.HealthCheck(ctx)
+}
+
+// ExportRAiDs exports the source's RAiDs
+func (d *DualWrite) ExportRAiDs(ctx context.Context, fn func(*RAiDRecord) error) error {
+	return d.sourceSnap.ExportRAiDs(ctx, fn)
+}
+
+// ImportRAiD imports into the source, then the target
+func (d *DualWrite) ImportRAiD(ctx context.Context, record *RAiDRecord) error {
+	if err := d.sourceSnap.ImportRAiD(ctx, record); err != nil {
+		return err
+	}
+	ref := ""
+	if current := record.Current(); current != nil && current.Identifier != nil {
+		ref = current.Identifier.ID
+	}
+	if err := d.targetSnap.ImportRAiD(context.WithoutCancel(ctx), record); err != nil {
+		diverged("import", ref, err)
+	} else {
+		mirrored()
+	}
+	return nil
+}
+
+// ImportServicePoint imports into the source, then the target
+func (d *DualWrite) ImportServicePoint(ctx context.Context, sp *models.ServicePoint) error {
+	if err := d.sourceSnap.ImportServicePoint(ctx, sp); err != nil {
+		return err
+	}
+	replica := *sp
+	if err := d.targetSnap.ImportServicePoint(context.WithoutCancel(ctx), &replica); err != nil {
+		diverged("import", fmt.Sprintf("service point %d", sp.ID), err)
+	} else {
+		mirrored()
+	}
+	return nil
+}
+
+// Counters returns the source's counters
+func (d *DualWrite) Counters(ctx context.Context) (map[string]int64, error) {
+	return d.sourceSnap.Counters(ctx)
+}
+
+// SetCounters raises the counters of both backends
+func (d *DualWrite) SetCounters(ctx context.Context, counters map[string]int64) error {
+	if err := d.sourceSnap.SetCounters(ctx, counters); err != nil {
+		return err
+	}
+	if err := d.targetSnap.SetCounters(context.WithoutCancel(ctx), counters); err != nil {
+		diverged("counters", "identifier counters", err)
+	}
+	return nil
+}
+
+// Stats reports the share of reads shifted and the statistics of both
+// backends
+func (d *DualWrite) Stats(ctx context.Context) (map[string]interface{}, error) {
+	stats := map[string]interface{}{"readPercent": d.readPercent}
+	for name, repo := range map[string]Repository{"source": d.source, "target": d.target} {
+		if sp, ok := repo.(StatsProvider); ok {
+			s, err := sp.Stats(ctx)
+			if err != nil {
+				s = map[string]interface{}{"error": err.Error()}
+			}
+			stats[name] = s
+		}
+	}
+	return stats, nil
+}
+
+// PurgeRAiD purges from the source, then the target
+func (d *DualWrite) PurgeRAiD(ctx context.Context, purge *models.Purge) error {
+	if err := d.sourceMaint.PurgeRAiD(ctx, purge); err != nil {
+		return err
+	}
+	replica := *purge
+	if err := d.targetMaint.PurgeRAiD(context.WithoutCancel(ctx), &replica); err != nil {
+		diverged("purge", purge.Prefix+"/"+purge.Suffix, err)
+	} else {
+		mirrored()
+	}
+	return nil
+}
+
+// ListPurges returns the source's purges
+func (d *DualWrite) ListPurges(ctx context.Context) ([]*models.Purge, error) {
+	return d.sourceMaint.ListPurges(ctx)
+}
+
+// RedactRAiD redacts the source, then the target, which records its own
+// copy of the redaction. A target changing another number of versions
+// has diverged.
+func (d *DualWrite) RedactRAiD(ctx context.Context, prefix, suffix string, redact func(*models.RAiD) bool, redaction *models.Redaction) (int, error) {
+	n, err := d.sourceMaint.RedactRAiD(ctx, prefix, suffix, redact, redaction)
+	if err != nil {
+		return 0, err
+	}
+	ref := prefix + "/" + suffix
+	replica := *redaction
+	replica.Fields = append([]string(nil), redaction.Fields...)
+	got, err := d.targetMaint.RedactRAiD(context.WithoutCancel(ctx), prefix, suffix, redact, &replica)
+	switch {
+	case err != nil:
+		diverged("redaction", ref, err)
+	case got != n:
+		diverged("redaction", ref, fmt.Errorf("%d versions redacted, source redacted %d", got, n))
+	default:
+		mirrored()
+	}
+	return n, nil
+}
+
+// ListRedactions returns the source's redactions
+func (d *DualWrite) ListRedactions(ctx context.Context) ([]*models.Redaction, error) {
+	return d.sourceMaint.ListRedactions(ctx)
+}
+
+// CompactRAiD compacts the source, then the target
+func (d *DualWrite) CompactRAiD(ctx context.Context, prefix, suffix string, policy CompactionPolicy) (int, error) {
+	n, err := d.sourceMaint.CompactRAiD(ctx, prefix, suffix, policy)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := d.targetMaint.CompactRAiD(context.WithoutCancel(ctx), prefix, suffix, policy); err != nil {
+		diverged("compaction", prefix+"/"+suffix, err)
+	} else {
+		mirrored()
+	}
+	return n, nil
+}
+
+// RehydrateRAiD rehydrates the source, then the target
+func (d *DualWrite) RehydrateRAiD(ctx context.Context, prefix, suffix string) (int, error) {
+	n, err := d.sourceMaint.RehydrateRAiD(ctx, prefix, suffix)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := d.targetMaint.RehydrateRAiD(context.WithoutCancel(ctx), prefix, suffix); err != nil {
+		diverged("rehydration", prefix+"/"+suffix, err)
+	} else {
+		mirrored()
+	}
+	return n, nil
+}
+
+// Verify DualWrite supports snapshots, statistics and the admin writes
+var (
+	_ Repository       = (*DualWrite)(nil)
+	_ Snapshotter      = (*DualWrite)(nil)
+	_ StatsProvider    = (*DualWrite)(nil)
+	_ Purger           = (*DualWrite)(nil)
+	_ Redactor         = (*DualWrite)(nil)
+	_ VersionCompactor = (*DualWrite)(nil)
+)
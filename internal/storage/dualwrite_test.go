@@ -0,0 +1,181 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/contributor"
+	"github.com/leifj/go-raid/internal/migrate"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/file"
+)
+
+func newFileStorage(t *testing.T) *file.FileStorage {
+	t.Helper()
+	fs, err := file.New(&file.Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+func TestDualWrite(t *testing.T) {
+	ctx := context.Background()
+	source, target := newFileStorage(t), newFileStorage(t)
+	dual, err := storage.NewDualWrite(source, target, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storage.Source(dual) != storage.Repository(source) {
+		t.Error("expected the source to hold the data")
+	}
+
+	// Written before the window opened, so only the source has it
+	if _, err := source.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/old"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	sp, err := dual.CreateServicePoint(ctx, &models.ServicePoint{Name: "Test SP", Prefix: "10.99999"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"a", "b"} {
+		id := "https://raid.org/10.99999/" + suffix
+		if _, err := dual.CreateRAiD(storage.WithActor(ctx, "alice"), &models.RAiD{Identifier: &models.Identifier{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+		updated := &models.RAiD{Identifier: &models.Identifier{ID: id, Version: 1}, Title: []models.Title{{Text: "Updated"}}}
+		if _, err := dual.UpdateRAiD(storage.WithActor(ctx, "bob"), "10.99999", suffix, updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := dual.DeleteRAiD(ctx, "10.99999", "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// An update the target cannot take diverges without failing
+	if _, err := dual.UpdateRAiD(ctx, "10.99999", "old", &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/old"}, Title: []models.Title{{Text: "Updated"}}}); err != nil {
+		t.Fatalf("expected a write the target cannot take to succeed, got %v", err)
+	}
+
+	// The target holds what was written through the window, keeping IDs
+	if got, err := target.GetServicePoint(ctx, sp.ID); err != nil || got.Name != "Test SP" {
+		t.Errorf("expected the service point in the target under its ID, got %+v, %v", got, err)
+	}
+	raid, err := target.GetRAiD(ctx, "10.99999", "a")
+	if err != nil || raid.Identifier.Version != 2 || raid.Title[0].Text != "Updated" {
+		t.Errorf("expected the update in the target, got %+v, %v", raid, err)
+	}
+	if changes, err := target.GetRAiDChanges(ctx, "10.99999", "a", nil); err != nil || len(changes) != 2 || changes[0].Actor != "alice" || changes[1].Actor != "bob" {
+		t.Errorf("expected the actors in the target, got %+v, %v", changes, err)
+	}
+	if _, err := target.GetRAiD(ctx, "10.99999", "b"); err != storage.ErrNotFound {
+		t.Errorf("expected the deletion in the target, got %v", err)
+	}
+
+	// Reads shifted to the target fall back to the source
+	if raid, err := dual.GetRAiD(ctx, "10.99999", "old"); err != nil || raid.Identifier.Version != 2 {
+		t.Errorf("expected a RAiD missing in the target to be read from the source, got %+v, %v", raid, err)
+	}
+
+	// Backfilling the rest leaves the backends alike
+	if _, err := migrate.Run(ctx, source, target, migrate.Options{Resume: true}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := migrate.Run(ctx, source, target, migrate.Options{Resume: true, Verify: true})
+	if err != nil {
+		t.Fatalf("expected the backends to match, got %v", err)
+	}
+	if summary.Verified != 4 {
+		t.Errorf("expected 3 RAiDs and a service point verified, got %+v", summary)
+	}
+}
+
+func TestDualWrite_AdminWrites(t *testing.T) {
+	ctx := context.Background()
+	source, target := newFileStorage(t), newFileStorage(t)
+	dual, err := storage.NewDualWrite(source, target, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"a", "b"} {
+		id := "https://raid.org/10.99999/" + suffix
+		raid := &models.RAiD{
+			Identifier:  &models.Identifier{ID: id},
+			Contributor: []models.Contributor{{ID: "https://orcid.org/0000-0002-1825-0097", Email: "jane@example.org"}},
+		}
+		if _, err := dual.CreateRAiD(ctx, raid); err != nil {
+			t.Fatal(err)
+		}
+		for v := 1; v <= 2; v++ {
+			raid.Identifier.Version = v
+			raid.Title = []models.Title{{Text: fmt.Sprintf("Version %d", v+1)}}
+			if _, err := dual.UpdateRAiD(ctx, "10.99999", suffix, raid); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	t.Run("redaction", func(t *testing.T) {
+		redaction := &models.Redaction{ID: "r1", Prefix: "10.99999", Suffix: "a", Redacted: time.Now().UTC()}
+		n, err := dual.RedactRAiD(ctx, "10.99999", "a", contributor.Redact(contributor.Subject{Email: "jane@example.org"}, redaction), redaction)
+		if err != nil || n != 3 {
+			t.Fatalf("expected 3 versions redacted, got %d, %v", n, err)
+		}
+		for _, backend := range []storage.Redactor{source, target} {
+			redactions, err := backend.ListRedactions(ctx)
+			if err != nil || len(redactions) != 1 || redactions[0].Versions != 3 {
+				t.Errorf("expected the redaction recorded in both backends, got %+v, %v", redactions, err)
+			}
+		}
+		raid, err := target.GetRAiDVersion(ctx, "10.99999", "a", 1)
+		if err != nil || raid.Contributor[0].Email != "" {
+			t.Errorf("expected the target's versions redacted, got %+v, %v", raid, err)
+		}
+	})
+
+	t.Run("compaction", func(t *testing.T) {
+		n, err := dual.CompactRAiD(ctx, "10.99999", "b", storage.CompactionPolicy{KeepVersions: 1})
+		if err != nil || n != 2 {
+			t.Fatalf("expected 2 versions archived, got %d, %v", n, err)
+		}
+		if n, err := target.RehydrateRAiD(ctx, "10.99999", "b"); err != nil || n != 2 {
+			t.Errorf("expected the target compacted, got %d, %v", n, err)
+		}
+		if n, err := dual.RehydrateRAiD(ctx, "10.99999", "b"); err != nil || n != 2 {
+			t.Errorf("expected the source rehydrated, got %d, %v", n, err)
+		}
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		if err := dual.DeleteRAiD(ctx, "10.99999", "b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := dual.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: "b", Reason: "test"}); err != nil {
+			t.Fatal(err)
+		}
+		for _, backend := range []storage.Purger{source, target} {
+			purges, err := backend.ListPurges(ctx)
+			if err != nil || len(purges) != 1 {
+				t.Errorf("expected the purge recorded in both backends, got %+v, %v", purges, err)
+			}
+		}
+		if _, err := target.GetRAiDVersion(ctx, "10.99999", "b", 1); err != storage.ErrNotFound {
+			t.Errorf("expected the RAiD purged from the target, got %v", err)
+		}
+
+		// A purge the target cannot take diverges without failing
+		if _, err := source.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/old"}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := source.DeleteRAiD(ctx, "10.99999", "old"); err != nil {
+			t.Fatal(err)
+		}
+		if err := dual.PurgeRAiD(ctx, &models.Purge{Prefix: "10.99999", Suffix: "old", Reason: "test"}); err != nil {
+			t.Errorf("expected a purge the target cannot take to succeed, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Classes of errors returned by backends and the repositories wrapping
+// them, matched with errors.Is. Handlers map each to an HTTP status instead
+// of passing backend messages on to clients.
+var (
+	// ErrValidation is matched by a *ValidationError
+	ErrValidation = errors.New("validation failed")
+	// ErrConflict is matched by a *ConflictError
+	ErrConflict = errors.New("conflicting write")
+	// ErrBackendUnavailable is matched by an *UnavailableError
+	ErrBackendUnavailable = errors.New("storage backend unavailable")
+)
+
+// ValidationError reports a RAiD breaking the metadata schema rules, with
+// each failure
+type ValidationError struct {
+	Failures []models.ValidationFailure
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = f.FieldID + ": " + f.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// QuotaError reports a mint for a service point that owns as many RAiDs as
+// its quota allows
+type QuotaError struct {
+	ServicePoint int64
+	Max          int
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("service point %d has reached its quota of %d RAiDs", e.ServicePoint, e.Max)
+}
+
+// ConflictError reports an update made to a version of a RAiD other than
+// the current one, which would overwrite changes the writer has not seen
+type ConflictError struct {
+	// Current is the current version of the RAiD
+	Current int
+	// Given is the version the update was made to
+	Given int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("update of version %d conflicts with current version %d", e.Given, e.Current)
+}
+
+// Is matches ErrConflict and, for callers predating it, ErrInvalidVersion
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict || target == ErrInvalidVersion
+}
+
+// CheckVersion returns a *ConflictError if raid names a version other than
+// current, the version being replaced. Updates naming no version replace
+// whatever is current.
+func CheckVersion(raid *models.RAiD, current int) error {
+	if raid.Identifier == nil || raid.Identifier.Version == 0 || raid.Identifier.Version == current {
+		return nil
+	}
+	return &ConflictError{Current: current, Given: raid.Identifier.Version}
+}
+
+// UnavailableError reports a backend failing rather than refusing the
+// request, as judged by IsUnavailable
+type UnavailableError struct {
+	Err error
+}
+
+func (e *UnavailableError) Error() string {
+	return ErrBackendUnavailable.Error() + ": " + e.Err.Error()
+}
+
+func (e *UnavailableError) Is(target error) bool {
+	return target == ErrBackendUnavailable
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// Unavailable wraps err in an *UnavailableError if it suggests the backend
+// is failing, and returns it unchanged otherwise
+func Unavailable(err error) error {
+	if err == nil || errors.Is(err, ErrBackendUnavailable) || !IsUnavailable(err) {
+		return err
+	}
+	return &UnavailableError{Err: err}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestCheckVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		raid     *models.RAiD
+		conflict bool
+	}{
+		{"no identifier", &models.RAiD{}, false},
+		{"no version", &models.RAiD{Identifier: &models.Identifier{}}, false},
+		{"current version", &models.RAiD{Identifier: &models.Identifier{Version: 3}}, false},
+		{"stale version", &models.RAiD{Identifier: &models.Identifier{Version: 2}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckVersion(tt.raid, 3)
+			if got := errors.Is(err, ErrConflict); got != tt.conflict {
+				t.Fatalf("expected conflict %t, got %v", tt.conflict, err)
+			}
+			if tt.conflict && !errors.Is(err, ErrInvalidVersion) {
+				t.Error("expected a conflict to match ErrInvalidVersion")
+			}
+		})
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	err := Unavailable(fmt.Errorf("query: %w", context.DeadlineExceeded))
+	if !errors.Is(err, ErrBackendUnavailable) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected a timeout to be wrapped as unavailable, got %v", err)
+	}
+	if Unavailable(err) != err {
+		t.Error("expected an unavailable error to be returned unchanged")
+	}
+	if err := Unavailable(ErrNotFound); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound to be returned unchanged, got %v", err)
+	}
+}
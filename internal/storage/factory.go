@@ -2,6 +2,7 @@ package storage
 
 import (
 	"fmt"
+	"time"
 )
 
 // StorageType defines the type of storage backend
@@ -20,45 +21,73 @@ const (
 
 // StorageConfig holds configuration for all storage types
 type StorageConfig struct {
-	Type StorageType
+	Type StorageType `yaml:"type" toml:"type"`
 
 	// File storage configuration
-	File *FileConfig
+	File *FileConfig `yaml:"file" toml:"file"`
 
 	// FoundationDB configuration
-	FDB *FDBConfig
+	FDB *FDBConfig `yaml:"fdb" toml:"fdb"`
 
 	// CockroachDB configuration
-	Cockroach *CockroachConfig
+	Cockroach *CockroachConfig `yaml:"cockroach" toml:"cockroach"`
+
+	// Options configures backends registered out of tree, which receive
+	// them unchanged
+	Options map[string]interface{} `yaml:"options" toml:"options"`
+
+	// Migration, when set, opens a dual-write window: writes are mirrored
+	// to its target backend, which serves a share of reads
+	Migration *MigrationConfig `yaml:"migration" toml:"migration"`
 }
 
 // FileConfig holds file storage configuration
 type FileConfig struct {
-	DataDir string
+	DataDir string `yaml:"dataDir" toml:"dataDir"`
 	// Git configuration (optional)
-	GitEnabled     bool
-	GitAutoCommit  bool
-	GitAuthorName  string
-	GitAuthorEmail string
+	GitEnabled     bool   `yaml:"gitEnabled" toml:"gitEnabled"`
+	GitAutoCommit  bool   `yaml:"gitAutoCommit" toml:"gitAutoCommit"`
+	GitAuthorName  string `yaml:"gitAuthorName" toml:"gitAuthorName"`
+	GitAuthorEmail string `yaml:"gitAuthorEmail" toml:"gitAuthorEmail"`
+	// Lock coordinates instances sharing the data directory: "exclusive"
+	// (default) fails at startup while another instance holds it, "wait"
+	// stands by until it is released, "none" disables coordination
+	Lock     string        `yaml:"lock" toml:"lock"`
+	LeaseTTL time.Duration `yaml:"leaseTTL" toml:"leaseTTL"`
 }
 
 // FDBConfig holds FoundationDB configuration
 type FDBConfig struct {
-	ClusterFile string
-	APIVersion  int
+	ClusterFile string `yaml:"clusterFile" toml:"clusterFile"`
+	APIVersion  int    `yaml:"apiVersion" toml:"apiVersion"`
+	// Encoding is the encoding records are stored in, "json" or
+	// "msgpack"; values in either encoding are read regardless
+	Encoding string `yaml:"encoding" toml:"encoding"`
 }
 
 // CockroachConfig holds CockroachDB configuration
 type CockroachConfig struct {
-	Host     string
-	Port     int
-	Database string
-	User     string
-	Password string
-	SSLMode  string
-	SSLCert  string
-	SSLKey   string
-	SSLRoot  string
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Database string `yaml:"database" toml:"database"`
+	User     string `yaml:"user" toml:"user"`
+	Password string `yaml:"password" toml:"password"`
+	SSLMode  string `yaml:"sslMode" toml:"sslMode"`
+	SSLCert  string `yaml:"sslCert" toml:"sslCert"`
+	SSLKey   string `yaml:"sslKey" toml:"sslKey"`
+	SSLRoot  string `yaml:"sslRoot" toml:"sslRoot"`
+	// PasswordRefresh controls how often a password given as a secret
+	// reference is re-read, so rotated credentials are used by new connections
+	PasswordRefresh time.Duration `yaml:"passwordRefresh" toml:"passwordRefresh"`
+	// FollowerReads serves listings from the nearest replica as of a few
+	// seconds ago instead of the leaseholder
+	FollowerReads bool `yaml:"followerReads" toml:"followerReads"`
+	// Regions makes the database multi-region with these regions, the
+	// first being primary, homing RAiDs in their service point's region
+	Regions []string `yaml:"regions" toml:"regions"`
+	// Region is the region this instance runs in, whose nodes Host should
+	// reach; its mints draw on suffixes leased to the region
+	Region string `yaml:"region" toml:"region"`
 }
 
 // RepositoryFactory is a function type for creating repositories
@@ -71,8 +100,39 @@ func RegisterFactory(storageType StorageType, factory RepositoryFactory) {
 	factories[storageType] = factory
 }
 
-// NewRepository creates a new storage repository based on configuration
+// Registered reports whether a factory is registered for a storage type
+func Registered(storageType StorageType) bool {
+	_, ok := factories[storageType]
+	return ok
+}
+
+// NewRepository creates a new storage repository based on configuration.
+// With a migration configured it opens both backends and returns a
+// DualWrite over them.
 func NewRepository(cfg *StorageConfig) (Repository, error) {
+	if m := cfg.Migration; m != nil && m.Target != nil {
+		source := *cfg
+		source.Migration = nil
+		repo, err := NewRepository(&source)
+		if err != nil {
+			return nil, err
+		}
+		target := *m.Target
+		target.Migration = nil
+		targetRepo, err := NewRepository(&target)
+		if err != nil {
+			repo.Close()
+			return nil, fmt.Errorf("migration target: %w", err)
+		}
+		dual, err := NewDualWrite(repo, targetRepo, m.ReadPercent)
+		if err != nil {
+			repo.Close()
+			targetRepo.Close()
+			return nil, err
+		}
+		return dual, nil
+	}
+
 	factory, ok := factories[cfg.Type]
 	if !ok {
 		return nil, fmt.Errorf("unknown storage type: %s (not registered)", cfg.Type)
@@ -88,7 +148,7 @@ func NewRepository(cfg *StorageConfig) (Repository, error) {
 	case StorageTypeCockroach:
 		config = cfg.Cockroach
 	default:
-		return nil, fmt.Errorf("unknown storage type: %s", cfg.Type)
+		config = cfg.Options
 	}
 
 	return factory(config)
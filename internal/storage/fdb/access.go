@@ -0,0 +1,182 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The access directory indexes current RAiDs by access type, with empty
+// values under (access type ID, prefix, suffix) keys, so that public RAiDs
+// are listed without reading the others.
+
+// accessType returns the access type ID of raid, or "" if it has none
+func accessType(raid *models.RAiD) string {
+	if raid == nil || raid.Access == nil || raid.Access.Type == nil {
+		return ""
+	}
+	return raid.Access.Type.ID
+}
+
+// setAccessIndex replaces the index entry of a RAiD whose access type moves
+// from previous to current; nil stands for a RAiD that is not current
+func (fs *FDBStorage) setAccessIndex(tr fdb.Transaction, prefix, suffix string, previous, current *models.RAiD) {
+	if previous != nil {
+		tr.Clear(fs.accessDir.Pack(tuple.Tuple{accessType(previous), prefix, suffix}))
+	}
+	if current != nil {
+		tr.Set(fs.accessDir.Pack(tuple.Tuple{accessType(current), prefix, suffix}), []byte{})
+	}
+}
+
+// buildAccessIndex indexes every current RAiD. It runs when the access
+// directory is first created, so that data written by earlier versions is
+// listed.
+func (fs *FDBStorage) buildAccessIndex(ctx context.Context) error {
+	var batch []fdb.KeyValue
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			for _, kv := range batch {
+				t, err := fs.raidDir.Unpack(kv.Key)
+				if err != nil {
+					continue
+				}
+				var raid models.RAiD
+				if err := fs.unmarshal(kv.Value, &raid); err != nil {
+					continue
+				}
+				prefix, _ := t[0].(string)
+				suffix, _ := t[1].(string)
+				fs.setAccessIndex(tr, prefix, suffix, nil, &raid)
+			}
+			return nil, nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	var flushErr error
+	err := fs.scanRange(ctx, fs.raidDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		t, err := fs.raidDir.Unpack(kv.Key)
+		if err != nil || len(t) != 3 || t[2] != "current" || flushErr != nil {
+			return
+		}
+		batch = append(batch, kv)
+		if len(batch) == exportBatchSize {
+			flushErr = flush()
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if flushErr != nil {
+		return flushErr
+	}
+	return flush()
+}
+
+// publicBatch is one transaction's worth of public RAiDs
+type publicBatch struct {
+	raids []*models.RAiD
+	// last is the last index key read and more whether keys may follow it
+	last fdb.Key
+	more bool
+}
+
+// ListPublicRAiDs lists only public RAiDs. It walks the access index for
+// the open access type in batches, loading each RAiD it names, and stops
+// once the page is full.
+func (fs *FDBStorage) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	var offset, limit int
+	if filter != nil {
+		offset, limit = filter.Offset, filter.Limit
+	}
+
+	index := fs.accessDir.Pack(tuple.Tuple{storage.AccessTypeOpen})
+	begin := fdb.Key(append(append([]byte{}, index...), 0x00))
+	end := fdb.Key(append(append([]byte{}, index...), 0xFF))
+	if filter != nil && filter.Cursor != "" {
+		// Index keys sort by handle, so the listing resumes right after
+		// the cursor's key
+		prefix, suffix, err := identifier.ParseCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		after := fs.accessDir.Pack(tuple.Tuple{storage.AccessTypeOpen, prefix, suffix})
+		begin = fdb.Key(append(append([]byte{}, after...), 0x00))
+	}
+
+	raids := make([]*models.RAiD, 0)
+	skipped := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+			kvs, err := rtr.GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{Limit: exportBatchSize}).GetSliceWithError()
+			if err != nil {
+				return nil, err
+			}
+
+			futures := make([]fdb.FutureByteSlice, 0, len(kvs))
+			for _, kv := range kvs {
+				t, err := fs.accessDir.Unpack(kv.Key)
+				if err != nil || len(t) != 3 {
+					continue
+				}
+				futures = append(futures, rtr.Get(fs.raidDir.Pack(tuple.Tuple{t[1], t[2], "current"})))
+			}
+
+			batch := &publicBatch{raids: make([]*models.RAiD, 0, len(futures)), more: len(kvs) == exportBatchSize}
+			if len(kvs) > 0 {
+				batch.last = kvs[len(kvs)-1].Key
+			}
+			for _, f := range futures {
+				data, err := f.Get()
+				if err != nil {
+					return nil, err
+				}
+				if data == nil {
+					continue
+				}
+				var raid models.RAiD
+				if err := fs.unmarshal(data, &raid); err != nil {
+					continue
+				}
+				batch.raids = append(batch.raids, &raid)
+			}
+			return batch, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		batch := result.(*publicBatch)
+
+		for _, raid := range applyFilters(batch.raids, filter) {
+			if skipped < offset {
+				skipped++
+				continue
+			}
+			raids = append(raids, raid)
+			if limit > 0 && len(raids) == limit {
+				return raids, nil
+			}
+		}
+
+		if !batch.more {
+			return raids, nil
+		}
+		begin = fdb.Key(append(append([]byte{}, batch.last...), 0x00))
+	}
+}
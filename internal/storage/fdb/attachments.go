@@ -0,0 +1,108 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The blob directory holds attachment content under (hash, chunk), split
+// into chunks below the value size limit and written in one transaction,
+// so content must stay under the transaction size limit (10 MB). The
+// attachment directory holds attachments under (prefix, suffix, hash).
+
+// blobChunkSize is the size of the chunks content is stored in
+const blobChunkSize = 64 << 10
+
+// PutBlob stores attachment content
+func (fs *FDBStorage) PutBlob(ctx context.Context, hash string, data []byte) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if tr.Get(fs.blobDir.Pack(tuple.Tuple{hash, 0})).MustGet() != nil {
+			return nil, nil
+		}
+		// Empty content is held as one empty chunk
+		for i := 0; i == 0 || i*blobChunkSize < len(data); i++ {
+			chunk := data[i*blobChunkSize : min((i+1)*blobChunkSize, len(data))]
+			tr.Set(fs.blobDir.Pack(tuple.Tuple{hash, i}), chunk)
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// GetBlob retrieves attachment content
+func (fs *FDBStorage) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	var data []byte
+	found := false
+	err := fs.scanRange(ctx, fs.blobDir.Pack(tuple.Tuple{hash}), func(kv fdb.KeyValue) {
+		found = true
+		data = append(data, kv.Value...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, storage.ErrNotFound
+	}
+	return data, nil
+}
+
+// SaveAttachment stores an attachment
+func (fs *FDBStorage) SaveAttachment(ctx context.Context, attachment *models.Attachment) error {
+	data, err := fs.marshal(attachment)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.attachmentDir.Pack(tuple.Tuple{attachment.Prefix, attachment.Suffix, attachment.Hash}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// GetAttachment retrieves an attachment
+func (fs *FDBStorage) GetAttachment(ctx context.Context, prefix, suffix, hash string) (*models.Attachment, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		data := rtr.Get(fs.attachmentDir.Pack(tuple.Tuple{prefix, suffix, hash})).MustGet()
+		if data == nil {
+			return nil, storage.ErrNotFound
+		}
+		var attachment models.Attachment
+		if err := fs.unmarshal(data, &attachment); err != nil {
+			return nil, err
+		}
+		return &attachment, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Attachment), nil
+}
+
+// ListAttachments retrieves the attachments of a RAiD, ordered by hash
+func (fs *FDBStorage) ListAttachments(ctx context.Context, prefix, suffix string) ([]*models.Attachment, error) {
+	attachments := make([]*models.Attachment, 0)
+	err := fs.scanRange(ctx, fs.attachmentDir.Pack(tuple.Tuple{prefix, suffix}), func(kv fdb.KeyValue) {
+		var attachment models.Attachment
+		if err := fs.unmarshal(kv.Value, &attachment); err != nil {
+			return
+		}
+		attachments = append(attachments, &attachment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// Verify FDBStorage can hold attachments and their content
+var (
+	_ storage.BlobStore       = (*FDBStorage)(nil)
+	_ storage.AttachmentStore = (*FDBStorage)(nil)
+)
@@ -0,0 +1,129 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// setChange stores the change a version made under (prefix, suffix,
+// "change", version)
+func (fs *FDBStorage) setChange(tr fdb.Transaction, prefix, suffix string, change *storage.VersionChange) error {
+	data, err := fs.marshal(change)
+	if err != nil {
+		return err
+	}
+	tr.Set(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "change", change.Version}), data)
+	return nil
+}
+
+// recordChange stores the change raid, a new version, made to prev, the
+// version before it or nil for a new RAiD
+func (fs *FDBStorage) recordChange(ctx context.Context, tr fdb.Transaction, prefix, suffix string, prev, raid *models.RAiD) error {
+	var chain string
+	if prev != nil {
+		chain = fs.storedChange(tr, prefix, suffix, prev.Identifier.Version).Chain
+	}
+	change, err := storage.RecordVersion(ctx, prev, raid, chain)
+	if err != nil {
+		return err
+	}
+	return fs.setChange(tr, prefix, suffix, change)
+}
+
+// storedHash returns the stored content hash of version of a RAiD, empty
+// if none was stored
+func (fs *FDBStorage) storedHash(tr fdb.Transaction, prefix, suffix string, version int) string {
+	return fs.storedChange(tr, prefix, suffix, version).Hash
+}
+
+// storedChange returns the stored change of version of a RAiD, empty if
+// none was stored
+func (fs *FDBStorage) storedChange(tr fdb.Transaction, prefix, suffix string, version int) storage.VersionChange {
+	data := tr.Get(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "change", version})).MustGet()
+	var change storage.VersionChange
+	if data == nil || fs.unmarshal(data, &change) != nil {
+		return storage.VersionChange{}
+	}
+	return change
+}
+
+// changePage is a page of stored changes and the newest version of the RAiD
+type changePage struct {
+	changes []storage.VersionChange
+	latest  int
+}
+
+// GetRAiDChanges reads a page of the stored changes of a RAiD with one
+// range read, diffing its history if the page misses some
+func (fs *FDBStorage) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		// The newest version key names the version the changes run up to
+		versionPrefix := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version"})
+		newest := rtr.GetRange(fdb.KeyRange{
+			Begin: fdb.Key(append(append([]byte{}, versionPrefix...), 0x00)),
+			End:   fdb.Key(append(append([]byte{}, versionPrefix...), 0xFF)),
+		}, fdb.RangeOptions{Limit: 1, Reverse: true})
+
+		keyPrefix := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "change"})
+		begin := fdb.Key(append(append([]byte{}, keyPrefix...), 0x00))
+		opts := fdb.RangeOptions{}
+		if page != nil {
+			if page.After > 0 {
+				begin = fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "change", page.After + 1})
+			}
+			opts.Limit = page.Limit
+		}
+		kvs, err := rtr.GetRange(fdb.KeyRange{
+			Begin: begin,
+			End:   fdb.Key(append(append([]byte{}, keyPrefix...), 0xFF)),
+		}, opts).GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+
+		result := &changePage{changes: make([]storage.VersionChange, 0, len(kvs))}
+		for _, kv := range kvs {
+			var change storage.VersionChange
+			if err := fs.unmarshal(kv.Value, &change); err != nil {
+				return nil, err
+			}
+			result.changes = append(result.changes, change)
+		}
+		last, err := newest.GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+		if len(last) == 1 {
+			if t, err := fs.raidDir.Unpack(last[0].Key); err == nil && len(t) == 4 {
+				version, _ := t[3].(int64)
+				result.latest = int(version)
+			}
+		}
+		return result, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := result.(*changePage)
+	if storage.PageComplete(result.changes, page, result.latest) {
+		return storage.PageChanges(result.changes, page), nil
+	}
+
+	history, err := fs.GetRAiDHistory(ctx, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := storage.DiffHistory(history)
+	if err != nil {
+		return nil, err
+	}
+	return storage.PageChanges(changes, page), nil
+}
@@ -0,0 +1,104 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+)
+
+// storedVersions reads the value of every version key of a RAiD as stored,
+// archived or not, in ascending order. Values are decoded to JSON whatever
+// their storage encoding.
+func (fs *FDBStorage) storedVersions(rtr fdb.ReadTransaction, prefix, suffix string) ([]storage.StoredVersion, error) {
+	keyPrefix := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version"})
+	kvs, err := rtr.GetRange(fdb.KeyRange{
+		Begin: fdb.Key(append(append([]byte{}, keyPrefix...), 0x00)),
+		End:   fdb.Key(append(append([]byte{}, keyPrefix...), 0xFF)),
+	}, fdb.RangeOptions{}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]storage.StoredVersion, 0, len(kvs))
+	for _, kv := range kvs {
+		t, err := fs.raidDir.Unpack(kv.Key)
+		if err != nil || len(t) != 4 {
+			continue
+		}
+		version, _ := t[3].(int64)
+		data, err := codec.Decode(kv.Value)
+		if err != nil {
+			return nil, fmt.Errorf("version %d: %w", version, err)
+		}
+		versions = append(versions, storage.StoredVersion{Version: int(version), Data: data})
+	}
+	return versions, nil
+}
+
+// CompactRAiD replaces the version keys policy selects with archived diffs
+func (fs *FDBStorage) CompactRAiD(ctx context.Context, prefix, suffix string, policy storage.CompactionPolicy) (int, error) {
+	return fs.rewriteVersions(prefix, suffix, func(stored []storage.StoredVersion) ([]storage.StoredVersion, error) {
+		return storage.CompactVersions(stored, policy, time.Now())
+	})
+}
+
+// RehydrateRAiD stores the archived version keys of a RAiD as full
+// documents again
+func (fs *FDBStorage) RehydrateRAiD(ctx context.Context, prefix, suffix string) (int, error) {
+	return fs.rewriteVersions(prefix, suffix, func(stored []storage.StoredVersion) ([]storage.StoredVersion, error) {
+		full, err := storage.RehydrateVersions(stored)
+		if err != nil {
+			return nil, err
+		}
+		var rehydrated []storage.StoredVersion
+		for i, v := range stored {
+			if storage.IsArchived(v.Data) {
+				rehydrated = append(rehydrated, full[i])
+			}
+		}
+		return rehydrated, nil
+	})
+}
+
+// rewriteVersions replaces the version keys that rewrite returns for the
+// stored versions of a RAiD, in one transaction
+func (fs *FDBStorage) rewriteVersions(prefix, suffix string, rewrite func([]storage.StoredVersion) ([]storage.StoredVersion, error)) (int, error) {
+	result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if tr.Get(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "current"})).MustGet() == nil &&
+			tr.Get(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "deleted"})).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+
+		stored, err := fs.storedVersions(tr, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := rewrite(stored)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range changed {
+			data, err := codec.Encode(fs.encoding, v.Data)
+			if err != nil {
+				return nil, err
+			}
+			tr.Set(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version", v.Version}), data)
+		}
+		return len(changed), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// Verify FDBStorage supports compaction
+var _ storage.VersionCompactor = (*FDBStorage)(nil)
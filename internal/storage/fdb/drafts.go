@@ -0,0 +1,82 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The draft directory holds drafts under their ID. Listing the drafts of
+// a service point reads them all.
+
+// SaveDraft stores a draft RAiD
+func (fs *FDBStorage) SaveDraft(ctx context.Context, draft *models.Draft) error {
+	data, err := fs.marshal(draft)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.draftDir.Pack(tuple.Tuple{draft.ID}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// GetDraft retrieves a draft RAiD
+func (fs *FDBStorage) GetDraft(ctx context.Context, id string) (*models.Draft, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		data := rtr.Get(fs.draftDir.Pack(tuple.Tuple{id})).MustGet()
+		if data == nil {
+			return nil, storage.ErrNotFound
+		}
+		var draft models.Draft
+		if err := fs.unmarshal(data, &draft); err != nil {
+			return nil, err
+		}
+		return &draft, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Draft), nil
+}
+
+// ListDrafts retrieves the drafts of a service point
+func (fs *FDBStorage) ListDrafts(ctx context.Context, servicePoint int64) ([]*models.Draft, error) {
+	drafts := make([]*models.Draft, 0)
+	err := fs.scanRange(ctx, fs.draftDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		var draft models.Draft
+		if err := fs.unmarshal(kv.Value, &draft); err != nil {
+			return
+		}
+		if draft.ServicePoint == servicePoint {
+			drafts = append(drafts, &draft)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// DeleteDraft removes a draft RAiD
+func (fs *FDBStorage) DeleteDraft(ctx context.Context, id string) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		key := fs.draftDir.Pack(tuple.Tuple{id})
+		if tr.Get(key).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can keep drafts
+var _ storage.DraftStore = (*FDBStorage)(nil)
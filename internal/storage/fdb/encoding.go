@@ -0,0 +1,95 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+)
+
+// marshal encodes v for storage in the configured encoding
+func (fs *FDBStorage) marshal(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return codec.Encode(fs.encoding, data)
+}
+
+// unmarshal decodes a stored value in any encoding into v
+func (fs *FDBStorage) unmarshal(data []byte, v any) error {
+	doc, err := codec.Decode(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(doc, v)
+}
+
+// ConvertEncoding rewrites every RAiD and service point value not stored in
+// the configured encoding, in batches of exportBatchSize keys per
+// transaction. Values are read in either encoding throughout, so the
+// server can keep running while data is converted.
+func (fs *FDBStorage) ConvertEncoding(ctx context.Context) (int, error) {
+	converted := 0
+	for _, prefix := range [][]byte{fs.raidDir.Pack(tuple.Tuple{}), fs.servicePointDir.Pack(tuple.Tuple{})} {
+		var batch []fdb.KeyValue
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+				for _, kv := range batch {
+					// Skip values changed since they were scanned
+					data := tr.Get(kv.Key).MustGet()
+					if data == nil || codec.FormatOf(data) == fs.encoding {
+						continue
+					}
+					doc, err := codec.Decode(data)
+					if err != nil {
+						return nil, err
+					}
+					if data, err = codec.Encode(fs.encoding, doc); err != nil {
+						return nil, err
+					}
+					tr.Set(kv.Key, data)
+				}
+				return nil, nil
+			})
+			if err == nil {
+				converted += len(batch)
+			}
+			batch = batch[:0]
+			return err
+		}
+
+		var flushErr error
+		err := fs.scanRange(ctx, prefix, func(kv fdb.KeyValue) {
+			if flushErr != nil || codec.FormatOf(kv.Value) == fs.encoding {
+				return
+			}
+			batch = append(batch, kv)
+			if len(batch) == exportBatchSize {
+				flushErr = flush()
+			}
+		})
+		if err != nil {
+			return converted, err
+		}
+		if flushErr != nil {
+			return converted, flushErr
+		}
+		if err := flush(); err != nil {
+			return converted, err
+		}
+	}
+	return converted, nil
+}
+
+// Verify FDBStorage can convert its storage encoding
+var _ storage.EncodingConverter = (*FDBStorage)(nil)
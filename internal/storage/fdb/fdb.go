@@ -4,17 +4,23 @@
 package fdb
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/apple/foundationdb/bindings/go/src/fdb"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/directory"
 	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/identifier"
 	"github.com/leifj/go-raid/internal/models"
 	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+	"github.com/leifj/go-raid/internal/textnorm"
 )
 
 func init() {
@@ -24,11 +30,35 @@ func init() {
 		if !ok || fdbCfg == nil {
 			fdbCfg = &storage.FDBConfig{}
 		}
+		encoding, err := codec.ParseFormat(fdbCfg.Encoding)
+		if err != nil {
+			return nil, err
+		}
 		return New(&Config{
 			ClusterFile: fdbCfg.ClusterFile,
 			APIVersion:  fdbCfg.APIVersion,
+			Encoding:    encoding,
 		})
 	})
+
+	// Transactions are retried by Transact on conflicts; these escape it
+	// when the cluster is briefly overloaded or recovering. An unknown
+	// commit result (1021) is not retried, as the write may have succeeded.
+	storage.RegisterTransient(func(err error) bool {
+		var fdbErr fdb.Error
+		if !errors.As(err, &fdbErr) {
+			return false
+		}
+		switch fdbErr.Code {
+		case 1007, // transaction_too_old
+			1009, // future_version
+			1020, // not_committed
+			1037, // process_behind
+			1213: // tag_throttled
+			return true
+		}
+		return false
+	})
 }
 
 // FDBStorage implements storage.Repository using FoundationDB
@@ -37,12 +67,30 @@ type FDBStorage struct {
 	raidDir         directory.DirectorySubspace
 	servicePointDir directory.DirectorySubspace
 	counterDir      directory.DirectorySubspace
+	accessDir       directory.DirectorySubspace
+	searchDir       directory.DirectorySubspace
+	tagDir          directory.DirectorySubspace
+	draftDir        directory.DirectorySubspace
+	reservationDir  directory.DirectorySubspace
+	scheduleDir     directory.DirectorySubspace
+	proposalDir     directory.DirectorySubspace
+	followerDir     directory.DirectorySubspace
+	attachmentDir   directory.DirectorySubspace
+	blobDir         directory.DirectorySubspace
+	purgeDir        directory.DirectorySubspace
+	redactionDir    directory.DirectorySubspace
+	statsDir        directory.DirectorySubspace
+	prefixes        storage.PrefixAllocator
+	servicePoints   storage.ServicePointCache
+	encoding        codec.Format
 }
 
 // Config holds FoundationDB configuration
 type Config struct {
 	ClusterFile string // Path to fdb.cluster file, empty for default
 	APIVersion  int    // FDB API version, 0 for latest
+	// Encoding is the encoding records are written in; JSON if empty
+	Encoding codec.Format
 }
 
 // New creates a new FoundationDB storage instance
@@ -72,20 +120,28 @@ func New(cfg *Config) (*FDBStorage, error) {
 	}
 
 	fs := &FDBStorage{
-		db: db,
+		db:       db,
+		encoding: cmp.Or(cfg.Encoding, codec.JSON),
 	}
 
 	// Initialize directory structure
-	if err := fs.initDirectories(); err != nil {
+	indexed, err := fs.initDirectories()
+	if err != nil {
 		return nil, err
 	}
+	if !indexed {
+		if err := fs.buildAccessIndex(context.Background()); err != nil {
+			return nil, fmt.Errorf("failed to build FDB access index: %w", err)
+		}
+	}
 
 	return fs, nil
 }
 
-// Initialize directory structure in FDB
-func (fs *FDBStorage) initDirectories() error {
-	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+// Initialize directory structure in FDB, reporting whether the access
+// index already existed
+func (fs *FDBStorage) initDirectories() (bool, error) {
+	indexed, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
 		// Create raid directory
 		raidDir, err := directory.CreateOrOpen(tr, []string{"raid"}, nil)
 		if err != nil {
@@ -107,38 +163,123 @@ func (fs *FDBStorage) initDirectories() error {
 		}
 		fs.counterDir = counterDir
 
-		return nil, nil
+		// Create saved search directory
+		searchDir, err := directory.CreateOrOpen(tr, []string{"search"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.searchDir = searchDir
+
+		// Create tag directory
+		tagDir, err := directory.CreateOrOpen(tr, []string{"tag"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.tagDir = tagDir
+
+		// Create draft directory
+		draftDir, err := directory.CreateOrOpen(tr, []string{"draft"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.draftDir = draftDir
+
+		// Create reservation directory
+		reservationDir, err := directory.CreateOrOpen(tr, []string{"reservation"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.reservationDir = reservationDir
+
+		// Create scheduled change directory
+		scheduleDir, err := directory.CreateOrOpen(tr, []string{"schedule"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.scheduleDir = scheduleDir
+
+		// Create proposal directory
+		proposalDir, err := directory.CreateOrOpen(tr, []string{"proposal"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.proposalDir = proposalDir
+
+		// Create follower directory
+		followerDir, err := directory.CreateOrOpen(tr, []string{"follower"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.followerDir = followerDir
+
+		// Create attachment and blob directories
+		attachmentDir, err := directory.CreateOrOpen(tr, []string{"attachment"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.attachmentDir = attachmentDir
+		blobDir, err := directory.CreateOrOpen(tr, []string{"blob"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.blobDir = blobDir
+
+		// Create purge directory
+		purgeDir, err := directory.CreateOrOpen(tr, []string{"purge"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.purgeDir = purgeDir
+
+		// Create redaction directory
+		redactionDir, err := directory.CreateOrOpen(tr, []string{"redaction"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.redactionDir = redactionDir
+
+		// Create stats directory
+		statsDir, err := directory.CreateOrOpen(tr, []string{"stats"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.statsDir = statsDir
+
+		// Create access type index directory
+		indexed, err := directory.Exists(tr, []string{"access"})
+		if err != nil {
+			return nil, err
+		}
+		accessDir, err := directory.CreateOrOpen(tr, []string{"access"}, nil)
+		if err != nil {
+			return nil, err
+		}
+		fs.accessDir = accessDir
+
+		return indexed, nil
 	})
+	if err != nil {
+		return false, err
+	}
 
-	return err
+	return indexed.(bool), nil
 }
 
 // CreateRAiD creates a new RAiD
 func (fs *FDBStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
 	// Generate identifier if not present
 	if raid.Identifier == nil || raid.Identifier.ID == "" {
-		servicePointID := int64(0)
-		if raid.Identifier != nil && raid.Identifier.Owner != nil {
-			servicePointID = raid.Identifier.Owner.ServicePoint
-		}
-		prefix, suffix, err := fs.GenerateIdentifier(ctx, servicePointID)
-		if err != nil {
-			return nil, err
-		}
-		if raid.Identifier == nil {
-			raid.Identifier = &models.Identifier{}
-		}
-		raid.Identifier.ID = fmt.Sprintf("https://raid.org/%s/%s", prefix, suffix)
+		return storage.MintGenerated(ctx, fs, raid, identifier.FormatDefault)
 	}
 
 	// Extract prefix and suffix
-	prefix, suffix, err := parseRAiDIdentifier(raid.Identifier.ID)
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set metadata
-	now := time.Now()
+	now := storage.WriteTime(ctx)
 	if raid.Metadata == nil {
 		raid.Metadata = &models.Metadata{}
 	}
@@ -160,19 +301,20 @@ func (fs *FDBStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*model
 		}
 
 		// Serialize raid
-		data, err := json.Marshal(raid)
+		data, err := fs.marshal(raid)
 		if err != nil {
 			return nil, err
 		}
 
 		// Store current version
 		tr.Set(key, data)
+		fs.setAccessIndex(tr, prefix, suffix, nil, raid)
 
 		// Store in version history
 		versionKey := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version", raid.Identifier.Version})
 		tr.Set(versionKey, data)
 
-		return nil, nil
+		return nil, fs.recordChange(ctx, tr, prefix, suffix, nil, raid)
 	})
 
 	if err != nil {
@@ -193,7 +335,7 @@ func (fs *FDBStorage) GetRAiD(ctx context.Context, prefix, suffix string) (*mode
 		}
 
 		var raid models.RAiD
-		if err := json.Unmarshal(data, &raid); err != nil {
+		if err := fs.unmarshal(data, &raid); err != nil {
 			return nil, err
 		}
 
@@ -207,6 +349,37 @@ func (fs *FDBStorage) GetRAiD(ctx context.Context, prefix, suffix string) (*mode
 	return result.(*models.RAiD), nil
 }
 
+// GetRAiDs retrieves several RAiDs in one transaction, issuing all reads
+// before waiting on any
+func (fs *FDBStorage) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		futures := make([]fdb.FutureByteSlice, len(refs))
+		for i, ref := range refs {
+			futures[i] = rtr.Get(fs.raidDir.Pack(tuple.Tuple{ref.Prefix, ref.Suffix, "current"}))
+		}
+
+		raids := make([]*models.RAiD, len(refs))
+		for i, future := range futures {
+			data := future.MustGet()
+			if data == nil {
+				continue
+			}
+			var raid models.RAiD
+			if err := fs.unmarshal(data, &raid); err != nil {
+				return nil, err
+			}
+			raids[i] = &raid
+		}
+		return raids, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.([]*models.RAiD), nil
+}
+
 // GetRAiDVersion retrieves a specific version
 func (fs *FDBStorage) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
 	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
@@ -216,6 +389,17 @@ func (fs *FDBStorage) GetRAiDVersion(ctx context.Context, prefix, suffix string,
 		if data == nil {
 			return nil, storage.ErrNotFound
 		}
+		data, err := codec.Decode(data)
+		if err != nil {
+			return nil, err
+		}
+		if storage.IsArchived(data) {
+			stored, err := fs.storedVersions(rtr, prefix, suffix)
+			if err != nil {
+				return nil, err
+			}
+			return storage.RehydrateVersion(stored, version)
+		}
 
 		var raid models.RAiD
 		if err := json.Unmarshal(data, &raid); err != nil {
@@ -234,7 +418,7 @@ func (fs *FDBStorage) GetRAiDVersion(ctx context.Context, prefix, suffix string,
 
 // UpdateRAiD updates a RAiD
 func (fs *FDBStorage) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
-	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+	result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
 		// Load existing
 		key := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "current"})
 		existingData := tr.Get(key).MustGet()
@@ -244,12 +428,24 @@ func (fs *FDBStorage) UpdateRAiD(ctx context.Context, prefix, suffix string, rai
 		}
 
 		var existing models.RAiD
-		if err := json.Unmarshal(existingData, &existing); err != nil {
+		if err := fs.unmarshal(existingData, &existing); err != nil {
+			return nil, err
+		}
+		if err := storage.CheckVersion(raid, existing.Identifier.Version); err != nil {
 			return nil, err
 		}
 
+		// An update repeating the current content adds no version
+		unchanged, err := storage.Unchanged(fs.storedHash(tr, prefix, suffix, existing.Identifier.Version), &existing, raid)
+		if err != nil {
+			return nil, err
+		}
+		if unchanged {
+			return &existing, nil
+		}
+
 		// Update metadata
-		now := time.Now()
+		now := storage.WriteTime(ctx)
 		if raid.Metadata == nil {
 			raid.Metadata = &models.Metadata{}
 		}
@@ -258,26 +454,27 @@ func (fs *FDBStorage) UpdateRAiD(ctx context.Context, prefix, suffix string, rai
 		raid.Identifier.Version = existing.Identifier.Version + 1
 
 		// Serialize
-		data, err := json.Marshal(raid)
+		data, err := fs.marshal(raid)
 		if err != nil {
 			return nil, err
 		}
 
 		// Update current version
 		tr.Set(key, data)
+		fs.setAccessIndex(tr, prefix, suffix, &existing, raid)
 
 		// Store in version history
 		versionKey := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version", raid.Identifier.Version})
 		tr.Set(versionKey, data)
 
-		return nil, nil
+		return raid, fs.recordChange(ctx, tr, prefix, suffix, &existing, raid)
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return raid, nil
+	return result.(*models.RAiD), nil
 }
 
 // ListRAiDs lists RAiDs with filters
@@ -285,28 +482,60 @@ func (fs *FDBStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter)
 	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
 		// Get all current RAiDs
 		prefix := fs.raidDir.Pack(tuple.Tuple{})
+		begin := fdb.Key(append(append([]byte{}, prefix...), 0x00))
+		end := fdb.Key(append(append([]byte{}, prefix...), 0xFF))
 
-		iter := rtr.GetRange(fdb.KeyRange{
-			Begin: fdb.Key(append(prefix, 0x00)),
-			End:   fdb.Key(append(prefix, 0xFF)),
-		}, fdb.RangeOptions{}).Iterator()
+		// ULID suffixes sort by mint time, so the RAiDs minted before
+		// MintedSince are skipped by jumping past them in each prefix.
+		// Counter suffixes sort after the bound and are filtered below.
+		var since string
+		if filter != nil && !filter.MintedSince.IsZero() {
+			since = identifier.ULIDLowerBound(filter.MintedSince)
+		}
 
 		raids := make([]*models.RAiD, 0)
 
-		for iter.Advance() {
-			kv := iter.MustGet()
+		for {
+			iter := rtr.GetRange(fdb.KeyRange{
+				Begin: begin,
+				End:   end,
+			}, fdb.RangeOptions{}).Iterator()
 
-			// Only process "current" keys
-			t, err := fs.raidDir.Unpack(kv.Key)
-			if err != nil {
-				continue
-			}
-			if len(t) >= 3 && t[2].(string) == "current" {
-				var raid models.RAiD
-				if err := json.Unmarshal(kv.Value, &raid); err != nil {
+			skipped := false
+			for iter.Advance() {
+				kv := iter.MustGet()
+
+				// Only process "current" keys
+				t, err := fs.raidDir.Unpack(kv.Key)
+				if err != nil {
+					continue
+				}
+				if len(t) < 3 {
 					continue
 				}
-				raids = append(raids, &raid)
+				if suffix, _ := t[1].(string); since != "" && suffix < since {
+					begin = fs.raidDir.Pack(tuple.Tuple{t[0], since})
+					skipped = true
+					break
+				}
+				deleted := t[2].(string) == "deleted"
+				listed := t[2].(string) == "current" || deleted && filter != nil && filter.IncludeDeleted
+				if filter != nil && filter.DeletedOnly {
+					listed = deleted
+				}
+				if listed {
+					var raid models.RAiD
+					if err := fs.unmarshal(kv.Value, &raid); err != nil {
+						continue
+					}
+					if deleted {
+						storage.MarkDeleted(&raid, fs.deletedAt(rtr, t[0].(string), t[1].(string)))
+					}
+					raids = append(raids, &raid)
+				}
+			}
+			if !skipped {
+				break
 			}
 		}
 
@@ -324,6 +553,7 @@ func (fs *FDBStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter)
 
 	// Apply pagination
 	if filter != nil {
+		raids = identifier.AfterCursor(raids, filter.Cursor)
 		if filter.Offset > 0 && filter.Offset < len(raids) {
 			raids = raids[filter.Offset:]
 		}
@@ -335,39 +565,23 @@ func (fs *FDBStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter)
 	return raids, nil
 }
 
-// ListPublicRAiDs lists only public RAiDs
-func (fs *FDBStorage) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
-	raids, err := fs.ListRAiDs(ctx, filter)
-	if err != nil {
-		return nil, err
-	}
-
-	public := make([]*models.RAiD, 0)
-	for _, raid := range raids {
-		if raid.Access != nil && raid.Access.Type != nil && raid.Access.Type.ID == "https://vocabulary.raid.org/access.type.schema/82" {
-			public = append(public, raid)
-		}
-	}
-
-	return public, nil
-}
-
 // GetRAiDHistory retrieves version history
 func (fs *FDBStorage) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
 	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
-		keyPrefix := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version"})
-
-		iter := rtr.GetRange(fdb.KeyRange{
-			Begin: fdb.Key(append(keyPrefix, 0x00)),
-			End:   fdb.Key(append(keyPrefix, 0xFF)),
-		}, fdb.RangeOptions{}).Iterator()
+		stored, err := fs.storedVersions(rtr, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		full, err := storage.RehydrateVersions(stored)
+		if err != nil {
+			return nil, err
+		}
 
-		history := make([]*models.RAiD, 0)
+		history := make([]*models.RAiD, 0, len(full))
 
-		for iter.Advance() {
-			kv := iter.MustGet()
+		for _, v := range full {
 			var raid models.RAiD
-			if err := json.Unmarshal(kv.Value, &raid); err != nil {
+			if err := json.Unmarshal(v.Data, &raid); err != nil {
 				continue
 			}
 			history = append(history, &raid)
@@ -397,6 +611,13 @@ func (fs *FDBStorage) DeleteRAiD(ctx context.Context, prefix, suffix string) err
 
 		tr.Set(deletedKey, data)
 		tr.Clear(key)
+		tr.Set(fs.purgeDir.Pack(tuple.Tuple{"deleted", prefix, suffix}), tuple.Tuple{time.Now().UnixNano()}.Pack())
+
+		var existing models.RAiD
+		if err := fs.unmarshal(data, &existing); err != nil {
+			return nil, err
+		}
+		fs.setAccessIndex(tr, prefix, suffix, &existing, nil)
 
 		return nil, nil
 	})
@@ -407,13 +628,13 @@ func (fs *FDBStorage) DeleteRAiD(ctx context.Context, prefix, suffix string) err
 // GenerateIdentifier generates a unique identifier
 func (fs *FDBStorage) GenerateIdentifier(ctx context.Context, servicePointID int64) (prefix, suffix string, err error) {
 	// Load service point to get prefix
-	prefix = "10.25.1.1" // Default
+	var sp *models.ServicePoint
 	if servicePointID > 0 {
-		sp, err := fs.GetServicePoint(ctx, servicePointID)
-		if err == nil && sp.Prefix != "" {
-			prefix = sp.Prefix
+		if loaded, err := fs.servicePoints.Get(ctx, servicePointID, fs.GetServicePoint); err == nil {
+			sp = loaded
 		}
 	}
+	prefix = fs.prefixes.Allocate(ctx, sp)
 
 	// Generate suffix using FDB atomic counter
 	result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
@@ -466,7 +687,7 @@ func (fs *FDBStorage) CreateServicePoint(ctx context.Context, sp *models.Service
 		}
 
 		// Serialize
-		data, err := json.Marshal(sp)
+		data, err := fs.marshal(sp)
 		if err != nil {
 			return nil, err
 		}
@@ -493,7 +714,7 @@ func (fs *FDBStorage) GetServicePoint(ctx context.Context, id int64) (*models.Se
 		}
 
 		var sp models.ServicePoint
-		if err := json.Unmarshal(data, &sp); err != nil {
+		if err := fs.unmarshal(data, &sp); err != nil {
 			return nil, err
 		}
 
@@ -521,7 +742,7 @@ func (fs *FDBStorage) UpdateServicePoint(ctx context.Context, id int64, sp *mode
 		}
 
 		// Serialize
-		data, err := json.Marshal(sp)
+		data, err := fs.marshal(sp)
 		if err != nil {
 			return nil, err
 		}
@@ -529,6 +750,7 @@ func (fs *FDBStorage) UpdateServicePoint(ctx context.Context, id int64, sp *mode
 		tr.Set(key, data)
 		return nil, nil
 	})
+	fs.servicePoints.Invalidate(id)
 
 	if err != nil {
 		return nil, err
@@ -552,7 +774,7 @@ func (fs *FDBStorage) ListServicePoints(ctx context.Context) ([]*models.ServiceP
 		for iter.Advance() {
 			kv := iter.MustGet()
 			var sp models.ServicePoint
-			if err := json.Unmarshal(kv.Value, &sp); err != nil {
+			if err := fs.unmarshal(kv.Value, &sp); err != nil {
 				continue
 			}
 			sps = append(sps, &sp)
@@ -575,6 +797,7 @@ func (fs *FDBStorage) DeleteServicePoint(ctx context.Context, id int64) error {
 		tr.Clear(key)
 		return nil, nil
 	})
+	fs.servicePoints.Invalidate(id)
 
 	return err
 }
@@ -596,6 +819,46 @@ func (fs *FDBStorage) HealthCheck(ctx context.Context) error {
 	return err
 }
 
+// Stats reports read and commit latencies measured against the cluster
+// together with client status. Commit latency is measured by writing a key
+// of the stats directory, which holds no data.
+func (fs *FDBStorage) Stats(ctx context.Context) (map[string]interface{}, error) {
+	stats := make(map[string]interface{})
+
+	// Read version latency
+	start := time.Now()
+	_, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.GetReadVersion().Get()
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats["readVersionLatency"] = time.Since(start).String()
+
+	// Single key read latency
+	start = time.Now()
+	_, err = fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.Get(fs.statsDir.Pack(tuple.Tuple{"probe"})).Get()
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats["readLatency"] = time.Since(start).String()
+
+	// Commit latency of a write-only transaction
+	start = time.Now()
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.statsDir.Pack(tuple.Tuple{"probe"}), []byte(time.Now().UTC().Format(time.RFC3339)))
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats["commitLatency"] = time.Since(start).String()
+
+	return stats, nil
+}
+
 // Helper methods
 
 func (fs *FDBStorage) generateServicePointID(ctx context.Context) (int64, error) {
@@ -631,14 +894,6 @@ func (fs *FDBStorage) generateServicePointID(ctx context.Context) (int64, error)
 	return result.(int64), nil
 }
 
-func parseRAiDIdentifier(id string) (prefix, suffix string, err error) {
-	parts := strings.Split(id, "/")
-	if len(parts) < 5 {
-		return "", "", fmt.Errorf("invalid RAiD identifier format: %s", id)
-	}
-	return parts[3], parts[4], nil
-}
-
 func applyFilters(raids []*models.RAiD, filter *storage.RAiDFilter) []*models.RAiD {
 	if filter == nil {
 		return raids
@@ -674,6 +929,66 @@ func applyFilters(raids []*models.RAiD, filter *storage.RAiDFilter) []*models.RA
 			}
 		}
 
+		// Filter by TK and BC labels
+		if filter.HasTraditionalKnowledge != nil && *filter.HasTraditionalKnowledge != (len(raid.TraditionalKnowledge) > 0) {
+			continue
+		}
+		if filter.TraditionalKnowledgeLabelID != "" {
+			found := false
+			for _, label := range raid.TraditionalKnowledge {
+				if label.ID == filter.TraditionalKnowledgeLabelID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		// Filter by subject and subject keyword
+		if filter.SubjectID != "" || filter.SubjectKeyword != "" {
+			found := false
+			for _, subject := range raid.Subject {
+				if !strings.HasPrefix(subject.ID, filter.SubjectID) {
+					continue
+				}
+				if filter.SubjectKeyword == "" || slices.ContainsFunc(subject.Keyword, func(kw models.SubjectKeyword) bool {
+					return textnorm.Matches(kw.Text, filter.SubjectKeyword)
+				}) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		if filter.Text != "" && !textnorm.ContainsText(textnorm.Terms(raid), filter.Text) {
+			continue
+		}
+
+		if filter.ServicePointID != 0 && !storage.OwnedBy(raid, filter.ServicePointID) {
+			continue
+		}
+
+		if !filter.MintedSince.IsZero() && (raid.Metadata == nil || raid.Metadata.Created.Before(filter.MintedSince)) {
+			continue
+		}
+
+		// Filter by related object DOI
+		if filter.RelatedObjectDOI != "" && !slices.ContainsFunc(raid.RelatedObject, func(obj models.RelatedObject) bool {
+			doi, _ := identifier.NormalizeDOI(obj.ID)
+			return doi == filter.RelatedObjectDOI
+		}) {
+			continue
+		}
+
+		if filter.Expression != nil && !storage.MatchesExpression(raid, filter.Expression) {
+			continue
+		}
+
 		filtered = append(filtered, raid)
 	}
 
@@ -682,3 +997,6 @@ func applyFilters(raids []*models.RAiD, filter *storage.RAiDFilter) []*models.RA
 
 // Verify FDBStorage implements storage.Repository
 var _ storage.Repository = (*FDBStorage)(nil)
+
+// Verify FDBStorage exposes diagnostic statistics
+var _ storage.StatsProvider = (*FDBStorage)(nil)
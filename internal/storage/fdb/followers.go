@@ -0,0 +1,60 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The follower directory holds followers under their actor URL.
+
+// SaveFollower stores a follower
+func (fs *FDBStorage) SaveFollower(ctx context.Context, follower *models.Follower) error {
+	data, err := fs.marshal(follower)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.followerDir.Pack(tuple.Tuple{follower.ID}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// ListFollowers retrieves all followers, ordered by ID
+func (fs *FDBStorage) ListFollowers(ctx context.Context) ([]*models.Follower, error) {
+	followers := make([]*models.Follower, 0)
+	err := fs.scanRange(ctx, fs.followerDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		var follower models.Follower
+		if err := fs.unmarshal(kv.Value, &follower); err != nil {
+			return
+		}
+		followers = append(followers, &follower)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return followers, nil
+}
+
+// DeleteFollower removes a follower
+func (fs *FDBStorage) DeleteFollower(ctx context.Context, id string) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		key := fs.followerDir.Pack(tuple.Tuple{id})
+		if tr.Get(key).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can hold followers
+var _ storage.FollowerStore = (*FDBStorage)(nil)
@@ -0,0 +1,83 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The proposal directory holds proposals under their ID. Listing them
+// reads them all.
+
+// SaveProposal stores a proposal
+func (fs *FDBStorage) SaveProposal(ctx context.Context, proposal *models.Proposal) error {
+	data, err := fs.marshal(proposal)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.proposalDir.Pack(tuple.Tuple{proposal.ID}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// GetProposal retrieves a proposal
+func (fs *FDBStorage) GetProposal(ctx context.Context, id string) (*models.Proposal, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		data := rtr.Get(fs.proposalDir.Pack(tuple.Tuple{id})).MustGet()
+		if data == nil {
+			return nil, storage.ErrNotFound
+		}
+		var proposal models.Proposal
+		if err := fs.unmarshal(data, &proposal); err != nil {
+			return nil, err
+		}
+		return &proposal, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Proposal), nil
+}
+
+// ListProposals retrieves the proposals for the RAiDs of a service point,
+// ordered by ID
+func (fs *FDBStorage) ListProposals(ctx context.Context, servicePoint int64) ([]*models.Proposal, error) {
+	proposals := make([]*models.Proposal, 0)
+	err := fs.scanRange(ctx, fs.proposalDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		var proposal models.Proposal
+		if err := fs.unmarshal(kv.Value, &proposal); err != nil {
+			return
+		}
+		if proposal.ServicePoint == servicePoint {
+			proposals = append(proposals, &proposal)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proposals, nil
+}
+
+// DeleteProposal removes a proposal
+func (fs *FDBStorage) DeleteProposal(ctx context.Context, id string) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		key := fs.proposalDir.Pack(tuple.Tuple{id})
+		if tr.Get(key).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can hold proposals
+var _ storage.ProposalStore = (*FDBStorage)(nil)
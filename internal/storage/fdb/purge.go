@@ -0,0 +1,125 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The purge directory keeps when each RAiD was soft deleted under
+// ("deleted", prefix, suffix), as packed Unix nanoseconds, and the purges
+// under ("purge", Unix nanoseconds, prefix, suffix), so they list in the
+// order they were made. RAiDs deleted before it was added have no time.
+
+// keyRange returns the range of the keys below prefix
+func keyRange(prefix []byte) fdb.KeyRange {
+	return fdb.KeyRange{
+		Begin: fdb.Key(append(append([]byte{}, prefix...), 0x00)),
+		End:   fdb.Key(append(append([]byte{}, prefix...), 0xFF)),
+	}
+}
+
+// deletedAt returns when a RAiD was soft deleted, zero if not recorded
+func (fs *FDBStorage) deletedAt(rtr fdb.ReadTransaction, prefix, suffix string) time.Time {
+	data := rtr.Get(fs.purgeDir.Pack(tuple.Tuple{"deleted", prefix, suffix})).MustGet()
+	if data == nil {
+		return time.Time{}
+	}
+	t, err := tuple.Unpack(data)
+	if err != nil || len(t) != 1 {
+		return time.Time{}
+	}
+	nanos, _ := t[0].(int64)
+	return time.Unix(0, nanos)
+}
+
+// PurgeRAiD removes every key of a soft deleted RAiD, its tags and its
+// attachments, recording the purge in the same transaction
+func (fs *FDBStorage) PurgeRAiD(ctx context.Context, purge *models.Purge) error {
+	// Tags are keyed by service point first, so those of the RAiD are
+	// looked up under each
+	servicePoints, err := fs.ListServicePoints(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		prefix, suffix := purge.Prefix, purge.Suffix
+		if tr.Get(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "deleted"})).MustGet() == nil {
+			if tr.Get(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "current"})).MustGet() != nil {
+				return nil, storage.ErrNotDeleted
+			}
+			return nil, storage.ErrNotFound
+		}
+		purge.Deleted = fs.deletedAt(tr, prefix, suffix)
+
+		for _, sp := range servicePoints {
+			tags, err := fs.readTags(tr, sp.ID, prefix, suffix)
+			if err != nil {
+				return nil, err
+			}
+			for _, tag := range tags {
+				tr.Clear(fs.tagDir.Pack(tuple.Tuple{sp.ID, "raid", prefix, suffix, tag}))
+				tr.Clear(fs.tagDir.Pack(tuple.Tuple{sp.ID, "tag", tag, prefix, suffix}))
+			}
+		}
+		tr.ClearRange(keyRange(fs.raidDir.Pack(tuple.Tuple{prefix, suffix})))
+		tr.ClearRange(keyRange(fs.attachmentDir.Pack(tuple.Tuple{prefix, suffix})))
+		tr.Clear(fs.purgeDir.Pack(tuple.Tuple{"deleted", prefix, suffix}))
+
+		data, err := fs.marshal(purge)
+		if err != nil {
+			return nil, err
+		}
+		tr.Set(fs.purgeDir.Pack(tuple.Tuple{"purge", purge.Purged.UnixNano(), prefix, suffix}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// ListPurges returns the recorded purges, oldest first
+func (fs *FDBStorage) ListPurges(ctx context.Context) ([]*models.Purge, error) {
+	purges := make([]*models.Purge, 0)
+	var decodeErr error
+	err := fs.scanRange(ctx, fs.purgeDir.Pack(tuple.Tuple{"purge"}), func(kv fdb.KeyValue) {
+		var purge models.Purge
+		if err := fs.unmarshal(kv.Value, &purge); err != nil {
+			decodeErr = err
+			return
+		}
+		purges = append(purges, &purge)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return purges, nil
+}
+
+// ImportPurge records a purge made elsewhere, removing nothing
+func (fs *FDBStorage) ImportPurge(ctx context.Context, purge *models.Purge) error {
+	data, err := fs.marshal(purge)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.purgeDir.Pack(tuple.Tuple{"purge", purge.Purged.UnixNano(), purge.Prefix, purge.Suffix}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can purge RAiDs and import audit records
+var (
+	_ storage.Purger        = (*FDBStorage)(nil)
+	_ storage.AuditImporter = (*FDBStorage)(nil)
+)
@@ -0,0 +1,118 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+)
+
+// The redaction directory keeps the redactions under (Unix nanoseconds,
+// prefix, suffix), so they list in the order they were made.
+
+// RedactRAiD rewrites the version keys of a RAiD redact changes, with the
+// archived ones, the current or deleted key and the change of every
+// version, recording the redaction in the same transaction
+func (fs *FDBStorage) RedactRAiD(ctx context.Context, prefix, suffix string, redact func(*models.RAiD) bool, redaction *models.Redaction) (int, error) {
+	result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		latest := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "current"})
+		if tr.Get(latest).MustGet() == nil {
+			latest = fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "deleted"})
+			if tr.Get(latest).MustGet() == nil {
+				return nil, storage.ErrNotFound
+			}
+		}
+
+		stored, err := fs.storedVersions(tr, prefix, suffix)
+		if err != nil {
+			return nil, err
+		}
+		if len(stored) == 0 {
+			return nil, storage.ErrNotFound
+		}
+		changes := make([]storage.VersionChange, 0, len(stored))
+		for _, v := range stored {
+			if change := fs.storedChange(tr, prefix, suffix, v.Version); change.Version != 0 {
+				changes = append(changes, change)
+			}
+		}
+
+		rewritten, recorded, n, err := storage.RedactVersions(stored, changes, redact)
+		if err != nil || n == 0 {
+			return 0, err
+		}
+
+		// The current or deleted key holds the newest version
+		newest := stored[len(stored)-1].Version
+		for _, v := range rewritten {
+			data, err := codec.Encode(fs.encoding, v.Data)
+			if err != nil {
+				return nil, err
+			}
+			tr.Set(fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version", v.Version}), data)
+			if v.Version == newest {
+				tr.Set(latest, data)
+			}
+		}
+		for i := range recorded {
+			if err := fs.setChange(tr, prefix, suffix, &recorded[i]); err != nil {
+				return nil, err
+			}
+		}
+
+		redaction.Versions = n
+		data, err := fs.marshal(redaction)
+		if err != nil {
+			return nil, err
+		}
+		tr.Set(fs.redactionDir.Pack(tuple.Tuple{redaction.Redacted.UnixNano(), prefix, suffix}), data)
+		return n, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.(int), nil
+}
+
+// ListRedactions returns the recorded redactions, oldest first
+func (fs *FDBStorage) ListRedactions(ctx context.Context) ([]*models.Redaction, error) {
+	redactions := make([]*models.Redaction, 0)
+	var decodeErr error
+	err := fs.scanRange(ctx, fs.redactionDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		var redaction models.Redaction
+		if err := fs.unmarshal(kv.Value, &redaction); err != nil {
+			decodeErr = err
+			return
+		}
+		redactions = append(redactions, &redaction)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return redactions, nil
+}
+
+// ImportRedaction records a redaction made elsewhere, rewriting no version
+func (fs *FDBStorage) ImportRedaction(ctx context.Context, redaction *models.Redaction) error {
+	data, err := fs.marshal(redaction)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.redactionDir.Pack(tuple.Tuple{redaction.Redacted.UnixNano(), redaction.Prefix, redaction.Suffix}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can redact RAiDs
+var _ storage.Redactor = (*FDBStorage)(nil)
@@ -0,0 +1,119 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The reservation directory holds reservations under their handle. They
+// are saved in a transaction reading the current version of the RAiD, so
+// a handle is not reserved while it is minted.
+
+// SaveReservation stores a reservation unless its handle is minted or
+// held by a live reservation
+func (fs *FDBStorage) SaveReservation(ctx context.Context, reservation *models.Reservation) error {
+	data, err := fs.marshal(reservation)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if tr.Get(fs.raidDir.Pack(tuple.Tuple{reservation.Prefix, reservation.Suffix, "current"})).MustGet() != nil {
+			return nil, storage.ErrAlreadyExists
+		}
+		key := fs.reservationDir.Pack(tuple.Tuple{reservation.Prefix, reservation.Suffix})
+		if existing := tr.Get(key).MustGet(); existing != nil {
+			var held models.Reservation
+			if err := fs.unmarshal(existing, &held); err == nil && time.Now().Before(held.ExpiresAt) {
+				return nil, storage.ErrAlreadyExists
+			}
+		}
+		tr.Set(key, data)
+		return nil, nil
+	})
+	return err
+}
+
+// GetReservation retrieves the live reservation of a handle
+func (fs *FDBStorage) GetReservation(ctx context.Context, prefix, suffix string) (*models.Reservation, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		data := rtr.Get(fs.reservationDir.Pack(tuple.Tuple{prefix, suffix})).MustGet()
+		if data == nil {
+			return nil, storage.ErrNotFound
+		}
+		var reservation models.Reservation
+		if err := fs.unmarshal(data, &reservation); err != nil {
+			return nil, err
+		}
+		if !time.Now().Before(reservation.ExpiresAt) {
+			return nil, storage.ErrNotFound
+		}
+		return &reservation, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.Reservation), nil
+}
+
+// DeleteReservation releases the reservation of a handle
+func (fs *FDBStorage) DeleteReservation(ctx context.Context, prefix, suffix string) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		key := fs.reservationDir.Pack(tuple.Tuple{prefix, suffix})
+		if tr.Get(key).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// ReleaseExpiredReservations removes the reservations expired by at. Each
+// is cleared in a transaction reading it again, so that one renewed
+// meanwhile is kept.
+func (fs *FDBStorage) ReleaseExpiredReservations(ctx context.Context, at time.Time) (int, error) {
+	var expired []fdb.Key
+	err := fs.scanRange(ctx, fs.reservationDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		var reservation models.Reservation
+		if err := fs.unmarshal(kv.Value, &reservation); err == nil && !at.Before(reservation.ExpiresAt) {
+			expired = append(expired, kv.Key)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, key := range expired {
+		result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			data := tr.Get(key).MustGet()
+			if data == nil {
+				return false, nil
+			}
+			var reservation models.Reservation
+			if err := fs.unmarshal(data, &reservation); err != nil || at.Before(reservation.ExpiresAt) {
+				return false, nil
+			}
+			tr.Clear(key)
+			return true, nil
+		})
+		if err != nil {
+			return released, err
+		}
+		if result.(bool) {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// Verify FDBStorage can hold reservations
+var _ storage.ReservationStore = (*FDBStorage)(nil)
@@ -0,0 +1,90 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// PruneReservations removes the reservations made before the cutoff of
+// their service point. Each is cleared in a transaction reading it again,
+// so that one replaced meanwhile is judged by its replacement.
+func (fs *FDBStorage) PruneReservations(ctx context.Context, cutoffs storage.Cutoffs, dryRun bool) (int, error) {
+	old := func(data []byte) bool {
+		var reservation models.Reservation
+		return fs.unmarshal(data, &reservation) == nil && reservation.Created.Before(cutoffs.For(reservation.ServicePoint))
+	}
+	var keys []fdb.Key
+	err := fs.scanRange(ctx, fs.reservationDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		if old(kv.Value) {
+			keys = append(keys, kv.Key)
+		}
+	})
+	if err != nil || dryRun {
+		return len(keys), err
+	}
+
+	pruned := 0
+	for _, key := range keys {
+		result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			data := tr.Get(key).MustGet()
+			if data == nil || !old(data) {
+				return false, nil
+			}
+			tr.Clear(key)
+			return true, nil
+		})
+		if err != nil {
+			return pruned, err
+		}
+		if result.(bool) {
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// PruneAudit removes the purges and redactions recorded before before.
+// Both are keyed by time first, so each is one range.
+func (fs *FDBStorage) PruneAudit(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	pruned := 0
+	for _, r := range []fdb.KeyRange{
+		{
+			Begin: keyRange(fs.purgeDir.Pack(tuple.Tuple{"purge"})).Begin,
+			End:   fs.purgeDir.Pack(tuple.Tuple{"purge", before.UnixNano()}),
+		},
+		{
+			Begin: keyRange(fs.redactionDir.Pack(tuple.Tuple{})).Begin,
+			End:   fs.redactionDir.Pack(tuple.Tuple{before.UnixNano()}),
+		},
+	} {
+		result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			kvs, err := tr.GetRange(r, fdb.RangeOptions{}).GetSliceWithError()
+			if err != nil {
+				return nil, err
+			}
+			if !dryRun {
+				tr.ClearRange(r)
+			}
+			return len(kvs), nil
+		})
+		if err != nil {
+			return pruned, err
+		}
+		pruned += result.(int)
+	}
+	return pruned, nil
+}
+
+// Verify FDBStorage can prune reservations and audit records
+var (
+	_ storage.ReservationPruner = (*FDBStorage)(nil)
+	_ storage.AuditPruner       = (*FDBStorage)(nil)
+)
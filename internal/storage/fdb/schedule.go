@@ -0,0 +1,103 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The schedule directory holds scheduled changes under their ID. Listing
+// them, and finding those due, reads them all.
+
+// SaveScheduledChange stores a scheduled change
+func (fs *FDBStorage) SaveScheduledChange(ctx context.Context, change *models.ScheduledChange) error {
+	data, err := fs.marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.scheduleDir.Pack(tuple.Tuple{change.ID}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// GetScheduledChange retrieves a scheduled change
+func (fs *FDBStorage) GetScheduledChange(ctx context.Context, id string) (*models.ScheduledChange, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		data := rtr.Get(fs.scheduleDir.Pack(tuple.Tuple{id})).MustGet()
+		if data == nil {
+			return nil, storage.ErrNotFound
+		}
+		var change models.ScheduledChange
+		if err := fs.unmarshal(data, &change); err != nil {
+			return nil, err
+		}
+		return &change, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.ScheduledChange), nil
+}
+
+// ListScheduledChanges retrieves the scheduled changes of a service point
+func (fs *FDBStorage) ListScheduledChanges(ctx context.Context, servicePoint int64) ([]*models.ScheduledChange, error) {
+	return fs.scheduledChanges(ctx, func(change *models.ScheduledChange) bool {
+		return change.ServicePoint == servicePoint
+	})
+}
+
+// DueChanges retrieves the pending changes effective by at
+func (fs *FDBStorage) DueChanges(ctx context.Context, at time.Time) ([]*models.ScheduledChange, error) {
+	changes, err := fs.scheduledChanges(ctx, func(change *models.ScheduledChange) bool {
+		return change.Status == models.SchedulePending && !change.EffectiveAt.After(at)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].EffectiveAt.Before(changes[j].EffectiveAt) })
+	return changes, nil
+}
+
+// scheduledChanges reads the scheduled changes keep selects, ordered by ID
+func (fs *FDBStorage) scheduledChanges(ctx context.Context, keep func(*models.ScheduledChange) bool) ([]*models.ScheduledChange, error) {
+	changes := make([]*models.ScheduledChange, 0)
+	err := fs.scanRange(ctx, fs.scheduleDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		var change models.ScheduledChange
+		if err := fs.unmarshal(kv.Value, &change); err != nil {
+			return
+		}
+		if keep(&change) {
+			changes = append(changes, &change)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// DeleteScheduledChange removes a scheduled change
+func (fs *FDBStorage) DeleteScheduledChange(ctx context.Context, id string) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		key := fs.scheduleDir.Pack(tuple.Tuple{id})
+		if tr.Get(key).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can hold scheduled changes
+var _ storage.ScheduleStore = (*FDBStorage)(nil)
@@ -0,0 +1,108 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The search directory holds saved searches under (owner, name) keys.
+// Feeds are looked up by reading every search, of which there are few.
+
+// SaveSearch stores a saved search
+func (fs *FDBStorage) SaveSearch(ctx context.Context, search *models.SavedSearch) error {
+	data, err := fs.marshal(search)
+	if err != nil {
+		return err
+	}
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(fs.searchDir.Pack(tuple.Tuple{search.Owner, search.Name}), data)
+		return nil, nil
+	})
+	return err
+}
+
+// GetSearch retrieves a saved search
+func (fs *FDBStorage) GetSearch(ctx context.Context, owner, name string) (*models.SavedSearch, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		data := rtr.Get(fs.searchDir.Pack(tuple.Tuple{owner, name})).MustGet()
+		if data == nil {
+			return nil, storage.ErrNotFound
+		}
+		var search models.SavedSearch
+		if err := fs.unmarshal(data, &search); err != nil {
+			return nil, err
+		}
+		return &search, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.SavedSearch), nil
+}
+
+// GetSearchByFeed retrieves the saved search published under feed
+func (fs *FDBStorage) GetSearchByFeed(ctx context.Context, feed string) (*models.SavedSearch, error) {
+	searches, err := fs.listSearches(fs.searchDir.Pack(tuple.Tuple{}))
+	if err != nil {
+		return nil, err
+	}
+	for _, search := range searches {
+		if search.Feed != "" && search.Feed == feed {
+			return search, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+// ListSearches retrieves the saved searches of owner, in name order as
+// their keys are
+func (fs *FDBStorage) ListSearches(ctx context.Context, owner string) ([]*models.SavedSearch, error) {
+	return fs.listSearches(fs.searchDir.Pack(tuple.Tuple{owner}))
+}
+
+func (fs *FDBStorage) listSearches(prefix fdb.Key) ([]*models.SavedSearch, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		iter := rtr.GetRange(fdb.KeyRange{
+			Begin: fdb.Key(append(append([]byte{}, prefix...), 0x00)),
+			End:   fdb.Key(append(append([]byte{}, prefix...), 0xFF)),
+		}, fdb.RangeOptions{}).Iterator()
+
+		searches := make([]*models.SavedSearch, 0)
+		for iter.Advance() {
+			kv := iter.MustGet()
+			var search models.SavedSearch
+			if err := fs.unmarshal(kv.Value, &search); err != nil {
+				continue
+			}
+			searches = append(searches, &search)
+		}
+		return searches, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]*models.SavedSearch), nil
+}
+
+// DeleteSearch removes a saved search
+func (fs *FDBStorage) DeleteSearch(ctx context.Context, owner, name string) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		key := fs.searchDir.Pack(tuple.Tuple{owner, name})
+		if tr.Get(key).MustGet() == nil {
+			return nil, storage.ErrNotFound
+		}
+		tr.Clear(key)
+		return nil, nil
+	})
+	return err
+}
+
+// Verify FDBStorage can keep saved searches
+var _ storage.SearchStore = (*FDBStorage)(nil)
@@ -0,0 +1,248 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+)
+
+// exportBatchSize bounds the keys read per transaction during export so
+// that large exports stay within FDB's five second transaction limit
+const exportBatchSize = 1000
+
+// ExportRAiDs streams every RAiD, including deleted ones, with all versions
+func (fs *FDBStorage) ExportRAiDs(ctx context.Context, fn func(*storage.RAiDRecord) error) error {
+	begin := fdb.Key(append(fs.raidDir.Pack(tuple.Tuple{}), 0x00))
+	end := fdb.Key(append(fs.raidDir.Pack(tuple.Tuple{}), 0xFF))
+
+	// Versions are collected as stored and decoded once the RAiD is
+	// complete, so that archived versions can be rehydrated
+	var record *storage.RAiDRecord
+	var stored []storage.StoredVersion
+	var changes []storage.VersionChange
+	var lastPrefix, lastSuffix string
+	flush := func() error {
+		if record == nil {
+			return nil
+		}
+		record.Actors = storage.ChangeActors(changes)
+		full, err := storage.RehydrateVersions(stored)
+		if err != nil {
+			return fmt.Errorf("failed to rehydrate RAiD %s/%s: %w", lastPrefix, lastSuffix, err)
+		}
+		for _, v := range full {
+			var raid models.RAiD
+			if err := json.Unmarshal(v.Data, &raid); err != nil {
+				return fmt.Errorf("failed to unmarshal RAiD %s/%s: %w", lastPrefix, lastSuffix, err)
+			}
+			record.Versions = append(record.Versions, &raid)
+		}
+		err = fn(record)
+		record, stored, changes = nil, nil, nil
+		return err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+			return rtr.GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{Limit: exportBatchSize}).GetSliceWithError()
+		})
+		if err != nil {
+			return err
+		}
+		kvs := result.([]fdb.KeyValue)
+
+		for _, kv := range kvs {
+			t, err := fs.raidDir.Unpack(kv.Key)
+			if err != nil || len(t) < 3 {
+				continue
+			}
+			prefix, _ := t[0].(string)
+			suffix, _ := t[1].(string)
+			kind, _ := t[2].(string)
+
+			if record != nil && (prefix != lastPrefix || suffix != lastSuffix) {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			if record == nil {
+				record = &storage.RAiDRecord{}
+				lastPrefix, lastSuffix = prefix, suffix
+			}
+
+			switch kind {
+			case "version":
+				if len(t) < 4 {
+					continue
+				}
+				version, _ := t[3].(int64)
+				data, err := codec.Decode(kv.Value)
+				if err != nil {
+					return fmt.Errorf("failed to decode RAiD %s/%s version %d: %w", prefix, suffix, version, err)
+				}
+				stored = append(stored, storage.StoredVersion{Version: int(version), Data: data})
+			case "change":
+				var change storage.VersionChange
+				if err := fs.unmarshal(kv.Value, &change); err != nil {
+					return fmt.Errorf("failed to decode RAiD %s/%s change: %w", prefix, suffix, err)
+				}
+				changes = append(changes, change)
+			case "deleted":
+				record.Deleted = true
+			}
+		}
+
+		if len(kvs) < exportBatchSize {
+			break
+		}
+		last := kvs[len(kvs)-1].Key
+		begin = fdb.Key(append(append([]byte{}, last...), 0x00))
+	}
+
+	return flush()
+}
+
+// ImportRAiD stores all versions of a RAiD in one transaction
+func (fs *FDBStorage) ImportRAiD(ctx context.Context, record *storage.RAiDRecord) error {
+	current := record.Current()
+	if current == nil || current.Identifier == nil {
+		return fmt.Errorf("RAiD record has no versions")
+	}
+
+	prefix, suffix, err := identifier.Parse(current.Identifier.ID)
+	if err != nil {
+		return err
+	}
+
+	changes, err := storage.RecordChanges(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		currentKey := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "current"})
+		deletedKey := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "deleted"})
+
+		if tr.Get(currentKey).MustGet() != nil || tr.Get(deletedKey).MustGet() != nil {
+			return nil, storage.ErrAlreadyExists
+		}
+
+		var data []byte
+		for _, version := range record.Versions {
+			var err error
+			data, err = fs.marshal(version)
+			if err != nil {
+				return nil, err
+			}
+			versionKey := fs.raidDir.Pack(tuple.Tuple{prefix, suffix, "version", version.Identifier.Version})
+			tr.Set(versionKey, data)
+		}
+		for _, change := range changes {
+			if err := fs.setChange(tr, prefix, suffix, &change); err != nil {
+				return nil, err
+			}
+		}
+
+		if record.Deleted {
+			tr.Set(deletedKey, data)
+		} else {
+			tr.Set(currentKey, data)
+			fs.setAccessIndex(tr, prefix, suffix, nil, current)
+		}
+
+		return nil, nil
+	})
+
+	return err
+}
+
+// ImportServicePoint stores a service point keeping its ID
+func (fs *FDBStorage) ImportServicePoint(ctx context.Context, sp *models.ServicePoint) error {
+	_, err := fs.CreateServicePoint(ctx, sp)
+	return err
+}
+
+// Counters returns the service point ID counter and per-prefix RAiD counters
+func (fs *FDBStorage) Counters(ctx context.Context) (map[string]int64, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return rtr.GetRange(fs.counterDir, fdb.RangeOptions{}).GetSliceWithError()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]int64)
+	for _, kv := range result.([]fdb.KeyValue) {
+		t, err := fs.counterDir.Unpack(kv.Key)
+		if err != nil || len(t) == 0 {
+			continue
+		}
+		switch t[0] {
+		case "servicepoint_id":
+			counters[storage.CounterServicePoint] = decodeCounter(kv.Value)
+		case "raid":
+			if len(t) == 2 {
+				if prefix, ok := t[1].(string); ok {
+					counters[storage.CounterRAiDPrefix+prefix] = decodeCounter(kv.Value)
+				}
+			}
+		}
+	}
+
+	return counters, nil
+}
+
+// SetCounters raises counters to at least the given values
+func (fs *FDBStorage) SetCounters(ctx context.Context, counters map[string]int64) error {
+	_, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for name, value := range counters {
+			var key fdb.Key
+			if name == storage.CounterServicePoint {
+				key = fs.counterDir.Pack(tuple.Tuple{"servicepoint_id"})
+			} else if prefix, ok := strings.CutPrefix(name, storage.CounterRAiDPrefix); ok {
+				key = fs.counterDir.Pack(tuple.Tuple{"raid", prefix})
+			} else {
+				continue
+			}
+
+			// Max is an atomic little-endian maximum
+			tr.Max(key, encodeCounter(value))
+		}
+		return nil, nil
+	})
+
+	return err
+}
+
+// decodeCounter decodes a little-endian atomic counter value
+func decodeCounter(val []byte) int64 {
+	buf := make([]byte, 8)
+	copy(buf, val)
+	return int64(binary.LittleEndian.Uint64(buf))
+}
+
+// encodeCounter encodes a value for FDB atomic operations
+func encodeCounter(value int64) []byte {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, uint64(value))
+	return buf
+}
+
+// Verify FDBStorage supports snapshots
+var _ storage.Snapshotter = (*FDBStorage)(nil)
@@ -0,0 +1,102 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The tag directory keeps each tag twice, with empty values: under
+// (service point, "raid", prefix, suffix, tag) to read the tags of a RAiD
+// and under (service point, "tag", tag, prefix, suffix) to find the RAiDs
+// with a tag.
+
+// GetTags returns the tags a service point gave a RAiD
+func (fs *FDBStorage) GetTags(ctx context.Context, servicePoint int64, prefix, suffix string) ([]string, error) {
+	result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return fs.readTags(rtr, servicePoint, prefix, suffix)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// readTags reads the tags of a RAiD, which sort by their keys
+func (fs *FDBStorage) readTags(rtr fdb.ReadTransaction, servicePoint int64, prefix, suffix string) ([]string, error) {
+	key := fs.tagDir.Pack(tuple.Tuple{servicePoint, "raid", prefix, suffix})
+	iter := rtr.GetRange(fdb.KeyRange{
+		Begin: fdb.Key(append(append([]byte{}, key...), 0x00)),
+		End:   fdb.Key(append(append([]byte{}, key...), 0xFF)),
+	}, fdb.RangeOptions{}).Iterator()
+
+	tags := make([]string, 0)
+	for iter.Advance() {
+		kv := iter.MustGet()
+		t, err := fs.tagDir.Unpack(kv.Key)
+		if err != nil || len(t) != 5 {
+			continue
+		}
+		if tag, ok := t[4].(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+// AddTags adds tags a service point gives a RAiD
+func (fs *FDBStorage) AddTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error) {
+	return fs.updateTags(servicePoint, prefix, suffix, func(tr fdb.Transaction, tag string) {
+		tr.Set(fs.tagDir.Pack(tuple.Tuple{servicePoint, "raid", prefix, suffix, tag}), []byte{})
+		tr.Set(fs.tagDir.Pack(tuple.Tuple{servicePoint, "tag", tag, prefix, suffix}), []byte{})
+	})
+}
+
+// RemoveTags removes tags a service point gave a RAiD
+func (fs *FDBStorage) RemoveTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error) {
+	return fs.updateTags(servicePoint, prefix, suffix, func(tr fdb.Transaction, tag string) {
+		tr.Clear(fs.tagDir.Pack(tuple.Tuple{servicePoint, "raid", prefix, suffix, tag}))
+		tr.Clear(fs.tagDir.Pack(tuple.Tuple{servicePoint, "tag", tag, prefix, suffix}))
+	})
+}
+
+// updateTags calls update for each of tags and returns the tags of the
+// RAiD afterwards, in one transaction
+func (fs *FDBStorage) updateTags(servicePoint int64, prefix, suffix string, tags []string, update func(fdb.Transaction, string)) ([]string, error) {
+	result, err := fs.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for _, tag := range tags {
+			update(tr, tag)
+		}
+		return fs.readTags(tr, servicePoint, prefix, suffix)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]string), nil
+}
+
+// TaggedRAiDs returns the RAiDs a service point gave tag
+func (fs *FDBStorage) TaggedRAiDs(ctx context.Context, servicePoint int64, tag string) ([]storage.IdentifierRef, error) {
+	refs := make([]storage.IdentifierRef, 0)
+	err := fs.scanRange(ctx, fs.tagDir.Pack(tuple.Tuple{servicePoint, "tag", tag}), func(kv fdb.KeyValue) {
+		t, err := fs.tagDir.Unpack(kv.Key)
+		if err != nil || len(t) != 5 {
+			return
+		}
+		prefix, _ := t[3].(string)
+		suffix, _ := t[4].(string)
+		refs = append(refs, storage.IdentifierRef{Prefix: prefix, Suffix: suffix})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Verify FDBStorage can keep tags
+var _ storage.TagStore = (*FDBStorage)(nil)
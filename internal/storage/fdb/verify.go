@@ -0,0 +1,225 @@
+//go:build !noexternal
+// +build !noexternal
+
+package fdb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apple/foundationdb/bindings/go/src/fdb"
+	"github.com/apple/foundationdb/bindings/go/src/fdb/tuple"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/storage/codec"
+)
+
+// verifyGroup tracks the keys of one RAiD while scanning
+type verifyGroup struct {
+	prefix, suffix string
+	versions       []int
+	hasCurrent     bool
+	currentVersion int
+	// stored is kept to check that archived versions rehydrate
+	stored   []storage.StoredVersion
+	archived bool
+}
+
+// Verify scans every RAiD key and service point in batches. Repair is not
+// supported; problems are reported only.
+func (fs *FDBStorage) Verify(ctx context.Context, opts storage.VerifyOptions) (*storage.VerifyReport, error) {
+	report := storage.NewVerifyReport()
+
+	var group *verifyGroup
+	flush := func() {
+		if group == nil {
+			return
+		}
+		name := group.prefix + "/" + group.suffix
+		if !group.hasCurrent {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueOrphanedHistory,
+				Location: "raid/" + name,
+				RAiD:     name,
+				Message:  fmt.Sprintf("%d versions without a current or deleted key", len(group.versions)),
+			})
+			return
+		}
+		report.RAiDs++
+		if issue := storage.CheckVersionSequence("raid/"+name, name, group.versions); issue != nil {
+			report.Add(*issue)
+		} else if group.currentVersion != len(group.versions) {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: "raid/" + name,
+				RAiD:     name,
+				Message:  fmt.Sprintf("current version %d is not the latest version %d", group.currentVersion, len(group.versions)),
+			})
+		}
+		if group.archived {
+			if _, err := storage.RehydrateVersions(group.stored); err != nil {
+				report.Add(storage.VerifyIssue{
+					Kind:     storage.IssueUnparseable,
+					Location: "raid/" + name,
+					RAiD:     name,
+					Message:  fmt.Sprintf("archived versions cannot be rehydrated: %v", err),
+				})
+			}
+		}
+	}
+
+	err := fs.scanRange(ctx, fs.raidDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		t, err := fs.raidDir.Unpack(kv.Key)
+		if err != nil || len(t) < 3 {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: fmt.Sprintf("raid/%x", kv.Key), Message: "unrecognised key"})
+			return
+		}
+		prefix, _ := t[0].(string)
+		suffix, _ := t[1].(string)
+		kind, _ := t[2].(string)
+		name := prefix + "/" + suffix
+
+		if group == nil || group.prefix != prefix || group.suffix != suffix {
+			flush()
+			group = &verifyGroup{prefix: prefix, suffix: suffix}
+		}
+
+		if kind == "change" {
+			// Changes are derived from the versions, which are checked
+			return
+		}
+
+		location := fmt.Sprintf("raid/%s/%s", name, kind)
+		keyVersion := 0
+		if kind == "version" && len(t) > 3 {
+			v, _ := t[3].(int64)
+			keyVersion = int(v)
+			location = fmt.Sprintf("raid/%s/version/%d", name, keyVersion)
+			group.versions = append(group.versions, keyVersion)
+			report.Versions++
+
+			data, err := codec.Decode(kv.Value)
+			if err != nil {
+				report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: location, RAiD: name, Message: err.Error()})
+				return
+			}
+			group.stored = append(group.stored, storage.StoredVersion{Version: keyVersion, Data: data})
+			if storage.IsArchived(data) {
+				group.archived = true
+				if n, _ := storage.ArchivedVersionNumber(data); n != keyVersion {
+					report.Add(storage.VerifyIssue{
+						Kind:     storage.IssueIdentifierMismatch,
+						Location: location,
+						RAiD:     name,
+						Message:  fmt.Sprintf("archived version %d does not match key", n),
+					})
+				}
+				return
+			}
+		}
+
+		var raid models.RAiD
+		if err := fs.unmarshal(kv.Value, &raid); err != nil {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: location, RAiD: name, Message: err.Error()})
+			return
+		}
+		if raid.Identifier == nil {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueIdentifierMismatch, Location: location, RAiD: name, Message: "document has no identifier"})
+			return
+		}
+
+		switch kind {
+		case "current", "deleted":
+			group.hasCurrent = true
+			group.currentVersion = raid.Identifier.Version
+		case "version":
+			if raid.Identifier.Version != keyVersion {
+				report.Add(storage.VerifyIssue{
+					Kind:     storage.IssueIdentifierMismatch,
+					Location: location,
+					RAiD:     name,
+					Message:  fmt.Sprintf("payload version %d does not match key", raid.Identifier.Version),
+				})
+			}
+		}
+
+		p, s, err := identifier.Parse(raid.Identifier.ID)
+		if err != nil || p != prefix || s != suffix {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: location,
+				RAiD:     name,
+				Message:  fmt.Sprintf("payload identifier %q does not match key", raid.Identifier.ID),
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	flush()
+
+	err = fs.scanRange(ctx, fs.servicePointDir.Pack(tuple.Tuple{}), func(kv fdb.KeyValue) {
+		t, err := fs.servicePointDir.Unpack(kv.Key)
+		if err != nil || len(t) < 1 {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: fmt.Sprintf("servicepoint/%x", kv.Key), Message: "unrecognised key"})
+			return
+		}
+		id, _ := t[0].(int64)
+		location := fmt.Sprintf("servicepoint/%d", id)
+
+		var sp models.ServicePoint
+		if err := fs.unmarshal(kv.Value, &sp); err != nil {
+			report.Add(storage.VerifyIssue{Kind: storage.IssueUnparseable, Location: location, Message: err.Error()})
+			return
+		}
+		report.ServicePoints++
+
+		if sp.ID != id {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: location,
+				Message:  fmt.Sprintf("service point ID %d does not match key", sp.ID),
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report.Finish(), nil
+}
+
+// scanRange calls fn for every key under prefix, reading in batches of
+// exportBatchSize to stay within the transaction time limit
+func (fs *FDBStorage) scanRange(ctx context.Context, prefix []byte, fn func(fdb.KeyValue)) error {
+	begin := fdb.Key(append(append([]byte{}, prefix...), 0x00))
+	end := fdb.Key(append(append([]byte{}, prefix...), 0xFF))
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		result, err := fs.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+			return rtr.GetRange(fdb.KeyRange{Begin: begin, End: end}, fdb.RangeOptions{Limit: exportBatchSize}).GetSliceWithError()
+		})
+		if err != nil {
+			return err
+		}
+		kvs := result.([]fdb.KeyValue)
+
+		for _, kv := range kvs {
+			fn(kv)
+		}
+
+		if len(kvs) < exportBatchSize {
+			return nil
+		}
+		last := kvs[len(kvs)-1].Key
+		begin = fdb.Key(append(append([]byte{}, last...), 0x00))
+	}
+}
+
+// Verify FDBStorage can check its data
+var _ storage.Verifier = (*FDBStorage)(nil)
@@ -0,0 +1,138 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Attachment content is kept at blobs/<hash>, and attachments at
+// attachments/<escaped prefix>/<escaped suffix>/<hash>.json.
+
+func (fs *FileStorage) blobPath(hash string) string {
+	return filepath.Join(fs.dataDir, "blobs", url.QueryEscape(hash))
+}
+
+func (fs *FileStorage) attachmentDir(prefix, suffix string) string {
+	return filepath.Join(fs.dataDir, "attachments", url.QueryEscape(prefix), url.QueryEscape(suffix))
+}
+
+// PutBlob stores attachment content
+func (fs *FileStorage) PutBlob(ctx context.Context, hash string, data []byte) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	path := fs.blobPath(hash)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create blobs directory: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write blob file: %w", err)
+	}
+	return nil
+}
+
+// GetBlob retrieves attachment content
+func (fs *FileStorage) GetBlob(ctx context.Context, hash string) ([]byte, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	data, err := os.ReadFile(fs.blobPath(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read blob file: %w", err)
+	}
+	return data, nil
+}
+
+// SaveAttachment stores an attachment
+func (fs *FileStorage) SaveAttachment(ctx context.Context, attachment *models.Attachment) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	dir := fs.attachmentDir(attachment.Prefix, attachment.Suffix)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create attachments directory: %w", err)
+	}
+	data, err := json.MarshalIndent(attachment, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal attachment: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, url.QueryEscape(attachment.Hash)+".json"), data); err != nil {
+		return fmt.Errorf("failed to write attachment file: %w", err)
+	}
+	return nil
+}
+
+// GetAttachment retrieves an attachment
+func (fs *FileStorage) GetAttachment(ctx context.Context, prefix, suffix, hash string) (*models.Attachment, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	data, err := os.ReadFile(filepath.Join(fs.attachmentDir(prefix, suffix), url.QueryEscape(hash)+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read attachment file: %w", err)
+	}
+	var attachment models.Attachment
+	if err := json.Unmarshal(data, &attachment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attachment: %w", err)
+	}
+	return &attachment, nil
+}
+
+// ListAttachments retrieves the attachments of a RAiD
+func (fs *FileStorage) ListAttachments(ctx context.Context, prefix, suffix string) ([]*models.Attachment, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir := fs.attachmentDir(prefix, suffix)
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	attachments := make([]*models.Attachment, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read attachment file: %w", err)
+		}
+		var attachment models.Attachment
+		if err := json.Unmarshal(data, &attachment); err != nil {
+			continue // Skip corrupted files
+		}
+		attachments = append(attachments, &attachment)
+	}
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Hash < attachments[j].Hash })
+	return attachments, nil
+}
+
+// Verify FileStorage can hold attachments and their content
+var (
+	_ storage.BlobStore       = (*FileStorage)(nil)
+	_ storage.AttachmentStore = (*FileStorage)(nil)
+)
@@ -0,0 +1,346 @@
+package file
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/textnorm"
+)
+
+// The catalogue follows RAiD files changed behind the storage's back, e.g.
+// edited by hand or checked out with git, as the file system reports them.
+// It also rescans the data directory every catalogueRescan for changes
+// missed, or every catalogueRefresh if changes cannot be watched.
+const (
+	catalogueRescan  = 5 * time.Minute
+	catalogueRefresh = 2 * time.Second
+)
+
+// catalogueEntry describes one current or soft deleted RAiD file
+type catalogueEntry struct {
+	// prefix and suffix are empty for files that cannot be read
+	prefix, suffix string
+	servicePoint   int64
+	accessType     string
+	deleted        bool
+	minted         time.Time
+	// terms are the folded titles and keywords the Text filter searches
+	terms   []textnorm.Term
+	modTime time.Time
+	size    int64
+}
+
+// catalogue lists the RAiD files with the handles, owners, access types
+// and folded titles and keywords they hold, keyed by path, so listings read only the files they return.
+// It is built when the storage is opened, kept up to date by writes and
+// refreshed by a watcher comparing modification times and sizes, under the
+// state mutex.
+type catalogue struct {
+	entries map[string]*catalogueEntry
+}
+
+func newCatalogue() *catalogue {
+	return &catalogue{entries: make(map[string]*catalogueEntry)}
+}
+
+// raidFileKind reports whether path is a current or a soft deleted RAiD
+// file
+func raidFileKind(path string) (deleted, ok bool) {
+	switch {
+	case strings.HasSuffix(path, ".json.deleted"):
+		return true, true
+	case strings.HasSuffix(path, ".json"):
+		return false, true
+	}
+	return false, false
+}
+
+// record adds or replaces the entry for the file at path, which holds raid
+// or could not be read if raid is nil
+func (fs *FileStorage) record(path string, info os.FileInfo, raid *models.RAiD, deleted bool) {
+	fs.forget(path)
+	e := &catalogueEntry{deleted: deleted, modTime: info.ModTime(), size: info.Size()}
+	if raid != nil && raid.Identifier != nil {
+		e.prefix, e.suffix, _ = identifier.Parse(raid.Identifier.ID)
+		if raid.Identifier.Owner != nil {
+			e.servicePoint = raid.Identifier.Owner.ServicePoint
+		}
+	}
+	if raid != nil && raid.Metadata != nil {
+		e.minted = raid.Metadata.Created
+	}
+	if raid != nil && raid.Access != nil && raid.Access.Type != nil {
+		e.accessType = raid.Access.Type.ID
+	}
+	if raid != nil {
+		e.terms = textnorm.Terms(raid)
+	}
+	fs.catalogue.entries[path] = e
+	if e.prefix != "" && !deleted {
+		fs.relatedObjects.set(e.prefix+"/"+e.suffix, raid)
+	}
+}
+
+// recordWritten records a RAiD file the storage has just written
+func (fs *FileStorage) recordWritten(path string, raid *models.RAiD, deleted bool) {
+	if info, err := os.Stat(path); err == nil {
+		fs.stateMu.Lock()
+		fs.record(path, info, raid, deleted)
+		fs.stateMu.Unlock()
+	}
+}
+
+// forget drops the entry for path
+func (fs *FileStorage) forget(path string) {
+	e, ok := fs.catalogue.entries[path]
+	if !ok {
+		return
+	}
+	delete(fs.catalogue.entries, path)
+	if e.prefix != "" && !e.deleted {
+		fs.relatedObjects.remove(e.prefix + "/" + e.suffix)
+	}
+}
+
+// statRAiDFiles returns the current and soft deleted RAiD files, skipping
+// the version history
+func (fs *FileStorage) statRAiDFiles() (map[string]os.FileInfo, error) {
+	files := make(map[string]os.FileInfo)
+	err := filepath.Walk(fs.raidDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if skipDir(info.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := raidFileKind(path); ok {
+			files[path] = info
+		}
+		return nil
+	})
+	return files, err
+}
+
+// refreshCatalogue brings the catalogue in line with files as found by
+// statRAiDFiles, reading those that are new or changed. Files may have been
+// written since they were found, so they are checked again first.
+func (fs *FileStorage) refreshCatalogue(files map[string]os.FileInfo) {
+	for path, info := range files {
+		if e, ok := fs.catalogue.entries[path]; ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+			continue
+		}
+		fs.refreshFile(path)
+	}
+	for path := range fs.catalogue.entries {
+		if _, ok := files[path]; !ok {
+			fs.refreshFile(path)
+		}
+	}
+}
+
+// refreshFile brings the entry for the RAiD file at path in line with the
+// file, reading it if it is new or changed and forgetting it if it is gone
+func (fs *FileStorage) refreshFile(path string) {
+	deleted, ok := raidFileKind(path)
+	if !ok {
+		return
+	}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		fs.forget(path)
+		return
+	}
+	if err != nil {
+		return
+	}
+	if e, ok := fs.catalogue.entries[path]; ok && e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+		return
+	}
+	raid, err := fs.loadRAiDFromFile(path)
+	if err != nil {
+		raid = nil
+	}
+	fs.record(path, info, raid, deleted)
+}
+
+// newWatcher returns a watcher of the directories holding RAiD files, or
+// nil if they cannot be watched. It is set up before the catalogue is
+// built, so that no change made after that is missed.
+func (fs *FileStorage) newWatcher() *fsnotify.Watcher {
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		if err = fs.watchDirs(watcher, fs.raidDir); err != nil {
+			watcher.Close()
+		}
+	}
+	if err != nil {
+		log.Printf("Cannot watch %s, rescanning it every %s: %v", fs.raidDir, catalogueRefresh, err)
+		return nil
+	}
+	return watcher
+}
+
+// watch refreshes the catalogue as watcher reports RAiD files changed
+// until stop is closed, and rescans the data directory, which is walked
+// without holding the state mutex, every catalogueRescan. Without a
+// watcher, it rescans every catalogueRefresh.
+func (fs *FileStorage) watch(watcher *fsnotify.Watcher, stop <-chan struct{}) {
+	rescan := catalogueRefresh
+	var events chan fsnotify.Event
+	var errs chan error
+	if watcher != nil {
+		defer watcher.Close()
+		rescan, events, errs = catalogueRescan, watcher.Events, watcher.Errors
+	}
+
+	ticker := time.NewTicker(rescan)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case event := <-events:
+			fs.changed(watcher, event)
+			continue
+		case <-errs:
+			// Events may have been lost, e.g. when the queue overflowed
+		case <-ticker.C:
+		}
+		files, err := fs.statRAiDFiles()
+		if err != nil {
+			continue
+		}
+		fs.stateMu.Lock()
+		fs.refreshCatalogue(files)
+		fs.stateMu.Unlock()
+	}
+}
+
+// changed refreshes the catalogue for a file system event. Directories
+// created under the data directory are watched too, and the files already
+// in them refreshed, as they may have been written before the watch began.
+func (fs *FileStorage) changed(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if skipDir(info.Name()) {
+				return
+			}
+			fs.watchDirs(watcher, event.Name)
+			files, err := fs.statRAiDFiles()
+			if err != nil {
+				return
+			}
+			fs.stateMu.Lock()
+			defer fs.stateMu.Unlock()
+			for path := range files {
+				if strings.HasPrefix(path, event.Name+string(filepath.Separator)) {
+					fs.refreshFile(path)
+				}
+			}
+			return
+		}
+	}
+	fs.stateMu.Lock()
+	fs.refreshFile(event.Name)
+	fs.stateMu.Unlock()
+}
+
+// watchDirs adds dir and the directories below it holding RAiD files to
+// watcher
+func (fs *FileStorage) watchDirs(watcher *fsnotify.Watcher, dir string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != dir && skipDir(info.Name()) {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// skipDir reports whether a directory below the data directory holds
+// something other than RAiD files
+func skipDir(name string) bool {
+	return name == ".history" || name == changesDir
+}
+
+// candidates returns the paths of the RAiD files a listing may return,
+// judged by their entries alone, in handle order. public keeps only open
+// access RAiDs; handles are narrowed to those in linked if it is not nil.
+func (c *catalogue) candidates(filter *storage.RAiDFilter, public bool, linked map[string]bool) []string {
+	var f storage.RAiDFilter
+	if filter != nil {
+		f = *filter
+	}
+	var afterPrefix, afterSuffix string
+	if f.Cursor != "" {
+		var err error
+		if afterPrefix, afterSuffix, err = identifier.ParseCursor(f.Cursor); err != nil {
+			return nil
+		}
+	}
+
+	paths := make([]string, 0, len(c.entries))
+	for path, e := range c.entries {
+		switch {
+		case e.prefix == "":
+		case e.deleted && (public || !f.IncludeDeleted && !f.DeletedOnly):
+		case !e.deleted && f.DeletedOnly:
+		case public && e.accessType != storage.AccessTypeOpen:
+		case f.ServicePointID != 0 && e.servicePoint != f.ServicePointID:
+		case !f.MintedSince.IsZero() && e.minted.Before(f.MintedSince):
+		case f.Text != "" && !textnorm.ContainsText(e.terms, f.Text):
+		case linked != nil && !linked[e.prefix+"/"+e.suffix]:
+		case f.Cursor != "" && (e.prefix < afterPrefix || e.prefix == afterPrefix && e.suffix <= afterSuffix):
+		default:
+			paths = append(paths, path)
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := c.entries[paths[i]], c.entries[paths[j]]
+		if a.prefix != b.prefix {
+			return a.prefix < b.prefix
+		}
+		if a.suffix != b.suffix {
+			return a.suffix < b.suffix
+		}
+		return !a.deleted && b.deleted
+	})
+	return paths
+}
+
+// readsContent reports whether filter tests fields the catalogue does not
+// hold, so the page can only be cut after the files are read
+func readsContent(filter *storage.RAiDFilter) bool {
+	return filter != nil && (filter.ContributorID != "" || filter.OrganisationID != "" ||
+		filter.HasTraditionalKnowledge != nil || filter.TraditionalKnowledgeLabelID != "" ||
+		filter.SubjectID != "" || filter.SubjectKeyword != "" || filter.RelatedObjectDOI != "" ||
+		filter.Expression != nil)
+}
+
+// pageOf cuts the page filter selects out of items
+func pageOf[T any](items []T, filter *storage.RAiDFilter) []T {
+	if filter == nil {
+		return items
+	}
+	items = items[min(max(filter.Offset, 0), len(items)):]
+	if filter.Limit > 0 && filter.Limit < len(items) {
+		items = items[:filter.Limit]
+	}
+	return items
+}
@@ -0,0 +1,144 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/expr"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestCatalogue(t *testing.T) {
+	ctx := context.Background()
+	fs, err := New(&Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	owned := func(suffix string, sp int64, access string) *models.RAiD {
+		return &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix, Owner: &models.Owner{ServicePoint: sp}},
+			Access:     &models.Access{Type: &models.IDSchema{ID: access}},
+		}
+	}
+	for _, raid := range []*models.RAiD{
+		owned("a", 1001, storage.AccessTypeEmbargoed),
+		owned("b", 1001, storage.AccessTypeOpen),
+		owned("c", 1002, storage.AccessTypeOpen),
+		owned("d", 1001, storage.AccessTypeOpen),
+	} {
+		if _, err := fs.CreateRAiD(ctx, raid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := fs.DeleteRAiD(ctx, "10.99999", "d"); err != nil {
+		t.Fatal(err)
+	}
+
+	ids := func(raids []*models.RAiD, err error) []string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(raids))
+		for i, raid := range raids {
+			ids[i] = raid.Identifier.ID[len("https://raid.org/10.99999/"):]
+		}
+		return ids
+	}
+	if got := ids(fs.ListPublicRAiDs(ctx, &storage.RAiDFilter{Limit: 1, Offset: 1})); len(got) != 1 || got[0] != "c" {
+		t.Errorf("expected the second public RAiD to be c, got %v", got)
+	}
+	if got := ids(fs.ListRAiDs(ctx, &storage.RAiDFilter{ServicePointID: 1001, IncludeDeleted: true})); len(got) != 3 || got[2] != "d" {
+		t.Errorf("expected a, b and the deleted d, got %v", got)
+	}
+	if got := ids(fs.ListRAiDs(ctx, &storage.RAiDFilter{MintedSince: time.Now().Add(-time.Hour)})); len(got) != 3 {
+		t.Errorf("expected a, b and c to be minted in the last hour, got %v", got)
+	}
+	if got := ids(fs.ListRAiDs(ctx, &storage.RAiDFilter{MintedSince: time.Now().Add(time.Hour)})); len(got) != 0 {
+		t.Errorf("expected no RAiD minted in the next hour, got %v", got)
+	}
+	open, err := expr.Compile("access.type.id == '" + storage.AccessTypeOpen + "' && identifier.owner.servicePoint != 1002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(fs.ListRAiDs(ctx, &storage.RAiDFilter{Expression: open})); len(got) != 1 || got[0] != "b" {
+		t.Errorf("expected the filter to match only b, got %v", got)
+	}
+
+	// Files changed behind the storage's back are picked up by the watcher
+	dir := filepath.Join(fs.raidDir, sanitizePath("10.99999"))
+	data, _ := json.Marshal(owned("e", 1002, storage.AccessTypeOpen))
+	if err := os.WriteFile(filepath.Join(dir, "e.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = json.Marshal(owned("b", 1001, storage.AccessTypeEmbargoed))
+	if err := os.WriteFile(filepath.Join(dir, "b.json"), append(data, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(dir, "c.json")); err != nil {
+		t.Fatal(err)
+	}
+	files, err := fs.statRAiDFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.stateMu.Lock()
+	fs.refreshCatalogue(files)
+	fs.stateMu.Unlock()
+
+	if got := ids(fs.ListPublicRAiDs(ctx, nil)); len(got) != 1 || got[0] != "e" {
+		t.Errorf("expected only e to be public after the edits, got %v", got)
+	}
+}
+
+func TestCatalogue_Watch(t *testing.T) {
+	ctx := context.Background()
+	fs, err := New(&Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	// A RAiD written by hand under a new prefix, which the watcher must
+	// start watching first
+	dir := filepath.Join(fs.raidDir, sanitizePath("10.88888"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(&models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.88888/manual"},
+		Access:     &models.Access{Type: &models.IDSchema{ID: storage.AccessTypeOpen}},
+	})
+	if err := os.WriteFile(filepath.Join(dir, "manual.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	public := func() int {
+		raids, err := fs.ListPublicRAiDs(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(raids)
+	}
+	for deadline := time.Now().Add(5 * time.Second); public() != 1; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the watcher to pick up the new RAiD file")
+		}
+	}
+
+	if err := os.Remove(filepath.Join(dir, "manual.json")); err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); public() != 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the watcher to notice the RAiD file removed")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// changesDir holds, per prefix directory, a file for each RAiD with the
+// changes its versions made
+const changesDir = ".changes"
+
+func (fs *FileStorage) getRaidChangesFilePath(prefix, suffix string) string {
+	dirPath := filepath.Join(fs.raidDir, sanitizePath(prefix), changesDir)
+	os.MkdirAll(dirPath, 0755)
+	return filepath.Join(dirPath, sanitizePath(suffix)+".json")
+}
+
+// loadChanges reads the stored changes of a RAiD, none if it has no file
+func (fs *FileStorage) loadChanges(prefix, suffix string) ([]storage.VersionChange, error) {
+	data, err := os.ReadFile(fs.getRaidChangesFilePath(prefix, suffix))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var changes []storage.VersionChange
+	if err := json.Unmarshal(data, &changes); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RAiD changes: %w", err)
+	}
+	return changes, nil
+}
+
+func (fs *FileStorage) saveChanges(prefix, suffix string, changes []storage.VersionChange) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fs.getRaidChangesFilePath(prefix, suffix), data); err != nil {
+		return fmt.Errorf("failed to write RAiD changes: %w", err)
+	}
+	return nil
+}
+
+// appendChange records the change raid, a new version, made to prev, the
+// version before it or nil for a new RAiD
+func (fs *FileStorage) appendChange(ctx context.Context, prefix, suffix string, prev, raid *models.RAiD) error {
+	var changes []storage.VersionChange
+	var chain string
+	if prev != nil {
+		var err error
+		if changes, err = fs.loadChanges(prefix, suffix); err != nil {
+			return err
+		}
+		if n := len(changes); n > 0 && changes[n-1].Version == prev.Identifier.Version {
+			chain = changes[n-1].Chain
+		}
+	}
+	change, err := storage.RecordVersion(ctx, prev, raid, chain)
+	if err != nil {
+		return err
+	}
+	return fs.saveChanges(prefix, suffix, append(changes, *change))
+}
+
+// storedHash returns the stored content hash of version of a RAiD, empty
+// if none was stored
+func (fs *FileStorage) storedHash(prefix, suffix string, version int) string {
+	changes, err := fs.loadChanges(prefix, suffix)
+	if err != nil || len(changes) == 0 || changes[len(changes)-1].Version != version {
+		return ""
+	}
+	return changes[len(changes)-1].Hash
+}
+
+// GetRAiDChanges reads the stored changes of a RAiD, diffing its history
+// if they are incomplete. They are kept in one file, which is read whole.
+func (fs *FileStorage) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+	defer fs.rlockRecord(raidLockKey(prefix, suffix))()
+
+	// Like the history, changes of deleted RAiDs are not served
+	path := fs.getRaidFilePath(prefix, suffix)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, storage.ErrNotFound
+	}
+
+	changes, err := fs.loadChanges(prefix, suffix)
+	if err == nil && storage.ChangesComplete(changes) {
+		return storage.PageChanges(changes, page), nil
+	}
+
+	versions, err := fs.loadVersions(path, fs.getRaidHistoryDir(prefix, suffix), false)
+	if err != nil {
+		return nil, err
+	}
+	if changes, err = storage.DiffHistory(versions); err != nil {
+		return nil, err
+	}
+	return storage.PageChanges(changes, page), nil
+}
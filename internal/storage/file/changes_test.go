@@ -0,0 +1,156 @@
+package file
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestGetRAiDChanges(t *testing.T) {
+	ctx := context.Background()
+	fs, err := New(&Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	raid := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"},
+		Title:      []models.Title{{Text: "First"}},
+	}
+	if _, err := fs.CreateRAiD(ctx, raid); err != nil {
+		t.Fatal(err)
+	}
+	raid.Title = []models.Title{{Text: "Second"}}
+	if _, err := fs.UpdateRAiD(ctx, "10.99999", "a", raid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Repeating the current content adds no version
+	same, err := fs.UpdateRAiD(ctx, "10.99999", "a", &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"},
+		Title:      []models.Title{{Text: "Second"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Identifier.Version != 2 {
+		t.Errorf("expected an identical update to return version 2, got %d", same.Identifier.Version)
+	}
+
+	changes, err := fs.GetRAiDChanges(ctx, "10.99999", "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Version != 1 || changes[1].Version != 2 {
+		t.Fatalf("expected the changes of versions 1 and 2, got %+v", changes)
+	}
+	found := false
+	for _, op := range changes[1].Patch {
+		if op.Path == "/title/0/text" && string(op.Value) == `"Second"` {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected version 2 to replace the title, got %+v", changes[1].Patch)
+	}
+
+	// RAiDs written before changes were stored are diffed from history
+	history, err := fs.GetRAiDHistory(ctx, "10.99999", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := storage.DiffHistory(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(fs.getRaidChangesFilePath("10.99999", "a")); err != nil {
+		t.Fatal(err)
+	}
+	got, err := fs.GetRAiDChanges(ctx, "10.99999", "a", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the changes diffed from history\n got %+v\nwant %+v", got, want)
+	}
+	for i := range got {
+		if got[i].Version != changes[i].Version || !reflect.DeepEqual(got[i].Patch, changes[i].Patch) {
+			t.Errorf("stored change of version %d differs from the diffed one", changes[i].Version)
+		}
+	}
+
+	if _, err := fs.GetRAiDChanges(ctx, "10.99999", "missing", nil); err != storage.ErrNotFound {
+		t.Errorf("expected ErrNotFound for a missing RAiD, got %v", err)
+	}
+}
+
+func TestGetRAiDChanges_Page(t *testing.T) {
+	ctx := storage.WithActor(context.Background(), "alice")
+	fs, err := New(&Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	raid := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"},
+		Title:      []models.Title{{Text: "Version 1"}},
+	}
+	if _, err := fs.CreateRAiD(ctx, raid); err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"Version 2", "Version 3"} {
+		raid.Title = []models.Title{{Text: title}}
+		if _, err := fs.UpdateRAiD(ctx, "10.99999", "a", raid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changes, err := fs.GetRAiDChanges(ctx, "10.99999", "a", &storage.HistoryPage{After: 1, Limit: 1, Summary: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || changes[0].Version != 2 || changes[0].Patch != nil || changes[0].Actor != "alice" {
+		t.Errorf("expected the summary of version 2 written by alice, got %+v", changes)
+	}
+	if changes, err = fs.GetRAiDChanges(ctx, "10.99999", "a", &storage.HistoryPage{After: 3}); err != nil || len(changes) != 0 {
+		t.Errorf("expected no changes after the last version, got %+v, %v", changes, err)
+	}
+}
+
+func TestUpdateRAiD_StaleVersion(t *testing.T) {
+	ctx := context.Background()
+	fs, err := New(&Config{DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	raid := &models.RAiD{
+		Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a"},
+		Title:      []models.Title{{Text: "First"}},
+	}
+	if _, err := fs.CreateRAiD(ctx, raid); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"Second", "Third"} {
+		_, err := fs.UpdateRAiD(ctx, "10.99999", "a", &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/a", Version: 1},
+			Title:      []models.Title{{Text: text}},
+		})
+		if text == "Second" && err != nil {
+			t.Fatal(err)
+		}
+		// The second update was made to version 1 as well
+		var conflict *storage.ConflictError
+		if text == "Third" && (!errors.As(err, &conflict) || conflict.Current != 2) {
+			t.Fatalf("expected a conflict with version 2, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// raidFilePath returns the current or, for a soft deleted RAiD, the deleted
+// file of a RAiD
+func (fs *FileStorage) raidFilePath(prefix, suffix string) (string, error) {
+	path := fs.getRaidFilePath(prefix, suffix)
+	for _, p := range []string{path, path + ".deleted"} {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", storage.ErrNotFound
+}
+
+// storedVersions reads the documents of every version of a RAiD as they are
+// stored: the history files in historyDir, some of which may be archived,
+// and the current file at path
+func (fs *FileStorage) storedVersions(path, historyDir string) ([]storage.StoredVersion, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, err
+	}
+	var current models.RAiD
+	if err := json.Unmarshal(data, &current); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RAiD: %w", err)
+	}
+	if current.Identifier == nil {
+		return nil, fmt.Errorf("%s has no identifier", fs.relative(path))
+	}
+
+	entries, err := os.ReadDir(historyDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	versions := make([]storage.StoredVersion, 0, len(entries)+1)
+	for _, entry := range entries {
+		version, ok := historyVersion(entry)
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(historyDir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, storage.StoredVersion{Version: version, Data: data})
+	}
+	return append(versions, storage.StoredVersion{Version: current.Identifier.Version, Data: data}), nil
+}
+
+// historyVersion returns the version a history file entry holds
+func historyVersion(entry os.DirEntry) (int, bool) {
+	name := entry.Name()
+	if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), ".json"))
+	return version, err == nil
+}
+
+// loadVersions returns every version of a RAiD in ascending order with
+// archived versions rehydrated, skipping documents that cannot be decoded
+// unless strict is set
+func (fs *FileStorage) loadVersions(path, historyDir string, strict bool) ([]*models.RAiD, error) {
+	stored, err := fs.storedVersions(path, historyDir)
+	if err != nil {
+		return nil, err
+	}
+	full, err := storage.RehydrateVersions(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]*models.RAiD, 0, len(full))
+	for _, v := range full {
+		var raid models.RAiD
+		if err := json.Unmarshal(v.Data, &raid); err != nil {
+			if strict {
+				return nil, fmt.Errorf("version %d: %w", v.Version, err)
+			}
+			continue
+		}
+		versions = append(versions, &raid)
+	}
+	return versions, nil
+}
+
+// CompactRAiD replaces the history files of the versions policy selects
+// with archived diffs
+func (fs *FileStorage) CompactRAiD(ctx context.Context, prefix, suffix string, policy storage.CompactionPolicy) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return 0, err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	path, err := fs.raidFilePath(prefix, suffix)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := fs.storedVersions(path, fs.getRaidHistoryDir(prefix, suffix))
+	if err != nil {
+		return 0, err
+	}
+	compacted, err := storage.CompactVersions(stored, policy, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, v := range compacted {
+		if err := writeFileAtomic(fs.getRaidHistoryFilePath(prefix, suffix, v.Version), v.Data); err != nil {
+			return 0, fmt.Errorf("failed to write archived version: %w", err)
+		}
+	}
+	return len(compacted), nil
+}
+
+// RehydrateRAiD writes the archived history files of a RAiD as full
+// documents again
+func (fs *FileStorage) RehydrateRAiD(ctx context.Context, prefix, suffix string) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return 0, err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	path, err := fs.raidFilePath(prefix, suffix)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := fs.storedVersions(path, fs.getRaidHistoryDir(prefix, suffix))
+	if err != nil {
+		return 0, err
+	}
+	full, err := storage.RehydrateVersions(stored)
+	if err != nil {
+		return 0, err
+	}
+	byVersion := make(map[int][]byte, len(full))
+	for _, v := range full {
+		byVersion[v.Version] = v.Data
+	}
+
+	rehydrated := 0
+	for _, v := range stored {
+		if !storage.IsArchived(v.Data) {
+			continue
+		}
+		var raid models.RAiD
+		if err := json.Unmarshal(byVersion[v.Version], &raid); err != nil {
+			return rehydrated, fmt.Errorf("version %d: %w", v.Version, err)
+		}
+		if err := fs.saveRAiDToFile(&raid, fs.getRaidHistoryFilePath(prefix, suffix, v.Version)); err != nil {
+			return rehydrated, err
+		}
+		rehydrated++
+	}
+	return rehydrated, nil
+}
+
+// CompactRAiD archives old versions and commits the change to git
+func (gs *GitStorage) CompactRAiD(ctx context.Context, prefix, suffix string, policy storage.CompactionPolicy) (int, error) {
+	n, err := gs.FileStorage.CompactRAiD(ctx, prefix, suffix, policy)
+	if err != nil || n == 0 {
+		return n, err
+	}
+
+	if gs.gitEnabled && gs.autoCommit {
+		commitMsg := fmt.Sprintf("Archive %d versions of RAiD %s/%s", n, prefix, suffix)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return n, nil
+}
+
+// RehydrateRAiD restores archived versions and commits the change to git
+func (gs *GitStorage) RehydrateRAiD(ctx context.Context, prefix, suffix string) (int, error) {
+	n, err := gs.FileStorage.RehydrateRAiD(ctx, prefix, suffix)
+	if err != nil || n == 0 {
+		return n, err
+	}
+
+	if gs.gitEnabled && gs.autoCommit {
+		commitMsg := fmt.Sprintf("Rehydrate %d versions of RAiD %s/%s", n, prefix, suffix)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return n, nil
+}
+
+// Verify the file backends support compaction
+var (
+	_ storage.VersionCompactor = (*FileStorage)(nil)
+	_ storage.VersionCompactor = (*GitStorage)(nil)
+)
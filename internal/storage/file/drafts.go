@@ -0,0 +1,113 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Drafts are kept one file each, at drafts/<id>.json. Listing the drafts
+// of a service point reads them all.
+
+func (fs *FileStorage) draftPath(id string) string {
+	return filepath.Join(fs.dataDir, "drafts", url.QueryEscape(id)+".json")
+}
+
+// SaveDraft stores a draft RAiD
+func (fs *FileStorage) SaveDraft(ctx context.Context, draft *models.Draft) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(fs.dataDir, "drafts"), 0755); err != nil {
+		return fmt.Errorf("failed to create drafts directory: %w", err)
+	}
+	data, err := json.MarshalIndent(draft, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal draft: %w", err)
+	}
+	if err := writeFileAtomic(fs.draftPath(draft.ID), data); err != nil {
+		return fmt.Errorf("failed to write draft file: %w", err)
+	}
+	return nil
+}
+
+// GetDraft retrieves a draft RAiD
+func (fs *FileStorage) GetDraft(ctx context.Context, id string) (*models.Draft, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return loadDraft(fs.draftPath(id))
+}
+
+// ListDrafts retrieves the drafts of a service point
+func (fs *FileStorage) ListDrafts(ctx context.Context, servicePoint int64) ([]*models.Draft, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir := filepath.Join(fs.dataDir, "drafts")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	drafts := make([]*models.Draft, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		draft, err := loadDraft(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue // Skip corrupted files
+		}
+		if draft.ServicePoint == servicePoint {
+			drafts = append(drafts, draft)
+		}
+	}
+	sort.Slice(drafts, func(i, j int) bool { return drafts[i].ID < drafts[j].ID })
+	return drafts, nil
+}
+
+// DeleteDraft removes a draft RAiD
+func (fs *FileStorage) DeleteDraft(ctx context.Context, id string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.Remove(fs.draftPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func loadDraft(path string) (*models.Draft, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read draft file: %w", err)
+	}
+	var draft models.Draft
+	if err := json.Unmarshal(data, &draft); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal draft: %w", err)
+	}
+	return &draft, nil
+}
+
+// Verify FileStorage can keep drafts
+var _ storage.DraftStore = (*FileStorage)(nil)
@@ -2,18 +2,26 @@ package file
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"math/big"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/leifj/go-raid/internal/identifier"
 	"github.com/leifj/go-raid/internal/models"
 	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/textnorm"
 )
 
+// suffixRandomRange bounds the random digits of generated suffixes
+const suffixRandomRange = 1_000_000
+
 func init() {
 	// Register file storage factory
 	storage.RegisterFactory(storage.StorageTypeFile, func(cfg interface{}) (storage.Repository, error) {
@@ -21,7 +29,7 @@ func init() {
 		if !ok || fileCfg == nil {
 			fileCfg = &storage.FileConfig{DataDir: "./data"}
 		}
-		return New(&Config{DataDir: fileCfg.DataDir})
+		return New(&Config{DataDir: fileCfg.DataDir, Lock: fileCfg.Lock, LeaseTTL: fileCfg.LeaseTTL})
 	})
 }
 
@@ -30,13 +38,30 @@ type FileStorage struct {
 	dataDir         string
 	raidDir         string
 	servicePointDir string
-	mu              sync.RWMutex
-	idCounter       int64
+	// mu is held for reading by operations on single records and for
+	// writing by those spanning the data directory, such as repairs
+	mu sync.RWMutex
+	// records orders the reads and writes of each RAiD and service point
+	records *recordLocks
+	// stateMu guards the catalogue, the related object index and idCounter
+	stateMu        sync.Mutex
+	idCounter      int64
+	prefixes       storage.PrefixAllocator
+	lock           *dirLock
+	relatedObjects *relatedObjectIndex
+	catalogue      *catalogue
+	stopWatch      chan struct{}
+	closeOnce      sync.Once
 }
 
 // Config holds configuration for file-based storage
 type Config struct {
 	DataDir string
+	// Lock is the multi-instance lock mode (LockExclusive by default,
+	// LockWait or LockNone)
+	Lock string
+	// LeaseTTL is how long the data directory lease lasts without renewal
+	LeaseTTL time.Duration
 }
 
 // New creates a new file-based storage instance
@@ -56,47 +81,71 @@ func New(cfg *Config) (*FileStorage, error) {
 		return nil, fmt.Errorf("failed to create servicepoints directory: %w", err)
 	}
 
+	// Only one instance may serve the directory: the service point counter
+	// and the catalogue are kept in memory. Writes also take advisory locks
+	// on their records, so tools sharing the directory cannot interleave
+	// with them.
+	lock, err := acquireLock(cfg.DataDir, cfg.Lock, cfg.LeaseTTL)
+	if err != nil {
+		return nil, err
+	}
+
 	fs := &FileStorage{
 		dataDir:         cfg.DataDir,
 		raidDir:         raidDir,
 		servicePointDir: servicePointDir,
 		idCounter:       1000, // Start service point IDs at 1000
+		records:         newRecordLocks(),
+		lock:            lock,
+		relatedObjects:  newRelatedObjectIndex(),
+		catalogue:       newCatalogue(),
+		stopWatch:       make(chan struct{}),
 	}
 
 	// Load the highest service point ID
 	if err := fs.loadMaxServicePointID(); err != nil {
+		lock.release()
 		return nil, err
 	}
 
+	watcher := fs.newWatcher()
+	if err := fs.indexRAiDs(); err != nil {
+		if watcher != nil {
+			watcher.Close()
+		}
+		lock.release()
+		return nil, err
+	}
+	go fs.watch(watcher, fs.stopWatch)
+
 	return fs, nil
 }
 
 // CreateRAiD mints a new RAiD
 func (fs *FileStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
-
-	// Generate identifier if not present
+	// Generate identifier if not present, before taking the locks the
+	// mint under it takes
 	if raid.Identifier == nil || raid.Identifier.ID == "" {
-		servicePointID := int64(0)
-		if raid.Identifier != nil && raid.Identifier.Owner != nil {
-			servicePointID = raid.Identifier.Owner.ServicePoint
-		}
-		prefix, suffix, err := fs.generateIdentifier(ctx, servicePointID)
-		if err != nil {
-			return nil, err
-		}
-		if raid.Identifier == nil {
-			raid.Identifier = &models.Identifier{}
-		}
-		raid.Identifier.ID = fmt.Sprintf("https://raid.org/%s/%s", prefix, suffix)
+		return storage.MintGenerated(ctx, fs, raid, identifier.FormatDefault)
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return nil, err
 	}
 
 	// Extract prefix and suffix from identifier
-	prefix, suffix, err := parseRAiDIdentifier(raid.Identifier.ID)
+	prefix, suffix, err := identifier.Parse(raid.Identifier.ID)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
 	if err != nil {
 		return nil, err
 	}
+	defer unlock()
 
 	// Check if already exists
 	filePath := fs.getRaidFilePath(prefix, suffix)
@@ -105,7 +154,7 @@ func (fs *FileStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*mode
 	}
 
 	// Set metadata
-	now := time.Now()
+	now := storage.WriteTime(ctx)
 	if raid.Metadata == nil {
 		raid.Metadata = &models.Metadata{}
 	}
@@ -121,6 +170,9 @@ func (fs *FileStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*mode
 	if err := fs.saveRAiD(raid, prefix, suffix); err != nil {
 		return nil, err
 	}
+	if err := fs.appendChange(ctx, prefix, suffix, nil, raid); err != nil {
+		return nil, err
+	}
 
 	return raid, nil
 }
@@ -133,10 +185,21 @@ func (fs *FileStorage) GetRAiD(ctx context.Context, prefix, suffix string) (*mod
 	return fs.loadRAiD(prefix, suffix)
 }
 
+// GetRAiDs retrieves several RAiDs under one lock
+func (fs *FileStorage) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return storage.GetEach(ctx, func(_ context.Context, prefix, suffix string) (*models.RAiD, error) {
+		return fs.loadRAiD(prefix, suffix)
+	}, refs)
+}
+
 // GetRAiDVersion retrieves a specific version of a RAiD
 func (fs *FileStorage) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
+	defer fs.rlockRecord(raidLockKey(prefix, suffix))()
 
 	// Load the current version
 	raid, err := fs.loadRAiD(prefix, suffix)
@@ -149,25 +212,58 @@ func (fs *FileStorage) GetRAiDVersion(ctx context.Context, prefix, suffix string
 		return raid, nil
 	}
 
-	// Try to load historical version
+	// Try to load historical version, rehydrating it if it is archived
 	historyFile := fs.getRaidHistoryFilePath(prefix, suffix, version)
-	if _, err := os.Stat(historyFile); err != nil {
+	data, err := os.ReadFile(historyFile)
+	if err != nil {
 		return nil, storage.ErrNotFound
 	}
+	if storage.IsArchived(data) {
+		stored, err := fs.storedVersions(fs.getRaidFilePath(prefix, suffix), fs.getRaidHistoryDir(prefix, suffix))
+		if err != nil {
+			return nil, err
+		}
+		return storage.RehydrateVersion(stored, version)
+	}
 
-	return fs.loadRAiDFromFile(historyFile)
+	var historical models.RAiD
+	if err := json.Unmarshal(data, &historical); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal RAiD: %w", err)
+	}
+	return &historical, nil
 }
 
 // UpdateRAiD updates an existing RAiD
 func (fs *FileStorage) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return nil, err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Load existing RAiD
 	existing, err := fs.loadRAiD(prefix, suffix)
 	if err != nil {
 		return nil, err
 	}
+	if err := storage.CheckVersion(raid, existing.Identifier.Version); err != nil {
+		return nil, err
+	}
+
+	// An update repeating the current content adds no version
+	unchanged, err := storage.Unchanged(fs.storedHash(prefix, suffix, existing.Identifier.Version), existing, raid)
+	if err != nil {
+		return nil, err
+	}
+	if unchanged {
+		return existing, nil
+	}
 
 	// Save old version to history
 	historyFile := fs.getRaidHistoryFilePath(prefix, suffix, existing.Identifier.Version)
@@ -176,7 +272,7 @@ func (fs *FileStorage) UpdateRAiD(ctx context.Context, prefix, suffix string, ra
 	}
 
 	// Update metadata
-	now := time.Now()
+	now := storage.WriteTime(ctx)
 	if raid.Metadata == nil {
 		raid.Metadata = &models.Metadata{}
 	}
@@ -190,100 +286,136 @@ func (fs *FileStorage) UpdateRAiD(ctx context.Context, prefix, suffix string, ra
 	if err := fs.saveRAiD(raid, prefix, suffix); err != nil {
 		return nil, err
 	}
+	if err := fs.appendChange(ctx, prefix, suffix, existing, raid); err != nil {
+		return nil, err
+	}
 
 	return raid, nil
 }
 
 // ListRAiDs retrieves RAiDs with filters
 func (fs *FileStorage) ListRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+	return fs.list(filter, false)
+}
 
-	raids, err := fs.loadAllRAiDs()
-	if err != nil {
-		return nil, err
-	}
+// ListPublicRAiDs retrieves only public RAiDs
+func (fs *FileStorage) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
+	return fs.list(filter, true)
+}
 
-	// Apply filters
-	filtered := fs.applyFilters(raids, filter)
+// list reads the RAiDs the catalogue picks for a listing, in handle order.
+// When the catalogue alone decides the listing, only the page is read.
+func (fs *FileStorage) list(filter *storage.RAiDFilter, public bool) ([]*models.RAiD, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
 
-	// Apply pagination
-	if filter != nil {
-		if filter.Offset > 0 && filter.Offset < len(filtered) {
-			filtered = filtered[filter.Offset:]
+	fs.stateMu.Lock()
+	var linked map[string]bool
+	if filter != nil && filter.RelatedObjectDOI != "" {
+		linked = make(map[string]bool)
+		for _, handle := range fs.relatedObjects.lookup(filter.RelatedObjectDOI) {
+			linked[handle] = true
 		}
-		if filter.Limit > 0 && filter.Limit < len(filtered) {
-			filtered = filtered[:filter.Limit]
+	}
+	paths := fs.catalogue.candidates(filter, public, linked)
+	// Soft deleted files are touched when deleted, so their modification
+	// time is when
+	deleted := make(map[string]time.Time)
+	for _, path := range paths {
+		if e := fs.catalogue.entries[path]; e.deleted {
+			deleted[path] = e.modTime
 		}
 	}
+	fs.stateMu.Unlock()
 
-	return filtered, nil
-}
-
-// ListPublicRAiDs retrieves only public RAiDs
-func (fs *FileStorage) ListPublicRAiDs(ctx context.Context, filter *storage.RAiDFilter) ([]*models.RAiD, error) {
-	raids, err := fs.ListRAiDs(ctx, filter)
-	if err != nil {
-		return nil, err
+	content := readsContent(filter)
+	if !content {
+		paths = pageOf(paths, filter)
 	}
 
-	// Filter for open access only
-	public := make([]*models.RAiD, 0)
-	for _, raid := range raids {
-		if raid.Access != nil && raid.Access.Type != nil && raid.Access.Type.ID == "https://vocabulary.raid.org/access.type.schema/82" {
-			public = append(public, raid)
+	raids := make([]*models.RAiD, 0, len(paths))
+	for _, path := range paths {
+		raid, err := fs.loadRAiDFromFile(path)
+		if err != nil {
+			continue // Skip files gone or corrupted since they were catalogued
 		}
+		if at, ok := deleted[path]; ok {
+			storage.MarkDeleted(raid, at)
+		}
+		raids = append(raids, raid)
+	}
+
+	// Apply filters
+	filtered := fs.applyFilters(raids, filter)
+	if content {
+		filtered = pageOf(filtered, filter)
 	}
 
-	return public, nil
+	return filtered, nil
 }
 
 // GetRAiDHistory retrieves version history
 func (fs *FileStorage) GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
+	defer fs.rlockRecord(raidLockKey(prefix, suffix))()
 
-	// Load current version
-	current, err := fs.loadRAiD(prefix, suffix)
-	if err != nil {
-		return nil, err
-	}
-
-	history := []*models.RAiD{current}
-
-	// Load historical versions
-	historyDir := fs.getRaidHistoryDir(prefix, suffix)
-	entries, err := os.ReadDir(historyDir)
+	// Load all versions, skipping corrupted history files
+	versions, err := fs.loadVersions(fs.getRaidFilePath(prefix, suffix), fs.getRaidHistoryDir(prefix, suffix), false)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return history, nil
-		}
 		return nil, err
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
-			filePath := filepath.Join(historyDir, entry.Name())
-			raid, err := fs.loadRAiDFromFile(filePath)
-			if err != nil {
-				continue // Skip corrupted history files
-			}
-			history = append(history, raid)
-		}
+	// Newest first, starting with the current version
+	history := make([]*models.RAiD, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		history = append(history, versions[i])
 	}
-
 	return history, nil
 }
 
 // DeleteRAiD soft deletes a RAiD
 func (fs *FileStorage) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	filePath := fs.getRaidFilePath(prefix, suffix)
 	deletedPath := filePath + ".deleted"
 
-	return os.Rename(filePath, deletedPath)
+	if err := os.Rename(filePath, deletedPath); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	// The modification time of the deleted file records when it was
+	// deleted, which the retention window is measured from
+	now := time.Now()
+	if err := os.Chtimes(deletedPath, now, now); err != nil {
+		return err
+	}
+	info, err := os.Stat(deletedPath)
+	if err != nil {
+		return err
+	}
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+	if e, ok := fs.catalogue.entries[filePath]; ok {
+		fs.forget(filePath)
+		e.deleted = true
+		e.modTime = info.ModTime()
+		fs.catalogue.entries[deletedPath] = e
+	}
+	return nil
 }
 
 // GenerateIdentifier generates a unique identifier
@@ -293,14 +425,25 @@ func (fs *FileStorage) GenerateIdentifier(ctx context.Context, servicePointID in
 
 // CreateServicePoint creates a new service point
 func (fs *FileStorage) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return nil, err
+	}
 
 	// Generate ID if not set
 	if sp.ID == 0 {
+		fs.stateMu.Lock()
 		fs.idCounter++
 		sp.ID = fs.idCounter
+		fs.stateMu.Unlock()
+	}
+	unlock, err := fs.lockRecord(servicePointLockKey(sp.ID))
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
 	// Check if already exists
 	filePath := fs.getServicePointFilePath(sp.ID)
@@ -326,8 +469,17 @@ func (fs *FileStorage) GetServicePoint(ctx context.Context, id int64) (*models.S
 
 // UpdateServicePoint updates a service point
 func (fs *FileStorage) UpdateServicePoint(ctx context.Context, id int64, sp *models.ServicePoint) (*models.ServicePoint, error) {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return nil, err
+	}
+	unlock, err := fs.lockRecord(servicePointLockKey(id))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	// Check if exists
 	if _, err := fs.loadServicePoint(id); err != nil {
@@ -372,20 +524,48 @@ func (fs *FileStorage) ListServicePoints(ctx context.Context) ([]*models.Service
 
 // DeleteServicePoint removes a service point
 func (fs *FileStorage) DeleteServicePoint(ctx context.Context, id int64) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	unlock, err := fs.lockRecord(servicePointLockKey(id))
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	filePath := fs.getServicePointFilePath(id)
 	return os.Remove(filePath)
 }
 
-// Close closes the storage
+// Close stops watching the data directory and releases its lock
 func (fs *FileStorage) Close() error {
-	return nil // No resources to clean up
+	fs.closeOnce.Do(func() { close(fs.stopWatch) })
+	return fs.lock.release()
+}
+
+// Release gives up the data directory lock for an instance taking over,
+// rejecting writes until Retake
+func (fs *FileStorage) Release() error {
+	return fs.lock.suspend()
+}
+
+// Retake takes the data directory lock back after Release
+func (fs *FileStorage) Retake() error {
+	return fs.lock.resume()
 }
 
-// HealthCheck verifies storage is accessible
+// Verify the file backends can hand their lock over
+var _ storage.LockHolder = (*FileStorage)(nil)
+
+// HealthCheck verifies storage is accessible and still held by this instance
 func (fs *FileStorage) HealthCheck(ctx context.Context) error {
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+
 	// Try to write a test file
 	testFile := filepath.Join(fs.dataDir, ".healthcheck")
 	if err := os.WriteFile(testFile, []byte("ok"), 0644); err != nil {
@@ -394,22 +574,76 @@ func (fs *FileStorage) HealthCheck(ctx context.Context) error {
 	return os.Remove(testFile)
 }
 
+// Stats reports file counts and disk usage of the data directory
+func (fs *FileStorage) Stats(ctx context.Context) (map[string]interface{}, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	var raids, deleted, versions, changes, totalBytes int64
+	err := filepath.Walk(fs.raidDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		totalBytes += info.Size()
+		switch {
+		case strings.Contains(path, ".history"):
+			versions++
+		case strings.Contains(path, changesDir):
+			changes++
+		case strings.HasSuffix(path, ".deleted"):
+			deleted++
+		case strings.HasSuffix(path, ".json"):
+			raids++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(fs.servicePointDir)
+	if err != nil {
+		return nil, err
+	}
+	fs.stateMu.Lock()
+	nextServicePoint := fs.idCounter + 1
+	fs.stateMu.Unlock()
+
+	return map[string]interface{}{
+		"dataDir":           fs.dataDir,
+		"raidFiles":         raids,
+		"deletedFiles":      deleted,
+		"historyFiles":      versions,
+		"changeFiles":       changes,
+		"servicePointFiles": len(entries),
+		"raidBytes":         totalBytes,
+		"nextServicePoint":  nextServicePoint,
+	}, nil
+}
+
 // Helper methods
 
 func (fs *FileStorage) generateIdentifier(ctx context.Context, servicePointID int64) (string, string, error) {
 	// Load service point to get prefix
-	prefix := "10.25.1.1" // Default prefix
+	var sp *models.ServicePoint
 	if servicePointID > 0 {
-		sp, err := fs.loadServicePoint(servicePointID)
-		if err == nil && sp.Prefix != "" {
-			prefix = sp.Prefix
+		if loaded, err := fs.loadServicePoint(servicePointID); err == nil {
+			sp = loaded
 		}
 	}
+	prefix := fs.prefixes.Allocate(ctx, sp)
 
-	// Generate suffix using timestamp + random component
-	suffix := fmt.Sprintf("%d", time.Now().UnixNano())
-
-	return prefix, suffix, nil
+	// Milliseconds keep suffixes in mint order; the random digits keep
+	// concurrent mints, including by other instances sharing the data
+	// directory, apart
+	n, err := rand.Int(rand.Reader, big.NewInt(suffixRandomRange))
+	if err != nil {
+		return "", "", err
+	}
+	return prefix, fmt.Sprintf("%d%06d", time.Now().UnixMilli(), n), nil
 }
 
 func (fs *FileStorage) getRaidFilePath(prefix, suffix string) string {
@@ -436,7 +670,11 @@ func (fs *FileStorage) getServicePointFilePath(id int64) string {
 
 func (fs *FileStorage) saveRAiD(raid *models.RAiD, prefix, suffix string) error {
 	filePath := fs.getRaidFilePath(prefix, suffix)
-	return fs.saveRAiDToFile(raid, filePath)
+	if err := fs.saveRAiDToFile(raid, filePath); err != nil {
+		return err
+	}
+	fs.recordWritten(filePath, raid, false)
+	return nil
 }
 
 func (fs *FileStorage) saveRAiDToFile(raid *models.RAiD, filePath string) error {
@@ -445,7 +683,7 @@ func (fs *FileStorage) saveRAiDToFile(raid *models.RAiD, filePath string) error
 		return fmt.Errorf("failed to marshal RAiD: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := writeFileAtomic(filePath, data); err != nil {
 		return fmt.Errorf("failed to write RAiD file: %w", err)
 	}
 
@@ -474,23 +712,15 @@ func (fs *FileStorage) loadRAiDFromFile(filePath string) (*models.RAiD, error) {
 	return &raid, nil
 }
 
-func (fs *FileStorage) loadAllRAiDs() ([]*models.RAiD, error) {
-	raids := make([]*models.RAiD, 0)
-
-	err := filepath.Walk(fs.raidDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") && !strings.Contains(path, ".history") && !strings.HasSuffix(path, ".deleted") {
-			raid, err := fs.loadRAiDFromFile(path)
-			if err == nil {
-				raids = append(raids, raid)
-			}
-		}
-		return nil
-	})
-
-	return raids, err
+// indexRAiDs builds the catalogue and the related object index from the
+// RAiD files
+func (fs *FileStorage) indexRAiDs() error {
+	files, err := fs.statRAiDFiles()
+	if err != nil {
+		return fmt.Errorf("failed to index RAiDs: %w", err)
+	}
+	fs.refreshCatalogue(files)
+	return nil
 }
 
 func (fs *FileStorage) saveServicePoint(sp *models.ServicePoint) error {
@@ -500,7 +730,7 @@ func (fs *FileStorage) saveServicePoint(sp *models.ServicePoint) error {
 		return fmt.Errorf("failed to marshal service point: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
+	if err := writeFileAtomic(filePath, data); err != nil {
 		return fmt.Errorf("failed to write service point file: %w", err)
 	}
 
@@ -588,21 +818,64 @@ func (fs *FileStorage) applyFilters(raids []*models.RAiD, filter *storage.RAiDFi
 			}
 		}
 
+		// Filter by TK and BC labels
+		if filter.HasTraditionalKnowledge != nil && *filter.HasTraditionalKnowledge != (len(raid.TraditionalKnowledge) > 0) {
+			continue
+		}
+		if filter.TraditionalKnowledgeLabelID != "" {
+			found := false
+			for _, label := range raid.TraditionalKnowledge {
+				if label.ID == filter.TraditionalKnowledgeLabelID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		// Filter by subject and subject keyword
+		if filter.SubjectID != "" || filter.SubjectKeyword != "" {
+			found := false
+			for _, subject := range raid.Subject {
+				if !strings.HasPrefix(subject.ID, filter.SubjectID) {
+					continue
+				}
+				if filter.SubjectKeyword == "" || slices.ContainsFunc(subject.Keyword, func(kw models.SubjectKeyword) bool {
+					return textnorm.Matches(kw.Text, filter.SubjectKeyword)
+				}) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		if filter.ServicePointID != 0 && !storage.OwnedBy(raid, filter.ServicePointID) {
+			continue
+		}
+
+		// Filter by related object DOI
+		if filter.RelatedObjectDOI != "" && !slices.ContainsFunc(raid.RelatedObject, func(obj models.RelatedObject) bool {
+			doi, _ := identifier.NormalizeDOI(obj.ID)
+			return doi == filter.RelatedObjectDOI
+		}) {
+			continue
+		}
+
+		if filter.Expression != nil && !storage.MatchesExpression(raid, filter.Expression) {
+			continue
+		}
+
 		filtered = append(filtered, raid)
 	}
 
 	return filtered
 }
 
-func parseRAiDIdentifier(id string) (prefix, suffix string, err error) {
-	// Expected format: https://raid.org/{prefix}/{suffix}
-	parts := strings.Split(id, "/")
-	if len(parts) < 5 {
-		return "", "", fmt.Errorf("invalid RAiD identifier format: %s", id)
-	}
-	return parts[3], parts[4], nil
-}
-
 func sanitizePath(s string) string {
 	// Replace characters that are problematic in file paths
 	s = strings.ReplaceAll(s, "/", "_")
@@ -0,0 +1,91 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Followers are kept one file each, at followers/<escaped actor URL>.json.
+
+func (fs *FileStorage) followerPath(id string) string {
+	return filepath.Join(fs.dataDir, "followers", url.QueryEscape(id)+".json")
+}
+
+// SaveFollower stores a follower
+func (fs *FileStorage) SaveFollower(ctx context.Context, follower *models.Follower) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(fs.dataDir, "followers"), 0755); err != nil {
+		return fmt.Errorf("failed to create followers directory: %w", err)
+	}
+	data, err := json.MarshalIndent(follower, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal follower: %w", err)
+	}
+	if err := writeFileAtomic(fs.followerPath(follower.ID), data); err != nil {
+		return fmt.Errorf("failed to write follower file: %w", err)
+	}
+	return nil
+}
+
+// ListFollowers retrieves all followers
+func (fs *FileStorage) ListFollowers(ctx context.Context) ([]*models.Follower, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir := filepath.Join(fs.dataDir, "followers")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	followers := make([]*models.Follower, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read follower file: %w", err)
+		}
+		var follower models.Follower
+		if err := json.Unmarshal(data, &follower); err != nil {
+			continue // Skip corrupted files
+		}
+		followers = append(followers, &follower)
+	}
+	sort.Slice(followers, func(i, j int) bool { return followers[i].ID < followers[j].ID })
+	return followers, nil
+}
+
+// DeleteFollower removes a follower
+func (fs *FileStorage) DeleteFollower(ctx context.Context, id string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.Remove(fs.followerPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Verify FileStorage can hold followers
+var _ storage.FollowerStore = (*FileStorage)(nil)
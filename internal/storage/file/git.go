@@ -3,11 +3,14 @@ package file
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/leifj/go-raid/internal/identifier"
 	"github.com/leifj/go-raid/internal/models"
 	"github.com/leifj/go-raid/internal/storage"
 )
@@ -24,7 +27,7 @@ func init() {
 			}
 		}
 		return NewGitStorage(&GitConfig{
-			FileConfig:  &Config{DataDir: fileCfg.DataDir},
+			FileConfig:  &Config{DataDir: fileCfg.DataDir, Lock: fileCfg.Lock, LeaseTTL: fileCfg.LeaseTTL},
 			Enabled:     true,
 			AutoCommit:  fileCfg.GitAutoCommit,
 			AuthorName:  fileCfg.GitAuthorName,
@@ -40,6 +43,9 @@ type GitStorage struct {
 	autoCommit  bool
 	authorName  string
 	authorEmail string
+	// gitMu serializes commits, which writes to different RAiDs may make
+	// at the same time
+	gitMu sync.Mutex
 }
 
 // GitConfig holds configuration for git-enabled storage
@@ -78,8 +84,13 @@ func NewGitStorage(cfg *GitConfig) (*GitStorage, error) {
 	// Initialize git repository if enabled
 	if gs.gitEnabled {
 		if err := gs.initGitRepo(); err != nil {
+			fs.Close()
 			return nil, fmt.Errorf("failed to initialize git repository: %w", err)
 		}
+		if err := gs.excludeLockFiles(); err != nil {
+			fs.Close()
+			return nil, err
+		}
 	}
 
 	return gs, nil
@@ -93,7 +104,7 @@ func (gs *GitStorage) CreateRAiD(ctx context.Context, raid *models.RAiD) (*model
 	}
 
 	if gs.gitEnabled && gs.autoCommit {
-		prefix, suffix, _ := parseRAiDIdentifier(result.Identifier.ID)
+		prefix, suffix, _ := identifier.Parse(result.Identifier.ID)
 		commitMsg := fmt.Sprintf("Create RAiD %s/%s", prefix, suffix)
 		if err := gs.gitCommit(commitMsg); err != nil {
 			// Log error but don't fail the operation
@@ -137,6 +148,23 @@ func (gs *GitStorage) DeleteRAiD(ctx context.Context, prefix, suffix string) err
 	return nil
 }
 
+// PurgeRAiD purges a soft deleted RAiD and commits to git. Earlier commits
+// still hold the RAiD; rewriting the history is left to the operator.
+func (gs *GitStorage) PurgeRAiD(ctx context.Context, purge *models.Purge) error {
+	if err := gs.FileStorage.PurgeRAiD(ctx, purge); err != nil {
+		return err
+	}
+
+	if gs.gitEnabled && gs.autoCommit {
+		commitMsg := fmt.Sprintf("Purge RAiD %s/%s", purge.Prefix, purge.Suffix)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
 // CreateServicePoint creates a service point and commits to git
 func (gs *GitStorage) CreateServicePoint(ctx context.Context, sp *models.ServicePoint) (*models.ServicePoint, error) {
 	result, err := gs.FileStorage.CreateServicePoint(ctx, sp)
@@ -259,7 +287,44 @@ func (gs *GitStorage) initGitRepo() error {
 	return nil
 }
 
+// excludeLockFiles keeps the instance lock, the lease, the record lock
+// files and files being written out of commits
+func (gs *GitStorage) excludeLockFiles() error {
+	excludeFile := filepath.Join(gs.dataDir, ".git", "info", "exclude")
+	data, err := os.ReadFile(excludeFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read git excludes: %w", err)
+	}
+
+	var missing []string
+	for _, name := range []string{"/" + lockFile, "/" + leaseFile, "/" + leaseFile + ".tmp", "/" + locksDir + "/", "*.tmp"} {
+		if !strings.Contains("\n"+string(data)+"\n", "\n"+name+"\n") {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(excludeFile), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(excludeFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to update git excludes: %w", err)
+	}
+	defer f.Close()
+	if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+		f.WriteString("\n")
+	}
+	_, err = f.WriteString(strings.Join(missing, "\n") + "\n")
+	return err
+}
+
 func (gs *GitStorage) gitCommit(message string) error {
+	gs.gitMu.Lock()
+	defer gs.gitMu.Unlock()
+
 	// Add all changes
 	if err := gs.runGitCommand("add", "-A"); err != nil {
 		return err
@@ -300,3 +365,6 @@ type GitCommit struct {
 
 // Verify GitStorage implements storage.Repository
 var _ storage.Repository = (*GitStorage)(nil)
+
+// Verify the file backends expose diagnostic statistics
+var _ storage.StatsProvider = (*GitStorage)(nil)
@@ -0,0 +1,60 @@
+package file
+
+import (
+	"sort"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// relatedObjectIndex maps the DOIs of related objects to the handles of
+// the current RAiDs linking them, so looking up the RAiDs behind an output
+// does not read every RAiD. It is built when the storage is opened and
+// kept up to date by writes, under the state mutex. Entries may go stale
+// when files are changed behind the storage's back, so lookups are checked
+// against the RAiDs they return.
+type relatedObjectIndex struct {
+	handles map[string]map[string]bool
+	dois    map[string][]string
+}
+
+func newRelatedObjectIndex() *relatedObjectIndex {
+	return &relatedObjectIndex{handles: make(map[string]map[string]bool), dois: make(map[string][]string)}
+}
+
+// set replaces the entries of the RAiD with handle by those of raid
+func (ix *relatedObjectIndex) set(handle string, raid *models.RAiD) {
+	ix.remove(handle)
+	for _, obj := range raid.RelatedObject {
+		doi, ok := identifier.NormalizeDOI(obj.ID)
+		if !ok {
+			continue
+		}
+		if ix.handles[doi] == nil {
+			ix.handles[doi] = make(map[string]bool)
+		}
+		ix.handles[doi][handle] = true
+		ix.dois[handle] = append(ix.dois[handle], doi)
+	}
+}
+
+// remove drops the entries of the RAiD with handle
+func (ix *relatedObjectIndex) remove(handle string) {
+	for _, doi := range ix.dois[handle] {
+		delete(ix.handles[doi], handle)
+		if len(ix.handles[doi]) == 0 {
+			delete(ix.handles, doi)
+		}
+	}
+	delete(ix.dois, handle)
+}
+
+// lookup returns the handles of the RAiDs linking doi, in order
+func (ix *relatedObjectIndex) lookup(doi string) []string {
+	handles := make([]string, 0, len(ix.handles[doi]))
+	for handle := range ix.handles[doi] {
+		handles = append(handles, handle)
+	}
+	sort.Strings(handles)
+	return handles
+}
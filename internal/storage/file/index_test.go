@@ -0,0 +1,70 @@
+package file
+
+import (
+	"context"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestRelatedObjectIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	linking := func(suffix string, dois ...string) *models.RAiD {
+		raid := &models.RAiD{Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix}}
+		for _, doi := range dois {
+			raid.RelatedObject = append(raid.RelatedObject, models.RelatedObject{ID: doi})
+		}
+		return raid
+	}
+	for _, raid := range []*models.RAiD{
+		linking("a", "https://doi.org/10.5061/dryad.abc"),
+		linking("b", "10.5061/DRYAD.ABC", "https://doi.org/10.1/other"),
+		linking("c", "https://doi.org/10.1/other"),
+	} {
+		if _, err := fs.CreateRAiD(ctx, raid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := fs.UpdateRAiD(ctx, "10.99999", "a", linking("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.DeleteRAiD(ctx, "10.99999", "c"); err != nil {
+		t.Fatal(err)
+	}
+
+	find := func(fs *FileStorage, doi string) []string {
+		raids, err := fs.ListRAiDs(ctx, &storage.RAiDFilter{RelatedObjectDOI: doi})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make([]string, len(raids))
+		for i, raid := range raids {
+			ids[i] = raid.Identifier.ID
+		}
+		return ids
+	}
+	if got := find(fs, "https://doi.org/10.5061/dryad.abc"); len(got) != 1 || got[0] != "https://raid.org/10.99999/b" {
+		t.Errorf("expected only b to link the dataset, got %v", got)
+	}
+	if got := find(fs, "https://doi.org/10.1/other"); len(got) != 1 {
+		t.Errorf("expected deleted RAiDs to leave the index, got %v", got)
+	}
+
+	// The index is rebuilt when the storage is opened again
+	fs.Close()
+	fs, err = New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if got := find(fs, "https://doi.org/10.5061/dryad.abc"); len(got) != 1 || got[0] != "https://raid.org/10.99999/b" {
+		t.Errorf("expected the rebuilt index to find b, got %v", got)
+	}
+}
@@ -0,0 +1,283 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+const (
+	// lockFile is held with an exclusive flock for the lifetime of an instance
+	lockFile = ".lock"
+	// leaseFile records the instance holding the data directory. It covers
+	// shared filesystems where flock is not enforced across hosts.
+	leaseFile = ".lease"
+
+	// Lock modes
+	LockExclusive = "exclusive" // fail at startup if another instance holds the directory
+	LockWait      = "wait"      // stand by until the holder releases the directory or its lease expires
+	LockNone      = "none"      // no coordination; only one instance may run
+
+	defaultLeaseTTL = 30 * time.Second
+)
+
+var (
+	// ErrLocked is returned when another instance holds the data directory
+	ErrLocked = errors.New("data directory is in use by another instance")
+	// ErrLeaseLost is returned for writes after another instance has taken
+	// over the data directory
+	ErrLeaseLost = errors.New("data directory lease lost to another instance")
+	// ErrHandedOver is returned for writes while the data directory is
+	// released to an instance taking over
+	ErrHandedOver = fmt.Errorf("%w: data directory handed over to another instance", storage.ErrBackendUnavailable)
+)
+
+// lease is the content of the lease file
+type lease struct {
+	Instance string    `json:"instance"`
+	Host     string    `json:"host"`
+	PID      int       `json:"pid"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+func (l *lease) String() string {
+	return fmt.Sprintf("instance on %s (pid %d, lease expires %s)", l.Host, l.PID, l.Expires.Format(time.RFC3339))
+}
+
+// dirLock coordinates access to a data directory between processes. The
+// holder keeps an flock on the lock file and renews the lease file every
+// third of the lease TTL; an instance that finds its lease taken over stops
+// accepting writes.
+type dirLock struct {
+	dataDir   string
+	file      *os.File
+	leasePath string
+	ttl       time.Duration
+	lease     lease
+	held      bool
+
+	mu        sync.Mutex
+	lost      bool
+	suspended bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// acquireLock takes the data directory lock in the given mode. A nil lock is
+// returned for LockNone.
+func acquireLock(dataDir, mode string, ttl time.Duration) (*dirLock, error) {
+	if ttl <= 0 {
+		ttl = defaultLeaseTTL
+	}
+
+	switch mode {
+	case "", LockExclusive:
+		return tryLock(dataDir, ttl)
+	case LockWait:
+		for {
+			l, err := tryLock(dataDir, ttl)
+			if !errors.Is(err, ErrLocked) {
+				return l, err
+			}
+			log.Printf("Waiting for data directory %s: %v", dataDir, err)
+			time.Sleep(ttl / 3)
+		}
+	case LockNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown lock mode: %s", mode)
+	}
+}
+
+// tryLock takes the flock and the lease without waiting
+func tryLock(dataDir string, ttl time.Duration) (*dirLock, error) {
+	l := &dirLock{dataDir: dataDir, leasePath: filepath.Join(dataDir, leaseFile), ttl: ttl}
+	if err := l.take(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// take takes the flock and the lease without waiting and starts renewing
+// the lease
+func (l *dirLock) take() error {
+	f, err := os.OpenFile(filepath.Join(l.dataDir, lockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	if err := flock(f); err != nil {
+		f.Close()
+		if holder, _ := readLease(l.leasePath); holder != nil {
+			return fmt.Errorf("%w: held by %s", ErrLocked, holder)
+		}
+		return fmt.Errorf("%w: %v", ErrLocked, err)
+	}
+
+	// The flock is ours, so a lease from this host belongs to an instance
+	// that has exited. On filesystems that do not enforce flock across hosts
+	// a live lease from another host still wins.
+	holder, err := readLease(l.leasePath)
+	if err != nil {
+		funlock(f)
+		f.Close()
+		return err
+	}
+	host, _ := os.Hostname()
+	now := time.Now()
+	if holder != nil && holder.Host != host && now.Before(holder.Expires) {
+		funlock(f)
+		f.Close()
+		return fmt.Errorf("%w: held by %s", ErrLocked, holder)
+	}
+	if holder != nil {
+		log.Printf("Taking over data directory from %s", holder)
+	}
+
+	l.file = f
+	l.lease = lease{
+		Instance: fmt.Sprintf("%s-%d-%d", host, os.Getpid(), now.UnixNano()),
+		Host:     host,
+		PID:      os.Getpid(),
+		Acquired: now.UTC(),
+	}
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+	if err := l.writeLease(); err != nil {
+		funlock(f)
+		f.Close()
+		return err
+	}
+	l.held = true
+
+	go l.renew()
+	return nil
+}
+
+// readLease returns the current lease, or nil if there is none
+func readLease(path string) (*lease, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lease: %w", err)
+	}
+	var l lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		// A torn write is treated as an expired lease
+		return nil, nil
+	}
+	return &l, nil
+}
+
+// writeLease extends the lease and writes it atomically
+func (l *dirLock) writeLease() error {
+	l.lease.Expires = time.Now().Add(l.ttl).UTC()
+	data, err := json.Marshal(l.lease)
+	if err != nil {
+		return err
+	}
+	tmp := l.leasePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write lease: %w", err)
+	}
+	return os.Rename(tmp, l.leasePath)
+}
+
+// renew keeps the lease alive until release
+func (l *dirLock) renew() {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case <-ticker.C:
+			holder, err := readLease(l.leasePath)
+			if err == nil && holder != nil && holder.Instance != l.lease.Instance {
+				log.Printf("Data directory lease taken over by %s; rejecting writes", holder)
+				l.mu.Lock()
+				l.lost = true
+				l.mu.Unlock()
+				return
+			}
+			if err := l.writeLease(); err != nil {
+				log.Printf("Failed to renew data directory lease: %v", err)
+			}
+		}
+	}
+}
+
+// check returns ErrLeaseLost once another instance has taken over, and
+// ErrHandedOver while suspended
+func (l *dirLock) check() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lost {
+		return ErrLeaseLost
+	}
+	if l.suspended {
+		return ErrHandedOver
+	}
+	return nil
+}
+
+// suspend gives up the data directory, rejecting writes, so that another
+// instance can take it over
+func (l *dirLock) suspend() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	l.suspended = true
+	l.mu.Unlock()
+	return l.release()
+}
+
+// resume takes the data directory back after suspend, unless another
+// instance holds it
+func (l *dirLock) resume() error {
+	if l == nil {
+		return nil
+	}
+	if err := l.take(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.suspended = false
+	l.mu.Unlock()
+	return nil
+}
+
+// release stops renewal, removes the lease if it is still ours and drops the
+// flock. It does nothing once released.
+func (l *dirLock) release() error {
+	if l == nil || !l.held {
+		return nil
+	}
+	l.held = false
+	close(l.stop)
+	<-l.done
+
+	if holder, _ := readLease(l.leasePath); holder != nil && holder.Instance == l.lease.Instance {
+		os.Remove(l.leasePath)
+	}
+	funlock(l.file)
+	return l.file.Close()
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package file
+
+import "os"
+
+// flock is not available on this platform; the lease file alone
+// coordinates instances
+func flock(f *os.File) error {
+	return nil
+}
+
+func flockWait(f *os.File) error {
+	return nil
+}
+
+func funlock(f *os.File) {}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestLock_SecondInstanceFails(t *testing.T) {
+	dir := t.TempDir()
+	first, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := New(&Config{DataDir: dir}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, leaseFile)); !os.IsNotExist(err) {
+		t.Errorf("expected lease to be removed on close")
+	}
+
+	second, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("expected lock to be free after close: %v", err)
+	}
+	second.Close()
+}
+
+func TestLock_Release(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	first, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+
+	if err := first.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := first.CreateServicePoint(ctx, &models.ServicePoint{Name: "Released"}); !errors.Is(err, storage.ErrBackendUnavailable) {
+		t.Errorf("expected writes to be rejected as unavailable after Release, got %v", err)
+	}
+
+	// The lock is taken back only once the instance taking over lets go
+	second, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("expected the lock to be free after Release: %v", err)
+	}
+	if err := first.Retake(); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked retaking a held lock, got %v", err)
+	}
+	second.Close()
+	if err := first.Retake(); err != nil {
+		t.Fatalf("Retake() = %v", err)
+	}
+	if _, err := first.CreateServicePoint(ctx, &models.ServicePoint{Name: "Retaken"}); err != nil {
+		t.Errorf("expected writes after Retake: %v", err)
+	}
+}
+
+func TestLock_LiveLeaseFromOtherHost(t *testing.T) {
+	dir := t.TempDir()
+	writeTestLease(t, dir, lease{Instance: "other", Host: "other-host", Expires: time.Now().Add(time.Minute)})
+
+	if _, err := New(&Config{DataDir: dir}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked for a live lease, got %v", err)
+	}
+
+	// An expired lease is taken over
+	writeTestLease(t, dir, lease{Instance: "other", Host: "other-host", Expires: time.Now().Add(-time.Second)})
+	fs, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatalf("expected expired lease to be taken over: %v", err)
+	}
+	fs.Close()
+}
+
+func TestLock_LeaseLost(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := New(&Config{DataDir: dir, LeaseTTL: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	writeTestLease(t, dir, lease{Instance: "other", Host: "other-host", Expires: time.Now().Add(time.Minute)})
+	deadline := time.Now().Add(time.Second)
+	for fs.lock.check() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := fs.CreateServicePoint(context.Background(), &models.ServicePoint{Name: "SP"}); err != ErrLeaseLost {
+		t.Errorf("expected ErrLeaseLost for writes, got %v", err)
+	}
+	if err := fs.HealthCheck(context.Background()); err != ErrLeaseLost {
+		t.Errorf("expected health check to fail, got %v", err)
+	}
+}
+
+func writeTestLease(t *testing.T, dir string, l lease) {
+	t.Helper()
+	data, _ := json.Marshal(l)
+	if err := os.WriteFile(filepath.Join(dir, leaseFile), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Proposals are kept one file each, at proposals/<id>.json. Listing them
+// reads them all.
+
+func (fs *FileStorage) proposalPath(id string) string {
+	return filepath.Join(fs.dataDir, "proposals", url.QueryEscape(id)+".json")
+}
+
+// SaveProposal stores a proposal
+func (fs *FileStorage) SaveProposal(ctx context.Context, proposal *models.Proposal) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(fs.dataDir, "proposals"), 0755); err != nil {
+		return fmt.Errorf("failed to create proposals directory: %w", err)
+	}
+	data, err := json.MarshalIndent(proposal, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal proposal: %w", err)
+	}
+	if err := writeFileAtomic(fs.proposalPath(proposal.ID), data); err != nil {
+		return fmt.Errorf("failed to write proposal file: %w", err)
+	}
+	return nil
+}
+
+// GetProposal retrieves a proposal
+func (fs *FileStorage) GetProposal(ctx context.Context, id string) (*models.Proposal, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return loadProposal(fs.proposalPath(id))
+}
+
+// ListProposals retrieves the proposals for the RAiDs of a service point
+func (fs *FileStorage) ListProposals(ctx context.Context, servicePoint int64) ([]*models.Proposal, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir := filepath.Join(fs.dataDir, "proposals")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	proposals := make([]*models.Proposal, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		proposal, err := loadProposal(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue // Skip corrupted files
+		}
+		if proposal.ServicePoint == servicePoint {
+			proposals = append(proposals, proposal)
+		}
+	}
+	sort.Slice(proposals, func(i, j int) bool { return proposals[i].ID < proposals[j].ID })
+	return proposals, nil
+}
+
+// DeleteProposal removes a proposal
+func (fs *FileStorage) DeleteProposal(ctx context.Context, id string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.Remove(fs.proposalPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func loadProposal(path string) (*models.Proposal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read proposal file: %w", err)
+	}
+	var proposal models.Proposal
+	if err := json.Unmarshal(data, &proposal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal: %w", err)
+	}
+	return &proposal, nil
+}
+
+// Verify FileStorage can hold proposals
+var _ storage.ProposalStore = (*FileStorage)(nil)
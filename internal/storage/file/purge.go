@@ -0,0 +1,166 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Purges are recorded at purges/<escaped prefix>/<escaped suffix>/<unix
+// nanoseconds>.json, before anything is removed, so that a purge
+// interrupted part way is on record and can be run again.
+
+func (fs *FileStorage) purgePath(purge *models.Purge) string {
+	return filepath.Join(fs.dataDir, "purges", url.QueryEscape(purge.Prefix), url.QueryEscape(purge.Suffix),
+		strconv.FormatInt(purge.Purged.UnixNano(), 10)+".json")
+}
+
+// PurgeRAiD removes a soft deleted RAiD with its history, changes, tags
+// and attachments
+func (fs *FileStorage) PurgeRAiD(ctx context.Context, purge *models.Purge) error {
+	if err := fs.purgeRAiD(purge); err != nil {
+		return err
+	}
+	return fs.purgeTags(purge.Prefix, purge.Suffix)
+}
+
+// purgeRAiD records purge and removes the files of the RAiD
+func (fs *FileStorage) purgeRAiD(purge *models.Purge) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(purge.Prefix, purge.Suffix))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	filePath := filepath.Join(fs.raidDir, sanitizePath(purge.Prefix), sanitizePath(purge.Suffix)+".json")
+	deletedPath := filePath + ".deleted"
+	info, err := os.Stat(deletedPath)
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(filePath); err == nil {
+			return storage.ErrNotDeleted
+		}
+		return storage.ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	purge.Deleted = info.ModTime()
+
+	if err := saveRecord(fs.purgePath(purge), purge, "purge"); err != nil {
+		return err
+	}
+
+	prefixDir := filepath.Join(fs.raidDir, sanitizePath(purge.Prefix))
+	for _, path := range []string{
+		filepath.Join(prefixDir, ".history", sanitizePath(purge.Suffix)),
+		filepath.Join(prefixDir, changesDir, sanitizePath(purge.Suffix)+".json"),
+		fs.attachmentDir(purge.Prefix, purge.Suffix),
+		deletedPath,
+	} {
+		if err := os.RemoveAll(path); err != nil {
+			return fmt.Errorf("failed to purge RAiD: %w", err)
+		}
+	}
+
+	fs.stateMu.Lock()
+	fs.forget(deletedPath)
+	fs.stateMu.Unlock()
+	return nil
+}
+
+// purgeTags removes the tags every service point gave a RAiD
+func (fs *FileStorage) purgeTags(prefix, suffix string) error {
+	paths, err := filepath.Glob(filepath.Join(fs.dataDir, "tags", "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		servicePoint, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		tags, err := fs.loadTags(servicePoint)
+		if err != nil {
+			return err
+		}
+		if len(tags[prefix+"/"+suffix]) == 0 {
+			continue
+		}
+		if _, err := fs.updateTags(servicePoint, prefix, suffix, func([]string) []string { return nil }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListPurges returns the recorded purges, oldest first
+func (fs *FileStorage) ListPurges(ctx context.Context) ([]*models.Purge, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	paths, err := filepath.Glob(filepath.Join(fs.dataDir, "purges", "*", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	purges := make([]*models.Purge, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read purge file: %w", err)
+		}
+		var purge models.Purge
+		if err := json.Unmarshal(data, &purge); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal purge: %w", err)
+		}
+		purges = append(purges, &purge)
+	}
+	sort.SliceStable(purges, func(i, j int) bool { return purges[i].Purged.Before(purges[j].Purged) })
+	return purges, nil
+}
+
+// ImportPurge records a purge made elsewhere, removing nothing
+func (fs *FileStorage) ImportPurge(ctx context.Context, purge *models.Purge) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	return saveRecord(fs.purgePath(purge), purge, "purge")
+}
+
+// saveRecord writes the audit record of kind, a purge or redaction, to path
+func saveRecord(path string, record interface{}, kind string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %ss directory: %w", kind, err)
+	}
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", kind, err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write %s file: %w", kind, err)
+	}
+	return nil
+}
+
+// Verify FileStorage can purge RAiDs and import audit records
+var (
+	_ storage.Purger        = (*FileStorage)(nil)
+	_ storage.AuditImporter = (*FileStorage)(nil)
+)
@@ -0,0 +1,141 @@
+package file
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	// locksDir holds the advisory lock files writers take, so that
+	// processes sharing the data directory do not interleave writes to a
+	// record
+	locksDir = ".locks"
+	// lockStripes is the number of lock files records are spread over;
+	// records sharing a file only wait for each other across processes
+	lockStripes = 256
+)
+
+// recordLocks hands out a lock per RAiD or service point, so that reads
+// and writes of different records do not wait for each other. Locks are
+// dropped when no one holds or waits for them.
+type recordLocks struct {
+	mu    sync.Mutex
+	locks map[string]*recordLock
+}
+
+type recordLock struct {
+	sync.RWMutex
+	refs int
+}
+
+func newRecordLocks() *recordLocks {
+	return &recordLocks{locks: make(map[string]*recordLock)}
+}
+
+// get returns the lock of key, counting the caller as a user
+func (l *recordLocks) get(key string) *recordLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rl, ok := l.locks[key]
+	if !ok {
+		rl = &recordLock{}
+		l.locks[key] = rl
+	}
+	rl.refs++
+	return rl
+}
+
+// put drops the caller as a user of the lock of key
+func (l *recordLocks) put(key string, rl *recordLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rl.refs--; rl.refs == 0 {
+		delete(l.locks, key)
+	}
+}
+
+func raidLockKey(prefix, suffix string) string {
+	return "raid/" + prefix + "/" + suffix
+}
+
+func servicePointLockKey(id int64) string {
+	return fmt.Sprintf("servicepoint/%d", id)
+}
+
+// lockRecord takes the write lock of the record with key, in process and
+// with an advisory lock on its stripe's lock file shared with other
+// processes, and returns the function releasing both
+func (fs *FileStorage) lockRecord(key string) (func(), error) {
+	rl := fs.records.get(key)
+	rl.Lock()
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	path := filepath.Join(fs.dataDir, locksDir, fmt.Sprintf("%03d", h.Sum32()%lockStripes))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if os.IsNotExist(err) {
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+			f, err = os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		}
+	}
+	if err == nil {
+		if err = flockWait(f); err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		rl.Unlock()
+		fs.records.put(key, rl)
+		return nil, fmt.Errorf("failed to lock %s: %w", key, err)
+	}
+
+	return func() {
+		funlock(f)
+		f.Close()
+		rl.Unlock()
+		fs.records.put(key, rl)
+	}, nil
+}
+
+// rlockRecord takes the read lock of the record with key in process and
+// returns the function releasing it. Files are replaced atomically, so
+// readers need no lock against other processes.
+func (fs *FileStorage) rlockRecord(key string) func() {
+	rl := fs.records.get(key)
+	rl.RLock()
+	return func() {
+		rl.RUnlock()
+		fs.records.put(key, rl)
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so readers never see a partly written file
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestRecordLocks_ConcurrentUpdates(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	// Two storages without the directory lock stand in for two processes
+	var instances []*FileStorage
+	for range 2 {
+		fs, err := New(&Config{DataDir: dir, Lock: LockNone})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer fs.Close()
+		instances = append(instances, fs)
+	}
+
+	for _, suffix := range []string{"a", "b"} {
+		raid := &models.RAiD{
+			Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix},
+			Title:      []models.Title{{Text: "Version 1"}},
+		}
+		if _, err := instances[0].CreateRAiD(ctx, raid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const updates = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, 4*updates)
+	for i, fs := range instances {
+		for _, suffix := range []string{"a", "b"} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for n := range updates {
+					_, err := fs.UpdateRAiD(ctx, "10.99999", suffix, &models.RAiD{
+						Identifier: &models.Identifier{ID: "https://raid.org/10.99999/" + suffix},
+						Title:      []models.Title{{Text: fmt.Sprintf("Instance %d update %d", i, n)}},
+					})
+					if err != nil {
+						errs <- err
+					}
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	// No update was lost to another writing the same version
+	for _, suffix := range []string{"a", "b"} {
+		raid, err := instances[1].GetRAiD(ctx, "10.99999", suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 1 + len(instances)*updates; raid.Identifier.Version != want {
+			t.Errorf("expected %s at version %d, got %d", suffix, want, raid.Identifier.Version)
+		}
+		history, err := instances[1].GetRAiDHistory(ctx, "10.99999", suffix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1+len(instances)*updates {
+			t.Errorf("expected every version of %s in its history, got %d", suffix, len(history))
+		}
+	}
+
+	if n := len(instances[0].records.locks); n != 0 {
+		t.Errorf("expected record locks to be dropped once released, %d left", n)
+	}
+}
@@ -0,0 +1,147 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Redactions are recorded at redactions/<escaped prefix>/<escaped
+// suffix>/<unix nanoseconds>.json, before any version is rewritten, like
+// purges.
+
+func (fs *FileStorage) redactionPath(redaction *models.Redaction) string {
+	return filepath.Join(fs.dataDir, "redactions", url.QueryEscape(redaction.Prefix), url.QueryEscape(redaction.Suffix),
+		strconv.FormatInt(redaction.Redacted.UnixNano(), 10)+".json")
+}
+
+// RedactRAiD rewrites the versions of a RAiD redact changes, with the
+// archived history files, and records the changes of the RAiD again
+func (fs *FileStorage) RedactRAiD(ctx context.Context, prefix, suffix string, redact func(*models.RAiD) bool, redaction *models.Redaction) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return 0, err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	path, err := fs.raidFilePath(prefix, suffix)
+	if err != nil {
+		return 0, err
+	}
+	stored, err := fs.storedVersions(path, fs.getRaidHistoryDir(prefix, suffix))
+	if err != nil {
+		return 0, err
+	}
+	changes, err := fs.loadChanges(prefix, suffix)
+	if err != nil {
+		return 0, err
+	}
+	rewritten, recorded, n, err := storage.RedactVersions(stored, changes, redact)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+
+	redaction.Versions = n
+	if err := saveRecord(fs.redactionPath(redaction), redaction, "redaction"); err != nil {
+		return 0, err
+	}
+
+	// The current file holds the newest version
+	current := stored[len(stored)-1].Version
+	for _, v := range rewritten {
+		var raid models.RAiD
+		if err := json.Unmarshal(v.Data, &raid); err != nil {
+			return 0, fmt.Errorf("version %d: %w", v.Version, err)
+		}
+		if v.Version != current {
+			if err := fs.saveRAiDToFile(&raid, fs.getRaidHistoryFilePath(prefix, suffix, v.Version)); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if err := fs.saveRAiDToFile(&raid, path); err != nil {
+			return 0, err
+		}
+		fs.recordWritten(path, &raid, strings.HasSuffix(path, ".deleted"))
+	}
+	if err := fs.saveChanges(prefix, suffix, recorded); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// ListRedactions returns the recorded redactions, oldest first
+func (fs *FileStorage) ListRedactions(ctx context.Context) ([]*models.Redaction, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	paths, err := filepath.Glob(filepath.Join(fs.dataDir, "redactions", "*", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	redactions := make([]*models.Redaction, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redaction file: %w", err)
+		}
+		var redaction models.Redaction
+		if err := json.Unmarshal(data, &redaction); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal redaction: %w", err)
+		}
+		redactions = append(redactions, &redaction)
+	}
+	sort.SliceStable(redactions, func(i, j int) bool { return redactions[i].Redacted.Before(redactions[j].Redacted) })
+	return redactions, nil
+}
+
+// ImportRedaction records a redaction made elsewhere, rewriting no version
+func (fs *FileStorage) ImportRedaction(ctx context.Context, redaction *models.Redaction) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	return saveRecord(fs.redactionPath(redaction), redaction, "redaction")
+}
+
+// RedactRAiD redacts the versions of a RAiD and commits to git. Earlier
+// commits still hold what was removed; rewriting the history is left to
+// the operator.
+func (gs *GitStorage) RedactRAiD(ctx context.Context, prefix, suffix string, redact func(*models.RAiD) bool, redaction *models.Redaction) (int, error) {
+	n, err := gs.FileStorage.RedactRAiD(ctx, prefix, suffix, redact, redaction)
+	if err != nil || n == 0 {
+		return n, err
+	}
+
+	if gs.gitEnabled && gs.autoCommit {
+		commitMsg := fmt.Sprintf("Redact RAiD %s/%s", prefix, suffix)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return n, nil
+}
+
+// Verify the file backends can redact RAiDs
+var (
+	_ storage.Redactor = (*FileStorage)(nil)
+	_ storage.Redactor = (*GitStorage)(nil)
+)
@@ -0,0 +1,159 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Reservations are kept one file each, at reservations/<prefix>/<suffix>.json,
+// and written under the lock of the RAiD they reserve, so that a handle is
+// not reserved while it is minted.
+
+func (fs *FileStorage) reservationPath(prefix, suffix string) string {
+	return filepath.Join(fs.dataDir, "reservations", url.QueryEscape(prefix), url.QueryEscape(suffix)+".json")
+}
+
+// SaveReservation stores a reservation unless its handle is minted or
+// held by a live reservation
+func (fs *FileStorage) SaveReservation(ctx context.Context, reservation *models.Reservation) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(reservation.Prefix, reservation.Suffix))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(fs.getRaidFilePath(reservation.Prefix, reservation.Suffix)); err == nil {
+		return storage.ErrAlreadyExists
+	}
+	path := fs.reservationPath(reservation.Prefix, reservation.Suffix)
+	if held, err := loadReservation(path); err == nil && time.Now().Before(held.ExpiresAt) {
+		return storage.ErrAlreadyExists
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create reservations directory: %w", err)
+	}
+	data, err := json.MarshalIndent(reservation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal reservation: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write reservation file: %w", err)
+	}
+	return nil
+}
+
+// GetReservation retrieves the live reservation of a handle
+func (fs *FileStorage) GetReservation(ctx context.Context, prefix, suffix string) (*models.Reservation, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	reservation, err := loadReservation(fs.reservationPath(prefix, suffix))
+	if err != nil {
+		return nil, err
+	}
+	if !time.Now().Before(reservation.ExpiresAt) {
+		return nil, storage.ErrNotFound
+	}
+	return reservation, nil
+}
+
+// DeleteReservation releases the reservation of a handle
+func (fs *FileStorage) DeleteReservation(ctx context.Context, prefix, suffix string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.Remove(fs.reservationPath(prefix, suffix)); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ReleaseExpiredReservations removes the reservations expired by at. Each
+// is read again under its lock, so that one renewed meanwhile is kept.
+func (fs *FileStorage) ReleaseExpiredReservations(ctx context.Context, at time.Time) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return 0, err
+	}
+	paths, err := filepath.Glob(filepath.Join(fs.dataDir, "reservations", "*", "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	released := 0
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return released, err
+		}
+		reservation, err := loadReservation(path)
+		if err != nil || at.Before(reservation.ExpiresAt) {
+			continue
+		}
+		ok, err := fs.releaseExpired(reservation.Prefix, reservation.Suffix, at)
+		if err != nil {
+			return released, err
+		}
+		if ok {
+			released++
+		}
+	}
+	return released, nil
+}
+
+// releaseExpired removes the reservation of a handle if it expired by at
+func (fs *FileStorage) releaseExpired(prefix, suffix string, at time.Time) (bool, error) {
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	path := fs.reservationPath(prefix, suffix)
+	reservation, err := loadReservation(path)
+	if err != nil || at.Before(reservation.ExpiresAt) {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+func loadReservation(path string) (*models.Reservation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read reservation file: %w", err)
+	}
+	var reservation models.Reservation
+	if err := json.Unmarshal(data, &reservation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal reservation: %w", err)
+	}
+	return &reservation, nil
+}
+
+// Verify FileStorage can hold reservations
+var _ storage.ReservationStore = (*FileStorage)(nil)
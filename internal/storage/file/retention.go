@@ -0,0 +1,107 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// PruneReservations removes the reservations made before the cutoff of
+// their service point. Each is read again under its lock, so that one
+// replaced meanwhile is judged by its replacement.
+func (fs *FileStorage) PruneReservations(ctx context.Context, cutoffs storage.Cutoffs, dryRun bool) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return 0, err
+	}
+	paths, err := filepath.Glob(filepath.Join(fs.dataDir, "reservations", "*", "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return pruned, err
+		}
+		reservation, err := loadReservation(path)
+		if err != nil || !reservation.Created.Before(cutoffs.For(reservation.ServicePoint)) {
+			continue
+		}
+		if dryRun {
+			pruned++
+			continue
+		}
+		ok, err := fs.pruneReservation(reservation.Prefix, reservation.Suffix, cutoffs)
+		if err != nil {
+			return pruned, err
+		}
+		if ok {
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// pruneReservation removes the reservation of a handle if it was made
+// before the cutoff of its service point
+func (fs *FileStorage) pruneReservation(prefix, suffix string, cutoffs storage.Cutoffs) (bool, error) {
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return false, err
+	}
+	defer unlock()
+
+	path := fs.reservationPath(prefix, suffix)
+	reservation, err := loadReservation(path)
+	if err != nil || !reservation.Created.Before(cutoffs.For(reservation.ServicePoint)) {
+		return false, nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+// PruneAudit removes the purge and redaction files recorded before before,
+// going by the time in their names
+func (fs *FileStorage) PruneAudit(ctx context.Context, before time.Time, dryRun bool) (int, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return 0, err
+	}
+	pruned := 0
+	for _, dir := range []string{"purges", "redactions"} {
+		paths, err := filepath.Glob(filepath.Join(fs.dataDir, dir, "*", "*", "*.json"))
+		if err != nil {
+			return pruned, err
+		}
+		for _, path := range paths {
+			nanos, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(path), ".json"), 10, 64)
+			if err != nil || !time.Unix(0, nanos).Before(before) {
+				continue
+			}
+			if !dryRun {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return pruned, err
+				}
+			}
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+// Verify FileStorage can prune reservations and audit records
+var (
+	_ storage.ReservationPruner = (*FileStorage)(nil)
+	_ storage.AuditPruner       = (*FileStorage)(nil)
+)
@@ -0,0 +1,145 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Scheduled changes are kept one file each, at scheduled/<id>.json.
+// Listing them, and finding those due, reads them all.
+
+func (fs *FileStorage) scheduledPath(id string) string {
+	return filepath.Join(fs.dataDir, "scheduled", url.QueryEscape(id)+".json")
+}
+
+// SaveScheduledChange stores a scheduled change
+func (fs *FileStorage) SaveScheduledChange(ctx context.Context, change *models.ScheduledChange) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(fs.dataDir, "scheduled"), 0755); err != nil {
+		return fmt.Errorf("failed to create scheduled directory: %w", err)
+	}
+	data, err := json.MarshalIndent(change, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal scheduled change: %w", err)
+	}
+	if err := writeFileAtomic(fs.scheduledPath(change.ID), data); err != nil {
+		return fmt.Errorf("failed to write scheduled change file: %w", err)
+	}
+	return nil
+}
+
+// GetScheduledChange retrieves a scheduled change
+func (fs *FileStorage) GetScheduledChange(ctx context.Context, id string) (*models.ScheduledChange, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return loadScheduledChange(fs.scheduledPath(id))
+}
+
+// ListScheduledChanges retrieves the scheduled changes of a service point
+func (fs *FileStorage) ListScheduledChanges(ctx context.Context, servicePoint int64) ([]*models.ScheduledChange, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	changes, err := fs.scheduledChanges(func(change *models.ScheduledChange) bool {
+		return change.ServicePoint == servicePoint
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	return changes, nil
+}
+
+// DueChanges retrieves the pending changes effective by at
+func (fs *FileStorage) DueChanges(ctx context.Context, at time.Time) ([]*models.ScheduledChange, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	changes, err := fs.scheduledChanges(func(change *models.ScheduledChange) bool {
+		return change.Status == models.SchedulePending && !change.EffectiveAt.After(at)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if !changes[i].EffectiveAt.Equal(changes[j].EffectiveAt) {
+			return changes[i].EffectiveAt.Before(changes[j].EffectiveAt)
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	return changes, nil
+}
+
+// scheduledChanges reads the scheduled changes keep selects
+func (fs *FileStorage) scheduledChanges(keep func(*models.ScheduledChange) bool) ([]*models.ScheduledChange, error) {
+	dir := filepath.Join(fs.dataDir, "scheduled")
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	changes := make([]*models.ScheduledChange, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		change, err := loadScheduledChange(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue // Skip corrupted files
+		}
+		if keep(change) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+// DeleteScheduledChange removes a scheduled change
+func (fs *FileStorage) DeleteScheduledChange(ctx context.Context, id string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.Remove(fs.scheduledPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func loadScheduledChange(path string) (*models.ScheduledChange, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read scheduled change file: %w", err)
+	}
+	var change models.ScheduledChange
+	if err := json.Unmarshal(data, &change); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal scheduled change: %w", err)
+	}
+	return &change, nil
+}
+
+// Verify FileStorage can hold scheduled changes
+var _ storage.ScheduleStore = (*FileStorage)(nil)
@@ -0,0 +1,136 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Saved searches are kept one file each, at searches/<owner>/<name>.json
+// with both escaped. Feeds are looked up by reading every search, of which
+// there are few.
+
+func (fs *FileStorage) searchDir(owner string) string {
+	return filepath.Join(fs.dataDir, "searches", url.QueryEscape(owner))
+}
+
+func (fs *FileStorage) searchPath(owner, name string) string {
+	return filepath.Join(fs.searchDir(owner), url.QueryEscape(name)+".json")
+}
+
+// SaveSearch stores a saved search
+func (fs *FileStorage) SaveSearch(ctx context.Context, search *models.SavedSearch) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fs.searchDir(search.Owner), 0755); err != nil {
+		return fmt.Errorf("failed to create searches directory: %w", err)
+	}
+	data, err := json.MarshalIndent(search, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal saved search: %w", err)
+	}
+	if err := writeFileAtomic(fs.searchPath(search.Owner, search.Name), data); err != nil {
+		return fmt.Errorf("failed to write saved search file: %w", err)
+	}
+	return nil
+}
+
+// GetSearch retrieves a saved search
+func (fs *FileStorage) GetSearch(ctx context.Context, owner, name string) (*models.SavedSearch, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	return loadSearch(fs.searchPath(owner, name))
+}
+
+// GetSearchByFeed retrieves the saved search published under feed
+func (fs *FileStorage) GetSearchByFeed(ctx context.Context, feed string) (*models.SavedSearch, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	paths, err := filepath.Glob(filepath.Join(fs.dataDir, "searches", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		search, err := loadSearch(path)
+		if err != nil {
+			continue // Skip corrupted files
+		}
+		if search.Feed != "" && search.Feed == feed {
+			return search, nil
+		}
+	}
+	return nil, storage.ErrNotFound
+}
+
+// ListSearches retrieves the saved searches of owner
+func (fs *FileStorage) ListSearches(ctx context.Context, owner string) ([]*models.SavedSearch, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	entries, err := os.ReadDir(fs.searchDir(owner))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	searches := make([]*models.SavedSearch, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		search, err := loadSearch(filepath.Join(fs.searchDir(owner), entry.Name()))
+		if err != nil {
+			continue // Skip corrupted files
+		}
+		searches = append(searches, search)
+	}
+	sort.Slice(searches, func(i, j int) bool { return searches[i].Name < searches[j].Name })
+	return searches, nil
+}
+
+// DeleteSearch removes a saved search
+func (fs *FileStorage) DeleteSearch(ctx context.Context, owner, name string) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	if err := os.Remove(fs.searchPath(owner, name)); err != nil {
+		if os.IsNotExist(err) {
+			return storage.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func loadSearch(path string) (*models.SavedSearch, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, storage.ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read saved search file: %w", err)
+	}
+	var search models.SavedSearch
+	if err := json.Unmarshal(data, &search); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal saved search: %w", err)
+	}
+	return &search, nil
+}
+
+// Verify FileStorage can keep saved searches
+var _ storage.SearchStore = (*FileStorage)(nil)
@@ -0,0 +1,221 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// ExportRAiDs walks the data directory and reports every RAiD, including
+// deleted ones, with its history
+func (fs *FileStorage) ExportRAiDs(ctx context.Context, fn func(*storage.RAiDRecord) error) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	prefixes, err := os.ReadDir(fs.raidDir)
+	if err != nil {
+		return err
+	}
+
+	for _, prefixDir := range prefixes {
+		if !prefixDir.IsDir() {
+			continue
+		}
+		dir := filepath.Join(fs.raidDir, prefixDir.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() {
+				continue
+			}
+
+			deleted := strings.HasSuffix(name, ".json.deleted")
+			if !deleted && !strings.HasSuffix(name, ".json") {
+				continue
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			suffix := strings.TrimSuffix(strings.TrimSuffix(name, ".deleted"), ".json")
+			versions, err := fs.loadVersions(filepath.Join(dir, name), filepath.Join(dir, ".history", suffix), true)
+			if err != nil {
+				return fmt.Errorf("failed to export %s: %w", filepath.Join(prefixDir.Name(), name), err)
+			}
+
+			changes, err := fs.loadChanges(prefixDir.Name(), suffix)
+			if err != nil {
+				return fmt.Errorf("failed to export %s: %w", filepath.Join(prefixDir.Name(), name), err)
+			}
+
+			record := &storage.RAiDRecord{
+				Versions: versions,
+				Deleted:  deleted,
+				Actors:   storage.ChangeActors(changes),
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ImportRAiD writes a RAiD's history files and current file
+func (fs *FileStorage) ImportRAiD(ctx context.Context, record *storage.RAiDRecord) error {
+	current := record.Current()
+	if current == nil || current.Identifier == nil {
+		return fmt.Errorf("RAiD record has no versions")
+	}
+
+	prefix, suffix, err := identifier.Parse(current.Identifier.ID)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	unlock, err := fs.lockRecord(raidLockKey(prefix, suffix))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	filePath := fs.getRaidFilePath(prefix, suffix)
+	for _, path := range []string{filePath, filePath + ".deleted"} {
+		if _, err := os.Stat(path); err == nil {
+			return storage.ErrAlreadyExists
+		}
+	}
+
+	for _, version := range record.Versions[:len(record.Versions)-1] {
+		historyFile := fs.getRaidHistoryFilePath(prefix, suffix, version.Identifier.Version)
+		if err := fs.saveRAiDToFile(version, historyFile); err != nil {
+			return err
+		}
+	}
+	changes, err := storage.RecordChanges(record)
+	if err != nil {
+		return err
+	}
+	if err := fs.saveChanges(prefix, suffix, changes); err != nil {
+		return err
+	}
+
+	if record.Deleted {
+		if err := fs.saveRAiDToFile(current, filePath+".deleted"); err != nil {
+			return err
+		}
+		fs.recordWritten(filePath+".deleted", current, true)
+		return nil
+	}
+	return fs.saveRAiD(current, prefix, suffix)
+}
+
+// ImportServicePoint writes a service point keeping its ID
+func (fs *FileStorage) ImportServicePoint(ctx context.Context, sp *models.ServicePoint) error {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	unlock, err := fs.lockRecord(servicePointLockKey(sp.ID))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(fs.getServicePointFilePath(sp.ID)); err == nil {
+		return storage.ErrAlreadyExists
+	}
+	if err := fs.saveServicePoint(sp); err != nil {
+		return err
+	}
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+	if sp.ID > fs.idCounter {
+		fs.idCounter = sp.ID
+	}
+	return nil
+}
+
+// Counters returns the service point ID counter. RAiD suffixes are
+// timestamp based in this backend, so there are no per-prefix counters.
+func (fs *FileStorage) Counters(ctx context.Context) (map[string]int64, error) {
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+
+	return map[string]int64{
+		storage.CounterServicePoint: fs.idCounter,
+	}, nil
+}
+
+// SetCounters raises the service point ID counter
+func (fs *FileStorage) SetCounters(ctx context.Context, counters map[string]int64) error {
+	if err := fs.lock.check(); err != nil {
+		return err
+	}
+	fs.stateMu.Lock()
+	defer fs.stateMu.Unlock()
+
+	if v, ok := counters[storage.CounterServicePoint]; ok && v > fs.idCounter {
+		fs.idCounter = v
+	}
+	return nil
+}
+
+// ImportRAiD restores a RAiD and commits it to git
+func (gs *GitStorage) ImportRAiD(ctx context.Context, record *storage.RAiDRecord) error {
+	if err := gs.FileStorage.ImportRAiD(ctx, record); err != nil {
+		return err
+	}
+
+	if gs.gitEnabled && gs.autoCommit {
+		prefix, suffix, _ := identifier.Parse(record.Current().Identifier.ID)
+		commitMsg := fmt.Sprintf("Restore RAiD %s/%s", prefix, suffix)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// ImportServicePoint restores a service point and commits it to git
+func (gs *GitStorage) ImportServicePoint(ctx context.Context, sp *models.ServicePoint) error {
+	if err := gs.FileStorage.ImportServicePoint(ctx, sp); err != nil {
+		return err
+	}
+
+	if gs.gitEnabled && gs.autoCommit {
+		commitMsg := fmt.Sprintf("Restore service point %d (%s)", sp.ID, sp.Name)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return nil
+}
+
+// Verify the file backends support snapshots
+var (
+	_ storage.Snapshotter = (*FileStorage)(nil)
+	_ storage.Snapshotter = (*GitStorage)(nil)
+)
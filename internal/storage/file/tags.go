@@ -0,0 +1,131 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// The tags of each service point are kept in one file,
+// tags/<service point>.json, mapping prefix/suffix handles to sorted tags.
+
+func (fs *FileStorage) tagsPath(servicePoint int64) string {
+	return filepath.Join(fs.dataDir, "tags", strconv.FormatInt(servicePoint, 10)+".json")
+}
+
+func (fs *FileStorage) loadTags(servicePoint int64) (map[string][]string, error) {
+	data, err := os.ReadFile(fs.tagsPath(servicePoint))
+	if os.IsNotExist(err) {
+		return map[string][]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tags file: %w", err)
+	}
+	tags := map[string][]string{}
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tags: %w", err)
+	}
+	return tags, nil
+}
+
+// GetTags returns the tags a service point gave a RAiD
+func (fs *FileStorage) GetTags(ctx context.Context, servicePoint int64, prefix, suffix string) ([]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	tags, err := fs.loadTags(servicePoint)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, tags[prefix+"/"+suffix]...), nil
+}
+
+// AddTags adds tags a service point gives a RAiD
+func (fs *FileStorage) AddTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error) {
+	return fs.updateTags(servicePoint, prefix, suffix, func(current []string) []string {
+		for _, tag := range tags {
+			if !slices.Contains(current, tag) {
+				current = append(current, tag)
+			}
+		}
+		return current
+	})
+}
+
+// RemoveTags removes tags a service point gave a RAiD
+func (fs *FileStorage) RemoveTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error) {
+	return fs.updateTags(servicePoint, prefix, suffix, func(current []string) []string {
+		return slices.DeleteFunc(current, func(tag string) bool { return slices.Contains(tags, tag) })
+	})
+}
+
+// updateTags replaces the tags of a RAiD with those update makes of them
+func (fs *FileStorage) updateTags(servicePoint int64, prefix, suffix string, update func([]string) []string) ([]string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	if err := fs.lock.check(); err != nil {
+		return nil, err
+	}
+	unlock, err := fs.lockRecord(fmt.Sprintf("tags/%d", servicePoint))
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	all, err := fs.loadTags(servicePoint)
+	if err != nil {
+		return nil, err
+	}
+	handle := prefix + "/" + suffix
+	tags := update(append([]string{}, all[handle]...))
+	slices.Sort(tags)
+	if len(tags) == 0 {
+		delete(all, handle)
+	} else {
+		all[handle] = tags
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fs.tagsPath(servicePoint)), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create tags directory: %w", err)
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tags: %w", err)
+	}
+	if err := writeFileAtomic(fs.tagsPath(servicePoint), data); err != nil {
+		return nil, fmt.Errorf("failed to write tags file: %w", err)
+	}
+	return tags, nil
+}
+
+// TaggedRAiDs returns the RAiDs a service point gave tag
+func (fs *FileStorage) TaggedRAiDs(ctx context.Context, servicePoint int64, tag string) ([]storage.IdentifierRef, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	all, err := fs.loadTags(servicePoint)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]storage.IdentifierRef, 0)
+	for handle, tags := range all {
+		if !slices.Contains(tags, tag) {
+			continue
+		}
+		// Prefixes hold no slash
+		prefix, suffix, _ := strings.Cut(handle, "/")
+		refs = append(refs, storage.IdentifierRef{Prefix: prefix, Suffix: suffix})
+	}
+	return refs, nil
+}
+
+// Verify FileStorage can keep tags
+var _ storage.TagStore = (*FileStorage)(nil)
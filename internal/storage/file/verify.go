@@ -0,0 +1,311 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// lostFoundDir receives files moved aside by Verify repairs
+const lostFoundDir = "lost+found"
+
+// Verify checks every RAiD, history and service point file. With repair
+// enabled, unparseable files and orphaned history are moved to lost+found
+// in the data directory; other problems are reported only.
+func (fs *FileStorage) Verify(ctx context.Context, opts storage.VerifyOptions) (*storage.VerifyReport, error) {
+	if opts.Repair {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+		if err := fs.lock.check(); err != nil {
+			return nil, err
+		}
+	} else {
+		fs.mu.RLock()
+		defer fs.mu.RUnlock()
+	}
+
+	report := storage.NewVerifyReport()
+
+	prefixes, err := os.ReadDir(fs.raidDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, prefixDir := range prefixes {
+		if !prefixDir.IsDir() {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		dir := filepath.Join(fs.raidDir, prefixDir.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+
+		known := make(map[string]bool)
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".json.deleted")) {
+				continue
+			}
+			suffix := strings.TrimSuffix(strings.TrimSuffix(name, ".deleted"), ".json")
+			known[suffix] = true
+			fs.verifyRAiD(report, opts, prefixDir.Name(), suffix, filepath.Join(dir, name))
+		}
+
+		fs.verifyOrphanedHistory(report, opts, prefixDir.Name(), filepath.Join(dir, ".history"), known)
+	}
+
+	if err := fs.verifyServicePoints(report, opts); err != nil {
+		return nil, err
+	}
+
+	return report.Finish(), nil
+}
+
+// verifyRAiD checks a current (or deleted) RAiD file and its history
+func (fs *FileStorage) verifyRAiD(report *storage.VerifyReport, opts storage.VerifyOptions, prefixDir, suffix, path string) {
+	raidName := prefixDir + "/" + suffix
+
+	current, err := fs.loadRAiDFromFile(path)
+	if err != nil {
+		report.Add(fs.unparseable(opts, path, raidName, err))
+		return
+	}
+	if current.Identifier == nil {
+		report.Add(storage.VerifyIssue{
+			Kind:     storage.IssueIdentifierMismatch,
+			Location: fs.relative(path),
+			RAiD:     raidName,
+			Message:  "document has no identifier",
+		})
+		return
+	}
+
+	report.RAiDs++
+	prefix, payloadSuffix, err := identifier.Parse(current.Identifier.ID)
+	if err != nil || sanitizePath(prefix) != prefixDir || sanitizePath(payloadSuffix) != suffix {
+		report.Add(storage.VerifyIssue{
+			Kind:     storage.IssueIdentifierMismatch,
+			Location: fs.relative(path),
+			RAiD:     raidName,
+			Message:  fmt.Sprintf("payload identifier %q does not match path", current.Identifier.ID),
+		})
+		// History is looked up by path, so version checks would be misleading
+		return
+	}
+
+	versions := []int{current.Identifier.Version}
+	archived := false
+
+	historyDir := filepath.Join(fs.raidDir, prefixDir, ".history", suffix)
+	entries, err := os.ReadDir(historyDir)
+	if err != nil && !os.IsNotExist(err) {
+		report.Add(storage.VerifyIssue{
+			Kind:     storage.IssueUnparseable,
+			Location: fs.relative(historyDir),
+			RAiD:     raidName,
+			Message:  err.Error(),
+		})
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		historyPath := filepath.Join(historyDir, name)
+
+		version, convErr := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), ".json"))
+		if data, err := os.ReadFile(historyPath); err == nil && storage.IsArchived(data) {
+			archivedVersion, _ := storage.ArchivedVersionNumber(data)
+			if convErr != nil || archivedVersion != version {
+				report.Add(storage.VerifyIssue{
+					Kind:     storage.IssueIdentifierMismatch,
+					Location: fs.relative(historyPath),
+					RAiD:     raidName,
+					Message:  "history file name does not match archived version",
+				})
+			}
+			if convErr == nil {
+				versions = append(versions, version)
+			}
+			archived = true
+			continue
+		}
+		raid, err := fs.loadRAiDFromFile(historyPath)
+		if err != nil {
+			report.Add(fs.unparseable(opts, historyPath, raidName, err))
+			continue
+		}
+		if convErr != nil || raid.Identifier == nil || raid.Identifier.Version != version || raid.Identifier.ID != current.Identifier.ID {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: fs.relative(historyPath),
+				RAiD:     raidName,
+				Message:  "history file name does not match payload identifier or version",
+			})
+		}
+		if convErr == nil {
+			versions = append(versions, version)
+		}
+	}
+
+	sort.Ints(versions)
+	report.Versions += len(versions)
+	if issue := storage.CheckVersionSequence(fs.relative(path), raidName, versions); issue != nil {
+		report.Add(*issue)
+	}
+
+	// Archived versions are diffs, so they are only sound if the chain of
+	// newer versions they are based on still rehydrates
+	if archived {
+		stored, err := fs.storedVersions(path, historyDir)
+		if err == nil {
+			_, err = storage.RehydrateVersions(stored)
+		}
+		if err != nil {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueUnparseable,
+				Location: fs.relative(historyDir),
+				RAiD:     raidName,
+				Message:  fmt.Sprintf("archived versions cannot be rehydrated: %v", err),
+			})
+		}
+	}
+}
+
+// verifyOrphanedHistory reports history directories without a RAiD file
+func (fs *FileStorage) verifyOrphanedHistory(report *storage.VerifyReport, opts storage.VerifyOptions, prefixDir, historyRoot string, known map[string]bool) {
+	entries, err := os.ReadDir(historyRoot)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || known[entry.Name()] {
+			continue
+		}
+		path := filepath.Join(historyRoot, entry.Name())
+
+		files, _ := os.ReadDir(path)
+		if len(files) == 0 {
+			// Empty directories are left behind by lookups of unknown RAiDs
+			if opts.Repair {
+				os.Remove(path)
+			}
+			continue
+		}
+
+		issue := storage.VerifyIssue{
+			Kind:     storage.IssueOrphanedHistory,
+			Location: fs.relative(path),
+			RAiD:     prefixDir + "/" + entry.Name(),
+			Message:  fmt.Sprintf("%d history entries without a current or deleted RAiD", len(files)),
+		}
+		if opts.Repair {
+			issue.Repaired = fs.quarantine(path) == nil
+		}
+		report.Add(issue)
+	}
+}
+
+// verifyServicePoints checks service point files
+func (fs *FileStorage) verifyServicePoints(report *storage.VerifyReport, opts storage.VerifyOptions) error {
+	entries, err := os.ReadDir(fs.servicePointDir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		path := filepath.Join(fs.servicePointDir, name)
+
+		sp, err := fs.loadServicePointFromFile(path)
+		if err != nil {
+			report.Add(fs.unparseable(opts, path, "", err))
+			continue
+		}
+		report.ServicePoints++
+
+		if fmt.Sprintf("%d.json", sp.ID) != name {
+			report.Add(storage.VerifyIssue{
+				Kind:     storage.IssueIdentifierMismatch,
+				Location: fs.relative(path),
+				Message:  fmt.Sprintf("service point ID %d does not match file name", sp.ID),
+			})
+		}
+	}
+
+	return nil
+}
+
+// unparseable builds an issue for a corrupt file, quarantining it on repair
+func (fs *FileStorage) unparseable(opts storage.VerifyOptions, path, raidName string, err error) storage.VerifyIssue {
+	issue := storage.VerifyIssue{
+		Kind:     storage.IssueUnparseable,
+		Location: fs.relative(path),
+		RAiD:     raidName,
+		Message:  err.Error(),
+	}
+	if opts.Repair {
+		issue.Repaired = fs.quarantine(path) == nil
+	}
+	return issue
+}
+
+// quarantine moves a file or directory into lost+found, keeping its
+// relative path and adding a timestamp
+func (fs *FileStorage) quarantine(path string) error {
+	dest := filepath.Join(fs.dataDir, lostFoundDir, fs.relative(path)+"."+time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	return os.Rename(path, dest)
+}
+
+// relative returns path relative to the data directory
+func (fs *FileStorage) relative(path string) string {
+	rel, err := filepath.Rel(fs.dataDir, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// Verify checks the data directory and commits any repairs to git
+func (gs *GitStorage) Verify(ctx context.Context, opts storage.VerifyOptions) (*storage.VerifyReport, error) {
+	report, err := gs.FileStorage.Verify(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if report.Repaired > 0 && gs.gitEnabled && gs.autoCommit {
+		commitMsg := fmt.Sprintf("Verify: moved %d damaged entries to %s", report.Repaired, lostFoundDir)
+		if err := gs.gitCommit(commitMsg); err != nil {
+			fmt.Printf("Git commit failed: %v\n", err)
+		}
+	}
+
+	return report, nil
+}
+
+// Verify the file backends can check their data
+var (
+	_ storage.Verifier = (*FileStorage)(nil)
+	_ storage.Verifier = (*GitStorage)(nil)
+)
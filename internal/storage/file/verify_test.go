@@ -0,0 +1,88 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs, err := New(&Config{DataDir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, suffix := range []string{"good", "gap"} {
+		id := "https://raid.org/10.99999/" + suffix
+		if _, err := fs.CreateRAiD(ctx, &models.RAiD{Identifier: &models.Identifier{ID: id}}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			updated := &models.RAiD{Identifier: &models.Identifier{ID: id}, Title: []models.Title{{Text: fmt.Sprint("Update ", i)}}}
+			if _, err := fs.UpdateRAiD(ctx, "10.99999", suffix, updated); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	report, err := fs.Verify(ctx, storage.VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() || report.RAiDs != 2 {
+		t.Fatalf("expected a clean report for two RAiDs, got %+v", report)
+	}
+
+	raidDir := filepath.Join(dir, "raids", "10.99999")
+	os.Remove(filepath.Join(raidDir, ".history", "gap", "v2.json"))
+	os.WriteFile(filepath.Join(raidDir, "broken.json"), []byte("{"), 0644)
+	os.MkdirAll(filepath.Join(raidDir, ".history", "orphan"), 0755)
+	os.WriteFile(filepath.Join(raidDir, ".history", "orphan", "v1.json"), []byte("{}"), 0644)
+	data, _ := os.ReadFile(filepath.Join(raidDir, "good.json"))
+	os.WriteFile(filepath.Join(raidDir, "moved.json"), data, 0644)
+
+	report, err = fs.Verify(ctx, storage.VerifyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kinds := make(map[string]string)
+	for _, issue := range report.Issues {
+		kinds[issue.RAiD] = issue.Kind
+	}
+	expected := map[string]string{
+		"10.99999/gap":    storage.IssueVersionGap,
+		"10.99999/broken": storage.IssueUnparseable,
+		"10.99999/orphan": storage.IssueOrphanedHistory,
+		"10.99999/moved":  storage.IssueIdentifierMismatch,
+	}
+	for raid, kind := range expected {
+		if kinds[raid] != kind {
+			t.Errorf("expected %s issue for %s, got %q", kind, raid, kinds[raid])
+		}
+	}
+	if len(report.Issues) != len(expected) {
+		t.Errorf("expected %d issues, got %+v", len(expected), report.Issues)
+	}
+
+	report, err = fs.Verify(ctx, storage.VerifyOptions{Repair: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 2 {
+		t.Errorf("expected the unparseable file and orphaned history to be repaired, got %+v", report.Issues)
+	}
+	if _, err := os.Stat(filepath.Join(raidDir, "broken.json")); !os.IsNotExist(err) {
+		t.Error("expected unparseable file to be moved to lost+found")
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, lostFoundDir, "raids", "10.99999", ".history")); len(entries) != 1 {
+		t.Errorf("expected orphaned history in lost+found, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,21 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// FollowerStore is implemented by backends that can hold the ActivityPub
+// actors following registry activity
+type FollowerStore interface {
+	// SaveFollower stores follower, replacing the follower with the same
+	// ID
+	SaveFollower(ctx context.Context, follower *models.Follower) error
+
+	// ListFollowers retrieves all followers, ordered by ID
+	ListFollowers(ctx context.Context) ([]*models.Follower, error)
+
+	// DeleteFollower removes a follower
+	DeleteFollower(ctx context.Context, id string) error
+}
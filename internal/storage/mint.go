@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// MintAttempts bounds the identifiers MintGenerated tries before giving up
+const MintAttempts = 8
+
+// ErrIdentifiersExhausted is returned when every identifier MintGenerated
+// tried was taken
+var ErrIdentifiersExhausted = errors.New("no free identifier could be generated")
+
+// MintGenerated mints raid, which has no identifier yet, under the first
+// free identifier repo.GenerateIdentifier gives for its owner, written out
+// by format. Backends check that an identifier is free as they create the
+// RAiD, in the same transaction or under the same lock, so a taken one is
+// reported as ErrAlreadyExists and another is generated; after
+// MintAttempts taken identifiers ErrIdentifiersExhausted is returned.
+// Backends and repositories minting identifiers of their own call it from
+// CreateRAiD, so collisions are retried whichever generates the identifier.
+func MintGenerated(ctx context.Context, repo RAiDRepository, raid *models.RAiD, format func(prefix, suffix string) string) (*models.RAiD, error) {
+	if raid.Identifier == nil {
+		raid.Identifier = &models.Identifier{}
+	}
+	var owner int64
+	if raid.Identifier.Owner != nil {
+		owner = raid.Identifier.Owner.ServicePoint
+	}
+
+	for range MintAttempts {
+		prefix, suffix, err := repo.GenerateIdentifier(ctx, owner)
+		if err != nil {
+			return nil, err
+		}
+		raid.Identifier.ID = format(prefix, suffix)
+		created, err := repo.CreateRAiD(ctx, raid)
+		if errors.Is(err, ErrAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			raid.Identifier.ID = ""
+		}
+		return created, err
+	}
+	raid.Identifier.ID = ""
+	return nil, ErrIdentifiersExhausted
+}
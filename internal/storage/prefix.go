@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// DefaultPrefix is used for RAiDs minted without a service point, or for a
+// service point without a prefix
+const DefaultPrefix = "10.25.1.1"
+
+// Prefix allocation rules for service points with several prefixes
+const (
+	// PrefixAllocationFirst always uses the first prefix (the default)
+	PrefixAllocationFirst = "first"
+	// PrefixAllocationRoundRobin cycles through all prefixes
+	PrefixAllocationRoundRobin = "round-robin"
+	// PrefixAllocationProjectType uses the first pool reserved for the
+	// project type given at mint time, falling back to the first pool
+	// without project types
+	PrefixAllocationProjectType = "project-type"
+)
+
+// maxPrefixRange bounds the number of prefixes in one pool
+const maxPrefixRange = 1000
+
+type projectTypeKey struct{}
+
+// WithProjectType returns a context carrying the project type of a RAiD
+// being minted
+func WithProjectType(ctx context.Context, projectType string) context.Context {
+	return context.WithValue(ctx, projectTypeKey{}, projectType)
+}
+
+// ProjectType returns the project type carried by ctx, if any
+func ProjectType(ctx context.Context) string {
+	projectType, _ := ctx.Value(projectTypeKey{}).(string)
+	return projectType
+}
+
+// PoolPrefixes returns the prefixes in a pool, expanding a range
+func PoolPrefixes(pool models.PrefixPool) ([]string, error) {
+	if pool.Prefix == "" {
+		return nil, fmt.Errorf("prefix is required")
+	}
+	if pool.Last == "" || pool.Last == pool.Prefix {
+		return []string{pool.Prefix}, nil
+	}
+
+	stem, first, ok := splitPrefix(pool.Prefix)
+	lastStem, last, lastOK := splitPrefix(pool.Last)
+	if !ok || !lastOK || stem != lastStem {
+		return nil, fmt.Errorf("range %s to %s must differ only in the final numeric component", pool.Prefix, pool.Last)
+	}
+	if last < first {
+		return nil, fmt.Errorf("range %s to %s is empty", pool.Prefix, pool.Last)
+	}
+	if last-first >= maxPrefixRange {
+		return nil, fmt.Errorf("range %s to %s has more than %d prefixes", pool.Prefix, pool.Last, maxPrefixRange)
+	}
+
+	prefixes := make([]string, 0, last-first+1)
+	for n := first; n <= last; n++ {
+		prefixes = append(prefixes, stem+strconv.Itoa(n))
+	}
+	return prefixes, nil
+}
+
+// splitPrefix splits a prefix into everything up to its last dot and the
+// numeric component after it
+func splitPrefix(prefix string) (string, int, bool) {
+	i := strings.LastIndexByte(prefix, '.')
+	if i < 0 {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(prefix[i+1:])
+	if err != nil || n < 0 || strconv.Itoa(n) != prefix[i+1:] {
+		return "", 0, false
+	}
+	return prefix[:i+1], n, true
+}
+
+// ValidatePrefixes checks the prefix pools and allocation rule of a service
+// point
+func ValidatePrefixes(sp *models.ServicePoint) error {
+	switch sp.PrefixAllocation {
+	case "", PrefixAllocationFirst, PrefixAllocationRoundRobin, PrefixAllocationProjectType:
+	default:
+		return fmt.Errorf("unknown prefix allocation %q (want %s, %s or %s)",
+			sp.PrefixAllocation, PrefixAllocationFirst, PrefixAllocationRoundRobin, PrefixAllocationProjectType)
+	}
+	for i, pool := range sp.Prefixes {
+		if _, err := PoolPrefixes(pool); err != nil {
+			return fmt.Errorf("prefixes[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// PrefixAllocator picks the prefix for each new RAiD. Round-robin positions
+// are kept in memory, so each server instance cycles independently. The
+// zero value is ready to use.
+type PrefixAllocator struct {
+	mu   sync.Mutex
+	next map[int64]int
+}
+
+// Allocate returns the prefix for a RAiD minted by sp, which may be nil
+func (a *PrefixAllocator) Allocate(ctx context.Context, sp *models.ServicePoint) string {
+	if sp == nil {
+		return DefaultPrefix
+	}
+	if len(sp.Prefixes) == 0 {
+		if sp.Prefix != "" {
+			return sp.Prefix
+		}
+		return DefaultPrefix
+	}
+
+	pools := sp.Prefixes
+	if sp.PrefixAllocation == PrefixAllocationProjectType {
+		pools = poolsForProjectType(pools, ProjectType(ctx))
+	}
+
+	var prefixes []string
+	for _, pool := range pools {
+		// Pools are validated when the service point is saved
+		expanded, _ := PoolPrefixes(pool)
+		prefixes = append(prefixes, expanded...)
+	}
+	if len(prefixes) == 0 {
+		if sp.Prefix != "" {
+			return sp.Prefix
+		}
+		return DefaultPrefix
+	}
+
+	if sp.PrefixAllocation != PrefixAllocationRoundRobin {
+		return prefixes[0]
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.next == nil {
+		a.next = make(map[int64]int)
+	}
+	i := a.next[sp.ID] % len(prefixes)
+	a.next[sp.ID] = i + 1
+	return prefixes[i]
+}
+
+// poolsForProjectType returns the pools reserved for projectType, or the
+// pools without project types if none are
+func poolsForProjectType(pools []models.PrefixPool, projectType string) []models.PrefixPool {
+	var matching, general []models.PrefixPool
+	for _, pool := range pools {
+		if len(pool.ProjectTypes) == 0 {
+			general = append(general, pool)
+			continue
+		}
+		for _, t := range pool.ProjectTypes {
+			if projectType != "" && strings.EqualFold(t, projectType) {
+				matching = append(matching, pool)
+				break
+			}
+		}
+	}
+	if len(matching) > 0 {
+		return matching[:1]
+	}
+	return general[:min(len(general), 1)]
+}
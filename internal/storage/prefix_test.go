@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestPoolPrefixes(t *testing.T) {
+	got, err := PoolPrefixes(models.PrefixPool{Prefix: "10.82841.8", Last: "10.82841.10"})
+	if want := []string{"10.82841.8", "10.82841.9", "10.82841.10"}; err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("PoolPrefixes = %v, %v; want %v", got, err, want)
+	}
+
+	for _, pool := range []models.PrefixPool{
+		{},
+		{Prefix: "10.82841.3", Last: "10.82841.1"},
+		{Prefix: "10.82841.1", Last: "10.82842.2"},
+		{Prefix: "10.82841.01", Last: "10.82841.02"},
+		{Prefix: "10.1", Last: "10.5000"},
+	} {
+		if _, err := PoolPrefixes(pool); err == nil {
+			t.Errorf("PoolPrefixes(%+v): expected an error", pool)
+		}
+	}
+}
+
+func TestPrefixAllocator(t *testing.T) {
+	var a PrefixAllocator
+	ctx := context.Background()
+
+	if got := a.Allocate(ctx, nil); got != DefaultPrefix {
+		t.Errorf("no service point: got %s", got)
+	}
+	if got := a.Allocate(ctx, &models.ServicePoint{Prefix: "10.1"}); got != "10.1" {
+		t.Errorf("single prefix: got %s", got)
+	}
+
+	rr := &models.ServicePoint{ID: 1, Prefix: "10.1", PrefixAllocation: PrefixAllocationRoundRobin, Prefixes: []models.PrefixPool{
+		{Prefix: "10.2"},
+		{Prefix: "10.3.1", Last: "10.3.2"},
+	}}
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, a.Allocate(ctx, rr))
+	}
+	if want := []string{"10.2", "10.3.1", "10.3.2", "10.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("round-robin: got %v, want %v", got, want)
+	}
+
+	byType := &models.ServicePoint{ID: 2, PrefixAllocation: PrefixAllocationProjectType, Prefixes: []models.PrefixPool{
+		{Prefix: "10.4", ProjectTypes: []string{"clinical-trial"}},
+		{Prefix: "10.5"},
+	}}
+	if got := a.Allocate(WithProjectType(ctx, "Clinical-Trial"), byType); got != "10.4" {
+		t.Errorf("project type: got %s", got)
+	}
+	if got := a.Allocate(WithProjectType(ctx, "survey"), byType); got != "10.5" {
+		t.Errorf("other project type: got %s", got)
+	}
+	if got := a.Allocate(ctx, byType); got != "10.5" {
+		t.Errorf("no project type: got %s", got)
+	}
+}
+
+func TestValidatePrefixes(t *testing.T) {
+	if err := ValidatePrefixes(&models.ServicePoint{PrefixAllocation: "random"}); err == nil {
+		t.Error("expected an error for an unknown allocation rule")
+	}
+	if err := ValidatePrefixes(&models.ServicePoint{Prefixes: []models.PrefixPool{{Prefix: "10.1.2", Last: "10.1.1"}}}); err == nil {
+		t.Error("expected an error for an empty range")
+	}
+	if err := ValidatePrefixes(&models.ServicePoint{Prefix: "10.1"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
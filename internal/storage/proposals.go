@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ProposalStore is implemented by backends that can hold related objects
+// proposed for RAiDs by other systems. Proposals are kept apart from RAiDs,
+// so nothing of them is public until they are accepted.
+type ProposalStore interface {
+	// SaveProposal stores proposal, replacing the proposal with the same
+	// ID
+	SaveProposal(ctx context.Context, proposal *models.Proposal) error
+
+	// GetProposal retrieves a proposal by ID
+	GetProposal(ctx context.Context, id string) (*models.Proposal, error)
+
+	// ListProposals retrieves the proposals for the RAiDs of a service
+	// point, ordered by ID
+	ListProposals(ctx context.Context, servicePoint int64) ([]*models.Proposal, error)
+
+	// DeleteProposal removes a proposal
+	DeleteProposal(ctx context.Context, id string) error
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ErrNotDeleted is returned when purging a RAiD that is not soft deleted
+var ErrNotDeleted = errors.New("RAiD is not deleted")
+
+// Purger is implemented by backends that can hard remove soft deleted
+// RAiDs. Soft deletion keeps every version; a purge removes them, with the
+// changes, tags and attachments of the RAiD, and records the purge in the
+// same write. Attachment content, shared by content hash, is kept.
+type Purger interface {
+	// PurgeRAiD removes the soft deleted RAiD purge names and records
+	// purge, with Deleted set to when the RAiD was deleted if known. It
+	// returns ErrNotFound for an unknown RAiD and ErrNotDeleted for one
+	// that is not deleted.
+	PurgeRAiD(ctx context.Context, purge *models.Purge) error
+
+	// ListPurges returns the recorded purges, oldest first
+	ListPurges(ctx context.Context) ([]*models.Purge, error)
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Redactor is implemented by backends that can rewrite every stored version
+// of a RAiD in place, to remove personal data while keeping the history of
+// what was recorded, when and by whom.
+type Redactor interface {
+	// RedactRAiD applies redact, which reports whether it changed the
+	// version, to every version of a current or soft deleted RAiD. Changed
+	// versions are stored in full and the changes of the RAiD recorded
+	// again. When any version changed, redaction is recorded in the same
+	// write with Versions set; redact has then run on every version and
+	// may have filled it in. It returns the number of versions changed.
+	RedactRAiD(ctx context.Context, prefix, suffix string, redact func(*models.RAiD) bool, redaction *models.Redaction) (int, error)
+
+	// ListRedactions returns the recorded redactions, oldest first
+	ListRedactions(ctx context.Context) ([]*models.Redaction, error)
+}
+
+// RedactVersions applies redact to the stored versions of a RAiD, in any
+// order, and returns the full documents of the versions to store again:
+// those redact changed, and archived versions, whose diffs may hold what
+// was removed. changes are those stored for the RAiD; the changes of every
+// version are returned recorded again from the redacted history, chained
+// from the first version and keeping their actors, with the number of
+// versions redact changed. It returns no versions if redact changed none.
+func RedactVersions(stored []StoredVersion, changes []VersionChange, redact func(*models.RAiD) bool) ([]StoredVersion, []VersionChange, int, error) {
+	full, err := RehydrateVersions(stored)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	sorted := sortVersions(stored)
+
+	var rewritten []StoredVersion
+	history := make([]*models.RAiD, 0, len(full))
+	redacted := 0
+	for i, v := range full {
+		var raid models.RAiD
+		if err := json.Unmarshal(v.Data, &raid); err != nil {
+			return nil, nil, 0, fmt.Errorf("version %d: %w", v.Version, err)
+		}
+		history = append(history, &raid)
+
+		changed := redact(&raid)
+		if !changed && !IsArchived(sorted[i].Data) {
+			continue
+		}
+		if changed {
+			redacted++
+		}
+		data, err := json.Marshal(&raid)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		rewritten = append(rewritten, StoredVersion{Version: v.Version, Data: data})
+	}
+	if redacted == 0 {
+		return nil, nil, 0, nil
+	}
+
+	recorded, err := RecordHistory(history)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	actors := ChangeActors(changes)
+	for i := range recorded {
+		recorded[i].Actor = actors[recorded[i].Version]
+	}
+	return rewritten, recorded, redacted, nil
+}
@@ -2,8 +2,11 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"time"
 
+	"github.com/leifj/go-raid/internal/expr"
 	"github.com/leifj/go-raid/internal/models"
 )
 
@@ -26,6 +29,11 @@ type RAiDRepository interface {
 	// GetRAiD retrieves a RAiD by its prefix and suffix
 	GetRAiD(ctx context.Context, prefix, suffix string) (*models.RAiD, error)
 
+	// GetRAiDs retrieves several RAiDs at once. The result has an entry for
+	// each of refs, in the same order, which is nil where GetRAiD would
+	// return ErrNotFound.
+	GetRAiDs(ctx context.Context, refs []IdentifierRef) ([]*models.RAiD, error)
+
 	// GetRAiDVersion retrieves a specific version of a RAiD
 	GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error)
 
@@ -41,6 +49,10 @@ type RAiDRepository interface {
 	// GetRAiDHistory retrieves the version history of a RAiD
 	GetRAiDHistory(ctx context.Context, prefix, suffix string) ([]*models.RAiD, error)
 
+	// GetRAiDChanges retrieves the change each version of a RAiD made,
+	// oldest first, in pages if page is not nil
+	GetRAiDChanges(ctx context.Context, prefix, suffix string, page *HistoryPage) ([]VersionChange, error)
+
 	// DeleteRAiD removes a RAiD (soft delete, keeps history)
 	DeleteRAiD(ctx context.Context, prefix, suffix string) error
 
@@ -78,16 +90,156 @@ type Repository interface {
 	HealthCheck(ctx context.Context) error
 }
 
+// StatsProvider is implemented by backends that expose diagnostic statistics
+// (connection pool counts, latencies, file counts) for troubleshooting
+type StatsProvider interface {
+	// Stats returns backend-specific statistics keyed by name
+	Stats(ctx context.Context) (map[string]interface{}, error)
+}
+
+// EncodingConverter is implemented by backends that can store records in
+// an encoding other than JSON
+type EncodingConverter interface {
+	// ConvertEncoding rewrites stored records not in the configured
+	// encoding and returns how many it rewrote
+	ConvertEncoding(ctx context.Context) (int, error)
+}
+
+// LockHolder is implemented by backends that hold a lock keeping other
+// processes from opening them, so that a binary upgraded in place can open
+// storage before the process it replaces stops
+type LockHolder interface {
+	// Release gives up the lock; writes fail with ErrBackendUnavailable
+	// until Retake
+	Release() error
+
+	// Retake takes the lock back after Release, unless another process
+	// holds it
+	Retake() error
+}
+
+// Snapshotter is implemented by backends that support full export and import
+// of their contents, used for backup/restore and moving data between backends
+type Snapshotter interface {
+	// ExportRAiDs calls fn for every stored RAiD, including deleted ones,
+	// with all of its versions. Iteration stops at the first error from fn.
+	ExportRAiDs(ctx context.Context, fn func(*RAiDRecord) error) error
+
+	// ImportRAiD stores a RAiD with its versions and timestamps exactly as
+	// given. It returns ErrAlreadyExists if the RAiD is already stored.
+	ImportRAiD(ctx context.Context, record *RAiDRecord) error
+
+	// ImportServicePoint stores a service point keeping its ID
+	ImportServicePoint(ctx context.Context, sp *models.ServicePoint) error
+
+	// Counters returns the identifier counters, keyed by CounterServicePoint
+	// or CounterRAiDPrefix + prefix
+	Counters(ctx context.Context) (map[string]int64, error)
+
+	// SetCounters raises identifier counters to at least the given values
+	SetCounters(ctx context.Context, counters map[string]int64) error
+}
+
+// Counter names used by Snapshotter
+const (
+	// CounterServicePoint is the last allocated service point ID
+	CounterServicePoint = "servicePoint"
+	// CounterRAiDPrefix prefixes the per-prefix RAiD suffix counters
+	CounterRAiDPrefix = "raid/"
+)
+
+// RAiDRecord is a RAiD with its complete version history
+type RAiDRecord struct {
+	// Versions holds every stored version in ascending order; the last
+	// entry is the current version
+	Versions []*models.RAiD `json:"versions"`
+	// Deleted is true if the RAiD has been soft deleted
+	Deleted bool `json:"deleted,omitempty"`
+	// Actors maps versions to the authenticated users who wrote them, for
+	// the versions whose writer is known
+	Actors map[int]string `json:"actors,omitempty"`
+}
+
+// Current returns the latest version of the record, or nil if it is empty
+func (r *RAiDRecord) Current() *models.RAiD {
+	if len(r.Versions) == 0 {
+		return nil
+	}
+	return r.Versions[len(r.Versions)-1]
+}
+
+// MarkDeleted marks raid, listed with RAiDFilter.IncludeDeleted, as soft
+// deleted at the given time, which is zero if the backend did not record it
+func MarkDeleted(raid *models.RAiD, at time.Time) {
+	if raid.Metadata == nil {
+		raid.Metadata = &models.Metadata{}
+	}
+	raid.Metadata.Deleted = true
+	raid.Metadata.DeletedAt = at
+}
+
+// OwnedBy reports whether raid is owned by the service point with id
+func OwnedBy(raid *models.RAiD, id int64) bool {
+	return raid.Identifier != nil && raid.Identifier.Owner != nil && raid.Identifier.Owner.ServicePoint == id
+}
+
+// MatchesExpression reports whether e holds for raid. A RAiD the expression
+// cannot be evaluated against, for instance because a field it orders is
+// not a string or number, does not match.
+func MatchesExpression(raid *models.RAiD, e *expr.Expr) bool {
+	data, err := json.Marshal(raid)
+	if err != nil {
+		return false
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	ok, err := e.EvalBool(doc)
+	return err == nil && ok
+}
+
 // RAiDFilter contains filtering options for RAiD queries
 type RAiDFilter struct {
 	// ContributorID filters by contributor ORCID
 	ContributorID string
 	// OrganisationID filters by organisation ROR ID
 	OrganisationID string
+	// TraditionalKnowledgeLabelID filters by Local Contexts TK or BC label
+	TraditionalKnowledgeLabelID string
+	// HasTraditionalKnowledge filters by the presence (true) or absence
+	// (false) of TK and BC labels
+	HasTraditionalKnowledge *bool
+	// SubjectID filters by subject; narrower subjects, whose IDs extend
+	// SubjectID, match too
+	SubjectID string
+	// SubjectKeyword filters by subject keyword, ignoring case and
+	// diacritics
+	SubjectKeyword string
+	// Text keeps RAiDs with a title or subject keyword containing it,
+	// ignoring case and diacritics as textnorm.Fold does
+	Text string
+	// Expression keeps the RAiDs it holds for, see MatchesExpression.
+	// Backends push it down where they can translate it.
+	Expression *expr.Expr
+	// RelatedObjectDOI filters by related object, given as a DOI URL in the
+	// form returned by identifier.NormalizeDOI
+	RelatedObjectDOI string
+	// ServicePointID filters by owning service point
+	ServicePointID int64
+	// MintedSince keeps RAiDs minted at or after it, when not zero
+	MintedSince time.Time
+	// IncludeDeleted lists soft deleted RAiDs too, marked by MarkDeleted
+	IncludeDeleted bool
+	// DeletedOnly lists only soft deleted RAiDs; it implies IncludeDeleted
+	DeletedOnly bool
 	// IncludeFields specifies which fields to return (nil = all fields)
 	IncludeFields []string
 	// Limit specifies maximum number of results
 	Limit int
 	// Offset specifies number of results to skip
 	Offset int
+	// Cursor continues a listing after the RAiD it was made from by
+	// identifier.EncodeCursor; Offset then skips results after it
+	Cursor string
 }
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ReservationStore is implemented by backends that can hold identifiers
+// reserved ahead of their mint. A reservation is live until its ExpiresAt;
+// expired reservations are treated as absent and replaced.
+type ReservationStore interface {
+	// SaveReservation stores reservation, failing with ErrAlreadyExists
+	// when its handle is minted or another live reservation holds it
+	SaveReservation(ctx context.Context, reservation *models.Reservation) error
+
+	// GetReservation retrieves the live reservation of a handle
+	GetReservation(ctx context.Context, prefix, suffix string) (*models.Reservation, error)
+
+	// DeleteReservation releases the reservation of a handle
+	DeleteReservation(ctx context.Context, prefix, suffix string) error
+
+	// ReleaseExpiredReservations removes the reservations expired by at,
+	// returning how many it removed
+	ReleaseExpiredReservations(ctx context.Context, at time.Time) (int, error)
+}
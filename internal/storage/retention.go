@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Cutoffs are the times before which data is removed by retention rules,
+// per service point
+type Cutoffs struct {
+	// Default applies to the data of service points without a cutoff of
+	// their own and to data of no service point; zero keeps that data
+	Default time.Time
+	// ServicePoints holds the cutoffs of single service points
+	ServicePoints map[int64]time.Time
+}
+
+// For returns the cutoff for the data of servicePoint, zero to keep it
+func (c Cutoffs) For(servicePoint int64) time.Time {
+	if cutoff, ok := c.ServicePoints[servicePoint]; ok {
+		return cutoff
+	}
+	return c.Default
+}
+
+// AccessLogPruner is implemented by backends that can remove stored access
+// log entries
+type AccessLogPruner interface {
+	// PruneAccessLog removes the entries logged before the cutoff of their
+	// service point and returns how many it removed, or with dryRun how
+	// many it would remove
+	PruneAccessLog(ctx context.Context, cutoffs Cutoffs, dryRun bool) (int, error)
+}
+
+// ReservationPruner is implemented by backends that can remove
+// reservations by age, whether or not they have expired
+type ReservationPruner interface {
+	// PruneReservations removes the reservations made before the cutoff
+	// of their service point and returns how many it removed, or with
+	// dryRun how many it would remove
+	PruneReservations(ctx context.Context, cutoffs Cutoffs, dryRun bool) (int, error)
+}
+
+// AuditPruner is implemented by backends that can remove old records of
+// purges and redactions
+type AuditPruner interface {
+	// PruneAudit removes the purges and redactions recorded before before
+	// and returns how many it removed, or with dryRun how many it would
+	// remove
+	PruneAudit(ctx context.Context, before time.Time, dryRun bool) (int, error)
+}
@@ -0,0 +1,31 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ScheduleStore is implemented by backends that can hold changes scheduled
+// to take effect later. Held changes are kept apart from RAiDs, so nothing
+// of them is public until they are applied.
+type ScheduleStore interface {
+	// SaveScheduledChange stores change, replacing the change with the
+	// same ID
+	SaveScheduledChange(ctx context.Context, change *models.ScheduledChange) error
+
+	// GetScheduledChange retrieves a change by ID
+	GetScheduledChange(ctx context.Context, id string) (*models.ScheduledChange, error)
+
+	// ListScheduledChanges retrieves the changes of a service point,
+	// ordered by ID
+	ListScheduledChanges(ctx context.Context, servicePoint int64) ([]*models.ScheduledChange, error)
+
+	// DueChanges retrieves the pending changes effective at or before at,
+	// ordered by when they take effect
+	DueChanges(ctx context.Context, at time.Time) ([]*models.ScheduledChange, error)
+
+	// DeleteScheduledChange removes a change
+	DeleteScheduledChange(ctx context.Context, id string) error
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Prefixes of models.SavedSearch.Owner, followed by the user or service
+// point ID
+const (
+	SearchOwnerUser         = "user:"
+	SearchOwnerServicePoint = "service-point:"
+)
+
+// SearchStore is implemented by backends that can keep saved searches
+type SearchStore interface {
+	// SaveSearch stores search, replacing the search of the same owner
+	// and name
+	SaveSearch(ctx context.Context, search *models.SavedSearch) error
+
+	// GetSearch retrieves a search by owner and name
+	GetSearch(ctx context.Context, owner, name string) (*models.SavedSearch, error)
+
+	// GetSearchByFeed retrieves the search published under feed
+	GetSearchByFeed(ctx context.Context, feed string) (*models.SavedSearch, error)
+
+	// ListSearches retrieves the searches of owner, ordered by name
+	ListSearches(ctx context.Context, owner string) ([]*models.SavedSearch, error)
+
+	// DeleteSearch removes a search
+	DeleteSearch(ctx context.Context, owner, name string) error
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ServicePointCacheTTL bounds how long a cached service point is used.
+// Updates through the same backend invalidate it at once; the TTL bounds
+// how long other server instances sharing the database see the old one.
+const ServicePointCacheTTL = 30 * time.Second
+
+// maxCachedServicePoints bounds the cache; it is emptied when full
+const maxCachedServicePoints = 1024
+
+// ServicePointCache caches the service points GenerateIdentifier loads to
+// pick a prefix, saving a storage round trip per mint. Lookups that fail
+// are not cached, so a new service point can be used at once. The zero
+// value is ready to use.
+type ServicePointCache struct {
+	mu      sync.Mutex
+	entries map[int64]cachedServicePoint
+	now     func() time.Time
+	// gen counts invalidations, so that a service point loaded while it
+	// was being changed is not cached
+	gen uint64
+}
+
+type cachedServicePoint struct {
+	sp      *models.ServicePoint
+	expires time.Time
+}
+
+// Get returns service point id, calling load when it is not cached or has
+// expired. The returned service point is shared and must not be modified.
+func (c *ServicePointCache) Get(ctx context.Context, id int64, load func(context.Context, int64) (*models.ServicePoint, error)) (*models.ServicePoint, error) {
+	c.mu.Lock()
+	now := c.clock()
+	if e, ok := c.entries[id]; ok && now.Before(e.expires) {
+		c.mu.Unlock()
+		return e.sp, nil
+	}
+	gen := c.gen
+	c.mu.Unlock()
+
+	sp, err := load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gen != gen {
+		return sp, nil
+	}
+	if c.entries == nil || len(c.entries) >= maxCachedServicePoints {
+		c.entries = make(map[int64]cachedServicePoint)
+	}
+	c.entries[id] = cachedServicePoint{sp: sp, expires: now.Add(ServicePointCacheTTL)}
+	return sp, nil
+}
+
+// Invalidate drops service point id from the cache after it was changed
+func (c *ServicePointCache) Invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	delete(c.entries, id)
+}
+
+func (c *ServicePointCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestServicePointCache(t *testing.T) {
+	now := time.Unix(0, 0)
+	cache := ServicePointCache{now: func() time.Time { return now }}
+	loads := 0
+	load := func(_ context.Context, id int64) (*models.ServicePoint, error) {
+		loads++
+		if id != 1 {
+			return nil, ErrNotFound
+		}
+		return &models.ServicePoint{ID: id, Prefix: "10.12345"}, nil
+	}
+	ctx := context.Background()
+
+	for range 3 {
+		sp, err := cache.Get(ctx, 1, load)
+		if err != nil || sp.Prefix != "10.12345" {
+			t.Fatalf("unexpected lookup result %v, %v", sp, err)
+		}
+	}
+	if loads != 1 {
+		t.Errorf("expected one load for repeated lookups, got %d", loads)
+	}
+
+	cache.Get(ctx, 2, load)
+	cache.Get(ctx, 2, load)
+	if loads != 3 {
+		t.Errorf("expected failed lookups not to be cached, got %d loads", loads)
+	}
+
+	cache.Invalidate(1)
+	cache.Get(ctx, 1, load)
+	if loads != 4 {
+		t.Errorf("expected a load after invalidation, got %d loads", loads)
+	}
+
+	now = now.Add(ServicePointCacheTTL)
+	cache.Get(ctx, 1, load)
+	if loads != 5 {
+		t.Errorf("expected a load after the TTL, got %d loads", loads)
+	}
+
+	// A service point changed while it was being loaded is not cached
+	cache.Invalidate(1)
+	cache.Get(ctx, 1, func(ctx context.Context, id int64) (*models.ServicePoint, error) {
+		cache.Invalidate(id)
+		return load(ctx, id)
+	})
+	cache.Get(ctx, 1, load)
+	if loads != 7 {
+		t.Errorf("expected a load after a concurrent invalidation, got %d loads", loads)
+	}
+}
@@ -0,0 +1,22 @@
+package storage
+
+import "context"
+
+// TagStore is implemented by backends that can keep tags on RAiDs. Tags
+// are kept apart from the RAiD document, so tagging makes no new version,
+// and are scoped to a service point: each sees only the tags it gave.
+type TagStore interface {
+	// GetTags returns the tags servicePoint gave a RAiD, sorted
+	GetTags(ctx context.Context, servicePoint int64, prefix, suffix string) ([]string, error)
+
+	// AddTags adds tags to those servicePoint gave a RAiD and returns
+	// them all, sorted
+	AddTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error)
+
+	// RemoveTags removes tags from those servicePoint gave a RAiD and
+	// returns the rest, sorted
+	RemoveTags(ctx context.Context, servicePoint int64, prefix, suffix string, tags []string) ([]string, error)
+
+	// TaggedRAiDs returns the RAiDs servicePoint gave tag
+	TaggedRAiDs(ctx context.Context, servicePoint int64, tag string) ([]IdentifierRef, error)
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/leifj/go-raid/internal/identifier"
 	"github.com/leifj/go-raid/internal/models"
 	"github.com/leifj/go-raid/internal/storage"
 )
@@ -27,6 +28,8 @@ type MockRepository struct {
 	ListRAiDsFunc          func(context.Context, *storage.RAiDFilter) ([]*models.RAiD, error)
 	ListPublicRAiDsFunc    func(context.Context, *storage.RAiDFilter) ([]*models.RAiD, error)
 	GetRAiDHistoryFunc     func(context.Context, string, string) ([]*models.RAiD, error)
+	GetRAiDChangesFunc     func(context.Context, string, string, *storage.HistoryPage) ([]storage.VersionChange, error)
+	GetRAiDsFunc           func(context.Context, []storage.IdentifierRef) ([]*models.RAiD, error)
 	DeleteRAiDFunc         func(context.Context, string, string) error
 	GenerateIdentifierFunc func(context.Context, int64) (string, string, error)
 
@@ -84,6 +87,14 @@ func (m *MockRepository) GetRAiD(ctx context.Context, prefix, suffix string) (*m
 	return NewTestRAiD(prefix, suffix), nil
 }
 
+// GetRAiDs defaults to GetRAiD for each ref
+func (m *MockRepository) GetRAiDs(ctx context.Context, refs []storage.IdentifierRef) ([]*models.RAiD, error) {
+	if m.GetRAiDsFunc != nil {
+		return m.GetRAiDsFunc(ctx, refs)
+	}
+	return storage.GetEach(ctx, m.GetRAiD, refs)
+}
+
 func (m *MockRepository) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*models.RAiD, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -136,6 +147,23 @@ func (m *MockRepository) GetRAiDHistory(ctx context.Context, prefix, suffix stri
 	return []*models.RAiD{}, nil
 }
 
+// GetRAiDChanges diffs the history by default, like backends do for RAiDs
+// written before changes were stored
+func (m *MockRepository) GetRAiDChanges(ctx context.Context, prefix, suffix string, page *storage.HistoryPage) ([]storage.VersionChange, error) {
+	if m.GetRAiDChangesFunc != nil {
+		return m.GetRAiDChangesFunc(ctx, prefix, suffix, page)
+	}
+	history, err := m.GetRAiDHistory(ctx, prefix, suffix)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := storage.DiffHistory(history)
+	if err != nil {
+		return nil, err
+	}
+	return storage.PageChanges(changes, page), nil
+}
+
 func (m *MockRepository) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
 	m.mu.Lock()
 	m.DeleteRAiDCalls++
@@ -232,7 +260,7 @@ var _ storage.Repository = (*MockRepository)(nil)
 // NewTestRAiD creates a test RAiD with the given prefix and suffix
 func NewTestRAiD(prefix, suffix string) *models.RAiD {
 	now := time.Now()
-	id := fmt.Sprintf("https://raid.org/%s/%s", prefix, suffix)
+	id := identifier.Format("", prefix, suffix)
 
 	return &models.RAiD{
 		Identifier: &models.Identifier{
@@ -0,0 +1,52 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+)
+
+var (
+	transientMu          sync.RWMutex
+	transientClassifiers []func(error) bool
+)
+
+// RegisterTransient registers a function recognising the transient errors
+// of a backend, such as serialization failures, that succeed when retried
+func RegisterTransient(fn func(error) bool) {
+	transientMu.Lock()
+	defer transientMu.Unlock()
+	transientClassifiers = append(transientClassifiers, fn)
+}
+
+// IsTransient reports whether err is a transient backend error that is
+// safe to retry
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	transientMu.RLock()
+	defer transientMu.RUnlock()
+	for _, fn := range transientClassifiers {
+		if fn(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnavailable reports whether err suggests the backend itself is failing
+// rather than rejecting the request: transient errors, network and broken
+// connection errors and deadlines expiring while waiting for the backend
+func IsUnavailable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var netErr net.Error
+	return IsTransient(err) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Verifier is implemented by backends that can check their stored data for
+// integrity problems
+type Verifier interface {
+	// Verify scans the backend and reports problems. With opts.Repair set,
+	// backends that support it fix what can be fixed safely and mark those
+	// issues as repaired.
+	Verify(ctx context.Context, opts VerifyOptions) (*VerifyReport, error)
+}
+
+// VerifyOptions controls a verification run
+type VerifyOptions struct {
+	// Repair attempts to fix problems (file backends only)
+	Repair bool
+}
+
+// Issue kinds reported by Verify
+const (
+	// IssueUnparseable is a stored document that is not valid JSON for its type
+	IssueUnparseable = "unparseable"
+	// IssueVersionGap is a RAiD whose versions are not contiguous from 1
+	IssueVersionGap = "version-gap"
+	// IssueIdentifierMismatch is a document whose payload identifier or
+	// version disagrees with the key or path it is stored under
+	IssueIdentifierMismatch = "identifier-mismatch"
+	// IssueOrphanedHistory is a history entry without a current or deleted RAiD
+	IssueOrphanedHistory = "orphaned-history"
+)
+
+// VerifyIssue is a single integrity problem
+type VerifyIssue struct {
+	Kind string `json:"kind"`
+	// Location is the backend-specific key, path or row of the problem
+	Location string `json:"location"`
+	// RAiD is the prefix/suffix the problem belongs to, if known
+	RAiD     string `json:"raid,omitempty"`
+	Message  string `json:"message"`
+	Repaired bool   `json:"repaired,omitempty"`
+}
+
+// VerifyReport is the machine-readable result of a verification run
+type VerifyReport struct {
+	StartedAt     time.Time     `json:"startedAt"`
+	Duration      string        `json:"duration"`
+	RAiDs         int           `json:"raids"`
+	Versions      int           `json:"versions"`
+	ServicePoints int           `json:"servicePoints"`
+	Issues        []VerifyIssue `json:"issues"`
+	Repaired      int           `json:"repaired"`
+	// Notes are findings explained by something recorded, such as a
+	// redaction, that need no action
+	Notes []VerifyIssue `json:"notes,omitempty"`
+}
+
+// NewVerifyReport starts a report
+func NewVerifyReport() *VerifyReport {
+	return &VerifyReport{
+		StartedAt: time.Now().UTC(),
+		Issues:    make([]VerifyIssue, 0),
+	}
+}
+
+// Add records an issue
+func (r *VerifyReport) Add(issue VerifyIssue) {
+	if issue.Repaired {
+		r.Repaired++
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// Note records a finding that is not an issue
+func (r *VerifyReport) Note(issue VerifyIssue) {
+	r.Notes = append(r.Notes, issue)
+}
+
+// Finish records the run duration
+func (r *VerifyReport) Finish() *VerifyReport {
+	r.Duration = time.Since(r.StartedAt).String()
+	return r
+}
+
+// OK reports whether no unrepaired issues were found
+func (r *VerifyReport) OK() bool {
+	return len(r.Issues) == r.Repaired
+}
+
+// CheckVersionSequence reports a gap if versions (sorted ascending) are not
+// exactly 1..n
+func CheckVersionSequence(location, raid string, versions []int) *VerifyIssue {
+	for i, v := range versions {
+		if v != i+1 {
+			return &VerifyIssue{
+				Kind:     IssueVersionGap,
+				Location: location,
+				RAiD:     raid,
+				Message:  fmt.Sprintf("expected version %d, found %d (versions %v)", i+1, v, versions),
+			}
+		}
+	}
+	return nil
+}
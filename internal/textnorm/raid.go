@@ -0,0 +1,85 @@
+package textnorm
+
+import (
+	"strings"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Kinds of Term
+const (
+	TermTitle   = "title"
+	TermKeyword = "keyword"
+)
+
+// Term is the folded text of a title or subject keyword of a RAiD, kept by
+// backends next to the RAiD to search by
+type Term struct {
+	Kind string
+	// Scope is the subject ID of a keyword, empty for titles
+	Scope string
+	Text  string
+}
+
+// Normalize puts the titles, descriptions, subject keywords, spatial
+// coverage places and access statement of raid in NFC, in place
+func Normalize(raid *models.RAiD) {
+	for i := range raid.Title {
+		raid.Title[i].Text = NFC(raid.Title[i].Text)
+	}
+	for i := range raid.Description {
+		raid.Description[i].Text = NFC(raid.Description[i].Text)
+	}
+	for i := range raid.Subject {
+		for j := range raid.Subject[i].Keyword {
+			raid.Subject[i].Keyword[j].Text = NFC(raid.Subject[i].Keyword[j].Text)
+		}
+	}
+	for i := range raid.SpatialCoverage {
+		for j := range raid.SpatialCoverage[i].Place {
+			raid.SpatialCoverage[i].Place[j].Text = NFC(raid.SpatialCoverage[i].Place[j].Text)
+		}
+	}
+	if raid.Access != nil && raid.Access.Statement != nil {
+		raid.Access.Statement.Text = NFC(raid.Access.Statement.Text)
+	}
+}
+
+// Terms returns the folded titles and subject keywords of raid, each once
+func Terms(raid *models.RAiD) []Term {
+	var terms []Term
+	seen := make(map[Term]bool)
+	add := func(t Term) {
+		if t.Text != "" && !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	for _, t := range raid.Title {
+		add(Term{Kind: TermTitle, Text: Fold(t.Text)})
+	}
+	for _, s := range raid.Subject {
+		for _, kw := range s.Keyword {
+			add(Term{Kind: TermKeyword, Scope: s.ID, Text: Fold(kw.Text)})
+		}
+	}
+	return terms
+}
+
+// ContainsText reports whether a title or keyword among terms contains
+// text, ignoring case and diacritics
+func ContainsText(terms []Term, text string) bool {
+	text = Fold(text)
+	for _, t := range terms {
+		if strings.Contains(t.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a and b are the same text but for case,
+// diacritics and white space
+func Matches(a, b string) bool {
+	return Fold(a) == Fold(b)
+}
@@ -0,0 +1,29 @@
+package textnorm
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that puts the free text of RAiDs in NFC with
+// Normalize before they are minted or updated in repo, so that text typed
+// with combining marks is stored, compared and indexed in one form
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	Normalize(raid)
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	Normalize(raid)
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
@@ -0,0 +1,57 @@
+// Package textnorm normalizes the free text of RAiDs to Unicode NFC and
+// folds it for searching, so that text typed with combining marks, and
+// names typed with or without their diacritics, match.
+package textnorm
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// NFC returns s in Unicode Normalization Form C: canonically decomposed,
+// with combining marks in canonical order, and composed again
+func NFC(s string) string {
+	return norm.NFC.String(s)
+}
+
+// letters folds the letters that do not decompose to a base letter and
+// marks, so that Nordic and other names match their ASCII spelling
+var letters = map[rune]string{
+	'ø': "o", 'đ': "d", 'ð': "d", 'ł': "l", 'ħ': "h", 'ŧ': "t", 'ı': "i",
+	'æ': "ae", 'œ': "oe", 'þ': "th",
+}
+
+// Fold returns s for matching: case folded, decomposed, without combining
+// marks and with its white space collapsed, so "Ekström" and "ekstrom"
+// fold to the same text. Folded text is for comparison only and is never
+// stored in place of the original.
+func Fold(s string) string {
+	// Case folding comes first, as it can itself produce combining marks
+	folded, _, err := transform.String(transform.Chain(cases.Fold(), norm.NFD, runes.Remove(runes.In(unicode.Mn))), s)
+	if err != nil {
+		folded = strings.ToLower(s)
+	}
+	var b strings.Builder
+	space := false
+	for _, r := range folded {
+		if unicode.IsSpace(r) {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		if l, ok := letters[r]; ok {
+			b.WriteString(l)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
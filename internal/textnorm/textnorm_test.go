@@ -0,0 +1,79 @@
+package textnorm
+
+import (
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestNFC(t *testing.T) {
+	for in, want := range map[string]string{
+		"Ekstro\u0308m":          "Ekström",
+		"Ekström":                "Ekström",
+		"\u212bngström":          "Ångström",
+		"q\u0307\u0323":          "q\u0323\u0307",
+		"ḋ\u0323":                "ḍ\u0307",
+		"a\u0328\u0301":          "ą\u0301",
+		"e\u0301\u0301":          "é\u0301",
+		"\u1100\u1161\u11a8":     "각",
+		"\u0958":                 "क\u093c",
+		"\u0301a":                "\u0301a",
+		"plain ASCII":            "plain ASCII",
+		"Nguye\u0302\u0303n":     "Nguyễn",
+		"Α\u0313\u0301":          "Ἄ",
+		"Zu\u0308rich, So\u0308": "Zürich, Sö",
+	} {
+		if got := NFC(in); got != want {
+			t.Errorf("NFC(%+q) = %+q, expected %+q", in, got, want)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	for in, want := range map[string]string{
+		"Ekström":        "ekstrom",
+		"Ekstro\u0308m":  "ekstrom",
+		"EKSTROM":        "ekstrom",
+		"  Bjørn   Ærø ": "bjorn aero",
+		"Łódź":           "lodz",
+		"Straße":         "strasse",
+		"Nguyễn Thị":     "nguyen thi",
+		"İstanbul":       "istanbul",
+	} {
+		if got := Fold(in); got != want {
+			t.Errorf("Fold(%q) = %q, expected %q", in, got, want)
+		}
+	}
+	if !Matches("Ekström", "ekstrom") || Matches("Ekström", "Ekstrand") {
+		t.Error("expected names to match by their folded text only")
+	}
+}
+
+func TestNormalizeAndTerms(t *testing.T) {
+	raid := &models.RAiD{
+		Title:       []models.Title{{Text: "Ekstro\u0308m glaciers"}, {Text: "Ekström Glaciers"}},
+		Description: []models.Description{{Text: "Mo\u0308te"}},
+		Subject: []models.Subject{{
+			ID:      "https://linked.data.gov.au/def/anzsrc-for/2020/3705",
+			Keyword: []models.SubjectKeyword{{Text: "Glaciologi\u0301a"}},
+		}},
+	}
+	Normalize(raid)
+	if raid.Title[0].Text != "Ekström glaciers" || raid.Description[0].Text != "Möte" || raid.Subject[0].Keyword[0].Text != "Glaciología" {
+		t.Errorf("expected text in NFC, got %+q, %+q, %+q", raid.Title[0].Text, raid.Description[0].Text, raid.Subject[0].Keyword[0].Text)
+	}
+
+	terms := Terms(raid)
+	want := []Term{
+		{Kind: TermTitle, Text: "ekstrom glaciers"},
+		{Kind: TermKeyword, Scope: raid.Subject[0].ID, Text: "glaciologia"},
+	}
+	if len(terms) != len(want) || terms[0] != want[0] || terms[1] != want[1] {
+		t.Errorf("expected %v, got %v", want, terms)
+	}
+	for text, want := range map[string]bool{"Ekstrom": true, "EKSTRÖM GLAC": true, "glaciología": true, "Ekstrand": false} {
+		if got := ContainsText(terms, text); got != want {
+			t.Errorf("ContainsText(%q) = %v, expected %v", text, got, want)
+		}
+	}
+}
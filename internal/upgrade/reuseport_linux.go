@@ -0,0 +1,9 @@
+//go:build linux
+
+package upgrade
+
+import "syscall"
+
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+}
@@ -0,0 +1,7 @@
+//go:build linux && !(mips || mipsle || mips64 || mips64le)
+
+package upgrade
+
+// soReusePort is SO_REUSEPORT, which package syscall lacks on most Linux
+// architectures
+const soReusePort = 0xf
@@ -0,0 +1,9 @@
+//go:build unix && !linux && !solaris
+
+package upgrade
+
+import "syscall"
+
+func setReusePort(fd uintptr) error {
+	return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEPORT, 1)
+}
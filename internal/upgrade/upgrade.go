@@ -0,0 +1,153 @@
+// Package upgrade replaces the running server binary in place without
+// dropping connections. On Upgrade the server starts the new binary with
+// its listening sockets, which the new process serves from instead of
+// binding the addresses again; once the new process reports Ready, the
+// old one stops accepting and shuts down gracefully. Connections arriving
+// meanwhile wait in the sockets' backlog, which both processes share.
+//
+// A new process that needs something the old one holds before it can be
+// ready, such as the lock of a file storage directory, asks for it with
+// Handover; the old process releases it, and takes it back if the new
+// process fails.
+package upgrade
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// envListeners names the addresses of the sockets passed to the new
+	// process, comma-separated, from file descriptor 3 on
+	envListeners = "RAID_UPGRADE_LISTENERS"
+	// envReadyFD is the file descriptor the new process reports Ready on
+	envReadyFD = "RAID_UPGRADE_READY_FD"
+
+	// Bytes the new process writes to the ready pipe
+	readyByte    = 1
+	handoverByte = 2
+)
+
+// Timeout bounds how long Upgrade waits for the new process to be Ready
+var Timeout = 2 * time.Minute
+
+// ErrNotSupported is returned by Upgrade where sockets cannot be passed
+// to a new process
+var ErrNotSupported = errors.New("upgrades are not supported on this platform")
+
+// Handover is what a process being replaced gives up when the new process
+// asks for it: Release is called then, and Retake if the new process fails
+// before it is ready
+type Handover interface {
+	Release() error
+	Retake() error
+}
+
+// Upgrader opens the server's listeners, taking them over from the process
+// it replaces if there is one, and passes them on to its replacement
+type Upgrader struct {
+	reusePort bool
+
+	mu        sync.Mutex
+	inherited map[string]*os.File
+	listeners map[string]net.Listener
+	upgrading bool
+
+	ready     *os.File
+	readyOnce sync.Once
+	readied   bool
+}
+
+// New creates an upgrader, taking the sockets passed by the process being
+// replaced, if any. With reusePort, addresses are bound with SO_REUSEPORT,
+// so that a new binary can also be started alongside by other means.
+func New(reusePort bool) (*Upgrader, error) {
+	u := &Upgrader{reusePort: reusePort, inherited: map[string]*os.File{}, listeners: map[string]net.Listener{}}
+	if names := os.Getenv(envListeners); names != "" {
+		for i, addr := range strings.Split(names, ",") {
+			u.inherited[addr] = os.NewFile(uintptr(3+i), addr)
+		}
+	}
+	if fd := os.Getenv(envReadyFD); fd != "" {
+		n, err := strconv.Atoi(fd)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", envReadyFD, err)
+		}
+		u.ready = os.NewFile(uintptr(n), "ready")
+	}
+	// The sockets are not passed on to processes started otherwise
+	os.Unsetenv(envListeners)
+	os.Unsetenv(envReadyFD)
+	return u, nil
+}
+
+// Upgrading reports whether this process replaces another
+func (u *Upgrader) Upgrading() bool {
+	return u.ready != nil
+}
+
+// Listen returns a listener on address: the one already returned for it,
+// the socket the replaced process passed for it, or else a new one
+func (u *Upgrader) Listen(network, address string) (net.Listener, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if ln, ok := u.listeners[address]; ok {
+		return ln, nil
+	}
+	var ln net.Listener
+	var err error
+	if f, ok := u.inherited[address]; ok {
+		delete(u.inherited, address)
+		ln, err = net.FileListener(f)
+		f.Close()
+	} else {
+		ln, err = listen(network, address, u.reusePort)
+	}
+	if err != nil {
+		return nil, err
+	}
+	u.listeners[address] = ln
+	return ln, nil
+}
+
+// Ready tells the replaced process that this one has taken over, and
+// closes the sockets passed for addresses no longer listened on. It does
+// nothing in a process that replaces none, and after the first call.
+func (u *Upgrader) Ready() error {
+	var err error
+	u.readyOnce.Do(func() {
+		if u.ready == nil {
+			return
+		}
+		u.mu.Lock()
+		for addr, f := range u.inherited {
+			f.Close()
+			delete(u.inherited, addr)
+		}
+		u.readied = true
+		u.mu.Unlock()
+		_, err = u.ready.Write([]byte{readyByte})
+		u.ready.Close()
+	})
+	return err
+}
+
+// Handover asks the replaced process to release what it hands over, and
+// returns once the request is sent. It does nothing in a process that
+// replaces none, and after Ready.
+func (u *Upgrader) Handover() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.ready == nil || u.readied {
+		return nil
+	}
+	_, err := u.ready.Write([]byte{handoverByte})
+	return err
+}
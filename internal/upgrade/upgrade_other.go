@@ -0,0 +1,20 @@
+//go:build !unix
+
+package upgrade
+
+import (
+	"errors"
+	"net"
+)
+
+func listen(network, address string, reusePort bool) (net.Listener, error) {
+	if reusePort {
+		return nil, errors.New("SO_REUSEPORT is not supported on this platform")
+	}
+	return net.Listen(network, address)
+}
+
+// Upgrade returns ErrNotSupported
+func (u *Upgrader) Upgrade(handover Handover) error {
+	return ErrNotSupported
+}
@@ -0,0 +1,139 @@
+//go:build unix
+
+package upgrade
+
+import (
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+// addr is the address the test listens on in both processes
+const addr = "127.0.0.1:0"
+
+// envFail makes the replacing process exit after asking for a Handover
+const envFail = "UPGRADE_TEST_FAIL"
+
+// handover counts the calls of a Handover
+type handover struct {
+	released, retaken int
+}
+
+func (h *handover) Release() error {
+	h.released++
+	return nil
+}
+
+func (h *handover) Retake() error {
+	h.retaken++
+	return nil
+}
+
+// TestMain runs the replacing process when the test binary is started by
+// Upgrade: it takes over the listener, asks for a Handover, is Ready and
+// answers one connection
+func TestMain(m *testing.M) {
+	if os.Getenv(envReadyFD) == "" {
+		os.Exit(m.Run())
+	}
+	u, err := New(false)
+	if err != nil {
+		os.Exit(2)
+	}
+	ln, err := u.Listen("tcp", addr)
+	if err != nil {
+		os.Exit(3)
+	}
+	if err := u.Handover(); err != nil {
+		os.Exit(6)
+	}
+	if os.Getenv(envFail) != "" {
+		os.Exit(7)
+	}
+	if err := u.Ready(); err != nil {
+		os.Exit(4)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		os.Exit(5)
+	}
+	conn.Write([]byte("new"))
+	conn.Close()
+	os.Exit(0)
+}
+
+func TestUpgrade(t *testing.T) {
+	u, err := New(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Upgrading() {
+		t.Fatal("Upgrading() = true without a process to replace")
+	}
+	ln, err := u.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := u.Listen("tcp", addr); again != ln {
+		t.Error("Listen on the same address returned another listener")
+	}
+	if err := u.Ready(); err != nil {
+		t.Fatalf("Ready() without a process to replace: %v", err)
+	}
+
+	if err := u.Handover(); err != nil {
+		t.Fatalf("Handover() without a process to replace: %v", err)
+	}
+
+	// A replacement failing after the handover gives it back
+	t.Setenv(envFail, "1")
+	h := &handover{}
+	if err := u.Upgrade(h); err == nil {
+		t.Fatal("Upgrade() = nil for a process that exited")
+	}
+	if h.released != 1 || h.retaken != 1 {
+		t.Errorf("failed upgrade released %d times and retook %d times, want 1 and 1", h.released, h.retaken)
+	}
+
+	os.Unsetenv(envFail)
+	h = &handover{}
+	if err := u.Upgrade(h); err != nil {
+		t.Fatalf("Upgrade() = %v", err)
+	}
+	if h.released != 1 || h.retaken != 0 {
+		t.Errorf("upgrade released %d times and retook %d times, want 1 and 0", h.released, h.retaken)
+	}
+	// The old process stops accepting; the new one has the socket
+	address := ln.Addr().String()
+	ln.Close()
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		t.Fatalf("dial after upgrade: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	got, err := io.ReadAll(conn)
+	if err != nil || string(got) != "new" {
+		t.Errorf("read %q, %v; want the new process to answer", got, err)
+	}
+}
+
+func TestReusePort(t *testing.T) {
+	first, err := listen("tcp", "127.0.0.1:0", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Close()
+	second, err := listen("tcp", first.Addr().String(), true)
+	if err != nil {
+		t.Fatalf("second listener on %s: %v", first.Addr(), err)
+	}
+	second.Close()
+
+	if ln, err := listen("tcp", first.Addr().String(), false); err == nil {
+		ln.Close()
+		t.Error("listener without SO_REUSEPORT bound a port in use")
+	}
+}
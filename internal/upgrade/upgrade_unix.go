@@ -0,0 +1,167 @@
+//go:build unix
+
+package upgrade
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// listen binds address, with SO_REUSEPORT if reusePort
+func listen(network, address string, reusePort bool) (net.Listener, error) {
+	lc := net.ListenConfig{}
+	if reusePort {
+		lc.Control = func(network, address string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) {
+				serr = setReusePort(fd)
+			}); err != nil {
+				return err
+			}
+			return serr
+		}
+	}
+	return lc.Listen(context.Background(), network, address)
+}
+
+// Upgrade starts the binary at the path of the running one, with the same
+// arguments and environment and the listeners, and waits until it is
+// Ready. The caller then shuts down. If the new process asks for a
+// Handover, handover, unless nil, is released. If the new process exits or
+// is not Ready within Timeout, it is stopped, what was released is taken
+// back and the error returned, and the caller goes on serving.
+func (u *Upgrader) Upgrade(handover Handover) error {
+	u.mu.Lock()
+	if u.upgrading {
+		u.mu.Unlock()
+		return errors.New("an upgrade is already in progress")
+	}
+	u.upgrading = true
+	u.mu.Unlock()
+	defer func() {
+		u.mu.Lock()
+		u.upgrading = false
+		u.mu.Unlock()
+	}()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	files, names, err := u.files()
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(os.Environ(),
+		envListeners+"="+strings.Join(names, ","),
+		envReadyFD+"="+strconv.Itoa(3+len(files)),
+	)
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return fmt.Errorf("failed to start %s: %w", exe, err)
+	}
+
+	// The reader stops at the ready byte, when the pipe closes, which it
+	// does when the process exits, or once Upgrade returns
+	signals := make(chan byte)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(signals)
+		var b [1]byte
+		for {
+			if _, err := r.Read(b[:]); err != nil {
+				return
+			}
+			select {
+			case signals <- b[0]:
+			case <-done:
+				return
+			}
+			if b[0] == readyByte {
+				return
+			}
+		}
+	}()
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	released := false
+	fail := func(err error) error {
+		if released {
+			if rerr := handover.Retake(); rerr != nil {
+				return fmt.Errorf("%w; taking back what was handed over failed: %v", err, rerr)
+			}
+		}
+		return err
+	}
+	timeout := time.After(Timeout)
+	for {
+		select {
+		case b, ok := <-signals:
+			switch {
+			case !ok:
+				// The pipe closed without the ready byte: the process exited
+				return fail(fmt.Errorf("new process exited before it was ready: %v", <-exited))
+			case b == readyByte:
+				return nil
+			case b == handoverByte && handover != nil && !released:
+				if err := handover.Release(); err != nil {
+					cmd.Process.Kill()
+					return fmt.Errorf("failed to hand over to the new process: %w", err)
+				}
+				released = true
+			}
+		case <-timeout:
+			// Only a process that has exited has let go of what it took
+			cmd.Process.Kill()
+			<-exited
+			return fail(fmt.Errorf("new process was not ready within %s", Timeout))
+		}
+	}
+}
+
+// files returns duplicates of the listeners' sockets and their addresses
+func (u *Upgrader) files() ([]*os.File, []string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	var files []*os.File
+	var names []string
+	for addr, ln := range u.listeners {
+		filer, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			return files, nil, fmt.Errorf("listener on %s cannot be passed on", addr)
+		}
+		f, err := filer.File()
+		if err != nil {
+			return files, nil, err
+		}
+		files = append(files, f)
+		names = append(names, addr)
+	}
+	return files, names, nil
+}
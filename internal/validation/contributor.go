@@ -0,0 +1,48 @@
+package validation
+
+import (
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// checkContributors requires at least one contributor flagged leader and
+// one flagged contact, each with a position. A RAiD without contributors
+// is accepted, so activities can be minted before their team is known.
+// Position terms are checked by the vocabulary package.
+func checkContributors(contributors []models.Contributor) []models.ValidationFailure {
+	if len(contributors) == 0 {
+		return nil
+	}
+	var failures []models.ValidationFailure
+	var leader, contact bool
+	for i, c := range contributors {
+		leader = leader || c.Leader
+		contact = contact || c.Contact
+		if !c.Leader && !c.Contact {
+			continue
+		}
+		if len(c.Position) == 0 {
+			failures = append(failures, models.ValidationFailure{
+				FieldID:   fmt.Sprintf("contributor[%d].position", i),
+				ErrorType: NotSet,
+				Message:   "a contributor flagged as leader or contact must hold a position",
+			})
+		}
+	}
+	if !leader {
+		failures = append(failures, models.ValidationFailure{
+			FieldID:   "contributor",
+			ErrorType: NotSet,
+			Message:   "at least one contributor must be flagged as a project leader",
+		})
+	}
+	if !contact {
+		failures = append(failures, models.ValidationFailure{
+			FieldID:   "contributor",
+			ErrorType: NotSet,
+			Message:   "at least one contributor must be flagged as a project contact",
+		})
+	}
+	return failures
+}
@@ -0,0 +1,135 @@
+package validation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// dateLayouts are the partial date formats of the metadata schema
+var dateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// span is the days a partial date covers; 2024-03 covers March 2024
+type span struct {
+	first, last time.Time
+}
+
+// parseDate returns the days covered by a partial date
+func parseDate(s string) (span, bool) {
+	for i, layout := range dateLayouts {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			continue
+		}
+		switch i {
+		case 0:
+			return span{t, t}, true
+		case 1:
+			return span{t, t.AddDate(0, 1, -1)}, true
+		default:
+			return span{t, t.AddDate(1, 0, -1)}, true
+		}
+	}
+	return span{}, false
+}
+
+// interval is the period from a start date to an end date. A zero first
+// or last day leaves that side open.
+type interval struct {
+	first, last time.Time
+}
+
+// parseInterval returns the interval from start to end, either of which
+// may be empty, and false if either cannot be parsed
+func parseInterval(start, end string) (interval, bool) {
+	var iv interval
+	if start != "" {
+		s, ok := parseDate(start)
+		if !ok {
+			return iv, false
+		}
+		iv.first = s.first
+	}
+	if end != "" {
+		e, ok := parseDate(end)
+		if !ok {
+			return iv, false
+		}
+		iv.last = e.last
+	}
+	return iv, true
+}
+
+// startsBefore reports whether a starts before b
+func (a interval) startsBefore(b interval) bool {
+	if a.first.IsZero() || b.first.IsZero() {
+		return a.first.IsZero() && !b.first.IsZero()
+	}
+	return a.first.Before(b.first)
+}
+
+// endsAfter reports whether a ends after b
+func (a interval) endsAfter(b interval) bool {
+	if a.last.IsZero() || b.last.IsZero() {
+		return a.last.IsZero() && !b.last.IsZero()
+	}
+	return a.last.After(b.last)
+}
+
+// checkDates requires every date to be a partial date, every start date
+// to come no later than its end date and every dated block to lie within
+// the RAiD's dates
+func checkDates(raid *models.RAiD) []models.ValidationFailure {
+	var failures []models.ValidationFailure
+	var dates interval
+	if raid.Date != nil {
+		failures = append(failures, checkPeriod("date", raid.Date.StartDate, raid.Date.EndDate, interval{})...)
+		dates, _ = parseInterval(raid.Date.StartDate, raid.Date.EndDate)
+	}
+	for i, t := range raid.Title {
+		failures = append(failures, checkPeriod(fmt.Sprintf("title[%d]", i), t.StartDate, t.EndDate, dates)...)
+	}
+	for i, c := range raid.Contributor {
+		for j, p := range c.Position {
+			failures = append(failures, checkPeriod(fmt.Sprintf("contributor[%d].position[%d]", i, j), p.StartDate, p.EndDate, dates)...)
+		}
+	}
+	for i, o := range raid.Organisation {
+		for j, r := range o.Role {
+			failures = append(failures, checkPeriod(fmt.Sprintf("organisation[%d].role[%d]", i, j), r.StartDate, r.EndDate, dates)...)
+		}
+	}
+	return failures
+}
+
+// checkPeriod checks the dates of the block at field against each other
+// and against the enclosing interval
+func checkPeriod(field, start, end string, within interval) []models.ValidationFailure {
+	var failures []models.ValidationFailure
+	var s, e span
+	var hasStart, hasEnd bool
+	if start != "" {
+		if s, hasStart = parseDate(start); !hasStart {
+			failures = append(failures, invalid(field+".startDate", "must be a date of the form YYYY, YYYY-MM or YYYY-MM-DD"))
+		}
+	}
+	if end != "" {
+		if e, hasEnd = parseDate(end); !hasEnd {
+			failures = append(failures, invalid(field+".endDate", "must be a date of the form YYYY, YYYY-MM or YYYY-MM-DD"))
+		}
+	}
+	if hasStart && hasEnd && s.first.After(e.last) {
+		failures = append(failures, invalid(field+".endDate", "must not be before startDate"))
+	}
+	if hasStart && !within.first.IsZero() && s.first.Before(within.first) {
+		failures = append(failures, invalid(field+".startDate", "must not be before the RAiD's start date"))
+	}
+	if hasStart && !within.last.IsZero() && s.first.After(within.last) {
+		failures = append(failures, invalid(field+".startDate", "must not be after the RAiD's end date"))
+	}
+	if hasEnd && !within.last.IsZero() && e.last.After(within.last) {
+		failures = append(failures, invalid(field+".endDate", "must not be after the RAiD's end date"))
+	}
+	return failures
+}
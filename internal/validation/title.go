@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// checkTitles requires, once any title has a type, every title to have one
+// and exactly one primary title to be active on each day of the RAiD.
+// Titles without a start date start with the RAiD. Titles whose dates
+// cannot be parsed are left to checkDates.
+func checkTitles(raid *models.RAiD) []models.ValidationFailure {
+	var dates interval
+	if raid.Date != nil {
+		dates, _ = parseInterval(raid.Date.StartDate, raid.Date.EndDate)
+	}
+
+	var failures []models.ValidationFailure
+	typed := false
+	for _, t := range raid.Title {
+		typed = typed || t.Type != nil
+	}
+	type primary struct {
+		index int
+		interval
+	}
+	var primaries []primary
+	for i, t := range raid.Title {
+		iv, ok := parseInterval(t.StartDate, t.EndDate)
+		if !ok {
+			continue
+		}
+		if iv.first.IsZero() {
+			iv.first = dates.first
+		}
+
+		switch {
+		case !typed:
+		case t.Type == nil:
+			failures = append(failures, models.ValidationFailure{
+				FieldID:   fmt.Sprintf("title[%d].type", i),
+				ErrorType: NotSet,
+				Message:   "field must be set when other titles have a type",
+			})
+		case t.Type.ID == models.TitleTypePrimary:
+			primaries = append(primaries, primary{i, iv})
+		}
+	}
+	if !typed {
+		return failures
+	}
+	if len(primaries) == 0 {
+		return append(failures, models.ValidationFailure{
+			FieldID:   "title",
+			ErrorType: NotSet,
+			Message:   "a primary title is required",
+		})
+	}
+
+	sort.SliceStable(primaries, func(i, j int) bool { return primaries[i].startsBefore(primaries[j].interval) })
+	if dates.startsBefore(primaries[0].interval) {
+		failures = append(failures, invalid(fmt.Sprintf("title[%d].startDate", primaries[0].index), "no primary title is active at the start of the RAiD"))
+	}
+	for k := 1; k < len(primaries); k++ {
+		prev, cur := primaries[k-1], primaries[k]
+		switch {
+		case prev.last.IsZero() || cur.first.Before(prev.last):
+			failures = append(failures, invalid(fmt.Sprintf("title[%d].startDate", cur.index),
+				fmt.Sprintf("overlaps primary title[%d]; only one primary title may be active at a time", prev.index)))
+		case cur.first.After(prev.last.AddDate(0, 0, 1)):
+			failures = append(failures, invalid(fmt.Sprintf("title[%d].startDate", cur.index),
+				fmt.Sprintf("leaves a gap after primary title[%d]; a primary title must be active at all times", prev.index)))
+		}
+	}
+	if last := primaries[len(primaries)-1]; dates.endsAfter(last.interval) {
+		failures = append(failures, invalid(fmt.Sprintf("title[%d].endDate", last.index), "no primary title is active at the end of the RAiD"))
+	}
+	return failures
+}
+
+// invalid returns an InvalidValue failure of field
+func invalid(field, message string) models.ValidationFailure {
+	return models.ValidationFailure{FieldID: field, ErrorType: InvalidValue, Message: message}
+}
@@ -0,0 +1,62 @@
+// Package validation enforces the rules of the RAiD metadata schema that
+// go beyond single fields, such as how many contributors must lead the
+// activity. Every broken rule is reported as a ValidationFailure naming the
+// field at fault.
+package validation
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Error types of validation failures
+const (
+	// NotSet marks a required value that is missing
+	NotSet = "notSet"
+	// InvalidValue marks a value that breaks a rule
+	InvalidValue = "invalidValue"
+	// UnknownProperty marks a property the schema does not define
+	UnknownProperty = "unknownProperty"
+)
+
+// Error reports the rules a RAiD breaks; it matches storage.ErrValidation
+type Error = storage.ValidationError
+
+// Validate checks raid against the rules and returns an *Error listing
+// every failure, or nil
+func Validate(raid *models.RAiD) error {
+	var failures []models.ValidationFailure
+	failures = append(failures, checkDates(raid)...)
+	failures = append(failures, checkTitles(raid)...)
+	failures = append(failures, checkContributors(raid.Contributor)...)
+	if len(failures) == 0 {
+		return nil
+	}
+	return &Error{Failures: failures}
+}
+
+// Wrap returns a repository that validates RAiDs before they are minted or
+// updated in repo
+func Wrap(repo storage.Repository) storage.Repository {
+	return &repository{Repository: repo}
+}
+
+type repository struct {
+	storage.Repository
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if err := Validate(raid); err != nil {
+		return nil, err
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	if err := Validate(raid); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
@@ -0,0 +1,207 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestValidate_Contributors(t *testing.T) {
+	position := []models.ContributorPosition{{ID: "https://vocabulary.raid.org/contributor.position.schema/307", StartDate: "2024-01-01"}}
+	tests := []struct {
+		name         string
+		contributors []models.Contributor
+		want         []string
+	}{
+		{name: "none"},
+		{
+			name:         "leader and contact",
+			contributors: []models.Contributor{{Leader: true, Contact: true, Position: position}, {}},
+		},
+		{
+			name:         "no leader",
+			contributors: []models.Contributor{{Contact: true, Position: position}},
+			want:         []string{"contributor"},
+		},
+		{
+			name:         "no flags",
+			contributors: []models.Contributor{{Position: position}},
+			want:         []string{"contributor", "contributor"},
+		},
+		{
+			name:         "leader without position",
+			contributors: []models.Contributor{{Leader: true}, {Contact: true, Position: position}},
+			want:         []string{"contributor[0].position"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&models.RAiD{Contributor: tt.contributors})
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var valErr *Error
+			if !errors.As(err, &valErr) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if len(valErr.Failures) != len(tt.want) {
+				t.Fatalf("expected %d failures, got %+v", len(tt.want), valErr.Failures)
+			}
+			for i, f := range valErr.Failures {
+				if f.FieldID != tt.want[i] || f.ErrorType != NotSet {
+					t.Errorf("unexpected failure %+v, want field %s", f, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_Titles(t *testing.T) {
+	primary := &models.IDSchema{ID: models.TitleTypePrimary}
+	alternative := &models.IDSchema{ID: models.TitleTypeAlternative}
+	dates := &models.Date{StartDate: "2020-01-01", EndDate: "2024-12-31"}
+	tests := []struct {
+		name   string
+		titles []models.Title
+		want   []string
+	}{
+		{name: "untyped", titles: []models.Title{{Text: "A"}, {Text: "B"}}},
+		{
+			name:   "one primary",
+			titles: []models.Title{{Text: "A", Type: primary}, {Text: "B", Type: alternative, StartDate: "2021"}},
+		},
+		{
+			name: "renamed",
+			titles: []models.Title{
+				{Text: "A", Type: primary, StartDate: "2020-01-01", EndDate: "2022-06-30"},
+				{Text: "B", Type: primary, StartDate: "2022-07"},
+			},
+		},
+		{
+			name:   "no primary",
+			titles: []models.Title{{Text: "A", Type: alternative}},
+			want:   []string{"title"},
+		},
+		{
+			name:   "missing type",
+			titles: []models.Title{{Text: "A", Type: primary}, {Text: "B"}},
+			want:   []string{"title[1].type"},
+		},
+		{
+			name:   "overlapping primaries",
+			titles: []models.Title{{Text: "A", Type: primary}, {Text: "B", Type: primary, StartDate: "2022"}},
+			want:   []string{"title[1].startDate"},
+		},
+		{
+			name: "gap between primaries",
+			titles: []models.Title{
+				{Text: "A", Type: primary, EndDate: "2021"},
+				{Text: "B", Type: primary, StartDate: "2022-02"},
+			},
+			want: []string{"title[1].startDate"},
+		},
+		{
+			name:   "primary ends early",
+			titles: []models.Title{{Text: "A", Type: primary, EndDate: "2023"}},
+			want:   []string{"title[0].endDate"},
+		},
+		{
+			name:   "outside the RAiD",
+			titles: []models.Title{{Text: "A", Type: primary, StartDate: "2019", EndDate: "2025"}},
+			want:   []string{"title[0].startDate", "title[0].endDate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&models.RAiD{Date: dates, Title: tt.titles})
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var valErr *Error
+			if !errors.As(err, &valErr) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if len(valErr.Failures) != len(tt.want) {
+				t.Fatalf("expected %d failures, got %+v", len(tt.want), valErr.Failures)
+			}
+			for i, f := range valErr.Failures {
+				if f.FieldID != tt.want[i] {
+					t.Errorf("unexpected failure %+v, want field %s", f, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidate_Dates(t *testing.T) {
+	tests := []struct {
+		name string
+		raid models.RAiD
+		want []string
+	}{
+		{
+			name: "partial dates",
+			raid: models.RAiD{
+				Date:  &models.Date{StartDate: "2020", EndDate: "2024-06"},
+				Title: []models.Title{{Text: "A", StartDate: "2020-01-01", EndDate: "2024-06-30"}},
+			},
+		},
+		{
+			name: "bad formats",
+			raid: models.RAiD{Date: &models.Date{StartDate: "2020-1-1", EndDate: "01/02/2024"}},
+			want: []string{"date.startDate", "date.endDate"},
+		},
+		{
+			name: "end before start",
+			raid: models.RAiD{
+				Date: &models.Date{StartDate: "2024", EndDate: "2023"},
+				Organisation: []models.Organisation{{
+					ID:   "https://ror.org/038sjwq14",
+					Role: []models.OrganisationRole{{StartDate: "2024-05-01", EndDate: "2024-04"}},
+				}},
+			},
+			want: []string{"date.endDate", "organisation[0].role[0].endDate", "organisation[0].role[0].startDate", "organisation[0].role[0].endDate"},
+		},
+		{
+			name: "position outside the RAiD",
+			raid: models.RAiD{
+				Date: &models.Date{StartDate: "2020-01-01", EndDate: "2020-12-31"},
+				Contributor: []models.Contributor{{
+					Leader: true, Contact: true,
+					Position: []models.ContributorPosition{{ID: "https://vocabulary.raid.org/contributor.position.schema/307", StartDate: "2021-01-01"}},
+				}},
+			},
+			want: []string{"contributor[0].position[0].startDate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.raid)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var valErr *Error
+			if !errors.As(err, &valErr) {
+				t.Fatalf("expected an *Error, got %v", err)
+			}
+			if len(valErr.Failures) != len(tt.want) {
+				t.Fatalf("expected %d failures, got %+v", len(tt.want), valErr.Failures)
+			}
+			for i, f := range valErr.Failures {
+				if f.FieldID != tt.want[i] || f.ErrorType != InvalidValue {
+					t.Errorf("unexpected failure %+v, want field %s", f, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package vocabulary
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Checker checks RAiDs against the vocabularies and a set of subject
+// classification schemes
+type Checker struct {
+	subjects []subjectScheme
+}
+
+// NewChecker returns a checker accepting subjects from schemes, or from
+// DefaultSubjectSchemes if schemes is empty
+func NewChecker(schemes []SubjectScheme) (*Checker, error) {
+	if len(schemes) == 0 {
+		schemes = DefaultSubjectSchemes
+	}
+	subjects, err := compileSubjectSchemes(schemes)
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{subjects: subjects}, nil
+}
+
+var defaultChecker, _ = NewChecker(nil)
+
+// Check checks raid with the default subject schemes
+func Check(raid *models.RAiD) error {
+	return defaultChecker.Check(raid)
+}
+
+// Check validates the controlled terms of raid, replacing terms of earlier
+// schema versions with the current ones and filling in missing schema URIs.
+// Subject keywords are normalized with NormalizeKeyword and related object
+// DOIs with identifier.NormalizeDOI; keywords differing only in case or
+// diacritics are deduplicated.
+// Every term not in its vocabulary is reported as an *Error.
+func (c *Checker) Check(raid *models.RAiD) error {
+	var errs []error
+	for i := range raid.Subject {
+		errs = append(errs, checkSubject(c.subjects, fmt.Sprintf("subject[%d]", i), &raid.Subject[i]))
+	}
+	for i := range raid.Title {
+		errs = append(errs, checkTerm(TitleType, fmt.Sprintf("title[%d].type", i), raid.Title[i].Type, false))
+	}
+	for i := range raid.Contributor {
+		errs = append(errs, checkPositions(fmt.Sprintf("contributor[%d]", i), raid.Contributor[i].Position)...)
+	}
+	for i := range raid.RelatedRAiD {
+		field := fmt.Sprintf("relatedRaid[%d].type", i)
+		errs = append(errs, checkTerm(RelatedRAiDType, field, raid.RelatedRAiD[i].Type, false))
+	}
+	for i := range raid.RelatedObject {
+		obj := &raid.RelatedObject[i]
+		// DOIs are written in one form so they can be looked up
+		if doi, ok := identifier.NormalizeDOI(obj.ID); ok {
+			obj.ID = doi
+		}
+		errs = append(errs, checkTerm(RelatedObjectType, fmt.Sprintf("relatedObject[%d].type", i), obj.Type, false))
+		for j := range obj.Category {
+			errs = append(errs, checkTerm(RelatedObjectCategory, fmt.Sprintf("relatedObject[%d].category[%d]", i, j), &obj.Category[j], true))
+		}
+	}
+	for i := range raid.TraditionalKnowledge {
+		errs = append(errs, checkLabel(fmt.Sprintf("traditionalKnowledgeLabel[%d]", i), &raid.TraditionalKnowledge[i]))
+	}
+	return errors.Join(errs...)
+}
+
+// checkLabel normalizes a TK or BC label in place
+func checkLabel(field string, label *models.TraditionalKnowledge) error {
+	v := labelVocabulary(label.ID)
+	if v == nil {
+		return &Error{Field: field + ".id", Value: label.ID, Vocabulary: "Local Contexts TK or BC label"}
+	}
+	term := models.IDSchema{ID: strings.TrimSpace(label.ID), SchemaURI: label.SchemaURI}
+	if err := checkTerm(v, field, &term, true); err != nil {
+		return err
+	}
+	label.ID, label.SchemaURI = term.ID, term.SchemaURI
+	return nil
+}
+
+// checkTerm normalizes term in place. A nil term is accepted unless
+// required.
+func checkTerm(v *Vocabulary, field string, term *models.IDSchema, required bool) error {
+	if term == nil {
+		if required {
+			return &Error{Field: field + ".id", Vocabulary: v.Name}
+		}
+		return nil
+	}
+	id, ok := v.Normalize(term.ID)
+	if !ok {
+		return &Error{Field: field + ".id", Value: term.ID, Vocabulary: v.Name}
+	}
+	schemaURI, ok := v.NormalizeSchemaURI(term.SchemaURI)
+	if !ok {
+		return &Error{Field: field + ".schemaUri", Value: term.SchemaURI, Vocabulary: v.Name, SchemaURI: v.SchemaURI}
+	}
+	term.ID, term.SchemaURI = id, schemaURI
+	return nil
+}
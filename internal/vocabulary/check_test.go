@@ -0,0 +1,93 @@
+package vocabulary
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestCheck(t *testing.T) {
+	raid := &models.RAiD{
+		RelatedRAiD: []models.RelatedRAiD{
+			{ID: "https://raid.org/10.1/a", Type: &models.IDSchema{ID: models.RelatedRAiDTypeHasPart}},
+			{ID: "https://raid.org/10.1/b", Type: &models.IDSchema{
+				ID:        "https://github.com/au-research/raid-metadata/blob/main/scheme/related-raid/type/v1/continues.json",
+				SchemaURI: "https://github.com/au-research/raid-metadata/tree/main/scheme/related-raid/type/v1/",
+			}},
+			{ID: "https://raid.org/10.1/c"},
+		},
+		RelatedObject: []models.RelatedObject{{
+			ID:       "https://doi.org/10.1/x",
+			Type:     &models.IDSchema{ID: Base + "related-object.type.schema/250", SchemaURI: Base + "related-object.type.schema/329"},
+			Category: []models.IDSchema{{ID: "https://github.com/au-research/raid-metadata/blob/main/scheme/related-object/category/v1/input.json"}},
+		}},
+		TraditionalKnowledge: []models.TraditionalKnowledge{{ID: "https://localcontexts.org/label/bc-provenance"}},
+		Title: []models.Title{{Text: "A", Type: &models.IDSchema{
+			ID: "https://github.com/au-research/raid-metadata/blob/main/scheme/title/type/v1/primary.json",
+		}}},
+		Contributor: []models.Contributor{{
+			ID:       "https://orcid.org/0000-0000-0000-0001",
+			Position: []models.ContributorPosition{{ID: Base + "contributor.position.schema/307", StartDate: "2024-01-01"}},
+		}},
+	}
+	if err := Check(raid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := raid.RelatedRAiD[0].Type; got.SchemaURI != models.RelatedRAiDTypeSchema {
+		t.Errorf("expected the schema URI to be filled in, got %+v", got)
+	}
+	if got := raid.RelatedRAiD[1].Type; got.ID != models.RelatedRAiDTypeContinues || got.SchemaURI != models.RelatedRAiDTypeSchema {
+		t.Errorf("expected the version 1 term to be replaced, got %+v", got)
+	}
+	if got := raid.RelatedObject[0].Category[0]; got.ID != Base+"related-object.category.id/191" || got.SchemaURI != Base+"related-object.category.schema/385" {
+		t.Errorf("expected the version 1 category to be replaced, got %+v", got)
+	}
+	if got := raid.TraditionalKnowledge[0]; got.ID != "https://localcontexts.org/label/bc-provenance/" || got.SchemaURI != BioculturalLabel.SchemaURI {
+		t.Errorf("expected the BC label to be normalized, got %+v", got)
+	}
+	if got := raid.Title[0].Type; got.ID != models.TitleTypePrimary || got.SchemaURI != models.TitleTypeSchema {
+		t.Errorf("expected the version 1 title type to be replaced, got %+v", got)
+	}
+	if got := raid.Contributor[0].Position[0]; got.SchemaURI != ContributorPosition.SchemaURI {
+		t.Errorf("expected the position schema URI to be filled in, got %+v", got)
+	}
+}
+
+func TestCheck_Unknown(t *testing.T) {
+	raid := &models.RAiD{
+		RelatedRAiD: []models.RelatedRAiD{
+			{ID: "https://raid.org/10.1/a", Type: &models.IDSchema{ID: Base + "related-raid.type.schema/999"}},
+			{ID: "https://raid.org/10.1/b", Type: &models.IDSchema{ID: models.RelatedRAiDTypeHasPart, SchemaURI: Base + "related-object.type.schema/329"}},
+		},
+		RelatedObject: []models.RelatedObject{{
+			ID:       "https://doi.org/10.1/x",
+			Type:     &models.IDSchema{ID: models.RelatedRAiDTypeHasPart},
+			Category: []models.IDSchema{{}},
+		}},
+		TraditionalKnowledge: []models.TraditionalKnowledge{
+			{ID: "https://localcontexts.org/label/tk-unknown/"},
+			{ID: "https://localcontexts.org/label/tk-attribution/", SchemaURI: BioculturalLabel.SchemaURI},
+		},
+		Contributor: []models.Contributor{{Position: []models.ContributorPosition{{ID: Base + "contributor.position.schema/399"}}}},
+	}
+	err := Check(raid)
+	var vocabErr *Error
+	if !errors.As(err, &vocabErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	for _, want := range []string{
+		"relatedRaid[0].type.id: " + Base + "related-raid.type.schema/999 is not a term of the related RAiD type vocabulary",
+		"relatedRaid[1].type.schemaUri",
+		"relatedObject[0].type.id",
+		"relatedObject[0].category[0].id is required",
+		"traditionalKnowledgeLabel[0].id: https://localcontexts.org/label/tk-unknown/ is not a term of the Local Contexts TK or BC label vocabulary",
+		"traditionalKnowledgeLabel[1].schemaUri",
+		"contributor[0].position[0].id",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package vocabulary
+
+import (
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// ContributorPosition is the vocabulary of contributor.position
+var ContributorPosition = newVocabulary("contributor position", Base+"contributor.position.schema/305", "contributor/position/v1", numbered(Base+"contributor.position.schema/", map[string]int{
+	"principal-or-chief-investigator": 307,
+	"co-investigator":                 308,
+	"partner-investigator":            309,
+	"consultant":                      310,
+	"other-participant":               311,
+}))
+
+// checkPositions normalizes the positions of a contributor in place
+func checkPositions(field string, positions []models.ContributorPosition) []error {
+	var errs []error
+	for i := range positions {
+		p := &positions[i]
+		term := models.IDSchema{ID: p.ID, SchemaURI: p.SchemaURI}
+		if err := checkTerm(ContributorPosition, fmt.Sprintf("%s.position[%d]", field, i), &term, true); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		p.ID, p.SchemaURI = term.ID, term.SchemaURI
+	}
+	return errs
+}
@@ -0,0 +1,64 @@
+package vocabulary
+
+import "strings"
+
+// localContextsLabel is the URI Local Contexts labels start with
+const localContextsLabel = "https://localcontexts.org/label/"
+
+// TraditionalKnowledgeLabel is the vocabulary of Local Contexts
+// Traditional Knowledge (TK) labels
+var TraditionalKnowledgeLabel = newTerms("Local Contexts TK label", "https://localcontexts.org/labels/traditional-knowledge-labels/", labels(
+	"tk-attribution",
+	"tk-clan",
+	"tk-family",
+	"tk-multiple-communities",
+	"tk-community-voice",
+	"tk-creative",
+	"tk-verified",
+	"tk-non-verified",
+	"tk-seasonal",
+	"tk-women-general",
+	"tk-men-general",
+	"tk-men-restricted",
+	"tk-women-restricted",
+	"tk-culturally-sensitive",
+	"tk-secret-sacred",
+	"tk-outreach",
+	"tk-non-commercial",
+	"tk-commercial",
+	"tk-community-use-only",
+	"tk-open-to-collaboration",
+)...)
+
+// BioculturalLabel is the vocabulary of Local Contexts Biocultural (BC)
+// labels
+var BioculturalLabel = newTerms("Local Contexts BC label", "https://localcontexts.org/labels/biocultural-labels/", labels(
+	"bc-provenance",
+	"bc-multiple-communities",
+	"bc-clan",
+	"bc-consent-verified",
+	"bc-consent-non-verified",
+	"bc-research-use",
+	"bc-non-commercial",
+	"bc-outreach",
+	"bc-open-to-collaboration",
+)...)
+
+// labels returns the URIs of Local Contexts labels
+func labels(names ...string) []string {
+	uris := make([]string, len(names))
+	for i, name := range names {
+		uris[i] = localContextsLabel + name + "/"
+	}
+	return uris
+}
+
+// labelVocabulary returns the label vocabulary id belongs to, or nil
+func labelVocabulary(id string) *Vocabulary {
+	for _, v := range []*Vocabulary{TraditionalKnowledgeLabel, BioculturalLabel} {
+		if _, ok := v.Normalize(strings.TrimSpace(id)); ok {
+			return v
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package vocabulary
+
+import "github.com/leifj/go-raid/internal/models"
+
+// RelatedRAiDType is the vocabulary of relatedRaid.type
+var RelatedRAiDType = newVocabulary("related RAiD type", models.RelatedRAiDTypeSchema, "related-raid/type/v1", map[string]string{
+	"continues":       models.RelatedRAiDTypeContinues,
+	"has-part":        models.RelatedRAiDTypeHasPart,
+	"is-continued-by": models.RelatedRAiDTypeIsContinuedBy,
+	"is-derived-from": models.RelatedRAiDTypeIsDerivedFrom,
+	"has-derivation":  models.RelatedRAiDTypeHasDerivation,
+	"is-part-of":      models.RelatedRAiDTypeIsPartOf,
+	"is-obsoleted-by": models.RelatedRAiDTypeIsObsoletedBy,
+	"obsoletes":       models.RelatedRAiDTypeObsoletes,
+})
+
+// RelatedObjectType is the vocabulary of relatedObject.type
+var RelatedObjectType = newVocabulary("related object type", Base+"related-object.type.schema/329", "related-object/type/v1", numbered(Base+"related-object.type.schema/", map[string]int{
+	"output-management-plan": 247,
+	"conference-poster":      248,
+	"workflow":               249,
+	"journal-article":        250,
+	"standard":               251,
+	"report":                 252,
+	"dissertation":           253,
+	"preprint":               254,
+	"data-paper":             255,
+	"computational-notebook": 256,
+	"image":                  257,
+	"book":                   258,
+	"software":               259,
+	"event":                  260,
+	"sound":                  261,
+	"conference-proceeding":  262,
+	"model":                  263,
+	"conference-paper":       264,
+	"text":                   265,
+	"instrument":             266,
+	"learning-object":        267,
+	"prize":                  268,
+	"dataset":                269,
+	"physical-object":        270,
+	"book-chapter":           271,
+	"funding":                272,
+	"audiovisual":            273,
+}))
+
+// RelatedObjectCategory is the vocabulary of relatedObject.category
+var RelatedObjectCategory = newVocabulary("related object category", Base+"related-object.category.schema/385", "related-object/category/v1", numbered(Base+"related-object.category.id/", map[string]int{
+	"output":                                190,
+	"input":                                 191,
+	"internal-process-document-or-artefact": 192,
+}))
@@ -0,0 +1,37 @@
+package vocabulary
+
+import (
+	"context"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// Wrap returns a repository that checks the controlled terms of RAiDs
+// with c before they are minted or updated in repo. A nil c uses the
+// default subject schemes.
+func Wrap(repo storage.Repository, c *Checker) storage.Repository {
+	if c == nil {
+		c = defaultChecker
+	}
+	return &repository{Repository: repo, checker: c}
+}
+
+type repository struct {
+	storage.Repository
+	checker *Checker
+}
+
+func (r *repository) CreateRAiD(ctx context.Context, raid *models.RAiD) (*models.RAiD, error) {
+	if err := r.checker.Check(raid); err != nil {
+		return nil, err
+	}
+	return r.Repository.CreateRAiD(ctx, raid)
+}
+
+func (r *repository) UpdateRAiD(ctx context.Context, prefix, suffix string, raid *models.RAiD) (*models.RAiD, error) {
+	if err := r.checker.Check(raid); err != nil {
+		return nil, err
+	}
+	return r.Repository.UpdateRAiD(ctx, prefix, suffix, raid)
+}
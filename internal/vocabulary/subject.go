@@ -0,0 +1,126 @@
+package vocabulary
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/textnorm"
+)
+
+// SubjectScheme is a subject classification scheme. Subject IDs are the
+// scheme's schema URI followed by a code.
+type SubjectScheme struct {
+	Name      string `yaml:"name" toml:"name" json:"name,omitempty"`
+	SchemaURI string `yaml:"schemaUri" toml:"schemaUri" json:"schemaUri"`
+	// Pattern is a regular expression the whole code must match; empty
+	// accepts any code
+	Pattern string `yaml:"pattern" toml:"pattern" json:"pattern,omitempty"`
+}
+
+// DefaultSubjectSchemes are the ANZSRC 2020 Fields of Research and
+// Socio-Economic Objectives classifications: two digit divisions, four
+// digit groups and six digit fields or objectives
+var DefaultSubjectSchemes = []SubjectScheme{
+	{Name: "ANZSRC FoR", SchemaURI: "https://linked.data.gov.au/def/anzsrc-for/2020/", Pattern: `(3[0-9]|4[0-9]|5[0-2])([0-9]{2}([0-9]{2})?)?`},
+	{Name: "ANZSRC SEO", SchemaURI: "https://linked.data.gov.au/def/anzsrc-seo/2020/", Pattern: `(1[0-9]|2[0-8])([0-9]{2}([0-9]{2})?)?`},
+}
+
+// ValidateSubjectSchemes checks configured subject schemes
+func ValidateSubjectSchemes(schemes []SubjectScheme) error {
+	_, err := compileSubjectSchemes(schemes)
+	return err
+}
+
+type subjectScheme struct {
+	SubjectScheme
+	code *regexp.Regexp
+}
+
+func compileSubjectSchemes(schemes []SubjectScheme) ([]subjectScheme, error) {
+	var errs []error
+	compiled := make([]subjectScheme, 0, len(schemes))
+	for i, s := range schemes {
+		if s.SchemaURI == "" {
+			errs = append(errs, fmt.Errorf("subjectSchemes[%d].schemaUri is required", i))
+			continue
+		}
+		code, err := regexp.Compile("^(?:" + s.Pattern + ")$")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("subjectSchemes[%d].pattern: %w", i, err))
+			continue
+		}
+		if s.Name == "" {
+			s.Name = s.SchemaURI
+		}
+		compiled = append(compiled, subjectScheme{SubjectScheme: s, code: code})
+	}
+	return compiled, errors.Join(errs...)
+}
+
+// checkSubject validates the ID of subject against the schemes, filling in
+// its schema URI, and normalizes its keywords
+func checkSubject(schemes []subjectScheme, field string, subject *models.Subject) error {
+	subject.ID = strings.TrimSpace(subject.ID)
+	var err error = &Error{Field: field + ".id", Value: subject.ID, Vocabulary: "subject classification"}
+	for _, s := range schemes {
+		code, ok := strings.CutPrefix(subject.ID, s.SchemaURI)
+		if !ok {
+			continue
+		}
+		if !s.code.MatchString(code) {
+			err = &Error{Field: field + ".id", Value: subject.ID, Vocabulary: s.Name}
+			break
+		}
+		if subject.SchemaURI != "" && subject.SchemaURI != s.SchemaURI {
+			err = &Error{Field: field + ".schemaUri", Value: subject.SchemaURI, Vocabulary: s.Name, SchemaURI: s.SchemaURI}
+			break
+		}
+		subject.SchemaURI = s.SchemaURI
+		err = nil
+		break
+	}
+
+	keywords := subject.Keyword[:0]
+	seen := make(map[string]bool, len(subject.Keyword))
+	for _, kw := range subject.Keyword {
+		kw.Text = NormalizeKeyword(kw.Text)
+		key := textnorm.Fold(kw.Text)
+		if kw.Language != nil {
+			key += "@" + kw.Language.ID
+		}
+		if kw.Text == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		keywords = append(keywords, kw)
+	}
+	subject.Keyword = keywords
+	return err
+}
+
+// NormalizeKeyword trims a subject keyword, collapses its white space and
+// lower-cases its words, except words such as acronyms that have capitals
+// after their first letter ("COVID-19 Vaccines" becomes "COVID-19
+// vaccines")
+func NormalizeKeyword(text string) string {
+	words := strings.Fields(text)
+	for i, w := range words {
+		if !hasInnerCapital(w) {
+			words[i] = strings.ToLower(w)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+func hasInnerCapital(word string) bool {
+	for i, r := range word {
+		if i > 0 && unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package vocabulary
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+func TestNormalizeKeyword(t *testing.T) {
+	for in, want := range map[string]string{
+		"  Climate   Change ": "climate change",
+		"COVID-19 Vaccines":   "COVID-19 vaccines",
+		"mRNA\tdelivery":      "mRNA delivery",
+		"":                    "",
+	} {
+		if got := NormalizeKeyword(in); got != want {
+			t.Errorf("NormalizeKeyword(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCheck_Subjects(t *testing.T) {
+	raid := &models.RAiD{Subject: []models.Subject{{
+		ID: "https://linked.data.gov.au/def/anzsrc-for/2020/3709",
+		Keyword: []models.SubjectKeyword{
+			{Text: "Coastal  Erosion"},
+			{Text: "coastal erosion"},
+			{Text: " "},
+		},
+	}}}
+	if err := Check(raid); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := raid.Subject[0]
+	if s.SchemaURI != "https://linked.data.gov.au/def/anzsrc-for/2020/" {
+		t.Errorf("expected the schema URI to be filled in, got %q", s.SchemaURI)
+	}
+	if len(s.Keyword) != 1 || s.Keyword[0].Text != "coastal erosion" {
+		t.Errorf("expected one normalized keyword, got %+v", s.Keyword)
+	}
+
+	raid = &models.RAiD{Subject: []models.Subject{
+		{ID: "https://linked.data.gov.au/def/anzsrc-for/2020/9999"},
+		{ID: "https://example.org/subjects/1"},
+		{ID: "https://linked.data.gov.au/def/anzsrc-seo/2020/1801", SchemaURI: "https://linked.data.gov.au/def/anzsrc-for/2020/"},
+	}}
+	err := Check(raid)
+	for _, want := range []string{
+		"subject[0].id: https://linked.data.gov.au/def/anzsrc-for/2020/9999 is not a term of the ANZSRC FoR vocabulary",
+		"subject[1].id: https://example.org/subjects/1 is not a term of the subject classification vocabulary",
+		"subject[2].schemaUri",
+	} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestNewChecker(t *testing.T) {
+	c, err := NewChecker([]SubjectScheme{{SchemaURI: "https://example.org/subjects/", Pattern: `[0-9]+`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(&models.RAiD{Subject: []models.Subject{{ID: "https://example.org/subjects/12"}}}); err != nil {
+		t.Errorf("expected a configured scheme to be accepted, got %v", err)
+	}
+	if err := c.Check(&models.RAiD{Subject: []models.Subject{{ID: "https://linked.data.gov.au/def/anzsrc-for/2020/3709"}}}); err == nil {
+		t.Error("expected configured schemes to replace the defaults")
+	}
+
+	if _, err := NewChecker([]SubjectScheme{{Pattern: `[`}}); err == nil || !strings.Contains(err.Error(), "subjectSchemes[0].schemaUri is required") {
+		t.Errorf("expected a missing schema URI to be reported, got %v", err)
+	}
+	if err := ValidateSubjectSchemes([]SubjectScheme{{SchemaURI: "https://example.org/", Pattern: `[`}}); err == nil || !strings.Contains(err.Error(), "subjectSchemes[0].pattern") {
+		t.Errorf("expected a bad pattern to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,11 @@
+package vocabulary
+
+import "github.com/leifj/go-raid/internal/models"
+
+// TitleType is the vocabulary of title.type
+var TitleType = newVocabulary("title type", models.TitleTypeSchema, "title/type/v1", map[string]string{
+	"alternative": models.TitleTypeAlternative,
+	"primary":     models.TitleTypePrimary,
+	"acronym":     models.TitleTypeAcronym,
+	"short":       models.TitleTypeShort,
+})
@@ -0,0 +1,106 @@
+// Package vocabulary checks RAiD metadata against the controlled
+// vocabularies of the RAiD metadata schema. Terms of earlier schema
+// versions are replaced by their current equivalents.
+package vocabulary
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Base is the URI all current RAiD vocabulary terms start with
+const Base = "https://vocabulary.raid.org/"
+
+// legacyBase is the URI terms of version 1 of the metadata schema start with
+const legacyBase = "https://github.com/au-research/raid-metadata/blob/main/scheme/"
+
+// Vocabulary is a controlled list of terms sharing a schema URI
+type Vocabulary struct {
+	// Name is the vocabulary name used in error messages
+	Name      string
+	SchemaURI string
+	// terms maps each current term to itself and each replaced term to
+	// its replacement
+	terms map[string]string
+	// legacySchemaURI is the schema URI the replaced terms were listed in
+	legacySchemaURI string
+}
+
+// newVocabulary returns a vocabulary of terms, keyed by their name in
+// version 1 of the metadata schema. Terms are also accepted under their
+// version 1 URI in legacyScheme and replaced by the current term.
+func newVocabulary(name, schemaURI, legacyScheme string, terms map[string]string) *Vocabulary {
+	v := &Vocabulary{
+		Name:            name,
+		SchemaURI:       schemaURI,
+		terms:           make(map[string]string, 2*len(terms)),
+		legacySchemaURI: strings.Replace(legacyBase, "/blob/", "/tree/", 1) + legacyScheme + "/",
+	}
+	for legacyName, current := range terms {
+		v.terms[current] = current
+		v.terms[legacyBase+legacyScheme+"/"+legacyName+".json"] = current
+	}
+	return v
+}
+
+// newTerms returns a vocabulary of URI terms. Terms written without their
+// trailing slash are accepted and replaced.
+func newTerms(name, schemaURI string, terms ...string) *Vocabulary {
+	v := &Vocabulary{Name: name, SchemaURI: schemaURI, terms: make(map[string]string, 2*len(terms))}
+	for _, term := range terms {
+		v.terms[term] = term
+		v.terms[strings.TrimSuffix(term, "/")] = term
+	}
+	return v
+}
+
+// numbered returns the URIs of terms numbered under base
+func numbered(base string, terms map[string]int) map[string]string {
+	uris := make(map[string]string, len(terms))
+	for name, n := range terms {
+		uris[name] = base + strconv.Itoa(n)
+	}
+	return uris
+}
+
+// Normalize returns the current term for id, which may be a replaced
+// term, and false if id is not in the vocabulary
+func (v *Vocabulary) Normalize(id string) (string, bool) {
+	current, ok := v.terms[id]
+	return current, ok
+}
+
+// NormalizeSchemaURI returns the vocabulary's schema URI for an empty or
+// replaced schemaURI, and false for the schema URI of another vocabulary.
+// A schema URI is also accepted without its trailing slash.
+func (v *Vocabulary) NormalizeSchemaURI(schemaURI string) (string, bool) {
+	switch schemaURI {
+	case "", v.SchemaURI:
+		return v.SchemaURI, true
+	case v.legacySchemaURI, strings.TrimSuffix(v.SchemaURI, "/"):
+		return v.SchemaURI, true
+	}
+	return "", false
+}
+
+// Error reports a value that is not in its vocabulary
+type Error struct {
+	// Field is the JSON path of the value, e.g. relatedRaid[0].type.id
+	Field string
+	Value string
+	// Vocabulary is the name of the vocabulary the value must be from
+	Vocabulary string
+	// SchemaURI is set when Value is the wrong schema URI for Vocabulary
+	SchemaURI string
+}
+
+func (e *Error) Error() string {
+	if e.SchemaURI != "" {
+		return fmt.Sprintf("%s: %s is not the schema of the %s vocabulary (%s)", e.Field, e.Value, e.Vocabulary, e.SchemaURI)
+	}
+	if e.Value == "" {
+		return fmt.Sprintf("%s is required (a term of the %s vocabulary)", e.Field, e.Vocabulary)
+	}
+	return fmt.Sprintf("%s: %s is not a term of the %s vocabulary", e.Field, e.Value, e.Vocabulary)
+}
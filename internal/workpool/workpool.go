@@ -0,0 +1,146 @@
+// Package workpool runs bulk operations, such as imports and migrations, on
+// a bounded number of goroutines. Each item gets its own context, item
+// starts can be rate limited to spare the storage backend, and failures are
+// collected rather than stopping the run.
+package workpool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the number of items processed at once when
+// Options.Workers is not set
+const DefaultWorkers = 8
+
+// maxReportedErrors bounds the failures listed in an Errors message
+const maxReportedErrors = 10
+
+// Options controls a pool
+type Options struct {
+	// Workers bounds the items processed at once
+	Workers int
+	// Rate bounds the items started per second; 0 means no limit
+	Rate float64
+	// ItemTimeout bounds the context of each item; 0 means no limit
+	ItemTimeout time.Duration
+}
+
+// ItemError is the failure of one item
+type ItemError struct {
+	Item string
+	Err  error
+}
+
+func (e *ItemError) Error() string {
+	return e.Item + ": " + e.Err.Error()
+}
+
+func (e *ItemError) Unwrap() error {
+	return e.Err
+}
+
+// Errors collects the failures of a run, ordered by item
+type Errors []*ItemError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, maxReportedErrors)
+	for i, err := range e {
+		if i == maxReportedErrors {
+			msgs = append(msgs, fmt.Sprintf("and %d more", len(e)-i))
+			break
+		}
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d of the items failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Pool runs items on a bounded number of goroutines. A pool is used for
+// one run: items are added with Go and the run ends with Wait.
+type Pool struct {
+	ctx     context.Context
+	timeout time.Duration
+	slots   chan struct{}
+	ticker  *time.Ticker
+	wg      sync.WaitGroup
+
+	mu   sync.Mutex
+	errs Errors
+}
+
+// New returns a pool whose items run with contexts derived from ctx
+func New(ctx context.Context, opts Options) *Pool {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	p := &Pool{ctx: ctx, timeout: opts.ItemTimeout, slots: make(chan struct{}, workers)}
+	if opts.Rate > 0 {
+		p.ticker = time.NewTicker(time.Duration(float64(time.Second) / opts.Rate))
+	}
+	return p
+}
+
+// Go runs fn for item once a worker is free and the rate allows. It waits
+// until then, and returns the context's error without running fn if the
+// pool's context ends first.
+func (p *Pool) Go(item string, fn func(ctx context.Context) error) error {
+	if p.ticker != nil {
+		select {
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		case <-p.ticker.C:
+		}
+	}
+	select {
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case p.slots <- struct{}{}:
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer func() {
+			<-p.slots
+			p.wg.Done()
+		}()
+		ctx := p.ctx
+		if p.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, p.timeout)
+			defer cancel()
+		}
+		if err := fn(ctx); err != nil {
+			p.mu.Lock()
+			p.errs = append(p.errs, &ItemError{Item: item, Err: err})
+			p.mu.Unlock()
+		}
+	}()
+	return nil
+}
+
+// Wait waits for the items started and returns their failures as Errors,
+// or nil if all succeeded
+func (p *Pool) Wait() error {
+	p.wg.Wait()
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+	if len(p.errs) == 0 {
+		return nil
+	}
+	sort.Slice(p.errs, func(i, j int) bool { return p.errs[i].Item < p.errs[j].Item })
+	return p.errs
+}
@@ -0,0 +1,92 @@
+package workpool_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/workpool"
+)
+
+func TestPool(t *testing.T) {
+	pool := workpool.New(context.Background(), workpool.Options{Workers: 3})
+
+	var running, peak, done atomic.Int32
+	for i := range 20 {
+		err := pool.Go(fmt.Sprintf("item-%02d", i), func(ctx context.Context) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+			if i%7 == 3 {
+				return errors.New("rejected")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := pool.Wait()
+	if done.Load() != 20 {
+		t.Errorf("expected all 20 items to run, got %d", done.Load())
+	}
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 items at once, got %d", peak.Load())
+	}
+	var errs workpool.Errors
+	if !errors.As(err, &errs) || len(errs) != 3 || errs[0].Item != "item-03" || errs[2].Item != "item-17" {
+		t.Fatalf("expected items 3, 10 and 17 to fail, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "3 of the items failed: item-03: rejected;") {
+		t.Errorf("unexpected message %q", err)
+	}
+}
+
+func TestPoolRateAndTimeout(t *testing.T) {
+	pool := workpool.New(context.Background(), workpool.Options{Rate: 100, ItemTimeout: 10 * time.Millisecond})
+
+	start := time.Now()
+	for i := range 5 {
+		pool.Go(fmt.Sprint(i), func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}
+	err := pool.Wait()
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("expected 5 items at 100/s to take 50ms, took %s", elapsed)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the items to time out, got %v", err)
+	}
+}
+
+func TestPoolCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := workpool.New(ctx, workpool.Options{Workers: 1})
+	release := make(chan struct{})
+	pool.Go("busy", func(context.Context) error {
+		<-release
+		return nil
+	})
+	cancel()
+	if err := pool.Go("waiting", func(context.Context) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Go to give up once cancelled, got %v", err)
+	}
+	close(release)
+	if err := pool.Wait(); err != nil {
+		t.Errorf("expected the started item to succeed, got %v", err)
+	}
+}
@@ -1,28 +1,75 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"flag"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/leifj/go-raid/internal/config"
-	"github.com/leifj/go-raid/internal/handlers"
 	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/upgrade"
 
-	// Import storage implementations to register factories
-	_ "github.com/leifj/go-raid/internal/storage/cockroach"
-	_ "github.com/leifj/go-raid/internal/storage/fdb"
-	_ "github.com/leifj/go-raid/internal/storage/file"
+	// Also registers the storage backends used by the subcommands
+	"github.com/leifj/go-raid/pkg/server"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "migrate-storage":
+			os.Exit(runMigrateStorage(os.Args[2:]))
+		case "verify-replica":
+			os.Exit(runVerifyReplica(os.Args[2:]))
+		case "convert-encoding":
+			os.Exit(runConvertEncoding(os.Args[2:]))
+		case "seed":
+			os.Exit(runSeed(os.Args[2:]))
+		}
+	}
+
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	dev := flag.Bool("dev", false, "zero-config development mode: temporary seeded storage, a printed operator token, verbose logging and CORS for localhost")
+	flag.Parse()
+
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadFile(*configFile)
 	if err != nil {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	var opts []server.Option
+	var devToken string
+	cleanup := func() {}
+	if *dev {
+		devToken, cleanup, err = setupDev(cfg)
+		if err != nil {
+			log.Fatalf("Failed to set up dev mode: %v", err)
+		}
+		defer cleanup()
+		opts = devOptions()
+	}
+	log.Printf("Effective configuration:\n%s", cfg.Summary())
+
+	up, err := upgrade.New(cfg.Server.ReusePort)
+	if err != nil {
+		log.Fatalf("Failed to take over listeners: %v", err)
+	}
+	opts = append(opts, server.WithListener(up.Listen), server.OnStart(func(context.Context) error { return up.Ready() }))
+	if up.Upgrading() && (cfg.Storage.Type == storage.StorageTypeFile || cfg.Storage.Type == storage.StorageTypeFileGit) {
+		// The replaced instance holds the data directory until it hands it
+		// over, rejecting writes until this one is ready; it takes the
+		// directory back if this one exits first
+		if l := cfg.Storage.File.Lock; l == "" || l == "exclusive" {
+			cfg.Storage.File.Lock = "wait"
+		}
+		if err := up.Handover(); err != nil {
+			log.Fatalf("Failed to ask for the data directory: %v", err)
+		}
+	}
 
 	// Initialize storage
 	repo, err := storage.NewRepository(&cfg.Storage)
@@ -38,61 +85,68 @@ func main() {
 		log.Printf("Storage (%s) initialized successfully", cfg.Storage.Type)
 	}
 
-	// Create router
-	r := chi.NewRouter()
-
-	// Add middleware
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
-	r.Use(middleware.RequestID)
-
-	// Initialize handlers with storage
-	raidHandler := handlers.NewRAiDHandler(repo)
-	spHandler := handlers.NewServicePointHandler(repo)
+	if *dev {
+		if err := seedDev(repo); err != nil {
+			log.Printf("Warning: Seeding dev data failed: %v", err)
+		}
+		log.Printf("Dev mode: data in %s is deleted on exit", cfg.Storage.File.DataDir)
+		log.Printf("Dev mode: operator token (valid %s):\n\n  Authorization: Bearer %s\n", devTokenTTL, devToken)
+	}
 
-	// Setup routes
-	setupRoutes(r, raidHandler, spHandler)
+	srv, err := server.New(cfg, repo, opts...)
+	if err != nil {
+		log.Fatalf("Failed to configure server: %v", err)
+	}
 
-	// Start server
-	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
-	log.Printf("Starting go-RAiD server on %s", addr)
-	log.Printf("API endpoints available at http://%s/raid/", addr)
+	// Shut down cleanly on SIGINT/SIGTERM so that storage is closed and the
+	// file backend releases its data directory lease
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *dev {
+		// Dev mode data does not outlive the process
+		log.Printf("Dev mode: upgrades on SIGHUP are disabled")
+	} else {
+		ctx = upgradeOnHangup(ctx, up, repo)
+	}
 
-	if err := http.ListenAndServe(addr, r); err != nil {
-		log.Fatalf("Server failed to start: %v", err)
+	if err := srv.ListenAndServe(ctx); err != nil {
+		log.Printf("Server failed: %v", err)
+		repo.Close()
+		cleanup()
+		os.Exit(1)
 	}
 }
 
-func setupRoutes(r chi.Router, raidHandler *handlers.RAiDHandler, spHandler *handlers.ServicePointHandler) {
-	// Health check
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(`{"status":"ok"}`))
-	})
-
-	// RAiD endpoints
-	r.Route("/raid", func(r chi.Router) {
-		r.Post("/", raidHandler.MintRAiD)
-		r.Get("/", raidHandler.FindAllRAiDs)
-		r.Get("/all-public", raidHandler.FindAllPublicRAiDs)
-
-		r.Route("/{prefix}/{suffix}", func(r chi.Router) {
-			r.Get("/", raidHandler.FindRAiDByName)
-			r.Put("/", raidHandler.UpdateRAiD)
-			r.Patch("/", raidHandler.PatchRAiD)
-			r.Get("/history", raidHandler.RAiDHistory)
-			r.Get("/{version}", raidHandler.FindRAiDByNameAndVersion)
-		})
-	})
-
-	// Service Point endpoints
-	r.Route("/service-point", func(r chi.Router) {
-		r.Post("/", spHandler.CreateServicePoint)
-		r.Get("/", spHandler.FindAllServicePoints)
-
-		r.Route("/{id}", func(r chi.Router) {
-			r.Get("/", spHandler.FindServicePointByID)
-			r.Put("/", spHandler.UpdateServicePoint)
-		})
-	})
+// upgradeOnHangup replaces the binary in place on SIGHUP: the new binary
+// takes over the listeners and, once it is ready, the returned context is
+// done and the server shuts down gracefully. The lock of repo, if it holds
+// one, is handed over when the new binary asks for it. If the upgrade
+// fails, the server goes on serving.
+func upgradeOnHangup(ctx context.Context, up *upgrade.Upgrader, repo storage.Repository) context.Context {
+	var handover upgrade.Handover
+	if holder, ok := storage.Source(repo).(storage.LockHolder); ok {
+		handover = holder
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+			}
+			log.Printf("Upgrading: starting the new binary")
+			if err := up.Upgrade(handover); err != nil {
+				log.Printf("Upgrade failed, still serving: %v", err)
+				continue
+			}
+			log.Printf("Upgrade: the new binary is ready, shutting down")
+			cancel()
+			return
+		}
+	}()
+	return ctx
 }
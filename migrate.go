@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/migrate"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/workpool"
+)
+
+// runMigrateStorage implements "raid-server migrate-storage": it copies all
+// data from the configured backend to the backend described by -to, logging
+// progress to stderr and printing a JSON summary to stdout
+func runMigrateStorage(args []string) int {
+	fs := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "source configuration file (environment overrides apply)")
+	targetFile := fs.String("to", "", "target configuration file (environment overrides do not apply)")
+	resume := fs.Bool("resume", false, "continue an interrupted migration into a non-empty target")
+	verify := fs.Bool("verify", true, "compare source and target checksums after copying")
+	every := fs.Int("progress", 1000, "log progress every N RAiDs")
+	workers := fs.Int("workers", workpool.DefaultWorkers, "RAiDs imported into the target at once")
+	rate := fs.Float64("rate", 0, "RAiDs imported per second at most (0 for no limit)")
+	fs.Parse(args)
+
+	if *targetFile == "" {
+		log.Printf("migrate-storage: -to is required")
+		fs.Usage()
+		return 2
+	}
+
+	srcCfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Printf("Failed to load source configuration: %v", err)
+		return 2
+	}
+	dstCfg, err := config.LoadFileOnly(*targetFile)
+	if err != nil {
+		log.Printf("Failed to load target configuration: %v", err)
+		return 2
+	}
+
+	src, err := storage.NewRepository(&srcCfg.Storage)
+	if err != nil {
+		log.Printf("Failed to initialize source storage: %v", err)
+		return 2
+	}
+	defer src.Close()
+
+	dst, err := storage.NewRepository(&dstCfg.Storage)
+	if err != nil {
+		log.Printf("Failed to initialize target storage: %v", err)
+		return 2
+	}
+	defer dst.Close()
+
+	log.Printf("Migrating %s storage to %s storage", srcCfg.Storage.Type, dstCfg.Storage.Type)
+
+	summary, err := migrate.Run(context.Background(), src, dst, migrate.Options{
+		Resume:        *resume,
+		Verify:        *verify,
+		ProgressEvery: *every,
+		Workers:       *workers,
+		Rate:          *rate,
+		Progress: func(phase string, s migrate.Summary) {
+			log.Printf("%s: %d service points, %d RAiDs (%d versions), %d skipped, %d verified",
+				phase, s.ServicePoints, s.RAiDs, s.Versions, s.Skipped, s.Verified)
+		},
+	})
+	if summary != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(summary)
+	}
+	if err != nil {
+		log.Printf("Migration failed: %v", err)
+		if !*resume {
+			log.Printf("Re-run with -resume to continue into the partially migrated target")
+		}
+		return 1
+	}
+
+	log.Printf("Migration complete")
+	return 0
+}
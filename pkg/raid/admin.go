@@ -0,0 +1,60 @@
+package raid
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// MaintenanceStatus reports whether the server is in read-only mode
+type MaintenanceStatus struct {
+	Enabled    bool      `json:"enabled"`
+	Reason     string    `json:"reason,omitempty"`
+	RetryAfter int       `json:"retryAfter"`
+	Since      time.Time `json:"since,omitempty"`
+}
+
+// StorageStatus is the storage backend diagnostics reported by the server
+type StorageStatus struct {
+	Type    string `json:"type"`
+	Healthy bool   `json:"healthy"`
+	Error   string `json:"error,omitempty"`
+	// HealthCheckLatency is the duration of the backend health check, as
+	// formatted by time.Duration
+	HealthCheckLatency string `json:"healthCheckLatency"`
+}
+
+// Health checks that the server is up
+func (c *Client) Health(ctx context.Context) error {
+	var out struct {
+		Status string `json:"status"`
+	}
+	if err := c.doRoot(ctx, http.MethodGet, "/health", nil, nil, &out); err != nil {
+		return err
+	}
+	if out.Status != "ok" {
+		return fmt.Errorf("server reported status %q", out.Status)
+	}
+	return nil
+}
+
+// Maintenance fetches the read-only mode status. It requires the operator
+// role.
+func (c *Client) Maintenance(ctx context.Context) (*MaintenanceStatus, error) {
+	var out MaintenanceStatus
+	if err := c.doRoot(ctx, http.MethodGet, "/admin/maintenance", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StorageStatus runs the server's storage health check. It requires the
+// operator role.
+func (c *Client) StorageStatus(ctx context.Context) (*StorageStatus, error) {
+	var out StorageStatus
+	if err := c.doRoot(ctx, http.MethodGet, "/debug/storage", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,285 @@
+// Package raid is a Go client for the go-RAiD HTTP API.
+//
+// A Client is safe for concurrent use. Every call takes a context for
+// cancellation and deadlines; requests that fail with a network error or a
+// retryable status (429, 502, 503, 504) are retried with exponential backoff
+// according to the client's RetryPolicy, honouring Retry-After.
+//
+//	client := raid.NewClient("https://raid.example.org", raid.WithToken(token))
+//	r, err := client.GetRAiD(ctx, "10.82481", "1234567890")
+//	if raid.IsNotFound(err) {
+//		...
+//	}
+package raid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/models"
+)
+
+// Client calls the go-RAiD API
+type Client struct {
+	baseURL   string
+	http      *http.Client
+	auth      Authenticator
+	retry     RetryPolicy
+	userAgent string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client used for requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithTimeout sets the timeout of the default HTTP client for each attempt
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.http = &http.Client{Timeout: d} }
+}
+
+// WithAuth sets how requests are authenticated
+func WithAuth(a Authenticator) Option {
+	return func(c *Client) { c.auth = a }
+}
+
+// WithToken authenticates requests with a bearer token
+func WithToken(token string) Option {
+	return WithAuth(BearerToken(token))
+}
+
+// WithAPIKey sends an API key in the X-API-Key header
+func WithAPIKey(key string) Option {
+	return WithAuth(APIKey(key))
+}
+
+// WithRetry sets the retry policy; RetryPolicy{} disables retries
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// NewClient creates a client for the server at baseURL, the root the
+// versioned API and the admin endpoints are served under
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		http:      &http.Client{Timeout: 30 * time.Second},
+		retry:     DefaultRetryPolicy,
+		userAgent: "go-raid-client",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RetryPolicy controls retries of failed requests. MaxAttempts includes the
+// first attempt; values below 2 disable retries.
+type RetryPolicy struct {
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, MinBackoff: 200 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// backoff returns the delay before the given retry (1-based) with jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.MinBackoff << (retry - 1)
+	if d <= 0 || (p.MaxBackoff > 0 && d > p.MaxBackoff) {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// Error is a non-2xx API response
+type Error struct {
+	StatusCode int
+	Message    string
+	// Failures lists the rules a rejected RAiD breaks
+	Failures []ValidationFailure
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server returned %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("server returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// IsNotFound reports whether err is a 404 response
+func IsNotFound(err error) bool {
+	return statusCode(err) == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 response, e.g. for a RAiD that
+// already exists or an update made to a version other than the current one
+func IsConflict(err error) bool {
+	return statusCode(err) == http.StatusConflict
+}
+
+func statusCode(err error) int {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+// retryableStatus reports whether a response status may succeed on retry.
+// 429 and 503 reject a request before it is processed, so they are retried
+// for all methods; 502 and 504 only for idempotent ones.
+func retryableStatus(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != http.MethodPost
+	}
+	return false
+}
+
+// APIVersion is the path prefix of the API version the client speaks
+const APIVersion = "/v2"
+
+// do sends a request to path under APIVersion
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	return c.doRoot(ctx, method, APIVersion+path, query, in, out)
+}
+
+// doRoot sends a request to path on the server, retrying per the policy,
+// and decodes a JSON response into out when out is not nil
+func (c *Client) doRoot(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, method, u, body)
+		retry := attempt < c.retry.MaxAttempts
+		var wait time.Duration
+
+		if err != nil {
+			// Non-idempotent requests may have been processed already
+			if !retry || ctx.Err() != nil || method == http.MethodPost {
+				return err
+			}
+		} else {
+			if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+				return decode(resp, out)
+			}
+			apiErr := readError(resp)
+			if !retry || !retryableStatus(method, resp.StatusCode) {
+				return apiErr
+			}
+			if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+				wait = time.Duration(s) * time.Second
+			}
+		}
+
+		if wait == 0 {
+			wait = c.retry.backoff(attempt)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, u string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	// Lists are decoded as bare arrays, whatever the server's default
+	req.Header.Set("Accept", fmt.Sprintf(`application/json; profile="%s"`, models.BareProfile))
+	req.Header.Set("User-Agent", c.userAgent)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.auth != nil {
+		if err := c.auth.Authenticate(req); err != nil {
+			return nil, err
+		}
+	}
+	return c.http.Do(req)
+}
+
+func decode(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// readError builds an *Error from a response body, which is either plain
+// text, a JSON object with an "error" or "message" field or an
+// ErrorResponse listing validation failures
+func readError(resp *http.Response) *Error {
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+
+	apiErr := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	var body struct {
+		Error    string              `json:"error"`
+		Message  string              `json:"message"`
+		Failures []ValidationFailure `json:"failures"`
+	}
+	if json.Unmarshal(data, &body) == nil {
+		if body.Error != "" {
+			apiErr.Message = body.Error
+		} else if body.Message != "" {
+			apiErr.Message = body.Message
+		} else if len(body.Failures) > 0 {
+			msgs := make([]string, len(body.Failures))
+			for i, f := range body.Failures {
+				msgs[i] = f.FieldID + ": " + f.Message
+			}
+			apiErr.Message = strings.Join(msgs, "; ")
+		}
+		apiErr.Failures = body.Failures
+	}
+	return apiErr
+}
@@ -0,0 +1,142 @@
+package raid
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/middleware"
+)
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func TestClient_RetriesUnavailable(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(RAiD{Identifier: &Identifier{ID: "https://raid.org/10.99999/abc", Version: 2}})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithRetry(fastRetry))
+	r, err := c.GetRAiD(context.Background(), "10.99999", "abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Identifier.Version != 2 || calls != 3 {
+		t.Errorf("expected version 2 after 3 calls, got %d after %d", r.Identifier.Version, calls)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.Method {
+		case http.MethodPost:
+			http.Error(w, "RAiD already exists", http.StatusConflict)
+		case http.MethodPut:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":400,"failures":[{"fieldId":"contributor","errorType":"notSet","message":"no leader"}]}`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":"RAiD not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithRetry(fastRetry))
+	_, err := c.GetRAiD(context.Background(), "10.99999", "missing")
+	if !IsNotFound(err) || err.(*Error).Message != "RAiD not found" {
+		t.Errorf("expected not found error, got %v", err)
+	}
+	if _, err := c.MintRAiD(context.Background(), &RAiD{}); !IsConflict(err) {
+		t.Errorf("expected conflict, got %v", err)
+	}
+	_, err = c.UpdateRAiD(context.Background(), "10.99999", "x", &RAiD{})
+	if apiErr, ok := err.(*Error); !ok || len(apiErr.Failures) != 1 || apiErr.Message != "contributor: no leader" {
+		t.Errorf("expected validation failures, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expected client errors not to be retried, got %d calls", calls)
+	}
+}
+
+func TestClient_RAiDsPaginates(t *testing.T) {
+	const total = 7
+	var pages int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&pages, 1)
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		if r.URL.Query().Get("contributor.id") != "orcid" {
+			t.Errorf("filter not passed: %s", r.URL.RawQuery)
+		}
+		page := []RAiD{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			page = append(page, RAiD{Identifier: &Identifier{ID: fmt.Sprintf("https://raid.org/10.99999/%d", i)}})
+		}
+		json.NewEncoder(w).Encode(page)
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL)
+	var ids []string
+	for r, err := range c.RAiDs(context.Background(), ListOptions{ContributorID: "orcid", Limit: 3}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, r.Identifier.ID)
+	}
+	if len(ids) != total || ids[6] != "https://raid.org/10.99999/6" || pages != 3 {
+		t.Errorf("expected %d RAiDs in 3 pages, got %d in %d: %v", total, len(ids), pages, ids)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	token, err := NewToken([]byte("s3cret"), TokenOptions{UserID: "alice", Roles: []string{middleware.RoleOperator}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var user, apiKey string
+	auth := middleware.JWTAuth(&config.AuthConfig{Enabled: true, JWTSecret: "s3cret"})
+	srv := httptest.NewServer(auth(middleware.RequireRole(middleware.RoleOperator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _ = middleware.GetUserID(r.Context())
+		apiKey = r.Header.Get("X-API-Key")
+		w.Write([]byte("[]"))
+	}))))
+	defer srv.Close()
+
+	c := NewClient(srv.URL, WithAuth(MultiAuth(BearerToken(token), APIKey("key"))))
+	if _, err := c.ListServicePoints(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if user != "alice" || apiKey != "key" {
+		t.Errorf("expected alice with API key, got %q and %q", user, apiKey)
+	}
+}
+
+func TestParseHandle(t *testing.T) {
+	for _, s := range []string{"10.99999/abc", "https://raid.org/10.99999/abc", "/10.99999/abc/"} {
+		prefix, suffix, err := ParseHandle(s)
+		if err != nil || prefix != "10.99999" || suffix != "abc" {
+			t.Errorf("ParseHandle(%q) = %q, %q, %v", s, prefix, suffix, err)
+		}
+	}
+	if _, _, err := ParseHandle("abc"); err == nil {
+		t.Errorf("expected error for a handle without prefix")
+	}
+}
@@ -0,0 +1,69 @@
+package raid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func draftPath(id string, rest ...string) string {
+	p := "/drafts/" + url.PathEscape(id)
+	for _, r := range rest {
+		p += "/" + r
+	}
+	return p
+}
+
+// ListDrafts fetches the drafts of the user's service point
+func (c *Client) ListDrafts(ctx context.Context) ([]*Draft, error) {
+	var out []*Draft
+	if err := c.do(ctx, http.MethodGet, "/drafts", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateDraft stages raid as a draft of the service point named by its
+// identifier owner, or else the user's. Drafts are not validated and take
+// no identifier until they are promoted.
+func (c *Client) CreateDraft(ctx context.Context, raid *RAiD) (*Draft, error) {
+	var out Draft
+	if err := c.do(ctx, http.MethodPost, "/drafts", nil, raid, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDraft fetches a draft
+func (c *Client) GetDraft(ctx context.Context, id string) (*Draft, error) {
+	var out Draft
+	if err := c.do(ctx, http.MethodGet, draftPath(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateDraft replaces the RAiD of a draft
+func (c *Client) UpdateDraft(ctx context.Context, id string, raid *RAiD) (*Draft, error) {
+	var out Draft
+	if err := c.do(ctx, http.MethodPut, draftPath(id), nil, raid, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteDraft discards a draft
+func (c *Client) DeleteDraft(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, draftPath(id), nil, nil, nil)
+}
+
+// PromoteDraft mints a draft, validating it as MintRAiD does, and returns the
+// minted RAiD. The draft is discarded once minted, and kept if minting
+// fails.
+func (c *Client) PromoteDraft(ctx context.Context, id string) (*RAiD, error) {
+	var out RAiD
+	if err := c.do(ctx, http.MethodPost, draftPath(id, "promote"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,103 @@
+package raid
+
+import "github.com/leifj/go-raid/internal/models"
+
+// The API types are aliases of the server's models so that the client and
+// server always agree on the wire format.
+type (
+	RAiD                     = models.RAiD
+	Metadata                 = models.Metadata
+	Identifier               = models.Identifier
+	RegistrationAgency       = models.RegistrationAgency
+	Owner                    = models.Owner
+	Title                    = models.Title
+	Date                     = models.Date
+	Description              = models.Description
+	Access                   = models.Access
+	AccessStatement          = models.AccessStatement
+	Contributor              = models.Contributor
+	ContributorPosition      = models.ContributorPosition
+	Organisation             = models.Organisation
+	OrganisationRole         = models.OrganisationRole
+	AlternateURL             = models.AlternateURL
+	Subject                  = models.Subject
+	SubjectKeyword           = models.SubjectKeyword
+	RelatedRAiD              = models.RelatedRAiD
+	RelatedObject            = models.RelatedObject
+	AlternateIdentifier      = models.AlternateIdentifier
+	SpatialCoverage          = models.SpatialCoverage
+	SpatialCoveragePlace     = models.SpatialCoveragePlace
+	TraditionalKnowledge     = models.TraditionalKnowledge
+	Language                 = models.Language
+	IDSchema                 = models.IDSchema
+	ServicePoint             = models.ServicePoint
+	PrefixPool               = models.PrefixPool
+	ServicePointDeactivation = models.ServicePointDeactivation
+	MintDefaults             = models.MintDefaults
+	NotificationSettings     = models.NotificationSettings
+	RAiDChange               = models.RAiDChange
+	AccessChange             = models.AccessChange
+	Citation                 = models.Citation
+	Completeness             = models.Completeness
+	ContributorRAiDs         = models.ContributorRAiDs
+	ContributorRAiD          = models.ContributorRAiD
+	OrganisationRAiDs        = models.OrganisationRAiDs
+	OrganisationRAiD         = models.OrganisationRAiD
+	Page                     = models.Page
+	SearchResult             = models.SearchResult
+	Aggregate                = models.Aggregate
+	AggregateGroup           = models.AggregateGroup
+	FacetCount               = models.FacetCount
+	Draft                    = models.Draft
+	Reservation              = models.Reservation
+	ScheduledChange          = models.ScheduledChange
+	SavedSearch              = models.SavedSearch
+	Extensions               = models.Extensions
+	Deprecation              = models.Deprecation
+	ErrorResponse            = models.ErrorResponse
+	Envelope                 = models.Envelope
+	EnvelopeMeta             = models.EnvelopeMeta
+	ValidationFailure        = models.ValidationFailure
+)
+
+// Profiles of list responses
+const (
+	EnvelopeProfile = models.EnvelopeProfile
+	BareProfile     = models.BareProfile
+)
+
+// Related RAiD type vocabulary identifiers
+const (
+	RelatedRAiDTypeSchema        = models.RelatedRAiDTypeSchema
+	RelatedRAiDTypeContinues     = models.RelatedRAiDTypeContinues
+	RelatedRAiDTypeHasPart       = models.RelatedRAiDTypeHasPart
+	RelatedRAiDTypeIsContinuedBy = models.RelatedRAiDTypeIsContinuedBy
+	RelatedRAiDTypeIsDerivedFrom = models.RelatedRAiDTypeIsDerivedFrom
+	RelatedRAiDTypeHasDerivation = models.RelatedRAiDTypeHasDerivation
+	RelatedRAiDTypeIsPartOf      = models.RelatedRAiDTypeIsPartOf
+	RelatedRAiDTypeIsObsoletedBy = models.RelatedRAiDTypeIsObsoletedBy
+	RelatedRAiDTypeObsoletes     = models.RelatedRAiDTypeObsoletes
+)
+
+// Statuses of scheduled changes
+const (
+	SchedulePending = models.SchedulePending
+	ScheduleFailed  = models.ScheduleFailed
+)
+
+// Facets of RAiD searches
+const (
+	FacetSubject      = models.FacetSubject
+	FacetOrganisation = models.FacetOrganisation
+	FacetAccessType   = models.FacetAccessType
+	FacetStartYear    = models.FacetStartYear
+	FacetServicePoint = models.FacetServicePoint
+)
+
+// Groupings and metrics of RAiD aggregates
+const (
+	GroupBySubject      = models.GroupBySubject
+	GroupByOrganisation = models.GroupByOrganisation
+	GroupByYear         = models.GroupByYear
+	MetricCount         = models.MetricCount
+)
@@ -0,0 +1,432 @@
+package raid
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leifj/go-raid/internal/identifier"
+)
+
+// ParseHandle splits a RAiD given as PREFIX/SUFFIX, as doi:PREFIX/SUFFIX or
+// as its identifier URL under any base URL (https://raid.org/PREFIX/SUFFIX)
+func ParseHandle(s string) (prefix, suffix string, err error) {
+	return identifier.Parse(s)
+}
+
+// raidPath returns the API path for a RAiD
+func raidPath(prefix, suffix string, rest ...string) string {
+	p := "/raid/" + url.PathEscape(prefix) + "/" + url.PathEscape(suffix)
+	for _, r := range rest {
+		p += "/" + url.PathEscape(r)
+	}
+	return p
+}
+
+// ListOptions filters RAiD listings
+type ListOptions struct {
+	ContributorID  string
+	OrganisationID string
+	// TraditionalKnowledgeLabelID selects RAiDs with this TK or BC label
+	TraditionalKnowledgeLabelID string
+	// HasTraditionalKnowledge selects RAiDs with (true) or without (false)
+	// TK and BC labels
+	HasTraditionalKnowledge *bool
+	// SubjectID selects RAiDs with this subject or a narrower one
+	SubjectID      string
+	SubjectKeyword string
+	// Text selects RAiDs with a title or subject keyword containing it,
+	// ignoring case and diacritics
+	Text string
+	// MaxCompleteness selects RAiDs with a completeness score of at most
+	// *MaxCompleteness
+	MaxCompleteness *int
+	// Tags selects the RAiDs the service point gave every tag;
+	// ListServicePointRAiDs only, for members of the service point
+	Tags   []string
+	Limit  int
+	Offset int
+	// Cursor continues the listing after the last RAiD of a previous page,
+	// as returned by NextCursor; ListRAiDs and ListPublicRAiDs only
+	Cursor string
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if o.ContributorID != "" {
+		q.Set("contributor.id", o.ContributorID)
+	}
+	if o.OrganisationID != "" {
+		q.Set("organisation.id", o.OrganisationID)
+	}
+	if o.TraditionalKnowledgeLabelID != "" {
+		q.Set("traditionalKnowledgeLabel.id", o.TraditionalKnowledgeLabelID)
+	}
+	if o.HasTraditionalKnowledge != nil {
+		q.Set("traditionalKnowledgeLabel", strconv.FormatBool(*o.HasTraditionalKnowledge))
+	}
+	if o.SubjectID != "" {
+		q.Set("subject.id", o.SubjectID)
+	}
+	if o.SubjectKeyword != "" {
+		q.Set("subject.keyword", o.SubjectKeyword)
+	}
+	if o.Text != "" {
+		q.Set("q", o.Text)
+	}
+	if o.MaxCompleteness != nil {
+		q.Set("completeness.max", strconv.Itoa(*o.MaxCompleteness))
+	}
+	for _, tag := range o.Tags {
+		q.Add("tag", tag)
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		q.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	return q
+}
+
+// NextCursor returns the ListOptions.Cursor continuing after a page of
+// RAiDs. Cursors stay stable while RAiDs are minted, unlike offsets.
+func NextCursor(page []*RAiD) string {
+	return identifier.NextCursor(page)
+}
+
+// MintRAiD mints a new RAiD
+func (c *Client) MintRAiD(ctx context.Context, r *RAiD) (*RAiD, error) {
+	var out RAiD
+	if err := c.do(ctx, http.MethodPost, "/raid/", nil, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRAiD fetches the current version of a RAiD
+func (c *Client) GetRAiD(ctx context.Context, prefix, suffix string) (*RAiD, error) {
+	var out RAiD
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRAiDVersion fetches a specific version of a RAiD
+func (c *Client) GetRAiDVersion(ctx context.Context, prefix, suffix string, version int) (*RAiD, error) {
+	var out RAiD
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, strconv.Itoa(version)), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRAiDAsOf fetches the version of a RAiD that was current at t
+func (c *Client) GetRAiDAsOf(ctx context.Context, prefix, suffix string, t time.Time) (*RAiD, error) {
+	var out RAiD
+	q := url.Values{"asOf": {t.UTC().Format(time.RFC3339Nano)}}
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateRAiD replaces a RAiD, creating a new version
+func (c *Client) UpdateRAiD(ctx context.Context, prefix, suffix string, r *RAiD) (*RAiD, error) {
+	var out RAiD
+	if err := c.do(ctx, http.MethodPut, raidPath(prefix, suffix), nil, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteRAiD deletes a RAiD
+func (c *Client) DeleteRAiD(ctx context.Context, prefix, suffix string) error {
+	return c.do(ctx, http.MethodDelete, raidPath(prefix, suffix), nil, nil, nil)
+}
+
+// DeprecateRAiD marks a RAiD as deprecated, superseded by the RAiD with
+// identifier supersededBy if it is not empty. Fetching a deprecated RAiD
+// then fails, or follows the redirect to its successor.
+func (c *Client) DeprecateRAiD(ctx context.Context, prefix, suffix, supersededBy, reason string) (*RAiD, error) {
+	in := map[string]string{"supersededBy": supersededBy, "reason": reason}
+	var out RAiD
+	if err := c.do(ctx, http.MethodPost, raidPath(prefix, suffix, "deprecate"), nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Split selects the titles, contributors and organisations, by index, that
+// SplitRAiD copies into the new RAiD. ID optionally names the new RAiD.
+type Split struct {
+	Title        []int  `json:"title"`
+	Contributor  []int  `json:"contributor,omitempty"`
+	Organisation []int  `json:"organisation,omitempty"`
+	ID           string `json:"id,omitempty"`
+}
+
+// SplitRAiD mints a new RAiD from parts of an existing one, linking the two
+// with IsDerivedFrom/HasDerivation relations. It returns the new version of
+// the original and the derived RAiD.
+func (c *Client) SplitRAiD(ctx context.Context, prefix, suffix string, split Split) (original, derived *RAiD, err error) {
+	var out struct {
+		Original *RAiD `json:"original"`
+		Derived  *RAiD `json:"derived"`
+	}
+	if err := c.do(ctx, http.MethodPost, raidPath(prefix, suffix, "split"), nil, split, &out); err != nil {
+		return nil, nil, err
+	}
+	return out.Original, out.Derived, nil
+}
+
+// RAiDHistory fetches the changes made by each version of a RAiD. Each
+// change carries a base64 encoded JSON Patch (RFC 6902) document; use
+// GetRAiDVersion to fetch a complete version.
+func (c *Client) RAiDHistory(ctx context.Context, prefix, suffix string) ([]*RAiDChange, error) {
+	var out []*RAiDChange
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, "history"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HistoryOptions selects a page of the history of a RAiD
+type HistoryOptions struct {
+	// Limit bounds the number of versions returned, at most 1000
+	Limit int
+	// After continues after this version, the last of a previous page
+	After int
+	// Summary leaves out the patches, keeping versions, timestamps and
+	// actors
+	Summary bool
+}
+
+// RAiDHistoryPage fetches the changes made by a page of the versions of a
+// RAiD, oldest first. Continue with After set to the last version returned
+// until a page has fewer than Limit changes.
+func (c *Client) RAiDHistoryPage(ctx context.Context, prefix, suffix string, opts HistoryOptions) ([]*RAiDChange, error) {
+	q := url.Values{}
+	if opts.Limit > 0 {
+		q.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.After > 0 {
+		q.Set("cursor", strconv.Itoa(opts.After))
+	}
+	if opts.Summary {
+		q.Set("summary", "true")
+	}
+	var out []*RAiDChange
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, "history"), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FindByRelatedObject fetches the RAiDs linking the output with a DOI
+func (c *Client) FindByRelatedObject(ctx context.Context, doi string) ([]*RAiD, error) {
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, "/raid/find", url.Values{"relatedObject": {doi}}, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetRAiDs fetches several RAiDs at once, in the order of ids, which are
+// RAiD handles or URLs. RAiDs the server does not hold are left out.
+func (c *Client) GetRAiDs(ctx context.Context, ids ...string) ([]*RAiD, error) {
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, "/raid/batch", url.Values{"id": ids}, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Citation fetches the citation of one version of a RAiD
+func (c *Client) Citation(ctx context.Context, prefix, suffix string, version int) (*Citation, error) {
+	var out Citation
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, strconv.Itoa(version), "citation"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Completeness fetches the completeness score of a RAiD
+func (c *Client) Completeness(ctx context.Context, prefix, suffix string) (*Completeness, error) {
+	var out Completeness
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, "completeness"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AccessHistory fetches the access type changes of a RAiD, including
+// embargoes lapsing
+func (c *Client) AccessHistory(ctx context.Context, prefix, suffix string) ([]*AccessChange, error) {
+	var out []*AccessChange
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, "access-history"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ContributorRAiDs fetches one page of the RAiDs a contributor appears in,
+// given their bare ORCID iD. Only Limit and Offset of opts apply.
+func (c *Client) ContributorRAiDs(ctx context.Context, orcid string, opts *ListOptions) (*ContributorRAiDs, error) {
+	var out ContributorRAiDs
+	if err := c.do(ctx, http.MethodGet, "/contributor/"+url.PathEscape(orcid)+"/raids", opts.query(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OrganisationRAiDs fetches one page of the RAiDs an organisation appears
+// in, given its bare ROR ID, grouped by role. A non-empty role, e.g.
+// "funder", limits the listing to that group. Only Limit and Offset of
+// opts apply.
+func (c *Client) OrganisationRAiDs(ctx context.Context, ror, role string, opts *ListOptions) (*OrganisationRAiDs, error) {
+	q := opts.query()
+	if role != "" {
+		q.Set("role", role)
+	}
+	var out OrganisationRAiDs
+	if err := c.do(ctx, http.MethodGet, "/organisation/"+url.PathEscape(ror)+"/raids", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRAiDs fetches one page of RAiDs
+func (c *Client) ListRAiDs(ctx context.Context, opts *ListOptions) ([]*RAiD, error) {
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, "/raid/", opts.query(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListPublicRAiDs fetches one page of public RAiDs. Only Limit, Offset and
+// Cursor apply.
+func (c *Client) ListPublicRAiDs(ctx context.Context, opts *ListOptions) ([]*RAiD, error) {
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, "/raid/all-public", opts.query(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SearchOptions selects the public RAiDs SearchRAiDs finds and the facets
+// it counts them by
+type SearchOptions struct {
+	// ListOptions filters the RAiDs as ListRAiDs does and selects the page
+	// of hits with Limit and Offset
+	ListOptions
+	// ServicePoint, AccessType and StartYear keep the RAiDs with that
+	// facet value, when set
+	ServicePoint int64
+	AccessType   string
+	StartYear    string
+	// Facets lists the facets to count, e.g. FacetSubject; nil counts all
+	Facets []string
+	// FacetLimit is the number of values listed per facet
+	FacetLimit int
+}
+
+func (o *SearchOptions) query() url.Values {
+	if o == nil {
+		return url.Values{}
+	}
+	q := o.ListOptions.query()
+	if o.ServicePoint != 0 {
+		q.Set("servicePoint", strconv.FormatInt(o.ServicePoint, 10))
+	}
+	if o.AccessType != "" {
+		q.Set("accessType", o.AccessType)
+	}
+	if o.StartYear != "" {
+		q.Set("startYear", o.StartYear)
+	}
+	if o.Facets != nil {
+		q.Set("facets", strings.Join(o.Facets, ","))
+	}
+	if o.FacetLimit > 0 {
+		q.Set("facet.limit", strconv.Itoa(o.FacetLimit))
+	}
+	return q
+}
+
+// SearchRAiDs fetches one page of the public RAiDs matching opts, with the
+// number found and their counts by facet
+func (c *Client) SearchRAiDs(ctx context.Context, opts *SearchOptions) (*SearchResult, error) {
+	var out SearchResult
+	if err := c.do(ctx, http.MethodGet, "/raid/search", opts.query(), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Aggregate counts the RAiDs matching opts by groupBy, e.g. GroupBySubject.
+// The page of opts does not apply.
+func (c *Client) Aggregate(ctx context.Context, groupBy string, opts *ListOptions) (*Aggregate, error) {
+	q := opts.query()
+	q.Del("limit")
+	q.Del("offset")
+	q.Del("cursor")
+	q.Set("groupBy", groupBy)
+	var out Aggregate
+	if err := c.do(ctx, http.MethodGet, "/raid/aggregate", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DefaultPageSize is the page size used by the iterators when
+// ListOptions.Limit is not set
+const DefaultPageSize = 100
+
+// RAiDs iterates over all RAiDs matching opts, fetching pages of opts.Limit
+// (DefaultPageSize if unset) starting at opts.Offset. Iteration stops at the
+// first error, which is yielded with a nil RAiD.
+func (c *Client) RAiDs(ctx context.Context, opts ListOptions) iter.Seq2[*RAiD, error] {
+	return c.paginate(ctx, opts, c.ListRAiDs)
+}
+
+// PublicRAiDs iterates over all public RAiDs like RAiDs
+func (c *Client) PublicRAiDs(ctx context.Context, opts ListOptions) iter.Seq2[*RAiD, error] {
+	return c.paginate(ctx, opts, c.ListPublicRAiDs)
+}
+
+func (c *Client) paginate(ctx context.Context, opts ListOptions, list func(context.Context, *ListOptions) ([]*RAiD, error)) iter.Seq2[*RAiD, error] {
+	return func(yield func(*RAiD, error) bool) {
+		if opts.Limit <= 0 {
+			opts.Limit = DefaultPageSize
+		}
+		for {
+			page, err := list(ctx, &opts)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, r := range page {
+				if !yield(r, nil) {
+					return
+				}
+			}
+			if len(page) < opts.Limit {
+				return
+			}
+			opts.Offset += len(page)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package raid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+func reservationPath(prefix, suffix string, rest ...string) string {
+	p := "/raid/reserve/" + url.PathEscape(prefix) + "/" + url.PathEscape(suffix)
+	for _, r := range rest {
+		p += "/" + r
+	}
+	return p
+}
+
+// ReserveIdentifier reserves an identifier for servicePoint, or the
+// user's if zero, without minting a RAiD. A mint supplying the reserved
+// identifier as identifier.id claims it until the reservation expires.
+func (c *Client) ReserveIdentifier(ctx context.Context, servicePoint int64) (*Reservation, error) {
+	in := struct {
+		ServicePoint int64 `json:"servicePoint,omitempty"`
+	}{servicePoint}
+	var out Reservation
+	if err := c.do(ctx, http.MethodPost, "/raid/reserve", nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetReservation fetches the live reservation of a handle
+func (c *Client) GetReservation(ctx context.Context, prefix, suffix string) (*Reservation, error) {
+	var out Reservation
+	if err := c.do(ctx, http.MethodGet, reservationPath(prefix, suffix), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReleaseReservation gives a reserved identifier up before it expires
+func (c *Client) ReleaseReservation(ctx context.Context, prefix, suffix string) error {
+	return c.do(ctx, http.MethodDelete, reservationPath(prefix, suffix), nil, nil, nil)
+}
+
+// MintReservation mints r under a reserved identifier, for the service
+// point holding it, and releases the reservation
+func (c *Client) MintReservation(ctx context.Context, prefix, suffix string, r *RAiD) (*RAiD, error) {
+	var out RAiD
+	if err := c.do(ctx, http.MethodPost, reservationPath(prefix, suffix, "mint"), nil, r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
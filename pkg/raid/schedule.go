@@ -0,0 +1,58 @@
+package raid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+func effectiveAtQuery(at time.Time) url.Values {
+	return url.Values{"effectiveAt": {at.UTC().Format(time.RFC3339)}}
+}
+
+// ScheduleMint mints r when at comes. Until then nothing of it is public.
+// The schema is checked now and the rest when the mint is applied; a mint
+// that fails then is kept with status ScheduleFailed.
+func (c *Client) ScheduleMint(ctx context.Context, r *RAiD, at time.Time) (*ScheduledChange, error) {
+	var out ScheduledChange
+	if err := c.do(ctx, http.MethodPost, "/raid/", effectiveAtQuery(at), r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ScheduleUpdate replaces a RAiD with r when at comes. The current version
+// stays public until then.
+func (c *Client) ScheduleUpdate(ctx context.Context, prefix, suffix string, r *RAiD, at time.Time) (*ScheduledChange, error) {
+	var out ScheduledChange
+	if err := c.do(ctx, http.MethodPut, raidPath(prefix, suffix), effectiveAtQuery(at), r, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListScheduledChanges fetches the scheduled changes of the user's
+// service point
+func (c *Client) ListScheduledChanges(ctx context.Context) ([]*ScheduledChange, error) {
+	var out []*ScheduledChange
+	if err := c.do(ctx, http.MethodGet, "/scheduled", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetScheduledChange fetches a scheduled change. Applied changes are gone.
+func (c *Client) GetScheduledChange(ctx context.Context, id string) (*ScheduledChange, error) {
+	var out ScheduledChange
+	if err := c.do(ctx, http.MethodGet, "/scheduled/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelScheduledChange withdraws a change before it is applied, or removes
+// one that failed
+func (c *Client) CancelScheduledChange(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/scheduled/"+url.PathEscape(id), nil, nil, nil)
+}
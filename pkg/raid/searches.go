@@ -0,0 +1,94 @@
+package raid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Scopes of saved searches
+const (
+	// SearchScopeUser holds the searches of the authenticated user
+	SearchScopeUser = "user"
+	// SearchScopeServicePoint holds the searches shared within the
+	// user's service point, which its admins change
+	SearchScopeServicePoint = "service-point"
+)
+
+func searchPath(name string, rest ...string) string {
+	p := "/searches/" + url.PathEscape(name)
+	for _, r := range rest {
+		p += "/" + r
+	}
+	return p
+}
+
+func scopeQuery(scope string) url.Values {
+	q := url.Values{}
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+	return q
+}
+
+// ListSearches fetches the saved searches of the user and of their
+// service point
+func (c *Client) ListSearches(ctx context.Context) ([]*SavedSearch, error) {
+	var out []*SavedSearch
+	if err := c.do(ctx, http.MethodGet, "/searches", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SaveSearch saves the RAiD listing query, the query parameters GET /raid/
+// takes, under name in scope, SearchScopeUser if empty. A public search
+// is published as a feed of the public RAiDs it finds, named by the Feed
+// of the search returned; see Feed.
+func (c *Client) SaveSearch(ctx context.Context, scope, name string, query map[string]string, public bool) (*SavedSearch, error) {
+	in := map[string]any{"query": query, "public": public}
+	var out SavedSearch
+	if err := c.do(ctx, http.MethodPut, searchPath(name), scopeQuery(scope), in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSearch fetches a saved search. Without a scope the user's own search
+// is preferred to their service point's.
+func (c *Client) GetSearch(ctx context.Context, scope, name string) (*SavedSearch, error) {
+	var out SavedSearch
+	if err := c.do(ctx, http.MethodGet, searchPath(name), scopeQuery(scope), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSearch removes a saved search in scope, SearchScopeUser if empty
+func (c *Client) DeleteSearch(ctx context.Context, scope, name string) error {
+	return c.do(ctx, http.MethodDelete, searchPath(name), scopeQuery(scope), nil, nil)
+}
+
+// SearchResults runs a saved search, fetching one page of the RAiDs it
+// finds. The filters in opts refine the saved ones.
+func (c *Client) SearchResults(ctx context.Context, scope, name string, opts *ListOptions) ([]*RAiD, error) {
+	q := opts.query()
+	if scope != "" {
+		q.Set("scope", scope)
+	}
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, searchPath(name, "results"), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Feed fetches one page of the public RAiDs found by the public search
+// published under feed, which needs no authentication
+func (c *Client) Feed(ctx context.Context, feed string, opts *ListOptions) ([]*RAiD, error) {
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, "/feeds/"+url.PathEscape(feed), opts.query(), nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
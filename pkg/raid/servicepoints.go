@@ -0,0 +1,99 @@
+package raid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+func servicePointPath(id int64) string {
+	return "/service-point/" + strconv.FormatInt(id, 10)
+}
+
+// ListServicePoints fetches all service points
+func (c *Client) ListServicePoints(ctx context.Context) ([]*ServicePoint, error) {
+	var out []*ServicePoint
+	if err := c.do(ctx, http.MethodGet, "/service-point/", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetServicePoint fetches a service point
+func (c *Client) GetServicePoint(ctx context.Context, id int64) (*ServicePoint, error) {
+	var out ServicePoint
+	if err := c.do(ctx, http.MethodGet, servicePointPath(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateServicePoint creates a service point
+func (c *Client) CreateServicePoint(ctx context.Context, sp *ServicePoint) (*ServicePoint, error) {
+	var out ServicePoint
+	if err := c.do(ctx, http.MethodPost, "/service-point/", nil, sp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateServicePoint replaces a service point
+func (c *Client) UpdateServicePoint(ctx context.Context, id int64, sp *ServicePoint) (*ServicePoint, error) {
+	var out ServicePoint
+	if err := c.do(ctx, http.MethodPut, servicePointPath(id), nil, sp, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeactivateServicePoint soft deletes a service point, which then mints no
+// more RAiDs. RAiDs it owns are kept read-only if readOnly is set or moved
+// to the service point transferTo if it is not 0; the server refuses the
+// deactivation of a service point owning RAiDs without either. Only
+// operators may deactivate service points.
+func (c *Client) DeactivateServicePoint(ctx context.Context, id int64, reason string, readOnly bool, transferTo int64) (*ServicePoint, error) {
+	q := url.Values{}
+	if reason != "" {
+		q.Set("reason", reason)
+	}
+	if readOnly {
+		q.Set("raids", "read-only")
+	}
+	if transferTo != 0 {
+		q.Set("transferTo", strconv.FormatInt(transferTo, 10))
+	}
+	var out ServicePoint
+	if err := c.do(ctx, http.MethodDelete, servicePointPath(id), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteServicePoint removes a service point outright, after moving the
+// RAiDs it owns to the service point transferTo if it is not 0; otherwise
+// they are left owned by a service point that no longer exists. Only
+// operators may delete service points.
+func (c *Client) DeleteServicePoint(ctx context.Context, id, transferTo int64) error {
+	q := url.Values{"force": {"true"}}
+	if transferTo != 0 {
+		q.Set("transferTo", strconv.FormatInt(transferTo, 10))
+	}
+	return c.do(ctx, http.MethodDelete, servicePointPath(id), q, nil, nil)
+}
+
+// ListServicePointRAiDs fetches one page of the RAiDs owned by a service
+// point, ordered by sort (created, updated or title, descending with a
+// leading "-") unless it is empty. Clients authenticated as an admin of the
+// service point also get embargoed and deleted RAiDs.
+func (c *Client) ListServicePointRAiDs(ctx context.Context, id int64, sort string, opts *ListOptions) ([]*RAiD, error) {
+	q := opts.query()
+	if sort != "" {
+		q.Set("sort", sort)
+	}
+	var out []*RAiD
+	if err := c.do(ctx, http.MethodGet, servicePointPath(id)+"/raids", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
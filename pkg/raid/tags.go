@@ -0,0 +1,40 @@
+package raid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type tags struct {
+	Tags []string `json:"tags"`
+}
+
+// GetTags fetches the tags the service point owning a RAiD gave it. Only
+// members of that service point and operators see them.
+func (c *Client) GetTags(ctx context.Context, prefix, suffix string) ([]string, error) {
+	var out tags
+	if err := c.do(ctx, http.MethodGet, raidPath(prefix, suffix, "tags"), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Tags, nil
+}
+
+// AddTags tags a RAiD for the service point owning it and returns all its
+// tags. Tags are kept in lower case.
+func (c *Client) AddTags(ctx context.Context, prefix, suffix string, add ...string) ([]string, error) {
+	var out tags
+	if err := c.do(ctx, http.MethodPost, raidPath(prefix, suffix, "tags"), nil, tags{Tags: add}, &out); err != nil {
+		return nil, err
+	}
+	return out.Tags, nil
+}
+
+// RemoveTag removes a tag from a RAiD and returns the rest
+func (c *Client) RemoveTag(ctx context.Context, prefix, suffix, tag string) ([]string, error) {
+	var out tags
+	if err := c.do(ctx, http.MethodDelete, raidPath(prefix, suffix, "tags")+"/"+url.PathEscape(tag), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Tags, nil
+}
@@ -0,0 +1,350 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/leifj/go-raid/internal/api"
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/handlers"
+	raidmw "github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/resilience"
+	"github.com/leifj/go-raid/internal/signing"
+)
+
+func setupRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, validator *api.Validator, signer *signing.Signer, raidHandler *handlers.RAiDHandler, spHandler *handlers.ServicePointHandler, graphqlHandler *handlers.GraphQLHandler) {
+	// Health check
+	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"status":"ok"}`))
+	})
+
+	// The OpenAPI document the API implements
+	r.Get("/openapi.yaml", api.ServeSpec)
+
+	// RAiD and service point endpoints, routed by the code generated from
+	// the OpenAPI spec
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		// Around the rate limits, timeouts and validation of each route,
+		// so that it signs what clients receive
+		r.Use(signer.Middleware)
+		// Callers may authenticate, so that writes record who made them
+		// and operators can do what others cannot
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+
+		opts := api.ChiServerOptions{
+			BaseRouter:  r,
+			Middlewares: []api.MiddlewareFunc{byMethod(budgets.read, budgets.write)},
+		}
+		if validator != nil {
+			// Innermost, so that rejected requests count against the
+			// rate limits
+			opts.Middlewares = append([]api.MiddlewareFunc{validator.Middleware}, opts.Middlewares...)
+			opts.ErrorHandlerFunc = validator.ParamError
+		}
+		api.HandlerWithOptions(api.NewServer(raidHandler, spHandler), opts)
+
+		// Not part of the RAiD API
+		r.With(budgets.write...).Delete("/raid/{prefix}/{suffix}", raidHandler.DeleteRAiD)
+		r.With(budgets.write...).With(raidmw.JWTAuth(authCfg), raidmw.RequireRole(raidmw.RoleOperator)).Delete("/service-point/{id}", spHandler.DeleteServicePoint)
+		r.With(budgets.write...).Post("/raid/{prefix}/{suffix}/deprecate", raidHandler.DeprecateRAiD)
+		r.With(budgets.write...).Post("/raid/{prefix}/{suffix}/split", raidHandler.SplitRAiD)
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/access-history", raidHandler.AccessHistory)
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/timegate", raidHandler.TimeGate)
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/timemap", raidHandler.TimeMap)
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/{version}/citation", raidHandler.Citation)
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/completeness", raidHandler.Completeness)
+		r.With(budgets.read...).Get("/raid/find", raidHandler.FindRAiDsByRelatedObject)
+		r.With(budgets.read...).Get("/raid/feed.atom", raidHandler.AtomFeed)
+		r.With(budgets.read...).Get("/raid/feed.rss", raidHandler.RSSFeed)
+		r.With(budgets.read...).Get("/raid/search", raidHandler.SearchRAiDs)
+		r.With(budgets.read...).Get("/raid/aggregate", raidHandler.AggregateRAiDs)
+		r.With(budgets.read...).Get("/raid/batch", raidHandler.GetRAiDBatch)
+		r.With(budgets.read...).Get("/contributor/{orcid}/raids", raidHandler.ContributorRAiDs)
+		r.With(budgets.read...).Get("/organisation/{ror}/raids", raidHandler.OrganisationRAiDs)
+		// Admins of the service point see more
+		r.With(budgets.read...).Get("/service-point/{id}/raids", spHandler.FindServicePointRAiDs)
+	})
+
+	// Handle-style resolution at the root, e.g. /10.82841/abc, so the server
+	// can sit behind a resolver host name without path rewriting. Only
+	// DOI-style prefixes match, leaving other top-level paths alone.
+	r.With(signer.Middleware, raidmw.OptionalJWTAuth(authCfg)).With(budgets.read...).Get("/{prefix:10\\.[^/]+}/{suffix}", raidHandler.FindRAiDByName)
+
+	// GraphQL queries only read, so POST is allowed in read-only mode and
+	// counts against the read budget
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+		r.Use(budgets.read...)
+
+		r.Get("/graphql", graphqlHandler.Query)
+		r.Post("/graphql", graphqlHandler.Query)
+		r.Get("/graphql/schema", graphqlHandler.Schema)
+	})
+}
+
+// setupInvitationRoutes mounts contributor invitations. Invitees follow
+// the signed link they were given, which authorizes the request.
+func setupInvitationRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, invitationHandler *handlers.InvitationHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+
+		r.With(budgets.write...).Post("/raid/{prefix}/{suffix}/invitations", invitationHandler.Invite)
+		r.With(budgets.read...).Get("/invitations/{token}", invitationHandler.GetInvitation)
+		r.With(budgets.write...).Post("/invitations/{token}", invitationHandler.RespondToInvitation)
+	})
+}
+
+// setupSearchRoutes mounts saved searches, which belong to the
+// authenticated caller, and the feeds of public ones, which need no
+// authentication
+func setupSearchRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, searchHandler *handlers.SearchHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+
+		r.With(budgets.read...).Get("/feeds/{feed}", searchHandler.Feed)
+		r.Group(func(r chi.Router) {
+			r.Use(raidmw.JWTAuth(authCfg))
+			r.With(budgets.read...).Get("/searches", searchHandler.ListSearches)
+			r.With(budgets.read...).Get("/searches/{name}", searchHandler.GetSearch)
+			r.With(budgets.write...).Put("/searches/{name}", searchHandler.SaveSearch)
+			r.With(budgets.write...).Delete("/searches/{name}", searchHandler.DeleteSearch)
+			r.With(budgets.read...).Get("/searches/{name}/results", searchHandler.SearchResults)
+		})
+	})
+}
+
+// setupTagRoutes mounts the tags members of a service point give the RAiDs
+// it owns
+func setupTagRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, tagHandler *handlers.TagHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.JWTAuth(authCfg))
+
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/tags", tagHandler.GetTags)
+		r.With(budgets.write...).Post("/raid/{prefix}/{suffix}/tags", tagHandler.AddTags)
+		r.With(budgets.write...).Delete("/raid/{prefix}/{suffix}/tags/{tag}", tagHandler.RemoveTag)
+	})
+}
+
+// setupNotifyRoutes mounts the COAR Notify inbox, which takes notifications
+// without authentication, and the proposals they are held as, which the
+// service points owning the RAiDs accept or reject
+func setupNotifyRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, notifyHandler *handlers.NotifyHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+
+		r.With(budgets.write...).Post("/inbox", notifyHandler.Inbox)
+		r.With(budgets.read...).Get("/inbox", notifyHandler.ListInbox)
+		r.Group(func(r chi.Router) {
+			r.Use(raidmw.JWTAuth(authCfg))
+			r.With(budgets.read...).Get("/proposals", notifyHandler.ListProposals)
+			r.With(budgets.read...).Get("/proposals/{id}", notifyHandler.GetProposal)
+			r.With(budgets.write...).Post("/proposals/{id}/accept", notifyHandler.AcceptProposal)
+			r.With(budgets.write...).Delete("/proposals/{id}", notifyHandler.RejectProposal)
+		})
+	})
+}
+
+// setupActivityRoutes mounts the registry's ActivityPub actor, its outbox
+// and followers, which need no authentication, and its inbox, which takes
+// requests signed by other servers
+func setupActivityRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, activityHandler *handlers.ActivityHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+
+		r.With(budgets.read...).Get("/activitypub/actor", activityHandler.Actor)
+		r.With(budgets.read...).Get("/activitypub/outbox", activityHandler.Outbox)
+		r.With(budgets.read...).Get("/activitypub/followers", activityHandler.Followers)
+		r.With(budgets.write...).Post("/activitypub/inbox", activityHandler.Inbox)
+	})
+}
+
+// setupAttachmentRoutes mounts the documents attached to RAiDs; callers
+// may authenticate to read the attachments of RAiDs that are not open.
+// Uploads are limited by the attachment size limit, with room for the
+// rest of the form, rather than the request body limit.
+func setupAttachmentRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, attachmentCfg *config.AttachmentConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, attachmentHandler *handlers.AttachmentHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.OptionalJWTAuth(authCfg))
+
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/attachments", attachmentHandler.ListAttachments)
+		r.With(budgets.read...).Get("/raid/{prefix}/{suffix}/attachments/{hash}", attachmentHandler.GetAttachment)
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(attachmentCfg.MaxBytes + 1<<20))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.JWTAuth(authCfg))
+
+		r.With(budgets.write...).Post("/raid/{prefix}/{suffix}/attachments", attachmentHandler.UploadAttachment)
+	})
+}
+
+// routeBudgets are the per-route rate limits and handler timeouts, built
+// once and shared by every route group; reads and writes have separate
+// budgets. Groups authenticate callers before the budgets, so that tokens
+// are limited apart from the addresses they are sent from.
+type routeBudgets struct {
+	read, write chi.Middlewares
+}
+
+func newRouteBudgets(serverCfg *config.ServerConfig, rateCfg *config.RateLimitConfig, limiter *raidmw.RateLimiter) routeBudgets {
+	return routeBudgets{
+		read: chi.Middlewares{
+			limiter.Limit("read", perMinute(rateCfg.ReadPerMinute, rateCfg.ReadBurst)),
+			raidmw.Timeout(serverCfg.ReadRouteTimeout),
+		},
+		write: chi.Middlewares{
+			limiter.Limit("write", perMinute(rateCfg.WritePerMinute, rateCfg.WriteBurst)),
+			raidmw.Timeout(serverCfg.WriteRouteTimeout),
+		},
+	}
+}
+
+// byMethod applies the read middlewares to GET and HEAD requests and the
+// write middlewares to everything else
+func byMethod(read, write chi.Middlewares) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		readHandler, writeHandler := read.Handler(next), write.Handler(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				readHandler.ServeHTTP(w, r)
+				return
+			}
+			writeHandler.ServeHTTP(w, r)
+		})
+	}
+}
+
+func perMinute(limit, burst int) raidmw.Rate {
+	return raidmw.Rate{Limit: limit, Period: time.Minute, Burst: burst}
+}
+
+// setupAdminRoutes mounts the operator-only admin API under /admin. Admin
+// routes stay writable in read-only mode so maintenance can be switched off.
+// Backup and restore stream archives and are exempt from body and time limits.
+func setupAdminRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, rateCfg *config.RateLimitConfig, limiter *raidmw.RateLimiter, adminHandler *handlers.AdminHandler) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(raidmw.JWTAuth(authCfg))
+		r.Use(raidmw.RequireRole(raidmw.RoleOperator))
+		r.Use(limiter.Limit("admin", perMinute(rateCfg.AdminPerMinute, rateCfg.AdminBurst)))
+
+		r.Get("/maintenance", adminHandler.GetMaintenance)
+		r.With(raidmw.MaxBodySize(serverCfg.MaxBodyBytes)).Put("/maintenance", adminHandler.SetMaintenance)
+
+		r.Get("/backup", adminHandler.Backup)
+		r.Post("/restore", adminHandler.Restore)
+		r.Get("/backup/status", adminHandler.BackupStatus)
+		r.Post("/backup/run", adminHandler.RunBackup)
+
+		r.Get("/compaction/status", adminHandler.CompactionStatus)
+		r.Post("/compaction/run", adminHandler.RunCompaction)
+		r.Post("/raids/{prefix}/{suffix}/rehydrate", adminHandler.RehydrateRAiD)
+		r.Get("/raids/{prefix}/{suffix}/verify", adminHandler.VerifyChain)
+		r.Post("/anchors/run", adminHandler.RunAnchor)
+
+		r.Get("/raids/deleted", adminHandler.ListDeletedRAiDs)
+		r.With(raidmw.MaxBodySize(serverCfg.MaxBodyBytes)).Post("/raids/{prefix}/{suffix}/purge", adminHandler.PurgeRAiD)
+		r.Get("/purges", adminHandler.ListPurges)
+		r.Get("/retention/status", adminHandler.RetentionStatus)
+		r.Post("/retention/run", adminHandler.RunRetention)
+		r.With(raidmw.MaxBodySize(serverCfg.MaxBodyBytes)).Post("/redactions", adminHandler.Redact)
+		r.Get("/redactions", adminHandler.ListRedactions)
+
+		r.Get("/stats", adminHandler.Stats)
+		r.Get("/stats/minting", adminHandler.MintingActivity)
+		r.Get("/verify", adminHandler.Verify)
+		r.Post("/verify", adminHandler.Verify)
+	})
+}
+
+// setupDebugRoutes mounts pprof, expvar and storage diagnostics under /debug,
+// restricted to authenticated operators
+func setupDebugRoutes(r chi.Router, authCfg *config.AuthConfig, debugHandler *handlers.DebugHandler) {
+	r.Route("/debug", func(r chi.Router) {
+		r.Use(raidmw.JWTAuth(authCfg))
+		r.Use(raidmw.RequireRole(raidmw.RoleOperator))
+
+		r.Get("/storage", debugHandler.StorageStats)
+		r.Mount("/", middleware.Profiler())
+	})
+}
+
+// setupDraftRoutes configures the routes of draft RAiDs, which all need
+// authentication
+func setupDraftRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, draftHandler *handlers.DraftHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.JWTAuth(authCfg))
+
+		r.With(budgets.read...).Get("/drafts", draftHandler.ListDrafts)
+		r.With(budgets.write...).Post("/drafts", draftHandler.CreateDraft)
+		r.With(budgets.read...).Get("/drafts/{id}", draftHandler.GetDraft)
+		r.With(budgets.write...).Put("/drafts/{id}", draftHandler.UpdateDraft)
+		r.With(budgets.write...).Delete("/drafts/{id}", draftHandler.DeleteDraft)
+		r.With(budgets.write...).Post("/drafts/{id}/promote", draftHandler.PromoteDraft)
+	})
+}
+
+// setupReservationRoutes configures the routes of identifier reservations,
+// which all need authentication
+func setupReservationRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, reservationHandler *handlers.ReservationHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.JWTAuth(authCfg))
+
+		r.With(budgets.write...).Post("/raid/reserve", reservationHandler.ReserveIdentifier)
+		r.With(budgets.read...).Get("/raid/reserve/{prefix}/{suffix}", reservationHandler.GetReservation)
+		r.With(budgets.write...).Delete("/raid/reserve/{prefix}/{suffix}", reservationHandler.ReleaseReservation)
+		r.With(budgets.write...).Post("/raid/reserve/{prefix}/{suffix}/mint", reservationHandler.MintReservation)
+	})
+}
+
+// setupScheduleRoutes configures the routes of changes scheduled to take
+// effect later, which all need authentication
+func setupScheduleRoutes(r chi.Router, serverCfg *config.ServerConfig, authCfg *config.AuthConfig, maintenance *raidmw.Maintenance, breaker *resilience.Breaker, budgets routeBudgets, scheduleHandler *handlers.ScheduleHandler) {
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.MaxBodySize(serverCfg.MaxBodyBytes))
+		r.Use(maintenance.ReadOnly)
+		r.Use(breaker.FailFast)
+		r.Use(raidmw.JWTAuth(authCfg))
+
+		r.With(budgets.read...).Get("/scheduled", scheduleHandler.ListScheduledChanges)
+		r.With(budgets.read...).Get("/scheduled/{id}", scheduleHandler.GetScheduledChange)
+		r.With(budgets.write...).Delete("/scheduled/{id}", scheduleHandler.CancelScheduledChange)
+	})
+}
@@ -0,0 +1,1015 @@
+// Package server embeds go-RAiD in another Go program.
+//
+// A Server is an http.Handler serving the complete API - RAiD and service
+// point routes and GraphQL under /v2, admin and debug endpoints - for a
+// configuration and storage backend supplied by the caller. Background work
+// such as scheduled backups and version compaction runs between Start and
+// Shutdown, and callers can hook their own setup and teardown into that
+// lifecycle:
+//
+//	cfg, err := server.LoadConfig("raid.yaml")
+//	repo, err := server.NewRepository(cfg)
+//	defer repo.Close()
+//	srv, err := server.New(cfg, repo, server.OnShutdown(flushMetrics))
+//	if err := srv.Start(ctx); err != nil {
+//		...
+//	}
+//	defer srv.Shutdown(context.Background())
+//	mux.Handle("/raid-api/", http.StripPrefix("/raid-api", srv))
+//
+// Institution-specific policies hook into RAiD writes with BeforeMint,
+// AfterMint, BeforeUpdate and AfterDelete; before hooks may modify the RAiD
+// or reject the request with Veto.
+//
+// The caller owns the repository; Shutdown does not close it.
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/leifj/go-raid/internal/access"
+	"github.com/leifj/go-raid/internal/accesslog"
+	"github.com/leifj/go-raid/internal/activitypub"
+	"github.com/leifj/go-raid/internal/agency"
+	"github.com/leifj/go-raid/internal/api"
+	"github.com/leifj/go-raid/internal/attachment"
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/cache"
+	"github.com/leifj/go-raid/internal/chain"
+	"github.com/leifj/go-raid/internal/checkdigit"
+	"github.com/leifj/go-raid/internal/coarnotify"
+	"github.com/leifj/go-raid/internal/compaction"
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/contributor"
+	"github.com/leifj/go-raid/internal/deactivation"
+	"github.com/leifj/go-raid/internal/defaults"
+	"github.com/leifj/go-raid/internal/faults"
+	"github.com/leifj/go-raid/internal/handlers"
+	"github.com/leifj/go-raid/internal/health"
+	"github.com/leifj/go-raid/internal/hooks"
+	"github.com/leifj/go-raid/internal/i18n"
+	"github.com/leifj/go-raid/internal/identifier"
+	"github.com/leifj/go-raid/internal/invitation"
+	"github.com/leifj/go-raid/internal/language"
+	raidmw "github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/notify"
+	"github.com/leifj/go-raid/internal/policy"
+	"github.com/leifj/go-raid/internal/prefix"
+	"github.com/leifj/go-raid/internal/publication"
+	"github.com/leifj/go-raid/internal/quota"
+	"github.com/leifj/go-raid/internal/relation"
+	"github.com/leifj/go-raid/internal/reservation"
+	"github.com/leifj/go-raid/internal/resilience"
+	"github.com/leifj/go-raid/internal/retention"
+	"github.com/leifj/go-raid/internal/signing"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/internal/textnorm"
+	"github.com/leifj/go-raid/internal/validation"
+	"github.com/leifj/go-raid/internal/vocabulary"
+
+	// Import storage implementations to register factories
+	_ "github.com/leifj/go-raid/internal/storage/cockroach"
+	_ "github.com/leifj/go-raid/internal/storage/fdb"
+	_ "github.com/leifj/go-raid/internal/storage/file"
+)
+
+// shutdownTimeout bounds the graceful shutdown in ListenAndServe
+const shutdownTimeout = 30 * time.Second
+
+// apiVersion is the path prefix of the current API version; the API is also
+// served, deprecated, without it
+const apiVersion = "/v2"
+
+type (
+	// Config is the server configuration
+	Config = config.Config
+	// Repository is a storage backend
+	Repository = storage.Repository
+	// Agency is a registration agency hosted by the server
+	Agency = agency.Agency
+)
+
+// DefaultConfig returns the built-in configuration defaults
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// LoadConfig loads a YAML or TOML configuration file, applies environment
+// variable overrides and validates the result. An empty path uses defaults
+// plus environment variables only.
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadFile(path)
+}
+
+// NewRepository opens the storage backend described by cfg
+func NewRepository(cfg *Config) (Repository, error) {
+	return storage.NewRepository(&cfg.Storage)
+}
+
+// Hook is called when the server starts or shuts down
+type Hook func(ctx context.Context) error
+
+// Option configures a Server
+type Option func(*Server)
+
+// OnStart adds a hook run by Start after background jobs have started.
+// Hooks run in the order they were added; the first error aborts Start.
+func OnStart(h Hook) Option {
+	return func(s *Server) { s.onStart = append(s.onStart, h) }
+}
+
+// OnShutdown adds a hook run by Shutdown after background jobs have
+// stopped. Hooks run in reverse order and all of them run even if some fail.
+func OnShutdown(h Hook) Option {
+	return func(s *Server) { s.onShutdown = append(s.onShutdown, h) }
+}
+
+// WithListener makes ListenAndServe open its listeners with listen rather
+// than net.Listen, e.g. to take them over from the process it replaces
+func WithListener(listen func(network, address string) (net.Listener, error)) Option {
+	return func(s *Server) { s.listen = listen }
+}
+
+// WithMiddleware adds middleware that runs for every request, after the
+// built-in logging, recovery, request ID and access log middleware
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(s *Server) { s.middleware = append(s.middleware, mw...) }
+}
+
+type (
+	// RAiDHook is called with the request context and a RAiD
+	RAiDHook = hooks.Hook
+	// UpdateHook is called with the request context, the stored RAiD and
+	// its replacement
+	UpdateHook = hooks.UpdateHook
+)
+
+// Veto returns an error that a before hook uses to reject an operation. The
+// client receives 422 Unprocessable Entity with the formatted reason.
+func Veto(format string, args ...interface{}) error {
+	return hooks.Veto(format, args...)
+}
+
+// BeforeMint adds a hook run before a RAiD is minted. It may modify the
+// RAiD; an error aborts the mint.
+func BeforeMint(h RAiDHook) Option {
+	return func(s *Server) { s.hooks.BeforeMint = append(s.hooks.BeforeMint, h) }
+}
+
+// AfterMint adds a hook run with a newly minted RAiD. Errors are logged.
+func AfterMint(h RAiDHook) Option {
+	return func(s *Server) { s.hooks.AfterMint = append(s.hooks.AfterMint, h) }
+}
+
+// BeforeUpdate adds a hook run before a RAiD is replaced. It may modify the
+// replacement; an error aborts the update.
+func BeforeUpdate(h UpdateHook) Option {
+	return func(s *Server) { s.hooks.BeforeUpdate = append(s.hooks.BeforeUpdate, h) }
+}
+
+// AfterDelete adds a hook run with the last version of a deleted RAiD.
+// Errors are logged.
+func AfterDelete(h RAiDHook) Option {
+	return func(s *Server) { s.hooks.AfterDelete = append(s.hooks.AfterDelete, h) }
+}
+
+// Server is an embeddable go-RAiD instance
+type Server struct {
+	cfg        *Config
+	repo       Repository
+	router     chi.Router
+	scheduler  *backup.Scheduler
+	compactor  *compaction.Compactor
+	retention  *retention.Enforcer
+	anchorer   *chain.Anchorer
+	signer     *signing.Signer
+	publisher  *publication.Scheduler
+	reserved   storage.ReservationStore
+	checks     health.Checker
+	notifier   *notify.Notifier
+	outbox     *coarnotify.Outbox
+	activity   *activitypub.Publisher
+	accessLog  accesslog.Sink
+	middleware []func(http.Handler) http.Handler
+	listen     func(network, address string) (net.Listener, error)
+	hooks      hooks.Hooks
+	onStart    []Hook
+	onShutdown []Hook
+
+	mu      sync.Mutex
+	started bool
+	cancel  context.CancelFunc
+	jobs    sync.WaitGroup
+}
+
+// New creates a server for cfg backed by repo. The returned server handles
+// requests immediately; Start runs its background jobs.
+func New(cfg *Config, repo Repository, opts ...Option) (*Server, error) {
+	s := &Server{cfg: cfg, repo: repo}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if cfg.Languages.Messages != "" {
+		if err := i18n.LoadDir(cfg.Languages.Messages); err != nil {
+			return nil, fmt.Errorf("load message catalogs: %w", err)
+		}
+		log.Printf("API error messages available in %s", strings.Join(i18n.Languages(), ", "))
+	}
+
+	// During a dual-write migration, the stores beyond Repository are
+	// those of the backend migrated from
+	backend := storage.Source(repo)
+
+	accessLog, err := newAccessLogSink(&cfg.AccessLog, backend)
+	if err != nil {
+		return nil, fmt.Errorf("configure access log: %w", err)
+	}
+	s.accessLog = accessLog
+
+	// Archives are signed along with responses; a nil *signing.Signer must
+	// not be passed as a backup.Signer
+	var archives backup.Signer
+	if cfg.Signing.Key != "" {
+		signer, err := signing.New(cfg.Signing.Key, cfg.Signing.KeyID, cfg.Signing.Format)
+		if err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure signing: %w", err)
+		}
+		s.signer, archives = signer, signer
+	}
+
+	scheduler, err := newBackupScheduler(cfg, repo, archives)
+	if err != nil {
+		s.closeAccessLog()
+		return nil, fmt.Errorf("configure scheduled backups: %w", err)
+	}
+	s.scheduler = scheduler
+
+	compactor, err := newCompactor(cfg, repo)
+	if err != nil {
+		s.closeAccessLog()
+		return nil, fmt.Errorf("configure compaction: %w", err)
+	}
+	s.compactor = compactor
+
+	if cfg.Retention.Schedule != "" {
+		enforcer, err := retention.New(repo, cfg.Retention.Schedule, retentionPolicy(cfg.Retention))
+		if err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure retention: %w", err)
+		}
+		s.retention = enforcer
+	}
+
+	if cfg.History.AnchorSchedule != "" {
+		anchorer, err := chain.NewAnchorer(repo, cfg.History.AnchorSchedule, cfg.History.AnchorFile)
+		if err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure history anchors: %w", err)
+		}
+		s.anchorer = anchorer
+	}
+
+	limiter, err := newRateLimiter(&cfg.RateLimit)
+	if err != nil {
+		s.closeAccessLog()
+		return nil, fmt.Errorf("configure rate limiting: %w", err)
+	}
+
+	r := chi.NewRouter()
+	r.Use(middleware.Logger)
+	r.Use(middleware.Recoverer)
+	r.Use(middleware.RequestID)
+	r.Use(raidmw.AccessLog(accessLog))
+	r.Use(s.middleware...)
+	var registry *agency.Registry
+	if len(cfg.Agencies) > 0 {
+		registry = agency.NewRegistry(cfg.Agencies)
+		r.Use(registry.Middleware)
+	}
+	if cfg.Server.ListEnvelope {
+		r.Use(handlers.DefaultEnvelope)
+	}
+
+	maintenance := raidmw.NewMaintenance(cfg.Server.ReadOnly, cfg.Server.ReadOnlyReason, cfg.Server.ReadOnlyRetryAfter)
+	checker, err := vocabulary.NewChecker(cfg.Vocabularies.SubjectSchemes)
+	if err != nil {
+		s.closeAccessLog()
+		return nil, err
+	}
+	// API calls are retried and pass the circuit breaker, with any
+	// injected faults beneath; admin and background jobs use repo directly
+	var breaker *resilience.Breaker
+	if cfg.Resilience.BreakerThreshold > 0 {
+		breaker = resilience.NewBreaker(cfg.Resilience.BreakerThreshold, cfg.Resilience.BreakerOpenTimeout)
+	}
+	apiRepo := repo
+	if f := cfg.Faults; f.Enabled {
+		log.Printf("WARNING: injecting storage faults into API calls: latency=%s jitter=%s errorPercent=%d partialPercent=%d",
+			f.Latency, f.Jitter, f.ErrorPercent, f.PartialPercent)
+		apiRepo = faults.Wrap(repo, faults.Options{
+			Latency:        f.Latency,
+			Jitter:         f.Jitter,
+			ErrorPercent:   f.ErrorPercent,
+			PartialPercent: f.PartialPercent,
+			Operations:     f.Operations,
+		})
+	}
+	resilient := resilience.Wrap(apiRepo, resilience.Policy{
+		MaxRetries:     cfg.Resilience.MaxRetries,
+		InitialBackoff: cfg.Resilience.InitialBackoff,
+		MaxBackoff:     cfg.Resilience.MaxBackoff,
+	}, breaker)
+	// The storage backend is the only dependency the server cannot serve
+	// without; the others degrade it
+	s.checks.Add("storage", true, resilience.Check(resilient, breaker))
+	s.checks.Add("maintenance", false, func(context.Context) health.Result {
+		status := maintenance.Status()
+		if status.Enabled {
+			return health.Result{Status: health.Degraded, Message: "read-only: " + status.Reason, Detail: status}
+		}
+		return health.Result{Status: health.OK}
+	})
+	if cfg.Faults.Enabled {
+		s.checks.Add("faults", false, func(context.Context) health.Result {
+			return health.Result{Status: health.Degraded, Message: "storage fault injection is enabled"}
+		})
+	}
+	r.Get("/readyz", health.Handler(&s.checks))
+	cached, err := newCache(&cfg.Cache, resilient)
+	if err != nil {
+		s.closeAccessLog()
+		return nil, fmt.Errorf("configure cache: %w", err)
+	}
+	// Purges and redactions are made around the cache, which they must
+	// tell of the RAiDs they change
+	invalidator, _ := cached.(cache.RAiDInvalidator)
+	if s.retention != nil && invalidator != nil {
+		s.retention.WithCache(invalidator)
+	}
+
+	alloc := prefix.Allocation{Prefixes: cfg.Identifiers.AllocatedPrefixes, Agencies: map[string][]string{}}
+	for _, a := range cfg.Agencies {
+		if len(a.Prefixes) > 0 {
+			alloc.Agencies[a.ID] = a.Prefixes
+		}
+	}
+	operator := func(ctx context.Context) bool {
+		return raidmw.HasRole(ctx, raidmw.RoleOperator)
+	}
+	raids := quota.Wrap(deactivation.Wrap(prefix.Wrap(validation.Wrap(cached), alloc)), operator)
+	raids = access.Wrap(raids, cfg.Access, operator)
+	raids = textnorm.Wrap(contributor.Wrap(vocabulary.Wrap(raids, checker)))
+	// Inside the generators, so that every identifier they try is checked
+	// against the reservations
+	reservations, _ := backend.(storage.ReservationStore)
+	if reservations != nil {
+		raids = reservation.Wrap(raids, reservations)
+	}
+	if cfg.Languages.Detect {
+		raids = language.Wrap(raids)
+	}
+	if cfg.Identifiers.Suffix == identifier.SuffixULID {
+		// Inside the check digit, which is appended to the ULID
+		raids = identifier.WrapULID(raids)
+	}
+	if cfg.Identifiers.CheckDigit {
+		raids = checkdigit.Wrap(raids)
+	}
+	if cfg.Identifiers.BaseURL != "" {
+		raids = identifier.Wrap(raids, cfg.Identifiers.BaseURL)
+	}
+	if len(cfg.Agencies) > 0 {
+		raids = agency.Wrap(raids)
+	}
+	// Policies see the service point defaults, which are merged outside
+	// the agency, so that a service point's registration agency takes
+	// precedence over its agency's
+	if raids, err = policy.Wrap(raids, cfg.Policies); err != nil {
+		s.closeAccessLog()
+		return nil, fmt.Errorf("configure policies: %w", err)
+	}
+	raids = defaults.Wrap(raids)
+	if cfg.Relations.Reciprocal {
+		// Outside the agency scope, so RAiDs of other agencies are not linked
+		raids = relation.Wrap(raids)
+	}
+	if n := cfg.COARNotify; n.BaseURL != "" {
+		s.outbox = coarnotify.NewOutbox(n.BaseURL, n.Targets, n.Discover)
+		raids = coarnotify.Wrap(raids, s.outbox)
+	}
+	if ap := cfg.ActivityPub; ap.BaseURL != "" {
+		followers, _ := backend.(storage.FollowerStore)
+		if s.activity, err = activitypub.NewPublisher(ap.BaseURL, ap.Username, ap.Key, followers); err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure ActivityPub: %w", err)
+		}
+		if s.activity.Follows() {
+			raids = activitypub.Wrap(raids, s.activity)
+		} else if ap.Key != "" {
+			log.Printf("ActivityPub follows disabled: the %s backend cannot hold followers", cfg.Storage.Type)
+		}
+	}
+	if n := cfg.Notifications; n.SMTPHost != "" {
+		sender := notify.NewSMTPSender(n.SMTPHost, n.SMTPPort, n.Username, n.Password, n.From)
+		if s.notifier, err = notify.New(repo, sender, notify.Config{
+			EmbargoNotice:  n.EmbargoNotice,
+			QuotaThreshold: n.QuotaThreshold,
+			Templates:      n.Templates,
+		}); err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure notifications: %w", err)
+		}
+		raids = notify.Wrap(raids, s.notifier)
+		notifier := s.notifier
+		s.checks.Add("notifications", false, func(context.Context) health.Result {
+			pending, err := notifier.Status()
+			result := health.Result{Status: health.OK, Detail: map[string]int64{"pending": pending}}
+			if err != nil {
+				result.Status, result.Message = health.Degraded, "last send failed: "+err.Error()
+			}
+			return result
+		})
+	}
+	raidHandler := handlers.NewRAiDHandler(hooks.Wrap(raids, &s.hooks), handlers.DocumentLimits{
+		MaxBytes:          cfg.Server.MaxRAiDBytes,
+		MaxRelatedObjects: cfg.Server.MaxRelatedObjects,
+		Strict:            cfg.Server.StrictDecoding,
+	})
+	if a, ok := backend.(storage.Aggregator); ok {
+		raidHandler.WithAggregator(a)
+	}
+	// Scheduled changes are applied through the decorators and hooks, as
+	// the API writes
+	var scheduleHandler *handlers.ScheduleHandler
+	if store, ok := backend.(storage.ScheduleStore); ok {
+		raidHandler.WithSchedules(store)
+		scheduleHandler = handlers.NewScheduleHandler(store)
+		if cfg.Publication.Interval > 0 {
+			s.publisher = publication.New(hooks.Wrap(raids, &s.hooks), store, cfg.Publication.Interval, registry, maintenance)
+		}
+	}
+	spHandler := handlers.NewServicePointHandler(raids)
+	var tagHandler *handlers.TagHandler
+	if store, ok := backend.(storage.TagStore); ok {
+		spHandler.WithTags(store)
+		tagHandler = handlers.NewTagHandler(raids, store)
+	}
+	// Accepted proposals are added through the decorators and hooks, as
+	// the API writes
+	var notifyHandler *handlers.NotifyHandler
+	if store, ok := backend.(storage.ProposalStore); ok && cfg.COARNotify.Inbox {
+		notifyHandler = handlers.NewNotifyHandler(hooks.Wrap(raids, &s.hooks), store)
+	}
+	var attachmentHandler *handlers.AttachmentHandler
+	if a := cfg.Attachments; a.Store != "" {
+		store, err := newAttachmentStore(&a, backend)
+		if err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure attachments: %w", err)
+		}
+		if store != nil {
+			// Attachments are related through the decorators and hooks, as
+			// the API writes
+			attachmentHandler = handlers.NewAttachmentHandler(hooks.Wrap(raids, &s.hooks), store)
+		} else {
+			log.Printf("Attachments disabled: the %s backend cannot hold attachments", cfg.Storage.Type)
+		}
+	}
+	var activityHandler *handlers.ActivityHandler
+	if s.activity != nil {
+		activityHandler = handlers.NewActivityHandler(s.activity, raidHandler)
+	}
+	graphqlHandler := handlers.NewGraphQLHandler(raids)
+	var invitationHandler *handlers.InvitationHandler
+	if secret := cmp.Or(cfg.Invitations.Secret, cfg.Auth.JWTSecret); secret != "" {
+		invitationHandler = handlers.NewInvitationHandler(hooks.Wrap(raids, &s.hooks), invitation.NewSigner(secret, cfg.Invitations.TTL))
+		if s.notifier != nil {
+			invitationHandler.WithNotifier(s.notifier, cfg.Notifications.InvitationURL)
+		}
+	}
+	// Saved searches run as listings through the decorators, and are kept
+	// by backends that support them
+	var searchHandler *handlers.SearchHandler
+	if store, ok := backend.(storage.SearchStore); ok {
+		searchHandler = handlers.NewSearchHandler(store, raidHandler)
+	}
+	// Drafts are promoted by minting them as POST /raid/ does
+	var draftHandler *handlers.DraftHandler
+	if store, ok := backend.(storage.DraftStore); ok {
+		draftHandler = handlers.NewDraftHandler(store, raidHandler)
+	}
+	var reservationHandler *handlers.ReservationHandler
+	if reservations != nil {
+		reservationHandler = handlers.NewReservationHandler(reservations, raidHandler, cfg.Identifiers.ReservationTTL, cfg.Identifiers.BaseURL)
+		s.reserved = reservations
+	}
+	adminHandler := handlers.NewAdminHandler(repo, cfg.Storage.Type, maintenance, scheduler, compactor, s.anchorer, cfg.History.AnchorFile, archives).WithRetention(s.retention).WithCache(invalidator)
+	debugHandler := handlers.NewDebugHandler(repo, cfg.Storage.Type)
+
+	var validator *api.Validator
+	if cfg.Server.ValidateRequests || cfg.Server.ValidateResponses {
+		spec, err := api.GetSpec()
+		if err == nil {
+			validator, err = api.NewValidator(spec)
+		}
+		if err != nil {
+			s.closeAccessLog()
+			return nil, fmt.Errorf("configure OpenAPI validation: %w", err)
+		}
+		validator.Requests = cfg.Server.ValidateRequests
+		validator.Responses = cfg.Server.ValidateResponses
+	}
+
+	budgets := newRouteBudgets(&cfg.Server, &cfg.RateLimit, limiter)
+	versioned := func(r chi.Router) {
+		setupRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, validator, s.signer, raidHandler, spHandler, graphqlHandler)
+		if invitationHandler != nil {
+			setupInvitationRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, invitationHandler)
+		}
+		if searchHandler != nil {
+			setupSearchRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, searchHandler)
+		}
+		if tagHandler != nil {
+			setupTagRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, tagHandler)
+		}
+		if notifyHandler != nil {
+			setupNotifyRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, notifyHandler)
+		}
+		if attachmentHandler != nil {
+			setupAttachmentRoutes(r, &cfg.Server, &cfg.Auth, &cfg.Attachments, maintenance, breaker, budgets, attachmentHandler)
+		}
+		if activityHandler != nil {
+			setupActivityRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, activityHandler)
+		}
+		if draftHandler != nil {
+			setupDraftRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, draftHandler)
+		}
+		if reservationHandler != nil {
+			setupReservationRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, reservationHandler)
+		}
+		if scheduleHandler != nil {
+			setupScheduleRoutes(r, &cfg.Server, &cfg.Auth, maintenance, breaker, budgets, scheduleHandler)
+		}
+	}
+	r.Route(apiVersion, versioned)
+	// The unversioned paths predate /v2 and are kept as an alias of it
+	deprecated, _ := config.ParseDate(cfg.Server.UnversionedDeprecation)
+	sunset, _ := config.ParseDate(cfg.Server.UnversionedSunset)
+	r.Group(func(r chi.Router) {
+		r.Use(raidmw.Deprecation(apiVersion, deprecated, sunset))
+		versioned(r)
+	})
+	if s.signer != nil {
+		r.Get(signing.JWKSPath, s.signer.ServeJWKS)
+	}
+	if activityHandler != nil {
+		r.Get("/.well-known/webfinger", activityHandler.WebFinger)
+	}
+	setupAdminRoutes(r, &cfg.Server, &cfg.Auth, &cfg.RateLimit, limiter, adminHandler)
+	setupDebugRoutes(r, &cfg.Auth, debugHandler)
+	s.router = r
+
+	if cfg.Server.ReadOnly {
+		log.Printf("Read-only mode enabled: write requests will be rejected")
+	}
+	return s, nil
+}
+
+// ServeHTTP serves the go-RAiD API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// Start runs the server's background jobs and then its start hooks. Jobs
+// run until Shutdown, not until ctx is done; ctx is passed to the hooks.
+func (s *Server) Start(ctx context.Context) error {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return errors.New("server already started")
+	}
+	s.started = true
+	jobCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	if s.scheduler != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.scheduler.Run(jobCtx)
+		}()
+		log.Printf("Scheduled backups enabled (%s)", s.cfg.Backup.Schedule)
+	}
+	if s.compactor != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.compactor.Run(jobCtx)
+		}()
+		log.Printf("Version compaction enabled (%s, keeping %d versions)", s.cfg.Compaction.Schedule, s.cfg.Compaction.KeepVersions)
+	}
+	if s.retention != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.retention.Run(jobCtx)
+		}()
+		status := s.retention.Status()
+		log.Printf("Retention enabled (%s, %s, dry run %t)", status.Schedule, strings.Join(status.Rules, " "), status.DryRun)
+	}
+	if s.anchorer != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.anchorer.Run(jobCtx)
+		}()
+		log.Printf("Version history anchoring enabled (%s, to %s)", s.cfg.History.AnchorSchedule, s.cfg.History.AnchorFile)
+	}
+	if s.publisher != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.publisher.Run(jobCtx)
+		}()
+		log.Printf("Scheduled publication enabled (every %s)", s.cfg.Publication.Interval)
+	}
+	if s.reserved != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			reservation.Run(jobCtx, s.reserved, reservation.CleanupInterval)
+		}()
+	}
+	if s.notifier != nil {
+		s.jobs.Add(1)
+		go func() {
+			defer s.jobs.Done()
+			s.notifier.Run(jobCtx, time.Hour)
+		}()
+		log.Printf("Email notifications enabled (%s)", s.cfg.Notifications.SMTPHost)
+	}
+
+	for _, h := range s.onStart {
+		if err := h(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops background jobs, runs the shutdown hooks and flushes the
+// access log. It waits for jobs to finish until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+	s.mu.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		s.jobs.Wait()
+		if s.notifier != nil {
+			s.notifier.Wait()
+		}
+		if s.outbox != nil {
+			s.outbox.Wait()
+		}
+		if s.activity != nil {
+			s.activity.Wait()
+		}
+		close(stopped)
+	}()
+	var errs []error
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("waiting for background jobs: %w", ctx.Err()))
+	}
+
+	for i := len(s.onShutdown) - 1; i >= 0; i-- {
+		if err := s.onShutdown[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := s.closeAccessLog(); err != nil {
+		errs = append(errs, fmt.Errorf("close access log: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) closeAccessLog() error {
+	s.mu.Lock()
+	sink := s.accessLog
+	s.accessLog = nil
+	s.mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+	return sink.Close()
+}
+
+// GRPCHealth returns the handler of the gRPC health checking protocol
+// (grpc.health.v1), which reports the same states as /readyz. It must be
+// served over HTTP/2; ListenAndServe serves it on Server.GRPCHealthPort.
+func (s *Server) GRPCHealth() http.Handler {
+	return health.GRPCHandler(&s.checks)
+}
+
+// ListenAndServe listens on the configured addresses, starts the server,
+// serves until ctx is done and then shuts down gracefully
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	listen := s.listen
+	if listen == nil {
+		listen = net.Listen
+	}
+	addr := fmt.Sprintf("%s:%d", s.cfg.Server.Host, s.cfg.Server.Port)
+	ln, err := listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	var grpcLn net.Listener
+	if port := s.cfg.Server.GRPCHealthPort; port > 0 {
+		if grpcLn, err = listen("tcp", fmt.Sprintf("%s:%d", s.cfg.Server.Host, port)); err != nil {
+			ln.Close()
+			return err
+		}
+	}
+
+	if err := s.Start(ctx); err != nil {
+		ln.Close()
+		if grpcLn != nil {
+			grpcLn.Close()
+		}
+		s.Shutdown(context.Background())
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       s.cfg.Server.ReadTimeout,
+		WriteTimeout:      s.cfg.Server.WriteTimeout,
+		IdleTimeout:       s.cfg.Server.IdleTimeout,
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		log.Printf("Starting go-RAiD server on %s", addr)
+		log.Printf("API endpoints available at http://%s/raid/", addr)
+		errc <- httpServer.Serve(ln)
+	}()
+	var grpcServer *http.Server
+	if grpcLn != nil {
+		grpcServer = &http.Server{
+			Addr:              grpcLn.Addr().String(),
+			Handler:           s.GRPCHealth(),
+			ReadHeaderTimeout: s.cfg.Server.ReadHeaderTimeout,
+			IdleTimeout:       s.cfg.Server.IdleTimeout,
+			Protocols:         new(http.Protocols),
+		}
+		grpcServer.Protocols.SetUnencryptedHTTP2(true)
+		go func() {
+			log.Printf("gRPC health service available on %s", grpcServer.Addr)
+			errc <- grpcServer.Serve(grpcLn)
+		}()
+	}
+
+	var serveErr error
+	select {
+	case err := <-errc:
+		serveErr = err
+	case <-ctx.Done():
+		log.Printf("Shutting down")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	httpServer.Shutdown(shutdownCtx)
+	if grpcServer != nil {
+		// Watch calls last until their clients go
+		grpcServer.Close()
+	}
+	if err := s.Shutdown(shutdownCtx); err != nil && serveErr == nil {
+		return err
+	}
+	if serveErr == http.ErrServerClosed {
+		return nil
+	}
+	return serveErr
+}
+
+// newBackupScheduler creates the backup scheduler, or returns nil when no
+// schedule is configured
+func newBackupScheduler(cfg *Config, repo storage.Repository, signer backup.Signer) (*backup.Scheduler, error) {
+	if cfg.Backup.Schedule == "" {
+		return nil, nil
+	}
+
+	var target backup.Target
+	var err error
+	switch cfg.Backup.Target {
+	case "s3":
+		target, err = backup.NewS3Target(backup.S3Config{
+			Bucket:   cfg.Backup.S3.Bucket,
+			Prefix:   cfg.Backup.S3.Prefix,
+			Region:   cfg.Backup.S3.Region,
+			Endpoint: cfg.Backup.S3.Endpoint,
+		})
+	default:
+		target, err = backup.NewLocalTarget(cfg.Backup.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return backup.NewScheduler(repo, cfg.Storage.Type, cfg.Backup.Schedule, target, backup.Retention{
+		Count:  cfg.Backup.RetentionCount,
+		MaxAge: cfg.Backup.RetentionMaxAge,
+	}, signer)
+}
+
+// newCompactor creates the version compactor, or returns nil when no
+// schedule is configured
+func newCompactor(cfg *Config, repo storage.Repository) (*compaction.Compactor, error) {
+	if cfg.Compaction.Schedule == "" {
+		return nil, nil
+	}
+	return compaction.New(repo, cfg.Compaction.Schedule, storage.CompactionPolicy{
+		KeepVersions: cfg.Compaction.KeepVersions,
+		MinAge:       cfg.Compaction.MinAge,
+	})
+}
+
+// newAttachmentStore creates the attachment store, or returns nil when the
+// backend cannot hold attachments, or their content when it is to
+func newAttachmentStore(cfg *config.AttachmentConfig, repo storage.Repository) (*attachment.Store, error) {
+	attachments, ok := repo.(storage.AttachmentStore)
+	if !ok {
+		return nil, nil
+	}
+	var blobs storage.BlobStore
+	switch cfg.Store {
+	case "s3":
+		s3, err := attachment.NewS3Blobs(attachment.S3Config{
+			Bucket:   cfg.S3.Bucket,
+			Prefix:   cfg.S3.Prefix,
+			Region:   cfg.S3.Region,
+			Endpoint: cfg.S3.Endpoint,
+		})
+		if err != nil {
+			return nil, err
+		}
+		blobs = s3
+	default:
+		if blobs, ok = repo.(storage.BlobStore); !ok {
+			return nil, nil
+		}
+	}
+	store := attachment.New(blobs, attachments, cfg.MaxBytes).WithMediaTypes(cfg.MediaTypes)
+	if cfg.ScanURL != "" {
+		store.WithScanner(attachment.NewHTTPScanner(cfg.ScanURL, cfg.ScanTimeout))
+	}
+	return store, nil
+}
+
+// newRateLimiter creates the request rate limiter, or returns nil when rate
+// limiting is disabled
+func newRateLimiter(cfg *config.RateLimitConfig) (*raidmw.RateLimiter, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var store raidmw.RateLimitStore = raidmw.NewMemoryRateLimitStore()
+	if cfg.RedisURL != "" {
+		redisStore, err := raidmw.NewRedisRateLimitStore(cfg.RedisURL, cfg.RedisPrefix)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	}
+
+	global := raidmw.Rate{Limit: cfg.GlobalPerSecond, Period: time.Second, Burst: cfg.GlobalBurst}
+	return raidmw.NewRateLimiter(store, global, cfg.TrustProxy), nil
+}
+
+// newCache layers the configured read-through cache over repo, or returns
+// repo when caching is disabled. It sits below the decorators that adapt
+// RAiDs to the caller, so cached RAiDs are as stored.
+func newCache(cfg *config.CacheConfig, repo storage.Repository) (storage.Repository, error) {
+	var store cache.Store
+	switch cfg.Store {
+	case "memory":
+		store = cache.NewMemoryStore(cfg.MaxEntries)
+	case "redis":
+		redisStore, err := cache.NewRedisStore(cfg.RedisURL, cfg.RedisPrefix)
+		if err != nil {
+			return nil, err
+		}
+		store = redisStore
+	default:
+		return repo, nil
+	}
+	return cache.Wrap(repo, store, cache.Policy{TTL: cfg.TTL, NegativeTTL: cfg.NegativeTTL}), nil
+}
+
+// newAccessLogSink creates the configured access log sink and export, or
+// returns nil when both are disabled
+func newAccessLogSink(cfg *config.AccessLogConfig, repo storage.Repository) (accesslog.Sink, error) {
+	var sink accesslog.Sink
+	switch cfg.Sink {
+	case "file":
+		file, err := accesslog.NewFileSink(cfg.File, int64(cfg.MaxSizeMB)<<20, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sink = file
+	case "storage":
+		store, ok := repo.(storage.AccessLogStore)
+		if !ok {
+			return nil, fmt.Errorf("storage backend does not support access logs")
+		}
+		sink = accesslog.NewStoreSink(store, 0)
+	}
+
+	export, err := newAccessLogExport(&cfg.Export)
+	if err != nil {
+		if sink != nil {
+			sink.Close()
+		}
+		return nil, fmt.Errorf("export: %w", err)
+	}
+	switch {
+	case export == nil:
+		return sink, nil
+	case sink == nil:
+		return export, nil
+	}
+	return accesslog.Tee(sink, export), nil
+}
+
+// retentionPolicy returns the rules of cfg: its windows for all service
+// points, unless its rules set one for the same data, and its rules
+func retentionPolicy(cfg config.RetentionConfig) retention.Policy {
+	policy := retention.Policy{DryRun: cfg.DryRun}
+	overridden := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		if rule.ServicePoint == 0 {
+			overridden[rule.Data] = true
+		}
+	}
+	for _, window := range []struct {
+		data  string
+		after time.Duration
+	}{
+		{retention.DataDeleted, cfg.PurgeDeletedAfter},
+		{retention.DataAccessLog, cfg.AccessLogAfter},
+		{retention.DataReservations, cfg.ReservationsAfter},
+		{retention.DataAudit, cfg.AuditAfter},
+	} {
+		if window.after > 0 && !overridden[window.data] {
+			policy.Rules = append(policy.Rules, retention.Rule{Data: window.data, After: window.after})
+		}
+	}
+	for _, rule := range cfg.Rules {
+		policy.Rules = append(policy.Rules, retention.Rule{Data: rule.Data, ServicePoint: rule.ServicePoint, After: rule.After})
+	}
+	return policy
+}
+
+// newAccessLogExport creates the configured audit event export, or returns
+// nil when it is disabled
+func newAccessLogExport(cfg *config.AccessLogExportConfig) (accesslog.Sink, error) {
+	if cfg.Target == "" {
+		return nil, nil
+	}
+	format, err := accesslog.NewFormatter(cfg.Format, cfg.Fields)
+	if err != nil {
+		return nil, err
+	}
+	var sender accesslog.Sender
+	switch cfg.Target {
+	case "syslog":
+		if sender, err = accesslog.NewSyslogSender(cfg.Address); err != nil {
+			return nil, err
+		}
+	case "http":
+		sender = accesslog.NewHTTPSender(cfg.Address, cfg.Format, cfg.Authorization)
+	}
+	return accesslog.NewExportSink(format, sender, 0), nil
+}
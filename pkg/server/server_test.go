@@ -0,0 +1,2925 @@
+package server
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/leifj/go-raid/internal/activitypub"
+	"github.com/leifj/go-raid/internal/attachment"
+	"github.com/leifj/go-raid/internal/coarnotify"
+	raidmw "github.com/leifj/go-raid/internal/middleware"
+	"github.com/leifj/go-raid/internal/models"
+	"github.com/leifj/go-raid/internal/signing"
+	"github.com/leifj/go-raid/internal/storage"
+	"github.com/leifj/go-raid/pkg/raid"
+)
+
+func newTestServer(t *testing.T, opts ...Option) *Server {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { repo.Close() })
+
+	srv, err := New(cfg, repo, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// do serves a request to srv and returns the response. A token, if not
+// empty, is sent as a bearer token. header holds further header names and
+// values in pairs, leaving out those with empty values; "Host" sets the
+// host the request is sent to.
+func do(srv http.Handler, token, method, path, body string, header ...string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(header); i += 2 {
+		switch {
+		case header[i+1] == "":
+		case header[i] == "Host":
+			r.Host = header[i+1]
+		default:
+			r.Header.Set(header[i], header[i+1])
+		}
+	}
+	srv.ServeHTTP(w, r)
+	return w
+}
+
+func TestServer_ServeHTTP(t *testing.T) {
+	srv := newTestServer(t, WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Embedded", "yes")
+			next.ServeHTTP(w, r)
+		})
+	}))
+
+	// Mounted under a prefix as an embedding application would
+	mux := http.NewServeMux()
+	mux.Handle("/raid-api/", http.StripPrefix("/raid-api", srv))
+
+	for _, path := range []string{"/raid-api/health", "/raid-api/raid/", "/raid-api/service-point/", "/raid-api/openapi.yaml"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: expected 200, got %d", path, w.Code)
+		}
+		if w.Header().Get("X-Embedded") != "yes" {
+			t.Errorf("GET %s: custom middleware did not run", path)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":"{ servicePoints { id } }"}`)))
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"data":{"servicePoints":[]}}` {
+		t.Errorf("GraphQL: unexpected response %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_Lifecycle(t *testing.T) {
+	var calls []string
+	hook := func(name string, err error) Hook {
+		return func(context.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	srv := newTestServer(t,
+		OnStart(hook("start1", nil)),
+		OnStart(hook("start2", nil)),
+		OnShutdown(hook("stop1", errors.New("stop1 failed"))),
+		OnShutdown(hook("stop2", nil)),
+	)
+
+	ctx := context.Background()
+	if err := srv.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Start(ctx); err == nil {
+		t.Error("expected an error when starting twice")
+	}
+	err := srv.Shutdown(ctx)
+	if err == nil || !strings.Contains(err.Error(), "stop1 failed") {
+		t.Errorf("expected the failing shutdown hook's error, got %v", err)
+	}
+
+	want := []string{"start1", "start2", "stop2", "stop1"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("hooks ran as %v, want %v", calls, want)
+	}
+}
+
+func TestServer_StartHookError(t *testing.T) {
+	srv := newTestServer(t, OnStart(func(context.Context) error { return errors.New("not ready") }))
+	if err := srv.Start(context.Background()); err == nil || err.Error() != "not ready" {
+		t.Errorf("expected the start hook's error, got %v", err)
+	}
+	srv.Shutdown(context.Background())
+}
+
+func TestServer_Hooks(t *testing.T) {
+	var minted []string
+	srv := newTestServer(t,
+		BeforeMint(func(_ context.Context, r *raid.RAiD) error {
+			if len(r.Title) == 0 {
+				return Veto("a title is required")
+			}
+			return nil
+		}),
+		AfterMint(func(_ context.Context, r *raid.RAiD) error {
+			minted = append(minted, r.Identifier.ID)
+			return nil
+		}),
+	)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"identifier":{"id":"https://raid.org/10.99999/a"}}`)))
+	if w.Code != http.StatusUnprocessableEntity || strings.TrimSpace(w.Body.String()) != "a title is required" {
+		t.Errorf("expected the mint to be vetoed, got %d: %s", w.Code, w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"identifier":{"id":"https://raid.org/10.99999/b"},"title":[{"text":"Allowed"}]}`)))
+	if w.Code != http.StatusCreated || !reflect.DeepEqual(minted, []string{"https://raid.org/10.99999/b"}) {
+		t.Errorf("expected the mint to succeed and run AfterMint, got %d (%v)", w.Code, minted)
+	}
+}
+
+func TestServer_CheckDigit(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Identifiers.CheckDigit = true
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"title":[{"text":"Checked"}]}`)))
+	var created raid.RAiD
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&created) != nil {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	handle := strings.TrimPrefix(created.Identifier.ID, "https://raid.org/")
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/raid/"+handle, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET %s: expected 200, got %d", handle, w.Code)
+	}
+
+	// Mistype the check character. Dropping it is not a reliable test: the
+	// last digit is a valid check character for the rest 1 time in 37.
+	mistyped := handle[:len(handle)-1] + "0"
+	if strings.HasSuffix(handle, "0") {
+		mistyped = handle[:len(handle)-1] + "1"
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/raid/"+mistyped, nil))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "check character") {
+		t.Errorf("expected 400 for a mistyped identifier, got %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_PrefixPools(t *testing.T) {
+	srv := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/service-point/", strings.NewReader(`{"name":"Bad","prefixAllocation":"random"}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown allocation rule, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/service-point/", strings.NewReader(`{"name":"Pooled","prefixAllocation":"project-type","prefixes":[{"prefix":"10.99991","projectTypes":["clinical-trial"]},{"prefix":"10.99992"}]}`)))
+	var sp raid.ServicePoint
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&sp) != nil {
+		t.Fatalf("create service point: %d %s", w.Code, w.Body)
+	}
+
+	for query, want := range map[string]string{"?projectType=clinical-trial": "10.99991", "": "10.99992"} {
+		body := fmt.Sprintf(`{"identifier":{"owner":{"servicePoint":%d}},"title":[{"text":"Pooled"}]}`, sp.ID)
+		w = httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/"+query, strings.NewReader(body)))
+		var created raid.RAiD
+		if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&created) != nil {
+			t.Fatalf("mint%s: %d %s", query, w.Code, w.Body)
+		}
+		if !strings.HasPrefix(created.Identifier.ID, "https://raid.org/"+want+"/") {
+			t.Errorf("mint%s: expected prefix %s, got %s", query, want, created.Identifier.ID)
+		}
+	}
+}
+
+func TestServer_Agencies(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Agencies = []Agency{
+		{ID: "north", BaseURL: "https://raid.north.example", Hosts: []string{"raid.north.example"}, RegistrationAgency: "https://ror.org/038sjwq14"},
+		{ID: "south", BaseURL: "https://raid.south.example", Hosts: []string{"raid.south.example"}},
+	}
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := do(srv, "", http.MethodPost, "/service-point/", `{"name":"North SP","prefix":"10.11111"}`, "Host", "raid.north.example")
+	var sp raid.ServicePoint
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&sp) != nil || sp.AgencyID != "north" {
+		t.Fatalf("create service point: %d %s", w.Code, w.Body)
+	}
+
+	w = do(srv, "", http.MethodPost, "/raid/", fmt.Sprintf(`{"identifier":{"owner":{"servicePoint":%d}},"title":[{"text":"North"}]}`, sp.ID), "Host", "raid.north.example")
+	var created raid.RAiD
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&created) != nil {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if !strings.HasPrefix(created.Identifier.ID, "https://raid.north.example/10.11111/") ||
+		created.Identifier.RegistrationAgency == nil || created.Identifier.RegistrationAgency.ID != "https://ror.org/038sjwq14" {
+		t.Errorf("expected the agency's base URL and registration agency, got %+v", created.Identifier)
+	}
+	path := "/raid/" + strings.TrimPrefix(created.Identifier.ID, "https://raid.north.example/")
+
+	// The other agency sees neither the service point nor the RAiD
+	if w := do(srv, "", http.MethodGet, path, "", "Host", "raid.south.example"); w.Code != http.StatusNotFound {
+		t.Errorf("GET from another agency: expected 404, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/", "", "Host", "raid.south.example"); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("listing from another agency: expected no RAiDs, got %s", w.Body)
+	}
+	if w := do(srv, "", http.MethodGet, "/service-point/", "", "Host", "raid.south.example"); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("service points from another agency: expected none, got %s", w.Body)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/", fmt.Sprintf(`{"identifier":{"owner":{"servicePoint":%d}}}`, sp.ID), "Host", "raid.south.example"); w.Code != http.StatusForbidden {
+		t.Errorf("mint for another agency's service point: expected 403, got %d", w.Code)
+	}
+
+	// Other hosts see everything
+	if w := do(srv, "", http.MethodGet, path, "", "Host", "localhost"); w.Code != http.StatusOK {
+		t.Errorf("GET without an agency: expected 200, got %d", w.Code)
+	}
+}
+
+func TestServer_BaseURL(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Identifiers.BaseURL = "https://doi.org/"
+	cfg.Identifiers.CheckDigit = true
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"title":[{"text":"DOI style"}]}`)))
+	var created raid.RAiD
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&created) != nil {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if !strings.HasPrefix(created.Identifier.ID, "https://doi.org/") {
+		t.Fatalf("expected an identifier under the base URL, got %s", created.Identifier.ID)
+	}
+
+	prefix, suffix, err := raid.ParseHandle(created.Identifier.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/raid/"+prefix+"/"+suffix, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET %s/%s: expected 200, got %d", prefix, suffix, w.Code)
+	}
+}
+
+func TestServer_RootResolution(t *testing.T) {
+	srv := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"identifier":{"id":"https://raid.org/10.99999/resolved"},"title":[{"text":"Resolved"}]}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	api, root := get("/raid/10.99999/resolved"), get("/10.99999/resolved")
+	if root.Code != http.StatusOK || root.Body.String() != api.Body.String() || root.Header().Get("Content-Type") != api.Header().Get("Content-Type") {
+		t.Errorf("expected the root path to serve the same response, got %d: %s", root.Code, root.Body)
+	}
+	if w := get("/10.99999/missing"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown RAiD, got %d", w.Code)
+	}
+	if w := get("/graphql/schema"); w.Code != http.StatusOK {
+		t.Errorf("expected other two-segment paths to be unaffected, got %d", w.Code)
+	}
+}
+
+func TestServer_Deprecation(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, suffix := range []string{"old", "new", "gone"} {
+		if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/`+suffix+`"},"title":[{"text":"T"}]}`); w.Code != http.StatusCreated {
+			t.Fatalf("mint %s: %d %s", suffix, w.Code, w.Body)
+		}
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/old/deprecate", `{"supersededBy":"10.99999/missing"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for an unknown successor, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/old/deprecate", `{"supersededBy":"https://raid.org/10.99999/old"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a self-reference, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/old/deprecate", `{"supersededBy":"10.99999/new","reason":"merged"}`); w.Code != http.StatusOK {
+		t.Fatalf("deprecate: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/old/deprecate", `{}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 when deprecating twice, got %d", w.Code)
+	}
+
+	w := do(srv, "", http.MethodGet, "/raid/10.99999/old", "")
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected 301, got %d %s", w.Code, w.Body)
+	}
+	if loc := w.Header().Get("Location"); loc != "../10.99999/new" {
+		t.Errorf("unexpected Location %q", loc)
+	}
+	// After the successor version of the unversioned API
+	if links := w.Header().Values("Link"); !slices.Contains(links, `<https://raid.org/10.99999/new>; rel="successor-version"`) {
+		t.Errorf("unexpected Link %q", links)
+	}
+	var body struct {
+		SupersededBy string `json:"supersededBy"`
+		Reason       string `json:"reason"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.SupersededBy != "https://raid.org/10.99999/new" || body.Reason != "merged" {
+		t.Errorf("unexpected body %+v (%v)", body, err)
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/gone/deprecate", `{"reason":"withdrawn"}`); w.Code != http.StatusOK {
+		t.Fatalf("deprecate: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodGet, "/10.99999/gone", ""); w.Code != http.StatusGone {
+		t.Errorf("expected 410 without a successor, got %d", w.Code)
+	}
+
+	if w := do(srv, "", http.MethodGet, "/raid/10.99999/old/1", ""); w.Code != http.StatusOK {
+		t.Errorf("expected earlier versions to stay available, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/10.99999/old/history", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "version") {
+		t.Errorf("expected history to stay available, got %d", w.Code)
+	}
+}
+
+func TestServer_ValidationFailures(t *testing.T) {
+	srv := newTestServer(t)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"title":[{"text":"Leaderless"}],
+		"contributor":[{"id":"https://orcid.org/0000-0000-0000-0001","contact":true}]}`)))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d %s", w.Code, w.Body)
+	}
+	var resp raid.ErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Failures) != 2 || resp.Failures[0].FieldID != "contributor[0].position" || resp.Failures[1].FieldID != "contributor" {
+		t.Errorf("unexpected failures: %+v", resp.Failures)
+	}
+}
+
+func TestServer_AccessTransitions(t *testing.T) {
+	srv := newTestServer(t)
+	open := `{"identifier":{"id":"https://raid.org/10.99999/open"},"title":[{"text":"Open"}],
+		"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}}}`
+	if w := do(srv, "", http.MethodPost, "/raid/", open); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	w := do(srv, "", http.MethodPut, "/raid/10.99999/open", `{"identifier":{"id":"https://raid.org/10.99999/open"},"title":[{"text":"Open"}],
+		"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/53"},"embargoExpiry":"2999-01-01"}}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "only an operator can embargo an open RAiD") {
+		t.Errorf("expected re-embargo to be rejected, got %d %s", w.Code, w.Body)
+	}
+
+	w = do(srv, "", http.MethodGet, "/raid/10.99999/open/access-history", "")
+	var timeline []raid.AccessChange
+	if err := json.NewDecoder(w.Body).Decode(&timeline); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || len(timeline) != 1 || timeline[0].Type != "https://vocabulary.raid.org/access.type.schema/82" {
+		t.Errorf("unexpected access history: %d %+v", w.Code, timeline)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/10.99999/missing/access-history", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing RAiD, got %d", w.Code)
+	}
+}
+
+func TestServer_AccessTransitions_Operator(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "operator-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := raidmw.Claims{UserID: "curator", Roles: []string{raidmw.RoleOperator},
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	open := `{"identifier":{"id":"https://raid.org/10.99999/open"},"title":[{"text":"Open"}],
+		"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}}}`
+	if w := do(srv, "", http.MethodPost, "/raid/", open); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	embargoed := `{"identifier":{"id":"https://raid.org/10.99999/open"},"title":[{"text":"Open"}],
+		"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/53"},"embargoExpiry":"2999-01-01"}}`
+	if w := do(srv, "garbage", http.MethodPut, "/raid/10.99999/open", embargoed); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an invalid token, got %d", w.Code)
+	}
+	if w := do(srv, token, http.MethodPut, "/raid/10.99999/open", embargoed); w.Code != http.StatusOK {
+		t.Errorf("expected an operator to re-embargo, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_Quota_Operator(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "operator-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp, err := repo.CreateServicePoint(context.Background(), &raid.ServicePoint{Name: "Pilot", Enabled: true, MaxRAiDs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(roles ...string) string {
+		claims := raidmw.Claims{UserID: "minter", ServicePointID: &sp.ID, Roles: roles,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	member, operator := sign(), sign(raidmw.RoleOperator)
+
+	mint := func(suffix string) string {
+		return fmt.Sprintf(`{"identifier":{"id":"https://raid.org/10.99999/%s","owner":{"servicePoint":%d}},"title":[{"text":"Pilot"}]}`, suffix, sp.ID)
+	}
+	if w := do(srv, member, http.MethodPost, "/raid/", mint("first")); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, member, http.MethodPost, "/raid/", mint("second")); w.Code != http.StatusForbidden {
+		t.Errorf("expected the quota to stop a member, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, operator, http.MethodPost, "/raid/", mint("third")); w.Code != http.StatusCreated {
+		t.Errorf("expected an operator to mint past the quota, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_HistoryActors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "history-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(user string) string {
+		claims := raidmw.Claims{UserID: user,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	if w := do(srv, sign("alice"), http.MethodPost, "/v2/raid/", `{"identifier":{"id":"https://raid.org/10.99999/kept"},"title":[{"text":"First"}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, sign("bob"), http.MethodPut, "/v2/raid/10.99999/kept", `{"identifier":{"id":"https://raid.org/10.99999/kept"},"title":[{"text":"Second"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+
+	w := do(srv, "", http.MethodGet, "/v2/raid/10.99999/kept/history?summary=true", "")
+	var changes []raid.RAiDChange
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&changes) != nil {
+		t.Fatalf("history: %d %s", w.Code, w.Body)
+	}
+	if len(changes) != 2 || changes[0].Actor != "alice" || changes[1].Actor != "bob" {
+		t.Errorf("expected the writers of the versions, got %+v", changes)
+	}
+}
+
+func TestServer_RateLimitPerToken(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "limit-secret"
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.ReadPerMinute, cfg.RateLimit.ReadBurst = 1, 1
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(user string) string {
+		claims := raidmw.Claims{UserID: user,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	// All from one address, each token with a budget of its own
+	for _, path := range []string{"/v2/raid/", "/v2/service-point/1/raids", "/graphql/schema"} {
+		alice, bob := sign("alice-"+path), sign("bob-"+path)
+		if w := do(srv, alice, http.MethodGet, path, ""); w.Code == http.StatusTooManyRequests {
+			t.Errorf("%s: expected the first request let through", path)
+		}
+		if w := do(srv, bob, http.MethodGet, path, ""); w.Code == http.StatusTooManyRequests {
+			t.Errorf("%s: expected another token limited apart", path)
+		}
+		if w := do(srv, alice, http.MethodGet, path, ""); w.Code != http.StatusTooManyRequests {
+			t.Errorf("%s: expected the token's budget spent, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestServer_Split(t *testing.T) {
+	srv := newTestServer(t)
+
+	w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/whole"},
+		"title":[{"text":"Alpha"},{"text":"Beta"}],
+		"contributor":[{"id":"https://orcid.org/0000-0000-0000-0001"},{"id":"https://orcid.org/0000-0000-0000-0002","leader":true,"contact":true,
+			"position":[{"id":"https://vocabulary.raid.org/contributor.position.schema/307","startDate":"2024-01-01"}]}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/whole/split", `{"title":[]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without titles, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/whole/split", `{"title":[2]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an index out of range, got %d", w.Code)
+	}
+
+	w = do(srv, "", http.MethodPost, "/raid/10.99999/whole/split", `{"title":[1],"contributor":[1],"id":"https://raid.org/10.99999/part"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("split: %d %s", w.Code, w.Body)
+	}
+	var out struct {
+		Original *raid.RAiD `json:"original"`
+		Derived  *raid.RAiD `json:"derived"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	d := out.Derived
+	if len(d.Title) != 1 || d.Title[0].Text != "Beta" || len(d.Contributor) != 1 || d.Contributor[0].ID != "https://orcid.org/0000-0000-0000-0002" {
+		t.Errorf("unexpected derived blocks: %+v %+v", d.Title, d.Contributor)
+	}
+	if len(d.RelatedRAiD) != 1 || d.RelatedRAiD[0].ID != "https://raid.org/10.99999/whole" || d.RelatedRAiD[0].Type.ID != raid.RelatedRAiDTypeIsDerivedFrom {
+		t.Errorf("unexpected derived relations: %+v", d.RelatedRAiD)
+	}
+	o := out.Original
+	if o.Identifier.Version != 2 || len(o.Title) != 2 {
+		t.Errorf("expected the original to keep its blocks in version 2, got version %d with %d titles", o.Identifier.Version, len(o.Title))
+	}
+	if len(o.RelatedRAiD) != 1 || o.RelatedRAiD[0].ID != "https://raid.org/10.99999/part" || o.RelatedRAiD[0].Type.ID != raid.RelatedRAiDTypeHasDerivation {
+		t.Errorf("unexpected original relations: %+v", o.RelatedRAiD)
+	}
+}
+
+func TestServer_ReciprocalRelations(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Relations.Reciprocal = true
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	relations := func(path string) []raid.RelatedRAiD {
+		w := do(srv, "", http.MethodGet, path, "")
+		var got raid.RAiD
+		if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got.RelatedRAiD
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/whole"},"title":[{"text":"Whole"}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/part"},"title":[{"text":"Part"}],
+		"relatedRaid":[{"id":"10.99999/whole","type":{"id":"`+raid.RelatedRAiDTypeIsPartOf+`"}},{"id":"https://raid.org/10.99999/elsewhere","type":{"id":"`+raid.RelatedRAiDTypeContinues+`"}}]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	rels := relations("/raid/10.99999/whole")
+	if len(rels) != 1 || rels[0].ID != "https://raid.org/10.99999/part" || rels[0].Type.ID != raid.RelatedRAiDTypeHasPart {
+		t.Fatalf("expected a HasPart link back, got %+v", rels)
+	}
+
+	// Changing the relation corrects the link back rather than adding one
+	w = do(srv, "", http.MethodPut, "/raid/10.99999/part", `{"identifier":{"id":"https://raid.org/10.99999/part"},"title":[{"text":"Part"}],
+		"relatedRaid":[{"id":"https://raid.org/10.99999/whole","type":{"id":"`+raid.RelatedRAiDTypeContinues+`"}}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+	rels = relations("/raid/10.99999/whole")
+	if len(rels) != 1 || rels[0].Type.ID != raid.RelatedRAiDTypeIsContinuedBy {
+		t.Errorf("expected the link back to become IsContinuedBy, got %+v", rels)
+	}
+
+	// Splitting does not link the original twice
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/whole/split", `{"title":[0]}`); w.Code != http.StatusCreated {
+		t.Fatalf("split: %d %s", w.Code, w.Body)
+	}
+	if rels := relations("/raid/10.99999/whole"); len(rels) != 2 {
+		t.Errorf("expected one link per related RAiD, got %+v", rels)
+	}
+}
+
+func TestServer_TraditionalKnowledge(t *testing.T) {
+	srv := newTestServer(t)
+
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"title":[{"text":"Labelled"}],"traditionalKnowledgeLabel":[{"id":"https://localcontexts.org/label/tk-unknown/"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown label, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/tk"},"title":[{"text":"Labelled"}],"traditionalKnowledgeLabel":[{"id":"https://localcontexts.org/label/tk-attribution"}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/plain"},"title":[{"text":"Plain"}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	list := func(query string) []string {
+		w := do(srv, "", http.MethodGet, "/raid/?"+query, "")
+		var raids []raid.RAiD
+		if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+			t.Fatalf("%s: %d %v", query, w.Code, err)
+		}
+		ids := make([]string, len(raids))
+		for i, r := range raids {
+			ids[i] = r.Identifier.ID
+		}
+		return ids
+	}
+	if got := list("traditionalKnowledgeLabel=true"); !reflect.DeepEqual(got, []string{"https://raid.org/10.99999/tk"}) {
+		t.Errorf("expected only the labelled RAiD, got %v", got)
+	}
+	if got := list("traditionalKnowledgeLabel=false"); !reflect.DeepEqual(got, []string{"https://raid.org/10.99999/plain"}) {
+		t.Errorf("expected only the unlabelled RAiD, got %v", got)
+	}
+	if got := list("traditionalKnowledgeLabel.id=https://localcontexts.org/label/tk-attribution/"); len(got) != 1 {
+		t.Errorf("expected the stored label to be normalized and matched, got %v", got)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/?traditionalKnowledgeLabel=maybe", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad presence filter, got %d", w.Code)
+	}
+}
+
+func TestServer_FindByRelatedObject(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, body := range []string{
+		`{"identifier":{"id":"https://raid.org/10.99999/producer"},"title":[{"text":"Producer"}],"relatedObject":[{"id":"doi:10.5061/Dryad.ABC","schemaUri":"https://doi.org/"}]}`,
+		`{"identifier":{"id":"https://raid.org/10.99999/other"},"title":[{"text":"Other"}],"relatedObject":[{"id":"https://doi.org/10.1/x","schemaUri":"https://doi.org/"}]}`,
+	} {
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint: %d %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(srv, "", http.MethodGet, "/raid/find?relatedObject=10.5061/dryad.abc", "")
+	var raids []raid.RAiD
+	if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if len(raids) != 1 || raids[0].Identifier.ID != "https://raid.org/10.99999/producer" || raids[0].RelatedObject[0].ID != "https://doi.org/10.5061/dryad.abc" {
+		t.Errorf("expected the producing RAiD with its DOI normalized, got %+v", raids)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/find?relatedObject=not-a-doi", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a value that is not a DOI, got %d", w.Code)
+	}
+}
+
+func TestServer_GetRAiDBatch(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, suffix := range []string{"one", "two"} {
+		body := `{"identifier":{"id":"https://raid.org/10.99999/` + suffix + `"},"title":[{"text":"` + suffix + `"}]}`
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint: %d %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(srv, "", http.MethodGet, "/raid/batch?id=10.99999/two&id=10.99999/missing&id=https://raid.org/10.99999/one", "")
+	var raids []raid.RAiD
+	if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if len(raids) != 2 || raids[0].Title[0].Text != "two" || raids[1].Title[0].Text != "one" {
+		t.Errorf("expected the two RAiDs held in the order requested, got %+v", raids)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/batch", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without ids, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/batch?id=nonsense", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an id that is not a handle, got %d", w.Code)
+	}
+}
+
+func TestServer_ContributorRAiDs(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, suffix := range []string{"a", "b", "c"} {
+		body := `{"identifier":{"id":"https://raid.org/10.99999/` + suffix + `"},"title":[{"text":"Project ` + suffix + `"}],
+			"contributor":[{"id":"https://orcid.org/0000-0002-1825-0097","leader":true,"contact":true,
+				"role":[{"id":"https://credit.niso.org/contributor-roles/software/"}],
+				"position":[{"id":"https://vocabulary.raid.org/contributor.position.schema/307","startDate":"2024-01-01","endDate":"2024-06-30"},
+					{"id":"https://vocabulary.raid.org/contributor.position.schema/308","startDate":"2024-07-01","endDate":"2024-12-31"}]}]}`
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint %s: %d %s", suffix, w.Code, w.Body)
+		}
+	}
+
+	w := do(srv, "", http.MethodGet, "/contributor/0000-0002-1825-0097/raids?limit=2", "")
+	var page raid.ContributorRAiDs
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if page.Contributor != "https://orcid.org/0000-0002-1825-0097" || len(page.RAiDs) != 2 || !page.Page.HasMore {
+		t.Fatalf("expected a full first page, got %+v", page)
+	}
+	got := page.RAiDs[0]
+	if !got.Leader || !got.Contact || len(got.Position) != 2 || len(got.Role) != 1 || got.StartDate != "2024-01-01" || got.EndDate != "2024-12-31" || !strings.HasPrefix(got.Title, "Project ") {
+		t.Errorf("expected the contributor's part in the RAiD, got %+v", got)
+	}
+
+	w = do(srv, "", http.MethodGet, "/contributor/https:%2F%2Forcid.org%2F0000-0002-1825-0097/raids?offset=2", "")
+	page = raid.ContributorRAiDs{}
+	if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if len(page.RAiDs) != 1 || page.Page.HasMore {
+		t.Errorf("expected the last RAiD on the second page, got %+v", page)
+	}
+
+	for _, path := range []string{"/contributor/0000-0002-1825-0098/raids", "/contributor/0000-0002-1825-0097/raids?limit=1000"} {
+		if w := do(srv, "", http.MethodGet, path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestServer_OrganisationRAiDs(t *testing.T) {
+	srv := newTestServer(t)
+
+	for suffix, roles := range map[string]string{"a": "182", "b": "186", "c": "182", "d": "184"} {
+		body := `{"identifier":{"id":"https://raid.org/10.99999/` + suffix + `"},"title":[{"text":"Project ` + suffix + `"}],
+			"organisation":[{"id":"https://ror.org/038sjwq14","schemaUri":"https://ror.org/",
+				"role":[{"id":"https://vocabulary.raid.org/organisation.role.schema/` + roles + `","startDate":"2024-01-01"}]}]}`
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint %s: %d %s", suffix, w.Code, w.Body)
+		}
+	}
+
+	get := func(path string) raid.OrganisationRAiDs {
+		w := do(srv, "", http.MethodGet, path, "")
+		var page raid.OrganisationRAiDs
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%s: %d %v", path, w.Code, err)
+		}
+		return page
+	}
+	page := get("/organisation/038sjwq14/raids?limit=3")
+	if page.Organisation != "https://ror.org/038sjwq14" || page.Total != 4 || page.Counts["lead"] != 2 || page.Counts["funder"] != 1 || page.Counts["partner"] != 1 || !page.Page.HasMore {
+		t.Errorf("expected counts over all RAiDs, got %+v", page)
+	}
+	listed := 0
+	for _, group := range page.Roles {
+		listed += len(group)
+	}
+	if listed != 3 {
+		t.Errorf("expected a page of 3 RAiDs, got %+v", page.Roles)
+	}
+
+	page = get("/organisation/https:%2F%2Fror.org%2F038sjwq14/raids?role=lead")
+	if page.Total != 2 || len(page.Roles) != 1 || len(page.Roles["lead"]) != 2 || page.Counts["funder"] != 1 || page.Page.HasMore {
+		t.Errorf("expected only the lead RAiDs, got %+v", page)
+	}
+
+	for _, path := range []string{"/organisation/nope/raids", "/organisation/038sjwq14/raids?role=sponsor"} {
+		if w := do(srv, "", http.MethodGet, path, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+}
+
+func TestServer_ServicePointRAiDs(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "service-point-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sp raid.ServicePoint
+	w := do(srv, "", http.MethodPost, "/service-point/", `{"name":"Lab","prefix":"10.99999"}`)
+	if err := json.NewDecoder(w.Body).Decode(&sp); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	owner := fmt.Sprintf(`"owner":{"id":"https://ror.org/038sjwq14","schemaUri":"https://ror.org/","servicePoint":%d}`, sp.ID)
+	for suffix, doc := range map[string]string{
+		"beta":  `"title":[{"text":"Beta"}],"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}}`,
+		"alpha": `"title":[{"text":"Alpha"}],"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/53"},"embargoExpiry":"2999-01-01"}`,
+		"gamma": `"title":[{"text":"Gamma"}],"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}}`,
+	} {
+		body := `{"identifier":{"id":"https://raid.org/10.99999/` + suffix + `",` + owner + `},` + doc + `}`
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint %s: %d %s", suffix, w.Code, w.Body)
+		}
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/other"},"title":[{"text":"Other"}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint other: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodDelete, "/raid/10.99999/gamma", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+
+	token := func(servicePoint int64) string {
+		claims := raidmw.Claims{UserID: "admin", ServicePointID: &servicePoint, Roles: []string{raidmw.RoleAdmin},
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	list := func(query, token string) []string {
+		w := do(srv, token, http.MethodGet, fmt.Sprintf("/service-point/%d/raids%s", sp.ID, query), "")
+		var raids []raid.RAiD
+		if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+			t.Fatalf("%s: %d %v", query, w.Code, err)
+		}
+		var titles []string
+		for _, r := range raids {
+			title := r.Title[0].Text
+			if r.Metadata != nil && r.Metadata.Deleted {
+				title += " (deleted)"
+			}
+			titles = append(titles, title)
+		}
+		return titles
+	}
+	for _, tc := range []struct {
+		query, token string
+		want         []string
+	}{
+		{"", "", []string{"Beta"}},
+		{"?sort=title", token(sp.ID + 1), []string{"Beta"}},
+		{"?sort=title", token(sp.ID), []string{"Alpha", "Beta", "Gamma (deleted)"}},
+		{"?sort=-title&limit=2&offset=1", token(sp.ID), []string{"Beta", "Alpha"}},
+	} {
+		if got := list(tc.query, tc.token); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.query, tc.want, got)
+		}
+	}
+
+	for path, want := range map[string]int{
+		fmt.Sprintf("/service-point/%d/raids?sort=size", sp.ID): http.StatusBadRequest,
+		"/service-point/999/raids":                              http.StatusNotFound,
+	} {
+		if w := do(srv, "", http.MethodGet, path, ""); w.Code != want {
+			t.Errorf("%s: expected %d, got %d", path, want, w.Code)
+		}
+	}
+	if w := do(srv, "garbage", http.MethodGet, fmt.Sprintf("/service-point/%d/raids", sp.ID), ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an invalid token, got %d", w.Code)
+	}
+}
+
+func TestServer_Citation(t *testing.T) {
+	srv := newTestServer(t)
+
+	doc := func(title string) string {
+		return `{"identifier":{"id":"https://raid.org/10.99999/cited"},"title":[{"text":"` + title + `"}],
+			"contributor":[{"id":"https://orcid.org/0000-0000-0000-0001","position":[{"id":"https://vocabulary.raid.org/contributor.position.schema/308","startDate":"2024-01-01"}]},
+				{"id":"https://orcid.org/0000-0000-0000-0002","leader":true,"contact":true,"position":[{"id":"https://vocabulary.raid.org/contributor.position.schema/307","startDate":"2024-01-01"}]}]}`
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/", doc("Before")); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodPut, "/raid/10.99999/cited", doc("After")); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+
+	w := do(srv, "", http.MethodGet, "/raid/10.99999/cited/1/citation", "", "Accept", "application/json")
+	var citation raid.Citation
+	if err := json.NewDecoder(w.Body).Decode(&citation); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if citation.Title != "Before" || citation.Version != 1 ||
+		!slices.Equal(citation.Contributors, []string{"https://orcid.org/0000-0000-0000-0002", "https://orcid.org/0000-0000-0000-0001"}) {
+		t.Errorf("expected the first version cited with the leader first, got %+v", citation)
+	}
+
+	w = do(srv, "", http.MethodGet, "/raid/10.99999/cited/2/citation", "", "Accept", "text/plain")
+	year := time.Now().UTC().Format("2006")
+	want := "https://orcid.org/0000-0000-0000-0002; https://orcid.org/0000-0000-0000-0001 (" + year + "). After (Version 2) [Research activity]. RAiD. https://raid.org/10.99999/cited\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("unexpected text citation: %d %q", w.Code, w.Body)
+	}
+
+	for path, code := range map[string]int{"/raid/10.99999/cited/3/citation": http.StatusNotFound, "/raid/10.99999/cited/latest/citation": http.StatusBadRequest} {
+		if w := do(srv, "", http.MethodGet, path, ""); w.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestServer_Completeness(t *testing.T) {
+	srv := newTestServer(t)
+
+	for _, body := range []string{
+		`{"identifier":{"id":"https://raid.org/10.99999/bare"},"title":[{"text":"Bare"}]}`,
+		`{"identifier":{"id":"https://raid.org/10.99999/curated"},"title":[{"text":"Curated"}],"description":[{"text":"About"}],
+			"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}},
+			"subject":[{"id":"https://linked.data.gov.au/def/anzsrc-for/2020/3107"}]}`,
+	} {
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint: %d %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(srv, "", http.MethodGet, "/raid/10.99999/curated/completeness", "")
+	var score raid.Completeness
+	if err := json.NewDecoder(w.Body).Decode(&score); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if score.Score != 35 || !slices.Contains(score.Met, "description") || !slices.Contains(score.Missing, "organisation") {
+		t.Errorf("unexpected completeness: %+v", score)
+	}
+
+	w = do(srv, "", http.MethodGet, "/raid/?completeness.max=20", "")
+	var raids []raid.RAiD
+	if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if len(raids) != 1 || raids[0].Identifier.ID != "https://raid.org/10.99999/bare" {
+		t.Errorf("expected only the bare RAiD, got %d RAiDs", len(raids))
+	}
+
+	for path, code := range map[string]int{
+		"/raid/?completeness.min=101":         http.StatusBadRequest,
+		"/raid/10.99999/missing/completeness": http.StatusNotFound,
+	} {
+		if w := do(srv, "", http.MethodGet, path, ""); w.Code != code {
+			t.Errorf("%s: expected %d, got %d", path, code, w.Code)
+		}
+	}
+}
+
+func TestServer_LanguageDetection(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Languages.Detect = true
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"title":[{"text":"Die Geschichte des Hafens und der Stadt"}]}`)))
+	var minted raid.RAiD
+	if err := json.NewDecoder(w.Body).Decode(&minted); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if l := minted.Title[0].Language; l == nil || l.ID != "deu" || !l.AutoDetected {
+		t.Errorf("expected German to be detected, got %+v", l)
+	}
+}
+
+func TestServer_Extensions(t *testing.T) {
+	srv := newTestServer(t)
+	doc := `{"identifier":{"id":"https://raid.org/10.99999/extended"},"x-local":{"grant":"G-1"},
+		"title":[{"text":"Extended","x-source":"intake"}]}`
+	if w := do(srv, "", http.MethodPost, "/raid/", doc); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	w := do(srv, "", http.MethodGet, "/raid/10.99999/extended", "")
+	var fetched raid.RAiD
+	if err := json.NewDecoder(w.Body).Decode(&fetched); err != nil {
+		t.Fatalf("%d %v", w.Code, err)
+	}
+	if string(fetched.Extensions["x-local"]) != `{"grant":"G-1"}` || string(fetched.Title[0].Extensions["x-source"]) != `"intake"` {
+		t.Errorf("expected extensions to survive minting, got %s and %s", fetched.Extensions, fetched.Title[0].Extensions)
+	}
+
+	// An update sending back what was read keeps the extensions
+	fetched.Title[0].Text = "Renamed"
+	body, _ := json.Marshal(fetched)
+	if w := do(srv, "", http.MethodPut, "/raid/10.99999/extended", string(body)); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+	w = do(srv, "", http.MethodGet, "/raid/10.99999/extended", "")
+	if !strings.Contains(w.Body.String(), `"x-local":{"grant":"G-1"}`) || !strings.Contains(w.Body.String(), `"x-source":"intake"`) {
+		t.Errorf("expected extensions to survive an update, got %s", w.Body)
+	}
+}
+
+func TestServer_CursorPagination(t *testing.T) {
+	srv := newTestServer(t)
+	for _, suffix := range []string{"c", "a", "b"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/raid/", strings.NewReader(`{"identifier":{"id":"https://raid.org/10.99999/`+suffix+`"},"title":[{"text":"Paged"}]}`)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("mint: %d %s", w.Code, w.Body)
+		}
+	}
+
+	var seen []string
+	path := "/v2/raid/?limit=2"
+	for path != "" {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var page []*raid.RAiD
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("%d %v", w.Code, err)
+		}
+		for _, r := range page {
+			seen = append(seen, r.Identifier.ID)
+		}
+		path = ""
+		if link := w.Header().Get("Link"); link != "" {
+			next, ok := strings.CutPrefix(link, "<")
+			next, _, ok2 := strings.Cut(next, `>; rel="next"`)
+			if !ok || !ok2 {
+				t.Fatalf("unexpected Link header %q", link)
+			}
+			path = "/v2/raid/" + next
+		}
+		if len(seen) > 3 {
+			t.Fatalf("pagination does not end: %v", seen)
+		}
+	}
+	want := []string{"https://raid.org/10.99999/a", "https://raid.org/10.99999/b", "https://raid.org/10.99999/c"}
+	if !slices.Equal(seen, want) {
+		t.Errorf("paged through %v, want %v", seen, want)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/raid/all-public?cursor=not-a-cursor!", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed cursor, got %d", w.Code)
+	}
+}
+
+func TestServer_SubjectFilters(t *testing.T) {
+	srv := newTestServer(t)
+
+	for suffix, subject := range map[string]string{"coast": "370901", "soil": "3107"} {
+		body := `{"identifier":{"id":"https://raid.org/10.99999/` + suffix + `"},"title":[{"text":"T"}],
+			"subject":[{"id":"https://linked.data.gov.au/def/anzsrc-for/2020/` + subject + `","keyword":[{"text":" ` + strings.ToUpper(suffix[:1]) + suffix[1:] + ` "}]}]}`
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint %s: %d %s", suffix, w.Code, w.Body)
+		}
+	}
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"title":[{"text":"T"}],"subject":[{"id":"https://linked.data.gov.au/def/anzsrc-for/2020/99"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown subject, got %d", w.Code)
+	}
+
+	count := func(query string) int {
+		w := do(srv, "", http.MethodGet, "/raid/?"+query, "")
+		var raids []raid.RAiD
+		if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+			t.Fatalf("%s: %d %v", query, w.Code, err)
+		}
+		return len(raids)
+	}
+	for query, want := range map[string]int{
+		"subject.id=https://linked.data.gov.au/def/anzsrc-for/2020/37":   1,
+		"subject.id=https://linked.data.gov.au/def/anzsrc-for/2020/3107": 1,
+		"subject.keyword=COAST": 1,
+		"subject.keyword=Coast&subject.id=https://linked.data.gov.au/def/anzsrc-for/2020/31": 0,
+	} {
+		if got := count(query); got != want {
+			t.Errorf("%s: expected %d RAiDs, got %d", query, want, got)
+		}
+	}
+}
+
+func TestServer_Invitations(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Invitations.Secret = "invitation-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/team"},"title":[{"text":"Team"}],
+		"contributor":[{"id":"https://orcid.org/0000-0000-0000-0001","leader":true,"contact":true,
+			"position":[{"id":"https://vocabulary.raid.org/contributor.position.schema/307","startDate":"2024-01-01"}]}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/10.99999/team/invitations", `{"email":"nobody"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad email address, got %d", w.Code)
+	}
+	w := do(srv, "", http.MethodPost, "/raid/10.99999/team/invitations", `{"email":"j@example.org"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("invite: %d %s", w.Code, w.Body)
+	}
+	var invite struct {
+		Contributor raid.Contributor `json:"contributor"`
+		Path        string           `json:"path"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&invite); err != nil {
+		t.Fatal(err)
+	}
+	if invite.Contributor.Status != "PENDING_AUTHENTICATION" || invite.Contributor.UUID == "" {
+		t.Errorf("expected a pending contributor with a UUID, got %+v", invite.Contributor)
+	}
+
+	if w := do(srv, "", http.MethodGet, invite.Path, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"title":"Team"`) {
+		t.Errorf("expected the invitation details, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodGet, invite.Path+"x", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a damaged link, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodPost, invite.Path, `{"accept":true,"orcid":"0000-0002-1825-0098"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad ORCID iD, got %d", w.Code)
+	}
+
+	w = do(srv, "", http.MethodPost, invite.Path, `{"accept":true,"orcid":"0000-0002-1825-0097"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("accept: %d %s", w.Code, w.Body)
+	}
+	var updated raid.RAiD
+	if err := json.NewDecoder(w.Body).Decode(&updated); err != nil {
+		t.Fatal(err)
+	}
+	c := updated.Contributor[1]
+	if c.ID != "https://orcid.org/0000-0002-1825-0097" || c.Status != "AUTHENTICATED" || c.UUID != invite.Contributor.UUID {
+		t.Errorf("expected the contributor to be authenticated with the same UUID, got %+v", c)
+	}
+	if w := do(srv, "", http.MethodPost, invite.Path, `{"accept":false}`); w.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a second answer, got %d", w.Code)
+	}
+}
+
+func TestServer_Versioning(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Server.UnversionedDeprecation = "2026-01-01"
+	cfg.Server.UnversionedSunset = "2027-01-01"
+	cfg.Invitations.Secret = "invitation-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(srv, "", http.MethodPost, "/v2/raid/", `{"identifier":{"id":"https://raid.org/10.99999/v"},"title":[{"text":"Versioned"}],
+		"contributor":[{"email":"j@example.org","leader":true,"contact":true,
+			"position":[{"id":"https://vocabulary.raid.org/contributor.position.schema/307","startDate":"2024-01-01"}]}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint under /v2: %d %s", w.Code, w.Body)
+	}
+
+	w := do(srv, "", http.MethodGet, "/v2/raid/", "", "Accept", `application/json; profile="https://raid.org/profiles/envelope"`)
+	if w.Code != http.StatusOK || w.Header().Get("Deprecation") != "" || w.Header().Get("Link") != "" {
+		t.Errorf("expected /v2 not to be deprecated, got %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), `"self":"../raid/10.99999/v"`) {
+		t.Errorf("expected links relative to /v2, got %s", w.Body)
+	}
+
+	w = do(srv, "", http.MethodGet, "/raid/10.99999/v", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected the unversioned path to be served, got %d", w.Code)
+	}
+	if got := w.Header().Get("Link"); got != `<../../v2/raid/10.99999/v>; rel="successor-version"` {
+		t.Errorf("unexpected Link %q", got)
+	}
+	if w.Header().Get("Deprecation") != "@1767225600" || w.Header().Get("Sunset") != "Fri, 01 Jan 2027 00:00:00 GMT" {
+		t.Errorf("expected Deprecation and Sunset headers, got %v", w.Header())
+	}
+	if w := do(srv, "", http.MethodGet, "/readyz", ""); w.Header().Get("Deprecation") != "" {
+		t.Error("expected operator endpoints not to be deprecated")
+	}
+
+	w = do(srv, "", http.MethodPost, "/v2/raid/10.99999/v/invitations", `{"email":"j@example.org"}`)
+	var invite struct {
+		Path string `json:"path"`
+	}
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&invite) != nil {
+		t.Fatalf("invite: %d %s", w.Code, w.Body)
+	}
+	if !strings.HasPrefix(invite.Path, "/v2/invitations/") {
+		t.Errorf("expected the invitation link under /v2, got %s", invite.Path)
+	}
+}
+
+func TestServer_DeleteServicePoint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "operator-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sp, err := repo.CreateServicePoint(ctx, &raid.ServicePoint{Name: "Closing", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRAiD(ctx, &raid.RAiD{Identifier: &raid.Identifier{
+		ID: "https://raid.org/10.99999/owned", Owner: &raid.Owner{ServicePoint: sp.ID},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	claims := raidmw.Claims{UserID: "ops", Roles: []string{raidmw.RoleOperator},
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := fmt.Sprintf("/v2/service-point/%d", sp.ID)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, path+"?force=true", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected deletion to require an operator, got %d", w.Code)
+	}
+	for query, want := range map[string]int{
+		"":                                   http.StatusConflict,
+		"?raids=gone":                        http.StatusBadRequest,
+		fmt.Sprintf("?transferTo=%d", sp.ID): http.StatusUnprocessableEntity,
+		"?raids=read-only&transferTo=9999":   http.StatusBadRequest,
+		"?raids=read-only&force=true":        http.StatusBadRequest,
+		"?force=maybe":                       http.StatusBadRequest,
+	} {
+		if w := do(srv, token, http.MethodDelete, path+query, ""); w.Code != want {
+			t.Errorf("DELETE %s: expected %d, got %d %s", query, want, w.Code, w.Body)
+		}
+	}
+
+	w = do(srv, token, http.MethodDelete, path+"?raids=read-only&reason=merged", "")
+	var got raid.ServicePoint
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+		t.Fatalf("deactivate: %d %s", w.Code, w.Body)
+	}
+	if got.Enabled || got.Deactivation == nil || got.Deactivation.Reason != "merged" || got.Deactivation.Count != 1 {
+		t.Errorf("unexpected deactivated service point %+v", got)
+	}
+	if w := do(srv, token, http.MethodDelete, path+"?raids=read-only", ""); w.Code != http.StatusConflict {
+		t.Errorf("expected a second deactivation to conflict, got %d", w.Code)
+	}
+
+	// An update cannot clear the deactivation
+	if w := do(srv, token, http.MethodPut, path, `{"name":"Reopened","enabled":true}`); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+	if stored, err := repo.GetServicePoint(ctx, sp.ID); err != nil || stored.Deactivation == nil {
+		t.Errorf("expected the deactivation to be kept, got %+v, %v", stored, err)
+	}
+	if w := do(srv, token, http.MethodPut, "/v2/raid/10.99999/owned", `{"identifier":{"id":"https://raid.org/10.99999/owned"}}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected the read-only RAiD not to be updated, got %d %s", w.Code, w.Body)
+	}
+
+	// A forced delete removes the service point after moving its RAiDs
+	merged, err := repo.CreateServicePoint(ctx, &raid.ServicePoint{Name: "Merged"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := repo.CreateServicePoint(ctx, &raid.ServicePoint{Name: "Kept"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRAiD(ctx, &raid.RAiD{Identifier: &raid.Identifier{
+		ID: "https://raid.org/10.99999/moved", Owner: &raid.Owner{ServicePoint: merged.ID},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(srv, token, http.MethodDelete, fmt.Sprintf("/v2/service-point/%d?force=true&transferTo=%d", merged.ID, kept.ID), ""); w.Code != http.StatusNoContent {
+		t.Fatalf("forced delete: %d %s", w.Code, w.Body)
+	}
+	if _, err := repo.GetServicePoint(ctx, merged.ID); err == nil {
+		t.Error("expected the service point to be removed")
+	}
+	if moved, err := repo.GetRAiD(ctx, "10.99999", "moved"); err != nil || moved.Identifier.Owner.ServicePoint != kept.ID {
+		t.Errorf("expected the RAiD to be transferred, got %+v, %v", moved, err)
+	}
+}
+
+func TestServer_SavedSearches(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "search-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for suffix, access := range map[string]string{"open": "https://vocabulary.raid.org/access.type.schema/82", "closed": "https://vocabulary.raid.org/access.type.schema/53"} {
+		if _, err := repo.CreateRAiD(ctx, &raid.RAiD{
+			Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/" + suffix},
+			Title:      []raid.Title{{Text: "Survey " + suffix}},
+			Access:     &raid.Access{Type: &raid.IDSchema{ID: access}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sp := int64(1001)
+	sign := func(user string, roles ...string) string {
+		claims := raidmw.Claims{UserID: user, ServicePointID: &sp, Roles: roles,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	alice, bob := sign("alice"), sign("bob", raidmw.RoleAdmin)
+	ids := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var raids []raid.RAiD
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&raids) != nil {
+			t.Fatalf("listing: %d %s", w.Code, w.Body)
+		}
+		var ids []string
+		for _, r := range raids {
+			ids = append(ids, r.Identifier.ID[len("https://raid.org/10.99999/"):])
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	if w := do(srv, "", http.MethodGet, "/v2/searches", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected searches to need authentication, got %d", w.Code)
+	}
+	for path, want := range map[string]int{
+		"/v2/searches/.hidden":                     http.StatusBadRequest,
+		"/v2/searches/bad":                         http.StatusBadRequest,
+		"/v2/searches/surveys?scope=service-point": http.StatusForbidden,
+	} {
+		body := `{"query":{"filter":"title[0].text.startsWith('Survey')"}}`
+		if path == "/v2/searches/bad" {
+			body = `{"query":{"filter":"title =="}}`
+		}
+		if w := do(srv, alice, http.MethodPut, path, body); w.Code != want {
+			t.Errorf("PUT %s: expected %d, got %d %s", path, want, w.Code, w.Body)
+		}
+	}
+
+	w := do(srv, alice, http.MethodPut, "/v2/searches/surveys", `{"query":{"filter":"title[0].text.startsWith('Survey')"}}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("save: %d %s", w.Code, w.Body)
+	}
+	if got := ids(do(srv, alice, http.MethodGet, "/v2/searches/surveys/results", "")); !slices.Equal(got, []string{"closed", "open"}) {
+		t.Errorf("expected both RAiDs, got %v", got)
+	}
+	if got := ids(do(srv, alice, http.MethodGet, "/v2/searches/surveys/results?limit=1", "")); len(got) != 1 {
+		t.Errorf("expected the request to page the results, got %v", got)
+	}
+	if w := do(srv, bob, http.MethodGet, "/v2/searches/surveys/results", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected another user not to see the search, got %d", w.Code)
+	}
+
+	// A service point search is shared by its members and published as a
+	// feed of its public RAiDs
+	w = do(srv, bob, http.MethodPut, "/v2/searches/surveys?scope=service-point", `{"query":{"filter":"size(title) > 0"},"public":true}`)
+	var shared raid.SavedSearch
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&shared) != nil || shared.Feed == "" {
+		t.Fatalf("save shared: %d %s", w.Code, w.Body)
+	}
+	if got := ids(do(srv, bob, http.MethodGet, "/v2/searches/surveys/results", "")); len(got) != 2 {
+		t.Errorf("expected both RAiDs, got %v", got)
+	}
+	if got := ids(do(srv, "", http.MethodGet, "/v2/feeds/"+shared.Feed, "")); !slices.Equal(got, []string{"open"}) {
+		t.Errorf("expected the feed to list the public RAiD, got %v", got)
+	}
+	var listed []raid.SavedSearch
+	if w := do(srv, alice, http.MethodGet, "/v2/searches", ""); json.NewDecoder(w.Body).Decode(&listed) != nil || len(listed) != 2 {
+		t.Errorf("expected alice to see her search and the shared one, got %s", w.Body)
+	}
+
+	if w := do(srv, bob, http.MethodPut, "/v2/searches/surveys?scope=service-point", `{"query":{"filter":"size(title) > 0"}}`); w.Code != http.StatusOK {
+		t.Fatalf("unpublish: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodGet, "/v2/feeds/"+shared.Feed, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the feed to be withdrawn, got %d", w.Code)
+	}
+	if w := do(srv, alice, http.MethodDelete, "/v2/searches/surveys", ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, alice, http.MethodDelete, "/v2/searches/surveys", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected a second delete to find nothing, got %d", w.Code)
+	}
+}
+
+func TestServer_Tags(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "tag-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sp, err := repo.CreateServicePoint(ctx, &raid.ServicePoint{Name: "Curators", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, suffix := range []string{"a", "b"} {
+		if _, err := repo.CreateRAiD(ctx, &raid.RAiD{
+			Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/" + suffix, Owner: &raid.Owner{ServicePoint: sp.ID}},
+			Access:     &raid.Access{Type: &raid.IDSchema{ID: "https://vocabulary.raid.org/access.type.schema/82"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sign := func(servicePoint int64) string {
+		claims := raidmw.Claims{UserID: "curator", ServicePointID: &servicePoint,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	member, outsider := sign(sp.ID), sign(sp.ID+1)
+	tags := func(w *httptest.ResponseRecorder) []string {
+		t.Helper()
+		var got struct{ Tags []string }
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+			t.Fatalf("tags: %d %s", w.Code, w.Body)
+		}
+		return got.Tags
+	}
+
+	if got := tags(do(srv, member, http.MethodPost, "/v2/raid/10.99999/a/tags", `{"tags":["Review", "pilot"]}`)); !slices.Equal(got, []string{"pilot", "review"}) {
+		t.Errorf("expected the tags in lower case and sorted, got %v", got)
+	}
+	tags(do(srv, member, http.MethodPost, "/v2/raid/10.99999/b/tags", `{"tags":["pilot"]}`))
+	for _, c := range []struct {
+		token, method, path, body string
+		want                      int
+	}{
+		{"", http.MethodGet, "/v2/raid/10.99999/a/tags", "", http.StatusUnauthorized},
+		{outsider, http.MethodGet, "/v2/raid/10.99999/a/tags", "", http.StatusForbidden},
+		{member, http.MethodPost, "/v2/raid/10.99999/a/tags", `{"tags":["no spaces"]}`, http.StatusBadRequest},
+		{member, http.MethodPost, "/v2/raid/10.99999/a/tags", `{"tags":[]}`, http.StatusBadRequest},
+		{member, http.MethodGet, "/v2/raid/10.99999/missing/tags", "", http.StatusNotFound},
+		{outsider, http.MethodGet, fmt.Sprintf("/v2/service-point/%d/raids?tag=pilot", sp.ID), "", http.StatusForbidden},
+	} {
+		if w := do(srv, c.token, c.method, c.path, c.body); w.Code != c.want {
+			t.Errorf("%s %s: expected %d, got %d %s", c.method, c.path, c.want, w.Code, w.Body)
+		}
+	}
+
+	listed := func(query string) []string {
+		t.Helper()
+		w := do(srv, member, http.MethodGet, fmt.Sprintf("/v2/service-point/%d/raids?%s", sp.ID, query), "")
+		var raids []raid.RAiD
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&raids) != nil {
+			t.Fatalf("listing: %d %s", w.Code, w.Body)
+		}
+		var ids []string
+		for _, r := range raids {
+			ids = append(ids, r.Identifier.ID[len("https://raid.org/10.99999/"):])
+		}
+		slices.Sort(ids)
+		return ids
+	}
+	if got := listed("tag=pilot"); !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("expected both pilot RAiDs, got %v", got)
+	}
+	if got := listed("tag=pilot&tag=REVIEW"); !slices.Equal(got, []string{"a"}) {
+		t.Errorf("expected the RAiD with both tags, got %v", got)
+	}
+
+	if got := tags(do(srv, member, http.MethodDelete, "/v2/raid/10.99999/a/tags/review", "")); !slices.Equal(got, []string{"pilot"}) {
+		t.Errorf("expected pilot to remain, got %v", got)
+	}
+	if got := listed("tag=review"); len(got) != 0 {
+		t.Errorf("expected no RAiD tagged review, got %v", got)
+	}
+	// Tags are not part of the RAiD
+	if stored, err := repo.GetRAiD(ctx, "10.99999", "a"); err != nil || stored.Identifier.Version != 1 {
+		t.Errorf("expected tagging to leave the RAiD alone, got %+v, %v", stored, err)
+	}
+}
+
+func TestServer_Drafts(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "draft-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp, err := repo.CreateServicePoint(context.Background(), &raid.ServicePoint{Name: "Stagers", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(servicePoint int64) string {
+		claims := raidmw.Claims{UserID: "stager", ServicePointID: &servicePoint,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	member, outsider := sign(sp.ID), sign(sp.ID+1)
+
+	// A draft that would fail validation is accepted, and takes no
+	// identifier
+	w := do(srv, member, http.MethodPost, "/v2/drafts", `{"title":[{"text":"Leaderless"}],
+		"contributor":[{"id":"https://orcid.org/0000-0000-0000-0001","contact":true}]}`)
+	var draft raid.Draft
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&draft) != nil {
+		t.Fatalf("create: %d %s", w.Code, w.Body)
+	}
+	if draft.ID == "" || draft.ServicePoint != sp.ID || draft.RAiD.Identifier != nil {
+		t.Errorf("unexpected draft: %+v", draft)
+	}
+	if raids, _ := repo.ListRAiDs(context.Background(), nil); len(raids) != 0 {
+		t.Errorf("expected drafts not to be RAiDs, got %d", len(raids))
+	}
+
+	if w := do(srv, outsider, http.MethodGet, "/v2/drafts/"+draft.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected drafts hidden from other service points, got %d", w.Code)
+	}
+	if w := do(srv, outsider, http.MethodGet, "/v2/drafts", ""); w.Code != http.StatusOK || strings.Contains(w.Body.String(), draft.ID) {
+		t.Errorf("expected other service points to list no drafts, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, member, http.MethodGet, "/v2/drafts", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), draft.ID) {
+		t.Errorf("expected the draft listed, got %d %s", w.Code, w.Body)
+	}
+
+	// Promotion validates, keeping the draft when that fails
+	if w := do(srv, member, http.MethodPost, "/v2/drafts/"+draft.ID+"/promote", ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected promoting an invalid draft to fail, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, member, http.MethodPut, "/v2/drafts/"+draft.ID, `{"title":[{"text":"Staged"}]}`); w.Code != http.StatusOK {
+		t.Fatalf("update: %d %s", w.Code, w.Body)
+	}
+	w = do(srv, member, http.MethodPost, "/v2/drafts/"+draft.ID+"/promote", "")
+	var minted raid.RAiD
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&minted) != nil {
+		t.Fatalf("promote: %d %s", w.Code, w.Body)
+	}
+	if minted.Identifier == nil || minted.Identifier.ID == "" || minted.Identifier.Owner.ServicePoint != sp.ID {
+		t.Errorf("expected a RAiD minted for the service point, got %+v", minted.Identifier)
+	}
+	if w := do(srv, member, http.MethodGet, "/v2/drafts/"+draft.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the draft gone once promoted, got %d", w.Code)
+	}
+}
+
+func TestServer_Readiness(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Server.ReadOnly = true
+	cfg.Server.ReadOnlyReason = "upgrade"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read-only degrades the server without taking it out of rotation
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report struct {
+		Ready  bool                       `json:"ready"`
+		Status string                     `json:"status"`
+		Checks map[string]json.RawMessage `json:"checks"`
+	}
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&report) != nil {
+		t.Fatalf("readyz: %d %s", w.Code, w.Body)
+	}
+	if !report.Ready || report.Status != "degraded" {
+		t.Errorf("expected a ready, degraded server, got %+v", report)
+	}
+	if !strings.Contains(string(report.Checks["storage"]), `"status":"ok"`) || !strings.Contains(string(report.Checks["maintenance"]), "upgrade") {
+		t.Errorf("unexpected checks %s", report.Checks)
+	}
+
+	// The same state over gRPC: a degraded server is SERVING
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", strings.NewReader("\x00\x00\x00\x00\x00"))
+	r.Header.Set("Content-Type", "application/grpc")
+	srv.GRPCHealth().ServeHTTP(w, r)
+	if got := w.Body.String(); got != "\x00\x00\x00\x00\x02\x08\x01" || w.Result().Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("expected SERVING, got %q %v", got, w.Result().Trailer)
+	}
+}
+
+func TestServer_Reservations(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "reservation-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sps []*raid.ServicePoint
+	for _, name := range []string{"Printers", "Others"} {
+		sp, err := repo.CreateServicePoint(context.Background(), &raid.ServicePoint{Name: name, Enabled: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		sps = append(sps, sp)
+	}
+
+	sign := func(servicePoint int64) string {
+		claims := raidmw.Claims{UserID: "reserver", ServicePointID: &servicePoint,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	member, other := sign(sps[0].ID), sign(sps[1].ID)
+
+	if w := do(srv, other, http.MethodPost, "/v2/raid/reserve", fmt.Sprintf(`{"servicePoint":%d}`, sps[0].ID)); w.Code != http.StatusForbidden {
+		t.Errorf("expected reserving for another service point forbidden, got %d", w.Code)
+	}
+	w := do(srv, member, http.MethodPost, "/v2/raid/reserve", "")
+	var reserved raid.Reservation
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&reserved) != nil {
+		t.Fatalf("reserve: %d %s", w.Code, w.Body)
+	}
+	if reserved.ID == "" || reserved.ServicePoint != sps[0].ID || !reserved.ExpiresAt.After(time.Now()) {
+		t.Errorf("unexpected reservation: %+v", reserved)
+	}
+	if raids, _ := repo.ListRAiDs(context.Background(), nil); len(raids) != 0 {
+		t.Errorf("expected a reservation to mint nothing, got %d RAiDs", len(raids))
+	}
+
+	// Only the holder mints the identifier
+	mint := func(sp int64) string {
+		return fmt.Sprintf(`{"identifier":{"id":%q,"owner":{"servicePoint":%d}},"title":[{"text":"Printed"}]}`, reserved.ID, sp)
+	}
+	if w := do(srv, other, http.MethodPost, "/v2/raid/", mint(sps[1].ID)); w.Code != http.StatusConflict {
+		t.Errorf("expected a reserved identifier refused to other service points, got %d %s", w.Code, w.Body)
+	}
+	w = do(srv, member, http.MethodPost, "/v2/raid/", mint(sps[0].ID))
+	var minted raid.RAiD
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&minted) != nil {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	if minted.Identifier.ID != reserved.ID {
+		t.Errorf("expected the reserved identifier minted, got %s", minted.Identifier.ID)
+	}
+	if w := do(srv, member, http.MethodGet, "/v2/raid/reserve/"+reserved.Prefix+"/"+reserved.Suffix, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the reservation released by the mint, got %d", w.Code)
+	}
+
+	// Reservations are managed, and minted, by their holders only
+	w = do(srv, member, http.MethodPost, "/v2/raid/reserve", "")
+	var second raid.Reservation
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&second) != nil {
+		t.Fatalf("reserve: %d %s", w.Code, w.Body)
+	}
+	path := "/v2/raid/reserve/" + second.Prefix + "/" + second.Suffix
+	if w := do(srv, other, http.MethodGet, path, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected reservations hidden from other service points, got %d", w.Code)
+	}
+	if w := do(srv, other, http.MethodPost, path+"/mint", `{"title":[{"text":"Taken"}]}`); w.Code != http.StatusNotFound {
+		t.Errorf("expected other service points not to mint a reservation, got %d", w.Code)
+	}
+	if w := do(srv, member, http.MethodGet, path, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), second.ID) {
+		t.Errorf("get: %d %s", w.Code, w.Body)
+	}
+	w = do(srv, member, http.MethodPost, path+"/mint", `{"title":[{"text":"Printed too"}]}`)
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&minted) != nil {
+		t.Fatalf("mint reservation: %d %s", w.Code, w.Body)
+	}
+	if minted.Identifier.ID != second.ID || minted.Identifier.Owner.ServicePoint != sps[0].ID {
+		t.Errorf("expected the reservation minted for its service point, got %+v", minted.Identifier)
+	}
+
+	w = do(srv, member, http.MethodPost, "/v2/raid/reserve", "")
+	var third raid.Reservation
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&third) != nil {
+		t.Fatalf("reserve: %d %s", w.Code, w.Body)
+	}
+	path = "/v2/raid/reserve/" + third.Prefix + "/" + third.Suffix
+	if w := do(srv, member, http.MethodDelete, path, ""); w.Code != http.StatusNoContent {
+		t.Errorf("release: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, other, http.MethodPost, "/v2/raid/", fmt.Sprintf(`{"identifier":{"id":%q,"owner":{"servicePoint":%d}},"title":[{"text":"Freed"}]}`, third.ID, sps[1].ID)); w.Code != http.StatusCreated {
+		t.Errorf("expected a released identifier free to mint, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_ScheduledPublication(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "schedule-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sp, err := repo.CreateServicePoint(context.Background(), &raid.ServicePoint{Name: "Announcers", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	servicePoint := sp.ID
+	claims := raidmw.Claims{UserID: "announcer", ServicePointID: &servicePoint,
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(srv, token, http.MethodPost, "/v2/raid/?effectiveAt=tomorrow", `{"title":[{"text":"Soon"}]}`); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a malformed effectiveAt, got %d", w.Code)
+	}
+	at := url.QueryEscape(time.Now().Add(time.Hour).Format(time.RFC3339))
+	w := do(srv, token, http.MethodPost, "/v2/raid/?effectiveAt="+at,
+		fmt.Sprintf(`{"identifier":{"id":"https://raid.org/10.99999/soon","owner":{"servicePoint":%d}},"title":[{"text":"Soon"}]}`, sp.ID))
+	var change raid.ScheduledChange
+	if w.Code != http.StatusAccepted || json.NewDecoder(w.Body).Decode(&change) != nil {
+		t.Fatalf("schedule: %d %s", w.Code, w.Body)
+	}
+	if change.Status != raid.SchedulePending || change.ServicePoint != sp.ID {
+		t.Errorf("unexpected change: %+v", change)
+	}
+	if w := do(srv, token, http.MethodGet, "/v2/raid/10.99999/soon", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the RAiD hidden until it takes effect, got %d", w.Code)
+	}
+	if w := do(srv, token, http.MethodGet, "/v2/scheduled", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), change.ID) {
+		t.Errorf("expected the change listed, got %d %s", w.Code, w.Body)
+	}
+	// Without an owner in the body the change belongs to the caller's
+	// service point, and is applied as the caller
+	w = do(srv, token, http.MethodPost, "/v2/raid/?effectiveAt="+at, `{"identifier":{"id":"https://raid.org/10.99999/unowned"},"title":[{"text":"Unowned"}]}`)
+	var unowned raid.ScheduledChange
+	if w.Code != http.StatusAccepted || json.NewDecoder(w.Body).Decode(&unowned) != nil {
+		t.Fatalf("schedule without an owner: %d %s", w.Code, w.Body)
+	}
+	stored, err := repo.(storage.ScheduleStore).GetScheduledChange(context.Background(), unowned.ID)
+	if err != nil || stored.Actor != "announcer" || stored.ServicePoint != sp.ID {
+		t.Errorf("expected the caller and their service point stored, got %+v %v", stored, err)
+	}
+	if w := do(srv, token, http.MethodGet, "/v2/scheduled", ""); !strings.Contains(w.Body.String(), unowned.ID) {
+		t.Errorf("expected the change listed, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, token, http.MethodDelete, "/v2/scheduled/"+unowned.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("cancel: %d %s", w.Code, w.Body)
+	}
+
+	if summary, err := srv.publisher.RunNow(context.Background()); err != nil || summary.Applied != 0 {
+		t.Errorf("expected nothing due yet, got %+v %v", summary, err)
+	}
+
+	// Once due the mint is applied and the change is gone
+	held, err := repo.(storage.ScheduleStore).GetScheduledChange(context.Background(), change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	held.EffectiveAt = time.Now().Add(-time.Second)
+	if err := repo.(storage.ScheduleStore).SaveScheduledChange(context.Background(), held); err != nil {
+		t.Fatal(err)
+	}
+	if summary, err := srv.publisher.RunNow(context.Background()); err != nil || summary.Applied != 1 {
+		t.Fatalf("expected the change applied, got %+v %v", summary, err)
+	}
+	if w := do(srv, token, http.MethodGet, "/v2/raid/10.99999/soon", ""); w.Code != http.StatusOK {
+		t.Errorf("expected the RAiD public once applied, got %d", w.Code)
+	}
+	if w := do(srv, token, http.MethodGet, "/v2/scheduled/"+change.ID, ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected the applied change gone, got %d", w.Code)
+	}
+
+	// A scheduled update leaves the current version public, and can be
+	// cancelled
+	w = do(srv, token, http.MethodPut, "/v2/raid/10.99999/soon?effectiveAt="+at,
+		fmt.Sprintf(`{"identifier":{"id":"https://raid.org/10.99999/soon","owner":{"servicePoint":%d}},"title":[{"text":"Later"}]}`, sp.ID))
+	if w.Code != http.StatusAccepted || json.NewDecoder(w.Body).Decode(&change) != nil {
+		t.Fatalf("schedule update: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, token, http.MethodGet, "/v2/raid/10.99999/soon", ""); !strings.Contains(w.Body.String(), "Soon") {
+		t.Errorf("expected the current version kept, got %s", w.Body)
+	}
+	if w := do(srv, token, http.MethodDelete, "/v2/scheduled/"+change.ID, ""); w.Code != http.StatusNoContent {
+		t.Errorf("cancel: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_Feeds(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, suffix := range []string{"older", "newer", "closed"} {
+		access := "https://vocabulary.raid.org/access.type.schema/82"
+		if suffix == "closed" {
+			access = "https://vocabulary.raid.org/access.type.schema/53"
+		}
+		if _, err := repo.CreateRAiD(ctx, &raid.RAiD{
+			Identifier:  &raid.Identifier{ID: "https://raid.org/10.99999/" + suffix},
+			Title:       []raid.Title{{Text: "Survey " + suffix}},
+			Description: []raid.Description{{Text: "About " + suffix}},
+			Access:      &raid.Access{Type: &raid.IDSchema{ID: access}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	older, err := repo.GetRAiD(ctx, "10.99999", "older")
+	if err != nil {
+		t.Fatal(err)
+	}
+	older.Title[0].Text = "Survey older, revised"
+	if _, err := repo.UpdateRAiD(ctx, "10.99999", "older", older); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/v2/raid/feed.atom")
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/atom+xml") {
+		t.Fatalf("atom feed: %d %s %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	var atom struct {
+		Entries []struct {
+			ID       string `xml:"id"`
+			Title    string `xml:"title"`
+			Category struct {
+				Term string `xml:"term,attr"`
+			} `xml:"category"`
+			Summary string `xml:"summary"`
+		} `xml:"entry"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &atom); err != nil {
+		t.Fatal(err)
+	}
+	if len(atom.Entries) != 2 {
+		t.Fatalf("expected the two public RAiDs, got %+v", atom.Entries)
+	}
+	if e := atom.Entries[0]; e.ID != "https://raid.org/10.99999/older" || e.Title != "Survey older, revised" || e.Category.Term != "updated" {
+		t.Errorf("expected the updated RAiD first, got %+v", e)
+	}
+	if e := atom.Entries[1]; e.ID != "https://raid.org/10.99999/newer" || e.Category.Term != "minted" || e.Summary != "About newer" {
+		t.Errorf("expected the minted RAiD second, got %+v", e)
+	}
+
+	if w := get("/v2/raid/feed.atom?limit=1"); !strings.Contains(w.Body.String(), "10.99999/older") || strings.Contains(w.Body.String(), "10.99999/newer") {
+		t.Errorf("expected limit to keep the newest entry only, got %s", w.Body)
+	}
+	if w := get("/v2/raid/feed.atom?servicePoint=42"); w.Code != http.StatusOK || strings.Contains(w.Body.String(), "<entry>") {
+		t.Errorf("expected no entries for another service point, got %d %s", w.Code, w.Body)
+	}
+	for _, path := range []string{"/v2/raid/feed.atom?limit=0", "/v2/raid/feed.rss?servicePoint=x"} {
+		if w := get(path); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", path, w.Code)
+		}
+	}
+
+	w = get("/v2/raid/feed.rss")
+	var rss struct {
+		Items []struct {
+			Link     string `xml:"link"`
+			Category string `xml:"category"`
+		} `xml:"channel>item"`
+	}
+	if err := xml.Unmarshal(w.Body.Bytes(), &rss); err != nil {
+		t.Fatal(err)
+	}
+	if len(rss.Items) != 2 || rss.Items[0].Link != "https://raid.org/10.99999/older" || rss.Items[1].Category != "minted" {
+		t.Errorf("unexpected RSS items %+v", rss.Items)
+	}
+}
+
+func TestServer_Search(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		open    = "https://vocabulary.raid.org/access.type.schema/82"
+		closed  = "https://vocabulary.raid.org/access.type.schema/53"
+		ecology = "https://linked.data.gov.au/def/anzsrc-for/2020/4102"
+		geology = "https://linked.data.gov.au/def/anzsrc-for/2020/3705"
+		uniA    = "https://ror.org/000000001"
+		uniB    = "https://ror.org/000000002"
+		base    = "https://raid.org/10.99999/"
+	)
+	for suffix, r := range map[string]struct {
+		access, start string
+		subjects      []string
+		orgs          []string
+	}{
+		"a": {open, "2021-02-01", []string{ecology, geology}, []string{uniA}},
+		"b": {open, "2021-06-01", []string{ecology}, []string{uniA, uniB}},
+		"c": {open, "2023-01-01", []string{geology}, nil},
+		"d": {closed, "2021-01-01", []string{ecology}, []string{uniA}},
+	} {
+		doc := &raid.RAiD{
+			Identifier: &raid.Identifier{ID: base + suffix, Owner: &raid.Owner{ServicePoint: 7}},
+			Title:      []raid.Title{{Text: "Survey " + suffix}},
+			Date:       &raid.Date{StartDate: r.start},
+			Access:     &raid.Access{Type: &raid.IDSchema{ID: r.access}},
+		}
+		for _, s := range r.subjects {
+			doc.Subject = append(doc.Subject, raid.Subject{ID: s})
+		}
+		for _, o := range r.orgs {
+			doc.Organisation = append(doc.Organisation, raid.Organisation{ID: o})
+		}
+		if _, err := repo.CreateRAiD(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	search := func(query string) raid.SearchResult {
+		t.Helper()
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/raid/search?"+query, nil))
+		var res raid.SearchResult
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&res) != nil {
+			t.Fatalf("search %s: %d %s", query, w.Code, w.Body)
+		}
+		return res
+	}
+
+	res := search("")
+	if res.Total != 3 || len(res.Hits) != 3 {
+		t.Fatalf("expected the three public RAiDs, got %d %d", res.Total, len(res.Hits))
+	}
+	for facet, want := range map[string][]raid.FacetCount{
+		raid.FacetSubject:      {{Value: geology, Count: 2}, {Value: ecology, Count: 2}},
+		raid.FacetOrganisation: {{Value: uniA, Count: 2}, {Value: uniB, Count: 1}},
+		raid.FacetAccessType:   {{Value: open, Count: 3}},
+		raid.FacetStartYear:    {{Value: "2021", Count: 2}, {Value: "2023", Count: 1}},
+		raid.FacetServicePoint: {{Value: "7", Count: 3}},
+	} {
+		if got := res.Facets[facet]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: expected %v, got %v", facet, want, got)
+		}
+	}
+
+	res = search("startYear=2021&subject.id=" + url.QueryEscape(ecology) + "&facets=organisation&facet.limit=1&limit=1")
+	if res.Total != 2 || len(res.Hits) != 1 || !res.Page.HasMore {
+		t.Errorf("expected the first of two hits, got %d %d %+v", res.Total, len(res.Hits), res.Page)
+	}
+	if want := map[string][]raid.FacetCount{raid.FacetOrganisation: {{Value: uniA, Count: 2}}}; !reflect.DeepEqual(res.Facets, want) {
+		t.Errorf("expected %v, got %v", want, res.Facets)
+	}
+	if res = search("accessType=" + url.QueryEscape(closed)); res.Total != 0 {
+		t.Errorf("expected closed RAiDs to stay hidden, got %d", res.Total)
+	}
+
+	for _, query := range []string{"facets=colour", "facet.limit=0", "servicePoint=x", "limit=1000"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/raid/search?"+query, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestServer_Aggregate(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const (
+		ecology = "https://linked.data.gov.au/def/anzsrc-for/2020/4102"
+		geology = "https://linked.data.gov.au/def/anzsrc-for/2020/3705"
+		uniA    = "https://ror.org/000000001"
+	)
+	for suffix, r := range map[string]struct {
+		start    string
+		sp       int64
+		subjects []string
+	}{
+		"a": {"2021-02-01", 7, []string{ecology, geology, ecology}},
+		"b": {"2021-06-01", 7, []string{ecology}},
+		"c": {"2023-01-01", 8, []string{ecology}},
+		"d": {"2019-05-01", 8, nil},
+	} {
+		doc := &raid.RAiD{
+			Identifier:   &raid.Identifier{ID: "https://raid.org/10.99999/" + suffix, Owner: &raid.Owner{ServicePoint: r.sp}},
+			Title:        []raid.Title{{Text: "Survey " + suffix}},
+			Date:         &raid.Date{StartDate: r.start},
+			Access:       &raid.Access{Type: &raid.IDSchema{ID: "https://vocabulary.raid.org/access.type.schema/53"}},
+			Organisation: []raid.Organisation{{ID: uniA}},
+		}
+		for _, s := range r.subjects {
+			doc.Subject = append(doc.Subject, raid.Subject{ID: s})
+		}
+		if _, err := repo.CreateRAiD(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	aggregate := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/raid/aggregate?"+query, nil))
+		return w
+	}
+	for query, want := range map[string][]raid.AggregateGroup{
+		"groupBy=subject":                                        {{Key: ecology, Value: 3}, {Key: geology, Value: 1}},
+		"groupBy=organisation&metric=count":                      {{Key: uniA, Value: 4}},
+		"groupBy=year":                                           {{Key: "2019", Value: 1}, {Key: "2021", Value: 2}, {Key: "2023", Value: 1}},
+		"groupBy=year&servicePoint=8":                            {{Key: "2019", Value: 1}, {Key: "2023", Value: 1}},
+		"groupBy=subject&subject.id=" + url.QueryEscape(geology): {{Key: geology, Value: 1}, {Key: ecology, Value: 1}},
+	} {
+		w := aggregate(query)
+		var got raid.Aggregate
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+			t.Fatalf("%s: %d %s", query, w.Code, w.Body)
+		}
+		if got.Metric != raid.MetricCount || !reflect.DeepEqual(got.Groups, want) {
+			t.Errorf("%s: expected %v, got %s %v", query, want, got.Metric, got.Groups)
+		}
+	}
+	for _, query := range []string{"", "groupBy=colour", "groupBy=year&metric=sum", "groupBy=year&servicePoint=x"} {
+		if w := aggregate(query); w.Code != http.StatusBadRequest {
+			t.Errorf("%q: expected 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func TestServer_FindRAiDAsOf(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	v1, err := srv.repo.CreateRAiD(ctx, &raid.RAiD{
+		Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/asof"},
+		Title:      []raid.Title{{Text: "First title"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	v1.Title[0].Text = "Second title"
+	v2, err := srv.repo.UpdateRAiD(ctx, "10.99999", "asof", v1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(asOf string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v2/raid/10.99999/asof?asOf="+url.QueryEscape(asOf), nil))
+		return w
+	}
+	between := v2.Metadata.Updated.Add(-10 * time.Millisecond).Format(time.RFC3339Nano)
+	for asOf, want := range map[string]string{
+		between: "First title",
+		v2.Metadata.Updated.Format(time.RFC3339Nano):   "Second title",
+		time.Now().Add(time.Hour).Format(time.RFC3339): "Second title",
+	} {
+		w := get(asOf)
+		var got raid.RAiD
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+			t.Fatalf("asOf %s: %d %s", asOf, w.Code, w.Body)
+		}
+		if got.Title[0].Text != want {
+			t.Errorf("asOf %s: expected %q, got %q", asOf, want, got.Title[0].Text)
+		}
+	}
+	if loc := get(between).Header().Get("Content-Location"); !strings.HasSuffix(loc, "raid/10.99999/asof/1") {
+		t.Errorf("expected the version to be named, got %q", loc)
+	}
+	for asOf, want := range map[string]int{"2000-01-01": http.StatusNotFound, "yesterday": http.StatusBadRequest} {
+		if w := get(asOf); w.Code != want {
+			t.Errorf("asOf %s: expected %d, got %d", asOf, want, w.Code)
+		}
+	}
+}
+
+func TestServer_Memento(t *testing.T) {
+	srv := newTestServer(t)
+	ctx := context.Background()
+	v1, err := srv.repo.CreateRAiD(ctx, &raid.RAiD{
+		Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/memento"},
+		Title:      []raid.Title{{Text: "First title"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v1.Title[0].Text = "Second title"
+	if _, err := srv.repo.UpdateRAiD(ctx, "10.99999", "memento", v1); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path, acceptDatetime string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptDatetime != "" {
+			r.Header.Set("Accept-Datetime", acceptDatetime)
+		}
+		srv.ServeHTTP(w, r)
+		return w
+	}
+
+	w := get("/v2/raid/10.99999/memento/timemap", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/link-format" {
+		t.Fatalf("timemap: %d %s", w.Code, w.Body)
+	}
+	for _, want := range []string{
+		`<http://example.com/v2/raid/10.99999/memento>; rel="original"`,
+		`<http://example.com/v2/raid/10.99999/memento/timegate>; rel="timegate"`,
+		`<http://example.com/v2/raid/10.99999/memento/1>; rel="first memento"; datetime="`,
+		`<http://example.com/v2/raid/10.99999/memento/2>; rel="last memento"; datetime="`,
+	} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("expected the timemap to have %s, got %s", want, w.Body)
+		}
+	}
+
+	for acceptDatetime, want := range map[string]string{
+		"":                              "/v2/raid/10.99999/memento/2",
+		"Sat, 01 Jan 2000 00:00:00 GMT": "/v2/raid/10.99999/memento/1",
+		time.Now().Add(time.Hour).UTC().Format(http.TimeFormat): "/v2/raid/10.99999/memento/2",
+	} {
+		w := get("/v2/raid/10.99999/memento/timegate", acceptDatetime)
+		if w.Code != http.StatusFound || w.Header().Get("Location") != "http://example.com"+want || w.Header().Get("Vary") != "accept-datetime" {
+			t.Errorf("Accept-Datetime %q: expected a redirect to %s, got %d %v", acceptDatetime, want, w.Code, w.Header())
+		}
+	}
+	if w := get("/raid/10.99999/memento/timegate", "yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid Accept-Datetime, got %d", w.Code)
+	}
+
+	w = get("/raid/10.99999/memento/1", "")
+	if w.Header().Get("Memento-Datetime") == "" || !strings.Contains(strings.Join(w.Header().Values("Link"), ", "), `<http://example.com/raid/10.99999/memento>; rel="original"`) {
+		t.Errorf("expected the version to be a memento, got %v", w.Header())
+	}
+	if links := get("/10.99999/memento", "").Header().Values("Link"); !strings.Contains(strings.Join(links, ", "), `<http://example.com/raid/10.99999/memento/timegate>; rel="timegate"`) {
+		t.Errorf("expected the RAiD to link its timegate, got %q", links)
+	}
+}
+
+func TestServer_LanguageSelection(t *testing.T) {
+	srv := newTestServer(t)
+	if _, err := srv.repo.CreateRAiD(context.Background(), &raid.RAiD{
+		Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/lang"},
+		Title: []raid.Title{
+			{Text: "Glaciers", Language: &raid.Language{ID: "eng"}},
+			{Text: "Glaciärer", Language: &raid.Language{ID: "swe"}},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query, acceptLanguage string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v2/raid/10.99999/lang"+query, nil)
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+	titles := func(w *httptest.ResponseRecorder) string {
+		var got raid.RAiD
+		if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		var texts []string
+		for _, title := range got.Title {
+			texts = append(texts, title.Text)
+		}
+		return strings.Join(texts, ", ")
+	}
+
+	for _, tc := range []struct{ query, acceptLanguage, want string }{
+		{"?lang=swe", "", "Glaciärer"},
+		{"?lang=fin,eng", "", "Glaciers"},
+		{"", "sv-SE, en;q=0.8", "Glaciärer"},
+		{"", "", "Glaciers, Glaciärer"},
+		{"?lang=*", "sv", "Glaciers, Glaciärer"},
+		{"?lang=fin", "", "Glaciers"},
+	} {
+		if got := titles(get(tc.query, tc.acceptLanguage)); got != tc.want {
+			t.Errorf("%q with Accept-Language %q: expected %q, got %q", tc.query, tc.acceptLanguage, tc.want, got)
+		}
+	}
+	if vary := get("", "sv").Header().Get("Vary"); !strings.Contains(vary, "Accept-Language") {
+		t.Errorf("expected responses to vary by Accept-Language, got %q", vary)
+	}
+	if w := get("?lang=xx", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown language, got %d", w.Code)
+	}
+}
+
+func TestServer_TextNormalization(t *testing.T) {
+	srv := newTestServer(t)
+
+	// The title and first keyword are typed with combining marks
+	body := `{"identifier":{"id":"https://raid.org/10.99999/glacier"},"title":[{"text":"Ekstro\u0308m glacier survey"}],
+		"subject":[{"id":"https://linked.data.gov.au/def/anzsrc-for/2020/3709","keyword":[{"text":"Glaciologi\u0301a"},{"text":"glaciologia"}]}]}`
+	if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+
+	var got raid.RAiD
+	if w := do(srv, "", http.MethodGet, "/raid/10.99999/glacier", ""); w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&got) != nil {
+		t.Fatalf("get: %d %s", w.Code, w.Body)
+	}
+	if got.Title[0].Text != "Ekström glacier survey" {
+		t.Errorf("expected the title in NFC, got %+q", got.Title[0].Text)
+	}
+	if len(got.Subject[0].Keyword) != 1 || got.Subject[0].Keyword[0].Text != "glaciología" {
+		t.Errorf("expected keywords differing only in case and diacritics to be merged, got %+v", got.Subject[0].Keyword)
+	}
+
+	count := func(query string) int {
+		w := do(srv, "", http.MethodGet, "/raid/?"+query, "")
+		var raids []raid.RAiD
+		if err := json.NewDecoder(w.Body).Decode(&raids); err != nil {
+			t.Fatalf("%s: %d %v", query, w.Code, err)
+		}
+		return len(raids)
+	}
+	for query, want := range map[string]int{
+		"q=ekstrom": 1,
+		"q=" + url.QueryEscape("EKSTRÖM GLACIER"): 1,
+		"q=ekstrand":                  0,
+		"subject.keyword=glaciologia": 1,
+		"subject.keyword=GLACIOLOGÍA": 1,
+	} {
+		if got := count(query); got != want {
+			t.Errorf("%s: expected %d RAiDs, got %d", query, want, got)
+		}
+	}
+}
+
+func TestServer_Signing(t *testing.T) {
+	_, key, _ := ed25519.GenerateKey(rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Signing.Key = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := do(srv, "", http.MethodGet, signing.JWKSPath, "")
+	var set signing.JWKS
+	if err := json.NewDecoder(w.Body).Decode(&set); err != nil {
+		t.Fatalf("jwks: %d %v", w.Code, err)
+	}
+	keys, err := set.PublicKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(srv, "", http.MethodPost, "/raid/", `{"identifier":{"id":"https://raid.org/10.99999/signed"},"title":[{"text":"Signed"}]}`); w.Code != http.StatusCreated {
+		t.Fatalf("mint: %d %s", w.Code, w.Body)
+	}
+	for _, path := range []string{"/raid/10.99999/signed", "/v2/raid/10.99999/signed", "/10.99999/signed"} {
+		w := do(srv, "", http.MethodGet, path, "")
+		if err := signing.VerifyJWS(keys, w.Body.Bytes(), w.Header().Get(signing.JWSHeader)); err != nil {
+			t.Errorf("%s: expected a verifiable signature, got %v", path, err)
+		}
+	}
+	if w := do(srv, "", http.MethodGet, "/raid/10.99999/missing", ""); w.Header().Get(signing.JWSHeader) != "" {
+		t.Errorf("expected errors to be left unsigned")
+	}
+}
+
+func TestServer_COARNotify(t *testing.T) {
+	var mu sync.Mutex
+	var sent []*coarnotify.Notification
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		n, err := coarnotify.Parse(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		sent = append(sent, n)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer target.Close()
+
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "notify-secret"
+	cfg.COARNotify.Inbox = true
+	cfg.COARNotify.BaseURL = "https://raid.example.org"
+	cfg.COARNotify.Targets = []string{target.URL + "/inbox"}
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sp, err := repo.CreateServicePoint(ctx, &raid.ServicePoint{Name: "Curators", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRAiD(ctx, &raid.RAiD{
+		Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/a", Owner: &raid.Owner{ServicePoint: sp.ID}},
+		Access:     &raid.Access{Type: &raid.IDSchema{ID: storage.AccessTypeOpen}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(servicePoint int64) string {
+		claims := raidmw.Claims{UserID: "curator", ServicePointID: &servicePoint,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	member, outsider := sign(sp.ID), sign(sp.ID+1)
+
+	announcement := `{
+		"@context": ["https://www.w3.org/ns/activitystreams", "https://coar-notify.net"],
+		"id": "urn:uuid:6908e2d0-ab41-4fbf-8b27-e6d6cf1f7b95",
+		"type": ["Announce", "coar-notify:RelationshipAction"],
+		"origin": {"id": "https://repository.example.org", "inbox": "https://repository.example.org/inbox", "type": "Service"},
+		"target": {"id": "https://raid.example.org", "inbox": "https://raid.example.org/inbox", "type": "Service"},
+		"object": {
+			"id": "urn:uuid:74fb13a5-8ff3-4f1b-a8a6-5d1b8b0cf4d8",
+			"type": "Relationship",
+			"as:subject": "https://doi.org/10.5555/article",
+			"as:relationship": "http://purl.org/vocab/frbr/core#supplementOf",
+			"as:object": "https://raid.org/10.99999/a"
+		},
+		"context": {"id": "https://doi.org/10.5555/article", "type": ["sorg:AboutPage", "sorg:ScholarlyArticle"]}
+	}`
+	w := do(srv, "", http.MethodPost, "/inbox", announcement, "Content-Type", coarnotify.ContentType)
+	if w.Code != http.StatusCreated || w.Header().Get("Location") == "" {
+		t.Fatalf("inbox: %d %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	id := location[strings.LastIndex(location, "/")+1:]
+	if w := do(srv, "", http.MethodPost, "/inbox", announcement, "Content-Type", coarnotify.ContentType); w.Code != http.StatusAccepted {
+		t.Errorf("expected a repeated announcement to be accepted once, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodPost, "/inbox", strings.Replace(announcement, "10.99999/a", "10.99999/missing", 1), "Content-Type", coarnotify.ContentType); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for a RAiD not held here, got %d", w.Code)
+	}
+
+	if w := do(srv, member, http.MethodGet, "/inbox", "", "Content-Type", coarnotify.ContentType); !strings.Contains(w.Body.String(), "/proposals/"+id) {
+		t.Errorf("expected the proposal in the member's inbox, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodGet, "/inbox", "", "Content-Type", coarnotify.ContentType); strings.Contains(w.Body.String(), id) {
+		t.Errorf("expected anonymous callers to see no proposals, got %s", w.Body)
+	}
+	if w := do(srv, outsider, http.MethodGet, "/proposals/"+id, "", "Content-Type", coarnotify.ContentType); w.Code != http.StatusNotFound {
+		t.Errorf("expected another service point's proposal to be hidden, got %d", w.Code)
+	}
+
+	w = do(srv, member, http.MethodPost, "/proposals/"+id+"/accept", "", "Content-Type", coarnotify.ContentType)
+	var accepted raid.RAiD
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&accepted) != nil {
+		t.Fatalf("accept: %d %s", w.Code, w.Body)
+	}
+	if len(accepted.RelatedObject) != 1 || accepted.RelatedObject[0].ID != "https://doi.org/10.5555/article" ||
+		accepted.RelatedObject[0].Type == nil || !strings.HasSuffix(accepted.RelatedObject[0].Type.ID, "/250") {
+		t.Errorf("expected the article to be related, got %+v", accepted.RelatedObject)
+	}
+	if w := do(srv, member, http.MethodGet, "/proposals/"+id, "", "Content-Type", coarnotify.ContentType); w.Code != http.StatusNotFound {
+		t.Errorf("expected the accepted proposal to be dropped, got %d", w.Code)
+	}
+
+	// The related object added is announced to the target
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(sent) != 1 || sent[0].Object.Subject != "https://raid.org/10.99999/a" || sent[0].Object.Object != "https://doi.org/10.5555/article" {
+		t.Fatalf("expected the related object to be announced, got %+v", sent)
+	}
+	if sent[0].Origin.Inbox != "https://raid.example.org/inbox" {
+		t.Errorf("expected the origin inbox to be ours, got %q", sent[0].Origin.Inbox)
+	}
+}
+
+func TestServer_ActivityPub(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.ActivityPub.BaseURL = "https://raid.example.org"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, body := range []string{
+		`{"identifier":{"id":"https://raid.org/10.99999/open"},"title":[{"text":"Open"}],"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/82"}}}`,
+		`{"identifier":{"id":"https://raid.org/10.99999/closed"},"title":[{"text":"Closed"}],"access":{"type":{"id":"https://vocabulary.raid.org/access.type.schema/53"},"embargoExpiry":"2099-01-01"}}`,
+	} {
+		if w := do(srv, "", http.MethodPost, "/raid/", body); w.Code != http.StatusCreated {
+			t.Fatalf("mint: %d %s", w.Code, w.Body)
+		}
+	}
+
+	w := do(srv, "", http.MethodGet, "/activitypub/outbox", "")
+	var outbox struct {
+		TotalItems   int
+		OrderedItems []activitypub.Activity
+	}
+	if w.Header().Get("Content-Type") != activitypub.ContentType || json.NewDecoder(w.Body).Decode(&outbox) != nil {
+		t.Fatalf("outbox: %d %s", w.Code, w.Body)
+	}
+	if outbox.TotalItems != 1 || outbox.OrderedItems[0].Type != activitypub.TypeCreate ||
+		outbox.OrderedItems[0].Actor != "https://raid.example.org"+activitypub.ActorPath {
+		t.Errorf("expected the public mint only, got %+v", outbox)
+	}
+
+	var actor activitypub.Actor
+	if w := do(srv, "", http.MethodGet, "/v2/activitypub/actor", ""); json.NewDecoder(w.Body).Decode(&actor) != nil || actor.Outbox != "https://raid.example.org"+activitypub.OutboxPath {
+		t.Errorf("expected the actor to link its outbox, got %+v", actor)
+	}
+	if actor.PublicKey != nil {
+		t.Error("expected no follows to be taken without a key")
+	}
+	if w := do(srv, "", http.MethodPost, "/activitypub/inbox", `{"type":"Follow"}`); w.Code != http.StatusForbidden {
+		t.Errorf("expected follows to be refused without a key, got %d", w.Code)
+	}
+	w = do(srv, "", http.MethodGet, "/.well-known/webfinger?resource=acct:raid@raid.example.org", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), actor.ID) {
+		t.Errorf("expected WebFinger to resolve the actor, got %d %s", w.Code, w.Body)
+	}
+}
+
+func TestServer_Attachments(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "attachment-secret"
+	cfg.Attachments.Store = "backend"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sp, err := repo.CreateServicePoint(ctx, &raid.ServicePoint{Name: "Planners", Enabled: true,
+		Attachments: &models.AttachmentPolicy{MediaTypes: []string{"text/*", "application/pdf"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for suffix, accessType := range map[string]string{"open": storage.AccessTypeOpen, "closed": "https://vocabulary.raid.org/access.type.schema/53"} {
+		if _, err := repo.CreateRAiD(ctx, &raid.RAiD{
+			Identifier: &raid.Identifier{ID: "https://raid.org/10.99999/" + suffix, Owner: &raid.Owner{ServicePoint: sp.ID}},
+			Access:     &raid.Access{Type: &raid.IDSchema{ID: accessType}, EmbargoExpiry: "2099-01-01"},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sign := func(servicePoint int64) string {
+		claims := raidmw.Claims{UserID: "planner", ServicePointID: &servicePoint,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	member, outsider := sign(sp.ID), sign(sp.ID+1)
+	upload := func(token, path, name, mediaType, content string, fields ...string) *httptest.ResponseRecorder {
+		var body strings.Builder
+		form := multipart.NewWriter(&body)
+		part, _ := form.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {fmt.Sprintf(`form-data; name="file"; filename=%q`, name)},
+			"Content-Type":        {mediaType},
+		})
+		part.Write([]byte(content))
+		for i := 0; i+1 < len(fields); i += 2 {
+			form.WriteField(fields[i], fields[i+1])
+		}
+		form.Close()
+		return do(srv, token, http.MethodPost, path, body.String(), "Content-Type", form.FormDataContentType())
+	}
+
+	w := upload(member, "/v2/raid/10.99999/open/attachments", "plan.txt", "text/plain", "data plan",
+		"sha256", attachment.Hash([]byte("data plan")), "type", "https://vocabulary.raid.org/related-object.type.schema/247")
+	var plan models.Attachment
+	if w.Code != http.StatusCreated || json.NewDecoder(w.Body).Decode(&plan) != nil || plan.Hash != attachment.Hash([]byte("data plan")) {
+		t.Fatalf("expected the document to be attached, got %d %s", w.Code, w.Body)
+	}
+	related, err := repo.GetRAiD(ctx, "10.99999", "open")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(related.RelatedObject) != 1 || related.RelatedObject[0].ID != "http://example.com/v2/raid/10.99999/open/attachments/"+plan.Hash ||
+		related.RelatedObject[0].Type == nil {
+		t.Errorf("expected the attachment to be related by its URL, got %+v", related.RelatedObject)
+	}
+
+	for _, tc := range []struct {
+		name, token, mediaType, content string
+		fields                          []string
+		code                            int
+	}{
+		{"outsider", outsider, "text/plain", "data plan", nil, http.StatusForbidden},
+		{"media type", member, "application/zip", "PK", nil, http.StatusUnsupportedMediaType},
+		{"checksum", member, "text/plain", "data plan", []string{"sha256", attachment.Hash(nil)}, http.StatusUnprocessableEntity},
+		{"too large", member, "text/plain", strings.Repeat("x", int(cfg.Attachments.MaxBytes)+1), nil, http.StatusRequestEntityTooLarge},
+	} {
+		if w := upload(tc.token, "/v2/raid/10.99999/open/attachments", "doc", tc.mediaType, tc.content, tc.fields...); w.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d %s", tc.name, tc.code, w.Code, w.Body)
+		}
+	}
+
+	w = do(srv, "", http.MethodGet, "/v2/raid/10.99999/open/attachments", "")
+	var list []models.Attachment
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&list) != nil || len(list) != 1 || list[0].Hash != plan.Hash {
+		t.Fatalf("expected the attachment to be listed, got %d %s", w.Code, w.Body)
+	}
+	path := "/v2/raid/10.99999/open/attachments/" + plan.Hash
+	w = do(srv, "", http.MethodGet, path, "")
+	if w.Code != http.StatusOK || w.Body.String() != "data plan" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Fatalf("expected the content, got %d %s", w.Code, w.Body)
+	}
+	if w.Header().Get("Content-Disposition") != `attachment; filename=plan.txt` {
+		t.Errorf("expected the document's name, got %q", w.Header().Get("Content-Disposition"))
+	}
+	if w := do(srv, "", http.MethodGet, path, "", "If-None-Match", w.Header().Get("ETag")); w.Code != http.StatusNotModified {
+		t.Errorf("expected content to be revalidated by its hash, got %d", w.Code)
+	}
+	if w := do(srv, "", http.MethodGet, "/v2/raid/10.99999/open/attachments/"+attachment.Hash(nil), ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for content not attached, got %d", w.Code)
+	}
+
+	if w := upload(member, "/v2/raid/10.99999/closed/attachments", "minutes.txt", "application/octet-stream", "minutes", "relate", "false"); w.Code != http.StatusCreated {
+		t.Fatalf("expected the document to be attached, got %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "", http.MethodGet, "/v2/raid/10.99999/closed/attachments", ""); w.Code != http.StatusForbidden {
+		t.Errorf("expected the attachments of a closed RAiD to be hidden, got %d", w.Code)
+	}
+	w = do(srv, member, http.MethodGet, "/v2/raid/10.99999/closed/attachments", "")
+	if w.Code != http.StatusOK || json.NewDecoder(w.Body).Decode(&list) != nil || len(list) != 1 || !strings.HasPrefix(list[0].MediaType, "text/plain") {
+		t.Errorf("expected members to see the attachment with its detected type, got %d %s", w.Code, w.Body)
+	}
+	if closed, _ := repo.GetRAiD(ctx, "10.99999", "closed"); len(closed.RelatedObject) != 0 {
+		t.Errorf("expected relate=false to leave the RAiD as it is, got %+v", closed.RelatedObject)
+	}
+	if w := do(srv, "", http.MethodGet, "/v2/raid/10.99999/missing/attachments", ""); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing RAiD, got %d", w.Code)
+	}
+}
+
+func TestServer_CacheInvalidation(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "operator-secret"
+	cfg.Cache.Store = "memory"
+	cfg.Cache.TTL = time.Hour
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, suffix := range []string{"redacted", "purged"} {
+		if _, err := repo.CreateRAiD(ctx, &raid.RAiD{
+			Identifier:  &raid.Identifier{ID: "https://raid.org/10.99999/" + suffix},
+			Contributor: []raid.Contributor{{ID: "https://orcid.org/0000-0002-1825-0097", Email: "jane@example.org"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claims := raidmw.Claims{UserID: "ops", Roles: []string{raidmw.RoleOperator},
+		RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(suffix string) (int, string) {
+		w := do(srv, token, http.MethodGet, "/v2/raid/10.99999/"+suffix, "")
+		return w.Code, w.Body.String()
+	}
+
+	// Cached by reading, then redacted around the cache
+	if code, body := get("redacted"); code != http.StatusOK || !strings.Contains(body, "jane@example.org") {
+		t.Fatalf("read: %d %s", code, body)
+	}
+	if w := do(srv, token, http.MethodPost, "/admin/redactions", `{"contributor":{"email":"jane@example.org"},"raids":["10.99999/redacted"],"reason":"erasure request"}`); w.Code != http.StatusOK {
+		t.Fatalf("redact: %d %s", w.Code, w.Body)
+	}
+	if code, body := get("redacted"); code != http.StatusOK || strings.Contains(body, "jane@example.org") {
+		t.Errorf("expected the redacted RAiD not to be served from the cache, got %d %s", code, body)
+	}
+
+	if code, body := get("purged"); code != http.StatusOK {
+		t.Fatalf("read: %d %s", code, body)
+	}
+	if w := do(srv, token, http.MethodDelete, "/v2/raid/10.99999/purged", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("delete: %d %s", w.Code, w.Body)
+	}
+	deleted, _ := get("purged")
+	if w := do(srv, token, http.MethodPost, "/admin/raids/10.99999/purged/purge", `{"reason":"erasure request"}`); w.Code != http.StatusOK {
+		t.Fatalf("purge: %d %s", w.Code, w.Body)
+	}
+	if code, body := get("purged"); code != http.StatusNotFound {
+		t.Errorf("expected the purged RAiD not to be served from the cache after %d, got %d %s", deleted, code, body)
+	}
+}
+
+func TestServer_Debug(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Storage.Type = "file"
+	cfg.Storage.File.DataDir = t.TempDir()
+	cfg.Auth.Enabled = true
+	cfg.Auth.JWTSecret = "operator-secret"
+	repo, err := NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, err := New(cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := func(roles ...string) string {
+		claims := raidmw.Claims{UserID: "someone", Roles: roles,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}}
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.Auth.JWTSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return "Bearer " + signed
+	}
+	for _, path := range []string{"/debug/storage", "/debug/vars", "/debug/pprof/"} {
+		for auth, want := range map[string]int{
+			"":                         http.StatusUnauthorized,
+			"Bearer garbage":           http.StatusUnauthorized,
+			token():                    http.StatusForbidden,
+			token(raidmw.RoleAdmin):    http.StatusForbidden,
+			token(raidmw.RoleOperator): http.StatusOK,
+		} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			if auth != "" {
+				r.Header.Set("Authorization", auth)
+			}
+			srv.ServeHTTP(w, r)
+			if w.Code != want {
+				t.Errorf("GET %s: expected %d, got %d", path, want, w.Code)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/debug/storage", nil)
+	r.Header.Set("Authorization", token(raidmw.RoleOperator))
+	srv.ServeHTTP(w, r)
+	var diagnostics struct {
+		Type    string         `json:"type"`
+		Healthy bool           `json:"healthy"`
+		Stats   map[string]any `json:"stats"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&diagnostics); err != nil || diagnostics.Type != "file" || !diagnostics.Healthy || diagnostics.Stats == nil {
+		t.Errorf("unexpected storage diagnostics %+v, %v", diagnostics, err)
+	}
+
+	// Without authentication there are no operators
+	w = httptest.NewRecorder()
+	newTestServer(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected diagnostics closed with authentication disabled, got %d", w.Code)
+	}
+}
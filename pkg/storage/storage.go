@@ -0,0 +1,114 @@
+// Package storage lets third parties implement go-RAiD storage backends out
+// of tree.
+//
+// A backend is a Go package that implements Repository and registers a
+// factory under a storage type name, usually from its init function:
+//
+//	func init() {
+//		storage.Register("dynamodb", func(opts storage.Options) (storage.Repository, error) {
+//			var cfg struct {
+//				Table  string `json:"table"`
+//				Region string `json:"region"`
+//			}
+//			if err := opts.Decode(&cfg); err != nil {
+//				return nil, err
+//			}
+//			return open(cfg.Table, cfg.Region)
+//		})
+//	}
+//
+// A program that embeds go-RAiD (see package server) blank-imports the
+// backend, and the configuration selects it by name and passes it the
+// storage options unchanged:
+//
+//	storage:
+//	  type: dynamodb
+//	  options:
+//	    table: raids
+//	    region: eu-north-1
+//
+// Backends may also implement the optional interfaces of the built-in
+// backends, such as Snapshotter for backup and restore and StatsProvider for
+// diagnostics; the server detects them by type assertion. The model types in
+// the interfaces are those of package raid.
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+type (
+	// Repository is the interface every backend implements
+	Repository = storage.Repository
+	// RAiDFilter holds the filters of a RAiD listing
+	RAiDFilter = storage.RAiDFilter
+	// RAiDRecord is a RAiD with its complete version history
+	RAiDRecord = storage.RAiDRecord
+	// Snapshotter is implemented by backends that support full export and
+	// import, used for backup, restore and migration
+	Snapshotter = storage.Snapshotter
+	// StatsProvider is implemented by backends that expose diagnostics
+	StatsProvider = storage.StatsProvider
+)
+
+// Errors backends return; callers match them with errors.Is
+var (
+	ErrNotFound       = storage.ErrNotFound
+	ErrAlreadyExists  = storage.ErrAlreadyExists
+	ErrInvalidVersion = storage.ErrInvalidVersion
+	ErrAccessDenied   = storage.ErrAccessDenied
+	// ErrValidation matches a RAiD rejected by the metadata schema rules
+	ErrValidation = storage.ErrValidation
+	// ErrConflict matches an update made to a version other than the
+	// current one
+	ErrConflict = storage.ErrConflict
+	// ErrBackendUnavailable matches the backend failing, e.g. timing out
+	// or refusing connections
+	ErrBackendUnavailable = storage.ErrBackendUnavailable
+)
+
+// Options are the storage.options of the configuration
+type Options map[string]interface{}
+
+// Decode converts the options to v, a pointer to a struct whose fields are
+// matched by their JSON names
+func (o Options) Decode(v interface{}) error {
+	data, err := json.Marshal(o)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid storage options: %w", err)
+	}
+	return nil
+}
+
+// Factory opens a backend with the configured options
+type Factory func(opts Options) (Repository, error)
+
+// builtin are the storage types of the backends in this module
+var builtin = map[string]bool{
+	string(storage.StorageTypeFile):      true,
+	string(storage.StorageTypeFileGit):   true,
+	string(storage.StorageTypeFDB):       true,
+	string(storage.StorageTypeCockroach): true,
+}
+
+// Register makes a backend available under the storage type name. It
+// panics if name is empty, is the name of a built-in backend or is
+// registered twice.
+func Register(name string, factory Factory) {
+	if name == "" || builtin[name] {
+		panic(fmt.Sprintf("storage: cannot register backend %q", name))
+	}
+	if storage.Registered(storage.StorageType(name)) {
+		panic(fmt.Sprintf("storage: backend %q registered twice", name))
+	}
+	storage.RegisterFactory(storage.StorageType(name), func(cfg interface{}) (storage.Repository, error) {
+		opts, _ := cfg.(map[string]interface{})
+		return factory(opts)
+	})
+}
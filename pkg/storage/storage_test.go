@@ -0,0 +1,65 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/leifj/go-raid/internal/storage/file"
+	"github.com/leifj/go-raid/pkg/raid"
+	"github.com/leifj/go-raid/pkg/server"
+	"github.com/leifj/go-raid/pkg/storage"
+)
+
+func TestRegister(t *testing.T) {
+	var got struct {
+		Dir   string `json:"dir"`
+		Shard int    `json:"shard"`
+	}
+	storage.Register("test-plugin", func(opts storage.Options) (storage.Repository, error) {
+		if err := opts.Decode(&got); err != nil {
+			return nil, err
+		}
+		return file.New(&file.Config{DataDir: got.Dir})
+	})
+
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "raid.yaml")
+	os.WriteFile(cfgFile, []byte("storage:\n  type: test-plugin\n  options:\n    dir: "+dir+"\n    shard: 3\n"), 0644)
+
+	cfg, err := server.LoadConfig(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cfg.Summary(), "storage: type=test-plugin options=dir,shard") {
+		t.Errorf("unexpected summary:\n%s", cfg.Summary())
+	}
+	repo, err := server.NewRepository(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	if got.Dir != dir || got.Shard != 3 {
+		t.Errorf("factory received %+v", got)
+	}
+
+	sp, err := repo.CreateServicePoint(context.Background(), &raid.ServicePoint{Name: "Plugin SP"})
+	if err != nil || sp.ID == 0 {
+		t.Errorf("expected the plugin backend to work, got %v", err)
+	}
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	for _, name := range []string{"", "file", "cockroach"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected registering %q to panic", name)
+				}
+			}()
+			storage.Register(name, nil)
+		}()
+	}
+}
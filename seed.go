@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/seed"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// runSeed implements "raid-server seed": it creates demo service points and
+// sample RAiDs in the configured backend and prints a JSON summary to stdout
+func runSeed(args []string) int {
+	fs := flag.NewFlagSet("seed", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	raids := fs.Int("raids", seed.DefaultRAiDs, "number of sample RAiDs to create")
+	randSeed := fs.Int64("seed", time.Now().UnixNano(), "random seed; the same seed generates the same content")
+	fs.Parse(args)
+
+	if *raids < 1 {
+		log.Printf("seed: -raids must be at least 1")
+		return 2
+	}
+
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 2
+	}
+
+	repo, err := storage.NewRepository(&cfg.Storage)
+	if err != nil {
+		log.Printf("Failed to initialize storage: %v", err)
+		return 2
+	}
+	defer repo.Close()
+
+	log.Printf("Seeding %s storage with %d sample RAiDs (seed %d)", cfg.Storage.Type, *raids, *randSeed)
+
+	summary, err := seed.Run(context.Background(), repo, seed.Options{RAiDs: *raids, Seed: *randSeed})
+	if summary != nil {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(summary)
+	}
+	if err != nil {
+		log.Printf("Seeding failed: %v", err)
+		return 1
+	}
+	return 0
+}
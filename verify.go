@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/leifj/go-raid/internal/chain"
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// runVerify implements "raid-server verify": it checks the configured
+// storage backend and the hash chains of version history, prints a JSON
+// report to stdout and returns the exit code (0 when no unrepaired issues
+// were found, 1 otherwise, 2 on failure)
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	repair := fs.Bool("repair", false, "repair problems where supported (file backends only)")
+	anchors := fs.String("anchors", "", "verify version history against the newest anchor in this file (default history.anchorFile)")
+	fs.Parse(args)
+
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Printf("Failed to load configuration: %v", err)
+		return 2
+	}
+	if *anchors == "" {
+		*anchors = cfg.History.AnchorFile
+	}
+
+	repo, err := storage.NewRepository(&cfg.Storage)
+	if err != nil {
+		log.Printf("Failed to initialize storage: %v", err)
+		return 2
+	}
+	defer repo.Close()
+
+	verifier, ok := repo.(storage.Verifier)
+	if !ok {
+		log.Printf("Storage backend %s does not support verification", cfg.Storage.Type)
+		return 2
+	}
+
+	report, err := verifier.Verify(context.Background(), storage.VerifyOptions{Repair: *repair})
+	if err != nil {
+		log.Printf("Verification failed: %v", err)
+		return 2
+	}
+	var anchor *chain.Anchor
+	if path := *anchors; path != "" {
+		if anchor, err = chain.LastAnchor(path); err != nil {
+			log.Printf("Failed to read anchors: %v", err)
+			return 2
+		}
+	}
+	if err := chain.VerifyAll(context.Background(), repo, report, anchor); err != nil {
+		log.Printf("Verification failed: %v", err)
+		return 2
+	}
+	report.Finish()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+
+	if !report.OK() {
+		fmt.Fprintf(os.Stderr, "%d issues found (%d repaired)\n", len(report.Issues), report.Repaired)
+		return 1
+	}
+	return 0
+}
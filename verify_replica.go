@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/leifj/go-raid/internal/backup"
+	"github.com/leifj/go-raid/internal/config"
+	"github.com/leifj/go-raid/internal/migrate"
+	"github.com/leifj/go-raid/internal/storage"
+)
+
+// replicaResult is the JSON printed by verify-replica
+type replicaResult struct {
+	*migrate.Report
+	// Repaired counts the items copied into the replica with -repair; the
+	// report then describes the replica after the repair
+	Repaired int `json:"repaired,omitempty"`
+}
+
+// runVerifyReplica implements "raid-server verify-replica": it compares the
+// configured backend with the backend described by -to, or with the backup
+// archive -backup, by counts, identifiers and record checksums. It prints
+// the differences as JSON to stdout and exits 1 if there are any.
+func runVerifyReplica(args []string) int {
+	fs := flag.NewFlagSet("verify-replica", flag.ExitOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "source configuration file (environment overrides apply)")
+	replicaFile := fs.String("to", "", "replica configuration file (environment overrides do not apply)")
+	backupFile := fs.String("backup", "", "backup archive to compare with instead of a replica")
+	repair := fs.Bool("repair", false, "copy missing and differing items from the source into the replica")
+	fs.Parse(args)
+
+	if (*replicaFile == "") == (*backupFile == "") {
+		log.Printf("verify-replica: exactly one of -to and -backup is required")
+		fs.Usage()
+		return 2
+	}
+	if *repair && *backupFile != "" {
+		log.Printf("verify-replica: -repair needs a replica given with -to")
+		return 2
+	}
+
+	srcCfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Printf("Failed to load source configuration: %v", err)
+		return 2
+	}
+	src, err := storage.NewRepository(&srcCfg.Storage)
+	if err != nil {
+		log.Printf("Failed to initialize source storage: %v", err)
+		return 2
+	}
+	defer src.Close()
+	srcSnap, ok := src.(migrate.Snapshot)
+	if !ok {
+		log.Printf("Source: %v", backup.ErrUnsupported)
+		return 2
+	}
+
+	var dst storage.Repository
+	var dstSnap migrate.Snapshot
+	if *backupFile != "" {
+		archive, err := backup.OpenArchive(*backupFile)
+		if err != nil {
+			log.Printf("Failed to open backup archive: %v", err)
+			return 2
+		}
+		dstSnap = archive
+		log.Printf("Comparing %s storage with backup %s", srcCfg.Storage.Type, *backupFile)
+	} else {
+		dstCfg, err := config.LoadFileOnly(*replicaFile)
+		if err != nil {
+			log.Printf("Failed to load replica configuration: %v", err)
+			return 2
+		}
+		dst, err = storage.NewRepository(&dstCfg.Storage)
+		if err != nil {
+			log.Printf("Failed to initialize replica storage: %v", err)
+			return 2
+		}
+		defer dst.Close()
+		if dstSnap, ok = dst.(migrate.Snapshot); !ok {
+			log.Printf("Replica: %v", backup.ErrUnsupported)
+			return 2
+		}
+		log.Printf("Comparing %s storage with %s storage", srcCfg.Storage.Type, dstCfg.Storage.Type)
+	}
+
+	ctx := context.Background()
+	report, err := migrate.Compare(ctx, srcSnap, dstSnap)
+	if err != nil {
+		log.Printf("Comparison failed: %v", err)
+		return 2
+	}
+	result := replicaResult{Report: report}
+
+	if *repair && !report.Consistent() {
+		log.Printf("Repairing %d missing and %d differing RAiDs, %d missing and %d differing service points",
+			len(report.Missing), len(report.Differing), len(report.MissingServicePoints), len(report.DifferingServicePoints))
+		result.Repaired, err = migrate.Repair(ctx, srcSnap, dst, report)
+		if err != nil {
+			log.Printf("Repair failed after %d items: %v", result.Repaired, err)
+			return 2
+		}
+		if result.Report, err = migrate.Compare(ctx, srcSnap, dstSnap); err != nil {
+			log.Printf("Comparison after repair failed: %v", err)
+			return 2
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(result)
+
+	if !result.Consistent() {
+		log.Printf("Replica differs: %d missing, %d extra and %d differing RAiDs",
+			len(result.Missing), len(result.Extra), len(result.Differing))
+		return 1
+	}
+	log.Printf("Replica is consistent: %d items match", result.Matched)
+	return 0
+}